@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"neonexcore/pkg/sdkgen"
+
+	"github.com/spf13/cobra"
+)
+
+type clientGenerateOptions struct {
+	dir       string
+	modules   string
+	out       string
+	languages []string
+	basePath  string
+	static    bool
+	check     bool
+}
+
+func newClientGenerateCommand() *cobra.Command {
+	opts := &clientGenerateOptions{}
+	cmd := &cobra.Command{
+		Use:   "client:generate",
+		Short: "Generate typed Go and TypeScript clients for the modules' REST endpoints",
+		Long: `Generates a client per module from the swag annotations of its handlers
+(@Router, @Param, @Success and @Produce) and the Go types they name.
+Boots the application in --dir as the routes command does, so methods and
+paths are those the modules actually register; with --static, paths come
+from the @Router annotations under --base-path without booting it.
+
+Writes to --out:
+
+  <module>client/   a Go package per module, built on neonexcore/pkg/sdk
+                    for authentication, retries and errors
+  typescript/       client.ts, a file per module and index.ts, using fetch
+
+Handlers without annotations take and return untyped JSON. Redirects and
+routes whose handler is not a method of the module are left out and
+listed.
+
+With --check, writes nothing and exits with status 1 when the files on
+disk differ from what the handlers generate, so CI can keep them in sync.`,
+		Example: `  neonex client:generate
+  neonex client:generate --lang go --out internal/clients
+  neonex client:generate --static --check`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runClientGenerate(cmd.OutOrStdout(), opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.dir, "dir", ".", "application directory")
+	flags.StringVar(&opts.modules, "modules", "modules", "modules directory, relative to --dir")
+	flags.StringVar(&opts.out, "out", "clients", "output directory, relative to --dir")
+	flags.StringSliceVar(&opts.languages, "lang", []string{"go", "typescript"}, "languages to generate (go, typescript)")
+	flags.StringVar(&opts.basePath, "base-path", "/api/v1", "prefix of @Router paths, with --static")
+	flags.BoolVar(&opts.static, "static", false, "read paths from annotations instead of booting the application")
+	flags.BoolVar(&opts.check, "check", false, "fail if the files on disk are out of date instead of writing them")
+	return cmd
+}
+
+func runClientGenerate(out io.Writer, opts *clientGenerateOptions) error {
+	loadOpts := sdkgen.Options{BasePath: opts.basePath}
+	if !opts.static {
+		table, err := loadRouteTable(opts.dir)
+		if err != nil {
+			return err
+		}
+		loadOpts.Routes = table
+	}
+
+	modules, err := sdkgen.LoadAll(filepath.Join(opts.dir, opts.modules), loadOpts)
+	if err != nil {
+		return err
+	}
+	files, err := sdkgen.Generate(modules, opts.languages)
+	if err != nil {
+		return err
+	}
+
+	outDir := filepath.Join(opts.dir, opts.out)
+	if filepath.IsAbs(opts.out) {
+		outDir = opts.out
+	}
+	if opts.check {
+		if err := checkGeneratedFiles(out, outDir, files, sdkgen.GeneratedMarker, "Clients", "neonex client:generate"); err != nil {
+			return err
+		}
+	} else if err := writeGeneratedFiles(out, outDir, opts.out, files, sdkgen.GeneratedMarker); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	for _, m := range modules {
+		fmt.Fprintf(out, "%s: %d endpoint(s), %d type(s)\n", m.Name, len(m.Endpoints), len(m.Types))
+		for _, warning := range m.Warnings {
+			fmt.Fprintf(out, "  note: %s\n", warning)
+		}
+	}
+	return nil
+}
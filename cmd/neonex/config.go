@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"neonexcore/pkg/envconfig"
+
+	"github.com/spf13/cobra"
+)
+
+type configCheckOptions struct {
+	env          string
+	dir          string
+	modules      string
+	example      string
+	diff         string
+	processEnv   bool
+	allowUnknown bool
+	failOnDiff   bool
+	json         bool
+}
+
+func newConfigCheckCommand() *cobra.Command {
+	opts := &configCheckOptions{}
+	cmd := &cobra.Command{
+		Use:   "config:check",
+		Short: "Validate an environment's configuration and diff it against another",
+		Long: `Loads .env and .env.<env> for the target environment and checks every
+value against the keys the framework and the modules read (module.json
+"env" lists). Reports invalid values, required keys that are missing and
+keys nothing reads. With --diff, also lists the keys set differently in
+another environment; secret values are masked.
+
+Exits with status 1 when a check fails, so it can gate a deploy.`,
+		Example: `  neonex config:check --env production
+  neonex config:check --env production --diff staging
+  neonex config:check --env staging --process-env --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigCheck(cmd.OutOrStdout(), opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.env, "env", "e", os.Getenv("APP_ENV"), "environment to check (reads .env.<env> over .env)")
+	flags.StringVar(&opts.dir, "dir", ".", "directory with the .env files")
+	flags.StringVar(&opts.modules, "modules", "modules", "modules directory")
+	flags.StringVar(&opts.example, "example", ".env.example", "example file listing the documented keys")
+	flags.StringVar(&opts.diff, "diff", "", "environment to compare with")
+	flags.BoolVar(&opts.processEnv, "process-env", false, "apply known keys set in the process environment")
+	flags.BoolVar(&opts.allowUnknown, "allow-unknown", false, "do not fail on keys nothing reads")
+	flags.BoolVar(&opts.failOnDiff, "fail-on-diff", false, "fail when the environments differ")
+	flags.BoolVar(&opts.json, "json", false, "print the result as JSON")
+	return cmd
+}
+
+type configCheckResult struct {
+	Reports     []*envconfig.Report    `json:"reports"`
+	Differences []envconfig.Difference `json:"differences,omitempty"`
+}
+
+func runConfigCheck(out io.Writer, opts *configCheckOptions) error {
+	schema := envconfig.NewSchema()
+	if err := schema.Add("core", envconfig.CoreVars()...); err != nil {
+		return err
+	}
+	if err := schema.LoadModules(opts.modules); err != nil {
+		return err
+	}
+	example := opts.example
+	if !filepath.IsAbs(example) {
+		example = filepath.Join(opts.dir, example)
+	}
+	if err := schema.LoadExample(example); err != nil {
+		return err
+	}
+
+	names := []string{opts.env}
+	if opts.diff != "" {
+		names = append(names, opts.diff)
+	}
+	result := &configCheckResult{}
+	environments := make([]*envconfig.Environment, 0, len(names))
+	for _, name := range names {
+		env, err := envconfig.Load(opts.dir, name)
+		if errors.Is(err, fs.ErrNotExist) && opts.processEnv {
+			env, err = &envconfig.Environment{Name: name, Values: map[string]string{}, Sources: map[string]string{}}, nil
+		}
+		if err != nil {
+			return err
+		}
+		// A misspelled environment would otherwise check .env alone
+		if name != "" && len(env.Files) < 2 && !opts.processEnv {
+			return fmt.Errorf("no .env.%s in %s; use --process-env if the environment is configured elsewhere", name, opts.dir)
+		}
+		if opts.processEnv {
+			env.Overlay(schema.Keys())
+		}
+		environments = append(environments, env)
+		result.Reports = append(result.Reports, schema.Check(env))
+	}
+	if len(environments) == 2 {
+		result.Differences = schema.Diff(environments[0], environments[1])
+	}
+
+	if opts.json {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		printConfigCheck(out, result, names)
+	}
+
+	failed := false
+	for _, report := range result.Reports {
+		if len(report.Invalid) > 0 || len(report.Missing) > 0 || (len(report.Unknown) > 0 && !opts.allowUnknown) {
+			failed = true
+		}
+	}
+	if opts.failOnDiff && len(result.Differences) > 0 {
+		failed = true
+	}
+	if failed {
+		return errors.New("configuration check failed")
+	}
+	return nil
+}
+
+func printConfigCheck(out io.Writer, result *configCheckResult, names []string) {
+	for _, report := range result.Reports {
+		fmt.Fprintf(out, "%s (%s): %d keys set\n", environmentName(report.Environment), strings.Join(report.Files, ", "), report.Checked)
+		if report.OK() {
+			fmt.Fprintln(out, "  ok")
+		}
+		printIssues(out, "Invalid", report.Invalid)
+		printIssues(out, "Missing", report.Missing)
+		printIssues(out, "Unknown", report.Unknown)
+		fmt.Fprintln(out)
+	}
+
+	if len(names) < 2 {
+		return
+	}
+	left, right := environmentName(names[0]), environmentName(names[1])
+	if len(result.Differences) == 0 {
+		fmt.Fprintf(out, "%s and %s are set the same\n", left, right)
+		return
+	}
+	fmt.Fprintf(out, "%d keys differ between %s and %s:\n", len(result.Differences), left, right)
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "  KEY\tMODULE\t%s\t%s\n", strings.ToUpper(left), strings.ToUpper(right))
+	for _, d := range result.Differences {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", d.Key, d.Module, diffValue(d.Left, d.LeftSet), diffValue(d.Right, d.RightSet))
+	}
+	w.Flush()
+}
+
+func printIssues(out io.Writer, title string, issues []envconfig.Issue) {
+	if len(issues) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "  %s:\n", title)
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, issue := range issues {
+		fmt.Fprintf(w, "    %s\t%s\t%s\t%s\n", issue.Key, issue.Module, issue.Source, issue.Message)
+	}
+	w.Flush()
+}
+
+func environmentName(name string) string {
+	if name == "" {
+		return "default"
+	}
+	return name
+}
+
+func diffValue(value string, set bool) string {
+	switch {
+	case !set:
+		return "(unset)"
+	case value == "":
+		return `""`
+	default:
+		return value
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"neonexcore/internal/config"
+	"neonexcore/pkg/deploy"
+	"neonexcore/pkg/envconfig"
+
+	"github.com/spf13/cobra"
+)
+
+type deployGenerateOptions struct {
+	env         string
+	dir         string
+	modules     string
+	out         string
+	name        string
+	image       string
+	replicas    int
+	maxReplicas int
+	cpuTarget   int
+	check       bool
+}
+
+func newDeployGenerateCommand() *cobra.Command {
+	opts := &deployGenerateOptions{}
+	cmd := &cobra.Command{
+		Use:   "deploy:generate",
+		Short: "Generate Dockerfile, Compose and Kubernetes manifests from the configuration",
+		Long: `Derives the deployment of an environment from its .env and .env.<env>
+files, after applying APP_PROFILE: the port, the database, Redis,
+Memcached and Qdrant it uses, and the volumes SQLite and local storage
+need. Writes to --out:
+
+  Dockerfile, Dockerfile.dockerignore
+  docker-compose.yml   the app and the backing services it points at
+                       on this machine
+  k8s/                 config map, deployment, service, autoscaler and
+                       volume claims
+
+Secret values are never written. Compose reads them from the environment
+files at runtime; in Kubernetes they come from a secret created separately.
+An app on SQLite runs a single replica without an autoscaler.
+
+With --check, writes nothing and exits with status 1 when the files on
+disk differ from what the configuration generates, so CI can keep them in
+sync.`,
+		Example: `  neonex deploy:generate --env production
+  neonex deploy:generate --env staging --out deploy/staging --image registry.example.com/shop:1.4
+  neonex deploy:generate --env production --check`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDeployGenerate(cmd.OutOrStdout(), opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.env, "env", "e", os.Getenv("APP_ENV"), "environment to deploy (reads .env.<env> over .env)")
+	flags.StringVar(&opts.dir, "dir", ".", "application directory")
+	flags.StringVar(&opts.modules, "modules", "modules", "modules directory, relative to --dir")
+	flags.StringVar(&opts.out, "out", "deploy", "output directory, relative to --dir")
+	flags.StringVar(&opts.name, "name", "", "app name (default APP_NAME)")
+	flags.StringVar(&opts.image, "image", "", "image to build and deploy (default <name>:latest)")
+	flags.IntVar(&opts.replicas, "replicas", 2, "replicas, and the autoscaler's minimum")
+	flags.IntVar(&opts.maxReplicas, "max-replicas", 10, "autoscaler maximum")
+	flags.IntVar(&opts.cpuTarget, "cpu-target", 70, "average CPU utilization the autoscaler aims for, in percent")
+	flags.BoolVar(&opts.check, "check", false, "fail if the files on disk are out of date instead of writing them")
+	return cmd
+}
+
+func runDeployGenerate(out io.Writer, opts *deployGenerateOptions) error {
+	schema := envconfig.NewSchema()
+	if err := schema.Add("core", envconfig.CoreVars()...); err != nil {
+		return err
+	}
+	if err := schema.LoadModules(filepath.Join(opts.dir, opts.modules)); err != nil {
+		return err
+	}
+
+	env, err := envconfig.Load(opts.dir, opts.env)
+	if err != nil {
+		return err
+	}
+	// A misspelled environment would otherwise deploy .env alone
+	if opts.env != "" && len(env.Files) < 2 {
+		return fmt.Errorf("no .env.%s in %s", opts.env, opts.dir)
+	}
+	resolved, err := config.ResolveProfile(env.Values["APP_PROFILE"], env.Values)
+	if err != nil {
+		return err
+	}
+
+	outDir := filepath.Join(opts.dir, opts.out)
+	if filepath.IsAbs(opts.out) {
+		outDir = opts.out
+	}
+	context, err := relativePath(outDir, opts.dir)
+	if err != nil {
+		return err
+	}
+	dockerfile, err := relativePath(opts.dir, filepath.Join(outDir, "Dockerfile"))
+	if err != nil {
+		return err
+	}
+	envFiles := make([]string, len(env.Files))
+	for i, file := range env.Files {
+		if envFiles[i], err = relativePath(outDir, file); err != nil {
+			return err
+		}
+	}
+
+	spec, err := deploy.Build(env, schema, deploy.Options{
+		Name:        opts.name,
+		Image:       opts.image,
+		GoVersion:   goVersion(filepath.Join(opts.dir, "go.mod")),
+		Replicas:    opts.replicas,
+		MaxReplicas: opts.maxReplicas,
+		CPUTarget:   opts.cpuTarget,
+		Context:     context,
+		Dockerfile:  dockerfile,
+		EnvFiles:    envFiles,
+		Resolved:    resolved,
+	})
+	if err != nil {
+		return err
+	}
+	files, err := deploy.Generate(spec)
+	if err != nil {
+		return err
+	}
+
+	if opts.check {
+		return checkGeneratedFiles(out, outDir, files, deploy.GeneratedMarker, "Deployment files", "neonex deploy:generate")
+	}
+	if err := writeGeneratedFiles(out, outDir, opts.out, files, deploy.GeneratedMarker); err != nil {
+		return err
+	}
+	printDeploySummary(out, spec, opts)
+	return nil
+}
+
+func printDeploySummary(out io.Writer, spec *deploy.Spec, opts *deployGenerateOptions) {
+	fmt.Fprintf(out, "\n%s: port %d, %d replica(s)", spec.Name, spec.Port, spec.Replicas)
+	if spec.Scalable {
+		fmt.Fprintf(out, " scaling to %d", max(spec.MaxReplicas, spec.Replicas))
+	}
+	fmt.Fprintln(out)
+	if len(spec.Services) > 0 {
+		services := make([]string, len(spec.Services))
+		for i, service := range spec.Services {
+			services[i] = service.Name + " (" + service.Image + ")"
+		}
+		fmt.Fprintf(out, "Compose services: %s\n", strings.Join(services, ", "))
+	}
+	for _, warning := range spec.Warnings {
+		fmt.Fprintf(out, "Note: %s\n", warning)
+	}
+	if len(spec.Secrets) > 0 {
+		fmt.Fprintf(out, "\nCreate the Kubernetes secret %s with: %s\n", deploy.SecretName(spec), strings.Join(spec.Secrets, ", "))
+	}
+
+	// Compose substitutes service passwords from the environment files
+	command := "docker compose -f " + filepath.Join(opts.out, "docker-compose.yml")
+	for _, file := range spec.Options.EnvFiles {
+		command += " --env-file " + filepath.Base(file)
+	}
+	fmt.Fprintf(out, "\nRun with Compose (in %s): %s up --build\n", opts.dir, command)
+	fmt.Fprintf(out, "Apply to a cluster: kubectl apply -f %s\n", filepath.Join(opts.out, "k8s"))
+}
+
+// relativePath returns target relative to base, slash-separated as
+// Compose and Docker expect
+func relativePath(base, target string) (string, error) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absBase, absTarget)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// goVersion returns the major.minor Go version of a go.mod, empty when it
+// cannot be read
+func goVersion(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "go" {
+			if parts := strings.SplitN(fields[1], ".", 3); len(parts) >= 2 {
+				return parts[0] + "." + parts[1]
+			}
+			return fields[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// writeGeneratedFiles writes files, by slash-separated path relative to
+// outDir, and removes the files a previous run generated that are no
+// longer. Paths are reported under display, outDir as the user gave it.
+func writeGeneratedFiles(out io.Writer, outDir, display string, files map[string][]byte, marker string) error {
+	stale, err := generatedFiles(outDir, marker)
+	if err != nil {
+		return err
+	}
+	names := sortedFileNames(files)
+	for _, name := range names {
+		delete(stale, name)
+		path := filepath.Join(outDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, files[name], 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "  wrote   %s\n", filepath.Join(display, filepath.FromSlash(name)))
+	}
+	removed := make([]string, 0, len(stale))
+	for name := range stale {
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		if err := os.Remove(filepath.Join(outDir, filepath.FromSlash(name))); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "  removed %s\n", filepath.Join(display, filepath.FromSlash(name)))
+	}
+	return nil
+}
+
+// checkGeneratedFiles compares files with those on disk, failing when any
+// differ, are missing or are no longer generated. what names the files
+// in messages and command regenerates them.
+func checkGeneratedFiles(out io.Writer, outDir string, files map[string][]byte, marker, what, command string) error {
+	stale, err := generatedFiles(outDir, marker)
+	if err != nil {
+		return err
+	}
+	var outdated []string
+	for _, name := range sortedFileNames(files) {
+		delete(stale, name)
+		data, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(name)))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			outdated = append(outdated, name+" (missing)")
+		case err != nil:
+			return err
+		case !bytes.Equal(data, files[name]):
+			outdated = append(outdated, name)
+		}
+	}
+	for name := range stale {
+		outdated = append(outdated, name+" (no longer generated)")
+	}
+	sort.Strings(outdated)
+
+	if len(outdated) == 0 {
+		fmt.Fprintf(out, "%s are up to date\n", what)
+		return nil
+	}
+	fmt.Fprintf(out, "%s out of date in %s:\n", what, outDir)
+	for _, name := range outdated {
+		fmt.Fprintf(out, "  %s\n", name)
+	}
+	return fmt.Errorf("%s are out of date; run %s", strings.ToLower(what), command)
+}
+
+// generatedFiles returns the files under dir a previous run generated,
+// those whose first line is a comment with marker, by slash-separated path
+// relative to dir
+func generatedFiles(dir, marker string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == dir {
+			return fs.SkipAll
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		line, _ := bufio.NewReader(file).ReadString('\n')
+		if strings.Contains(line, marker) {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(rel)] = true
+		}
+		return nil
+	})
+	return files, err
+}
+
+func sortedFileNames(files map[string][]byte) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,26 @@
+// Command neonex is the Neonex Core command line tool
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	root := &cobra.Command{
+		Use:          "neonex",
+		Short:        "Neonex Core command line tool",
+		SilenceUsage: true,
+	}
+	root.AddCommand(newConfigCheckCommand())
+	root.AddCommand(newRoutesCommand())
+	root.AddCommand(newServeCommand())
+	root.AddCommand(newMigrateCommand())
+	root.AddCommand(newDeployGenerateCommand())
+	root.AddCommand(newClientGenerateCommand())
+
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"neonexcore/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+type migrateOptions struct {
+	dir string
+	dev bool
+}
+
+func newMigrateCommand() *cobra.Command {
+	opts := &migrateOptions{}
+	cmd := &cobra.Command{
+		Use:   "migrate [up|status|install|uninstall|rollback] [module]",
+		Short: "Migrate the database, or one module's schema",
+		Long: `Runs the application in --dir as ` + "`migrate`" + ` to manage the database
+schema without serving.
+
+Each module owns the tables of the models it registers and those created
+by the SQL migrations in its modules/<module>/migrations directory, named
+<version>_<name>.up.sql with an optional <version>_<name>.down.sql.
+Applied migrations are tracked per module.
+
+  up                 migrate the core models and every installed module
+                     (the default)
+  status             list modules with their applied and pending
+                     migrations, tables and foreign key dependencies
+  install <module>   install a module's schema, or apply its pending
+                     migrations
+  uninstall <module> roll back a module's migrations and drop its tables;
+                     ` + "`up`" + ` leaves it uninstalled until installed again
+  rollback <module>  roll back the module's last batch of migrations
+
+Modules are installed after the modules their foreign keys reference,
+and cannot be uninstalled while an installed module references them.`,
+		Example: `  neonex migrate
+  neonex migrate status
+  neonex migrate uninstall links --dev`,
+		Args:      cobra.RangeArgs(0, 2),
+		ValidArgs: []string{"up", "status", "install", "uninstall", "rollback"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMigrate(opts, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.dir, "dir", ".", "application directory")
+	flags.BoolVar(&opts.dev, "dev", false, "use the zero-dependency development profile")
+	return cmd
+}
+
+func runMigrate(opts *migrateOptions, args []string) error {
+	run := exec.Command("go", append([]string{"run", ".", "migrate"}, args...)...)
+	run.Dir = opts.dir
+	run.Stdin, run.Stdout, run.Stderr = os.Stdin, os.Stdout, os.Stderr
+	run.Env = os.Environ()
+	if opts.dev {
+		run.Env = append(run.Env, "APP_PROFILE="+config.ProfileDev)
+	}
+
+	if err := run.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		return fmt.Errorf("running the application in %s: %w", filepath.Clean(opts.dir), err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"neonexcore/pkg/api"
+
+	"github.com/spf13/cobra"
+)
+
+type routesOptions struct {
+	dir    string
+	module string
+	method string
+	json   bool
+}
+
+func newRoutesCommand() *cobra.Command {
+	opts := &routesOptions{}
+	cmd := &cobra.Command{
+		Use:   "routes",
+		Short: "List registered routes and fail on conflicting registrations",
+		Long: `Boots the application in --dir without migrating or serving, and lists
+every route with the module that registered it, the middleware that runs
+before its handler and the authentication it requires. Middleware in front
+of every route is listed once, above the table.
+
+Exits with status 1 when a route is registered twice, or can never be
+reached because an earlier route with parameters matches it first.`,
+		Example: `  neonex routes
+  neonex routes --module links
+  neonex routes --json > routes.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRoutes(cmd.OutOrStdout(), opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.dir, "dir", ".", "application directory")
+	flags.StringVar(&opts.module, "module", "", "only list routes registered by this module")
+	flags.StringVar(&opts.method, "method", "", "only list routes for this HTTP method")
+	flags.BoolVar(&opts.json, "json", false, "print the result as JSON")
+	return cmd
+}
+
+func runRoutes(out io.Writer, opts *routesOptions) error {
+	table, err := loadRouteTable(opts.dir)
+	if err != nil {
+		return err
+	}
+
+	routes := make([]api.RouteInfo, 0, len(table.Routes))
+	for _, route := range table.Routes {
+		if opts.module != "" && route.Module != opts.module {
+			continue
+		}
+		if opts.method != "" && !strings.EqualFold(route.Method, opts.method) {
+			continue
+		}
+		routes = append(routes, route)
+	}
+	filtered := &api.RouteTable{Global: table.Global, Routes: routes, Conflicts: table.Conflicts}
+
+	if opts.json {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(filtered); err != nil {
+			return err
+		}
+	} else {
+		printRoutes(out, filtered)
+	}
+
+	if len(table.Conflicts) > 0 {
+		return fmt.Errorf("%d conflicting route registrations", len(table.Conflicts))
+	}
+	return nil
+}
+
+// loadRouteTable runs the application as `routes <file>` and reads the
+// table it writes. Its boot output is only shown if it fails.
+func loadRouteTable(dir string) (*api.RouteTable, error) {
+	file, err := os.CreateTemp("", "neonex-routes-*.json")
+	if err != nil {
+		return nil, err
+	}
+	path := file.Name()
+	file.Close()
+	defer os.Remove(path)
+
+	var output bytes.Buffer
+	run := exec.Command("go", "run", ".", "routes", path)
+	run.Dir = dir
+	run.Stdout, run.Stderr = &output, &output
+	if err := run.Run(); err != nil {
+		os.Stderr.Write(output.Bytes())
+		return nil, fmt.Errorf("running the application in %s: %w", filepath.Clean(dir), err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.New("the application did not write a route table; does its main handle `routes`?")
+	}
+	table := &api.RouteTable{}
+	if err := json.Unmarshal(data, table); err != nil {
+		return nil, fmt.Errorf("reading the route table: %w", err)
+	}
+	return table, nil
+}
+
+func printRoutes(out io.Writer, table *api.RouteTable) {
+	if len(table.Global) > 0 {
+		fmt.Fprintf(out, "Global middleware: %s\n\n", strings.Join(table.Global, ", "))
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tPATH\tMODULE\tAUTH\tMIDDLEWARE\tHANDLER")
+	for _, route := range table.Routes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", route.Method, route.Path, route.Module,
+			listOrDash(route.Auth), listOrDash(route.Middleware), route.Handler)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n%d routes\n", len(table.Routes))
+
+	if len(table.Conflicts) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\n%d conflicts:\n", len(table.Conflicts))
+	for _, conflict := range table.Conflicts {
+		fmt.Fprintf(out, "  %s: %s\n", conflict.Kind, conflict.Message)
+	}
+}
+
+func listOrDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ", ")
+}
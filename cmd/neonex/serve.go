@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"neonexcore/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+type serveOptions struct {
+	dir string
+	dev bool
+}
+
+func newServeCommand() *cobra.Command {
+	opts := &serveOptions{}
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run the application",
+		Long: `Builds and runs the application in --dir with the current environment.
+
+With --dev, runs it with the zero-dependency development profile
+(APP_PROFILE=dev): a SQLite database file, an in-memory cache, an embedded
+message queue and local storage, whatever the environment says about
+them, so a fresh checkout serves without Docker or any other service.
+Production configurations set CACHE_DRIVER, QUEUE_DRIVER and DB_DRIVER to
+their real backends and leave APP_PROFILE unset.`,
+		Example: `  neonex serve --dev
+  neonex serve --dir ./myapp`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runServe(opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.dir, "dir", ".", "application directory")
+	flags.BoolVar(&opts.dev, "dev", false, "use the zero-dependency development profile")
+	return cmd
+}
+
+func runServe(opts *serveOptions) error {
+	run := exec.Command("go", "run", ".")
+	run.Dir = opts.dir
+	run.Stdin, run.Stdout, run.Stderr = os.Stdin, os.Stdout, os.Stderr
+	run.Env = os.Environ()
+	if opts.dev {
+		run.Env = append(run.Env, "APP_PROFILE="+config.ProfileDev)
+	}
+
+	// Ctrl-C reaches the application too; wait for it to shut down
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	if err := run.Start(); err != nil {
+		return fmt.Errorf("running the application in %s: %w", filepath.Clean(opts.dir), err)
+	}
+	go func() {
+		for sig := range signals {
+			if run.Process != nil {
+				run.Process.Signal(sig)
+			}
+		}
+	}()
+
+	if err := run.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		return err
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/glebarez/sqlite"
@@ -55,8 +57,51 @@ func LoadDatabaseConfig() *DatabaseConfig {
 	}
 }
 
+// SandboxEnabled reports whether test mode API keys and the sandbox
+// database are enabled
+func SandboxEnabled() bool {
+	return getEnv("SANDBOX_ENABLED", "false") == "true"
+}
+
+// LoadSandboxDatabaseConfig loads the sandbox database configuration. It
+// shares the live database's server and credentials; the database name
+// defaults to the live name with a _sandbox suffix.
+func LoadSandboxDatabaseConfig() *DatabaseConfig {
+	config := LoadDatabaseConfig()
+	config.Database = getEnv("SANDBOX_DB_DATABASE", sandboxDatabaseName(config))
+	return config
+}
+
+func sandboxDatabaseName(config *DatabaseConfig) string {
+	switch config.Driver {
+	case "sqlite":
+		ext := filepath.Ext(config.Database)
+		return strings.TrimSuffix(config.Database, ext) + "_sandbox" + ext
+	default:
+		return config.Database + "_sandbox"
+	}
+}
+
 // InitDatabase initializes database connection
 func InitDatabase(config *DatabaseConfig) (*DatabaseManager, error) {
+	db, err := OpenDatabase(config)
+	if err != nil {
+		return nil, err
+	}
+
+	manager := &DatabaseManager{
+		db:     db,
+		config: config,
+	}
+
+	DB = manager
+
+	fmt.Printf("✅ Database connected: %s\n", config.Driver)
+	return manager, nil
+}
+
+// OpenDatabase opens a connection without making it the global DB
+func OpenDatabase(config *DatabaseConfig) (*gorm.DB, error) {
 	var dialector gorm.Dialector
 
 	switch config.Driver {
@@ -123,15 +168,7 @@ func InitDatabase(config *DatabaseConfig) (*DatabaseManager, error) {
 	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
 	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
 
-	manager := &DatabaseManager{
-		db:     db,
-		config: config,
-	}
-
-	DB = manager
-
-	fmt.Printf("✅ Database connected: %s\n", config.Driver)
-	return manager, nil
+	return db, nil
 }
 
 // GetDB returns the database instance
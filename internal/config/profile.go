@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ProfileDev runs the framework without external services: SQLite, an
+// in-memory cache and an embedded queue
+const ProfileDev = "dev"
+
+// devBackends are forced by the dev profile, so a shell or .env pointing
+// at shared services is not used by accident
+var devBackends = map[string]string{
+	"DB_DRIVER":          "sqlite",
+	"CACHE_DRIVER":       "memory",
+	"QUEUE_DRIVER":       "memory",
+	"VECTOR_STORE":       "memory",
+	"STORAGE_DRIVER":     "local",
+	"BOOT_WAIT_DATABASE": "false",
+	"BOOT_WAIT_FOR":      "",
+}
+
+// devDefaults are used by the dev profile for keys that are not set
+var devDefaults = map[string]string{
+	"APP_ENV":     "development",
+	"APP_DEBUG":   "true",
+	"DB_DATABASE": "neonex_dev.db",
+	"LOG_LEVEL":   "debug",
+	"LOG_FORMAT":  "text",
+}
+
+// Profile returns the profile selected by APP_PROFILE, empty for none
+func Profile() string {
+	return os.Getenv("APP_PROFILE")
+}
+
+// ApplyProfile sets the environment of the profile selected by
+// APP_PROFILE, before any configuration is loaded, and returns the keys it
+// changed. Without a profile, every backend comes from the environment as
+// usual.
+func ApplyProfile() ([]string, error) {
+	values := make(map[string]string)
+	for _, keys := range []map[string]string{devBackends, devDefaults} {
+		for key := range keys {
+			if value, set := os.LookupEnv(key); set {
+				values[key] = value
+			}
+		}
+	}
+	changed, err := ResolveProfile(Profile(), values)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range changed {
+		os.Setenv(key, values[key])
+	}
+	return changed, nil
+}
+
+// ResolveProfile applies a profile to a set of values, such as those read
+// from .env files, as ApplyProfile does to the environment
+func ResolveProfile(profile string, values map[string]string) ([]string, error) {
+	switch profile {
+	case "":
+		return nil, nil
+	case ProfileDev:
+		var changed []string
+		// A database name meant for a server makes a poor file name
+		if driver := values["DB_DRIVER"]; driver != "" && driver != "sqlite" {
+			delete(values, "DB_DATABASE")
+		}
+		for key, value := range devBackends {
+			if current, set := values[key]; !set || current != value {
+				values[key] = value
+				changed = append(changed, key)
+			}
+		}
+		for key, value := range devDefaults {
+			if values[key] == "" {
+				values[key] = value
+				changed = append(changed, key)
+			}
+		}
+		sort.Strings(changed)
+		return changed, nil
+	default:
+		return nil, fmt.Errorf("unknown profile %q, expected %q", profile, ProfileDev)
+	}
+}
@@ -1,14 +1,37 @@
 package core
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
 	"neonexcore/internal/config"
 	"neonexcore/pkg/api"
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/contracts"
 	"neonexcore/pkg/database"
+	"neonexcore/pkg/deprecation"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/format"
+	"neonexcore/pkg/ids"
 	"neonexcore/pkg/logger"
 	"neonexcore/pkg/metrics"
+	"neonexcore/pkg/monitors"
+	"neonexcore/pkg/ownership"
+	"neonexcore/pkg/probe"
+	"neonexcore/pkg/queue"
+	"neonexcore/pkg/sandbox"
+	"neonexcore/pkg/session"
+	"neonexcore/pkg/signing"
+	"neonexcore/pkg/storage"
+	"neonexcore/pkg/views"
+	"neonexcore/pkg/warehouse"
 	"neonexcore/pkg/websocket"
 
 	"github.com/gofiber/fiber/v2"
@@ -25,6 +48,31 @@ type App struct {
 	WSHub      *websocket.Hub // WebSocket hub
 	Collector  *metrics.Collector
 	Dashboard  *metrics.Dashboard
+	Storage    storage.Storage
+	Cache      cache.Cache
+	Queue      queue.Queue
+	Sandbox    *sandbox.Partition // Test mode database, nil when disabled
+	Signer     *signing.Signer    // Signed URLs and temporary access tokens
+	RequestSigner *signing.RequestSigner // Signs internal requests, nil without REQUEST_SIGNING_KEYS
+	Routes     *api.RouteRecorder // Route registrations by module, see RouteTable
+	Monitors   *monitors.Monitor  // Probes of external endpoints, nil without MONITOR_TARGETS
+	Events     *events.Recorder   // Recent events to capture, nil without EVENT_CAPTURE_ENABLED
+	Capture    events.CaptureConfig // Event capture and replay settings
+	Pusher     *metrics.Pusher      // Pushes metrics on shutdown, nil without METRICS_PUSH_URL
+	Views      *views.Engine        // Server-rendered pages, see pkg/views
+	Warehouse  *warehouse.Sink      // Streams events, requests and metrics, nil without WAREHOUSE_DRIVER
+	Deprecations *deprecation.Registry // Deprecated routes, config keys and module APIs
+	IDs        *ids.Plugin            // Assigns generated IDs to tagged model fields
+	Sessions   *session.Store         // Cookie sessions, for modules serving browsers
+
+	shutdownHooks []shutdownHook
+	hooksOnce     sync.Once
+}
+
+// shutdownHook is a function run when the app begins shutting down
+type shutdownHook struct {
+	name string
+	run  func(ctx context.Context) error
 }
 
 // -----------------------------------------------------------
@@ -32,20 +80,133 @@ type App struct {
 // -----------------------------------------------------------
 func NewApp() *App {
 	// Initialize WebSocket hub
-	hubConfig := websocket.DefaultHubConfig()
+	hubConfig := websocket.LoadHubConfig()
 	wsHub := websocket.NewHub(hubConfig)
 	
+	// Fan WebSocket messages out to the other instances over a bus
+	if bus, err := websocket.NewBusFromEnv(); err != nil {
+		fmt.Println("WebSocket messages stay on this instance:", err)
+	} else if bus != nil {
+		wsHub.UseBus(bus)
+	}
+	
 	// Initialize metrics collector
 	collectorConfig := metrics.DefaultCollectorConfig()
 	collectorConfig.CollectSystemMetrics = true
 	collectorConfig.SystemMetricsInterval = 5 * time.Second
 	collector := metrics.NewCollector(collectorConfig)
+	metrics.InstrumentHub(collector, wsHub)
 	
 	// Initialize dashboard
 	dashConfig := metrics.DefaultDashboardConfig()
 	dashConfig.BroadcastInterval = 1 * time.Second
 	dashboard := metrics.NewDashboard(collector, wsHub, dashConfig)
 	
+	// Push metrics on shutdown, for commands and workers that are not scraped
+	var pusher *metrics.Pusher
+	if pushConfig := metrics.LoadPushConfig(); pushConfig.URL != "" {
+		pusher = metrics.NewPusher(collector, pushConfig)
+	}
+	
+	// Initialize object storage
+	storageConfig := storage.LoadConfig()
+	store, err := storage.New(storageConfig)
+	if err != nil {
+		fmt.Println("Falling back to local storage:", err)
+		store = storage.NewLocalStorage(storageConfig.Root, storageConfig.BaseURL)
+	}
+	
+	// Initialize cache and message queue, in memory unless configured
+	appCache, err := cache.NewFromEnv()
+	if err != nil {
+		fmt.Println("Falling back to in-memory cache:", err)
+		appCache = cache.NewMemoryCache(cache.DefaultMemoryCacheConfig())
+	}
+	queueConfig := queue.LoadConfig()
+	appQueue, err := queue.New(queueConfig)
+	if err != nil {
+		fmt.Println("Falling back to embedded queue:", err)
+		appQueue = queue.NewMemoryQueue(queueConfig)
+	}
+	
+	// Initialize signed URLs; single-use tokens are redeemed through the
+	// app cache, so each is redeemed once across instances
+	signingConfig := signing.LoadConfig()
+	if signingConfig.Secret == "" {
+		fmt.Println("SIGNING_SECRET is not set; signed URLs will not survive a restart")
+	}
+	signer := signing.NewSigner(signingConfig, appCache)
+	
+	// Sign internal service-to-service requests; nonces are shared
+	// through the app cache
+	var requestSigner *signing.RequestSigner
+	if keys, err := signing.LoadKeyRing(); err != nil {
+		fmt.Println("Request signing disabled:", err)
+	} else if keys != nil {
+		requestSigner = signing.NewRequestSigner(keys, signing.LoadRequestConfig(), appCache)
+	}
+	
+	// Record recent events, so a bug can be captured and replayed locally
+	captureConfig := events.LoadCaptureConfig()
+	var recorder *events.Recorder
+	if captureConfig.Enabled {
+		recorder = events.NewRecorder(events.Default(), captureConfig)
+	}
+	
+	// Server-rendered pages; app-wide templates are read from VIEWS_DIR
+	viewEngine, err := views.New(views.LoadConfig())
+	if err != nil {
+		fmt.Println("Failed to parse views:", err)
+	}
+	
+	// Stream events, request logs and metric samples to an analytical store
+	var sink *warehouse.Sink
+	if warehouseConfig := warehouse.LoadConfig(); warehouseConfig.Driver != "" {
+		sink, err = warehouse.New(warehouseConfig)
+		if err != nil {
+			fmt.Println("Warehouse streaming disabled:", err)
+		} else {
+			if warehouseConfig.Events {
+				sink.ObserveEvents(events.Default())
+			}
+			sink.SampleMetrics(metrics.NewHooks(collector, nil), warehouseConfig.MetricsInterval)
+		}
+	}
+	
+	// Deprecated routes, config keys and module APIs, from DEPRECATIONS_FILE
+	// and modules
+	deprecations, err := deprecation.New(deprecation.LoadConfig(), metrics.NewHooks(collector, nil))
+	if err != nil {
+		fmt.Println("Failed to load deprecations:", err)
+		deprecations, _ = deprecation.New(deprecation.DefaultConfig(), metrics.NewHooks(collector, nil))
+	}
+	
+	// Generated IDs for models tagged ids:"auto", from ID_STRATEGY
+	idPlugin, err := ids.NewPlugin(ids.LoadConfig())
+	if err != nil {
+		fmt.Println("Falling back to UUIDv7 IDs:", err)
+		idPlugin, _ = ids.NewPlugin(ids.DefaultConfig())
+	}
+	
+	// Cookie sessions in the app cache; cookie attributes follow APP_ENV
+	sessionConfig := session.LoadConfig()
+	var codec *session.Codec
+	keys, err := session.LoadKeys()
+	if err == nil && len(keys) > 0 {
+		codec, err = session.NewCodec(keys...)
+	}
+	if err != nil {
+		fmt.Println("Invalid SESSION_KEYS; sessions will not survive a restart:", err)
+	} else if codec == nil {
+		fmt.Println("SESSION_KEYS is not set; sessions will not survive a restart")
+	}
+	sessions, err := session.NewStore(sessionConfig, codec, appCache)
+	if err != nil {
+		fmt.Println("Falling back to default session cookies:", err)
+		sessions, _ = session.NewStore(session.ConfigForEnvironment(sessionConfig.Environment), codec, appCache)
+	}
+	sessions.ListenPrivilegeEvents(nil)
+	
 	return &App{
 		Registry:  NewModuleRegistry(),
 		Container: NewContainer(),
@@ -53,6 +214,20 @@ func NewApp() *App {
 		WSHub:     wsHub,
 		Collector: collector,
 		Dashboard: dashboard,
+		Storage:   store,
+		Cache:     appCache,
+		Queue:     appQueue,
+		Signer:    signer,
+		RequestSigner: requestSigner,
+		Routes:    api.NewRouteRecorder("core"),
+		Events:    recorder,
+		Capture:   captureConfig,
+		Pusher:    pusher,
+		Views:     viewEngine,
+		Warehouse: sink,
+		Deprecations: deprecations,
+		IDs:       idPlugin,
+		Sessions:  sessions,
 	}
 }
 
@@ -63,7 +238,7 @@ func (a *App) InitLogger(cfg logger.Config) error {
 	if err := logger.Setup(cfg); err != nil {
 		return fmt.Errorf("failed to initialize logger: %w", err)
 	}
-	a.Logger = logger.NewLogger()
+	a.Logger = logger.Default()
 	a.Logger.Info("Logger initialized", logger.Fields{
 		"level":  cfg.Level,
 		"format": cfg.Format,
@@ -73,7 +248,23 @@ func (a *App) InitLogger(cfg logger.Config) error {
 }
 
 // -----------------------------------------------------------
-// 4) InitDatabase() - เริ่ม Database + Migrator
+// 4) WaitForDependencies() - Wait for databases and services to come up
+// -----------------------------------------------------------
+func (a *App) WaitForDependencies(ctx context.Context) error {
+	probeConfig, err := probe.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("invalid dependency configuration: %w", err)
+	}
+	if len(probeConfig.Dependencies) == 0 {
+		return nil
+	}
+
+	a.Logger.Info("Waiting for dependencies...", logger.Fields{"count": len(probeConfig.Dependencies)})
+	return probe.Wait(ctx, a.Logger, probeConfig)
+}
+
+// -----------------------------------------------------------
+// 5) InitDatabase() - เริ่ม Database + Migrator
 // -----------------------------------------------------------
 func (a *App) InitDatabase() error {
 	dbConfig := config.LoadDatabaseConfig()
@@ -82,15 +273,46 @@ func (a *App) InitDatabase() error {
 		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 
+	// Assign generated IDs on create
+	if err := config.DB.GetDB().Use(a.IDs); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	// Scope owned models to the principal of each statement's context
+	if err := config.DB.GetDB().Use(ownership.NewPlugin()); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+
 	// Initialize migrator
 	a.Migrator = database.NewMigrator(config.DB.GetDB())
 	a.Logger.Info("Database initialized", logger.Fields{"driver": dbConfig.Driver})
 
+	if config.SandboxEnabled() {
+		return a.initSandbox()
+	}
+	return nil
+}
+
+// initSandbox opens the sandbox database and routes test mode queries to it
+func (a *App) initSandbox() error {
+	sandboxConfig := config.LoadSandboxDatabaseConfig()
+	testDB, err := config.OpenDatabase(sandboxConfig)
+	if err != nil {
+		return fmt.Errorf("failed to initialize sandbox database: %w", err)
+	}
+
+	partition := sandbox.NewPartition(testDB, sandbox.DefaultSharedTables...)
+	if err := config.DB.GetDB().Use(partition); err != nil {
+		return fmt.Errorf("failed to initialize sandbox database: %w", err)
+	}
+
+	a.Sandbox = partition
+	a.Logger.Info("Sandbox database initialized", logger.Fields{"database": sandboxConfig.Database})
 	return nil
 }
 
 // -----------------------------------------------------------
-// 5) RegisterModels() - Register models for auto-migration
+// 6) RegisterModels() - Register models for auto-migration
 // -----------------------------------------------------------
 func (a *App) RegisterModels(models ...interface{}) {
 	if a.Migrator != nil {
@@ -99,8 +321,49 @@ func (a *App) RegisterModels(models ...interface{}) {
 	}
 }
 
+// RegisterModuleModels registers models a module owns: AutoMigrate
+// installs them with the module's schema, and UninstallModule drops them
+func (a *App) RegisterModuleModels(module string, models ...interface{}) {
+	if a.Migrator != nil {
+		a.Migrator.RegisterModule(module, nil, models...)
+	}
+}
+
+// DiscoverModuleMigrations registers the SQL migrations modules ship in
+// modules/<module>/migrations, disabled modules included, so their schema
+// can be installed or uninstalled on its own
+func (a *App) DiscoverModuleMigrations() {
+	if a.Migrator == nil {
+		return
+	}
+	entries, err := os.ReadDir("./modules")
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		dir := filepath.Join("modules", e.Name(), "migrations")
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+
+		name := e.Name()
+		var meta struct {
+			Name string `json:"name"`
+		}
+		if raw, err := os.ReadFile(filepath.Join("modules", e.Name(), "module.json")); err == nil {
+			if json.Unmarshal(raw, &meta) == nil && meta.Name != "" {
+				name = meta.Name
+			}
+		}
+
+		a.Migrator.RegisterModule(name, os.DirFS(dir))
+		a.Logger.Info("Module migrations registered", logger.Fields{"module": name, "dir": dir})
+	}
+}
+
 // -----------------------------------------------------------
-// 6) AutoMigrate() - Run auto-migration
+// 7) AutoMigrate() - Run auto-migration
 // -----------------------------------------------------------
 func (a *App) AutoMigrate() error {
 	if a.Migrator != nil {
@@ -109,13 +372,48 @@ func (a *App) AutoMigrate() error {
 			a.Logger.Error("Auto-migration failed", logger.Fields{"error": err.Error()})
 			return err
 		}
+		if a.Sandbox != nil {
+			if err := a.Migrator.WithDB(a.Sandbox.DB()).AutoMigrate(); err != nil {
+				a.Logger.Error("Sandbox auto-migration failed", logger.Fields{"error": err.Error()})
+				return err
+			}
+		}
 		a.Logger.Info("Auto-migration completed")
 	}
 	return nil
 }
 
+// InstallModule installs a module's schema, in the sandbox database too
+func (a *App) InstallModule(ctx context.Context, name string) error {
+	if a.Migrator == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if err := a.Migrator.InstallModule(ctx, name); err != nil {
+		return err
+	}
+	if a.Sandbox != nil {
+		return a.Migrator.WithDB(a.Sandbox.DB()).InstallModule(ctx, name)
+	}
+	return nil
+}
+
+// UninstallModule uninstalls a module's schema, in the sandbox database
+// too. Its data is lost.
+func (a *App) UninstallModule(ctx context.Context, name string) error {
+	if a.Migrator == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if err := a.Migrator.UninstallModule(ctx, name); err != nil {
+		return err
+	}
+	if a.Sandbox != nil {
+		return a.Migrator.WithDB(a.Sandbox.DB()).UninstallModule(ctx, name)
+	}
+	return nil
+}
+
 // -----------------------------------------------------------
-// 7) Boot() - เริ่มระบบพื้นฐาน
+// 8) Boot() - เริ่มระบบพื้นฐาน
 // -----------------------------------------------------------
 func (a *App) Boot() {
 	fmt.Println("⚙️  Booting Neonex Core...")
@@ -123,14 +421,74 @@ func (a *App) Boot() {
 }
 
 // -----------------------------------------------------------
-// 8) StartHTTP() - HTTP Server Engine
+// 9) StartHTTP() - HTTP Server Engine
 // -----------------------------------------------------------
 func (a *App) StartHTTP() {
+	app := a.setupHTTP()
+	if a.Monitors != nil {
+		a.Monitors.Start()
+	}
+
+	// Custom Neonex startup banner
+	fmt.Println()
+	fmt.Println("┌───────────────────────────────────────────────────┐")
+	fmt.Println("│              Neonex Core v0.1-alpha               │")
+	fmt.Println("│               http://127.0.0.1:8080               │")
+	fmt.Println("│       (bound on host 0.0.0.0 and port 8080)       │")
+	fmt.Println("│                                                   │")
+	fmt.Println("│ Framework .... Neonex  Engine ..... Fiber/fasthttp│")
+	fmt.Println("│                                                   │")
+	fmt.Println("│ 📚 Documentation: http://127.0.0.1:8080/api/docs  │")
+	fmt.Println("│ 💚 Health Check:  http://127.0.0.1:8080/health    │")
+	fmt.Println("│ 🚀 API v1:        http://127.0.0.1:8080/api/v1    │")
+	fmt.Println("│ 🔴 WebSocket:     ws://127.0.0.1:8080/ws          │")
+	fmt.Println("│ 📊 Metrics:       http://127.0.0.1:8080/metrics/dashboard │")
+	fmt.Println("└───────────────────────────────────────────────────┘")
+	fmt.Println()
+
+	// Graceful shutdown on SIGINT/SIGTERM
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+		<-quit
+
+		// Deregister before draining, so no new requests are routed here
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		a.runShutdownHooks(ctx)
+		cancel()
+
+		a.Logger.Info("Shutting down HTTP server...")
+		if err := app.ShutdownWithTimeout(10 * time.Second); err != nil {
+			a.Logger.Error("HTTP server shutdown failed", logger.Fields{"error": err.Error()})
+		}
+	}()
+
+	host, port := os.Getenv("HTTP_HOST"), os.Getenv("HTTP_PORT")
+	if port == "" {
+		port = "8080"
+	}
+	a.Logger.Info("HTTP server starting", logger.Fields{"host": host, "port": port})
+	if err := app.Listen(host + ":" + port); err != nil {
+		a.Logger.Fatal("Failed to start server", logger.Fields{"error": err.Error()})
+	}
+
+	a.Shutdown()
+}
+
+// setupHTTP creates the Fiber app and registers the middleware and every
+// module's routes
+func (a *App) setupHTTP() *fiber.App {
 	// Configure Fiber with custom branding
 	app := fiber.New(fiber.Config{
 		AppName:               "Neonex Core v0.1-alpha",
 		DisableStartupMessage: true, // Disable default Fiber banner
+		Views:                 a.Views,
 	})
+	a.Routes.Attach(app)
+
+	// Per-route metric series, registered once the app starts listening
+	routeMetrics := metrics.NewRouteMetrics(a.Collector)
+	routeMetrics.Attach(app)
 
 	// Global middleware - CORS
 	app.Use(api.CORSMiddleware())
@@ -146,9 +504,15 @@ func (a *App) StartHTTP() {
 	app.Use(logger.HTTPMiddleware(a.Logger))
 
 	// Global middleware - Metrics
-	app.Use(metrics.Middleware(a.Collector))
-	app.Use(metrics.MethodMiddleware(a.Collector))
-	app.Use(metrics.ErrorMiddleware(a.Collector))
+	app.Use(routeMetrics.Middleware())
+
+	// Global middleware - Warehouse request logs
+	if a.Warehouse != nil && a.Warehouse.Config().Requests {
+		app.Use(a.Warehouse.Middleware())
+	}
+
+	// Global middleware - Deprecation headers, and 410 for routes past their sunset
+	app.Use(a.Deprecations.Middleware())
 
 	// Global rate limiting (100 requests per minute per IP)
 	app.Use(api.IPRateLimitMiddleware(100, time.Minute))
@@ -157,6 +521,19 @@ func (a *App) StartHTTP() {
 	healthChecker := api.NewHealthChecker("0.1-alpha", config.DB.GetDB())
 	api.SetupHealthRoutes(app, healthChecker, config.DB.GetDB())
 
+	// External endpoints show on the status page and alert when down
+	if monitorConfig, err := monitors.LoadConfig(); err != nil {
+		a.Logger.Error("Invalid monitor configuration", logger.Fields{"error": err.Error()})
+	} else if len(monitorConfig.Targets) > 0 {
+		hooks := metrics.NewHooks(a.Collector, a.Dashboard)
+		if a.Monitors, err = monitors.New(monitorConfig, hooks); err != nil {
+			a.Logger.Error("Failed to set up monitors", logger.Fields{"error": err.Error()})
+		} else {
+			a.Monitors.RegisterHealthChecks(healthChecker)
+			a.Monitors.RegisterAlerts(hooks)
+		}
+	}
+
 	// API versioning
 	versionManager := api.NewVersionManager()
 	versionManager.RegisterVersion("v1", "1.0.0")
@@ -171,12 +548,46 @@ func (a *App) StartHTTP() {
 	// Create versioned API routes
 	apiV1 := api.VersionedRouter(app, "v1")
 	apiV1.Use(api.VersionMiddleware(versionManager))
+	apiV1.Use(format.Middleware(format.LoadConfig()))
+
+	// Shared infrastructure available to modules
+	a.Container.Provide(func() *fiber.App { return app }, Singleton)
+	a.Container.Provide(func() *metrics.Collector { return a.Collector }, Singleton)
+	a.Container.Provide(func() *metrics.Dashboard { return a.Dashboard }, Singleton)
+	a.Container.Provide(func() storage.Storage { return a.Storage }, Singleton)
+	a.Container.Provide(func() cache.Cache { return a.Cache }, Singleton)
+	a.Container.Provide(func() queue.Queue { return a.Queue }, Singleton)
+	a.Container.Provide(func() *api.HealthChecker { return healthChecker }, Singleton)
+	a.Container.Provide(func() *monitors.Monitor { return a.Monitors }, Singleton)
+	a.Container.Provide(func() *api.SwaggerGenerator { return swagger }, Singleton)
+	a.Container.Provide(func() *sandbox.Partition { return a.Sandbox }, Singleton)
+	a.Container.Provide(func() *signing.Signer { return a.Signer }, Singleton)
+	a.Container.Provide(func() *signing.RequestSigner { return a.RequestSigner }, Singleton)
+	a.Container.Provide(func() *api.RouteRecorder { return a.Routes }, Singleton)
+	a.Container.Provide(func() *events.Recorder { return a.Events }, Singleton)
+	a.Container.Provide(func() events.CaptureConfig { return a.Capture }, Singleton)
+	a.Container.Provide(func() *views.Engine { return a.Views }, Singleton)
+	a.Container.Provide(func() *warehouse.Sink { return a.Warehouse }, Singleton)
+	a.Container.Provide(func() *deprecation.Registry { return a.Deprecations }, Singleton)
+	a.Container.Provide(func() ids.Generator { return a.IDs.Generator() }, Singleton)
+	a.Container.Provide(func() *session.Store { return a.Sessions }, Singleton)
+
+	// Stand-ins for module services, used when their module is disabled
+	StubService[contracts.UserLookup](a.Container, contracts.NoUserLookup{})
+	StubService[contracts.GeoLocator](a.Container, contracts.NoGeoLocator{})
 
 	// Load module routes
 	a.Logger.Info("Registering modules...")
 	a.Registry.RegisterModuleServices(a.Container)
+	a.Registry.LoadMiddleware(apiV1, a.Container)
 	a.Registry.LoadRoutes(apiV1, a.Container) // Load routes into /api/v1
 
+	// Modules have declared their deprecations; warn about deprecated
+	// config keys that are still set
+	if err := a.Deprecations.CheckEnvironment(); err != nil {
+		a.Logger.Error("Deprecated configuration is ignored", logger.Fields{"error": err.Error()})
+	}
+
 	// Setup WebSocket routes
 	a.Logger.Info("Setting up WebSocket support...")
 	websocket.SetupRoutes(app, a.WSHub, nil) // nil = use default message handler
@@ -184,6 +595,7 @@ func (a *App) StartHTTP() {
 	// Setup metrics dashboard
 	a.Logger.Info("Setting up metrics dashboard...")
 	a.Dashboard.SetupRoutes(app)
+	app.Post("/metrics/ingest", metrics.IngestHandler(a.Collector, metrics.LoadPushConfig().Token))
 
 	// Default homepage
 	app.Get("/", func(c *fiber.Ctx) error {
@@ -200,25 +612,67 @@ func (a *App) StartHTTP() {
 		})
 	})
 
-	// Custom Neonex startup banner
-	fmt.Println()
-	fmt.Println("┌───────────────────────────────────────────────────┐")
-	fmt.Println("│              Neonex Core v0.1-alpha               │")
-	fmt.Println("│               http://127.0.0.1:8080               │")
-	fmt.Println("│       (bound on host 0.0.0.0 and port 8080)       │")
-	fmt.Println("│                                                   │")
-	fmt.Println("│ Framework .... Neonex  Engine ..... Fiber/fasthttp│")
-	fmt.Println("│                                                   │")
-	fmt.Println("│ 📚 Documentation: http://127.0.0.1:8080/api/docs  │")
-	fmt.Println("│ 💚 Health Check:  http://127.0.0.1:8080/health    │")
-	fmt.Println("│ 🚀 API v1:        http://127.0.0.1:8080/api/v1    │")
-	fmt.Println("│ 🔴 WebSocket:     ws://127.0.0.1:8080/ws          │")
-	fmt.Println("│ 📊 Metrics:       http://127.0.0.1:8080/metrics/dashboard │")
-	fmt.Println("└───────────────────────────────────────────────────┘")
-	fmt.Println()
+	return app
+}
 
-	a.Logger.Info("HTTP server starting", logger.Fields{"port": 8080})
-	if err := app.Listen(":8080"); err != nil {
-		a.Logger.Fatal("Failed to start server", logger.Fields{"error": err.Error()})
+// RouteTable registers every route without starting the server and
+// returns them with their owners and conflicts, for `neonex routes`
+func (a *App) RouteTable() *api.RouteTable {
+	a.setupHTTP()
+	return a.Routes.Table()
+}
+
+// OnShutdown registers a function to run as the app begins shutting
+// down, before the HTTP server drains, such as releasing a service
+// registry lease so discovery stops routing requests to this instance.
+// Hooks run once, in reverse order of registration.
+func (a *App) OnShutdown(name string, hook func(ctx context.Context) error) {
+	a.shutdownHooks = append(a.shutdownHooks, shutdownHook{name: name, run: hook})
+}
+
+// runShutdownHooks runs the shutdown hooks the first time it is called
+func (a *App) runShutdownHooks(ctx context.Context) {
+	a.hooksOnce.Do(func() {
+		for i := len(a.shutdownHooks) - 1; i >= 0; i-- {
+			hook := a.shutdownHooks[i]
+			if err := hook.run(ctx); err != nil {
+				a.Logger.Error("Shutdown hook failed", logger.Fields{"hook": hook.name, "error": err.Error()})
+			}
+		}
+	})
+}
+
+// -----------------------------------------------------------
+// 10) Shutdown() - Flush logs and release resources
+// -----------------------------------------------------------
+func (a *App) Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	a.runShutdownHooks(ctx)
+
+	if a.Monitors != nil {
+		a.Monitors.Stop()
+	}
+	if a.Pusher != nil {
+		if err := a.Pusher.Close(ctx); err != nil {
+			a.Logger.Error("Failed to push metrics", logger.Fields{"error": err.Error()})
+		}
+	}
+	if a.Warehouse != nil {
+		if err := a.Warehouse.Close(ctx); err != nil {
+			a.Logger.Error("Failed to flush warehouse", logger.Fields{"error": err.Error()})
+		}
+	}
+	if err := a.Queue.Close(); err != nil {
+		a.Logger.Error("Failed to close queue", logger.Fields{"error": err.Error()})
+	}
+	if err := a.Cache.Close(); err != nil {
+		a.Logger.Error("Failed to close cache", logger.Fields{"error": err.Error()})
+	}
+
+	a.Logger.Info("Flushing logs...")
+	if err := logger.Shutdown(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to flush logs: %v\n", err)
 	}
 }
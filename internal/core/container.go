@@ -20,12 +20,14 @@ type providerDef struct {
 
 type Container struct {
 	providers map[reflect.Type]*providerDef
+	services  map[reflect.Type]*serviceDef // Contracts between modules, see services.go
 	mu        sync.Mutex
 }
 
 func NewContainer() *Container {
 	return &Container{
 		providers: make(map[reflect.Type]*providerDef),
+		services:  make(map[reflect.Type]*serviceDef),
 	}
 }
 
@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 
+	"neonexcore/pkg/api"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -16,6 +18,12 @@ type Module interface {
 	RegisterServices(c *Container)
 }
 
+// MiddlewareProvider is implemented by modules that install middleware in
+// front of every module's routes, e.g. alternative authentication
+type MiddlewareProvider interface {
+	Middleware(c *Container) []fiber.Handler
+}
+
 type ModuleRegistry struct {
 	Modules []Module
 }
@@ -38,10 +46,34 @@ func (r *ModuleRegistry) Load() {
 	}
 }
 
+func (r *ModuleRegistry) LoadMiddleware(router fiber.Router, c *Container) {
+	recorder := Resolve[*api.RouteRecorder](c)
+	for _, m := range r.Modules {
+		if provider, ok := m.(MiddlewareProvider); ok {
+			setRouteOwner(recorder, m.Name())
+			for _, handler := range provider.Middleware(c) {
+				router.Use(handler)
+			}
+		}
+	}
+	setRouteOwner(recorder, "core")
+}
+
 func (r *ModuleRegistry) LoadRoutes(app fiber.Router, c *Container) {
+	recorder := Resolve[*api.RouteRecorder](c)
 	for _, m := range r.Modules {
+		setRouteOwner(recorder, m.Name())
 		m.Routes(app, c)
 	}
+	setRouteOwner(recorder, "core")
+}
+
+// setRouteOwner attributes the routes registered next to a module, for
+// `neonex routes`
+func setRouteOwner(recorder *api.RouteRecorder, module string) {
+	if recorder != nil {
+		recorder.SetOwner(module)
+	}
 }
 
 func (r *ModuleRegistry) AutoDiscover() {
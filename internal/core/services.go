@@ -0,0 +1,216 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"neonexcore/pkg/contracts"
+)
+
+var (
+	// ErrServiceUnavailable is returned when no enabled module publishes a
+	// service, as by the stubs in pkg/contracts
+	ErrServiceUnavailable = contracts.ErrUnavailable
+	// ErrServiceVersion is returned when a service is published at a version
+	// the caller cannot use
+	ErrServiceVersion = errors.New("incompatible service version")
+)
+
+// ServiceInfo describes a published service
+type ServiceInfo struct {
+	Name    string `json:"name"`    // The contract, e.g. contracts.UserLookup
+	Module  string `json:"module"`  // The publishing module, empty for stubs
+	Version string `json:"version"` // e.g. 1.2.0
+	Stub    bool   `json:"stub"`    // Whether no module publishes it
+}
+
+// serviceDef is a service published by a module, and the stub used in its
+// place when none is
+type serviceDef struct {
+	module  string
+	version string
+	factory interface{}
+	stub    interface{}
+
+	once     sync.Once
+	instance interface{}
+}
+
+// get returns the service, created on first use, or its stub
+func (s *serviceDef) get() (interface{}, bool) {
+	if s.factory == nil {
+		return s.stub, false
+	}
+	s.once.Do(func() {
+		s.instance = reflect.ValueOf(s.factory).Call(nil)[0].Interface()
+	})
+	if s.instance == nil {
+		return s.stub, false
+	}
+	return s.instance, true
+}
+
+// ProvideService publishes a module's implementation of a service contract,
+// an interface other modules resolve with Service without importing the
+// module. The factory runs on first use, after every module has registered
+// its services; it may return nil when the module cannot serve, e.g. for
+// lack of configuration, and callers then get the stub.
+func ProvideService[T any](c *Container, module, version string, factory func() T) {
+	t := serviceType[T]()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	def := c.service(t)
+	def.module = module
+	def.version = version
+	def.factory = factory
+}
+
+// StubService registers the implementation used while no module publishes
+// a service, so callers need no nil checks when the providing module is
+// disabled. Stubs return an error wrapping ErrServiceUnavailable.
+func StubService[T any](c *Container, stub T) {
+	t := serviceType[T]()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.service(t).stub = stub
+}
+
+// Service resolves a service contract: its published implementation, the
+// stub when none is published, or nil without either
+func Service[T any](c *Container) T {
+	var zero T
+	c.mu.Lock()
+	def, ok := c.services[serviceType[T]()]
+	c.mu.Unlock()
+	if !ok {
+		return zero
+	}
+
+	instance, _ := def.get()
+	if instance == nil {
+		return zero
+	}
+	return instance.(T)
+}
+
+// ServiceVersion resolves a service published at a version compatible with
+// minVersion: the same major version, and at least the same minor and
+// patch. It returns an error wrapping ErrServiceUnavailable, along with the
+// stub, when no module publishes the service.
+func ServiceVersion[T any](c *Container, minVersion string) (T, error) {
+	var zero T
+	t := serviceType[T]()
+	c.mu.Lock()
+	def, ok := c.services[t]
+	c.mu.Unlock()
+	if !ok {
+		return zero, fmt.Errorf("%w: %s", ErrServiceUnavailable, t)
+	}
+
+	instance, published := def.get()
+	if !published {
+		stub, _ := instance.(T)
+		return stub, fmt.Errorf("%w: %s", ErrServiceUnavailable, t)
+	}
+	if !versionCompatible(def.version, minVersion) {
+		return zero, fmt.Errorf("%w: %s %s from %s, need %s", ErrServiceVersion, t, def.version, def.module, minVersion)
+	}
+	return instance.(T), nil
+}
+
+// HasService reports whether a module publishes a service
+func HasService[T any](c *Container) bool {
+	c.mu.Lock()
+	def, ok := c.services[serviceType[T]()]
+	c.mu.Unlock()
+	if !ok {
+		return false
+	}
+	_, published := def.get()
+	return published
+}
+
+// Services lists the published and stubbed services, by contract name
+func (c *Container) Services() []ServiceInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	services := make([]ServiceInfo, 0, len(c.services))
+	for t, def := range c.services {
+		info := ServiceInfo{Name: t.String(), Stub: def.factory == nil}
+		if !info.Stub {
+			info.Module = def.module
+			info.Version = def.version
+		}
+		services = append(services, info)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+	return services
+}
+
+// service returns the definition of a service type, creating it; c.mu must
+// be held
+func (c *Container) service(t reflect.Type) *serviceDef {
+	if c.services == nil {
+		c.services = make(map[reflect.Type]*serviceDef)
+	}
+	def, ok := c.services[t]
+	if !ok {
+		def = &serviceDef{}
+		c.services[t] = def
+	}
+	return def
+}
+
+// serviceType returns the type of a service contract, which must be an
+// interface so modules never share concrete types
+func serviceType[T any]() reflect.Type {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("service contract %s is not an interface", t))
+	}
+	return t
+}
+
+// versionCompatible reports whether version satisfies minVersion under
+// semantic versioning; an empty minVersion accepts any version
+func versionCompatible(version, minVersion string) bool {
+	if minVersion == "" {
+		return true
+	}
+	have, want := parseVersion(version), parseVersion(minVersion)
+	if have[0] != want[0] {
+		return false
+	}
+	for i := 1; i < 3; i++ {
+		if have[i] != want[i] {
+			return have[i] > want[i]
+		}
+	}
+	return true
+}
+
+// parseVersion parses major.minor.patch, ignoring a "v" prefix and any
+// pre-release or build suffix; missing parts are zero
+func parseVersion(version string) [3]int {
+	var parts [3]int
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	for i, part := range strings.SplitN(version, ".", 3) {
+		parts[i], _ = strconv.Atoi(part)
+	}
+	return parts
+}
@@ -2,16 +2,40 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
 
 	"neonexcore/internal/config"
 	"neonexcore/internal/core"
+	aimodule "neonexcore/modules/ai"
 	"neonexcore/modules/admin"
+	"neonexcore/modules/analytics"
+	"neonexcore/modules/campaigns"
+	"neonexcore/modules/cms"
+	"neonexcore/modules/comments"
+	"neonexcore/modules/compliance"
+	"neonexcore/modules/forms"
+	"neonexcore/modules/incidents"
+	"neonexcore/modules/links"
+	"neonexcore/modules/moderation"
+	"neonexcore/modules/passkey"
+	"neonexcore/modules/payments"
+	"neonexcore/modules/portal"
+	"neonexcore/modules/review"
+	"neonexcore/modules/risk"
+	"neonexcore/modules/security"
+	"neonexcore/modules/status"
 	"neonexcore/modules/user"
+	"neonexcore/modules/vault"
+	"neonexcore/pkg/ai"
 	"neonexcore/pkg/api"
 	"neonexcore/pkg/database"
 	"neonexcore/pkg/logger"
+	"neonexcore/pkg/metering"
 	"neonexcore/pkg/module"
 	"neonexcore/pkg/rbac"
 )
@@ -19,18 +43,55 @@ import (
 func main() {
 	fmt.Println("Neonex Core v0.1 starting...")
 
+	// Apply the APP_PROFILE environment before anything reads it
+	changed, err := config.ApplyProfile()
+	if err != nil {
+		log.Fatalf("Failed to apply profile: %v", err)
+	}
+	if profile := config.Profile(); profile != "" {
+		fmt.Printf("Profile %s: SQLite database %s, in-memory cache, embedded queue (set %v)\n",
+			profile, os.Getenv("DB_DATABASE"), changed)
+	}
+
 	// Register module factories
 	core.ModuleMap["user"] = func() core.Module { return user.New() }
 	core.ModuleMap["admin"] = func() core.Module { return admin.New() }
+	core.ModuleMap["cms"] = func() core.Module { return cms.New() }
+	core.ModuleMap["comments"] = func() core.Module { return comments.New() }
+	core.ModuleMap["forms"] = func() core.Module { return forms.New() }
+	core.ModuleMap["links"] = func() core.Module { return links.New() }
+	core.ModuleMap["campaigns"] = func() core.Module { return campaigns.New() }
+	core.ModuleMap["analytics"] = func() core.Module { return analytics.New() }
+	core.ModuleMap["status"] = func() core.Module { return status.New() }
+	core.ModuleMap["incidents"] = func() core.Module { return incidents.New() }
+	core.ModuleMap["portal"] = func() core.Module { return portal.New() }
+	core.ModuleMap["vault"] = func() core.Module { return vault.New() }
+	core.ModuleMap["passkey"] = func() core.Module { return passkey.New() }
+	core.ModuleMap["security"] = func() core.Module { return security.New() }
+	core.ModuleMap["moderation"] = func() core.Module { return moderation.New() }
+	core.ModuleMap["review"] = func() core.Module { return review.New() }
+	core.ModuleMap["risk"] = func() core.Module { return risk.New() }
+	core.ModuleMap["compliance"] = func() core.Module { return compliance.New() }
+	core.ModuleMap["ai"] = func() core.Module { return aimodule.New() }
+	core.ModuleMap["payments"] = func() core.Module { return payments.New() }
 
 	app := core.NewApp()
 
+	// `neonex routes` runs the app as `routes <file>` to list its routes
+	// without migrating, seeding or serving
+	listRoutes := len(os.Args) > 1 && os.Args[1] == "routes"
+
 	// Initialize Logger
 	loggerConfig := logger.LoadConfig()
 	if err := app.InitLogger(loggerConfig); err != nil {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
 
+	// Wait for the database and other declared services
+	if err := app.WaitForDependencies(context.Background()); err != nil {
+		log.Fatalf("Dependencies not ready: %v", err)
+	}
+
 	// Initialize Database
 	if err := app.InitDatabase(); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
@@ -38,7 +99,6 @@ func main() {
 
 	// Register models for auto-migration
 	app.RegisterModels(
-		&user.User{},
 		&rbac.Role{},
 		&rbac.Permission{},
 		&rbac.UserRole{},
@@ -46,11 +106,56 @@ func main() {
 		&module.Module{},
 		&module.ModuleDependency{},
 		&module.ModuleMigration{},
-		&admin.AuditLog{},
-		&admin.SystemSettings{},
-		&admin.BackupInfo{},
+		&metering.Usage{},
+		&ai.TokenUsage{},
+		&ai.ModelVersion{},
+		&ai.PromptTemplate{},
+		&ai.AgentRun{},
 	)
 
+	// Register the models each module owns, installed and uninstalled
+	// with its schema, and the SQL migrations modules ship
+	app.RegisterModuleModels("user", &user.User{})
+	app.RegisterModuleModels("admin", &admin.AuditLog{}, &admin.SystemSettings{}, &admin.BackupInfo{})
+	app.RegisterModuleModels("cms", &cms.ContentType{}, &cms.Content{}, &cms.ContentVersion{})
+	app.RegisterModuleModels("comments", &comments.Comment{}, &comments.CommentMention{}, &comments.CommentReaction{})
+	app.RegisterModuleModels("forms", &forms.Form{}, &forms.Submission{})
+	app.RegisterModuleModels("links", &links.Link{}, &links.Click{})
+	app.RegisterModuleModels("campaigns", &campaigns.Segment{}, &campaigns.Campaign{}, &campaigns.Recipient{}, &campaigns.Link{}, &campaigns.Click{}, &campaigns.Unsubscribe{})
+	app.RegisterModuleModels("status", &status.Sample{}, &status.Incident{}, &status.IncidentUpdate{}, &status.Subscriber{})
+	app.RegisterModuleModels("incidents", &incidents.Incident{}, &incidents.TimelineEntry{}, &incidents.Snapshot{})
+	app.RegisterModuleModels("portal", &portal.APIKey{}, &portal.Webhook{}, &portal.Delivery{})
+	app.RegisterModuleModels("vault", &vault.DataKey{}, &vault.Secret{}, &vault.AccessLog{})
+	app.RegisterModuleModels("passkey", &passkey.Credential{}, &passkey.Policy{})
+	app.RegisterModuleModels("security", &security.Event{}, &security.Device{}, &security.StepUp{})
+	app.RegisterModuleModels("moderation", &moderation.RuleList{}, &moderation.Item{})
+	app.RegisterModuleModels("review", &review.Queue{}, &review.Item{})
+	app.RegisterModuleModels("risk", &risk.Assessment{})
+	app.RegisterModuleModels("compliance", &compliance.Document{}, &compliance.Acceptance{}, &compliance.DataRequest{})
+	app.RegisterModuleModels("ai", &aimodule.Experiment{}, &aimodule.ExperimentRun{})
+	app.RegisterModuleModels("payments", &payments.Invoice{}, &payments.Payment{}, &payments.ChainCursor{})
+	app.DiscoverModuleMigrations()
+
+	// `neonex migrate` runs the app as `migrate <command> [module]` to
+	// manage the schema without serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := runMigrate(app, os.Args[2:])
+		app.Shutdown() // Push the command's metrics and flush logs
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	if listRoutes {
+		app.Registry.AutoDiscover()
+		app.Registry.Load()
+		if err := writeRouteTable(app, os.Args[2:]); err != nil {
+			log.Fatalf("Failed to list routes: %v", err)
+		}
+		return
+	}
+
 	// Run auto-migration
 	if err := app.AutoMigrate(); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
@@ -87,6 +192,63 @@ func main() {
 	app.StartHTTP()
 }
 
+// writeRouteTable writes the app's route table as JSON to the file named
+// in args, or stdout
+func writeRouteTable(app *core.App, args []string) error {
+	out := os.Stdout
+	if len(args) > 0 {
+		file, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	return json.NewEncoder(out).Encode(app.RouteTable())
+}
+
+// runMigrate runs a `migrate` command: up, status, or install, uninstall
+// or rollback followed by a module name
+func runMigrate(app *core.App, args []string) error {
+	ctx := context.Background()
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	name := ""
+	if len(args) > 1 {
+		name = args[1]
+	}
+	if name == "" && (command == "install" || command == "uninstall" || command == "rollback") {
+		return fmt.Errorf("migrate %s needs a module name", command)
+	}
+
+	switch command {
+	case "up":
+		return app.AutoMigrate()
+	case "install":
+		return app.InstallModule(ctx, name)
+	case "uninstall":
+		return app.UninstallModule(ctx, name)
+	case "rollback":
+		return app.Migrator.RollbackModule(ctx, name)
+	case "status":
+		statuses, err := app.Migrator.ModuleStatus(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "MODULE\tINSTALLED\tAPPLIED\tPENDING\tDEPENDS ON\tTABLES")
+		for _, s := range statuses {
+			fmt.Fprintf(w, "%s\t%t\t%d\t%d\t%s\t%s\n", s.Module, s.Installed, len(s.Applied), len(s.Pending),
+				strings.Join(s.DependsOn, ","), strings.Join(s.Tables, ","))
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+}
+
 // seedUserPermissions seeds default user module permissions
 func seedUserPermissions(ctx context.Context, rbacManager *rbac.Manager) error {
 	permissions := []rbac.Permission{
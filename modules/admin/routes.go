@@ -2,7 +2,10 @@ package admin
 
 import (
 	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/events"
 	"neonexcore/pkg/rbac"
+	"neonexcore/pkg/storage"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -63,4 +66,16 @@ func SetupRoutes(router fiber.Router, container *core.Container) {
 	settingsGroup.Post("/", controller.CreateSetting)
 	settingsGroup.Put("/:key", controller.UpdateSetting)
 	settingsGroup.Delete("/:key", controller.DeleteSetting)
+
+	// Event capture and replay (require admin.events.manage permission)
+	eventsGroup := admin.Group("/events",
+		auth.AuthMiddleware(core.Resolve[*auth.JWTManager](container), auth.AcceptAPIKeys()),
+		rbac.RequirePermission(rbacManager, "admin.events.manage"),
+	)
+	events.RegisterCaptureRoutes(eventsGroup,
+		events.Default(),
+		core.Resolve[*events.Recorder](container),
+		core.Resolve[storage.Storage](container),
+		core.Resolve[events.CaptureConfig](container),
+	)
 }
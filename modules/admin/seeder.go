@@ -92,6 +92,13 @@ func (s *AdminSeeder) seedPermissions(ctx context.Context) error {
 			Module:      "admin",
 			Category:    "admin",
 		},
+		{
+			Name:        "Manage Event Captures",
+			Slug:        "admin.events.manage",
+			Description: "Capture recent events and replay captures",
+			Module:      "admin",
+			Category:    "admin",
+		},
 	}
 
 	for _, perm := range permissions {
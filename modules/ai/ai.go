@@ -0,0 +1,28 @@
+package ai
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AIModule struct{}
+
+func New() *AIModule {
+	return &AIModule{}
+}
+
+func (m *AIModule) Name() string {
+	return "ai"
+}
+
+func (m *AIModule) Init() {}
+
+func (m *AIModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *AIModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
@@ -0,0 +1,461 @@
+package ai
+
+import (
+	"strings"
+
+	"neonexcore/pkg/ai"
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// ListModels lists the loaded models
+// @Summary List models
+// @Tags AI
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]ModelInfo}
+// @Router /ai/models [get]
+func (c *Controller) ListModels(ctx *fiber.Ctx) error {
+	return api.Success(ctx, c.service.Models())
+}
+
+// Predict runs a model
+// @Summary Run a model
+// @Description Runs a model on the input. With "stream": true, or an Accept header of text/event-stream, the result is streamed as Server-Sent Events: one data event per chunk, then "data: [DONE]".
+// @Tags AI
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Produce text/event-stream
+// @Param request body PredictInput true "Inference request"
+// @Success 200 {object} api.Response{data=PredictResult}
+// @Failure 402 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Failure 422 {object} api.Response
+// @Failure 502 {object} api.Response
+// @Router /ai/predict [post]
+func (c *Controller) Predict(ctx *fiber.Ctx) error {
+	var input PredictInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	if input.Stream || strings.Contains(ctx.Get(fiber.HeaderAccept), "text/event-stream") {
+		if err := c.service.Stream(ctx, &input); err != nil {
+			return api.RespondError(ctx, err)
+		}
+		return nil
+	}
+
+	result, err := c.service.Predict(ctx.UserContext(), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, result)
+}
+
+// ExecutePipeline runs a pipeline
+// @Summary Run a pipeline
+// @Description Runs a pipeline's steps in order on the input. When a step fails, the error details list the steps run.
+// @Tags AI
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Pipeline ID"
+// @Param request body ExecuteInput true "Pipeline input"
+// @Success 200 {object} api.Response{data=ExecuteResult}
+// @Failure 404 {object} api.Response
+// @Failure 502 {object} api.Response
+// @Router /ai/pipelines/{id}/execute [post]
+func (c *Controller) ExecutePipeline(ctx *fiber.Ctx) error {
+	var input ExecuteInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	result, err := c.service.Execute(ctx.UserContext(), ctx.Params("id"), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, result)
+}
+
+// ListAgents lists the registered agents
+// @Summary List agents
+// @Tags AI
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]AgentInfo}
+// @Router /ai/agents [get]
+func (c *Controller) ListAgents(ctx *fiber.Ctx) error {
+	return api.Success(ctx, c.service.Agents())
+}
+
+// RunAgent runs an agent
+// @Summary Run an agent
+// @Description Starts an agent run and responds with it; follow its steps at /ai/agents/runs/{id}. With "wait": true, responds with the finished run.
+// @Tags AI
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param name path string true "Agent name"
+// @Param request body RunAgentInput true "Agent task"
+// @Success 200 {object} api.Response{data=ai.AgentRun}
+// @Success 201 {object} api.Response{data=ai.AgentRun}
+// @Failure 404 {object} api.Response
+// @Router /ai/agents/{name}/runs [post]
+func (c *Controller) RunAgent(ctx *fiber.Ctx) error {
+	var input RunAgentInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	run, err := c.service.RunAgent(ctx.UserContext(), ctx.Params("name"), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	if input.Wait {
+		return api.Success(ctx, run)
+	}
+	return api.Created(ctx, "Agent run started", run)
+}
+
+// ListAgentRuns lists agent runs
+// @Summary List agent runs
+// @Tags AI
+// @Security BearerAuth
+// @Produce json
+// @Param agent query string false "Agent name"
+// @Param status query string false "Run status"
+// @Param caller query string false "Caller, e.g. user:42"
+// @Param limit query int false "Maximum runs, 50 by default"
+// @Success 200 {object} api.Response{data=[]ai.AgentRun}
+// @Router /ai/agents/runs [get]
+func (c *Controller) ListAgentRuns(ctx *fiber.Ctx) error {
+	runs, err := c.service.AgentRuns(ctx.UserContext(), ai.AgentRunFilter{
+		Agent:  ctx.Query("agent"),
+		Caller: ctx.Query("caller"),
+		Status: ai.AgentRunStatus(ctx.Query("status")),
+		Limit:  ctx.QueryInt("limit"),
+	})
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, runs)
+}
+
+// GetAgentRun returns an agent run's trace
+// @Summary Get an agent run
+// @Tags AI
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Run ID"
+// @Success 200 {object} api.Response{data=ai.AgentRun}
+// @Failure 404 {object} api.Response
+// @Router /ai/agents/runs/{id} [get]
+func (c *Controller) GetAgentRun(ctx *fiber.Ctx) error {
+	run, err := c.service.AgentRun(ctx.UserContext(), ctx.Params("id"))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, run)
+}
+
+// CancelAgentRun stops an agent run
+// @Summary Cancel an agent run
+// @Tags AI
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Run ID"
+// @Success 200 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /ai/agents/runs/{id}/cancel [post]
+func (c *Controller) CancelAgentRun(ctx *fiber.Ctx) error {
+	if err := c.service.CancelAgentRun(ctx.UserContext(), ctx.Params("id")); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, fiber.Map{"id": ctx.Params("id"), "cancelled": true})
+}
+
+// ResumeAgentRun continues an agent run
+// @Summary Resume an agent run
+// @Description Continues a cancelled, failed or over-budget run from its saved steps, with a further allowance of budget.
+// @Tags AI
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Run ID"
+// @Param request body ResumeAgentInput false "Further budget"
+// @Success 200 {object} api.Response{data=ai.AgentRun}
+// @Failure 404 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /ai/agents/runs/{id}/resume [post]
+func (c *Controller) ResumeAgentRun(ctx *fiber.Ctx) error {
+	var input ResumeAgentInput
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&input); err != nil {
+			return api.BadRequest(ctx, "Invalid request body", nil)
+		}
+	}
+
+	run, err := c.service.ResumeAgentRun(ctx.UserContext(), ctx.Params("id"), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, run)
+}
+
+// Metrics reports model requests, batching and semantic caching
+// @Summary AI metrics
+// @Tags AI
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=Metrics}
+// @Router /ai/metrics [get]
+func (c *Controller) Metrics(ctx *fiber.Ctx) error {
+	return api.Success(ctx, c.service.Metrics())
+}
+
+// RunPlayground runs a prompt without saving it
+// @Summary Run a prompt in the playground
+// @Description Runs a registered prompt version, a draft template or raw input against a model, with parameters overriding the model's. Model errors are reported on the run.
+// @Tags AI Playground
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body PlaygroundInput true "Playground run"
+// @Success 200 {object} api.Response{data=ExperimentRun}
+// @Failure 400 {object} api.Response
+// @Failure 402 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /ai/playground/run [post]
+func (c *Controller) RunPlayground(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input PlaygroundInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	_, run, err := c.service.Playground(ctx.UserContext(), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, run)
+}
+
+// ListPrompts lists registered prompt versions
+// @Summary List prompt versions
+// @Tags AI Playground
+// @Security BearerAuth
+// @Produce json
+// @Param name query string false "Prompt name"
+// @Success 200 {object} api.Response{data=[]ai.PromptTemplate}
+// @Router /ai/prompts [get]
+func (c *Controller) ListPrompts(ctx *fiber.Ctx) error {
+	prompts, err := c.service.Prompts(ctx.UserContext(), ctx.Query("name"))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, prompts)
+}
+
+// CreateExperiment creates an experiment
+// @Summary Create an experiment
+// @Tags AI Playground
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body ExperimentInput true "Experiment"
+// @Success 201 {object} api.Response{data=Experiment}
+// @Failure 409 {object} api.Response
+// @Router /ai/experiments [post]
+func (c *Controller) CreateExperiment(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input ExperimentInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	experiment, err := c.service.CreateExperiment(ctx.UserContext(), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Experiment created", experiment)
+}
+
+// ListExperiments lists experiments, most recently run first
+// @Summary List experiments
+// @Tags AI Playground
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} api.PaginatedResponse{data=[]Experiment}
+// @Router /ai/experiments [get]
+func (c *Controller) ListExperiments(ctx *fiber.Ctx) error {
+	pagination := api.GetPagination(ctx)
+	experiments, total, err := c.service.ListExperiments(ctx.UserContext(), pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, experiments, pagination.Page, pagination.Limit, total)
+}
+
+// GetExperiment returns an experiment with its runs
+// @Summary Get an experiment
+// @Tags AI Playground
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Experiment ID"
+// @Success 200 {object} api.Response{data=Experiment}
+// @Failure 404 {object} api.Response
+// @Router /ai/experiments/{id} [get]
+func (c *Controller) GetExperiment(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid experiment ID", nil)
+	}
+
+	experiment, err := c.service.GetExperiment(ctx.UserContext(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, experiment)
+}
+
+// DeleteExperiment deletes an experiment and its runs
+// @Summary Delete an experiment
+// @Tags AI Playground
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Experiment ID"
+// @Success 200 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /ai/experiments/{id} [delete]
+func (c *Controller) DeleteExperiment(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid experiment ID", nil)
+	}
+
+	if err := c.service.DeleteExperiment(ctx.UserContext(), uint(id)); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Experiment deleted", nil)
+}
+
+// RunExperiment runs a prompt and saves the run into an experiment
+// @Summary Run an experiment
+// @Description Runs a prompt as the playground does and saves the run, numbered after the experiment's last one. Failed runs are saved with their error.
+// @Tags AI Playground
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Experiment ID"
+// @Param request body PlaygroundInput true "Playground run"
+// @Success 201 {object} api.Response{data=ExperimentRun}
+// @Failure 400 {object} api.Response
+// @Failure 402 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /ai/experiments/{id}/runs [post]
+func (c *Controller) RunExperiment(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid experiment ID", nil)
+	}
+	var input PlaygroundInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	run, err := c.service.RunExperiment(ctx.UserContext(), uint(id), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Run saved", run)
+}
+
+// DiffRuns compares two runs of an experiment
+// @Summary Diff experiment runs
+// @Description Compares the settings, prompts, outputs and metrics of two runs. Prompts and outputs are diffed line by line.
+// @Tags AI Playground
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Experiment ID"
+// @Param from query int true "From run number"
+// @Param to query int true "To run number"
+// @Success 200 {object} api.Response{data=RunDiff}
+// @Failure 404 {object} api.Response
+// @Router /ai/experiments/{id}/diff [get]
+func (c *Controller) DiffRuns(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid experiment ID", nil)
+	}
+
+	from := ctx.QueryInt("from", 0)
+	to := ctx.QueryInt("to", 0)
+	if from <= 0 || to <= 0 {
+		return api.BadRequest(ctx, "Both from and to runs are required", nil)
+	}
+
+	diff, err := c.service.DiffRuns(ctx.UserContext(), uint(id), from, to)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, diff)
+}
+
+// PromoteRun registers a run's prompt as a new prompt version
+// @Summary Promote a run's prompt
+// @Description Registers the template of a successful run as a new version of the experiment's prompt, which "name" references then resolve to.
+// @Tags AI Playground
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Experiment ID"
+// @Param number path int true "Run number"
+// @Param request body PromoteInput true "Prompt version"
+// @Success 201 {object} api.Response{data=ai.PromptTemplate}
+// @Failure 400 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /ai/experiments/{id}/runs/{number}/promote [post]
+func (c *Controller) PromoteRun(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid experiment ID", nil)
+	}
+	number, err := ctx.ParamsInt("number")
+	if err != nil || number <= 0 {
+		return api.BadRequest(ctx, "Invalid run number", nil)
+	}
+	var input PromoteInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	prompt, err := c.service.Promote(ctx.UserContext(), uint(id), number, &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Prompt version registered", prompt)
+}
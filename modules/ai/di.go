@@ -0,0 +1,177 @@
+package ai
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/ai"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/metrics"
+
+	"gorm.io/gorm"
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register the Model Manager shared with other modules, with the
+	// providers configured in the environment and the models in AI_MODELS
+	container.Provide(func() *ai.ModelManager {
+		manager := ai.NewModelManager()
+		providers := manager.RegisterProvidersFromEnv()
+
+		collector := core.Resolve[*metrics.Collector](container)
+		if collector != nil {
+			manager.SetMetrics(collector)
+		}
+		if db != nil {
+			tracker := ai.NewUsageTracker(db, ai.LoadUsageConfig())
+			if collector != nil {
+				tracker.SetMetrics(collector)
+			}
+			manager.SetUsageTracker(tracker)
+
+			if err := ai.NewModelRegistry(db, manager).Load(context.Background()); err != nil {
+				logger.Warn("Failed to load registered AI models", logger.Fields{"error": err.Error()})
+			}
+		}
+
+		for _, config := range loadModelConfigs(os.Getenv("AI_MODELS")) {
+			if _, err := manager.LoadModel(config); err != nil {
+				logger.Warn("Failed to load AI model", logger.Fields{"model": config.ID, "error": err.Error()})
+			}
+		}
+
+		batching, err := ai.LoadBatchConfigs()
+		if err != nil {
+			logger.Warn("Ignoring AI_BATCH_MODELS", logger.Fields{"error": err.Error()})
+		}
+		for modelID, config := range batching {
+			manager.EnableBatching(modelID, config)
+		}
+
+		logger.Info("AI model manager ready", logger.Fields{
+			"providers": strings.Join(providers, ","),
+			"models":    len(manager.ListModels()),
+		})
+		return manager
+	}, core.Singleton)
+
+	if db != nil {
+		// Register the Prompt Registry shared by pipelines and the playground
+		container.Provide(func() *ai.PromptRegistry {
+			return ai.NewPromptRegistry(db)
+		}, core.Singleton)
+
+		// Register Repository
+		container.Provide(func() *Repository {
+			return NewRepository(db)
+		}, core.Singleton)
+	}
+
+	// Register the Pipeline Manager with the pipelines in AI_PIPELINES_DIR
+	container.Provide(func() *ai.PipelineManager {
+		pipelines := ai.NewPipelineManager(core.Resolve[*ai.ModelManager](container))
+		if prompts := core.Resolve[*ai.PromptRegistry](container); prompts != nil {
+			pipelines.SetPromptRegistry(prompts)
+		}
+		if dir := os.Getenv("AI_PIPELINES_DIR"); dir != "" {
+			loadPipelines(pipelines, dir)
+		}
+		return pipelines
+	}, core.Singleton)
+
+	// Register the Agent Manager; modules register their agents and tools
+	// with it. Run traces are kept in the database when there is one.
+	container.Provide(func() *ai.AgentManager {
+		var store ai.AgentStore
+		if db != nil {
+			store = ai.NewSQLAgentStore(db)
+		}
+		return ai.NewAgentManager(core.Resolve[*ai.ModelManager](container), store)
+	}, core.Singleton)
+
+	// Register Service
+	container.Provide(func() *Service {
+		return NewService(
+			core.Resolve[*ai.ModelManager](container),
+			core.Resolve[*ai.PipelineManager](container),
+			core.Resolve[*ai.AgentManager](container),
+			core.Resolve[*ai.PromptRegistry](container),
+			core.Resolve[*Repository](container),
+		)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
+
+// loadModelConfigs parses a comma separated list of id=provider entries,
+// optionally with the model type: "text-embedding-3-small=openai:embedding".
+// The type defaults to text generation.
+func loadModelConfigs(value string) []*ai.ModelConfig {
+	var configs []*ai.ModelConfig
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		modelID, spec, ok := strings.Cut(entry, "=")
+		if !ok || modelID == "" || spec == "" {
+			logger.Warn("Ignoring invalid AI_MODELS entry, expected id=provider", logger.Fields{"entry": entry})
+			continue
+		}
+		provider, modelType, _ := strings.Cut(spec, ":")
+		if modelType == "" {
+			modelType = string(ai.ModelTypeTextGeneration)
+		}
+		configs = append(configs, &ai.ModelConfig{
+			ID:       strings.TrimSpace(modelID),
+			Name:     strings.TrimSpace(modelID),
+			Type:     ai.ModelType(strings.TrimSpace(modelType)),
+			Provider: strings.TrimSpace(provider),
+		})
+	}
+	return configs
+}
+
+// loadPipelines creates the pipelines defined by the YAML and JSON files in
+// dir. Files that fail to parse are logged and skipped.
+func loadPipelines(pipelines *ai.PipelineManager, dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		logger.Warn("Failed to read AI pipelines", logger.Fields{"dir": dir, "error": err.Error()})
+		return
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		ext := strings.ToLower(filepath.Ext(path))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logger.Warn("Failed to read AI pipeline", logger.Fields{"file": path, "error": err.Error()})
+			continue
+		}
+		var pipeline *ai.Pipeline
+		if ext == ".json" {
+			pipeline, err = ai.PipelineFromJSON(data, nil)
+		} else {
+			pipeline, err = ai.PipelineFromYAML(data, nil)
+		}
+		if err != nil {
+			logger.Warn("Invalid AI pipeline", logger.Fields{"file": path, "error": err.Error()})
+			continue
+		}
+		if pipeline.ID == "" {
+			pipeline.ID = strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		}
+		pipelines.CreatePipeline(pipeline)
+	}
+}
@@ -0,0 +1,180 @@
+package ai
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// diffMaxLines bounds the line diff, which is quadratic; longer texts are
+// compared as a whole
+const diffMaxLines = 2000
+
+// RunDiff compares two runs of an experiment
+type RunDiff struct {
+	From       int          `json:"from"`
+	To         int          `json:"to"`
+	Settings   []DiffEntry  `json:"settings"` // Model, prompt version, variables and parameters
+	Prompt     []LineChange `json:"prompt"`
+	Output     []LineChange `json:"output"`
+	Similarity float64      `json:"similarity"` // Share of output lines in common, from 0 to 1
+	Metrics    []DiffEntry  `json:"metrics"`
+}
+
+// DiffEntry is a setting or metric that differs between two runs
+type DiffEntry struct {
+	Path string      `json:"path"`
+	Op   string      `json:"op"` // added, removed or changed
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// LineChange is a line of a text diff
+type LineChange struct {
+	Op   string `json:"op"` // equal, added or removed
+	Text string `json:"text"`
+}
+
+// diffRuns compares what was sent and what came back in two runs
+func diffRuns(from, to *ExperimentRun) *RunDiff {
+	diff := &RunDiff{From: from.Number, To: to.Number}
+
+	diff.Settings = append(diff.Settings, diffValue("model_id", from.ModelID, to.ModelID)...)
+	diff.Settings = append(diff.Settings, diffValue("resolved_model", from.ResolvedModelID, to.ResolvedModelID)...)
+	diff.Settings = append(diff.Settings, diffValue("prompt_ref", from.PromptRef, to.PromptRef)...)
+	diff.Settings = append(diff.Settings, diffValue("template", from.Template, to.Template)...)
+	diff.Settings = append(diff.Settings, diffMaps("variables", from.Variables, to.Variables)...)
+	diff.Settings = append(diff.Settings, diffMaps("parameters", from.Parameters, to.Parameters)...)
+
+	diff.Prompt = diffLines(from.Prompt, to.Prompt)
+	diff.Output = diffLines(from.Output, to.Output)
+	diff.Similarity = similarity(diff.Output)
+
+	diff.Metrics = append(diff.Metrics, diffValue("prompt_tokens", from.PromptTokens, to.PromptTokens)...)
+	diff.Metrics = append(diff.Metrics, diffValue("completion_tokens", from.CompletionTokens, to.CompletionTokens)...)
+	diff.Metrics = append(diff.Metrics, diffValue("latency_ms", from.LatencyMS, to.LatencyMS)...)
+	diff.Metrics = append(diff.Metrics, diffValue("error", from.Error, to.Error)...)
+	return diff
+}
+
+func diffValue(path string, old, new interface{}) []DiffEntry {
+	if reflect.DeepEqual(old, new) {
+		return nil
+	}
+	return []DiffEntry{{Path: path, Op: "changed", Old: old, New: new}}
+}
+
+func diffMaps(path string, old, new map[string]interface{}) []DiffEntry {
+	keys := make(map[string]struct{}, len(old)+len(new))
+	for k := range old {
+		keys[k] = struct{}{}
+	}
+	for k := range new {
+		keys[k] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var entries []DiffEntry
+	for _, k := range sorted {
+		child := path + "." + k
+		oldVal, inOld := old[k]
+		newVal, inNew := new[k]
+		switch {
+		case inOld && !inNew:
+			entries = append(entries, DiffEntry{Path: child, Op: "removed", Old: oldVal})
+		case !inOld && inNew:
+			entries = append(entries, DiffEntry{Path: child, Op: "added", New: newVal})
+		default:
+			entries = append(entries, diffValue(child, oldVal, newVal)...)
+		}
+	}
+	return entries
+}
+
+// diffLines returns the changes turning old into new, line by line, from
+// their longest common subsequence
+func diffLines(old, new string) []LineChange {
+	if old == new {
+		if old == "" {
+			return nil
+		}
+		return []LineChange{{Op: "equal", Text: old}}
+	}
+
+	a, b := splitLines(old), splitLines(new)
+	if len(a) > diffMaxLines || len(b) > diffMaxLines {
+		return []LineChange{{Op: "removed", Text: old}, {Op: "added", Text: new}}
+	}
+
+	// common[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	changes := make([]LineChange, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			changes = append(changes, LineChange{Op: "equal", Text: a[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			changes = append(changes, LineChange{Op: "removed", Text: a[i]})
+			i++
+		default:
+			changes = append(changes, LineChange{Op: "added", Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		changes = append(changes, LineChange{Op: "removed", Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		changes = append(changes, LineChange{Op: "added", Text: b[j]})
+	}
+	return changes
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// similarity is the share of lines two texts have in common, from their
+// diff
+func similarity(changes []LineChange) float64 {
+	if len(changes) == 0 {
+		return 1
+	}
+	var equal, total int
+	for _, change := range changes {
+		lines := 1
+		if change.Op == "equal" {
+			lines = len(splitLines(change.Text))
+			equal += 2 * lines
+			total += 2 * lines
+			continue
+		}
+		total += lines
+	}
+	return float64(equal) / float64(total)
+}
@@ -0,0 +1,54 @@
+package ai
+
+import "time"
+
+// Experiment groups playground runs comparing prompts, models and
+// parameters for one task
+type Experiment struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"size:100;uniqueIndex;not null" json:"name"`
+	Description string    `gorm:"size:1000" json:"description,omitempty"`
+	Prompt      string    `gorm:"size:100" json:"prompt,omitempty"` // Prompt name winning versions are promoted to
+	RunCount    int       `gorm:"not null;default:0" json:"run_count"`
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	Runs []ExperimentRun `gorm:"foreignKey:ExperimentID" json:"runs,omitempty"`
+}
+
+// TableName specifies the table name for Experiment
+func (Experiment) TableName() string {
+	return "ai_experiments"
+}
+
+// ExperimentRun is a saved playground run: the prompt sent to a model
+// with the parameters used, and what came back. Runs are immutable and
+// numbered from 1 within their experiment.
+type ExperimentRun struct {
+	ID               uint                   `gorm:"primarykey" json:"id"`
+	ExperimentID     uint                   `gorm:"not null;uniqueIndex:idx_ai_experiment_runs_number" json:"experiment_id"`
+	Number           int                    `gorm:"not null;uniqueIndex:idx_ai_experiment_runs_number" json:"number"`
+	Label            string                 `gorm:"size:100" json:"label,omitempty"`
+	ModelID          string                 `gorm:"size:255;not null" json:"model_id"`       // As requested, e.g. an alias
+	ResolvedModelID  string                 `gorm:"size:255;not null" json:"resolved_model"` // The model that ran
+	PromptRef        string                 `gorm:"size:151" json:"prompt_ref,omitempty"`    // name@version of a registered prompt
+	Template         string                 `gorm:"type:text" json:"template,omitempty"`     // Empty when the input was sent as is
+	Variables        map[string]interface{} `gorm:"serializer:json" json:"variables,omitempty"`
+	Parameters       map[string]interface{} `gorm:"serializer:json" json:"parameters,omitempty"`
+	Prompt           string                 `gorm:"type:text" json:"prompt"` // As sent to the model
+	Output           string                 `gorm:"type:text" json:"output"`
+	Result           interface{}            `gorm:"serializer:json" json:"result,omitempty"`
+	Error            string                 `gorm:"size:2000" json:"error,omitempty"`
+	PromptTokens     int                    `json:"prompt_tokens"`
+	CompletionTokens int                    `json:"completion_tokens"`
+	LatencyMS        int64                  `json:"latency_ms"`
+	PromotedAs       string                 `gorm:"size:151" json:"promoted_as,omitempty"` // name@version it was promoted to
+	CreatedBy        uint                   `json:"created_by"`
+	CreatedAt        time.Time              `json:"created_at"`
+}
+
+// TableName specifies the table name for ExperimentRun
+func (ExperimentRun) TableName() string {
+	return "ai_experiment_runs"
+}
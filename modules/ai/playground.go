@@ -0,0 +1,336 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/ai"
+	"neonexcore/pkg/errors"
+)
+
+// PlaygroundInput is the payload for running a prompt in the playground.
+// The model gets a registered prompt, a draft template or the input as
+// is: exactly one of Prompt, Template and Input.
+type PlaygroundInput struct {
+	ModelID    string                 `json:"model_id" validate:"required,max=255"`
+	Prompt     string                 `json:"prompt" validate:"max=151"` // name@version, or name for the latest
+	Template   string                 `json:"template" validate:"max=100000"`
+	Input      interface{}            `json:"input"`
+	Variables  map[string]interface{} `json:"variables,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"` // Override the model's, e.g. temperature
+	Label      string                 `json:"label" validate:"max=100"`
+}
+
+// ExperimentInput is the payload for creating an experiment
+type ExperimentInput struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description" validate:"max=1000"`
+	Prompt      string `json:"prompt" validate:"max=100"` // Prompt name winning versions are promoted to
+}
+
+// PromoteInput is the payload for promoting a run's prompt to a version
+type PromoteInput struct {
+	Name        string `json:"name" validate:"max=100"` // The experiment's prompt, or the run's, by default
+	Version     string `json:"version" validate:"required,max=50"`
+	Description string `json:"description" validate:"max=1000"`
+}
+
+// ==================== Playground ====================
+
+// Playground runs a prompt without saving the run
+func (s *Service) Playground(ctx context.Context, input *PlaygroundInput, userID uint) (*ai.PromptTemplate, *ExperimentRun, error) {
+	return s.runPrompt(ctx, input, userID)
+}
+
+// Prompts lists registered prompt versions, newest first, of one prompt
+// or all
+func (s *Service) Prompts(ctx context.Context, name string) ([]ai.PromptTemplate, error) {
+	if s.prompts == nil {
+		return nil, errPlaygroundUnavailable
+	}
+	prompts, err := s.prompts.Versions(ctx, name)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to list prompts").WithError(err)
+	}
+	return prompts, nil
+}
+
+// runPrompt renders the prompt of a playground input and runs the model.
+// Model failures are kept on the run rather than returned, so failed runs
+// can be saved and compared too; budget errors are returned.
+func (s *Service) runPrompt(ctx context.Context, input *PlaygroundInput, userID uint) (*ai.PromptTemplate, *ExperimentRun, error) {
+	sources := 0
+	for _, set := range []bool{input.Prompt != "", input.Template != "", input.Input != nil} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, nil, errors.NewBadRequest("Give exactly one of prompt, template and input")
+	}
+
+	modelID := s.models.ResolveModelID(input.ModelID)
+	if s.models.GetModel(modelID) == nil {
+		return nil, nil, errors.NewNotFound("Model not found: " + input.ModelID)
+	}
+
+	run := &ExperimentRun{
+		Label:           input.Label,
+		ModelID:         input.ModelID,
+		ResolvedModelID: modelID,
+		Variables:       input.Variables,
+		Parameters:      input.Parameters,
+		CreatedBy:       userID,
+		CreatedAt:       time.Now(),
+	}
+
+	var prompt *ai.PromptTemplate
+	var data interface{}
+	switch {
+	case input.Prompt != "":
+		if s.prompts == nil {
+			return nil, nil, errPlaygroundUnavailable
+		}
+		found, err := s.prompts.Get(ctx, input.Prompt)
+		if err != nil {
+			if stderrors.Is(err, ai.ErrPromptNotFound) {
+				return nil, nil, errors.NewNotFound(err.Error())
+			}
+			return nil, nil, errors.NewInternal("Failed to load prompt").WithError(err)
+		}
+		rendered, err := s.prompts.RenderTemplate(found, input.Variables)
+		if err != nil {
+			return nil, nil, errors.NewBadRequest(err.Error())
+		}
+		prompt, data = found, rendered
+		run.PromptRef, run.Template, run.Prompt = found.Ref(), found.Template, rendered
+	case input.Template != "":
+		if s.prompts == nil {
+			return nil, nil, errPlaygroundUnavailable
+		}
+		draft := &ai.PromptTemplate{Name: "playground", Version: "draft", Template: input.Template}
+		rendered, err := s.prompts.RenderDraft(draft, input.Variables)
+		if err != nil {
+			return nil, nil, errors.NewBadRequest(err.Error())
+		}
+		data = rendered
+		run.Template, run.Prompt = input.Template, rendered
+	default:
+		data = input.Input
+		run.Prompt = displayText(input.Input)
+	}
+
+	output, err := s.models.Predict(ctx, &ai.InferenceInput{
+		ModelID:    input.ModelID,
+		Data:       data,
+		Parameters: input.Parameters,
+		Metadata:   map[string]string{"source": "playground"},
+	})
+	if err != nil {
+		if stderrors.Is(err, ai.ErrBudgetExceeded) {
+			return nil, nil, inferenceError(err)
+		}
+		run.Error = err.Error()
+		run.LatencyMS = time.Since(run.CreatedAt).Milliseconds()
+		return prompt, run, nil
+	}
+
+	run.Result = output.Result
+	run.LatencyMS = output.Latency.Milliseconds()
+	if text, ok := ai.OutputText(output.Result); ok {
+		run.Output = text
+	} else {
+		run.Output = displayText(output.Result)
+	}
+	if promptTokens, completionTokens, ok := ai.ExtractUsage(output.Result); ok {
+		run.PromptTokens, run.CompletionTokens = promptTokens, completionTokens
+	} else {
+		run.PromptTokens, run.CompletionTokens = ai.EstimateTokens(run.Prompt), ai.EstimateTokens(run.Output)
+	}
+	return prompt, run, nil
+}
+
+// ==================== Experiments ====================
+
+func (s *Service) CreateExperiment(ctx context.Context, input *ExperimentInput, userID uint) (*Experiment, error) {
+	if s.repo == nil {
+		return nil, errPlaygroundUnavailable
+	}
+	if strings.Contains(input.Prompt, "@") {
+		return nil, errors.NewBadRequest("Prompt names cannot contain @")
+	}
+
+	experiment := &Experiment{
+		Name:        input.Name,
+		Description: input.Description,
+		Prompt:      input.Prompt,
+		CreatedBy:   userID,
+	}
+	if err := s.repo.CreateExperiment(ctx, experiment); err != nil {
+		return nil, errors.NewConflict("An experiment with this name already exists")
+	}
+	return experiment, nil
+}
+
+func (s *Service) ListExperiments(ctx context.Context, page, limit int) ([]Experiment, int64, error) {
+	if s.repo == nil {
+		return nil, 0, errPlaygroundUnavailable
+	}
+	experiments, total, err := s.repo.ListExperiments(ctx, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list experiments").WithError(err)
+	}
+	return experiments, total, nil
+}
+
+// GetExperiment returns an experiment with its runs
+func (s *Service) GetExperiment(ctx context.Context, id uint) (*Experiment, error) {
+	if s.repo == nil {
+		return nil, errPlaygroundUnavailable
+	}
+	experiment, err := s.repo.FindExperiment(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load experiment").WithError(err)
+	}
+	if experiment == nil {
+		return nil, errors.NewNotFound("Experiment not found")
+	}
+	return experiment, nil
+}
+
+func (s *Service) DeleteExperiment(ctx context.Context, id uint) error {
+	if _, err := s.GetExperiment(ctx, id); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteExperiment(ctx, id); err != nil {
+		return errors.NewInternal("Failed to delete experiment").WithError(err)
+	}
+	return nil
+}
+
+// RunExperiment runs a prompt and saves the run into an experiment
+func (s *Service) RunExperiment(ctx context.Context, id uint, input *PlaygroundInput, userID uint) (*ExperimentRun, error) {
+	if _, err := s.GetExperiment(ctx, id); err != nil {
+		return nil, err
+	}
+
+	_, run, err := s.runPrompt(ctx, input, userID)
+	if err != nil {
+		return nil, err
+	}
+	run.ExperimentID = id
+	if err := s.repo.AddRun(ctx, run); err != nil {
+		return nil, errors.NewInternal("Failed to save run").WithError(err)
+	}
+	return run, nil
+}
+
+// GetRun returns an experiment's run by number
+func (s *Service) GetRun(ctx context.Context, id uint, number int) (*ExperimentRun, error) {
+	if s.repo == nil {
+		return nil, errPlaygroundUnavailable
+	}
+	run, err := s.repo.FindRun(ctx, id, number)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load run").WithError(err)
+	}
+	if run == nil {
+		return nil, errors.NewNotFound(fmt.Sprintf("Run %d not found", number))
+	}
+	return run, nil
+}
+
+// DiffRuns compares two runs of an experiment
+func (s *Service) DiffRuns(ctx context.Context, id uint, from, to int) (*RunDiff, error) {
+	fromRun, err := s.GetRun(ctx, id, from)
+	if err != nil {
+		return nil, err
+	}
+	toRun, err := s.GetRun(ctx, id, to)
+	if err != nil {
+		return nil, err
+	}
+	return diffRuns(fromRun, toRun), nil
+}
+
+// Promote registers the prompt template of a run as a new version in the
+// prompt registry, which makes it the version "name" references resolve
+// to
+func (s *Service) Promote(ctx context.Context, id uint, number int, input *PromoteInput, userID uint) (*ai.PromptTemplate, error) {
+	experiment, err := s.GetExperiment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	run, err := s.GetRun(ctx, id, number)
+	if err != nil {
+		return nil, err
+	}
+	if run.Template == "" {
+		return nil, errors.NewBadRequest("Only runs of a prompt template can be promoted")
+	}
+	if run.Error != "" {
+		return nil, errors.NewBadRequest("Failed runs cannot be promoted")
+	}
+
+	name := input.Name
+	if name == "" {
+		name = experiment.Prompt
+	}
+	if name == "" && run.PromptRef != "" {
+		name, _, _ = strings.Cut(run.PromptRef, "@")
+	}
+	if name == "" {
+		return nil, errors.NewBadRequest("Name the prompt to promote the run to")
+	}
+
+	description := input.Description
+	if description == "" {
+		description = fmt.Sprintf("Promoted from run %d of experiment %s", run.Number, experiment.Name)
+	}
+	prompt := &ai.PromptTemplate{
+		Name:        name,
+		Version:     input.Version,
+		Description: description,
+		Template:    run.Template,
+		CreatedBy:   ai.UserCaller(userID),
+	}
+	// Keep the limits of the version the run was made from
+	if run.PromptRef != "" {
+		if source, err := s.prompts.Get(ctx, run.PromptRef); err == nil {
+			prompt.Variables, prompt.MaxLength, prompt.Forbidden = source.Variables, source.MaxLength, source.Forbidden
+		}
+	}
+
+	registered, err := s.prompts.Register(ctx, prompt)
+	if err != nil {
+		if strings.Contains(err.Error(), "already registered") {
+			return nil, errors.NewConflict(err.Error())
+		}
+		return nil, errors.NewBadRequest(err.Error())
+	}
+	if err := s.repo.MarkPromoted(ctx, run.ID, registered.Ref()); err != nil {
+		return nil, errors.NewInternal("Failed to record promotion").WithError(err)
+	}
+	return registered, nil
+}
+
+// errPlaygroundUnavailable is returned without a database to keep prompts
+// and experiments in
+var errPlaygroundUnavailable = errors.New(errors.ErrCodeInternal, "The prompt playground needs a database", http.StatusServiceUnavailable)
+
+// displayText returns a string as is and anything else as JSON
+func displayText(v interface{}) string {
+	if text, ok := v.(string); ok {
+		return text
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
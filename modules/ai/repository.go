@@ -0,0 +1,96 @@
+package ai
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ==================== Experiments ====================
+
+func (r *Repository) CreateExperiment(ctx context.Context, experiment *Experiment) error {
+	return r.db.WithContext(ctx).Create(experiment).Error
+}
+
+func (r *Repository) ListExperiments(ctx context.Context, page, limit int) ([]Experiment, int64, error) {
+	var experiments []Experiment
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Experiment{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("updated_at DESC").Offset(offset).Limit(limit).Find(&experiments).Error
+	return experiments, total, err
+}
+
+// FindExperiment returns an experiment with its runs, or nil
+func (r *Repository) FindExperiment(ctx context.Context, id uint) (*Experiment, error) {
+	var experiment Experiment
+	err := r.db.WithContext(ctx).
+		Preload("Runs", func(db *gorm.DB) *gorm.DB { return db.Order("number") }).
+		First(&experiment, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &experiment, nil
+}
+
+func (r *Repository) DeleteExperiment(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("experiment_id = ?", id).Delete(&ExperimentRun{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Experiment{}, id).Error
+	})
+}
+
+// ==================== Runs ====================
+
+// AddRun numbers a run after the experiment's last one and saves it
+func (r *Repository) AddRun(ctx context.Context, run *ExperimentRun) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var experiment Experiment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&experiment, run.ExperimentID).Error; err != nil {
+			return err
+		}
+		run.Number = experiment.RunCount + 1
+		if err := tx.Create(run).Error; err != nil {
+			return err
+		}
+		return tx.Model(&experiment).Update("run_count", run.Number).Error
+	})
+}
+
+// FindRun returns an experiment's run by number, or nil
+func (r *Repository) FindRun(ctx context.Context, experimentID uint, number int) (*ExperimentRun, error) {
+	var run ExperimentRun
+	err := r.db.WithContext(ctx).Where("experiment_id = ? AND number = ?", experimentID, number).First(&run).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+// MarkPromoted records the prompt version a run was promoted to
+func (r *Repository) MarkPromoted(ctx context.Context, id uint, ref string) error {
+	return r.db.WithContext(ctx).Model(&ExperimentRun{}).Where("id = ?", id).Update("promoted_as", ref).Error
+}
@@ -0,0 +1,44 @@
+package ai
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/ai"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	// Write token usage to the database in the background
+	if tracker := core.Resolve[*ai.ModelManager](container).UsageTracker(); tracker != nil {
+		tracker.Start()
+	}
+
+	// Usage is charged to the authenticated user
+	group := router.Group("/ai", auth.AuthMiddleware(jwtManager, auth.AcceptAPIKeys()), ai.CallerMiddleware())
+	group.Get("/models", rbac.RequirePermission(rbacManager, "ai.models.read"), controller.ListModels)
+	group.Post("/predict", rbac.RequirePermission(rbacManager, "ai.predict"), controller.Predict)
+	group.Post("/pipelines/:id/execute", rbac.RequirePermission(rbacManager, "ai.pipelines.execute"), controller.ExecutePipeline)
+	group.Get("/agents", rbac.RequirePermission(rbacManager, "ai.agents.read"), controller.ListAgents)
+	group.Get("/agents/runs", rbac.RequirePermission(rbacManager, "ai.agents.read"), controller.ListAgentRuns)
+	group.Get("/agents/runs/:id", rbac.RequirePermission(rbacManager, "ai.agents.read"), controller.GetAgentRun)
+	group.Post("/agents/runs/:id/cancel", rbac.RequirePermission(rbacManager, "ai.agents.run"), controller.CancelAgentRun)
+	group.Post("/agents/runs/:id/resume", rbac.RequirePermission(rbacManager, "ai.agents.run"), controller.ResumeAgentRun)
+	group.Post("/agents/:name/runs", rbac.RequirePermission(rbacManager, "ai.agents.run"), controller.RunAgent)
+	group.Post("/playground/run", rbac.RequirePermission(rbacManager, "ai.playground"), controller.RunPlayground)
+	group.Get("/prompts", rbac.RequirePermission(rbacManager, "ai.playground"), controller.ListPrompts)
+	group.Get("/experiments", rbac.RequirePermission(rbacManager, "ai.playground"), controller.ListExperiments)
+	group.Post("/experiments", rbac.RequirePermission(rbacManager, "ai.playground"), controller.CreateExperiment)
+	group.Get("/experiments/:id", rbac.RequirePermission(rbacManager, "ai.playground"), controller.GetExperiment)
+	group.Delete("/experiments/:id", rbac.RequirePermission(rbacManager, "ai.playground"), controller.DeleteExperiment)
+	group.Post("/experiments/:id/runs", rbac.RequirePermission(rbacManager, "ai.playground"), controller.RunExperiment)
+	group.Get("/experiments/:id/diff", rbac.RequirePermission(rbacManager, "ai.playground"), controller.DiffRuns)
+	group.Post("/experiments/:id/runs/:number/promote", rbac.RequirePermission(rbacManager, "ai.prompts.manage"), controller.PromoteRun)
+	group.Get("/metrics", rbac.RequirePermission(rbacManager, "ai.metrics.read"), controller.Metrics)
+}
@@ -0,0 +1,351 @@
+package ai
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"neonexcore/pkg/ai"
+	"neonexcore/pkg/errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Error codes specific to inference
+const (
+	ErrCodeBudgetExceeded    errors.ErrorCode = "BUDGET_EXCEEDED"
+	ErrCodeGuardrailRejected errors.ErrorCode = "GUARDRAIL_REJECTED"
+	ErrCodeInferenceFailed   errors.ErrorCode = "INFERENCE_FAILED"
+)
+
+// PredictInput is the payload for running a model
+type PredictInput struct {
+	ModelID    string                 `json:"model_id" validate:"required,max=255"`
+	Input      interface{}            `json:"input" validate:"required"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Metadata   map[string]string      `json:"metadata,omitempty"`
+	Stream     bool                   `json:"stream,omitempty"` // Respond with Server-Sent Events
+}
+
+// ExecuteInput is the payload for running a pipeline
+type ExecuteInput struct {
+	Input interface{} `json:"input" validate:"required"`
+}
+
+// RunAgentInput is the payload for running an agent
+type RunAgentInput struct {
+	Input  string         `json:"input" validate:"required"`
+	Budget ai.AgentBudget `json:"budget"` // Lowers the agent's budget
+	Wait   bool           `json:"wait"`   // Respond with the finished run
+}
+
+// ResumeAgentInput is the payload for resuming an agent run
+type ResumeAgentInput struct {
+	Budget ai.AgentBudget `json:"budget"` // Further allowance, the agent's budget by default
+}
+
+// AgentInfo describes a registered agent
+type AgentInfo struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	ModelID     string         `json:"model_id"`
+	Tools       []string       `json:"tools"`
+	Budget      ai.AgentBudget `json:"budget"`
+}
+
+// ModelInfo describes a loaded model
+type ModelInfo struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Version      string            `json:"version,omitempty"`
+	Type         ai.ModelType      `json:"type"`
+	Status       ai.ModelStatus    `json:"status"`
+	Provider     string            `json:"provider"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	LoadedAt     time.Time         `json:"loaded_at"`
+	LastUsedAt   *time.Time        `json:"last_used_at,omitempty"`
+	RequestCount int64             `json:"request_count"`
+}
+
+// PredictResult is the result of running a model
+type PredictResult struct {
+	ModelID   string                 `json:"model_id"`
+	Result    interface{}            `json:"result"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	LatencyMS int64                  `json:"latency_ms"`
+}
+
+// StepInfo reports one pipeline step
+type StepInfo struct {
+	Name      string      `json:"name"`
+	Output    interface{} `json:"output,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	LatencyMS int64       `json:"latency_ms"`
+}
+
+// ExecuteResult is the result of running a pipeline
+type ExecuteResult struct {
+	PipelineID string      `json:"pipeline_id"`
+	Output     interface{} `json:"output"`
+	Steps      []StepInfo  `json:"steps"`
+	LatencyMS  int64       `json:"latency_ms"`
+}
+
+// ModelMetrics reports a model's requests
+type ModelMetrics struct {
+	ModelID       string     `json:"model_id"`
+	Requests      int64      `json:"requests"`
+	Errors        int64      `json:"errors"`
+	AvgLatencyMS  float64    `json:"avg_latency_ms"`
+	LastRequestAt *time.Time `json:"last_request_at,omitempty"`
+}
+
+// Metrics reports the model manager
+type Metrics struct {
+	Models        []ModelMetrics          `json:"models"`
+	Batching      []ai.BatchStats         `json:"batching"`
+	SemanticCache []ai.SemanticCacheStats `json:"semantic_cache"`
+}
+
+type Service struct {
+	models    *ai.ModelManager
+	pipelines *ai.PipelineManager
+	agents    *ai.AgentManager
+	prompts   *ai.PromptRegistry // nil without a database
+	repo      *Repository        // nil without a database
+}
+
+func NewService(models *ai.ModelManager, pipelines *ai.PipelineManager, agents *ai.AgentManager, prompts *ai.PromptRegistry, repo *Repository) *Service {
+	return &Service{models: models, pipelines: pipelines, agents: agents, prompts: prompts, repo: repo}
+}
+
+// Models lists the loaded models by ID
+func (s *Service) Models() []ModelInfo {
+	models := s.models.ListModels()
+	infos := make([]ModelInfo, 0, len(models))
+	for _, model := range models {
+		info := ModelInfo{
+			ID:       model.ID,
+			Name:     model.Name,
+			Version:  model.Version,
+			Type:     model.Type,
+			Status:   model.Status,
+			Provider: model.Provider,
+			Metadata: model.Metadata,
+			LoadedAt: model.LoadedAt,
+		}
+		// Providers that keep metrics count requests themselves
+		if metrics := s.models.GetMetrics(model.ID); metrics != nil {
+			info.RequestCount = metrics.RequestCount
+			info.LastUsedAt = optionalTime(metrics.LastRequestAt)
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Input returns the inference input for a request, after checking the
+// model exists. Aliases such as "name@production" are resolved.
+func (s *Service) Input(input *PredictInput) (*ai.InferenceInput, error) {
+	modelID := s.models.ResolveModelID(input.ModelID)
+	if s.models.GetModel(modelID) == nil {
+		return nil, errors.NewNotFound("Model not found: " + input.ModelID)
+	}
+	return &ai.InferenceInput{
+		ModelID:    input.ModelID,
+		Data:       input.Input,
+		Parameters: input.Parameters,
+		Metadata:   input.Metadata,
+	}, nil
+}
+
+// Predict runs a model
+func (s *Service) Predict(ctx context.Context, input *PredictInput) (*PredictResult, error) {
+	inference, err := s.Input(input)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := s.models.Predict(ctx, inference)
+	if err != nil {
+		return nil, inferenceError(err)
+	}
+	return &PredictResult{
+		ModelID:   output.ModelID,
+		Result:    output.Result,
+		Metadata:  output.Metadata,
+		LatencyMS: output.Latency.Milliseconds(),
+	}, nil
+}
+
+// Execute runs a pipeline. When a step fails, the error details hold the
+// steps run so far.
+func (s *Service) Execute(ctx context.Context, pipelineID string, input *ExecuteInput) (*ExecuteResult, error) {
+	if _, err := s.pipelines.GetPipeline(pipelineID); err != nil {
+		return nil, errors.NewNotFound("Pipeline not found: " + pipelineID)
+	}
+
+	result, err := s.pipelines.Execute(ctx, pipelineID, input.Input)
+	if result == nil {
+		return nil, inferenceError(err)
+	}
+
+	execution := &ExecuteResult{
+		PipelineID: result.PipelineID,
+		Output:     result.Output,
+		Steps:      make([]StepInfo, 0, len(result.StepResults)),
+		LatencyMS:  result.Latency.Milliseconds(),
+	}
+	for _, step := range result.StepResults {
+		info := StepInfo{Name: step.StepName, LatencyMS: step.Latency.Milliseconds()}
+		if step.Error != nil {
+			info.Error = step.Error.Error()
+		} else {
+			info.Output = step.Output
+		}
+		execution.Steps = append(execution.Steps, info)
+	}
+	if err != nil {
+		return nil, inferenceError(err).WithDetails(map[string]interface{}{"steps": execution.Steps})
+	}
+	return execution, nil
+}
+
+// Stream runs a model, streaming the result to the client as Server-Sent
+// Events
+func (s *Service) Stream(c *fiber.Ctx, input *PredictInput) error {
+	inference, err := s.Input(input)
+	if err != nil {
+		return err
+	}
+	return ai.StreamSSE(c, s.models, inference)
+}
+
+// Agents lists the registered agents
+func (s *Service) Agents() []AgentInfo {
+	agents := s.agents.ListAgents()
+	infos := make([]AgentInfo, 0, len(agents))
+	for _, agent := range agents {
+		info := AgentInfo{
+			Name:        agent.Name,
+			Description: agent.Description,
+			ModelID:     agent.ModelID,
+			Tools:       make([]string, 0, len(agent.Tools)),
+			Budget:      agent.Budget,
+		}
+		for _, tool := range agent.Tools {
+			info.Tools = append(info.Tools, tool.Name)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// RunAgent starts an agent run, or with Wait runs it to the end
+func (s *Service) RunAgent(ctx context.Context, name string, input *RunAgentInput) (*ai.AgentRun, error) {
+	var run *ai.AgentRun
+	var err error
+	if input.Wait {
+		run, err = s.agents.Run(ctx, name, input.Input, input.Budget)
+	} else {
+		run, err = s.agents.Start(ctx, name, input.Input, input.Budget)
+	}
+	if err != nil {
+		return nil, agentError(err)
+	}
+	return run, nil
+}
+
+// AgentRuns lists agent runs, newest first
+func (s *Service) AgentRuns(ctx context.Context, filter ai.AgentRunFilter) ([]*ai.AgentRun, error) {
+	runs, err := s.agents.ListRuns(ctx, filter)
+	if err != nil {
+		return nil, agentError(err)
+	}
+	return runs, nil
+}
+
+// AgentRun returns the trace of an agent run
+func (s *Service) AgentRun(ctx context.Context, runID string) (*ai.AgentRun, error) {
+	run, err := s.agents.GetRun(ctx, runID)
+	if err != nil {
+		return nil, agentError(err)
+	}
+	return run, nil
+}
+
+// CancelAgentRun stops an agent run
+func (s *Service) CancelAgentRun(ctx context.Context, runID string) error {
+	if err := s.agents.Cancel(ctx, runID); err != nil {
+		return agentError(err)
+	}
+	return nil
+}
+
+// ResumeAgentRun continues an unfinished agent run in the background
+func (s *Service) ResumeAgentRun(ctx context.Context, runID string, input *ResumeAgentInput) (*ai.AgentRun, error) {
+	run, err := s.agents.Resume(ctx, runID, input.Budget)
+	if err != nil {
+		return nil, agentError(err)
+	}
+	return run, nil
+}
+
+// Metrics reports requests per model, batching and semantic caching
+func (s *Service) Metrics() *Metrics {
+	all := s.models.GetAllMetrics()
+	report := &Metrics{
+		Models:        make([]ModelMetrics, 0, len(all)),
+		Batching:      s.models.BatchStats(),
+		SemanticCache: s.models.SemanticCacheStats(),
+	}
+	for modelID, metrics := range all {
+		model := ModelMetrics{
+			ModelID:       modelID,
+			Requests:      metrics.RequestCount,
+			Errors:        metrics.ErrorCount,
+			LastRequestAt: optionalTime(metrics.LastRequestAt),
+		}
+		if metrics.RequestCount > 0 {
+			model.AvgLatencyMS = float64(metrics.TotalLatency.Microseconds()) / float64(metrics.RequestCount) / 1000
+		}
+		report.Models = append(report.Models, model)
+	}
+	sort.Slice(report.Models, func(i, j int) bool { return report.Models[i].ModelID < report.Models[j].ModelID })
+	return report
+}
+
+// inferenceError maps model and pipeline errors to status codes: budgets
+// to 402, guardrail rejections to 422 and provider failures to 502
+func inferenceError(err error) *errors.AppError {
+	switch {
+	case stderrors.Is(err, ai.ErrBudgetExceeded):
+		return errors.New(ErrCodeBudgetExceeded, err.Error(), http.StatusPaymentRequired)
+	case stderrors.Is(err, ai.ErrGuardrailRejected):
+		return errors.New(ErrCodeGuardrailRejected, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		return errors.New(ErrCodeInferenceFailed, err.Error(), http.StatusBadGateway)
+	}
+}
+
+// agentError maps unknown agents and runs to 404 and finished runs to 409
+func agentError(err error) *errors.AppError {
+	switch {
+	case stderrors.Is(err, ai.ErrAgentNotFound), stderrors.Is(err, ai.ErrAgentRunNotFound):
+		return errors.NewNotFound(err.Error())
+	case stderrors.Is(err, ai.ErrAgentRunFinished):
+		return errors.NewConflict(err.Error())
+	default:
+		return errors.NewInternal(err.Error())
+	}
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
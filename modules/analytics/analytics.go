@@ -0,0 +1,28 @@
+package analytics
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AnalyticsModule struct{}
+
+func New() *AnalyticsModule {
+	return &AnalyticsModule{}
+}
+
+func (m *AnalyticsModule) Name() string {
+	return "analytics"
+}
+
+func (m *AnalyticsModule) Init() {}
+
+func (m *AnalyticsModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *AnalyticsModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
@@ -0,0 +1,132 @@
+package analytics
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// List lists the queries the user may run
+// @Summary List analytics queries
+// @Tags Analytics
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]Query}
+// @Router /analytics/queries [get]
+func (c *Controller) List(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	queries, err := c.service.List(ctx.UserContext(), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, queries)
+}
+
+// Get describes a query and its parameters
+// @Summary Get analytics query
+// @Tags Analytics
+// @Security BearerAuth
+// @Produce json
+// @Param name path string true "Query name"
+// @Success 200 {object} api.Response{data=Query}
+// @Failure 403 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /analytics/queries/{name} [get]
+func (c *Controller) Get(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	query, err := c.service.Get(ctx.UserContext(), userID, ctx.Params("name"))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, query)
+}
+
+// Run runs a query with parameters from the query string
+// @Summary Run analytics query
+// @Description Parameters are passed by name, e.g. ?from=2024-01-01&to=-1d. Results are cached and cut short at the row limit.
+// @Tags Analytics
+// @Security BearerAuth
+// @Produce json
+// @Param name path string true "Query name"
+// @Success 200 {object} api.Response{data=Result}
+// @Failure 400 {object} api.Response
+// @Failure 403 {object} api.Response
+// @Failure 429 {object} api.Response
+// @Router /analytics/queries/{name}/results [get]
+func (c *Controller) Run(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	result, err := c.service.Run(ctx.UserContext(), userID, ctx.Params("name"), queryValues(ctx))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, result)
+}
+
+// Export runs a query and returns its result as CSV
+// @Summary Export analytics query as CSV
+// @Description X-Result-Truncated is true when more rows matched than the limit allows
+// @Tags Analytics
+// @Security BearerAuth
+// @Produce text/csv
+// @Param name path string true "Query name"
+// @Success 200 {file} file
+// @Failure 400 {object} api.Response
+// @Failure 403 {object} api.Response
+// @Router /analytics/queries/{name}/export [get]
+func (c *Controller) Export(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	result, err := c.service.Run(ctx.UserContext(), userID, ctx.Params("name"), queryValues(ctx))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	var body bytes.Buffer
+	if err := WriteCSV(&body, result); err != nil {
+		logger.Error("Analytics export failed", logger.Fields{"query": result.Query, "error": err.Error()})
+		return api.InternalError(ctx, "Failed to export query")
+	}
+
+	ctx.Set(fiber.HeaderContentType, "text/csv")
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s-%s.csv"`, result.Query, result.RunAt.Format("20060102-150405")))
+	ctx.Set("X-Result-Truncated", fmt.Sprint(result.Truncated))
+	return ctx.Send(body.Bytes())
+}
+
+// ClearCache drops a query's cached results
+// @Summary Clear analytics query cache
+// @Tags Analytics
+// @Security BearerAuth
+// @Param name path string true "Query name"
+// @Success 204
+// @Failure 404 {object} api.Response
+// @Router /analytics/queries/{name}/cache [delete]
+func (c *Controller) ClearCache(ctx *fiber.Ctx) error {
+	if err := c.service.Invalidate(ctx.Context(), ctx.Params("name")); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.NoContent(ctx)
+}
+
+// queryValues copies the query string, whose values fiber reuses after the
+// request, as results holding them may be cached
+func queryValues(ctx *fiber.Ctx) map[string]string {
+	values := make(map[string]string)
+	for key, value := range ctx.Queries() {
+		values[strings.Clone(key)] = strings.Clone(value)
+	}
+	return values
+}
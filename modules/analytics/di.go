@@ -0,0 +1,56 @@
+package analytics
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/metrics"
+	"neonexcore/pkg/rbac"
+
+	"gorm.io/gorm"
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Service
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		if rows, err := strconv.Atoi(os.Getenv("ANALYTICS_MAX_ROWS")); err == nil && rows > 0 {
+			config.MaxRows = rows
+		}
+		if ttl, err := time.ParseDuration(os.Getenv("ANALYTICS_CACHE_TTL")); err == nil && ttl >= 0 {
+			config.CacheTTL = ttl
+		}
+		if timeout, err := time.ParseDuration(os.Getenv("ANALYTICS_TIMEOUT")); err == nil && timeout > 0 {
+			config.Timeout = timeout
+		}
+		if n, err := strconv.Atoi(os.Getenv("ANALYTICS_MAX_CONCURRENT")); err == nil && n > 0 {
+			config.MaxConcurrent = n
+		}
+
+		// Results are shared between instances when a cache is registered
+		store := core.Resolve[cache.Cache](container)
+		if store == nil {
+			store = cache.NewMemoryCache(cache.DefaultMemoryCacheConfig())
+		}
+		return NewService(
+			core.Resolve[*Repository](container),
+			store,
+			core.Resolve[*rbac.Manager](container),
+			core.Resolve[*metrics.Collector](container),
+			config,
+		)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
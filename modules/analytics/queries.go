@@ -0,0 +1,54 @@
+package analytics
+
+func init() {
+	for _, query := range DefaultQueries() {
+		MustRegister(query)
+	}
+}
+
+// DefaultQueries are the built-in queries over the platform's own tables.
+// The SQL is portable across PostgreSQL, MySQL and SQLite.
+func DefaultQueries() []Query {
+	return []Query{
+		{
+			Name:        "signups_daily",
+			Title:       "Signups per day",
+			Description: "New user accounts per day",
+			Category:    "users",
+			SQL: `SELECT DATE(created_at) AS day, COUNT(*) AS signups
+				FROM users
+				WHERE deleted_at IS NULL AND created_at >= @from AND created_at < @to
+				GROUP BY DATE(created_at)
+				ORDER BY day`,
+			Params: []Param{
+				{Name: "from", Type: ParamDate, Default: "-30d", Description: "First day"},
+				{Name: "to", Type: ParamDate, Default: "+1d", Description: "Day after the last"},
+			},
+		},
+		{
+			Name:        "active_users",
+			Title:       "Active users",
+			Description: "Users who signed in since a time, by email verification",
+			Category:    "users",
+			SQL: `SELECT is_email_verified AS verified, COUNT(*) AS users
+				FROM users
+				WHERE deleted_at IS NULL AND last_login_at >= @since
+				GROUP BY is_email_verified`,
+			Params: []Param{
+				{Name: "since", Type: ParamDateTime, Default: "-168h", Description: "Signed in after"},
+			},
+		},
+		{
+			Name:        "users_by_role",
+			Title:       "Users by role",
+			Description: "Number of users holding each role",
+			Category:    "users",
+			SQL: `SELECT roles.name AS role, COUNT(user_roles.user_id) AS users
+				FROM roles
+				LEFT JOIN user_roles ON user_roles.role_id = roles.id
+				GROUP BY roles.name
+				ORDER BY users DESC, role`,
+			Permission: "admin.users.manage",
+		},
+	}
+}
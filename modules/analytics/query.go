@@ -0,0 +1,307 @@
+package analytics
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Parameter types
+const (
+	ParamString   = "string"
+	ParamInt      = "int"
+	ParamFloat    = "float"
+	ParamBool     = "bool"
+	ParamDate     = "date"     // 2006-01-02, or relative such as -30d
+	ParamDateTime = "datetime" // RFC 3339, or relative such as -24h
+)
+
+// DefaultPermission is required to run queries that set none
+const DefaultPermission = "analytics.query"
+
+// Query is a read-only SQL query defined by developers and run by users
+// with its permission. Parameters are referenced as @name and always bound,
+// never interpolated.
+type Query struct {
+	Name        string        `json:"name"`
+	Title       string        `json:"title"`
+	Description string        `json:"description,omitempty"`
+	Category    string        `json:"category,omitempty"`
+	SQL         string        `json:"-"`
+	Params      []Param       `json:"params,omitempty"`
+	Permission  string        `json:"permission"`         // DefaultPermission when empty
+	MaxRows     int           `json:"max_rows,omitempty"` // Lower than the configured limit, if set
+	CacheTTL    time.Duration `json:"-"`                  // The configured TTL when zero, no caching when negative
+	Timeout     time.Duration `json:"-"`                  // Shorter than the configured timeout, if set
+}
+
+// Param is a query parameter
+type Param struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Description string   `json:"description,omitempty"`
+	Required    bool     `json:"required,omitempty"`
+	Default     string   `json:"default,omitempty"`
+	Options     []string `json:"options,omitempty"` // Allowed values, if any
+	Min         *float64 `json:"min,omitempty"`     // Numbers only
+	Max         *float64 `json:"max,omitempty"`
+}
+
+var (
+	namePattern  = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	paramPattern = regexp.MustCompile(`@([A-Za-z_][A-Za-z0-9_]*)`)
+	readPattern  = regexp.MustCompile(`(?i)^(select|with)\b`)
+)
+
+// registry holds the queries registered by the app and its modules
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Query)
+)
+
+// Register adds or replaces a query, after checking it is a single
+// SELECT whose parameters are all declared
+func Register(query Query) error {
+	if err := validateQuery(&query); err != nil {
+		return fmt.Errorf("analytics query %q: %w", query.Name, err)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[query.Name] = &query
+	return nil
+}
+
+// MustRegister registers a query and panics if it is invalid
+func MustRegister(query Query) {
+	if err := Register(query); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns a registered query by name
+func Lookup(name string) (*Query, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	query, ok := registry[name]
+	return query, ok
+}
+
+// Queries returns the registered queries sorted by category and name
+func Queries() []*Query {
+	registryMu.RLock()
+	queries := make([]*Query, 0, len(registry))
+	for _, query := range registry {
+		queries = append(queries, query)
+	}
+	registryMu.RUnlock()
+
+	sort.Slice(queries, func(i, j int) bool {
+		if queries[i].Category != queries[j].Category {
+			return queries[i].Category < queries[j].Category
+		}
+		return queries[i].Name < queries[j].Name
+	})
+	return queries
+}
+
+func validateQuery(query *Query) error {
+	if !namePattern.MatchString(query.Name) {
+		return fmt.Errorf("name must be lowercase letters, digits and underscores")
+	}
+	if query.Title == "" {
+		query.Title = query.Name
+	}
+	if query.Permission == "" {
+		query.Permission = DefaultPermission
+	}
+
+	// One statement that only reads: the row limit wraps it in a subquery,
+	// which rejects anything but a SELECT
+	query.SQL = strings.TrimSuffix(strings.TrimSpace(query.SQL), ";")
+	if !readPattern.MatchString(query.SQL) {
+		return fmt.Errorf("SQL must be a SELECT")
+	}
+	if strings.Contains(query.SQL, ";") {
+		return fmt.Errorf("SQL must be a single statement")
+	}
+
+	declared := make(map[string]bool, len(query.Params))
+	for i := range query.Params {
+		param := &query.Params[i]
+		if !namePattern.MatchString(param.Name) {
+			return fmt.Errorf("param %q: name must be lowercase letters, digits and underscores", param.Name)
+		}
+		if declared[param.Name] {
+			return fmt.Errorf("param %q declared twice", param.Name)
+		}
+		declared[param.Name] = true
+
+		if param.Type == "" {
+			param.Type = ParamString
+		}
+		switch param.Type {
+		case ParamString, ParamInt, ParamFloat, ParamBool, ParamDate, ParamDateTime:
+		default:
+			return fmt.Errorf("param %q: unknown type %q", param.Name, param.Type)
+		}
+		if param.Default != "" {
+			if _, err := param.parse(param.Default, time.Now()); err != nil {
+				return fmt.Errorf("param %q: default: %w", param.Name, err)
+			}
+		}
+	}
+
+	used := make(map[string]bool)
+	for _, match := range paramPattern.FindAllStringSubmatch(query.SQL, -1) {
+		if !declared[match[1]] {
+			return fmt.Errorf("SQL references undeclared param @%s", match[1])
+		}
+		used[match[1]] = true
+	}
+	for name := range declared {
+		if !used[name] {
+			return fmt.Errorf("param %q is not used in SQL", name)
+		}
+	}
+	return nil
+}
+
+// Bind parses raw parameter values into the arguments of the query's SQL,
+// applying defaults. Unknown parameters are rejected.
+func (q *Query) Bind(values map[string]string, now time.Time) (map[string]interface{}, error) {
+	params := make(map[string]*Param, len(q.Params))
+	for i := range q.Params {
+		params[q.Params[i].Name] = &q.Params[i]
+	}
+	for name := range values {
+		if params[name] == nil {
+			return nil, fmt.Errorf("unknown param %q", name)
+		}
+	}
+
+	args := make(map[string]interface{}, len(q.Params))
+	for _, param := range q.Params {
+		raw, ok := values[param.Name]
+		if !ok || raw == "" {
+			raw = param.Default
+		}
+		if raw == "" {
+			if param.Required {
+				return nil, fmt.Errorf("param %q is required", param.Name)
+			}
+			args[param.Name] = nil
+			continue
+		}
+
+		value, err := param.parse(raw, now)
+		if err != nil {
+			return nil, fmt.Errorf("param %q: %w", param.Name, err)
+		}
+		args[param.Name] = value
+	}
+	return args, nil
+}
+
+// parse converts a raw value to the parameter's type and checks its
+// options and bounds
+func (p *Param) parse(raw string, now time.Time) (interface{}, error) {
+	if len(p.Options) > 0 {
+		allowed := false
+		for _, option := range p.Options {
+			if raw == option {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, fmt.Errorf("must be one of %s", strings.Join(p.Options, ", "))
+		}
+	}
+
+	switch p.Type {
+	case ParamInt:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return n, p.checkBounds(float64(n))
+	case ParamFloat:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return f, p.checkBounds(f)
+	case ParamBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return b, nil
+	case ParamDate:
+		if t, ok := relativeTime(raw, now); ok {
+			year, month, day := t.Date()
+			return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), nil
+		}
+		t, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be a date such as 2006-01-02 or -30d")
+		}
+		return t, nil
+	case ParamDateTime:
+		if t, ok := relativeTime(raw, now); ok {
+			return t, nil
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be a time such as 2006-01-02T15:04:05Z or -24h")
+		}
+		return t.UTC(), nil
+	default:
+		return raw, nil
+	}
+}
+
+func (p *Param) checkBounds(value float64) error {
+	if p.Min != nil && value < *p.Min {
+		return fmt.Errorf("must be at least %v", *p.Min)
+	}
+	if p.Max != nil && value > *p.Max {
+		return fmt.Errorf("must be at most %v", *p.Max)
+	}
+	return nil
+}
+
+// relativeTime parses "now", "today" and offsets from now such as -30d,
+// -12h or +1d
+func relativeTime(raw string, now time.Time) (time.Time, bool) {
+	now = now.UTC()
+	switch raw {
+	case "now", "today":
+		return now, true
+	}
+	if len(raw) < 3 || (raw[0] != '-' && raw[0] != '+') {
+		return time.Time{}, false
+	}
+
+	n, err := strconv.Atoi(raw[1 : len(raw)-1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	if raw[0] == '-' {
+		n = -n
+	}
+	switch raw[len(raw)-1] {
+	case 'd':
+		return now.AddDate(0, 0, n), true
+	case 'h':
+		return now.Add(time.Duration(n) * time.Hour), true
+	case 'm':
+		return now.Add(time.Duration(n) * time.Minute), true
+	}
+	return time.Time{}, false
+}
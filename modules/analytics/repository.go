@@ -0,0 +1,82 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Run executes a query's SQL with its arguments and returns at most limit
+// rows, and whether there were more. It runs in a read-only transaction
+// that is always rolled back, so a query can never change data.
+func (r *Repository) Run(ctx context.Context, query string, args map[string]interface{}, limit int) ([]string, [][]interface{}, bool, error) {
+	// SQLite drivers refuse read-only transactions; the rollback still
+	// discards any change
+	var options *sql.TxOptions
+	if r.db.Dialector.Name() != "sqlite" {
+		options = &sql.TxOptions{ReadOnly: true}
+	}
+	tx := r.db.WithContext(ctx).Begin(options)
+	if tx.Error != nil {
+		return nil, nil, false, tx.Error
+	}
+	defer tx.Rollback()
+
+	// One row past the limit tells whether the result was cut short
+	limited := fmt.Sprintf("SELECT * FROM (%s) analytics_query LIMIT %d", query, limit+1)
+	var vars []interface{}
+	if len(args) > 0 {
+		vars = append(vars, args)
+	}
+	rows, err := tx.Raw(limited, vars...).Rows()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	var result [][]interface{}
+	truncated := false
+	for rows.Next() {
+		if len(result) == limit {
+			truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, nil, false, err
+		}
+		for i, value := range values {
+			// Text columns may scan as bytes
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result = append(result, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, false, err
+	}
+	if result == nil {
+		result = [][]interface{}{}
+	}
+	return columns, result, truncated, nil
+}
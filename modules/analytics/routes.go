@@ -0,0 +1,25 @@
+package analytics
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	// ==================== Queries ====================
+	// Each query checks its own permission, and API key scope, in the service
+	analytics := router.Group("/analytics", auth.AuthMiddleware(jwtManager, auth.AcceptAPIKeys()))
+	analytics.Get("/queries", controller.List)
+	analytics.Get("/queries/:name", controller.Get)
+	analytics.Get("/queries/:name/results", controller.Run)
+	analytics.Get("/queries/:name/export", controller.Export)
+	analytics.Delete("/queries/:name/cache", rbac.RequirePermission(rbacManager, "analytics.manage"), controller.ClearCache)
+}
@@ -0,0 +1,315 @@
+package analytics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/metrics"
+	"neonexcore/pkg/rbac"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Config holds analytics configuration
+type Config struct {
+	MaxRows       int           // Rows a query may return; larger results are cut short
+	CacheTTL      time.Duration // How long results are cached, unless the query says otherwise
+	Timeout       time.Duration // How long a query may run
+	MaxConcurrent int           // Queries running at once on this instance
+}
+
+// DefaultConfig returns default analytics configuration
+func DefaultConfig() Config {
+	return Config{
+		MaxRows:       10000,
+		CacheTTL:      5 * time.Minute,
+		Timeout:       30 * time.Second,
+		MaxConcurrent: 4,
+	}
+}
+
+// Result is the outcome of running a query
+type Result struct {
+	Query     string                 `json:"query"`
+	Params    map[string]interface{} `json:"params"`
+	Columns   []string               `json:"columns"`
+	Rows      [][]interface{}        `json:"rows"`
+	Truncated bool                   `json:"truncated"` // More rows than the limit matched
+	RunAt     time.Time              `json:"run_at"`
+	Duration  float64                `json:"duration_ms"`
+	Cached    bool                   `json:"cached"`
+}
+
+type Service struct {
+	repo    *Repository
+	cache   cache.Cache
+	rbac    *rbac.Manager
+	metrics *metrics.Collector
+	config  Config
+	running chan struct{}
+	group   singleflight.Group
+}
+
+func NewService(repo *Repository, store cache.Cache, rbacManager *rbac.Manager, collector *metrics.Collector, config Config) *Service {
+	defaults := DefaultConfig()
+	if config.MaxRows <= 0 {
+		config.MaxRows = defaults.MaxRows
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = defaults.MaxConcurrent
+	}
+
+	return &Service{
+		repo:    repo,
+		cache:   store,
+		rbac:    rbacManager,
+		metrics: collector,
+		config:  config,
+		running: make(chan struct{}, config.MaxConcurrent),
+	}
+}
+
+// List returns the queries the user may run
+func (s *Service) List(ctx context.Context, userID uint) ([]*Query, error) {
+	queries := make([]*Query, 0)
+	for _, query := range Queries() {
+		allowed, err := s.allowed(ctx, userID, query)
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			queries = append(queries, query)
+		}
+	}
+	return queries, nil
+}
+
+// Get returns a query the user may run
+func (s *Service) Get(ctx context.Context, userID uint, name string) (*Query, error) {
+	query, ok := Lookup(name)
+	if !ok {
+		return nil, errors.NewNotFound("Query not found")
+	}
+	allowed, err := s.allowed(ctx, userID, query)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, errors.NewForbidden("You do not have permission to run this query")
+	}
+	return query, nil
+}
+
+// Run runs a query with raw parameter values. Results are cached per
+// parameter values, and concurrent runs of the same query and values
+// share one execution.
+func (s *Service) Run(ctx context.Context, userID uint, name string, values map[string]string) (*Result, error) {
+	query, err := s.Get(ctx, userID, name)
+	if err != nil {
+		return nil, err
+	}
+	args, err := query.Bind(values, time.Now())
+	if err != nil {
+		return nil, errors.NewBadRequest(err.Error())
+	}
+
+	ttl := query.CacheTTL
+	if ttl == 0 {
+		ttl = s.config.CacheTTL
+	}
+	key := cacheKey(query.Name, args)
+
+	if ttl > 0 && s.cache != nil {
+		var cached Result
+		if err := cache.GetInto(ctx, s.cache, key, &cached); err == nil {
+			cached.Cached = true
+			s.count(query.Name, "cached")
+			return &cached, nil
+		}
+	}
+
+	value, err, _ := s.group.Do(key, func() (interface{}, error) {
+		result, err := s.execute(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		if ttl > 0 && s.cache != nil {
+			if err := s.cache.Set(ctx, key, *result, ttl, cache.WithTags(cacheTag(query.Name))); err != nil {
+				logger.Warn("Failed to cache analytics result", logger.Fields{"query": query.Name, "error": err.Error()})
+			}
+		}
+		return result, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers sharing a run get their own copy
+	result := *value.(*Result)
+	return &result, nil
+}
+
+// execute runs a query against the database within the limits
+func (s *Service) execute(ctx context.Context, query *Query, args map[string]interface{}) (*Result, error) {
+	select {
+	case s.running <- struct{}{}:
+		defer func() { <-s.running }()
+	default:
+		return nil, errors.New(errors.ErrCodeTooManyRequests, "Too many analytics queries are running, try again shortly", http.StatusTooManyRequests)
+	}
+
+	limit := s.config.MaxRows
+	if query.MaxRows > 0 && query.MaxRows < limit {
+		limit = query.MaxRows
+	}
+	timeout := s.config.Timeout
+	if query.Timeout > 0 && query.Timeout < timeout {
+		timeout = query.Timeout
+	}
+
+	// The run outlives the request when others share it
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	start := time.Now()
+	columns, rows, truncated, err := s.repo.Run(ctx, query.SQL, args, limit)
+	duration := time.Since(start)
+	if err != nil {
+		s.count(query.Name, "failed")
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, errors.NewBadRequest(fmt.Sprintf("Query took longer than %s; narrow its parameters", timeout))
+		}
+		logger.Warn("Analytics query failed", logger.Fields{"query": query.Name, "error": err.Error()})
+		return nil, errors.NewInternal("Failed to run query").WithError(err)
+	}
+	s.count(query.Name, "executed")
+
+	if duration > timeout/2 {
+		logger.Info("Slow analytics query", logger.Fields{"query": query.Name, "duration_ms": duration.Milliseconds(), "rows": len(rows)})
+	}
+
+	return &Result{
+		Query:     query.Name,
+		Params:    args,
+		Columns:   columns,
+		Rows:      rows,
+		Truncated: truncated,
+		RunAt:     start.UTC(),
+		Duration:  float64(duration.Microseconds()) / 1000,
+	}, nil
+}
+
+// Invalidate drops the cached results of a query
+func (s *Service) Invalidate(ctx context.Context, name string) error {
+	if _, ok := Lookup(name); !ok {
+		return errors.NewNotFound("Query not found")
+	}
+	if s.cache == nil {
+		return nil
+	}
+	if err := s.cache.InvalidateTag(ctx, cacheTag(name)); err != nil {
+		return errors.NewInternal("Failed to clear cached results").WithError(err)
+	}
+	return nil
+}
+
+// WriteCSV writes a result as CSV with a header row
+func WriteCSV(w io.Writer, result *Result) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(result.Columns); err != nil {
+		return err
+	}
+	record := make([]string, len(result.Columns))
+	for _, row := range result.Rows {
+		for i := range record {
+			record[i] = ""
+			if i < len(row) {
+				record[i] = csvValue(row[i])
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvValue formats a result value as a CSV cell
+func csvValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case time.Time:
+		return value.UTC().Format(time.RFC3339)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(value), 'f', -1, 32)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func (s *Service) allowed(ctx context.Context, userID uint, query *Query) (bool, error) {
+	if s.rbac == nil {
+		return false, nil
+	}
+	if claims, ok := auth.ClaimsFromContext(ctx); ok && !claims.HasScope(query.Permission) {
+		return false, nil
+	}
+	allowed, err := s.rbac.HasPermission(ctx, userID, query.Permission)
+	if err != nil {
+		return false, errors.NewInternal("Failed to check permissions").WithError(err)
+	}
+	return allowed, nil
+}
+
+func (s *Service) count(query, outcome string) {
+	if s.metrics == nil {
+		return
+	}
+	s.metrics.NewCounter(
+		"analytics_query_"+query+"_"+outcome+"_total",
+		"Total runs of analytics query "+query+", "+outcome,
+		map[string]string{"query": query, "outcome": outcome},
+	).Inc()
+}
+
+// cacheKey identifies a query's result for its arguments
+func cacheKey(name string, args map[string]interface{}) string {
+	names := make([]string, 0, len(args))
+	for param := range args {
+		names = append(names, param)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, param := range names {
+		fmt.Fprintf(&b, "%s=%v\n", param, args[param])
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return "analytics:" + name + ":" + hex.EncodeToString(sum[:12])
+}
+
+func cacheTag(name string) string {
+	return "analytics:" + name
+}
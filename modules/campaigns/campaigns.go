@@ -0,0 +1,28 @@
+package campaigns
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CampaignsModule struct{}
+
+func New() *CampaignsModule {
+	return &CampaignsModule{}
+}
+
+func (m *CampaignsModule) Name() string {
+	return "campaigns"
+}
+
+func (m *CampaignsModule) Init() {}
+
+func (m *CampaignsModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *CampaignsModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
@@ -0,0 +1,119 @@
+package campaigns
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+)
+
+// MergeData is the data campaign templates render with, e.g.
+// {{.FirstName}}, {{.UnsubscribeURL}} or {{.Vars.promo_code}}
+type MergeData struct {
+	Name           string
+	FirstName      string
+	Email          string
+	Campaign       string
+	UnsubscribeURL string
+	Vars           map[string]string
+}
+
+// Content is a campaign's parsed subject and body
+type Content struct {
+	subject *texttemplate.Template
+	body    *htmltemplate.Template
+}
+
+// ParseContent parses a campaign's subject and body templates. Unknown
+// fields fail when rendering, so a draft is checked by rendering it with
+// sample data.
+func ParseContent(subject, body string) (*Content, error) {
+	subjectTemplate, err := texttemplate.New("subject").Option("missingkey=zero").Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("subject: %w", err)
+	}
+	bodyTemplate, err := htmltemplate.New("body").Option("missingkey=zero").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	content := &Content{subject: subjectTemplate, body: bodyTemplate}
+
+	sample := MergeData{
+		Name:           "Jane Doe",
+		FirstName:      "Jane",
+		Email:          "jane@example.com",
+		Campaign:       "Sample",
+		UnsubscribeURL: "https://example.com/unsubscribe",
+	}
+	if _, _, err := content.Render(sample); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// Render renders the subject and HTML body for one recipient
+func (c *Content) Render(data MergeData) (string, string, error) {
+	if data.Vars == nil {
+		data.Vars = map[string]string{}
+	}
+
+	var subject, body bytes.Buffer
+	if err := c.subject.Execute(&subject, data); err != nil {
+		return "", "", fmt.Errorf("subject: %w", err)
+	}
+	if err := c.body.Execute(&body, data); err != nil {
+		return "", "", fmt.Errorf("body: %w", err)
+	}
+	// Subjects are a single header line
+	return strings.Join(strings.Fields(subject.String()), " "), body.String(), nil
+}
+
+// hrefPattern matches absolute http(s) links in HTML
+var hrefPattern = regexp.MustCompile(`(?i)href\s*=\s*"(https?://[^"]+)"`)
+
+// ExtractLinks returns the distinct links a body template contains. Links
+// built from merge variables are left out: they differ per recipient and
+// are not tracked.
+func ExtractLinks(body string) []string {
+	seen := make(map[string]bool)
+	var links []string
+	for _, match := range hrefPattern.FindAllStringSubmatch(body, -1) {
+		link := html.UnescapeString(match[1])
+		if strings.Contains(link, "{{") || seen[link] {
+			continue
+		}
+		seen[link] = true
+		links = append(links, link)
+	}
+	return links
+}
+
+// trackContent points a rendered body's known links at the click endpoint
+// and adds the open pixel. links maps each URL to its Link ID.
+func trackContent(body, trackURL string, links map[string]uint) string {
+	body = hrefPattern.ReplaceAllStringFunc(body, func(attr string) string {
+		link := html.UnescapeString(hrefPattern.FindStringSubmatch(attr)[1])
+		id, ok := links[link]
+		if !ok {
+			return attr
+		}
+		return fmt.Sprintf(`href="%s/l/%d"`, trackURL, id)
+	})
+
+	pixel := `<img src="` + trackURL + `/o.gif" width="1" height="1" alt="" style="display:none">`
+	if i := strings.LastIndex(strings.ToLower(body), "</body>"); i >= 0 {
+		return body[:i] + pixel + body[i:]
+	}
+	return body + pixel
+}
+
+// firstName returns the first word of a name
+func firstName(name string) string {
+	if fields := strings.Fields(name); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
@@ -0,0 +1,524 @@
+package campaigns
+
+import (
+	"context"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// pixel is a transparent 1x1 GIF
+var pixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// ==================== Segments ====================
+
+// ListSegments lists audience segments
+// @Summary List segments
+// @Tags Campaigns
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]Segment}
+// @Router /campaigns/segments [get]
+func (c *Controller) ListSegments(ctx *fiber.Ctx) error {
+	pagination := api.GetPagination(ctx)
+
+	segments, total, err := c.service.ListSegments(ctx.Context(), pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, segments, pagination.Page, pagination.Limit, total)
+}
+
+// GetSegment retrieves a segment
+// @Summary Get segment
+// @Tags Campaigns
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Segment ID"
+// @Success 200 {object} api.Response{data=Segment}
+// @Failure 404 {object} api.Response
+// @Router /campaigns/segments/{id} [get]
+func (c *Controller) GetSegment(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid segment ID", nil)
+	}
+
+	segment, err := c.service.GetSegment(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, segment)
+}
+
+// CreateSegment creates a segment
+// @Summary Create segment
+// @Description Define an audience by filters on user fields, e.g. {"field": "created_at", "op": "within", "value": "30d"}
+// @Tags Campaigns
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param segment body SegmentInput true "Segment"
+// @Success 201 {object} api.Response{data=Segment}
+// @Failure 400 {object} api.Response
+// @Router /campaigns/segments [post]
+func (c *Controller) CreateSegment(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input SegmentInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	segment, err := c.service.CreateSegment(ctx.Context(), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Segment created", segment)
+}
+
+// UpdateSegment updates a segment
+// @Summary Update segment
+// @Tags Campaigns
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Segment ID"
+// @Param segment body SegmentInput true "Segment"
+// @Success 200 {object} api.Response{data=Segment}
+// @Router /campaigns/segments/{id} [put]
+func (c *Controller) UpdateSegment(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid segment ID", nil)
+	}
+
+	var input SegmentInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	segment, err := c.service.UpdateSegment(ctx.Context(), uint(id), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, segment)
+}
+
+// DeleteSegment deletes a segment no unfinished campaign uses
+// @Summary Delete segment
+// @Tags Campaigns
+// @Security BearerAuth
+// @Param id path int true "Segment ID"
+// @Success 204
+// @Failure 409 {object} api.Response
+// @Router /campaigns/segments/{id} [delete]
+func (c *Controller) DeleteSegment(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid segment ID", nil)
+	}
+
+	if err := c.service.DeleteSegment(ctx.Context(), uint(id)); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.NoContent(ctx)
+}
+
+// PreviewSegment counts and samples a segment's audience
+// @Summary Preview segment
+// @Tags Campaigns
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Segment ID"
+// @Success 200 {object} api.Response{data=SegmentPreview}
+// @Router /campaigns/segments/{id}/preview [get]
+func (c *Controller) PreviewSegment(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid segment ID", nil)
+	}
+
+	segment, err := c.service.GetSegment(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	preview, err := c.service.PreviewSegment(ctx.Context(), segment)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, preview)
+}
+
+// PreviewFilters counts and samples the audience of unsaved filters
+// @Summary Preview filters
+// @Tags Campaigns
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param segment body SegmentInput true "Segment"
+// @Success 200 {object} api.Response{data=SegmentPreview}
+// @Router /campaigns/segments/preview [post]
+func (c *Controller) PreviewFilters(ctx *fiber.Ctx) error {
+	var input SegmentInput
+	if err := ctx.BodyParser(&input); err != nil {
+		return api.BadRequest(ctx, "Invalid request body", nil)
+	}
+
+	preview, err := c.service.PreviewInput(ctx.Context(), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, preview)
+}
+
+// ==================== Campaigns ====================
+
+// List lists campaigns
+// @Summary List campaigns
+// @Tags Campaigns
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "Status (draft, scheduled, sending, paused, sent, canceled)"
+// @Success 200 {object} api.Response{data=[]Campaign}
+// @Router /campaigns [get]
+func (c *Controller) List(ctx *fiber.Ctx) error {
+	pagination := api.GetPagination(ctx)
+
+	campaigns, total, err := c.service.ListCampaigns(ctx.Context(), ctx.Query("status"), pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, campaigns, pagination.Page, pagination.Limit, total)
+}
+
+// Get retrieves a campaign
+// @Summary Get campaign
+// @Tags Campaigns
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Campaign ID"
+// @Success 200 {object} api.Response{data=Campaign}
+// @Failure 404 {object} api.Response
+// @Router /campaigns/{id} [get]
+func (c *Controller) Get(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid campaign ID", nil)
+	}
+
+	campaign, err := c.service.GetCampaign(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, campaign)
+}
+
+// Create creates a draft campaign
+// @Summary Create campaign
+// @Description Subject and body are templates with merge variables: {{.Name}}, {{.FirstName}}, {{.Email}}, {{.UnsubscribeURL}} and {{.Vars.key}}
+// @Tags Campaigns
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param campaign body CampaignInput true "Campaign"
+// @Success 201 {object} api.Response{data=Campaign}
+// @Failure 400 {object} api.Response
+// @Router /campaigns [post]
+func (c *Controller) Create(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input CampaignInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	campaign, err := c.service.CreateCampaign(ctx.Context(), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Campaign created", campaign)
+}
+
+// Update updates a campaign that has not started sending
+// @Summary Update campaign
+// @Tags Campaigns
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Campaign ID"
+// @Param campaign body CampaignInput true "Campaign"
+// @Success 200 {object} api.Response{data=Campaign}
+// @Failure 409 {object} api.Response
+// @Router /campaigns/{id} [put]
+func (c *Controller) Update(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid campaign ID", nil)
+	}
+
+	var input CampaignInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	campaign, err := c.service.UpdateCampaign(ctx.Context(), uint(id), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, campaign)
+}
+
+// Delete deletes a campaign that is not being sent
+// @Summary Delete campaign
+// @Tags Campaigns
+// @Security BearerAuth
+// @Param id path int true "Campaign ID"
+// @Success 204
+// @Failure 409 {object} api.Response
+// @Router /campaigns/{id} [delete]
+func (c *Controller) Delete(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid campaign ID", nil)
+	}
+
+	if err := c.service.DeleteCampaign(ctx.Context(), uint(id)); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.NoContent(ctx)
+}
+
+// Send sends a campaign now, or schedules it
+// @Summary Send campaign
+// @Tags Campaigns
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Campaign ID"
+// @Param send body SendInput false "Schedule"
+// @Success 200 {object} api.Response{data=Campaign}
+// @Failure 409 {object} api.Response
+// @Router /campaigns/{id}/send [post]
+func (c *Controller) Send(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid campaign ID", nil)
+	}
+
+	var input SendInput
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&input); err != nil {
+			return api.BadRequest(ctx, "Invalid request body", nil)
+		}
+	}
+
+	campaign, err := c.service.Send(ctx.Context(), uint(id), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, campaign)
+}
+
+// Pause pauses sending a campaign
+// @Summary Pause campaign
+// @Tags Campaigns
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Campaign ID"
+// @Success 200 {object} api.Response{data=Campaign}
+// @Failure 409 {object} api.Response
+// @Router /campaigns/{id}/pause [post]
+func (c *Controller) Pause(ctx *fiber.Ctx) error {
+	return c.transition(ctx, c.service.Pause)
+}
+
+// Resume resumes sending a paused campaign
+// @Summary Resume campaign
+// @Tags Campaigns
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Campaign ID"
+// @Success 200 {object} api.Response{data=Campaign}
+// @Failure 409 {object} api.Response
+// @Router /campaigns/{id}/resume [post]
+func (c *Controller) Resume(ctx *fiber.Ctx) error {
+	return c.transition(ctx, c.service.Resume)
+}
+
+// Cancel cancels a campaign, skipping recipients not yet sent to
+// @Summary Cancel campaign
+// @Tags Campaigns
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Campaign ID"
+// @Success 200 {object} api.Response{data=Campaign}
+// @Failure 409 {object} api.Response
+// @Router /campaigns/{id}/cancel [post]
+func (c *Controller) Cancel(ctx *fiber.Ctx) error {
+	return c.transition(ctx, c.service.Cancel)
+}
+
+// Test sends a campaign to one address with sample merge data
+// @Summary Send test email
+// @Tags Campaigns
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Campaign ID"
+// @Param test body TestInput true "Recipient"
+// @Success 200 {object} api.Response
+// @Router /campaigns/{id}/test [post]
+func (c *Controller) Test(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid campaign ID", nil)
+	}
+
+	var input TestInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	if err := c.service.SendTest(ctx.Context(), uint(id), input.Email); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Test email sent", nil)
+}
+
+// Stats returns a campaign's delivery analytics
+// @Summary Campaign stats
+// @Description Delivery counts, open, click and click-to-open rates, send rate, link clicks, common errors and daily engagement
+// @Tags Campaigns
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Campaign ID"
+// @Success 200 {object} api.Response{data=Stats}
+// @Router /campaigns/{id}/stats [get]
+func (c *Controller) Stats(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid campaign ID", nil)
+	}
+
+	stats, err := c.service.Stats(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, stats)
+}
+
+// Recipients lists a campaign's recipients
+// @Summary List campaign recipients
+// @Tags Campaigns
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Campaign ID"
+// @Param status query string false "Status (pending, sent, failed, skipped)"
+// @Success 200 {object} api.Response{data=[]Recipient}
+// @Router /campaigns/{id}/recipients [get]
+func (c *Controller) Recipients(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid campaign ID", nil)
+	}
+	pagination := api.GetPagination(ctx)
+
+	recipients, total, err := c.service.ListRecipients(ctx.Context(), uint(id), ctx.Query("status"), pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, recipients, pagination.Page, pagination.Limit, total)
+}
+
+func (c *Controller) transition(ctx *fiber.Ctx, action func(ctx context.Context, id uint) (*Campaign, error)) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid campaign ID", nil)
+	}
+
+	campaign, err := action(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, campaign)
+}
+
+// ==================== Tracking ====================
+
+// Open records an email open and returns a transparent pixel
+// @Summary Track campaign open
+// @Tags Campaigns
+// @Produce image/gif
+// @Param token path string true "Recipient token"
+// @Success 200 {file} binary
+// @Router /campaigns/t/{token}/o.gif [get]
+func (c *Controller) Open(ctx *fiber.Ctx) error {
+	c.service.TrackOpen(ctx.Context(), ctx.Params("token"))
+
+	// Every open must reach us to be counted
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	ctx.Set(fiber.HeaderContentType, "image/gif")
+	return ctx.Send(pixel)
+}
+
+// Click records a link click and redirects to the link
+// @Summary Track campaign click
+// @Tags Campaigns
+// @Param token path string true "Recipient token"
+// @Param link path int true "Link ID"
+// @Success 302
+// @Failure 404 {object} api.Response
+// @Router /campaigns/t/{token}/l/{link} [get]
+func (c *Controller) Click(ctx *fiber.Ctx) error {
+	linkID, err := ctx.ParamsInt("link")
+	if err != nil || linkID <= 0 {
+		return api.NotFound(ctx, "Link not found")
+	}
+
+	target, err := c.service.TrackClick(ctx.Context(), ctx.Params("token"), uint(linkID))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	return ctx.Redirect(target, fiber.StatusFound)
+}
+
+// Unsubscribe opts the recipient out of campaigns. Mail clients POST to it
+// for one-click unsubscribe (RFC 8058).
+// @Summary Unsubscribe from campaigns
+// @Tags Campaigns
+// @Produce html
+// @Param token path string true "Recipient token"
+// @Success 200 {string} string
+// @Router /campaigns/u/{token} [get]
+func (c *Controller) Unsubscribe(ctx *fiber.Ctx) error {
+	if err := c.service.Unsubscribe(ctx.Context(), ctx.Params("token")); err != nil {
+		if appErr, ok := errors.GetAppError(err); ok && appErr.StatusCode == fiber.StatusNotFound {
+			ctx.Status(fiber.StatusNotFound)
+			ctx.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+			return ctx.SendString("<!DOCTYPE html><html><body><p>This unsubscribe link is not valid.</p></body></html>")
+		}
+		return api.RespondError(ctx, err)
+	}
+
+	ctx.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return ctx.SendString("<!DOCTYPE html><html><body><p>You have been unsubscribed and will no longer receive these emails.</p></body></html>")
+}
@@ -0,0 +1,453 @@
+package campaigns
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/notification"
+	"neonexcore/pkg/queue"
+)
+
+// Queue topic and consumer group of recipient batches
+const (
+	BatchTopic = "campaigns.batches"
+	BatchGroup = "campaign-senders"
+)
+
+const (
+	// sendLimitKey is the rate limiter bucket shared by every campaign, as
+	// the provider's limits are
+	sendLimitKey = "campaigns:send"
+	// statusCheckInterval is how many sends pass between checks that a
+	// campaign has not been paused or canceled
+	statusCheckInterval = 10
+	// prepareTimeout is how long a campaign may take to queue its
+	// recipients before another instance takes over, as after a crash
+	prepareTimeout = 15 * time.Minute
+)
+
+// batchMessage is a queued batch of a campaign's recipients
+type batchMessage struct {
+	CampaignID   uint   `json:"campaign_id"`
+	RecipientIDs []uint `json:"recipient_ids"`
+}
+
+// StartDue starts the scheduled campaigns whose time has come
+func (s *Service) StartDue(ctx context.Context) {
+	campaigns, err := s.repo.DueCampaigns(ctx, time.Now())
+	if err != nil {
+		logger.Warn("Failed to load due campaigns", logger.Fields{"error": err.Error()})
+		return
+	}
+	for i := range campaigns {
+		campaign := &campaigns[i]
+		moved, err := s.repo.TransitionCampaign(ctx, campaign.ID, []string{StatusScheduled}, StatusSending, map[string]interface{}{"started_at": time.Now()})
+		if err != nil || !moved {
+			// Another instance started it, or it was canceled meanwhile
+			continue
+		}
+		if err := s.prepare(ctx, campaign.ID); err != nil {
+			logger.Warn("Failed to start campaign", logger.Fields{"campaign_id": campaign.ID, "error": err.Error()})
+		}
+	}
+}
+
+// Reconcile starts due campaigns, finishes those with every recipient
+// handled, and takes over preparing campaigns whose instance stopped
+func (s *Service) Reconcile(ctx context.Context) {
+	s.StartDue(ctx)
+
+	campaigns, err := s.repo.ActiveCampaigns(ctx)
+	if err != nil {
+		logger.Warn("Failed to load sending campaigns", logger.Fields{"error": err.Error()})
+		return
+	}
+	for _, campaign := range campaigns {
+		if campaign.QueuedAt != nil {
+			s.finishIfDone(ctx, campaign.ID)
+			continue
+		}
+		if campaign.StartedAt != nil && time.Since(*campaign.StartedAt) > prepareTimeout {
+			if err := s.prepare(ctx, campaign.ID); err != nil {
+				logger.Warn("Failed to prepare campaign", logger.Fields{"campaign_id": campaign.ID, "error": err.Error()})
+			}
+		}
+	}
+}
+
+// Requeue queues the pending recipients of every sending campaign again,
+// for queues that lose their messages on restart
+func (s *Service) Requeue(ctx context.Context) {
+	campaigns, err := s.repo.ActiveCampaigns(ctx)
+	if err != nil {
+		logger.Warn("Failed to load sending campaigns", logger.Fields{"error": err.Error()})
+		return
+	}
+	for _, campaign := range campaigns {
+		if campaign.QueuedAt == nil {
+			err = s.prepare(ctx, campaign.ID)
+		} else {
+			err = s.enqueuePending(ctx, campaign.ID)
+		}
+		if err != nil {
+			logger.Warn("Failed to requeue campaign", logger.Fields{"campaign_id": campaign.ID, "error": err.Error()})
+		}
+	}
+}
+
+// prepare creates a started campaign's recipients from its segment, as it
+// stands when the campaign started, and queues them in batches. Preparing
+// again skips the users already added.
+func (s *Service) prepare(ctx context.Context, id uint) error {
+	campaign, err := s.repo.FindCampaign(ctx, id)
+	if err != nil {
+		return err
+	}
+	if campaign == nil {
+		return fmt.Errorf("campaign %d not found", id)
+	}
+	segment, err := s.repo.FindSegment(ctx, campaign.SegmentID)
+	if err != nil {
+		return err
+	}
+	if segment == nil {
+		return fmt.Errorf("segment %d not found", campaign.SegmentID)
+	}
+
+	if campaign.Track {
+		if _, err := s.repo.EnsureLinks(ctx, campaign.ID, ExtractLinks(campaign.Body)); err != nil {
+			return err
+		}
+	}
+
+	at := time.Now()
+	if campaign.StartedAt != nil {
+		at = *campaign.StartedAt
+	}
+	var afterID uint
+	for {
+		users, err := s.repo.FindAudience(ctx, segment, at, afterID, s.config.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			break
+		}
+
+		recipients := make([]Recipient, len(users))
+		for i, user := range users {
+			token, err := newToken()
+			if err != nil {
+				return err
+			}
+			recipients[i] = Recipient{
+				CampaignID: campaign.ID,
+				UserID:     user.ID,
+				Email:      user.Email,
+				Name:       user.Name,
+				Token:      token,
+				Status:     RecipientPending,
+			}
+		}
+		if err := s.repo.CreateRecipients(ctx, recipients); err != nil {
+			return err
+		}
+		afterID = users[len(users)-1].ID
+	}
+
+	total, err := s.repo.CountRecipients(ctx, campaign.ID)
+	if err != nil {
+		return err
+	}
+
+	// The campaign may have been paused or canceled while preparing
+	if campaign, err = s.repo.FindCampaign(ctx, id); err != nil {
+		return err
+	}
+	if campaign == nil {
+		return fmt.Errorf("campaign %d was deleted while starting", id)
+	}
+	switch campaign.Status {
+	case StatusSending:
+		if err := s.enqueuePending(ctx, campaign.ID); err != nil {
+			return err
+		}
+	case StatusCanceled:
+		if _, err := s.repo.SkipPending(ctx, campaign.ID, "campaign canceled"); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.MarkQueued(ctx, campaign.ID, total, time.Now()); err != nil {
+		return err
+	}
+
+	logger.Info("Campaign started", logger.Fields{"campaign_id": campaign.ID, "recipients": total})
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventCampaignStarted,
+		Data: map[string]interface{}{
+			"campaign_id": campaign.ID,
+			"segment_id":  campaign.SegmentID,
+			"recipients":  total,
+		},
+	})
+
+	s.finishIfDone(ctx, campaign.ID)
+	return nil
+}
+
+// enqueuePending queues a campaign's pending recipients in batches
+func (s *Service) enqueuePending(ctx context.Context, campaignID uint) error {
+	if s.queue == nil {
+		return fmt.Errorf("no queue configured")
+	}
+
+	var afterID uint
+	for {
+		ids, err := s.repo.PendingRecipientIDs(ctx, campaignID, afterID, s.config.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		payload, err := json.Marshal(batchMessage{CampaignID: campaignID, RecipientIDs: ids})
+		if err != nil {
+			return err
+		}
+		if err := s.queue.Publish(ctx, BatchTopic, payload); err != nil {
+			return err
+		}
+		afterID = ids[len(ids)-1]
+	}
+}
+
+// HandleBatch sends a queued batch of recipients, throttled to the
+// configured rate. It stops early when the campaign is paused or canceled,
+// and returns an error to have the queue redeliver the batch when sends
+// failed that may be retried.
+func (s *Service) HandleBatch(ctx context.Context, msg *queue.Message) error {
+	var batch batchMessage
+	if err := json.Unmarshal(msg.Payload, &batch); err != nil {
+		logger.Warn("Dropping invalid campaign batch", logger.Fields{"message_id": msg.ID, "error": err.Error()})
+		return nil
+	}
+
+	campaign, err := s.repo.FindCampaign(ctx, batch.CampaignID)
+	if err != nil {
+		return err
+	}
+	if campaign == nil || campaign.Status != StatusSending {
+		// Resuming a paused campaign queues its pending recipients again
+		return nil
+	}
+
+	content, err := ParseContent(campaign.Subject, campaign.Body)
+	if err != nil {
+		logger.Warn("Campaign content does not render", logger.Fields{"campaign_id": campaign.ID, "error": err.Error()})
+		return nil
+	}
+	var links map[string]uint
+	if campaign.Track {
+		if links, err = s.repo.CampaignLinks(ctx, campaign.ID); err != nil {
+			return err
+		}
+	}
+
+	recipients, err := s.repo.FindRecipients(ctx, batch.RecipientIDs)
+	if err != nil {
+		return err
+	}
+
+	retries := 0
+	for i := range recipients {
+		recipient := &recipients[i]
+		if recipient.Status != RecipientPending {
+			continue
+		}
+		if i > 0 && i%statusCheckInterval == 0 {
+			current, err := s.repo.FindCampaign(ctx, campaign.ID)
+			if err != nil {
+				return err
+			}
+			if current == nil || current.Status != StatusSending {
+				return nil
+			}
+		}
+
+		if s.limiter != nil {
+			if err := s.limiter.Wait(ctx, sendLimitKey, s.config.Rate, s.config.Burst); err != nil {
+				return err
+			}
+		}
+		if s.deliver(ctx, campaign, content, links, recipient) {
+			retries++
+		}
+	}
+
+	s.finishIfDone(ctx, campaign.ID)
+	if retries > 0 {
+		return fmt.Errorf("%d recipients of campaign %d will be retried", retries, campaign.ID)
+	}
+	return nil
+}
+
+// deliver sends the campaign to one recipient, and reports whether the
+// send failed and should be retried
+func (s *Service) deliver(ctx context.Context, campaign *Campaign, content *Content, links map[string]uint, recipient *Recipient) bool {
+	// Claiming the attempt keeps instances handling a duplicated batch
+	// from both sending
+	claimed, err := s.repo.ClaimRecipient(ctx, recipient.ID, recipient.Attempts)
+	if err != nil {
+		return true
+	}
+	if !claimed {
+		return false
+	}
+	recipient.Attempts++
+
+	unsubscribed, err := s.repo.IsUnsubscribed(ctx, recipient.Email)
+	if err != nil {
+		return true
+	}
+	if unsubscribed {
+		s.repo.UpdatePendingRecipient(ctx, recipient.ID, map[string]interface{}{"status": RecipientSkipped, "error": "unsubscribed"})
+		return false
+	}
+
+	unsubscribeURL := s.unsubscribeURL(recipient.Token)
+	subject, body, err := content.Render(MergeData{
+		Name:           recipient.Name,
+		FirstName:      firstName(recipient.Name),
+		Email:          recipient.Email,
+		Campaign:       campaign.Name,
+		UnsubscribeURL: unsubscribeURL,
+		Vars:           campaign.Vars,
+	})
+	if err != nil {
+		s.fail(ctx, campaign, recipient, err, true)
+		return false
+	}
+	if campaign.Track {
+		body = trackContent(body, s.trackURL(recipient.Token), links)
+	}
+
+	if s.notifier == nil {
+		s.fail(ctx, campaign, recipient, fmt.Errorf("email notifications are not configured"), true)
+		return false
+	}
+	err = s.notifier.Send(ctx, &notification.Notification{
+		Channel: notification.ChannelEmail,
+		To:      recipient.Email,
+		Subject: subject,
+		Body:    body,
+		Data: map[string]interface{}{
+			"html":             true,
+			"campaign_id":      campaign.ID,
+			"recipient_id":     recipient.ID,
+			"list_unsubscribe": unsubscribeURL,
+		},
+	})
+	if err != nil {
+		final := recipient.Attempts >= s.config.MaxAttempts
+		s.fail(ctx, campaign, recipient, err, final)
+		return !final
+	}
+
+	now := time.Now()
+	sent, err := s.repo.UpdatePendingRecipient(ctx, recipient.ID, map[string]interface{}{"status": RecipientSent, "sent_at": now, "error": ""})
+	if err != nil {
+		logger.Warn("Failed to record campaign send", logger.Fields{"recipient_id": recipient.ID, "error": err.Error()})
+	}
+	if sent {
+		s.repo.AddCampaignCount(ctx, campaign.ID, "sent", 1)
+	}
+	if s.metrics != nil {
+		s.metrics.NewCounter("campaigns_emails_sent_total", "Total campaign emails sent", nil).Inc()
+	}
+	return false
+}
+
+// fail records a failed send, marking the recipient failed when final
+func (s *Service) fail(ctx context.Context, campaign *Campaign, recipient *Recipient, cause error, final bool) {
+	message := cause.Error()
+	if len(message) > 500 {
+		message = message[:500]
+	}
+
+	updates := map[string]interface{}{"error": message}
+	if final {
+		updates["status"] = RecipientFailed
+	}
+	failed, err := s.repo.UpdatePendingRecipient(ctx, recipient.ID, updates)
+	if err != nil {
+		logger.Warn("Failed to record campaign send failure", logger.Fields{"recipient_id": recipient.ID, "error": err.Error()})
+	}
+	if !final {
+		return
+	}
+
+	if failed {
+		s.repo.AddCampaignCount(ctx, campaign.ID, "failed", 1)
+	}
+	if s.metrics != nil {
+		s.metrics.NewCounter("campaigns_emails_failed_total", "Total campaign emails that failed", nil).Inc()
+	}
+	logger.Warn("Campaign email failed", logger.Fields{
+		"campaign_id":  campaign.ID,
+		"recipient_id": recipient.ID,
+		"attempts":     recipient.Attempts,
+		"error":        message,
+	})
+}
+
+// finishIfDone marks a queued campaign sent once no recipient is pending
+func (s *Service) finishIfDone(ctx context.Context, id uint) {
+	campaign, err := s.repo.FindCampaign(ctx, id)
+	if err != nil || campaign == nil || campaign.Status != StatusSending || campaign.QueuedAt == nil {
+		return
+	}
+	pending, err := s.repo.CountPending(ctx, id)
+	if err != nil || pending > 0 {
+		return
+	}
+
+	moved, err := s.repo.TransitionCampaign(ctx, id, []string{StatusSending}, StatusSent, map[string]interface{}{"completed_at": time.Now()})
+	if err != nil || !moved {
+		return
+	}
+
+	logger.Info("Campaign sent", logger.Fields{"campaign_id": id, "sent": campaign.Sent, "failed": campaign.Failed})
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventCampaignCompleted,
+		Data: map[string]interface{}{
+			"campaign_id": id,
+			"recipients":  campaign.Recipients,
+			"sent":        campaign.Sent,
+			"failed":      campaign.Failed,
+		},
+	})
+}
+
+// wake has the sender start campaigns sent now without waiting for its
+// next check
+func (s *Service) wake() {
+	select {
+	case s.queued <- struct{}{}:
+	default:
+	}
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,93 @@
+package campaigns
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/metrics"
+	"neonexcore/pkg/notification"
+	"neonexcore/pkg/queue"
+	"neonexcore/pkg/workflow"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Service
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		if baseURL := os.Getenv("CAMPAIGNS_BASE_URL"); baseURL != "" {
+			config.BaseURL = baseURL
+		}
+		if rate, err := strconv.ParseFloat(os.Getenv("CAMPAIGNS_RATE"), 64); err == nil && rate > 0 {
+			config.Rate = rate
+		}
+		if burst, err := strconv.Atoi(os.Getenv("CAMPAIGNS_BURST")); err == nil && burst > 0 {
+			config.Burst = burst
+		}
+		if size, err := strconv.Atoi(os.Getenv("CAMPAIGNS_BATCH_SIZE")); err == nil && size > 0 {
+			config.BatchSize = size
+		}
+		if attempts, err := strconv.Atoi(os.Getenv("CAMPAIGNS_MAX_ATTEMPTS")); err == nil && attempts > 0 {
+			config.MaxAttempts = attempts
+		}
+
+		// Failed sends are retried by redelivering their batch, so every
+		// attempt must happen before the queue gives up on it
+		queueConfig := queue.LoadConfig()
+		if config.MaxAttempts > queueConfig.MaxAttempts {
+			config.MaxAttempts = queueConfig.MaxAttempts
+		}
+
+		// The send rate is shared by every instance when batches are spread
+		// over them by Redis; the memory queue keeps them in one process and
+		// loses them on restart
+		var limiter Limiter = workflow.NewMemoryLimiter()
+		config.Requeue = true
+		if queueConfig.Driver == "redis" {
+			config.Requeue = false
+			if options, err := redis.ParseURL(queueConfig.RedisURL); err == nil {
+				limiter = workflow.NewRedisLimiter(redis.NewClient(options), "campaigns:")
+			} else {
+				logger.Warn("Invalid queue Redis URL, throttling campaigns per instance", logger.Fields{"error": err.Error()})
+			}
+		}
+
+		var notifier Notifier
+		if manager := core.Resolve[*notification.Manager](container); manager != nil {
+			notifier = manager
+		}
+
+		return NewService(
+			core.Resolve[*Repository](container),
+			notifier,
+			core.Resolve[queue.Queue](container),
+			limiter,
+			core.Resolve[*metrics.Collector](container),
+			config,
+		)
+	}, core.Singleton)
+
+	// Register Sender
+	container.Provide(func() *Sender {
+		interval, err := time.ParseDuration(os.Getenv("CAMPAIGNS_SCHEDULE_INTERVAL"))
+		if err != nil {
+			interval = 30 * time.Second
+		}
+		return NewSender(core.Resolve[*Service](container), interval)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
@@ -0,0 +1,185 @@
+package campaigns
+
+import "time"
+
+// Campaign statuses
+const (
+	StatusDraft     = "draft"     // Being written
+	StatusScheduled = "scheduled" // Starts sending at ScheduledAt
+	StatusSending   = "sending"   // Recipients are being sent to
+	StatusPaused    = "paused"    // Sending stopped; resuming sends to the rest
+	StatusSent      = "sent"      // Every recipient was sent to or failed
+	StatusCanceled  = "canceled"  // Stopped for good; the rest are skipped
+)
+
+// Recipient statuses
+const (
+	RecipientPending = "pending"
+	RecipientSent    = "sent"
+	RecipientFailed  = "failed"
+	RecipientSkipped = "skipped" // Unsubscribed meanwhile, or the campaign was canceled
+)
+
+// Segment is an audience of users matching query filters, e.g. active users
+// who signed up in the last 30 days
+type Segment struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"size:200;not null" json:"name"`
+	Description string    `gorm:"size:1000" json:"description,omitempty"`
+	Match       string    `gorm:"size:10;not null;default:all" json:"match"` // all or any of the filters
+	Filters     []Filter  `gorm:"serializer:json" json:"filters"`
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for Segment
+func (Segment) TableName() string {
+	return "campaign_segments"
+}
+
+// Campaign is an email sent to a segment. Subject and Body are templates
+// with merge variables, e.g. {{.FirstName}} and {{.Vars.promo_code}}.
+type Campaign struct {
+	ID          uint              `gorm:"primarykey" json:"id"`
+	Name        string            `gorm:"size:200;not null" json:"name"`
+	SegmentID   uint              `gorm:"not null;index" json:"segment_id"`
+	Subject     string            `gorm:"size:500;not null" json:"subject"`
+	Body        string            `gorm:"type:text;not null" json:"body"` // HTML
+	Vars        map[string]string `gorm:"serializer:json" json:"vars,omitempty"`
+	Track       bool              `gorm:"not null" json:"track"` // Track opens and clicks
+	Status      string            `gorm:"size:20;not null;index" json:"status"`
+	ScheduledAt *time.Time        `gorm:"index" json:"scheduled_at,omitempty"`
+	StartedAt   *time.Time        `json:"started_at,omitempty"`
+	QueuedAt    *time.Time        `json:"queued_at,omitempty"` // When every recipient was queued
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+
+	// Delivery counters, kept current while sending
+	Recipients int64 `gorm:"not null;default:0" json:"recipients"`
+	Sent       int64 `gorm:"not null;default:0" json:"sent"`
+	Failed     int64 `gorm:"not null;default:0" json:"failed"`
+	Opened     int64 `gorm:"not null;default:0" json:"opened"`  // Unique opens
+	Clicked    int64 `gorm:"not null;default:0" json:"clicked"` // Unique clicks
+
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for Campaign
+func (Campaign) TableName() string {
+	return "campaigns"
+}
+
+// Editable reports whether the campaign's content and audience may change
+func (c *Campaign) Editable() bool {
+	return c.Status == StatusDraft || c.Status == StatusScheduled
+}
+
+// Recipient is a user a campaign is sent to, with the token of their
+// tracking and unsubscribe links
+type Recipient struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	CampaignID uint       `gorm:"not null;uniqueIndex:idx_campaign_recipients_user;index:idx_campaign_recipients_status" json:"campaign_id"`
+	UserID     uint       `gorm:"not null;uniqueIndex:idx_campaign_recipients_user" json:"user_id"`
+	Email      string     `gorm:"size:255;not null" json:"email"`
+	Name       string     `gorm:"size:255" json:"name,omitempty"`
+	Token      string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Status     string     `gorm:"size:20;not null;index:idx_campaign_recipients_status" json:"status"`
+	Attempts   int        `gorm:"not null;default:0" json:"attempts"`
+	Error      string     `gorm:"size:500" json:"error,omitempty"`
+	SentAt     *time.Time `json:"sent_at,omitempty"`
+	OpenedAt   *time.Time `json:"opened_at,omitempty"`  // First open
+	ClickedAt  *time.Time `json:"clicked_at,omitempty"` // First click
+	Opens      int        `gorm:"not null;default:0" json:"opens"`
+	Clicks     int        `gorm:"not null;default:0" json:"clicks"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for Recipient
+func (Recipient) TableName() string {
+	return "campaign_recipients"
+}
+
+// Link is a URL in a campaign's content, which tracked emails link to
+// through the click endpoint
+type Link struct {
+	ID         uint   `gorm:"primarykey" json:"id"`
+	CampaignID uint   `gorm:"not null;index" json:"campaign_id"`
+	URL        string `gorm:"size:2048;not null" json:"url"`
+}
+
+// TableName specifies the table name for Link
+func (Link) TableName() string {
+	return "campaign_links"
+}
+
+// Click records a recipient following a link
+type Click struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CampaignID  uint      `gorm:"not null;index" json:"campaign_id"`
+	RecipientID uint      `gorm:"not null;index" json:"recipient_id"`
+	LinkID      uint      `gorm:"not null;index" json:"link_id"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name for Click
+func (Click) TableName() string {
+	return "campaign_clicks"
+}
+
+// Unsubscribe opts an email address out of every campaign
+type Unsubscribe struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	Email      string    `gorm:"size:255;not null;uniqueIndex" json:"email"`
+	CampaignID uint      `gorm:"index" json:"campaign_id,omitempty"` // The campaign unsubscribed from, if any
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for Unsubscribe
+func (Unsubscribe) TableName() string {
+	return "campaign_unsubscribes"
+}
+
+// Audience is a user a segment matches
+type Audience struct {
+	ID       uint   `json:"id"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
+// Count is a labelled count in a breakdown
+type Count struct {
+	Key   string `gorm:"column:bucket" json:"key"`
+	Count int64  `gorm:"column:hits" json:"count"`
+}
+
+// LinkStats counts the clicks on one link
+type LinkStats struct {
+	URL    string `json:"url"`
+	Clicks int64  `json:"clicks"`
+	Unique int64  `gorm:"column:recipients" json:"unique"` // Recipients who clicked
+}
+
+// Stats is a campaign's delivery and engagement
+type Stats struct {
+	CampaignID   uint        `json:"campaign_id"`
+	Status       string      `json:"status"`
+	Recipients   int64       `json:"recipients"`
+	Pending      int64       `json:"pending"`
+	Sent         int64       `json:"sent"`
+	Failed       int64       `json:"failed"`
+	Skipped      int64       `json:"skipped"`
+	Opened       int64       `json:"opened"`
+	Clicked      int64       `json:"clicked"`
+	Unsubscribed int64       `json:"unsubscribed"`
+	OpenRate     float64     `json:"open_rate"`          // Opened / sent
+	ClickRate    float64     `json:"click_rate"`         // Clicked / sent
+	ClickToOpen  float64     `json:"click_to_open_rate"` // Clicked / opened
+	SendRate     float64     `json:"send_rate"`          // Emails a second since sending started
+	Links        []LinkStats `json:"links"`
+	Errors       []Count     `json:"errors"`       // Most common send errors
+	DailyOpens   []Count     `json:"daily_opens"`  // First opens by day
+	DailyClicks  []Count     `json:"daily_clicks"` // Clicks by day
+}
@@ -0,0 +1,500 @@
+package campaigns
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ==================== Segments ====================
+
+func (r *Repository) ListSegments(ctx context.Context, page, limit int) ([]Segment, int64, error) {
+	var segments []Segment
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Segment{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("name ASC").Offset(offset).Limit(limit).Find(&segments).Error
+	return segments, total, err
+}
+
+func (r *Repository) FindSegment(ctx context.Context, id uint) (*Segment, error) {
+	var segment Segment
+	err := r.db.WithContext(ctx).First(&segment, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &segment, nil
+}
+
+func (r *Repository) CreateSegment(ctx context.Context, segment *Segment) error {
+	return r.db.WithContext(ctx).Create(segment).Error
+}
+
+func (r *Repository) UpdateSegment(ctx context.Context, segment *Segment) error {
+	return r.db.WithContext(ctx).Save(segment).Error
+}
+
+func (r *Repository) DeleteSegment(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&Segment{}, id).Error
+}
+
+// SegmentInUse reports whether campaigns that have not finished target a
+// segment
+func (r *Repository) SegmentInUse(ctx context.Context, id uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Campaign{}).
+		Where("segment_id = ? AND status NOT IN ?", id, []string{StatusSent, StatusCanceled}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ==================== Audience ====================
+
+// audience queries the users a segment matches, leaving out deleted users
+// and unsubscribed addresses
+func (r *Repository) audience(ctx context.Context, segment *Segment, now time.Time) (*gorm.DB, error) {
+	query := r.db.WithContext(ctx).Table("users").
+		Where("users.deleted_at IS NULL AND users.email <> ''").
+		Where("NOT EXISTS (SELECT 1 FROM campaign_unsubscribes WHERE campaign_unsubscribes.email = LOWER(users.email))")
+	return applySegment(query, segment, now)
+}
+
+// CountAudience counts the users a segment matches
+func (r *Repository) CountAudience(ctx context.Context, segment *Segment, now time.Time) (int64, error) {
+	query, err := r.audience(ctx, segment, now)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = query.Count(&count).Error
+	return count, err
+}
+
+// FindAudience returns up to limit users a segment matches with IDs above
+// afterID, in ID order, to page through large audiences
+func (r *Repository) FindAudience(ctx context.Context, segment *Segment, now time.Time, afterID uint, limit int) ([]Audience, error) {
+	query, err := r.audience(ctx, segment, now)
+	if err != nil {
+		return nil, err
+	}
+	var users []Audience
+	err = query.Select("users.id, users.email, users.name, users.username").
+		Where("users.id > ?", afterID).
+		Order("users.id ASC").
+		Limit(limit).
+		Scan(&users).Error
+	return users, err
+}
+
+// ==================== Campaigns ====================
+
+func (r *Repository) ListCampaigns(ctx context.Context, status string, page, limit int) ([]Campaign, int64, error) {
+	var campaigns []Campaign
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Campaign{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&campaigns).Error
+	return campaigns, total, err
+}
+
+func (r *Repository) FindCampaign(ctx context.Context, id uint) (*Campaign, error) {
+	var campaign Campaign
+	err := r.db.WithContext(ctx).First(&campaign, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+func (r *Repository) CreateCampaign(ctx context.Context, campaign *Campaign) error {
+	return r.db.WithContext(ctx).Create(campaign).Error
+}
+
+func (r *Repository) UpdateCampaign(ctx context.Context, campaign *Campaign) error {
+	return r.db.WithContext(ctx).Save(campaign).Error
+}
+
+func (r *Repository) DeleteCampaign(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&Click{}, &Link{}, &Recipient{}} {
+			if err := tx.Where("campaign_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&Campaign{}, id).Error
+	})
+}
+
+// DueCampaigns returns scheduled campaigns whose time has come
+func (r *Repository) DueCampaigns(ctx context.Context, now time.Time) ([]Campaign, error) {
+	var campaigns []Campaign
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND scheduled_at <= ?", StatusScheduled, now).
+		Order("scheduled_at ASC").
+		Find(&campaigns).Error
+	return campaigns, err
+}
+
+// ActiveCampaigns returns the campaigns being sent
+func (r *Repository) ActiveCampaigns(ctx context.Context) ([]Campaign, error) {
+	var campaigns []Campaign
+	err := r.db.WithContext(ctx).Where("status = ?", StatusSending).Find(&campaigns).Error
+	return campaigns, err
+}
+
+// TransitionCampaign moves a campaign to a status if it is in one of from,
+// so instances racing to start or finish it do so once. It reports whether
+// the campaign moved.
+func (r *Repository) TransitionCampaign(ctx context.Context, id uint, from []string, to string, updates map[string]interface{}) (bool, error) {
+	values := map[string]interface{}{"status": to, "updated_at": time.Now()}
+	for key, value := range updates {
+		values[key] = value
+	}
+	result := r.db.WithContext(ctx).Model(&Campaign{}).
+		Where("id = ? AND status IN ?", id, from).
+		Updates(values)
+	return result.RowsAffected == 1, result.Error
+}
+
+// MarkQueued records that every recipient of a campaign was queued
+func (r *Repository) MarkQueued(ctx context.Context, id uint, recipients int64, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&Campaign{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"recipients": recipients, "queued_at": at}).Error
+}
+
+// AddCampaignCount adds n to one of a campaign's delivery counters
+func (r *Repository) AddCampaignCount(ctx context.Context, id uint, column string, n int64) error {
+	return r.db.WithContext(ctx).Model(&Campaign{}).Where("id = ?", id).
+		UpdateColumn(column, gorm.Expr(column+" + ?", n)).Error
+}
+
+// ==================== Recipients ====================
+
+// CreateRecipients stores a batch of recipients, skipping users a campaign
+// already has, as when a start is retried
+func (r *Repository) CreateRecipients(ctx context.Context, recipients []Recipient) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&recipients).Error
+}
+
+func (r *Repository) FindRecipients(ctx context.Context, ids []uint) ([]Recipient, error) {
+	var recipients []Recipient
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Order("id ASC").Find(&recipients).Error
+	return recipients, err
+}
+
+func (r *Repository) FindRecipientByToken(ctx context.Context, token string) (*Recipient, error) {
+	var recipient Recipient
+	err := r.db.WithContext(ctx).Where("token = ?", token).First(&recipient).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &recipient, nil
+}
+
+// PendingRecipientIDs returns up to limit IDs of a campaign's pending
+// recipients above afterID, in ID order
+func (r *Repository) PendingRecipientIDs(ctx context.Context, campaignID, afterID uint, limit int) ([]uint, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Model(&Recipient{}).
+		Where("campaign_id = ? AND status = ? AND id > ?", campaignID, RecipientPending, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (r *Repository) CountRecipients(ctx context.Context, campaignID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Recipient{}).Where("campaign_id = ?", campaignID).Count(&count).Error
+	return count, err
+}
+
+func (r *Repository) CountPending(ctx context.Context, campaignID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Recipient{}).
+		Where("campaign_id = ? AND status = ?", campaignID, RecipientPending).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *Repository) ListRecipients(ctx context.Context, campaignID uint, status string, page, limit int) ([]Recipient, int64, error) {
+	var recipients []Recipient
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Recipient{}).Where("campaign_id = ?", campaignID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("id ASC").Offset(offset).Limit(limit).Find(&recipients).Error
+	return recipients, total, err
+}
+
+// ClaimRecipient takes the next send attempt of a pending recipient, and
+// reports whether no one else took it first
+func (r *Repository) ClaimRecipient(ctx context.Context, id uint, attempts int) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&Recipient{}).
+		Where("id = ? AND status = ? AND attempts = ?", id, RecipientPending, attempts).
+		UpdateColumn("attempts", gorm.Expr("attempts + ?", 1))
+	return result.RowsAffected == 1, result.Error
+}
+
+// UpdatePendingRecipient updates a recipient that is still pending, and
+// reports whether it was
+func (r *Repository) UpdatePendingRecipient(ctx context.Context, id uint, updates map[string]interface{}) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&Recipient{}).
+		Where("id = ? AND status = ?", id, RecipientPending).
+		Updates(updates)
+	return result.RowsAffected == 1, result.Error
+}
+
+// SkipPending marks a campaign's pending recipients skipped
+func (r *Repository) SkipPending(ctx context.Context, campaignID uint, reason string) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&Recipient{}).
+		Where("campaign_id = ? AND status = ?", campaignID, RecipientPending).
+		Updates(map[string]interface{}{"status": RecipientSkipped, "error": reason})
+	return result.RowsAffected, result.Error
+}
+
+// ==================== Tracking ====================
+
+// RecordOpen counts an open, and the campaign's unique opens on the
+// recipient's first. It reports whether this was the first open.
+func (r *Repository) RecordOpen(ctx context.Context, recipient *Recipient, at time.Time) (bool, error) {
+	first := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Recipient{}).Where("id = ? AND opened_at IS NULL", recipient.ID).Update("opened_at", at)
+		if result.Error != nil {
+			return result.Error
+		}
+		first = result.RowsAffected == 1
+
+		err := tx.Model(&Recipient{}).Where("id = ?", recipient.ID).
+			UpdateColumn("opens", gorm.Expr("opens + ?", 1)).Error
+		if err != nil || !first {
+			return err
+		}
+		return tx.Model(&Campaign{}).Where("id = ?", recipient.CampaignID).
+			UpdateColumn("opened", gorm.Expr("opened + ?", 1)).Error
+	})
+	return first, err
+}
+
+// RecordClick stores a click, and counts the campaign's unique clicks on
+// the recipient's first. It reports whether this was the first click.
+func (r *Repository) RecordClick(ctx context.Context, recipient *Recipient, linkID uint, at time.Time) (bool, error) {
+	first := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		click := &Click{CampaignID: recipient.CampaignID, RecipientID: recipient.ID, LinkID: linkID, CreatedAt: at}
+		if err := tx.Create(click).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&Recipient{}).Where("id = ? AND clicked_at IS NULL", recipient.ID).Update("clicked_at", at)
+		if result.Error != nil {
+			return result.Error
+		}
+		first = result.RowsAffected == 1
+
+		err := tx.Model(&Recipient{}).Where("id = ?", recipient.ID).
+			UpdateColumn("clicks", gorm.Expr("clicks + ?", 1)).Error
+		if err != nil || !first {
+			return err
+		}
+		return tx.Model(&Campaign{}).Where("id = ?", recipient.CampaignID).
+			UpdateColumn("clicked", gorm.Expr("clicked + ?", 1)).Error
+	})
+	return first, err
+}
+
+// ==================== Links ====================
+
+// EnsureLinks stores the links of a campaign it does not have yet, and
+// returns the ID of each of its links by URL. Only the instance starting a
+// campaign calls it.
+func (r *Repository) EnsureLinks(ctx context.Context, campaignID uint, urls []string) (map[string]uint, error) {
+	ids, err := r.CampaignLinks(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	for _, url := range urls {
+		if _, ok := ids[url]; ok {
+			continue
+		}
+		link := &Link{CampaignID: campaignID, URL: url}
+		if err := r.db.WithContext(ctx).Create(link).Error; err != nil {
+			return nil, err
+		}
+		ids[url] = link.ID
+	}
+	return ids, nil
+}
+
+// CampaignLinks returns the ID of each of a campaign's links by URL
+func (r *Repository) CampaignLinks(ctx context.Context, campaignID uint) (map[string]uint, error) {
+	var links []Link
+	if err := r.db.WithContext(ctx).Where("campaign_id = ?", campaignID).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	ids := make(map[string]uint, len(links))
+	for _, link := range links {
+		ids[link.URL] = link.ID
+	}
+	return ids, nil
+}
+
+func (r *Repository) FindLink(ctx context.Context, campaignID, id uint) (*Link, error) {
+	var link Link
+	err := r.db.WithContext(ctx).Where("id = ? AND campaign_id = ?", id, campaignID).First(&link).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+// ==================== Unsubscribes ====================
+
+// Unsubscribe opts an address out of campaigns; unsubscribing twice keeps
+// the first record
+func (r *Repository) Unsubscribe(ctx context.Context, email string, campaignID uint) error {
+	unsubscribe := &Unsubscribe{Email: strings.ToLower(email), CampaignID: campaignID}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(unsubscribe).Error
+}
+
+func (r *Repository) IsUnsubscribed(ctx context.Context, email string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Unsubscribe{}).
+		Where("email = ?", strings.ToLower(email)).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ==================== Stats ====================
+
+// Stats aggregates a campaign's recipients, clicks and unsubscribes; the
+// caller fills in the rates
+func (r *Repository) Stats(ctx context.Context, campaignID uint, top int) (*Stats, error) {
+	db := r.db.WithContext(ctx)
+	recipients := func() *gorm.DB {
+		return db.Model(&Recipient{}).Where("campaign_id = ?", campaignID)
+	}
+	stats := &Stats{CampaignID: campaignID}
+
+	var statuses []Count
+	err := recipients().Select("status AS bucket, COUNT(*) AS hits").Group("status").Scan(&statuses).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		stats.Recipients += status.Count
+		switch status.Key {
+		case RecipientPending:
+			stats.Pending = status.Count
+		case RecipientSent:
+			stats.Sent = status.Count
+		case RecipientFailed:
+			stats.Failed = status.Count
+		case RecipientSkipped:
+			stats.Skipped = status.Count
+		}
+	}
+
+	if err := recipients().Where("opened_at IS NOT NULL").Count(&stats.Opened).Error; err != nil {
+		return nil, err
+	}
+	if err := recipients().Where("clicked_at IS NOT NULL").Count(&stats.Clicked).Error; err != nil {
+		return nil, err
+	}
+	err = db.Model(&Unsubscribe{}).Where("campaign_id = ?", campaignID).Count(&stats.Unsubscribed).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = recipients().
+		Select("error AS bucket, COUNT(*) AS hits").
+		Where("status = ? AND error <> ''", RecipientFailed).
+		Group("error").
+		Order("hits DESC").
+		Limit(top).
+		Scan(&stats.Errors).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Table("campaign_links").
+		Select("campaign_links.url, COUNT(campaign_clicks.id) AS clicks, COUNT(DISTINCT campaign_clicks.recipient_id) AS recipients").
+		Joins("LEFT JOIN campaign_clicks ON campaign_clicks.link_id = campaign_links.id").
+		Where("campaign_links.campaign_id = ?", campaignID).
+		Group("campaign_links.id, campaign_links.url").
+		Order("clicks DESC").
+		Scan(&stats.Links).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = recipients().
+		Select("DATE(opened_at) AS bucket, COUNT(*) AS hits").
+		Where("opened_at IS NOT NULL").
+		Group("DATE(opened_at)").
+		Order("bucket ASC").
+		Scan(&stats.DailyOpens).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Model(&Click{}).
+		Select("DATE(created_at) AS bucket, COUNT(*) AS hits").
+		Where("campaign_id = ?", campaignID).
+		Group("DATE(created_at)").
+		Order("bucket ASC").
+		Scan(&stats.DailyClicks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
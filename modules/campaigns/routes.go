@@ -0,0 +1,54 @@
+package campaigns
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	// Start scheduled campaigns and send queued batches in the background
+	core.Resolve[*Sender](container).Start()
+
+	// ==================== Tracking ====================
+	// Links in emails live outside /api/v1, on the root app
+	if app := core.Resolve[*fiber.App](container); app != nil {
+		app.Get("/campaigns/t/:token/o.gif", controller.Open)
+		app.Get("/campaigns/t/:token/l/:link", controller.Click)
+		app.Get("/campaigns/u/:token", controller.Unsubscribe)
+		app.Post("/campaigns/u/:token", controller.Unsubscribe)
+	}
+
+	// ==================== Management API ====================
+	campaigns := router.Group("/campaigns", auth.AuthMiddleware(jwtManager, auth.AcceptAPIKeys()))
+
+	// Segments
+	campaigns.Get("/segments", rbac.RequirePermission(rbacManager, "campaigns.read"), controller.ListSegments)
+	campaigns.Post("/segments", rbac.RequirePermission(rbacManager, "campaigns.manage"), controller.CreateSegment)
+	campaigns.Post("/segments/preview", rbac.RequirePermission(rbacManager, "campaigns.read"), controller.PreviewFilters)
+	campaigns.Get("/segments/:id", rbac.RequirePermission(rbacManager, "campaigns.read"), controller.GetSegment)
+	campaigns.Put("/segments/:id", rbac.RequirePermission(rbacManager, "campaigns.manage"), controller.UpdateSegment)
+	campaigns.Delete("/segments/:id", rbac.RequirePermission(rbacManager, "campaigns.manage"), controller.DeleteSegment)
+	campaigns.Get("/segments/:id/preview", rbac.RequirePermission(rbacManager, "campaigns.read"), controller.PreviewSegment)
+
+	// Campaigns
+	campaigns.Get("", rbac.RequirePermission(rbacManager, "campaigns.read"), controller.List)
+	campaigns.Post("", rbac.RequirePermission(rbacManager, "campaigns.manage"), controller.Create)
+	campaigns.Get("/:id", rbac.RequirePermission(rbacManager, "campaigns.read"), controller.Get)
+	campaigns.Put("/:id", rbac.RequirePermission(rbacManager, "campaigns.manage"), controller.Update)
+	campaigns.Delete("/:id", rbac.RequirePermission(rbacManager, "campaigns.manage"), controller.Delete)
+	campaigns.Post("/:id/send", rbac.RequirePermission(rbacManager, "campaigns.manage"), controller.Send)
+	campaigns.Post("/:id/pause", rbac.RequirePermission(rbacManager, "campaigns.manage"), controller.Pause)
+	campaigns.Post("/:id/resume", rbac.RequirePermission(rbacManager, "campaigns.manage"), controller.Resume)
+	campaigns.Post("/:id/cancel", rbac.RequirePermission(rbacManager, "campaigns.manage"), controller.Cancel)
+	campaigns.Post("/:id/test", rbac.RequirePermission(rbacManager, "campaigns.manage"), controller.Test)
+	campaigns.Get("/:id/stats", rbac.RequirePermission(rbacManager, "campaigns.read"), controller.Stats)
+	campaigns.Get("/:id/recipients", rbac.RequirePermission(rbacManager, "campaigns.read"), controller.Recipients)
+}
@@ -0,0 +1,239 @@
+package campaigns
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Filter operators
+const (
+	OpEq        = "eq"
+	OpNeq       = "neq"
+	OpGt        = "gt"
+	OpGte       = "gte"
+	OpLt        = "lt"
+	OpLte       = "lte"
+	OpContains  = "contains"   // Case-insensitive substring
+	OpIn        = "in"         // Any of a list of values
+	OpSet       = "set"        // Has a value
+	OpUnset     = "unset"      // Has no value
+	OpWithin    = "within"     // A time in the last window, e.g. "30d"
+	OpOlderThan = "older_than" // A time before the last window
+)
+
+// Segment match modes
+const (
+	MatchAll = "all"
+	MatchAny = "any"
+)
+
+// Filter matches users on a field, e.g. {"field": "created_at", "op":
+// "within", "value": "30d"}
+type Filter struct {
+	Field string      `json:"field"`
+	Op    string      `json:"op"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+type fieldKind int
+
+const (
+	fieldString fieldKind = iota
+	fieldBool
+	fieldNumber
+	fieldTime
+)
+
+// segmentField is a users column filters may match
+type segmentField struct {
+	column string
+	kind   fieldKind
+}
+
+// segmentFields are the fields of users segments filter on
+var segmentFields = map[string]segmentField{
+	"email":          {"email", fieldString},
+	"name":           {"name", fieldString},
+	"username":       {"username", fieldString},
+	"active":         {"is_active", fieldBool},
+	"email_verified": {"is_email_verified", fieldBool},
+	"age":            {"age", fieldNumber},
+	"created_at":     {"created_at", fieldTime},
+	"last_login_at":  {"last_login_at", fieldTime},
+}
+
+// kindOps are the operators each kind of field supports
+var kindOps = map[fieldKind][]string{
+	fieldString: {OpEq, OpNeq, OpContains, OpIn, OpSet, OpUnset},
+	fieldBool:   {OpEq, OpNeq},
+	fieldNumber: {OpEq, OpNeq, OpGt, OpGte, OpLt, OpLte, OpIn, OpSet, OpUnset},
+	fieldTime:   {OpGt, OpGte, OpLt, OpLte, OpWithin, OpOlderThan, OpSet, OpUnset},
+}
+
+// condition returns the SQL condition of a filter and its arguments, as of
+// now for relative windows
+func (f Filter) condition(now time.Time) (string, []interface{}, error) {
+	field, ok := segmentFields[f.Field]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown field %q", f.Field)
+	}
+	supported := false
+	for _, op := range kindOps[field.kind] {
+		supported = supported || op == f.Op
+	}
+	if !supported {
+		return "", nil, fmt.Errorf("field %s does not support %q", f.Field, f.Op)
+	}
+	column := field.column
+
+	switch f.Op {
+	case OpSet:
+		if field.kind == fieldString {
+			return column + " IS NOT NULL AND " + column + " <> ''", nil, nil
+		}
+		return column + " IS NOT NULL", nil, nil
+	case OpUnset:
+		if field.kind == fieldString {
+			return "(" + column + " IS NULL OR " + column + " = '')", nil, nil
+		}
+		return column + " IS NULL", nil, nil
+	case OpWithin, OpOlderThan:
+		window, err := parseWindow(f.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("field %s: %w", f.Field, err)
+		}
+		if f.Op == OpWithin {
+			return column + " >= ?", []interface{}{now.Add(-window)}, nil
+		}
+		return column + " < ?", []interface{}{now.Add(-window)}, nil
+	case OpContains:
+		text, ok := f.Value.(string)
+		if !ok || text == "" {
+			return "", nil, fmt.Errorf("field %s: contains needs text", f.Field)
+		}
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(text)) + "%"
+		return "LOWER(" + column + ") LIKE ? ESCAPE '\\'", []interface{}{pattern}, nil
+	case OpIn:
+		list, ok := f.Value.([]interface{})
+		if !ok || len(list) == 0 {
+			return "", nil, fmt.Errorf("field %s: in needs a list of values", f.Field)
+		}
+		values := make([]interface{}, len(list))
+		for i, item := range list {
+			value, err := field.value(item)
+			if err != nil {
+				return "", nil, fmt.Errorf("field %s: %w", f.Field, err)
+			}
+			values[i] = value
+		}
+		return column + " IN ?", []interface{}{values}, nil
+	}
+
+	value, err := field.value(f.Value)
+	if err != nil {
+		return "", nil, fmt.Errorf("field %s: %w", f.Field, err)
+	}
+	operators := map[string]string{OpEq: "=", OpNeq: "<>", OpGt: ">", OpGte: ">=", OpLt: "<", OpLte: "<="}
+	return column + " " + operators[f.Op] + " ?", []interface{}{value}, nil
+}
+
+// likeEscaper escapes LIKE wildcards in a search term
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// value converts a JSON filter value to the field's type
+func (f segmentField) value(raw interface{}) (interface{}, error) {
+	switch f.kind {
+	case fieldString:
+		if text, ok := raw.(string); ok {
+			return text, nil
+		}
+		return nil, fmt.Errorf("expected text, got %v", raw)
+	case fieldBool:
+		if flag, ok := raw.(bool); ok {
+			return flag, nil
+		}
+		return nil, fmt.Errorf("expected true or false, got %v", raw)
+	case fieldNumber:
+		if number, ok := raw.(float64); ok {
+			return number, nil
+		}
+		return nil, fmt.Errorf("expected a number, got %v", raw)
+	case fieldTime:
+		if text, ok := raw.(string); ok {
+			if at, err := time.Parse(time.RFC3339, text); err == nil {
+				return at, nil
+			}
+			if at, err := time.Parse("2006-01-02", text); err == nil {
+				return at, nil
+			}
+		}
+		return nil, fmt.Errorf("expected an RFC 3339 time or date, got %v", raw)
+	}
+	return nil, fmt.Errorf("unsupported value %v", raw)
+}
+
+// parseWindow parses a relative window: a Go duration, or a number of days
+// or weeks such as "30d" or "2w"
+func parseWindow(raw interface{}) (time.Duration, error) {
+	text, ok := raw.(string)
+	if !ok || text == "" {
+		return 0, fmt.Errorf("expected a window such as 30d, got %v", raw)
+	}
+
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if unit, ok := units[text[len(text)-1]]; ok {
+		count, err := strconv.Atoi(text[:len(text)-1])
+		if err != nil || count <= 0 {
+			return 0, fmt.Errorf("invalid window %q", text)
+		}
+		return time.Duration(count) * unit, nil
+	}
+	window, err := time.ParseDuration(text)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("invalid window %q", text)
+	}
+	return window, nil
+}
+
+// validateFilters checks a segment's match mode and filters
+func validateFilters(match string, filters []Filter) error {
+	if match != MatchAll && match != MatchAny {
+		return fmt.Errorf("match must be %s or %s", MatchAll, MatchAny)
+	}
+	now := time.Now()
+	for _, filter := range filters {
+		if _, _, err := filter.condition(now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applySegment narrows a users query to a segment's audience. A segment
+// without filters matches every user.
+func applySegment(query *gorm.DB, segment *Segment, now time.Time) (*gorm.DB, error) {
+	if len(segment.Filters) == 0 {
+		return query, nil
+	}
+
+	conditions := make([]string, 0, len(segment.Filters))
+	var args []interface{}
+	for _, filter := range segment.Filters {
+		condition, filterArgs, err := filter.condition(now)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, "("+condition+")")
+		args = append(args, filterArgs...)
+	}
+
+	join := " AND "
+	if segment.Match == MatchAny {
+		join = " OR "
+	}
+	return query.Where("("+strings.Join(conditions, join)+")", args...), nil
+}
@@ -0,0 +1,83 @@
+package campaigns
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/logger"
+)
+
+// Sender starts scheduled campaigns and sends the batches of recipients
+// queued by any instance, throttled to the rate shared by all of them
+type Sender struct {
+	service  *Service
+	interval time.Duration
+
+	mu         sync.Mutex
+	started    bool
+	subscribed bool
+	stop       chan struct{}
+}
+
+// NewSender creates a sender checking for due campaigns every interval,
+// and as soon as a campaign is sent now on this instance
+func NewSender(service *Service, interval time.Duration) *Sender {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &Sender{service: service, interval: interval}
+}
+
+// Start begins sending in the background. It is safe to call more than
+// once. Batches keep being handled after Stop, as queue subscriptions last
+// for the life of the queue.
+func (s *Sender) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+	if !s.subscribed && s.service.queue != nil {
+		if err := s.service.queue.Subscribe(BatchTopic, BatchGroup, s.service.HandleBatch); err != nil {
+			logger.Error("Failed to subscribe to campaign batches", logger.Fields{"error": err.Error()})
+		} else {
+			s.subscribed = true
+		}
+	}
+	s.started = true
+	s.stop = make(chan struct{})
+	go s.run(s.stop)
+}
+
+// Stop ends starting scheduled campaigns
+func (s *Sender) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		close(s.stop)
+		s.started = false
+	}
+}
+
+func (s *Sender) run(stop <-chan struct{}) {
+	if s.service.config.Requeue {
+		s.service.Requeue(context.Background())
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.service.Reconcile(context.Background())
+		case <-s.service.queued:
+			s.service.StartDue(context.Background())
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,581 @@
+package campaigns
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/metrics"
+	"neonexcore/pkg/notification"
+	"neonexcore/pkg/queue"
+)
+
+// Campaign event names
+const (
+	EventCampaignStarted   = "campaigns.started"
+	EventCampaignCompleted = "campaigns.completed"
+	EventCampaignCanceled  = "campaigns.canceled"
+	EventUnsubscribed      = "campaigns.unsubscribed"
+)
+
+const (
+	previewSampleSize = 10
+	statsTopLimit     = 10
+)
+
+// Notifier sends campaign emails
+type Notifier interface {
+	Send(ctx context.Context, notification *notification.Notification) error
+}
+
+// Limiter throttles sending to the provider's rate, shared by every
+// instance when backed by Redis
+type Limiter interface {
+	Wait(ctx context.Context, key string, rate float64, burst int) error
+}
+
+// Config holds campaign configuration
+type Config struct {
+	BaseURL     string  // Public URL of the app, for tracking and unsubscribe links
+	Rate        float64 // Emails a second across every instance, per the provider's limits
+	Burst       int     // Emails sent at once after a quiet spell
+	BatchSize   int     // Recipients in each queued batch
+	MaxAttempts int     // Sends to a recipient before it is marked failed
+	Requeue     bool    // Queue the pending recipients of sending campaigns on start, for queues that lose messages on restart
+}
+
+// DefaultConfig returns default campaign configuration
+func DefaultConfig() Config {
+	return Config{
+		BaseURL:     "http://localhost:8080",
+		Rate:        10,
+		Burst:       10,
+		BatchSize:   100,
+		MaxAttempts: 3,
+	}
+}
+
+// SegmentInput is the payload for creating or updating a segment
+type SegmentInput struct {
+	Name        string   `json:"name" validate:"required,max=200"`
+	Description string   `json:"description" validate:"max=1000"`
+	Match       string   `json:"match" validate:"omitempty,oneof=all any"`
+	Filters     []Filter `json:"filters"`
+}
+
+// SegmentPreview is the size of a segment's audience and a sample of it
+type SegmentPreview struct {
+	Count  int64      `json:"count"`
+	Sample []Audience `json:"sample"`
+}
+
+// CampaignInput is the payload for creating or updating a campaign
+type CampaignInput struct {
+	Name      string            `json:"name" validate:"required,max=200"`
+	SegmentID uint              `json:"segment_id" validate:"required"`
+	Subject   string            `json:"subject" validate:"required,max=500"`
+	Body      string            `json:"body" validate:"required"`
+	Vars      map[string]string `json:"vars"`
+	Track     *bool             `json:"track"` // Defaults to true
+}
+
+// SendInput is the payload for sending a campaign, now or at ScheduledAt
+type SendInput struct {
+	ScheduledAt *time.Time `json:"scheduled_at"`
+}
+
+// TestInput is the payload for sending a campaign to one address
+type TestInput struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type Service struct {
+	repo     *Repository
+	notifier Notifier
+	queue    queue.Queue
+	limiter  Limiter
+	metrics  *metrics.Collector
+	config   Config
+	queued   chan struct{} // Wakes the sender when a campaign is sent now
+}
+
+func NewService(repo *Repository, notifier Notifier, q queue.Queue, limiter Limiter, collector *metrics.Collector, config Config) *Service {
+	defaults := DefaultConfig()
+	if config.Rate <= 0 {
+		config.Rate = defaults.Rate
+	}
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+
+	return &Service{
+		repo:     repo,
+		notifier: notifier,
+		queue:    q,
+		limiter:  limiter,
+		metrics:  collector,
+		config:   config,
+		queued:   make(chan struct{}, 1),
+	}
+}
+
+// ==================== Segments ====================
+
+func (s *Service) ListSegments(ctx context.Context, page, limit int) ([]Segment, int64, error) {
+	segments, total, err := s.repo.ListSegments(ctx, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list segments").WithError(err)
+	}
+	return segments, total, nil
+}
+
+func (s *Service) GetSegment(ctx context.Context, id uint) (*Segment, error) {
+	segment, err := s.repo.FindSegment(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load segment").WithError(err)
+	}
+	if segment == nil {
+		return nil, errors.NewNotFound("Segment not found")
+	}
+	return segment, nil
+}
+
+func (s *Service) CreateSegment(ctx context.Context, input *SegmentInput, userID uint) (*Segment, error) {
+	segment := &Segment{CreatedBy: userID}
+	if err := applySegmentInput(segment, input); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateSegment(ctx, segment); err != nil {
+		return nil, errors.NewInternal("Failed to create segment").WithError(err)
+	}
+	return segment, nil
+}
+
+func (s *Service) UpdateSegment(ctx context.Context, id uint, input *SegmentInput) (*Segment, error) {
+	segment, err := s.GetSegment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applySegmentInput(segment, input); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateSegment(ctx, segment); err != nil {
+		return nil, errors.NewInternal("Failed to update segment").WithError(err)
+	}
+	return segment, nil
+}
+
+func (s *Service) DeleteSegment(ctx context.Context, id uint) error {
+	if _, err := s.GetSegment(ctx, id); err != nil {
+		return err
+	}
+	inUse, err := s.repo.SegmentInUse(ctx, id)
+	if err != nil {
+		return errors.NewInternal("Failed to check segment").WithError(err)
+	}
+	if inUse {
+		return errors.NewConflict("Segment is used by campaigns that have not finished")
+	}
+	if err := s.repo.DeleteSegment(ctx, id); err != nil {
+		return errors.NewInternal("Failed to delete segment").WithError(err)
+	}
+	return nil
+}
+
+// PreviewSegment counts a segment's audience as it stands and samples it
+func (s *Service) PreviewSegment(ctx context.Context, segment *Segment) (*SegmentPreview, error) {
+	if err := validateFilters(segment.Match, segment.Filters); err != nil {
+		return nil, errors.NewBadRequest(err.Error())
+	}
+
+	now := time.Now()
+	count, err := s.repo.CountAudience(ctx, segment, now)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to count audience").WithError(err)
+	}
+	sample, err := s.repo.FindAudience(ctx, segment, now, 0, previewSampleSize)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load audience").WithError(err)
+	}
+	return &SegmentPreview{Count: count, Sample: sample}, nil
+}
+
+// PreviewInput previews the audience of an unsaved segment
+func (s *Service) PreviewInput(ctx context.Context, input *SegmentInput) (*SegmentPreview, error) {
+	segment := &Segment{}
+	if err := applySegmentInput(segment, input); err != nil {
+		return nil, err
+	}
+	return s.PreviewSegment(ctx, segment)
+}
+
+func applySegmentInput(segment *Segment, input *SegmentInput) error {
+	match := input.Match
+	if match == "" {
+		match = MatchAll
+	}
+	if err := validateFilters(match, input.Filters); err != nil {
+		return errors.NewBadRequest(err.Error())
+	}
+
+	segment.Name = input.Name
+	segment.Description = input.Description
+	segment.Match = match
+	segment.Filters = input.Filters
+	return nil
+}
+
+// ==================== Campaigns ====================
+
+func (s *Service) ListCampaigns(ctx context.Context, status string, page, limit int) ([]Campaign, int64, error) {
+	campaigns, total, err := s.repo.ListCampaigns(ctx, status, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list campaigns").WithError(err)
+	}
+	return campaigns, total, nil
+}
+
+func (s *Service) GetCampaign(ctx context.Context, id uint) (*Campaign, error) {
+	campaign, err := s.repo.FindCampaign(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load campaign").WithError(err)
+	}
+	if campaign == nil {
+		return nil, errors.NewNotFound("Campaign not found")
+	}
+	return campaign, nil
+}
+
+func (s *Service) CreateCampaign(ctx context.Context, input *CampaignInput, userID uint) (*Campaign, error) {
+	campaign := &Campaign{Status: StatusDraft, Track: true, CreatedBy: userID}
+	if err := s.applyCampaignInput(ctx, campaign, input); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateCampaign(ctx, campaign); err != nil {
+		return nil, errors.NewInternal("Failed to create campaign").WithError(err)
+	}
+	return campaign, nil
+}
+
+// UpdateCampaign changes a campaign that has not started sending
+func (s *Service) UpdateCampaign(ctx context.Context, id uint, input *CampaignInput) (*Campaign, error) {
+	campaign, err := s.GetCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !campaign.Editable() {
+		return nil, errors.NewConflict("Campaign has already been sent")
+	}
+	if err := s.applyCampaignInput(ctx, campaign, input); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateCampaign(ctx, campaign); err != nil {
+		return nil, errors.NewInternal("Failed to update campaign").WithError(err)
+	}
+	return campaign, nil
+}
+
+// DeleteCampaign deletes a campaign with its recipients and tracking,
+// unless it is being sent
+func (s *Service) DeleteCampaign(ctx context.Context, id uint) error {
+	campaign, err := s.GetCampaign(ctx, id)
+	if err != nil {
+		return err
+	}
+	if campaign.Status == StatusSending || campaign.Status == StatusPaused {
+		return errors.NewConflict("Cancel the campaign before deleting it")
+	}
+	if err := s.repo.DeleteCampaign(ctx, id); err != nil {
+		return errors.NewInternal("Failed to delete campaign").WithError(err)
+	}
+	return nil
+}
+
+func (s *Service) applyCampaignInput(ctx context.Context, campaign *Campaign, input *CampaignInput) error {
+	if _, err := ParseContent(input.Subject, input.Body); err != nil {
+		return errors.NewBadRequest("Invalid template: " + err.Error())
+	}
+	if _, err := s.GetSegment(ctx, input.SegmentID); err != nil {
+		return err
+	}
+
+	campaign.Name = input.Name
+	campaign.SegmentID = input.SegmentID
+	campaign.Subject = input.Subject
+	campaign.Body = input.Body
+	campaign.Vars = input.Vars
+	if input.Track != nil {
+		campaign.Track = *input.Track
+	}
+	return nil
+}
+
+// Send sends a draft campaign now, or schedules it; a scheduled campaign
+// may be moved or sent now
+func (s *Service) Send(ctx context.Context, id uint, input *SendInput) (*Campaign, error) {
+	campaign, err := s.GetCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !campaign.Editable() {
+		return nil, errors.NewConflict("Campaign has already been sent")
+	}
+
+	at := time.Now()
+	if input.ScheduledAt != nil {
+		if !input.ScheduledAt.After(at) {
+			return nil, errors.NewBadRequest("Schedule time must be in the future")
+		}
+		at = *input.ScheduledAt
+	}
+
+	moved, err := s.repo.TransitionCampaign(ctx, id, []string{StatusDraft, StatusScheduled}, StatusScheduled, map[string]interface{}{"scheduled_at": at})
+	if err != nil {
+		return nil, errors.NewInternal("Failed to schedule campaign").WithError(err)
+	}
+	if !moved {
+		return nil, errors.NewConflict("Campaign has already been sent")
+	}
+	if input.ScheduledAt == nil {
+		s.wake()
+	}
+	return s.GetCampaign(ctx, id)
+}
+
+// Pause stops sending a campaign; recipients not yet sent to wait for Resume
+func (s *Service) Pause(ctx context.Context, id uint) (*Campaign, error) {
+	if err := s.transition(ctx, id, []string{StatusSending}, StatusPaused, nil); err != nil {
+		return nil, err
+	}
+	return s.GetCampaign(ctx, id)
+}
+
+// Resume continues sending a paused campaign
+func (s *Service) Resume(ctx context.Context, id uint) (*Campaign, error) {
+	if err := s.transition(ctx, id, []string{StatusPaused}, StatusSending, nil); err != nil {
+		return nil, err
+	}
+	campaign, err := s.GetCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.QueuedAt != nil {
+		if err := s.enqueuePending(ctx, campaign.ID); err != nil {
+			return nil, errors.NewInternal("Failed to queue recipients").WithError(err)
+		}
+	}
+	return campaign, nil
+}
+
+// Cancel stops a campaign for good, skipping recipients not yet sent to
+func (s *Service) Cancel(ctx context.Context, id uint) (*Campaign, error) {
+	from := []string{StatusDraft, StatusScheduled, StatusSending, StatusPaused}
+	if err := s.transition(ctx, id, from, StatusCanceled, map[string]interface{}{"completed_at": time.Now()}); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.SkipPending(ctx, id, "campaign canceled"); err != nil {
+		return nil, errors.NewInternal("Failed to skip recipients").WithError(err)
+	}
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventCampaignCanceled,
+		Data: map[string]interface{}{"campaign_id": id},
+	})
+	return s.GetCampaign(ctx, id)
+}
+
+// transition moves a campaign between statuses, with a conflict if it is
+// in none of from
+func (s *Service) transition(ctx context.Context, id uint, from []string, to string, updates map[string]interface{}) error {
+	campaign, err := s.GetCampaign(ctx, id)
+	if err != nil {
+		return err
+	}
+	moved, err := s.repo.TransitionCampaign(ctx, id, from, to, updates)
+	if err != nil {
+		return errors.NewInternal("Failed to update campaign").WithError(err)
+	}
+	if !moved {
+		return errors.NewConflict("Campaign cannot be " + to + " while " + campaign.Status)
+	}
+	return nil
+}
+
+// SendTest sends a campaign to one address with sample merge data and
+// without tracking, to check how it renders
+func (s *Service) SendTest(ctx context.Context, id uint, email string) error {
+	campaign, err := s.GetCampaign(ctx, id)
+	if err != nil {
+		return err
+	}
+	if s.notifier == nil {
+		return errors.NewInternal("Email notifications are not configured")
+	}
+
+	content, err := ParseContent(campaign.Subject, campaign.Body)
+	if err != nil {
+		return errors.NewBadRequest("Invalid template: " + err.Error())
+	}
+	subject, body, err := content.Render(MergeData{
+		Name:           "Test Recipient",
+		FirstName:      "Test",
+		Email:          email,
+		Campaign:       campaign.Name,
+		UnsubscribeURL: s.config.BaseURL + "/campaigns/u/test",
+		Vars:           campaign.Vars,
+	})
+	if err != nil {
+		return errors.NewBadRequest("Failed to render campaign: " + err.Error())
+	}
+
+	err = s.notifier.Send(ctx, &notification.Notification{
+		Channel: notification.ChannelEmail,
+		To:      email,
+		Subject: "[Test] " + subject,
+		Body:    body,
+		Data:    map[string]interface{}{"html": true, "campaign_id": campaign.ID},
+	})
+	if err != nil {
+		return errors.NewInternal("Failed to send test email").WithError(err)
+	}
+	return nil
+}
+
+// ==================== Analytics ====================
+
+// Stats returns a campaign's delivery and engagement
+func (s *Service) Stats(ctx context.Context, id uint) (*Stats, error) {
+	campaign, err := s.GetCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := s.repo.Stats(ctx, id, statsTopLimit)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load campaign stats").WithError(err)
+	}
+	stats.Status = campaign.Status
+	if stats.Sent > 0 {
+		stats.OpenRate = float64(stats.Opened) / float64(stats.Sent)
+		stats.ClickRate = float64(stats.Clicked) / float64(stats.Sent)
+	}
+	if stats.Opened > 0 {
+		stats.ClickToOpen = float64(stats.Clicked) / float64(stats.Opened)
+	}
+	if campaign.StartedAt != nil {
+		end := time.Now()
+		if campaign.CompletedAt != nil {
+			end = *campaign.CompletedAt
+		}
+		if elapsed := end.Sub(*campaign.StartedAt).Seconds(); elapsed > 0 {
+			stats.SendRate = float64(stats.Sent+stats.Failed) / elapsed
+		}
+	}
+	return stats, nil
+}
+
+func (s *Service) ListRecipients(ctx context.Context, id uint, status string, page, limit int) ([]Recipient, int64, error) {
+	if _, err := s.GetCampaign(ctx, id); err != nil {
+		return nil, 0, err
+	}
+	recipients, total, err := s.repo.ListRecipients(ctx, id, status, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list recipients").WithError(err)
+	}
+	return recipients, total, nil
+}
+
+// ==================== Tracking ====================
+
+// TrackOpen records a recipient opening a campaign email. Unknown tokens
+// are ignored, so the pixel always loads.
+func (s *Service) TrackOpen(ctx context.Context, token string) {
+	recipient, err := s.repo.FindRecipientByToken(ctx, token)
+	if err != nil || recipient == nil {
+		return
+	}
+	s.recordOpen(ctx, recipient)
+}
+
+func (s *Service) recordOpen(ctx context.Context, recipient *Recipient) {
+	if _, err := s.repo.RecordOpen(ctx, recipient, time.Now()); err != nil {
+		logger.Warn("Failed to record campaign open", logger.Fields{"recipient_id": recipient.ID, "error": err.Error()})
+		return
+	}
+	if s.metrics != nil {
+		s.metrics.NewCounter("campaigns_opens_total", "Total campaign email opens", nil).Inc()
+	}
+}
+
+// TrackClick records a recipient following a link and returns its URL. A
+// click implies an open, for clients that block images.
+func (s *Service) TrackClick(ctx context.Context, token string, linkID uint) (string, error) {
+	recipient, err := s.repo.FindRecipientByToken(ctx, token)
+	if err != nil {
+		return "", errors.NewInternal("Failed to load recipient").WithError(err)
+	}
+	if recipient == nil {
+		return "", errors.NewNotFound("Link not found")
+	}
+	link, err := s.repo.FindLink(ctx, recipient.CampaignID, linkID)
+	if err != nil {
+		return "", errors.NewInternal("Failed to load link").WithError(err)
+	}
+	if link == nil {
+		return "", errors.NewNotFound("Link not found")
+	}
+
+	if recipient.OpenedAt == nil {
+		s.recordOpen(ctx, recipient)
+	}
+	if _, err := s.repo.RecordClick(ctx, recipient, link.ID, time.Now()); err != nil {
+		logger.Warn("Failed to record campaign click", logger.Fields{"recipient_id": recipient.ID, "error": err.Error()})
+	} else if s.metrics != nil {
+		s.metrics.NewCounter("campaigns_clicks_total", "Total campaign link clicks", nil).Inc()
+	}
+	return link.URL, nil
+}
+
+// Unsubscribe opts a recipient's address out of every campaign
+func (s *Service) Unsubscribe(ctx context.Context, token string) error {
+	recipient, err := s.repo.FindRecipientByToken(ctx, token)
+	if err != nil {
+		return errors.NewInternal("Failed to load recipient").WithError(err)
+	}
+	if recipient == nil {
+		return errors.NewNotFound("Unsubscribe link is invalid")
+	}
+	if err := s.repo.Unsubscribe(ctx, recipient.Email, recipient.CampaignID); err != nil {
+		return errors.NewInternal("Failed to unsubscribe").WithError(err)
+	}
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventUnsubscribed,
+		Data: map[string]interface{}{
+			"campaign_id": recipient.CampaignID,
+			"user_id":     recipient.UserID,
+			"email":       recipient.Email,
+		},
+	})
+	return nil
+}
+
+// trackURL returns the prefix of a recipient's open and click links
+func (s *Service) trackURL(token string) string {
+	return s.config.BaseURL + "/campaigns/t/" + token
+}
+
+// unsubscribeURL returns a recipient's unsubscribe link
+func (s *Service) unsubscribeURL(token string) string {
+	return s.config.BaseURL + "/campaigns/u/" + token
+}
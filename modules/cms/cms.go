@@ -0,0 +1,28 @@
+package cms
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CMSModule struct{}
+
+func New() *CMSModule {
+	return &CMSModule{}
+}
+
+func (m *CMSModule) Name() string {
+	return "cms"
+}
+
+func (m *CMSModule) Init() {}
+
+func (m *CMSModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *CMSModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
@@ -0,0 +1,441 @@
+package cms
+
+import (
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// ListTypes lists content types
+// @Summary List content types
+// @Tags CMS
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]ContentType}
+// @Router /cms/types [get]
+func (c *Controller) ListTypes(ctx *fiber.Ctx) error {
+	types, err := c.service.ListTypes(ctx.Context())
+	if err != nil {
+		return api.InternalError(ctx, err.Error())
+	}
+	return api.Success(ctx, types)
+}
+
+// GetType retrieves a content type
+// @Summary Get content type
+// @Tags CMS
+// @Security BearerAuth
+// @Produce json
+// @Param slug path string true "Content type slug"
+// @Success 200 {object} api.Response{data=ContentType}
+// @Failure 404 {object} api.Response
+// @Router /cms/types/{slug} [get]
+func (c *Controller) GetType(ctx *fiber.Ctx) error {
+	ct, err := c.service.GetType(ctx.Context(), ctx.Params("slug"))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, ct)
+}
+
+// CreateType creates a content type
+// @Summary Create content type
+// @Description Create a content type with a JSON schema for its data
+// @Tags CMS
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param type body ContentTypeInput true "Content type"
+// @Success 201 {object} api.Response{data=ContentType}
+// @Failure 400 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /cms/types [post]
+func (c *Controller) CreateType(ctx *fiber.Ctx) error {
+	var input ContentTypeInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	ct, err := c.service.CreateType(ctx.Context(), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Content type created", ct)
+}
+
+// UpdateType updates a content type
+// @Summary Update content type
+// @Tags CMS
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param slug path string true "Content type slug"
+// @Param type body ContentTypeInput true "Content type"
+// @Success 200 {object} api.Response{data=ContentType}
+// @Router /cms/types/{slug} [put]
+func (c *Controller) UpdateType(ctx *fiber.Ctx) error {
+	var input ContentTypeInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	ct, err := c.service.UpdateType(ctx.Context(), ctx.Params("slug"), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, ct)
+}
+
+// DeleteType deletes an unused content type
+// @Summary Delete content type
+// @Tags CMS
+// @Security BearerAuth
+// @Param slug path string true "Content type slug"
+// @Success 204 "No Content"
+// @Failure 409 {object} api.Response
+// @Router /cms/types/{slug} [delete]
+func (c *Controller) DeleteType(ctx *fiber.Ctx) error {
+	if err := c.service.DeleteType(ctx.Context(), ctx.Params("slug")); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.NoContent(ctx)
+}
+
+// ListContents lists content with optional type and status filters
+// @Summary List content
+// @Tags CMS
+// @Security BearerAuth
+// @Produce json
+// @Param type query string false "Content type slug"
+// @Param status query string false "Status (draft, published, archived)"
+// @Success 200 {object} api.Response{data=[]Content}
+// @Router /cms/contents [get]
+func (c *Controller) ListContents(ctx *fiber.Ctx) error {
+	pagination := api.GetPagination(ctx)
+
+	contents, total, err := c.service.ListContents(ctx.Context(), ctx.Query("type"), ctx.Query("status"), pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, contents, pagination.Page, pagination.Limit, total)
+}
+
+// GetContent retrieves content including its draft data
+// @Summary Get content
+// @Tags CMS
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Content ID"
+// @Success 200 {object} api.Response{data=Content}
+// @Router /cms/contents/{id} [get]
+func (c *Controller) GetContent(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid content ID", nil)
+	}
+
+	content, err := c.service.GetContent(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, content)
+}
+
+// CreateContent creates a draft
+// @Summary Create content
+// @Tags CMS
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param content body ContentInput true "Content"
+// @Success 201 {object} api.Response{data=Content}
+// @Failure 422 {object} api.Response
+// @Router /cms/contents [post]
+func (c *Controller) CreateContent(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input ContentInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	content, err := c.service.CreateContent(ctx.Context(), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Content created", content)
+}
+
+// UpdateContent updates the draft and records a version
+// @Summary Update content
+// @Tags CMS
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Content ID"
+// @Param content body ContentInput true "Content"
+// @Success 200 {object} api.Response{data=Content}
+// @Router /cms/contents/{id} [put]
+func (c *Controller) UpdateContent(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid content ID", nil)
+	}
+
+	var input ContentInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	content, err := c.service.UpdateContent(ctx.Context(), uint(id), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, content)
+}
+
+// DeleteContent deletes content
+// @Summary Delete content
+// @Tags CMS
+// @Security BearerAuth
+// @Param id path int true "Content ID"
+// @Success 204 "No Content"
+// @Router /cms/contents/{id} [delete]
+func (c *Controller) DeleteContent(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid content ID", nil)
+	}
+
+	if err := c.service.DeleteContent(ctx.Context(), uint(id)); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.NoContent(ctx)
+}
+
+// Publish publishes the current draft
+// @Summary Publish content
+// @Tags CMS
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Content ID"
+// @Success 200 {object} api.Response{data=Content}
+// @Router /cms/contents/{id}/publish [post]
+func (c *Controller) Publish(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid content ID", nil)
+	}
+
+	content, err := c.service.Publish(ctx.Context(), uint(id), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Content published", content)
+}
+
+// Unpublish removes content from delivery
+// @Summary Unpublish content
+// @Tags CMS
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Content ID"
+// @Success 200 {object} api.Response{data=Content}
+// @Router /cms/contents/{id}/unpublish [post]
+func (c *Controller) Unpublish(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid content ID", nil)
+	}
+
+	content, err := c.service.Unpublish(ctx.Context(), uint(id), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Content unpublished", content)
+}
+
+// ListVersions lists the version history of content
+// @Summary List content versions
+// @Tags CMS
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Content ID"
+// @Success 200 {object} api.Response{data=[]ContentVersion}
+// @Router /cms/contents/{id}/versions [get]
+func (c *Controller) ListVersions(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid content ID", nil)
+	}
+
+	versions, err := c.service.ListVersions(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, versions)
+}
+
+// GetVersion retrieves a single version
+// @Summary Get content version
+// @Tags CMS
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Content ID"
+// @Param version path int true "Version number"
+// @Success 200 {object} api.Response{data=ContentVersion}
+// @Router /cms/contents/{id}/versions/{version} [get]
+func (c *Controller) GetVersion(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid content ID", nil)
+	}
+	version, err := ctx.ParamsInt("version")
+	if err != nil || version <= 0 {
+		return api.BadRequest(ctx, "Invalid version", nil)
+	}
+
+	v, err := c.service.GetVersion(ctx.Context(), uint(id), version)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, v)
+}
+
+// Diff compares two versions
+// @Summary Diff content versions
+// @Tags CMS
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Content ID"
+// @Param from query int true "From version"
+// @Param to query int true "To version"
+// @Success 200 {object} api.Response{data=[]DiffEntry}
+// @Router /cms/contents/{id}/diff [get]
+func (c *Controller) Diff(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid content ID", nil)
+	}
+
+	from := ctx.QueryInt("from", 0)
+	to := ctx.QueryInt("to", 0)
+	if from <= 0 || to <= 0 {
+		return api.BadRequest(ctx, "Both from and to versions are required", nil)
+	}
+
+	entries, err := c.service.Diff(ctx.Context(), uint(id), from, to)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, entries)
+}
+
+// Rollback restores a previous version into the draft
+// @Summary Roll back content
+// @Tags CMS
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Content ID"
+// @Param version path int true "Version number"
+// @Success 200 {object} api.Response{data=Content}
+// @Router /cms/contents/{id}/rollback/{version} [post]
+func (c *Controller) Rollback(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid content ID", nil)
+	}
+	version, err := ctx.ParamsInt("version")
+	if err != nil || version <= 0 {
+		return api.BadRequest(ctx, "Invalid version", nil)
+	}
+
+	content, err := c.service.Rollback(ctx.Context(), uint(id), version, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Content rolled back", content)
+}
+
+// PreviewToken issues a draft preview token
+// @Summary Issue preview token
+// @Tags CMS
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Content ID"
+// @Success 200 {object} api.Response{data=map[string]interface{}}
+// @Router /cms/contents/{id}/preview-token [post]
+func (c *Controller) PreviewToken(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid content ID", nil)
+	}
+
+	token, expiresAt, err := c.service.IssuePreviewToken(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, fiber.Map{
+		"token":      token,
+		"expires_at": expiresAt,
+	})
+}
+
+// Deliver serves published content (or a draft with ?preview=<token>)
+// @Summary Deliver content
+// @Tags CMS Delivery
+// @Produce json
+// @Param type path string true "Content type slug"
+// @Param slug path string true "Content slug"
+// @Param preview query string false "Preview token"
+// @Success 200 {object} api.Response{data=DeliveredContent}
+// @Failure 404 {object} api.Response
+// @Router /cms/delivery/{type}/{slug} [get]
+func (c *Controller) Deliver(ctx *fiber.Ctx) error {
+	content, err := c.service.Deliver(ctx.Context(), ctx.Params("type"), ctx.Params("slug"), ctx.Query("preview"))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	if content.Preview {
+		ctx.Set("Cache-Control", "no-store")
+	} else {
+		ctx.Set("Cache-Control", "public, max-age=60")
+	}
+	return api.Success(ctx, content)
+}
+
+// DeliverList serves published content of a type
+// @Summary List published content
+// @Tags CMS Delivery
+// @Produce json
+// @Param type path string true "Content type slug"
+// @Success 200 {object} api.Response{data=[]DeliveredContent}
+// @Router /cms/delivery/{type} [get]
+func (c *Controller) DeliverList(ctx *fiber.Ctx) error {
+	pagination := api.GetPagination(ctx)
+
+	contents, total, err := c.service.ListPublished(ctx.Context(), ctx.Params("type"), pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, contents, pagination.Page, pagination.Limit, total)
+}
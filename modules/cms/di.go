@@ -0,0 +1,46 @@
+package cms
+
+import (
+	"os"
+	"time"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultDeliveryCacheTTL = 5 * time.Minute
+	defaultPreviewTokenTTL  = time.Hour
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Preview Signer
+	container.Provide(func() *PreviewSigner {
+		secret := os.Getenv("CMS_PREVIEW_SECRET")
+		if secret == "" {
+			logger.Warn("CMS_PREVIEW_SECRET is not set; preview links will not survive a restart")
+		}
+		return NewPreviewSigner(secret, defaultPreviewTokenTTL)
+	}, core.Singleton)
+
+	// Register Service
+	container.Provide(func() *Service {
+		repo := core.Resolve[*Repository](container)
+		preview := core.Resolve[*PreviewSigner](container)
+		deliveryCache := cache.NewMemoryCache(cache.DefaultMemoryCacheConfig())
+		return NewService(repo, deliveryCache, defaultDeliveryCacheTTL, preview)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
@@ -0,0 +1,92 @@
+package cms
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// diffValues compares two decoded JSON documents and returns the changes
+// needed to turn old into new, ordered by path
+func diffValues(path string, old, new interface{}) []DiffEntry {
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		return diffMaps(path, oldMap, newMap)
+	}
+
+	oldList, oldIsList := old.([]interface{})
+	newList, newIsList := new.([]interface{})
+	if oldIsList && newIsList {
+		return diffLists(path, oldList, newList)
+	}
+
+	if reflect.DeepEqual(old, new) {
+		return nil
+	}
+	return []DiffEntry{{Path: diffPath(path), Op: "changed", Old: old, New: new}}
+}
+
+func diffMaps(path string, old, new map[string]interface{}) []DiffEntry {
+	keys := make(map[string]struct{}, len(old)+len(new))
+	for k := range old {
+		keys[k] = struct{}{}
+	}
+	for k := range new {
+		keys[k] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var entries []DiffEntry
+	for _, k := range sorted {
+		child := k
+		if path != "" {
+			child = path + "." + k
+		}
+
+		oldVal, inOld := old[k]
+		newVal, inNew := new[k]
+		switch {
+		case inOld && !inNew:
+			entries = append(entries, DiffEntry{Path: child, Op: "removed", Old: oldVal})
+		case !inOld && inNew:
+			entries = append(entries, DiffEntry{Path: child, Op: "added", New: newVal})
+		default:
+			entries = append(entries, diffValues(child, oldVal, newVal)...)
+		}
+	}
+	return entries
+}
+
+func diffLists(path string, old, new []interface{}) []DiffEntry {
+	var entries []DiffEntry
+	max := len(old)
+	if len(new) > max {
+		max = len(new)
+	}
+
+	for i := 0; i < max; i++ {
+		child := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(new):
+			entries = append(entries, DiffEntry{Path: child, Op: "removed", Old: old[i]})
+		case i >= len(old):
+			entries = append(entries, DiffEntry{Path: child, Op: "added", New: new[i]})
+		default:
+			entries = append(entries, diffValues(child, old[i], new[i])...)
+		}
+	}
+	return entries
+}
+
+func diffPath(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return path
+}
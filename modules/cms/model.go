@@ -0,0 +1,95 @@
+package cms
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Content statuses
+const (
+	StatusDraft     = "draft"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// ContentType defines a kind of content (page, article, banner, ...) and
+// the JSON schema its data must satisfy
+type ContentType struct {
+	ID          uint           `gorm:"primarykey" json:"id"`
+	Slug        string         `gorm:"size:100;uniqueIndex;not null" json:"slug"`
+	Name        string         `gorm:"size:255;not null" json:"name"`
+	Description string         `gorm:"type:text" json:"description"`
+	Schema      string         `gorm:"type:text;not null" json:"schema"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for ContentType
+func (ContentType) TableName() string {
+	return "cms_content_types"
+}
+
+// Content is a single page or entry. Data holds the working draft;
+// PublishedData holds the snapshot served by the delivery API.
+type Content struct {
+	ID               uint           `gorm:"primarykey" json:"id"`
+	ContentTypeID    uint           `gorm:"not null;uniqueIndex:idx_cms_type_slug" json:"content_type_id"`
+	Slug             string         `gorm:"size:255;not null;uniqueIndex:idx_cms_type_slug" json:"slug"`
+	Title            string         `gorm:"size:255;not null" json:"title"`
+	Status           string         `gorm:"size:20;default:'draft';index" json:"status"`
+	Data             string         `gorm:"type:text" json:"data"`
+	PublishedData    string         `gorm:"type:text" json:"-"`
+	CurrentVersion   int            `gorm:"default:0" json:"current_version"`
+	PublishedVersion int            `gorm:"default:0" json:"published_version"`
+	PublishedAt      *time.Time     `json:"published_at,omitempty"`
+	CreatedBy        uint           `json:"created_by"`
+	UpdatedBy        uint           `json:"updated_by"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+
+	ContentType *ContentType `gorm:"foreignKey:ContentTypeID" json:"content_type,omitempty"`
+}
+
+// TableName specifies the table name for Content
+func (Content) TableName() string {
+	return "cms_contents"
+}
+
+// ContentVersion is an immutable snapshot saved on every change
+type ContentVersion struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	ContentID uint      `gorm:"not null;uniqueIndex:idx_cms_content_version" json:"content_id"`
+	Version   int       `gorm:"not null;uniqueIndex:idx_cms_content_version" json:"version"`
+	Title     string    `gorm:"size:255" json:"title"`
+	Data      string    `gorm:"type:text" json:"data"`
+	Message   string    `gorm:"size:500" json:"message"`
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for ContentVersion
+func (ContentVersion) TableName() string {
+	return "cms_content_versions"
+}
+
+// DiffEntry describes a single change between two versions
+type DiffEntry struct {
+	Path string      `json:"path"`
+	Op   string      `json:"op"` // added, removed, changed
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// DeliveredContent is the public representation served by the delivery API
+type DeliveredContent struct {
+	Type        string                 `json:"type"`
+	Slug        string                 `json:"slug"`
+	Title       string                 `json:"title"`
+	Version     int                    `json:"version"`
+	Data        map[string]interface{} `json:"data"`
+	PublishedAt *time.Time             `json:"published_at,omitempty"`
+	Preview     bool                   `json:"preview,omitempty"`
+}
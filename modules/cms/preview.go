@@ -0,0 +1,89 @@
+package cms
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPreviewToken = errors.New("invalid preview token")
+	ErrExpiredPreviewToken = errors.New("preview token has expired")
+)
+
+// PreviewSigner issues and verifies HMAC-signed preview tokens that grant
+// read access to a content draft without authentication
+type PreviewSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewPreviewSigner creates a new preview signer. Without a secret, a
+// random one is generated, so tokens do not survive a restart.
+func NewPreviewSigner(secret string, ttl time.Duration) *PreviewSigner {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &PreviewSigner{secret: key, ttl: ttl}
+}
+
+// Issue creates a token for a content ID
+func (p *PreviewSigner) Issue(contentID uint) (string, time.Time) {
+	expiresAt := time.Now().Add(p.ttl)
+	payload := fmt.Sprintf("%d.%d", contentID, expiresAt.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + p.sign(payload), expiresAt
+}
+
+// Verify validates a token and returns the content ID it grants
+func (p *PreviewSigner) Verify(token string) (uint, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return 0, ErrInvalidPreviewToken
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return 0, ErrInvalidPreviewToken
+	}
+	payload := string(raw)
+
+	if !hmac.Equal([]byte(p.sign(payload)), []byte(parts[1])) {
+		return 0, ErrInvalidPreviewToken
+	}
+
+	fields := strings.SplitN(payload, ".", 2)
+	if len(fields) != 2 {
+		return 0, ErrInvalidPreviewToken
+	}
+
+	id, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidPreviewToken
+	}
+	expires, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidPreviewToken
+	}
+	if time.Now().Unix() > expires {
+		return 0, ErrExpiredPreviewToken
+	}
+
+	return uint(id), nil
+}
+
+func (p *PreviewSigner) sign(payload string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,180 @@
+package cms
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// WithTx returns a repository bound to a transaction
+func (r *Repository) WithTx(tx *gorm.DB) *Repository {
+	return &Repository{db: tx}
+}
+
+// Transaction runs fn inside a database transaction
+func (r *Repository) Transaction(ctx context.Context, fn func(repo *Repository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(r.WithTx(tx))
+	})
+}
+
+// ==================== Content Types ====================
+
+func (r *Repository) ListTypes(ctx context.Context) ([]ContentType, error) {
+	var types []ContentType
+	err := r.db.WithContext(ctx).Order("name ASC").Find(&types).Error
+	return types, err
+}
+
+func (r *Repository) FindTypeBySlug(ctx context.Context, slug string) (*ContentType, error) {
+	var ct ContentType
+	err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&ct).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ct, nil
+}
+
+func (r *Repository) FindTypeByID(ctx context.Context, id uint) (*ContentType, error) {
+	var ct ContentType
+	err := r.db.WithContext(ctx).First(&ct, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ct, nil
+}
+
+func (r *Repository) CreateType(ctx context.Context, ct *ContentType) error {
+	return r.db.WithContext(ctx).Create(ct).Error
+}
+
+func (r *Repository) UpdateType(ctx context.Context, ct *ContentType) error {
+	return r.db.WithContext(ctx).Save(ct).Error
+}
+
+func (r *Repository) DeleteType(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&ContentType{}, id).Error
+}
+
+func (r *Repository) CountContentsByType(ctx context.Context, typeID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Content{}).Where("content_type_id = ?", typeID).Count(&count).Error
+	return count, err
+}
+
+// ==================== Contents ====================
+
+func (r *Repository) ListContents(ctx context.Context, typeID uint, status string, page, limit int) ([]Content, int64, error) {
+	var contents []Content
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Content{})
+	if typeID > 0 {
+		query = query.Where("content_type_id = ?", typeID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	query.Count(&total)
+
+	offset := (page - 1) * limit
+	err := query.Preload("ContentType").Order("updated_at DESC").Offset(offset).Limit(limit).Find(&contents).Error
+	return contents, total, err
+}
+
+func (r *Repository) FindContentByID(ctx context.Context, id uint) (*Content, error) {
+	var content Content
+	err := r.db.WithContext(ctx).Preload("ContentType").First(&content, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &content, nil
+}
+
+func (r *Repository) FindContentBySlug(ctx context.Context, typeID uint, slug string) (*Content, error) {
+	var content Content
+	err := r.db.WithContext(ctx).
+		Where("content_type_id = ? AND slug = ?", typeID, slug).
+		First(&content).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &content, nil
+}
+
+func (r *Repository) ListPublished(ctx context.Context, typeID uint, page, limit int) ([]Content, int64, error) {
+	var contents []Content
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Content{}).
+		Where("content_type_id = ? AND status = ?", typeID, StatusPublished)
+
+	query.Count(&total)
+
+	offset := (page - 1) * limit
+	err := query.Order("published_at DESC").Offset(offset).Limit(limit).Find(&contents).Error
+	return contents, total, err
+}
+
+func (r *Repository) CreateContent(ctx context.Context, content *Content) error {
+	return r.db.WithContext(ctx).Create(content).Error
+}
+
+func (r *Repository) UpdateContent(ctx context.Context, content *Content) error {
+	return r.db.WithContext(ctx).Omit("ContentType").Save(content).Error
+}
+
+func (r *Repository) DeleteContent(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&Content{}, id).Error
+}
+
+// ==================== Versions ====================
+
+func (r *Repository) CreateVersion(ctx context.Context, version *ContentVersion) error {
+	return r.db.WithContext(ctx).Create(version).Error
+}
+
+func (r *Repository) ListVersions(ctx context.Context, contentID uint) ([]ContentVersion, error) {
+	var versions []ContentVersion
+	err := r.db.WithContext(ctx).
+		Where("content_id = ?", contentID).
+		Order("version DESC").
+		Find(&versions).Error
+	return versions, err
+}
+
+func (r *Repository) FindVersion(ctx context.Context, contentID uint, version int) (*ContentVersion, error) {
+	var v ContentVersion
+	err := r.db.WithContext(ctx).
+		Where("content_id = ? AND version = ?", contentID, version).
+		First(&v).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &v, nil
+}
@@ -0,0 +1,51 @@
+package cms
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	cms := router.Group("/cms")
+
+	// ==================== Delivery API (Public) ====================
+	delivery := cms.Group("/delivery")
+	delivery.Get("/:type", controller.DeliverList)
+	delivery.Get("/:type/:slug", controller.Deliver)
+
+	// ==================== Management API ====================
+	manage := cms.Group("", auth.AuthMiddleware(jwtManager, auth.AcceptAPIKeys()))
+
+	// Content types
+	manage.Get("/types", rbac.RequirePermission(rbacManager, "cms.content.read"), controller.ListTypes)
+	manage.Get("/types/:slug", rbac.RequirePermission(rbacManager, "cms.content.read"), controller.GetType)
+	manage.Post("/types", rbac.RequirePermission(rbacManager, "cms.types.manage"), controller.CreateType)
+	manage.Put("/types/:slug", rbac.RequirePermission(rbacManager, "cms.types.manage"), controller.UpdateType)
+	manage.Delete("/types/:slug", rbac.RequirePermission(rbacManager, "cms.types.manage"), controller.DeleteType)
+
+	// Content
+	manage.Get("/contents", rbac.RequirePermission(rbacManager, "cms.content.read"), controller.ListContents)
+	manage.Get("/contents/:id", rbac.RequirePermission(rbacManager, "cms.content.read"), controller.GetContent)
+	manage.Post("/contents", rbac.RequirePermission(rbacManager, "cms.content.write"), controller.CreateContent)
+	manage.Put("/contents/:id", rbac.RequirePermission(rbacManager, "cms.content.write"), controller.UpdateContent)
+	manage.Delete("/contents/:id", rbac.RequirePermission(rbacManager, "cms.content.write"), controller.DeleteContent)
+
+	// Publishing
+	manage.Post("/contents/:id/publish", rbac.RequirePermission(rbacManager, "cms.content.publish"), controller.Publish)
+	manage.Post("/contents/:id/unpublish", rbac.RequirePermission(rbacManager, "cms.content.publish"), controller.Unpublish)
+	manage.Post("/contents/:id/preview-token", rbac.RequirePermission(rbacManager, "cms.content.read"), controller.PreviewToken)
+
+	// Versions
+	manage.Get("/contents/:id/versions", rbac.RequirePermission(rbacManager, "cms.content.read"), controller.ListVersions)
+	manage.Get("/contents/:id/versions/:version", rbac.RequirePermission(rbacManager, "cms.content.read"), controller.GetVersion)
+	manage.Get("/contents/:id/diff", rbac.RequirePermission(rbacManager, "cms.content.read"), controller.Diff)
+	manage.Post("/contents/:id/rollback/:version", rbac.RequirePermission(rbacManager, "cms.content.write"), controller.Rollback)
+}
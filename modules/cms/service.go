@@ -0,0 +1,531 @@
+package cms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/validation"
+)
+
+// CMS event names
+const (
+	EventContentPublished   = "cms.content.published"
+	EventContentUnpublished = "cms.content.unpublished"
+)
+
+// ContentTypeInput is the payload for creating or updating a content type
+type ContentTypeInput struct {
+	Slug        string          `json:"slug" validate:"required,slug,max=100"`
+	Name        string          `json:"name" validate:"required,max=255"`
+	Description string          `json:"description"`
+	Schema      json.RawMessage `json:"schema" validate:"required"`
+}
+
+// ContentInput is the payload for creating or updating content
+type ContentInput struct {
+	Type    string                 `json:"type" validate:"required"`
+	Slug    string                 `json:"slug" validate:"required,slug,max=255"`
+	Title   string                 `json:"title" validate:"required,max=255"`
+	Data    map[string]interface{} `json:"data"`
+	Message string                 `json:"message"`
+}
+
+type Service struct {
+	repo     *Repository
+	cache    cache.Cache
+	cacheTTL time.Duration
+	preview  *PreviewSigner
+}
+
+func NewService(repo *Repository, c cache.Cache, cacheTTL time.Duration, preview *PreviewSigner) *Service {
+	return &Service{
+		repo:     repo,
+		cache:    c,
+		cacheTTL: cacheTTL,
+		preview:  preview,
+	}
+}
+
+// ==================== Content Types ====================
+
+func (s *Service) ListTypes(ctx context.Context) ([]ContentType, error) {
+	return s.repo.ListTypes(ctx)
+}
+
+func (s *Service) GetType(ctx context.Context, slug string) (*ContentType, error) {
+	ct, err := s.repo.FindTypeBySlug(ctx, slug)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load content type").WithError(err)
+	}
+	if ct == nil {
+		return nil, errors.NewNotFound("Content type not found")
+	}
+	return ct, nil
+}
+
+func (s *Service) CreateType(ctx context.Context, input *ContentTypeInput) (*ContentType, error) {
+	if _, err := validation.ParseSchema(string(input.Schema)); err != nil {
+		return nil, errors.NewBadRequest(err.Error())
+	}
+
+	existing, err := s.repo.FindTypeBySlug(ctx, input.Slug)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load content type").WithError(err)
+	}
+	if existing != nil {
+		return nil, errors.NewConflict("Content type already exists")
+	}
+
+	ct := &ContentType{
+		Slug:        input.Slug,
+		Name:        input.Name,
+		Description: input.Description,
+		Schema:      string(input.Schema),
+	}
+	if err := s.repo.CreateType(ctx, ct); err != nil {
+		return nil, errors.NewInternal("Failed to create content type").WithError(err)
+	}
+	return ct, nil
+}
+
+// UpdateType updates a content type. Existing content is not revalidated;
+// it is checked against the new schema on its next save.
+func (s *Service) UpdateType(ctx context.Context, slug string, input *ContentTypeInput) (*ContentType, error) {
+	ct, err := s.GetType(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := validation.ParseSchema(string(input.Schema)); err != nil {
+		return nil, errors.NewBadRequest(err.Error())
+	}
+
+	ct.Name = input.Name
+	ct.Description = input.Description
+	ct.Schema = string(input.Schema)
+
+	if err := s.repo.UpdateType(ctx, ct); err != nil {
+		return nil, errors.NewInternal("Failed to update content type").WithError(err)
+	}
+	return ct, nil
+}
+
+func (s *Service) DeleteType(ctx context.Context, slug string) error {
+	ct, err := s.GetType(ctx, slug)
+	if err != nil {
+		return err
+	}
+
+	count, err := s.repo.CountContentsByType(ctx, ct.ID)
+	if err != nil {
+		return errors.NewInternal("Failed to count contents").WithError(err)
+	}
+	if count > 0 {
+		return errors.NewConflict("Content type still has content")
+	}
+
+	return s.repo.DeleteType(ctx, ct.ID)
+}
+
+// ==================== Contents ====================
+
+func (s *Service) ListContents(ctx context.Context, typeSlug, status string, page, limit int) ([]Content, int64, error) {
+	var typeID uint
+	if typeSlug != "" {
+		ct, err := s.GetType(ctx, typeSlug)
+		if err != nil {
+			return nil, 0, err
+		}
+		typeID = ct.ID
+	}
+	return s.repo.ListContents(ctx, typeID, status, page, limit)
+}
+
+func (s *Service) GetContent(ctx context.Context, id uint) (*Content, error) {
+	content, err := s.repo.FindContentByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load content").WithError(err)
+	}
+	if content == nil {
+		return nil, errors.NewNotFound("Content not found")
+	}
+	return content, nil
+}
+
+// CreateContent creates a draft and records version 1
+func (s *Service) CreateContent(ctx context.Context, input *ContentInput, userID uint) (*Content, error) {
+	ct, err := s.GetType(ctx, input.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.validateData(ct, input.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.FindContentBySlug(ctx, ct.ID, input.Slug)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load content").WithError(err)
+	}
+	if existing != nil {
+		return nil, errors.NewConflict("Content with this slug already exists")
+	}
+
+	content := &Content{
+		ContentTypeID: ct.ID,
+		Slug:          input.Slug,
+		Title:         input.Title,
+		Status:        StatusDraft,
+		Data:          data,
+		CreatedBy:     userID,
+		UpdatedBy:     userID,
+	}
+
+	err = s.repo.Transaction(ctx, func(repo *Repository) error {
+		if err := repo.CreateContent(ctx, content); err != nil {
+			return err
+		}
+		if err := s.snapshot(ctx, repo, content, input.Message, userID); err != nil {
+			return err
+		}
+		return repo.UpdateContent(ctx, content)
+	})
+	if err != nil {
+		return nil, errors.NewInternal("Failed to create content").WithError(err)
+	}
+
+	content.ContentType = ct
+	return content, nil
+}
+
+// UpdateContent updates the draft and records a new version
+func (s *Service) UpdateContent(ctx context.Context, id uint, input *ContentInput, userID uint) (*Content, error) {
+	content, err := s.GetContent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.validateData(content.ContentType, input.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Slug != content.Slug {
+		existing, err := s.repo.FindContentBySlug(ctx, content.ContentTypeID, input.Slug)
+		if err != nil {
+			return nil, errors.NewInternal("Failed to load content").WithError(err)
+		}
+		if existing != nil {
+			return nil, errors.NewConflict("Content with this slug already exists")
+		}
+	}
+
+	oldSlug := content.Slug
+	content.Slug = input.Slug
+	content.Title = input.Title
+	content.Data = data
+	content.UpdatedBy = userID
+
+	err = s.repo.Transaction(ctx, func(repo *Repository) error {
+		if err := s.snapshot(ctx, repo, content, input.Message, userID); err != nil {
+			return err
+		}
+		return repo.UpdateContent(ctx, content)
+	})
+	if err != nil {
+		return nil, errors.NewInternal("Failed to update content").WithError(err)
+	}
+
+	if oldSlug != content.Slug {
+		s.invalidate(ctx, content.ContentType.Slug, oldSlug)
+	}
+	return content, nil
+}
+
+func (s *Service) DeleteContent(ctx context.Context, id uint) error {
+	content, err := s.GetContent(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteContent(ctx, id); err != nil {
+		return errors.NewInternal("Failed to delete content").WithError(err)
+	}
+
+	s.invalidate(ctx, content.ContentType.Slug, content.Slug)
+	return nil
+}
+
+// Publish copies the current draft to the published snapshot
+func (s *Service) Publish(ctx context.Context, id uint, userID uint) (*Content, error) {
+	content, err := s.GetContent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	content.Status = StatusPublished
+	content.PublishedData = content.Data
+	content.PublishedVersion = content.CurrentVersion
+	content.PublishedAt = &now
+	content.UpdatedBy = userID
+
+	if err := s.repo.UpdateContent(ctx, content); err != nil {
+		return nil, errors.NewInternal("Failed to publish content").WithError(err)
+	}
+
+	s.invalidate(ctx, content.ContentType.Slug, content.Slug)
+	events.DispatchAsync(context.Background(), events.Event{Name: EventContentPublished, Data: content})
+	return content, nil
+}
+
+// Unpublish removes content from delivery while keeping its draft
+func (s *Service) Unpublish(ctx context.Context, id uint, userID uint) (*Content, error) {
+	content, err := s.GetContent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	content.Status = StatusDraft
+	content.PublishedData = ""
+	content.PublishedVersion = 0
+	content.PublishedAt = nil
+	content.UpdatedBy = userID
+
+	if err := s.repo.UpdateContent(ctx, content); err != nil {
+		return nil, errors.NewInternal("Failed to unpublish content").WithError(err)
+	}
+
+	s.invalidate(ctx, content.ContentType.Slug, content.Slug)
+	events.DispatchAsync(context.Background(), events.Event{Name: EventContentUnpublished, Data: content})
+	return content, nil
+}
+
+// ==================== Versions ====================
+
+func (s *Service) ListVersions(ctx context.Context, id uint) ([]ContentVersion, error) {
+	if _, err := s.GetContent(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListVersions(ctx, id)
+}
+
+func (s *Service) GetVersion(ctx context.Context, id uint, version int) (*ContentVersion, error) {
+	v, err := s.repo.FindVersion(ctx, id, version)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load version").WithError(err)
+	}
+	if v == nil {
+		return nil, errors.NewNotFound("Version not found")
+	}
+	return v, nil
+}
+
+// Diff compares two versions of a content item
+func (s *Service) Diff(ctx context.Context, id uint, from, to int) ([]DiffEntry, error) {
+	fromVersion, err := s.GetVersion(ctx, id, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := s.GetVersion(ctx, id, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldData, newData interface{}
+	json.Unmarshal([]byte(fromVersion.Data), &oldData)
+	json.Unmarshal([]byte(toVersion.Data), &newData)
+
+	entries := diffValues("data", oldData, newData)
+	if fromVersion.Title != toVersion.Title {
+		entries = append([]DiffEntry{{Path: "title", Op: "changed", Old: fromVersion.Title, New: toVersion.Title}}, entries...)
+	}
+	if entries == nil {
+		entries = []DiffEntry{}
+	}
+	return entries, nil
+}
+
+// Rollback restores a previous version into the draft as a new version.
+// The published snapshot is unchanged until the next Publish.
+func (s *Service) Rollback(ctx context.Context, id uint, version int, userID uint) (*Content, error) {
+	content, err := s.GetContent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := s.GetVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	content.Title = target.Title
+	content.Data = target.Data
+	content.UpdatedBy = userID
+
+	err = s.repo.Transaction(ctx, func(repo *Repository) error {
+		if err := s.snapshot(ctx, repo, content, fmt.Sprintf("Rollback to version %d", version), userID); err != nil {
+			return err
+		}
+		return repo.UpdateContent(ctx, content)
+	})
+	if err != nil {
+		return nil, errors.NewInternal("Failed to roll back content").WithError(err)
+	}
+	return content, nil
+}
+
+// ==================== Preview ====================
+
+// IssuePreviewToken creates a token granting read access to a draft
+func (s *Service) IssuePreviewToken(ctx context.Context, id uint) (string, time.Time, error) {
+	if _, err := s.GetContent(ctx, id); err != nil {
+		return "", time.Time{}, err
+	}
+	token, expiresAt := s.preview.Issue(id)
+	return token, expiresAt, nil
+}
+
+// ==================== Delivery ====================
+
+// Deliver returns published content by type and slug. When a valid preview
+// token for the item is supplied, the current draft is returned instead.
+func (s *Service) Deliver(ctx context.Context, typeSlug, slug, previewToken string) (*DeliveredContent, error) {
+	if previewToken != "" {
+		return s.deliverPreview(ctx, typeSlug, slug, previewToken)
+	}
+
+	key := deliveryCacheKey(typeSlug, slug)
+	if cached, err := s.cache.Get(ctx, key); err == nil {
+		if delivered, ok := cached.(*DeliveredContent); ok {
+			return delivered, nil
+		}
+	}
+
+	ct, err := s.GetType(ctx, typeSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := s.repo.FindContentBySlug(ctx, ct.ID, slug)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load content").WithError(err)
+	}
+	if content == nil || content.Status != StatusPublished {
+		return nil, errors.NewNotFound("Content not found")
+	}
+
+	delivered := toDelivered(ct.Slug, content, content.PublishedData, content.PublishedVersion)
+	s.cache.Set(ctx, key, delivered, s.cacheTTL)
+	return delivered, nil
+}
+
+func (s *Service) deliverPreview(ctx context.Context, typeSlug, slug, token string) (*DeliveredContent, error) {
+	contentID, err := s.preview.Verify(token)
+	if err != nil {
+		return nil, errors.NewUnauthorized(err.Error())
+	}
+
+	content, err := s.GetContent(ctx, contentID)
+	if err != nil {
+		return nil, err
+	}
+	if content.ContentType.Slug != typeSlug || content.Slug != slug {
+		return nil, errors.NewForbidden("Preview token does not grant access to this content")
+	}
+
+	delivered := toDelivered(typeSlug, content, content.Data, content.CurrentVersion)
+	delivered.Preview = true
+	return delivered, nil
+}
+
+// ListPublished returns published content of a type
+func (s *Service) ListPublished(ctx context.Context, typeSlug string, page, limit int) ([]*DeliveredContent, int64, error) {
+	ct, err := s.GetType(ctx, typeSlug)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	contents, total, err := s.repo.ListPublished(ctx, ct.ID, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list content").WithError(err)
+	}
+
+	result := make([]*DeliveredContent, 0, len(contents))
+	for i := range contents {
+		result = append(result, toDelivered(ct.Slug, &contents[i], contents[i].PublishedData, contents[i].PublishedVersion))
+	}
+	return result, total, nil
+}
+
+// ==================== Helpers ====================
+
+// validateData validates content data against the type schema and returns
+// its JSON encoding
+func (s *Service) validateData(ct *ContentType, data map[string]interface{}) (string, error) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	schema, err := validation.ParseSchema(ct.Schema)
+	if err != nil {
+		return "", errors.NewInternal("Content type schema is invalid").WithError(err)
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", errors.NewBadRequest("Invalid content data")
+	}
+
+	if errs := schema.ValidateJSON(string(raw)); errs != nil {
+		details := make(map[string]interface{}, len(errs))
+		for field, message := range errs {
+			details[field] = message
+		}
+		return "", errors.NewValidationError("Content does not match schema", details)
+	}
+
+	return string(raw), nil
+}
+
+// snapshot increments the content version and stores the draft
+func (s *Service) snapshot(ctx context.Context, repo *Repository, content *Content, message string, userID uint) error {
+	content.CurrentVersion++
+	return repo.CreateVersion(ctx, &ContentVersion{
+		ContentID: content.ID,
+		Version:   content.CurrentVersion,
+		Title:     content.Title,
+		Data:      content.Data,
+		Message:   message,
+		CreatedBy: userID,
+	})
+}
+
+// invalidate drops the cached delivery response for an item
+func (s *Service) invalidate(ctx context.Context, typeSlug, slug string) {
+	s.cache.Delete(ctx, deliveryCacheKey(typeSlug, slug))
+}
+
+func deliveryCacheKey(typeSlug, slug string) string {
+	return "cms:delivery:" + typeSlug + ":" + slug
+}
+
+func toDelivered(typeSlug string, content *Content, data string, version int) *DeliveredContent {
+	decoded := make(map[string]interface{})
+	json.Unmarshal([]byte(data), &decoded)
+
+	return &DeliveredContent{
+		Type:        typeSlug,
+		Slug:        content.Slug,
+		Title:       content.Title,
+		Version:     version,
+		Data:        decoded,
+		PublishedAt: content.PublishedAt,
+	}
+}
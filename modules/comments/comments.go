@@ -0,0 +1,28 @@
+package comments
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CommentsModule struct{}
+
+func New() *CommentsModule {
+	return &CommentsModule{}
+}
+
+func (m *CommentsModule) Name() string {
+	return "comments"
+}
+
+func (m *CommentsModule) Init() {}
+
+func (m *CommentsModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *CommentsModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
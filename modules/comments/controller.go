@@ -0,0 +1,244 @@
+package comments
+
+import (
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// List lists the comment threads of an entity
+// @Summary List comments
+// @Tags Comments
+// @Produce json
+// @Param entity_type query string true "Entity type"
+// @Param entity_id query string true "Entity ID"
+// @Success 200 {object} api.Response{data=[]Comment}
+// @Router /comments [get]
+func (c *Controller) List(ctx *fiber.Ctx) error {
+	entityType := ctx.Query("entity_type")
+	entityID := ctx.Query("entity_id")
+	if entityType == "" || entityID == "" {
+		return api.BadRequest(ctx, "entity_type and entity_id are required", nil)
+	}
+
+	pagination := api.GetPagination(ctx)
+	comments, total, err := c.service.List(ctx.Context(), entityType, entityID, pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, comments, pagination.Page, pagination.Limit, total)
+}
+
+// Get retrieves a comment
+// @Summary Get comment
+// @Tags Comments
+// @Produce json
+// @Param id path int true "Comment ID"
+// @Success 200 {object} api.Response{data=Comment}
+// @Failure 404 {object} api.Response
+// @Router /comments/{id} [get]
+func (c *Controller) Get(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid comment ID", nil)
+	}
+
+	comment, err := c.service.Get(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	if comment.Status != StatusPublished && comment.UserID != userID {
+		return api.NotFound(ctx, "Comment not found")
+	}
+	return api.Success(ctx, comment)
+}
+
+// Create posts a comment or reply
+// @Summary Create comment
+// @Description Post a comment on an entity. The comment may be held for moderation.
+// @Tags Comments
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param comment body CreateInput true "Comment"
+// @Success 201 {object} api.Response{data=Comment}
+// @Failure 400 {object} api.Response
+// @Router /comments [post]
+func (c *Controller) Create(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input CreateInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	comment, err := c.service.Create(ctx.Context(), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	message := "Comment posted"
+	if comment.Status != StatusPublished {
+		message = "Comment submitted for moderation"
+	}
+	return api.Created(ctx, message, comment)
+}
+
+// Update edits the caller's own comment
+// @Summary Update comment
+// @Tags Comments
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Comment ID"
+// @Param comment body UpdateInput true "Comment"
+// @Success 200 {object} api.Response{data=Comment}
+// @Failure 403 {object} api.Response
+// @Router /comments/{id} [put]
+func (c *Controller) Update(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid comment ID", nil)
+	}
+
+	var input UpdateInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	comment, err := c.service.Update(ctx.Context(), uint(id), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, comment)
+}
+
+// Delete deletes the caller's own comment
+// @Summary Delete comment
+// @Tags Comments
+// @Security BearerAuth
+// @Param id path int true "Comment ID"
+// @Success 204 "No Content"
+// @Router /comments/{id} [delete]
+func (c *Controller) Delete(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid comment ID", nil)
+	}
+
+	if err := c.service.Delete(ctx.Context(), uint(id), userID); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.NoContent(ctx)
+}
+
+// React adds a reaction to a comment
+// @Summary React to comment
+// @Tags Comments
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Comment ID"
+// @Param type path string true "Reaction type"
+// @Success 200 {object} api.Response{data=map[string]int64}
+// @Router /comments/{id}/reactions/{type} [post]
+func (c *Controller) React(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid comment ID", nil)
+	}
+
+	counts, err := c.service.React(ctx.Context(), uint(id), userID, ctx.Params("type"))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, counts)
+}
+
+// Unreact removes a reaction from a comment
+// @Summary Remove reaction
+// @Tags Comments
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Comment ID"
+// @Param type path string true "Reaction type"
+// @Success 200 {object} api.Response{data=map[string]int64}
+// @Router /comments/{id}/reactions/{type} [delete]
+func (c *Controller) Unreact(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid comment ID", nil)
+	}
+
+	counts, err := c.service.Unreact(ctx.Context(), uint(id), userID, ctx.Params("type"))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, counts)
+}
+
+// ModerationQueue lists pending and flagged comments
+// @Summary Moderation queue
+// @Tags Comments
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]Comment}
+// @Router /comments/moderation [get]
+func (c *Controller) ModerationQueue(ctx *fiber.Ctx) error {
+	pagination := api.GetPagination(ctx)
+
+	comments, total, err := c.service.ModerationQueue(ctx.Context(), pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, comments, pagination.Page, pagination.Limit, total)
+}
+
+// Moderate publishes, hides or rejects a comment
+// @Summary Moderate comment
+// @Tags Comments
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Comment ID"
+// @Param decision body ModerateInput true "Decision"
+// @Success 200 {object} api.Response{data=Comment}
+// @Router /comments/{id}/moderate [post]
+func (c *Controller) Moderate(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid comment ID", nil)
+	}
+
+	var input ModerateInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	comment, err := c.service.Moderate(ctx.Context(), uint(id), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Comment moderated", comment)
+}
@@ -0,0 +1,47 @@
+package comments
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/ai"
+
+	"gorm.io/gorm"
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Service with moderation hooks
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		config.RequireApproval = os.Getenv("COMMENTS_REQUIRE_APPROVAL") == "true"
+
+		service := NewService(core.Resolve[*Repository](container), config)
+
+		// Keyword guardrail
+		if terms := os.Getenv("COMMENTS_BLOCKED_TERMS"); terms != "" {
+			service.AddModerator(NewKeywordModerator(strings.Split(terms, ",")...))
+		}
+
+		// AI toxicity guardrail (requires a model loaded in the AI model manager)
+		if modelID := os.Getenv("COMMENTS_MODERATION_MODEL"); modelID != "" {
+			if manager := core.Resolve[*ai.ModelManager](container); manager != nil {
+				threshold, _ := strconv.ParseFloat(os.Getenv("COMMENTS_TOXICITY_THRESHOLD"), 64)
+				service.AddModerator(NewAIModerator(manager, modelID, threshold))
+			}
+		}
+
+		return service
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
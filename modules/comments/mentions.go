@@ -0,0 +1,26 @@
+package comments
+
+import "regexp"
+
+// mentionRegex matches @username where username follows the validation
+// package's username rule (3-20 alphanumeric characters or underscore)
+var mentionRegex = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_]{3,20})\b`)
+
+// ParseMentions returns the unique usernames mentioned in body, in order
+// of first appearance
+func ParseMentions(body string) []string {
+	matches := mentionRegex.FindAllStringSubmatch(body, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(matches))
+	usernames := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			usernames = append(usernames, m[1])
+		}
+	}
+	return usernames
+}
@@ -0,0 +1,77 @@
+package comments
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Comment moderation states
+const (
+	StatusPending   = "pending"   // Awaiting moderation
+	StatusPublished = "published" // Visible to everyone
+	StatusFlagged   = "flagged"   // Held by an automatic check
+	StatusHidden    = "hidden"    // Hidden by a moderator
+	StatusRejected  = "rejected"  // Rejected by a moderator
+)
+
+// Comment is a threaded comment attached to any entity. The target is
+// referenced polymorphically by EntityType ("cms.content", "product", ...)
+// and EntityID.
+type Comment struct {
+	ID               uint           `gorm:"primarykey" json:"id"`
+	EntityType       string         `gorm:"size:100;not null;index:idx_comments_entity" json:"entity_type"`
+	EntityID         string         `gorm:"size:100;not null;index:idx_comments_entity" json:"entity_id"`
+	ParentID         *uint          `gorm:"index" json:"parent_id,omitempty"`
+	RootID           *uint          `gorm:"index" json:"root_id,omitempty"`
+	Depth            int            `gorm:"default:0" json:"depth"`
+	UserID           uint           `gorm:"not null;index" json:"user_id"`
+	Body             string         `gorm:"type:text;not null" json:"body"`
+	Status           string         `gorm:"size:20;default:'pending';index" json:"status"`
+	ModerationScore  float64        `gorm:"default:0" json:"moderation_score"`
+	ModerationReason string         `gorm:"size:500" json:"moderation_reason,omitempty"`
+	ModeratedBy      *uint          `json:"moderated_by,omitempty"`
+	ModeratedAt      *time.Time     `json:"moderated_at,omitempty"`
+	ReplyCount       int            `gorm:"default:0" json:"reply_count"`
+	Edited           bool           `gorm:"default:false" json:"edited"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Mentions  []CommentMention `gorm:"foreignKey:CommentID" json:"mentions,omitempty"`
+	Reactions map[string]int64 `gorm:"-" json:"reactions,omitempty"`
+	Replies   []*Comment       `gorm:"-" json:"replies,omitempty"`
+}
+
+// TableName specifies the table name for Comment
+func (Comment) TableName() string {
+	return "comments"
+}
+
+// CommentMention records an @username mention in a comment
+type CommentMention struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CommentID uint      `gorm:"not null;uniqueIndex:idx_comment_mention" json:"comment_id"`
+	Username  string    `gorm:"size:50;not null;uniqueIndex:idx_comment_mention" json:"username"`
+	UserID    *uint     `gorm:"index" json:"user_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for CommentMention
+func (CommentMention) TableName() string {
+	return "comment_mentions"
+}
+
+// CommentReaction is a single user's reaction to a comment
+type CommentReaction struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CommentID uint      `gorm:"not null;uniqueIndex:idx_comment_reaction" json:"comment_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_comment_reaction" json:"user_id"`
+	Type      string    `gorm:"size:30;not null;uniqueIndex:idx_comment_reaction" json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for CommentReaction
+func (CommentReaction) TableName() string {
+	return "comment_reactions"
+}
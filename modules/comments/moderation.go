@@ -0,0 +1,166 @@
+package comments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"neonexcore/pkg/ai"
+)
+
+// ModerationResult is the verdict of a Moderator
+type ModerationResult struct {
+	Flagged    bool     `json:"flagged"`
+	Score      float64  `json:"score"` // 0 (clean) to 1 (certainly toxic)
+	Categories []string `json:"categories,omitempty"`
+	Reason     string   `json:"reason,omitempty"`
+}
+
+// Moderator inspects a comment before it is published. Moderators run in
+// registration order; the first flagged result holds the comment.
+type Moderator interface {
+	Moderate(ctx context.Context, comment *Comment) (*ModerationResult, error)
+}
+
+// ModeratorFunc adapts a function to the Moderator interface
+type ModeratorFunc func(ctx context.Context, comment *Comment) (*ModerationResult, error)
+
+// Moderate calls f(ctx, comment)
+func (f ModeratorFunc) Moderate(ctx context.Context, comment *Comment) (*ModerationResult, error) {
+	return f(ctx, comment)
+}
+
+// KeywordModerator flags comments containing blocked terms
+type KeywordModerator struct {
+	terms []string
+}
+
+// NewKeywordModerator creates a keyword moderator (case-insensitive)
+func NewKeywordModerator(terms ...string) *KeywordModerator {
+	m := &KeywordModerator{}
+	for _, term := range terms {
+		if term = strings.TrimSpace(strings.ToLower(term)); term != "" {
+			m.terms = append(m.terms, term)
+		}
+	}
+	return m
+}
+
+// Moderate implements Moderator
+func (m *KeywordModerator) Moderate(ctx context.Context, comment *Comment) (*ModerationResult, error) {
+	body := strings.ToLower(comment.Body)
+	for _, term := range m.terms {
+		if strings.Contains(body, term) {
+			return &ModerationResult{
+				Flagged:    true,
+				Score:      1,
+				Categories: []string{"blocked_term"},
+				Reason:     "contains blocked term",
+			}, nil
+		}
+	}
+	return &ModerationResult{}, nil
+}
+
+const aiModerationPrompt = `You are a content moderation classifier. Rate the toxicity of the user's comment.
+Respond with JSON only: {"score": <number between 0 and 1>, "categories": [<zero or more of "harassment", "hate", "sexual", "violence", "self_harm", "spam">]}`
+
+// AIModerator scores toxicity with a chat model loaded in an ai.ModelManager
+type AIModerator struct {
+	manager   *ai.ModelManager
+	modelID   string
+	threshold float64
+}
+
+// NewAIModerator creates an AI guardrail. Comments scoring at or above
+// threshold are flagged.
+func NewAIModerator(manager *ai.ModelManager, modelID string, threshold float64) *AIModerator {
+	if threshold <= 0 || threshold > 1 {
+		threshold = 0.8
+	}
+	return &AIModerator{
+		manager:   manager,
+		modelID:   modelID,
+		threshold: threshold,
+	}
+}
+
+// Moderate implements Moderator
+func (m *AIModerator) Moderate(ctx context.Context, comment *Comment) (*ModerationResult, error) {
+	output, err := m.manager.Predict(ctx, &ai.InferenceInput{
+		ModelID: m.modelID,
+		Data:    comment.Body,
+		Parameters: map[string]interface{}{
+			"type":        "chat",
+			"system":      aiModerationPrompt,
+			"temperature": 0,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("toxicity check failed: %w", err)
+	}
+
+	result, err := parseModelVerdict(output.Result)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Flagged = result.Score >= m.threshold
+	if result.Flagged {
+		result.Reason = fmt.Sprintf("toxicity score %.2f", result.Score)
+	}
+	return result, nil
+}
+
+// parseModelVerdict extracts a verdict from a provider result. Plain
+// scores, verdict maps and OpenAI-style chat responses are accepted.
+func parseModelVerdict(raw interface{}) (*ModerationResult, error) {
+	switch v := raw.(type) {
+	case float64:
+		return &ModerationResult{Score: v}, nil
+	case string:
+		return decodeVerdict(v)
+	case map[string]interface{}:
+		if score, ok := v["score"].(float64); ok {
+			return &ModerationResult{Score: score, Categories: toStrings(v["categories"])}, nil
+		}
+		if choices, ok := v["choices"].([]interface{}); ok && len(choices) > 0 {
+			choice, _ := choices[0].(map[string]interface{})
+			message, _ := choice["message"].(map[string]interface{})
+			if content, ok := message["content"].(string); ok {
+				return decodeVerdict(content)
+			}
+		}
+	}
+	return nil, fmt.Errorf("unexpected moderation model output: %T", raw)
+}
+
+func decodeVerdict(content string) (*ModerationResult, error) {
+	content = strings.TrimSpace(content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.Trim(content, "` \n")
+
+	var verdict struct {
+		Score      float64  `json:"score"`
+		Categories []string `json:"categories"`
+	}
+	if err := json.Unmarshal([]byte(content), &verdict); err != nil {
+		return nil, fmt.Errorf("invalid moderation verdict: %w", err)
+	}
+	return &ModerationResult{Score: verdict.Score, Categories: verdict.Categories}, nil
+}
+
+func toStrings(v interface{}) []string {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
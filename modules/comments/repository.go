@@ -0,0 +1,198 @@
+package comments
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// WithTx returns a repository bound to a transaction
+func (r *Repository) WithTx(tx *gorm.DB) *Repository {
+	return &Repository{db: tx}
+}
+
+// Transaction runs fn inside a database transaction
+func (r *Repository) Transaction(ctx context.Context, fn func(repo *Repository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(r.WithTx(tx))
+	})
+}
+
+// ==================== Comments ====================
+
+func (r *Repository) FindByID(ctx context.Context, id uint) (*Comment, error) {
+	var comment Comment
+	err := r.db.WithContext(ctx).Preload("Mentions").First(&comment, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// ListRoots returns top-level comments for an entity, newest first
+func (r *Repository) ListRoots(ctx context.Context, entityType, entityID string, statuses []string, page, limit int) ([]*Comment, int64, error) {
+	var comments []*Comment
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Comment{}).
+		Where("entity_type = ? AND entity_id = ? AND parent_id IS NULL", entityType, entityID).
+		Where("status IN ?", statuses)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Preload("Mentions").Order("created_at DESC").Offset(offset).Limit(limit).Find(&comments).Error
+	return comments, total, err
+}
+
+// ListThreads returns every reply under the given root comments, oldest first
+func (r *Repository) ListThreads(ctx context.Context, rootIDs []uint, statuses []string) ([]*Comment, error) {
+	var comments []*Comment
+	if len(rootIDs) == 0 {
+		return comments, nil
+	}
+	err := r.db.WithContext(ctx).Preload("Mentions").
+		Where("root_id IN ? AND status IN ?", rootIDs, statuses).
+		Order("created_at ASC").
+		Find(&comments).Error
+	return comments, err
+}
+
+// ListByStatus returns comments in the given statuses, oldest first
+func (r *Repository) ListByStatus(ctx context.Context, statuses []string, page, limit int) ([]*Comment, int64, error) {
+	var comments []*Comment
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Comment{}).Where("status IN ?", statuses)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Preload("Mentions").Order("created_at ASC").Offset(offset).Limit(limit).Find(&comments).Error
+	return comments, total, err
+}
+
+func (r *Repository) Create(ctx context.Context, comment *Comment) error {
+	return r.db.WithContext(ctx).Omit("Mentions").Create(comment).Error
+}
+
+func (r *Repository) Update(ctx context.Context, comment *Comment) error {
+	return r.db.WithContext(ctx).Omit("Mentions").Save(comment).Error
+}
+
+func (r *Repository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&Comment{}, id).Error
+}
+
+// AdjustReplyCount increments or decrements a comment's reply count
+func (r *Repository) AdjustReplyCount(ctx context.Context, id uint, delta int) error {
+	return r.db.WithContext(ctx).Model(&Comment{}).Where("id = ?", id).
+		UpdateColumn("reply_count", gorm.Expr("reply_count + ?", delta)).Error
+}
+
+// ==================== Mentions ====================
+
+// ReplaceMentions replaces the mentions recorded for a comment
+func (r *Repository) ReplaceMentions(ctx context.Context, commentID uint, mentions []CommentMention) error {
+	if err := r.db.WithContext(ctx).Where("comment_id = ?", commentID).Delete(&CommentMention{}).Error; err != nil {
+		return err
+	}
+	if len(mentions) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Create(&mentions).Error
+}
+
+// ResolveUsernames maps usernames to user IDs; unknown names are omitted
+func (r *Repository) ResolveUsernames(ctx context.Context, usernames []string) (map[string]uint, error) {
+	result := make(map[string]uint, len(usernames))
+	if len(usernames) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		ID       uint
+		Username string
+	}
+	err := r.db.WithContext(ctx).Table("users").
+		Select("id, username").
+		Where("username IN ? AND deleted_at IS NULL", usernames).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		result[row.Username] = row.ID
+	}
+	return result, nil
+}
+
+// ==================== Reactions ====================
+
+// AddReaction records a reaction; it is a no-op if it already exists
+func (r *Repository) AddReaction(ctx context.Context, reaction *CommentReaction) error {
+	var existing CommentReaction
+	err := r.db.WithContext(ctx).
+		Where("comment_id = ? AND user_id = ? AND type = ?", reaction.CommentID, reaction.UserID, reaction.Type).
+		First(&existing).Error
+	if err == nil {
+		*reaction = existing
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return r.db.WithContext(ctx).Create(reaction).Error
+}
+
+func (r *Repository) RemoveReaction(ctx context.Context, commentID, userID uint, reactionType string) error {
+	return r.db.WithContext(ctx).
+		Where("comment_id = ? AND user_id = ? AND type = ?", commentID, userID, reactionType).
+		Delete(&CommentReaction{}).Error
+}
+
+// ReactionCounts returns reaction counts per type for each comment
+func (r *Repository) ReactionCounts(ctx context.Context, commentIDs []uint) (map[uint]map[string]int64, error) {
+	counts := make(map[uint]map[string]int64)
+	if len(commentIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		CommentID uint
+		Type      string
+		Count     int64
+	}
+	err := r.db.WithContext(ctx).Model(&CommentReaction{}).
+		Select("comment_id, type, COUNT(*) AS count").
+		Where("comment_id IN ?", commentIDs).
+		Group("comment_id, type").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if counts[row.CommentID] == nil {
+			counts[row.CommentID] = make(map[string]int64)
+		}
+		counts[row.CommentID][row.Type] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,38 @@
+package comments
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	comments := router.Group("/comments")
+
+	// Moderation (registered before /:id)
+	moderation := comments.Group("/moderation",
+		auth.AuthMiddleware(jwtManager),
+		rbac.RequirePermission(rbacManager, "comments.moderate"),
+	)
+	moderation.Get("", controller.ModerationQueue)
+
+	// Public read access
+	comments.Get("", controller.List)
+	comments.Get("/:id", controller.Get)
+
+	// Authenticated actions
+	protected := comments.Group("", auth.AuthMiddleware(jwtManager))
+	protected.Post("", controller.Create)
+	protected.Put("/:id", controller.Update)
+	protected.Delete("/:id", controller.Delete)
+	protected.Post("/:id/reactions/:type", controller.React)
+	protected.Delete("/:id/reactions/:type", controller.Unreact)
+	protected.Post("/:id/moderate", rbac.RequirePermission(rbacManager, "comments.moderate"), controller.Moderate)
+}
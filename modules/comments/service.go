@@ -0,0 +1,501 @@
+package comments
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+)
+
+// Comment event names
+const (
+	EventCommentCreated   = "comments.created"
+	EventCommentFlagged   = "comments.flagged"
+	EventCommentModerated = "comments.moderated"
+	EventUserMentioned    = "comments.mentioned"
+)
+
+// Config holds comment service configuration
+type Config struct {
+	MaxDepth         int      // Maximum reply nesting depth (0 = top level only)
+	MaxLength        int      // Maximum body length in characters
+	RequireApproval  bool     // Hold every comment for manual approval
+	FailOpen         bool     // Publish when a moderator errors instead of holding
+	AllowedReactions []string // Accepted reaction types
+}
+
+// DefaultConfig returns default comment configuration
+func DefaultConfig() Config {
+	return Config{
+		MaxDepth:         5,
+		MaxLength:        5000,
+		AllowedReactions: []string{"like", "love", "laugh", "insightful", "sad", "angry"},
+	}
+}
+
+// CreateInput is the payload for posting a comment
+type CreateInput struct {
+	EntityType string `json:"entity_type" validate:"required,max=100"`
+	EntityID   string `json:"entity_id" validate:"required,max=100"`
+	ParentID   *uint  `json:"parent_id"`
+	Body       string `json:"body" validate:"required"`
+}
+
+// UpdateInput is the payload for editing a comment
+type UpdateInput struct {
+	Body string `json:"body" validate:"required"`
+}
+
+// ModerateInput is the payload for a moderator decision
+type ModerateInput struct {
+	Status string `json:"status" validate:"required,oneof=published hidden rejected"`
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+type Service struct {
+	repo       *Repository
+	config     Config
+	moderators []Moderator
+	reactions  map[string]bool
+}
+
+func NewService(repo *Repository, config Config) *Service {
+	s := &Service{
+		repo:      repo,
+		config:    config,
+		reactions: make(map[string]bool, len(config.AllowedReactions)),
+	}
+	for _, r := range config.AllowedReactions {
+		s.reactions[r] = true
+	}
+	return s
+}
+
+// AddModerator registers a moderation hook run before publication
+func (s *Service) AddModerator(m Moderator) {
+	s.moderators = append(s.moderators, m)
+}
+
+// List returns published top-level comments for an entity with their
+// published replies nested beneath them
+func (s *Service) List(ctx context.Context, entityType, entityID string, page, limit int) ([]*Comment, int64, error) {
+	visible := []string{StatusPublished}
+
+	roots, total, err := s.repo.ListRoots(ctx, entityType, entityID, visible, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to load comments").WithError(err)
+	}
+
+	rootIDs := make([]uint, len(roots))
+	for i, root := range roots {
+		rootIDs[i] = root.ID
+	}
+
+	replies, err := s.repo.ListThreads(ctx, rootIDs, visible)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to load replies").WithError(err)
+	}
+
+	all := append(append([]*Comment{}, roots...), replies...)
+	if err := s.attachReactions(ctx, all); err != nil {
+		return nil, 0, err
+	}
+
+	// Nest replies under their parents. Replies are ordered oldest first,
+	// so parents are always indexed before their children.
+	byID := make(map[uint]*Comment, len(all))
+	for _, c := range roots {
+		byID[c.ID] = c
+	}
+	for _, reply := range replies {
+		byID[reply.ID] = reply
+		if parent, ok := byID[*reply.ParentID]; ok {
+			parent.Replies = append(parent.Replies, reply)
+		}
+	}
+
+	return roots, total, nil
+}
+
+func (s *Service) Get(ctx context.Context, id uint) (*Comment, error) {
+	comment, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load comment").WithError(err)
+	}
+	if comment == nil {
+		return nil, errors.NewNotFound("Comment not found")
+	}
+	if err := s.attachReactions(ctx, []*Comment{comment}); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+func (s *Service) Create(ctx context.Context, input *CreateInput, userID uint) (*Comment, error) {
+	body, err := s.cleanBody(input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &Comment{
+		EntityType: input.EntityType,
+		EntityID:   input.EntityID,
+		UserID:     userID,
+		Body:       body,
+	}
+
+	if input.ParentID != nil {
+		parent, err := s.repo.FindByID(ctx, *input.ParentID)
+		if err != nil {
+			return nil, errors.NewInternal("Failed to load parent comment").WithError(err)
+		}
+		if parent == nil || parent.Status != StatusPublished {
+			return nil, errors.NewNotFound("Parent comment not found")
+		}
+		if parent.EntityType != input.EntityType || parent.EntityID != input.EntityID {
+			return nil, errors.NewBadRequest("Parent comment belongs to a different entity")
+		}
+		if parent.Depth+1 > s.config.MaxDepth {
+			return nil, errors.NewBadRequest("Maximum reply depth reached")
+		}
+
+		rootID := parent.ID
+		if parent.RootID != nil {
+			rootID = *parent.RootID
+		}
+		comment.ParentID = &parent.ID
+		comment.RootID = &rootID
+		comment.Depth = parent.Depth + 1
+	}
+
+	s.moderate(ctx, comment)
+
+	err = s.repo.Transaction(ctx, func(repo *Repository) error {
+		if err := repo.Create(ctx, comment); err != nil {
+			return err
+		}
+		if err := s.syncMentions(ctx, repo, comment); err != nil {
+			return err
+		}
+		if comment.Status == StatusPublished && comment.ParentID != nil {
+			return repo.AdjustReplyCount(ctx, *comment.ParentID, 1)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.NewInternal("Failed to create comment").WithError(err)
+	}
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventCommentCreated,
+		Data: map[string]interface{}{
+			"comment_id":  comment.ID,
+			"entity_type": comment.EntityType,
+			"entity_id":   comment.EntityID,
+			"parent_id":   comment.ParentID,
+			"user_id":     comment.UserID,
+			"status":      comment.Status,
+		},
+	})
+	s.notifyAfterModeration(ctx, comment)
+
+	return comment, nil
+}
+
+func (s *Service) Update(ctx context.Context, id uint, input *UpdateInput, userID uint) (*Comment, error) {
+	comment, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if comment.UserID != userID {
+		return nil, errors.NewForbidden("You can only edit your own comments")
+	}
+	if comment.Status == StatusHidden || comment.Status == StatusRejected {
+		return nil, errors.NewForbidden("Moderated comments cannot be edited")
+	}
+
+	body, err := s.cleanBody(input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	wasPublished := comment.Status == StatusPublished
+	comment.Body = body
+	comment.Edited = true
+	s.moderate(ctx, comment)
+
+	err = s.repo.Transaction(ctx, func(repo *Repository) error {
+		if err := repo.Update(ctx, comment); err != nil {
+			return err
+		}
+		if err := s.syncMentions(ctx, repo, comment); err != nil {
+			return err
+		}
+		return s.adjustParent(ctx, repo, comment, wasPublished)
+	})
+	if err != nil {
+		return nil, errors.NewInternal("Failed to update comment").WithError(err)
+	}
+
+	if !wasPublished || comment.Status != StatusPublished {
+		s.notifyAfterModeration(ctx, comment)
+	}
+	return comment, nil
+}
+
+func (s *Service) Delete(ctx context.Context, id uint, userID uint) error {
+	comment, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return errors.NewInternal("Failed to load comment").WithError(err)
+	}
+	if comment == nil {
+		return errors.NewNotFound("Comment not found")
+	}
+	if comment.UserID != userID {
+		return errors.NewForbidden("You can only delete your own comments")
+	}
+
+	err = s.repo.Transaction(ctx, func(repo *Repository) error {
+		if err := repo.Delete(ctx, comment.ID); err != nil {
+			return err
+		}
+		if comment.Status == StatusPublished && comment.ParentID != nil {
+			return repo.AdjustReplyCount(ctx, *comment.ParentID, -1)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.NewInternal("Failed to delete comment").WithError(err)
+	}
+	return nil
+}
+
+// ==================== Reactions ====================
+
+func (s *Service) React(ctx context.Context, id uint, userID uint, reactionType string) (map[string]int64, error) {
+	if !s.reactions[reactionType] {
+		return nil, errors.NewBadRequest("Unsupported reaction type")
+	}
+
+	comment, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if comment.Status != StatusPublished {
+		return nil, errors.NewNotFound("Comment not found")
+	}
+
+	reaction := &CommentReaction{CommentID: id, UserID: userID, Type: reactionType}
+	if err := s.repo.AddReaction(ctx, reaction); err != nil {
+		return nil, errors.NewInternal("Failed to add reaction").WithError(err)
+	}
+	return s.reactionCounts(ctx, id)
+}
+
+func (s *Service) Unreact(ctx context.Context, id uint, userID uint, reactionType string) (map[string]int64, error) {
+	if err := s.repo.RemoveReaction(ctx, id, userID, reactionType); err != nil {
+		return nil, errors.NewInternal("Failed to remove reaction").WithError(err)
+	}
+	return s.reactionCounts(ctx, id)
+}
+
+// ==================== Moderation ====================
+
+// ModerationQueue returns comments awaiting a moderator decision
+func (s *Service) ModerationQueue(ctx context.Context, page, limit int) ([]*Comment, int64, error) {
+	comments, total, err := s.repo.ListByStatus(ctx, []string{StatusFlagged, StatusPending}, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to load moderation queue").WithError(err)
+	}
+	return comments, total, nil
+}
+
+// Moderate applies a moderator decision to a comment
+func (s *Service) Moderate(ctx context.Context, id uint, input *ModerateInput, moderatorID uint) (*Comment, error) {
+	comment, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	wasPublished := comment.Status == StatusPublished
+	now := time.Now()
+	comment.Status = input.Status
+	comment.ModerationReason = input.Reason
+	comment.ModeratedBy = &moderatorID
+	comment.ModeratedAt = &now
+
+	err = s.repo.Transaction(ctx, func(repo *Repository) error {
+		if err := repo.Update(ctx, comment); err != nil {
+			return err
+		}
+		return s.adjustParent(ctx, repo, comment, wasPublished)
+	})
+	if err != nil {
+		return nil, errors.NewInternal("Failed to moderate comment").WithError(err)
+	}
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventCommentModerated,
+		Data: map[string]interface{}{
+			"comment_id":   comment.ID,
+			"status":       comment.Status,
+			"reason":       comment.ModerationReason,
+			"moderator_id": moderatorID,
+		},
+	})
+	if !wasPublished && comment.Status == StatusPublished {
+		s.notifyMentions(ctx, comment)
+	}
+
+	return comment, nil
+}
+
+// ==================== Helpers ====================
+
+// cleanBody trims and length-checks a comment body
+func (s *Service) cleanBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", errors.NewBadRequest("Comment body is required")
+	}
+	if s.config.MaxLength > 0 && len([]rune(body)) > s.config.MaxLength {
+		return "", errors.NewBadRequest("Comment body is too long")
+	}
+	return body, nil
+}
+
+// moderate runs the moderation hooks and sets the comment status
+func (s *Service) moderate(ctx context.Context, comment *Comment) {
+	comment.Status = StatusPublished
+	comment.ModerationScore = 0
+	comment.ModerationReason = ""
+
+	for _, m := range s.moderators {
+		result, err := m.Moderate(ctx, comment)
+		if err != nil {
+			if !s.config.FailOpen {
+				comment.Status = StatusPending
+				comment.ModerationReason = "automatic moderation unavailable"
+				return
+			}
+			continue
+		}
+		if result.Score > comment.ModerationScore {
+			comment.ModerationScore = result.Score
+		}
+		if result.Flagged {
+			comment.Status = StatusFlagged
+			comment.ModerationReason = result.Reason
+			return
+		}
+	}
+
+	if s.config.RequireApproval {
+		comment.Status = StatusPending
+	}
+}
+
+// syncMentions records the users mentioned in a comment
+func (s *Service) syncMentions(ctx context.Context, repo *Repository, comment *Comment) error {
+	usernames := ParseMentions(comment.Body)
+
+	ids, err := repo.ResolveUsernames(ctx, usernames)
+	if err != nil {
+		return err
+	}
+
+	mentions := make([]CommentMention, 0, len(usernames))
+	for _, username := range usernames {
+		mention := CommentMention{CommentID: comment.ID, Username: username}
+		if id, ok := ids[username]; ok {
+			mention.UserID = &id
+		}
+		mentions = append(mentions, mention)
+	}
+
+	if err := repo.ReplaceMentions(ctx, comment.ID, mentions); err != nil {
+		return err
+	}
+	comment.Mentions = mentions
+	return nil
+}
+
+// adjustParent keeps the parent's reply count in step with visibility
+func (s *Service) adjustParent(ctx context.Context, repo *Repository, comment *Comment, wasPublished bool) error {
+	if comment.ParentID == nil {
+		return nil
+	}
+	isPublished := comment.Status == StatusPublished
+	switch {
+	case isPublished && !wasPublished:
+		return repo.AdjustReplyCount(ctx, *comment.ParentID, 1)
+	case !isPublished && wasPublished:
+		return repo.AdjustReplyCount(ctx, *comment.ParentID, -1)
+	}
+	return nil
+}
+
+// notifyAfterModeration dispatches flag or mention events
+func (s *Service) notifyAfterModeration(ctx context.Context, comment *Comment) {
+	switch comment.Status {
+	case StatusFlagged:
+		events.DispatchAsync(ctx, events.Event{
+			Name: EventCommentFlagged,
+			Data: map[string]interface{}{
+				"comment_id": comment.ID,
+				"score":      comment.ModerationScore,
+				"reason":     comment.ModerationReason,
+			},
+		})
+	case StatusPublished:
+		s.notifyMentions(ctx, comment)
+	}
+}
+
+// notifyMentions dispatches a mention event for each known mentioned user
+func (s *Service) notifyMentions(ctx context.Context, comment *Comment) {
+	for _, mention := range comment.Mentions {
+		if mention.UserID == nil || *mention.UserID == comment.UserID {
+			continue
+		}
+		events.DispatchAsync(ctx, events.Event{
+			Name: EventUserMentioned,
+			Data: map[string]interface{}{
+				"comment_id":  comment.ID,
+				"user_id":     *mention.UserID,
+				"author_id":   comment.UserID,
+				"entity_type": comment.EntityType,
+				"entity_id":   comment.EntityID,
+			},
+		})
+	}
+}
+
+func (s *Service) attachReactions(ctx context.Context, comments []*Comment) error {
+	ids := make([]uint, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+	}
+
+	counts, err := s.repo.ReactionCounts(ctx, ids)
+	if err != nil {
+		return errors.NewInternal("Failed to load reactions").WithError(err)
+	}
+	for _, c := range comments {
+		c.Reactions = counts[c.ID]
+	}
+	return nil
+}
+
+func (s *Service) reactionCounts(ctx context.Context, id uint) (map[string]int64, error) {
+	counts, err := s.repo.ReactionCounts(ctx, []uint{id})
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load reactions").WithError(err)
+	}
+	if counts[id] == nil {
+		return map[string]int64{}, nil
+	}
+	return counts[id], nil
+}
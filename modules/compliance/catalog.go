@@ -0,0 +1,52 @@
+package compliance
+
+// Personal data categories
+const (
+	CategoryIdentity = "identity" // Account and credential details
+	CategoryActivity = "activity" // Sign-ins, devices and risk checks
+	CategoryContent  = "content"  // What the user wrote or submitted
+	CategoryConsent  = "consent"  // Policy acceptances and consents
+)
+
+// DataSource is a table holding personal data. Rows belong to a user by
+// UserColumn, or by EmailColumn for tables keyed by address.
+type DataSource struct {
+	Name        string   `json:"name"` // File name in export archives
+	Table       string   `json:"table"`
+	UserColumn  string   `json:"user_column,omitempty"`
+	EmailColumn string   `json:"email_column,omitempty"`
+	Category    string   `json:"category"`
+	Description string   `json:"description"`
+	Exclude     []string `json:"exclude,omitempty"` // Never exported, e.g. secrets and hashes
+}
+
+// DefaultCatalog lists the tables of the platform's modules that hold
+// personal data. Tables of disabled modules are skipped at export time.
+func DefaultCatalog() []DataSource {
+	return []DataSource{
+		{Name: "account", Table: "users", UserColumn: "id", Category: CategoryIdentity, Description: "Account profile",
+			Exclude: []string{"password", "password_reset_token", "password_reset_expiry", "api_key", "deleted_at"}},
+		{Name: "roles", Table: "user_roles", UserColumn: "user_id", Category: CategoryIdentity, Description: "Assigned roles"},
+		{Name: "passkeys", Table: "passkey_credentials", UserColumn: "user_id", Category: CategoryIdentity, Description: "Registered passkeys",
+			Exclude: []string{"public_key", "sign_count"}},
+		{Name: "api_keys", Table: "portal_api_keys", UserColumn: "user_id", Category: CategoryIdentity, Description: "Developer API keys",
+			Exclude: []string{"key_hash"}},
+		{Name: "webhooks", Table: "portal_webhooks", UserColumn: "user_id", Category: CategoryIdentity, Description: "Webhook subscriptions",
+			Exclude: []string{"secret"}},
+		{Name: "vault_secrets", Table: "vault_secrets", UserColumn: "owner_id", Category: CategoryIdentity, Description: "Names of stored secrets",
+			Exclude: []string{"ciphertext", "nonce"}},
+		{Name: "security_events", Table: "security_events", UserColumn: "user_id", Category: CategoryActivity, Description: "Sign-ins and account changes"},
+		{Name: "devices", Table: "security_devices", UserColumn: "user_id", Category: CategoryActivity, Description: "Devices signed in from",
+			Exclude: []string{"fingerprint"}},
+		{Name: "audit_log", Table: "audit_logs", UserColumn: "user_id", Category: CategoryActivity, Description: "Administrative actions"},
+		{Name: "risk_assessments", Table: "risk_assessments", UserColumn: "user_id", Category: CategoryActivity, Description: "Fraud and risk checks"},
+		{Name: "comments", Table: "comments", UserColumn: "user_id", Category: CategoryContent, Description: "Comments"},
+		{Name: "reactions", Table: "comment_reactions", UserColumn: "user_id", Category: CategoryContent, Description: "Comment reactions"},
+		{Name: "form_submissions", Table: "form_submissions", UserColumn: "user_id", Category: CategoryContent, Description: "Form responses"},
+		{Name: "moderation", Table: "moderation_items", UserColumn: "user_id", Category: CategoryContent, Description: "Moderated content"},
+		{Name: "links", Table: "links", UserColumn: "created_by", Category: CategoryContent, Description: "Short links"},
+		{Name: "status_subscriptions", Table: "status_subscribers", EmailColumn: "email", Category: CategoryContent, Description: "Status page subscriptions",
+			Exclude: []string{"token"}},
+		{Name: "acceptances", Table: "compliance_acceptances", UserColumn: "user_id", Category: CategoryConsent, Description: "Accepted policies and consents"},
+	}
+}
@@ -0,0 +1,32 @@
+package compliance
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ComplianceModule struct{}
+
+func New() *ComplianceModule {
+	return &ComplianceModule{}
+}
+
+func (m *ComplianceModule) Name() string {
+	return "compliance"
+}
+
+func (m *ComplianceModule) Init() {}
+
+func (m *ComplianceModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *ComplianceModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
+
+func (m *ComplianceModule) Middleware(c *core.Container) []fiber.Handler {
+	return Middleware(c)
+}
@@ -0,0 +1,477 @@
+package compliance
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// RequireAcceptance blocks signed-in users who have not accepted the
+// current required documents. Store errors let requests through, so a
+// failing database cannot lock users out.
+func (c *Controller) RequireAcceptance(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	if userID == 0 || c.service.Exempt(ctx.Path()) {
+		return ctx.Next()
+	}
+
+	pending, err := c.service.Pending(ctx.UserContext(), userID)
+	if err != nil {
+		logger.Error("Compliance check failed", logger.Fields{"user_id": userID, "error": err.Error()})
+		return ctx.Next()
+	}
+	if len(pending) == 0 {
+		return ctx.Next()
+	}
+
+	return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error":     "acceptance_required",
+		"message":   "updated terms must be accepted",
+		"documents": pending,
+	})
+}
+
+// ==================== Documents ====================
+
+// CurrentDocuments returns the current version of each document
+// @Summary Current documents
+// @Description The latest published version of each kind, e.g. terms and privacy
+// @Tags Compliance
+// @Produce json
+// @Success 200 {object} api.Response{data=[]Document}
+// @Router /compliance/documents/current [get]
+func (c *Controller) CurrentDocuments(ctx *fiber.Ctx) error {
+	documents, err := c.service.CurrentDocuments(ctx.UserContext())
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, documents)
+}
+
+// ListDocuments returns all document versions, including drafts
+// @Summary List documents
+// @Tags Compliance
+// @Security BearerAuth
+// @Produce json
+// @Param kind query string false "Document kind"
+// @Success 200 {object} api.Response{data=[]Document}
+// @Router /compliance/documents [get]
+func (c *Controller) ListDocuments(ctx *fiber.Ctx) error {
+	documents, err := c.service.ListDocuments(ctx.UserContext(), ctx.Query("kind"))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, documents)
+}
+
+// GetDocument returns a document version
+// @Summary Get document
+// @Tags Compliance
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Document ID"
+// @Success 200 {object} api.Response{data=Document}
+// @Failure 404 {object} api.Response
+// @Router /compliance/documents/{id} [get]
+func (c *Controller) GetDocument(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid document ID", nil)
+	}
+
+	document, err := c.service.GetDocument(ctx.UserContext(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, document)
+}
+
+// CreateDocument adds a draft document version
+// @Summary Create document
+// @Description Documents are created as drafts and take effect when published
+// @Tags Compliance
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param document body DocumentInput true "Document"
+// @Success 201 {object} api.Response{data=Document}
+// @Failure 409 {object} api.Response
+// @Router /compliance/documents [post]
+func (c *Controller) CreateDocument(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input DocumentInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	document, err := c.service.CreateDocument(ctx.UserContext(), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Document created", document)
+}
+
+// UpdateDocument changes a draft document
+// @Summary Update document
+// @Tags Compliance
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Document ID"
+// @Param document body DocumentInput true "Document"
+// @Success 200 {object} api.Response{data=Document}
+// @Failure 409 {object} api.Response
+// @Router /compliance/documents/{id} [put]
+func (c *Controller) UpdateDocument(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid document ID", nil)
+	}
+
+	var input DocumentInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	document, err := c.service.UpdateDocument(ctx.UserContext(), uint(id), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Document updated", document)
+}
+
+// PublishDocument makes a draft the current version of its kind
+// @Summary Publish document
+// @Description Users must accept a newly published required document before further requests
+// @Tags Compliance
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Document ID"
+// @Success 200 {object} api.Response{data=Document}
+// @Failure 409 {object} api.Response
+// @Router /compliance/documents/{id}/publish [post]
+func (c *Controller) PublishDocument(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid document ID", nil)
+	}
+
+	document, err := c.service.PublishDocument(ctx.UserContext(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Document published", document)
+}
+
+// ==================== Own Acceptance ====================
+
+// Status returns the current documents and whether the caller accepted them
+// @Summary My document status
+// @Tags Compliance
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]DocumentStatus}
+// @Router /compliance/me [get]
+func (c *Controller) Status(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	statuses, err := c.service.Status(ctx.UserContext(), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, statuses)
+}
+
+// Accept records the caller accepting current documents
+// @Summary Accept documents
+// @Description Records the acceptance time, IP address and user agent
+// @Tags Compliance
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body AcceptInput true "Documents"
+// @Success 200 {object} api.Response{data=[]Acceptance}
+// @Failure 400 {object} api.Response
+// @Router /compliance/me/accept [post]
+func (c *Controller) Accept(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input AcceptInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	acceptances, err := c.service.Accept(ctx.UserContext(), userID, &input, ctx.IP(), ctx.Get(fiber.HeaderUserAgent))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Documents accepted", acceptances)
+}
+
+// Withdraw withdraws the caller's consent to an optional document
+// @Summary Withdraw consent
+// @Tags Compliance
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Document ID"
+// @Success 200 {object} api.Response
+// @Failure 400 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /compliance/me/consents/{id} [delete]
+func (c *Controller) Withdraw(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid document ID", nil)
+	}
+
+	if err := c.service.Withdraw(ctx.UserContext(), userID, uint(id)); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Consent withdrawn", nil)
+}
+
+// ==================== Data Exports ====================
+
+// RequestExport queues an export of the caller's data
+// @Summary Request data export
+// @Description Gathers everything held about the caller into a zip archive. The caller is emailed when it is ready.
+// @Tags Compliance
+// @Security BearerAuth
+// @Produce json
+// @Success 201 {object} api.Response{data=DataRequest}
+// @Failure 409 {object} api.Response
+// @Router /compliance/me/exports [post]
+func (c *Controller) RequestExport(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	request, err := c.service.RequestExport(ctx.UserContext(), userID, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Data export requested", request)
+}
+
+// ListMyExports returns the caller's data requests
+// @Summary List my data exports
+// @Tags Compliance
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} api.PaginatedResponse{data=[]DataRequest}
+// @Router /compliance/me/exports [get]
+func (c *Controller) ListMyExports(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	pagination := api.GetPagination(ctx)
+	requests, total, err := c.service.ListDataRequests(ctx.UserContext(), DataRequestFilter{UserID: userID}, pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, requests, pagination.Page, pagination.Limit, total)
+}
+
+// DownloadExport streams one of the caller's ready export archives
+// @Summary Download data export
+// @Tags Compliance
+// @Security BearerAuth
+// @Produce application/zip
+// @Param id path int true "Request ID"
+// @Success 200 {file} file
+// @Failure 404 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /compliance/me/exports/{id}/download [get]
+func (c *Controller) DownloadExport(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid request ID", nil)
+	}
+
+	reader, request, err := c.service.OpenExport(ctx.UserContext(), uint(id), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	ctx.Set(fiber.HeaderContentType, "application/zip")
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="data-export-%d.zip"`, request.ID))
+	return ctx.SendStream(reader, int(request.Size))
+}
+
+// RequestUserExport queues an export of a user's data on their behalf,
+// e.g. for a request received by email
+// @Summary Request data export for a user
+// @Tags Compliance
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 201 {object} api.Response{data=DataRequest}
+// @Failure 409 {object} api.Response
+// @Router /compliance/users/{id}/exports [post]
+func (c *Controller) RequestUserExport(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid user ID", nil)
+	}
+
+	request, err := c.service.RequestExport(ctx.UserContext(), uint(id), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Data export requested", request)
+}
+
+// ListDataRequests returns data requests, newest first, as a record of
+// their fulfillment
+// @Summary List data requests
+// @Tags Compliance
+// @Security BearerAuth
+// @Produce json
+// @Param user_id query int false "User ID"
+// @Param status query string false "Status (pending, processing, ready, failed, expired)"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} api.PaginatedResponse{data=[]DataRequest}
+// @Router /compliance/exports [get]
+func (c *Controller) ListDataRequests(ctx *fiber.Ctx) error {
+	filter := DataRequestFilter{Status: ctx.Query("status")}
+	if userID, err := strconv.ParseUint(ctx.Query("user_id"), 10, 64); err == nil {
+		filter.UserID = uint(userID)
+	}
+
+	pagination := api.GetPagination(ctx)
+	requests, total, err := c.service.ListDataRequests(ctx.UserContext(), filter, pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, requests, pagination.Page, pagination.Limit, total)
+}
+
+// Catalog returns the tables gathered into data exports
+// @Summary Personal data catalog
+// @Tags Compliance
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]DataSource}
+// @Router /compliance/catalog [get]
+func (c *Controller) Catalog(ctx *fiber.Ctx) error {
+	return api.Success(ctx, c.service.Catalog())
+}
+
+// ==================== Audit ====================
+
+// ListAcceptances returns acceptance records, newest first
+// @Summary List acceptances
+// @Tags Compliance
+// @Security BearerAuth
+// @Produce json
+// @Param user_id query int false "User ID"
+// @Param document_id query int false "Document ID"
+// @Param kind query string false "Document kind"
+// @Param from query string false "RFC 3339 timestamp"
+// @Param to query string false "RFC 3339 timestamp"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} api.PaginatedResponse{data=[]Acceptance}
+// @Router /compliance/acceptances [get]
+func (c *Controller) ListAcceptances(ctx *fiber.Ctx) error {
+	filter, err := acceptanceFilter(ctx)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	pagination := api.GetPagination(ctx)
+	acceptances, total, err := c.service.ListAcceptances(ctx.UserContext(), filter, pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, acceptances, pagination.Page, pagination.Limit, total)
+}
+
+// Export streams acceptance records as CSV or NDJSON for audits
+// @Summary Export acceptances
+// @Tags Compliance
+// @Security BearerAuth
+// @Produce text/csv
+// @Param format query string false "Export format (csv, ndjson)" default(csv)
+// @Param user_id query int false "User ID"
+// @Param document_id query int false "Document ID"
+// @Param kind query string false "Document kind"
+// @Param from query string false "RFC 3339 timestamp"
+// @Param to query string false "RFC 3339 timestamp"
+// @Success 200 {file} file
+// @Router /compliance/acceptances/export [get]
+func (c *Controller) Export(ctx *fiber.Ctx) error {
+	filter, err := acceptanceFilter(ctx)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	format := ctx.Query("format", ExportCSV)
+	contentType := "text/csv"
+	switch format {
+	case ExportCSV:
+	case ExportNDJSON:
+		contentType = "application/x-ndjson"
+	default:
+		return api.BadRequest(ctx, "Unsupported export format", nil)
+	}
+
+	ctx.Set(fiber.HeaderContentType, contentType)
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="acceptances-%s.%s"`, time.Now().Format("20060102"), format))
+
+	// The body is written after the handler returns, so the export must
+	// not use the request context
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := c.service.Export(context.Background(), filter, format, w); err != nil {
+			logger.Error("Acceptance export failed", logger.Fields{"error": err.Error()})
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// ==================== Helpers ====================
+
+// acceptanceFilter reads an acceptance filter from the query string
+func acceptanceFilter(ctx *fiber.Ctx) (AcceptanceFilter, error) {
+	filter := AcceptanceFilter{Kind: ctx.Query("kind")}
+	if userID, err := strconv.ParseUint(ctx.Query("user_id"), 10, 64); err == nil {
+		filter.UserID = uint(userID)
+	}
+	if documentID, err := strconv.ParseUint(ctx.Query("document_id"), 10, 64); err == nil {
+		filter.DocumentID = uint(documentID)
+	}
+	for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := ctx.Query(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, errors.NewBadRequest(name + " must be an RFC 3339 timestamp")
+			}
+			*target = &t
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,72 @@
+package compliance
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/notification"
+	"neonexcore/pkg/storage"
+
+	"gorm.io/gorm"
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Service
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		if enforce := os.Getenv("COMPLIANCE_ENFORCE"); enforce != "" {
+			config.Enforce = enforce == "true"
+		}
+		if paths := os.Getenv("COMPLIANCE_EXEMPT_PATHS"); paths != "" {
+			config.ExemptPaths = nil
+			for _, path := range strings.Split(paths, ",") {
+				if path = strings.TrimSpace(path); path != "" {
+					config.ExemptPaths = append(config.ExemptPaths, path)
+				}
+			}
+		}
+		if ttl, err := time.ParseDuration(os.Getenv("COMPLIANCE_CACHE_TTL")); err == nil && ttl > 0 {
+			config.CacheTTL = ttl
+		}
+		if ttl, err := time.ParseDuration(os.Getenv("COMPLIANCE_EXPORT_TTL")); err == nil && ttl > 0 {
+			config.ExportTTL = ttl
+		}
+		if url := os.Getenv("COMPLIANCE_EXPORT_URL"); url != "" {
+			config.ExportURL = url
+		}
+
+		var notifier Notifier
+		if manager := core.Resolve[*notification.Manager](container); manager != nil {
+			notifier = manager
+		}
+
+		// Passed checks are shared between instances when a cache is registered
+		store := core.Resolve[cache.Cache](container)
+		if store == nil {
+			store = cache.NewMemoryCache(cache.DefaultMemoryCacheConfig())
+		}
+		return NewService(core.Resolve[*Repository](container), store, core.Resolve[storage.Storage](container), notifier, config)
+	}, core.Singleton)
+
+	// Register Exporter
+	container.Provide(func() *Exporter {
+		interval, err := time.ParseDuration(os.Getenv("COMPLIANCE_EXPORT_INTERVAL"))
+		if err != nil {
+			interval = 30 * time.Second
+		}
+		return NewExporter(core.Resolve[*Service](container), interval)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
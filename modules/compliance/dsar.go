@@ -0,0 +1,363 @@
+package compliance
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+)
+
+const (
+	// exportStaleAfter is how long a request may stay processing before
+	// another exporter takes it over, e.g. after a crash
+	exportStaleAfter = time.Hour
+	// exportBatch is how many requests an exporter run takes on
+	exportBatch = 10
+)
+
+// Catalog returns the data sources gathered into exports
+func (s *Service) Catalog() []DataSource {
+	return s.config.Catalog
+}
+
+// RequestExport queues an export of everything held about a user.
+// requestedBy is the user, or an administrator acting for them.
+func (s *Service) RequestExport(ctx context.Context, userID, requestedBy uint) (*DataRequest, error) {
+	if s.files == nil {
+		return nil, errors.NewInternal("No storage is configured for data exports")
+	}
+
+	active, err := s.repo.FindActiveDataRequest(ctx, userID)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to check data requests").WithError(err)
+	}
+	if active != nil {
+		return nil, errors.NewConflict("An export is already in progress")
+	}
+
+	request := &DataRequest{UserID: userID, RequestedBy: requestedBy, Status: RequestPending}
+	if err := s.repo.CreateDataRequest(ctx, request); err != nil {
+		return nil, errors.NewInternal("Failed to create data request").WithError(err)
+	}
+
+	logger.Info("Data export requested", logger.Fields{"request_id": request.ID, "user_id": userID, "requested_by": requestedBy})
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventExportRequested,
+		Data: map[string]interface{}{
+			"request_id":   request.ID,
+			"user_id":      userID,
+			"requested_by": requestedBy,
+		},
+	})
+
+	select {
+	case s.queued <- struct{}{}:
+	default:
+	}
+	return request, nil
+}
+
+func (s *Service) ListDataRequests(ctx context.Context, filter DataRequestFilter, page, limit int) ([]DataRequest, int64, error) {
+	requests, total, err := s.repo.ListDataRequests(ctx, filter, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list data requests").WithError(err)
+	}
+	return requests, total, nil
+}
+
+// OpenExport opens a ready export archive. With a non-zero userID, only
+// that user's exports are found. The first download is recorded.
+func (s *Service) OpenExport(ctx context.Context, id, userID uint) (io.ReadCloser, *DataRequest, error) {
+	request, err := s.repo.FindDataRequest(ctx, id)
+	if err != nil {
+		return nil, nil, errors.NewInternal("Failed to load data request").WithError(err)
+	}
+	if request == nil || (userID != 0 && request.UserID != userID) {
+		return nil, nil, errors.NewNotFound("Export not found")
+	}
+	switch request.Status {
+	case RequestReady:
+	case RequestExpired:
+		return nil, nil, errors.NewNotFound("Export has expired; request a new one")
+	default:
+		return nil, nil, errors.NewConflict("Export is not ready")
+	}
+
+	reader, _, err := s.files.Get(ctx, request.StorageKey)
+	if err != nil {
+		return nil, nil, errors.NewInternal("Failed to open export").WithError(err)
+	}
+
+	if request.DownloadedAt == nil {
+		now := time.Now()
+		request.DownloadedAt = &now
+		if err := s.repo.UpdateDataRequest(ctx, request); err != nil {
+			logger.Warn("Failed to record export download", logger.Fields{"request_id": request.ID, "error": err.Error()})
+		}
+	}
+	return reader, request, nil
+}
+
+// ProcessDataRequests fulfills pending requests, and takes over ones whose
+// exporter stopped. Requests claimed by another instance are skipped.
+func (s *Service) ProcessDataRequests(ctx context.Context) int {
+	requests, err := s.repo.ClaimableDataRequests(ctx, time.Now().Add(-exportStaleAfter), exportBatch)
+	if err != nil {
+		logger.Error("Failed to load data requests", logger.Fields{"error": err.Error()})
+		return 0
+	}
+
+	processed := 0
+	for i := range requests {
+		claimed, err := s.repo.ClaimDataRequest(ctx, &requests[i], time.Now())
+		if err != nil || !claimed {
+			continue
+		}
+		request, err := s.repo.FindDataRequest(ctx, requests[i].ID)
+		if err != nil || request == nil {
+			continue
+		}
+		s.fulfill(ctx, request)
+		processed++
+	}
+	return processed
+}
+
+// ExpireDataRequests deletes archives past their retention
+func (s *Service) ExpireDataRequests(ctx context.Context) {
+	requests, err := s.repo.ExpiredDataRequests(ctx, time.Now(), exportBatch)
+	if err != nil {
+		logger.Error("Failed to load expired data requests", logger.Fields{"error": err.Error()})
+		return
+	}
+
+	for i := range requests {
+		request := &requests[i]
+		if err := s.files.Delete(ctx, request.StorageKey); err != nil {
+			logger.Warn("Failed to delete expired export", logger.Fields{"request_id": request.ID, "error": err.Error()})
+			continue
+		}
+		request.Status = RequestExpired
+		request.StorageKey = ""
+		if err := s.repo.UpdateDataRequest(ctx, request); err != nil {
+			logger.Error("Failed to expire data request", logger.Fields{"request_id": request.ID, "error": err.Error()})
+		}
+	}
+}
+
+// fulfill gathers a claimed request's data, retrying later on failure
+func (s *Service) fulfill(ctx context.Context, request *DataRequest) {
+	err := s.export(ctx, request)
+	now := time.Now()
+
+	if err != nil {
+		request.Error = err.Error()
+		request.Status = RequestPending
+		if request.Attempts >= s.config.MaxAttempts {
+			request.Status = RequestFailed
+			request.CompletedAt = &now
+		}
+		if err := s.repo.UpdateDataRequest(ctx, request); err != nil {
+			logger.Error("Failed to save data request", logger.Fields{"request_id": request.ID, "error": err.Error()})
+		}
+
+		logger.Error("Data export failed", logger.Fields{"request_id": request.ID, "attempt": request.Attempts, "error": err.Error()})
+		if request.Status == RequestFailed {
+			events.DispatchAsync(ctx, events.Event{
+				Name: EventExportFailed,
+				Data: map[string]interface{}{
+					"request_id": request.ID,
+					"user_id":    request.UserID,
+					"error":      request.Error,
+				},
+			})
+		}
+		return
+	}
+
+	expiresAt := now.Add(s.config.ExportTTL)
+	request.Status = RequestReady
+	request.Error = ""
+	request.CompletedAt = &now
+	request.ExpiresAt = &expiresAt
+	if err := s.repo.UpdateDataRequest(ctx, request); err != nil {
+		logger.Error("Failed to save data request", logger.Fields{"request_id": request.ID, "error": err.Error()})
+		return
+	}
+	s.notifyReady(ctx, request)
+
+	logger.Info("Data export ready", logger.Fields{"request_id": request.ID, "user_id": request.UserID, "size": request.Size})
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventExportReady,
+		Data: map[string]interface{}{
+			"request_id": request.ID,
+			"user_id":    request.UserID,
+			"size":       request.Size,
+			"records":    request.Records,
+			"expires_at": expiresAt,
+		},
+	})
+}
+
+// export writes the user's data from each catalog source to a zip archive
+// in storage, with a manifest describing the sources
+func (s *Service) export(ctx context.Context, request *DataRequest) error {
+	email, err := s.repo.FindAccountEmail(ctx, request.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to load account: %w", err)
+	}
+
+	file, err := os.CreateTemp("", "data-export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	type manifestSource struct {
+		DataSource
+		Records int `json:"records"`
+	}
+	manifest := struct {
+		RequestID   uint             `json:"request_id"`
+		UserID      uint             `json:"user_id"`
+		GeneratedAt time.Time        `json:"generated_at"`
+		Sources     []manifestSource `json:"sources"`
+	}{RequestID: request.ID, UserID: request.UserID, GeneratedAt: time.Now()}
+
+	archive := zip.NewWriter(file)
+	records := make(map[string]int)
+	for _, source := range s.config.Catalog {
+		var value interface{}
+		column := source.UserColumn
+		switch {
+		case source.UserColumn != "":
+			value = request.UserID
+		case source.EmailColumn != "" && email != "":
+			column, value = source.EmailColumn, email
+		default:
+			continue
+		}
+		if !s.repo.HasTable(source.Table) {
+			continue
+		}
+
+		count, err := s.exportSource(ctx, archive, source, column, value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", source.Name, err)
+		}
+		records[source.Name] = count
+		manifest.Sources = append(manifest.Sources, manifestSource{DataSource: source, Records: count})
+	}
+
+	w, err := archive.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("compliance/exports/%d/%d-%d.zip", request.UserID, request.ID, time.Now().Unix())
+	if err := s.files.Put(ctx, key, file, "application/zip"); err != nil {
+		return fmt.Errorf("failed to store archive: %w", err)
+	}
+
+	request.StorageKey = key
+	request.Size = info.Size()
+	request.Records = records
+	return nil
+}
+
+// exportSource writes a source's rows as a JSON array, leaving out its
+// excluded columns
+func (s *Service) exportSource(ctx context.Context, archive *zip.Writer, source DataSource, column string, value interface{}) (int, error) {
+	w, err := archive.Create(source.Name + ".json")
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.WriteString(w, "["); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = s.repo.EachRow(ctx, source.Table, column, value, func(row map[string]interface{}) error {
+		for _, excluded := range source.Exclude {
+			delete(row, excluded)
+		}
+		data, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+
+		separator := "\n  "
+		if count > 0 {
+			separator = ",\n  "
+		}
+		count++
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	closing := "]\n"
+	if count > 0 {
+		closing = "\n]\n"
+	}
+	_, err = io.WriteString(w, closing)
+	return count, err
+}
+
+// notifyReady emails the user that their export can be downloaded
+func (s *Service) notifyReady(ctx context.Context, request *DataRequest) {
+	if s.notifier == nil {
+		return
+	}
+	email, err := s.repo.FindAccountEmail(ctx, request.UserID)
+	if err != nil || email == "" {
+		return
+	}
+
+	link := s.config.ExportURL
+	if strings.Contains(link, "%d") {
+		link = fmt.Sprintf(link, request.ID)
+	}
+	body := fmt.Sprintf("The copy of your data you requested is ready.\n\nDownload it from %s before %s, when it will be deleted.\n\n"+
+		"If you did not request it, contact support.",
+		link, request.ExpiresAt.Format("January 2, 2006"))
+	if err := s.notifier.SendEmail(ctx, email, "Your data export is ready", body); err != nil {
+		logger.Warn("Failed to send export notification", logger.Fields{"request_id": request.ID, "error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	request.NotifiedAt = &now
+	if err := s.repo.UpdateDataRequest(ctx, request); err != nil {
+		logger.Warn("Failed to record export notification", logger.Fields{"request_id": request.ID, "error": err.Error()})
+	}
+}
@@ -0,0 +1,68 @@
+package compliance
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Exporter fulfills data requests in the background and deletes expired
+// archives
+type Exporter struct {
+	service  *Service
+	interval time.Duration
+
+	mu      sync.Mutex
+	started bool
+	stop    chan struct{}
+}
+
+// NewExporter creates an exporter checking for work every interval, and
+// as soon as a request is made on this instance
+func NewExporter(service *Service, interval time.Duration) *Exporter {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &Exporter{service: service, interval: interval}
+}
+
+// Start begins exporting in the background. It is safe to call more than once.
+func (e *Exporter) Start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.started {
+		return
+	}
+	e.started = true
+	e.stop = make(chan struct{})
+	go e.run(e.stop)
+}
+
+// Stop ends exporting
+func (e *Exporter) Stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.started {
+		close(e.stop)
+		e.started = false
+	}
+}
+
+func (e *Exporter) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.service.ExpireDataRequests(context.Background())
+			e.service.ProcessDataRequests(context.Background())
+		case <-e.service.queued:
+			e.service.ProcessDataRequests(context.Background())
+		case <-stop:
+			return
+		}
+	}
+}
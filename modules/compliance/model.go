@@ -0,0 +1,122 @@
+package compliance
+
+import "time"
+
+// Common document kinds
+const (
+	KindTerms   = "terms"   // Terms of service
+	KindPrivacy = "privacy" // Privacy policy
+)
+
+// Document is a version of a policy users accept, such as the terms of
+// service, a privacy policy or an optional marketing consent. The latest
+// published version of each kind is current.
+type Document struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	Kind        string     `gorm:"size:50;not null;uniqueIndex:idx_compliance_documents_version" json:"kind"`
+	Version     string     `gorm:"size:50;not null;uniqueIndex:idx_compliance_documents_version" json:"version"`
+	Title       string     `gorm:"size:200;not null" json:"title"`
+	URL         string     `gorm:"size:500" json:"url,omitempty"`
+	Content     string     `gorm:"type:text" json:"content,omitempty"`
+	Summary     string     `gorm:"size:2000" json:"summary,omitempty"`  // What changed since the previous version
+	Required    bool       `gorm:"not null" json:"required"`            // Must be accepted to use the API; optional consents can be withdrawn
+	PublishedAt *time.Time `gorm:"index" json:"published_at,omitempty"` // Nil for drafts
+	CreatedBy   uint       `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for Document
+func (Document) TableName() string {
+	return "compliance_documents"
+}
+
+// Acceptance records a user accepting a document version. Withdrawn
+// consents keep their record for the audit trail.
+type Acceptance struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	UserID      uint       `gorm:"not null;index:idx_compliance_acceptances_user" json:"user_id"`
+	DocumentID  uint       `gorm:"not null;index:idx_compliance_acceptances_user;index" json:"document_id"`
+	Kind        string     `gorm:"size:50;not null" json:"kind"`
+	Version     string     `gorm:"size:50;not null" json:"version"`
+	IP          string     `gorm:"size:45" json:"ip,omitempty"`
+	UserAgent   string     `gorm:"size:500" json:"user_agent,omitempty"`
+	AcceptedAt  time.Time  `gorm:"not null;index" json:"accepted_at"`
+	WithdrawnAt *time.Time `json:"withdrawn_at,omitempty"`
+}
+
+// TableName specifies the table name for Acceptance
+func (Acceptance) TableName() string {
+	return "compliance_acceptances"
+}
+
+// AcceptanceFilter narrows an acceptance listing or export
+type AcceptanceFilter struct {
+	UserID     uint
+	DocumentID uint
+	Kind       string
+	From       *time.Time
+	To         *time.Time
+}
+
+// DocumentStatus is a current document and whether a user has accepted it
+type DocumentStatus struct {
+	Document   Document   `json:"document"`
+	Accepted   bool       `json:"accepted"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+}
+
+// PendingDocument is a required document a user has yet to accept
+type PendingDocument struct {
+	ID      uint   `json:"id"`
+	Kind    string `json:"kind"`
+	Version string `json:"version"`
+	Title   string `json:"title"`
+	URL     string `json:"url,omitempty"`
+}
+
+// Data request states
+const (
+	RequestPending    = "pending"    // Waiting for the exporter
+	RequestProcessing = "processing" // Being gathered
+	RequestReady      = "ready"      // Archive available for download
+	RequestFailed     = "failed"
+	RequestExpired    = "expired" // Archive deleted after its retention
+)
+
+// DataRequest is a data subject access request: an export of everything
+// the platform holds about a user. It doubles as the fulfillment record.
+type DataRequest struct {
+	ID           uint           `gorm:"primarykey" json:"id"`
+	UserID       uint           `gorm:"not null;index" json:"user_id"`
+	RequestedBy  uint           `json:"requested_by"` // The user, or an administrator acting on their behalf
+	Status       string         `gorm:"size:20;not null;index" json:"status"`
+	StorageKey   string         `gorm:"size:300" json:"-"`
+	Size         int64          `json:"size,omitempty"`
+	Records      map[string]int `gorm:"serializer:json" json:"records,omitempty"` // Rows exported per data source
+	Error        string         `gorm:"size:1000" json:"error,omitempty"`
+	Attempts     int            `gorm:"default:0" json:"attempts"`
+	StartedAt    *time.Time     `json:"started_at,omitempty"`
+	CompletedAt  *time.Time     `json:"completed_at,omitempty"`
+	NotifiedAt   *time.Time     `json:"notified_at,omitempty"`
+	DownloadedAt *time.Time     `json:"downloaded_at,omitempty"`
+	ExpiresAt    *time.Time     `gorm:"index" json:"expires_at,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// TableName specifies the table name for DataRequest
+func (DataRequest) TableName() string {
+	return "compliance_data_requests"
+}
+
+// active reports whether the request is still being worked on
+func (r *DataRequest) active() bool {
+	return r.Status == RequestPending || r.Status == RequestProcessing
+}
+
+// DataRequestFilter narrows a data request listing
+type DataRequestFilter struct {
+	UserID uint
+	Status string
+}
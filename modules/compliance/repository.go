@@ -0,0 +1,290 @@
+package compliance
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ==================== Documents ====================
+
+func (r *Repository) ListDocuments(ctx context.Context, kind string) ([]Document, error) {
+	var documents []Document
+	query := r.db.WithContext(ctx).Omit("content")
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	err := query.Order("kind ASC, created_at DESC").Find(&documents).Error
+	return documents, err
+}
+
+func (r *Repository) FindDocument(ctx context.Context, id uint) (*Document, error) {
+	var document Document
+	if err := r.db.WithContext(ctx).First(&document, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &document, nil
+}
+
+func (r *Repository) FindDocumentByVersion(ctx context.Context, kind, version string) (*Document, error) {
+	var document Document
+	if err := r.db.WithContext(ctx).Where("kind = ? AND version = ?", kind, version).First(&document).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &document, nil
+}
+
+// CurrentDocuments returns the latest published version of each kind
+func (r *Repository) CurrentDocuments(ctx context.Context) ([]Document, error) {
+	latest := r.db.Model(&Document{}).
+		Select("kind, MAX(published_at) AS published_at").
+		Where("published_at IS NOT NULL").
+		Group("kind")
+
+	var documents []Document
+	err := r.db.WithContext(ctx).
+		Joins("JOIN (?) AS latest ON latest.kind = compliance_documents.kind AND latest.published_at = compliance_documents.published_at", latest).
+		Order("compliance_documents.kind ASC").
+		Find(&documents).Error
+	return documents, err
+}
+
+func (r *Repository) CreateDocument(ctx context.Context, document *Document) error {
+	return r.db.WithContext(ctx).Create(document).Error
+}
+
+func (r *Repository) UpdateDocument(ctx context.Context, document *Document) error {
+	return r.db.WithContext(ctx).Save(document).Error
+}
+
+// ==================== Acceptances ====================
+
+// ActiveAcceptances returns a user's acceptances of the given documents
+// that have not been withdrawn
+func (r *Repository) ActiveAcceptances(ctx context.Context, userID uint, documentIDs []uint) ([]Acceptance, error) {
+	var acceptances []Acceptance
+	if len(documentIDs) == 0 {
+		return acceptances, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND document_id IN ? AND withdrawn_at IS NULL", userID, documentIDs).
+		Find(&acceptances).Error
+	return acceptances, err
+}
+
+func (r *Repository) ListAcceptances(ctx context.Context, filter AcceptanceFilter, page, limit int) ([]Acceptance, int64, error) {
+	var acceptances []Acceptance
+	var total int64
+
+	query := r.filter(r.db.WithContext(ctx).Model(&Acceptance{}), filter)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("accepted_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&acceptances).Error
+	return acceptances, total, err
+}
+
+// EachAcceptance streams matching acceptances in ID order, batchSize at a time
+func (r *Repository) EachAcceptance(ctx context.Context, filter AcceptanceFilter, batchSize int, fn func(batch []Acceptance) error) error {
+	var batch []Acceptance
+	return r.filter(r.db.WithContext(ctx), filter).
+		Order("id ASC").
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
+
+func (r *Repository) CreateAcceptance(ctx context.Context, acceptance *Acceptance) error {
+	return r.db.WithContext(ctx).Create(acceptance).Error
+}
+
+// WithdrawAcceptances marks a user's active acceptances of a document withdrawn
+func (r *Repository) WithdrawAcceptances(ctx context.Context, userID, documentID uint, at time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&Acceptance{}).
+		Where("user_id = ? AND document_id = ? AND withdrawn_at IS NULL", userID, documentID).
+		Update("withdrawn_at", at)
+	return result.RowsAffected, result.Error
+}
+
+func (r *Repository) filter(query *gorm.DB, filter AcceptanceFilter) *gorm.DB {
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.DocumentID != 0 {
+		query = query.Where("document_id = ?", filter.DocumentID)
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	if filter.From != nil {
+		query = query.Where("accepted_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("accepted_at < ?", *filter.To)
+	}
+	return query
+}
+
+// ==================== Data Requests ====================
+
+func (r *Repository) ListDataRequests(ctx context.Context, filter DataRequestFilter, page, limit int) ([]DataRequest, int64, error) {
+	var requests []DataRequest
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&DataRequest{})
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&requests).Error
+	return requests, total, err
+}
+
+func (r *Repository) FindDataRequest(ctx context.Context, id uint) (*DataRequest, error) {
+	var request DataRequest
+	if err := r.db.WithContext(ctx).First(&request, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// FindActiveDataRequest returns a user's pending or processing request, or nil
+func (r *Repository) FindActiveDataRequest(ctx context.Context, userID uint) (*DataRequest, error) {
+	var request DataRequest
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND status IN ?", userID, []string{RequestPending, RequestProcessing}).
+		First(&request).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// ClaimableDataRequests returns pending requests, and processing ones
+// whose exporter stopped before staleBefore, oldest first
+func (r *Repository) ClaimableDataRequests(ctx context.Context, staleBefore time.Time, limit int) ([]DataRequest, error) {
+	var requests []DataRequest
+	err := r.db.WithContext(ctx).
+		Where("status = ? OR (status = ? AND updated_at < ?)", RequestPending, RequestProcessing, staleBefore).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&requests).Error
+	return requests, err
+}
+
+// ClaimDataRequest moves a request to processing unless another exporter
+// changed it since it was read
+func (r *Repository) ClaimDataRequest(ctx context.Context, request *DataRequest, now time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&DataRequest{}).
+		Where("id = ? AND status = ? AND updated_at = ?", request.ID, request.Status, request.UpdatedAt).
+		Updates(map[string]interface{}{
+			"status":     RequestProcessing,
+			"started_at": now,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"updated_at": now,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// ExpiredDataRequests returns ready requests past their expiry
+func (r *Repository) ExpiredDataRequests(ctx context.Context, now time.Time, limit int) ([]DataRequest, error) {
+	var requests []DataRequest
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND expires_at < ?", RequestReady, now).
+		Limit(limit).
+		Find(&requests).Error
+	return requests, err
+}
+
+func (r *Repository) CreateDataRequest(ctx context.Context, request *DataRequest) error {
+	return r.db.WithContext(ctx).Create(request).Error
+}
+
+func (r *Repository) UpdateDataRequest(ctx context.Context, request *DataRequest) error {
+	return r.db.WithContext(ctx).Save(request).Error
+}
+
+// HasTable reports whether a table exists, as tables of disabled modules do not
+func (r *Repository) HasTable(table string) bool {
+	return r.db.Migrator().HasTable(table)
+}
+
+// EachRow streams the rows of a table matching column = value as column maps
+func (r *Repository) EachRow(ctx context.Context, table, column string, value interface{}, fn func(row map[string]interface{}) error) error {
+	rows, err := r.db.WithContext(ctx).Table(table).Where(clause.Eq{Column: clause.Column{Name: column}, Value: value}).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, name := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[name] = string(b)
+			} else {
+				row[name] = values[i]
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// FindAccountEmail returns a user's email, or empty
+func (r *Repository) FindAccountEmail(ctx context.Context, userID uint) (string, error) {
+	var emails []string
+	err := r.db.WithContext(ctx).Table("users").
+		Where("id = ? AND deleted_at IS NULL", userID).
+		Limit(1).
+		Pluck("email", &emails).Error
+	if err != nil || len(emails) == 0 {
+		return "", err
+	}
+	return emails[0], nil
+}
@@ -0,0 +1,60 @@
+package compliance
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	// Fulfill data requests and delete expired archives
+	core.Resolve[*Exporter](container).Start()
+
+	compliance := router.Group("/compliance")
+	compliance.Get("/documents/current", controller.CurrentDocuments)
+
+	protected := compliance.Group("", auth.AuthMiddleware(jwtManager))
+
+	// ==================== Own Acceptance ====================
+	protected.Get("/me", controller.Status)
+	protected.Post("/me/accept", controller.Accept)
+	protected.Delete("/me/consents/:id", controller.Withdraw)
+	protected.Get("/me/exports", controller.ListMyExports)
+	protected.Post("/me/exports", controller.RequestExport)
+	protected.Get("/me/exports/:id/download", controller.DownloadExport)
+
+	// ==================== Documents ====================
+	protected.Get("/documents", rbac.RequirePermission(rbacManager, "compliance.manage"), controller.ListDocuments)
+	protected.Post("/documents", rbac.RequirePermission(rbacManager, "compliance.manage"), controller.CreateDocument)
+	protected.Get("/documents/:id", rbac.RequirePermission(rbacManager, "compliance.manage"), controller.GetDocument)
+	protected.Put("/documents/:id", rbac.RequirePermission(rbacManager, "compliance.manage"), controller.UpdateDocument)
+	protected.Post("/documents/:id/publish", rbac.RequirePermission(rbacManager, "compliance.manage"), controller.PublishDocument)
+
+	// ==================== Audit ====================
+	protected.Get("/acceptances", rbac.RequirePermission(rbacManager, "compliance.audit"), controller.ListAcceptances)
+	protected.Get("/acceptances/export", rbac.RequirePermission(rbacManager, "compliance.audit"), controller.Export)
+	protected.Get("/catalog", rbac.RequirePermission(rbacManager, "compliance.audit"), controller.Catalog)
+	protected.Get("/exports", rbac.RequirePermission(rbacManager, "compliance.audit"), controller.ListDataRequests)
+	protected.Post("/users/:id/exports", rbac.RequirePermission(rbacManager, "compliance.manage"), controller.RequestUserExport)
+}
+
+// Middleware identifies signed-in users and holds back those with required
+// documents to accept. Exempt paths, such as sign in and acceptance, pass.
+func Middleware(container *core.Container) []fiber.Handler {
+	controller := core.Resolve[*Controller](container)
+	if !core.Resolve[*Service](container).Enforced() {
+		return nil
+	}
+
+	return []fiber.Handler{
+		auth.OptionalAuthMiddleware(core.Resolve[*auth.JWTManager](container)),
+		controller.RequireAcceptance,
+	}
+}
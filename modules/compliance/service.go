@@ -0,0 +1,520 @@
+package compliance
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/storage"
+)
+
+// Compliance event names
+const (
+	EventDocumentPublished = "compliance.document_published"
+	EventAccepted          = "compliance.accepted"
+	EventWithdrawn         = "compliance.withdrawn"
+	EventExportRequested   = "compliance.export.requested"
+	EventExportReady       = "compliance.export.ready"
+	EventExportFailed      = "compliance.export.failed"
+)
+
+// Export formats
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+)
+
+const exportBatchSize = 500
+
+// Notifier sends compliance notifications by email
+type Notifier interface {
+	SendEmail(ctx context.Context, to, subject, body string) error
+}
+
+// Config holds compliance configuration
+type Config struct {
+	Enforce     bool          // Block users who have not accepted the current required documents
+	ExemptPaths []string      // Path prefixes the gate always lets through, e.g. sign in and acceptance
+	CacheTTL    time.Duration // How long current documents and passed checks are cached
+	Catalog     []DataSource  // Tables gathered into data exports
+	ExportTTL   time.Duration // How long export archives stay downloadable
+	ExportURL   string        // Download page linked from the ready email; %d is the request ID
+	MaxAttempts int           // Export attempts before a request fails
+}
+
+// DefaultConfig returns default compliance configuration
+func DefaultConfig() Config {
+	return Config{
+		Enforce:     true,
+		ExemptPaths: []string{"/api/v1/auth", "/api/v1/compliance"},
+		CacheTTL:    5 * time.Minute,
+		Catalog:     DefaultCatalog(),
+		ExportTTL:   7 * 24 * time.Hour,
+		ExportURL:   "/api/v1/compliance/me/exports/%d/download",
+		MaxAttempts: 3,
+	}
+}
+
+// DocumentInput is the payload for creating or updating a draft document
+type DocumentInput struct {
+	Kind     string `json:"kind" validate:"required,max=50"`
+	Version  string `json:"version" validate:"required,max=50"`
+	Title    string `json:"title" validate:"required,max=200"`
+	URL      string `json:"url" validate:"omitempty,url,max=500"`
+	Content  string `json:"content"`
+	Summary  string `json:"summary" validate:"max=2000"`
+	Required *bool  `json:"required"` // Defaults to true
+}
+
+// AcceptInput is the payload for accepting documents
+type AcceptInput struct {
+	DocumentIDs []uint `json:"document_ids" validate:"required,min=1,dive,min=1"`
+}
+
+// Service records which policy versions users accepted, and when and from
+// where they did so, and fulfills requests for users' data
+type Service struct {
+	repo     *Repository
+	cache    cache.Cache
+	files    storage.Storage
+	notifier Notifier
+	config   Config
+	queued   chan struct{} // Wakes the exporter when a request is made
+
+	mu       sync.RWMutex
+	current  []Document
+	loadedAt time.Time
+}
+
+func NewService(repo *Repository, store cache.Cache, files storage.Storage, notifier Notifier, config Config) *Service {
+	return &Service{
+		repo:     repo,
+		cache:    store,
+		files:    files,
+		notifier: notifier,
+		config:   config,
+		queued:   make(chan struct{}, 1),
+	}
+}
+
+// ==================== Documents ====================
+
+func (s *Service) ListDocuments(ctx context.Context, kind string) ([]Document, error) {
+	documents, err := s.repo.ListDocuments(ctx, kind)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to list documents").WithError(err)
+	}
+	return documents, nil
+}
+
+func (s *Service) GetDocument(ctx context.Context, id uint) (*Document, error) {
+	document, err := s.repo.FindDocument(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load document").WithError(err)
+	}
+	if document == nil {
+		return nil, errors.NewNotFound("Document not found")
+	}
+	return document, nil
+}
+
+// CreateDocument adds a draft document version
+func (s *Service) CreateDocument(ctx context.Context, input *DocumentInput, userID uint) (*Document, error) {
+	if err := s.checkVersion(ctx, input, 0); err != nil {
+		return nil, err
+	}
+
+	document := &Document{Required: true, CreatedBy: userID}
+	applyInput(document, input)
+	if err := s.repo.CreateDocument(ctx, document); err != nil {
+		return nil, errors.NewInternal("Failed to create document").WithError(err)
+	}
+	return document, nil
+}
+
+// UpdateDocument changes a draft. Published versions are immutable, so
+// changes to them are published as a new version.
+func (s *Service) UpdateDocument(ctx context.Context, id uint, input *DocumentInput) (*Document, error) {
+	document, err := s.GetDocument(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if document.PublishedAt != nil {
+		return nil, errors.NewConflict("Published documents cannot be changed; create a new version")
+	}
+	if err := s.checkVersion(ctx, input, document.ID); err != nil {
+		return nil, err
+	}
+
+	applyInput(document, input)
+	if err := s.repo.UpdateDocument(ctx, document); err != nil {
+		return nil, errors.NewInternal("Failed to update document").WithError(err)
+	}
+	return document, nil
+}
+
+// PublishDocument makes a draft the current version of its kind. Users must
+// accept a newly published required document before they continue.
+func (s *Service) PublishDocument(ctx context.Context, id uint) (*Document, error) {
+	document, err := s.GetDocument(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if document.PublishedAt != nil {
+		return nil, errors.NewConflict("Document is already published")
+	}
+
+	now := time.Now()
+	document.PublishedAt = &now
+	if err := s.repo.UpdateDocument(ctx, document); err != nil {
+		return nil, errors.NewInternal("Failed to publish document").WithError(err)
+	}
+	s.invalidate()
+
+	logger.Info("Compliance document published", logger.Fields{"kind": document.Kind, "version": document.Version, "required": document.Required})
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventDocumentPublished,
+		Data: map[string]interface{}{
+			"document_id": document.ID,
+			"kind":        document.Kind,
+			"version":     document.Version,
+			"title":       document.Title,
+			"summary":     document.Summary,
+			"required":    document.Required,
+		},
+	})
+	return document, nil
+}
+
+// CurrentDocuments returns the latest published version of each kind. It is
+// cached for CacheTTL, so other instances see new versions within that time.
+func (s *Service) CurrentDocuments(ctx context.Context) ([]Document, error) {
+	s.mu.RLock()
+	current, loadedAt := s.current, s.loadedAt
+	s.mu.RUnlock()
+	if !loadedAt.IsZero() && time.Since(loadedAt) < s.config.CacheTTL {
+		return current, nil
+	}
+
+	current, err := s.repo.CurrentDocuments(ctx)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load current documents").WithError(err)
+	}
+
+	s.mu.Lock()
+	s.current, s.loadedAt = current, time.Now()
+	s.mu.Unlock()
+	return current, nil
+}
+
+// ==================== Acceptance ====================
+
+// Status returns each current document and whether the user accepted it
+func (s *Service) Status(ctx context.Context, userID uint) ([]DocumentStatus, error) {
+	current, err := s.CurrentDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accepted, err := s.accepted(ctx, userID, current)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]DocumentStatus, 0, len(current))
+	for _, document := range current {
+		status := DocumentStatus{Document: document}
+		if acceptance, ok := accepted[document.ID]; ok {
+			status.Accepted = true
+			status.AcceptedAt = &acceptance.AcceptedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending returns the current required documents the user has not
+// accepted. Users with none pending are remembered for CacheTTL.
+func (s *Service) Pending(ctx context.Context, userID uint) ([]PendingDocument, error) {
+	current, err := s.CurrentDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var required []Document
+	var stamp []string
+	for _, document := range current {
+		if document.Required {
+			required = append(required, document)
+			stamp = append(stamp, strconv.FormatUint(uint64(document.ID), 10))
+		}
+	}
+	if len(required) == 0 {
+		return nil, nil
+	}
+
+	// The key names the required versions, so publishing one invalidates it
+	key := fmt.Sprintf("compliance:passed:%d:%s", userID, strings.Join(stamp, "-"))
+	if passed, err := s.cache.Exists(ctx, key); err == nil && passed {
+		return nil, nil
+	}
+
+	accepted, err := s.accepted(ctx, userID, required)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []PendingDocument
+	for _, document := range required {
+		if _, ok := accepted[document.ID]; !ok {
+			pending = append(pending, PendingDocument{
+				ID:      document.ID,
+				Kind:    document.Kind,
+				Version: document.Version,
+				Title:   document.Title,
+				URL:     document.URL,
+			})
+		}
+	}
+	if len(pending) == 0 {
+		s.cache.Set(ctx, key, true, s.config.CacheTTL)
+	}
+	return pending, nil
+}
+
+// Accept records the user accepting current document versions. Documents
+// the user already accepted keep their original record.
+func (s *Service) Accept(ctx context.Context, userID uint, input *AcceptInput, ip, userAgent string) ([]Acceptance, error) {
+	current, err := s.CurrentDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]Document, len(current))
+	for _, document := range current {
+		byID[document.ID] = document
+	}
+
+	var documents []Document
+	for _, id := range input.DocumentIDs {
+		document, ok := byID[id]
+		if !ok {
+			return nil, errors.NewBadRequest(fmt.Sprintf("Document %d is not a current version", id))
+		}
+		documents = append(documents, document)
+	}
+
+	accepted, err := s.accepted(ctx, userID, documents)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(userAgent) > 500 {
+		userAgent = userAgent[:500]
+	}
+	now := time.Now()
+	acceptances := make([]Acceptance, 0, len(documents))
+	for _, document := range documents {
+		if existing, ok := accepted[document.ID]; ok {
+			acceptances = append(acceptances, existing)
+			continue
+		}
+
+		acceptance := Acceptance{
+			UserID:     userID,
+			DocumentID: document.ID,
+			Kind:       document.Kind,
+			Version:    document.Version,
+			IP:         ip,
+			UserAgent:  userAgent,
+			AcceptedAt: now,
+		}
+		if err := s.repo.CreateAcceptance(ctx, &acceptance); err != nil {
+			return nil, errors.NewInternal("Failed to record acceptance").WithError(err)
+		}
+		accepted[document.ID] = acceptance
+		acceptances = append(acceptances, acceptance)
+
+		events.DispatchAsync(ctx, events.Event{
+			Name: EventAccepted,
+			Data: map[string]interface{}{
+				"user_id":     userID,
+				"document_id": document.ID,
+				"kind":        document.Kind,
+				"version":     document.Version,
+				"ip":          ip,
+			},
+		})
+	}
+	return acceptances, nil
+}
+
+// Withdraw records the user withdrawing an optional consent. Required
+// documents cannot be withdrawn.
+func (s *Service) Withdraw(ctx context.Context, userID, documentID uint) error {
+	document, err := s.GetDocument(ctx, documentID)
+	if err != nil {
+		return err
+	}
+	if document.Required {
+		return errors.NewBadRequest("Required documents cannot be withdrawn")
+	}
+
+	withdrawn, err := s.repo.WithdrawAcceptances(ctx, userID, documentID, time.Now())
+	if err != nil {
+		return errors.NewInternal("Failed to withdraw consent").WithError(err)
+	}
+	if withdrawn == 0 {
+		return errors.NewNotFound("Document has not been accepted")
+	}
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventWithdrawn,
+		Data: map[string]interface{}{
+			"user_id":     userID,
+			"document_id": document.ID,
+			"kind":        document.Kind,
+			"version":     document.Version,
+		},
+	})
+	return nil
+}
+
+// ==================== Audit ====================
+
+func (s *Service) ListAcceptances(ctx context.Context, filter AcceptanceFilter, page, limit int) ([]Acceptance, int64, error) {
+	acceptances, total, err := s.repo.ListAcceptances(ctx, filter, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list acceptances").WithError(err)
+	}
+	return acceptances, total, nil
+}
+
+// Export streams matching acceptances to w as CSV or NDJSON
+func (s *Service) Export(ctx context.Context, filter AcceptanceFilter, format string, w io.Writer) error {
+	switch format {
+	case ExportNDJSON:
+		encoder := json.NewEncoder(w)
+		return s.repo.EachAcceptance(ctx, filter, exportBatchSize, func(batch []Acceptance) error {
+			for i := range batch {
+				if err := encoder.Encode(&batch[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	case ExportCSV:
+		writer := csv.NewWriter(w)
+		header := []string{"id", "user_id", "document_id", "kind", "version", "accepted_at", "withdrawn_at", "ip", "user_agent"}
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+
+		err := s.repo.EachAcceptance(ctx, filter, exportBatchSize, func(batch []Acceptance) error {
+			for _, acceptance := range batch {
+				withdrawnAt := ""
+				if acceptance.WithdrawnAt != nil {
+					withdrawnAt = acceptance.WithdrawnAt.Format(time.RFC3339)
+				}
+				row := []string{
+					strconv.FormatUint(uint64(acceptance.ID), 10),
+					strconv.FormatUint(uint64(acceptance.UserID), 10),
+					strconv.FormatUint(uint64(acceptance.DocumentID), 10),
+					acceptance.Kind,
+					acceptance.Version,
+					acceptance.AcceptedAt.Format(time.RFC3339),
+					withdrawnAt,
+					acceptance.IP,
+					acceptance.UserAgent,
+				}
+				if err := writer.Write(row); err != nil {
+					return err
+				}
+			}
+			writer.Flush()
+			return writer.Error()
+		})
+		writer.Flush()
+		return err
+
+	default:
+		return errors.NewBadRequest("Unsupported export format")
+	}
+}
+
+// Exempt reports whether the gate lets a path through regardless of
+// pending documents
+func (s *Service) Exempt(path string) bool {
+	for _, prefix := range s.config.ExemptPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Enforced reports whether pending required documents block requests
+func (s *Service) Enforced() bool {
+	return s.config.Enforce
+}
+
+// ==================== Helpers ====================
+
+// accepted returns the user's active acceptances of documents by document ID
+func (s *Service) accepted(ctx context.Context, userID uint, documents []Document) (map[uint]Acceptance, error) {
+	ids := make([]uint, 0, len(documents))
+	for _, document := range documents {
+		ids = append(ids, document.ID)
+	}
+
+	acceptances, err := s.repo.ActiveAcceptances(ctx, userID, ids)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load acceptances").WithError(err)
+	}
+	accepted := make(map[uint]Acceptance, len(acceptances))
+	for _, acceptance := range acceptances {
+		accepted[acceptance.DocumentID] = acceptance
+	}
+	return accepted, nil
+}
+
+// checkVersion rejects a kind and version already used by another document
+func (s *Service) checkVersion(ctx context.Context, input *DocumentInput, id uint) error {
+	existing, err := s.repo.FindDocumentByVersion(ctx, input.Kind, input.Version)
+	if err != nil {
+		return errors.NewInternal("Failed to check document version").WithError(err)
+	}
+	if existing != nil && existing.ID != id {
+		return errors.NewConflict(fmt.Sprintf("Version %s of %s already exists", input.Version, input.Kind))
+	}
+	return nil
+}
+
+// invalidate drops the cached current documents
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+// applyInput copies input onto a draft document
+func applyInput(document *Document, input *DocumentInput) {
+	document.Kind = input.Kind
+	document.Version = input.Version
+	document.Title = input.Title
+	document.URL = input.URL
+	document.Content = input.Content
+	document.Summary = input.Summary
+	if input.Required != nil {
+		document.Required = *input.Required
+	}
+}
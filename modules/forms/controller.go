@@ -0,0 +1,249 @@
+package forms
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// List lists forms
+// @Summary List forms
+// @Tags Forms
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "Status (draft, open, closed)"
+// @Success 200 {object} api.Response{data=[]Form}
+// @Router /forms [get]
+func (c *Controller) List(ctx *fiber.Ctx) error {
+	pagination := api.GetPagination(ctx)
+
+	forms, total, err := c.service.List(ctx.Context(), ctx.Query("status"), pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, forms, pagination.Page, pagination.Limit, total)
+}
+
+// Get retrieves a form definition
+// @Summary Get form
+// @Tags Forms
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Form ID"
+// @Success 200 {object} api.Response{data=Form}
+// @Failure 404 {object} api.Response
+// @Router /forms/{id} [get]
+func (c *Controller) Get(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid form ID", nil)
+	}
+
+	form, err := c.service.Get(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, form)
+}
+
+// Create creates a form
+// @Summary Create form
+// @Description Create a form with typed fields and conditional logic
+// @Tags Forms
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param form body FormInput true "Form"
+// @Success 201 {object} api.Response{data=Form}
+// @Failure 409 {object} api.Response
+// @Failure 422 {object} api.Response
+// @Router /forms [post]
+func (c *Controller) Create(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input FormInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	form, err := c.service.Create(ctx.Context(), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Form created", form)
+}
+
+// Update updates a form
+// @Summary Update form
+// @Tags Forms
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Form ID"
+// @Param form body FormInput true "Form"
+// @Success 200 {object} api.Response{data=Form}
+// @Router /forms/{id} [put]
+func (c *Controller) Update(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid form ID", nil)
+	}
+
+	var input FormInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	form, err := c.service.Update(ctx.Context(), uint(id), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, form)
+}
+
+// Delete deletes a form
+// @Summary Delete form
+// @Tags Forms
+// @Security BearerAuth
+// @Param id path int true "Form ID"
+// @Success 204 "No Content"
+// @Router /forms/{id} [delete]
+func (c *Controller) Delete(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid form ID", nil)
+	}
+
+	if err := c.service.Delete(ctx.Context(), uint(id)); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.NoContent(ctx)
+}
+
+// Submissions lists a form's submissions
+// @Summary List submissions
+// @Tags Forms
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Form ID"
+// @Success 200 {object} api.Response{data=[]Submission}
+// @Router /forms/{id}/submissions [get]
+func (c *Controller) Submissions(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid form ID", nil)
+	}
+
+	pagination := api.GetPagination(ctx)
+	submissions, total, err := c.service.ListSubmissions(ctx.Context(), uint(id), pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, submissions, pagination.Page, pagination.Limit, total)
+}
+
+// Export streams all submissions as CSV or NDJSON
+// @Summary Export submissions
+// @Tags Forms
+// @Security BearerAuth
+// @Produce text/csv
+// @Param id path int true "Form ID"
+// @Param format query string false "Export format (csv, ndjson)" default(csv)
+// @Success 200 {file} file
+// @Router /forms/{id}/export [get]
+func (c *Controller) Export(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid form ID", nil)
+	}
+
+	format := ctx.Query("format", ExportCSV)
+	contentType := "text/csv"
+	switch format {
+	case ExportCSV:
+	case ExportNDJSON:
+		contentType = "application/x-ndjson"
+	default:
+		return api.BadRequest(ctx, "Unsupported export format", nil)
+	}
+
+	form, err := c.service.Get(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	ctx.Set(fiber.HeaderContentType, contentType)
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s-submissions.%s"`, form.Slug, format))
+
+	// The body is written after the handler returns, so the export must
+	// not use the request context
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := c.service.Export(context.Background(), form, format, w); err != nil {
+			logger.Error("Form export failed", logger.Fields{"form": form.Slug, "error": err.Error()})
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// Schema renders the JSON schema of an open form
+// @Summary Get form schema
+// @Tags Forms
+// @Produce json
+// @Param slug path string true "Form slug"
+// @Success 200 {object} api.Response{data=map[string]interface{}}
+// @Failure 404 {object} api.Response
+// @Router /forms/public/{slug} [get]
+func (c *Controller) Schema(ctx *fiber.Ctx) error {
+	schema, err := c.service.Schema(ctx.Context(), ctx.Params("slug"))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, schema)
+}
+
+// Submit submits answers to an open form
+// @Summary Submit form
+// @Tags Forms
+// @Accept json
+// @Produce json
+// @Param slug path string true "Form slug"
+// @Param submission body SubmitInput true "Answers"
+// @Success 201 {object} api.Response{data=Submission}
+// @Failure 422 {object} api.Response
+// @Router /forms/public/{slug}/submissions [post]
+func (c *Controller) Submit(ctx *fiber.Ctx) error {
+	var input SubmitInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	meta := SubmissionMeta{
+		IPAddress: ctx.IP(),
+		UserAgent: ctx.Get(fiber.HeaderUserAgent),
+	}
+	if userID, ok := auth.GetUserID(ctx); ok {
+		meta.UserID = &userID
+	}
+
+	submission, err := c.service.Submit(ctx.Context(), ctx.Params("slug"), &input, meta)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Submission received", submission)
+}
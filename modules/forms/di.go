@@ -0,0 +1,38 @@
+package forms
+
+import (
+	"time"
+
+	"neonexcore/internal/core"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultWebhookTimeout = 10 * time.Second
+	defaultWebhookRetries = 3
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Webhook Dispatcher
+	container.Provide(func() *WebhookDispatcher {
+		return NewWebhookDispatcher(defaultWebhookTimeout, defaultWebhookRetries)
+	}, core.Singleton)
+
+	// Register Service
+	container.Provide(func() *Service {
+		repo := core.Resolve[*Repository](container)
+		webhooks := core.Resolve[*WebhookDispatcher](container)
+		return NewService(repo, webhooks)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
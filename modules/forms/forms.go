@@ -0,0 +1,28 @@
+package forms
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type FormsModule struct{}
+
+func New() *FormsModule {
+	return &FormsModule{}
+}
+
+func (m *FormsModule) Name() string {
+	return "forms"
+}
+
+func (m *FormsModule) Init() {}
+
+func (m *FormsModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *FormsModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
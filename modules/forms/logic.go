@@ -0,0 +1,167 @@
+package forms
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// validOperators lists supported condition operators
+var validOperators = map[string]bool{
+	"eq": true, "neq": true, "in": true, "not_in": true,
+	"gt": true, "gte": true, "lt": true, "lte": true,
+	"contains": true, "filled": true, "empty": true,
+}
+
+var fieldNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// checkFields verifies a field list is well-formed beyond struct tags.
+// Conditions may only reference fields declared earlier, which rules out
+// cycles.
+func checkFields(fields []Field) map[string]interface{} {
+	errs := make(map[string]interface{})
+	seen := make(map[string]bool, len(fields))
+
+	for i, f := range fields {
+		key := fmt.Sprintf("fields[%d]", i)
+
+		if !fieldNameRegex.MatchString(f.Name) {
+			errs[key] = "name must start with a letter and contain only letters, digits and underscores"
+			continue
+		}
+		if seen[f.Name] {
+			errs[key] = fmt.Sprintf("duplicate field name %q", f.Name)
+			continue
+		}
+		if (f.Type == FieldSelect || f.Type == FieldMultiSelect) && len(f.Options) == 0 {
+			errs[key] = "select fields require options"
+			continue
+		}
+		if f.Pattern != "" {
+			if _, err := regexp.Compile(f.Pattern); err != nil {
+				errs[key] = fmt.Sprintf("invalid pattern: %v", err)
+				continue
+			}
+		}
+		if f.ShowIf != nil {
+			if !seen[f.ShowIf.Field] {
+				errs[key] = fmt.Sprintf("show_if must reference an earlier field, got %q", f.ShowIf.Field)
+				continue
+			}
+			if !validOperators[f.ShowIf.Operator] {
+				errs[key] = fmt.Sprintf("unsupported show_if operator %q", f.ShowIf.Operator)
+				continue
+			}
+		}
+
+		seen[f.Name] = true
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// visibleFields returns the fields shown for the given answers. A field
+// whose controlling field is hidden is hidden too.
+func visibleFields(fields []Field, answers map[string]interface{}) []Field {
+	visible := make([]Field, 0, len(fields))
+	shown := make(map[string]bool, len(fields))
+
+	for _, f := range fields {
+		if f.ShowIf != nil {
+			if !shown[f.ShowIf.Field] || !f.ShowIf.Matches(answers[f.ShowIf.Field]) {
+				continue
+			}
+		}
+		shown[f.Name] = true
+		visible = append(visible, f)
+	}
+	return visible
+}
+
+// Matches evaluates the condition against an answer
+func (c *Condition) Matches(answer interface{}) bool {
+	switch c.Operator {
+	case "filled":
+		return !isEmpty(answer)
+	case "empty":
+		return isEmpty(answer)
+	case "eq":
+		return equal(answer, c.Value)
+	case "neq":
+		return !equal(answer, c.Value)
+	case "in", "not_in":
+		found := false
+		if list, ok := c.Value.([]interface{}); ok {
+			for _, v := range list {
+				if equal(answer, v) {
+					found = true
+					break
+				}
+			}
+		}
+		return found == (c.Operator == "in")
+	case "contains":
+		switch a := answer.(type) {
+		case string:
+			return strings.Contains(a, fmt.Sprint(c.Value))
+		case []interface{}:
+			for _, v := range a {
+				if equal(v, c.Value) {
+					return true
+				}
+			}
+		}
+		return false
+	case "gt", "gte", "lt", "lte":
+		a, ok1 := answer.(float64)
+		b, ok2 := toFloat(c.Value)
+		if !ok1 || !ok2 {
+			return false
+		}
+		switch c.Operator {
+		case "gt":
+			return a > b
+		case "gte":
+			return a >= b
+		case "lt":
+			return a < b
+		default:
+			return a <= b
+		}
+	}
+	return false
+}
+
+func isEmpty(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(val) == ""
+	case []interface{}:
+		return len(val) == 0
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			return af == bf
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
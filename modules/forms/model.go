@@ -0,0 +1,94 @@
+package forms
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Form statuses
+const (
+	StatusDraft  = "draft"  // Editable, not accepting submissions
+	StatusOpen   = "open"   // Accepting submissions
+	StatusClosed = "closed" // No longer accepting submissions
+)
+
+// Field types
+const (
+	FieldText        = "text"
+	FieldTextarea    = "textarea"
+	FieldEmail       = "email"
+	FieldURL         = "url"
+	FieldNumber      = "number"
+	FieldInteger     = "integer"
+	FieldBoolean     = "boolean"
+	FieldSelect      = "select"
+	FieldMultiSelect = "multiselect"
+	FieldDate        = "date"
+)
+
+// Form is a survey or form definition. Fields are stored as JSON.
+type Form struct {
+	ID            uint           `gorm:"primarykey" json:"id"`
+	Slug          string         `gorm:"size:100;uniqueIndex;not null" json:"slug"`
+	Title         string         `gorm:"size:255;not null" json:"title"`
+	Description   string         `gorm:"type:text" json:"description"`
+	Status        string         `gorm:"size:20;default:'draft';index" json:"status"`
+	Fields        string         `gorm:"type:text;not null" json:"-"`
+	WebhookURL    string         `gorm:"size:500" json:"webhook_url,omitempty"`
+	WebhookSecret string         `gorm:"size:255" json:"-"`
+	AllowAnon     bool           `gorm:"default:true" json:"allow_anonymous"`
+	CreatedBy     uint           `json:"created_by"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+
+	FieldList []Field `gorm:"-" json:"fields"`
+}
+
+// TableName specifies the table name for Form
+func (Form) TableName() string {
+	return "forms"
+}
+
+// Field is a single typed input on a form
+type Field struct {
+	Name        string     `json:"name" validate:"required,max=100"`
+	Label       string     `json:"label" validate:"required,max=255"`
+	Type        string     `json:"type" validate:"required,oneof=text textarea email url number integer boolean select multiselect date"`
+	Required    bool       `json:"required"`
+	Placeholder string     `json:"placeholder,omitempty"`
+	HelpText    string     `json:"help_text,omitempty"`
+	Options     []string   `json:"options,omitempty"`
+	Min         *float64   `json:"min,omitempty"`        // Minimum number, or minimum selections
+	Max         *float64   `json:"max,omitempty"`        // Maximum number, or maximum selections
+	MinLength   *int       `json:"min_length,omitempty"` // Text fields only
+	MaxLength   *int       `json:"max_length,omitempty"` // Text fields only
+	Pattern     string     `json:"pattern,omitempty"`    // Text fields only
+	ShowIf      *Condition `json:"show_if,omitempty"`    // Field is hidden unless the condition holds
+}
+
+// Condition controls field visibility based on another field's answer
+type Condition struct {
+	Field    string      `json:"field"`
+	Operator string      `json:"operator"` // eq, neq, in, not_in, gt, gte, lt, lte, contains, filled, empty
+	Value    interface{} `json:"value,omitempty"`
+}
+
+// Submission is a single response to a form
+type Submission struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	FormID    uint      `gorm:"not null;index" json:"form_id"`
+	Data      string    `gorm:"type:text;not null" json:"-"`
+	UserID    *uint     `gorm:"index" json:"user_id,omitempty"`
+	IPAddress string    `gorm:"size:45" json:"ip_address"`
+	UserAgent string    `gorm:"size:500" json:"user_agent"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	Answers map[string]interface{} `gorm:"-" json:"answers"`
+}
+
+// TableName specifies the table name for Submission
+func (Submission) TableName() string {
+	return "form_submissions"
+}
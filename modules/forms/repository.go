@@ -0,0 +1,109 @@
+package forms
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ==================== Forms ====================
+
+func (r *Repository) List(ctx context.Context, status string, page, limit int) ([]Form, int64, error) {
+	var forms []Form
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Form{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&forms).Error
+	return forms, total, err
+}
+
+func (r *Repository) FindByID(ctx context.Context, id uint) (*Form, error) {
+	var form Form
+	err := r.db.WithContext(ctx).First(&form, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &form, nil
+}
+
+func (r *Repository) FindBySlug(ctx context.Context, slug string) (*Form, error) {
+	var form Form
+	err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&form).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &form, nil
+}
+
+func (r *Repository) Create(ctx context.Context, form *Form) error {
+	return r.db.WithContext(ctx).Create(form).Error
+}
+
+func (r *Repository) Update(ctx context.Context, form *Form) error {
+	return r.db.WithContext(ctx).Save(form).Error
+}
+
+func (r *Repository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&Form{}, id).Error
+}
+
+// ==================== Submissions ====================
+
+func (r *Repository) CreateSubmission(ctx context.Context, submission *Submission) error {
+	return r.db.WithContext(ctx).Create(submission).Error
+}
+
+func (r *Repository) ListSubmissions(ctx context.Context, formID uint, page, limit int) ([]Submission, int64, error) {
+	var submissions []Submission
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Submission{}).Where("form_id = ?", formID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&submissions).Error
+	return submissions, total, err
+}
+
+func (r *Repository) CountSubmissions(ctx context.Context, formID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Submission{}).Where("form_id = ?", formID).Count(&count).Error
+	return count, err
+}
+
+// EachSubmission streams a form's submissions in ID order, batchSize at a time
+func (r *Repository) EachSubmission(ctx context.Context, formID uint, batchSize int, fn func(batch []Submission) error) error {
+	var batch []Submission
+	return r.db.WithContext(ctx).
+		Where("form_id = ?", formID).
+		Order("id ASC").
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
@@ -0,0 +1,33 @@
+package forms
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	forms := router.Group("/forms")
+
+	// ==================== Public API ====================
+	public := forms.Group("/public", auth.OptionalAuthMiddleware(jwtManager))
+	public.Get("/:slug", controller.Schema)
+	public.Post("/:slug/submissions", controller.Submit)
+
+	// ==================== Management API ====================
+	manage := forms.Group("", auth.AuthMiddleware(jwtManager, auth.AcceptAPIKeys()))
+	manage.Get("", rbac.RequirePermission(rbacManager, "forms.manage"), controller.List)
+	manage.Post("", rbac.RequirePermission(rbacManager, "forms.manage"), controller.Create)
+	manage.Get("/:id", rbac.RequirePermission(rbacManager, "forms.manage"), controller.Get)
+	manage.Put("/:id", rbac.RequirePermission(rbacManager, "forms.manage"), controller.Update)
+	manage.Delete("/:id", rbac.RequirePermission(rbacManager, "forms.manage"), controller.Delete)
+	manage.Get("/:id/submissions", rbac.RequirePermission(rbacManager, "forms.submissions.read"), controller.Submissions)
+	manage.Get("/:id/export", rbac.RequirePermission(rbacManager, "forms.submissions.read"), controller.Export)
+}
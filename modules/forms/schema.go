@@ -0,0 +1,153 @@
+package forms
+
+import (
+	"neonexcore/pkg/validation"
+)
+
+// RenderSchema renders a form as a JSON schema for frontends. Field
+// metadata that JSON schema cannot express (labels, order, conditional
+// visibility) is carried in "x-" extension keywords.
+func RenderSchema(form *Form) map[string]interface{} {
+	properties := make(map[string]interface{}, len(form.FieldList))
+	order := make([]string, 0, len(form.FieldList))
+	required := make([]string, 0)
+
+	for _, f := range form.FieldList {
+		prop := fieldSchema(f)
+		prop["title"] = f.Label
+		if f.Placeholder != "" {
+			prop["x-placeholder"] = f.Placeholder
+		}
+		if f.HelpText != "" {
+			prop["description"] = f.HelpText
+		}
+		prop["x-widget"] = f.Type
+		if f.ShowIf != nil {
+			prop["x-show-if"] = f.ShowIf
+		}
+
+		properties[f.Name] = map[string]interface{}(prop)
+		order = append(order, f.Name)
+
+		// Conditional fields are only required while visible
+		if f.Required && f.ShowIf == nil {
+			required = append(required, f.Name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"$id":                  "forms/" + form.Slug,
+		"title":                form.Title,
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+		"x-order":              order,
+	}
+	if form.Description != "" {
+		schema["description"] = form.Description
+	}
+	return schema
+}
+
+// fieldSchema returns the validation schema for a single field
+func fieldSchema(f Field) validation.Schema {
+	s := validation.Schema{}
+
+	switch f.Type {
+	case FieldText, FieldTextarea, FieldEmail, FieldURL, FieldDate:
+		s["type"] = "string"
+		if f.MinLength != nil {
+			s["minLength"] = float64(*f.MinLength)
+		}
+		if f.MaxLength != nil {
+			s["maxLength"] = float64(*f.MaxLength)
+		}
+		if f.Pattern != "" {
+			s["pattern"] = f.Pattern
+		}
+		switch f.Type {
+		case FieldEmail:
+			s["format"] = "email"
+		case FieldURL:
+			s["format"] = "uri"
+		case FieldDate:
+			s["format"] = "date"
+		}
+
+	case FieldNumber, FieldInteger:
+		s["type"] = "number"
+		if f.Type == FieldInteger {
+			s["type"] = "integer"
+		}
+		if f.Min != nil {
+			s["minimum"] = *f.Min
+		}
+		if f.Max != nil {
+			s["maximum"] = *f.Max
+		}
+
+	case FieldBoolean:
+		s["type"] = "boolean"
+
+	case FieldSelect:
+		s["type"] = "string"
+		s["enum"] = toInterfaces(f.Options)
+
+	case FieldMultiSelect:
+		s["type"] = "array"
+		s["items"] = map[string]interface{}{
+			"type": "string",
+			"enum": toInterfaces(f.Options),
+		}
+		if f.Min != nil {
+			s["minItems"] = *f.Min
+		}
+		if f.Max != nil {
+			s["maxItems"] = *f.Max
+		}
+	}
+
+	return s
+}
+
+// ValidateSubmission validates answers against the fields visible for
+// those answers. Answers to hidden or unknown fields are discarded. It
+// returns the cleaned answers or a map of field name to error message.
+func ValidateSubmission(fields []Field, answers map[string]interface{}) (map[string]interface{}, map[string]string) {
+	visible := visibleFields(fields, answers)
+
+	properties := make(map[string]interface{}, len(visible))
+	required := make([]interface{}, 0)
+	clean := make(map[string]interface{}, len(visible))
+
+	for _, f := range visible {
+		properties[f.Name] = map[string]interface{}(fieldSchema(f))
+
+		value, present := answers[f.Name]
+		if present && !isEmpty(value) {
+			clean[f.Name] = value
+		} else if f.Required {
+			required = append(required, f.Name)
+		}
+	}
+
+	schema := validation.Schema{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+	if errs := schema.Validate(clean); errs != nil {
+		return nil, errs
+	}
+	return clean, nil
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
@@ -0,0 +1,359 @@
+package forms
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+)
+
+// Form event names
+const (
+	EventSubmissionCreated = "forms.submission.created"
+)
+
+// Export formats
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+)
+
+const exportBatchSize = 500
+
+// FormInput is the payload for creating or updating a form
+type FormInput struct {
+	Slug           string  `json:"slug" validate:"required,slug,max=100"`
+	Title          string  `json:"title" validate:"required,max=255"`
+	Description    string  `json:"description"`
+	Status         string  `json:"status" validate:"omitempty,oneof=draft open closed"`
+	Fields         []Field `json:"fields" validate:"required,min=1,dive"`
+	WebhookURL     string  `json:"webhook_url" validate:"omitempty,url,max=500"`
+	WebhookSecret  string  `json:"webhook_secret" validate:"max=255"`
+	AllowAnonymous *bool   `json:"allow_anonymous"`
+}
+
+// SubmitInput is the payload for a form submission
+type SubmitInput struct {
+	Answers map[string]interface{} `json:"answers" validate:"required"`
+}
+
+// SubmissionMeta describes who submitted a form
+type SubmissionMeta struct {
+	UserID    *uint
+	IPAddress string
+	UserAgent string
+}
+
+type Service struct {
+	repo     *Repository
+	webhooks *WebhookDispatcher
+}
+
+func NewService(repo *Repository, webhooks *WebhookDispatcher) *Service {
+	return &Service{
+		repo:     repo,
+		webhooks: webhooks,
+	}
+}
+
+// ==================== Forms ====================
+
+func (s *Service) List(ctx context.Context, status string, page, limit int) ([]Form, int64, error) {
+	forms, total, err := s.repo.List(ctx, status, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list forms").WithError(err)
+	}
+	for i := range forms {
+		if err := decodeFields(&forms[i]); err != nil {
+			return nil, 0, err
+		}
+	}
+	return forms, total, nil
+}
+
+func (s *Service) Get(ctx context.Context, id uint) (*Form, error) {
+	form, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load form").WithError(err)
+	}
+	if form == nil {
+		return nil, errors.NewNotFound("Form not found")
+	}
+	if err := decodeFields(form); err != nil {
+		return nil, err
+	}
+	return form, nil
+}
+
+// GetOpen returns a form that is accepting submissions
+func (s *Service) GetOpen(ctx context.Context, slug string) (*Form, error) {
+	form, err := s.repo.FindBySlug(ctx, slug)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load form").WithError(err)
+	}
+	if form == nil || form.Status == StatusDraft {
+		return nil, errors.NewNotFound("Form not found")
+	}
+	if err := decodeFields(form); err != nil {
+		return nil, err
+	}
+	return form, nil
+}
+
+// Schema renders the JSON schema of an open form
+func (s *Service) Schema(ctx context.Context, slug string) (map[string]interface{}, error) {
+	form, err := s.GetOpen(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	return RenderSchema(form), nil
+}
+
+func (s *Service) Create(ctx context.Context, input *FormInput, userID uint) (*Form, error) {
+	existing, err := s.repo.FindBySlug(ctx, input.Slug)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load form").WithError(err)
+	}
+	if existing != nil {
+		return nil, errors.NewConflict("Form slug already exists")
+	}
+
+	form := &Form{
+		Status:    StatusDraft,
+		AllowAnon: true,
+		CreatedBy: userID,
+	}
+	if err := applyInput(form, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, form); err != nil {
+		return nil, errors.NewInternal("Failed to create form").WithError(err)
+	}
+	return form, nil
+}
+
+func (s *Service) Update(ctx context.Context, id uint, input *FormInput) (*Form, error) {
+	form, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Slug != form.Slug {
+		existing, err := s.repo.FindBySlug(ctx, input.Slug)
+		if err != nil {
+			return nil, errors.NewInternal("Failed to load form").WithError(err)
+		}
+		if existing != nil {
+			return nil, errors.NewConflict("Form slug already exists")
+		}
+	}
+
+	if err := applyInput(form, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, form); err != nil {
+		return nil, errors.NewInternal("Failed to update form").WithError(err)
+	}
+	return form, nil
+}
+
+func (s *Service) Delete(ctx context.Context, id uint) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return errors.NewInternal("Failed to delete form").WithError(err)
+	}
+	return nil
+}
+
+// ==================== Submissions ====================
+
+// Submit validates and stores a submission, then notifies listeners and
+// the form's webhook
+func (s *Service) Submit(ctx context.Context, slug string, input *SubmitInput, meta SubmissionMeta) (*Submission, error) {
+	form, err := s.GetOpen(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if form.Status != StatusOpen {
+		return nil, errors.NewForbidden("Form is closed")
+	}
+	if !form.AllowAnon && meta.UserID == nil {
+		return nil, errors.NewUnauthorized("Sign in to submit this form")
+	}
+
+	answers, fieldErrs := ValidateSubmission(form.FieldList, input.Answers)
+	if fieldErrs != nil {
+		details := make(map[string]interface{}, len(fieldErrs))
+		for k, v := range fieldErrs {
+			details[k] = v
+		}
+		return nil, errors.NewValidationError("Submission is invalid", details)
+	}
+
+	data, err := json.Marshal(answers)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to encode submission").WithError(err)
+	}
+
+	submission := &Submission{
+		FormID:    form.ID,
+		Data:      string(data),
+		UserID:    meta.UserID,
+		IPAddress: meta.IPAddress,
+		UserAgent: meta.UserAgent,
+		Answers:   answers,
+	}
+	if err := s.repo.CreateSubmission(ctx, submission); err != nil {
+		return nil, errors.NewInternal("Failed to save submission").WithError(err)
+	}
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventSubmissionCreated,
+		Data: map[string]interface{}{
+			"form_id":       form.ID,
+			"form":          form.Slug,
+			"submission_id": submission.ID,
+			"answers":       answers,
+		},
+	})
+	s.webhooks.Dispatch(form, submission)
+
+	return submission, nil
+}
+
+func (s *Service) ListSubmissions(ctx context.Context, formID uint, page, limit int) ([]Submission, int64, error) {
+	if _, err := s.Get(ctx, formID); err != nil {
+		return nil, 0, err
+	}
+
+	submissions, total, err := s.repo.ListSubmissions(ctx, formID, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list submissions").WithError(err)
+	}
+	for i := range submissions {
+		json.Unmarshal([]byte(submissions[i].Data), &submissions[i].Answers)
+	}
+	return submissions, total, nil
+}
+
+// Export streams every submission of a form to w as CSV or NDJSON
+func (s *Service) Export(ctx context.Context, form *Form, format string, w io.Writer) error {
+	switch format {
+	case ExportNDJSON:
+		encoder := json.NewEncoder(w)
+		return s.repo.EachSubmission(ctx, form.ID, exportBatchSize, func(batch []Submission) error {
+			for i := range batch {
+				json.Unmarshal([]byte(batch[i].Data), &batch[i].Answers)
+				if err := encoder.Encode(&batch[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	case ExportCSV:
+		writer := csv.NewWriter(w)
+		header := []string{"id", "submitted_at", "user_id"}
+		for _, f := range form.FieldList {
+			header = append(header, f.Name)
+		}
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+
+		err := s.repo.EachSubmission(ctx, form.ID, exportBatchSize, func(batch []Submission) error {
+			for _, sub := range batch {
+				var answers map[string]interface{}
+				json.Unmarshal([]byte(sub.Data), &answers)
+
+				userID := ""
+				if sub.UserID != nil {
+					userID = strconv.FormatUint(uint64(*sub.UserID), 10)
+				}
+				row := []string{strconv.FormatUint(uint64(sub.ID), 10), sub.CreatedAt.Format(time.RFC3339), userID}
+				for _, f := range form.FieldList {
+					row = append(row, csvValue(answers[f.Name]))
+				}
+				if err := writer.Write(row); err != nil {
+					return err
+				}
+			}
+			writer.Flush()
+			return writer.Error()
+		})
+		writer.Flush()
+		return err
+
+	default:
+		return errors.NewBadRequest("Unsupported export format")
+	}
+}
+
+// ==================== Helpers ====================
+
+// applyInput copies input onto form after checking the field definitions
+func applyInput(form *Form, input *FormInput) error {
+	if errs := checkFields(input.Fields); errs != nil {
+		return errors.NewValidationError("Invalid form fields", errs)
+	}
+
+	fields, err := json.Marshal(input.Fields)
+	if err != nil {
+		return errors.NewInternal("Failed to encode fields").WithError(err)
+	}
+
+	form.Slug = input.Slug
+	form.Title = input.Title
+	form.Description = input.Description
+	form.Fields = string(fields)
+	form.FieldList = input.Fields
+	form.WebhookURL = input.WebhookURL
+	if input.WebhookSecret != "" {
+		form.WebhookSecret = input.WebhookSecret
+	}
+	if input.Status != "" {
+		form.Status = input.Status
+	}
+	if input.AllowAnonymous != nil {
+		form.AllowAnon = *input.AllowAnonymous
+	}
+	return nil
+}
+
+func decodeFields(form *Form) error {
+	if err := json.Unmarshal([]byte(form.Fields), &form.FieldList); err != nil {
+		return errors.NewInternal("Stored form fields are corrupt").WithError(err)
+	}
+	return nil
+}
+
+// csvValue flattens an answer into a CSV cell
+func csvValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, len(val))
+		for i, item := range val {
+			parts[i] = csvValue(item)
+		}
+		return strings.Join(parts, "; ")
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,125 @@
+package forms
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"neonexcore/pkg/httpclient"
+	"neonexcore/pkg/logger"
+)
+
+// Webhook headers
+const (
+	SignatureHeader = "X-Neonex-Signature" // sha256=<hex HMAC of "<timestamp>.<body>">
+	TimestampHeader = "X-Neonex-Timestamp"
+	EventHeader     = "X-Neonex-Event"
+)
+
+// WebhookPayload is the body posted to a form's webhook
+type WebhookPayload struct {
+	Event      string      `json:"event"`
+	Form       string      `json:"form"`
+	Submission *Submission `json:"submission"`
+}
+
+// WebhookDispatcher posts submissions to form webhooks in the background
+type WebhookDispatcher struct {
+	client  *http.Client
+	retries int
+	backoff time.Duration
+}
+
+// NewWebhookDispatcher creates a webhook dispatcher
+func NewWebhookDispatcher(timeout time.Duration, retries int) *WebhookDispatcher {
+	if retries < 0 {
+		retries = 0
+	}
+	return &WebhookDispatcher{
+		client:  httpclient.New(timeout),
+		retries: retries,
+		backoff: time.Second,
+	}
+}
+
+// Dispatch delivers the submission asynchronously. Failures are retried
+// with exponential backoff and logged once retries are exhausted.
+func (d *WebhookDispatcher) Dispatch(form *Form, submission *Submission) {
+	if form.WebhookURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(WebhookPayload{
+		Event:      EventSubmissionCreated,
+		Form:       form.Slug,
+		Submission: submission,
+	})
+	if err != nil {
+		logger.Error("Failed to encode form webhook payload", logger.Fields{"form": form.Slug, "error": err.Error()})
+		return
+	}
+
+	go func() {
+		backoff := d.backoff
+		for attempt := 0; ; attempt++ {
+			err := d.post(context.Background(), form.WebhookURL, form.WebhookSecret, body)
+			if err == nil {
+				return
+			}
+			if attempt >= d.retries {
+				logger.Error("Form webhook delivery failed", logger.Fields{
+					"form":          form.Slug,
+					"submission_id": submission.ID,
+					"attempts":      attempt + 1,
+					"error":         err.Error(),
+				})
+				return
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}()
+}
+
+func (d *WebhookDispatcher) post(ctx context.Context, url, secret string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, EventSubmissionCreated)
+	req.Header.Set(TimestampHeader, timestamp)
+	if secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign computes the webhook signature for a timestamp and body, so
+// receivers can verify deliveries
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
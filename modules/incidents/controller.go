@@ -0,0 +1,222 @@
+package incidents
+
+import (
+	"strconv"
+	"time"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultReportDays is the report window when no "days" query is given
+const defaultReportDays = 30
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// List lists incidents
+// @Summary List incidents
+// @Tags Incidents
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "Status (open, acknowledged, resolved)"
+// @Param severity query string false "Severity (critical, high, medium, low)"
+// @Success 200 {object} api.Response{data=[]Incident}
+// @Router /incidents [get]
+func (c *Controller) List(ctx *fiber.Ctx) error {
+	pagination := api.GetPagination(ctx)
+	filter := ListFilter{
+		Status:   ctx.Query("status"),
+		Severity: ctx.Query("severity"),
+	}
+
+	incidents, total, err := c.service.List(ctx.Context(), filter, pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, incidents, pagination.Page, pagination.Limit, total)
+}
+
+// Get retrieves an incident with its timeline and metrics snapshots
+// @Summary Get incident
+// @Tags Incidents
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Incident ID"
+// @Success 200 {object} api.Response{data=Incident}
+// @Failure 404 {object} api.Response
+// @Router /incidents/{id} [get]
+func (c *Controller) Get(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid incident ID", nil)
+	}
+
+	incident, err := c.service.Get(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, incident)
+}
+
+// Create declares an incident manually
+// @Summary Declare incident
+// @Tags Incidents
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param incident body OpenInput true "Incident"
+// @Success 201 {object} api.Response{data=Incident}
+// @Router /incidents [post]
+func (c *Controller) Create(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input OpenInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	incident, err := c.service.Open(ctx.Context(), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Incident opened", incident)
+}
+
+// Acknowledge acknowledges an incident
+// @Summary Acknowledge incident
+// @Tags Incidents
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Incident ID"
+// @Success 200 {object} api.Response{data=Incident}
+// @Failure 409 {object} api.Response
+// @Router /incidents/{id}/acknowledge [post]
+func (c *Controller) Acknowledge(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid incident ID", nil)
+	}
+
+	incident, err := c.service.Acknowledge(ctx.Context(), uint(id), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, incident)
+}
+
+// Resolve resolves an incident
+// @Summary Resolve incident
+// @Tags Incidents
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Incident ID"
+// @Param note body NoteInput false "Resolution note"
+// @Success 200 {object} api.Response{data=Incident}
+// @Failure 409 {object} api.Response
+// @Router /incidents/{id}/resolve [post]
+func (c *Controller) Resolve(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid incident ID", nil)
+	}
+
+	var input NoteInput
+	if len(ctx.Body()) > 0 {
+		if err := validation.ValidateBody(ctx, &input); err != nil {
+			return api.RespondError(ctx, err)
+		}
+	}
+
+	incident, err := c.service.Resolve(ctx.Context(), uint(id), userID, input.Message)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, incident)
+}
+
+// AddNote posts a note to the incident timeline
+// @Summary Add incident note
+// @Tags Incidents
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Incident ID"
+// @Param note body NoteInput true "Note"
+// @Success 201 {object} api.Response{data=Incident}
+// @Router /incidents/{id}/notes [post]
+func (c *Controller) AddNote(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid incident ID", nil)
+	}
+
+	var input NoteInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	incident, err := c.service.AddNote(ctx.Context(), uint(id), userID, input.Message)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Note added", incident)
+}
+
+// Snapshot captures the incident's related metrics
+// @Summary Capture metrics snapshot
+// @Tags Incidents
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Incident ID"
+// @Success 201 {object} api.Response{data=Incident}
+// @Router /incidents/{id}/snapshots [post]
+func (c *Controller) Snapshot(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid incident ID", nil)
+	}
+
+	incident, err := c.service.Snapshot(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Snapshot captured", incident)
+}
+
+// Report returns SLA performance
+// @Summary SLA report
+// @Description Counts, breaches, mean time to acknowledge/resolve and compliance for incidents opened in the last N days
+// @Tags Incidents
+// @Security BearerAuth
+// @Produce json
+// @Param days query int false "Window in days (default 30)"
+// @Success 200 {object} api.Response{data=Report}
+// @Router /incidents/report [get]
+func (c *Controller) Report(ctx *fiber.Ctx) error {
+	days, err := strconv.Atoi(ctx.Query("days"))
+	if err != nil || days <= 0 {
+		days = defaultReportDays
+	}
+
+	report, err := c.service.Report(ctx.Context(), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, report)
+}
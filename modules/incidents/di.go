@@ -0,0 +1,67 @@
+package incidents
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/metrics"
+
+	"gorm.io/gorm"
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Service
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		if severity := os.Getenv("INCIDENTS_DEFAULT_SEVERITY"); severities[severity] {
+			config.DefaultSeverity = severity
+		}
+		// INCIDENTS_SLA_<SEVERITY>=<ack>,<resolve>, e.g. INCIDENTS_SLA_CRITICAL=5m,1h
+		for severity, sla := range config.SLAs {
+			parts := strings.Split(os.Getenv("INCIDENTS_SLA_"+strings.ToUpper(severity)), ",")
+			if len(parts) != 2 {
+				continue
+			}
+			if d, err := time.ParseDuration(strings.TrimSpace(parts[0])); err == nil && d > 0 {
+				sla.AckWithin = d
+			}
+			if d, err := time.ParseDuration(strings.TrimSpace(parts[1])); err == nil && d > 0 {
+				sla.ResolveWithin = d
+			}
+			config.SLAs[severity] = sla
+		}
+
+		var notifier Notifier
+		if webhookURL := os.Getenv("INCIDENTS_SLACK_WEBHOOK_URL"); webhookURL != "" {
+			notifier = NewSlackNotifier(webhookURL, os.Getenv("INCIDENTS_SLACK_CHANNEL"), os.Getenv("INCIDENTS_LINK_URL"))
+		}
+
+		return NewService(
+			core.Resolve[*Repository](container),
+			core.Resolve[*metrics.Collector](container),
+			notifier,
+			config,
+		)
+	}, core.Singleton)
+
+	// Register SLA Checker
+	container.Provide(func() *SLAChecker {
+		interval, err := time.ParseDuration(os.Getenv("INCIDENTS_SLA_CHECK_INTERVAL"))
+		if err != nil {
+			interval = time.Minute
+		}
+		return NewSLAChecker(core.Resolve[*Service](container), interval)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
@@ -0,0 +1,28 @@
+package incidents
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type IncidentsModule struct{}
+
+func New() *IncidentsModule {
+	return &IncidentsModule{}
+}
+
+func (m *IncidentsModule) Name() string {
+	return "incidents"
+}
+
+func (m *IncidentsModule) Init() {}
+
+func (m *IncidentsModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *IncidentsModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
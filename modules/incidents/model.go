@@ -0,0 +1,151 @@
+package incidents
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Severities, from most to least urgent
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+)
+
+// Incident statuses
+const (
+	StatusOpen         = "open"
+	StatusAcknowledged = "acknowledged"
+	StatusResolved     = "resolved"
+)
+
+// Incident sources
+const (
+	SourceAlert  = "alert"
+	SourceManual = "manual"
+)
+
+// Timeline entry kinds
+const (
+	EntryOpened       = "opened"
+	EntryAlert        = "alert"
+	EntryAcknowledged = "acknowledged"
+	EntryResolved     = "resolved"
+	EntryNote         = "note"
+	EntrySLABreach    = "sla_breach"
+	EntrySnapshot     = "snapshot"
+)
+
+// Incident is an operational incident tracked against SLA targets
+type Incident struct {
+	ID              uint           `gorm:"primarykey" json:"id"`
+	Title           string         `gorm:"size:255;not null" json:"title"`
+	Description     string         `gorm:"type:text" json:"description,omitempty"`
+	Severity        string         `gorm:"size:20;index;not null" json:"severity"`
+	Status          string         `gorm:"size:20;index;not null;default:'open'" json:"status"`
+	Source          string         `gorm:"size:20;not null;default:'manual'" json:"source"`
+	Fingerprint     string         `gorm:"size:255;index" json:"fingerprint,omitempty"` // Deduplicates repeated alerts
+	AlertCount      int            `gorm:"default:0" json:"alert_count"`
+	RelatedMetrics  string         `gorm:"size:1000" json:"-"` // Comma-separated metric names, "prefix*" allowed
+	AckDueAt        time.Time      `json:"ack_due_at"`
+	ResolveDueAt    time.Time      `json:"resolve_due_at"`
+	AcknowledgedAt  *time.Time     `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy  uint           `json:"acknowledged_by,omitempty"`
+	ResolvedAt      *time.Time     `gorm:"index" json:"resolved_at,omitempty"`
+	ResolvedBy      uint           `json:"resolved_by,omitempty"`
+	AckBreached     bool           `gorm:"default:false" json:"ack_breached"`
+	ResolveBreached bool           `gorm:"default:false" json:"resolve_breached"`
+	CreatedBy       uint           `json:"created_by,omitempty"`
+	CreatedAt       time.Time      `gorm:"index" json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Timeline  []TimelineEntry `gorm:"foreignKey:IncidentID" json:"timeline,omitempty"`
+	Snapshots []Snapshot      `gorm:"foreignKey:IncidentID" json:"snapshots,omitempty"`
+
+	MetricNames []string `gorm:"-" json:"related_metrics,omitempty"` // Decoded RelatedMetrics
+}
+
+// TableName specifies the table name for Incident
+func (Incident) TableName() string {
+	return "incidents"
+}
+
+// Resolved reports whether the incident is closed
+func (i *Incident) Resolved() bool {
+	return i.Status == StatusResolved
+}
+
+// TimeToAcknowledge returns how long acknowledgement took, or zero
+func (i *Incident) TimeToAcknowledge() time.Duration {
+	if i.AcknowledgedAt == nil {
+		return 0
+	}
+	return i.AcknowledgedAt.Sub(i.CreatedAt)
+}
+
+// TimeToResolve returns how long resolution took, or zero
+func (i *Incident) TimeToResolve() time.Duration {
+	if i.ResolvedAt == nil {
+		return 0
+	}
+	return i.ResolvedAt.Sub(i.CreatedAt)
+}
+
+// TimelineEntry is one event in an incident's history
+type TimelineEntry struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	IncidentID uint      `gorm:"index;not null" json:"incident_id"`
+	Kind       string    `gorm:"size:20;not null" json:"kind"`
+	Message    string    `gorm:"type:text" json:"message"`
+	SnapshotID *uint     `json:"snapshot_id,omitempty"`
+	UserID     uint      `json:"user_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for TimelineEntry
+func (TimelineEntry) TableName() string {
+	return "incident_timeline"
+}
+
+// Snapshot is a capture of the metrics related to an incident
+type Snapshot struct {
+	ID         uint          `gorm:"primarykey" json:"id"`
+	IncidentID uint          `gorm:"index;not null" json:"incident_id"`
+	Reason     string        `gorm:"size:100" json:"reason"`
+	Metrics    string        `gorm:"type:text" json:"-"` // JSON-encoded Data
+	CapturedAt time.Time     `json:"captured_at"`
+	Data       []MetricValue `gorm:"-" json:"metrics"`
+}
+
+// TableName specifies the table name for Snapshot
+func (Snapshot) TableName() string {
+	return "incident_snapshots"
+}
+
+// MetricValue is one metric in a snapshot
+type MetricValue struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type"`
+	Value  float64           `json:"value"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Report summarizes SLA performance over a period
+type Report struct {
+	Since             time.Time      `json:"since"`
+	Total             int            `json:"total"`
+	Open              int            `json:"open"`
+	Acknowledged      int            `json:"acknowledged"`
+	Resolved          int            `json:"resolved"`
+	AckBreaches       int            `json:"ack_breaches"`
+	ResolveBreaches   int            `json:"resolve_breaches"`
+	MeanTimeToAck     float64        `json:"mean_time_to_ack_seconds"`
+	MeanTimeToResolve float64        `json:"mean_time_to_resolve_seconds"`
+	BySeverity        map[string]int `json:"by_severity"`
+	AckCompliance     float64        `json:"ack_compliance"`     // Percent acknowledged within SLA
+	ResolveCompliance float64        `json:"resolve_compliance"` // Percent resolved within SLA
+	Policies          map[string]SLA `json:"policies"`
+}
@@ -0,0 +1,124 @@
+package incidents
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ListFilter narrows incident listings
+type ListFilter struct {
+	Status   string
+	Severity string
+}
+
+func (r *Repository) List(ctx context.Context, filter ListFilter, page, limit int) ([]Incident, int64, error) {
+	var incidents []Incident
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Incident{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Severity != "" {
+		query = query.Where("severity = ?", filter.Severity)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&incidents).Error
+	return incidents, total, err
+}
+
+func (r *Repository) FindByID(ctx context.Context, id uint) (*Incident, error) {
+	var incident Incident
+	err := r.db.WithContext(ctx).
+		Preload("Timeline", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC, id ASC") }).
+		Preload("Snapshots", func(db *gorm.DB) *gorm.DB { return db.Order("captured_at ASC") }).
+		First(&incident, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &incident, nil
+}
+
+// FindOpenByFingerprint returns the unresolved incident for a fingerprint
+func (r *Repository) FindOpenByFingerprint(ctx context.Context, fingerprint string) (*Incident, error) {
+	var incident Incident
+	err := r.db.WithContext(ctx).
+		Where("fingerprint = ? AND status <> ?", fingerprint, StatusResolved).
+		Order("created_at DESC").
+		First(&incident).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &incident, nil
+}
+
+// FindAckOverdue returns open incidents past their acknowledgement target
+// that have not been flagged yet
+func (r *Repository) FindAckOverdue(ctx context.Context, now time.Time) ([]Incident, error) {
+	var incidents []Incident
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND ack_breached = ? AND ack_due_at < ?", StatusOpen, false, now).
+		Find(&incidents).Error
+	return incidents, err
+}
+
+// FindResolveOverdue returns unresolved incidents past their resolution
+// target that have not been flagged yet
+func (r *Repository) FindResolveOverdue(ctx context.Context, now time.Time) ([]Incident, error) {
+	var incidents []Incident
+	err := r.db.WithContext(ctx).
+		Where("status <> ? AND resolve_breached = ? AND resolve_due_at < ?", StatusResolved, false, now).
+		Find(&incidents).Error
+	return incidents, err
+}
+
+// CreatedSince returns incidents opened after the given time
+func (r *Repository) CreatedSince(ctx context.Context, since time.Time) ([]Incident, error) {
+	var incidents []Incident
+	err := r.db.WithContext(ctx).Where("created_at >= ?", since).Find(&incidents).Error
+	return incidents, err
+}
+
+func (r *Repository) Create(ctx context.Context, incident *Incident) error {
+	return r.db.WithContext(ctx).Create(incident).Error
+}
+
+func (r *Repository) Update(ctx context.Context, incident *Incident) error {
+	return r.db.WithContext(ctx).Omit("Timeline", "Snapshots").Save(incident).Error
+}
+
+// IncrementAlertCount records another firing of the incident's alert
+func (r *Repository) IncrementAlertCount(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Model(&Incident{}).Where("id = ?", id).
+		UpdateColumn("alert_count", gorm.Expr("alert_count + 1")).Error
+}
+
+func (r *Repository) AddEntry(ctx context.Context, entry *TimelineEntry) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+func (r *Repository) AddSnapshot(ctx context.Context, snapshot *Snapshot) error {
+	return r.db.WithContext(ctx).Create(snapshot).Error
+}
@@ -0,0 +1,33 @@
+package incidents
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/metrics"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	// Open incidents from dashboard alerts and watch SLA deadlines
+	if dashboard := core.Resolve[*metrics.Dashboard](container); dashboard != nil {
+		dashboard.OnAlert(core.Resolve[*Service](container).HandleAlert)
+	}
+	core.Resolve[*SLAChecker](container).Start()
+
+	incidents := router.Group("/incidents", auth.AuthMiddleware(jwtManager, auth.AcceptAPIKeys()))
+	incidents.Get("", rbac.RequirePermission(rbacManager, "incidents.read"), controller.List)
+	incidents.Post("", rbac.RequirePermission(rbacManager, "incidents.manage"), controller.Create)
+	incidents.Get("/report", rbac.RequirePermission(rbacManager, "incidents.read"), controller.Report)
+	incidents.Get("/:id", rbac.RequirePermission(rbacManager, "incidents.read"), controller.Get)
+	incidents.Post("/:id/acknowledge", rbac.RequirePermission(rbacManager, "incidents.manage"), controller.Acknowledge)
+	incidents.Post("/:id/resolve", rbac.RequirePermission(rbacManager, "incidents.manage"), controller.Resolve)
+	incidents.Post("/:id/notes", rbac.RequirePermission(rbacManager, "incidents.manage"), controller.AddNote)
+	incidents.Post("/:id/snapshots", rbac.RequirePermission(rbacManager, "incidents.manage"), controller.Snapshot)
+}
@@ -0,0 +1,584 @@
+package incidents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/metrics"
+)
+
+// Incident event names
+const (
+	EventIncidentOpened       = "incident.opened"
+	EventIncidentAcknowledged = "incident.acknowledged"
+	EventIncidentResolved     = "incident.resolved"
+	EventIncidentSLABreached  = "incident.sla_breached"
+)
+
+// alertFingerprintPrefix namespaces fingerprints of alert-opened incidents
+const alertFingerprintPrefix = "alert:"
+
+var severities = map[string]bool{
+	SeverityCritical: true, SeverityHigh: true, SeverityMedium: true, SeverityLow: true,
+}
+
+var conditionSymbols = map[metrics.AlertCondition]string{
+	metrics.ConditionGreaterThan: ">",
+	metrics.ConditionLessThan:    "<",
+	metrics.ConditionEquals:      "==",
+	metrics.ConditionNotEquals:   "!=",
+}
+
+// Config holds incident management configuration
+type Config struct {
+	SLAs            map[string]SLA // Response targets per severity
+	DefaultSeverity string         // Severity of alerts without a "severity" metadata entry
+}
+
+// DefaultConfig returns default incident configuration
+func DefaultConfig() Config {
+	return Config{
+		SLAs:            DefaultSLAs(),
+		DefaultSeverity: SeverityHigh,
+	}
+}
+
+// OpenInput is the payload for declaring an incident manually
+type OpenInput struct {
+	Title          string   `json:"title" validate:"required,max=255"`
+	Description    string   `json:"description"`
+	Severity       string   `json:"severity" validate:"required,oneof=critical high medium low"`
+	RelatedMetrics []string `json:"related_metrics" validate:"dive,max=100"`
+}
+
+// NoteInput is the payload for notes and resolutions
+type NoteInput struct {
+	Message string `json:"message" validate:"max=5000"`
+}
+
+type Service struct {
+	repo      *Repository
+	collector *metrics.Collector
+	notifier  Notifier
+	config    Config
+
+	// Serializes alert handling so repeated firings join one incident
+	alertMu sync.Mutex
+}
+
+func NewService(repo *Repository, collector *metrics.Collector, notifier Notifier, config Config) *Service {
+	return &Service{
+		repo:      repo,
+		collector: collector,
+		notifier:  notifier,
+		config:    config,
+	}
+}
+
+// ==================== Alerts ====================
+
+// HandleAlert opens an incident for a fired alert, or records the firing on
+// the alert's open incident. It matches metrics.AlertHandler.
+//
+// Alert metadata may set "severity" and "related_metrics" (a list or a
+// comma-separated string of metric names, "prefix*" allowed) to snapshot
+// alongside the alerting metric.
+func (s *Service) HandleAlert(alert metrics.Alert, metric metrics.Metric) {
+	ctx := context.Background()
+
+	s.alertMu.Lock()
+	defer s.alertMu.Unlock()
+
+	message := fmt.Sprintf("%s is %g (threshold %s %g)", metric.Name, metric.Value, conditionSymbols[alert.Condition], alert.Threshold)
+	fingerprint := alertFingerprintPrefix + alert.Name
+
+	existing, err := s.repo.FindOpenByFingerprint(ctx, fingerprint)
+	if err != nil {
+		logger.Error("Failed to look up incident for alert", logger.Fields{"alert": alert.Name, "error": err.Error()})
+		return
+	}
+	if existing != nil {
+		if err := s.repo.IncrementAlertCount(ctx, existing.ID); err != nil {
+			logger.Warn("Failed to count alert firing", logger.Fields{"incident_id": existing.ID, "error": err.Error()})
+		}
+		// Repeated firings go on the timeline without paging again
+		s.addEntry(ctx, existing, EntryAlert, "Alert fired again: "+message, 0, false)
+		return
+	}
+
+	severity, _ := alert.Metadata["severity"].(string)
+	if !severities[severity] {
+		severity = s.config.DefaultSeverity
+	}
+
+	title := alert.Name
+	if alert.Description != "" {
+		title = alert.Description
+	}
+
+	incident := &Incident{
+		Title:          truncate(title, 255),
+		Description:    message,
+		Severity:       severity,
+		Source:         SourceAlert,
+		Fingerprint:    fingerprint,
+		AlertCount:     1,
+		RelatedMetrics: truncate(strings.Join(append([]string{metric.Name}, metadataNames(alert.Metadata["related_metrics"])...), ","), 1000),
+	}
+	if _, err := s.open(ctx, incident, "Opened from alert "+alert.Name+": "+message); err != nil {
+		logger.Error("Failed to open incident for alert", logger.Fields{"alert": alert.Name, "error": err.Error()})
+	}
+}
+
+// ==================== Incidents ====================
+
+func (s *Service) List(ctx context.Context, filter ListFilter, page, limit int) ([]Incident, int64, error) {
+	incidents, total, err := s.repo.List(ctx, filter, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list incidents").WithError(err)
+	}
+	for i := range incidents {
+		decodeMetricNames(&incidents[i])
+	}
+	return incidents, total, nil
+}
+
+// Get returns an incident with its timeline and snapshots
+func (s *Service) Get(ctx context.Context, id uint) (*Incident, error) {
+	incident, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load incident").WithError(err)
+	}
+	if incident == nil {
+		return nil, errors.NewNotFound("Incident not found")
+	}
+
+	decodeMetricNames(incident)
+	for i := range incident.Snapshots {
+		if err := json.Unmarshal([]byte(incident.Snapshots[i].Metrics), &incident.Snapshots[i].Data); err != nil {
+			incident.Snapshots[i].Data = []MetricValue{}
+		}
+	}
+	return incident, nil
+}
+
+// Open declares an incident manually
+func (s *Service) Open(ctx context.Context, input *OpenInput, userID uint) (*Incident, error) {
+	names := make([]string, 0, len(input.RelatedMetrics))
+	for _, name := range input.RelatedMetrics {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	incident := &Incident{
+		Title:          input.Title,
+		Description:    input.Description,
+		Severity:       input.Severity,
+		Source:         SourceManual,
+		RelatedMetrics: strings.Join(names, ","),
+		CreatedBy:      userID,
+	}
+
+	created, err := s.open(ctx, incident, "Incident declared")
+	if err != nil {
+		return nil, errors.NewInternal("Failed to open incident").WithError(err)
+	}
+	return s.Get(ctx, created.ID)
+}
+
+// Acknowledge marks an incident as being worked on
+func (s *Service) Acknowledge(ctx context.Context, id, userID uint) (*Incident, error) {
+	incident, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if incident.Resolved() {
+		return nil, errors.NewBadRequest("Incident is already resolved")
+	}
+	if incident.AcknowledgedAt != nil {
+		return nil, errors.NewConflict("Incident is already acknowledged")
+	}
+
+	now := time.Now()
+	incident.Status = StatusAcknowledged
+	incident.AcknowledgedAt = &now
+	incident.AcknowledgedBy = userID
+	if now.After(incident.AckDueAt) {
+		incident.AckBreached = true
+	}
+	if err := s.repo.Update(ctx, incident); err != nil {
+		return nil, errors.NewInternal("Failed to acknowledge incident").WithError(err)
+	}
+
+	message := fmt.Sprintf("Acknowledged after %s", incident.TimeToAcknowledge().Round(time.Second))
+	s.addEntry(ctx, incident, EntryAcknowledged, message, userID, true)
+	s.dispatch(ctx, EventIncidentAcknowledged, incident)
+
+	return s.Get(ctx, id)
+}
+
+// Resolve closes an incident, capturing a final metrics snapshot
+func (s *Service) Resolve(ctx context.Context, id, userID uint, note string) (*Incident, error) {
+	incident, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if incident.Resolved() {
+		return nil, errors.NewConflict("Incident is already resolved")
+	}
+
+	now := time.Now()
+	if incident.AcknowledgedAt == nil {
+		// Resolving implies acknowledgement
+		incident.AcknowledgedAt = &now
+		incident.AcknowledgedBy = userID
+		if now.After(incident.AckDueAt) {
+			incident.AckBreached = true
+		}
+	}
+	incident.Status = StatusResolved
+	incident.ResolvedAt = &now
+	incident.ResolvedBy = userID
+	if now.After(incident.ResolveDueAt) {
+		incident.ResolveBreached = true
+	}
+	if err := s.repo.Update(ctx, incident); err != nil {
+		return nil, errors.NewInternal("Failed to resolve incident").WithError(err)
+	}
+
+	s.snapshot(ctx, incident, "resolved")
+
+	message := fmt.Sprintf("Resolved after %s", incident.TimeToResolve().Round(time.Second))
+	if note = strings.TrimSpace(note); note != "" {
+		message += ": " + note
+	}
+	s.addEntry(ctx, incident, EntryResolved, message, userID, true)
+	s.dispatch(ctx, EventIncidentResolved, incident)
+
+	return s.Get(ctx, id)
+}
+
+// AddNote posts a note to an incident's timeline
+func (s *Service) AddNote(ctx context.Context, id, userID uint, message string) (*Incident, error) {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return nil, errors.NewBadRequest("Message is required")
+	}
+
+	incident, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.addEntry(ctx, incident, EntryNote, message, userID, true)
+	return s.Get(ctx, id)
+}
+
+// Snapshot captures the incident's related metrics on demand
+func (s *Service) Snapshot(ctx context.Context, id uint) (*Incident, error) {
+	incident, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.snapshot(ctx, incident, "manual") == nil {
+		return nil, errors.NewBadRequest("Incident has no related metrics to capture")
+	}
+	return s.Get(ctx, id)
+}
+
+// Report summarizes SLA performance for incidents opened since the given time
+func (s *Service) Report(ctx context.Context, since time.Time) (*Report, error) {
+	incidents, err := s.repo.CreatedSince(ctx, since)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to build incident report").WithError(err)
+	}
+
+	report := &Report{
+		Since:             since,
+		Total:             len(incidents),
+		BySeverity:        make(map[string]int),
+		AckCompliance:     100,
+		ResolveCompliance: 100,
+		Policies:          s.config.SLAs,
+	}
+
+	var ackTotal, resolveTotal time.Duration
+	for i := range incidents {
+		incident := &incidents[i]
+		report.BySeverity[incident.Severity]++
+
+		switch incident.Status {
+		case StatusOpen:
+			report.Open++
+		case StatusAcknowledged:
+			report.Acknowledged++
+		case StatusResolved:
+			report.Resolved++
+		}
+		if incident.AckBreached {
+			report.AckBreaches++
+		}
+		if incident.ResolveBreached {
+			report.ResolveBreaches++
+		}
+		ackTotal += incident.TimeToAcknowledge()
+		resolveTotal += incident.TimeToResolve()
+	}
+
+	acked := report.Acknowledged + report.Resolved
+	if acked > 0 {
+		report.MeanTimeToAck = (ackTotal / time.Duration(acked)).Seconds()
+	}
+	if report.Resolved > 0 {
+		report.MeanTimeToResolve = (resolveTotal / time.Duration(report.Resolved)).Seconds()
+	}
+	if report.Total > 0 {
+		report.AckCompliance = compliance(report.AckBreaches, report.Total)
+		report.ResolveCompliance = compliance(report.ResolveBreaches, report.Total)
+	}
+	return report, nil
+}
+
+// ==================== SLA ====================
+
+// CheckSLA flags incidents that passed their acknowledgement or resolution
+// targets without meeting them
+func (s *Service) CheckSLA(ctx context.Context, now time.Time) error {
+	overdue, err := s.repo.FindAckOverdue(ctx, now)
+	if err != nil {
+		return err
+	}
+	for i := range overdue {
+		incident := &overdue[i]
+		incident.AckBreached = true
+		if err := s.repo.Update(ctx, incident); err != nil {
+			return err
+		}
+		s.breach(ctx, incident, "acknowledge", s.sla(incident.Severity).AckWithin)
+	}
+
+	overdue, err = s.repo.FindResolveOverdue(ctx, now)
+	if err != nil {
+		return err
+	}
+	for i := range overdue {
+		incident := &overdue[i]
+		incident.ResolveBreached = true
+		if err := s.repo.Update(ctx, incident); err != nil {
+			return err
+		}
+		s.breach(ctx, incident, "resolve", s.sla(incident.Severity).ResolveWithin)
+	}
+	return nil
+}
+
+func (s *Service) breach(ctx context.Context, incident *Incident, target string, within time.Duration) {
+	message := fmt.Sprintf("SLA breached: not %sd within %s", target, within)
+	s.addEntry(ctx, incident, EntrySLABreach, message, 0, true)
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventIncidentSLABreached,
+		Data: map[string]interface{}{
+			"incident_id": incident.ID,
+			"severity":    incident.Severity,
+			"target":      target,
+		},
+	})
+}
+
+// sla returns the targets for a severity, falling back to the default
+// severity's
+func (s *Service) sla(severity string) SLA {
+	if sla, ok := s.config.SLAs[severity]; ok {
+		return sla
+	}
+	return s.config.SLAs[s.config.DefaultSeverity]
+}
+
+// ==================== Helpers ====================
+
+// open stores a new incident with its SLA deadlines, snapshots its metrics
+// and announces it
+func (s *Service) open(ctx context.Context, incident *Incident, message string) (*Incident, error) {
+	now := time.Now()
+	sla := s.sla(incident.Severity)
+	incident.Status = StatusOpen
+	incident.AckDueAt = now.Add(sla.AckWithin)
+	incident.ResolveDueAt = now.Add(sla.ResolveWithin)
+
+	if err := s.repo.Create(ctx, incident); err != nil {
+		return nil, err
+	}
+
+	s.addEntry(ctx, incident, EntryOpened, message, incident.CreatedBy, true)
+	s.snapshot(ctx, incident, "opened")
+	s.dispatch(ctx, EventIncidentOpened, incident)
+	return incident, nil
+}
+
+func (s *Service) load(ctx context.Context, id uint) (*Incident, error) {
+	incident, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load incident").WithError(err)
+	}
+	if incident == nil {
+		return nil, errors.NewNotFound("Incident not found")
+	}
+	return incident, nil
+}
+
+// addEntry appends to the timeline and, when notify is set, posts the
+// entry to the notifier in the background
+func (s *Service) addEntry(ctx context.Context, incident *Incident, kind, message string, userID uint, notify bool) *TimelineEntry {
+	entry := &TimelineEntry{
+		IncidentID: incident.ID,
+		Kind:       kind,
+		Message:    message,
+		UserID:     userID,
+	}
+	if err := s.repo.AddEntry(ctx, entry); err != nil {
+		logger.Warn("Failed to record incident timeline entry", logger.Fields{"incident_id": incident.ID, "error": err.Error()})
+		return nil
+	}
+
+	if notify && s.notifier != nil {
+		snapshot := *incident
+		go func() {
+			if err := s.notifier.Notify(context.Background(), &snapshot, entry); err != nil {
+				logger.Warn("Failed to post incident update", logger.Fields{"incident_id": incident.ID, "error": err.Error()})
+			}
+		}()
+	}
+	return entry
+}
+
+// snapshot captures the incident's related metrics and links the capture
+// from the timeline. It returns nil when there is nothing to capture.
+func (s *Service) snapshot(ctx context.Context, incident *Incident, reason string) *Snapshot {
+	if s.collector == nil || incident.RelatedMetrics == "" {
+		return nil
+	}
+
+	patterns := strings.Split(incident.RelatedMetrics, ",")
+	values := make([]MetricValue, 0, len(patterns))
+	for _, metric := range s.collector.GetAllMetrics() {
+		if matchesAny(metric.Name, patterns) {
+			values = append(values, MetricValue{
+				Name:   metric.Name,
+				Type:   string(metric.Type),
+				Value:  metric.Value,
+				Labels: metric.Labels,
+			})
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil
+	}
+	snapshot := &Snapshot{
+		IncidentID: incident.ID,
+		Reason:     reason,
+		Metrics:    string(data),
+		CapturedAt: time.Now(),
+		Data:       values,
+	}
+	if err := s.repo.AddSnapshot(ctx, snapshot); err != nil {
+		logger.Warn("Failed to store incident metrics snapshot", logger.Fields{"incident_id": incident.ID, "error": err.Error()})
+		return nil
+	}
+
+	entry := &TimelineEntry{
+		IncidentID: incident.ID,
+		Kind:       EntrySnapshot,
+		Message:    fmt.Sprintf("Captured %d related metrics (%s)", len(values), reason),
+		SnapshotID: &snapshot.ID,
+	}
+	if err := s.repo.AddEntry(ctx, entry); err != nil {
+		logger.Warn("Failed to record incident timeline entry", logger.Fields{"incident_id": incident.ID, "error": err.Error()})
+	}
+	return snapshot
+}
+
+func (s *Service) dispatch(ctx context.Context, name string, incident *Incident) {
+	events.DispatchAsync(ctx, events.Event{
+		Name: name,
+		Data: map[string]interface{}{
+			"incident_id": incident.ID,
+			"title":       incident.Title,
+			"severity":    incident.Severity,
+			"status":      incident.Status,
+			"source":      incident.Source,
+		},
+	})
+}
+
+// matchesAny reports whether name equals a pattern or starts with a
+// pattern's prefix when it ends in "*"
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// metadataNames reads metric names from alert metadata
+func metadataNames(value interface{}) []string {
+	var names []string
+	switch v := value.(type) {
+	case string:
+		names = strings.Split(v, ",")
+	case []string:
+		names = v
+	case []interface{}:
+		for _, item := range v {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+func decodeMetricNames(incident *Incident) {
+	incident.MetricNames = []string{}
+	if incident.RelatedMetrics != "" {
+		incident.MetricNames = strings.Split(incident.RelatedMetrics, ",")
+	}
+}
+
+func compliance(breaches, total int) float64 {
+	return float64((total-breaches)*10000/total) / 100
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
@@ -0,0 +1,83 @@
+package incidents
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/logger"
+)
+
+// SLA is the response target for a severity
+type SLA struct {
+	AckWithin     time.Duration `json:"ack_within"`
+	ResolveWithin time.Duration `json:"resolve_within"`
+}
+
+// DefaultSLAs returns the default response targets per severity
+func DefaultSLAs() map[string]SLA {
+	return map[string]SLA{
+		SeverityCritical: {AckWithin: 5 * time.Minute, ResolveWithin: time.Hour},
+		SeverityHigh:     {AckWithin: 15 * time.Minute, ResolveWithin: 4 * time.Hour},
+		SeverityMedium:   {AckWithin: time.Hour, ResolveWithin: 24 * time.Hour},
+		SeverityLow:      {AckWithin: 4 * time.Hour, ResolveWithin: 72 * time.Hour},
+	}
+}
+
+// SLAChecker periodically flags incidents that missed their targets
+type SLAChecker struct {
+	service  *Service
+	interval time.Duration
+
+	mu      sync.Mutex
+	started bool
+	stop    chan struct{}
+}
+
+// NewSLAChecker creates an SLA checker running every interval
+func NewSLAChecker(service *Service, interval time.Duration) *SLAChecker {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &SLAChecker{service: service, interval: interval}
+}
+
+// Start begins checking in the background. It is safe to call more than once.
+func (c *SLAChecker) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.started {
+		return
+	}
+	c.started = true
+	c.stop = make(chan struct{})
+	go c.run(c.stop)
+}
+
+// Stop ends checking
+func (c *SLAChecker) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.started {
+		close(c.stop)
+		c.started = false
+	}
+}
+
+func (c *SLAChecker) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.service.CheckSLA(context.Background(), time.Now()); err != nil {
+				logger.Warn("Failed to check incident SLAs", logger.Fields{"error": err.Error()})
+			}
+		case <-stop:
+			return
+		}
+	}
+}
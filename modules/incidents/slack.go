@@ -0,0 +1,120 @@
+package incidents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/httpclient"
+)
+
+// Notifier publishes incident timeline updates
+type Notifier interface {
+	Notify(ctx context.Context, incident *Incident, entry *TimelineEntry) error
+}
+
+// severityColors are Slack attachment colors per severity
+var severityColors = map[string]string{
+	SeverityCritical: "#dc2626",
+	SeverityHigh:     "#ea580c",
+	SeverityMedium:   "#ca8a04",
+	SeverityLow:      "#2563eb",
+}
+
+// SlackNotifier posts timeline updates to a Slack incoming webhook
+type SlackNotifier struct {
+	webhookURL string
+	channel    string
+	linkURL    string
+	client     *http.Client
+}
+
+// NewSlackNotifier creates a Slack notifier. channel overrides the
+// webhook's default channel when set; linkURL, when set, is the base URL
+// incident IDs are appended to for links back to the incident.
+func NewSlackNotifier(webhookURL, channel, linkURL string) *SlackNotifier {
+	return &SlackNotifier{
+		webhookURL: webhookURL,
+		channel:    channel,
+		linkURL:    strings.TrimRight(linkURL, "/"),
+		client:     httpclient.New(10 * time.Second),
+	}
+}
+
+type slackMessage struct {
+	Channel     string            `json:"channel,omitempty"`
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color     string       `json:"color"`
+	Title     string       `json:"title"`
+	TitleLink string       `json:"title_link,omitempty"`
+	Text      string       `json:"text"`
+	Fields    []slackField `json:"fields"`
+	Ts        int64        `json:"ts"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// Notify posts the entry to Slack
+func (n *SlackNotifier) Notify(ctx context.Context, incident *Incident, entry *TimelineEntry) error {
+	if events.Stubbed(ctx, "slack", map[string]interface{}{"incident_id": incident.ID, "kind": entry.Kind}) {
+		return nil
+	}
+	attachment := slackAttachment{
+		Color: severityColors[incident.Severity],
+		Title: fmt.Sprintf("#%d %s", incident.ID, incident.Title),
+		Text:  entry.Message,
+		Fields: []slackField{
+			{Title: "Severity", Value: incident.Severity, Short: true},
+			{Title: "Status", Value: incident.Status, Short: true},
+		},
+		Ts: entry.CreatedAt.Unix(),
+	}
+	if n.linkURL != "" {
+		attachment.TitleLink = fmt.Sprintf("%s/%d", n.linkURL, incident.ID)
+	}
+	if !incident.Resolved() {
+		attachment.Fields = append(attachment.Fields,
+			slackField{Title: "Ack due", Value: incident.AckDueAt.UTC().Format(time.RFC822), Short: true},
+			slackField{Title: "Resolve due", Value: incident.ResolveDueAt.UTC().Format(time.RFC822), Short: true},
+		)
+	}
+
+	body, err := json.Marshal(slackMessage{
+		Channel:     n.channel,
+		Text:        fmt.Sprintf("Incident #%d %s", incident.ID, strings.ReplaceAll(entry.Kind, "_", " ")),
+		Attachments: []slackAttachment{attachment},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
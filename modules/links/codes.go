@@ -0,0 +1,33 @@
+package links
+
+import (
+	"crypto/rand"
+	"math/big"
+	"regexp"
+)
+
+const codeAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// aliasRegex limits custom codes to URL-safe characters
+var aliasRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// reservedCodes cannot be used as custom aliases
+var reservedCodes = map[string]bool{
+	"api": true, "admin": true, "health": true, "metrics": true,
+	"dashboard": true, "storage": true, "swagger": true, "ws": true,
+}
+
+// generateCode returns a random code of the given length. The alphabet
+// leaves out characters that are easily confused (0/O, 1/l/I).
+func generateCode(length int) (string, error) {
+	max := big.NewInt(int64(len(codeAlphabet)))
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = codeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
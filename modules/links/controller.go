@@ -0,0 +1,272 @@
+package links
+
+import (
+	"io"
+	"strings"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// countryHeaders are CDN headers carrying the client's country code
+var countryHeaders = []string{"CF-IPCountry", "CloudFront-Viewer-Country", "X-Country-Code"}
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// List lists short links
+// @Summary List links
+// @Tags Links
+// @Security BearerAuth
+// @Produce json
+// @Param source query string false "Source (api, notification, web3)"
+// @Success 200 {object} api.Response{data=[]Link}
+// @Router /links [get]
+func (c *Controller) List(ctx *fiber.Ctx) error {
+	pagination := api.GetPagination(ctx)
+
+	links, total, err := c.service.List(ctx.Context(), ctx.Query("source"), pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, links, pagination.Page, pagination.Limit, total)
+}
+
+// Get retrieves a short link
+// @Summary Get link
+// @Tags Links
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Link ID"
+// @Success 200 {object} api.Response{data=Link}
+// @Failure 404 {object} api.Response
+// @Router /links/{id} [get]
+func (c *Controller) Get(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid link ID", nil)
+	}
+
+	link, err := c.service.Get(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, link)
+}
+
+// Create creates a short link
+// @Summary Create link
+// @Description Shorten a URL, optionally with a custom alias, domain and expiry
+// @Tags Links
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param link body ShortenInput true "Link"
+// @Success 201 {object} api.Response{data=Link}
+// @Failure 409 {object} api.Response
+// @Failure 422 {object} api.Response
+// @Router /links [post]
+func (c *Controller) Create(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input ShortenInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	link, err := c.service.Shorten(ctx.Context(), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Link created", link)
+}
+
+// Update updates a short link
+// @Summary Update link
+// @Tags Links
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Link ID"
+// @Param link body UpdateInput true "Link"
+// @Success 200 {object} api.Response{data=Link}
+// @Router /links/{id} [put]
+func (c *Controller) Update(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid link ID", nil)
+	}
+
+	var input UpdateInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	link, err := c.service.Update(ctx.Context(), uint(id), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, link)
+}
+
+// Delete deletes a short link
+// @Summary Delete link
+// @Tags Links
+// @Security BearerAuth
+// @Param id path int true "Link ID"
+// @Success 204
+// @Router /links/{id} [delete]
+func (c *Controller) Delete(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid link ID", nil)
+	}
+
+	if err := c.service.Delete(ctx.Context(), uint(id)); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.NoContent(ctx)
+}
+
+// Stats returns click breakdowns for a link
+// @Summary Link click stats
+// @Description Click totals with country, device, browser, OS, referrer and daily breakdowns
+// @Tags Links
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Link ID"
+// @Param days query int false "Window in days (default 30)"
+// @Success 200 {object} api.Response{data=Stats}
+// @Router /links/{id}/stats [get]
+func (c *Controller) Stats(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid link ID", nil)
+	}
+
+	stats, err := c.service.Stats(ctx.Context(), uint(id), ctx.QueryInt("days", 30))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, stats)
+}
+
+// QRCode returns the QR code image of a link
+// @Summary Link QR code
+// @Tags Links
+// @Security BearerAuth
+// @Produce png
+// @Param id path int true "Link ID"
+// @Success 200 {file} binary
+// @Router /links/{id}/qr [get]
+func (c *Controller) QRCode(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid link ID", nil)
+	}
+
+	link, err := c.service.Get(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return c.sendQR(ctx, link)
+}
+
+// ==================== Public ====================
+
+// Redirect sends the client to a link's target and records the click
+// @Summary Follow short link
+// @Tags Links
+// @Param code path string true "Short code"
+// @Success 302
+// @Failure 404 {object} api.Response
+// @Failure 410 {object} api.Response
+// @Router /l/{code} [get]
+func (c *Controller) Redirect(ctx *fiber.Ctx) error {
+	return c.redirect(ctx, "", ctx.Params("code"))
+}
+
+// PublicQRCode returns the QR code image of an active link
+// @Summary Short link QR code
+// @Tags Links
+// @Produce png
+// @Param code path string true "Short code"
+// @Success 200 {file} binary
+// @Router /l/{code}/qr [get]
+func (c *Controller) PublicQRCode(ctx *fiber.Ctx) error {
+	return c.publicQR(ctx, "", ctx.Params("code"))
+}
+
+// DomainRedirect serves short links on custom domains. Requests for other
+// hosts fall through to the rest of the application.
+func (c *Controller) DomainRedirect(ctx *fiber.Ctx) error {
+	host := ctx.Hostname()
+	if !c.service.IsDomain(host) || ctx.Method() != fiber.MethodGet {
+		return ctx.Next()
+	}
+
+	parts := strings.Split(strings.Trim(ctx.Path(), "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return c.redirect(ctx, host, parts[0])
+	case len(parts) == 2 && parts[1] == "qr":
+		return c.publicQR(ctx, host, parts[0])
+	}
+	return ctx.Next()
+}
+
+func (c *Controller) redirect(ctx *fiber.Ctx, domain, code string) error {
+	link, err := c.service.Resolve(ctx.Context(), domain, code)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	info := ClickInfo{
+		IP:        ctx.IP(),
+		UserAgent: ctx.Get(fiber.HeaderUserAgent),
+		Referrer:  ctx.Get(fiber.HeaderReferer),
+	}
+	for _, header := range countryHeaders {
+		if country := ctx.Get(header); country != "" {
+			info.Country = country
+			break
+		}
+	}
+	c.service.Track(link, info)
+
+	// Every click must reach us to be counted
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	return ctx.Redirect(link.TargetURL, fiber.StatusFound)
+}
+
+func (c *Controller) publicQR(ctx *fiber.Ctx, domain, code string) error {
+	link, err := c.service.Resolve(ctx.Context(), domain, code)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	return c.sendQR(ctx, link)
+}
+
+func (c *Controller) sendQR(ctx *fiber.Ctx, link *Link) error {
+	r, err := c.service.QRCode(ctx.Context(), link)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	defer r.Close()
+
+	png, err := io.ReadAll(r)
+	if err != nil {
+		return api.InternalError(ctx, "Failed to read QR code")
+	}
+	ctx.Set(fiber.HeaderContentType, "image/png")
+	return ctx.Send(png)
+}
@@ -0,0 +1,67 @@
+package links
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/metrics"
+	"neonexcore/pkg/storage"
+
+	"gorm.io/gorm"
+)
+
+const defaultQRSize = 256
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register QR Generator backed by the application storage
+	container.Provide(func() *QRGenerator {
+		store := core.Resolve[storage.Storage](container)
+		if store == nil {
+			cfg := storage.LoadConfig()
+			store = storage.NewLocalStorage(cfg.Root, cfg.BaseURL)
+		}
+		size, _ := strconv.Atoi(os.Getenv("LINKS_QR_SIZE"))
+		if size <= 0 {
+			size = defaultQRSize
+		}
+		return NewQRGenerator(store, size)
+	}, core.Singleton)
+
+	// Register Service
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		if baseURL := os.Getenv("LINKS_BASE_URL"); baseURL != "" {
+			config.BaseURL = baseURL
+		}
+		if domains := os.Getenv("LINKS_DOMAINS"); domains != "" {
+			for _, d := range strings.Split(domains, ",") {
+				if d = strings.TrimSpace(d); d != "" {
+					config.Domains = append(config.Domains, d)
+				}
+			}
+		}
+		if length, err := strconv.Atoi(os.Getenv("LINKS_CODE_LENGTH")); err == nil && length > 0 {
+			config.CodeLength = length
+		}
+		config.VisitorSalt = os.Getenv("LINKS_VISITOR_SALT")
+
+		return NewService(
+			core.Resolve[*Repository](container),
+			core.Resolve[*QRGenerator](container),
+			core.Resolve[*metrics.Collector](container),
+			config,
+		)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
@@ -0,0 +1,28 @@
+package links
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LinksModule struct{}
+
+func New() *LinksModule {
+	return &LinksModule{}
+}
+
+func (m *LinksModule) Name() string {
+	return "links"
+}
+
+func (m *LinksModule) Init() {}
+
+func (m *LinksModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *LinksModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
@@ -0,0 +1,83 @@
+package links
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Device classes reported in click breakdowns
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceUnknown = "unknown"
+)
+
+// Link is a short code that redirects to a target URL. Domain is empty for
+// links served from the default base URL.
+type Link struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	Domain    string         `gorm:"size:255;uniqueIndex:idx_links_domain_code;not null;default:''" json:"domain,omitempty"`
+	Code      string         `gorm:"size:64;uniqueIndex:idx_links_domain_code;not null" json:"code"`
+	TargetURL string         `gorm:"type:text;not null" json:"target_url"`
+	Title     string         `gorm:"size:255" json:"title,omitempty"`
+	Source    string         `gorm:"size:50;index" json:"source,omitempty"` // Originating feature, e.g. notification, web3
+	Active    bool           `gorm:"default:true" json:"active"`
+	ExpiresAt *time.Time     `gorm:"index" json:"expires_at,omitempty"`
+	Clicks    int64          `gorm:"default:0" json:"clicks"`
+	QRKey     string         `gorm:"size:255" json:"-"` // Storage key of the rendered QR code
+	CreatedBy uint           `gorm:"index" json:"created_by,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	ShortURL string `gorm:"-" json:"short_url"`
+}
+
+// TableName specifies the table name for Link
+func (Link) TableName() string {
+	return "links"
+}
+
+// Expired reports whether the link is past its expiry time
+func (l *Link) Expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// Click records a single redirect
+type Click struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	LinkID    uint      `gorm:"index;not null" json:"link_id"`
+	Country   string    `gorm:"size:2;index" json:"country,omitempty"`
+	Device    string    `gorm:"size:20" json:"device"`
+	OS        string    `gorm:"size:50" json:"os,omitempty"`
+	Browser   string    `gorm:"size:50" json:"browser,omitempty"`
+	Referrer  string    `gorm:"size:255" json:"referrer,omitempty"` // Referrer host only
+	VisitorID string    `gorm:"size:64;index" json:"-"`             // Salted hash of IP and user agent
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name for Click
+func (Click) TableName() string {
+	return "link_clicks"
+}
+
+// Count is a labelled count in a breakdown
+type Count struct {
+	Key   string `gorm:"column:bucket" json:"key"`
+	Count int64  `gorm:"column:hits" json:"count"`
+}
+
+// Stats summarises the clicks on a link
+type Stats struct {
+	Total     int64   `json:"total"`
+	Unique    int64   `json:"unique"`
+	Countries []Count `json:"countries"`
+	Devices   []Count `json:"devices"`
+	Browsers  []Count `json:"browsers"`
+	OS        []Count `json:"os"`
+	Referrers []Count `json:"referrers"`
+	Daily     []Count `json:"daily"`
+}
@@ -0,0 +1,64 @@
+package links
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"neonexcore/pkg/storage"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QRGenerator renders QR codes for short URLs and keeps them in storage
+type QRGenerator struct {
+	store storage.Storage
+	size  int
+}
+
+// NewQRGenerator creates a QR generator producing size x size PNGs
+func NewQRGenerator(store storage.Storage, size int) *QRGenerator {
+	if size <= 0 {
+		size = 256
+	}
+	return &QRGenerator{store: store, size: size}
+}
+
+// Key returns the storage key for a link's QR code
+func (g *QRGenerator) Key(link *Link) string {
+	if link.Domain == "" {
+		return fmt.Sprintf("links/qr/%s.png", link.Code)
+	}
+	return fmt.Sprintf("links/qr/%s/%s.png", link.Domain, link.Code)
+}
+
+// Render encodes content as a PNG and stores it under the link's key
+func (g *QRGenerator) Render(ctx context.Context, link *Link, content string) (string, error) {
+	png, err := qrcode.Encode(content, qrcode.Medium, g.size)
+	if err != nil {
+		return "", err
+	}
+
+	key := g.Key(link)
+	if err := g.store.Put(ctx, key, bytes.NewReader(png), "image/png"); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Open returns the stored QR code
+func (g *QRGenerator) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	r, _, err := g.store.Get(ctx, key)
+	return r, err
+}
+
+// Remove deletes a stored QR code
+func (g *QRGenerator) Remove(ctx context.Context, key string) error {
+	return g.store.Delete(ctx, key)
+}
+
+// URL returns the storage URL of a QR code
+func (g *QRGenerator) URL(key string) string {
+	return g.store.URL(key)
+}
@@ -0,0 +1,144 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ==================== Links ====================
+
+func (r *Repository) List(ctx context.Context, source string, page, limit int) ([]Link, int64, error) {
+	var links []Link
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Link{})
+	if source != "" {
+		query = query.Where("source = ?", source)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&links).Error
+	return links, total, err
+}
+
+func (r *Repository) FindByID(ctx context.Context, id uint) (*Link, error) {
+	var link Link
+	err := r.db.WithContext(ctx).First(&link, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *Repository) FindByCode(ctx context.Context, domain, code string) (*Link, error) {
+	var link Link
+	err := r.db.WithContext(ctx).Where("domain = ? AND code = ?", domain, code).First(&link).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+// CodeExists reports whether a code is taken on a domain, including by
+// deleted links so old short URLs are never reassigned
+func (r *Repository) CodeExists(ctx context.Context, domain, code string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Unscoped().Model(&Link{}).
+		Where("domain = ? AND code = ?", domain, code).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *Repository) Create(ctx context.Context, link *Link) error {
+	return r.db.WithContext(ctx).Create(link).Error
+}
+
+func (r *Repository) Update(ctx context.Context, link *Link) error {
+	return r.db.WithContext(ctx).Save(link).Error
+}
+
+func (r *Repository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&Link{}, id).Error
+}
+
+// ==================== Clicks ====================
+
+// RecordClick stores a click and bumps the link's counter
+func (r *Repository) RecordClick(ctx context.Context, click *Click) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(click).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Link{}).Where("id = ?", click.LinkID).
+			UpdateColumn("clicks", gorm.Expr("clicks + ?", 1)).Error
+	})
+}
+
+// Stats aggregates clicks on a link since the given time
+func (r *Repository) Stats(ctx context.Context, linkID uint, since time.Time, top int) (*Stats, error) {
+	base := func() *gorm.DB {
+		return r.db.WithContext(ctx).Model(&Click{}).Where("link_id = ? AND created_at >= ?", linkID, since)
+	}
+
+	stats := &Stats{}
+	if err := base().Count(&stats.Total).Error; err != nil {
+		return nil, err
+	}
+	if err := base().Distinct("visitor_id").Count(&stats.Unique).Error; err != nil {
+		return nil, err
+	}
+
+	breakdowns := []struct {
+		column string
+		target *[]Count
+	}{
+		{"country", &stats.Countries},
+		{"device", &stats.Devices},
+		{"browser", &stats.Browsers},
+		{"os", &stats.OS},
+		{"referrer", &stats.Referrers},
+	}
+	for _, b := range breakdowns {
+		err := base().
+			Select(b.column + " AS bucket, COUNT(*) AS hits").
+			Group(b.column).
+			Order("hits DESC").
+			Limit(top).
+			Scan(b.target).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := base().
+		Select("DATE(created_at) AS bucket, COUNT(*) AS hits").
+		Group("DATE(created_at)").
+		Order("bucket ASC").
+		Scan(&stats.Daily).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
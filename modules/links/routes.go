@@ -0,0 +1,34 @@
+package links
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	// ==================== Redirects ====================
+	// Short URLs live outside /api/v1, on the root app and custom domains
+	if app := core.Resolve[*fiber.App](container); app != nil {
+		app.Use(controller.DomainRedirect)
+		app.Get("/l/:code", controller.Redirect)
+		app.Get("/l/:code/qr", controller.PublicQRCode)
+	}
+
+	// ==================== Management API ====================
+	links := router.Group("/links", auth.AuthMiddleware(jwtManager, auth.AcceptAPIKeys()))
+	links.Get("", rbac.RequirePermission(rbacManager, "links.manage"), controller.List)
+	links.Post("", rbac.RequirePermission(rbacManager, "links.manage"), controller.Create)
+	links.Get("/:id", rbac.RequirePermission(rbacManager, "links.manage"), controller.Get)
+	links.Put("/:id", rbac.RequirePermission(rbacManager, "links.manage"), controller.Update)
+	links.Delete("/:id", rbac.RequirePermission(rbacManager, "links.manage"), controller.Delete)
+	links.Get("/:id/stats", rbac.RequirePermission(rbacManager, "links.stats.read"), controller.Stats)
+	links.Get("/:id/qr", rbac.RequirePermission(rbacManager, "links.manage"), controller.QRCode)
+}
@@ -0,0 +1,497 @@
+package links
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/metrics"
+	"neonexcore/pkg/storage"
+)
+
+// Link event names
+const (
+	EventLinkCreated = "links.created"
+)
+
+// Link sources used by other modules
+const (
+	SourceAPI          = "api"
+	SourceNotification = "notification"
+	SourceWeb3         = "web3"
+)
+
+const (
+	codeAttempts  = 5
+	statsTopLimit = 10
+)
+
+// allowedSchemes are the target URL schemes links may redirect to.
+// ethereum: carries EIP-681 payment requests to wallet apps.
+var allowedSchemes = map[string]bool{"http": true, "https": true, "ethereum": true}
+
+// Config holds link service configuration
+type Config struct {
+	BaseURL     string   // Short URL prefix for links without a custom domain
+	Domains     []string // Custom domains links may be created on
+	CodeLength  int      // Length of generated codes
+	VisitorSalt string   // Salt for hashing visitor IPs in click records
+}
+
+// DefaultConfig returns default link configuration
+func DefaultConfig() Config {
+	return Config{
+		BaseURL:    "http://localhost:8080/l",
+		CodeLength: 7,
+	}
+}
+
+// GeoResolver maps a client IP to an ISO 3166-1 alpha-2 country code
+type GeoResolver interface {
+	Country(ctx context.Context, ip string) string
+}
+
+// ShortenInput is the payload for creating a link
+type ShortenInput struct {
+	URL       string     `json:"url" validate:"required,max=2048"`
+	Alias     string     `json:"alias" validate:"omitempty,min=3,max=64"`
+	Domain    string     `json:"domain" validate:"omitempty,hostname"`
+	Title     string     `json:"title" validate:"max=255"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Source    string     `json:"-"`
+}
+
+// UpdateInput is the payload for updating a link
+type UpdateInput struct {
+	URL       string     `json:"url" validate:"required,max=2048"`
+	Title     string     `json:"title" validate:"max=255"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Active    *bool      `json:"active"`
+}
+
+// ClickInfo describes the request behind a redirect
+type ClickInfo struct {
+	IP        string
+	UserAgent string
+	Referrer  string
+	Country   string // From a trusted CDN header, if any
+}
+
+type Service struct {
+	repo    *Repository
+	qr      *QRGenerator
+	metrics *metrics.Collector
+	geo     GeoResolver
+	config  Config
+	domains map[string]bool
+}
+
+func NewService(repo *Repository, qr *QRGenerator, collector *metrics.Collector, config Config) *Service {
+	if config.CodeLength <= 0 {
+		config.CodeLength = DefaultConfig().CodeLength
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+
+	s := &Service{
+		repo:    repo,
+		qr:      qr,
+		metrics: collector,
+		config:  config,
+		domains: make(map[string]bool, len(config.Domains)),
+	}
+	for _, d := range config.Domains {
+		s.domains[strings.ToLower(d)] = true
+	}
+	return s
+}
+
+// SetGeoResolver sets the IP geolocation fallback used when no CDN
+// country header is present
+func (s *Service) SetGeoResolver(geo GeoResolver) {
+	s.geo = geo
+}
+
+// IsDomain reports whether host is a configured custom domain
+func (s *Service) IsDomain(host string) bool {
+	return s.domains[strings.ToLower(host)]
+}
+
+// ShortURL returns the public short URL of a link
+func (s *Service) ShortURL(link *Link) string {
+	if link.Domain != "" {
+		return "https://" + link.Domain + "/" + link.Code
+	}
+	return s.config.BaseURL + "/" + link.Code
+}
+
+// ==================== Links ====================
+
+func (s *Service) List(ctx context.Context, source string, page, limit int) ([]Link, int64, error) {
+	links, total, err := s.repo.List(ctx, source, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list links").WithError(err)
+	}
+	for i := range links {
+		links[i].ShortURL = s.ShortURL(&links[i])
+	}
+	return links, total, nil
+}
+
+func (s *Service) Get(ctx context.Context, id uint) (*Link, error) {
+	link, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load link").WithError(err)
+	}
+	if link == nil {
+		return nil, errors.NewNotFound("Link not found")
+	}
+	link.ShortURL = s.ShortURL(link)
+	return link, nil
+}
+
+// Shorten creates a short link, with a generated code unless an alias is given
+func (s *Service) Shorten(ctx context.Context, input *ShortenInput, userID uint) (*Link, error) {
+	if err := s.checkTarget(input.URL); err != nil {
+		return nil, err
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, errors.NewBadRequest("Expiry must be in the future")
+	}
+
+	domain := strings.ToLower(input.Domain)
+	if domain != "" && !s.domains[domain] {
+		return nil, errors.NewBadRequest("Domain is not configured for short links")
+	}
+
+	code, err := s.allocateCode(ctx, domain, input.Alias)
+	if err != nil {
+		return nil, err
+	}
+
+	source := input.Source
+	if source == "" {
+		source = SourceAPI
+	}
+
+	link := &Link{
+		Domain:    domain,
+		Code:      code,
+		TargetURL: input.URL,
+		Title:     input.Title,
+		Source:    source,
+		Active:    true,
+		ExpiresAt: input.ExpiresAt,
+		CreatedBy: userID,
+	}
+	if err := s.repo.Create(ctx, link); err != nil {
+		return nil, errors.NewInternal("Failed to create link").WithError(err)
+	}
+	link.ShortURL = s.ShortURL(link)
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventLinkCreated,
+		Data: map[string]interface{}{
+			"link_id":   link.ID,
+			"short_url": link.ShortURL,
+			"source":    link.Source,
+		},
+	})
+
+	return link, nil
+}
+
+// NotificationLink shortens a URL for use in a notification body
+func (s *Service) NotificationLink(ctx context.Context, target string, ttl time.Duration) (*Link, error) {
+	input := &ShortenInput{URL: target, Source: SourceNotification}
+	if ttl > 0 {
+		expires := time.Now().Add(ttl)
+		input.ExpiresAt = &expires
+	}
+	return s.Shorten(ctx, input, 0)
+}
+
+// PaymentLink shortens an EIP-681 payment URI and renders its QR code so
+// the request can be scanned by a wallet
+func (s *Service) PaymentLink(ctx context.Context, paymentURI string, ttl time.Duration) (*Link, error) {
+	if !strings.HasPrefix(paymentURI, "ethereum:") {
+		return nil, errors.NewBadRequest("Payment link must be an ethereum: URI")
+	}
+
+	input := &ShortenInput{URL: paymentURI, Source: SourceWeb3}
+	if ttl > 0 {
+		expires := time.Now().Add(ttl)
+		input.ExpiresAt = &expires
+	}
+
+	link, err := s.Shorten(ctx, input, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.renderQR(ctx, link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func (s *Service) Update(ctx context.Context, id uint, input *UpdateInput) (*Link, error) {
+	link, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTarget(input.URL); err != nil {
+		return nil, err
+	}
+
+	link.TargetURL = input.URL
+	link.Title = input.Title
+	link.ExpiresAt = input.ExpiresAt
+	if input.Active != nil {
+		link.Active = *input.Active
+	}
+
+	if err := s.repo.Update(ctx, link); err != nil {
+		return nil, errors.NewInternal("Failed to update link").WithError(err)
+	}
+	return link, nil
+}
+
+func (s *Service) Delete(ctx context.Context, id uint) error {
+	link, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return errors.NewInternal("Failed to delete link").WithError(err)
+	}
+	if link.QRKey != "" {
+		s.qr.Remove(ctx, link.QRKey)
+	}
+	return nil
+}
+
+// ==================== Redirects ====================
+
+// Resolve returns the active link for a code on a domain
+func (s *Service) Resolve(ctx context.Context, domain, code string) (*Link, error) {
+	link, err := s.repo.FindByCode(ctx, strings.ToLower(domain), code)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load link").WithError(err)
+	}
+	if link == nil || !link.Active {
+		return nil, errors.NewNotFound("Link not found")
+	}
+	if link.Expired(time.Now()) {
+		return nil, errors.New(errors.ErrCodeNotFound, "Link has expired", http.StatusGone)
+	}
+	link.ShortURL = s.ShortURL(link)
+	return link, nil
+}
+
+// Track records a click in the background and updates click metrics
+func (s *Service) Track(link *Link, info ClickInfo) {
+	device, os, browser := parseUserAgent(info.UserAgent)
+	click := &Click{
+		LinkID:    link.ID,
+		Country:   normalizeCountry(info.Country),
+		Device:    device,
+		OS:        os,
+		Browser:   browser,
+		Referrer:  referrerHost(info.Referrer),
+		VisitorID: s.visitorID(info),
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if click.Country == "" && s.geo != nil && info.IP != "" {
+			click.Country = normalizeCountry(s.geo.Country(ctx, info.IP))
+		}
+		s.recordMetrics(click)
+
+		if err := s.repo.RecordClick(ctx, click); err != nil {
+			logger.Warn("Failed to record link click", logger.Fields{"link_id": link.ID, "error": err.Error()})
+		}
+	}()
+}
+
+// Stats returns click breakdowns for the last days days
+func (s *Service) Stats(ctx context.Context, id uint, days int) (*Stats, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	if days <= 0 {
+		days = 30
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	stats, err := s.repo.Stats(ctx, id, since, statsTopLimit)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load link stats").WithError(err)
+	}
+	return stats, nil
+}
+
+// ==================== QR Codes ====================
+
+// QRCode returns the PNG QR code of a link's short URL, rendering and
+// storing it on first use
+func (s *Service) QRCode(ctx context.Context, link *Link) (io.ReadCloser, error) {
+	if link.QRKey != "" {
+		r, err := s.qr.Open(ctx, link.QRKey)
+		if err == nil {
+			return r, nil
+		}
+		if err != storage.ErrNotFound {
+			return nil, errors.NewInternal("Failed to load QR code").WithError(err)
+		}
+	}
+
+	if err := s.renderQR(ctx, link); err != nil {
+		return nil, err
+	}
+	r, err := s.qr.Open(ctx, link.QRKey)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load QR code").WithError(err)
+	}
+	return r, nil
+}
+
+// QRCodeURL returns the public URL of a link's QR code image
+func (s *Service) QRCodeURL(link *Link) string {
+	return s.ShortURL(link) + "/qr"
+}
+
+func (s *Service) renderQR(ctx context.Context, link *Link) error {
+	key, err := s.qr.Render(ctx, link, s.ShortURL(link))
+	if err != nil {
+		return errors.NewInternal("Failed to render QR code").WithError(err)
+	}
+	if key != link.QRKey {
+		link.QRKey = key
+		if err := s.repo.Update(ctx, link); err != nil {
+			return errors.NewInternal("Failed to update link").WithError(err)
+		}
+	}
+	return nil
+}
+
+// ==================== Helpers ====================
+
+// allocateCode validates a custom alias or generates a free code
+func (s *Service) allocateCode(ctx context.Context, domain, alias string) (string, error) {
+	if alias != "" {
+		if !aliasRegex.MatchString(alias) || reservedCodes[strings.ToLower(alias)] {
+			return "", errors.NewBadRequest("Alias may only contain letters, digits, '-' and '_' and must not be reserved")
+		}
+		exists, err := s.repo.CodeExists(ctx, domain, alias)
+		if err != nil {
+			return "", errors.NewInternal("Failed to check alias").WithError(err)
+		}
+		if exists {
+			return "", errors.NewConflict("Alias is already taken")
+		}
+		return alias, nil
+	}
+
+	length := s.config.CodeLength
+	for attempt := 0; attempt < codeAttempts; attempt++ {
+		code, err := generateCode(length)
+		if err != nil {
+			return "", errors.NewInternal("Failed to generate code").WithError(err)
+		}
+		exists, err := s.repo.CodeExists(ctx, domain, code)
+		if err != nil {
+			return "", errors.NewInternal("Failed to check code").WithError(err)
+		}
+		if !exists {
+			return code, nil
+		}
+		// Collisions mean the code space is getting crowded
+		length++
+	}
+	return "", errors.NewInternal("Failed to allocate a unique code")
+}
+
+// checkTarget validates a target URL and rejects redirects back to our
+// own short domains
+func (s *Service) checkTarget(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || !allowedSchemes[strings.ToLower(u.Scheme)] {
+		return errors.NewBadRequest("URL must be an absolute http(s) or ethereum: URI")
+	}
+	if u.Scheme == "ethereum" {
+		return nil
+	}
+	if u.Host == "" {
+		return errors.NewBadRequest("URL must include a host")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if base, err := url.Parse(s.config.BaseURL); err == nil && strings.EqualFold(base.Hostname(), host) &&
+		strings.HasPrefix(u.Path, base.Path+"/") {
+		return errors.NewBadRequest("URL must not point to another short link")
+	}
+	if s.domains[host] {
+		return errors.NewBadRequest("URL must not point to another short link")
+	}
+	return nil
+}
+
+func (s *Service) recordMetrics(click *Click) {
+	if s.metrics == nil {
+		return
+	}
+
+	s.metrics.NewCounter("links_clicks_total", "Total short link clicks", nil).Inc()
+
+	country := click.Country
+	if country == "" {
+		country = "unknown"
+	}
+	s.metrics.NewCounter(
+		"links_clicks_country_"+country,
+		"Short link clicks from "+country,
+		map[string]string{"country": country},
+	).Inc()
+	s.metrics.NewCounter(
+		"links_clicks_device_"+click.Device,
+		"Short link clicks from "+click.Device+" devices",
+		map[string]string{"device": click.Device},
+	).Inc()
+}
+
+// visitorID hashes the client so unique clicks can be counted without
+// storing IP addresses
+func (s *Service) visitorID(info ClickInfo) string {
+	sum := sha256.Sum256([]byte(s.config.VisitorSalt + "|" + info.IP + "|" + info.UserAgent))
+	return hex.EncodeToString(sum[:16])
+}
+
+func normalizeCountry(country string) string {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	// CDNs report XX or T1 for unknown and Tor traffic
+	if len(country) != 2 || country == "XX" || country == "T1" {
+		return ""
+	}
+	return country
+}
+
+func referrerHost(referrer string) string {
+	if referrer == "" {
+		return ""
+	}
+	u, err := url.Parse(referrer)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
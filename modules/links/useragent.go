@@ -0,0 +1,67 @@
+package links
+
+import (
+	"strings"
+)
+
+// parseUserAgent classifies a user agent into device, OS and browser.
+// It is a coarse heuristic meant for aggregate breakdowns only.
+func parseUserAgent(ua string) (device, os, browser string) {
+	lower := strings.ToLower(ua)
+	if lower == "" {
+		return DeviceUnknown, "", ""
+	}
+
+	switch {
+	case containsAny(lower, "bot", "crawler", "spider", "slurp", "facebookexternalhit", "preview", "curl", "wget"):
+		device = DeviceBot
+	case containsAny(lower, "ipad", "tablet") || (strings.Contains(lower, "android") && !strings.Contains(lower, "mobile")):
+		device = DeviceTablet
+	case containsAny(lower, "mobi", "iphone", "ipod", "android", "windows phone"):
+		device = DeviceMobile
+	default:
+		device = DeviceDesktop
+	}
+
+	switch {
+	case containsAny(lower, "iphone", "ipad", "ipod"):
+		os = "iOS"
+	case strings.Contains(lower, "android"):
+		os = "Android"
+	case strings.Contains(lower, "windows"):
+		os = "Windows"
+	case strings.Contains(lower, "mac os"):
+		os = "macOS"
+	case strings.Contains(lower, "cros"):
+		os = "ChromeOS"
+	case strings.Contains(lower, "linux"):
+		os = "Linux"
+	}
+
+	// Order matters: most browsers also claim to be Safari or Chrome
+	switch {
+	case strings.Contains(lower, "edg/"):
+		browser = "Edge"
+	case containsAny(lower, "opr/", "opera"):
+		browser = "Opera"
+	case strings.Contains(lower, "samsungbrowser"):
+		browser = "Samsung Internet"
+	case containsAny(lower, "firefox/", "fxios"):
+		browser = "Firefox"
+	case containsAny(lower, "chrome/", "crios"):
+		browser = "Chrome"
+	case strings.Contains(lower, "safari/"):
+		browser = "Safari"
+	}
+
+	return device, os, browser
+}
+
+func containsAny(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"neonexcore/pkg/ai"
+)
+
+// Classifier media
+const (
+	MediaText  = "text"
+	MediaImage = "image"
+)
+
+// Classifier scores content per category, from 0 (clean) to 1 (certain)
+type Classifier interface {
+	Name() string
+	Classify(ctx context.Context, content *Content) (map[string]float64, error)
+}
+
+// safeLabels are classifier labels that mean the content is fine
+var safeLabels = map[string]bool{
+	"safe": true, "sfw": true, "neutral": true, "normal": true, "ok": true, "clean": true, "none": true,
+}
+
+const textClassifierPrompt = `You are a content moderation classifier. Rate how likely the user's text is to contain each category.
+Respond with JSON only: {"categories": {"harassment": <0-1>, "hate": <0-1>, "sexual": <0-1>, "violence": <0-1>, "self_harm": <0-1>, "spam": <0-1>}}`
+
+// AIClassifier classifies text or images with a model loaded in an
+// ai.ModelManager. Text goes to chat models with a JSON scoring prompt;
+// images go to classification models by URL, one request per image.
+type AIClassifier struct {
+	name    string
+	manager *ai.ModelManager
+	modelID string
+	media   string
+}
+
+// NewAIClassifier creates a classifier for text or image content
+func NewAIClassifier(manager *ai.ModelManager, modelID, media string) *AIClassifier {
+	if media != MediaImage {
+		media = MediaText
+	}
+	return &AIClassifier{
+		name:    media + ":" + modelID,
+		manager: manager,
+		modelID: modelID,
+		media:   media,
+	}
+}
+
+// Name implements Classifier
+func (c *AIClassifier) Name() string {
+	return c.name
+}
+
+// Classify implements Classifier
+func (c *AIClassifier) Classify(ctx context.Context, content *Content) (map[string]float64, error) {
+	if c.media == MediaImage {
+		return c.classifyImages(ctx, content.ImageURLs)
+	}
+	if strings.TrimSpace(content.Text) == "" {
+		return nil, nil
+	}
+
+	output, err := c.manager.Predict(ctx, &ai.InferenceInput{
+		ModelID: c.modelID,
+		Data:    content.Text,
+		Parameters: map[string]interface{}{
+			"type":        "chat",
+			"system":      textClassifierPrompt,
+			"temperature": 0,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("text classification failed: %w", err)
+	}
+	return parseScores(output.Result)
+}
+
+// classifyImages keeps each category's highest score across images
+func (c *AIClassifier) classifyImages(ctx context.Context, urls []string) (map[string]float64, error) {
+	scores := make(map[string]float64)
+	for _, url := range urls {
+		output, err := c.manager.Predict(ctx, &ai.InferenceInput{
+			ModelID:    c.modelID,
+			Data:       url,
+			Parameters: map[string]interface{}{"type": "classification"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("image classification failed: %w", err)
+		}
+		imageScores, err := parseScores(output.Result)
+		if err != nil {
+			return nil, err
+		}
+		for category, score := range imageScores {
+			if score > scores[category] {
+				scores[category] = score
+			}
+		}
+	}
+	return scores, nil
+}
+
+// parseScores reads category scores from a model result. Category maps,
+// a single score with category names, classification predictions
+// ({"predictions": [{"label", "score", "scores"}]}), OpenAI moderation
+// results and chat responses carrying any of these are accepted.
+func parseScores(raw interface{}) (map[string]float64, error) {
+	switch v := raw.(type) {
+	case float64:
+		return map[string]float64{"unsafe": v}, nil
+	case string:
+		return decodeScores(v)
+	case map[string]interface{}:
+		if choices, ok := v["choices"].([]interface{}); ok && len(choices) > 0 {
+			choice, _ := choices[0].(map[string]interface{})
+			message, _ := choice["message"].(map[string]interface{})
+			if content, ok := message["content"].(string); ok {
+				return decodeScores(content)
+			}
+		}
+		if results, ok := v["results"].([]interface{}); ok && len(results) > 0 {
+			result, _ := results[0].(map[string]interface{})
+			return scoreMap(result["category_scores"]), nil
+		}
+		if predictions, ok := v["predictions"].([]interface{}); ok {
+			if len(predictions) == 0 {
+				return nil, nil
+			}
+			prediction, _ := predictions[0].(map[string]interface{})
+			if scores := scoreMap(prediction["scores"]); len(scores) > 0 {
+				return scores, nil
+			}
+			label, _ := prediction["label"].(string)
+			score, _ := prediction["score"].(float64)
+			return scoreMap(map[string]interface{}{label: score}), nil
+		}
+		if scores, ok := v["categories"].(map[string]interface{}); ok {
+			return scoreMap(scores), nil
+		}
+		if score, ok := v["score"].(float64); ok {
+			scores := make(map[string]float64)
+			for _, category := range toStrings(v["categories"]) {
+				scores[category] = score
+			}
+			if len(scores) == 0 {
+				scores["unsafe"] = score
+			}
+			return scores, nil
+		}
+		// Anything else, such as a sandbox reply, carries no findings
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected classifier output: %T", raw)
+}
+
+// decodeScores parses a JSON verdict from model text
+func decodeScores(content string) (map[string]float64, error) {
+	content = strings.TrimSpace(content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.Trim(content, "` \n")
+
+	var verdict map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &verdict); err != nil {
+		return nil, fmt.Errorf("invalid classifier verdict: %w", err)
+	}
+	return parseScores(verdict)
+}
+
+// scoreMap converts a JSON object of scores, dropping safe labels
+func scoreMap(raw interface{}) map[string]float64 {
+	object, _ := raw.(map[string]interface{})
+	scores := make(map[string]float64, len(object))
+	for category, value := range object {
+		score, ok := value.(float64)
+		if !ok || safeLabels[strings.ToLower(category)] {
+			continue
+		}
+		scores[strings.ToLower(category)] = score
+	}
+	return scores
+}
+
+func toStrings(v interface{}) []string {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
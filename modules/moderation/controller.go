@@ -0,0 +1,238 @@
+package moderation
+
+import (
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// Check moderates content submitted by the caller
+// @Summary Check content
+// @Description Runs text and images through rule lists and AI classifiers. Flagged content is queued for review.
+// @Tags Moderation
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param content body Content true "Content"
+// @Success 200 {object} api.Response{data=Result}
+// @Failure 400 {object} api.Response
+// @Router /moderation/check [post]
+func (c *Controller) Check(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input Content
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	input.UserID = userID
+
+	result, err := c.service.Check(ctx.UserContext(), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, result)
+}
+
+// ListItems returns moderated items
+// @Summary List moderation queue
+// @Tags Moderation
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "Status (pending, approved, rejected, blocked); pending by default"
+// @Param source query string false "Submitting feature"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} api.PaginatedResponse{data=[]Item}
+// @Router /moderation/items [get]
+func (c *Controller) ListItems(ctx *fiber.Ctx) error {
+	filter := ItemFilter{
+		Status: ctx.Query("status", StatusPending),
+		Source: ctx.Query("source"),
+	}
+	if filter.Status == "all" {
+		filter.Status = ""
+	}
+
+	pagination := api.GetPagination(ctx)
+	items, total, err := c.service.ListItems(ctx.UserContext(), filter, pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, items, pagination.Page, pagination.Limit, total)
+}
+
+// GetItem returns a moderated item
+// @Summary Get moderation item
+// @Tags Moderation
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {object} api.Response{data=Item}
+// @Failure 404 {object} api.Response
+// @Router /moderation/items/{id} [get]
+func (c *Controller) GetItem(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid item ID", nil)
+	}
+
+	item, err := c.service.GetItem(ctx.UserContext(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, item)
+}
+
+// Review approves or rejects a flagged item
+// @Summary Review moderation item
+// @Tags Moderation
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param review body ReviewInput true "Decision"
+// @Success 200 {object} api.Response{data=Item}
+// @Failure 409 {object} api.Response
+// @Router /moderation/items/{id}/review [post]
+func (c *Controller) Review(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid item ID", nil)
+	}
+
+	var input ReviewInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	item, err := c.service.Review(ctx.UserContext(), uint(id), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Item reviewed", item)
+}
+
+// ListRuleLists returns all rule lists
+// @Summary List rule lists
+// @Tags Moderation
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]RuleList}
+// @Router /moderation/rules [get]
+func (c *Controller) ListRuleLists(ctx *fiber.Ctx) error {
+	lists, err := c.service.ListRuleLists(ctx.UserContext())
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, lists)
+}
+
+// GetRuleList returns a rule list
+// @Summary Get rule list
+// @Tags Moderation
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Rule list ID"
+// @Success 200 {object} api.Response{data=RuleList}
+// @Failure 404 {object} api.Response
+// @Router /moderation/rules/{id} [get]
+func (c *Controller) GetRuleList(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid rule list ID", nil)
+	}
+
+	list, err := c.service.GetRuleList(ctx.UserContext(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, list)
+}
+
+// CreateRuleList adds a rule list
+// @Summary Create rule list
+// @Description Keyword lists match whole words or phrases, regex lists match patterns and domain lists match linked domains
+// @Tags Moderation
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param list body RuleListInput true "Rule list"
+// @Success 201 {object} api.Response{data=RuleList}
+// @Failure 400 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /moderation/rules [post]
+func (c *Controller) CreateRuleList(ctx *fiber.Ctx) error {
+	var input RuleListInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	list, err := c.service.CreateRuleList(ctx.UserContext(), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Rule list created", list)
+}
+
+// UpdateRuleList replaces a rule list
+// @Summary Update rule list
+// @Tags Moderation
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Rule list ID"
+// @Param list body RuleListInput true "Rule list"
+// @Success 200 {object} api.Response{data=RuleList}
+// @Failure 400 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /moderation/rules/{id} [put]
+func (c *Controller) UpdateRuleList(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid rule list ID", nil)
+	}
+
+	var input RuleListInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	list, err := c.service.UpdateRuleList(ctx.UserContext(), uint(id), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Rule list updated", list)
+}
+
+// DeleteRuleList removes a rule list
+// @Summary Delete rule list
+// @Tags Moderation
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Rule list ID"
+// @Success 200 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /moderation/rules/{id} [delete]
+func (c *Controller) DeleteRuleList(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid rule list ID", nil)
+	}
+
+	if err := c.service.DeleteRuleList(ctx.UserContext(), uint(id)); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Rule list deleted", nil)
+}
@@ -0,0 +1,56 @@
+package moderation
+
+import (
+	"os"
+	"strconv"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/ai"
+	"neonexcore/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Service with AI classifiers
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		if threshold, err := strconv.ParseFloat(os.Getenv("MODERATION_FLAG_THRESHOLD"), 64); err == nil && threshold > 0 {
+			config.FlagThreshold = threshold
+		}
+		if threshold, err := strconv.ParseFloat(os.Getenv("MODERATION_BLOCK_THRESHOLD"), 64); err == nil && threshold > 0 {
+			config.BlockThreshold = threshold
+		}
+		config.FailOpen = os.Getenv("MODERATION_FAIL_OPEN") == "true"
+
+		service := NewService(core.Resolve[*Repository](container), config)
+
+		// Classifiers require models loaded in the AI model manager
+		textModel, imageModel := os.Getenv("MODERATION_TEXT_MODEL"), os.Getenv("MODERATION_IMAGE_MODEL")
+		if textModel != "" || imageModel != "" {
+			manager := core.Resolve[*ai.ModelManager](container)
+			if manager == nil {
+				logger.Warn("Moderation classifiers disabled: no AI model manager", logger.Fields{})
+				return service
+			}
+			if textModel != "" {
+				service.AddClassifier(NewAIClassifier(manager, textModel, MediaText))
+			}
+			if imageModel != "" {
+				service.AddClassifier(NewAIClassifier(manager, imageModel, MediaImage))
+			}
+		}
+
+		return service
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
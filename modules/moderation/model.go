@@ -0,0 +1,109 @@
+package moderation
+
+import "time"
+
+// Decisions, from least to most severe
+const (
+	DecisionAllow = "allow" // Publish
+	DecisionFlag  = "flag"  // Hold for human review
+	DecisionBlock = "block" // Reject outright
+)
+
+// Rule list types
+const (
+	ListKeyword = "keyword" // Whole words or phrases, case-insensitive
+	ListRegex   = "regex"   // Regular expressions
+	ListDomain  = "domain"  // Link domains, including subdomains
+)
+
+// Review states of a moderated item
+const (
+	StatusPending  = "pending"  // Flagged, awaiting review
+	StatusApproved = "approved" // Released by a reviewer
+	StatusRejected = "rejected" // Rejected by a reviewer
+	StatusBlocked  = "blocked"  // Blocked automatically; kept for audit
+)
+
+// Reason sources
+const (
+	SourceRule       = "rule"
+	SourceClassifier = "classifier"
+)
+
+// RuleList is a named list of terms, patterns or domains that flags or
+// blocks matching content
+type RuleList struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Name      string    `gorm:"size:100;uniqueIndex;not null" json:"name"`
+	Type      string    `gorm:"size:20;not null" json:"type"`
+	Action    string    `gorm:"size:20;not null" json:"action"`
+	Category  string    `gorm:"size:50" json:"category,omitempty"`
+	Entries   []string  `gorm:"serializer:json" json:"entries"`
+	Enabled   bool      `gorm:"default:true" json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for RuleList
+func (RuleList) TableName() string {
+	return "moderation_rule_lists"
+}
+
+// Item is content that was flagged or blocked, with the reasons why
+type Item struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	Source      string     `gorm:"size:100;index:idx_moderation_items_ref" json:"source,omitempty"`
+	ReferenceID string     `gorm:"size:100;index:idx_moderation_items_ref" json:"reference_id,omitempty"`
+	UserID      uint       `gorm:"index" json:"user_id,omitempty"`
+	Text        string     `gorm:"type:text" json:"text,omitempty"`
+	ImageURLs   []string   `gorm:"serializer:json" json:"image_urls,omitempty"`
+	Decision    string     `gorm:"size:20;not null" json:"decision"`
+	Score       float64    `json:"score"`
+	Categories  []string   `gorm:"serializer:json" json:"categories,omitempty"`
+	Reasons     []Reason   `gorm:"serializer:json" json:"reasons"`
+	Status      string     `gorm:"size:20;not null;index" json:"status"`
+	ReviewedBy  *uint      `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote  string     `gorm:"size:500" json:"review_note,omitempty"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for Item
+func (Item) TableName() string {
+	return "moderation_items"
+}
+
+// Reason explains one rule or classifier finding
+type Reason struct {
+	Source   string  `json:"source"`             // rule or classifier
+	Name     string  `json:"name"`               // Rule list or classifier name
+	Decision string  `json:"decision"`           // What the finding calls for
+	Category string  `json:"category,omitempty"` // e.g. hate, spam
+	Score    float64 `json:"score,omitempty"`    // Classifier score
+	Match    string  `json:"match,omitempty"`    // Matched term, pattern, domain or image
+}
+
+// Content is submitted for moderation
+type Content struct {
+	Source      string   `json:"source" validate:"max=100"`       // Submitting feature, e.g. comments
+	ReferenceID string   `json:"reference_id" validate:"max=100"` // ID of the content in that feature
+	UserID      uint     `json:"-"`
+	Text        string   `json:"text"`
+	ImageURLs   []string `json:"image_urls" validate:"max=10,dive,url"`
+}
+
+// Result is the moderation decision for some content
+type Result struct {
+	Decision   string   `json:"decision"`
+	Score      float64  `json:"score"`
+	Categories []string `json:"categories,omitempty"`
+	Reasons    []Reason `json:"reasons"`
+	ItemID     uint     `json:"item_id,omitempty"` // Set when the content was queued or logged
+}
+
+// ItemFilter narrows an item listing
+type ItemFilter struct {
+	Status string
+	Source string
+}
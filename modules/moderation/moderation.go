@@ -0,0 +1,28 @@
+package moderation
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ModerationModule struct{}
+
+func New() *ModerationModule {
+	return &ModerationModule{}
+}
+
+func (m *ModerationModule) Name() string {
+	return "moderation"
+}
+
+func (m *ModerationModule) Init() {}
+
+func (m *ModerationModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *ModerationModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
@@ -0,0 +1,105 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ==================== Rule Lists ====================
+
+func (r *Repository) ListRuleLists(ctx context.Context) ([]RuleList, error) {
+	var lists []RuleList
+	err := r.db.WithContext(ctx).Order("name ASC").Find(&lists).Error
+	return lists, err
+}
+
+// EnabledRuleLists returns the lists applied to content
+func (r *Repository) EnabledRuleLists(ctx context.Context) ([]RuleList, error) {
+	var lists []RuleList
+	err := r.db.WithContext(ctx).Where("enabled = ?", true).Order("id ASC").Find(&lists).Error
+	return lists, err
+}
+
+func (r *Repository) FindRuleList(ctx context.Context, id uint) (*RuleList, error) {
+	var list RuleList
+	err := r.db.WithContext(ctx).First(&list, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &list, nil
+}
+
+// RuleListNameExists reports whether another list uses a name
+func (r *Repository) RuleListNameExists(ctx context.Context, name string, excludeID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&RuleList{}).
+		Where("name = ? AND id <> ?", name, excludeID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *Repository) SaveRuleList(ctx context.Context, list *RuleList) error {
+	return r.db.WithContext(ctx).Save(list).Error
+}
+
+func (r *Repository) DeleteRuleList(ctx context.Context, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).Delete(&RuleList{}, id)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ==================== Items ====================
+
+func (r *Repository) CreateItem(ctx context.Context, item *Item) error {
+	return r.db.WithContext(ctx).Create(item).Error
+}
+
+func (r *Repository) UpdateItem(ctx context.Context, item *Item) error {
+	return r.db.WithContext(ctx).Save(item).Error
+}
+
+func (r *Repository) FindItem(ctx context.Context, id uint) (*Item, error) {
+	var item Item
+	err := r.db.WithContext(ctx).First(&item, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ListItems returns items, oldest first so the queue is worked in order
+func (r *Repository) ListItems(ctx context.Context, filter ItemFilter, page, limit int) ([]Item, int64, error) {
+	var items []Item
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Item{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("created_at ASC, id ASC").Offset(offset).Limit(limit).Find(&items).Error
+	return items, total, err
+}
@@ -0,0 +1,39 @@
+package moderation
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	// Apply decisions from the review module's moderation queue
+	events.Register(eventReviewDecided, core.Resolve[*Service](container).HandleReviewDecided)
+
+	moderation := router.Group("/moderation", auth.AuthMiddleware(jwtManager, auth.AcceptAPIKeys()))
+
+	// ==================== Checks ====================
+	moderation.Post("/check", auth.RequireScope("moderation.check"), controller.Check)
+
+	// ==================== Review Queue ====================
+	review := moderation.Group("/items", rbac.RequirePermission(rbacManager, "moderation.review"))
+	review.Get("", controller.ListItems)
+	review.Get("/:id", controller.GetItem)
+	review.Post("/:id/review", controller.Review)
+
+	// ==================== Rule Lists ====================
+	rules := moderation.Group("/rules", rbac.RequirePermission(rbacManager, "moderation.manage"))
+	rules.Get("", controller.ListRuleLists)
+	rules.Post("", controller.CreateRuleList)
+	rules.Get("/:id", controller.GetRuleList)
+	rules.Put("/:id", controller.UpdateRuleList)
+	rules.Delete("/:id", controller.DeleteRuleList)
+}
@@ -0,0 +1,101 @@
+package moderation
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// hostPattern finds domain names in text, with or without a scheme
+var hostPattern = regexp.MustCompile(`(?i)(?:https?://)?((?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,})`)
+
+// compiledList is a rule list ready for matching
+type compiledList struct {
+	list     RuleList
+	patterns []*regexp.Regexp // Keyword and regex lists, parallel to entries
+	domains  []string         // Domain lists
+}
+
+// compileList prepares a rule list, rejecting invalid patterns
+func compileList(list RuleList) (*compiledList, error) {
+	compiled := &compiledList{list: list}
+	for _, entry := range list.Entries {
+		switch list.Type {
+		case ListKeyword:
+			// Letters and digits around the term mean it is part of another word
+			pattern := `(?i)(?:^|[^\p{L}\p{N}_])` + regexp.QuoteMeta(entry) + `(?:$|[^\p{L}\p{N}_])`
+			compiled.patterns = append(compiled.patterns, regexp.MustCompile(pattern))
+		case ListRegex:
+			pattern, err := regexp.Compile(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", entry, err)
+			}
+			compiled.patterns = append(compiled.patterns, pattern)
+		case ListDomain:
+			compiled.domains = append(compiled.domains, strings.TrimPrefix(strings.ToLower(entry), "."))
+		default:
+			return nil, fmt.Errorf("unknown rule list type %q", list.Type)
+		}
+	}
+	return compiled, nil
+}
+
+// match returns a reason for the first entry the content matches, or nil
+func (c *compiledList) match(content *Content) *Reason {
+	reason := &Reason{
+		Source:   SourceRule,
+		Name:     c.list.Name,
+		Decision: c.list.Action,
+		Category: c.list.Category,
+	}
+
+	if c.list.Type == ListDomain {
+		for _, host := range contentHosts(content) {
+			for _, domain := range c.domains {
+				if host == domain || strings.HasSuffix(host, "."+domain) {
+					reason.Match = host
+					return reason
+				}
+			}
+		}
+		return nil
+	}
+
+	for i, pattern := range c.patterns {
+		if pattern.MatchString(content.Text) {
+			reason.Match = c.list.Entries[i]
+			return reason
+		}
+	}
+	return nil
+}
+
+// contentHosts returns the domains linked from text and hosting images
+func contentHosts(content *Content) []string {
+	var hosts []string
+	for _, match := range hostPattern.FindAllStringSubmatch(content.Text, -1) {
+		hosts = append(hosts, strings.ToLower(match[1]))
+	}
+	for _, raw := range content.ImageURLs {
+		if u, err := url.Parse(raw); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, strings.ToLower(u.Hostname()))
+		}
+	}
+	return hosts
+}
+
+// cleanEntries trims entries and drops blanks and duplicates
+func cleanEntries(entries []string) []string {
+	seen := make(map[string]bool, len(entries))
+	cleaned := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		cleaned = append(cleaned, entry)
+	}
+	return cleaned
+}
@@ -0,0 +1,430 @@
+package moderation
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+)
+
+// Moderation event names
+const (
+	EventContentFlagged  = "moderation.flagged"
+	EventContentBlocked  = "moderation.blocked"
+	EventContentReviewed = "moderation.reviewed"
+)
+
+// eventReviewDecided is dispatched by the review module when a reviewer
+// decides an item from a review queue
+const eventReviewDecided = "review.decided"
+
+// Config holds moderation configuration
+type Config struct {
+	FlagThreshold  float64 // Classifier score that holds content for review
+	BlockThreshold float64 // Classifier score that blocks content
+	FailOpen       bool    // Ignore failing classifiers instead of flagging
+	MaxTextLength  int     // Maximum text length in characters
+}
+
+// DefaultConfig returns default moderation configuration
+func DefaultConfig() Config {
+	return Config{
+		FlagThreshold:  0.5,
+		BlockThreshold: 0.9,
+		MaxTextLength:  20000,
+	}
+}
+
+// RuleListInput is the payload for creating or replacing a rule list
+type RuleListInput struct {
+	Name     string   `json:"name" validate:"required,max=100"`
+	Type     string   `json:"type" validate:"required,oneof=keyword regex domain"`
+	Action   string   `json:"action" validate:"required,oneof=flag block"`
+	Category string   `json:"category" validate:"max=50"`
+	Entries  []string `json:"entries" validate:"required,min=1"`
+	Enabled  *bool    `json:"enabled"`
+}
+
+// ReviewInput is the payload for a reviewer decision
+type ReviewInput struct {
+	Status string `json:"status" validate:"required,oneof=approved rejected"`
+	Note   string `json:"note" validate:"max=500"`
+}
+
+// Service decides whether content is allowed, flagged for review or
+// blocked, by running it through rule lists and AI classifiers
+type Service struct {
+	repo        *Repository
+	config      Config
+	classifiers []Classifier
+
+	rules   []*compiledList // Enabled rule lists, loaded on first use
+	rulesOK bool
+	mu      sync.RWMutex
+}
+
+func NewService(repo *Repository, config Config) *Service {
+	return &Service{
+		repo:   repo,
+		config: config,
+	}
+}
+
+// AddClassifier registers a classifier run on every check
+func (s *Service) AddClassifier(c Classifier) {
+	s.classifiers = append(s.classifiers, c)
+}
+
+// ==================== Checks ====================
+
+// Check moderates content. Flagged content is queued for review and
+// blocked content is logged; allowed content is not stored.
+func (s *Service) Check(ctx context.Context, content *Content) (*Result, error) {
+	content.Text = strings.TrimSpace(content.Text)
+	if content.Text == "" && len(content.ImageURLs) == 0 {
+		return nil, errors.NewBadRequest("Text or images are required")
+	}
+	if s.config.MaxTextLength > 0 && len([]rune(content.Text)) > s.config.MaxTextLength {
+		return nil, errors.NewBadRequest("Text is too long")
+	}
+
+	rules, err := s.ruleLists(ctx)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load rule lists").WithError(err)
+	}
+
+	result := &Result{Decision: DecisionAllow, Reasons: []Reason{}}
+	for _, list := range rules {
+		if reason := list.match(content); reason != nil {
+			result.Reasons = append(result.Reasons, *reason)
+		}
+	}
+	for _, classifier := range s.classifiers {
+		s.classify(ctx, classifier, content, result)
+	}
+
+	categories := make(map[string]bool)
+	for _, reason := range result.Reasons {
+		if severity(reason.Decision) > severity(result.Decision) {
+			result.Decision = reason.Decision
+		}
+		if reason.Category != "" {
+			categories[reason.Category] = true
+		}
+	}
+	for category := range categories {
+		result.Categories = append(result.Categories, category)
+	}
+	sort.Strings(result.Categories)
+
+	if result.Decision == DecisionAllow {
+		return result, nil
+	}
+
+	item := &Item{
+		Source:      content.Source,
+		ReferenceID: content.ReferenceID,
+		UserID:      content.UserID,
+		Text:        content.Text,
+		ImageURLs:   content.ImageURLs,
+		Decision:    result.Decision,
+		Score:       result.Score,
+		Categories:  result.Categories,
+		Reasons:     result.Reasons,
+		Status:      StatusPending,
+	}
+	eventName := EventContentFlagged
+	if result.Decision == DecisionBlock {
+		item.Status = StatusBlocked
+		eventName = EventContentBlocked
+	}
+	if err := s.repo.CreateItem(ctx, item); err != nil {
+		return nil, errors.NewInternal("Failed to queue content for review").WithError(err)
+	}
+	result.ItemID = item.ID
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: eventName,
+		Data: map[string]interface{}{
+			"item_id":      item.ID,
+			"source":       item.Source,
+			"reference_id": item.ReferenceID,
+			"user_id":      item.UserID,
+			"score":        item.Score,
+			"categories":   item.Categories,
+		},
+	})
+	return result, nil
+}
+
+// classify adds a classifier's findings over the flag threshold
+func (s *Service) classify(ctx context.Context, classifier Classifier, content *Content, result *Result) {
+	scores, err := classifier.Classify(ctx, content)
+	if err != nil {
+		logger.Warn("Moderation classifier failed", logger.Fields{"classifier": classifier.Name(), "error": err.Error()})
+		if !s.config.FailOpen {
+			result.Reasons = append(result.Reasons, Reason{
+				Source:   SourceClassifier,
+				Name:     classifier.Name(),
+				Decision: DecisionFlag,
+				Category: "unavailable",
+			})
+		}
+		return
+	}
+
+	categories := make([]string, 0, len(scores))
+	for category := range scores {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	for _, category := range categories {
+		score := scores[category]
+		if score > result.Score {
+			result.Score = score
+		}
+		decision := DecisionAllow
+		switch {
+		case score >= s.config.BlockThreshold:
+			decision = DecisionBlock
+		case score >= s.config.FlagThreshold:
+			decision = DecisionFlag
+		}
+		if decision == DecisionAllow {
+			continue
+		}
+		result.Reasons = append(result.Reasons, Reason{
+			Source:   SourceClassifier,
+			Name:     classifier.Name(),
+			Decision: decision,
+			Category: category,
+			Score:    score,
+		})
+	}
+}
+
+// ruleLists returns the compiled enabled rule lists
+func (s *Service) ruleLists(ctx context.Context) ([]*compiledList, error) {
+	s.mu.RLock()
+	if s.rulesOK {
+		rules := s.rules
+		s.mu.RUnlock()
+		return rules, nil
+	}
+	s.mu.RUnlock()
+
+	lists, err := s.repo.EnabledRuleLists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]*compiledList, 0, len(lists))
+	for _, list := range lists {
+		compiled, err := compileList(list)
+		if err != nil {
+			logger.Warn("Skipping invalid rule list", logger.Fields{"list": list.Name, "error": err.Error()})
+			continue
+		}
+		rules = append(rules, compiled)
+	}
+
+	s.mu.Lock()
+	s.rules, s.rulesOK = rules, true
+	s.mu.Unlock()
+	return rules, nil
+}
+
+// invalidateRules reloads rule lists on the next check
+func (s *Service) invalidateRules() {
+	s.mu.Lock()
+	s.rules, s.rulesOK = nil, false
+	s.mu.Unlock()
+}
+
+// ==================== Review ====================
+
+// ListItems returns moderated items, e.g. the pending review queue
+func (s *Service) ListItems(ctx context.Context, filter ItemFilter, page, limit int) ([]Item, int64, error) {
+	items, total, err := s.repo.ListItems(ctx, filter, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to load moderation queue").WithError(err)
+	}
+	return items, total, nil
+}
+
+func (s *Service) GetItem(ctx context.Context, id uint) (*Item, error) {
+	item, err := s.repo.FindItem(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load item").WithError(err)
+	}
+	if item == nil {
+		return nil, errors.NewNotFound("Item not found")
+	}
+	return item, nil
+}
+
+// Review approves or rejects a flagged item. The submitting feature
+// learns the outcome from the moderation.reviewed event.
+func (s *Service) Review(ctx context.Context, id uint, input *ReviewInput, reviewerID uint) (*Item, error) {
+	item, err := s.GetItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if item.Status != StatusPending {
+		return nil, errors.NewConflict("Item is not awaiting review")
+	}
+
+	now := time.Now()
+	item.Status = input.Status
+	item.ReviewNote = input.Note
+	item.ReviewedBy = &reviewerID
+	item.ReviewedAt = &now
+	if err := s.repo.UpdateItem(ctx, item); err != nil {
+		return nil, errors.NewInternal("Failed to review item").WithError(err)
+	}
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventContentReviewed,
+		Data: map[string]interface{}{
+			"item_id":      item.ID,
+			"source":       item.Source,
+			"reference_id": item.ReferenceID,
+			"user_id":      item.UserID,
+			"status":       item.Status,
+			"note":         item.ReviewNote,
+			"reviewer_id":  reviewerID,
+		},
+	})
+	return item, nil
+}
+
+// HandleReviewDecided applies decisions made in a review queue to the
+// moderation items the queue received
+func (s *Service) HandleReviewDecided(ctx context.Context, event events.Event) error {
+	data, ok := event.Data.(map[string]interface{})
+	if !ok || data["source"] != "moderation" {
+		return nil
+	}
+	decision, _ := data["decision"].(string)
+	if decision != StatusApproved && decision != StatusRejected {
+		return nil
+	}
+	referenceID, _ := data["reference_id"].(string)
+	id, err := strconv.ParseUint(referenceID, 10, 64)
+	if err != nil || id == 0 {
+		return nil
+	}
+
+	note, _ := data["note"].(string)
+	reviewerID, _ := data["reviewer_id"].(uint)
+	if _, err := s.Review(ctx, uint(id), &ReviewInput{Status: decision, Note: note}, reviewerID); err != nil {
+		// Items reviewed here directly are already settled
+		if appErr, ok := errors.GetAppError(err); !ok || appErr.StatusCode != http.StatusConflict {
+			logger.Error("Failed to apply review decision", logger.Fields{"item_id": id, "error": err.Error()})
+		}
+	}
+	return nil
+}
+
+// ==================== Rule Lists ====================
+
+func (s *Service) ListRuleLists(ctx context.Context) ([]RuleList, error) {
+	lists, err := s.repo.ListRuleLists(ctx)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load rule lists").WithError(err)
+	}
+	return lists, nil
+}
+
+func (s *Service) GetRuleList(ctx context.Context, id uint) (*RuleList, error) {
+	list, err := s.repo.FindRuleList(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load rule list").WithError(err)
+	}
+	if list == nil {
+		return nil, errors.NewNotFound("Rule list not found")
+	}
+	return list, nil
+}
+
+func (s *Service) CreateRuleList(ctx context.Context, input *RuleListInput) (*RuleList, error) {
+	list := &RuleList{Enabled: true}
+	if err := s.saveRuleList(ctx, list, input); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *Service) UpdateRuleList(ctx context.Context, id uint, input *RuleListInput) (*RuleList, error) {
+	list, err := s.GetRuleList(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveRuleList(ctx, list, input); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *Service) DeleteRuleList(ctx context.Context, id uint) error {
+	deleted, err := s.repo.DeleteRuleList(ctx, id)
+	if err != nil {
+		return errors.NewInternal("Failed to delete rule list").WithError(err)
+	}
+	if !deleted {
+		return errors.NewNotFound("Rule list not found")
+	}
+	s.invalidateRules()
+	return nil
+}
+
+// saveRuleList validates input onto a list and stores it
+func (s *Service) saveRuleList(ctx context.Context, list *RuleList, input *RuleListInput) error {
+	name := strings.TrimSpace(input.Name)
+	exists, err := s.repo.RuleListNameExists(ctx, name, list.ID)
+	if err != nil {
+		return errors.NewInternal("Failed to save rule list").WithError(err)
+	}
+	if exists {
+		return errors.NewConflict("A rule list with this name already exists")
+	}
+
+	list.Name = name
+	list.Type = input.Type
+	list.Action = input.Action
+	list.Category = strings.ToLower(strings.TrimSpace(input.Category))
+	list.Entries = cleanEntries(input.Entries)
+	if input.Enabled != nil {
+		list.Enabled = *input.Enabled
+	}
+	if len(list.Entries) == 0 {
+		return errors.NewBadRequest("Rule list needs at least one entry")
+	}
+	if _, err := compileList(*list); err != nil {
+		return errors.NewBadRequest(err.Error())
+	}
+
+	if err := s.repo.SaveRuleList(ctx, list); err != nil {
+		return errors.NewInternal("Failed to save rule list").WithError(err)
+	}
+	s.invalidateRules()
+	return nil
+}
+
+// severity orders decisions
+func severity(decision string) int {
+	switch decision {
+	case DecisionBlock:
+		return 2
+	case DecisionFlag:
+		return 1
+	}
+	return 0
+}
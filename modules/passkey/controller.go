@@ -0,0 +1,248 @@
+package passkey
+
+import (
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/auth/webauthn"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BeginLoginInput is the payload for starting a passkey login
+type BeginLoginInput struct {
+	Email string `json:"email" validate:"omitempty,email"` // Optional; omit for discoverable login
+}
+
+// FinishRegistrationInput is the payload for completing a registration
+type FinishRegistrationInput struct {
+	Session    string                         `json:"session" validate:"required"`
+	Name       string                         `json:"name" validate:"max=100"`
+	Credential *webauthn.RegistrationResponse `json:"credential" validate:"required"`
+}
+
+// FinishLoginInput is the payload for completing a passkey login
+type FinishLoginInput struct {
+	Session    string                  `json:"session" validate:"required"`
+	Credential *webauthn.LoginResponse `json:"credential" validate:"required"`
+}
+
+// RenameInput is the payload for renaming a passkey
+type RenameInput struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// PolicyInput is the payload for changing the password fallback
+type PolicyInput struct {
+	PasswordLogin *bool `json:"password_login" validate:"required"`
+}
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// BeginRegistration starts registering a passkey
+// @Summary Begin passkey registration
+// @Description Returns options for navigator.credentials.create() and a session to send back
+// @Tags Passkeys
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response
+// @Router /passkeys/register/begin [post]
+func (c *Controller) BeginRegistration(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	options, session, err := c.service.BeginRegistration(ctx.UserContext(), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, fiber.Map{"session": session, "public_key": options})
+}
+
+// FinishRegistration verifies and stores a new passkey
+// @Summary Finish passkey registration
+// @Tags Passkeys
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param registration body FinishRegistrationInput true "Session and created credential"
+// @Success 201 {object} api.Response{data=Credential}
+// @Failure 400 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /passkeys/register/finish [post]
+func (c *Controller) FinishRegistration(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input FinishRegistrationInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	credential, err := c.service.FinishRegistration(ctx.UserContext(), userID, input.Session, input.Name, input.Credential)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Passkey registered", credential)
+}
+
+// BeginLogin starts a passkey login
+// @Summary Begin passkey login
+// @Description Returns options for navigator.credentials.get() and a session to send back
+// @Tags Passkeys
+// @Accept json
+// @Produce json
+// @Param login body BeginLoginInput false "Optional email"
+// @Success 200 {object} api.Response
+// @Router /passkeys/login/begin [post]
+func (c *Controller) BeginLogin(ctx *fiber.Ctx) error {
+	var input BeginLoginInput
+	if len(ctx.Body()) > 0 {
+		if err := validation.ValidateBody(ctx, &input); err != nil {
+			return api.RespondError(ctx, err)
+		}
+	}
+
+	options, session, err := c.service.BeginLogin(ctx.UserContext(), input.Email)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, fiber.Map{"session": session, "public_key": options})
+}
+
+// FinishLogin verifies a passkey and signs the user in
+// @Summary Finish passkey login
+// @Tags Passkeys
+// @Accept json
+// @Produce json
+// @Param login body FinishLoginInput true "Session and assertion"
+// @Success 200 {object} api.Response
+// @Failure 401 {object} api.Response
+// @Router /passkeys/login/finish [post]
+func (c *Controller) FinishLogin(ctx *fiber.Ctx) error {
+	var input FinishLoginInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	result, err := c.service.FinishLogin(auth.RequestContext(ctx), input.Session, input.Credential)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Login successful", result)
+}
+
+// List lists the caller's passkeys
+// @Summary List passkeys
+// @Tags Passkeys
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]Credential}
+// @Router /passkeys [get]
+func (c *Controller) List(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	credentials, err := c.service.List(ctx.UserContext(), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, credentials)
+}
+
+// Rename renames one of the caller's passkeys
+// @Summary Rename passkey
+// @Tags Passkeys
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Passkey ID"
+// @Param passkey body RenameInput true "New name"
+// @Success 200 {object} api.Response{data=Credential}
+// @Failure 404 {object} api.Response
+// @Router /passkeys/{id} [patch]
+func (c *Controller) Rename(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid passkey ID", nil)
+	}
+
+	var input RenameInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	credential, err := c.service.Rename(ctx.UserContext(), userID, uint(id), input.Name)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, credential)
+}
+
+// Delete removes one of the caller's passkeys
+// @Summary Delete passkey
+// @Tags Passkeys
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Passkey ID"
+// @Success 200 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /passkeys/{id} [delete]
+func (c *Controller) Delete(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid passkey ID", nil)
+	}
+
+	if err := c.service.Delete(ctx.UserContext(), userID, uint(id)); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Passkey deleted", nil)
+}
+
+// GetPolicy shows the caller's sign-in methods
+// @Summary Get sign-in policy
+// @Tags Passkeys
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=PolicyInfo}
+// @Router /passkeys/policy [get]
+func (c *Controller) GetPolicy(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	policy, err := c.service.GetPolicy(ctx.UserContext(), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, policy)
+}
+
+// UpdatePolicy turns password login on or off for the caller
+// @Summary Update sign-in policy
+// @Description Turn password login off once a passkey is registered, when the server allows opting out
+// @Tags Passkeys
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param policy body PolicyInput true "Policy"
+// @Success 200 {object} api.Response{data=PolicyInfo}
+// @Failure 400 {object} api.Response
+// @Failure 403 {object} api.Response
+// @Router /passkeys/policy [put]
+func (c *Controller) UpdatePolicy(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input PolicyInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	policy, err := c.service.SetPasswordLogin(ctx.UserContext(), userID, *input.PasswordLogin)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, policy)
+}
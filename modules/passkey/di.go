@@ -0,0 +1,42 @@
+package passkey
+
+import (
+	"os"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/auth/webauthn"
+	"neonexcore/pkg/rbac"
+	"neonexcore/pkg/signing"
+
+	"gorm.io/gorm"
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Service
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		config.WebAuthn = webauthn.LoadConfig()
+		if fallback := os.Getenv("PASSKEY_FALLBACK"); fallback != "" {
+			config.Fallback = fallback
+		}
+
+		return NewService(
+			core.Resolve[*Repository](container),
+			core.Resolve[*signing.Signer](container),
+			core.Resolve[*auth.JWTManager](container),
+			core.Resolve[*rbac.Manager](container),
+			config,
+		)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
@@ -0,0 +1,55 @@
+package passkey
+
+import "time"
+
+// Credential is a passkey registered to a user
+type Credential struct {
+	ID                uint       `gorm:"primarykey" json:"id"`
+	UserID            uint       `gorm:"index;not null" json:"user_id"`
+	CredentialID      string     `gorm:"size:512;uniqueIndex;not null" json:"credential_id"` // base64url
+	PublicKey         []byte     `gorm:"not null" json:"-"`                                  // COSE_Key
+	SignCount         uint32     `json:"-"`
+	AAGUID            string     `gorm:"size:36" json:"aaguid"` // Authenticator model
+	Transports        []string   `gorm:"serializer:json" json:"transports"`
+	Name              string     `gorm:"size:100" json:"name"`
+	AttestationFormat string     `gorm:"size:32" json:"attestation_format"`
+	BackupEligible    bool       `json:"backup_eligible"` // Synced passkey
+	BackupState       bool       `json:"backup_state"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for Credential
+func (Credential) TableName() string {
+	return "passkey_credentials"
+}
+
+// Policy is a user's choice of sign-in methods
+type Policy struct {
+	UserID        uint      `gorm:"primarykey;autoIncrement:false" json:"user_id"`
+	PasswordLogin bool      `gorm:"not null" json:"password_login"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for Policy
+func (Policy) TableName() string {
+	return "passkey_policies"
+}
+
+// PolicyInfo describes the sign-in methods available to a user
+type PolicyInfo struct {
+	Fallback      string `json:"fallback"`       // Server fallback policy
+	Passkeys      int64  `json:"passkeys"`       // Registered passkeys
+	PasswordLogin bool   `json:"password_login"` // Whether password login is allowed
+	CanChange     bool   `json:"can_change"`     // Whether the user may toggle password login
+}
+
+// account is the part of a user row the module reads
+type account struct {
+	ID       uint
+	Email    string
+	Name     string
+	Username string
+	IsActive bool
+}
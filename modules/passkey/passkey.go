@@ -0,0 +1,31 @@
+package passkey
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PasskeyModule struct{}
+
+func New() *PasskeyModule {
+	return &PasskeyModule{}
+}
+
+func (m *PasskeyModule) Name() string {
+	return "passkey"
+}
+
+func (m *PasskeyModule) Init() {}
+
+func (m *PasskeyModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *PasskeyModule) Routes(router fiber.Router, c *core.Container) {
+	// Password logins consult the fallback policy from here on
+	auth.SetPasswordLoginPolicy(core.Resolve[*Service](c).PasswordLoginAllowed)
+	SetupRoutes(router, c)
+}
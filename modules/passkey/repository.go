@@ -0,0 +1,156 @@
+package passkey
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ==================== Credentials ====================
+
+// CreateCredential stores a new passkey. The unique index on the
+// credential ID rejects registering an authenticator twice.
+func (r *Repository) CreateCredential(ctx context.Context, credential *Credential) error {
+	return r.db.WithContext(ctx).Create(credential).Error
+}
+
+// ListCredentials returns a user's passkeys, oldest first
+func (r *Repository) ListCredentials(ctx context.Context, userID uint) ([]Credential, error) {
+	var credentials []Credential
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&credentials).Error
+	return credentials, err
+}
+
+// CountCredentials counts a user's passkeys
+func (r *Repository) CountCredentials(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Credential{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// FindCredential returns a user's passkey by ID, or nil
+func (r *Repository) FindCredential(ctx context.Context, userID, id uint) (*Credential, error) {
+	var credential Credential
+	err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&credential).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// FindByCredentialID returns a passkey by its WebAuthn credential ID, or nil
+func (r *Repository) FindByCredentialID(ctx context.Context, credentialID string) (*Credential, error) {
+	var credential Credential
+	err := r.db.WithContext(ctx).Where("credential_id = ?", credentialID).First(&credential).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// RecordUse saves the counter and backup state after a login, unless a
+// concurrent login with a higher counter got there first
+func (r *Repository) RecordUse(ctx context.Context, credential *Credential) (bool, error) {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&Credential{}).
+		Where("id = ? AND (sign_count < ? OR sign_count = 0)", credential.ID, credential.SignCount).
+		Updates(map[string]interface{}{
+			"sign_count":   credential.SignCount,
+			"backup_state": credential.BackupState,
+			"last_used_at": now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	credential.LastUsedAt = &now
+	return result.RowsAffected > 0, nil
+}
+
+// RenameCredential renames a passkey
+func (r *Repository) RenameCredential(ctx context.Context, credential *Credential, name string) error {
+	credential.Name = name
+	return r.db.WithContext(ctx).Model(credential).Update("name", name).Error
+}
+
+// DeleteCredential deletes a passkey
+func (r *Repository) DeleteCredential(ctx context.Context, credential *Credential) error {
+	return r.db.WithContext(ctx).Delete(credential).Error
+}
+
+// ==================== Policies ====================
+
+// FindPolicy returns a user's policy, or nil if they never set one
+func (r *Repository) FindPolicy(ctx context.Context, userID uint) (*Policy, error) {
+	var policy Policy
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&policy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// SavePolicy creates or updates a user's policy
+func (r *Repository) SavePolicy(ctx context.Context, policy *Policy) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"password_login", "updated_at"}),
+	}).Create(policy).Error
+}
+
+// DeletePolicy resets a user's policy to the default
+func (r *Repository) DeletePolicy(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&Policy{}).Error
+}
+
+// ==================== Users ====================
+
+// FindAccount returns a user by ID, or nil
+func (r *Repository) FindAccount(ctx context.Context, userID uint) (*account, error) {
+	return r.findAccount(ctx, "id = ?", userID)
+}
+
+// FindAccountByEmail returns a user by email, or nil
+func (r *Repository) FindAccountByEmail(ctx context.Context, email string) (*account, error) {
+	return r.findAccount(ctx, "email = ?", email)
+}
+
+func (r *Repository) findAccount(ctx context.Context, query string, arg interface{}) (*account, error) {
+	var rows []account
+	err := r.db.WithContext(ctx).Table("users").
+		Select("id, email, name, username, is_active").
+		Where(query+" AND deleted_at IS NULL", arg).
+		Limit(1).
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return &rows[0], nil
+}
+
+// TouchLastLogin records a user's login time
+func (r *Repository) TouchLastLogin(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Table("users").Where("id = ?", userID).Update("last_login_at", time.Now()).Error
+}
@@ -0,0 +1,30 @@
+package passkey
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+
+	passkeys := router.Group("/passkeys")
+
+	// ==================== Login (Public) ====================
+	passkeys.Post("/login/begin", controller.BeginLogin)
+	passkeys.Post("/login/finish", controller.FinishLogin)
+
+	// ==================== Own Passkeys ====================
+	protected := passkeys.Group("", auth.AuthMiddleware(jwtManager))
+	protected.Post("/register/begin", auth.RequireStepUp(), controller.BeginRegistration)
+	protected.Post("/register/finish", controller.FinishRegistration)
+	protected.Get("/", controller.List)
+	protected.Get("/policy", controller.GetPolicy)
+	protected.Put("/policy", controller.UpdatePolicy)
+	protected.Patch("/:id", controller.Rename)
+	protected.Delete("/:id", controller.Delete)
+}
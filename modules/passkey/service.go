@@ -0,0 +1,533 @@
+package passkey
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/auth/webauthn"
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/rbac"
+	"neonexcore/pkg/signing"
+)
+
+// Fallback policies for password login once a user has a passkey
+const (
+	FallbackAllow  = "allow"   // Password login stays available
+	FallbackOptOut = "opt_out" // Users may turn password login off
+	FallbackDeny   = "deny"    // Password login is disabled
+)
+
+// Signed ceremony session actions
+const (
+	actionRegister = "passkey_register"
+	actionLogin    = "passkey_login"
+)
+
+// maxNameLength bounds passkey names
+const maxNameLength = 100
+
+// Config holds passkey configuration
+type Config struct {
+	WebAuthn webauthn.Config
+	Fallback string
+}
+
+// DefaultConfig returns default passkey configuration
+func DefaultConfig() Config {
+	return Config{
+		WebAuthn: webauthn.DefaultConfig(),
+		Fallback: FallbackAllow,
+	}
+}
+
+// Service runs passkey ceremonies and manages users' passkeys and their
+// password fallback. Ceremony state travels with the client as a signed,
+// single-use session token.
+type Service struct {
+	repo        *Repository
+	rp          *webauthn.RelyingParty
+	signer      *signing.Signer
+	jwtManager  *auth.JWTManager
+	rbacManager *rbac.Manager
+	config      Config
+}
+
+func NewService(repo *Repository, signer *signing.Signer, jwtManager *auth.JWTManager, rbacManager *rbac.Manager, config Config) *Service {
+	switch config.Fallback {
+	case FallbackAllow, FallbackOptOut, FallbackDeny:
+	default:
+		config.Fallback = FallbackAllow
+	}
+
+	return &Service{
+		repo:        repo,
+		rp:          webauthn.New(config.WebAuthn),
+		signer:      signer,
+		jwtManager:  jwtManager,
+		rbacManager: rbacManager,
+		config:      config,
+	}
+}
+
+// ==================== Registration ====================
+
+// BeginRegistration starts registering a passkey for a user
+func (s *Service) BeginRegistration(ctx context.Context, userID uint) (*webauthn.CreationOptions, string, error) {
+	user, err := s.repo.FindAccount(ctx, userID)
+	if err != nil {
+		return nil, "", errors.NewInternal("Failed to load user")
+	}
+	if user == nil {
+		return nil, "", errors.NewNotFound("User not found")
+	}
+
+	credentials, err := s.repo.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, "", errors.NewInternal("Failed to load passkeys")
+	}
+
+	displayName := user.Name
+	if displayName == "" {
+		displayName = user.Username
+	}
+	options, session, err := s.rp.BeginRegistration(webauthn.User{
+		ID:          userHandle(userID),
+		Name:        user.Email,
+		DisplayName: displayName,
+	}, toWebAuthn(credentials))
+	if err != nil {
+		return nil, "", errors.NewInternal("Failed to start passkey registration")
+	}
+
+	token, err := s.issueSession(actionRegister, userID, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return options, token, nil
+}
+
+// FinishRegistration verifies a new passkey and stores it
+func (s *Service) FinishRegistration(ctx context.Context, userID uint, token, name string, response *webauthn.RegistrationResponse) (*Credential, error) {
+	session, grant, err := s.redeemSession(ctx, actionRegister, token)
+	if err != nil {
+		return nil, err
+	}
+	if grant.UserID != userID {
+		return nil, errors.NewForbidden("Passkey session belongs to another user")
+	}
+
+	verified, err := s.rp.FinishRegistration(session, response)
+	if err != nil {
+		logger.Warn("Passkey registration failed", logger.Fields{"user_id": userID, "error": err.Error()})
+		return nil, errors.NewBadRequest("Passkey verification failed")
+	}
+
+	credentialID := webauthn.EncodeBase64(verified.ID)
+	existing, err := s.repo.FindByCredentialID(ctx, credentialID)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to check passkey")
+	}
+	if existing != nil {
+		return nil, errors.NewConflict("Passkey is already registered")
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > maxNameLength {
+		name = name[:maxNameLength]
+	}
+
+	credential := &Credential{
+		UserID:            userID,
+		CredentialID:      credentialID,
+		PublicKey:         verified.PublicKey,
+		SignCount:         verified.SignCount,
+		AAGUID:            formatAAGUID(verified.AAGUID),
+		Transports:        verified.Transports,
+		Name:              name,
+		AttestationFormat: verified.AttestationFormat,
+		BackupEligible:    verified.BackupEligible,
+		BackupState:       verified.BackupState,
+	}
+	if err := s.repo.CreateCredential(ctx, credential); err != nil {
+		return nil, errors.NewInternal("Failed to save passkey")
+	}
+
+	return credential, nil
+}
+
+// ==================== Login ====================
+
+// BeginLogin starts a passkey login. With an email the user's passkeys are
+// listed for the browser; otherwise, or for unknown emails, any
+// discoverable passkey may be used, so the response does not reveal
+// whether an account exists.
+func (s *Service) BeginLogin(ctx context.Context, email string) (*webauthn.RequestOptions, string, error) {
+	var allowed []webauthn.Credential
+	if email != "" {
+		user, err := s.repo.FindAccountByEmail(ctx, email)
+		if err != nil {
+			return nil, "", errors.NewInternal("Failed to load user")
+		}
+		if user != nil {
+			credentials, err := s.repo.ListCredentials(ctx, user.ID)
+			if err != nil {
+				return nil, "", errors.NewInternal("Failed to load passkeys")
+			}
+			allowed = toWebAuthn(credentials)
+		}
+	}
+
+	options, session, err := s.rp.BeginLogin(allowed)
+	if err != nil {
+		return nil, "", errors.NewInternal("Failed to start passkey login")
+	}
+
+	token, err := s.issueSession(actionLogin, 0, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return options, token, nil
+}
+
+// FinishLogin verifies a passkey assertion and issues tokens like a
+// password login
+func (s *Service) FinishLogin(ctx context.Context, token string, response *webauthn.LoginResponse) (map[string]interface{}, error) {
+	session, _, err := s.redeemSession(ctx, actionLogin, token)
+	if err != nil {
+		return nil, err
+	}
+
+	invalid := errors.New(errors.ErrCodeInvalidCredentials, "Passkey verification failed", 401)
+
+	credential, err := s.repo.FindByCredentialID(ctx, webauthn.EncodeBase64(response.CredentialID()))
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load passkey")
+	}
+	if credential == nil {
+		return nil, invalid
+	}
+
+	// A discoverable passkey names its user; it must be the owner
+	handle := response.Response.UserHandle
+	if len(handle) > 0 && string(handle) != string(userHandle(credential.UserID)) {
+		return nil, invalid
+	}
+
+	verified, err := s.rp.FinishLogin(session, toWebAuthnCredential(credential), response)
+	if err != nil {
+		if stderrors.Is(err, webauthn.ErrCloneDetected) {
+			logger.Error("Passkey counter regressed; possible cloned authenticator", logger.Fields{
+				"user_id":       credential.UserID,
+				"credential_id": credential.ID,
+			})
+		} else {
+			logger.Warn("Passkey login failed", logger.Fields{"user_id": credential.UserID, "error": err.Error()})
+		}
+		return nil, invalid
+	}
+
+	user, err := s.repo.FindAccount(ctx, credential.UserID)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load user")
+	}
+	if user == nil {
+		return nil, invalid
+	}
+	if !user.IsActive {
+		return nil, errors.New(errors.ErrCodeAccountDisabled, "Account is disabled", 403)
+	}
+
+	credential.SignCount = verified.SignCount
+	credential.BackupState = verified.BackupState
+	recorded, err := s.repo.RecordUse(ctx, credential)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to update passkey")
+	}
+	if !recorded {
+		// A concurrent login already used this counter value
+		return nil, invalid
+	}
+
+	return s.issueTokens(ctx, user, credential)
+}
+
+// issueTokens issues access and refresh tokens for a passkey login
+func (s *Service) issueTokens(ctx context.Context, user *account, credential *Credential) (map[string]interface{}, error) {
+	// Get user roles and permissions
+	roles, _ := s.rbacManager.GetUserRoles(ctx, user.ID)
+	permissions, _ := s.rbacManager.GetUserPermissions(ctx, user.ID)
+
+	var roleNames []string
+	for _, role := range roles {
+		roleNames = append(roleNames, role.Slug)
+	}
+
+	var permissionSlugs []string
+	for _, perm := range permissions {
+		permissionSlugs = append(permissionSlugs, perm.Slug)
+	}
+
+	primaryRole := "user"
+	if len(roleNames) > 0 {
+		primaryRole = roleNames[0]
+	}
+
+	accessToken, err := s.jwtManager.GenerateAccessToken(user.ID, user.Email, primaryRole, permissionSlugs)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to generate access token")
+	}
+
+	refreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID, user.Email)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to generate refresh token")
+	}
+
+	if err := s.repo.TouchLastLogin(ctx, user.ID); err != nil {
+		logger.Warn("Failed to record last login", logger.Fields{"user_id": user.ID, "error": err.Error()})
+	}
+
+	data := auth.ClientFields(ctx)
+	data["user_id"] = user.ID
+	data["email"] = user.Email
+	data["method"] = "passkey"
+	data["passkey_id"] = credential.ID
+	events.DispatchAsync(ctx, events.Event{
+		Name: events.EventUserLoggedIn,
+		Data: data,
+	})
+
+	return map[string]interface{}{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    900, // 15 minutes
+		"user": map[string]interface{}{
+			"id":       user.ID,
+			"name":     user.Name,
+			"email":    user.Email,
+			"username": user.Username,
+			"roles":    roleNames,
+		},
+	}, nil
+}
+
+// ==================== Management ====================
+
+// List lists a user's passkeys
+func (s *Service) List(ctx context.Context, userID uint) ([]Credential, error) {
+	credentials, err := s.repo.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load passkeys")
+	}
+	return credentials, nil
+}
+
+// Rename renames one of a user's passkeys
+func (s *Service) Rename(ctx context.Context, userID, id uint, name string) (*Credential, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLength {
+		return nil, errors.NewBadRequest("Name must be 1 to 100 characters")
+	}
+
+	credential, err := s.findOwned(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.RenameCredential(ctx, credential, name); err != nil {
+		return nil, errors.NewInternal("Failed to rename passkey")
+	}
+	return credential, nil
+}
+
+// Delete removes one of a user's passkeys. Removing the last one restores
+// password login, so the account cannot be locked out.
+func (s *Service) Delete(ctx context.Context, userID, id uint) error {
+	credential, err := s.findOwned(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteCredential(ctx, credential); err != nil {
+		return errors.NewInternal("Failed to delete passkey")
+	}
+
+	count, err := s.repo.CountCredentials(ctx, userID)
+	if err == nil && count == 0 {
+		if err := s.repo.DeletePolicy(ctx, userID); err != nil {
+			logger.Warn("Failed to reset passkey policy", logger.Fields{"user_id": userID, "error": err.Error()})
+		}
+	}
+	return nil
+}
+
+func (s *Service) findOwned(ctx context.Context, userID, id uint) (*Credential, error) {
+	credential, err := s.repo.FindCredential(ctx, userID, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load passkey")
+	}
+	if credential == nil {
+		return nil, errors.NewNotFound("Passkey not found")
+	}
+	return credential, nil
+}
+
+// ==================== Password Fallback ====================
+
+// GetPolicy describes the sign-in methods available to a user
+func (s *Service) GetPolicy(ctx context.Context, userID uint) (*PolicyInfo, error) {
+	count, err := s.repo.CountCredentials(ctx, userID)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load passkeys")
+	}
+	allowed, err := s.passwordLoginAllowed(ctx, userID, count)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load passkey policy")
+	}
+
+	return &PolicyInfo{
+		Fallback:      s.config.Fallback,
+		Passkeys:      count,
+		PasswordLogin: allowed,
+		CanChange:     s.config.Fallback == FallbackOptOut && count > 0,
+	}, nil
+}
+
+// SetPasswordLogin turns password login on or off for a user with a
+// passkey, when the fallback policy lets users choose
+func (s *Service) SetPasswordLogin(ctx context.Context, userID uint, enabled bool) (*PolicyInfo, error) {
+	if s.config.Fallback != FallbackOptOut {
+		return nil, errors.NewForbidden("Password login is managed by the server policy")
+	}
+	if !enabled {
+		count, err := s.repo.CountCredentials(ctx, userID)
+		if err != nil {
+			return nil, errors.NewInternal("Failed to load passkeys")
+		}
+		if count == 0 {
+			return nil, errors.NewBadRequest("Register a passkey before turning off password login")
+		}
+	}
+
+	if err := s.repo.SavePolicy(ctx, &Policy{UserID: userID, PasswordLogin: enabled, UpdatedAt: time.Now()}); err != nil {
+		return nil, errors.NewInternal("Failed to save passkey policy")
+	}
+	return s.GetPolicy(ctx, userID)
+}
+
+// PasswordLoginAllowed reports whether a user may sign in with a password.
+// It is installed as the auth package's password login policy.
+func (s *Service) PasswordLoginAllowed(ctx context.Context, userID uint) (bool, error) {
+	if s.config.Fallback == FallbackAllow {
+		return true, nil
+	}
+	count, err := s.repo.CountCredentials(ctx, userID)
+	if err != nil {
+		return true, err
+	}
+	return s.passwordLoginAllowed(ctx, userID, count)
+}
+
+func (s *Service) passwordLoginAllowed(ctx context.Context, userID uint, passkeys int64) (bool, error) {
+	if passkeys == 0 {
+		return true, nil
+	}
+	switch s.config.Fallback {
+	case FallbackDeny:
+		return false, nil
+	case FallbackOptOut:
+		policy, err := s.repo.FindPolicy(ctx, userID)
+		if err != nil {
+			return true, err
+		}
+		return policy == nil || policy.PasswordLogin, nil
+	default:
+		return true, nil
+	}
+}
+
+// ==================== Sessions ====================
+
+// issueSession signs a ceremony session for the client to send back
+func (s *Service) issueSession(action string, userID uint, session *webauthn.Session) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", errors.NewInternal("Failed to encode passkey session")
+	}
+	token, _, err := s.signer.Issue(signing.Grant{
+		Action:    action,
+		UserID:    userID,
+		Data:      map[string]string{"session": string(data)},
+		SingleUse: true,
+		ExpiresAt: time.Unix(session.ExpiresAt, 0),
+	})
+	if err != nil {
+		return "", errors.NewInternal("Failed to sign passkey session")
+	}
+	return token, nil
+}
+
+// redeemSession verifies and uses up a ceremony session
+func (s *Service) redeemSession(ctx context.Context, action, token string) (*webauthn.Session, *signing.Grant, error) {
+	grant, err := s.signer.Redeem(ctx, token, action)
+	if err != nil {
+		switch {
+		case stderrors.Is(err, signing.ErrExpiredToken), stderrors.Is(err, signing.ErrUsedToken):
+			return nil, nil, errors.NewBadRequest("Passkey session has expired or was already used; start again")
+		case stderrors.Is(err, signing.ErrInvalidToken), stderrors.Is(err, signing.ErrWrongAction):
+			return nil, nil, errors.NewBadRequest("Invalid passkey session")
+		default:
+			return nil, nil, errors.NewInternal("Failed to verify passkey session")
+		}
+	}
+
+	var session webauthn.Session
+	if err := json.Unmarshal([]byte(grant.Data["session"]), &session); err != nil {
+		return nil, nil, errors.NewBadRequest("Invalid passkey session")
+	}
+	return &session, grant, nil
+}
+
+// userHandle is the WebAuthn user handle of a user
+func userHandle(userID uint) []byte {
+	return []byte(strconv.FormatUint(uint64(userID), 10))
+}
+
+func toWebAuthnCredential(credential *Credential) *webauthn.Credential {
+	id, _ := webauthn.DecodeBase64(credential.CredentialID)
+	return &webauthn.Credential{
+		ID:                id,
+		PublicKey:         credential.PublicKey,
+		SignCount:         credential.SignCount,
+		Transports:        credential.Transports,
+		AttestationFormat: credential.AttestationFormat,
+		BackupEligible:    credential.BackupEligible,
+		BackupState:       credential.BackupState,
+	}
+}
+
+func toWebAuthn(credentials []Credential) []webauthn.Credential {
+	result := make([]webauthn.Credential, 0, len(credentials))
+	for i := range credentials {
+		result = append(result, *toWebAuthnCredential(&credentials[i]))
+	}
+	return result
+}
+
+// formatAAGUID formats an AAGUID as a UUID
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return ""
+	}
+	h := hex.EncodeToString(aaguid)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
@@ -0,0 +1,178 @@
+package payments
+
+import (
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// ==================== Public ====================
+
+// Pay returns the payment instructions of an invoice
+// @Summary Invoice payment instructions
+// @Description Address, amount still due and EIP-681 payment link of an invoice
+// @Tags Payments
+// @Produce json
+// @Param public_id path string true "Invoice public ID"
+// @Success 200 {object} api.Response{data=Instructions}
+// @Failure 404 {object} api.Response
+// @Router /payments/pay/{public_id} [get]
+func (c *Controller) Pay(ctx *fiber.Ctx) error {
+	instructions, err := c.service.Instructions(ctx.UserContext(), ctx.Params("public_id"))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, instructions)
+}
+
+// ==================== Invoices ====================
+
+// Assets lists the assets invoices may be requested in
+// @Summary List payment assets
+// @Tags Payments
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]Asset}
+// @Router /payments/assets [get]
+func (c *Controller) Assets(ctx *fiber.Ctx) error {
+	return api.Success(ctx, c.service.Assets(ctx.UserContext()))
+}
+
+// List returns invoices
+// @Summary List invoices
+// @Tags Payments
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "Status"
+// @Param network query string false "Network"
+// @Param reference query string false "Billing reference"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} api.PaginatedResponse{data=[]Invoice}
+// @Router /payments/invoices [get]
+func (c *Controller) List(ctx *fiber.Ctx) error {
+	filter := InvoiceFilter{
+		Status:    ctx.Query("status"),
+		Network:   ctx.Query("network"),
+		Reference: ctx.Query("reference"),
+	}
+
+	pagination := api.GetPagination(ctx)
+	invoices, total, err := c.service.List(ctx.UserContext(), filter, pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, invoices, pagination.Page, pagination.Limit, total)
+}
+
+// Create issues an invoice
+// @Summary Create invoice
+// @Description Issues an invoice with an address of its own, paid in the network's native currency or a configured ERC-20 token
+// @Tags Payments
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param invoice body CreateInput true "Invoice"
+// @Success 201 {object} api.Response{data=Invoice}
+// @Failure 400 {object} api.Response
+// @Router /payments/invoices [post]
+func (c *Controller) Create(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input CreateInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	invoice, err := c.service.Create(ctx.UserContext(), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Invoice created", invoice)
+}
+
+// Get returns an invoice with its payments
+// @Summary Get invoice
+// @Tags Payments
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Invoice ID"
+// @Success 200 {object} api.Response{data=Invoice}
+// @Failure 404 {object} api.Response
+// @Router /payments/invoices/{id} [get]
+func (c *Controller) Get(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid invoice ID", nil)
+	}
+
+	invoice, err := c.service.Get(ctx.UserContext(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, invoice)
+}
+
+// Cancel cancels an open invoice
+// @Summary Cancel invoice
+// @Tags Payments
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Invoice ID"
+// @Success 200 {object} api.Response{data=Invoice}
+// @Failure 409 {object} api.Response
+// @Router /payments/invoices/{id}/cancel [post]
+func (c *Controller) Cancel(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid invoice ID", nil)
+	}
+
+	invoice, err := c.service.Cancel(ctx.UserContext(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Invoice canceled", invoice)
+}
+
+// Simulate pays a test mode invoice on the sandbox chain
+// @Summary Simulate payment
+// @Description Test mode only. Sends the amount due, or the given amount, to the invoice from a sandbox payer.
+// @Tags Payments
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Invoice ID"
+// @Param payment body SimulateInput false "Payment"
+// @Success 200 {object} api.Response
+// @Failure 403 {object} api.Response
+// @Router /payments/invoices/{id}/simulate [post]
+func (c *Controller) Simulate(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid invoice ID", nil)
+	}
+
+	var input SimulateInput
+	if len(ctx.Body()) > 0 {
+		if err := validation.ValidateBody(ctx, &input); err != nil {
+			return api.RespondError(ctx, err)
+		}
+	}
+
+	hash, err := c.service.Simulate(ctx.UserContext(), uint(id), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Payment sent", fiber.Map{"tx_hash": hash})
+}
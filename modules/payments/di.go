@@ -0,0 +1,139 @@
+package payments
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/web3"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPollInterval   = 15 * time.Second
+	defaultWebhookTimeout = 10 * time.Second
+	defaultWebhookRetries = 5
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Service; without a seed invoices cannot be created
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		if encoded := os.Getenv("PAYMENTS_SEED"); encoded != "" {
+			seed, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil || len(seed) < 32 {
+				logger.Error("Invalid payments seed; it must be 32 base64 encoded bytes")
+			} else {
+				config.Seed = seed
+			}
+		} else {
+			logger.Warn("PAYMENTS_SEED is not set; invoices cannot be created")
+		}
+		tokens, err := ParseTokens(os.Getenv("PAYMENTS_TOKENS"))
+		if err != nil {
+			logger.Error("Invalid payments tokens", logger.Fields{"error": err.Error()})
+		}
+		config.Tokens = tokens
+		if n, err := strconv.ParseUint(os.Getenv("PAYMENTS_CONFIRMATIONS"), 10, 64); err == nil {
+			config.Confirmations = n
+		}
+		if d, err := time.ParseDuration(os.Getenv("PAYMENTS_INVOICE_TTL")); err == nil && d > 0 {
+			config.InvoiceTTL = d
+		}
+		if d, err := time.ParseDuration(os.Getenv("PAYMENTS_LATE_GRACE")); err == nil && d >= 0 {
+			config.LateGrace = d
+		}
+		if pct, err := strconv.ParseFloat(os.Getenv("PAYMENTS_UNDERPAYMENT_TOLERANCE"), 64); err == nil && pct >= 0 && pct < 100 {
+			config.Tolerance = pct
+		}
+
+		webhooks := NewWebhookDispatcher(
+			os.Getenv("PAYMENTS_WEBHOOK_URL"),
+			os.Getenv("PAYMENTS_WEBHOOK_SECRET"),
+			defaultWebhookTimeout,
+			defaultWebhookRetries,
+		)
+
+		return NewService(core.Resolve[*Repository](container), web3Manager(container), webhooks, config)
+	}, core.Singleton)
+
+	// Register Monitor
+	container.Provide(func() *Monitor {
+		interval := defaultPollInterval
+		if d, err := time.ParseDuration(os.Getenv("PAYMENTS_POLL_INTERVAL")); err == nil && d > 0 {
+			interval = d
+		}
+		return NewMonitor(core.Resolve[*Service](container), interval)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
+
+// web3Manager returns the application's web3 manager, or one connected
+// to the networks in PAYMENTS_NETWORKS
+func web3Manager(container *core.Container) *web3.Web3Manager {
+	if manager := core.Resolve[*web3.Web3Manager](container); manager != nil {
+		return manager
+	}
+
+	manager := web3.NewWeb3Manager()
+	for _, entry := range strings.Split(os.Getenv("PAYMENTS_NETWORKS"), ",") {
+		name, rpcURL, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		defaults, known := web3.DefaultNetworkConfigs[web3.Network(name)]
+		if !known {
+			logger.Error("Unknown payments network", logger.Fields{"network": name})
+			continue
+		}
+		config := *defaults
+		config.RPCURL = rpcURL
+		if err := manager.Connect(&config); err != nil {
+			logger.Error("Failed to connect payments network", logger.Fields{"network": name, "error": err.Error()})
+		}
+	}
+	return manager
+}
+
+// ParseTokens parses accepted ERC-20 tokens, as comma separated
+// network:SYMBOL:address:decimals entries
+func ParseTokens(value string) ([]Asset, error) {
+	var tokens []Asset
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 4 || !common.IsHexAddress(parts[2]) {
+			return tokens, fmt.Errorf("invalid token %q, want network:SYMBOL:address:decimals", entry)
+		}
+		decimals, err := strconv.Atoi(parts[3])
+		if err != nil || decimals < 0 || decimals > 36 {
+			return tokens, fmt.Errorf("invalid decimals of token %q", entry)
+		}
+		tokens = append(tokens, Asset{
+			Network:  parts[0],
+			Symbol:   strings.ToUpper(parts[1]),
+			Token:    common.HexToAddress(parts[2]).Hex(),
+			Decimals: decimals,
+		})
+	}
+	return tokens, nil
+}
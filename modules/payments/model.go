@@ -0,0 +1,84 @@
+package payments
+
+import (
+	"time"
+
+	"neonexcore/pkg/sandbox"
+)
+
+// Invoice statuses
+const (
+	StatusPending       = "pending"        // Awaiting payment
+	StatusPartiallyPaid = "partially_paid" // Received less than the amount, still open
+	StatusSettled       = "settled"        // Paid in full
+	StatusExpired       = "expired"        // Not paid in full before it expired
+	StatusCanceled      = "canceled"       // Canceled before it was paid in full
+)
+
+// Invoice is a request for a payment in the native currency or an ERC-20
+// token of a network, to an address of its own. Amounts are in base units
+// (wei for ether), as decimal strings.
+type Invoice struct {
+	ID          uint         `gorm:"primarykey" json:"id"`
+	PublicID    string       `gorm:"size:48;uniqueIndex;not null" json:"public_id"` // Identifies the invoice to payers
+	Network     string       `gorm:"size:50;not null;index:idx_payment_invoices_watch" json:"network"`
+	Mode        sandbox.Mode `gorm:"size:10;not null;default:'live';index:idx_payment_invoices_watch" json:"mode"`
+	Asset       string       `gorm:"size:20;not null" json:"asset"`  // Symbol, e.g. ETH or USDC
+	Token       string       `gorm:"size:42" json:"token,omitempty"` // ERC-20 contract; empty for the native currency
+	Decimals    int          `gorm:"not null" json:"decimals"`
+	Address     string       `gorm:"size:42;not null;index" json:"address"`
+	Salt        string       `gorm:"size:64;not null" json:"derivation_salt"` // Derives the address's key from PAYMENTS_SEED
+	Amount      string       `gorm:"size:78;not null" json:"amount"`
+	AmountPaid  string       `gorm:"size:78;not null;default:'0'" json:"amount_paid"`
+	Status      string       `gorm:"size:20;not null;default:'pending';index:idx_payment_invoices_watch" json:"status"`
+	Description string       `gorm:"size:500" json:"description,omitempty"`
+	Reference   string       `gorm:"size:255;index" json:"reference,omitempty"` // The billing system's own ID
+	Metadata    string       `gorm:"type:text" json:"-"`
+	StartBlock  uint64       `gorm:"not null" json:"start_block"` // Transfers before it are not payments
+	ExpiresAt   time.Time    `gorm:"index" json:"expires_at"`
+	SettledAt   *time.Time   `json:"settled_at,omitempty"`
+	CreatedBy   uint         `json:"created_by"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+
+	Payments    []Payment              `gorm:"foreignKey:InvoiceID" json:"payments,omitempty"`
+	MetadataMap map[string]interface{} `gorm:"-" json:"metadata,omitempty"`
+	URI         string                 `gorm:"-" json:"uri"`
+}
+
+// TableName specifies the table name for Invoice
+func (Invoice) TableName() string {
+	return "payment_invoices"
+}
+
+// Payment is a confirmed transfer to an invoice's address
+type Payment struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	InvoiceID   uint      `gorm:"not null;index" json:"invoice_id"`
+	Network     string    `gorm:"size:50;not null;uniqueIndex:idx_payments_transfer" json:"network"`
+	TxHash      string    `gorm:"size:66;not null;uniqueIndex:idx_payments_transfer" json:"tx_hash"`
+	LogIndex    int       `gorm:"not null;uniqueIndex:idx_payments_transfer" json:"log_index"` // -1 for native transfers
+	From        string    `gorm:"size:42;not null" json:"from"`
+	Amount      string    `gorm:"size:78;not null" json:"amount"`
+	BlockNumber uint64    `gorm:"not null" json:"block_number"`
+	Late        bool      `json:"late"` // Received after the invoice expired or was canceled
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for Payment
+func (Payment) TableName() string {
+	return "payments"
+}
+
+// ChainCursor is the next block to scan for payments on a network
+type ChainCursor struct {
+	Network   string       `gorm:"primaryKey;size:50"`
+	Mode      sandbox.Mode `gorm:"primaryKey;size:10"`
+	Block     uint64       `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+// TableName specifies the table name for ChainCursor
+func (ChainCursor) TableName() string {
+	return "payment_chain_cursors"
+}
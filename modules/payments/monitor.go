@@ -0,0 +1,78 @@
+package payments
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/sandbox"
+)
+
+// Monitor expires overdue invoices and scans the chains for payments on
+// an interval, in live and test mode alike
+type Monitor struct {
+	service  *Service
+	interval time.Duration
+
+	mu      sync.Mutex
+	started bool
+	stop    chan struct{}
+}
+
+// NewMonitor creates a payments monitor
+func NewMonitor(service *Service, interval time.Duration) *Monitor {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	return &Monitor{service: service, interval: interval}
+}
+
+// Start begins monitoring in the background. It is safe to call more than once.
+func (m *Monitor) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.started {
+		return
+	}
+	m.started = true
+	m.stop = make(chan struct{})
+	go m.run(m.stop)
+}
+
+// Stop ends monitoring
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.started {
+		close(m.stop)
+		m.started = false
+	}
+}
+
+func (m *Monitor) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.Check(context.Background())
+
+		select {
+		case <-ticker.C:
+		case <-m.service.wake:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Check expires overdue invoices, then records new payments. Payments
+// mined before an invoice expired still settle it, late.
+func (m *Monitor) Check(ctx context.Context) {
+	for _, mode := range []sandbox.Mode{sandbox.ModeLive, sandbox.ModeTest} {
+		modeCtx := sandbox.WithMode(ctx, mode)
+		m.service.ExpireInvoices(modeCtx)
+		m.service.Scan(modeCtx)
+	}
+}
@@ -0,0 +1,28 @@
+package payments
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PaymentsModule struct{}
+
+func New() *PaymentsModule {
+	return &PaymentsModule{}
+}
+
+func (m *PaymentsModule) Name() string {
+	return "payments"
+}
+
+func (m *PaymentsModule) Init() {}
+
+func (m *PaymentsModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *PaymentsModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
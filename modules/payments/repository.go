@@ -0,0 +1,165 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"neonexcore/pkg/sandbox"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InvoiceFilter narrows invoice listings
+type InvoiceFilter struct {
+	Status    string
+	Network   string
+	Reference string
+}
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ==================== Invoices ====================
+
+func (r *Repository) Create(ctx context.Context, invoice *Invoice) error {
+	return r.db.WithContext(ctx).Create(invoice).Error
+}
+
+func (r *Repository) List(ctx context.Context, filter InvoiceFilter, page, limit int) ([]Invoice, int64, error) {
+	var invoices []Invoice
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Invoice{}).Where("mode = ?", sandbox.FromContext(ctx))
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Network != "" {
+		query = query.Where("network = ?", filter.Network)
+	}
+	if filter.Reference != "" {
+		query = query.Where("reference = ?", filter.Reference)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&invoices).Error
+	return invoices, total, err
+}
+
+// FindByID returns an invoice of the context's mode with its payments
+func (r *Repository) FindByID(ctx context.Context, id uint) (*Invoice, error) {
+	return r.find(ctx, "id = ?", id)
+}
+
+// FindByPublicID returns an invoice of the context's mode with its payments
+func (r *Repository) FindByPublicID(ctx context.Context, publicID string) (*Invoice, error) {
+	return r.find(ctx, "public_id = ?", publicID)
+}
+
+func (r *Repository) find(ctx context.Context, query string, arg interface{}) (*Invoice, error) {
+	var invoice Invoice
+	err := r.db.WithContext(ctx).
+		Preload("Payments", func(db *gorm.DB) *gorm.DB { return db.Order("block_number, log_index") }).
+		Where(query, arg).
+		Where("mode = ?", sandbox.FromContext(ctx)).
+		First(&invoice).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// SetStatus moves an invoice from one of the given statuses to another,
+// reporting whether it was in one of them
+func (r *Repository) SetStatus(ctx context.Context, id uint, from []string, to string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&Invoice{}).
+		Where("id = ? AND status IN ?", id, from).
+		Updates(map[string]interface{}{"status": to, "updated_at": time.Now()})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Watchable returns the invoices of the context's mode payments may still
+// arrive for: open ones, and those expired, canceled or settled after since
+func (r *Repository) Watchable(ctx context.Context, since time.Time) ([]Invoice, error) {
+	var invoices []Invoice
+	err := r.db.WithContext(ctx).
+		Where("mode = ?", sandbox.FromContext(ctx)).
+		Where(r.db.
+			Where("status IN ?", []string{StatusPending, StatusPartiallyPaid}).
+			Or("status IN ? AND expires_at > ?", []string{StatusExpired, StatusCanceled}, since).
+			Or("status = ? AND settled_at > ?", StatusSettled, since)).
+		Find(&invoices).Error
+	return invoices, err
+}
+
+// Overdue returns the open invoices of the context's mode past their expiry
+func (r *Repository) Overdue(ctx context.Context, now time.Time) ([]Invoice, error) {
+	var invoices []Invoice
+	err := r.db.WithContext(ctx).
+		Where("mode = ? AND status IN ? AND expires_at <= ?", sandbox.FromContext(ctx), []string{StatusPending, StatusPartiallyPaid}, now).
+		Find(&invoices).Error
+	return invoices, err
+}
+
+// RecordPayment stores a payment and lets apply update its invoice, in one
+// transaction. Payments already recorded are skipped: recorded is false.
+func (r *Repository) RecordPayment(ctx context.Context, payment *Payment, apply func(invoice *Invoice) error) (invoice *Invoice, recorded bool, err error) {
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(payment)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		recorded = true
+
+		invoice = &Invoice{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(invoice, payment.InvoiceID).Error; err != nil {
+			return err
+		}
+		if err := apply(invoice); err != nil {
+			return err
+		}
+		if err := tx.Save(payment).Error; err != nil {
+			return err
+		}
+		return tx.Save(invoice).Error
+	})
+	return invoice, recorded, err
+}
+
+// ==================== Cursors ====================
+
+// Cursor returns the next block to scan on a network in the context's
+// mode, or nil before the first scan
+func (r *Repository) Cursor(ctx context.Context, network string) (*ChainCursor, error) {
+	var cursor ChainCursor
+	err := r.db.WithContext(ctx).Where("network = ? AND mode = ?", network, sandbox.FromContext(ctx)).First(&cursor).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// SaveCursor sets the next block to scan on a network
+func (r *Repository) SaveCursor(ctx context.Context, network string, block uint64) error {
+	return r.db.WithContext(ctx).Save(&ChainCursor{
+		Network: network,
+		Mode:    sandbox.FromContext(ctx),
+		Block:   block,
+	}).Error
+}
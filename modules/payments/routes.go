@@ -0,0 +1,33 @@
+package payments
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	// Start watching the chains for payments
+	core.Resolve[*Monitor](container).Start()
+
+	payments := router.Group("/payments")
+
+	// ==================== Public ====================
+	payments.Get("/pay/:public_id", controller.Pay)
+
+	// ==================== Invoices ====================
+	manage := payments.Group("", auth.AuthMiddleware(jwtManager, auth.AcceptAPIKeys()), rbac.RequirePermission(rbacManager, "payments.manage"))
+	manage.Get("/assets", controller.Assets)
+	manage.Get("/invoices", controller.List)
+	manage.Post("/invoices", controller.Create)
+	manage.Get("/invoices/:id", controller.Get)
+	manage.Post("/invoices/:id/cancel", controller.Cancel)
+	manage.Post("/invoices/:id/simulate", controller.Simulate)
+}
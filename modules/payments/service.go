@@ -0,0 +1,630 @@
+package payments
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/sandbox"
+	"neonexcore/pkg/web3"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Payment event names
+const (
+	EventInvoiceCreated       = "payments.invoice.created"
+	EventPaymentReceived      = "payments.payment.received"
+	EventInvoicePartiallyPaid = "payments.invoice.partially_paid"
+	EventInvoiceSettled       = "payments.invoice.settled"
+	EventInvoiceOverpaid      = "payments.invoice.overpaid"
+	EventInvoiceExpired       = "payments.invoice.expired"
+	EventInvoiceCanceled      = "payments.invoice.canceled"
+)
+
+// nativeDecimals is the decimals of every EVM native currency
+const nativeDecimals = 18
+
+// maxScanRounds bounds the block ranges a network is scanned for at once,
+// so a network far behind does not hold up the others
+const maxScanRounds = 20
+
+// Asset is an ERC-20 token invoices may be paid in
+type Asset struct {
+	Network  string `json:"network"`
+	Symbol   string `json:"symbol"`
+	Token    string `json:"token,omitempty"` // Empty for the native currency
+	Decimals int    `json:"decimals"`
+}
+
+// Config holds payments configuration
+type Config struct {
+	Seed          []byte        // Derives the address of each invoice
+	Tokens        []Asset       // ERC-20 tokens accepted, besides native currencies
+	InvoiceTTL    time.Duration // How long invoices stay open by default
+	LateGrace     time.Duration // How long payments are still detected after an invoice expired, was canceled or settled
+	Tolerance     float64       // Percentage of the amount an invoice may be short and still settle
+	Confirmations uint64        // Blocks on top of a payment before it counts; 0 for the indexer's default
+}
+
+// DefaultConfig returns default payments configuration
+func DefaultConfig() Config {
+	return Config{
+		InvoiceTTL: time.Hour,
+		LateGrace:  24 * time.Hour,
+	}
+}
+
+// CreateInput is the payload for creating an invoice
+type CreateInput struct {
+	Network     string                 `json:"network" validate:"required,max=50"`
+	Asset       string                 `json:"asset" validate:"required,max=20"`       // Symbol, e.g. ETH or USDC
+	Amount      string                 `json:"amount" validate:"required,max=80"`      // In the asset, e.g. "12.50"
+	ExpiresIn   int                    `json:"expires_in" validate:"omitempty,min=60"` // Seconds
+	Description string                 `json:"description" validate:"max=500"`
+	Reference   string                 `json:"reference" validate:"max=255"`
+	Metadata    map[string]interface{} `json:"metadata"`
+}
+
+// SimulateInput is the payload for paying a test mode invoice
+type SimulateInput struct {
+	Amount string `json:"amount" validate:"max=80"` // In the asset; the amount due when empty
+}
+
+// Instructions tell a payer how to pay an invoice
+type Instructions struct {
+	PublicID    string    `json:"public_id"`
+	Network     string    `json:"network"`
+	ChainID     string    `json:"chain_id"`
+	Asset       string    `json:"asset"`
+	Token       string    `json:"token,omitempty"`
+	Decimals    int       `json:"decimals"`
+	Address     string    `json:"address"`
+	Amount      string    `json:"amount"`     // Base units
+	AmountDue   string    `json:"amount_due"` // Base units still to pay
+	Display     string    `json:"display"`    // Amount due in the asset, e.g. "12.5"
+	URI         string    `json:"uri"`        // EIP-681 payment link
+	Status      string    `json:"status"`
+	Description string    `json:"description,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// InvoiceEvent is the data of payment events and webhooks
+type InvoiceEvent struct {
+	Invoice   *Invoice `json:"invoice"`
+	Payment   *Payment `json:"payment,omitempty"`
+	Overpaid  string   `json:"overpaid,omitempty"`  // Base units paid above the amount
+	Shortfall string   `json:"shortfall,omitempty"` // Base units still unpaid
+}
+
+// Service issues invoices for on-chain payments and settles them as
+// confirmed transfers to their addresses come in
+type Service struct {
+	repo     *Repository
+	manager  *web3.Web3Manager
+	webhooks *WebhookDispatcher
+	config   Config
+	wake     chan struct{} // Wakes the monitor after a simulated payment
+}
+
+func NewService(repo *Repository, manager *web3.Web3Manager, webhooks *WebhookDispatcher, config Config) *Service {
+	return &Service{
+		repo:     repo,
+		manager:  manager,
+		webhooks: webhooks,
+		config:   config,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// ==================== Invoices ====================
+
+// Assets lists the assets invoices may be requested in, per network
+// connected
+func (s *Service) Assets(ctx context.Context) []Asset {
+	var assets []Asset
+	for network := range web3.DefaultNetworkConfigs {
+		client, err := s.manager.ClientFor(ctx, network)
+		if err != nil {
+			continue
+		}
+		assets = append(assets, Asset{Network: string(network), Symbol: nativeSymbol(network, client), Decimals: nativeDecimals})
+	}
+	for _, token := range s.config.Tokens {
+		if _, err := s.manager.ClientFor(ctx, web3.Network(token.Network)); err == nil {
+			assets = append(assets, token)
+		}
+	}
+	return assets
+}
+
+// Create issues an invoice with an address of its own
+func (s *Service) Create(ctx context.Context, input *CreateInput, userID uint) (*Invoice, error) {
+	if len(s.config.Seed) == 0 {
+		return nil, errors.New(errors.ErrCodeInternal, "Payments are not configured", http.StatusServiceUnavailable)
+	}
+
+	network := web3.Network(input.Network)
+	client, err := s.manager.ClientFor(ctx, network)
+	if err != nil {
+		return nil, errors.NewBadRequest("Network is not available: " + input.Network)
+	}
+	asset, err := s.asset(network, client, input.Asset)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := web3.ParseUnits(input.Amount, asset.Decimals)
+	if err != nil || amount.Sign() <= 0 {
+		return nil, errors.NewValidationError("Invalid amount", map[string]interface{}{"amount": "must be a positive amount of " + asset.Symbol})
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.NewInternal("Failed to create invoice").WithError(err)
+	}
+	wallet, err := web3.DeriveWallet(s.config.Seed, salt)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to create invoice").WithError(err)
+	}
+	head, err := client.GetBlockNumber(ctx)
+	if err != nil {
+		return nil, errors.NewInternal("Network is not reachable").WithError(err)
+	}
+	publicID, err := randomToken()
+	if err != nil {
+		return nil, errors.NewInternal("Failed to create invoice").WithError(err)
+	}
+
+	ttl := s.config.InvoiceTTL
+	if input.ExpiresIn > 0 {
+		ttl = time.Duration(input.ExpiresIn) * time.Second
+	}
+	invoice := &Invoice{
+		PublicID:    "inv_" + publicID,
+		Network:     input.Network,
+		Mode:        sandbox.FromContext(ctx),
+		Asset:       asset.Symbol,
+		Token:       asset.Token,
+		Decimals:    asset.Decimals,
+		Address:     wallet.Address.Hex(),
+		Salt:        hex.EncodeToString(salt),
+		Amount:      amount.String(),
+		AmountPaid:  "0",
+		Status:      StatusPending,
+		Description: input.Description,
+		Reference:   input.Reference,
+		StartBlock:  head,
+		ExpiresAt:   time.Now().Add(ttl),
+		CreatedBy:   userID,
+	}
+	if len(input.Metadata) > 0 {
+		metadata, err := json.Marshal(input.Metadata)
+		if err != nil {
+			return nil, errors.NewBadRequest("Invalid metadata")
+		}
+		invoice.Metadata = string(metadata)
+	}
+
+	if err := s.repo.Create(ctx, invoice); err != nil {
+		return nil, errors.NewInternal("Failed to create invoice").WithError(err)
+	}
+	s.decorate(ctx, invoice)
+
+	events.DispatchAsync(ctx, events.Event{Name: EventInvoiceCreated, Data: InvoiceEvent{Invoice: invoice}})
+	return invoice, nil
+}
+
+func (s *Service) List(ctx context.Context, filter InvoiceFilter, page, limit int) ([]Invoice, int64, error) {
+	invoices, total, err := s.repo.List(ctx, filter, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list invoices").WithError(err)
+	}
+	for i := range invoices {
+		s.decorate(ctx, &invoices[i])
+	}
+	return invoices, total, nil
+}
+
+func (s *Service) Get(ctx context.Context, id uint) (*Invoice, error) {
+	invoice, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load invoice").WithError(err)
+	}
+	if invoice == nil {
+		return nil, errors.NewNotFound("Invoice not found")
+	}
+	s.decorate(ctx, invoice)
+	return invoice, nil
+}
+
+// Instructions returns what a payer needs to pay an invoice
+func (s *Service) Instructions(ctx context.Context, publicID string) (*Instructions, error) {
+	invoice, err := s.repo.FindByPublicID(ctx, publicID)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load invoice").WithError(err)
+	}
+	if invoice == nil {
+		return nil, errors.NewNotFound("Invoice not found")
+	}
+	s.decorate(ctx, invoice)
+
+	due := invoice.due()
+	chainID := ""
+	if client, err := s.manager.ClientFor(ctx, web3.Network(invoice.Network)); err == nil && client.ChainID() != nil {
+		chainID = client.ChainID().String()
+	}
+	return &Instructions{
+		PublicID:    invoice.PublicID,
+		Network:     invoice.Network,
+		ChainID:     chainID,
+		Asset:       invoice.Asset,
+		Token:       invoice.Token,
+		Decimals:    invoice.Decimals,
+		Address:     invoice.Address,
+		Amount:      invoice.Amount,
+		AmountDue:   due.String(),
+		Display:     web3.FormatUnits(due, invoice.Decimals),
+		URI:         invoice.URI,
+		Status:      invoice.Status,
+		Description: invoice.Description,
+		ExpiresAt:   invoice.ExpiresAt,
+	}, nil
+}
+
+// Cancel cancels an open invoice. Payments still arriving are recorded as
+// late, for refunds.
+func (s *Service) Cancel(ctx context.Context, id uint) (*Invoice, error) {
+	invoice, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	canceled, err := s.repo.SetStatus(ctx, id, []string{StatusPending, StatusPartiallyPaid}, StatusCanceled)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to cancel invoice").WithError(err)
+	}
+	if !canceled {
+		return nil, errors.NewConflict("Only pending or partially paid invoices can be canceled")
+	}
+	invoice.Status = StatusCanceled
+	s.notify(ctx, EventInvoiceCanceled, invoice, nil)
+	return invoice, nil
+}
+
+// Simulate pays a test mode invoice on the sandbox chain, from a sandbox
+// payer. The payment is detected like any other, on the next scan.
+func (s *Service) Simulate(ctx context.Context, id uint, input *SimulateInput) (string, error) {
+	if !sandbox.IsTest(ctx) {
+		return "", errors.NewForbidden("Payments can only be simulated in test mode")
+	}
+	invoice, err := s.Get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	client, err := s.manager.ClientFor(ctx, web3.Network(invoice.Network))
+	if err != nil || client.Sandbox() == nil {
+		return "", errors.NewBadRequest("Test mode runs on a testnet here; pay the invoice from a testnet wallet")
+	}
+
+	amount := invoice.due()
+	if input.Amount != "" {
+		amount, err = web3.ParseUnits(input.Amount, invoice.Decimals)
+		if err != nil || amount.Sign() <= 0 {
+			return "", errors.NewValidationError("Invalid amount", map[string]interface{}{"amount": "must be a positive amount of " + invoice.Asset})
+		}
+	}
+	if amount.Sign() <= 0 {
+		return "", errors.NewConflict("Invoice is paid in full")
+	}
+
+	var token *common.Address
+	if invoice.Token != "" {
+		address := common.HexToAddress(invoice.Token)
+		token = &address
+	}
+	hash, err := client.Sandbox().SimulatePayment(common.HexToAddress(invoice.Address), token, amount)
+	if err != nil {
+		return "", errors.NewInternal("Failed to simulate payment").WithError(err)
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return hash.Hex(), nil
+}
+
+// ==================== Settlement ====================
+
+// Scan looks for payments to the invoices of the context's mode, network
+// by network, from where the previous scan stopped
+func (s *Service) Scan(ctx context.Context) {
+	invoices, err := s.repo.Watchable(ctx, time.Now().Add(-s.config.LateGrace))
+	if err != nil {
+		logger.Warn("Failed to load invoices to watch", logger.Fields{"error": err.Error()})
+		return
+	}
+
+	byNetwork := make(map[string][]Invoice)
+	for _, invoice := range invoices {
+		byNetwork[invoice.Network] = append(byNetwork[invoice.Network], invoice)
+	}
+	for network, invoices := range byNetwork {
+		if err := s.scanNetwork(ctx, network, invoices); err != nil {
+			logger.Warn("Failed to scan for payments", logger.Fields{
+				"network": network,
+				"mode":    string(sandbox.FromContext(ctx)),
+				"error":   err.Error(),
+			})
+		}
+	}
+}
+
+// scanNetwork records the transfers to the addresses of a network's
+// invoices
+func (s *Service) scanNetwork(ctx context.Context, network string, invoices []Invoice) error {
+	client, err := s.manager.ClientFor(ctx, web3.Network(network))
+	if err != nil {
+		return err
+	}
+	indexer := web3.NewTransferIndexer(client, web3.IndexerConfig{Confirmations: s.config.Confirmations})
+
+	// Each address belongs to a single invoice
+	byAddress := make(map[common.Address]*Invoice, len(invoices))
+	tokens := make(map[common.Address]bool)
+	var filter web3.TransferFilter
+	start := invoices[0].StartBlock
+	for i := range invoices {
+		invoice := &invoices[i]
+		address := common.HexToAddress(invoice.Address)
+		byAddress[address] = invoice
+		filter.To = append(filter.To, address)
+		if invoice.Token == "" {
+			filter.Native = true
+		} else if token := common.HexToAddress(invoice.Token); !tokens[token] {
+			tokens[token] = true
+			filter.Tokens = append(filter.Tokens, token)
+		}
+		if invoice.StartBlock < start {
+			start = invoice.StartBlock
+		}
+	}
+
+	cursor, err := s.repo.Cursor(ctx, network)
+	if err != nil {
+		return err
+	}
+	// Blocks before the oldest invoice hold no payments
+	from := start
+	if cursor != nil && cursor.Block > from {
+		from = cursor.Block
+	}
+
+	for round := 0; round < maxScanRounds; round++ {
+		transfers, next, err := indexer.Scan(ctx, from, filter)
+		if err != nil {
+			return err
+		}
+		for _, transfer := range transfers {
+			invoice := byAddress[transfer.To]
+			if invoice == nil {
+				continue
+			}
+			if err := s.recordTransfer(ctx, invoice, transfer); err != nil {
+				return err
+			}
+		}
+		if next == from {
+			return nil
+		}
+		if err := s.repo.SaveCursor(ctx, network, next); err != nil {
+			return err
+		}
+		from = next
+	}
+	return nil
+}
+
+// recordTransfer records a transfer as a payment of an invoice, settling
+// it once paid in full
+func (s *Service) recordTransfer(ctx context.Context, invoice *Invoice, transfer web3.Transfer) error {
+	if transfer.BlockNumber < invoice.StartBlock {
+		return nil
+	}
+	if !invoice.paidWith(transfer.Token) {
+		logger.Warn("Transfer in another asset sent to an invoice address", logger.Fields{
+			"invoice_id": invoice.ID,
+			"tx_hash":    transfer.TxHash.Hex(),
+		})
+		return nil
+	}
+
+	payment := &Payment{
+		InvoiceID:   invoice.ID,
+		Network:     invoice.Network,
+		TxHash:      transfer.TxHash.Hex(),
+		LogIndex:    transfer.LogIndex,
+		From:        transfer.From.Hex(),
+		Amount:      transfer.Amount.String(),
+		BlockNumber: transfer.BlockNumber,
+	}
+
+	var changes []string
+	updated, recorded, err := s.repo.RecordPayment(ctx, payment, func(invoice *Invoice) error {
+		changes = s.apply(invoice, payment, transfer.Amount)
+		return nil
+	})
+	if err != nil || !recorded {
+		return err
+	}
+	*invoice = *updated
+
+	for _, event := range changes {
+		s.notify(ctx, event, updated, payment)
+	}
+	return nil
+}
+
+// apply adds a payment to an invoice, returning the events it causes
+func (s *Service) apply(invoice *Invoice, payment *Payment, amount *big.Int) []string {
+	paid := new(big.Int).Add(parseAmount(invoice.AmountPaid), amount)
+	invoice.AmountPaid = paid.String()
+	changes := []string{EventPaymentReceived}
+
+	switch invoice.Status {
+	case StatusPending, StatusPartiallyPaid, StatusExpired:
+		payment.Late = invoice.Status == StatusExpired
+		if s.covers(paid, parseAmount(invoice.Amount)) {
+			now := time.Now()
+			invoice.Status = StatusSettled
+			invoice.SettledAt = &now
+			changes = append(changes, EventInvoiceSettled)
+		} else if invoice.Status != StatusExpired {
+			invoice.Status = StatusPartiallyPaid
+			changes = append(changes, EventInvoicePartiallyPaid)
+		}
+	case StatusSettled:
+		payment.Late = true
+		changes = append(changes, EventInvoiceOverpaid)
+	case StatusCanceled:
+		payment.Late = true
+	}
+	return changes
+}
+
+// covers reports whether paid settles amount, within the tolerance
+func (s *Service) covers(paid, amount *big.Int) bool {
+	if paid.Cmp(amount) >= 0 {
+		return true
+	}
+	if s.config.Tolerance <= 0 {
+		return false
+	}
+	// paid >= amount * (100 - tolerance) / 100
+	required := new(big.Rat).SetInt(amount)
+	required.Mul(required, new(big.Rat).SetFloat64((100-s.config.Tolerance)/100))
+	return new(big.Rat).SetInt(paid).Cmp(required) >= 0
+}
+
+// ExpireInvoices expires the open invoices of the context's mode past
+// their expiry
+func (s *Service) ExpireInvoices(ctx context.Context) {
+	invoices, err := s.repo.Overdue(ctx, time.Now())
+	if err != nil {
+		logger.Warn("Failed to load overdue invoices", logger.Fields{"error": err.Error()})
+		return
+	}
+	for i := range invoices {
+		invoice := &invoices[i]
+		expired, err := s.repo.SetStatus(ctx, invoice.ID, []string{StatusPending, StatusPartiallyPaid}, StatusExpired)
+		if err != nil {
+			logger.Warn("Failed to expire invoice", logger.Fields{"invoice_id": invoice.ID, "error": err.Error()})
+			continue
+		}
+		if expired {
+			invoice.Status = StatusExpired
+			s.notify(ctx, EventInvoiceExpired, invoice, nil)
+		}
+	}
+}
+
+// notify dispatches a payment event and delivers it to the billing webhook
+func (s *Service) notify(ctx context.Context, name string, invoice *Invoice, payment *Payment) {
+	s.decorate(ctx, invoice)
+	data := InvoiceEvent{Invoice: invoice, Payment: payment}
+	paid, amount := parseAmount(invoice.AmountPaid), parseAmount(invoice.Amount)
+	if diff := new(big.Int).Sub(paid, amount); diff.Sign() > 0 {
+		data.Overpaid = diff.String()
+	} else if diff.Sign() < 0 {
+		data.Shortfall = new(big.Int).Neg(diff).String()
+	}
+
+	events.DispatchAsync(ctx, events.Event{Name: name, Data: data})
+	s.webhooks.Dispatch(ctx, name, data)
+}
+
+// ==================== Helpers ====================
+
+// asset resolves the asset of a new invoice: the network's native
+// currency, or a configured token
+func (s *Service) asset(network web3.Network, client *web3.Web3Client, symbol string) (*Asset, error) {
+	native := nativeSymbol(network, client)
+	if strings.EqualFold(symbol, native) || strings.EqualFold(symbol, "native") {
+		return &Asset{Network: string(network), Symbol: native, Decimals: nativeDecimals}, nil
+	}
+	for _, token := range s.config.Tokens {
+		if token.Network == string(network) && strings.EqualFold(token.Symbol, symbol) {
+			return &token, nil
+		}
+	}
+	return nil, errors.NewBadRequest("Asset " + symbol + " is not accepted on " + string(network))
+}
+
+// decorate fills an invoice's computed fields
+func (s *Service) decorate(ctx context.Context, invoice *Invoice) {
+	if invoice.Metadata != "" && invoice.MetadataMap == nil {
+		json.Unmarshal([]byte(invoice.Metadata), &invoice.MetadataMap)
+	}
+
+	request := web3.PaymentRequest{To: common.HexToAddress(invoice.Address), Amount: invoice.due()}
+	if client, err := s.manager.ClientFor(ctx, web3.Network(invoice.Network)); err == nil {
+		request.ChainID = client.ChainID()
+	}
+	if invoice.Token != "" {
+		token := common.HexToAddress(invoice.Token)
+		request.Token = &token
+	}
+	invoice.URI = request.URI()
+}
+
+// due returns the amount still to pay
+func (i *Invoice) due() *big.Int {
+	due := new(big.Int).Sub(parseAmount(i.Amount), parseAmount(i.AmountPaid))
+	if due.Sign() < 0 {
+		return new(big.Int)
+	}
+	return due
+}
+
+// paidWith reports whether a transfer is in the invoice's asset
+func (i *Invoice) paidWith(token *common.Address) bool {
+	if i.Token == "" {
+		return token == nil
+	}
+	return token != nil && *token == common.HexToAddress(i.Token)
+}
+
+// nativeSymbol returns the symbol of a network's native currency, as
+// configured for the live network
+func nativeSymbol(network web3.Network, client *web3.Web3Client) string {
+	if config, ok := web3.DefaultNetworkConfigs[network]; ok && config.NativeCoin != "" {
+		return config.NativeCoin
+	}
+	if config := client.Config(); config != nil && config.NativeCoin != "" {
+		return config.NativeCoin
+	}
+	return "native"
+}
+
+func parseAmount(amount string) *big.Int {
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return new(big.Int)
+	}
+	return value
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,143 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"neonexcore/pkg/httpclient"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/sandbox"
+)
+
+// Webhook headers
+const (
+	SignatureHeader = "X-Neonex-Signature" // sha256=<hex HMAC of "<timestamp>.<body>">
+	TimestampHeader = "X-Neonex-Timestamp"
+	EventHeader     = "X-Neonex-Event"
+	DeliveryHeader  = "X-Neonex-Delivery"
+)
+
+// WebhookPayload is the body posted to the billing webhook
+type WebhookPayload struct {
+	ID        string       `json:"id"` // Same across retries, for deduplication
+	Event     string       `json:"event"`
+	Mode      sandbox.Mode `json:"mode"`
+	CreatedAt time.Time    `json:"created_at"`
+	Data      InvoiceEvent `json:"data"`
+}
+
+// WebhookDispatcher posts payment events to the billing system's webhook
+// in the background
+type WebhookDispatcher struct {
+	url     string
+	secret  string
+	client  *http.Client
+	retries int
+	backoff time.Duration
+}
+
+// NewWebhookDispatcher creates a webhook dispatcher. Without a URL events
+// are only dispatched in-process.
+func NewWebhookDispatcher(url, secret string, timeout time.Duration, retries int) *WebhookDispatcher {
+	if retries < 0 {
+		retries = 0
+	}
+	return &WebhookDispatcher{
+		url:     url,
+		secret:  secret,
+		client:  httpclient.New(timeout),
+		retries: retries,
+		backoff: time.Second,
+	}
+}
+
+// Dispatch delivers an event asynchronously. Failures are retried with
+// exponential backoff and logged once retries are exhausted.
+func (d *WebhookDispatcher) Dispatch(ctx context.Context, event string, data InvoiceEvent) {
+	if d.url == "" {
+		return
+	}
+
+	id, err := randomToken()
+	if err != nil {
+		logger.Error("Failed to create payments webhook delivery", logger.Fields{"event": event, "error": err.Error()})
+		return
+	}
+	body, err := json.Marshal(WebhookPayload{
+		ID:        "evt_" + id,
+		Event:     event,
+		Mode:      sandbox.FromContext(ctx),
+		CreatedAt: time.Now(),
+		Data:      data,
+	})
+	if err != nil {
+		logger.Error("Failed to encode payments webhook payload", logger.Fields{"event": event, "error": err.Error()})
+		return
+	}
+
+	go func() {
+		backoff := d.backoff
+		for attempt := 0; ; attempt++ {
+			err := d.post(context.Background(), event, "evt_"+id, body)
+			if err == nil {
+				return
+			}
+			if attempt >= d.retries {
+				logger.Error("Payments webhook delivery failed", logger.Fields{
+					"event":      event,
+					"invoice_id": data.Invoice.ID,
+					"attempts":   attempt + 1,
+					"error":      err.Error(),
+				})
+				return
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}()
+}
+
+func (d *WebhookDispatcher) post(ctx context.Context, event, delivery string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, delivery)
+	req.Header.Set(TimestampHeader, timestamp)
+	if d.secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(d.secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign computes the webhook signature for a timestamp and body, so the
+// billing system can verify deliveries
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
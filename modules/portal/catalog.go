@@ -0,0 +1,94 @@
+package portal
+
+import (
+	"sort"
+
+	"neonexcore/pkg/events"
+)
+
+// EventType is an event API consumers may subscribe webhooks to
+type EventType struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Permission  string `json:"permission,omitempty"` // Required to subscribe and receive, if any
+}
+
+// DefaultCatalog lists the platform events offered to webhooks
+func DefaultCatalog() []EventType {
+	return []EventType{
+		{Name: events.EventUserCreated, Description: "A user account was created", Permission: "admin.users.manage"},
+		{Name: events.EventUserUpdated, Description: "A user account was updated", Permission: "admin.users.manage"},
+		{Name: events.EventUserDeleted, Description: "A user account was deleted", Permission: "admin.users.manage"},
+		{Name: events.EventSecurityAlert, Description: "Suspicious account activity was detected", Permission: "security.read"},
+		{Name: "cms.content.published", Description: "Content was published", Permission: "cms.content.read"},
+		{Name: "cms.content.unpublished", Description: "Content was unpublished", Permission: "cms.content.read"},
+		{Name: "comments.created", Description: "A comment was posted", Permission: "comments.moderate"},
+		{Name: "comments.flagged", Description: "A comment was flagged for review", Permission: "comments.moderate"},
+		{Name: "comments.moderated", Description: "A comment was approved or rejected", Permission: "comments.moderate"},
+		{Name: "moderation.flagged", Description: "Content was flagged for human review", Permission: "moderation.review"},
+		{Name: "moderation.blocked", Description: "Content was blocked automatically", Permission: "moderation.review"},
+		{Name: "moderation.reviewed", Description: "A reviewer approved or rejected flagged content", Permission: "moderation.review"},
+		{Name: "review.created", Description: "An item was queued for human review", Permission: "review.work"},
+		{Name: "review.decided", Description: "A reviewer decided a queued item", Permission: "review.work"},
+		{Name: "review.sla_breached", Description: "A queued item missed its review SLA", Permission: "review.work"},
+		{Name: "risk.review_required", Description: "A risk assessment needs manual review", Permission: "risk.read"},
+		{Name: "risk.denied", Description: "A risk assessment denied an action", Permission: "risk.read"},
+		{Name: "risk.reviewed", Description: "A reviewer decided a risk assessment", Permission: "risk.read"},
+		{Name: "compliance.document_published", Description: "A new terms, privacy or consent document version was published"},
+		{Name: "compliance.accepted", Description: "A user accepted a document version", Permission: "compliance.audit"},
+		{Name: "compliance.withdrawn", Description: "A user withdrew an optional consent", Permission: "compliance.audit"},
+		{Name: "compliance.export.requested", Description: "A user's data export was requested", Permission: "compliance.audit"},
+		{Name: "compliance.export.ready", Description: "A user's data export is ready to download", Permission: "compliance.audit"},
+		{Name: "compliance.export.failed", Description: "A user's data export failed", Permission: "compliance.audit"},
+		{Name: "forms.submission.created", Description: "A form submission was received", Permission: "forms.submissions.read"},
+		{Name: "links.created", Description: "A short link was created", Permission: "links.manage"},
+		{Name: "campaigns.started", Description: "An email campaign started sending", Permission: "campaigns.read"},
+		{Name: "campaigns.completed", Description: "An email campaign finished sending", Permission: "campaigns.read"},
+		{Name: "campaigns.canceled", Description: "An email campaign was canceled", Permission: "campaigns.read"},
+		{Name: "campaigns.unsubscribed", Description: "A recipient unsubscribed from email campaigns", Permission: "campaigns.read"},
+		{Name: "payments.invoice.created", Description: "A crypto payment invoice was created", Permission: "payments.manage"},
+		{Name: "payments.payment.received", Description: "A confirmed payment to an invoice was received", Permission: "payments.manage"},
+		{Name: "payments.invoice.partially_paid", Description: "An invoice received less than its amount", Permission: "payments.manage"},
+		{Name: "payments.invoice.settled", Description: "An invoice was paid in full", Permission: "payments.manage"},
+		{Name: "payments.invoice.overpaid", Description: "A settled invoice received another payment", Permission: "payments.manage"},
+		{Name: "payments.invoice.expired", Description: "An invoice expired before it was paid in full", Permission: "payments.manage"},
+		{Name: "payments.invoice.canceled", Description: "An invoice was canceled", Permission: "payments.manage"},
+		{Name: "incident.opened", Description: "An incident was opened", Permission: "incidents.read"},
+		{Name: "incident.acknowledged", Description: "An incident was acknowledged", Permission: "incidents.read"},
+		{Name: "incident.resolved", Description: "An incident was resolved", Permission: "incidents.read"},
+		{Name: "incident.sla_breached", Description: "An incident missed its SLA target", Permission: "incidents.read"},
+		{Name: "status.component.changed", Description: "A status page component changed state"},
+		{Name: "status.incident.created", Description: "A status page incident was posted"},
+		{Name: "status.incident.updated", Description: "A status page incident was updated"},
+	}
+}
+
+// Catalog is the set of subscribable events
+type Catalog struct {
+	types map[string]EventType
+}
+
+// NewCatalog creates a catalog of event types
+func NewCatalog(types []EventType) *Catalog {
+	catalog := &Catalog{types: make(map[string]EventType, len(types))}
+	for _, t := range types {
+		catalog.types[t.Name] = t
+	}
+	return catalog
+}
+
+// Lookup returns an event type by name
+func (c *Catalog) Lookup(name string) (EventType, bool) {
+	t, ok := c.types[name]
+	return t, ok
+}
+
+// List returns all event types sorted by name
+func (c *Catalog) List() []EventType {
+	types := make([]EventType, 0, len(c.types))
+	for _, t := range c.types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
@@ -0,0 +1,461 @@
+package portal
+
+import (
+	"strings"
+
+	"neonexcore/pkg/ai"
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/sandbox"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiKeyLocal holds the authenticated *APIKey for the request
+const apiKeyLocal = "api_key"
+
+type Controller struct {
+	service *Service
+	docs    *Docs
+}
+
+func NewController(service *Service, docs *Docs) *Controller {
+	return &Controller{service: service, docs: docs}
+}
+
+// ==================== Middleware ====================
+
+// Authenticate authenticates requests carrying a portal API key as the
+// key's owner. Requests without one pass through to JWT authentication.
+func (c *Controller) Authenticate(ctx *fiber.Ctx) error {
+	plaintext := apiKeyFromRequest(ctx)
+	if plaintext == "" {
+		return ctx.Next()
+	}
+
+	key, err := c.service.Authenticate(ctx.Context(), plaintext)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	auth.SetClaims(ctx, c.service.Claims(key))
+	sandbox.Set(ctx, key.Mode)
+	ai.SetCaller(ctx, key.Subject())
+	ctx.Locals(apiKeyLocal, key)
+	return ctx.Next()
+}
+
+// RequireSession rejects requests authenticated with an API key, so keys
+// cannot be used to manage keys or webhooks
+func (c *Controller) RequireSession(ctx *fiber.Ctx) error {
+	if _, ok := ctx.Locals(apiKeyLocal).(*APIKey); ok {
+		return api.Forbidden(ctx, "API keys cannot access the developer portal; sign in instead")
+	}
+	return ctx.Next()
+}
+
+// meteringSubject meters requests made with an API key against the key
+func meteringSubject(ctx *fiber.Ctx) string {
+	if key, ok := ctx.Locals(apiKeyLocal).(*APIKey); ok {
+		return key.Subject()
+	}
+	return ""
+}
+
+func apiKeyFromRequest(ctx *fiber.Ctx) string {
+	if key := ctx.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	if token := strings.TrimPrefix(ctx.Get(fiber.HeaderAuthorization), "Bearer "); strings.HasPrefix(token, KeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+// ==================== Docs ====================
+
+// Docs lists the API reference documentation
+// @Summary API documentation index
+// @Description Links to the OpenAPI and GraphQL references and how to authenticate with an API key
+// @Tags Portal
+// @Produce json
+// @Success 200 {object} api.Response{data=DocsIndex}
+// @Router /portal/docs [get]
+func (c *Controller) Docs(ctx *fiber.Ctx) error {
+	return api.Success(ctx, c.docs.Index(ctx.Route().Path))
+}
+
+// OpenAPI returns the OpenAPI spec
+// @Summary OpenAPI spec
+// @Tags Portal
+// @Produce json
+// @Success 200 {object} api.SwaggerSpec
+// @Failure 404 {object} api.Response
+// @Router /portal/docs/openapi.json [get]
+func (c *Controller) OpenAPI(ctx *fiber.Ctx) error {
+	spec := c.docs.OpenAPI()
+	if spec == nil {
+		return api.NotFound(ctx, "OpenAPI documentation is not available")
+	}
+	return ctx.JSON(spec)
+}
+
+// GraphQLSchema returns the GraphQL schema in SDL
+// @Summary GraphQL schema
+// @Tags Portal
+// @Produce plain
+// @Success 200 {string} string
+// @Failure 404 {object} api.Response
+// @Router /portal/docs/schema.graphql [get]
+func (c *Controller) GraphQLSchema(ctx *fiber.Ctx) error {
+	sdl := c.docs.GraphQLSchema()
+	if sdl == "" {
+		return api.NotFound(ctx, "GraphQL documentation is not available")
+	}
+	ctx.Type("txt", "utf-8")
+	return ctx.SendString(sdl)
+}
+
+// ==================== API Keys ====================
+
+// ListKeys lists the current user's API keys
+// @Summary List API keys
+// @Tags Portal
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]APIKey}
+// @Router /portal/keys [get]
+func (c *Controller) ListKeys(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	keys, err := c.service.ListKeys(ctx.Context(), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, keys)
+}
+
+// GetKey retrieves one of the current user's API keys
+// @Summary Get API key
+// @Tags Portal
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Key ID"
+// @Success 200 {object} api.Response{data=APIKey}
+// @Failure 404 {object} api.Response
+// @Router /portal/keys/{id} [get]
+func (c *Controller) GetKey(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid key ID", nil)
+	}
+
+	key, err := c.service.GetKey(ctx.Context(), uint(id), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, key)
+}
+
+// CreateKey creates an API key
+// @Summary Create API key
+// @Description Issue an API key acting as the current user, optionally limited to scopes. The key is only shown in this response.
+// @Tags Portal
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param key body CreateKeyInput true "Key"
+// @Success 201 {object} api.Response{data=CreatedKey}
+// @Failure 403 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /portal/keys [post]
+func (c *Controller) CreateKey(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input CreateKeyInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	key, err := c.service.CreateKey(ctx.Context(), userID, &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "API key created; store it now, it will not be shown again", key)
+}
+
+// RevokeKey revokes an API key
+// @Summary Revoke API key
+// @Tags Portal
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Key ID"
+// @Success 200 {object} api.Response{data=APIKey}
+// @Failure 404 {object} api.Response
+// @Router /portal/keys/{id} [delete]
+func (c *Controller) RevokeKey(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid key ID", nil)
+	}
+
+	key, err := c.service.RevokeKey(ctx.Context(), uint(id), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "API key revoked", key)
+}
+
+// ==================== Usage ====================
+
+// KeyUsage returns usage of one of the current user's API keys
+// @Summary API key usage
+// @Tags Portal
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Key ID"
+// @Param days query int false "Days to include (default 7)"
+// @Param interval query string false "Series interval (hour, day)"
+// @Success 200 {object} api.Response{data=KeyUsage}
+// @Failure 404 {object} api.Response
+// @Router /portal/keys/{id}/usage [get]
+func (c *Controller) KeyUsage(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid key ID", nil)
+	}
+
+	usage, err := c.service.KeyUsage(ctx.Context(), uint(id), userID, ctx.QueryInt("days", 7), ctx.Query("interval", IntervalHour))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, usage)
+}
+
+// Usage summarizes usage of all of the current user's API keys
+// @Summary API usage overview
+// @Tags Portal
+// @Security BearerAuth
+// @Produce json
+// @Param days query int false "Days to include (default 7)"
+// @Success 200 {object} api.Response{data=[]KeyUsage}
+// @Router /portal/usage [get]
+func (c *Controller) Usage(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	overview, err := c.service.UsageOverview(ctx.Context(), userID, ctx.QueryInt("days", 7))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, overview)
+}
+
+// ==================== Webhooks ====================
+
+// Events lists the events webhooks may subscribe to
+// @Summary Webhook event catalog
+// @Tags Portal
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]EventType}
+// @Router /portal/webhooks/events [get]
+func (c *Controller) Events(ctx *fiber.Ctx) error {
+	return api.Success(ctx, c.service.Catalog())
+}
+
+// ListWebhooks lists the current user's webhooks
+// @Summary List webhooks
+// @Tags Portal
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]Webhook}
+// @Router /portal/webhooks [get]
+func (c *Controller) ListWebhooks(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	webhooks, err := c.service.ListWebhooks(ctx.Context(), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, webhooks)
+}
+
+// GetWebhook retrieves one of the current user's webhooks
+// @Summary Get webhook
+// @Tags Portal
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} api.Response{data=Webhook}
+// @Failure 404 {object} api.Response
+// @Router /portal/webhooks/{id} [get]
+func (c *Controller) GetWebhook(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid webhook ID", nil)
+	}
+
+	webhook, err := c.service.GetWebhook(ctx.Context(), uint(id), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, webhook)
+}
+
+// CreateWebhook subscribes a URL to events
+// @Summary Create webhook
+// @Description Deliveries are signed with the returned secret, which is only shown in this response
+// @Tags Portal
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param webhook body WebhookInput true "Webhook"
+// @Success 201 {object} api.Response{data=CreatedWebhook}
+// @Failure 403 {object} api.Response
+// @Router /portal/webhooks [post]
+func (c *Controller) CreateWebhook(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input WebhookInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	webhook, err := c.service.CreateWebhook(ctx.Context(), userID, &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Webhook created; store the secret now, it will not be shown again", webhook)
+}
+
+// UpdateWebhook updates one of the current user's webhooks
+// @Summary Update webhook
+// @Tags Portal
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param webhook body WebhookInput true "Webhook"
+// @Success 200 {object} api.Response{data=Webhook}
+// @Failure 404 {object} api.Response
+// @Router /portal/webhooks/{id} [put]
+func (c *Controller) UpdateWebhook(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid webhook ID", nil)
+	}
+
+	var input WebhookInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	webhook, err := c.service.UpdateWebhook(ctx.Context(), uint(id), userID, &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, webhook)
+}
+
+// DeleteWebhook deletes one of the current user's webhooks
+// @Summary Delete webhook
+// @Tags Portal
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Success 204
+// @Failure 404 {object} api.Response
+// @Router /portal/webhooks/{id} [delete]
+func (c *Controller) DeleteWebhook(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid webhook ID", nil)
+	}
+
+	if err := c.service.DeleteWebhook(ctx.Context(), uint(id), userID); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.NoContent(ctx)
+}
+
+// RotateSecret replaces a webhook's signing secret
+// @Summary Rotate webhook secret
+// @Tags Portal
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} api.Response{data=CreatedWebhook}
+// @Failure 404 {object} api.Response
+// @Router /portal/webhooks/{id}/rotate-secret [post]
+func (c *Controller) RotateSecret(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid webhook ID", nil)
+	}
+
+	webhook, err := c.service.RotateSecret(ctx.Context(), uint(id), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Webhook secret rotated", webhook)
+}
+
+// TestWebhook sends a ping delivery
+// @Summary Test webhook
+// @Tags Portal
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} api.Response{data=Delivery}
+// @Failure 404 {object} api.Response
+// @Router /portal/webhooks/{id}/test [post]
+func (c *Controller) TestWebhook(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid webhook ID", nil)
+	}
+
+	delivery, err := c.service.TestWebhook(ctx.Context(), uint(id), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, delivery)
+}
+
+// ListDeliveries lists recent deliveries to a webhook
+// @Summary List webhook deliveries
+// @Tags Portal
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} api.Response{data=[]Delivery}
+// @Failure 404 {object} api.Response
+// @Router /portal/webhooks/{id}/deliveries [get]
+func (c *Controller) ListDeliveries(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid webhook ID", nil)
+	}
+
+	pagination := api.GetPagination(ctx)
+	deliveries, total, err := c.service.ListDeliveries(ctx.Context(), uint(id), userID, pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, deliveries, pagination.Page, pagination.Limit, total)
+}
@@ -0,0 +1,88 @@
+package portal
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/graphql"
+	"neonexcore/pkg/metering"
+	"neonexcore/pkg/rbac"
+	"neonexcore/pkg/sandbox"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultWebhookTimeout = 10 * time.Second
+	defaultWebhookRetries = 3
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Meter recording API usage per key
+	container.Provide(func() *metering.Meter {
+		config := metering.DefaultConfig()
+		if interval, err := time.ParseDuration(os.Getenv("METERING_FLUSH_INTERVAL")); err == nil && interval > 0 {
+			config.FlushInterval = interval
+		}
+		return metering.NewMeter(db, config)
+	}, core.Singleton)
+
+	// Register Webhook Dispatcher
+	container.Provide(func() *WebhookDispatcher {
+		timeout := defaultWebhookTimeout
+		if d, err := time.ParseDuration(os.Getenv("PORTAL_WEBHOOK_TIMEOUT")); err == nil && d > 0 {
+			timeout = d
+		}
+		retries := defaultWebhookRetries
+		if n, err := strconv.Atoi(os.Getenv("PORTAL_WEBHOOK_RETRIES")); err == nil && n >= 0 {
+			retries = n
+		}
+		return NewWebhookDispatcher(timeout, retries)
+	}, core.Singleton)
+
+	// Register Docs over the application's OpenAPI spec and GraphQL schema, if any
+	container.Provide(func() *Docs {
+		return NewDocs(core.Resolve[*api.SwaggerGenerator](container), core.Resolve[*graphql.Schema](container))
+	}, core.Singleton)
+
+	// Register Service
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		if n, err := strconv.Atoi(os.Getenv("PORTAL_MAX_KEYS_PER_USER")); err == nil && n >= 0 {
+			config.MaxKeysPerUser = n
+		}
+		if n, err := strconv.Atoi(os.Getenv("PORTAL_USAGE_MAX_DAYS")); err == nil && n > 0 {
+			config.MaxUsageDays = n
+		}
+		if n, err := strconv.Atoi(os.Getenv("PORTAL_WEBHOOK_MAX_FAILURES")); err == nil && n >= 0 {
+			config.WebhookMaxFailures = n
+		}
+		if days, err := strconv.Atoi(os.Getenv("PORTAL_DELIVERY_RETENTION_DAYS")); err == nil && days >= 0 {
+			config.DeliveryRetention = time.Duration(days) * 24 * time.Hour
+		}
+		// Test keys need the sandbox database (SANDBOX_ENABLED)
+		config.TestMode = core.Resolve[*sandbox.Partition](container) != nil
+
+		return NewService(
+			core.Resolve[*Repository](container),
+			core.Resolve[*metering.Meter](container),
+			core.Resolve[*WebhookDispatcher](container),
+			NewCatalog(DefaultCatalog()),
+			core.Resolve[*rbac.Manager](container),
+			config,
+		)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container), core.Resolve[*Docs](container))
+	}, core.Transient)
+}
@@ -0,0 +1,84 @@
+package portal
+
+import (
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/graphql"
+)
+
+// APIKeyHeader carries a portal API key. Keys are also accepted as
+// "Authorization: Bearer nxk_...".
+const APIKeyHeader = "X-API-Key"
+
+// DocsIndex points API consumers at the reference documentation
+type DocsIndex struct {
+	OpenAPI        string   `json:"openapi,omitempty"`
+	SwaggerUI      string   `json:"swagger_ui,omitempty"`
+	ReDoc          string   `json:"redoc,omitempty"`
+	GraphQLSchema  string   `json:"graphql_schema,omitempty"`
+	Authentication AuthInfo `json:"authentication"`
+}
+
+// AuthInfo describes how to authenticate with an API key
+type AuthInfo struct {
+	Header    string `json:"header"`
+	Bearer    bool   `json:"bearer"` // Keys may also be sent as bearer tokens
+	KeyPrefix string `json:"key_prefix"`
+}
+
+// Docs serves the API's OpenAPI and GraphQL references. Either may be
+// absent when the application doesn't expose that API.
+type Docs struct {
+	swagger *api.SwaggerGenerator
+	schema  *graphql.Schema
+}
+
+// NewDocs creates the docs source and documents API key authentication
+// in the OpenAPI spec
+func NewDocs(swagger *api.SwaggerGenerator, schema *graphql.Schema) *Docs {
+	if swagger != nil {
+		swagger.AddSecurityScheme("apiKeyAuth", map[string]interface{}{
+			"type":        "apiKey",
+			"in":          "header",
+			"name":        APIKeyHeader,
+			"description": "Portal API key, created under /portal/keys",
+		})
+	}
+	return &Docs{swagger: swagger, schema: schema}
+}
+
+// Index lists the available references. docsPath is the path the portal's
+// docs routes are mounted on.
+func (d *Docs) Index(docsPath string) *DocsIndex {
+	index := &DocsIndex{
+		Authentication: AuthInfo{
+			Header:    APIKeyHeader,
+			Bearer:    true,
+			KeyPrefix: KeyPrefix,
+		},
+	}
+	if d.swagger != nil {
+		index.OpenAPI = docsPath + "/openapi.json"
+		index.SwaggerUI = "/api/docs"
+		index.ReDoc = "/api/docs/redoc"
+	}
+	if d.schema != nil {
+		index.GraphQLSchema = docsPath + "/schema.graphql"
+	}
+	return index
+}
+
+// OpenAPI returns the OpenAPI spec, or nil
+func (d *Docs) OpenAPI() *api.SwaggerSpec {
+	if d.swagger == nil {
+		return nil
+	}
+	return d.swagger.GetSpec()
+}
+
+// GraphQLSchema returns the GraphQL schema in SDL, or ""
+func (d *Docs) GraphQLSchema() string {
+	if d.schema == nil {
+		return ""
+	}
+	return d.schema.String()
+}
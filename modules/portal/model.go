@@ -0,0 +1,131 @@
+package portal
+
+import (
+	"strings"
+	"time"
+
+	"neonexcore/pkg/metering"
+	"neonexcore/pkg/sandbox"
+
+	"gorm.io/gorm"
+)
+
+// APIKey is a self-service credential for calling the API on behalf of its
+// owner. Only a hash of the key is stored; the plaintext is shown once.
+type APIKey struct {
+	ID         uint         `gorm:"primarykey" json:"id"`
+	UserID     uint         `gorm:"index;not null" json:"user_id"`
+	Name       string       `gorm:"size:100;not null" json:"name"`
+	Prefix     string       `gorm:"size:16;not null" json:"prefix"` // Leading characters, to recognize the key
+	KeyHash    string       `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Scopes     string       `gorm:"size:1000;not null;default:'*'" json:"-"`     // Comma-separated permissions
+	Mode       sandbox.Mode `gorm:"size:10;not null;default:'live'" json:"mode"` // Test keys run requests in the sandbox
+	LastUsedAt *time.Time   `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time   `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time   `gorm:"index" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+
+	ScopeList []string `gorm:"-" json:"scopes"` // Decoded Scopes
+}
+
+// TableName specifies the table name for APIKey
+func (APIKey) TableName() string {
+	return "portal_api_keys"
+}
+
+// Usable reports whether the key may authenticate requests
+func (k *APIKey) Usable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// Subject is the metering subject the key's usage is recorded against
+func (k *APIKey) Subject() string {
+	return keySubject(k.ID)
+}
+
+// CreatedKey is a newly created key with its plaintext value
+type CreatedKey struct {
+	*APIKey
+	Key string `json:"key"` // Shown only once
+}
+
+// Webhook is a subscription delivering platform events to a consumer URL
+type Webhook struct {
+	ID             uint           `gorm:"primarykey" json:"id"`
+	UserID         uint           `gorm:"index;not null" json:"user_id"`
+	URL            string         `gorm:"type:text;not null" json:"url"`
+	Description    string         `gorm:"size:255" json:"description,omitempty"`
+	Events         string         `gorm:"type:text;not null" json:"-"`                 // Comma-separated event names
+	Mode           sandbox.Mode   `gorm:"size:10;not null;default:'live'" json:"mode"` // Receives events from requests in this mode
+	Secret         string         `gorm:"size:100;not null" json:"-"`
+	Active         bool           `gorm:"default:true" json:"active"`
+	FailureCount   int            `gorm:"default:0" json:"failure_count"` // Consecutive failed deliveries
+	LastDeliveryAt *time.Time     `json:"last_delivery_at,omitempty"`
+	LastStatus     int            `json:"last_status,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+
+	EventNames []string `gorm:"-" json:"events"` // Decoded Events
+}
+
+// TableName specifies the table name for Webhook
+func (Webhook) TableName() string {
+	return "portal_webhooks"
+}
+
+// Subscribed reports whether the webhook receives an event
+func (w *Webhook) Subscribed(event string) bool {
+	for _, name := range splitList(w.Events) {
+		if name == event {
+			return true
+		}
+	}
+	return false
+}
+
+// CreatedWebhook is a newly created webhook with its signing secret
+type CreatedWebhook struct {
+	*Webhook
+	Secret string `json:"secret"` // Shown only once
+}
+
+// Delivery is one attempt to deliver an event to a webhook
+type Delivery struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	WebhookID  uint      `gorm:"index;not null" json:"webhook_id"`
+	Event      string    `gorm:"size:100;not null" json:"event"`
+	Payload    string    `gorm:"type:text" json:"payload"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `gorm:"type:text" json:"error,omitempty"`
+	Attempts   int       `json:"attempts"`
+	Success    bool      `gorm:"index" json:"success"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name for Delivery
+func (Delivery) TableName() string {
+	return "portal_webhook_deliveries"
+}
+
+// KeyUsage is a key's usage over a period
+type KeyUsage struct {
+	Key     *APIKey           `json:"key"`
+	Summary *metering.Summary `json:"summary"`
+	Series  []metering.Usage  `json:"series,omitempty"`
+}
+
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
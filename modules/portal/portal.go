@@ -0,0 +1,32 @@
+package portal
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PortalModule struct{}
+
+func New() *PortalModule {
+	return &PortalModule{}
+}
+
+func (m *PortalModule) Name() string {
+	return "portal"
+}
+
+func (m *PortalModule) Init() {}
+
+func (m *PortalModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *PortalModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
+
+func (m *PortalModule) Middleware(c *core.Container) []fiber.Handler {
+	return Middleware(c)
+}
@@ -0,0 +1,161 @@
+package portal
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ==================== API Keys ====================
+
+func (r *Repository) ListKeys(ctx context.Context, userID uint) ([]APIKey, error) {
+	var keys []APIKey
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// CountActiveKeys counts a user's keys that have not been revoked
+func (r *Repository) CountActiveKeys(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *Repository) FindKey(ctx context.Context, id uint) (*APIKey, error) {
+	var key APIKey
+	err := r.db.WithContext(ctx).First(&key, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *Repository) FindKeyByHash(ctx context.Context, hash string) (*APIKey, error) {
+	var key APIKey
+	err := r.db.WithContext(ctx).Where("key_hash = ?", hash).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *Repository) CreateKey(ctx context.Context, key *APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *Repository) UpdateKey(ctx context.Context, key *APIKey) error {
+	return r.db.WithContext(ctx).Save(key).Error
+}
+
+// TouchKey records when a key was last used
+func (r *Repository) TouchKey(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&APIKey{}).Where("id = ?", id).
+		UpdateColumn("last_used_at", at).Error
+}
+
+// ==================== Webhooks ====================
+
+func (r *Repository) ListWebhooks(ctx context.Context, userID uint) ([]Webhook, error) {
+	var webhooks []Webhook
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// ListActiveWebhooks returns every enabled webhook
+func (r *Repository) ListActiveWebhooks(ctx context.Context) ([]Webhook, error) {
+	var webhooks []Webhook
+	err := r.db.WithContext(ctx).Where("active = ?", true).Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *Repository) FindWebhook(ctx context.Context, id uint) (*Webhook, error) {
+	var webhook Webhook
+	err := r.db.WithContext(ctx).First(&webhook, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *Repository) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	return r.db.WithContext(ctx).Create(webhook).Error
+}
+
+func (r *Repository) UpdateWebhook(ctx context.Context, webhook *Webhook) error {
+	return r.db.WithContext(ctx).Save(webhook).Error
+}
+
+func (r *Repository) DeleteWebhook(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&Webhook{}, id).Error
+}
+
+// RecordDelivery stores a delivery and updates the webhook's delivery
+// state, disabling it after maxFailures consecutive failures
+func (r *Repository) RecordDelivery(ctx context.Context, delivery *Delivery, maxFailures int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(delivery).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"last_delivery_at": delivery.CreatedAt,
+			"last_status":      delivery.StatusCode,
+		}
+		if delivery.Success {
+			updates["failure_count"] = 0
+		} else {
+			updates["failure_count"] = gorm.Expr("failure_count + 1")
+		}
+		if err := tx.Model(&Webhook{}).Where("id = ?", delivery.WebhookID).UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+
+		if delivery.Success || maxFailures <= 0 {
+			return nil
+		}
+		return tx.Model(&Webhook{}).
+			Where("id = ? AND failure_count >= ?", delivery.WebhookID, maxFailures).
+			UpdateColumn("active", false).Error
+	})
+}
+
+func (r *Repository) ListDeliveries(ctx context.Context, webhookID uint, page, limit int) ([]Delivery, int64, error) {
+	var deliveries []Delivery
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Delivery{}).Where("webhook_id = ?", webhookID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&deliveries).Error
+	return deliveries, total, err
+}
+
+// PruneDeliveries deletes delivery records older than the given time
+func (r *Repository) PruneDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("created_at < ?", before).Delete(&Delivery{})
+	return result.RowsAffected, result.Error
+}
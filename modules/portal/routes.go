@@ -0,0 +1,71 @@
+package portal
+
+import (
+	"time"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/metering"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const pruneInterval = 24 * time.Hour
+
+func init() {
+	// Shown as auth requirements by `neonex routes`
+	api.RegisterAuthHandler("portal.(*Controller).Authenticate", "api key (optional)")
+	api.RegisterAuthHandler("portal.(*Controller).RequireSession", "session only")
+}
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	service := core.Resolve[*Service](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+
+	// Flush metered usage and deliver catalog events to webhooks
+	core.Resolve[*metering.Meter](container).Start()
+	for _, eventType := range service.Catalog() {
+		events.Register(eventType.Name, service.HandleEvent)
+	}
+	service.StartPruning(pruneInterval)
+
+	// ==================== Docs ====================
+	docs := router.Group("/portal/docs")
+	docs.Get("", controller.Docs)
+	docs.Get("/openapi.json", controller.OpenAPI)
+	docs.Get("/schema.graphql", controller.GraphQLSchema)
+
+	// ==================== Self-service ====================
+	portal := router.Group("/portal", auth.AuthMiddleware(jwtManager), controller.RequireSession)
+	portal.Get("/keys", controller.ListKeys)
+	portal.Post("/keys", controller.CreateKey)
+	portal.Get("/keys/:id", controller.GetKey)
+	portal.Delete("/keys/:id", controller.RevokeKey)
+	portal.Get("/keys/:id/usage", controller.KeyUsage)
+	portal.Get("/usage", controller.Usage)
+
+	portal.Get("/webhooks/events", controller.Events)
+	portal.Get("/webhooks", controller.ListWebhooks)
+	portal.Post("/webhooks", controller.CreateWebhook)
+	portal.Get("/webhooks/:id", controller.GetWebhook)
+	portal.Put("/webhooks/:id", controller.UpdateWebhook)
+	portal.Delete("/webhooks/:id", controller.DeleteWebhook)
+	portal.Post("/webhooks/:id/rotate-secret", controller.RotateSecret)
+	portal.Post("/webhooks/:id/test", controller.TestWebhook)
+	portal.Get("/webhooks/:id/deliveries", controller.ListDeliveries)
+}
+
+// Middleware authenticates API key requests and meters them per key
+func Middleware(container *core.Container) []fiber.Handler {
+	controller := core.Resolve[*Controller](container)
+	meter := core.Resolve[*metering.Meter](container)
+
+	return []fiber.Handler{
+		metering.Middleware(meter, meteringSubject),
+		controller.Authenticate,
+	}
+}
@@ -0,0 +1,709 @@
+package portal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/httpclient"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/metering"
+	"neonexcore/pkg/rbac"
+	"neonexcore/pkg/sandbox"
+
+	"github.com/google/uuid"
+)
+
+// Portal event names
+const (
+	EventKeyCreated = "portal.key.created"
+	EventKeyRevoked = "portal.key.revoked"
+)
+
+// KeyPrefix starts every portal API key, so keys are recognizable in
+// Authorization headers and secret scanners. The key's mode follows, e.g.
+// nxk_test_...
+const KeyPrefix = "nxk_"
+
+// Usage series intervals
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+)
+
+const (
+	keyDisplayLength = 16
+	touchInterval    = time.Minute
+	deliveryTimeout  = 5 * time.Minute
+)
+
+// scopePattern matches a permission slug, optionally ending in ".*"
+var scopePattern = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_]+)*(\.\*)?$`)
+
+// Config holds portal configuration
+type Config struct {
+	MaxKeysPerUser     int           // Active keys a user may hold
+	MaxUsageDays       int           // Furthest back usage may be queried
+	WebhookMaxFailures int           // Consecutive failures before a webhook is disabled, 0 never
+	DeliveryRetention  time.Duration // How long delivery records are kept
+	TestMode           bool          // Whether test keys may be created and used
+}
+
+// DefaultConfig returns default portal configuration
+func DefaultConfig() Config {
+	return Config{
+		MaxKeysPerUser:     10,
+		MaxUsageDays:       90,
+		WebhookMaxFailures: 20,
+		DeliveryRetention:  30 * 24 * time.Hour,
+	}
+}
+
+// CreateKeyInput is the payload for creating an API key
+type CreateKeyInput struct {
+	Name      string       `json:"name" validate:"required,max=100"`
+	Scopes    []string     `json:"scopes"`                                    // Permissions the key is limited to; all of the owner's if empty
+	Mode      sandbox.Mode `json:"mode" validate:"omitempty,oneof=live test"` // live by default
+	ExpiresAt *time.Time   `json:"expires_at"`
+}
+
+// WebhookInput is the payload for creating or updating a webhook
+type WebhookInput struct {
+	URL         string       `json:"url" validate:"required,url,max=2048"`
+	Description string       `json:"description" validate:"max=255"`
+	Events      []string     `json:"events" validate:"required,min=1"`
+	Mode        sandbox.Mode `json:"mode" validate:"omitempty,oneof=live test"` // Set on creation only; live by default
+	Active      *bool        `json:"active"`
+}
+
+type Service struct {
+	repo     *Repository
+	meter    *metering.Meter
+	webhooks *WebhookDispatcher
+	catalog  *Catalog
+	rbac     *rbac.Manager
+	config   Config
+
+	pruneOnce sync.Once
+}
+
+func NewService(repo *Repository, meter *metering.Meter, webhooks *WebhookDispatcher, catalog *Catalog, rbacManager *rbac.Manager, config Config) *Service {
+	return &Service{
+		repo:     repo,
+		meter:    meter,
+		webhooks: webhooks,
+		catalog:  catalog,
+		rbac:     rbacManager,
+		config:   config,
+	}
+}
+
+// ==================== API Keys ====================
+
+// ListKeys lists a user's API keys
+func (s *Service) ListKeys(ctx context.Context, userID uint) ([]APIKey, error) {
+	keys, err := s.repo.ListKeys(ctx, userID)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to list API keys").WithError(err)
+	}
+	for i := range keys {
+		decodeKey(&keys[i])
+	}
+	return keys, nil
+}
+
+// GetKey retrieves one of a user's API keys
+func (s *Service) GetKey(ctx context.Context, id, userID uint) (*APIKey, error) {
+	key, err := s.repo.FindKey(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load API key").WithError(err)
+	}
+	if key == nil || key.UserID != userID {
+		return nil, errors.NewNotFound("API key not found")
+	}
+	decodeKey(key)
+	return key, nil
+}
+
+// CreateKey issues an API key. The plaintext key is only returned here.
+func (s *Service) CreateKey(ctx context.Context, userID uint, input *CreateKeyInput) (*CreatedKey, error) {
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, errors.NewBadRequest("Expiry must be in the future")
+	}
+
+	mode, err := s.checkMode(input.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, err := s.checkScopes(ctx, userID, input.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.config.MaxKeysPerUser > 0 {
+		count, err := s.repo.CountActiveKeys(ctx, userID)
+		if err != nil {
+			return nil, errors.NewInternal("Failed to count API keys").WithError(err)
+		}
+		if count >= int64(s.config.MaxKeysPerUser) {
+			return nil, errors.NewConflict(fmt.Sprintf("API key limit of %d reached; revoke an unused key first", s.config.MaxKeysPerUser))
+		}
+	}
+
+	token, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, errors.NewInternal("Failed to generate API key").WithError(err)
+	}
+	plaintext := KeyPrefix + string(mode) + "_" + strings.TrimRight(token, "=")
+
+	key := &APIKey{
+		UserID:    userID,
+		Name:      input.Name,
+		Prefix:    plaintext[:keyDisplayLength],
+		KeyHash:   hashKey(plaintext),
+		Scopes:    strings.Join(scopes, ","),
+		Mode:      mode,
+		ExpiresAt: input.ExpiresAt,
+	}
+	if err := s.repo.CreateKey(ctx, key); err != nil {
+		return nil, errors.NewInternal("Failed to create API key").WithError(err)
+	}
+	decodeKey(key)
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventKeyCreated,
+		Data: map[string]interface{}{
+			"key_id":  key.ID,
+			"user_id": userID,
+			"scopes":  key.ScopeList,
+			"mode":    key.Mode,
+		},
+	})
+
+	return &CreatedKey{APIKey: key, Key: plaintext}, nil
+}
+
+// RevokeKey permanently disables one of a user's API keys
+func (s *Service) RevokeKey(ctx context.Context, id, userID uint) (*APIKey, error) {
+	key, err := s.GetKey(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return key, nil
+	}
+
+	now := time.Now()
+	key.RevokedAt = &now
+	if err := s.repo.UpdateKey(ctx, key); err != nil {
+		return nil, errors.NewInternal("Failed to revoke API key").WithError(err)
+	}
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventKeyRevoked,
+		Data: map[string]interface{}{
+			"key_id":  key.ID,
+			"user_id": userID,
+		},
+	})
+
+	return key, nil
+}
+
+// Authenticate resolves a plaintext API key to a usable key
+func (s *Service) Authenticate(ctx context.Context, plaintext string) (*APIKey, error) {
+	if !strings.HasPrefix(plaintext, KeyPrefix) {
+		return nil, errors.NewUnauthorized("Invalid API key")
+	}
+
+	key, err := s.repo.FindKeyByHash(ctx, hashKey(plaintext))
+	if err != nil {
+		return nil, errors.NewInternal("Failed to verify API key").WithError(err)
+	}
+	now := time.Now()
+	if key == nil || !key.Usable(now) {
+		return nil, errors.NewUnauthorized("Invalid or expired API key")
+	}
+	if key.Mode == sandbox.ModeTest && !s.config.TestMode {
+		return nil, errors.NewUnauthorized("Test mode is not enabled")
+	}
+	decodeKey(key)
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= touchInterval {
+		go func(id uint) {
+			if err := s.repo.TouchKey(context.Background(), id, now); err != nil {
+				logger.Warn("Failed to record API key use", logger.Fields{"key_id": id, "error": err.Error()})
+			}
+		}(key.ID)
+	}
+
+	return key, nil
+}
+
+// Claims builds the request claims for an authenticated key. Keys act as
+// their owner, limited to the key's scopes.
+func (s *Service) Claims(key *APIKey) *auth.Claims {
+	return &auth.Claims{
+		UserID: key.UserID,
+		Role:   "api_key",
+		Metadata: map[string]string{
+			auth.MetadataAPIKeyID: fmt.Sprintf("%d", key.ID),
+			auth.MetadataScopes:   key.Scopes,
+			auth.MetadataMode:     string(key.Mode),
+		},
+	}
+}
+
+// checkMode validates a requested key or webhook mode
+func (s *Service) checkMode(mode sandbox.Mode) (sandbox.Mode, error) {
+	if mode == "" {
+		return sandbox.ModeLive, nil
+	}
+	if !mode.Valid() {
+		return "", errors.NewBadRequest("Mode must be live or test")
+	}
+	if mode == sandbox.ModeTest && !s.config.TestMode {
+		return "", errors.NewBadRequest("Test mode is not enabled")
+	}
+	return mode, nil
+}
+
+// checkScopes validates requested scopes, which must be well-formed and,
+// unless wildcards, held by the user
+func (s *Service) checkScopes(ctx context.Context, userID uint, requested []string) ([]string, error) {
+	scopes := make([]string, 0, len(requested))
+	seen := make(map[string]bool)
+	for _, scope := range requested {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || seen[scope] {
+			continue
+		}
+		seen[scope] = true
+
+		if scope == "*" {
+			return []string{"*"}, nil
+		}
+		if !scopePattern.MatchString(scope) {
+			return nil, errors.NewBadRequest(fmt.Sprintf("Invalid scope %q", scope))
+		}
+		if !strings.HasSuffix(scope, ".*") {
+			allowed, err := s.rbac.HasPermission(ctx, userID, scope)
+			if err != nil {
+				return nil, errors.NewInternal("Failed to check permissions").WithError(err)
+			}
+			if !allowed {
+				return nil, errors.NewForbidden(fmt.Sprintf("You do not have the %q permission", scope))
+			}
+		}
+		scopes = append(scopes, scope)
+	}
+
+	if len(scopes) == 0 {
+		return []string{"*"}, nil
+	}
+	return scopes, nil
+}
+
+// ==================== Usage ====================
+
+// KeyUsage returns one of a user's keys with its usage over the last days,
+// bucketed by interval
+func (s *Service) KeyUsage(ctx context.Context, id, userID uint, days int, interval string) (*KeyUsage, error) {
+	key, err := s.GetKey(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if interval != IntervalHour && interval != IntervalDay {
+		return nil, errors.NewBadRequest("Interval must be hour or day")
+	}
+
+	until := time.Now().UTC()
+	since := until.Add(-time.Duration(s.clampDays(days)) * 24 * time.Hour)
+
+	buckets, err := s.meter.Usage(ctx, key.Subject(), since, until)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load usage").WithError(err)
+	}
+
+	usage := &KeyUsage{
+		Key:     key,
+		Summary: metering.Summarize(key.Subject(), since, until, buckets),
+		Series:  buckets,
+	}
+	if interval == IntervalDay {
+		usage.Series = metering.Rollup(buckets, 24*time.Hour)
+	}
+	return usage, nil
+}
+
+// UsageOverview summarizes usage of all of a user's keys over the last days
+func (s *Service) UsageOverview(ctx context.Context, userID uint, days int) ([]KeyUsage, error) {
+	keys, err := s.ListKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	until := time.Now().UTC()
+	since := until.Add(-time.Duration(s.clampDays(days)) * 24 * time.Hour)
+
+	overview := make([]KeyUsage, 0, len(keys))
+	for i := range keys {
+		summary, err := s.meter.Summarize(ctx, keys[i].Subject(), since, until)
+		if err != nil {
+			return nil, errors.NewInternal("Failed to load usage").WithError(err)
+		}
+		overview = append(overview, KeyUsage{Key: &keys[i], Summary: summary})
+	}
+	return overview, nil
+}
+
+func (s *Service) clampDays(days int) int {
+	if days <= 0 {
+		return 7
+	}
+	if s.config.MaxUsageDays > 0 && days > s.config.MaxUsageDays {
+		return s.config.MaxUsageDays
+	}
+	return days
+}
+
+// ==================== Webhooks ====================
+
+// Catalog lists the events webhooks may subscribe to
+func (s *Service) Catalog() []EventType {
+	return s.catalog.List()
+}
+
+// ListWebhooks lists a user's webhooks
+func (s *Service) ListWebhooks(ctx context.Context, userID uint) ([]Webhook, error) {
+	webhooks, err := s.repo.ListWebhooks(ctx, userID)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to list webhooks").WithError(err)
+	}
+	for i := range webhooks {
+		decodeWebhook(&webhooks[i])
+	}
+	return webhooks, nil
+}
+
+// GetWebhook retrieves one of a user's webhooks
+func (s *Service) GetWebhook(ctx context.Context, id, userID uint) (*Webhook, error) {
+	webhook, err := s.repo.FindWebhook(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load webhook").WithError(err)
+	}
+	if webhook == nil || webhook.UserID != userID {
+		return nil, errors.NewNotFound("Webhook not found")
+	}
+	decodeWebhook(webhook)
+	return webhook, nil
+}
+
+// CreateWebhook subscribes a URL to events. The signing secret is only
+// returned here and when rotated.
+func (s *Service) CreateWebhook(ctx context.Context, userID uint, input *WebhookInput) (*CreatedWebhook, error) {
+	mode, err := s.checkMode(input.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := s.checkWebhook(ctx, userID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to generate webhook secret").WithError(err)
+	}
+
+	webhook := &Webhook{
+		UserID:      userID,
+		URL:         input.URL,
+		Description: input.Description,
+		Events:      strings.Join(names, ","),
+		Mode:        mode,
+		Secret:      secret,
+		Active:      input.Active == nil || *input.Active,
+	}
+	if err := s.repo.CreateWebhook(ctx, webhook); err != nil {
+		return nil, errors.NewInternal("Failed to create webhook").WithError(err)
+	}
+	decodeWebhook(webhook)
+
+	return &CreatedWebhook{Webhook: webhook, Secret: secret}, nil
+}
+
+// UpdateWebhook changes one of a user's webhooks. Re-enabling a webhook
+// resets its failure count.
+func (s *Service) UpdateWebhook(ctx context.Context, id, userID uint, input *WebhookInput) (*Webhook, error) {
+	webhook, err := s.GetWebhook(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := s.checkWebhook(ctx, userID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.URL = input.URL
+	webhook.Description = input.Description
+	webhook.Events = strings.Join(names, ",")
+	if input.Active != nil {
+		if *input.Active && !webhook.Active {
+			webhook.FailureCount = 0
+		}
+		webhook.Active = *input.Active
+	}
+
+	if err := s.repo.UpdateWebhook(ctx, webhook); err != nil {
+		return nil, errors.NewInternal("Failed to update webhook").WithError(err)
+	}
+	decodeWebhook(webhook)
+	return webhook, nil
+}
+
+// RotateSecret replaces a webhook's signing secret
+func (s *Service) RotateSecret(ctx context.Context, id, userID uint) (*CreatedWebhook, error) {
+	webhook, err := s.GetWebhook(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to generate webhook secret").WithError(err)
+	}
+	webhook.Secret = secret
+	if err := s.repo.UpdateWebhook(ctx, webhook); err != nil {
+		return nil, errors.NewInternal("Failed to rotate webhook secret").WithError(err)
+	}
+
+	return &CreatedWebhook{Webhook: webhook, Secret: secret}, nil
+}
+
+// DeleteWebhook removes one of a user's webhooks
+func (s *Service) DeleteWebhook(ctx context.Context, id, userID uint) error {
+	if _, err := s.GetWebhook(ctx, id, userID); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteWebhook(ctx, id); err != nil {
+		return errors.NewInternal("Failed to delete webhook").WithError(err)
+	}
+	return nil
+}
+
+// ListDeliveries lists recent deliveries to one of a user's webhooks
+func (s *Service) ListDeliveries(ctx context.Context, id, userID uint, page, limit int) ([]Delivery, int64, error) {
+	if _, err := s.GetWebhook(ctx, id, userID); err != nil {
+		return nil, 0, err
+	}
+	deliveries, total, err := s.repo.ListDeliveries(ctx, id, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list deliveries").WithError(err)
+	}
+	return deliveries, total, nil
+}
+
+// TestWebhook sends a ping to one of a user's webhooks and waits for the
+// result, without retries
+func (s *Service) TestWebhook(ctx context.Context, id, userID uint) (*Delivery, error) {
+	webhook, err := s.GetWebhook(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.deliver(ctx, webhook, EventPing, map[string]interface{}{"webhook_id": webhook.ID}, false)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to record delivery").WithError(err)
+	}
+	return delivery, nil
+}
+
+// HandleEvent delivers a platform event to subscribed webhooks in the
+// background. Owners must still hold the event's permission, and only
+// webhooks of the dispatching request's mode receive it.
+func (s *Service) HandleEvent(eventCtx context.Context, event events.Event) error {
+	eventType, ok := s.catalog.Lookup(event.Name)
+	if !ok {
+		return nil
+	}
+	mode := sandbox.FromContext(eventCtx)
+
+	// The dispatching request may finish before delivery does
+	ctx := context.Background()
+	webhooks, err := s.repo.ListActiveWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range webhooks {
+		webhook := &webhooks[i]
+		if webhook.Mode != mode || !webhook.Subscribed(event.Name) {
+			continue
+		}
+		if eventType.Permission != "" {
+			allowed, err := s.rbac.HasPermission(ctx, webhook.UserID, eventType.Permission)
+			if err != nil || !allowed {
+				continue
+			}
+		}
+		if events.Stubbed(eventCtx, "webhook", map[string]interface{}{"webhook_id": webhook.ID, "url": webhook.URL}) {
+			continue
+		}
+
+		go func(webhook *Webhook) {
+			ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+			defer cancel()
+			if _, err := s.deliver(ctx, webhook, event.Name, event.Data, true); err != nil {
+				logger.Error("Failed to record webhook delivery", logger.Fields{
+					"webhook_id": webhook.ID,
+					"event":      event.Name,
+					"error":      err.Error(),
+				})
+			}
+		}(webhook)
+	}
+	return nil
+}
+
+// PruneDeliveries removes delivery records past retention
+func (s *Service) PruneDeliveries(ctx context.Context) (int64, error) {
+	if s.config.DeliveryRetention <= 0 {
+		return 0, nil
+	}
+	return s.repo.PruneDeliveries(ctx, time.Now().Add(-s.config.DeliveryRetention))
+}
+
+// StartPruning prunes delivery records in the background every interval.
+// Later calls have no effect.
+func (s *Service) StartPruning(interval time.Duration) {
+	s.pruneOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if _, err := s.PruneDeliveries(context.Background()); err != nil {
+					logger.Warn("Failed to prune webhook deliveries", logger.Fields{"error": err.Error()})
+				}
+			}
+		}()
+	})
+}
+
+// deliver posts an event to a webhook and records the outcome
+func (s *Service) deliver(ctx context.Context, webhook *Webhook, event string, data interface{}, retry bool) (*Delivery, error) {
+	payload := WebhookPayload{
+		ID:        uuid.New().String(),
+		Event:     event,
+		Mode:      webhook.Mode,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	dispatcher := s.webhooks
+	if !retry {
+		dispatcher = &WebhookDispatcher{client: s.webhooks.client}
+	}
+	result := dispatcher.Deliver(ctx, webhook, payload.ID, event, body)
+
+	delivery := &Delivery{
+		WebhookID:  webhook.ID,
+		Event:      event,
+		Payload:    string(body),
+		StatusCode: result.StatusCode,
+		Attempts:   result.Attempts,
+		Success:    result.Err == nil,
+		DurationMs: result.Duration.Milliseconds(),
+		CreatedAt:  time.Now(),
+	}
+	if result.Err != nil {
+		delivery.Error = result.Err.Error()
+	}
+
+	// Record even when the caller's context was cancelled mid-delivery
+	if err := s.repo.RecordDelivery(context.Background(), delivery, s.config.WebhookMaxFailures); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// checkWebhook validates a webhook's URL and events, returning the
+// deduplicated event names
+func (s *Service) checkWebhook(ctx context.Context, userID uint, input *WebhookInput) ([]string, error) {
+	target, err := url.Parse(input.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, errors.NewBadRequest("Webhook URL must be an absolute http or https URL")
+	}
+	// Deliveries are checked again when dialed, in case the host's DNS changes
+	if err := httpclient.CheckPublicHost(ctx, target.Hostname()); err != nil {
+		if stderrors.Is(err, httpclient.ErrPrivateAddress) {
+			return nil, errors.NewBadRequest("Webhook URL must point to a public address")
+		}
+		return nil, errors.NewBadRequest("Webhook URL host could not be resolved")
+	}
+
+	names := make([]string, 0, len(input.Events))
+	seen := make(map[string]bool)
+	for _, name := range input.Events {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		eventType, ok := s.catalog.Lookup(name)
+		if !ok {
+			return nil, errors.NewBadRequest(fmt.Sprintf("Unknown event %q", name))
+		}
+		if eventType.Permission != "" {
+			allowed, err := s.rbac.HasPermission(ctx, userID, eventType.Permission)
+			if err != nil {
+				return nil, errors.NewInternal("Failed to check permissions").WithError(err)
+			}
+			if !allowed {
+				return nil, errors.NewForbidden(fmt.Sprintf("Subscribing to %q requires the %q permission", name, eventType.Permission))
+			}
+		}
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		return nil, errors.NewBadRequest("At least one event is required")
+	}
+	return names, nil
+}
+
+func decodeKey(key *APIKey) {
+	key.ScopeList = splitList(key.Scopes)
+}
+
+func decodeWebhook(webhook *Webhook) {
+	webhook.EventNames = splitList(webhook.Events)
+}
+
+func hashKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+func keySubject(id uint) string {
+	return fmt.Sprintf("api_key:%d", id)
+}
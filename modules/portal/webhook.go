@@ -0,0 +1,128 @@
+package portal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"neonexcore/pkg/httpclient"
+	"neonexcore/pkg/sandbox"
+)
+
+// Webhook headers, matching the form webhooks so receivers verify both the
+// same way
+const (
+	SignatureHeader = "X-Neonex-Signature" // sha256=<hex HMAC of "<timestamp>.<body>">
+	TimestampHeader = "X-Neonex-Timestamp"
+	EventHeader     = "X-Neonex-Event"
+	DeliveryHeader  = "X-Neonex-Delivery"
+)
+
+// EventPing is sent by the webhook test endpoint
+const EventPing = "portal.ping"
+
+// WebhookPayload is the body posted to a webhook
+type WebhookPayload struct {
+	ID        string       `json:"id"` // Unique per event, repeated across retries
+	Event     string       `json:"event"`
+	Mode      sandbox.Mode `json:"mode"` // test for events from test mode requests
+	CreatedAt time.Time    `json:"created_at"`
+	Data      interface{}  `json:"data"`
+}
+
+// DeliveryResult is the outcome of delivering a payload
+type DeliveryResult struct {
+	StatusCode int
+	Attempts   int
+	Duration   time.Duration
+	Err        error
+}
+
+// WebhookDispatcher posts signed payloads to webhook URLs
+type WebhookDispatcher struct {
+	client  *http.Client
+	retries int
+	backoff time.Duration
+}
+
+// NewWebhookDispatcher creates a webhook dispatcher
+func NewWebhookDispatcher(timeout time.Duration, retries int) *WebhookDispatcher {
+	if retries < 0 {
+		retries = 0
+	}
+	return &WebhookDispatcher{
+		client:  httpclient.NewPublic(timeout), // Webhook URLs come from users
+		retries: retries,
+		backoff: time.Second,
+	}
+}
+
+// Deliver posts the body, retrying failures with exponential backoff
+func (d *WebhookDispatcher) Deliver(ctx context.Context, webhook *Webhook, deliveryID, event string, body []byte) DeliveryResult {
+	start := time.Now()
+	backoff := d.backoff
+
+	var result DeliveryResult
+	for attempt := 0; ; attempt++ {
+		result.Attempts = attempt + 1
+		result.StatusCode, result.Err = d.post(ctx, webhook, deliveryID, event, body)
+		if result.Err == nil || attempt >= d.retries {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			result.Err = ctx.Err()
+			result.Duration = time.Since(start)
+			return result
+		}
+	}
+
+	result.Duration = time.Since(start)
+	return result
+}
+
+func (d *WebhookDispatcher) post(ctx context.Context, webhook *Webhook, deliveryID, event string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(webhook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign computes the webhook signature for a timestamp and body, so
+// receivers can verify deliveries
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
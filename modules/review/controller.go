@@ -0,0 +1,355 @@
+package review
+
+import (
+	"strconv"
+	"time"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultStatsDays is the stats window when none is given
+const defaultStatsDays = 30
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// ==================== Queues ====================
+
+// ListQueues returns all review queues
+// @Summary List review queues
+// @Tags Review
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]Queue}
+// @Router /reviews/queues [get]
+func (c *Controller) ListQueues(ctx *fiber.Ctx) error {
+	queues, err := c.service.ListQueues(ctx.UserContext())
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, queues)
+}
+
+// GetQueue returns a review queue
+// @Summary Get review queue
+// @Tags Review
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Queue ID"
+// @Success 200 {object} api.Response{data=Queue}
+// @Failure 404 {object} api.Response
+// @Router /reviews/queues/{id} [get]
+func (c *Controller) GetQueue(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid queue ID", nil)
+	}
+
+	queue, err := c.service.GetQueue(ctx.UserContext(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, queue)
+}
+
+// CreateQueue adds a review queue
+// @Summary Create review queue
+// @Description Decisions limits what reviewers may decide; any decision is accepted when empty
+// @Tags Review
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param queue body QueueInput true "Queue"
+// @Success 201 {object} api.Response{data=Queue}
+// @Failure 400 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /reviews/queues [post]
+func (c *Controller) CreateQueue(ctx *fiber.Ctx) error {
+	var input QueueInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	queue, err := c.service.CreateQueue(ctx.UserContext(), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Review queue created", queue)
+}
+
+// UpdateQueue replaces a review queue's settings
+// @Summary Update review queue
+// @Description SLA changes apply to items queued afterwards
+// @Tags Review
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Queue ID"
+// @Param queue body QueueInput true "Queue"
+// @Success 200 {object} api.Response{data=Queue}
+// @Failure 404 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /reviews/queues/{id} [put]
+func (c *Controller) UpdateQueue(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid queue ID", nil)
+	}
+
+	var input QueueInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	queue, err := c.service.UpdateQueue(ctx.UserContext(), uint(id), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Review queue updated", queue)
+}
+
+// Stats returns a queue's backlog and SLA performance
+// @Summary Review queue stats
+// @Description Backlog size and age, plus mean time to decision and SLA compliance for items decided in the last N days
+// @Tags Review
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Queue ID"
+// @Param days query int false "Window in days (default 30)"
+// @Success 200 {object} api.Response{data=Stats}
+// @Failure 404 {object} api.Response
+// @Router /reviews/queues/{id}/stats [get]
+func (c *Controller) Stats(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid queue ID", nil)
+	}
+	days, err := strconv.Atoi(ctx.Query("days"))
+	if err != nil || days <= 0 {
+		days = defaultStatsDays
+	}
+
+	stats, err := c.service.Stats(ctx.UserContext(), uint(id), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, stats)
+}
+
+// ==================== Items ====================
+
+// ListItems returns a queue's items, highest priority and oldest first
+// @Summary List review items
+// @Tags Review
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Queue ID"
+// @Param status query string false "Status (open, claimed, decided, canceled, all); open by default"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} api.PaginatedResponse{data=[]Item}
+// @Router /reviews/queues/{id}/items [get]
+func (c *Controller) ListItems(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid queue ID", nil)
+	}
+
+	filter := ItemFilter{QueueID: uint(id), Status: ctx.Query("status", StatusOpen)}
+	if filter.Status == "all" {
+		filter.Status = ""
+	}
+
+	pagination := api.GetPagination(ctx)
+	items, total, err := c.service.ListItems(ctx.UserContext(), filter, pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, items, pagination.Page, pagination.Limit, total)
+}
+
+// ClaimNext claims the next item of a queue for the caller
+// @Summary Claim next review item
+// @Description Claims the highest priority, longest waiting item that is open or whose claim lapsed. Data is null when the queue is empty.
+// @Tags Review
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Queue ID"
+// @Success 200 {object} api.Response{data=Item}
+// @Failure 404 {object} api.Response
+// @Router /reviews/queues/{id}/next [post]
+func (c *Controller) ClaimNext(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid queue ID", nil)
+	}
+
+	item, err := c.service.ClaimNext(ctx.UserContext(), uint(id), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	if item == nil {
+		return api.SuccessWithMessage(ctx, "Nothing to review", nil)
+	}
+	return api.SuccessWithMessage(ctx, "Item claimed", item)
+}
+
+// Enqueue submits an item for review
+// @Summary Submit review item
+// @Description Adds an item with context and suggested decisions. An undecided item with the same source and reference ID is updated instead.
+// @Tags Review
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param item body EnqueueInput true "Item"
+// @Success 201 {object} api.Response{data=Item}
+// @Failure 400 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /reviews/items [post]
+func (c *Controller) Enqueue(ctx *fiber.Ctx) error {
+	var input EnqueueInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	item, err := c.service.Enqueue(ctx.UserContext(), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Item queued for review", item)
+}
+
+// GetItem returns a review item
+// @Summary Get review item
+// @Tags Review
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {object} api.Response{data=Item}
+// @Failure 404 {object} api.Response
+// @Router /reviews/items/{id} [get]
+func (c *Controller) GetItem(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid item ID", nil)
+	}
+
+	item, err := c.service.GetItem(ctx.UserContext(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, item)
+}
+
+// Claim assigns an item to the caller
+// @Summary Claim review item
+// @Tags Review
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {object} api.Response{data=Item}
+// @Failure 409 {object} api.Response
+// @Router /reviews/items/{id}/claim [post]
+func (c *Controller) Claim(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid item ID", nil)
+	}
+
+	item, err := c.service.Claim(ctx.UserContext(), uint(id), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Item claimed", item)
+}
+
+// Release returns an item claimed by the caller to its queue
+// @Summary Release review item
+// @Tags Review
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {object} api.Response{data=Item}
+// @Failure 409 {object} api.Response
+// @Router /reviews/items/{id}/release [post]
+func (c *Controller) Release(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid item ID", nil)
+	}
+
+	item, err := c.service.Release(ctx.UserContext(), uint(id), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Item released", item)
+}
+
+// Decide records the caller's decision on an item
+// @Summary Decide review item
+// @Tags Review
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param decision body DecideInput true "Decision"
+// @Success 200 {object} api.Response{data=Item}
+// @Failure 400 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /reviews/items/{id}/decide [post]
+func (c *Controller) Decide(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid item ID", nil)
+	}
+
+	var input DecideInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	item, err := c.service.Decide(ctx.UserContext(), uint(id), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Decision recorded", item)
+}
+
+// Cancel withdraws an undecided item
+// @Summary Cancel review item
+// @Tags Review
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {object} api.Response{data=Item}
+// @Failure 409 {object} api.Response
+// @Router /reviews/items/{id}/cancel [post]
+func (c *Controller) Cancel(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid item ID", nil)
+	}
+
+	item, err := c.service.Cancel(ctx.UserContext(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Item canceled", item)
+}
@@ -0,0 +1,54 @@
+package review
+
+import (
+	"os"
+	"time"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/metrics"
+
+	"gorm.io/gorm"
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Service
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		if ttl, err := time.ParseDuration(os.Getenv("REVIEW_CLAIM_TTL")); err == nil && ttl > 0 {
+			config.ClaimTTL = ttl
+		}
+		// REVIEW_MODERATION_QUEUE= (empty) stops queueing flagged content
+		if queue, ok := os.LookupEnv("REVIEW_MODERATION_QUEUE"); ok {
+			config.ModerationQueue = queue
+		}
+		// REVIEW_RISK_QUEUE= (empty) stops queueing risk assessments
+		if queue, ok := os.LookupEnv("REVIEW_RISK_QUEUE"); ok {
+			config.RiskQueue = queue
+		}
+
+		return NewService(
+			core.Resolve[*Repository](container),
+			core.Resolve[*metrics.Collector](container),
+			config,
+		)
+	}, core.Singleton)
+
+	// Register SLA Checker
+	container.Provide(func() *SLAChecker {
+		interval, err := time.ParseDuration(os.Getenv("REVIEW_SLA_CHECK_INTERVAL"))
+		if err != nil {
+			interval = time.Minute
+		}
+		return NewSLAChecker(core.Resolve[*Service](container), interval)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
@@ -0,0 +1,120 @@
+package review
+
+import "time"
+
+// Item states
+const (
+	StatusOpen     = "open"     // Waiting for a reviewer
+	StatusClaimed  = "claimed"  // Being reviewed
+	StatusDecided  = "decided"  // Reviewed
+	StatusCanceled = "canceled" // Withdrawn by the submitter
+)
+
+// Queue is a named stream of items awaiting human review, such as
+// moderation flags, fraud alerts or low-confidence AI output
+type Queue struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	Slug         string    `gorm:"size:100;uniqueIndex;not null" json:"slug"`
+	Name         string    `gorm:"size:200;not null" json:"name"`
+	Description  string    `gorm:"size:1000" json:"description,omitempty"`
+	Decisions    []string  `gorm:"serializer:json" json:"decisions"` // Allowed decisions; any when empty
+	SLASeconds   int64     `gorm:"default:0" json:"sla_seconds"`     // Decide within; 0 for none
+	ClaimSeconds int64     `gorm:"default:0" json:"claim_seconds"`   // Claims lapse after this; 0 for the default
+	Active       bool      `gorm:"not null" json:"active"`           // Inactive queues accept no items
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for Queue
+func (Queue) TableName() string {
+	return "review_queues"
+}
+
+// SLA returns the queue's decision target, or zero
+func (q *Queue) SLA() time.Duration {
+	return time.Duration(q.SLASeconds) * time.Second
+}
+
+// allows reports whether a decision is valid for the queue
+func (q *Queue) allows(decision string) bool {
+	if len(q.Decisions) == 0 {
+		return true
+	}
+	for _, d := range q.Decisions {
+		if d == decision {
+			return true
+		}
+	}
+	return false
+}
+
+// Item is something awaiting a reviewer's decision
+type Item struct {
+	ID             uint                   `gorm:"primarykey" json:"id"`
+	QueueID        uint                   `gorm:"not null;index:idx_review_items_queue" json:"queue_id"`
+	Source         string                 `gorm:"size:100;index:idx_review_items_ref" json:"source,omitempty"`
+	ReferenceID    string                 `gorm:"size:100;index:idx_review_items_ref" json:"reference_id,omitempty"`
+	Title          string                 `gorm:"size:300" json:"title"`
+	Context        map[string]interface{} `gorm:"serializer:json" json:"context,omitempty"`
+	Suggestions    []Suggestion           `gorm:"serializer:json" json:"suggestions,omitempty"`
+	Priority       int                    `gorm:"default:0;index:idx_review_items_queue" json:"priority"`
+	Status         string                 `gorm:"size:20;not null;index:idx_review_items_queue" json:"status"`
+	ClaimedBy      *uint                  `json:"claimed_by,omitempty"`
+	ClaimedAt      *time.Time             `json:"claimed_at,omitempty"`
+	ClaimExpiresAt *time.Time             `json:"claim_expires_at,omitempty"`
+	Decision       string                 `gorm:"size:50" json:"decision,omitempty"`
+	Note           string                 `gorm:"size:2000" json:"note,omitempty"`
+	DecidedBy      *uint                  `json:"decided_by,omitempty"`
+	DecidedAt      *time.Time             `json:"decided_at,omitempty"`
+	DueAt          *time.Time             `gorm:"index" json:"due_at,omitempty"`
+	Breached       bool                   `gorm:"default:false" json:"breached"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+// TableName specifies the table name for Item
+func (Item) TableName() string {
+	return "review_items"
+}
+
+// pending reports whether the item still needs a decision
+func (i *Item) pending() bool {
+	return i.Status == StatusOpen || i.Status == StatusClaimed
+}
+
+// Suggestion is a decision proposed by the system that raised the item
+type Suggestion struct {
+	Decision   string  `json:"decision" validate:"required,max=50"`
+	Confidence float64 `json:"confidence" validate:"min=0,max=1"`
+	Reason     string  `json:"reason,omitempty" validate:"max=500"`
+	Source     string  `json:"source,omitempty" validate:"max=100"` // e.g. moderation, fraud, pipeline name
+}
+
+// ItemFilter narrows an item listing
+type ItemFilter struct {
+	QueueID uint
+	Status  string
+}
+
+// Stats describes a queue's backlog and how quickly it is worked
+type Stats struct {
+	QueueID            uint           `json:"queue_id"`
+	Slug               string         `json:"slug"`
+	Since              time.Time      `json:"since"`
+	Open               int64          `json:"open"`
+	Claimed            int64          `json:"claimed"`
+	Overdue            int64          `json:"overdue"`            // Pending past their SLA
+	OldestAgeSeconds   float64        `json:"oldest_age_seconds"` // Of pending items
+	Decided            int            `json:"decided"`            // Since the start of the period
+	MeanTimeToDecision float64        `json:"mean_time_to_decision_seconds"`
+	SLACompliance      float64        `json:"sla_compliance"` // Percent decided within SLA
+	ByDecision         map[string]int `json:"by_decision"`
+}
+
+// backlog is the pending item aggregate of a queue
+type backlog struct {
+	Open    int64
+	Claimed int64
+	Overdue int64
+	Oldest  *time.Time
+}
@@ -0,0 +1,246 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ==================== Queues ====================
+
+func (r *Repository) ListQueues(ctx context.Context) ([]Queue, error) {
+	var queues []Queue
+	err := r.db.WithContext(ctx).Order("name ASC").Find(&queues).Error
+	return queues, err
+}
+
+func (r *Repository) FindQueue(ctx context.Context, id uint) (*Queue, error) {
+	var queue Queue
+	if err := r.db.WithContext(ctx).First(&queue, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &queue, nil
+}
+
+func (r *Repository) FindQueueBySlug(ctx context.Context, slug string) (*Queue, error) {
+	var queue Queue
+	if err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&queue).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &queue, nil
+}
+
+func (r *Repository) CreateQueue(ctx context.Context, queue *Queue) error {
+	return r.db.WithContext(ctx).Create(queue).Error
+}
+
+func (r *Repository) UpdateQueue(ctx context.Context, queue *Queue) error {
+	return r.db.WithContext(ctx).Save(queue).Error
+}
+
+// ==================== Items ====================
+
+func (r *Repository) ListItems(ctx context.Context, filter ItemFilter, page, limit int) ([]Item, int64, error) {
+	var items []Item
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Item{})
+	if filter.QueueID != 0 {
+		query = query.Where("queue_id = ?", filter.QueueID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("priority DESC, created_at ASC").Offset(offset).Limit(limit).Find(&items).Error
+	return items, total, err
+}
+
+func (r *Repository) FindItem(ctx context.Context, id uint) (*Item, error) {
+	var item Item
+	if err := r.db.WithContext(ctx).First(&item, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// FindPendingByReference returns the undecided item a source raised for a
+// reference in a queue
+func (r *Repository) FindPendingByReference(ctx context.Context, queueID uint, source, referenceID string) (*Item, error) {
+	var item Item
+	err := r.db.WithContext(ctx).
+		Where("queue_id = ? AND source = ? AND reference_id = ? AND status IN ?", queueID, source, referenceID, []string{StatusOpen, StatusClaimed}).
+		First(&item).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// FindNextAvailable returns the highest priority, oldest item of a queue
+// that is open or whose claim has lapsed
+func (r *Repository) FindNextAvailable(ctx context.Context, queueID uint, now time.Time) (*Item, error) {
+	var item Item
+	err := r.db.WithContext(ctx).
+		Where("queue_id = ?", queueID).
+		Where("status = ? OR (status = ? AND claim_expires_at < ?)", StatusOpen, StatusClaimed, now).
+		Order("priority DESC, created_at ASC").
+		First(&item).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Claim assigns an item to a reviewer if it is open, its claim has lapsed or
+// the reviewer already holds it. It reports whether the claim was taken.
+func (r *Repository) Claim(ctx context.Context, id, reviewerID uint, now, expiresAt time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&Item{}).
+		Where("id = ?", id).
+		Where("status = ? OR (status = ? AND (claim_expires_at < ? OR claimed_by = ?))", StatusOpen, StatusClaimed, now, reviewerID).
+		Updates(map[string]interface{}{
+			"status":           StatusClaimed,
+			"claimed_by":       reviewerID,
+			"claimed_at":       now,
+			"claim_expires_at": expiresAt,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Release returns a claimed item to the queue
+func (r *Repository) Release(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Model(&Item{}).
+		Where("id = ? AND status = ?", id, StatusClaimed).
+		Updates(map[string]interface{}{
+			"status":           StatusOpen,
+			"claimed_by":       nil,
+			"claimed_at":       nil,
+			"claim_expires_at": nil,
+		}).Error
+}
+
+// Decide records a decision on an item that has not been decided yet. It
+// reports whether the decision was recorded.
+func (r *Repository) Decide(ctx context.Context, item *Item) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&Item{}).
+		Where("id = ? AND status IN ?", item.ID, []string{StatusOpen, StatusClaimed}).
+		Updates(map[string]interface{}{
+			"status":     item.Status,
+			"decision":   item.Decision,
+			"note":       item.Note,
+			"decided_by": item.DecidedBy,
+			"decided_at": item.DecidedAt,
+			"breached":   item.Breached,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *Repository) CreateItem(ctx context.Context, item *Item) error {
+	return r.db.WithContext(ctx).Create(item).Error
+}
+
+func (r *Repository) UpdateItem(ctx context.Context, item *Item) error {
+	return r.db.WithContext(ctx).Save(item).Error
+}
+
+// FindOverdue returns undecided items past their due time that have not been
+// flagged yet
+func (r *Repository) FindOverdue(ctx context.Context, now time.Time) ([]Item, error) {
+	var items []Item
+	err := r.db.WithContext(ctx).
+		Where("status IN ? AND breached = ? AND due_at < ?", []string{StatusOpen, StatusClaimed}, false, now).
+		Find(&items).Error
+	return items, err
+}
+
+// MarkBreached flags items as having missed their SLA
+func (r *Repository) MarkBreached(ctx context.Context, ids []uint) error {
+	return r.db.WithContext(ctx).Model(&Item{}).Where("id IN ?", ids).Update("breached", true).Error
+}
+
+// Backlog aggregates a queue's undecided items
+func (r *Repository) Backlog(ctx context.Context, queueID uint, now time.Time) (*backlog, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db.WithContext(ctx).Model(&Item{}).
+		Select("status, COUNT(*) AS count").
+		Where("queue_id = ? AND status IN ?", queueID, []string{StatusOpen, StatusClaimed}).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := &backlog{}
+	for _, row := range rows {
+		if row.Status == StatusOpen {
+			result.Open = row.Count
+		} else {
+			result.Claimed = row.Count
+		}
+	}
+	if result.Open+result.Claimed == 0 {
+		return result, nil
+	}
+
+	err = r.db.WithContext(ctx).Model(&Item{}).
+		Where("queue_id = ? AND status IN ? AND due_at < ?", queueID, []string{StatusOpen, StatusClaimed}, now).
+		Count(&result.Overdue).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var oldest Item
+	err = r.db.WithContext(ctx).
+		Where("queue_id = ? AND status IN ?", queueID, []string{StatusOpen, StatusClaimed}).
+		Order("created_at ASC").
+		First(&oldest).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		result.Oldest = &oldest.CreatedAt
+	}
+	return result, nil
+}
+
+// DecidedSince returns a queue's items decided after the given time
+func (r *Repository) DecidedSince(ctx context.Context, queueID uint, since time.Time) ([]Item, error) {
+	var items []Item
+	err := r.db.WithContext(ctx).
+		Where("queue_id = ? AND status = ? AND decided_at >= ?", queueID, StatusDecided, since).
+		Find(&items).Error
+	return items, err
+}
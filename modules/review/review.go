@@ -0,0 +1,28 @@
+package review
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReviewModule struct{}
+
+func New() *ReviewModule {
+	return &ReviewModule{}
+}
+
+func (m *ReviewModule) Name() string {
+	return "review"
+}
+
+func (m *ReviewModule) Init() {}
+
+func (m *ReviewModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *ReviewModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
@@ -0,0 +1,41 @@
+package review
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	// Queue flagged moderation content and risk alerts, and watch SLA deadlines
+	events.Register(eventModerationFlagged, core.Resolve[*Service](container).HandleEvent)
+	events.Register(eventRiskReviewRequired, core.Resolve[*Service](container).HandleEvent)
+	core.Resolve[*SLAChecker](container).Start()
+
+	reviews := router.Group("/reviews", auth.AuthMiddleware(jwtManager, auth.AcceptAPIKeys()))
+
+	// ==================== Queues ====================
+	reviews.Get("/queues", rbac.RequirePermission(rbacManager, "review.work"), controller.ListQueues)
+	reviews.Post("/queues", rbac.RequirePermission(rbacManager, "review.manage"), controller.CreateQueue)
+	reviews.Get("/queues/:id", rbac.RequirePermission(rbacManager, "review.work"), controller.GetQueue)
+	reviews.Put("/queues/:id", rbac.RequirePermission(rbacManager, "review.manage"), controller.UpdateQueue)
+	reviews.Get("/queues/:id/stats", rbac.RequirePermission(rbacManager, "review.work"), controller.Stats)
+	reviews.Get("/queues/:id/items", rbac.RequirePermission(rbacManager, "review.work"), controller.ListItems)
+	reviews.Post("/queues/:id/next", rbac.RequirePermission(rbacManager, "review.work"), controller.ClaimNext)
+
+	// ==================== Items ====================
+	reviews.Post("/items", rbac.RequirePermission(rbacManager, "review.submit"), controller.Enqueue)
+	reviews.Get("/items/:id", rbac.RequirePermission(rbacManager, "review.work"), controller.GetItem)
+	reviews.Post("/items/:id/claim", rbac.RequirePermission(rbacManager, "review.work"), controller.Claim)
+	reviews.Post("/items/:id/release", rbac.RequirePermission(rbacManager, "review.work"), controller.Release)
+	reviews.Post("/items/:id/decide", rbac.RequirePermission(rbacManager, "review.work"), controller.Decide)
+	reviews.Post("/items/:id/cancel", rbac.RequirePermission(rbacManager, "review.submit"), controller.Cancel)
+}
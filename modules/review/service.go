@@ -0,0 +1,656 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/metrics"
+)
+
+// Review event names
+const (
+	EventItemCreated     = "review.created"
+	EventItemDecided     = "review.decided"
+	EventItemSLABreached = "review.sla_breached"
+)
+
+// eventModerationFlagged is dispatched by the moderation module for content
+// that needs a human decision
+const eventModerationFlagged = "moderation.flagged"
+
+// eventRiskReviewRequired is dispatched by the risk module for assessments
+// scored between its review and deny thresholds
+const eventRiskReviewRequired = "risk.review_required"
+
+// Config holds review queue configuration
+type Config struct {
+	ClaimTTL        time.Duration // Claim lifetime for queues without their own
+	ModerationQueue string        // Queue receiving flagged moderation items; empty to disable
+	RiskQueue       string        // Queue receiving risky signups, orders and withdrawals; empty to disable
+}
+
+// DefaultConfig returns default review configuration
+func DefaultConfig() Config {
+	return Config{
+		ClaimTTL:        30 * time.Minute,
+		ModerationQueue: "moderation",
+		RiskQueue:       "fraud",
+	}
+}
+
+// QueueInput is the payload for creating or updating a queue
+type QueueInput struct {
+	Slug         string   `json:"slug" validate:"required,min=2,max=100"`
+	Name         string   `json:"name" validate:"required,max=200"`
+	Description  string   `json:"description" validate:"max=1000"`
+	Decisions    []string `json:"decisions" validate:"dive,required,max=50"`
+	SLASeconds   int64    `json:"sla_seconds" validate:"min=0"`
+	ClaimSeconds int64    `json:"claim_seconds" validate:"min=0"`
+	Active       *bool    `json:"active"`
+}
+
+// EnqueueInput is the payload for submitting an item for review
+type EnqueueInput struct {
+	Queue       string                 `json:"queue" validate:"required,max=100"`
+	Source      string                 `json:"source" validate:"max=100"`
+	ReferenceID string                 `json:"reference_id" validate:"max=100"`
+	Title       string                 `json:"title" validate:"required,max=300"`
+	Context     map[string]interface{} `json:"context"`
+	Suggestions []Suggestion           `json:"suggestions" validate:"dive"`
+	Priority    int                    `json:"priority"`
+}
+
+// DecideInput is the payload for a reviewer decision
+type DecideInput struct {
+	Decision string `json:"decision" validate:"required,max=50"`
+	Note     string `json:"note" validate:"max=2000"`
+}
+
+// Service routes items from moderation, fraud checks and AI pipelines to
+// human reviewers and tracks how quickly each queue is worked
+type Service struct {
+	repo      *Repository
+	collector *metrics.Collector
+	config    Config
+}
+
+func NewService(repo *Repository, collector *metrics.Collector, config Config) *Service {
+	return &Service{
+		repo:      repo,
+		collector: collector,
+		config:    config,
+	}
+}
+
+// ==================== Queues ====================
+
+func (s *Service) ListQueues(ctx context.Context) ([]Queue, error) {
+	queues, err := s.repo.ListQueues(ctx)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load review queues").WithError(err)
+	}
+	return queues, nil
+}
+
+func (s *Service) GetQueue(ctx context.Context, id uint) (*Queue, error) {
+	queue, err := s.repo.FindQueue(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load review queue").WithError(err)
+	}
+	if queue == nil {
+		return nil, errors.NewNotFound("Review queue not found")
+	}
+	return queue, nil
+}
+
+func (s *Service) CreateQueue(ctx context.Context, input *QueueInput) (*Queue, error) {
+	existing, err := s.repo.FindQueueBySlug(ctx, input.Slug)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to create review queue").WithError(err)
+	}
+	if existing != nil {
+		return nil, errors.NewConflict("A review queue with this slug already exists")
+	}
+
+	queue := &Queue{Active: true}
+	applyQueueInput(queue, input)
+	if err := s.repo.CreateQueue(ctx, queue); err != nil {
+		return nil, errors.NewInternal("Failed to create review queue").WithError(err)
+	}
+	return queue, nil
+}
+
+func (s *Service) UpdateQueue(ctx context.Context, id uint, input *QueueInput) (*Queue, error) {
+	queue, err := s.GetQueue(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if input.Slug != queue.Slug {
+		existing, err := s.repo.FindQueueBySlug(ctx, input.Slug)
+		if err != nil {
+			return nil, errors.NewInternal("Failed to update review queue").WithError(err)
+		}
+		if existing != nil {
+			return nil, errors.NewConflict("A review queue with this slug already exists")
+		}
+	}
+
+	applyQueueInput(queue, input)
+	if err := s.repo.UpdateQueue(ctx, queue); err != nil {
+		return nil, errors.NewInternal("Failed to update review queue").WithError(err)
+	}
+	return queue, nil
+}
+
+// ==================== Items ====================
+
+// HandleEvent queues content flagged by the moderation module and
+// assessments the risk module wants a person to check
+func (s *Service) HandleEvent(ctx context.Context, event events.Event) error {
+	data, ok := event.Data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	switch event.Name {
+	case eventModerationFlagged:
+		s.queueFlaggedContent(ctx, data)
+	case eventRiskReviewRequired:
+		s.queueRiskAssessment(ctx, data)
+	}
+	return nil
+}
+
+// queueFlaggedContent queues a moderation flag for review
+func (s *Service) queueFlaggedContent(ctx context.Context, data map[string]interface{}) {
+	queue := s.intakeQueue(ctx, s.config.ModerationQueue)
+	if queue == nil {
+		return
+	}
+
+	itemID := fmt.Sprint(data["item_id"])
+	score, _ := data["score"].(float64)
+	input := &EnqueueInput{
+		Queue:       queue.Slug,
+		Source:      "moderation",
+		ReferenceID: itemID,
+		Title:       fmt.Sprintf("Flagged %v content #%s", data["source"], itemID),
+		Context:     data,
+		Suggestions: []Suggestion{{
+			Decision:   "rejected",
+			Confidence: score,
+			Reason:     "Flagged for " + strings.Join(stringList(data["categories"]), ", "),
+			Source:     "moderation",
+		}},
+		Priority: int(score * 100),
+	}
+	if _, err := s.Enqueue(ctx, input); err != nil {
+		logger.Error("Failed to queue flagged content for review", logger.Fields{"item_id": itemID, "error": err.Error()})
+	}
+}
+
+// queueRiskAssessment queues a risk assessment for review, suggesting the
+// action its score would have led to
+func (s *Service) queueRiskAssessment(ctx context.Context, data map[string]interface{}) {
+	queue := s.intakeQueue(ctx, s.config.RiskQueue)
+	if queue == nil {
+		return
+	}
+
+	assessmentID := fmt.Sprint(data["assessment_id"])
+	score, _ := data["score"].(float64)
+	input := &EnqueueInput{
+		Queue:       queue.Slug,
+		Source:      "risk",
+		ReferenceID: assessmentID,
+		Title:       fmt.Sprintf("Risky %v #%s (score %.0f)", data["event"], assessmentID, score),
+		Context:     data,
+		Suggestions: []Suggestion{{
+			Decision:   "deny",
+			Confidence: score / 100,
+			Reason:     strings.Join(stringList(data["reasons"]), "; "),
+			Source:     "risk",
+		}},
+		Priority: int(score),
+	}
+	if _, err := s.Enqueue(ctx, input); err != nil {
+		logger.Error("Failed to queue risk assessment for review", logger.Fields{"assessment_id": assessmentID, "error": err.Error()})
+	}
+}
+
+// intakeQueue returns the active queue receiving events from another
+// module, or nil when intake is disabled
+func (s *Service) intakeQueue(ctx context.Context, slug string) *Queue {
+	if slug == "" {
+		return nil
+	}
+	queue, err := s.repo.FindQueueBySlug(ctx, slug)
+	if err != nil {
+		logger.Error("Failed to load review queue", logger.Fields{"queue": slug, "error": err.Error()})
+		return nil
+	}
+	if queue == nil || !queue.Active {
+		return nil
+	}
+	return queue
+}
+
+// Enqueue adds an item to a queue. An undecided item from the same source
+// and reference is updated instead of duplicated.
+func (s *Service) Enqueue(ctx context.Context, input *EnqueueInput) (*Item, error) {
+	queue, err := s.repo.FindQueueBySlug(ctx, input.Queue)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to queue item").WithError(err)
+	}
+	if queue == nil {
+		return nil, errors.NewNotFound("Review queue not found")
+	}
+	if !queue.Active {
+		return nil, errors.NewBadRequest("Review queue is not accepting items")
+	}
+
+	if input.ReferenceID != "" {
+		existing, err := s.repo.FindPendingByReference(ctx, queue.ID, input.Source, input.ReferenceID)
+		if err != nil {
+			return nil, errors.NewInternal("Failed to queue item").WithError(err)
+		}
+		if existing != nil {
+			existing.Title = input.Title
+			existing.Context = input.Context
+			existing.Suggestions = input.Suggestions
+			if input.Priority > existing.Priority {
+				existing.Priority = input.Priority
+			}
+			if err := s.repo.UpdateItem(ctx, existing); err != nil {
+				return nil, errors.NewInternal("Failed to queue item").WithError(err)
+			}
+			return existing, nil
+		}
+	}
+
+	item := &Item{
+		QueueID:     queue.ID,
+		Source:      input.Source,
+		ReferenceID: input.ReferenceID,
+		Title:       input.Title,
+		Context:     input.Context,
+		Suggestions: input.Suggestions,
+		Priority:    input.Priority,
+		Status:      StatusOpen,
+	}
+	if sla := queue.SLA(); sla > 0 {
+		due := time.Now().Add(sla)
+		item.DueAt = &due
+	}
+	if err := s.repo.CreateItem(ctx, item); err != nil {
+		return nil, errors.NewInternal("Failed to queue item").WithError(err)
+	}
+
+	s.dispatch(ctx, EventItemCreated, queue, item)
+	return item, nil
+}
+
+func (s *Service) ListItems(ctx context.Context, filter ItemFilter, page, limit int) ([]Item, int64, error) {
+	items, total, err := s.repo.ListItems(ctx, filter, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to load review items").WithError(err)
+	}
+	return items, total, nil
+}
+
+func (s *Service) GetItem(ctx context.Context, id uint) (*Item, error) {
+	item, err := s.repo.FindItem(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load review item").WithError(err)
+	}
+	if item == nil {
+		return nil, errors.NewNotFound("Review item not found")
+	}
+	return item, nil
+}
+
+// Claim assigns an item to a reviewer until the queue's claim time lapses
+func (s *Service) Claim(ctx context.Context, id, reviewerID uint) (*Item, error) {
+	item, err := s.GetItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !item.pending() {
+		return nil, errors.NewConflict("Item has already been decided")
+	}
+	queue, err := s.GetQueue(ctx, item.QueueID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	claimed, err := s.repo.Claim(ctx, item.ID, reviewerID, now, now.Add(s.claimTTL(queue)))
+	if err != nil {
+		return nil, errors.NewInternal("Failed to claim item").WithError(err)
+	}
+	if !claimed {
+		return nil, errors.NewConflict("Item is claimed by another reviewer")
+	}
+	return s.GetItem(ctx, id)
+}
+
+// ClaimNext claims the highest priority, longest waiting item of a queue.
+// It returns nil when there is nothing to review.
+func (s *Service) ClaimNext(ctx context.Context, queueID, reviewerID uint) (*Item, error) {
+	queue, err := s.GetQueue(ctx, queueID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Another reviewer may take the same item first; try the next one
+	for attempt := 0; attempt < 5; attempt++ {
+		now := time.Now()
+		item, err := s.repo.FindNextAvailable(ctx, queue.ID, now)
+		if err != nil {
+			return nil, errors.NewInternal("Failed to claim item").WithError(err)
+		}
+		if item == nil {
+			return nil, nil
+		}
+
+		claimed, err := s.repo.Claim(ctx, item.ID, reviewerID, now, now.Add(s.claimTTL(queue)))
+		if err != nil {
+			return nil, errors.NewInternal("Failed to claim item").WithError(err)
+		}
+		if claimed {
+			return s.GetItem(ctx, item.ID)
+		}
+	}
+	return nil, errors.NewConflict("Queue is busy, please try again")
+}
+
+// Release returns an item claimed by the reviewer to its queue
+func (s *Service) Release(ctx context.Context, id, reviewerID uint) (*Item, error) {
+	item, err := s.GetItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if item.Status != StatusClaimed || item.ClaimedBy == nil || *item.ClaimedBy != reviewerID {
+		return nil, errors.NewConflict("Item is not claimed by you")
+	}
+
+	if err := s.repo.Release(ctx, item.ID); err != nil {
+		return nil, errors.NewInternal("Failed to release item").WithError(err)
+	}
+	return s.GetItem(ctx, id)
+}
+
+// Decide records a reviewer's decision. Items claimed by someone else cannot
+// be decided until the claim lapses.
+func (s *Service) Decide(ctx context.Context, id uint, input *DecideInput, reviewerID uint) (*Item, error) {
+	item, err := s.GetItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !item.pending() {
+		return nil, errors.NewConflict("Item has already been decided")
+	}
+
+	now := time.Now()
+	if item.Status == StatusClaimed && item.ClaimedBy != nil && *item.ClaimedBy != reviewerID &&
+		item.ClaimExpiresAt != nil && item.ClaimExpiresAt.After(now) {
+		return nil, errors.NewConflict("Item is claimed by another reviewer")
+	}
+
+	queue, err := s.GetQueue(ctx, item.QueueID)
+	if err != nil {
+		return nil, err
+	}
+	if !queue.allows(input.Decision) {
+		return nil, errors.NewBadRequest("Decision must be one of: " + strings.Join(queue.Decisions, ", "))
+	}
+
+	item.Status = StatusDecided
+	item.Decision = input.Decision
+	item.Note = input.Note
+	item.DecidedBy = &reviewerID
+	item.DecidedAt = &now
+	if item.DueAt != nil && now.After(*item.DueAt) {
+		item.Breached = true
+	}
+
+	decided, err := s.repo.Decide(ctx, item)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to record decision").WithError(err)
+	}
+	if !decided {
+		return nil, errors.NewConflict("Item has already been decided")
+	}
+
+	if s.collector != nil {
+		s.collector.NewCounter("review_"+metricName(queue.Slug)+"_decided_total",
+			"Items decided in the "+queue.Name+" review queue", map[string]string{"queue": queue.Slug}).Inc()
+	}
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventItemDecided,
+		Data: map[string]interface{}{
+			"item_id":      item.ID,
+			"queue":        queue.Slug,
+			"source":       item.Source,
+			"reference_id": item.ReferenceID,
+			"decision":     item.Decision,
+			"note":         item.Note,
+			"reviewer_id":  reviewerID,
+			"context":      item.Context,
+		},
+	})
+	return item, nil
+}
+
+// Cancel withdraws an undecided item, e.g. when the content it refers to was
+// deleted
+func (s *Service) Cancel(ctx context.Context, id uint) (*Item, error) {
+	item, err := s.GetItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !item.pending() {
+		return nil, errors.NewConflict("Item has already been decided")
+	}
+
+	item.Status = StatusCanceled
+	item.ClaimedBy, item.ClaimedAt, item.ClaimExpiresAt = nil, nil, nil
+	if err := s.repo.UpdateItem(ctx, item); err != nil {
+		return nil, errors.NewInternal("Failed to cancel item").WithError(err)
+	}
+	return item, nil
+}
+
+// ==================== SLA ====================
+
+// Stats summarizes a queue's backlog and the items decided since the given
+// time
+func (s *Service) Stats(ctx context.Context, queueID uint, since time.Time) (*Stats, error) {
+	queue, err := s.GetQueue(ctx, queueID)
+	if err != nil {
+		return nil, err
+	}
+	return s.stats(ctx, queue, since, time.Now())
+}
+
+// CheckSLA flags undecided items that passed their queue's SLA and refreshes
+// the queue aging metrics
+func (s *Service) CheckSLA(ctx context.Context, now time.Time) error {
+	overdue, err := s.repo.FindOverdue(ctx, now)
+	if err != nil {
+		return err
+	}
+	if len(overdue) > 0 {
+		ids := make([]uint, len(overdue))
+		for i := range overdue {
+			ids[i] = overdue[i].ID
+		}
+		if err := s.repo.MarkBreached(ctx, ids); err != nil {
+			return err
+		}
+	}
+
+	queues, err := s.repo.ListQueues(ctx)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uint]*Queue, len(queues))
+	for i := range queues {
+		byID[queues[i].ID] = &queues[i]
+	}
+
+	for i := range overdue {
+		item := &overdue[i]
+		queue := byID[item.QueueID]
+		if queue == nil {
+			continue
+		}
+		item.Breached = true
+		if s.collector != nil {
+			s.collector.NewCounter("review_"+metricName(queue.Slug)+"_sla_breaches_total",
+				"Items that missed the "+queue.Name+" review SLA", map[string]string{"queue": queue.Slug}).Inc()
+		}
+		s.dispatch(ctx, EventItemSLABreached, queue, item)
+	}
+
+	if s.collector != nil {
+		for i := range queues {
+			s.recordAging(ctx, &queues[i], now)
+		}
+	}
+	return nil
+}
+
+// recordAging publishes a queue's backlog size and age as gauges
+func (s *Service) recordAging(ctx context.Context, queue *Queue, now time.Time) {
+	pending, err := s.repo.Backlog(ctx, queue.ID, now)
+	if err != nil {
+		logger.Warn("Failed to measure review queue", logger.Fields{"queue": queue.Slug, "error": err.Error()})
+		return
+	}
+
+	name := "review_" + metricName(queue.Slug)
+	labels := map[string]string{"queue": queue.Slug}
+	s.collector.NewGauge(name+"_open", "Undecided items in the "+queue.Name+" review queue", labels).Set(pending.Open + pending.Claimed)
+	s.collector.NewGauge(name+"_overdue", "Items past the "+queue.Name+" review SLA", labels).Set(pending.Overdue)
+
+	var age int64
+	if pending.Oldest != nil {
+		age = int64(now.Sub(*pending.Oldest).Seconds())
+	}
+	s.collector.NewGauge(name+"_oldest_age_seconds", "Age of the oldest item in the "+queue.Name+" review queue", labels).Set(age)
+}
+
+func (s *Service) stats(ctx context.Context, queue *Queue, since, now time.Time) (*Stats, error) {
+	pending, err := s.repo.Backlog(ctx, queue.ID, now)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to build review queue stats").WithError(err)
+	}
+	decided, err := s.repo.DecidedSince(ctx, queue.ID, since)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to build review queue stats").WithError(err)
+	}
+
+	stats := &Stats{
+		QueueID:       queue.ID,
+		Slug:          queue.Slug,
+		Since:         since,
+		Open:          pending.Open,
+		Claimed:       pending.Claimed,
+		Overdue:       pending.Overdue,
+		Decided:       len(decided),
+		SLACompliance: 100,
+		ByDecision:    make(map[string]int),
+	}
+	if pending.Oldest != nil {
+		stats.OldestAgeSeconds = now.Sub(*pending.Oldest).Seconds()
+	}
+
+	var total time.Duration
+	breaches := 0
+	for i := range decided {
+		item := &decided[i]
+		stats.ByDecision[item.Decision]++
+		if item.Breached {
+			breaches++
+		}
+		if item.DecidedAt != nil {
+			total += item.DecidedAt.Sub(item.CreatedAt)
+		}
+	}
+	if stats.Decided > 0 {
+		stats.MeanTimeToDecision = (total / time.Duration(stats.Decided)).Seconds()
+		stats.SLACompliance = float64((stats.Decided-breaches)*10000/stats.Decided) / 100
+	}
+	return stats, nil
+}
+
+// ==================== Helpers ====================
+
+func (s *Service) claimTTL(queue *Queue) time.Duration {
+	if queue.ClaimSeconds > 0 {
+		return time.Duration(queue.ClaimSeconds) * time.Second
+	}
+	return s.config.ClaimTTL
+}
+
+func (s *Service) dispatch(ctx context.Context, name string, queue *Queue, item *Item) {
+	events.DispatchAsync(ctx, events.Event{
+		Name: name,
+		Data: map[string]interface{}{
+			"item_id":      item.ID,
+			"queue":        queue.Slug,
+			"source":       item.Source,
+			"reference_id": item.ReferenceID,
+			"title":        item.Title,
+			"priority":     item.Priority,
+		},
+	})
+}
+
+func applyQueueInput(queue *Queue, input *QueueInput) {
+	queue.Slug = input.Slug
+	queue.Name = input.Name
+	queue.Description = input.Description
+	queue.Decisions = input.Decisions
+	queue.SLASeconds = input.SLASeconds
+	queue.ClaimSeconds = input.ClaimSeconds
+	if input.Active != nil {
+		queue.Active = *input.Active
+	}
+}
+
+// metricName makes a queue slug safe for use in metric names
+func metricName(slug string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r + ('a' - 'A')
+		}
+		return '_'
+	}, slug)
+}
+
+// stringList reads a list of strings from event data
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			result = append(result, fmt.Sprint(item))
+		}
+		return result
+	case string:
+		return []string{v}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package review
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/logger"
+)
+
+// SLAChecker periodically flags overdue items and refreshes queue aging
+// metrics
+type SLAChecker struct {
+	service  *Service
+	interval time.Duration
+
+	mu      sync.Mutex
+	started bool
+	stop    chan struct{}
+}
+
+// NewSLAChecker creates an SLA checker running every interval
+func NewSLAChecker(service *Service, interval time.Duration) *SLAChecker {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &SLAChecker{service: service, interval: interval}
+}
+
+// Start begins checking in the background. It is safe to call more than once.
+func (c *SLAChecker) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.started {
+		return
+	}
+	c.started = true
+	c.stop = make(chan struct{})
+	go c.run(c.stop)
+}
+
+// Stop ends checking
+func (c *SLAChecker) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.started {
+		close(c.stop)
+		c.started = false
+	}
+}
+
+func (c *SLAChecker) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.service.CheckSLA(context.Background(), time.Now()); err != nil {
+				logger.Warn("Failed to check review SLAs", logger.Fields{"error": err.Error()})
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package risk
+
+import (
+	"strconv"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// Evaluate scores an event
+// @Summary Evaluate risk
+// @Description Scores a signup, order, withdrawal or other event against the configured signals and returns the action (allow, review, deny) with a factor breakdown
+// @Tags Risk
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param evaluation body Evaluation true "Event"
+// @Success 200 {object} api.Response{data=Assessment}
+// @Failure 400 {object} api.Response
+// @Router /risk/evaluate [post]
+func (c *Controller) Evaluate(ctx *fiber.Ctx) error {
+	var input Evaluation
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	assessment, err := c.service.Evaluate(ctx.UserContext(), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, assessment)
+}
+
+// List returns risk assessments, newest first
+// @Summary List risk assessments
+// @Tags Risk
+// @Security BearerAuth
+// @Produce json
+// @Param event query string false "Event type"
+// @Param action query string false "Action (allow, review, deny)"
+// @Param user_id query int false "User ID"
+// @Param reference_id query string false "Reference ID"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} api.PaginatedResponse{data=[]Assessment}
+// @Router /risk/assessments [get]
+func (c *Controller) List(ctx *fiber.Ctx) error {
+	filter := AssessmentFilter{
+		Event:       ctx.Query("event"),
+		Action:      ctx.Query("action"),
+		ReferenceID: ctx.Query("reference_id"),
+	}
+	if userID, err := strconv.ParseUint(ctx.Query("user_id"), 10, 64); err == nil {
+		filter.UserID = uint(userID)
+	}
+
+	pagination := api.GetPagination(ctx)
+	assessments, total, err := c.service.List(ctx.UserContext(), filter, pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, assessments, pagination.Page, pagination.Limit, total)
+}
+
+// Get returns a risk assessment
+// @Summary Get risk assessment
+// @Tags Risk
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Assessment ID"
+// @Success 200 {object} api.Response{data=Assessment}
+// @Failure 404 {object} api.Response
+// @Router /risk/assessments/{id} [get]
+func (c *Controller) Get(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid assessment ID", nil)
+	}
+
+	assessment, err := c.service.Get(ctx.UserContext(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, assessment)
+}
+
+// Signals returns the registered signals and their weights
+// @Summary List risk signals
+// @Tags Risk
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]SignalInfo}
+// @Router /risk/signals [get]
+func (c *Controller) Signals(ctx *fiber.Ctx) error {
+	return api.Success(ctx, c.service.Signals())
+}
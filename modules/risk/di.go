@@ -0,0 +1,80 @@
+package risk
+
+import (
+	"os"
+	"strconv"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/ai"
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/contracts"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/metrics"
+
+	"gorm.io/gorm"
+)
+
+// defaultModelWeight is the model signal weight when none is configured
+const defaultModelWeight = 40
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Service with signals from the environment
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		if threshold, err := strconv.ParseFloat(os.Getenv("RISK_REVIEW_THRESHOLD"), 64); err == nil && threshold > 0 {
+			config.ReviewThreshold = threshold
+		}
+		if threshold, err := strconv.ParseFloat(os.Getenv("RISK_DENY_THRESHOLD"), 64); err == nil && threshold > 0 {
+			config.DenyThreshold = threshold
+		}
+
+		service := NewService(core.Resolve[*Repository](container), core.Resolve[*metrics.Collector](container), config)
+
+		// Velocity counters are shared between instances when a cache is registered
+		store := core.Resolve[cache.Cache](container)
+		if store == nil {
+			store = cache.NewMemoryCache(cache.DefaultMemoryCacheConfig())
+		}
+		rules, err := ParseVelocityRules(os.Getenv("RISK_VELOCITY_RULES"))
+		if err != nil {
+			logger.Warn("Risk velocity rules ignored", logger.Fields{"error": err.Error()})
+		}
+		for _, rule := range rules {
+			service.AddSignal(NewVelocitySignal(store, rule.Event, rule.By, rule.Window, rule.Limit), rule.Weight, rule.Event)
+		}
+
+		if weight, err := strconv.ParseFloat(os.Getenv("RISK_GEO_MISMATCH_WEIGHT"), 64); err == nil && weight > 0 {
+			// GeoIP lookups come from the security module, when enabled
+			var locate CountryLocator
+			if core.HasService[contracts.GeoLocator](container) {
+				locate = core.Service[contracts.GeoLocator](container).Country
+			}
+			service.AddSignal(NewGeoMismatchSignal(locate), weight)
+		}
+
+		if modelID := os.Getenv("RISK_MODEL"); modelID != "" {
+			manager := core.Resolve[*ai.ModelManager](container)
+			if manager == nil {
+				logger.Warn("Risk model signal disabled: no AI model manager", logger.Fields{})
+				return service
+			}
+			weight, err := strconv.ParseFloat(os.Getenv("RISK_MODEL_WEIGHT"), 64)
+			if err != nil || weight <= 0 {
+				weight = defaultModelWeight
+			}
+			service.AddSignal(NewModelSignal(manager, modelID), weight)
+		}
+
+		return service
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
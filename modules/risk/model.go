@@ -0,0 +1,82 @@
+package risk
+
+import "time"
+
+// Actions, from least to most severe
+const (
+	ActionAllow  = "allow"  // Proceed
+	ActionReview = "review" // Hold for a human decision
+	ActionDeny   = "deny"   // Reject
+)
+
+// Common event types. Any event name may be evaluated.
+const (
+	EventSignup     = "signup"
+	EventLogin      = "login"
+	EventOrder      = "order"
+	EventWithdrawal = "withdrawal"
+)
+
+// Evaluation is an event to score
+type Evaluation struct {
+	Event       string                 `json:"event" validate:"required,max=50"`
+	UserID      uint                   `json:"user_id"`
+	ReferenceID string                 `json:"reference_id" validate:"max=100"` // e.g. order ID
+	IP          string                 `json:"ip" validate:"omitempty,ip"`
+	IPCountry   string                 `json:"ip_country" validate:"omitempty,len=2"` // Skips the GeoIP lookup when set
+	Country     string                 `json:"country" validate:"omitempty,len=2"`    // Claimed, e.g. billing country
+	Email       string                 `json:"email" validate:"omitempty,email"`
+	DeviceID    string                 `json:"device_id" validate:"max=200"`
+	Amount      float64                `json:"amount" validate:"min=0"`
+	Currency    string                 `json:"currency" validate:"omitempty,len=3"`
+	Attributes  map[string]interface{} `json:"attributes"` // Extra features for model signals
+}
+
+// Factor is one signal's part in a score
+type Factor struct {
+	Signal       string                 `json:"signal"`
+	Score        float64                `json:"score"`        // Signal strength, 0-1
+	Weight       float64                `json:"weight"`       // Points at full strength
+	Contribution float64                `json:"contribution"` // Points added to the risk score
+	Reason       string                 `json:"reason"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+// Assessment is the scored outcome of an evaluation
+type Assessment struct {
+	ID             uint                   `gorm:"primarykey" json:"id"`
+	Event          string                 `gorm:"size:50;index;not null" json:"event"`
+	UserID         uint                   `gorm:"index" json:"user_id,omitempty"`
+	ReferenceID    string                 `gorm:"size:100;index" json:"reference_id,omitempty"`
+	IP             string                 `gorm:"size:45" json:"ip,omitempty"`
+	Score          float64                `json:"score"` // 0-100
+	Action         string                 `gorm:"size:20;index;not null" json:"action"`
+	Factors        []Factor               `gorm:"serializer:json" json:"factors"`
+	Input          map[string]interface{} `gorm:"serializer:json" json:"input,omitempty"`
+	ReviewDecision string                 `gorm:"size:50" json:"review_decision,omitempty"`
+	ReviewNote     string                 `gorm:"size:2000" json:"review_note,omitempty"`
+	ReviewedBy     *uint                  `json:"reviewed_by,omitempty"`
+	ReviewedAt     *time.Time             `json:"reviewed_at,omitempty"`
+	CreatedAt      time.Time              `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name for Assessment
+func (Assessment) TableName() string {
+	return "risk_assessments"
+}
+
+// AssessmentFilter narrows an assessment listing
+type AssessmentFilter struct {
+	Event       string
+	Action      string
+	UserID      uint
+	ReferenceID string
+}
+
+// SignalInfo describes a registered signal
+type SignalInfo struct {
+	Name   string   `json:"name"`
+	Weight float64  `json:"weight"`
+	Events []string `json:"events,omitempty"` // All events when empty
+}
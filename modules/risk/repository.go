@@ -0,0 +1,62 @@
+package risk
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) List(ctx context.Context, filter AssessmentFilter, page, limit int) ([]Assessment, int64, error) {
+	var assessments []Assessment
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Assessment{})
+	if filter.Event != "" {
+		query = query.Where("event = ?", filter.Event)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.ReferenceID != "" {
+		query = query.Where("reference_id = ?", filter.ReferenceID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&assessments).Error
+	return assessments, total, err
+}
+
+func (r *Repository) FindByID(ctx context.Context, id uint) (*Assessment, error) {
+	var assessment Assessment
+	if err := r.db.WithContext(ctx).First(&assessment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &assessment, nil
+}
+
+func (r *Repository) Create(ctx context.Context, assessment *Assessment) error {
+	return r.db.WithContext(ctx).Create(assessment).Error
+}
+
+func (r *Repository) Update(ctx context.Context, assessment *Assessment) error {
+	return r.db.WithContext(ctx).Save(assessment).Error
+}
@@ -0,0 +1,28 @@
+package risk
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RiskModule struct{}
+
+func New() *RiskModule {
+	return &RiskModule{}
+}
+
+func (m *RiskModule) Name() string {
+	return "risk"
+}
+
+func (m *RiskModule) Init() {}
+
+func (m *RiskModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *RiskModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
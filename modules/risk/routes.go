@@ -0,0 +1,26 @@
+package risk
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	// Record decisions from the review module's fraud queue
+	events.Register(eventReviewDecided, core.Resolve[*Service](container).HandleReviewDecided)
+
+	risk := router.Group("/risk", auth.AuthMiddleware(jwtManager, auth.AcceptAPIKeys()))
+	risk.Post("/evaluate", rbac.RequirePermission(rbacManager, "risk.evaluate"), controller.Evaluate)
+	risk.Get("/assessments", rbac.RequirePermission(rbacManager, "risk.read"), controller.List)
+	risk.Get("/assessments/:id", rbac.RequirePermission(rbacManager, "risk.read"), controller.Get)
+	risk.Get("/signals", rbac.RequirePermission(rbacManager, "risk.read"), controller.Signals)
+}
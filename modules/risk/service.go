@@ -0,0 +1,302 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/metrics"
+)
+
+// Risk event names
+const (
+	EventReviewRequired = "risk.review_required"
+	EventDenied         = "risk.denied"
+	EventReviewed       = "risk.reviewed"
+)
+
+// eventReviewDecided is dispatched by the review module when a reviewer
+// decides an item from a review queue
+const eventReviewDecided = "review.decided"
+
+// Config holds risk engine configuration
+type Config struct {
+	ReviewThreshold float64 // Score holding events for review
+	DenyThreshold   float64 // Score denying events
+	ExplainFactors  int     // Factors named in event payloads
+}
+
+// DefaultConfig returns default risk configuration
+func DefaultConfig() Config {
+	return Config{
+		ReviewThreshold: 50,
+		DenyThreshold:   80,
+		ExplainFactors:  3,
+	}
+}
+
+// weightedSignal is a registered signal
+type weightedSignal struct {
+	signal Signal
+	weight float64
+	events map[string]bool
+}
+
+// Service scores events against weighted signals. Each signal adds up to
+// its weight in points; the total, capped at 100, decides the action.
+type Service struct {
+	repo      *Repository
+	collector *metrics.Collector
+	config    Config
+
+	mu      sync.RWMutex
+	signals []weightedSignal
+}
+
+func NewService(repo *Repository, collector *metrics.Collector, config Config) *Service {
+	return &Service{
+		repo:      repo,
+		collector: collector,
+		config:    config,
+	}
+}
+
+// AddSignal registers a signal worth up to weight points for the given
+// events, or for every event when none are given
+func (s *Service) AddSignal(signal Signal, weight float64, eventNames ...string) {
+	registered := weightedSignal{signal: signal, weight: weight}
+	if len(eventNames) > 0 {
+		registered.events = make(map[string]bool, len(eventNames))
+		for _, name := range eventNames {
+			registered.events[name] = true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signals = append(s.signals, registered)
+}
+
+// Signals describes the registered signals
+func (s *Service) Signals() []SignalInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]SignalInfo, 0, len(s.signals))
+	for _, registered := range s.signals {
+		info := SignalInfo{Name: registered.signal.Name(), Weight: registered.weight}
+		for name := range registered.events {
+			info.Events = append(info.Events, name)
+		}
+		sort.Strings(info.Events)
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// ==================== Evaluation ====================
+
+// Evaluate scores an event, stores the assessment and announces events
+// needing review or denied. Failing signals add nothing and are reported
+// in the factor breakdown.
+func (s *Service) Evaluate(ctx context.Context, evaluation *Evaluation) (*Assessment, error) {
+	s.mu.RLock()
+	signals := append([]weightedSignal(nil), s.signals...)
+	s.mu.RUnlock()
+
+	assessment := &Assessment{
+		Event:       evaluation.Event,
+		UserID:      evaluation.UserID,
+		ReferenceID: evaluation.ReferenceID,
+		IP:          evaluation.IP,
+		Factors:     []Factor{},
+		Input:       evaluationInput(evaluation),
+	}
+
+	for _, registered := range signals {
+		if registered.events != nil && !registered.events[evaluation.Event] {
+			continue
+		}
+
+		name := registered.signal.Name()
+		factor, err := registered.signal.Evaluate(ctx, evaluation)
+		if err != nil {
+			logger.Warn("Risk signal failed", logger.Fields{"signal": name, "error": err.Error()})
+			factor = &Factor{Reason: "Signal unavailable", Error: err.Error()}
+		}
+		if factor == nil {
+			continue
+		}
+
+		factor.Signal = name
+		factor.Score = clamp(factor.Score)
+		factor.Weight = registered.weight
+		factor.Contribution = round(factor.Score * registered.weight)
+		assessment.Score += factor.Contribution
+		assessment.Factors = append(assessment.Factors, *factor)
+	}
+
+	// Largest contributions first
+	sort.SliceStable(assessment.Factors, func(i, j int) bool {
+		return assessment.Factors[i].Contribution > assessment.Factors[j].Contribution
+	})
+	assessment.Score = round(math.Min(100, assessment.Score))
+	assessment.Action = s.action(assessment.Score)
+
+	if err := s.repo.Create(ctx, assessment); err != nil {
+		return nil, errors.NewInternal("Failed to store risk assessment").WithError(err)
+	}
+
+	if s.collector != nil {
+		s.collector.NewCounter("risk_"+assessment.Action+"_total", "Events assessed as "+assessment.Action, nil).Inc()
+	}
+
+	switch assessment.Action {
+	case ActionReview:
+		s.dispatch(ctx, EventReviewRequired, assessment)
+	case ActionDeny:
+		s.dispatch(ctx, EventDenied, assessment)
+	}
+	return assessment, nil
+}
+
+func (s *Service) action(score float64) string {
+	switch {
+	case score >= s.config.DenyThreshold:
+		return ActionDeny
+	case score >= s.config.ReviewThreshold:
+		return ActionReview
+	}
+	return ActionAllow
+}
+
+// ==================== Assessments ====================
+
+func (s *Service) List(ctx context.Context, filter AssessmentFilter, page, limit int) ([]Assessment, int64, error) {
+	assessments, total, err := s.repo.List(ctx, filter, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to load risk assessments").WithError(err)
+	}
+	return assessments, total, nil
+}
+
+func (s *Service) Get(ctx context.Context, id uint) (*Assessment, error) {
+	assessment, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load risk assessment").WithError(err)
+	}
+	if assessment == nil {
+		return nil, errors.NewNotFound("Risk assessment not found")
+	}
+	return assessment, nil
+}
+
+// HandleReviewDecided records decisions made in a review queue on the
+// assessments the queue received
+func (s *Service) HandleReviewDecided(ctx context.Context, event events.Event) error {
+	data, ok := event.Data.(map[string]interface{})
+	if !ok || data["source"] != "risk" {
+		return nil
+	}
+	referenceID, _ := data["reference_id"].(string)
+	id, err := strconv.ParseUint(referenceID, 10, 64)
+	if err != nil || id == 0 {
+		return nil
+	}
+
+	assessment, err := s.repo.FindByID(ctx, uint(id))
+	if err != nil || assessment == nil || assessment.ReviewedAt != nil {
+		return err
+	}
+
+	now := time.Now()
+	assessment.ReviewDecision, _ = data["decision"].(string)
+	assessment.ReviewNote, _ = data["note"].(string)
+	if reviewerID, ok := data["reviewer_id"].(uint); ok {
+		assessment.ReviewedBy = &reviewerID
+	}
+	assessment.ReviewedAt = &now
+	if err := s.repo.Update(ctx, assessment); err != nil {
+		logger.Error("Failed to record risk review", logger.Fields{"assessment_id": assessment.ID, "error": err.Error()})
+		return nil
+	}
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: EventReviewed,
+		Data: map[string]interface{}{
+			"assessment_id": assessment.ID,
+			"event":         assessment.Event,
+			"user_id":       assessment.UserID,
+			"reference_id":  assessment.ReferenceID,
+			"decision":      assessment.ReviewDecision,
+			"note":          assessment.ReviewNote,
+		},
+	})
+	return nil
+}
+
+// ==================== Helpers ====================
+
+func (s *Service) dispatch(ctx context.Context, name string, assessment *Assessment) {
+	events.DispatchAsync(ctx, events.Event{
+		Name: name,
+		Data: map[string]interface{}{
+			"assessment_id": assessment.ID,
+			"event":         assessment.Event,
+			"user_id":       assessment.UserID,
+			"reference_id":  assessment.ReferenceID,
+			"score":         assessment.Score,
+			"action":        assessment.Action,
+			"reasons":       s.explain(assessment),
+		},
+	})
+}
+
+// explain names the largest contributing factors
+func (s *Service) explain(assessment *Assessment) []string {
+	var reasons []string
+	for _, factor := range assessment.Factors {
+		if len(reasons) == s.config.ExplainFactors || factor.Contribution <= 0 {
+			break
+		}
+		reasons = append(reasons, fmt.Sprintf("%s (+%g): %s", factor.Signal, factor.Contribution, factor.Reason))
+	}
+	return reasons
+}
+
+// evaluationInput keeps the evaluated fields for the record
+func evaluationInput(evaluation *Evaluation) map[string]interface{} {
+	input := map[string]interface{}{}
+	if evaluation.Country != "" {
+		input["country"] = strings.ToUpper(evaluation.Country)
+	}
+	if evaluation.IPCountry != "" {
+		input["ip_country"] = strings.ToUpper(evaluation.IPCountry)
+	}
+	if evaluation.Email != "" {
+		input["email"] = evaluation.Email
+	}
+	if evaluation.DeviceID != "" {
+		input["device_id"] = evaluation.DeviceID
+	}
+	if evaluation.Amount != 0 {
+		input["amount"] = evaluation.Amount
+		input["currency"] = evaluation.Currency
+	}
+	for key, value := range evaluation.Attributes {
+		input[key] = value
+	}
+	return input
+}
+
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
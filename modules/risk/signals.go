@@ -0,0 +1,322 @@
+package risk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/ai"
+	"neonexcore/pkg/cache"
+)
+
+// Signal scores one aspect of an event from 0 (no risk) to 1 (certain).
+// It returns nil when it does not apply, e.g. a per-IP counter for an
+// event without an IP.
+type Signal interface {
+	Name() string
+	Evaluate(ctx context.Context, evaluation *Evaluation) (*Factor, error)
+}
+
+// ==================== Velocity ====================
+
+// Velocity counter subjects
+const (
+	ByUser   = "user"
+	ByIP     = "ip"
+	ByEmail  = "email"
+	ByDevice = "device"
+)
+
+// VelocitySignal counts events per user, IP, email or device in a window.
+// The first event scores 0, rising to 1 at the limit.
+type VelocitySignal struct {
+	store  cache.Cache
+	event  string
+	by     string
+	window time.Duration
+	limit  int64
+}
+
+// NewVelocitySignal counts an event by subject over a window
+func NewVelocitySignal(store cache.Cache, event, by string, window time.Duration, limit int64) *VelocitySignal {
+	if limit < 1 {
+		limit = 1
+	}
+	return &VelocitySignal{store: store, event: event, by: by, window: window, limit: limit}
+}
+
+// Name implements Signal
+func (s *VelocitySignal) Name() string {
+	return fmt.Sprintf("velocity:%s:%s", s.event, s.by)
+}
+
+// Evaluate implements Signal
+func (s *VelocitySignal) Evaluate(ctx context.Context, evaluation *Evaluation) (*Factor, error) {
+	subject := s.subject(evaluation)
+	if subject == "" {
+		return nil, nil
+	}
+
+	key := fmt.Sprintf("risk:velocity:%s:%s:%s", s.event, s.by, subject)
+	count, err := s.store.Increment(ctx, key, 1)
+	if err != nil {
+		return nil, err
+	}
+	if count == 1 {
+		s.store.Expire(ctx, key, s.window)
+	}
+
+	score := 1.0
+	if s.limit > 1 {
+		score = math.Min(1, float64(count-1)/float64(s.limit-1))
+	} else if count <= 1 {
+		score = 0
+	}
+	return &Factor{
+		Score:   score,
+		Reason:  fmt.Sprintf("%d %s events by this %s in %s (limit %d)", count, s.event, s.by, s.window, s.limit),
+		Details: map[string]interface{}{"count": count, "limit": s.limit, "window": s.window.String()},
+	}, nil
+}
+
+func (s *VelocitySignal) subject(evaluation *Evaluation) string {
+	switch s.by {
+	case ByUser:
+		if evaluation.UserID != 0 {
+			return fmt.Sprint(evaluation.UserID)
+		}
+	case ByIP:
+		return evaluation.IP
+	case ByEmail:
+		return strings.ToLower(evaluation.Email)
+	case ByDevice:
+		return evaluation.DeviceID
+	}
+	return ""
+}
+
+// ==================== Geo ====================
+
+// CountryLocator resolves an IP address to an ISO country code
+type CountryLocator func(ctx context.Context, ip string) (string, error)
+
+// GeoMismatchSignal scores 1 when the IP address is in a different country
+// than the one claimed, e.g. the billing country
+type GeoMismatchSignal struct {
+	locate CountryLocator
+}
+
+// NewGeoMismatchSignal creates a geo mismatch signal. Without a locator
+// only evaluations carrying their IP country are checked.
+func NewGeoMismatchSignal(locate CountryLocator) *GeoMismatchSignal {
+	return &GeoMismatchSignal{locate: locate}
+}
+
+// Name implements Signal
+func (s *GeoMismatchSignal) Name() string {
+	return "geo_mismatch"
+}
+
+// Evaluate implements Signal
+func (s *GeoMismatchSignal) Evaluate(ctx context.Context, evaluation *Evaluation) (*Factor, error) {
+	if evaluation.Country == "" {
+		return nil, nil
+	}
+
+	ipCountry := evaluation.IPCountry
+	if ipCountry == "" && evaluation.IP != "" && s.locate != nil {
+		country, err := s.locate(ctx, evaluation.IP)
+		if err != nil {
+			return nil, err
+		}
+		ipCountry = country
+	}
+	if ipCountry == "" {
+		return nil, nil
+	}
+
+	claimed, actual := strings.ToUpper(evaluation.Country), strings.ToUpper(ipCountry)
+	factor := &Factor{
+		Reason:  fmt.Sprintf("IP address is in %s, as claimed", actual),
+		Details: map[string]interface{}{"ip_country": actual, "claimed_country": claimed},
+	}
+	if claimed != actual {
+		factor.Score = 1
+		factor.Reason = fmt.Sprintf("IP address is in %s but %s was claimed", actual, claimed)
+	}
+	return factor, nil
+}
+
+// ==================== Models ====================
+
+const modelSignalPrompt = `You are a fraud risk model. Given a JSON description of a user event, rate how likely it is to be fraudulent.
+Respond with JSON only: {"score": <0-1>, "reason": "<short explanation>"}`
+
+// ModelSignal scores events with a model loaded in an ai.ModelManager.
+// Chat models get the event as JSON with a scoring prompt; other models get
+// the event's features and may return a probability, {"score": x},
+// {"probability": x} or {"predictions": [x]}.
+type ModelSignal struct {
+	manager *ai.ModelManager
+	modelID string
+}
+
+// NewModelSignal creates a signal scored by a model
+func NewModelSignal(manager *ai.ModelManager, modelID string) *ModelSignal {
+	return &ModelSignal{manager: manager, modelID: modelID}
+}
+
+// Name implements Signal
+func (s *ModelSignal) Name() string {
+	return "model:" + s.modelID
+}
+
+// Evaluate implements Signal
+func (s *ModelSignal) Evaluate(ctx context.Context, evaluation *Evaluation) (*Factor, error) {
+	features := modelFeatures(evaluation)
+	data, err := json.Marshal(features)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := s.manager.Predict(ctx, &ai.InferenceInput{
+		ModelID: s.modelID,
+		Data:    string(data),
+		Parameters: map[string]interface{}{
+			"type":        "chat",
+			"system":      modelSignalPrompt,
+			"temperature": 0,
+			"features":    features,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	score, reason, ok := parseModelScore(output.Result)
+	if !ok {
+		return nil, fmt.Errorf("unrecognized model result")
+	}
+	if reason == "" {
+		reason = fmt.Sprintf("%s scored %.2f", s.modelID, score)
+	}
+	return &Factor{Score: score, Reason: reason, Details: map[string]interface{}{"model": output.ModelID}}, nil
+}
+
+// modelFeatures flattens an evaluation into model inputs. The IP address
+// and email are left out; their derived signals are not.
+func modelFeatures(evaluation *Evaluation) map[string]interface{} {
+	features := map[string]interface{}{
+		"event":      evaluation.Event,
+		"amount":     evaluation.Amount,
+		"currency":   evaluation.Currency,
+		"country":    evaluation.Country,
+		"ip_country": evaluation.IPCountry,
+		"has_device": evaluation.DeviceID != "",
+	}
+	if at := strings.LastIndex(evaluation.Email, "@"); at >= 0 {
+		features["email_domain"] = strings.ToLower(evaluation.Email[at+1:])
+	}
+	for key, value := range evaluation.Attributes {
+		features[key] = value
+	}
+	return features
+}
+
+// parseModelScore reads a 0-1 score and optional reason from a model result
+func parseModelScore(raw interface{}) (float64, string, bool) {
+	switch v := raw.(type) {
+	case float64:
+		return clamp(v), "", true
+	case string:
+		// Chat models may wrap JSON in a code fence
+		v = strings.TrimSpace(v)
+		v = strings.TrimPrefix(strings.TrimPrefix(v, "```json"), "```")
+		v = strings.TrimSpace(strings.TrimSuffix(v, "```"))
+
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(v), &decoded); err != nil {
+			return 0, "", false
+		}
+		return parseModelScore(decoded)
+	case []interface{}:
+		// [p] or [[p_legit, p_fraud]]
+		if len(v) == 0 {
+			return 0, "", false
+		}
+		if inner, ok := v[0].([]interface{}); ok {
+			return parseModelScore(inner)
+		}
+		return parseModelScore(v[len(v)-1])
+	case map[string]interface{}:
+		if choices, ok := v["choices"].([]interface{}); ok && len(choices) > 0 {
+			choice, _ := choices[0].(map[string]interface{})
+			message, _ := choice["message"].(map[string]interface{})
+			if content, ok := message["content"].(string); ok {
+				return parseModelScore(content)
+			}
+		}
+		reason, _ := v["reason"].(string)
+		for _, key := range []string{"score", "probability", "fraud_probability", "risk"} {
+			if score, ok := v[key].(float64); ok {
+				return clamp(score), reason, true
+			}
+		}
+		if predictions, ok := v["predictions"]; ok {
+			return parseModelScore(predictions)
+		}
+	}
+	return 0, "", false
+}
+
+func clamp(score float64) float64 {
+	return math.Max(0, math.Min(1, score))
+}
+
+// VelocityRule configures a velocity signal and its weight
+type VelocityRule struct {
+	Event  string
+	By     string
+	Window time.Duration
+	Limit  int64
+	Weight float64
+}
+
+// ParseVelocityRules reads comma-separated event:by:window:limit:weight
+// rules, e.g. "order:user:1h:5:30,signup:ip:1h:3:40"
+func ParseVelocityRules(spec string) ([]VelocityRule, error) {
+	var rules []VelocityRule
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 5 {
+			return nil, fmt.Errorf("velocity rule %q is not event:by:window:limit:weight", entry)
+		}
+
+		rule := VelocityRule{Event: parts[0], By: parts[1]}
+		switch rule.By {
+		case ByUser, ByIP, ByEmail, ByDevice:
+		default:
+			return nil, fmt.Errorf("velocity rule %q counts by unknown subject %q", entry, rule.By)
+		}
+		var err error
+		if rule.Window, err = time.ParseDuration(parts[2]); err != nil || rule.Window <= 0 {
+			return nil, fmt.Errorf("velocity rule %q has an invalid window", entry)
+		}
+		if rule.Limit, err = strconv.ParseInt(parts[3], 10, 64); err != nil || rule.Limit < 1 {
+			return nil, fmt.Errorf("velocity rule %q has an invalid limit", entry)
+		}
+		if rule.Weight, err = strconv.ParseFloat(parts[4], 64); err != nil || rule.Weight <= 0 {
+			return nil, fmt.Errorf("velocity rule %q has an invalid weight", entry)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
@@ -0,0 +1,204 @@
+package security
+
+import (
+	"time"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// VerifyStepUpInput is the payload for completing a step-up
+type VerifyStepUpInput struct {
+	Code     string `json:"code" validate:"omitempty,len=6,numeric"` // When the method is email_code
+	Password string `json:"password"`                                // When the method is password
+}
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// ListEvents returns the caller's security log
+// @Summary List security events
+// @Description Sign-ins, new devices, impossible travel, credential and permission changes, newest first
+// @Tags Security
+// @Security BearerAuth
+// @Produce json
+// @Param type query string false "Event type"
+// @Param severity query string false "Severity (info, warning, critical)"
+// @Param since query string false "RFC 3339 timestamp"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} api.PaginatedResponse{data=[]Event}
+// @Router /security/events [get]
+func (c *Controller) ListEvents(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	return c.listEvents(ctx, userID)
+}
+
+// ListUserEvents returns a user's security log, for administrators
+// @Summary List a user's security events
+// @Tags Security
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Param type query string false "Event type"
+// @Param severity query string false "Severity (info, warning, critical)"
+// @Param since query string false "RFC 3339 timestamp"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} api.PaginatedResponse{data=[]Event}
+// @Router /security/users/{id}/events [get]
+func (c *Controller) ListUserEvents(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid user ID", nil)
+	}
+	return c.listEvents(ctx, uint(id))
+}
+
+func (c *Controller) listEvents(ctx *fiber.Ctx, userID uint) error {
+	filter := EventFilter{
+		Type:     ctx.Query("type"),
+		Severity: ctx.Query("severity"),
+	}
+	if since := ctx.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return api.BadRequest(ctx, "since must be an RFC 3339 timestamp", nil)
+		}
+		filter.Since = &t
+	}
+
+	pagination := api.GetPagination(ctx)
+	events, total, err := c.service.ListEvents(ctx.UserContext(), userID, filter, pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, events, pagination.Page, pagination.Limit, total)
+}
+
+// ListDevices returns the devices the caller has signed in from
+// @Summary List devices
+// @Tags Security
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]Device}
+// @Router /security/devices [get]
+func (c *Controller) ListDevices(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	devices, err := c.service.ListDevices(ctx.UserContext(), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, devices)
+}
+
+// ForgetDevice removes one of the caller's devices
+// @Summary Forget device
+// @Description The next sign-in from the device is reported as a new device
+// @Tags Security
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Device ID"
+// @Success 200 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /security/devices/{id} [delete]
+func (c *Controller) ForgetDevice(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid device ID", nil)
+	}
+
+	if err := c.service.ForgetDevice(ctx.UserContext(), userID, uint(id)); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Device forgotten", nil)
+}
+
+// GetStepUp tells the caller whether they must verify their identity
+// @Summary Get step-up status
+// @Tags Security
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=StepUpStatus}
+// @Router /security/step-up [get]
+func (c *Controller) GetStepUp(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	status, err := c.service.GetStepUp(ctx.UserContext(), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, status)
+}
+
+// SendStepUpCode emails the caller a verification code
+// @Summary Send step-up code
+// @Tags Security
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response
+// @Failure 400 {object} api.Response
+// @Router /security/step-up/challenge [post]
+func (c *Controller) SendStepUpCode(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	if err := c.service.SendStepUpCode(ctx.UserContext(), userID); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Verification code sent", nil)
+}
+
+// VerifyStepUp completes the caller's step-up
+// @Summary Verify step-up
+// @Tags Security
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param verification body VerifyStepUpInput true "Code or password"
+// @Success 200 {object} api.Response
+// @Failure 400 {object} api.Response
+// @Failure 401 {object} api.Response
+// @Failure 429 {object} api.Response
+// @Router /security/step-up/verify [post]
+func (c *Controller) VerifyStepUp(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input VerifyStepUpInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	if err := c.service.VerifyStepUp(auth.RequestContext(ctx), userID, input.Code, input.Password); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Identity verified", nil)
+}
+
+// ClearStepUp lifts a user's pending step-up, for administrators
+// @Summary Clear a user's step-up
+// @Tags Security
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /security/users/{id}/step-up [delete]
+func (c *Controller) ClearStepUp(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid user ID", nil)
+	}
+
+	if err := c.service.ClearStepUp(ctx.UserContext(), uint(id)); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Step-up cleared", nil)
+}
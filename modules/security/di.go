@@ -0,0 +1,80 @@
+package security
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/contracts"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/notification"
+
+	"gorm.io/gorm"
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register GeoIP Locator, nil without SECURITY_GEOIP_URL
+	container.Provide(func() *HTTPLocator {
+		url := os.Getenv("SECURITY_GEOIP_URL")
+		if url == "" {
+			return nil
+		}
+		locator, err := NewHTTPLocator(url)
+		if err != nil {
+			logger.Warn("GeoIP lookups disabled", logger.Fields{"error": err.Error()})
+			return nil
+		}
+		return locator
+	}, core.Singleton)
+
+	// Publish GeoIP lookups to other modules
+	core.ProvideService(container, "security", contracts.GeoLocatorVersion, func() contracts.GeoLocator {
+		if locator := core.Resolve[*HTTPLocator](container); locator != nil {
+			return CountryLocator{locator}
+		}
+		return nil
+	})
+
+	// Register Service
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		config.Rules = RulesFromLists(os.Getenv("SECURITY_NOTIFY_EVENTS"), os.Getenv("SECURITY_STEP_UP_EVENTS"))
+		config.TrustGeoHeaders = os.Getenv("SECURITY_TRUST_GEO_HEADERS") == "true"
+		if speed, err := strconv.ParseFloat(os.Getenv("SECURITY_MAX_TRAVEL_SPEED"), 64); err == nil && speed > 0 {
+			config.MaxTravelSpeed = speed
+		}
+		if days, err := strconv.Atoi(os.Getenv("SECURITY_RETENTION_DAYS")); err == nil && days >= 0 {
+			config.Retention = time.Duration(days) * 24 * time.Hour
+		}
+
+		var locator Locator
+		if httpLocator := core.Resolve[*HTTPLocator](container); httpLocator != nil {
+			locator = httpLocator
+		}
+
+		var notifier Notifier
+		if manager := core.Resolve[*notification.Manager](container); manager != nil {
+			notifier = manager
+		}
+
+		return NewService(
+			core.Resolve[*Repository](container),
+			notifier,
+			locator,
+			core.Resolve[*auth.PasswordHasher](container),
+			config,
+		)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
@@ -0,0 +1,162 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/httpclient"
+)
+
+// Locator resolves an IP address to a location
+type Locator interface {
+	Locate(ctx context.Context, ip string) (*Location, error)
+}
+
+// HTTPLocator looks addresses up with a JSON GeoIP API. The URL contains
+// {ip}, e.g. https://ipapi.co/{ip}/json/ or http://ip-api.com/json/{ip}.
+// Responses in the shape of ipapi.co, ip-api.com and ipinfo.io are read.
+type HTTPLocator struct {
+	urlTemplate string
+	client      *http.Client
+}
+
+// NewHTTPLocator creates a locator for a GeoIP API URL template
+func NewHTTPLocator(urlTemplate string) (*HTTPLocator, error) {
+	if !strings.Contains(urlTemplate, "{ip}") {
+		return nil, fmt.Errorf("GeoIP URL must contain {ip}")
+	}
+	return &HTTPLocator{
+		urlTemplate: urlTemplate,
+		client:      httpclient.New(5 * time.Second),
+	}, nil
+}
+
+// Locate looks up a public IP address
+func (l *HTTPLocator) Locate(ctx context.Context, ip string) (*Location, error) {
+	url := strings.ReplaceAll(l.urlTemplate, "{ip}", ip)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GeoIP lookup error: %d", resp.StatusCode)
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	location := &Location{
+		Country: firstString(body, "country_code", "countryCode", "country"),
+		City:    firstString(body, "city"),
+	}
+	lat, latOK := firstFloat(body, "latitude", "lat")
+	lon, lonOK := firstFloat(body, "longitude", "lon")
+	if !latOK || !lonOK {
+		// ipinfo.io returns "loc": "lat,lon"
+		if loc, ok := body["loc"].(string); ok {
+			if parts := strings.Split(loc, ","); len(parts) == 2 {
+				lat, latOK = parseCoordinate(parts[0])
+				lon, lonOK = parseCoordinate(parts[1])
+			}
+		}
+	}
+	if latOK && lonOK {
+		location.Latitude, location.Longitude = &lat, &lon
+	}
+	if len(location.Country) != 2 {
+		location.Country = ""
+	}
+	return location, nil
+}
+
+// CountryLocator implements contracts.GeoLocator with a Locator
+type CountryLocator struct {
+	Locator Locator
+}
+
+// Country implements contracts.GeoLocator
+func (l CountryLocator) Country(ctx context.Context, ip string) (string, error) {
+	location, err := l.Locator.Locate(ctx, ip)
+	if err != nil || location == nil {
+		return "", err
+	}
+	return location.Country, nil
+}
+
+// locationFromHeaders reads Cloudflare's visitor location headers
+func locationFromHeaders(geo map[string]string) *Location {
+	country := strings.ToUpper(geo["cf-ipcountry"])
+	if country == "" && geo["cf-iplatitude"] == "" {
+		return nil
+	}
+	// XX is unknown and T1 is Tor
+	if len(country) != 2 || country == "XX" || country == "T1" {
+		country = ""
+	}
+
+	location := &Location{Country: country, City: geo["cf-ipcity"]}
+	lat, latOK := parseCoordinate(geo["cf-iplatitude"])
+	lon, lonOK := parseCoordinate(geo["cf-iplongitude"])
+	if latOK && lonOK {
+		location.Latitude, location.Longitude = &lat, &lon
+	}
+	return location
+}
+
+// publicIP reports whether an address is worth a GeoIP lookup
+func publicIP(ip string) bool {
+	addr := net.ParseIP(ip)
+	return addr != nil && !addr.IsLoopback() && !addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() && !addr.IsUnspecified()
+}
+
+// distanceKm is the great-circle distance between two points
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func parseCoordinate(value string) (float64, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return f, err == nil
+}
+
+func firstString(body map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := body[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func firstFloat(body map[string]interface{}, keys ...string) (float64, bool) {
+	for _, key := range keys {
+		if f, ok := body[key].(float64); ok {
+			return f, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,121 @@
+package security
+
+import "time"
+
+// Security event types
+const (
+	TypeLogin             = "login"
+	TypeNewDeviceLogin    = "new_device_login"
+	TypeImpossibleTravel  = "impossible_travel"
+	TypeLoginFailed       = "login_failed"
+	TypeAccountLocked     = "account_locked"
+	TypePasswordChanged   = "password_changed"
+	TypePasswordReset     = "password_reset"
+	TypePermissionGranted = "permission_granted"
+	TypeStepUpVerified    = "step_up_verified"
+)
+
+// Event severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Step-up verification methods
+const (
+	StepUpMethodEmail    = "email_code"
+	StepUpMethodPassword = "password"
+)
+
+// Event is an entry in a user's security log
+type Event struct {
+	ID        uint                   `gorm:"primarykey" json:"id"`
+	UserID    uint                   `gorm:"index:idx_security_events_user;not null" json:"user_id"`
+	Type      string                 `gorm:"size:50;index;not null" json:"type"`
+	Severity  string                 `gorm:"size:20;not null" json:"severity"`
+	IP        string                 `gorm:"size:45" json:"ip,omitempty"`
+	UserAgent string                 `gorm:"size:500" json:"user_agent,omitempty"`
+	DeviceID  uint                   `json:"device_id,omitempty"`
+	Country   string                 `gorm:"size:2" json:"country,omitempty"`
+	City      string                 `gorm:"size:100" json:"city,omitempty"`
+	Latitude  *float64               `json:"latitude,omitempty"`
+	Longitude *float64               `json:"longitude,omitempty"`
+	Details   map[string]interface{} `gorm:"serializer:json" json:"details,omitempty"`
+	CreatedAt time.Time              `gorm:"index:idx_security_events_user" json:"created_at"`
+}
+
+// TableName specifies the table name for Event
+func (Event) TableName() string {
+	return "security_events"
+}
+
+// located reports whether the event has coordinates
+func (e *Event) located() bool {
+	return e.Latitude != nil && e.Longitude != nil
+}
+
+// Device is a browser or app a user has signed in from, identified by its
+// user agent
+type Device struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	UserID      uint      `gorm:"uniqueIndex:idx_security_devices_user_fingerprint;not null" json:"user_id"`
+	Fingerprint string    `gorm:"size:64;uniqueIndex:idx_security_devices_user_fingerprint;not null" json:"-"`
+	UserAgent   string    `gorm:"size:500" json:"user_agent"`
+	LastIP      string    `gorm:"size:45" json:"last_ip"`
+	LastCountry string    `gorm:"size:2" json:"last_country,omitempty"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// TableName specifies the table name for Device
+func (Device) TableName() string {
+	return "security_devices"
+}
+
+// StepUp is a pending requirement for a user to verify their identity
+type StepUp struct {
+	UserID        uint       `gorm:"primarykey;autoIncrement:false" json:"user_id"`
+	Reason        string     `gorm:"size:50;not null" json:"reason"`
+	EventID       uint       `json:"event_id"`
+	CodeHash      string     `gorm:"size:64" json:"-"`
+	CodeExpiresAt *time.Time `json:"-"`
+	Attempts      int        `json:"-"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for StepUp
+func (StepUp) TableName() string {
+	return "security_step_ups"
+}
+
+// StepUpStatus tells a user whether and how to step up
+type StepUpStatus struct {
+	Required bool       `json:"required"`
+	Reason   string     `json:"reason,omitempty"`
+	Since    *time.Time `json:"since,omitempty"`
+	Method   string     `json:"method,omitempty"`
+}
+
+// Location is where an IP address is
+type Location struct {
+	Country   string
+	City      string
+	Latitude  *float64
+	Longitude *float64
+}
+
+// EventFilter narrows an event listing
+type EventFilter struct {
+	Type     string
+	Severity string
+	Since    *time.Time
+}
+
+// account is the part of a user row the module reads
+type account struct {
+	ID       uint
+	Email    string
+	Name     string
+	Password string
+}
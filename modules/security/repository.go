@@ -0,0 +1,183 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ==================== Events ====================
+
+// CreateEvent appends an event to the log
+func (r *Repository) CreateEvent(ctx context.Context, event *Event) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+// ListEvents returns a user's events, newest first
+func (r *Repository) ListEvents(ctx context.Context, userID uint, filter EventFilter, page, limit int) ([]Event, int64, error) {
+	var events []Event
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Event{}).Where("user_id = ?", userID)
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Severity != "" {
+		query = query.Where("severity = ?", filter.Severity)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&events).Error
+	return events, total, err
+}
+
+// LastLocatedLogin returns the user's most recent login with coordinates,
+// or nil
+func (r *Repository) LastLocatedLogin(ctx context.Context, userID uint) (*Event, error) {
+	var event Event
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND type IN ? AND latitude IS NOT NULL AND longitude IS NOT NULL",
+			userID, []string{TypeLogin, TypeNewDeviceLogin}).
+		Order("created_at DESC, id DESC").
+		First(&event).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &event, nil
+}
+
+// DeleteEventsBefore prunes events older than cutoff
+func (r *Repository) DeleteEventsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("created_at < ?", cutoff).Delete(&Event{})
+	return result.RowsAffected, result.Error
+}
+
+// ==================== Devices ====================
+
+// FindDevice returns a user's device by fingerprint, or nil
+func (r *Repository) FindDevice(ctx context.Context, userID uint, fingerprint string) (*Device, error) {
+	var device Device
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND fingerprint = ?", userID, fingerprint).
+		First(&device).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &device, nil
+}
+
+// CountDevices counts a user's known devices
+func (r *Repository) CountDevices(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Device{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// SaveDevice creates or updates a device
+func (r *Repository) SaveDevice(ctx context.Context, device *Device) error {
+	return r.db.WithContext(ctx).Save(device).Error
+}
+
+// ListDevices returns a user's devices, most recently seen first
+func (r *Repository) ListDevices(ctx context.Context, userID uint) ([]Device, error) {
+	var devices []Device
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("last_seen_at DESC").
+		Find(&devices).Error
+	return devices, err
+}
+
+// DeleteDevice forgets one of a user's devices
+func (r *Repository) DeleteDevice(ctx context.Context, userID, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&Device{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ==================== Step-up ====================
+
+// FindStepUp returns a user's pending step-up, or nil
+func (r *Repository) FindStepUp(ctx context.Context, userID uint) (*StepUp, error) {
+	var stepUp StepUp
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&stepUp).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &stepUp, nil
+}
+
+// SaveStepUp creates or replaces a user's pending step-up
+func (r *Repository) SaveStepUp(ctx context.Context, stepUp *StepUp) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		UpdateAll: true,
+	}).Create(stepUp).Error
+}
+
+// DeleteStepUp clears a user's pending step-up
+func (r *Repository) DeleteStepUp(ctx context.Context, userID uint) (bool, error) {
+	result := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&StepUp{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ==================== Users ====================
+
+// FindAccount returns a user by ID, or nil
+func (r *Repository) FindAccount(ctx context.Context, userID uint) (*account, error) {
+	return r.findAccount(ctx, "id = ?", userID)
+}
+
+// FindAccountByEmail returns a user by email, or nil
+func (r *Repository) FindAccountByEmail(ctx context.Context, email string) (*account, error) {
+	return r.findAccount(ctx, "email = ?", email)
+}
+
+func (r *Repository) findAccount(ctx context.Context, query string, arg interface{}) (*account, error) {
+	var rows []account
+	err := r.db.WithContext(ctx).Table("users").
+		Select("id, email, name, password").
+		Where(query+" AND deleted_at IS NULL", arg).
+		Limit(1).
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return &rows[0], nil
+}
+
+// RoleSlug returns a role's slug, or empty
+func (r *Repository) RoleSlug(ctx context.Context, roleID uint) string {
+	var slugs []string
+	r.db.WithContext(ctx).Table("roles").Where("id = ?", roleID).Limit(1).Pluck("slug", &slugs)
+	if len(slugs) == 0 {
+		return ""
+	}
+	return slugs[0]
+}
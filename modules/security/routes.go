@@ -0,0 +1,52 @@
+package security
+
+import (
+	"time"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const pruneInterval = 24 * time.Hour
+
+// recordedEvents are the platform events kept in the security log
+var recordedEvents = []string{
+	events.EventUserLoggedIn,
+	events.EventUserPasswordChanged,
+	events.EventUserPasswordReset,
+	events.EventUserRoleAssigned,
+	events.EventSecurityLoginFailed,
+	events.EventSecurityAccountLocked,
+}
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	service := core.Resolve[*Service](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	for _, name := range recordedEvents {
+		events.Register(name, service.HandleEvent)
+	}
+	service.StartPruning(pruneInterval)
+
+	security := router.Group("/security", auth.AuthMiddleware(jwtManager))
+
+	// ==================== Own Account ====================
+	security.Get("/events", controller.ListEvents)
+	security.Get("/devices", controller.ListDevices)
+	security.Delete("/devices/:id", controller.ForgetDevice)
+
+	security.Get("/step-up", controller.GetStepUp)
+	security.Post("/step-up/challenge", controller.SendStepUpCode)
+	security.Post("/step-up/verify", controller.VerifyStepUp)
+
+	// ==================== Administration ====================
+	security.Get("/users/:id/events", rbac.RequirePermission(rbacManager, "security.read"), controller.ListUserEvents)
+	security.Delete("/users/:id/step-up", rbac.RequirePermission(rbacManager, "security.manage"), controller.ClearStepUp)
+}
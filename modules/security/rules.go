@@ -0,0 +1,75 @@
+package security
+
+import "strings"
+
+// Rule reacts to security events of the listed types
+type Rule struct {
+	Name   string
+	Types  []string
+	Notify bool // Email the user
+	StepUp bool // Require step-up verification before sensitive actions
+}
+
+// Matches reports whether the rule applies to an event type
+func (r Rule) Matches(eventType string) bool {
+	for _, t := range r.Types {
+		if t == eventType || t == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultRules notify on new devices, credential and permission changes
+// and impossible travel, and require step-up after impossible travel
+func DefaultRules() []Rule {
+	return []Rule{
+		{Name: "notify", Types: []string{TypeNewDeviceLogin, TypeImpossibleTravel, TypePasswordChanged, TypePasswordReset, TypePermissionGranted}, Notify: true},
+		{Name: "step_up", Types: []string{TypeImpossibleTravel}, StepUp: true},
+	}
+}
+
+// RulesFromLists builds rules from comma-separated event type lists, as
+// in SECURITY_NOTIFY_EVENTS and SECURITY_STEP_UP_EVENTS. "none" turns a
+// rule off.
+func RulesFromLists(notify, stepUp string) []Rule {
+	defaults := DefaultRules()
+	rules := make([]Rule, 0, 2)
+	if types := parseTypes(notify, defaults[0].Types); len(types) > 0 {
+		rules = append(rules, Rule{Name: "notify", Types: types, Notify: true})
+	}
+	if types := parseTypes(stepUp, defaults[1].Types); len(types) > 0 {
+		rules = append(rules, Rule{Name: "step_up", Types: types, StepUp: true})
+	}
+	return rules
+}
+
+// parseTypes splits a list, falling back to defaults when it is empty
+func parseTypes(list string, defaults []string) []string {
+	list = strings.TrimSpace(list)
+	switch list {
+	case "":
+		return defaults
+	case "none":
+		return nil
+	}
+
+	var types []string
+	for _, t := range strings.Split(list, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// notificationSubjects are the email subjects of notified event types
+var notificationSubjects = map[string]string{
+	TypeNewDeviceLogin:    "New sign-in to your account",
+	TypeImpossibleTravel:  "Unusual sign-in to your account",
+	TypePasswordChanged:   "Your password was changed",
+	TypePasswordReset:     "Your password was reset",
+	TypePermissionGranted: "New permissions on your account",
+	TypeAccountLocked:     "Your account was temporarily locked",
+	TypeLoginFailed:       "Failed sign-in to your account",
+}
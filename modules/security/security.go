@@ -0,0 +1,31 @@
+package security
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SecurityModule struct{}
+
+func New() *SecurityModule {
+	return &SecurityModule{}
+}
+
+func (m *SecurityModule) Name() string {
+	return "security"
+}
+
+func (m *SecurityModule) Init() {}
+
+func (m *SecurityModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *SecurityModule) Routes(router fiber.Router, c *core.Container) {
+	// Sensitive routes wrapped in auth.RequireStepUp consult pending step-ups
+	auth.SetStepUpPolicy(core.Resolve[*Service](c).StepUpRequired)
+	SetupRoutes(router, c)
+}
@@ -0,0 +1,657 @@
+package security
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+)
+
+// Notifier sends security notifications by email
+type Notifier interface {
+	SendEmail(ctx context.Context, to, subject, body string) error
+}
+
+// Config holds security log configuration
+type Config struct {
+	Rules             []Rule
+	TrustGeoHeaders   bool          // Read Cloudflare's location headers
+	MaxTravelSpeed    float64       // km/h; faster movement between logins is impossible travel
+	MinTravelDistance float64       // km; closer logins are never flagged, as GeoIP is imprecise
+	StepUpCodeTTL     time.Duration // Lifetime of emailed step-up codes
+	MaxStepUpAttempts int           // Wrong codes allowed per code
+	Retention         time.Duration // Events older than this are pruned; 0 keeps them
+}
+
+// DefaultConfig returns default security log configuration
+func DefaultConfig() Config {
+	return Config{
+		Rules:             DefaultRules(),
+		MaxTravelSpeed:    900, // Airliner cruise speed
+		MinTravelDistance: 500,
+		StepUpCodeTTL:     10 * time.Minute,
+		MaxStepUpAttempts: 5,
+		Retention:         365 * 24 * time.Hour,
+	}
+}
+
+// Service records security events from the platform's event stream,
+// detects suspicious activity and applies rules to it
+type Service struct {
+	repo     *Repository
+	notifier Notifier
+	locator  Locator
+	hasher   *auth.PasswordHasher
+	config   Config
+
+	pruneOnce sync.Once
+}
+
+func NewService(repo *Repository, notifier Notifier, locator Locator, hasher *auth.PasswordHasher, config Config) *Service {
+	return &Service{
+		repo:     repo,
+		notifier: notifier,
+		locator:  locator,
+		hasher:   hasher,
+		config:   config,
+	}
+}
+
+// ==================== Recording ====================
+
+// HandleEvent records a platform event in the security log
+func (s *Service) HandleEvent(ctx context.Context, event events.Event) error {
+	data, ok := event.Data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var err error
+	switch event.Name {
+	case events.EventUserLoggedIn:
+		err = s.recordLogin(ctx, data)
+	case events.EventUserPasswordChanged:
+		err = s.recordFromData(ctx, TypePasswordChanged, SeverityWarning, data, nil)
+	case events.EventUserPasswordReset:
+		err = s.recordFromData(ctx, TypePasswordReset, SeverityWarning, data, nil)
+	case events.EventUserRoleAssigned:
+		roleID := uintValue(data["role_id"])
+		details := map[string]interface{}{"role_id": roleID}
+		if slug := s.repo.RoleSlug(ctx, roleID); slug != "" {
+			details["role"] = slug
+		}
+		if grantedBy := uintValue(data["granted_by"]); grantedBy != 0 {
+			details["granted_by"] = grantedBy
+		}
+		err = s.recordFromData(ctx, TypePermissionGranted, SeverityWarning, data, details)
+	case events.EventSecurityLoginFailed, events.EventSecurityAccountLocked:
+		err = s.recordAccountEvent(ctx, event.Name, data)
+	}
+
+	if err != nil {
+		logger.Error("Failed to record security event", logger.Fields{"event": event.Name, "error": err.Error()})
+	}
+	return nil
+}
+
+// recordLogin logs a sign-in, flagging unknown devices and logins too far
+// from the previous one to have been travelled
+func (s *Service) recordLogin(ctx context.Context, data map[string]interface{}) error {
+	userID := uintValue(data["user_id"])
+	if userID == 0 {
+		return nil
+	}
+	ip, _ := data["ip"].(string)
+	userAgent, _ := data["user_agent"].(string)
+	location := s.locate(ctx, ip, geoValue(data["geo"]))
+
+	device, isNew, err := s.touchDevice(ctx, userID, ip, userAgent, location)
+	if err != nil {
+		return err
+	}
+
+	previous, err := s.repo.LastLocatedLogin(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	event := s.newEvent(userID, TypeLogin, SeverityInfo, ip, userAgent, location)
+	event.DeviceID = device.ID
+	event.Details = map[string]interface{}{}
+	if method, ok := data["method"].(string); ok {
+		event.Details["method"] = method
+	}
+	if isNew {
+		event.Type, event.Severity = TypeNewDeviceLogin, SeverityWarning
+	}
+	if err := s.record(ctx, event, stringValue(data["email"])); err != nil {
+		return err
+	}
+
+	if travel := s.impossibleTravel(previous, event); travel != nil {
+		travel.DeviceID = device.ID
+		return s.record(ctx, travel, stringValue(data["email"]))
+	}
+	return nil
+}
+
+// touchDevice finds or registers the device a user signed in from. A
+// device is new only if the user already had others, so a first login
+// is not flagged.
+func (s *Service) touchDevice(ctx context.Context, userID uint, ip, userAgent string, location *Location) (*Device, bool, error) {
+	fingerprint := deviceFingerprint(userAgent)
+	device, err := s.repo.FindDevice(ctx, userID, fingerprint)
+	if err != nil {
+		return nil, false, err
+	}
+
+	isNew := false
+	now := time.Now()
+	if device == nil {
+		count, err := s.repo.CountDevices(ctx, userID)
+		if err != nil {
+			return nil, false, err
+		}
+		isNew = count > 0
+		device = &Device{UserID: userID, Fingerprint: fingerprint, FirstSeenAt: now}
+	}
+
+	device.UserAgent = truncate(userAgent, 500)
+	device.LastIP = ip
+	device.LastSeenAt = now
+	if location != nil {
+		device.LastCountry = location.Country
+	}
+	if err := s.repo.SaveDevice(ctx, device); err != nil {
+		return nil, false, err
+	}
+	return device, isNew, nil
+}
+
+// impossibleTravel returns an impossible travel event when reaching the
+// current login's location from the previous one would have required
+// moving faster than MaxTravelSpeed
+func (s *Service) impossibleTravel(previous, current *Event) *Event {
+	if previous == nil || !current.located() {
+		return nil
+	}
+
+	distance := distanceKm(*previous.Latitude, *previous.Longitude, *current.Latitude, *current.Longitude)
+	if distance < s.config.MinTravelDistance {
+		return nil
+	}
+	// Floor the interval so back-to-back logins do not divide by zero
+	hours := math.Max(current.CreatedAt.Sub(previous.CreatedAt).Hours(), 1.0/60)
+	speed := distance / hours
+	if speed <= s.config.MaxTravelSpeed {
+		return nil
+	}
+
+	event := *current
+	event.ID = 0
+	event.Type, event.Severity = TypeImpossibleTravel, SeverityCritical
+	event.Details = map[string]interface{}{
+		"previous_event_id": previous.ID,
+		"previous_ip":       previous.IP,
+		"previous_country":  previous.Country,
+		"previous_city":     previous.City,
+		"distance_km":       math.Round(distance),
+		"hours":             math.Round(hours*100) / 100,
+		"speed_kmh":         math.Round(speed),
+	}
+	return &event
+}
+
+// recordFromData logs an event about the user in data
+func (s *Service) recordFromData(ctx context.Context, eventType, severity string, data map[string]interface{}, details map[string]interface{}) error {
+	userID := uintValue(data["user_id"])
+	if userID == 0 {
+		return nil
+	}
+	ip, _ := data["ip"].(string)
+	userAgent, _ := data["user_agent"].(string)
+
+	event := s.newEvent(userID, eventType, severity, ip, userAgent, s.locate(ctx, ip, geoValue(data["geo"])))
+	event.Details = details
+	return s.record(ctx, event, stringValue(data["email"]))
+}
+
+// recordAccountEvent logs a lockout guard event, which names the account
+// by email; attempts on unknown accounts are not logged
+func (s *Service) recordAccountEvent(ctx context.Context, name string, data map[string]interface{}) error {
+	email := stringValue(data["account"])
+	if !strings.Contains(email, "@") {
+		return nil
+	}
+	user, err := s.repo.FindAccountByEmail(ctx, email)
+	if err != nil || user == nil {
+		return err
+	}
+
+	ip, _ := data["ip"].(string)
+	eventType, severity := TypeLoginFailed, SeverityInfo
+	details := map[string]interface{}{"failures": data["failures"]}
+	if name == events.EventSecurityAccountLocked {
+		eventType, severity = TypeAccountLocked, SeverityWarning
+		details = map[string]interface{}{"locked_until": data["locked_until"], "lockouts": data["lockouts"]}
+	}
+
+	event := s.newEvent(user.ID, eventType, severity, ip, "", s.locate(ctx, ip, nil))
+	event.Details = details
+	return s.record(ctx, event, user.Email)
+}
+
+func (s *Service) newEvent(userID uint, eventType, severity, ip, userAgent string, location *Location) *Event {
+	event := &Event{
+		UserID:    userID,
+		Type:      eventType,
+		Severity:  severity,
+		IP:        ip,
+		UserAgent: truncate(userAgent, 500),
+		CreatedAt: time.Now(),
+	}
+	if location != nil {
+		event.Country = location.Country
+		event.City = truncate(location.City, 100)
+		event.Latitude, event.Longitude = location.Latitude, location.Longitude
+	}
+	return event
+}
+
+// record stores an event and applies the rules matching it
+func (s *Service) record(ctx context.Context, event *Event, email string) error {
+	if err := s.repo.CreateEvent(ctx, event); err != nil {
+		return err
+	}
+
+	matched, notify, stepUp := false, false, false
+	for _, rule := range s.config.Rules {
+		if rule.Matches(event.Type) {
+			matched = true
+			notify = notify || rule.Notify
+			stepUp = stepUp || rule.StepUp
+		}
+	}
+	if !matched {
+		return nil
+	}
+
+	if stepUp {
+		pending := &StepUp{UserID: event.UserID, Reason: event.Type, EventID: event.ID, CreatedAt: time.Now()}
+		if err := s.repo.SaveStepUp(ctx, pending); err != nil {
+			logger.Error("Failed to require step-up", logger.Fields{"user_id": event.UserID, "error": err.Error()})
+			stepUp = false
+		}
+	}
+	if notify {
+		s.notify(ctx, event, email)
+	}
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: events.EventSecurityAlert,
+		Data: map[string]interface{}{
+			"user_id":  event.UserID,
+			"event_id": event.ID,
+			"type":     event.Type,
+			"severity": event.Severity,
+			"ip":       event.IP,
+			"country":  event.Country,
+			"step_up":  stepUp,
+		},
+	})
+	return nil
+}
+
+// notify emails the user about an event in the background
+func (s *Service) notify(ctx context.Context, event *Event, email string) {
+	if s.notifier == nil {
+		return
+	}
+	if email == "" {
+		user, err := s.repo.FindAccount(ctx, event.UserID)
+		if err != nil || user == nil {
+			return
+		}
+		email = user.Email
+	}
+
+	subject, ok := notificationSubjects[event.Type]
+	if !ok {
+		subject = "Security activity on your account"
+	}
+	body := notificationBody(event)
+
+	go func() {
+		if err := s.notifier.SendEmail(context.Background(), email, subject, body); err != nil {
+			logger.Warn("Failed to send security notification", logger.Fields{"user_id": event.UserID, "error": err.Error()})
+		}
+	}()
+}
+
+// notificationBody describes an event to its user
+func notificationBody(event *Event) string {
+	var b strings.Builder
+	switch event.Type {
+	case TypeNewDeviceLogin:
+		b.WriteString("Your account was signed in to from a device we have not seen before.")
+	case TypeImpossibleTravel:
+		b.WriteString("Your account was signed in to from a location too far from your previous sign-in to have travelled in between.")
+	case TypePasswordChanged:
+		b.WriteString("The password for your account was changed.")
+	case TypePasswordReset:
+		b.WriteString("The password for your account was reset.")
+	case TypePermissionGranted:
+		role, _ := event.Details["role"].(string)
+		if role == "" {
+			role = "a new role"
+		}
+		fmt.Fprintf(&b, "Your account was granted %s.", role)
+	default:
+		fmt.Fprintf(&b, "Security activity on your account: %s.", strings.ReplaceAll(event.Type, "_", " "))
+	}
+
+	b.WriteString("\n\nTime: " + event.CreatedAt.UTC().Format(time.RFC1123))
+	if event.IP != "" {
+		b.WriteString("\nIP address: " + event.IP)
+	}
+	if event.City != "" || event.Country != "" {
+		b.WriteString("\nLocation: " + strings.Trim(event.City+", "+event.Country, ", "))
+	}
+	if event.UserAgent != "" {
+		b.WriteString("\nDevice: " + event.UserAgent)
+	}
+	b.WriteString("\n\nIf this was not you, change your password and review your account's security log.")
+	return b.String()
+}
+
+// locate resolves where a request came from: trusted proxy headers first,
+// then the GeoIP locator for public addresses
+func (s *Service) locate(ctx context.Context, ip string, geo map[string]string) *Location {
+	if s.config.TrustGeoHeaders {
+		if location := locationFromHeaders(geo); location != nil {
+			return location
+		}
+	}
+	if s.locator == nil || !publicIP(ip) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	location, err := s.locator.Locate(ctx, ip)
+	if err != nil {
+		logger.Warn("GeoIP lookup failed", logger.Fields{"ip": ip, "error": err.Error()})
+		return nil
+	}
+	return location
+}
+
+// ==================== Queries ====================
+
+// ListEvents returns a page of a user's security log
+func (s *Service) ListEvents(ctx context.Context, userID uint, filter EventFilter, page, limit int) ([]Event, int64, error) {
+	events, total, err := s.repo.ListEvents(ctx, userID, filter, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to load security events")
+	}
+	return events, total, nil
+}
+
+// ListDevices returns the devices a user has signed in from
+func (s *Service) ListDevices(ctx context.Context, userID uint) ([]Device, error) {
+	devices, err := s.repo.ListDevices(ctx, userID)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load devices")
+	}
+	return devices, nil
+}
+
+// ForgetDevice removes a device, so the next login from it is flagged again
+func (s *Service) ForgetDevice(ctx context.Context, userID, id uint) error {
+	deleted, err := s.repo.DeleteDevice(ctx, userID, id)
+	if err != nil {
+		return errors.NewInternal("Failed to forget device")
+	}
+	if !deleted {
+		return errors.NewNotFound("Device not found")
+	}
+	return nil
+}
+
+// ==================== Step-up ====================
+
+// StepUpRequired reports whether a user has a pending step-up; it is the
+// auth.StepUpPolicy
+func (s *Service) StepUpRequired(ctx context.Context, userID uint) (bool, error) {
+	pending, err := s.repo.FindStepUp(ctx, userID)
+	return pending != nil, err
+}
+
+// GetStepUp describes a user's pending step-up
+func (s *Service) GetStepUp(ctx context.Context, userID uint) (*StepUpStatus, error) {
+	pending, err := s.repo.FindStepUp(ctx, userID)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load step-up status")
+	}
+	if pending == nil {
+		return &StepUpStatus{Required: false}, nil
+	}
+	return &StepUpStatus{
+		Required: true,
+		Reason:   pending.Reason,
+		Since:    &pending.CreatedAt,
+		Method:   s.stepUpMethod(),
+	}, nil
+}
+
+// stepUpMethod is emailed codes when email is configured, else the
+// account password
+func (s *Service) stepUpMethod() string {
+	if s.notifier != nil {
+		return StepUpMethodEmail
+	}
+	return StepUpMethodPassword
+}
+
+// SendStepUpCode emails a one-time code for completing a step-up
+func (s *Service) SendStepUpCode(ctx context.Context, userID uint) error {
+	if s.notifier == nil {
+		return errors.NewBadRequest("Verification codes are unavailable; verify with your password")
+	}
+	pending, err := s.repo.FindStepUp(ctx, userID)
+	if err != nil {
+		return errors.NewInternal("Failed to load step-up status")
+	}
+	if pending == nil {
+		return errors.NewBadRequest("No verification is pending")
+	}
+	user, err := s.repo.FindAccount(ctx, userID)
+	if err != nil || user == nil {
+		return errors.NewNotFound("User not found")
+	}
+
+	code, err := randomCode()
+	if err != nil {
+		return errors.NewInternal("Failed to generate code")
+	}
+	expiresAt := time.Now().Add(s.config.StepUpCodeTTL)
+	pending.CodeHash = hashCode(code)
+	pending.CodeExpiresAt = &expiresAt
+	pending.Attempts = 0
+	if err := s.repo.SaveStepUp(ctx, pending); err != nil {
+		return errors.NewInternal("Failed to save code")
+	}
+
+	body := fmt.Sprintf("Your verification code is %s. It expires in %d minutes.\n\n"+
+		"We asked for it because of unusual activity on your account. If you did not request it, change your password.",
+		code, int(s.config.StepUpCodeTTL.Minutes()))
+	if err := s.notifier.SendEmail(ctx, user.Email, "Your verification code", body); err != nil {
+		return errors.NewInternal("Failed to send code")
+	}
+	return nil
+}
+
+// VerifyStepUp completes a pending step-up with an emailed code or, when
+// email is not configured, the account password
+func (s *Service) VerifyStepUp(ctx context.Context, userID uint, code, password string) error {
+	pending, err := s.repo.FindStepUp(ctx, userID)
+	if err != nil {
+		return errors.NewInternal("Failed to load step-up status")
+	}
+	if pending == nil {
+		return nil
+	}
+
+	if s.stepUpMethod() == StepUpMethodEmail {
+		if err := s.checkCode(ctx, pending, code); err != nil {
+			return err
+		}
+	} else {
+		user, err := s.repo.FindAccount(ctx, userID)
+		if err != nil || user == nil {
+			return errors.NewNotFound("User not found")
+		}
+		if password == "" || s.hasher.Verify(password, user.Password) != nil {
+			return errors.New(errors.ErrCodeInvalidCredentials, "Password is incorrect", http.StatusUnauthorized)
+		}
+	}
+
+	if _, err := s.repo.DeleteStepUp(ctx, userID); err != nil {
+		return errors.NewInternal("Failed to complete verification")
+	}
+
+	client, _ := auth.ClientFromContext(ctx)
+	event := s.newEvent(userID, TypeStepUpVerified, SeverityInfo, client.IP, client.UserAgent, nil)
+	event.Details = map[string]interface{}{"reason": pending.Reason, "method": s.stepUpMethod()}
+	if err := s.record(ctx, event, ""); err != nil {
+		logger.Warn("Failed to record step-up", logger.Fields{"user_id": userID, "error": err.Error()})
+	}
+	return nil
+}
+
+// checkCode verifies an emailed code, counting wrong guesses
+func (s *Service) checkCode(ctx context.Context, pending *StepUp, code string) error {
+	if pending.CodeHash == "" || pending.CodeExpiresAt == nil || time.Now().After(*pending.CodeExpiresAt) {
+		return errors.NewBadRequest("Verification code expired; request a new one")
+	}
+	if pending.Attempts >= s.config.MaxStepUpAttempts {
+		return errors.New(errors.ErrCodeTooManyRequests, "Too many wrong codes; request a new one", http.StatusTooManyRequests)
+	}
+	if subtle.ConstantTimeCompare([]byte(hashCode(strings.TrimSpace(code))), []byte(pending.CodeHash)) != 1 {
+		pending.Attempts++
+		if err := s.repo.SaveStepUp(ctx, pending); err != nil {
+			return errors.NewInternal("Failed to save attempt")
+		}
+		return errors.NewBadRequest("Verification code is incorrect")
+	}
+	return nil
+}
+
+// ClearStepUp lifts a user's pending step-up, for administrators
+func (s *Service) ClearStepUp(ctx context.Context, userID uint) error {
+	deleted, err := s.repo.DeleteStepUp(ctx, userID)
+	if err != nil {
+		return errors.NewInternal("Failed to clear verification")
+	}
+	if !deleted {
+		return errors.NewNotFound("No verification is pending")
+	}
+	return nil
+}
+
+// ==================== Retention ====================
+
+// PruneEvents deletes events past the retention period
+func (s *Service) PruneEvents(ctx context.Context) (int64, error) {
+	if s.config.Retention <= 0 {
+		return 0, nil
+	}
+	return s.repo.DeleteEventsBefore(ctx, time.Now().Add(-s.config.Retention))
+}
+
+// StartPruning prunes old events every interval
+func (s *Service) StartPruning(interval time.Duration) {
+	s.pruneOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if _, err := s.PruneEvents(context.Background()); err != nil {
+					logger.Warn("Failed to prune security events", logger.Fields{"error": err.Error()})
+				}
+			}
+		}()
+	})
+}
+
+// ==================== Helpers ====================
+
+// deviceFingerprint identifies a device by its user agent
+func deviceFingerprint(userAgent string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(userAgent)))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomCode returns a six-digit code
+func randomCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func uintValue(value interface{}) uint {
+	switch v := value.(type) {
+	case uint:
+		return v
+	case int:
+		if v > 0 {
+			return uint(v)
+		}
+	case int64:
+		if v > 0 {
+			return uint(v)
+		}
+	case float64:
+		if v > 0 {
+			return uint(v)
+		}
+	}
+	return 0
+}
+
+func stringValue(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
+func geoValue(value interface{}) map[string]string {
+	geo, _ := value.(map[string]string)
+	return geo
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
@@ -0,0 +1,244 @@
+package status
+
+import (
+	"bytes"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// ==================== Public ====================
+
+// Page returns the public status page as JSON
+// @Summary Status page
+// @Description Component health, uptime percentages, active incidents and scheduled maintenance
+// @Tags Status
+// @Produce json
+// @Success 200 {object} api.Response{data=Page}
+// @Router /status [get]
+func (c *Controller) Page(ctx *fiber.Ctx) error {
+	page, err := c.service.Page(ctx.Context())
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=30")
+	return api.Success(ctx, page)
+}
+
+// HTML renders the public status page
+func (c *Controller) HTML(ctx *fiber.Ctx) error {
+	page, err := c.service.Page(ctx.Context())
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	var buf bytes.Buffer
+	if err := renderHTML(&buf, c.service.Title(), "/status/subscribe", page); err != nil {
+		return api.InternalError(ctx, "Failed to render status page")
+	}
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=30")
+	ctx.Type("html", "utf-8")
+	return ctx.Send(buf.Bytes())
+}
+
+// Subscribe subscribes an email to status notifications
+// @Summary Subscribe to status updates
+// @Tags Status
+// @Accept json
+// @Produce json
+// @Param subscription body SubscribeInput true "Subscription"
+// @Success 200 {object} api.Response
+// @Router /status/subscribe [post]
+func (c *Controller) Subscribe(ctx *fiber.Ctx) error {
+	var input SubscribeInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	if err := c.service.Subscribe(ctx.Context(), input.Email); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Subscribed to status updates", nil)
+}
+
+// SubscribeForm handles the subscription form on the HTML page
+func (c *Controller) SubscribeForm(ctx *fiber.Ctx) error {
+	var input SubscribeInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	if err := c.service.Subscribe(ctx.Context(), input.Email); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return ctx.Redirect("/status", fiber.StatusSeeOther)
+}
+
+// Unsubscribe removes a subscription by its token
+// @Summary Unsubscribe from status updates
+// @Tags Status
+// @Param token path string true "Unsubscribe token"
+// @Success 200 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /status/unsubscribe/{token} [get]
+func (c *Controller) Unsubscribe(ctx *fiber.Ctx) error {
+	if err := c.service.Unsubscribe(ctx.Context(), ctx.Params("token")); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Unsubscribed from status updates", nil)
+}
+
+// ==================== Management ====================
+
+// ListIncidents lists incidents and maintenance windows
+// @Summary List incidents
+// @Tags Status
+// @Security BearerAuth
+// @Produce json
+// @Param kind query string false "Kind (incident, maintenance)"
+// @Success 200 {object} api.Response{data=[]Incident}
+// @Router /status/incidents [get]
+func (c *Controller) ListIncidents(ctx *fiber.Ctx) error {
+	pagination := api.GetPagination(ctx)
+
+	incidents, total, err := c.service.ListIncidents(ctx.Context(), ctx.Query("kind"), pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, incidents, pagination.Page, pagination.Limit, total)
+}
+
+// GetIncident retrieves an incident with its timeline
+// @Summary Get incident
+// @Tags Status
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Incident ID"
+// @Success 200 {object} api.Response{data=Incident}
+// @Failure 404 {object} api.Response
+// @Router /status/incidents/{id} [get]
+func (c *Controller) GetIncident(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid incident ID", nil)
+	}
+
+	incident, err := c.service.GetIncident(ctx.Context(), uint(id))
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, incident)
+}
+
+// CreateIncident declares an incident or schedules maintenance
+// @Summary Create incident
+// @Description Declare an incident or schedule a maintenance window. Subscribers are notified.
+// @Tags Status
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param incident body IncidentInput true "Incident"
+// @Success 201 {object} api.Response{data=Incident}
+// @Router /status/incidents [post]
+func (c *Controller) CreateIncident(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input IncidentInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	incident, err := c.service.CreateIncident(ctx.Context(), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Incident created", incident)
+}
+
+// UpdateIncident edits an incident
+// @Summary Update incident
+// @Tags Status
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Incident ID"
+// @Param incident body IncidentInput true "Incident"
+// @Success 200 {object} api.Response{data=Incident}
+// @Router /status/incidents/{id} [put]
+func (c *Controller) UpdateIncident(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid incident ID", nil)
+	}
+
+	var input IncidentInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	incident, err := c.service.UpdateIncident(ctx.Context(), uint(id), &input)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, incident)
+}
+
+// PostUpdate adds a timeline update to an incident
+// @Summary Post incident update
+// @Tags Status
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Incident ID"
+// @Param update body UpdateInput true "Update"
+// @Success 201 {object} api.Response{data=Incident}
+// @Router /status/incidents/{id}/updates [post]
+func (c *Controller) PostUpdate(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid incident ID", nil)
+	}
+
+	var input UpdateInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	incident, err := c.service.PostUpdate(ctx.Context(), uint(id), &input, userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Created(ctx, "Incident updated", incident)
+}
+
+// DeleteIncident deletes an incident
+// @Summary Delete incident
+// @Tags Status
+// @Security BearerAuth
+// @Param id path int true "Incident ID"
+// @Success 204
+// @Router /status/incidents/{id} [delete]
+func (c *Controller) DeleteIncident(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return api.BadRequest(ctx, "Invalid incident ID", nil)
+	}
+
+	if err := c.service.DeleteIncident(ctx.Context(), uint(id)); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.NoContent(ctx)
+}
@@ -0,0 +1,78 @@
+package status
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/notification"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultCheckInterval = time.Minute
+	defaultRetentionDays = 90
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Service
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		if title := os.Getenv("STATUS_PAGE_TITLE"); title != "" {
+			config.Title = title
+		}
+		if pageURL := os.Getenv("STATUS_PAGE_URL"); pageURL != "" {
+			config.PageURL = pageURL
+		}
+		if ttl, err := time.ParseDuration(os.Getenv("STATUS_CACHE_TTL")); err == nil && ttl > 0 {
+			config.CacheTTL = ttl
+		}
+
+		// Serve the previous page while a rebuild runs
+		loader := cache.NewLoader(cache.NewMemoryCache(cache.DefaultMemoryCacheConfig()), cache.LoaderConfig{
+			StaleTTL:       config.CacheTTL,
+			RefreshTimeout: 10 * time.Second,
+		})
+
+		var notifier Notifier
+		if manager := core.Resolve[*notification.Manager](container); manager != nil {
+			notifier = manager
+		}
+
+		return NewService(core.Resolve[*Repository](container), loader, notifier, config)
+	}, core.Singleton)
+
+	// Register Monitor
+	container.Provide(func() *Monitor {
+		interval := defaultCheckInterval
+		if d, err := time.ParseDuration(os.Getenv("STATUS_CHECK_INTERVAL")); err == nil && d > 0 {
+			interval = d
+		}
+		days := defaultRetentionDays
+		if n, err := strconv.Atoi(os.Getenv("STATUS_RETENTION_DAYS")); err == nil && n > 0 {
+			days = n
+		}
+
+		return NewMonitor(
+			core.Resolve[*api.HealthChecker](container),
+			core.Resolve[*Repository](container),
+			interval,
+			time.Duration(days)*24*time.Hour,
+			core.Resolve[*Service](container).HandleChanges,
+		)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
@@ -0,0 +1,118 @@
+package status
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// statusLabels are the human-readable page statuses
+var statusLabels = map[string]string{
+	StatusOperational:   "Operational",
+	StatusMaintenance:   "Under Maintenance",
+	StatusDegraded:      "Degraded Performance",
+	StatusPartialOutage: "Partial Outage",
+	StatusMajorOutage:   "Major Outage",
+}
+
+var pageSummaries = map[string]string{
+	StatusOperational:   "All Systems Operational",
+	StatusMaintenance:   "Scheduled Maintenance In Progress",
+	StatusDegraded:      "Some Systems Degraded",
+	StatusPartialOutage: "Partial System Outage",
+	StatusMajorOutage:   "Major System Outage",
+}
+
+var pageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"label":   func(s string) string { return statusLabels[s] },
+	"summary": func(s string) string { return pageSummaries[s] },
+	"human":   func(s string) string { return strings.ReplaceAll(s, "_", " ") },
+	"date":    func(t time.Time) string { return t.UTC().Format("Jan 2, 15:04 MST") },
+	"bar": func(uptime float64) string {
+		switch {
+		case uptime >= 99.9:
+			return "ok"
+		case uptime >= 99:
+			return "warn"
+		default:
+			return "bad"
+		}
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<title>{{.Title}}</title>
+<style>
+body{font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Roboto,sans-serif;max-width:860px;margin:0 auto;padding:24px;color:#1f2937;background:#f9fafb}
+h1{font-size:24px}
+.banner{padding:16px 20px;border-radius:8px;color:#fff;font-weight:600;margin-bottom:24px}
+.operational{background:#16a34a}.under_maintenance{background:#2563eb}.degraded{background:#ca8a04}.partial_outage{background:#ea580c}.major_outage{background:#dc2626}
+.card{background:#fff;border:1px solid #e5e7eb;border-radius:8px;padding:16px 20px;margin-bottom:16px}
+.row{display:flex;justify-content:space-between;align-items:center}
+.pill{font-size:12px;padding:2px 8px;border-radius:999px;color:#fff}
+.bars{display:flex;gap:2px;margin-top:8px}
+.bars span{flex:1;height:24px;border-radius:2px}
+.ok{background:#22c55e}.warn{background:#eab308}.bad{background:#ef4444}
+.muted{color:#6b7280;font-size:13px}
+form{display:flex;gap:8px}
+input{flex:1;padding:8px;border:1px solid #d1d5db;border-radius:6px}
+button{padding:8px 16px;border:0;border-radius:6px;background:#111827;color:#fff}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="banner {{.Page.Status}}">{{summary .Page.Status}}</div>
+
+{{range .Page.Active}}
+<div class="card">
+  <div class="row"><strong>{{.Title}}</strong><span class="pill {{if eq .Kind "maintenance"}}under_maintenance{{else}}partial_outage{{end}}">{{human .Status}}</span></div>
+  {{range .Updates}}<p><strong>{{human .Status}}</strong> - {{.Message}} <span class="muted">{{date .CreatedAt}}</span></p>{{end}}
+</div>
+{{end}}
+
+<div class="card">
+{{range .Page.Components}}
+  <div style="margin:12px 0">
+    <div class="row"><strong>{{.Name}}</strong><span class="pill {{.Status}}">{{label .Status}}</span></div>
+    <div class="bars">{{range .History}}<span class="{{bar .Uptime}}" title="{{.Date}}: {{.Uptime}}%"></span>{{end}}</div>
+    <div class="row muted"><span>90 days ago</span><span>{{printf "%.2f" .Uptime.Quarter}}% uptime</span><span>Today</span></div>
+  </div>
+{{else}}
+  <p class="muted">No health data collected yet.</p>
+{{end}}
+</div>
+
+{{if .Page.Scheduled}}
+<h2>Scheduled Maintenance</h2>
+{{range .Page.Scheduled}}
+<div class="card"><strong>{{.Title}}</strong><p class="muted">{{date .StartsAt}}{{if .EndsAt}} - {{date .EndsAt}}{{end}}</p></div>
+{{end}}
+{{end}}
+
+<h2>Past Incidents</h2>
+{{range .Page.Recent}}
+<div class="card"><strong>{{.Title}}</strong>{{range .Updates}}<p><strong>{{human .Status}}</strong> - {{.Message}} <span class="muted">{{date .CreatedAt}}</span></p>{{end}}</div>
+{{else}}
+<p class="muted">No incidents in the last 7 days.</p>
+{{end}}
+
+<div class="card">
+  <p><strong>Get notified of status changes</strong></p>
+  <form method="post" action="{{.SubscribeURL}}"><input type="email" name="email" placeholder="you@example.com" required><button type="submit">Subscribe</button></form>
+</div>
+<p class="muted">Updated {{date .Page.GeneratedAt}}</p>
+</body>
+</html>`))
+
+// renderHTML writes the status page as HTML
+func renderHTML(w io.Writer, title, subscribeURL string, page *Page) error {
+	return pageTemplate.Execute(w, struct {
+		Title        string
+		SubscribeURL string
+		Page         *Page
+	}{title, subscribeURL, page})
+}
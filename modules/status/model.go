@@ -0,0 +1,196 @@
+package status
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Component and page statuses, from best to worst
+const (
+	StatusOperational   = "operational"
+	StatusMaintenance   = "under_maintenance"
+	StatusDegraded      = "degraded"
+	StatusPartialOutage = "partial_outage"
+	StatusMajorOutage   = "major_outage"
+)
+
+var statusRank = map[string]int{
+	StatusOperational:   0,
+	StatusMaintenance:   1,
+	StatusDegraded:      2,
+	StatusPartialOutage: 3,
+	StatusMajorOutage:   4,
+}
+
+// worse returns the more severe of two statuses
+func worse(a, b string) string {
+	if statusRank[b] > statusRank[a] {
+		return b
+	}
+	return a
+}
+
+// Incident kinds
+const (
+	KindIncident    = "incident"
+	KindMaintenance = "maintenance"
+)
+
+// Incident statuses
+const (
+	IncidentInvestigating = "investigating"
+	IncidentIdentified    = "identified"
+	IncidentMonitoring    = "monitoring"
+	IncidentResolved      = "resolved"
+
+	MaintenanceScheduled  = "scheduled"
+	MaintenanceInProgress = "in_progress"
+	MaintenanceCompleted  = "completed"
+)
+
+// Incident impacts
+const (
+	ImpactNone     = "none"
+	ImpactMinor    = "minor"
+	ImpactMajor    = "major"
+	ImpactCritical = "critical"
+)
+
+// impactStatus maps an incident impact to the status it imposes
+var impactStatus = map[string]string{
+	ImpactNone:     StatusOperational,
+	ImpactMinor:    StatusDegraded,
+	ImpactMajor:    StatusPartialOutage,
+	ImpactCritical: StatusMajorOutage,
+}
+
+// Sample is one health-check observation of a component
+type Sample struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Component string    `gorm:"size:100;index:idx_status_samples_component_time;not null" json:"component"`
+	Status    string    `gorm:"size:20;not null" json:"status"` // healthy, degraded, unhealthy
+	Message   string    `gorm:"size:255" json:"message,omitempty"`
+	CheckedAt time.Time `gorm:"index:idx_status_samples_component_time;index" json:"checked_at"`
+}
+
+// TableName specifies the table name for Sample
+func (Sample) TableName() string {
+	return "status_samples"
+}
+
+// Incident is a declared outage or maintenance window. Components is a
+// comma-separated list of affected health checks; empty means all.
+type Incident struct {
+	ID         uint           `gorm:"primarykey" json:"id"`
+	Kind       string         `gorm:"size:20;default:'incident';index" json:"kind"`
+	Title      string         `gorm:"size:255;not null" json:"title"`
+	Status     string         `gorm:"size:20;index" json:"status"`
+	Impact     string         `gorm:"size:20;default:'minor'" json:"impact"`
+	Components string         `gorm:"size:500" json:"-"`
+	StartsAt   time.Time      `gorm:"index" json:"starts_at"`
+	EndsAt     *time.Time     `json:"ends_at,omitempty"` // Scheduled end of a maintenance window
+	ResolvedAt *time.Time     `json:"resolved_at,omitempty"`
+	CreatedBy  uint           `json:"created_by,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Updates       []IncidentUpdate `gorm:"foreignKey:IncidentID" json:"updates,omitempty"`
+	ComponentList []string         `gorm:"-" json:"components"`
+}
+
+// TableName specifies the table name for Incident
+func (Incident) TableName() string {
+	return "status_incidents"
+}
+
+// Resolved reports whether the incident or maintenance is over
+func (i *Incident) Resolved() bool {
+	return i.Status == IncidentResolved || i.Status == MaintenanceCompleted
+}
+
+// ActiveAt reports whether the incident affects the page at t
+func (i *Incident) ActiveAt(t time.Time) bool {
+	if i.Resolved() || t.Before(i.StartsAt) {
+		return false
+	}
+	return i.EndsAt == nil || t.Before(*i.EndsAt)
+}
+
+// Affects reports whether the incident covers a component
+func (i *Incident) Affects(component string) bool {
+	if i.Components == "" {
+		return true
+	}
+	for _, c := range strings.Split(i.Components, ",") {
+		if c == component {
+			return true
+		}
+	}
+	return false
+}
+
+// IncidentUpdate is a timeline entry on an incident
+type IncidentUpdate struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	IncidentID uint      `gorm:"index;not null" json:"incident_id"`
+	Status     string    `gorm:"size:20" json:"status"`
+	Message    string    `gorm:"type:text;not null" json:"message"`
+	CreatedBy  uint      `json:"created_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for IncidentUpdate
+func (IncidentUpdate) TableName() string {
+	return "status_incident_updates"
+}
+
+// Subscriber receives status change notifications
+type Subscriber struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Email     string    `gorm:"size:255;uniqueIndex;not null" json:"email"`
+	Token     string    `gorm:"size:64;uniqueIndex;not null" json:"-"` // Unsubscribe token
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for Subscriber
+func (Subscriber) TableName() string {
+	return "status_subscribers"
+}
+
+// ==================== Page ====================
+
+// Uptime is a component's availability over the standard windows, in percent
+type Uptime struct {
+	Day     float64 `json:"24h"`
+	Week    float64 `json:"7d"`
+	Month   float64 `json:"30d"`
+	Quarter float64 `json:"90d"`
+}
+
+// DayUptime is a component's availability on one day
+type DayUptime struct {
+	Date   string  `json:"date"`
+	Uptime float64 `json:"uptime"`
+}
+
+// Component is a health check as shown on the status page
+type Component struct {
+	Name    string      `json:"name"`
+	Status  string      `json:"status"`
+	Message string      `json:"message,omitempty"`
+	Uptime  Uptime      `json:"uptime"`
+	History []DayUptime `json:"history"`
+}
+
+// Page is the public status page
+type Page struct {
+	Status      string      `json:"status"`
+	Components  []Component `json:"components"`
+	Active      []Incident  `json:"active_incidents"`
+	Scheduled   []Incident  `json:"scheduled_maintenance"`
+	Recent      []Incident  `json:"recent_incidents"`
+	GeneratedAt time.Time   `json:"generated_at"`
+}
@@ -0,0 +1,157 @@
+package status
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/logger"
+)
+
+// Change is a component moving between health states
+type Change struct {
+	Component string
+	From      string // Empty when first seen
+	To        string
+	Message   string
+}
+
+// Monitor samples the application health checks on an interval, stores
+// the results and reports state changes
+type Monitor struct {
+	checker   *api.HealthChecker
+	repo      *Repository
+	interval  time.Duration
+	retention time.Duration
+	onChange  func(ctx context.Context, changes []Change)
+
+	mu      sync.Mutex
+	last    map[string]string
+	started bool
+	stop    chan struct{}
+}
+
+// NewMonitor creates a health monitor. Samples older than retention are
+// pruned.
+func NewMonitor(checker *api.HealthChecker, repo *Repository, interval, retention time.Duration, onChange func(ctx context.Context, changes []Change)) *Monitor {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &Monitor{
+		checker:   checker,
+		repo:      repo,
+		interval:  interval,
+		retention: retention,
+		onChange:  onChange,
+	}
+}
+
+// Start begins sampling in the background. It is safe to call more than once.
+func (m *Monitor) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.started || m.checker == nil {
+		return
+	}
+	m.started = true
+	m.stop = make(chan struct{})
+	go m.run(m.stop)
+}
+
+// Stop ends sampling
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.started {
+		close(m.stop)
+		m.started = false
+	}
+}
+
+func (m *Monitor) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	pruneEvery := int(time.Hour / m.interval)
+	if pruneEvery < 1 {
+		pruneEvery = 1
+	}
+
+	for tick := 0; ; tick++ {
+		m.Sample(context.Background())
+		if m.retention > 0 && tick%pruneEvery == 0 {
+			if _, err := m.repo.PruneSamples(context.Background(), time.Now().Add(-m.retention)); err != nil {
+				logger.Warn("Failed to prune status samples", logger.Fields{"error": err.Error()})
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Sample runs the health checks once and records the results
+func (m *Monitor) Sample(ctx context.Context) {
+	health := m.checker.Check()
+	now := time.Now()
+
+	m.mu.Lock()
+	if m.last == nil {
+		m.last = m.loadLast(ctx)
+	}
+
+	samples := make([]Sample, 0, len(health.Checks))
+	var changes []Change
+	for name, result := range health.Checks {
+		status := string(result.Status)
+		samples = append(samples, Sample{
+			Component: name,
+			Status:    status,
+			Message:   truncate(result.Message, 255),
+			CheckedAt: now,
+		})
+
+		if previous, seen := m.last[name]; !seen || previous != status {
+			changes = append(changes, Change{Component: name, From: previous, To: status, Message: result.Message})
+			m.last[name] = status
+		}
+	}
+	m.mu.Unlock()
+
+	if err := m.repo.RecordSamples(ctx, samples); err != nil {
+		logger.Warn("Failed to record status samples", logger.Fields{"error": err.Error()})
+	}
+
+	if len(changes) > 0 && m.onChange != nil {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Component < changes[j].Component })
+		m.onChange(ctx, changes)
+	}
+}
+
+// loadLast seeds the known states from storage so restarts do not report
+// every component as changed
+func (m *Monitor) loadLast(ctx context.Context) map[string]string {
+	last := make(map[string]string)
+	samples, err := m.repo.LatestSamples(ctx)
+	if err != nil {
+		return last
+	}
+	for _, s := range samples {
+		last[s.Component] = s.Status
+	}
+	return last
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
@@ -0,0 +1,210 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ==================== Samples ====================
+
+func (r *Repository) RecordSamples(ctx context.Context, samples []Sample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Create(&samples).Error
+}
+
+// LatestSamples returns the most recent sample of every component
+func (r *Repository) LatestSamples(ctx context.Context) ([]Sample, error) {
+	var samples []Sample
+	latest := r.db.Model(&Sample{}).Select("MAX(id)").Group("component")
+	err := r.db.WithContext(ctx).Where("id IN (?)", latest).Order("component ASC").Find(&samples).Error
+	return samples, err
+}
+
+// Uptime returns the percentage of non-unhealthy samples per component
+// since the given time
+func (r *Repository) Uptime(ctx context.Context, since time.Time) (map[string]float64, error) {
+	var rows []struct {
+		Component string
+		Total     int64
+		Up        int64
+	}
+	err := r.db.WithContext(ctx).Model(&Sample{}).
+		Select("component, COUNT(*) AS total, SUM(CASE WHEN status = 'unhealthy' THEN 0 ELSE 1 END) AS up").
+		Where("checked_at >= ?", since).
+		Group("component").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	uptime := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		if row.Total > 0 {
+			uptime[row.Component] = percent(row.Up, row.Total)
+		}
+	}
+	return uptime, nil
+}
+
+// DailyUptime returns per-day uptime per component since the given time
+func (r *Repository) DailyUptime(ctx context.Context, since time.Time) (map[string][]DayUptime, error) {
+	var rows []struct {
+		Component string
+		Day       string
+		Total     int64
+		Up        int64
+	}
+	err := r.db.WithContext(ctx).Model(&Sample{}).
+		Select("component, DATE(checked_at) AS day, COUNT(*) AS total, SUM(CASE WHEN status = 'unhealthy' THEN 0 ELSE 1 END) AS up").
+		Where("checked_at >= ?", since).
+		Group("component, DATE(checked_at)").
+		Order("day ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	daily := make(map[string][]DayUptime)
+	for _, row := range rows {
+		day := row.Day
+		if len(day) > 10 {
+			day = day[:10] // Drivers returning timestamps
+		}
+		daily[row.Component] = append(daily[row.Component], DayUptime{Date: day, Uptime: percent(row.Up, row.Total)})
+	}
+	return daily, nil
+}
+
+// PruneSamples deletes samples older than before
+func (r *Repository) PruneSamples(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("checked_at < ?", before).Delete(&Sample{})
+	return result.RowsAffected, result.Error
+}
+
+// ==================== Incidents ====================
+
+func (r *Repository) ListIncidents(ctx context.Context, kind string, page, limit int) ([]Incident, int64, error) {
+	var incidents []Incident
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&Incident{})
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("starts_at DESC").Offset(offset).Limit(limit).Find(&incidents).Error
+	return incidents, total, err
+}
+
+func (r *Repository) FindIncident(ctx context.Context, id uint) (*Incident, error) {
+	var incident Incident
+	err := r.db.WithContext(ctx).
+		Preload("Updates", func(db *gorm.DB) *gorm.DB { return db.Order("created_at DESC") }).
+		First(&incident, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &incident, nil
+}
+
+// OpenIncidents returns unresolved incidents and maintenance windows,
+// including those scheduled for the future
+func (r *Repository) OpenIncidents(ctx context.Context) ([]Incident, error) {
+	var incidents []Incident
+	err := r.db.WithContext(ctx).
+		Preload("Updates", func(db *gorm.DB) *gorm.DB { return db.Order("created_at DESC") }).
+		Where("status NOT IN ?", []string{IncidentResolved, MaintenanceCompleted}).
+		Order("starts_at ASC").
+		Find(&incidents).Error
+	return incidents, err
+}
+
+// ResolvedSince returns incidents resolved after the given time
+func (r *Repository) ResolvedSince(ctx context.Context, since time.Time) ([]Incident, error) {
+	var incidents []Incident
+	err := r.db.WithContext(ctx).
+		Preload("Updates", func(db *gorm.DB) *gorm.DB { return db.Order("created_at DESC") }).
+		Where("resolved_at >= ?", since).
+		Order("resolved_at DESC").
+		Find(&incidents).Error
+	return incidents, err
+}
+
+func (r *Repository) CreateIncident(ctx context.Context, incident *Incident) error {
+	return r.db.WithContext(ctx).Create(incident).Error
+}
+
+func (r *Repository) UpdateIncident(ctx context.Context, incident *Incident) error {
+	return r.db.WithContext(ctx).Omit("Updates").Save(incident).Error
+}
+
+func (r *Repository) DeleteIncident(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&Incident{}, id).Error
+}
+
+func (r *Repository) AddUpdate(ctx context.Context, update *IncidentUpdate) error {
+	return r.db.WithContext(ctx).Create(update).Error
+}
+
+// ==================== Subscribers ====================
+
+func (r *Repository) FindSubscriberByEmail(ctx context.Context, email string) (*Subscriber, error) {
+	var subscriber Subscriber
+	err := r.db.WithContext(ctx).Where("email = ?", email).First(&subscriber).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &subscriber, nil
+}
+
+func (r *Repository) CreateSubscriber(ctx context.Context, subscriber *Subscriber) error {
+	return r.db.WithContext(ctx).Create(subscriber).Error
+}
+
+// DeleteSubscriberByToken removes a subscriber, reporting whether one matched
+func (r *Repository) DeleteSubscriberByToken(ctx context.Context, token string) (bool, error) {
+	result := r.db.WithContext(ctx).Where("token = ?", token).Delete(&Subscriber{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// EachSubscriber streams subscribers in batches
+func (r *Repository) EachSubscriber(ctx context.Context, batchSize int, fn func(batch []Subscriber) error) error {
+	var batch []Subscriber
+	return r.db.WithContext(ctx).
+		Order("id ASC").
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
+
+func percent(part, total int64) float64 {
+	if total == 0 {
+		return 100
+	}
+	// Round to two decimals
+	return float64(part*10000/total) / 100
+}
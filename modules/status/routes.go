@@ -0,0 +1,41 @@
+package status
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	// Start collecting health history
+	core.Resolve[*Monitor](container).Start()
+
+	// ==================== Public Page ====================
+	if app := core.Resolve[*fiber.App](container); app != nil {
+		app.Get("/status", controller.HTML)
+		app.Get("/status.json", controller.Page)
+		app.Post("/status/subscribe", controller.SubscribeForm)
+		app.Get("/status/unsubscribe/:token", controller.Unsubscribe)
+	}
+
+	status := router.Group("/status")
+	status.Get("", controller.Page)
+	status.Post("/subscribe", controller.Subscribe)
+	status.Get("/unsubscribe/:token", controller.Unsubscribe)
+
+	// ==================== Incident Management ====================
+	incidents := status.Group("/incidents", auth.AuthMiddleware(jwtManager, auth.AcceptAPIKeys()), rbac.RequirePermission(rbacManager, "status.manage"))
+	incidents.Get("", controller.ListIncidents)
+	incidents.Post("", controller.CreateIncident)
+	incidents.Get("/:id", controller.GetIncident)
+	incidents.Put("/:id", controller.UpdateIncident)
+	incidents.Delete("/:id", controller.DeleteIncident)
+	incidents.Post("/:id/updates", controller.PostUpdate)
+}
@@ -0,0 +1,521 @@
+package status
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+)
+
+// Status event names
+const (
+	EventComponentChanged = "status.component.changed"
+	EventIncidentCreated  = "status.incident.created"
+	EventIncidentUpdated  = "status.incident.updated"
+)
+
+const (
+	pageCacheKey        = "status:page"
+	recentIncidentDays  = 7
+	historyDays         = 90
+	subscriberBatchSize = 200
+)
+
+// componentStatus maps health check results to page statuses
+var componentStatus = map[string]string{
+	"healthy":   StatusOperational,
+	"degraded":  StatusDegraded,
+	"unhealthy": StatusMajorOutage,
+}
+
+var incidentStatuses = map[string]bool{
+	IncidentInvestigating: true, IncidentIdentified: true, IncidentMonitoring: true, IncidentResolved: true,
+}
+
+var maintenanceStatuses = map[string]bool{
+	MaintenanceScheduled: true, MaintenanceInProgress: true, MaintenanceCompleted: true,
+}
+
+// Config holds status page configuration
+type Config struct {
+	Title    string        // Page title
+	PageURL  string        // Public URL of the status page, used in notifications
+	CacheTTL time.Duration // How long a rendered page is reused
+}
+
+// DefaultConfig returns default status page configuration
+func DefaultConfig() Config {
+	return Config{
+		Title:    "Neonex Core Status",
+		PageURL:  "http://localhost:8080/status",
+		CacheTTL: 30 * time.Second,
+	}
+}
+
+// Notifier delivers subscriber notifications
+type Notifier interface {
+	SendEmail(ctx context.Context, to, subject, body string) error
+}
+
+// IncidentInput is the payload for declaring or editing an incident
+type IncidentInput struct {
+	Kind       string     `json:"kind" validate:"omitempty,oneof=incident maintenance"`
+	Title      string     `json:"title" validate:"required,max=255"`
+	Status     string     `json:"status" validate:"omitempty,max=20"`
+	Impact     string     `json:"impact" validate:"omitempty,oneof=none minor major critical"`
+	Components []string   `json:"components" validate:"dive,max=100"`
+	StartsAt   *time.Time `json:"starts_at"`
+	EndsAt     *time.Time `json:"ends_at"`
+	Message    string     `json:"message"` // Initial timeline entry
+}
+
+// UpdateInput is the payload for a timeline update
+type UpdateInput struct {
+	Status  string `json:"status" validate:"required,max=20"`
+	Message string `json:"message" validate:"required"`
+}
+
+// SubscribeInput is the payload for subscribing to notifications
+type SubscribeInput struct {
+	Email string `json:"email" form:"email" validate:"required,email,max=255"`
+}
+
+type Service struct {
+	repo     *Repository
+	loader   *cache.Loader
+	notifier Notifier
+	config   Config
+}
+
+func NewService(repo *Repository, loader *cache.Loader, notifier Notifier, config Config) *Service {
+	return &Service{
+		repo:     repo,
+		loader:   loader,
+		notifier: notifier,
+		config:   config,
+	}
+}
+
+// Title returns the page title
+func (s *Service) Title() string {
+	return s.config.Title
+}
+
+// ==================== Page ====================
+
+// Page returns the public status page, served from cache
+func (s *Service) Page(ctx context.Context) (*Page, error) {
+	value, err := s.loader.GetOrLoad(ctx, pageCacheKey, s.config.CacheTTL, func(ctx context.Context) (interface{}, error) {
+		return s.buildPage(ctx)
+	})
+	if err != nil {
+		return nil, errors.NewInternal("Failed to build status page").WithError(err)
+	}
+	return value.(*Page), nil
+}
+
+func (s *Service) buildPage(ctx context.Context) (*Page, error) {
+	now := time.Now()
+
+	latest, err := s.repo.LatestSamples(ctx)
+	if err != nil {
+		return nil, err
+	}
+	open, err := s.repo.OpenIncidents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	recent, err := s.repo.ResolvedSince(ctx, now.AddDate(0, 0, -recentIncidentDays))
+	if err != nil {
+		return nil, err
+	}
+
+	windows := []time.Time{now.Add(-24 * time.Hour), now.AddDate(0, 0, -7), now.AddDate(0, 0, -30), now.AddDate(0, 0, -historyDays)}
+	uptimes := make([]map[string]float64, len(windows))
+	for i, since := range windows {
+		if uptimes[i], err = s.repo.Uptime(ctx, since); err != nil {
+			return nil, err
+		}
+	}
+	history, err := s.repo.DailyUptime(ctx, windows[3])
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{
+		Status:      StatusOperational,
+		Components:  make([]Component, 0, len(latest)),
+		Active:      []Incident{},
+		Scheduled:   []Incident{},
+		Recent:      recent,
+		GeneratedAt: now,
+	}
+
+	for i := range open {
+		decodeComponents(&open[i])
+		switch {
+		case open[i].ActiveAt(now):
+			page.Active = append(page.Active, open[i])
+		case now.Before(open[i].StartsAt):
+			page.Scheduled = append(page.Scheduled, open[i])
+		}
+	}
+	for i := range page.Recent {
+		decodeComponents(&page.Recent[i])
+	}
+
+	for _, sample := range latest {
+		component := Component{
+			Name:    sample.Component,
+			Status:  componentStatus[sample.Status],
+			Message: sample.Message,
+			Uptime: Uptime{
+				Day:     uptimeOr100(uptimes[0], sample.Component),
+				Week:    uptimeOr100(uptimes[1], sample.Component),
+				Month:   uptimeOr100(uptimes[2], sample.Component),
+				Quarter: uptimeOr100(uptimes[3], sample.Component),
+			},
+			History: history[sample.Component],
+		}
+		if component.Status == "" {
+			component.Status = StatusOperational
+		}
+
+		// Declared incidents and maintenance override measured health
+		for _, incident := range page.Active {
+			if !incident.Affects(sample.Component) {
+				continue
+			}
+			if incident.Kind == KindMaintenance {
+				component.Status = worse(component.Status, StatusMaintenance)
+			} else {
+				component.Status = worse(component.Status, impactStatus[incident.Impact])
+			}
+		}
+
+		page.Status = worse(page.Status, component.Status)
+		page.Components = append(page.Components, component)
+	}
+
+	// Incidents may also cover components with no samples yet
+	for _, incident := range page.Active {
+		if incident.Kind == KindMaintenance {
+			page.Status = worse(page.Status, StatusMaintenance)
+		} else {
+			page.Status = worse(page.Status, impactStatus[incident.Impact])
+		}
+	}
+
+	return page, nil
+}
+
+// invalidate drops the cached page
+func (s *Service) invalidate(ctx context.Context) {
+	s.loader.Cache().Delete(ctx, pageCacheKey)
+}
+
+// ==================== Incidents ====================
+
+func (s *Service) ListIncidents(ctx context.Context, kind string, page, limit int) ([]Incident, int64, error) {
+	incidents, total, err := s.repo.ListIncidents(ctx, kind, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list incidents").WithError(err)
+	}
+	for i := range incidents {
+		decodeComponents(&incidents[i])
+	}
+	return incidents, total, nil
+}
+
+func (s *Service) GetIncident(ctx context.Context, id uint) (*Incident, error) {
+	incident, err := s.repo.FindIncident(ctx, id)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load incident").WithError(err)
+	}
+	if incident == nil {
+		return nil, errors.NewNotFound("Incident not found")
+	}
+	decodeComponents(incident)
+	return incident, nil
+}
+
+// CreateIncident declares an incident or schedules maintenance
+func (s *Service) CreateIncident(ctx context.Context, input *IncidentInput, userID uint) (*Incident, error) {
+	incident := &Incident{Kind: input.Kind, CreatedBy: userID}
+	if incident.Kind == "" {
+		incident.Kind = KindIncident
+	}
+	if err := applyIncidentInput(incident, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateIncident(ctx, incident); err != nil {
+		return nil, errors.NewInternal("Failed to create incident").WithError(err)
+	}
+
+	if input.Message != "" {
+		update := &IncidentUpdate{IncidentID: incident.ID, Status: incident.Status, Message: input.Message, CreatedBy: userID}
+		if err := s.repo.AddUpdate(ctx, update); err != nil {
+			return nil, errors.NewInternal("Failed to add incident update").WithError(err)
+		}
+		incident.Updates = []IncidentUpdate{*update}
+	}
+
+	s.invalidate(ctx)
+	s.announce(ctx, EventIncidentCreated, incident, input.Message)
+	return incident, nil
+}
+
+// UpdateIncident edits an incident's details without posting to its timeline
+func (s *Service) UpdateIncident(ctx context.Context, id uint, input *IncidentInput) (*Incident, error) {
+	incident, err := s.GetIncident(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if input.Kind != "" && input.Kind != incident.Kind {
+		return nil, errors.NewBadRequest("Incident kind cannot be changed")
+	}
+	if err := applyIncidentInput(incident, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateIncident(ctx, incident); err != nil {
+		return nil, errors.NewInternal("Failed to update incident").WithError(err)
+	}
+	s.invalidate(ctx)
+	return incident, nil
+}
+
+// PostUpdate adds a timeline entry and moves the incident to its status
+func (s *Service) PostUpdate(ctx context.Context, id uint, input *UpdateInput, userID uint) (*Incident, error) {
+	incident, err := s.GetIncident(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !validStatus(incident.Kind, input.Status) {
+		return nil, errors.NewBadRequest(fmt.Sprintf("Invalid status %q for %s", input.Status, incident.Kind))
+	}
+
+	incident.Status = input.Status
+	if incident.Resolved() && incident.ResolvedAt == nil {
+		now := time.Now()
+		incident.ResolvedAt = &now
+	} else if !incident.Resolved() {
+		incident.ResolvedAt = nil
+	}
+	if err := s.repo.UpdateIncident(ctx, incident); err != nil {
+		return nil, errors.NewInternal("Failed to update incident").WithError(err)
+	}
+
+	update := &IncidentUpdate{IncidentID: incident.ID, Status: input.Status, Message: input.Message, CreatedBy: userID}
+	if err := s.repo.AddUpdate(ctx, update); err != nil {
+		return nil, errors.NewInternal("Failed to add incident update").WithError(err)
+	}
+	incident.Updates = append([]IncidentUpdate{*update}, incident.Updates...)
+
+	s.invalidate(ctx)
+	s.announce(ctx, EventIncidentUpdated, incident, input.Message)
+	return incident, nil
+}
+
+func (s *Service) DeleteIncident(ctx context.Context, id uint) error {
+	if _, err := s.GetIncident(ctx, id); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteIncident(ctx, id); err != nil {
+		return errors.NewInternal("Failed to delete incident").WithError(err)
+	}
+	s.invalidate(ctx)
+	return nil
+}
+
+// ==================== Subscribers ====================
+
+// Subscribe registers an email for status notifications. Subscribing
+// twice is not an error.
+func (s *Service) Subscribe(ctx context.Context, email string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	existing, err := s.repo.FindSubscriberByEmail(ctx, email)
+	if err != nil {
+		return errors.NewInternal("Failed to load subscriber").WithError(err)
+	}
+	if existing != nil {
+		return nil
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return errors.NewInternal("Failed to generate token").WithError(err)
+	}
+	if err := s.repo.CreateSubscriber(ctx, &Subscriber{Email: email, Token: token}); err != nil {
+		return errors.NewInternal("Failed to subscribe").WithError(err)
+	}
+	return nil
+}
+
+func (s *Service) Unsubscribe(ctx context.Context, token string) error {
+	deleted, err := s.repo.DeleteSubscriberByToken(ctx, token)
+	if err != nil {
+		return errors.NewInternal("Failed to unsubscribe").WithError(err)
+	}
+	if !deleted {
+		return errors.NewNotFound("Subscription not found")
+	}
+	return nil
+}
+
+// ==================== Notifications ====================
+
+// HandleChanges reacts to component state changes from the monitor
+func (s *Service) HandleChanges(ctx context.Context, changes []Change) {
+	s.invalidate(ctx)
+
+	var lines []string
+	for _, change := range changes {
+		events.DispatchAsync(ctx, events.Event{
+			Name: EventComponentChanged,
+			Data: map[string]interface{}{
+				"component": change.Component,
+				"from":      change.From,
+				"to":        change.To,
+				"message":   change.Message,
+			},
+		})
+
+		// First observations are not state changes
+		if change.From == "" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s is now %s (was %s). %s",
+			change.Component, componentStatus[change.To], componentStatus[change.From], change.Message))
+	}
+
+	if len(lines) > 0 {
+		s.notify(fmt.Sprintf("[%s] Component status changed", s.config.Title), strings.Join(lines, "\n"))
+	}
+}
+
+// announce publishes an incident event and notifies subscribers
+func (s *Service) announce(ctx context.Context, event string, incident *Incident, message string) {
+	events.DispatchAsync(ctx, events.Event{
+		Name: event,
+		Data: map[string]interface{}{
+			"incident_id": incident.ID,
+			"kind":        incident.Kind,
+			"title":       incident.Title,
+			"status":      incident.Status,
+			"impact":      incident.Impact,
+		},
+	})
+
+	subject := fmt.Sprintf("[%s] %s: %s", s.config.Title, incident.Title, strings.ReplaceAll(incident.Status, "_", " "))
+	body := incident.Title + "\n\n"
+	if message != "" {
+		body += message + "\n"
+	}
+	s.notify(subject, body)
+}
+
+// notify emails every subscriber in the background
+func (s *Service) notify(subject, body string) {
+	if s.notifier == nil {
+		return
+	}
+
+	go func() {
+		ctx := context.Background()
+		err := s.repo.EachSubscriber(ctx, subscriberBatchSize, func(batch []Subscriber) error {
+			for _, sub := range batch {
+				text := body + fmt.Sprintf("\nView the status page: %s\nUnsubscribe: %s/unsubscribe/%s\n",
+					s.config.PageURL, strings.TrimRight(s.config.PageURL, "/"), sub.Token)
+				if err := s.notifier.SendEmail(ctx, sub.Email, subject, text); err != nil {
+					logger.Warn("Failed to notify status subscriber", logger.Fields{"error": err.Error()})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Error("Failed to load status subscribers", logger.Fields{"error": err.Error()})
+		}
+	}()
+}
+
+// ==================== Helpers ====================
+
+// applyIncidentInput copies input onto an incident, defaulting fields by kind
+func applyIncidentInput(incident *Incident, input *IncidentInput) error {
+	incident.Title = input.Title
+	incident.Impact = input.Impact
+	if incident.Impact == "" {
+		incident.Impact = ImpactMinor
+	}
+	incident.Components = strings.Join(input.Components, ",")
+	incident.ComponentList = input.Components
+
+	if input.StartsAt != nil {
+		incident.StartsAt = *input.StartsAt
+	} else if incident.StartsAt.IsZero() {
+		incident.StartsAt = time.Now()
+	}
+	incident.EndsAt = input.EndsAt
+	if incident.EndsAt != nil && !incident.EndsAt.After(incident.StartsAt) {
+		return errors.NewBadRequest("End time must be after start time")
+	}
+
+	if input.Status != "" {
+		incident.Status = input.Status
+	}
+	if incident.Status == "" {
+		incident.Status = IncidentInvestigating
+		if incident.Kind == KindMaintenance {
+			incident.Status = MaintenanceScheduled
+		}
+	}
+	if !validStatus(incident.Kind, incident.Status) {
+		return errors.NewBadRequest(fmt.Sprintf("Invalid status %q for %s", incident.Status, incident.Kind))
+	}
+	if incident.Resolved() && incident.ResolvedAt == nil {
+		now := time.Now()
+		incident.ResolvedAt = &now
+	}
+	return nil
+}
+
+func validStatus(kind, status string) bool {
+	if kind == KindMaintenance {
+		return maintenanceStatuses[status]
+	}
+	return incidentStatuses[status]
+}
+
+func decodeComponents(incident *Incident) {
+	incident.ComponentList = []string{}
+	if incident.Components != "" {
+		incident.ComponentList = strings.Split(incident.Components, ",")
+	}
+	sort.Strings(incident.ComponentList)
+}
+
+func uptimeOr100(uptime map[string]float64, component string) float64 {
+	if v, ok := uptime[component]; ok {
+		return v
+	}
+	return 100
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
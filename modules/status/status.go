@@ -0,0 +1,28 @@
+package status
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type StatusModule struct{}
+
+func New() *StatusModule {
+	return &StatusModule{}
+}
+
+func (m *StatusModule) Name() string {
+	return "status"
+}
+
+func (m *StatusModule) Init() {}
+
+func (m *StatusModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *StatusModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
@@ -32,7 +32,7 @@ func (ctrl *AuthController) Login(c *fiber.Ctx) error {
 		return err
 	}
 
-	ctx := context.Background()
+	ctx := auth.RequestContext(c)
 	
 	// Authenticate user
 	result, err := ctrl.authService.Login(ctx, req.Email, req.Password)
@@ -176,7 +176,7 @@ func (ctrl *AuthController) UpdateProfile(c *fiber.Ctx) error {
 		user.Email = req.Email
 	}
 
-	if err := ctrl.authService.userRepo.Update(ctx, user); err != nil {
+	if err := ctrl.authService.saveUser(ctx, user); err != nil {
 		return errors.NewInternal("Failed to update profile")
 	}
 
@@ -205,7 +205,7 @@ func (ctrl *AuthController) ChangePassword(c *fiber.Ctx) error {
 		return err
 	}
 
-	ctx := context.Background()
+	ctx := auth.RequestContext(c)
 	err := ctrl.authService.ChangePassword(ctx, userID, req.CurrentPassword, req.NewPassword)
 	if err != nil {
 		return err
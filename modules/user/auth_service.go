@@ -17,6 +17,7 @@ type AuthService struct {
 	jwtManager  *auth.JWTManager
 	hasher      *auth.PasswordHasher
 	rbacManager *rbac.Manager
+	cache       *userCache
 }
 
 // NewAuthService creates a new auth service
@@ -25,12 +26,14 @@ func NewAuthService(
 	jwtManager *auth.JWTManager,
 	hasher *auth.PasswordHasher,
 	rbacManager *rbac.Manager,
+	cache *userCache,
 ) *AuthService {
 	return &AuthService{
 		userRepo:    userRepo,
 		jwtManager:  jwtManager,
 		hasher:      hasher,
 		rbacManager: rbacManager,
+		cache:       cache,
 	}
 }
 
@@ -52,6 +55,11 @@ func (s *AuthService) Login(ctx context.Context, email, password string) (map[st
 		return nil, errors.New(errors.ErrCodeInvalidCredentials, "Invalid email or password", 401)
 	}
 
+	// Accounts moved to passkeys may have password login turned off
+	if !auth.PasswordLoginAllowed(ctx, user.ID) {
+		return nil, errors.New(errors.ErrCodeForbidden, "Password login is disabled for this account; sign in with a passkey", 403)
+	}
+
 	// Get user roles and permissions
 	roles, _ := s.rbacManager.GetUserRoles(ctx, user.ID)
 	permissions, _ := s.rbacManager.GetUserPermissions(ctx, user.ID)
@@ -87,15 +95,16 @@ func (s *AuthService) Login(ctx context.Context, email, password string) (map[st
 	// Update last login
 	now := time.Now()
 	user.LastLoginAt = &now
-	s.userRepo.Update(ctx, user)
+	s.saveUser(ctx, user)
 
-	// Dispatch login event
+	// Dispatch login event with the client, for the security log
+	data := auth.ClientFields(ctx)
+	data["user_id"] = user.ID
+	data["email"] = user.Email
+	data["method"] = "password"
 	events.DispatchAsync(ctx, events.Event{
 		Name: events.EventUserLoggedIn,
-		Data: map[string]interface{}{
-			"user_id": user.ID,
-			"email":   user.Email,
-		},
+		Data: data,
 	})
 
 	return map[string]interface{}{
@@ -197,7 +206,19 @@ func (s *AuthService) ChangePassword(ctx context.Context, userID uint, currentPa
 	}
 
 	user.Password = hashedPassword
-	return s.userRepo.Update(ctx, user)
+	if err := s.saveUser(ctx, user); err != nil {
+		return err
+	}
+
+	data := auth.ClientFields(ctx)
+	data["user_id"] = user.ID
+	data["email"] = user.Email
+	events.DispatchAsync(ctx, events.Event{
+		Name: events.EventUserPasswordChanged,
+		Data: data,
+	})
+
+	return nil
 }
 
 // GenerateAPIKey generates API key for user
@@ -213,9 +234,18 @@ func (s *AuthService) GenerateAPIKey(ctx context.Context, userID uint) (string,
 	}
 
 	user.APIKey = &apiKey
-	if err := s.userRepo.Update(ctx, user); err != nil {
+	if err := s.saveUser(ctx, user); err != nil {
 		return "", errors.NewInternal("Failed to save API key")
 	}
 
 	return apiKey, nil
 }
+
+// saveUser updates a user and drops its cached views
+func (s *AuthService) saveUser(ctx context.Context, user *User) error {
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	s.cache.invalidate(ctx, user.ID)
+	return nil
+}
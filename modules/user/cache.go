@@ -0,0 +1,75 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/contracts"
+	"neonexcore/pkg/logger"
+)
+
+const (
+	userCacheTTL = 5 * time.Minute
+
+	// usersTag is carried by every cached user view, to drop them all at once
+	usersTag = "users"
+)
+
+// userTag is carried by every cached view of one user, so an update drops
+// them all in one call
+func userTag(id uint) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+// userCache caches user lookups in the app cache. Only public profiles are
+// cached, never password hashes or API keys.
+type userCache struct {
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+// newUserCache creates a user cache over c, or its own in-memory cache
+// when c is nil
+func newUserCache(c cache.Cache) *userCache {
+	if c == nil {
+		c = cache.NewMemoryCache(cache.DefaultMemoryCacheConfig())
+	}
+	return &userCache{cache: c, ttl: userCacheTTL}
+}
+
+// byID returns the cached profile of a user, loading it on a miss
+func (uc *userCache) byID(ctx context.Context, id uint, load func() (*User, error)) (*contracts.UserInfo, error) {
+	return uc.get(ctx, fmt.Sprintf("user:id:%d", id), load)
+}
+
+// byEmail returns the cached profile of the user with an email, loading it
+// on a miss
+func (uc *userCache) byEmail(ctx context.Context, email string, load func() (*User, error)) (*contracts.UserInfo, error) {
+	return uc.get(ctx, "user:email:"+email, load)
+}
+
+func (uc *userCache) get(ctx context.Context, key string, load func() (*User, error)) (*contracts.UserInfo, error) {
+	var info contracts.UserInfo
+	if err := cache.GetInto(ctx, uc.cache, key, &info); err == nil {
+		return &info, nil
+	}
+
+	user, err := load()
+	if err != nil || user == nil {
+		return nil, err
+	}
+	profile := userInfo(user)
+	if err := uc.cache.Set(ctx, key, profile, uc.ttl, cache.WithTags(userTag(user.ID), usersTag)); err != nil {
+		logger.Warn("Failed to cache user", logger.Fields{"user_id": user.ID, "error": err.Error()})
+	}
+	return profile, nil
+}
+
+// invalidate drops every cached view of a user
+func (uc *userCache) invalidate(ctx context.Context, id uint) {
+	if err := uc.cache.InvalidateTag(ctx, userTag(id)); err != nil {
+		logger.Warn("Failed to invalidate cached user", logger.Fields{"user_id": id, "error": err.Error()})
+	}
+}
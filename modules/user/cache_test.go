@@ -0,0 +1,97 @@
+package user
+
+import (
+	"context"
+	"testing"
+
+	"neonexcore/pkg/cache"
+)
+
+func TestUserCacheInvalidatesEveryViewOfAUser(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewMemoryCache(cache.DefaultMemoryCacheConfig())
+	uc := newUserCache(store)
+
+	users := map[uint]*User{
+		1: {ID: 1, Name: "Owner", Email: "owner@example.com"},
+		2: {ID: 2, Name: "Other", Email: "other@example.com"},
+	}
+	loads := 0
+	byID := func(id uint) string {
+		info, err := uc.byID(ctx, id, func() (*User, error) {
+			loads++
+			user := *users[id]
+			return &user, nil
+		})
+		if err != nil {
+			t.Fatalf("byID(%d): %v", id, err)
+		}
+		return info.Name
+	}
+	byEmail := func(email string) string {
+		info, err := uc.byEmail(ctx, email, func() (*User, error) {
+			loads++
+			for _, user := range users {
+				if user.Email == email {
+					copied := *user
+					return &copied, nil
+				}
+			}
+			return nil, nil
+		})
+		if err != nil {
+			t.Fatalf("byEmail(%s): %v", email, err)
+		}
+		return info.Name
+	}
+
+	byID(1)
+	byEmail("owner@example.com")
+	byID(2)
+	byID(1)
+	byEmail("owner@example.com")
+	if loads != 3 {
+		t.Fatalf("loads = %d, want 3", loads)
+	}
+
+	users[1].Name = "Renamed"
+	uc.invalidate(ctx, 1)
+	if name := byID(1); name != "Renamed" {
+		t.Errorf("by ID after invalidating = %q, want Renamed", name)
+	}
+	if name := byEmail("owner@example.com"); name != "Renamed" {
+		t.Errorf("by email after invalidating = %q, want Renamed", name)
+	}
+	byID(2)
+	if loads != 5 {
+		t.Errorf("loads = %d, want 5; only user 1's views should be reloaded", loads)
+	}
+
+	if err := store.InvalidateTag(ctx, usersTag); err != nil {
+		t.Fatalf("InvalidateTag: %v", err)
+	}
+	byID(2)
+	if loads != 6 {
+		t.Errorf("loads = %d, want 6 after invalidating every user", loads)
+	}
+}
+
+func TestUserCacheSkipsMissingUsers(t *testing.T) {
+	ctx := context.Background()
+	uc := newUserCache(nil)
+
+	loads := 0
+	for i := 0; i < 2; i++ {
+		info, err := uc.byEmail(ctx, "nobody@example.com", func() (*User, error) {
+			loads++
+			return nil, nil
+		})
+		if err != nil || info != nil {
+			t.Fatalf("byEmail = %v, %v; want nil, nil", info, err)
+		}
+	}
+	// A user created later must be found, so misses are not cached
+	if loads != 2 {
+		t.Errorf("loads = %d, want 2", loads)
+	}
+}
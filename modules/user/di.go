@@ -6,7 +6,12 @@ import (
 	"neonexcore/internal/config"
 	"neonexcore/internal/core"
 	"neonexcore/pkg/auth"
+	"neonexcore/pkg/auth/lockout"
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/contracts"
 	"neonexcore/pkg/database"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/notification"
 	"neonexcore/pkg/rbac"
 )
 
@@ -37,6 +42,24 @@ func (m *UserModule) RegisterServices(c *core.Container) {
 		return auth.NewPasswordHasher(12) // bcrypt cost
 	}, core.Singleton)
 
+	// Register Login Guard; attempts are tracked in the shared cache, so
+	// limits hold across instances when it is Redis or memcached
+	c.Provide(func() *lockout.Guard {
+		captcha, err := lockout.LoadCaptchaVerifier()
+		if err != nil {
+			logger.Error("Invalid CAPTCHA configuration; CAPTCHA is disabled", logger.Fields{"error": err.Error()})
+		}
+
+		var notifier lockout.Notifier
+		if manager := core.Resolve[*notification.Manager](c); manager != nil {
+			notifier = manager
+		}
+
+		// Without a registered cache the guard keeps its own, in memory
+		store := core.Resolve[cache.Cache](c)
+		return lockout.NewGuard(lockout.LoadConfig(), store, captcha, notifier)
+	}, core.Singleton)
+
 	// ==================== RBAC ====================
 	
 	// Register RBAC Manager
@@ -54,12 +77,18 @@ func (m *UserModule) RegisterServices(c *core.Container) {
 	}, core.Singleton)
 
 	// ==================== Services ====================
+
+	// Register User Cache; lookups are cached in the shared cache, so an
+	// update on one instance drops them on every instance
+	c.Provide(func() *userCache {
+		return newUserCache(core.Resolve[cache.Cache](c))
+	}, core.Singleton)
 	
 	// Register User Service
 	c.Provide(func() *UserService {
 		repo := core.Resolve[*UserRepository](c)
 		txManager := core.Resolve[*database.TxManager](c)
-		return NewUserService(repo, txManager)
+		return NewUserService(repo, txManager, core.Resolve[*userCache](c))
 	}, core.Singleton)
 
 	// Register Auth Service
@@ -68,9 +97,16 @@ func (m *UserModule) RegisterServices(c *core.Container) {
 		jwtManager := core.Resolve[*auth.JWTManager](c)
 		hasher := core.Resolve[*auth.PasswordHasher](c)
 		rbacManager := core.Resolve[*rbac.Manager](c)
-		return NewAuthService(userRepo, jwtManager, hasher, rbacManager)
+		return NewAuthService(userRepo, jwtManager, hasher, rbacManager, core.Resolve[*userCache](c))
 	}, core.Singleton)
 
+	// ==================== Published Services ====================
+
+	// Publish user lookups to other modules
+	core.ProvideService(c, m.Name(), contracts.UserLookupVersion, func() contracts.UserLookup {
+		return NewLookup(core.Resolve[*UserRepository](c), core.Resolve[*userCache](c))
+	})
+
 	// ==================== Controllers ====================
 	
 	// Register Auth Controller
@@ -0,0 +1,47 @@
+package user
+
+import (
+	"context"
+
+	"neonexcore/pkg/contracts"
+)
+
+// Lookup implements contracts.UserLookup, published for other modules.
+// Found users are served from the user cache.
+type Lookup struct {
+	repo  *UserRepository
+	cache *userCache
+}
+
+// NewLookup creates the user lookup service
+func NewLookup(repo *UserRepository, cache *userCache) *Lookup {
+	return &Lookup{repo: repo, cache: cache}
+}
+
+// LookupUser implements contracts.UserLookup
+func (l *Lookup) LookupUser(ctx context.Context, id uint) (*contracts.UserInfo, error) {
+	return l.cache.byID(ctx, id, func() (*User, error) {
+		return l.repo.FindByID(ctx, id)
+	})
+}
+
+// LookupUserByEmail implements contracts.UserLookup
+func (l *Lookup) LookupUserByEmail(ctx context.Context, email string) (*contracts.UserInfo, error) {
+	return l.cache.byEmail(ctx, email, func() (*User, error) {
+		return l.repo.FindByEmail(ctx, email)
+	})
+}
+
+// userInfo returns the public profile of a user, nil for none
+func userInfo(user *User) *contracts.UserInfo {
+	if user == nil {
+		return nil
+	}
+	return &contracts.UserInfo{
+		ID:       user.ID,
+		Name:     user.Name,
+		Email:    user.Email,
+		Username: user.Username,
+		Active:   user.IsActive,
+	}
+}
@@ -3,6 +3,7 @@ package user
 import (
 	"neonexcore/internal/core"
 	"neonexcore/pkg/auth"
+	"neonexcore/pkg/auth/lockout"
 	"neonexcore/pkg/rbac"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,6 +17,7 @@ func (m *UserModule) Routes(app *fiber.App, c *core.Container) {
 	// Resolve middleware dependencies
 	jwtManager := core.Resolve[*auth.JWTManager](c)
 	rbacManager := core.Resolve[*rbac.Manager](c)
+	loginGuard := core.Resolve[*lockout.Guard](c)
 
 	// API v1 group
 	api := app.Group("/api/v1")
@@ -24,7 +26,7 @@ func (m *UserModule) Routes(app *fiber.App, c *core.Container) {
 	authGroup := api.Group("/auth")
 	{
 		// Public auth endpoints
-		authGroup.Post("/login", authCtrl.Login)
+		authGroup.Post("/login", lockout.Protect(loginGuard, "email"), authCtrl.Login)
 		authGroup.Post("/register", authCtrl.Register)
 		authGroup.Post("/refresh", authCtrl.RefreshToken)
 		authGroup.Post("/forgot-password", authCtrl.ForgotPassword)
@@ -36,8 +38,8 @@ func (m *UserModule) Routes(app *fiber.App, c *core.Container) {
 		authProtected.Post("/logout", authCtrl.Logout)
 		authProtected.Get("/profile", authCtrl.GetProfile)
 		authProtected.Put("/profile", authCtrl.UpdateProfile)
-		authProtected.Post("/change-password", authCtrl.ChangePassword)
-		authProtected.Post("/api-key", authCtrl.GenerateAPIKey)
+		authProtected.Post("/change-password", auth.RequireStepUp(), authCtrl.ChangePassword)
+		authProtected.Post("/api-key", auth.RequireStepUp(), authCtrl.GenerateAPIKey)
 	}
 
 	// ==================== User Management Routes ====================
@@ -84,6 +86,7 @@ func (m *UserModule) Routes(app *fiber.App, c *core.Container) {
 			)
 			usersProtected.Post("/:id/roles",
 				rbac.RequirePermission(rbacManager, "users.manage-roles"),
+				auth.RequireStepUp(),
 				userCtrl.AssignRole,
 			)
 			usersProtected.Delete("/:id/roles/:roleId",
@@ -12,12 +12,14 @@ import (
 type UserService struct {
 	repo      *UserRepository
 	txManager *database.TxManager
+	cache     *userCache
 }
 
-func NewUserService(repo *UserRepository, txManager *database.TxManager) *UserService {
+func NewUserService(repo *UserRepository, txManager *database.TxManager, cache *userCache) *UserService {
 	return &UserService{
 		repo:      repo,
 		txManager: txManager,
+		cache:     cache,
 	}
 }
 
@@ -36,14 +38,22 @@ func (s *UserService) CreateUser(ctx context.Context, user *User) error {
 	return s.repo.Create(ctx, user)
 }
 
-// UpdateUser updates a user
+// UpdateUser updates a user and drops its cached views
 func (s *UserService) UpdateUser(ctx context.Context, user *User) error {
-	return s.repo.Update(ctx, user)
+	if err := s.repo.Update(ctx, user); err != nil {
+		return err
+	}
+	s.cache.invalidate(ctx, user.ID)
+	return nil
 }
 
-// DeleteUser deletes a user
+// DeleteUser deletes a user and drops its cached views
 func (s *UserService) DeleteUser(ctx context.Context, id uint) error {
-	return s.repo.Delete(ctx, id)
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.cache.invalidate(ctx, id)
+	return nil
 }
 
 // GetUserByEmail retrieves a user by email
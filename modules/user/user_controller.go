@@ -222,7 +222,7 @@ func (ctrl *UserController) Update(c *fiber.Ctx) error {
 		user.Active = *req.IsActive
 	}
 
-	if err := ctrl.service.repo.Update(ctx, user); err != nil {
+	if err := ctrl.service.UpdateUser(ctx, user); err != nil {
 		return errors.NewInternal("Failed to update user")
 	}
 
@@ -268,7 +268,7 @@ func (ctrl *UserController) Delete(c *fiber.Ctx) error {
 		return errors.NewNotFound("User not found")
 	}
 
-	if err := ctrl.service.repo.Delete(ctx, uint(id)); err != nil {
+	if err := ctrl.service.DeleteUser(ctx, uint(id)); err != nil {
 		return errors.NewInternal("Failed to delete user")
 	}
 
@@ -328,7 +328,7 @@ func (ctrl *UserController) AssignRole(c *fiber.Ctx) error {
 		return errors.NewBadRequest("Invalid request body")
 	}
 
-	ctx := context.Background()
+	ctx := auth.RequestContext(c)
 	
 	// Check if user exists
 	user, err := ctrl.service.repo.FindByID(ctx, uint(userID))
@@ -341,6 +341,18 @@ func (ctrl *UserController) AssignRole(c *fiber.Ctx) error {
 		return errors.NewInternal("Failed to assign role")
 	}
 
+	data := auth.ClientFields(ctx)
+	data["user_id"] = user.ID
+	data["email"] = user.Email
+	data["role_id"] = req.RoleID
+	if grantedBy, ok := auth.GetUserID(c); ok {
+		data["granted_by"] = grantedBy
+	}
+	events.DispatchAsync(ctx, events.Event{
+		Name: events.EventUserRoleAssigned,
+		Data: data,
+	})
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Role assigned successfully",
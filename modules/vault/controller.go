@@ -0,0 +1,127 @@
+package vault
+
+import (
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PutSecretInput is the payload for storing a secret
+type PutSecretInput struct {
+	Value string `json:"value" validate:"required"`
+}
+
+// Controller exposes the caller's own secrets in the user namespace.
+// Values can be written but are never returned over HTTP.
+type Controller struct {
+	service *Service
+}
+
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// List lists the caller's secrets
+// @Summary List secrets
+// @Description List the names of the caller's secrets, without values
+// @Tags Vault
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response{data=[]SecretInfo}
+// @Router /vault/secrets [get]
+func (c *Controller) List(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	secrets, err := c.service.For(NamespaceUser).List(ctx.UserContext(), userID)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, secrets)
+}
+
+// Put stores a secret of the caller
+// @Summary Store secret
+// @Description Store or replace a secret, such as an exchange API key
+// @Tags Vault
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param name path string true "Secret name"
+// @Param secret body PutSecretInput true "Secret"
+// @Success 200 {object} api.Response
+// @Failure 400 {object} api.Response
+// @Router /vault/secrets/{name} [put]
+func (c *Controller) Put(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+
+	var input PutSecretInput
+	if err := validation.ValidateBody(ctx, &input); err != nil {
+		return api.RespondError(ctx, err)
+	}
+
+	if err := c.service.For(NamespaceUser).Put(ctx.UserContext(), userID, ctx.Params("name"), []byte(input.Value)); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Secret stored", nil)
+}
+
+// Delete deletes a secret of the caller
+// @Summary Delete secret
+// @Tags Vault
+// @Security BearerAuth
+// @Produce json
+// @Param name path string true "Secret name"
+// @Success 200 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /vault/secrets/{name} [delete]
+func (c *Controller) Delete(ctx *fiber.Ctx) error {
+	userID, _ := auth.GetUserID(ctx)
+	if err := c.service.For(NamespaceUser).Delete(ctx.UserContext(), userID, ctx.Params("name")); err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.SuccessWithMessage(ctx, "Secret deleted", nil)
+}
+
+// AccessLogs lists the vault access log
+// @Summary List vault access log
+// @Tags Vault
+// @Security BearerAuth
+// @Produce json
+// @Param owner_id query int false "Owner user ID"
+// @Param namespace query string false "Namespace"
+// @Param action query string false "Action (put, get, delete, list, rotate)"
+// @Param status query string false "Status (success, not_found, failed)"
+// @Success 200 {object} api.Response{data=[]AccessLog}
+// @Router /vault/audit [get]
+func (c *Controller) AccessLogs(ctx *fiber.Ctx) error {
+	pagination := api.GetPagination(ctx)
+	filter := AccessLogFilter{
+		OwnerID:   uint(max(ctx.QueryInt("owner_id"), 0)),
+		Namespace: ctx.Query("namespace"),
+		Action:    ctx.Query("action"),
+		Status:    ctx.Query("status"),
+	}
+
+	logs, total, err := c.service.AccessLogs(ctx.UserContext(), filter, pagination.Page, pagination.Limit)
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Paginated(ctx, logs, pagination.Page, pagination.Limit, total)
+}
+
+// RotateKey rotates the tenant's data key
+// @Summary Rotate data key
+// @Description Activate a new data key for the tenant and re-encrypt its secrets
+// @Tags Vault
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} api.Response
+// @Router /vault/keys/rotate [post]
+func (c *Controller) RotateKey(ctx *fiber.Ctx) error {
+	version, reencrypted, err := c.service.RotateDataKey(ctx.UserContext())
+	if err != nil {
+		return api.RespondError(ctx, err)
+	}
+	return api.Success(ctx, fiber.Map{"version": version, "reencrypted": reencrypted})
+}
@@ -0,0 +1,89 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// keySize is the size of master and data keys (AES-256)
+const keySize = 32
+
+// ParseMasterKey decodes a base64 master key of 32 bytes
+func ParseMasterKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("vault: master key is not valid base64: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("vault: master key must be %d bytes, got %d", keySize, len(key))
+	}
+	return key, nil
+}
+
+// masterKeyID fingerprints a master key, so data keys wrapped by another
+// master key are reported as such instead of failing to decrypt
+func masterKeyID(key []byte) string {
+	sum := sha256.Sum256(append([]byte("neonex-vault:"), key...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// newDataKey generates a random data key
+func newDataKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// seal encrypts plaintext with AES-GCM, binding it to aad
+func seal(key, plaintext, aad []byte) (ciphertext, nonce []byte, err error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return gcm.Seal(nil, nonce, plaintext, aad), nonce, nil
+}
+
+// open decrypts a ciphertext sealed with the same key and aad
+func open(key, ciphertext, nonce, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("vault: invalid nonce")
+	}
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// dataKeyAAD binds a wrapped data key to its tenant and version
+func dataKeyAAD(tenantID string, version int) []byte {
+	return []byte("key\x00" + tenantID + "\x00" + strconv.Itoa(version))
+}
+
+// secretAAD binds a ciphertext to its row, so values cannot be swapped
+// between owners, names or tenants in the table
+func secretAAD(s *Secret) []byte {
+	return []byte("secret\x00" + s.TenantID + "\x00" + strconv.FormatUint(uint64(s.OwnerID), 10) +
+		"\x00" + s.Namespace + "\x00" + s.Name + "\x00" + strconv.Itoa(s.KeyVersion))
+}
@@ -0,0 +1,42 @@
+package vault
+
+import (
+	"os"
+	"strconv"
+
+	"neonexcore/internal/core"
+	"neonexcore/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+func RegisterDependencies(container *core.Container, db *gorm.DB) {
+	// Register Repository
+	container.Provide(func() *Repository {
+		return NewRepository(db)
+	}, core.Singleton)
+
+	// Register Service; without a master key every access fails
+	container.Provide(func() *Service {
+		config := DefaultConfig()
+		if encoded := os.Getenv("VAULT_MASTER_KEY"); encoded != "" {
+			key, err := ParseMasterKey(encoded)
+			if err != nil {
+				logger.Error("Invalid vault master key", logger.Fields{"error": err.Error()})
+			}
+			config.MasterKey = key
+		} else {
+			logger.Warn("VAULT_MASTER_KEY is not set; the vault is disabled")
+		}
+		if size, err := strconv.Atoi(os.Getenv("VAULT_MAX_SECRET_SIZE")); err == nil && size > 0 {
+			config.MaxSecretSize = size
+		}
+
+		return NewService(core.Resolve[*Repository](container), config)
+	}, core.Singleton)
+
+	// Register Controller
+	container.Provide(func() *Controller {
+		return NewController(core.Resolve[*Service](container))
+	}, core.Transient)
+}
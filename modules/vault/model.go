@@ -0,0 +1,108 @@
+package vault
+
+import "time"
+
+// Access log actions
+const (
+	ActionPut    = "put"
+	ActionGet    = "get"
+	ActionDelete = "delete"
+	ActionList   = "list"
+	ActionRotate = "rotate"
+)
+
+// Access log statuses
+const (
+	StatusSuccess  = "success"
+	StatusNotFound = "not_found"
+	StatusFailed   = "failed"
+)
+
+// DataKey is a tenant's data encryption key, stored wrapped by the master
+// key. Rotation adds a version; older versions stay to decrypt secrets not
+// yet re-encrypted.
+type DataKey struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	TenantID    string     `gorm:"size:100;uniqueIndex:idx_vault_keys_tenant_version;not null" json:"tenant_id"`
+	Version     int        `gorm:"uniqueIndex:idx_vault_keys_tenant_version;not null" json:"version"`
+	WrappedKey  []byte     `gorm:"not null" json:"-"`
+	Nonce       []byte     `gorm:"not null" json:"-"`
+	MasterKeyID string     `gorm:"size:16;not null" json:"master_key_id"` // Fingerprint of the wrapping master key
+	Active      bool       `gorm:"index" json:"active"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+}
+
+// TableName specifies the table name for DataKey
+func (DataKey) TableName() string {
+	return "vault_data_keys"
+}
+
+// Secret is an encrypted value owned by a user, in the namespace of the
+// module that stored it
+type Secret struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	TenantID       string     `gorm:"size:100;uniqueIndex:idx_vault_secrets_key;not null" json:"tenant_id"`
+	OwnerID        uint       `gorm:"uniqueIndex:idx_vault_secrets_key;not null" json:"owner_id"`
+	Namespace      string     `gorm:"size:64;uniqueIndex:idx_vault_secrets_key;not null" json:"namespace"`
+	Name           string     `gorm:"size:128;uniqueIndex:idx_vault_secrets_key;not null" json:"name"`
+	Ciphertext     []byte     `gorm:"not null" json:"-"`
+	Nonce          []byte     `gorm:"not null" json:"-"`
+	KeyVersion     int        `gorm:"index;not null" json:"key_version"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for Secret
+func (Secret) TableName() string {
+	return "vault_secrets"
+}
+
+// Info returns the secret's metadata, without its value
+func (s *Secret) Info() SecretInfo {
+	return SecretInfo{
+		Namespace:      s.Namespace,
+		Name:           s.Name,
+		KeyVersion:     s.KeyVersion,
+		LastAccessedAt: s.LastAccessedAt,
+		CreatedAt:      s.CreatedAt,
+		UpdatedAt:      s.UpdatedAt,
+	}
+}
+
+// SecretInfo describes a stored secret. Values are never listed.
+type SecretInfo struct {
+	Namespace      string     `json:"namespace"`
+	Name           string     `json:"name"`
+	KeyVersion     int        `json:"key_version"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// AccessLog records an access to the vault
+type AccessLog struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	TenantID  string    `gorm:"size:100;index" json:"tenant_id"`
+	OwnerID   uint      `gorm:"index" json:"owner_id"`
+	Namespace string    `gorm:"size:64;index" json:"namespace"` // Module that made the access
+	Name      string    `gorm:"size:128" json:"name,omitempty"`
+	Action    string    `gorm:"size:20;index" json:"action"`
+	Status    string    `gorm:"size:20;index" json:"status"`
+	Error     string    `gorm:"size:255" json:"error,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name for AccessLog
+func (AccessLog) TableName() string {
+	return "vault_access_logs"
+}
+
+// AccessLogFilter filters access logs
+type AccessLogFilter struct {
+	OwnerID   uint
+	Namespace string
+	Action    string
+	Status    string
+}
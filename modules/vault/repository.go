@@ -0,0 +1,156 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ==================== Data Keys ====================
+
+// ActiveDataKey returns the tenant's current data key, or nil
+func (r *Repository) ActiveDataKey(ctx context.Context, tenantID string) (*DataKey, error) {
+	var key DataKey
+	err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND active = ?", tenantID, true).
+		Order("version DESC").
+		First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// DataKeyVersion returns a version of the tenant's data key, or nil
+func (r *Repository) DataKeyVersion(ctx context.Context, tenantID string, version int) (*DataKey, error) {
+	var key DataKey
+	err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND version = ?", tenantID, version).
+		First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// CreateDataKey stores a new data key version. The unique index on tenant
+// and version makes concurrent creation of the same version fail.
+func (r *Repository) CreateDataKey(ctx context.Context, key *DataKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+// ActivateDataKey creates a new active version and retires the previous
+// ones, in one transaction
+func (r *Repository) ActivateDataKey(ctx context.Context, key *DataKey) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(key).Error; err != nil {
+			return err
+		}
+		return tx.Model(&DataKey{}).
+			Where("tenant_id = ? AND version < ? AND active = ?", key.TenantID, key.Version, true).
+			Updates(map[string]interface{}{"active": false, "retired_at": time.Now()}).Error
+	})
+}
+
+// ==================== Secrets ====================
+
+func (r *Repository) FindSecret(ctx context.Context, tenantID string, ownerID uint, namespace, name string) (*Secret, error) {
+	var secret Secret
+	err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND owner_id = ? AND namespace = ? AND name = ?", tenantID, ownerID, namespace, name).
+		First(&secret).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &secret, nil
+}
+
+func (r *Repository) ListSecrets(ctx context.Context, tenantID string, ownerID uint, namespace string) ([]Secret, error) {
+	var secrets []Secret
+	err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND owner_id = ? AND namespace = ?", tenantID, ownerID, namespace).
+		Order("name ASC").
+		Find(&secrets).Error
+	return secrets, err
+}
+
+// SecretsBelowVersion returns a batch of the tenant's secrets encrypted
+// with a data key older than version
+func (r *Repository) SecretsBelowVersion(ctx context.Context, tenantID string, version int, afterID uint, limit int) ([]Secret, error) {
+	var secrets []Secret
+	err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND key_version < ? AND id > ?", tenantID, version, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&secrets).Error
+	return secrets, err
+}
+
+func (r *Repository) SaveSecret(ctx context.Context, secret *Secret) error {
+	return r.db.WithContext(ctx).Save(secret).Error
+}
+
+func (r *Repository) TouchSecret(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&Secret{}).Where("id = ?", id).
+		UpdateColumn("last_accessed_at", at).Error
+}
+
+// DeleteSecret removes a secret permanently and reports whether it existed
+func (r *Repository) DeleteSecret(ctx context.Context, tenantID string, ownerID uint, namespace, name string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND owner_id = ? AND namespace = ? AND name = ?", tenantID, ownerID, namespace, name).
+		Delete(&Secret{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ==================== Access Logs ====================
+
+func (r *Repository) CreateAccessLog(ctx context.Context, log *AccessLog) error {
+	return r.db.WithContext(ctx).Create(log).Error
+}
+
+func (r *Repository) ListAccessLogs(ctx context.Context, tenantID string, filter AccessLogFilter, page, limit int) ([]AccessLog, int64, error) {
+	var logs []AccessLog
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&AccessLog{}).Where("tenant_id = ?", tenantID)
+	if filter.OwnerID != 0 {
+		query = query.Where("owner_id = ?", filter.OwnerID)
+	}
+	if filter.Namespace != "" {
+		query = query.Where("namespace = ?", filter.Namespace)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&logs).Error
+	return logs, total, err
+}
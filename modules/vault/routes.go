@@ -0,0 +1,27 @@
+package vault
+
+import (
+	"neonexcore/internal/core"
+	"neonexcore/pkg/auth"
+	"neonexcore/pkg/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupRoutes(router fiber.Router, container *core.Container) {
+	// Get dependencies
+	controller := core.Resolve[*Controller](container)
+	jwtManager := core.Resolve[*auth.JWTManager](container)
+	rbacManager := core.Resolve[*rbac.Manager](container)
+
+	vault := router.Group("/vault", auth.AuthMiddleware(jwtManager))
+
+	// ==================== Own Secrets ====================
+	vault.Get("/secrets", controller.List)
+	vault.Put("/secrets/:name", controller.Put)
+	vault.Delete("/secrets/:name", controller.Delete)
+
+	// ==================== Administration ====================
+	vault.Get("/audit", rbac.RequirePermission(rbacManager, "vault.audit.read"), controller.AccessLogs)
+	vault.Post("/keys/rotate", rbac.RequirePermission(rbacManager, "vault.keys.manage"), controller.RotateKey)
+}
@@ -0,0 +1,474 @@
+package vault
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/tenancy"
+)
+
+// DefaultTenant owns secrets stored outside a tenant context
+const DefaultTenant = "default"
+
+// NamespaceUser holds the secrets users manage themselves over the API
+const NamespaceUser = "user"
+
+// rotateBatchSize is the number of secrets re-encrypted per query when a
+// data key is rotated
+const rotateBatchSize = 100
+
+// namePattern restricts namespaces and secret names
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Config holds vault configuration
+type Config struct {
+	MasterKey     []byte // 32-byte key wrapping the tenants' data keys
+	MaxSecretSize int    // Largest value accepted, in bytes
+}
+
+// DefaultConfig returns default vault configuration
+func DefaultConfig() Config {
+	return Config{
+		MaxSecretSize: 4096,
+	}
+}
+
+// Service stores secrets encrypted with per-tenant data keys and audits
+// every access. Modules use it through a namespaced Vault from For.
+type Service struct {
+	repo     *Repository
+	config   Config
+	masterID string
+	keys     map[string][]byte // Unwrapped data keys by tenant and version
+	mu       sync.RWMutex
+}
+
+func NewService(repo *Repository, config Config) *Service {
+	if config.MaxSecretSize <= 0 {
+		config.MaxSecretSize = DefaultConfig().MaxSecretSize
+	}
+
+	s := &Service{
+		repo:   repo,
+		config: config,
+		keys:   make(map[string][]byte),
+	}
+	if len(config.MasterKey) == keySize {
+		s.masterID = masterKeyID(config.MasterKey)
+	}
+	return s
+}
+
+// Configured reports whether a valid master key is set
+func (s *Service) Configured() bool {
+	return s.masterID != ""
+}
+
+// Vault is a module's handle on the vault. Secrets are scoped to the
+// module's namespace, the owning user and the tenant in the context, and
+// every access is recorded in the access log.
+type Vault struct {
+	service   *Service
+	namespace string
+}
+
+// For returns the vault of a namespace, normally the calling module's name
+func (s *Service) For(namespace string) *Vault {
+	return &Vault{service: s, namespace: namespace}
+}
+
+// Put stores or replaces a secret of a user
+func (v *Vault) Put(ctx context.Context, ownerID uint, name string, value []byte) error {
+	return v.service.put(ctx, v.namespace, ownerID, name, value)
+}
+
+// Get returns the value of a secret of a user. IsNotFound reports a missing
+// secret.
+func (v *Vault) Get(ctx context.Context, ownerID uint, name string) ([]byte, error) {
+	return v.service.get(ctx, v.namespace, ownerID, name)
+}
+
+// Delete removes a secret of a user
+func (v *Vault) Delete(ctx context.Context, ownerID uint, name string) error {
+	return v.service.delete(ctx, v.namespace, ownerID, name)
+}
+
+// List describes the secrets of a user, without their values
+func (v *Vault) List(ctx context.Context, ownerID uint) ([]SecretInfo, error) {
+	return v.service.list(ctx, v.namespace, ownerID)
+}
+
+// IsNotFound reports whether err is a missing secret
+func IsNotFound(err error) bool {
+	appErr, ok := errors.GetAppError(err)
+	return ok && appErr.Code == errors.ErrCodeNotFound
+}
+
+// ==================== Secrets ====================
+
+func (s *Service) put(ctx context.Context, namespace string, ownerID uint, name string, value []byte) error {
+	tenantID := tenantFromContext(ctx)
+	entry := &AccessLog{TenantID: tenantID, OwnerID: ownerID, Namespace: namespace, Name: name, Action: ActionPut}
+
+	if err := s.validate(namespace, ownerID, name); err != nil {
+		return s.audit(ctx, entry, err)
+	}
+	if len(value) > s.config.MaxSecretSize {
+		return s.audit(ctx, entry, errors.NewBadRequest("Secret exceeds "+strconv.Itoa(s.config.MaxSecretSize)+" bytes"))
+	}
+
+	key, err := s.activeKey(ctx, tenantID)
+	if err != nil {
+		return s.audit(ctx, entry, err)
+	}
+
+	secret, err := s.repo.FindSecret(ctx, tenantID, ownerID, namespace, name)
+	if err != nil {
+		return s.audit(ctx, entry, errors.NewInternal("Failed to load secret").WithError(err))
+	}
+	if secret == nil {
+		secret = &Secret{TenantID: tenantID, OwnerID: ownerID, Namespace: namespace, Name: name}
+	}
+
+	if err := s.encrypt(secret, key, value); err != nil {
+		return s.audit(ctx, entry, err)
+	}
+	if err := s.repo.SaveSecret(ctx, secret); err != nil {
+		return s.audit(ctx, entry, errors.NewInternal("Failed to store secret").WithError(err))
+	}
+	return s.audit(ctx, entry, nil)
+}
+
+func (s *Service) get(ctx context.Context, namespace string, ownerID uint, name string) ([]byte, error) {
+	tenantID := tenantFromContext(ctx)
+	entry := &AccessLog{TenantID: tenantID, OwnerID: ownerID, Namespace: namespace, Name: name, Action: ActionGet}
+
+	if err := s.validate(namespace, ownerID, name); err != nil {
+		return nil, s.audit(ctx, entry, err)
+	}
+
+	secret, err := s.repo.FindSecret(ctx, tenantID, ownerID, namespace, name)
+	if err != nil {
+		return nil, s.audit(ctx, entry, errors.NewInternal("Failed to load secret").WithError(err))
+	}
+	if secret == nil {
+		return nil, s.audit(ctx, entry, errors.NewNotFound("Secret not found"))
+	}
+
+	value, err := s.decrypt(ctx, secret)
+	if err != nil {
+		return nil, s.audit(ctx, entry, err)
+	}
+
+	if err := s.repo.TouchSecret(ctx, secret.ID, time.Now()); err != nil {
+		logger.Warn("Failed to record secret access", logger.Fields{"secret_id": secret.ID, "error": err.Error()})
+	}
+	return value, s.audit(ctx, entry, nil)
+}
+
+func (s *Service) delete(ctx context.Context, namespace string, ownerID uint, name string) error {
+	tenantID := tenantFromContext(ctx)
+	entry := &AccessLog{TenantID: tenantID, OwnerID: ownerID, Namespace: namespace, Name: name, Action: ActionDelete}
+
+	if err := s.validate(namespace, ownerID, name); err != nil {
+		return s.audit(ctx, entry, err)
+	}
+
+	deleted, err := s.repo.DeleteSecret(ctx, tenantID, ownerID, namespace, name)
+	if err != nil {
+		return s.audit(ctx, entry, errors.NewInternal("Failed to delete secret").WithError(err))
+	}
+	if !deleted {
+		return s.audit(ctx, entry, errors.NewNotFound("Secret not found"))
+	}
+	return s.audit(ctx, entry, nil)
+}
+
+func (s *Service) list(ctx context.Context, namespace string, ownerID uint) ([]SecretInfo, error) {
+	tenantID := tenantFromContext(ctx)
+	entry := &AccessLog{TenantID: tenantID, OwnerID: ownerID, Namespace: namespace, Action: ActionList}
+
+	if err := s.validateOwner(namespace, ownerID); err != nil {
+		return nil, s.audit(ctx, entry, err)
+	}
+
+	secrets, err := s.repo.ListSecrets(ctx, tenantID, ownerID, namespace)
+	if err != nil {
+		return nil, s.audit(ctx, entry, errors.NewInternal("Failed to list secrets").WithError(err))
+	}
+
+	infos := make([]SecretInfo, len(secrets))
+	for i := range secrets {
+		infos[i] = secrets[i].Info()
+	}
+	return infos, s.audit(ctx, entry, nil)
+}
+
+// validate checks the arguments of an access to a secret
+func (s *Service) validate(namespace string, ownerID uint, name string) error {
+	if err := s.validateOwner(namespace, ownerID); err != nil {
+		return err
+	}
+	if len(name) > 128 || !namePattern.MatchString(name) {
+		return errors.NewBadRequest("Invalid secret name")
+	}
+	return nil
+}
+
+// validateOwner checks the arguments of an access to a user's secrets
+func (s *Service) validateOwner(namespace string, ownerID uint) error {
+	if !s.Configured() {
+		return errors.NewInternal("Vault master key is not configured")
+	}
+	if ownerID == 0 {
+		return errors.NewBadRequest("Secret owner is required")
+	}
+	if len(namespace) > 64 || !namePattern.MatchString(namespace) {
+		return errors.NewBadRequest("Invalid vault namespace")
+	}
+	return nil
+}
+
+// ==================== Keys ====================
+
+// RotateDataKey activates a new data key for the tenant in the context and
+// re-encrypts the tenant's secrets with it. It returns the new version and
+// the number of secrets re-encrypted.
+func (s *Service) RotateDataKey(ctx context.Context) (int, int, error) {
+	tenantID := tenantFromContext(ctx)
+	entry := &AccessLog{TenantID: tenantID, Namespace: "vault", Action: ActionRotate}
+
+	if !s.Configured() {
+		return 0, 0, s.audit(ctx, entry, errors.NewInternal("Vault master key is not configured"))
+	}
+
+	current, err := s.repo.ActiveDataKey(ctx, tenantID)
+	if err != nil {
+		return 0, 0, s.audit(ctx, entry, errors.NewInternal("Failed to load data key").WithError(err))
+	}
+	version := 1
+	if current != nil {
+		version = current.Version + 1
+	}
+
+	key, err := s.createDataKey(ctx, tenantID, version, true)
+	if err != nil {
+		return 0, 0, s.audit(ctx, entry, err)
+	}
+
+	reencrypted := 0
+	var afterID uint
+	for {
+		secrets, err := s.repo.SecretsBelowVersion(ctx, tenantID, version, afterID, rotateBatchSize)
+		if err != nil {
+			return version, reencrypted, s.audit(ctx, entry, errors.NewInternal("Failed to load secrets").WithError(err))
+		}
+		for i := range secrets {
+			secret := &secrets[i]
+			afterID = secret.ID
+
+			value, err := s.decrypt(ctx, secret)
+			if err != nil {
+				logger.Error("Failed to decrypt secret for rotation", logger.Fields{"secret_id": secret.ID, "error": err.Error()})
+				continue
+			}
+			if err := s.encrypt(secret, key, value); err != nil {
+				return version, reencrypted, s.audit(ctx, entry, err)
+			}
+			if err := s.repo.SaveSecret(ctx, secret); err != nil {
+				return version, reencrypted, s.audit(ctx, entry, errors.NewInternal("Failed to store secret").WithError(err))
+			}
+			reencrypted++
+		}
+		if len(secrets) < rotateBatchSize {
+			break
+		}
+	}
+
+	entry.Name = "v" + strconv.Itoa(version)
+	return version, reencrypted, s.audit(ctx, entry, nil)
+}
+
+// activeKey returns the tenant's active data key, creating the first one
+func (s *Service) activeKey(ctx context.Context, tenantID string) (*tenantKey, error) {
+	record, err := s.repo.ActiveDataKey(ctx, tenantID)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load data key").WithError(err)
+	}
+	if record != nil {
+		return s.unwrap(record)
+	}
+
+	key, err := s.createDataKey(ctx, tenantID, 1, false)
+	if err == nil {
+		return key, nil
+	}
+	// Another request may have created the first key concurrently
+	if record, lookupErr := s.repo.ActiveDataKey(ctx, tenantID); lookupErr == nil && record != nil {
+		return s.unwrap(record)
+	}
+	return nil, err
+}
+
+// createDataKey generates, wraps and stores a data key version. With
+// activate, older versions are retired.
+func (s *Service) createDataKey(ctx context.Context, tenantID string, version int, activate bool) (*tenantKey, error) {
+	raw, err := newDataKey()
+	if err != nil {
+		return nil, errors.NewInternal("Failed to generate data key").WithError(err)
+	}
+	wrapped, nonce, err := seal(s.config.MasterKey, raw, dataKeyAAD(tenantID, version))
+	if err != nil {
+		return nil, errors.NewInternal("Failed to wrap data key").WithError(err)
+	}
+
+	record := &DataKey{
+		TenantID:    tenantID,
+		Version:     version,
+		WrappedKey:  wrapped,
+		Nonce:       nonce,
+		MasterKeyID: s.masterID,
+		Active:      true,
+	}
+	if activate {
+		err = s.repo.ActivateDataKey(ctx, record)
+	} else {
+		err = s.repo.CreateDataKey(ctx, record)
+	}
+	if err != nil {
+		return nil, errors.NewInternal("Failed to store data key").WithError(err)
+	}
+
+	s.cacheKey(tenantID, version, raw)
+	return &tenantKey{version: version, key: raw}, nil
+}
+
+// tenantKey is an unwrapped data key
+type tenantKey struct {
+	version int
+	key     []byte
+}
+
+// keyVersion returns a version of a tenant's data key, unwrapped
+func (s *Service) keyVersion(ctx context.Context, tenantID string, version int) (*tenantKey, error) {
+	if raw, ok := s.cachedKey(tenantID, version); ok {
+		return &tenantKey{version: version, key: raw}, nil
+	}
+
+	record, err := s.repo.DataKeyVersion(ctx, tenantID, version)
+	if err != nil {
+		return nil, errors.NewInternal("Failed to load data key").WithError(err)
+	}
+	if record == nil {
+		return nil, errors.NewInternal("Data key version " + strconv.Itoa(version) + " is missing")
+	}
+	return s.unwrap(record)
+}
+
+// unwrap decrypts a stored data key with the master key
+func (s *Service) unwrap(record *DataKey) (*tenantKey, error) {
+	if raw, ok := s.cachedKey(record.TenantID, record.Version); ok {
+		return &tenantKey{version: record.Version, key: raw}, nil
+	}
+
+	if record.MasterKeyID != s.masterID {
+		return nil, errors.NewInternal("Data key was wrapped by a different master key")
+	}
+	raw, err := open(s.config.MasterKey, record.WrappedKey, record.Nonce, dataKeyAAD(record.TenantID, record.Version))
+	if err != nil {
+		return nil, errors.NewInternal("Failed to unwrap data key").WithError(err)
+	}
+
+	s.cacheKey(record.TenantID, record.Version, raw)
+	return &tenantKey{version: record.Version, key: raw}, nil
+}
+
+func (s *Service) cachedKey(tenantID string, version int) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	raw, ok := s.keys[tenantID+"\x00"+strconv.Itoa(version)]
+	return raw, ok
+}
+
+func (s *Service) cacheKey(tenantID string, version int, raw []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[tenantID+"\x00"+strconv.Itoa(version)] = raw
+}
+
+// encrypt seals a value into a secret with a data key
+func (s *Service) encrypt(secret *Secret, key *tenantKey, value []byte) error {
+	secret.KeyVersion = key.version
+	ciphertext, nonce, err := seal(key.key, value, secretAAD(secret))
+	if err != nil {
+		return errors.NewInternal("Failed to encrypt secret").WithError(err)
+	}
+	secret.Ciphertext, secret.Nonce = ciphertext, nonce
+	return nil
+}
+
+// decrypt opens a secret with the data key version it was sealed with
+func (s *Service) decrypt(ctx context.Context, secret *Secret) ([]byte, error) {
+	key, err := s.keyVersion(ctx, secret.TenantID, secret.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+	value, err := open(key.key, secret.Ciphertext, secret.Nonce, secretAAD(secret))
+	if err != nil {
+		return nil, errors.NewInternal("Failed to decrypt secret").WithError(err)
+	}
+	return value, nil
+}
+
+// ==================== Audit ====================
+
+// AccessLogs lists the access log of the tenant in the context
+func (s *Service) AccessLogs(ctx context.Context, filter AccessLogFilter, page, limit int) ([]AccessLog, int64, error) {
+	logs, total, err := s.repo.ListAccessLogs(ctx, tenantFromContext(ctx), filter, page, limit)
+	if err != nil {
+		return nil, 0, errors.NewInternal("Failed to list access logs").WithError(err)
+	}
+	return logs, total, nil
+}
+
+// audit records an access with the outcome of err, and returns err
+func (s *Service) audit(ctx context.Context, entry *AccessLog, err error) error {
+	entry.Status = StatusSuccess
+	if err != nil {
+		entry.Status = StatusFailed
+		if IsNotFound(err) {
+			entry.Status = StatusNotFound
+		}
+		message := err.Error()
+		if appErr, ok := errors.GetAppError(err); ok {
+			message = appErr.Message // Never the underlying cause, which may quote data
+		}
+		if len(message) > 255 {
+			message = message[:255]
+		}
+		entry.Error = message
+	}
+
+	if logErr := s.repo.CreateAccessLog(ctx, entry); logErr != nil {
+		logger.Error("Failed to record vault access", logger.Fields{
+			"namespace": entry.Namespace,
+			"action":    entry.Action,
+			"owner_id":  entry.OwnerID,
+			"error":     logErr.Error(),
+		})
+	}
+	return err
+}
+
+// tenantFromContext returns the ID of the tenant in ctx, or DefaultTenant
+func tenantFromContext(ctx context.Context) string {
+	if tenant, err := tenancy.GetTenant(ctx); err == nil && tenant.ID != "" {
+		return tenant.ID
+	}
+	return DefaultTenant
+}
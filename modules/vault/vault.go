@@ -0,0 +1,28 @@
+package vault
+
+import (
+	"neonexcore/internal/config"
+	"neonexcore/internal/core"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type VaultModule struct{}
+
+func New() *VaultModule {
+	return &VaultModule{}
+}
+
+func (m *VaultModule) Name() string {
+	return "vault"
+}
+
+func (m *VaultModule) Init() {}
+
+func (m *VaultModule) RegisterServices(c *core.Container) {
+	RegisterDependencies(c, config.DB.GetDB())
+}
+
+func (m *VaultModule) Routes(router fiber.Router, c *core.Container) {
+	SetupRoutes(router, c)
+}
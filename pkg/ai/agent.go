@@ -0,0 +1,625 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrAgentNotFound is returned for unknown agents
+	ErrAgentNotFound = errors.New("agent not found")
+	// ErrAgentRunNotFound is returned for unknown agent runs
+	ErrAgentRunNotFound = errors.New("agent run not found")
+	// ErrAgentRunFinished is returned when cancelling a finished run or
+	// resuming a completed one
+	ErrAgentRunFinished = errors.New("agent run already finished")
+)
+
+// maxObservation is the number of characters of a tool result shown to
+// the model; the trace keeps the whole result
+const maxObservation = 4000
+
+// AgentTool is a tool an agent may call. Parameters is the JSON schema of
+// the input, shown to the model; Run receives the input the model wrote.
+type AgentTool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+	Run         func(ctx context.Context, input json.RawMessage) (interface{}, error)
+}
+
+// AgentBudget bounds a run. Zero iteration and tool call limits take the
+// defaults; zero tokens is no token limit.
+type AgentBudget struct {
+	MaxIterations int `json:"max_iterations,omitempty"` // Model calls
+	MaxToolCalls  int `json:"max_tool_calls,omitempty"`
+	MaxTokens     int `json:"max_tokens,omitempty"` // Prompt and completion tokens
+}
+
+// DefaultAgentBudget returns the budget of agents that set none
+func DefaultAgentBudget() AgentBudget {
+	return AgentBudget{MaxIterations: 10, MaxToolCalls: 20}
+}
+
+// withDefaults fills in the default iteration and tool call limits
+func (b AgentBudget) withDefaults() AgentBudget {
+	defaults := DefaultAgentBudget()
+	if b.MaxIterations <= 0 {
+		b.MaxIterations = defaults.MaxIterations
+	}
+	if b.MaxToolCalls <= 0 {
+		b.MaxToolCalls = defaults.MaxToolCalls
+	}
+	return b
+}
+
+// within returns the budget lowered to b's limits: a run may ask for less
+// than its agent allows, never more
+func (b AgentBudget) within(limit AgentBudget) AgentBudget {
+	lower := func(value, max int) int {
+		if value <= 0 || (max > 0 && value > max) {
+			return max
+		}
+		return value
+	}
+	return AgentBudget{
+		MaxIterations: lower(b.MaxIterations, limit.MaxIterations),
+		MaxToolCalls:  lower(b.MaxToolCalls, limit.MaxToolCalls),
+		MaxTokens:     lower(b.MaxTokens, limit.MaxTokens),
+	}
+}
+
+// Agent answers a task by calling a model in a loop: each reply either
+// calls one of the tools, whose result is added to the scratchpad for the
+// next call, or gives the final answer.
+type Agent struct {
+	Name         string
+	Description  string
+	ModelID      string
+	Instructions string // Prepended to the system prompt
+	Tools        []AgentTool
+	Budget       AgentBudget   // Per run
+	ToolTimeout  time.Duration // Per tool call, 30 seconds by default
+	Parameters   map[string]interface{}
+}
+
+// tool returns the named tool
+func (a *Agent) tool(name string) (AgentTool, bool) {
+	for _, tool := range a.Tools {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return AgentTool{}, false
+}
+
+// AgentRunStatus is the state of an agent run
+type AgentRunStatus string
+
+const (
+	AgentRunRunning        AgentRunStatus = "running"
+	AgentRunCompleted      AgentRunStatus = "completed"
+	AgentRunFailed         AgentRunStatus = "failed"
+	AgentRunCancelled      AgentRunStatus = "cancelled"
+	AgentRunBudgetExceeded AgentRunStatus = "budget_exceeded"
+)
+
+// AgentStep is one iteration of a run: the model's reply and the tool call
+// it asked for, or its final answer
+type AgentStep struct {
+	Iteration        int             `json:"iteration"`
+	Reply            string          `json:"reply"` // As the model wrote it
+	Thought          string          `json:"thought,omitempty"`
+	Tool             string          `json:"tool,omitempty"`
+	Input            json.RawMessage `json:"input,omitempty"`
+	Observation      string          `json:"observation,omitempty"` // Tool result or error, as shown to the model
+	Final            string          `json:"final,omitempty"`
+	Error            string          `json:"error,omitempty"`
+	PromptTokens     int             `json:"prompt_tokens"`
+	CompletionTokens int             `json:"completion_tokens"`
+	ModelLatencyMS   int64           `json:"model_latency_ms"`
+	ToolLatencyMS    int64           `json:"tool_latency_ms,omitempty"`
+	StartedAt        time.Time       `json:"started_at"`
+}
+
+// AgentRun is the trace of an agent run. It is saved after every step, so
+// the steps are also the scratchpad a resumed run continues from.
+type AgentRun struct {
+	ID               string         `gorm:"primaryKey;size:36" json:"id"`
+	Agent            string         `gorm:"size:100;index;not null" json:"agent"`
+	Input            string         `gorm:"type:text" json:"input"`
+	Status           AgentRunStatus `gorm:"size:20;index;not null" json:"status"`
+	Output           string         `gorm:"type:text" json:"output,omitempty"`
+	Error            string         `gorm:"size:1000" json:"error,omitempty"`
+	Budget           AgentBudget    `gorm:"serializer:json" json:"budget"`
+	Steps            []AgentStep    `gorm:"serializer:json" json:"steps"`
+	Iterations       int            `json:"iterations"`
+	ToolCalls        int            `json:"tool_calls"`
+	PromptTokens     int            `json:"prompt_tokens"`
+	CompletionTokens int            `json:"completion_tokens"`
+	Caller           string         `gorm:"size:100;index" json:"caller,omitempty"`
+	StartedAt        time.Time      `gorm:"index" json:"started_at"`
+	CompletedAt      *time.Time     `json:"completed_at,omitempty"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+// TableName specifies the table name for AgentRun
+func (AgentRun) TableName() string {
+	return "ai_agent_runs"
+}
+
+// Tokens returns the prompt and completion tokens the run used
+func (r *AgentRun) Tokens() int {
+	return r.PromptTokens + r.CompletionTokens
+}
+
+// clone copies the run, so a stored run is not changed by the runner
+func (r *AgentRun) clone() *AgentRun {
+	copied := *r
+	copied.Steps = append([]AgentStep(nil), r.Steps...)
+	return &copied
+}
+
+// AgentManager runs agents and keeps their traces in a store
+type AgentManager struct {
+	models *ModelManager
+	store  AgentStore
+	agents map[string]*Agent
+	active map[string]context.CancelFunc // By run ID
+	mu     sync.RWMutex
+}
+
+// NewAgentManager creates an agent manager keeping traces in store, or in
+// memory when store is nil
+func NewAgentManager(models *ModelManager, store AgentStore) *AgentManager {
+	if store == nil {
+		store = NewMemoryAgentStore()
+	}
+	return &AgentManager{
+		models: models,
+		store:  store,
+		agents: make(map[string]*Agent),
+		active: make(map[string]context.CancelFunc),
+	}
+}
+
+// Register adds or replaces an agent
+func (am *AgentManager) Register(agent *Agent) error {
+	if agent.Name == "" {
+		return fmt.Errorf("agent name is required")
+	}
+	if agent.ModelID == "" {
+		return fmt.Errorf("agent %s: model is required", agent.Name)
+	}
+	seen := make(map[string]bool, len(agent.Tools))
+	for _, tool := range agent.Tools {
+		if tool.Name == "" || tool.Run == nil {
+			return fmt.Errorf("agent %s: tools need a name and a run function", agent.Name)
+		}
+		if seen[tool.Name] {
+			return fmt.Errorf("agent %s: duplicate tool %s", agent.Name, tool.Name)
+		}
+		seen[tool.Name] = true
+	}
+
+	am.mu.Lock()
+	am.agents[agent.Name] = agent
+	am.mu.Unlock()
+	return nil
+}
+
+// GetAgent returns a registered agent
+func (am *AgentManager) GetAgent(name string) (*Agent, error) {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+
+	agent, exists := am.agents[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrAgentNotFound, name)
+	}
+	return agent, nil
+}
+
+// ListAgents returns the registered agents by name
+func (am *AgentManager) ListAgents() []*Agent {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+
+	agents := make([]*Agent, 0, len(am.agents))
+	for _, agent := range am.agents {
+		agents = append(agents, agent)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Name < agents[j].Name })
+	return agents
+}
+
+// Run runs an agent on input and returns the finished run. The budget may
+// lower the agent's; zero fields keep it. A run that fails, is cancelled
+// or exhausts its budget is returned with that status, not as an error.
+func (am *AgentManager) Run(ctx context.Context, name, input string, budget AgentBudget) (*AgentRun, error) {
+	agent, run, err := am.newRun(ctx, name, input, budget)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := am.track(ctx, run.ID)
+	defer am.untrack(run.ID, cancel)
+
+	am.execute(ctx, agent, run)
+	return run.clone(), nil
+}
+
+// Start runs an agent in the background and returns the new run; follow
+// it with GetRun and stop it with Cancel
+func (am *AgentManager) Start(ctx context.Context, name, input string, budget AgentBudget) (*AgentRun, error) {
+	agent, run, err := am.newRun(ctx, name, input, budget)
+	if err != nil {
+		return nil, err
+	}
+	return am.background(ctx, agent, run), nil
+}
+
+// Resume continues a run that was cancelled, exhausted its budget, failed
+// or was interrupted by a restart from its saved scratchpad, in the
+// background. The run gets a fresh allowance of budget, limited as in Run.
+func (am *AgentManager) Resume(ctx context.Context, runID string, budget AgentBudget) (*AgentRun, error) {
+	run, err := am.store.LoadRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status == AgentRunCompleted || am.isActive(runID) {
+		return nil, fmt.Errorf("%w: %s is %s", ErrAgentRunFinished, runID, run.Status)
+	}
+	agent, err := am.GetAgent(run.Agent)
+	if err != nil {
+		return nil, err
+	}
+
+	allowance := budget.within(agent.Budget.withDefaults())
+	run.Budget = AgentBudget{
+		MaxIterations: run.Iterations + allowance.MaxIterations,
+		MaxToolCalls:  run.ToolCalls + allowance.MaxToolCalls,
+	}
+	if allowance.MaxTokens > 0 {
+		run.Budget.MaxTokens = run.Tokens() + allowance.MaxTokens
+	}
+	run.Status = AgentRunRunning
+	run.Error = ""
+	run.CompletedAt = nil
+	am.save(ctx, run)
+
+	return am.background(ctx, agent, run), nil
+}
+
+// Cancel stops a run. A run left running by a previous process is marked
+// cancelled.
+func (am *AgentManager) Cancel(ctx context.Context, runID string) error {
+	am.mu.RLock()
+	cancel, active := am.active[runID]
+	am.mu.RUnlock()
+	if active {
+		cancel()
+		return nil
+	}
+
+	run, err := am.store.LoadRun(ctx, runID)
+	if err != nil {
+		return err
+	}
+	if run.Status != AgentRunRunning {
+		return fmt.Errorf("%w: %s is %s", ErrAgentRunFinished, runID, run.Status)
+	}
+	am.finish(ctx, run, AgentRunCancelled, "cancelled")
+	return nil
+}
+
+// GetRun returns a run's trace
+func (am *AgentManager) GetRun(ctx context.Context, runID string) (*AgentRun, error) {
+	return am.store.LoadRun(ctx, runID)
+}
+
+// ListRuns returns runs, newest first
+func (am *AgentManager) ListRuns(ctx context.Context, filter AgentRunFilter) ([]*AgentRun, error) {
+	return am.store.ListRuns(ctx, filter)
+}
+
+// newRun creates and saves a run of the named agent
+func (am *AgentManager) newRun(ctx context.Context, name, input string, budget AgentBudget) (*Agent, *AgentRun, error) {
+	agent, err := am.GetAgent(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	run := &AgentRun{
+		ID:        uuid.New().String(),
+		Agent:     agent.Name,
+		Input:     input,
+		Status:    AgentRunRunning,
+		Budget:    budget.within(agent.Budget.withDefaults()),
+		Caller:    CallerFromContext(ctx),
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	if err := am.store.SaveRun(ctx, run); err != nil {
+		return nil, nil, fmt.Errorf("failed to save agent run: %w", err)
+	}
+	return agent, run, nil
+}
+
+// background executes a run detached from the caller's cancellation and
+// returns a copy of it as started
+func (am *AgentManager) background(ctx context.Context, agent *Agent, run *AgentRun) *AgentRun {
+	started := run.clone()
+	runCtx, cancel := am.track(context.WithoutCancel(ctx), run.ID)
+	go func() {
+		defer am.untrack(run.ID, cancel)
+		am.execute(runCtx, agent, run)
+	}()
+	return started
+}
+
+func (am *AgentManager) track(ctx context.Context, runID string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	am.mu.Lock()
+	am.active[runID] = cancel
+	am.mu.Unlock()
+	return ctx, cancel
+}
+
+func (am *AgentManager) untrack(runID string, cancel context.CancelFunc) {
+	am.mu.Lock()
+	delete(am.active, runID)
+	am.mu.Unlock()
+	cancel()
+}
+
+func (am *AgentManager) isActive(runID string) bool {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	_, active := am.active[runID]
+	return active
+}
+
+// execute iterates until the model gives a final answer, the budget runs
+// out, a model call fails or ctx is cancelled
+func (am *AgentManager) execute(ctx context.Context, agent *Agent, run *AgentRun) {
+	// Saves outlive cancellation, so a cancelled run is recorded as such
+	saveCtx := context.WithoutCancel(ctx)
+	system := agentSystemPrompt(agent)
+
+	for {
+		if ctx.Err() != nil {
+			am.finish(saveCtx, run, AgentRunCancelled, "cancelled")
+			return
+		}
+		if run.Iterations >= run.Budget.MaxIterations {
+			am.finish(saveCtx, run, AgentRunBudgetExceeded, fmt.Sprintf("iteration budget of %d exhausted", run.Budget.MaxIterations))
+			return
+		}
+		if run.Budget.MaxTokens > 0 && run.Tokens() >= run.Budget.MaxTokens {
+			am.finish(saveCtx, run, AgentRunBudgetExceeded, fmt.Sprintf("token budget of %d exhausted", run.Budget.MaxTokens))
+			return
+		}
+
+		step := AgentStep{Iteration: run.Iterations + 1, StartedAt: time.Now()}
+		prompt := agentPrompt(run)
+		parameters := make(map[string]interface{}, len(agent.Parameters)+1)
+		for k, v := range agent.Parameters {
+			parameters[k] = v
+		}
+		parameters["system"] = system
+
+		output, err := am.models.Predict(ctx, &InferenceInput{
+			ModelID:    agent.ModelID,
+			Data:       prompt,
+			Parameters: parameters,
+			Metadata:   map[string]string{"agent": agent.Name, "agent_run": run.ID},
+		})
+		step.ModelLatencyMS = time.Since(step.StartedAt).Milliseconds()
+		if err != nil {
+			if ctx.Err() != nil {
+				am.finish(saveCtx, run, AgentRunCancelled, "cancelled")
+				return
+			}
+			step.Error = err.Error()
+			run.Steps = append(run.Steps, step)
+			am.finish(saveCtx, run, AgentRunFailed, fmt.Sprintf("model call failed: %v", err))
+			return
+		}
+
+		step.Reply, _ = outputText(output.Result)
+		if promptTokens, completionTokens, ok := ExtractUsage(output.Result); ok {
+			step.PromptTokens, step.CompletionTokens = promptTokens, completionTokens
+		} else {
+			step.PromptTokens = EstimateTokens(system) + EstimateTokens(prompt)
+			step.CompletionTokens = EstimateTokens(step.Reply)
+		}
+		run.Iterations++
+		run.PromptTokens += step.PromptTokens
+		run.CompletionTokens += step.CompletionTokens
+
+		reply, err := parseAgentReply(step.Reply)
+		switch {
+		case err != nil:
+			step.Error = err.Error()
+			step.Observation = fmt.Sprintf("Invalid reply: %v. Reply with one JSON object as instructed.", err)
+		case reply.Final != nil:
+			step.Thought = reply.Thought
+			step.Final = *reply.Final
+			run.Steps = append(run.Steps, step)
+			run.Output = step.Final
+			am.finish(saveCtx, run, AgentRunCompleted, "")
+			return
+		default:
+			step.Thought, step.Tool, step.Input = reply.Thought, reply.Tool, reply.Input
+			if run.ToolCalls >= run.Budget.MaxToolCalls {
+				step.Error = "tool call budget exhausted"
+				step.Observation = "Error: tool call budget exhausted"
+				run.Steps = append(run.Steps, step)
+				am.finish(saveCtx, run, AgentRunBudgetExceeded, fmt.Sprintf("tool call budget of %d exhausted", run.Budget.MaxToolCalls))
+				return
+			}
+			am.callTool(ctx, agent, &step)
+			run.ToolCalls++
+		}
+
+		run.Steps = append(run.Steps, step)
+		am.save(saveCtx, run)
+	}
+}
+
+// callTool runs the tool a step asks for and records its result as the
+// step's observation
+func (am *AgentManager) callTool(ctx context.Context, agent *Agent, step *AgentStep) {
+	tool, ok := agent.tool(step.Tool)
+	if !ok {
+		step.Error = fmt.Sprintf("unknown tool %q", step.Tool)
+		step.Observation = fmt.Sprintf("Error: unknown tool %q", step.Tool)
+		return
+	}
+
+	timeout := agent.ToolTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	toolCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	input := step.Input
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+	}
+	started := time.Now()
+	result, err := tool.Run(toolCtx, input)
+	step.ToolLatencyMS = time.Since(started).Milliseconds()
+	if err != nil {
+		step.Error = err.Error()
+		step.Observation = "Error: " + err.Error()
+		return
+	}
+
+	switch v := result.(type) {
+	case string:
+		step.Observation = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			step.Error = fmt.Sprintf("tool result is not JSON: %v", err)
+			step.Observation = "Error: " + step.Error
+			return
+		}
+		step.Observation = string(data)
+	}
+}
+
+// finish records a run's outcome
+func (am *AgentManager) finish(ctx context.Context, run *AgentRun, status AgentRunStatus, reason string) {
+	now := time.Now()
+	run.Status = status
+	run.Error = reason
+	run.CompletedAt = &now
+	am.save(ctx, run)
+}
+
+func (am *AgentManager) save(ctx context.Context, run *AgentRun) {
+	run.UpdatedAt = time.Now()
+	if err := am.store.SaveRun(ctx, run); err != nil {
+		logger.Warn("Failed to save agent run", logger.Fields{"run": run.ID, "agent": run.Agent, "error": err.Error()})
+	}
+}
+
+// agentReply is the JSON object the model answers with
+type agentReply struct {
+	Thought string          `json:"thought"`
+	Tool    string          `json:"tool"`
+	Input   json.RawMessage `json:"input"`
+	Final   *string         `json:"final"`
+}
+
+// parseAgentReply reads the JSON object in a reply, ignoring code fences
+// and text around it
+func parseAgentReply(text string) (*agentReply, error) {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON object in reply")
+	}
+
+	var reply agentReply
+	if err := json.Unmarshal([]byte(text[start:end+1]), &reply); err != nil {
+		return nil, fmt.Errorf("reply is not valid JSON: %v", err)
+	}
+	if reply.Final == nil && reply.Tool == "" {
+		return nil, fmt.Errorf("reply names neither a tool nor a final answer")
+	}
+	return &reply, nil
+}
+
+// agentSystemPrompt describes the tools and the reply format
+func agentSystemPrompt(agent *Agent) string {
+	var b strings.Builder
+	if agent.Instructions != "" {
+		b.WriteString(agent.Instructions)
+		b.WriteString("\n\n")
+	}
+
+	if len(agent.Tools) > 0 {
+		b.WriteString("You can use these tools:\n")
+		for _, tool := range agent.Tools {
+			fmt.Fprintf(&b, "- %s: %s", tool.Name, tool.Description)
+			if tool.Parameters != nil {
+				if schema, err := json.Marshal(tool.Parameters); err == nil {
+					fmt.Fprintf(&b, " Input schema: %s", schema)
+				}
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\nTo use a tool, reply with only this JSON object:\n")
+		b.WriteString(`{"thought": "why", "tool": "tool name", "input": {...}}`)
+		b.WriteString("\nThe tool's result is shown to you as the observation in the next message.\n")
+	}
+	b.WriteString("When you can answer, reply with only this JSON object:\n")
+	b.WriteString(`{"thought": "why", "final": "your answer"}`)
+	return b.String()
+}
+
+// agentPrompt is the task followed by the scratchpad of earlier steps
+func agentPrompt(run *AgentRun) string {
+	var b strings.Builder
+	b.WriteString("Task: ")
+	b.WriteString(run.Input)
+	for _, step := range run.Steps {
+		if step.Reply == "" {
+			continue // The model call failed
+		}
+		fmt.Fprintf(&b, "\n\nStep %d\n", step.Iteration)
+		if step.Thought != "" {
+			fmt.Fprintf(&b, "Thought: %s\n", step.Thought)
+		}
+		if step.Tool != "" {
+			fmt.Fprintf(&b, "Action: %s %s\n", step.Tool, step.Input)
+		} else if step.Error != "" {
+			fmt.Fprintf(&b, "Reply: %s\n", truncate(step.Reply, maxObservation))
+		}
+		fmt.Fprintf(&b, "Observation: %s", truncate(step.Observation, maxObservation))
+	}
+	return b.String()
+}
+
+// truncate shortens text to n characters
+func truncate(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n]) + "... (truncated)"
+}
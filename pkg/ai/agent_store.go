@@ -0,0 +1,136 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// AgentRunFilter selects agent runs; empty fields match any run
+type AgentRunFilter struct {
+	Agent  string
+	Caller string
+	Status AgentRunStatus
+	Limit  int // 50 by default
+}
+
+func (f AgentRunFilter) limit() int {
+	if f.Limit <= 0 {
+		return 50
+	}
+	return f.Limit
+}
+
+// AgentStore persists agent run traces
+type AgentStore interface {
+	SaveRun(ctx context.Context, run *AgentRun) error
+	// LoadRun returns ErrAgentRunNotFound for unknown runs
+	LoadRun(ctx context.Context, id string) (*AgentRun, error)
+	// ListRuns returns the runs matching filter, newest first
+	ListRuns(ctx context.Context, filter AgentRunFilter) ([]*AgentRun, error)
+}
+
+// MemoryAgentStore keeps agent runs in memory, for tests and deployments
+// without a database
+type MemoryAgentStore struct {
+	runs map[string]*AgentRun
+	mu   sync.RWMutex
+}
+
+// NewMemoryAgentStore creates an in-memory agent store
+func NewMemoryAgentStore() *MemoryAgentStore {
+	return &MemoryAgentStore{runs: make(map[string]*AgentRun)}
+}
+
+// SaveRun stores a copy of run
+func (s *MemoryAgentStore) SaveRun(ctx context.Context, run *AgentRun) error {
+	s.mu.Lock()
+	s.runs[run.ID] = run.clone()
+	s.mu.Unlock()
+	return nil
+}
+
+// LoadRun returns a copy of a run
+func (s *MemoryAgentStore) LoadRun(ctx context.Context, id string) (*AgentRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	run, exists := s.runs[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrAgentRunNotFound, id)
+	}
+	return run.clone(), nil
+}
+
+// ListRuns returns copies of the matching runs, newest first
+func (s *MemoryAgentStore) ListRuns(ctx context.Context, filter AgentRunFilter) ([]*AgentRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var runs []*AgentRun
+	for _, run := range s.runs {
+		if (filter.Agent != "" && run.Agent != filter.Agent) ||
+			(filter.Caller != "" && run.Caller != filter.Caller) ||
+			(filter.Status != "" && run.Status != filter.Status) {
+			continue
+		}
+		runs = append(runs, run.clone())
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	if len(runs) > filter.limit() {
+		runs = runs[:filter.limit()]
+	}
+	return runs, nil
+}
+
+// SQLAgentStore keeps agent runs in the ai_agent_runs table
+type SQLAgentStore struct {
+	db *gorm.DB
+}
+
+// NewSQLAgentStore creates a database agent store
+func NewSQLAgentStore(db *gorm.DB) *SQLAgentStore {
+	return &SQLAgentStore{db: db}
+}
+
+// SaveRun inserts or updates a run
+func (s *SQLAgentStore) SaveRun(ctx context.Context, run *AgentRun) error {
+	return s.db.WithContext(ctx).Save(run).Error
+}
+
+// LoadRun returns a run
+func (s *SQLAgentStore) LoadRun(ctx context.Context, id string) (*AgentRun, error) {
+	var run AgentRun
+	err := s.db.WithContext(ctx).Where("id = ?", id).First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrAgentRunNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListRuns returns the matching runs, newest first
+func (s *SQLAgentStore) ListRuns(ctx context.Context, filter AgentRunFilter) ([]*AgentRun, error) {
+	query := s.db.WithContext(ctx).Order("started_at DESC").Limit(filter.limit())
+	if filter.Agent != "" {
+		query = query.Where("agent = ?", filter.Agent)
+	}
+	if filter.Caller != "" {
+		query = query.Where("caller = ?", filter.Caller)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var runs []*AgentRun
+	if err := query.Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
@@ -0,0 +1,485 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/metrics"
+)
+
+// ErrBatchQueueFull is returned when a model's batch queue is at capacity
+var ErrBatchQueueFull = errors.New("batch queue full")
+
+// BatchConfig configures dynamic batching for a model
+type BatchConfig struct {
+	Window   time.Duration // How long the first request waits for others (default 20ms)
+	MaxBatch int           // Requests merged into one call at most (default 32)
+	MaxQueue int           // Requests waiting at most; more are rejected (default 1024)
+}
+
+// DefaultBatchConfig returns the default batching configuration
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{Window: 20 * time.Millisecond, MaxBatch: 32, MaxQueue: 1024}
+}
+
+// LoadBatchConfigs reads AI_BATCH_MODELS, a comma separated list of
+// model=window:max_batch entries such as "text-embedding-3-small=20ms:32".
+// Omitted values use the defaults.
+func LoadBatchConfigs() (map[string]BatchConfig, error) {
+	configs := make(map[string]BatchConfig)
+	for _, entry := range strings.Split(os.Getenv("AI_BATCH_MODELS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		modelID, spec, _ := strings.Cut(entry, "=")
+		config := DefaultBatchConfig()
+		window, maxBatch, _ := strings.Cut(spec, ":")
+		if window != "" {
+			d, err := time.ParseDuration(window)
+			if err != nil {
+				return nil, fmt.Errorf("invalid batch window for %s: %w", modelID, err)
+			}
+			config.Window = d
+		}
+		if maxBatch != "" {
+			n, err := strconv.Atoi(maxBatch)
+			if err != nil {
+				return nil, fmt.Errorf("invalid max batch for %s: %w", modelID, err)
+			}
+			config.MaxBatch = n
+		}
+		configs[strings.TrimSpace(modelID)] = config
+	}
+	return configs, nil
+}
+
+// BatchProvider is implemented by providers that take several inputs in
+// one call. Outputs are returned in input order.
+type BatchProvider interface {
+	PredictBatch(ctx context.Context, modelID string, inputs []*InferenceInput) ([]*InferenceOutput, error)
+}
+
+// BatchStats reports a model's batching
+type BatchStats struct {
+	ModelID      string        `json:"model_id"`
+	Window       time.Duration `json:"window"`
+	MaxBatch     int           `json:"max_batch"`
+	QueueDepth   int           `json:"queue_depth"` // Requests waiting for their batch
+	Batches      int64         `json:"batches"`
+	Requests     int64         `json:"requests"`
+	AvgBatchSize float64       `json:"avg_batch_size"`
+	Rejected     int64         `json:"rejected"`
+	Errors       int64         `json:"errors"` // Failed provider calls
+}
+
+// EnableBatching merges requests to a model that arrive within the window
+// into one provider call. Embedding requests are merged for any provider;
+// other requests only for providers implementing BatchProvider, and pass
+// through otherwise. Model IDs may be aliases such as "name@production".
+func (m *ModelManager) EnableBatching(modelID string, config BatchConfig) {
+	defaults := DefaultBatchConfig()
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	if config.MaxBatch <= 0 {
+		config.MaxBatch = defaults.MaxBatch
+	}
+	if config.MaxQueue <= 0 {
+		config.MaxQueue = defaults.MaxQueue
+	}
+
+	m.mu.Lock()
+	previous := m.batchers[modelID]
+	m.batchers[modelID] = newBatcher(modelID, config, m.collector)
+	m.mu.Unlock()
+
+	if previous != nil {
+		previous.flushAll()
+	}
+}
+
+// DisableBatching stops batching a model; waiting requests are sent
+func (m *ModelManager) DisableBatching(modelID string) {
+	m.mu.Lock()
+	b := m.batchers[modelID]
+	delete(m.batchers, modelID)
+	m.mu.Unlock()
+
+	if b != nil {
+		b.flushAll()
+	}
+}
+
+// BatchStats returns the batching stats of every batched model
+func (m *ModelManager) BatchStats() []BatchStats {
+	m.mu.RLock()
+	batchers := make([]*batcher, 0, len(m.batchers))
+	for _, b := range m.batchers {
+		batchers = append(batchers, b)
+	}
+	m.mu.RUnlock()
+
+	stats := make([]BatchStats, 0, len(batchers))
+	for _, b := range batchers {
+		stats = append(stats, b.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ModelID < stats[j].ModelID })
+	return stats
+}
+
+// SetMetrics reports batch queue depths and sizes, and semantic cache hit
+// rates, to a metrics collector
+func (m *ModelManager) SetMetrics(collector *metrics.Collector) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.collector = collector
+	for _, b := range m.batchers {
+		b.mu.Lock()
+		b.collector = collector
+		b.mu.Unlock()
+	}
+	for _, c := range m.semantic {
+		c.mu.Lock()
+		c.collector = collector
+		c.mu.Unlock()
+	}
+}
+
+// getBatcher returns the batcher for the resolved model ID or the alias
+// it was requested by
+func (m *ModelManager) getBatcher(modelID, requested string) *batcher {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if b, ok := m.batchers[modelID]; ok {
+		return b
+	}
+	return m.batchers[requested]
+}
+
+// batcher queues a model's requests and sends them in batches. Requests
+// are grouped by what can share a call: embeddings with equal parameters,
+// or anything for a BatchProvider.
+type batcher struct {
+	modelID   string
+	config    BatchConfig
+	collector *metrics.Collector
+	groups    map[string]*batchGroup
+	depth     int
+	batches   int64
+	requests  int64
+	rejected  int64
+	errors    int64
+	mu        sync.Mutex
+}
+
+type batchGroup struct {
+	key      string
+	provider ModelProvider
+	items    []*batchItem
+	timer    *time.Timer
+}
+
+type batchItem struct {
+	ctx   context.Context
+	input *InferenceInput
+	done  chan batchResult
+}
+
+type batchResult struct {
+	output *InferenceOutput
+	err    error
+}
+
+func newBatcher(modelID string, config BatchConfig, collector *metrics.Collector) *batcher {
+	return &batcher{
+		modelID:   modelID,
+		config:    config,
+		collector: collector,
+		groups:    make(map[string]*batchGroup),
+	}
+}
+
+// submit queues a request and waits for its share of the batch result.
+// Requests that cannot be merged are sent directly.
+func (b *batcher) submit(ctx context.Context, provider ModelProvider, input *InferenceInput) (*InferenceOutput, error) {
+	key, ok := batchKey(provider, input)
+	if !ok {
+		return provider.Predict(ctx, input.ModelID, input)
+	}
+
+	item := &batchItem{ctx: ctx, input: input, done: make(chan batchResult, 1)}
+
+	b.mu.Lock()
+	if b.depth >= b.config.MaxQueue {
+		b.rejected++
+		collector := b.collector
+		b.mu.Unlock()
+		if collector != nil {
+			collector.NewCounter("ai_batch_rejections_total", "AI requests rejected by full batch queues", nil).Inc()
+		}
+		return nil, fmt.Errorf("%w: %s", ErrBatchQueueFull, b.modelID)
+	}
+	group, exists := b.groups[key]
+	if !exists {
+		group = &batchGroup{key: key, provider: provider}
+		b.groups[key] = group
+		group.timer = time.AfterFunc(b.config.Window, func() { b.flush(group) })
+	}
+	group.items = append(group.items, item)
+	b.depth++
+	full := len(group.items) >= b.config.MaxBatch
+	if full {
+		delete(b.groups, key)
+		group.timer.Stop()
+	}
+	b.reportDepth()
+	b.mu.Unlock()
+
+	if full {
+		go b.run(group)
+	}
+
+	select {
+	case result := <-item.done:
+		return result.output, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// flush sends a group when its window closes, unless it already filled up
+func (b *batcher) flush(group *batchGroup) {
+	b.mu.Lock()
+	if b.groups[group.key] != group {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.groups, group.key)
+	b.mu.Unlock()
+
+	b.run(group)
+}
+
+// flushAll sends every waiting group
+func (b *batcher) flushAll() {
+	b.mu.Lock()
+	groups := make([]*batchGroup, 0, len(b.groups))
+	for key, group := range b.groups {
+		group.timer.Stop()
+		delete(b.groups, key)
+		groups = append(groups, group)
+	}
+	b.mu.Unlock()
+
+	for _, group := range groups {
+		go b.run(group)
+	}
+}
+
+// run sends a group in one provider call and hands each request its result.
+// Requests cancelled while waiting are left out.
+func (b *batcher) run(group *batchGroup) {
+	items := make([]*batchItem, 0, len(group.items))
+	for _, item := range group.items {
+		if item.ctx.Err() == nil {
+			items = append(items, item)
+		}
+	}
+
+	b.mu.Lock()
+	b.depth -= len(group.items)
+	if len(items) > 0 {
+		b.batches++
+		b.requests += int64(len(items))
+	}
+	b.reportDepth()
+	collector := b.collector
+	b.mu.Unlock()
+
+	if len(items) == 0 {
+		return
+	}
+	if collector != nil {
+		collector.NewCounter("ai_batches_total", "AI provider calls made for batches", nil).Inc()
+		collector.NewCounter("ai_batched_requests_total", "AI requests sent in batches", nil).Add(uint64(len(items)))
+	}
+
+	// One caller cancelling must not fail the others' call
+	ctx := context.WithoutCancel(items[0].ctx)
+
+	var outputs []*InferenceOutput
+	var err error
+	if batchProvider, ok := group.provider.(BatchProvider); ok {
+		outputs, err = predictBatch(ctx, batchProvider, items)
+	} else {
+		outputs, err = predictEmbeddingBatch(ctx, group.provider, items)
+	}
+	if err != nil {
+		b.mu.Lock()
+		b.errors++
+		b.mu.Unlock()
+	}
+
+	for i, item := range items {
+		if err != nil {
+			item.done <- batchResult{err: err}
+			continue
+		}
+		item.done <- batchResult{output: outputs[i]}
+	}
+}
+
+// reportDepth sets the queue depth gauge; called with b.mu held
+func (b *batcher) reportDepth() {
+	if b.collector == nil {
+		return
+	}
+	b.collector.NewGauge("ai_batch_queue_depth_"+b.modelID,
+		"AI requests waiting for a batch of "+b.modelID, map[string]string{"model": b.modelID}).Set(int64(b.depth))
+}
+
+func (b *batcher) stats() BatchStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := BatchStats{
+		ModelID:    b.modelID,
+		Window:     b.config.Window,
+		MaxBatch:   b.config.MaxBatch,
+		QueueDepth: b.depth,
+		Batches:    b.batches,
+		Requests:   b.requests,
+		Rejected:   b.rejected,
+		Errors:     b.errors,
+	}
+	if b.batches > 0 {
+		stats.AvgBatchSize = float64(b.requests) / float64(b.batches)
+	}
+	return stats
+}
+
+// batchKey groups requests that can share a provider call
+func batchKey(provider ModelProvider, input *InferenceInput) (string, bool) {
+	if _, ok := provider.(BatchProvider); ok {
+		return input.ModelID, true
+	}
+	if input.Parameters["type"] != "embedding" {
+		return "", false
+	}
+	switch input.Data.(type) {
+	case string, []string:
+	default:
+		return "", false
+	}
+	params, err := json.Marshal(input.Parameters)
+	if err != nil {
+		return "", false
+	}
+	return input.ModelID + "\x00" + string(params), true
+}
+
+func predictBatch(ctx context.Context, provider BatchProvider, items []*batchItem) ([]*InferenceOutput, error) {
+	inputs := make([]*InferenceInput, len(items))
+	for i, item := range items {
+		inputs[i] = item.input
+	}
+	outputs, err := provider.PredictBatch(ctx, inputs[0].ModelID, inputs)
+	if err != nil {
+		return nil, err
+	}
+	if len(outputs) != len(inputs) {
+		return nil, fmt.Errorf("batch returned %d outputs for %d inputs", len(outputs), len(inputs))
+	}
+	return outputs, nil
+}
+
+// predictEmbeddingBatch embeds every request's texts in one call and
+// splits the vectors, and the reported prompt tokens by text length, back
+// into one embedding result per request
+func predictEmbeddingBatch(ctx context.Context, provider ModelProvider, items []*batchItem) ([]*InferenceOutput, error) {
+	var texts []string
+	counts := make([]int, len(items))
+	chars := make([]int, len(items))
+	for i, item := range items {
+		switch data := item.input.Data.(type) {
+		case string:
+			texts = append(texts, data)
+			counts[i], chars[i] = 1, len(data)
+		case []string:
+			texts = append(texts, data...)
+			counts[i] = len(data)
+			for _, text := range data {
+				chars[i] += len(text)
+			}
+		}
+	}
+
+	merged := *items[0].input
+	merged.Data = texts
+	output, err := provider.Predict(ctx, merged.ModelID, &merged)
+	if err != nil {
+		return nil, err
+	}
+	result, _ := output.Result.(map[string]interface{})
+	data, ok := result["data"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("inference result is not an embedding")
+	}
+	if len(data) != len(texts) {
+		return nil, fmt.Errorf("embedding model returned %d vectors for %d texts", len(data), len(texts))
+	}
+
+	promptTokens, _, hasUsage := ExtractUsage(output.Result)
+	totalChars := 0
+	for _, n := range chars {
+		totalChars += n
+	}
+
+	outputs := make([]*InferenceOutput, len(items))
+	offset, tokensLeft := 0, promptTokens
+	for i := range items {
+		entries := make([]interface{}, counts[i])
+		for j := range entries {
+			entry, _ := data[offset+j].(map[string]interface{})
+			copied := make(map[string]interface{}, len(entry))
+			for k, v := range entry {
+				copied[k] = v
+			}
+			copied["index"] = j
+			entries[j] = copied
+		}
+		offset += counts[i]
+
+		itemResult := map[string]interface{}{"object": "list", "model": result["model"], "data": entries}
+		if hasUsage {
+			tokens := tokensLeft
+			if i < len(items)-1 && totalChars > 0 {
+				tokens = promptTokens * chars[i] / totalChars
+			}
+			tokensLeft -= tokens
+			itemResult["usage"] = usageResult(tokens, 0)
+		}
+
+		metadata := make(map[string]interface{}, len(output.Metadata)+1)
+		for k, v := range output.Metadata {
+			metadata[k] = v
+		}
+		metadata["batch_size"] = len(items)
+
+		outputs[i] = &InferenceOutput{
+			ModelID:  items[i].input.ModelID,
+			Result:   itemResult,
+			Metadata: metadata,
+		}
+	}
+	return outputs, nil
+}
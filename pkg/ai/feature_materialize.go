@@ -0,0 +1,497 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"neonexcore/pkg/scheduler"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// materializeBatchSize is how many rows are written per statement
+const materializeBatchSize = 500
+
+// FeatureSnapshot is a feature group's values for an entity as of a point
+// in time. Snapshots are kept so training datasets can be built from the
+// values that were known when each label was observed.
+type FeatureSnapshot struct {
+	ID          uint                   `json:"id" gorm:"primaryKey"`
+	GroupName   string                 `json:"group_name" gorm:"size:100;not null;uniqueIndex:idx_ai_feature_snapshots_point"`
+	EntityType  string                 `json:"entity_type" gorm:"size:100"`
+	EntityID    string                 `json:"entity_id" gorm:"size:200;not null;uniqueIndex:idx_ai_feature_snapshots_point"`
+	Values      map[string]interface{} `json:"values" gorm:"serializer:json"`
+	FeatureTime time.Time              `json:"feature_time" gorm:"not null;uniqueIndex:idx_ai_feature_snapshots_point"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+// TableName specifies the table name for FeatureSnapshot
+func (FeatureSnapshot) TableName() string {
+	return "ai_feature_snapshots"
+}
+
+// MaterializationResult summarizes a materialization run
+type MaterializationResult struct {
+	Group    string        `json:"group"`
+	Rows     int           `json:"rows"`
+	Features []string      `json:"features"`
+	Duration time.Duration `json:"duration"`
+}
+
+// EntityTimestamp is an entity and the moment its features are wanted,
+// e.g. when a training label was observed
+type EntityTimestamp struct {
+	EntityID  string    `json:"entity_id"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// HistoricalFeatures is a group's values for an entity as they were known
+// at a point in time. Found is false when no value was known then, or the
+// latest one was older than the group's TTL.
+type HistoricalFeatures struct {
+	EntityID    string                 `json:"entity_id"`
+	Timestamp   time.Time              `json:"timestamp"`
+	FeatureTime *time.Time             `json:"feature_time,omitempty"`
+	Values      map[string]interface{} `json:"values,omitempty"`
+	Found       bool                   `json:"found"`
+}
+
+// FeatureFreshness describes how current a feature group's values are
+type FeatureFreshness struct {
+	Group              string        `json:"group"`
+	TTL                time.Duration `json:"ttl"`
+	LastMaterializedAt *time.Time    `json:"last_materialized_at,omitempty"`
+	Age                time.Duration `json:"age"`   // Since the last materialization
+	Stale              bool          `json:"stale"` // Not materialized within the TTL
+	StaleEntities      int64         `json:"stale_entities"`
+	LastError          string        `json:"last_error,omitempty"`
+}
+
+// TTL returns how long the group's values stay fresh, or zero
+func (g *FeatureGroup) TTL() time.Duration {
+	return time.Duration(g.TTLSeconds) * time.Second
+}
+
+// Materialize runs a feature group's query and stores the results. Each
+// row becomes an entity's current features, read by GetFeatureGroupVector,
+// and a snapshot for point-in-time retrieval. Columns other than the
+// entity and timestamp columns are features.
+func (fs *FeatureStore) Materialize(ctx context.Context, groupName string) (*MaterializationResult, error) {
+	group, err := fs.GetFeatureGroup(ctx, groupName)
+	if err != nil {
+		return nil, err
+	}
+	if group.Query == "" {
+		return nil, fmt.Errorf("feature group %s has no query", groupName)
+	}
+
+	start := time.Now()
+	result, err := fs.materialize(ctx, group, start)
+
+	group.LastMaterializeError = ""
+	if err != nil {
+		group.LastMaterializeError = err.Error()
+	} else {
+		group.LastMaterializedAt = &start
+		group.LastMaterializedRows = result.Rows
+		if len(group.Features) == 0 {
+			group.Features = result.Features
+		}
+	}
+	if saveErr := fs.db.WithContext(ctx).Save(group).Error; saveErr != nil && err == nil {
+		err = fmt.Errorf("failed to save feature group: %w", saveErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+func (fs *FeatureStore) materialize(ctx context.Context, group *FeatureGroup, now time.Time) (*MaterializationResult, error) {
+	rows, err := fs.db.WithContext(ctx).Raw(group.Query).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("feature query failed: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	entityColumn := group.EntityColumn
+	if entityColumn == "" {
+		entityColumn = "entity_id"
+	}
+	entityIndex, timeIndex := -1, -1
+	var featureNames []string
+	for i, column := range columns {
+		switch column {
+		case entityColumn:
+			entityIndex = i
+		case group.TimestampColumn:
+			timeIndex = i
+		default:
+			featureNames = append(featureNames, column)
+		}
+	}
+	if entityIndex < 0 {
+		return nil, fmt.Errorf("feature query returned no %s column", entityColumn)
+	}
+	if group.TimestampColumn != "" && timeIndex < 0 {
+		return nil, fmt.Errorf("feature query returned no %s column", group.TimestampColumn)
+	}
+
+	// Snapshots are written as they are read; current features only once
+	// the latest row of each entity is known
+	result := &MaterializationResult{Group: group.Name, Features: featureNames}
+	snapshots := make(map[string]FeatureSnapshot)
+	current := make(map[string]*Feature)
+	flush := func() error {
+		if err := fs.writeSnapshots(ctx, snapshots); err != nil {
+			return err
+		}
+		snapshots = make(map[string]FeatureSnapshot)
+		return nil
+	}
+
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		entityID := fmt.Sprint(columnValue(values[entityIndex]))
+		featureTime := now
+		if timeIndex >= 0 {
+			if featureTime, err = columnTime(values[timeIndex]); err != nil {
+				return nil, fmt.Errorf("entity %s: %w", entityID, err)
+			}
+		}
+		var expiresAt *time.Time
+		if ttl := group.TTL(); ttl > 0 {
+			expires := featureTime.Add(ttl)
+			expiresAt = &expires
+		}
+
+		snapshot := FeatureSnapshot{
+			GroupName:   group.Name,
+			EntityType:  group.EntityType,
+			EntityID:    entityID,
+			Values:      make(map[string]interface{}, len(featureNames)),
+			FeatureTime: featureTime,
+		}
+		for i, column := range columns {
+			if i == entityIndex || i == timeIndex {
+				continue
+			}
+			value := columnValue(values[i])
+			snapshot.Values[column] = value
+
+			id := fmt.Sprintf("%s:%s:%s", group.EntityType, entityID, column)
+			if existing, ok := current[id]; ok && existing.ComputedAt.After(featureTime) {
+				continue
+			}
+			current[id] = &Feature{
+				ID:         id,
+				Name:       column,
+				EntityType: group.EntityType,
+				EntityID:   entityID,
+				Values:     map[string]interface{}{column: value},
+				Version:    group.Version,
+				ComputedAt: featureTime,
+				ExpiresAt:  expiresAt,
+				Metadata:   map[string]string{"group": group.Name},
+			}
+		}
+		snapshots[entityID+"\x00"+featureTime.String()] = snapshot
+		result.Rows++
+
+		if len(snapshots) >= materializeBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if err := fs.writeFeatures(ctx, current); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// writeSnapshots upserts snapshots, replacing the values of any taken
+// at the same time
+func (fs *FeatureStore) writeSnapshots(ctx context.Context, snapshots map[string]FeatureSnapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	batch := make([]FeatureSnapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		batch = append(batch, snapshot)
+	}
+
+	err := fs.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "group_name"}, {Name: "entity_id"}, {Name: "feature_time"}},
+		DoUpdates: clause.AssignmentColumns([]string{"values"}),
+	}).CreateInBatches(&batch, materializeBatchSize).Error
+	if err != nil {
+		return fmt.Errorf("failed to store feature snapshots: %w", err)
+	}
+	return nil
+}
+
+// writeFeatures upserts current features and drops the replaced ones from
+// the cache
+func (fs *FeatureStore) writeFeatures(ctx context.Context, features map[string]*Feature) error {
+	if len(features) == 0 {
+		return nil
+	}
+	batch := make([]*Feature, 0, len(features))
+	for _, feature := range features {
+		batch = append(batch, feature)
+	}
+
+	err := fs.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(batch, materializeBatchSize).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store features: %w", err)
+	}
+
+	fs.mu.Lock()
+	for id := range features {
+		delete(fs.cache, id)
+	}
+	fs.mu.Unlock()
+	return nil
+}
+
+// GetHistoricalFeatures returns a group's values for each entity as they
+// were known at its timestamp, for building training datasets without
+// leaking later values. Values older than the group's TTL at the timestamp
+// are not returned. Results are in the order of entities.
+func (fs *FeatureStore) GetHistoricalFeatures(ctx context.Context, groupName string, entities []EntityTimestamp) ([]HistoricalFeatures, error) {
+	group, err := fs.GetFeatureGroup(ctx, groupName)
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, nil
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	earliest, latest := entities[0].Timestamp, entities[0].Timestamp
+	for _, entity := range entities {
+		if !seen[entity.EntityID] {
+			seen[entity.EntityID] = true
+			ids = append(ids, entity.EntityID)
+		}
+		if entity.Timestamp.Before(earliest) {
+			earliest = entity.Timestamp
+		}
+		if entity.Timestamp.After(latest) {
+			latest = entity.Timestamp
+		}
+	}
+
+	// Load each entity's snapshots in the window, oldest first
+	ttl := group.TTL()
+	history := make(map[string][]FeatureSnapshot, len(ids))
+	for start := 0; start < len(ids); start += materializeBatchSize {
+		end := start + materializeBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		query := fs.db.WithContext(ctx).
+			Where("group_name = ? AND entity_id IN ? AND feature_time <= ?", group.Name, ids[start:end], latest)
+		if ttl > 0 {
+			query = query.Where("feature_time >= ?", earliest.Add(-ttl))
+		}
+		var snapshots []FeatureSnapshot
+		if err := query.Order("feature_time ASC").Find(&snapshots).Error; err != nil {
+			return nil, err
+		}
+		for _, snapshot := range snapshots {
+			history[snapshot.EntityID] = append(history[snapshot.EntityID], snapshot)
+		}
+	}
+
+	results := make([]HistoricalFeatures, 0, len(entities))
+	for _, entity := range entities {
+		result := HistoricalFeatures{EntityID: entity.EntityID, Timestamp: entity.Timestamp}
+
+		// The latest snapshot at or before the timestamp
+		snapshots := history[entity.EntityID]
+		i := sort.Search(len(snapshots), func(i int) bool {
+			return snapshots[i].FeatureTime.After(entity.Timestamp)
+		}) - 1
+		if i >= 0 && (ttl == 0 || !snapshots[i].FeatureTime.Before(entity.Timestamp.Add(-ttl))) {
+			featureTime := snapshots[i].FeatureTime
+			result.FeatureTime = &featureTime
+			result.Values = selectFeatures(snapshots[i].Values, group.Features)
+			result.Found = true
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// CheckFreshness reports whether a feature group was materialized within
+// its TTL, and how many entities hold expired values
+func (fs *FeatureStore) CheckFreshness(ctx context.Context, groupName string) (*FeatureFreshness, error) {
+	group, err := fs.GetFeatureGroup(ctx, groupName)
+	if err != nil {
+		return nil, err
+	}
+	return fs.freshness(ctx, group)
+}
+
+// StaleFeatureGroups returns the freshness of every group with a TTL that
+// was not materialized within it
+func (fs *FeatureStore) StaleFeatureGroups(ctx context.Context) ([]FeatureFreshness, error) {
+	var groups []FeatureGroup
+	if err := fs.db.WithContext(ctx).Where("ttl_seconds > 0").Order("name ASC").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+
+	var stale []FeatureFreshness
+	for i := range groups {
+		freshness, err := fs.freshness(ctx, &groups[i])
+		if err != nil {
+			return nil, err
+		}
+		if freshness.Stale {
+			stale = append(stale, *freshness)
+		}
+	}
+	return stale, nil
+}
+
+func (fs *FeatureStore) freshness(ctx context.Context, group *FeatureGroup) (*FeatureFreshness, error) {
+	now := time.Now()
+	freshness := &FeatureFreshness{
+		Group:              group.Name,
+		TTL:                group.TTL(),
+		LastMaterializedAt: group.LastMaterializedAt,
+		LastError:          group.LastMaterializeError,
+	}
+	if group.LastMaterializedAt != nil {
+		freshness.Age = now.Sub(*group.LastMaterializedAt)
+	}
+	if freshness.TTL == 0 {
+		return freshness, nil
+	}
+	freshness.Stale = group.LastMaterializedAt == nil || freshness.Age > freshness.TTL
+
+	if len(group.Features) > 0 {
+		err := fs.db.WithContext(ctx).Model(&Feature{}).
+			Where("entity_type = ? AND name IN ? AND expires_at IS NOT NULL AND expires_at < ?", group.EntityType, group.Features, now).
+			Distinct("entity_id").
+			Count(&freshness.StaleEntities).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return freshness, nil
+}
+
+// SetScheduler materializes every feature group with a query and a
+// schedule on s, including groups created or updated later
+func (fs *FeatureStore) SetScheduler(ctx context.Context, s *scheduler.Scheduler) error {
+	fs.mu.Lock()
+	fs.scheduler = s
+	fs.mu.Unlock()
+
+	var groups []FeatureGroup
+	if err := fs.db.WithContext(ctx).Where("schedule_seconds > 0").Find(&groups).Error; err != nil {
+		return err
+	}
+	for i := range groups {
+		if err := fs.scheduleGroup(&groups[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scheduleGroup adds, replaces or removes a group's materialization job
+func (fs *FeatureStore) scheduleGroup(group *FeatureGroup) error {
+	fs.mu.RLock()
+	s := fs.scheduler
+	fs.mu.RUnlock()
+	if s == nil {
+		return nil
+	}
+
+	name := "feature_group:" + group.Name
+	if group.Query == "" || group.ScheduleSeconds <= 0 {
+		s.Remove(name)
+		return nil
+	}
+
+	groupName := group.Name
+	return s.Replace(scheduler.Job{
+		Name:     name,
+		Interval: time.Duration(group.ScheduleSeconds) * time.Second,
+		Run: func(ctx context.Context) error {
+			_, err := fs.Materialize(ctx, groupName)
+			return err
+		},
+	})
+}
+
+// selectFeatures returns the named values, or all of them when no names
+// are given
+func selectFeatures(values map[string]interface{}, names []string) map[string]interface{} {
+	if len(names) == 0 {
+		return values
+	}
+	selected := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		if value, ok := values[name]; ok {
+			selected[name] = value
+		}
+	}
+	return selected
+}
+
+// columnValue converts a scanned column for JSON storage
+func columnValue(value interface{}) interface{} {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return value
+}
+
+// columnTime reads an event time column
+func columnTime(value interface{}) (time.Time, error) {
+	switch v := columnValue(value).(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+	case int64:
+		return time.Unix(v, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid feature timestamp %v", value)
+}
@@ -7,30 +7,34 @@ import (
 	"sync"
 	"time"
 
+	"neonexcore/pkg/scheduler"
+
 	"gorm.io/gorm"
 )
 
 // FeatureStore stores and manages ML features
 type FeatureStore struct {
-	db         *gorm.DB
-	cache      map[string]*Feature
-	cacheTTL   time.Duration
-	mu         sync.RWMutex
+	db        *gorm.DB
+	cache     map[string]*Feature
+	cacheTTL  time.Duration
+	vectors   VectorStore
+	scheduler *scheduler.Scheduler
+	mu        sync.RWMutex
 }
 
 // Feature represents a machine learning feature
 type Feature struct {
-	ID          string                 `json:"id" gorm:"primaryKey"`
-	Name        string                 `json:"name" gorm:"index"`
-	EntityType  string                 `json:"entity_type"` // user, product, etc.
-	EntityID    string                 `json:"entity_id" gorm:"index"`
-	Values      map[string]interface{} `json:"values" gorm:"type:jsonb"`
-	Version     int                    `json:"version"`
-	ComputedAt  time.Time              `json:"computed_at"`
-	ExpiresAt   *time.Time             `json:"expires_at,omitempty"`
-	Metadata    map[string]string      `json:"metadata" gorm:"type:jsonb"`
-	CreatedAt   time.Time              `json:"created_at"`
-	UpdatedAt   time.Time              `json:"updated_at"`
+	ID         string                 `json:"id" gorm:"primaryKey"`
+	Name       string                 `json:"name" gorm:"index"`
+	EntityType string                 `json:"entity_type"` // user, product, etc.
+	EntityID   string                 `json:"entity_id" gorm:"index"`
+	Values     map[string]interface{} `json:"values" gorm:"type:jsonb;serializer:json"`
+	Version    int                    `json:"version"`
+	ComputedAt time.Time              `json:"computed_at"`
+	ExpiresAt  *time.Time             `json:"expires_at,omitempty"`
+	Metadata   map[string]string      `json:"metadata" gorm:"type:jsonb;serializer:json"`
+	CreatedAt  time.Time              `json:"created_at"`
+	UpdatedAt  time.Time              `json:"updated_at"`
 }
 
 // FeatureGroup groups related features
@@ -38,12 +42,24 @@ type FeatureGroup struct {
 	ID          string            `json:"id" gorm:"primaryKey"`
 	Name        string            `json:"name" gorm:"uniqueIndex"`
 	Description string            `json:"description"`
-	Features    []string          `json:"features" gorm:"type:jsonb"`
+	Features    []string          `json:"features" gorm:"type:jsonb;serializer:json"`
 	EntityType  string            `json:"entity_type"`
 	Version     int               `json:"version"`
-	Metadata    map[string]string `json:"metadata" gorm:"type:jsonb"`
-	CreatedAt   time.Time         `json:"created_at"`
-	UpdatedAt   time.Time         `json:"updated_at"`
+	Metadata    map[string]string `json:"metadata" gorm:"type:jsonb;serializer:json"`
+
+	// Materialization, see Materialize
+	Query           string `json:"query,omitempty" gorm:"type:text"` // SQL returning an entity column and a column per feature
+	EntityColumn    string `json:"entity_column,omitempty"`          // Defaults to entity_id
+	TimestampColumn string `json:"timestamp_column,omitempty"`       // Event time of each row; the run time when empty
+	ScheduleSeconds int64  `json:"schedule_seconds"`                 // Materialize every N seconds; 0 for on demand
+	TTLSeconds      int64  `json:"ttl_seconds"`                      // Values older than this are stale; 0 for never
+
+	LastMaterializedAt   *time.Time `json:"last_materialized_at,omitempty"`
+	LastMaterializedRows int        `json:"last_materialized_rows"`
+	LastMaterializeError string     `json:"last_materialize_error,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // NewFeatureStore creates a new feature store
@@ -55,7 +71,7 @@ func NewFeatureStore(db *gorm.DB) *FeatureStore {
 	}
 
 	// Auto-migrate
-	db.AutoMigrate(&Feature{}, &FeatureGroup{})
+	db.AutoMigrate(&Feature{}, &FeatureGroup{}, &FeatureSnapshot{})
 
 	// Start cleanup goroutine
 	go store.cleanupLoop()
@@ -148,9 +164,25 @@ func (fs *FeatureStore) GetFeatureVector(ctx context.Context, entityType, entity
 	return vector, nil
 }
 
-// CreateFeatureGroup creates a feature group
+// CreateFeatureGroup creates a feature group, scheduling its
+// materialization when it has a query and a schedule
 func (fs *FeatureStore) CreateFeatureGroup(ctx context.Context, group *FeatureGroup) error {
-	return fs.db.WithContext(ctx).Create(group).Error
+	if group.ID == "" {
+		group.ID = group.Name
+	}
+	if err := fs.db.WithContext(ctx).Create(group).Error; err != nil {
+		return err
+	}
+	return fs.scheduleGroup(group)
+}
+
+// UpdateFeatureGroup saves a feature group and reschedules its
+// materialization
+func (fs *FeatureStore) UpdateFeatureGroup(ctx context.Context, group *FeatureGroup) error {
+	if err := fs.db.WithContext(ctx).Save(group).Error; err != nil {
+		return err
+	}
+	return fs.scheduleGroup(group)
 }
 
 // GetFeatureGroup gets a feature group
@@ -257,6 +289,54 @@ func (fs *FeatureStore) ImportFeatures(ctx context.Context, data []byte) error {
 	return fs.BatchSetFeatures(ctx, features)
 }
 
+// SetVectorStore sets the store that holds entity embeddings
+func (fs *FeatureStore) SetVectorStore(store VectorStore) {
+	fs.vectors = store
+}
+
+// SetEmbedding stores an entity's embedding in the vector store
+func (fs *FeatureStore) SetEmbedding(ctx context.Context, entityType, entityID string, values []float32, metadata map[string]interface{}) error {
+	if fs.vectors == nil {
+		return fmt.Errorf("feature store has no vector store")
+	}
+
+	meta := map[string]interface{}{
+		"entity_type": entityType,
+		"entity_id":   entityID,
+	}
+	for k, v := range metadata {
+		meta[k] = v
+	}
+
+	return fs.vectors.Upsert(ctx, []Vector{{
+		ID:       fmt.Sprintf("%s:%s", entityType, entityID),
+		Values:   values,
+		Metadata: meta,
+	}})
+}
+
+// SimilarEntities finds the entities of a type whose embeddings are
+// closest to a vector
+func (fs *FeatureStore) SimilarEntities(ctx context.Context, entityType string, values []float32, topK int) ([]VectorMatch, error) {
+	if fs.vectors == nil {
+		return nil, fmt.Errorf("feature store has no vector store")
+	}
+
+	return fs.vectors.Query(ctx, VectorQuery{
+		Vector: values,
+		TopK:   topK,
+		Filter: VectorFilter{"entity_type": entityType},
+	})
+}
+
+// DeleteEmbedding removes an entity's embedding
+func (fs *FeatureStore) DeleteEmbedding(ctx context.Context, entityType, entityID string) error {
+	if fs.vectors == nil {
+		return nil
+	}
+	return fs.vectors.Delete(ctx, []string{fmt.Sprintf("%s:%s", entityType, entityID)})
+}
+
 // cleanupLoop periodically cleans up expired features
 func (fs *FeatureStore) cleanupLoop() {
 	ticker := time.NewTicker(1 * time.Hour)
@@ -0,0 +1,264 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrGuardrailRejected is returned when a guardrail rejects a model's
+// input or output
+var ErrGuardrailRejected = errors.New("rejected by guardrail")
+
+// Guardrail checks text going into or coming out of a model
+type Guardrail interface {
+	Name() string
+	Check(ctx context.Context, text string) (*GuardrailResult, error)
+}
+
+// GuardrailResult is the outcome of a check. Text passes when there are no
+// violations.
+type GuardrailResult struct {
+	Violations  []string
+	Sanitized   string // The text with the violations removed, if Sanitizable
+	Sanitizable bool
+	Correction  string // Instruction asking the model to fix its output
+}
+
+// Passed reports whether the text had no violations
+func (r *GuardrailResult) Passed() bool {
+	return len(r.Violations) == 0
+}
+
+// GuardrailStage is the side of a model call a guardrail checks
+type GuardrailStage string
+
+const (
+	GuardrailInput  GuardrailStage = "input"  // The prompt, before the call
+	GuardrailOutput GuardrailStage = "output" // The reply, after the call
+)
+
+// GuardrailAction is what happens when a guardrail finds a violation
+type GuardrailAction string
+
+const (
+	GuardrailSanitize GuardrailAction = "sanitize" // Continue with the sanitized text; rejects when it cannot be sanitized
+	GuardrailReject   GuardrailAction = "reject"   // Fail the step
+	GuardrailRetry    GuardrailAction = "retry"    // Call the model again with corrective instructions, then sanitize or reject; output only
+)
+
+// StepGuardrail attaches a guardrail to a model or generate step
+type StepGuardrail struct {
+	Guardrail Guardrail
+	Stage     GuardrailStage  // Defaults to output
+	Action    GuardrailAction // Defaults to reject
+	Retries   int             // Retries before rejecting (default 1)
+}
+
+// GuardrailError describes a rejection
+type GuardrailError struct {
+	Guardrail  string
+	Stage      GuardrailStage
+	Violations []string
+}
+
+func (e *GuardrailError) Error() string {
+	return fmt.Sprintf("%s %s %s: %s", e.Stage, ErrGuardrailRejected, e.Guardrail, strings.Join(e.Violations, "; "))
+}
+
+// Unwrap makes GuardrailError match ErrGuardrailRejected
+func (e *GuardrailError) Unwrap() error {
+	return ErrGuardrailRejected
+}
+
+func (g *StepGuardrail) stage() GuardrailStage {
+	if g.Stage == "" {
+		return GuardrailOutput
+	}
+	return g.Stage
+}
+
+func (g *StepGuardrail) action() GuardrailAction {
+	if g.Action == "" {
+		return GuardrailReject
+	}
+	return g.Action
+}
+
+// guardedPredict runs a step's model call through its guardrails. Input
+// guardrails check a text prompt before the call; output guardrails check
+// the reply, which is rewritten when sanitized. The violations that were
+// sanitized are listed in the output metadata under "guardrails".
+func (pm *PipelineManager) guardedPredict(ctx context.Context, step *PipelineStep, input *InferenceInput) (*InferenceOutput, error) {
+	var sanitized []string
+
+	if prompt, ok := input.Data.(string); ok {
+		for i := range step.Guardrails {
+			guard := &step.Guardrails[i]
+			if guard.stage() != GuardrailInput {
+				continue
+			}
+			result, err := guard.Guardrail.Check(ctx, prompt)
+			if err != nil {
+				return nil, fmt.Errorf("guardrail %s: %w", guard.Guardrail.Name(), err)
+			}
+			if result.Passed() {
+				continue
+			}
+			if guard.action() != GuardrailSanitize || !result.Sanitizable {
+				return nil, &GuardrailError{Guardrail: guard.Guardrail.Name(), Stage: GuardrailInput, Violations: result.Violations}
+			}
+			prompt = result.Sanitized
+			sanitized = append(sanitized, prefixViolations(guard.Guardrail.Name(), result.Violations)...)
+		}
+		guarded := *input
+		guarded.Data = prompt
+		input = &guarded
+	}
+
+	retries := make([]int, len(step.Guardrails))
+	call := input
+	for {
+		output, err := pm.modelManager.Predict(ctx, call)
+		if err != nil {
+			return nil, err
+		}
+		text, isText := outputText(output.Result)
+		if !isText {
+			return withGuardrailMetadata(output, sanitized), nil
+		}
+
+		var correction string
+		for i := range step.Guardrails {
+			guard := &step.Guardrails[i]
+			if guard.stage() != GuardrailOutput {
+				continue
+			}
+			result, err := guard.Guardrail.Check(ctx, text)
+			if err != nil {
+				return nil, fmt.Errorf("guardrail %s: %w", guard.Guardrail.Name(), err)
+			}
+			if result.Passed() {
+				continue
+			}
+
+			limit := guard.Retries
+			if limit <= 0 {
+				limit = 1
+			}
+			switch {
+			case guard.action() == GuardrailRetry && retries[i] < limit:
+				retries[i]++
+				correction = correctionFor(guard.Guardrail.Name(), result)
+			case guard.action() != GuardrailReject && result.Sanitizable:
+				text = result.Sanitized
+				sanitized = append(sanitized, prefixViolations(guard.Guardrail.Name(), result.Violations)...)
+				continue
+			default:
+				return nil, &GuardrailError{Guardrail: guard.Guardrail.Name(), Stage: GuardrailOutput, Violations: result.Violations}
+			}
+			break
+		}
+
+		if correction == "" {
+			output.Result = replaceOutputText(output.Result, text)
+			return withGuardrailMetadata(output, sanitized), nil
+		}
+
+		// Ask again with the original prompt, the rejected reply and what to fix
+		retry := *input
+		retry.Data = fmt.Sprintf("%s\n\nYour previous response was:\n%s\n\n%s", promptText(input), text, correction)
+		call = &retry
+	}
+}
+
+func correctionFor(name string, result *GuardrailResult) string {
+	if result.Correction != "" {
+		return result.Correction
+	}
+	return fmt.Sprintf("It was rejected by the %s check (%s). Respond again without these problems.", name, strings.Join(result.Violations, "; "))
+}
+
+func prefixViolations(name string, violations []string) []string {
+	prefixed := make([]string, len(violations))
+	for i, violation := range violations {
+		prefixed[i] = name + ": " + violation
+	}
+	return prefixed
+}
+
+func withGuardrailMetadata(output *InferenceOutput, sanitized []string) *InferenceOutput {
+	if len(sanitized) == 0 {
+		return output
+	}
+	metadata := make(map[string]interface{}, len(output.Metadata)+1)
+	for k, v := range output.Metadata {
+		metadata[k] = v
+	}
+	metadata["guardrails"] = sanitized
+	guarded := *output
+	guarded.Metadata = metadata
+	return &guarded
+}
+
+// OutputText returns the reply text of a chat or completion result, or a
+// plain string result
+func OutputText(result interface{}) (string, bool) {
+	return outputText(result)
+}
+
+// outputText returns the reply text of a chat or completion result, or a
+// plain string result
+func outputText(result interface{}) (string, bool) {
+	if text, ok := result.(string); ok {
+		return text, true
+	}
+	data, ok := result.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	if choices, _ := data["choices"].([]interface{}); len(choices) > 0 {
+		text, _ := resultText(result)
+		return text, true
+	}
+	return "", false
+}
+
+// replaceOutputText returns the result with its reply text replaced,
+// copying rather than changing a cached result
+func replaceOutputText(result interface{}, text string) interface{} {
+	data, ok := result.(map[string]interface{})
+	if !ok {
+		return text
+	}
+	current, _ := resultText(result)
+	if current == text {
+		return result
+	}
+
+	choices, _ := data["choices"].([]interface{})
+	choice, _ := choices[0].(map[string]interface{})
+	newChoice := make(map[string]interface{}, len(choice))
+	for k, v := range choice {
+		newChoice[k] = v
+	}
+	if message, ok := choice["message"].(map[string]interface{}); ok {
+		newMessage := make(map[string]interface{}, len(message))
+		for k, v := range message {
+			newMessage[k] = v
+		}
+		newMessage["content"] = text
+		newChoice["message"] = newMessage
+	} else {
+		newChoice["text"] = text
+	}
+
+	newChoices := append([]interface{}{newChoice}, choices[1:]...)
+	newData := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		newData[k] = v
+	}
+	newData["choices"] = newChoices
+	return newData
+}
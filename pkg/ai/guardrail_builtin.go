@@ -0,0 +1,506 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// NewGuardrail creates a built-in guardrail by kind: "pii", "profanity",
+// "json_schema" or "max_tokens"
+func NewGuardrail(kind string, config map[string]interface{}) (Guardrail, error) {
+	switch kind {
+	case "pii":
+		return NewPIIGuardrail(stringsParam(config, "types")...)
+	case "profanity":
+		return NewProfanityGuardrail(stringsParam(config, "terms")...), nil
+	case "json_schema":
+		schema, ok := config["schema"]
+		if !ok {
+			return nil, fmt.Errorf("json_schema guardrail requires a schema")
+		}
+		if text, isText := schema.(string); isText {
+			return NewJSONSchemaGuardrail([]byte(text))
+		}
+		data, err := json.Marshal(normalizeYAML(schema))
+		if err != nil {
+			return nil, fmt.Errorf("json_schema guardrail: %w", err)
+		}
+		return NewJSONSchemaGuardrail(data)
+	case "max_tokens":
+		max := intParam(config, "max", 0)
+		if max <= 0 {
+			return nil, fmt.Errorf("max_tokens guardrail requires a positive max")
+		}
+		return &MaxTokensGuardrail{Max: max}, nil
+	default:
+		return nil, fmt.Errorf("unknown guardrail: %s", kind)
+	}
+}
+
+func buildGuardrail(def GuardrailDefinition) (StepGuardrail, error) {
+	guard, err := NewGuardrail(def.Type, def.Config)
+	if err != nil {
+		return StepGuardrail{}, err
+	}
+	step := StepGuardrail{
+		Guardrail: guard,
+		Stage:     GuardrailStage(def.Stage),
+		Action:    GuardrailAction(def.Action),
+		Retries:   def.Retries,
+	}
+	switch step.Stage {
+	case "", GuardrailInput, GuardrailOutput:
+	default:
+		return StepGuardrail{}, fmt.Errorf("guardrail %s: unknown stage: %s", def.Type, def.Stage)
+	}
+	switch step.Action {
+	case "", GuardrailSanitize, GuardrailReject:
+	case GuardrailRetry:
+		if step.Stage == GuardrailInput {
+			return StepGuardrail{}, fmt.Errorf("guardrail %s: retry only applies to output", def.Type)
+		}
+	default:
+		return StepGuardrail{}, fmt.Errorf("guardrail %s: unknown action: %s", def.Type, def.Action)
+	}
+	return step, nil
+}
+
+// PII types detected by PIIGuardrail
+const (
+	PIIEmail      = "email"
+	PIIPhone      = "phone"
+	PIICreditCard = "credit_card"
+	PIISSN        = "ssn"
+	PIIIPAddress  = "ip_address"
+)
+
+var piiPatterns = map[string]*regexp.Regexp{
+	PIIEmail:      regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	PIICreditCard: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+	PIISSN:        regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+	PIIPhone:      regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{2,4}\)[ .-]?|\b\d{2,4}[ .-])\d{3,4}[ .-]\d{3,4}\b`),
+	PIIIPAddress:  regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`),
+}
+
+// Credit cards and SSNs are matched before phone numbers, which would
+// otherwise claim their digits
+var piiOrder = []string{PIIEmail, PIICreditCard, PIISSN, PIIPhone, PIIIPAddress}
+
+// PIIGuardrail detects personal data and redacts it to placeholders such
+// as [EMAIL]
+type PIIGuardrail struct {
+	types []string
+}
+
+// NewPIIGuardrail creates a PII guardrail for the given types, or all of
+// them
+func NewPIIGuardrail(types ...string) (*PIIGuardrail, error) {
+	if len(types) == 0 {
+		return &PIIGuardrail{types: piiOrder}, nil
+	}
+	selected := make([]string, 0, len(types))
+	for _, kind := range piiOrder {
+		for _, t := range types {
+			if t == kind {
+				selected = append(selected, kind)
+				break
+			}
+		}
+	}
+	for _, t := range types {
+		if _, ok := piiPatterns[t]; !ok {
+			return nil, fmt.Errorf("unknown PII type: %s", t)
+		}
+	}
+	return &PIIGuardrail{types: selected}, nil
+}
+
+// Name returns the guardrail name
+func (g *PIIGuardrail) Name() string {
+	return "pii"
+}
+
+// Check finds and redacts PII
+func (g *PIIGuardrail) Check(ctx context.Context, text string) (*GuardrailResult, error) {
+	counts := make(map[string]int)
+	sanitized := text
+	for _, kind := range g.types {
+		placeholder := "[" + strings.ToUpper(kind) + "]"
+		sanitized = piiPatterns[kind].ReplaceAllStringFunc(sanitized, func(match string) string {
+			if kind == PIICreditCard && !luhnValid(match) {
+				return match
+			}
+			counts[kind]++
+			return placeholder
+		})
+	}
+
+	result := &GuardrailResult{Sanitized: sanitized, Sanitizable: true}
+	for _, kind := range g.types {
+		if counts[kind] > 0 {
+			result.Violations = append(result.Violations, fmt.Sprintf("%s (%d)", kind, counts[kind]))
+		}
+	}
+	if !result.Passed() {
+		result.Correction = "Do not include personal data such as email addresses, phone numbers, card numbers or ID numbers in your response."
+	}
+	return result, nil
+}
+
+func luhnValid(number string) bool {
+	sum, digits := 0, 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+		double = !double
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+// DefaultProfanity is the word list ProfanityGuardrail starts with
+var DefaultProfanity = []string{
+	"asshole", "bastard", "bitch", "bollocks", "bullshit", "cunt", "dick",
+	"fuck", "fucked", "fucker", "fucking", "motherfucker", "piss", "prick",
+	"shit", "shitty", "slut", "twat", "wanker", "whore",
+}
+
+// ProfanityGuardrail finds profane words, matched whole and ignoring case,
+// and masks them with asterisks
+type ProfanityGuardrail struct {
+	terms map[string]bool
+}
+
+// NewProfanityGuardrail creates a profanity guardrail with the default
+// list plus extra terms
+func NewProfanityGuardrail(terms ...string) *ProfanityGuardrail {
+	g := &ProfanityGuardrail{terms: make(map[string]bool)}
+	for _, term := range append(DefaultProfanity, terms...) {
+		g.terms[strings.ToLower(term)] = true
+	}
+	return g
+}
+
+// Name returns the guardrail name
+func (g *ProfanityGuardrail) Name() string {
+	return "profanity"
+}
+
+// Check finds and masks profanity
+func (g *ProfanityGuardrail) Check(ctx context.Context, text string) (*GuardrailResult, error) {
+	found := make(map[string]bool)
+	var b strings.Builder
+	var word []rune
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		w := string(word)
+		if lower := strings.ToLower(w); g.terms[lower] {
+			found[lower] = true
+			w = strings.Repeat("*", len(word))
+		}
+		b.WriteString(w)
+		word = word[:0]
+	}
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+
+	result := &GuardrailResult{Sanitized: b.String(), Sanitizable: true}
+	if len(found) > 0 {
+		words := make([]string, 0, len(found))
+		for w := range found {
+			words = append(words, w)
+		}
+		sort.Strings(words)
+		result.Violations = []string{"profanity: " + strings.Join(words, ", ")}
+		result.Correction = "Respond again without profanity."
+	}
+	return result, nil
+}
+
+// JSONSchemaGuardrail checks that output is JSON matching a schema. It
+// supports the common JSON Schema keywords: type, properties, required,
+// additionalProperties, items, enum, minLength, maxLength, pattern,
+// minimum, maximum, minItems and maxItems. Valid JSON wrapped in a
+// markdown code fence is sanitized to the bare JSON.
+type JSONSchemaGuardrail struct {
+	schema map[string]interface{}
+	raw    string
+}
+
+// NewJSONSchemaGuardrail creates a guardrail from a JSON schema document
+func NewJSONSchemaGuardrail(schema []byte) (*JSONSchemaGuardrail, error) {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(schema, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	return &JSONSchemaGuardrail{schema: parsed, raw: string(schema)}, nil
+}
+
+// Name returns the guardrail name
+func (g *JSONSchemaGuardrail) Name() string {
+	return "json_schema"
+}
+
+// Check validates the text against the schema
+func (g *JSONSchemaGuardrail) Check(ctx context.Context, text string) (*GuardrailResult, error) {
+	trimmed := strings.TrimSpace(text)
+	unfenced := stripCodeFence(trimmed)
+
+	result := &GuardrailResult{}
+	var value interface{}
+	if err := json.Unmarshal([]byte(unfenced), &value); err != nil {
+		result.Violations = []string{"not valid JSON: " + err.Error()}
+	} else {
+		result.Violations = validateSchema(g.schema, value, "$")
+		if len(result.Violations) == 0 && unfenced != text {
+			// Valid, but wrapped in a fence or whitespace
+			result.Violations = []string{"JSON is not bare"}
+			result.Sanitized, result.Sanitizable = unfenced, true
+		}
+	}
+	if !result.Passed() {
+		result.Correction = "Respond with only a JSON document, without a code fence or other text, matching this JSON schema:\n" + g.raw
+	}
+	return result, nil
+}
+
+func stripCodeFence(text string) string {
+	if !strings.HasPrefix(text, "```") || !strings.HasSuffix(text, "```") || len(text) < 6 {
+		return text
+	}
+	inner := text[3 : len(text)-3]
+	if newline := strings.IndexByte(inner, '\n'); newline >= 0 {
+		inner = inner[newline+1:] // Drop the language tag line
+	}
+	return strings.TrimSpace(inner)
+}
+
+// validateSchema returns the ways value does not match schema, each
+// prefixed with the JSON path
+func validateSchema(schema map[string]interface{}, value interface{}, path string) []string {
+	var violations []string
+	fail := func(format string, args ...interface{}) {
+		violations = append(violations, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if expected, ok := schema["type"]; ok && !matchesSchemaType(expected, value) {
+		fail("expected %v, got %s", expected, jsonType(value))
+		return violations
+	}
+	if allowed, ok := schema["enum"].([]interface{}); ok {
+		match := false
+		for _, candidate := range allowed {
+			if fmt.Sprint(candidate) == fmt.Sprint(value) && jsonType(candidate) == jsonType(value) {
+				match = true
+				break
+			}
+		}
+		if !match {
+			fail("%v is not one of %v", value, allowed)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := len([]rune(v))
+		if min, ok := toFloat(schema["minLength"]); ok && float64(length) < min {
+			fail("shorter than %v characters", min)
+		}
+		if max, ok := toFloat(schema["maxLength"]); ok && float64(length) > max {
+			fail("longer than %v characters", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				fail("does not match %s", pattern)
+			}
+		}
+	case float64:
+		if min, ok := toFloat(schema["minimum"]); ok && v < min {
+			fail("%v is below the minimum of %v", v, min)
+		}
+		if max, ok := toFloat(schema["maximum"]); ok && v > max {
+			fail("%v is above the maximum of %v", v, max)
+		}
+	case []interface{}:
+		if min, ok := toFloat(schema["minItems"]); ok && float64(len(v)) < min {
+			fail("fewer than %v items", min)
+		}
+		if max, ok := toFloat(schema["maxItems"]); ok && float64(len(v)) > max {
+			fail("more than %v items", max)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				violations = append(violations, validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, key := range required {
+				if name, _ := key.(string); name != "" {
+					if _, present := v[name]; !present {
+						fail("missing required property %q", name)
+					}
+				}
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if property, ok := properties[key].(map[string]interface{}); ok {
+				violations = append(violations, validateSchema(property, v[key], path+"."+key)...)
+			} else if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				fail("unexpected property %q", key)
+			}
+		}
+	}
+	return violations
+}
+
+func matchesSchemaType(expected, value interface{}) bool {
+	var types []interface{}
+	switch t := expected.(type) {
+	case string:
+		types = []interface{}{t}
+	case []interface{}:
+		types = t
+	}
+	actual := jsonType(value)
+	for _, t := range types {
+		switch t {
+		case actual:
+			return true
+		case "number":
+			if actual == "integer" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// normalizeYAML converts the map[interface{}]interface{} values YAML
+// decoding can produce into JSON-compatible maps
+func normalizeYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = normalizeYAML(item)
+		}
+		return converted
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[key] = normalizeYAML(item)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, item := range v {
+			converted[i] = normalizeYAML(item)
+		}
+		return converted
+	default:
+		return value
+	}
+}
+
+// MaxTokensGuardrail limits the estimated token count of text. Sanitizing
+// truncates it.
+type MaxTokensGuardrail struct {
+	Max int
+}
+
+// Name returns the guardrail name
+func (g *MaxTokensGuardrail) Name() string {
+	return "max_tokens"
+}
+
+// Check compares the estimated token count with the limit
+func (g *MaxTokensGuardrail) Check(ctx context.Context, text string) (*GuardrailResult, error) {
+	tokens := EstimateTokens(text)
+	if tokens <= g.Max {
+		return &GuardrailResult{Sanitized: text, Sanitizable: true}, nil
+	}
+	runes := []rune(text)
+	return &GuardrailResult{
+		Violations:  []string{fmt.Sprintf("about %d tokens, limit is %d", tokens, g.Max)},
+		Sanitized:   string(runes[:g.Max*4]),
+		Sanitizable: true,
+		Correction:  fmt.Sprintf("Your response was too long. Respond again in under %d tokens.", g.Max),
+	}, nil
+}
+
+// stringsParam reads a list of strings, or a comma separated string
+func stringsParam(params map[string]interface{}, key string) []string {
+	switch v := params[key].(type) {
+	case string:
+		var values []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		return values
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	}
+	return nil
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"neonexcore/pkg/metrics"
+	"neonexcore/pkg/sandbox"
 )
 
 // ModelType represents the type of AI model
@@ -54,7 +57,14 @@ type Model struct {
 type ModelManager struct {
 	models    map[string]*Model
 	providers map[string]ModelProvider
+	sandbox   ModelProvider // Serves test mode requests
 	cache     *InferenceCache
+	usage     *UsageTracker // Counts tokens and enforces budgets, if set
+	registry  *ModelRegistry
+	aliases   map[string]string // "name@stage" to the model ID serving it
+	batchers  map[string]*batcher // Dynamic batching per model, see batch.go
+	semantic  map[string]*semanticCache // Similar-prompt caching per model, see semantic_cache.go
+	collector *metrics.Collector
 	mu        sync.RWMutex
 }
 
@@ -74,6 +84,7 @@ type ModelConfig struct {
 	Type     ModelType
 	Provider string
 	Endpoint string
+	Path     string // Local model file, for local providers
 	APIKey   string
 	Config   map[string]interface{}
 	Metadata map[string]string
@@ -108,11 +119,111 @@ type ModelMetrics struct {
 
 // NewModelManager creates a new model manager
 func NewModelManager() *ModelManager {
+	sandboxProvider := NewSandboxProvider()
 	return &ModelManager{
 		models:    make(map[string]*Model),
-		providers: make(map[string]ModelProvider),
+		providers: map[string]ModelProvider{"sandbox": sandboxProvider},
+		sandbox:   sandboxProvider,
 		cache:     NewInferenceCache(1000, 1*time.Hour),
+		aliases:   make(map[string]string),
+		batchers:  make(map[string]*batcher),
+		semantic:  make(map[string]*semanticCache),
+	}
+}
+
+// SetSandboxProvider replaces the provider serving test mode requests and
+// registered as "sandbox", e.g. with a scripted SandboxProvider
+func (m *ModelManager) SetSandboxProvider(provider ModelProvider) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sandbox = provider
+	m.providers["sandbox"] = provider
+}
+
+// SetUsageTracker tracks token usage and cost of live requests and
+// enforces the tracker's budgets. Cached and test mode results are free.
+func (m *ModelManager) SetUsageTracker(tracker *UsageTracker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage = tracker
+}
+
+// UsageTracker returns the usage tracker, or nil
+func (m *ModelManager) UsageTracker() *UsageTracker {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.usage
+}
+
+// CheckBudget reports whether a live request would exceed a budget
+func (m *ModelManager) CheckBudget(ctx context.Context, input *InferenceInput) error {
+	tracker := m.UsageTracker()
+	if tracker == nil || sandbox.IsTest(ctx) {
+		return nil
 	}
+	return tracker.Check(ctx, input)
+}
+
+// Registry returns the model registry, or nil
+func (m *ModelManager) Registry() *ModelRegistry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.registry
+}
+
+// PromoteModel moves a registered model version to a rollout stage
+func (m *ModelManager) PromoteModel(ctx context.Context, name, version, stage string) (*ModelVersion, error) {
+	registry := m.Registry()
+	if registry == nil {
+		return nil, fmt.Errorf("no model registry configured")
+	}
+	return registry.Promote(ctx, name, version, stage)
+}
+
+// RollbackModel returns a model's production alias to the version it
+// replaced
+func (m *ModelManager) RollbackModel(ctx context.Context, name string) (*ModelVersion, error) {
+	registry := m.Registry()
+	if registry == nil {
+		return nil, fmt.Errorf("no model registry configured")
+	}
+	return registry.Rollback(ctx, name)
+}
+
+// ResolveModelID returns the model ID serving a "name@stage" alias, or the
+// ID unchanged when it is not an alias
+func (m *ModelManager) ResolveModelID(modelID string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if target, ok := m.aliases[modelID]; ok {
+		return target
+	}
+	return modelID
+}
+
+func (m *ModelManager) setAlias(alias, modelID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if modelID == "" {
+		delete(m.aliases, alias)
+		return
+	}
+	m.aliases[alias] = modelID
+}
+
+// resolveInput returns the input addressed to the model serving its alias,
+// so results are cached and counted per version
+func (m *ModelManager) resolveInput(input *InferenceInput) (*InferenceInput, error) {
+	modelID := m.ResolveModelID(input.ModelID)
+	if modelID == input.ModelID {
+		if name, stage, ok := splitAlias(modelID); ok && m.getModel(modelID) == nil {
+			return nil, fmt.Errorf("model not found: %s has no %s version", name, stage)
+		}
+		return input, nil
+	}
+	resolved := *input
+	resolved.ModelID = modelID
+	return &resolved, nil
 }
 
 // RegisterProvider registers an AI provider
@@ -122,6 +233,41 @@ func (m *ModelManager) RegisterProvider(name string, provider ModelProvider) {
 	m.providers[name] = provider
 }
 
+// RegisterProvidersFromEnv registers the hosted providers whose
+// credentials are set in the environment, as "openai", "anthropic",
+// "gemini" and "azure", plus "ollama" when OLLAMA_HOST is set and "onnx"
+// when ONNXRUNTIME_LIB is set, and returns the names registered
+func (m *ModelManager) RegisterProvidersFromEnv() []string {
+	var registered []string
+
+	if config := LoadOpenAIConfig(); config.APIKey != "" {
+		m.RegisterProvider("openai", NewOpenAIProvider(config))
+		registered = append(registered, "openai")
+	}
+	if config := LoadAnthropicConfig(); config.APIKey != "" {
+		m.RegisterProvider("anthropic", NewAnthropicProvider(config))
+		registered = append(registered, "anthropic")
+	}
+	if config := LoadGeminiConfig(); config.APIKey != "" {
+		m.RegisterProvider("gemini", NewGeminiProvider(config))
+		registered = append(registered, "gemini")
+	}
+	if config := LoadAzureOpenAIConfig(); config.APIKey != "" && config.Endpoint != "" {
+		m.RegisterProvider("azure", NewAzureOpenAIProvider(config))
+		registered = append(registered, "azure")
+	}
+	if config := LoadOllamaConfig(); config.BaseURL != "" {
+		m.RegisterProvider("ollama", NewOllamaProvider(config))
+		registered = append(registered, "ollama")
+	}
+	if config := LoadONNXConfig(); config.LibraryPath != "" {
+		m.RegisterProvider("onnx", NewONNXProvider(config))
+		registered = append(registered, "onnx")
+	}
+
+	return registered
+}
+
 // LoadModel loads a model
 func (m *ModelManager) LoadModel(config *ModelConfig) (*Model, error) {
 	m.mu.Lock()
@@ -162,7 +308,7 @@ func (m *ModelManager) UnloadModel(modelID string) error {
 		return fmt.Errorf("model not found: %s", modelID)
 	}
 	
-	provider := m.getProvider(model.Provider)
+	provider := m.providers[model.Provider]
 	m.mu.Unlock()
 
 	if provider == nil {
@@ -184,9 +330,29 @@ func (m *ModelManager) UnloadModel(modelID string) error {
 
 // Predict performs inference on a model
 func (m *ModelManager) Predict(ctx context.Context, input *InferenceInput) (*InferenceOutput, error) {
+	// Test mode never reaches real providers or shares their cache
+	testMode := sandbox.IsTest(ctx)
+
+	requested := input.ModelID
+	input, err := m.resolveInput(input)
+	if err != nil {
+		return nil, err
+	}
+
 	// Check cache first
-	if cached := m.cache.Get(input); cached != nil {
-		return cached, nil
+	if !testMode {
+		if cached := m.cache.Get(input); cached != nil {
+			return cached, nil
+		}
+	}
+
+	// Then for an answer to a similar prompt
+	var semantic *semanticEntry
+	if c := m.getSemanticCache(input.ModelID, requested); c != nil && !testMode {
+		var cached *InferenceOutput
+		if cached, semantic = c.lookup(ctx, m, input); cached != nil {
+			return cached, nil
+		}
 	}
 
 	// Get model
@@ -201,13 +367,25 @@ func (m *ModelManager) Predict(ctx context.Context, input *InferenceInput) (*Inf
 
 	// Get provider
 	provider := m.getProvider(model.Provider)
+	if testMode {
+		provider = m.getSandboxProvider()
+	}
 	if provider == nil {
 		return nil, fmt.Errorf("provider not found: %s", model.Provider)
 	}
 
+	if err := m.CheckBudget(ctx, input); err != nil {
+		return nil, err
+	}
+
 	// Perform inference
 	startTime := time.Now()
-	output, err := provider.Predict(ctx, input.ModelID, input)
+	var output *InferenceOutput
+	if batcher := m.getBatcher(input.ModelID, requested); batcher != nil && !testMode {
+		output, err = batcher.submit(ctx, provider, input)
+	} else {
+		output, err = provider.Predict(ctx, input.ModelID, input)
+	}
 	if err != nil {
 		model.mu.Lock()
 		model.mu.Unlock()
@@ -224,7 +402,15 @@ func (m *ModelManager) Predict(ctx context.Context, input *InferenceInput) (*Inf
 	output.Timestamp = time.Now()
 
 	// Cache result
-	m.cache.Set(input, output)
+	if !testMode {
+		m.cache.Set(input, output)
+		if semantic != nil {
+			semantic.store(ctx, output)
+		}
+		if tracker := m.UsageTracker(); tracker != nil {
+			tracker.Record(ctx, input, output)
+		}
+	}
 
 	return output, nil
 }
@@ -246,6 +432,12 @@ func (m *ModelManager) getProvider(name string) ModelProvider {
 	return m.providers[name]
 }
 
+func (m *ModelManager) getSandboxProvider() ModelProvider {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sandbox
+}
+
 // ListModels lists all loaded models
 func (m *ModelManager) ListModels() []*Model {
 	m.mu.RLock()
@@ -284,10 +476,15 @@ func (m *ModelManager) GetMetrics(modelID string) *ModelMetrics {
 // GetAllMetrics gets metrics for all models
 func (m *ModelManager) GetAllMetrics() map[string]*ModelMetrics {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
+	ids := make([]string, 0, len(m.models))
+	for id := range m.models {
+		ids = append(ids, id)
+	}
+	m.mu.RUnlock()
 
+	// GetMetrics locks again; nested read locks deadlock with a waiting writer
 	metrics := make(map[string]*ModelMetrics)
-	for id := range m.models {
+	for _, id := range ids {
 		if m := m.GetMetrics(id); m != nil {
 			metrics[id] = m
 		}
@@ -319,9 +516,15 @@ func (m *ModelManager) Close() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// Send requests still waiting for a batch
+	for id, b := range m.batchers {
+		b.flushAll()
+		delete(m.batchers, id)
+	}
+
 	// Unload all models
 	for id, model := range m.models {
-		provider := m.getProvider(model.Provider)
+		provider := m.providers[model.Provider]
 		if provider != nil {
 			provider.UnloadModel(id)
 		}
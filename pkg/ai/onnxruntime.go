@@ -0,0 +1,145 @@
+//go:build onnxruntime
+
+package ai
+
+import (
+	"fmt"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// ONNX Runtime binding, built with -tags onnxruntime. Requires
+// github.com/yalue/onnxruntime_go and the onnxruntime shared library.
+
+func init() {
+	defaultONNXRuntime = newORTRuntime
+}
+
+var ortInit struct {
+	once sync.Once
+	err  error
+}
+
+// ortRuntime opens models with ONNX Runtime
+type ortRuntime struct {
+	threads int
+}
+
+func newORTRuntime(config *ONNXConfig) (ONNXRuntime, error) {
+	ortInit.once.Do(func() {
+		if config.LibraryPath != "" {
+			ort.SetSharedLibraryPath(config.LibraryPath)
+		}
+		ortInit.err = ort.InitializeEnvironment()
+	})
+	if ortInit.err != nil {
+		return nil, fmt.Errorf("onnx: failed to initialize ONNX Runtime: %w", ortInit.err)
+	}
+	return &ortRuntime{threads: config.Threads}, nil
+}
+
+// Open opens a model file
+func (r *ortRuntime) Open(path string) (ONNXSession, error) {
+	inputInfo, outputInfo, err := ort.GetInputOutputInfo(path)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make([]string, len(inputInfo))
+	for i, info := range inputInfo {
+		inputs[i] = info.Name
+	}
+	outputs := make([]string, len(outputInfo))
+	for i, info := range outputInfo {
+		outputs[i] = info.Name
+	}
+
+	options, err := ort.NewSessionOptions()
+	if err != nil {
+		return nil, err
+	}
+	defer options.Destroy()
+	if r.threads > 0 {
+		if err := options.SetIntraOpNumThreads(r.threads); err != nil {
+			return nil, err
+		}
+	}
+
+	session, err := ort.NewDynamicAdvancedSession(path, inputs, outputs, options)
+	if err != nil {
+		return nil, err
+	}
+	return &ortSession{session: session, inputs: inputs, outputs: outputs}, nil
+}
+
+// ortSession is an ONNX Runtime session
+type ortSession struct {
+	session *ort.DynamicAdvancedSession
+	inputs  []string
+	outputs []string
+}
+
+func (s *ortSession) InputNames() []string  { return s.inputs }
+func (s *ortSession) OutputNames() []string { return s.outputs }
+
+// Run runs the model. Missing inputs are an error; outputs are allocated by
+// ONNX Runtime.
+func (s *ortSession) Run(inputs map[string]ONNXTensor) (map[string]ONNXTensor, error) {
+	values := make([]ort.Value, len(s.inputs))
+	defer destroyValues(values)
+
+	for i, name := range s.inputs {
+		tensor, ok := inputs[name]
+		if !ok {
+			return nil, fmt.Errorf("missing input %q", name)
+		}
+		shape := ort.NewShape(tensor.Shape...)
+
+		var value ort.Value
+		var err error
+		if tensor.Int64 != nil {
+			value, err = ort.NewTensor(shape, tensor.Int64)
+		} else {
+			value, err = ort.NewTensor(shape, tensor.Float)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("input %q: %w", name, err)
+		}
+		values[i] = value
+	}
+
+	outputs := make([]ort.Value, len(s.outputs))
+	defer destroyValues(outputs)
+	if err := s.session.Run(values, outputs); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]ONNXTensor, len(outputs))
+	for i, value := range outputs {
+		tensor := ONNXTensor{Shape: []int64(value.GetShape())}
+		switch v := value.(type) {
+		case *ort.Tensor[float32]:
+			tensor.Float = append([]float32(nil), v.GetData()...)
+		case *ort.Tensor[int64]:
+			tensor.Int64 = append([]int64(nil), v.GetData()...)
+		default:
+			continue // Other element types are not used by the provider
+		}
+		result[s.outputs[i]] = tensor
+	}
+	return result, nil
+}
+
+// Close releases the session
+func (s *ortSession) Close() error {
+	return s.session.Destroy()
+}
+
+func destroyValues(values []ort.Value) {
+	for _, value := range values {
+		if value != nil {
+			value.Destroy()
+		}
+	}
+}
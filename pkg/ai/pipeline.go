@@ -24,6 +24,7 @@ type PipelineStep struct {
 	ModelID    string
 	Transform  TransformFunc
 	Parameters map[string]interface{}
+	Guardrails []StepGuardrail // Checks on model and generate steps, see guardrail.go
 }
 
 // StepType represents the type of pipeline step
@@ -34,6 +35,13 @@ const (
 	StepTypeModel       StepType = "model"
 	StepTypePostprocess StepType = "postprocess"
 	StepTypeTransform   StepType = "transform"
+
+	// Retrieval-augmented generation steps, see pipeline_rag.go
+	StepTypeChunk    StepType = "chunk"
+	StepTypeEmbed    StepType = "embed"
+	StepTypeRetrieve StepType = "retrieve"
+	StepTypePrompt   StepType = "prompt"
+	StepTypeGenerate StepType = "generate"
 )
 
 // TransformFunc function for transforming data
@@ -43,6 +51,8 @@ type TransformFunc func(context.Context, interface{}) (interface{}, error)
 type PipelineManager struct {
 	pipelines    map[string]*Pipeline
 	modelManager *ModelManager
+	vectorStores map[string]VectorStore
+	prompts      *PromptRegistry
 	mu           sync.RWMutex
 }
 
@@ -70,9 +80,42 @@ func NewPipelineManager(modelManager *ModelManager) *PipelineManager {
 	return &PipelineManager{
 		pipelines:    make(map[string]*Pipeline),
 		modelManager: modelManager,
+		vectorStores: make(map[string]VectorStore),
 	}
 }
 
+// RegisterVectorStore makes a vector store available to embed and
+// retrieve steps under name; steps without a store parameter use "default"
+func (pm *PipelineManager) RegisterVectorStore(name string, store VectorStore) {
+	pm.mu.Lock()
+	pm.vectorStores[name] = store
+	pm.mu.Unlock()
+}
+
+// SetPromptRegistry lets prompt steps reference registered prompts by
+// name@version
+func (pm *PipelineManager) SetPromptRegistry(registry *PromptRegistry) {
+	pm.mu.Lock()
+	pm.prompts = registry
+	pm.mu.Unlock()
+}
+
+// vectorStore returns a registered vector store
+func (pm *PipelineManager) vectorStore(name string) (VectorStore, error) {
+	if name == "" {
+		name = "default"
+	}
+
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	store, exists := pm.vectorStores[name]
+	if !exists {
+		return nil, fmt.Errorf("vector store not registered: %s", name)
+	}
+	return store, nil
+}
+
 // CreatePipeline creates a new pipeline
 func (pm *PipelineManager) CreatePipeline(pipeline *Pipeline) error {
 	if pipeline.ID == "" {
@@ -145,7 +188,7 @@ func (pm *PipelineManager) Execute(ctx context.Context, pipelineID string, input
 					Data:       currentData,
 					Parameters: step.Parameters,
 				}
-				inferenceOutput, err := pm.modelManager.Predict(ctx, inferenceInput)
+				inferenceOutput, err := pm.guardedPredict(ctx, &step, inferenceInput)
 				if err != nil {
 					stepErr = err
 				} else {
@@ -153,6 +196,9 @@ func (pm *PipelineManager) Execute(ctx context.Context, pipelineID string, input
 				}
 			}
 
+		case StepTypeChunk, StepTypeEmbed, StepTypeRetrieve, StepTypePrompt, StepTypeGenerate:
+			stepOutput, stepErr = pm.executeRAGStep(ctx, &step, currentData)
+
 		default:
 			stepErr = fmt.Errorf("unknown step type: %s", step.Type)
 		}
@@ -0,0 +1,138 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PipelineDefinition YAML/JSON pipeline definition
+type PipelineDefinition struct {
+	ID          string                   `yaml:"id" json:"id"`
+	Name        string                   `yaml:"name" json:"name"`
+	Description string                   `yaml:"description" json:"description"`
+	Config      map[string]interface{}   `yaml:"config,omitempty" json:"config,omitempty"`
+	Steps       []PipelineStepDefinition `yaml:"steps" json:"steps"`
+}
+
+// PipelineStepDefinition YAML/JSON pipeline step definition. Transform
+// names a function in the transform registry.
+type PipelineStepDefinition struct {
+	Name       string                 `yaml:"name" json:"name"`
+	Type       string                 `yaml:"type" json:"type"`
+	ModelID    string                 `yaml:"model_id,omitempty" json:"model_id,omitempty"`
+	Transform  string                 `yaml:"transform,omitempty" json:"transform,omitempty"`
+	Parameters map[string]interface{} `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+	Guardrails []GuardrailDefinition  `yaml:"guardrails,omitempty" json:"guardrails,omitempty"`
+}
+
+// GuardrailDefinition attaches a guardrail to a step, see NewGuardrail
+type GuardrailDefinition struct {
+	Type    string                 `yaml:"type" json:"type"`
+	Stage   string                 `yaml:"stage,omitempty" json:"stage,omitempty"`
+	Action  string                 `yaml:"action,omitempty" json:"action,omitempty"`
+	Retries int                    `yaml:"retries,omitempty" json:"retries,omitempty"`
+	Config  map[string]interface{} `yaml:"config,omitempty" json:"config,omitempty"`
+}
+
+// PipelineFromYAML creates a pipeline from YAML
+func PipelineFromYAML(data []byte, transforms map[string]TransformFunc) (*Pipeline, error) {
+	var def PipelineDefinition
+	if err := yaml.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	return buildPipelineFromDefinition(&def, transforms)
+}
+
+// PipelineFromJSON creates a pipeline from JSON
+func PipelineFromJSON(data []byte, transforms map[string]TransformFunc) (*Pipeline, error) {
+	var def PipelineDefinition
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	return buildPipelineFromDefinition(&def, transforms)
+}
+
+// buildPipelineFromDefinition builds a pipeline from a definition
+func buildPipelineFromDefinition(def *PipelineDefinition, transforms map[string]TransformFunc) (*Pipeline, error) {
+	pipeline := &Pipeline{
+		ID:          def.ID,
+		Name:        def.Name,
+		Description: def.Description,
+		Config:      def.Config,
+		Steps:       make([]PipelineStep, 0, len(def.Steps)),
+	}
+
+	for i, stepDef := range def.Steps {
+		name := stepDef.Name
+		if name == "" {
+			name = fmt.Sprintf("%s-%d", stepDef.Type, i+1)
+		}
+
+		step := PipelineStep{
+			Name:       name,
+			Type:       StepType(stepDef.Type),
+			ModelID:    stepDef.ModelID,
+			Parameters: stepDef.Parameters,
+		}
+
+		switch step.Type {
+		case StepTypePreprocess, StepTypePostprocess, StepTypeTransform:
+			if stepDef.Transform != "" {
+				transform, exists := transforms[stepDef.Transform]
+				if !exists {
+					return nil, fmt.Errorf("step %s: unknown transform: %s", name, stepDef.Transform)
+				}
+				step.Transform = transform
+			}
+		case StepTypeModel, StepTypeEmbed, StepTypeGenerate:
+			if step.ModelID == "" {
+				return nil, fmt.Errorf("step %s: model_id is required", name)
+			}
+		case StepTypeChunk, StepTypeRetrieve, StepTypePrompt:
+		default:
+			return nil, fmt.Errorf("step %s: unknown step type: %s", name, stepDef.Type)
+		}
+
+		if len(stepDef.Guardrails) > 0 && step.Type != StepTypeModel && step.Type != StepTypeGenerate {
+			return nil, fmt.Errorf("step %s: guardrails only apply to model and generate steps", name)
+		}
+		for _, guardDef := range stepDef.Guardrails {
+			guard, err := buildGuardrail(guardDef)
+			if err != nil {
+				return nil, fmt.Errorf("step %s: %w", name, err)
+			}
+			step.Guardrails = append(step.Guardrails, guard)
+		}
+
+		pipeline.Steps = append(pipeline.Steps, step)
+	}
+
+	return pipeline, nil
+}
+
+// PipelineToYAML exports a pipeline to YAML. Transforms are functions and
+// are not exported, nor are guardrails.
+func PipelineToYAML(pipeline *Pipeline) ([]byte, error) {
+	def := &PipelineDefinition{
+		ID:          pipeline.ID,
+		Name:        pipeline.Name,
+		Description: pipeline.Description,
+		Config:      pipeline.Config,
+		Steps:       make([]PipelineStepDefinition, 0, len(pipeline.Steps)),
+	}
+
+	for _, step := range pipeline.Steps {
+		def.Steps = append(def.Steps, PipelineStepDefinition{
+			Name:       step.Name,
+			Type:       string(step.Type),
+			ModelID:    step.ModelID,
+			Parameters: step.Parameters,
+		})
+	}
+
+	return yaml.Marshal(def)
+}
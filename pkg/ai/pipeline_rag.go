@@ -0,0 +1,435 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// RAGState is the data passed between retrieval-augmented generation
+// steps. Each step returns a new state, so step results show how it grew.
+//
+// Indexing pipeline:  documents → chunk → embed (with a store)
+// Question pipeline:  query → embed → retrieve → prompt → generate
+type RAGState struct {
+	Query     string        `json:"query,omitempty"`
+	Vector    []float32     `json:"-"`
+	Documents []Document    `json:"documents,omitempty"`
+	Chunks    []Chunk       `json:"chunks,omitempty"`
+	Matches   []VectorMatch `json:"matches,omitempty"`
+	Prompt    string        `json:"prompt,omitempty"`
+	Answer    string        `json:"answer,omitempty"`
+}
+
+// Document is a text to index
+type Document struct {
+	ID       string                 `json:"id"`
+	Text     string                 `json:"text"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Chunk is a piece of a document, embedded and stored as one vector
+type Chunk struct {
+	ID         string                 `json:"id"`
+	DocumentID string                 `json:"document_id"`
+	Index      int                    `json:"index"`
+	Text       string                 `json:"text"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Vector     []float32              `json:"-"`
+}
+
+// DefaultRAGTemplate is the prompt step's template when none is set
+const DefaultRAGTemplate = `Answer the question using only the context below. If the context does not contain the answer, say you don't know.
+
+Context:
+{{.Context}}
+
+Question: {{.Query}}`
+
+// executeRAGStep runs a chunk, embed, retrieve, prompt or generate step
+func (pm *PipelineManager) executeRAGStep(ctx context.Context, step *PipelineStep, input interface{}) (interface{}, error) {
+	state, err := toRAGState(input)
+	if err != nil {
+		return nil, err
+	}
+	next := *state
+
+	switch step.Type {
+	case StepTypeChunk:
+		err = chunkStep(&next, step.Parameters)
+	case StepTypeEmbed:
+		err = pm.embedStep(ctx, &next, step)
+	case StepTypeRetrieve:
+		err = pm.retrieveStep(ctx, &next, step.Parameters)
+	case StepTypePrompt:
+		err = pm.promptStep(ctx, &next, step.Parameters)
+	case StepTypeGenerate:
+		err = pm.generateStep(ctx, &next, step)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// toRAGState accepts a state, a query string, or documents to index
+func toRAGState(input interface{}) (*RAGState, error) {
+	switch v := input.(type) {
+	case *RAGState:
+		return v, nil
+	case RAGState:
+		return &v, nil
+	case string:
+		return &RAGState{Query: v}, nil
+	case Document:
+		return &RAGState{Documents: []Document{v}}, nil
+	case []Document:
+		return &RAGState{Documents: v}, nil
+	case []string:
+		documents := make([]Document, len(v))
+		for i, text := range v {
+			documents[i] = Document{Text: text}
+		}
+		return &RAGState{Documents: documents}, nil
+	default:
+		return nil, fmt.Errorf("unsupported RAG step input: %T", input)
+	}
+}
+
+// chunkStep splits the documents (or the query, when there are none)
+// into overlapping chunks.
+//
+//	size     maximum characters per chunk (default 1000)
+//	overlap  characters shared by consecutive chunks (default 200)
+func chunkStep(state *RAGState, params map[string]interface{}) error {
+	size := intParam(params, "size", 1000)
+	overlap := intParam(params, "overlap", 200)
+	if size <= 0 || overlap < 0 || overlap >= size {
+		return fmt.Errorf("chunk size must be positive and larger than the overlap")
+	}
+
+	documents := state.Documents
+	if len(documents) == 0 && state.Query != "" {
+		documents = []Document{{Text: state.Query}}
+	}
+	if len(documents) == 0 {
+		return fmt.Errorf("chunk step has no documents")
+	}
+
+	chunks := make([]Chunk, 0, len(documents))
+	for _, doc := range documents {
+		docID := doc.ID
+		if docID == "" {
+			sum := sha256.Sum256([]byte(doc.Text))
+			docID = "doc-" + hex.EncodeToString(sum[:6])
+		}
+		for i, text := range splitText(doc.Text, size, overlap) {
+			chunks = append(chunks, Chunk{
+				ID:         fmt.Sprintf("%s#%d", docID, i),
+				DocumentID: docID,
+				Index:      i,
+				Text:       text,
+				Metadata:   doc.Metadata,
+			})
+		}
+	}
+	state.Chunks = chunks
+	return nil
+}
+
+// embedStep embeds the chunks, or the query when there are none. With a
+// store parameter, embedded chunks are upserted into that vector store.
+//
+//	model_id    embedding model (the step's ModelID)
+//	batch_size  chunks per embedding request (default 64)
+//	store       vector store to index chunks into
+func (pm *PipelineManager) embedStep(ctx context.Context, state *RAGState, step *PipelineStep) error {
+	if step.ModelID == "" {
+		return fmt.Errorf("model_id required for embed step")
+	}
+
+	if len(state.Chunks) == 0 {
+		if state.Query == "" {
+			return fmt.Errorf("embed step has no chunks or query")
+		}
+		vectors, err := pm.embed(ctx, step, []string{state.Query})
+		if err != nil {
+			return err
+		}
+		state.Vector = vectors[0]
+		return nil
+	}
+
+	chunks := make([]Chunk, len(state.Chunks))
+	copy(chunks, state.Chunks)
+	batchSize := intParam(step.Parameters, "batch_size", 64)
+	if batchSize <= 0 {
+		batchSize = 64
+	}
+	for i := 0; i < len(chunks); i += batchSize {
+		end := min(i+batchSize, len(chunks))
+		texts := make([]string, 0, end-i)
+		for _, chunk := range chunks[i:end] {
+			texts = append(texts, chunk.Text)
+		}
+		vectors, err := pm.embed(ctx, step, texts)
+		if err != nil {
+			return err
+		}
+		for j := range vectors {
+			chunks[i+j].Vector = vectors[j]
+		}
+	}
+	state.Chunks = chunks
+
+	storeName, ok := step.Parameters["store"].(string)
+	if !ok {
+		return nil
+	}
+	store, err := pm.vectorStore(storeName)
+	if err != nil {
+		return err
+	}
+
+	vectors := make([]Vector, len(chunks))
+	for i, chunk := range chunks {
+		metadata := make(map[string]interface{}, len(chunk.Metadata)+3)
+		for k, v := range chunk.Metadata {
+			metadata[k] = v
+		}
+		metadata["document_id"] = chunk.DocumentID
+		metadata["chunk_index"] = chunk.Index
+		metadata["text"] = chunk.Text
+		vectors[i] = Vector{ID: chunk.ID, Values: chunk.Vector, Metadata: metadata}
+	}
+	return store.Upsert(ctx, vectors)
+}
+
+// embed runs the step's embedding model over texts
+func (pm *PipelineManager) embed(ctx context.Context, step *PipelineStep, texts []string) ([][]float32, error) {
+	params := make(map[string]interface{}, len(step.Parameters)+1)
+	for k, v := range step.Parameters {
+		params[k] = v
+	}
+	params["type"] = "embedding"
+
+	var data interface{} = texts
+	if len(texts) == 1 {
+		data = texts[0]
+	}
+	output, err := pm.modelManager.Predict(ctx, &InferenceInput{
+		ModelID:    step.ModelID,
+		Data:       data,
+		Parameters: params,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	vectors, err := EmbeddingVectors(output)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embedding model returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+	return vectors, nil
+}
+
+// retrieveStep finds the chunks nearest the embedded query.
+//
+//	store      vector store to search (default "default")
+//	top_k      number of matches (default 5)
+//	min_score  drop matches scoring below this
+//	filter     metadata filter, see VectorFilter
+func (pm *PipelineManager) retrieveStep(ctx context.Context, state *RAGState, params map[string]interface{}) error {
+	if len(state.Vector) == 0 {
+		return fmt.Errorf("retrieve step needs an embedded query; add an embed step first")
+	}
+
+	storeName, _ := params["store"].(string)
+	store, err := pm.vectorStore(storeName)
+	if err != nil {
+		return err
+	}
+
+	query := VectorQuery{
+		Vector: state.Vector,
+		TopK:   intParam(params, "top_k", 5),
+	}
+	if filter, ok := params["filter"].(map[string]interface{}); ok {
+		query.Filter = VectorFilter(filter)
+	}
+
+	matches, err := store.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	if minScore, ok := toFloat(params["min_score"]); ok {
+		kept := matches[:0]
+		for _, match := range matches {
+			if float64(match.Score) >= minScore {
+				kept = append(kept, match)
+			}
+		}
+		matches = kept
+	}
+	state.Matches = matches
+	return nil
+}
+
+// promptStep renders the prompt from the query and retrieved context.
+// The template sees .Query, .Context (the chunk texts joined) and .Matches.
+//
+//	prompt             registered prompt as name@version, or name for the
+//	                   latest; rendered with its variable checks and guardrails
+//	variables          extra values for a registered prompt
+//	template           text/template source (default DefaultRAGTemplate)
+//	separator          between chunks in .Context (default a blank line)
+//	max_context_chars  stop adding chunks past this length (0 = no limit)
+func (pm *PipelineManager) promptStep(ctx context.Context, state *RAGState, params map[string]interface{}) error {
+	ref, _ := params["prompt"].(string)
+	source, _ := params["template"].(string)
+	if ref != "" && source != "" {
+		return fmt.Errorf("prompt step takes a prompt or a template, not both")
+	}
+	if source == "" {
+		source = DefaultRAGTemplate
+	}
+
+	separator, ok := params["separator"].(string)
+	if !ok {
+		separator = "\n\n"
+	}
+	limit := intParam(params, "max_context_chars", 0)
+
+	var contextText strings.Builder
+	for _, match := range state.Matches {
+		text, _ := match.Metadata["text"].(string)
+		if text == "" {
+			continue
+		}
+		if limit > 0 && contextText.Len() > 0 && contextText.Len()+len(separator)+len(text) > limit {
+			break
+		}
+		if contextText.Len() > 0 {
+			contextText.WriteString(separator)
+		}
+		contextText.WriteString(text)
+	}
+
+	vars := map[string]interface{}{
+		"Query":   state.Query,
+		"Context": contextText.String(),
+		"Matches": state.Matches,
+	}
+
+	if ref != "" {
+		pm.mu.RLock()
+		registry := pm.prompts
+		pm.mu.RUnlock()
+		if registry == nil {
+			return fmt.Errorf("no prompt registry configured")
+		}
+		if extra, ok := params["variables"].(map[string]interface{}); ok {
+			for name, value := range extra {
+				vars[name] = value
+			}
+		}
+		prompt, err := registry.Render(ctx, ref, vars)
+		if err != nil {
+			return err
+		}
+		state.Prompt = prompt
+		return nil
+	}
+
+	tmpl, err := template.New("prompt").Parse(source)
+	if err != nil {
+		return fmt.Errorf("invalid prompt template: %w", err)
+	}
+	var prompt strings.Builder
+	if err := tmpl.Execute(&prompt, vars); err != nil {
+		return fmt.Errorf("failed to render prompt: %w", err)
+	}
+	state.Prompt = prompt.String()
+	return nil
+}
+
+// generateStep sends the prompt to a chat model and keeps the reply as the
+// answer. Parameters are passed to the model (system, temperature, ...).
+func (pm *PipelineManager) generateStep(ctx context.Context, state *RAGState, step *PipelineStep) error {
+	if step.ModelID == "" {
+		return fmt.Errorf("model_id required for generate step")
+	}
+
+	prompt := state.Prompt
+	if prompt == "" {
+		prompt = state.Query
+	}
+	output, err := pm.guardedPredict(ctx, step, &InferenceInput{
+		ModelID:    step.ModelID,
+		Data:       prompt,
+		Parameters: step.Parameters,
+	})
+	if err != nil {
+		return err
+	}
+
+	answer, _ := resultText(output.Result)
+	state.Answer = answer
+	return nil
+}
+
+// splitText cuts text into chunks of at most size runes, each starting
+// overlap runes before the previous one ended. Cuts fall on whitespace in
+// the second half of a window when there is any.
+func splitText(text string, size, overlap int) []string {
+	runes := []rune(strings.TrimSpace(text))
+	chunks := make([]string, 0, len(runes)/(size-overlap)+1)
+
+	for start := 0; start < len(runes); {
+		end := start + size
+		if end >= len(runes) {
+			end = len(runes)
+		} else {
+			for i := end; i > start+size/2; i-- {
+				if unicode.IsSpace(runes[i]) {
+					end = i
+					break
+				}
+			}
+		}
+
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		// Start the overlap on a word boundary
+		for next < end && !unicode.IsSpace(runes[next-1]) {
+			next++
+		}
+		start = next
+	}
+	return chunks
+}
+
+// intParam reads an integer parameter, which YAML and JSON decode
+// differently
+func intParam(params map[string]interface{}, key string, fallback int) int {
+	if value, ok := toFloat(params[key]); ok {
+		return int(value)
+	}
+	return fallback
+}
@@ -0,0 +1,361 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrPromptNotFound is returned for unknown prompt templates
+var ErrPromptNotFound = errors.New("prompt template not found")
+
+// DefaultPromptForbidden are strings a rendered prompt may not contain:
+// template syntax smuggled in through a variable and Go's marker for a
+// missing value
+var DefaultPromptForbidden = []string{"{{", "}}", "<no value>"}
+
+// PromptTemplate is a named, versioned prompt. The template uses Go
+// template syntax; Variables lists the values it may reference, all of
+// which are required when rendering. Versions are immutable once
+// registered.
+type PromptTemplate struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"size:100;uniqueIndex:idx_ai_prompt_templates_name_version;not null" json:"name"`
+	Version     string    `gorm:"size:50;uniqueIndex:idx_ai_prompt_templates_name_version;not null" json:"version"`
+	Description string    `gorm:"size:1000" json:"description,omitempty"`
+	Template    string    `gorm:"type:text;not null" json:"template"`
+	Variables   []string  `gorm:"serializer:json" json:"variables"`
+	MaxLength   int       `json:"max_length,omitempty"` // Rendered characters; 0 for no limit
+	Forbidden   []string  `gorm:"serializer:json" json:"forbidden,omitempty"`
+	CreatedBy   string    `gorm:"size:100" json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for PromptTemplate
+func (PromptTemplate) TableName() string {
+	return "ai_prompt_templates"
+}
+
+// Ref returns the name@version the template is referenced by
+func (p *PromptTemplate) Ref() string {
+	return p.Name + "@" + p.Version
+}
+
+// PromptRegistry persists prompt templates and renders them with
+// variable validation and guardrails. A prompt is referenced as
+// "name@version", or "name" for its latest version.
+type PromptRegistry struct {
+	db        *gorm.DB
+	forbidden []string
+	compiled  map[string]*template.Template // By name@version
+	mu        sync.RWMutex
+}
+
+// NewPromptRegistry creates a prompt registry. Every render is checked
+// against DefaultPromptForbidden as well as the template's own list.
+func NewPromptRegistry(db *gorm.DB) *PromptRegistry {
+	return &PromptRegistry{
+		db:        db,
+		forbidden: DefaultPromptForbidden,
+		compiled:  make(map[string]*template.Template),
+	}
+}
+
+// SetForbidden replaces the strings forbidden in every rendered prompt
+func (r *PromptRegistry) SetForbidden(forbidden []string) {
+	r.mu.Lock()
+	r.forbidden = forbidden
+	r.mu.Unlock()
+}
+
+// Register adds a prompt version. Variables are taken from the template
+// when not set; when set, the template may only reference those.
+func (r *PromptRegistry) Register(ctx context.Context, prompt *PromptTemplate) (*PromptTemplate, error) {
+	if prompt.Name == "" || prompt.Version == "" {
+		return nil, fmt.Errorf("prompt name and version are required")
+	}
+	if strings.Contains(prompt.Name, "@") || strings.Contains(prompt.Version, "@") {
+		return nil, fmt.Errorf("prompt names and versions cannot contain @")
+	}
+	if prompt.MaxLength < 0 {
+		return nil, fmt.Errorf("prompt max length cannot be negative")
+	}
+
+	tmpl, err := compilePrompt(prompt)
+	if err != nil {
+		return nil, err
+	}
+	referenced := templateVariables(tmpl)
+	if len(prompt.Variables) == 0 {
+		prompt.Variables = referenced
+	} else {
+		declared := make(map[string]bool, len(prompt.Variables))
+		for _, name := range prompt.Variables {
+			declared[name] = true
+		}
+		for _, name := range referenced {
+			if !declared[name] {
+				return nil, fmt.Errorf("prompt template references undeclared variable: %s", name)
+			}
+		}
+	}
+
+	existing, err := r.find(ctx, prompt.Name, prompt.Version)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("prompt version already registered: %s", prompt.Ref())
+	}
+	if err := r.db.WithContext(ctx).Create(prompt).Error; err != nil {
+		return nil, fmt.Errorf("failed to register prompt version: %w", err)
+	}
+
+	r.mu.Lock()
+	r.compiled[prompt.Ref()] = tmpl
+	r.mu.Unlock()
+
+	return prompt, nil
+}
+
+// Get returns a prompt by "name@version", or the latest version for "name"
+func (r *PromptRegistry) Get(ctx context.Context, ref string) (*PromptTemplate, error) {
+	name, version, _ := strings.Cut(ref, "@")
+
+	var found *PromptTemplate
+	var err error
+	if version == "" {
+		found, err = r.latest(ctx, name)
+	} else {
+		found, err = r.find(ctx, name, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPromptNotFound, ref)
+	}
+	return found, nil
+}
+
+// Versions lists a prompt's versions, newest first, or every prompt's when
+// name is empty
+func (r *PromptRegistry) Versions(ctx context.Context, name string) ([]PromptTemplate, error) {
+	var prompts []PromptTemplate
+	query := r.db.WithContext(ctx)
+	if name != "" {
+		query = query.Where("name = ?", name)
+	}
+	if err := query.Order("name ASC, created_at DESC, id DESC").Find(&prompts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list prompt versions: %w", err)
+	}
+	return prompts, nil
+}
+
+// Render renders a prompt. Every declared variable must be given; values
+// the template does not declare are ignored. The result must fit the
+// template's max length and contain no forbidden string.
+func (r *PromptRegistry) Render(ctx context.Context, ref string, vars map[string]interface{}) (string, error) {
+	prompt, err := r.Get(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	return r.RenderTemplate(prompt, vars)
+}
+
+// RenderTemplate renders an already loaded prompt, see Render
+func (r *PromptRegistry) RenderTemplate(prompt *PromptTemplate, vars map[string]interface{}) (string, error) {
+	tmpl, err := r.compiledTemplate(prompt)
+	if err != nil {
+		return "", err
+	}
+	return r.render(prompt, tmpl, vars)
+}
+
+// RenderDraft renders a prompt that is not registered, such as one being
+// tried out before it becomes a version. Variables are taken from the
+// template when not set, and the checks of Render apply. Drafts are not
+// cached.
+func (r *PromptRegistry) RenderDraft(draft *PromptTemplate, vars map[string]interface{}) (string, error) {
+	tmpl, err := compilePrompt(draft)
+	if err != nil {
+		return "", err
+	}
+	prompt := *draft
+	if len(prompt.Variables) == 0 {
+		prompt.Variables = templateVariables(tmpl)
+	}
+	return r.render(&prompt, tmpl, vars)
+}
+
+// render executes a compiled prompt and checks the result
+func (r *PromptRegistry) render(prompt *PromptTemplate, tmpl *template.Template, vars map[string]interface{}) (string, error) {
+	var missing []string
+	data := make(map[string]interface{}, len(prompt.Variables))
+	for _, name := range prompt.Variables {
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		data[name] = value
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("prompt %s: missing variables: %s", prompt.Ref(), strings.Join(missing, ", "))
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("prompt %s: failed to render: %w", prompt.Ref(), err)
+	}
+	rendered := out.String()
+
+	if prompt.MaxLength > 0 && len([]rune(rendered)) > prompt.MaxLength {
+		return "", fmt.Errorf("prompt %s: rendered length %d exceeds %d", prompt.Ref(), len([]rune(rendered)), prompt.MaxLength)
+	}
+	r.mu.RLock()
+	forbidden := r.forbidden
+	r.mu.RUnlock()
+	for _, list := range [][]string{forbidden, prompt.Forbidden} {
+		for _, value := range list {
+			if value != "" && strings.Contains(rendered, value) {
+				return "", fmt.Errorf("prompt %s: rendered prompt contains forbidden %q", prompt.Ref(), value)
+			}
+		}
+	}
+
+	return rendered, nil
+}
+
+// compiledTemplate returns the compiled template, compiling it on first use.
+// Versions are immutable, so compiled templates never go stale.
+func (r *PromptRegistry) compiledTemplate(prompt *PromptTemplate) (*template.Template, error) {
+	r.mu.RLock()
+	tmpl, exists := r.compiled[prompt.Ref()]
+	r.mu.RUnlock()
+	if exists {
+		return tmpl, nil
+	}
+
+	tmpl, err := compilePrompt(prompt)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.compiled[prompt.Ref()] = tmpl
+	r.mu.Unlock()
+	return tmpl, nil
+}
+
+func (r *PromptRegistry) find(ctx context.Context, name, version string) (*PromptTemplate, error) {
+	var found PromptTemplate
+	err := r.db.WithContext(ctx).Where("name = ? AND version = ?", name, version).First(&found).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load prompt version: %w", err)
+	}
+	return &found, nil
+}
+
+func (r *PromptRegistry) latest(ctx context.Context, name string) (*PromptTemplate, error) {
+	var found PromptTemplate
+	err := r.db.WithContext(ctx).
+		Where("name = ?", name).
+		Order("created_at DESC, id DESC").
+		First(&found).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load prompt version: %w", err)
+	}
+	return &found, nil
+}
+
+// compilePrompt parses a prompt; referencing a value that is not given
+// fails the render rather than printing "<no value>"
+func compilePrompt(prompt *PromptTemplate) (*template.Template, error) {
+	tmpl, err := template.New(prompt.Ref()).Option("missingkey=error").Parse(prompt.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// templateVariables returns the top-level fields a template references,
+// such as Query in {{.Query}} or {{range .Matches}}
+func templateVariables(tmpl *template.Template) []string {
+	seen := make(map[string]bool)
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			collectFields(t.Tree.Root, seen, true)
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// collectFields walks a parse tree. Inside range and with blocks dot is
+// no longer the root, so only $.Field references are collected there.
+func collectFields(node parse.Node, seen map[string]bool, root bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectFields(child, seen, root)
+		}
+	case *parse.ActionNode:
+		collectFields(n.Pipe, seen, root)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectFields(cmd, seen, root)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectFields(arg, seen, root)
+		}
+	case *parse.FieldNode:
+		if root {
+			seen[n.Ident[0]] = true
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			seen[n.Ident[1]] = true
+		}
+	case *parse.ChainNode:
+		collectFields(n.Node, seen, root)
+	case *parse.IfNode:
+		collectFields(n.Pipe, seen, root)
+		collectFields(n.List, seen, root)
+		collectFields(n.ElseList, seen, root)
+	case *parse.RangeNode:
+		collectFields(n.Pipe, seen, root)
+		collectFields(n.List, seen, false)
+		collectFields(n.ElseList, seen, root)
+	case *parse.WithNode:
+		collectFields(n.Pipe, seen, root)
+		collectFields(n.List, seen, false)
+		collectFields(n.ElseList, seen, root)
+	case *parse.TemplateNode:
+		collectFields(n.Pipe, seen, root)
+	}
+}
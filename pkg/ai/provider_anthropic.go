@@ -0,0 +1,237 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// anthropicOverloaded is the status of a temporarily overloaded Anthropic API
+const anthropicOverloaded = 529
+
+// AnthropicProvider provider for the Anthropic Messages API (Claude)
+type AnthropicProvider struct {
+	*httpProvider
+	apiKey    string
+	baseURL   string
+	version   string
+	maxTokens int
+}
+
+// AnthropicConfig configuration for Anthropic
+type AnthropicConfig struct {
+	APIKey           string
+	BaseURL          string // Optional, defaults to https://api.anthropic.com/v1
+	Version          string // anthropic-version header, defaults to 2023-06-01
+	DefaultMaxTokens int    // Used when a request sets no max_tokens, defaults to 1024
+	RateLimit        RateLimitConfig
+}
+
+// LoadAnthropicConfig loads Anthropic configuration from environment
+func LoadAnthropicConfig() *AnthropicConfig {
+	return &AnthropicConfig{
+		APIKey:    os.Getenv("ANTHROPIC_API_KEY"),
+		BaseURL:   os.Getenv("ANTHROPIC_BASE_URL"),
+		RateLimit: loadRateLimitConfig("ANTHROPIC"),
+	}
+}
+
+// NewAnthropicProvider creates a new Anthropic provider. Rate-limited (429)
+// and overloaded (529) requests are retried.
+func NewAnthropicProvider(config *AnthropicConfig) *AnthropicProvider {
+	baseURL := strings.TrimSuffix(config.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com/v1"
+	}
+	version := config.Version
+	if version == "" {
+		version = "2023-06-01"
+	}
+	maxTokens := config.DefaultMaxTokens
+	if maxTokens <= 0 {
+		maxTokens = 1024
+	}
+
+	return &AnthropicProvider{
+		httpProvider: newHTTPProvider("anthropic", config.RateLimit, anthropicOverloaded),
+		apiKey:       config.APIKey,
+		baseURL:      baseURL,
+		version:      version,
+		maxTokens:    maxTokens,
+	}
+}
+
+// LoadModel loads an Anthropic model
+func (p *AnthropicProvider) LoadModel(config *ModelConfig) (*Model, error) {
+	return p.loadModel(config), nil
+}
+
+// Predict performs inference using the Messages API. Chat and completion
+// requests both map to a single user message; embeddings are unsupported.
+func (p *AnthropicProvider) Predict(ctx context.Context, modelID string, input *InferenceInput) (*InferenceOutput, error) {
+	startTime := time.Now()
+
+	var result interface{}
+	var err error
+
+	switch input.Parameters["type"] {
+	case "embedding":
+		err = fmt.Errorf("anthropic: %w", ErrEmbeddingsUnsupported)
+	case "completion":
+		result, err = p.messages(ctx, modelID, input, false)
+	default:
+		result, err = p.messages(ctx, modelID, input, true) // Default to chat
+	}
+
+	return p.output(modelID, startTime, result, err)
+}
+
+// anthropicResponse is a Messages API response
+type anthropicResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// messages sends a Messages API request and maps the reply
+func (p *AnthropicProvider) messages(ctx context.Context, modelID string, input *InferenceInput, chat bool) (interface{}, error) {
+	var response anthropicResponse
+	if err := p.postJSON(ctx, p.baseURL+"/messages", p.headers(), p.messagesRequest(modelID, input), &response); err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+
+	finishReason := anthropicFinishReason(response.StopReason)
+	if chat {
+		return chatResult(response.ID, response.Model, text.String(), finishReason,
+			response.Usage.InputTokens, response.Usage.OutputTokens), nil
+	}
+	return completionResult(response.ID, response.Model, text.String(), finishReason,
+		response.Usage.InputTokens, response.Usage.OutputTokens), nil
+}
+
+// PredictStream streams a Messages API reply. Embeddings are unsupported.
+func (p *AnthropicProvider) PredictStream(ctx context.Context, modelID string, input *InferenceInput, fn StreamFunc) error {
+	if input.Parameters["type"] == "embedding" {
+		return fmt.Errorf("anthropic: %w", ErrEmbeddingsUnsupported)
+	}
+
+	startTime := time.Now()
+	requestBody := p.messagesRequest(modelID, input)
+	requestBody["stream"] = true
+
+	var inputTokens, outputTokens int
+	var finishReason string
+	err := p.streamSSE(ctx, p.baseURL+"/messages", p.headers(), requestBody, func(event string, data []byte) error {
+		var payload struct {
+			Message struct {
+				Usage struct {
+					InputTokens int `json:"input_tokens"`
+				} `json:"usage"`
+			} `json:"message"`
+			Delta struct {
+				Type       string `json:"type"`
+				Text       string `json:"text"`
+				StopReason string `json:"stop_reason"`
+			} `json:"delta"`
+			Usage struct {
+				OutputTokens int `json:"output_tokens"`
+			} `json:"usage"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return fmt.Errorf("anthropic: invalid stream event: %w", err)
+		}
+
+		switch event {
+		case "message_start":
+			inputTokens = payload.Message.Usage.InputTokens
+		case "content_block_delta":
+			if payload.Delta.Type == "text_delta" && payload.Delta.Text != "" {
+				return fn(StreamChunk{Delta: payload.Delta.Text})
+			}
+		case "message_delta":
+			finishReason = anthropicFinishReason(payload.Delta.StopReason)
+			outputTokens = payload.Usage.OutputTokens
+		case "message_stop":
+			return fn(StreamChunk{
+				FinishReason: finishReason,
+				Usage:        usageResult(inputTokens, outputTokens),
+				Done:         true,
+			})
+		case "error":
+			return fmt.Errorf("anthropic stream error: %s - %s", payload.Error.Type, payload.Error.Message)
+		}
+		return nil
+	})
+
+	p.recordMetrics(modelID, time.Since(startTime), err != nil)
+	return err
+}
+
+// messagesRequest builds a Messages API request
+func (p *AnthropicProvider) messagesRequest(modelID string, input *InferenceInput) map[string]interface{} {
+	requestBody := map[string]interface{}{
+		"model":      modelID,
+		"max_tokens": p.maxTokens,
+		"messages": []map[string]string{
+			{"role": "user", "content": promptText(input)},
+		},
+	}
+
+	// Add optional parameters
+	if systemMsg, ok := input.Parameters["system"]; ok {
+		requestBody["system"] = fmt.Sprintf("%v", systemMsg)
+	}
+	if temp, ok := input.Parameters["temperature"]; ok {
+		requestBody["temperature"] = temp
+	}
+	if maxTokens, ok := input.Parameters["max_tokens"]; ok {
+		requestBody["max_tokens"] = maxTokens
+	}
+
+	return requestBody
+}
+
+func (p *AnthropicProvider) headers() map[string]string {
+	return map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": p.version,
+	}
+}
+
+// anthropicFinishReason maps a stop reason to OpenAI's finish reasons
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	default:
+		return stopReason
+	}
+}
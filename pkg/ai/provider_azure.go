@@ -0,0 +1,205 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// AzureOpenAIProvider provider for Azure OpenAI deployments
+type AzureOpenAIProvider struct {
+	*httpProvider
+	apiKey      string
+	endpoint    string
+	apiVersion  string
+	deployments map[string]string // Model ID -> deployment name
+}
+
+// AzureOpenAIConfig configuration for Azure OpenAI
+type AzureOpenAIConfig struct {
+	Endpoint   string // Resource endpoint, e.g. https://my-resource.openai.azure.com
+	APIKey     string
+	APIVersion string // Optional, defaults to 2024-06-01
+
+	// Deployments maps model IDs to deployment names. Models without an
+	// entry use a "deployment" key in their ModelConfig.Config, then their
+	// ID.
+	Deployments map[string]string
+
+	RateLimit RateLimitConfig
+}
+
+// LoadAzureOpenAIConfig loads Azure OpenAI configuration from environment.
+// AZURE_OPENAI_DEPLOYMENTS lists model=deployment pairs, comma separated.
+func LoadAzureOpenAIConfig() *AzureOpenAIConfig {
+	config := &AzureOpenAIConfig{
+		Endpoint:    os.Getenv("AZURE_OPENAI_ENDPOINT"),
+		APIKey:      os.Getenv("AZURE_OPENAI_API_KEY"),
+		APIVersion:  os.Getenv("AZURE_OPENAI_API_VERSION"),
+		Deployments: make(map[string]string),
+		RateLimit:   loadRateLimitConfig("AZURE_OPENAI"),
+	}
+
+	for _, pair := range strings.Split(os.Getenv("AZURE_OPENAI_DEPLOYMENTS"), ",") {
+		modelID, deployment, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && modelID != "" && deployment != "" {
+			config.Deployments[strings.TrimSpace(modelID)] = strings.TrimSpace(deployment)
+		}
+	}
+
+	return config
+}
+
+// NewAzureOpenAIProvider creates a new Azure OpenAI provider. Rate-limited
+// (429) requests are retried after Azure's retry-after-ms hint.
+func NewAzureOpenAIProvider(config *AzureOpenAIConfig) *AzureOpenAIProvider {
+	apiVersion := config.APIVersion
+	if apiVersion == "" {
+		apiVersion = "2024-06-01"
+	}
+
+	deployments := make(map[string]string, len(config.Deployments))
+	for modelID, deployment := range config.Deployments {
+		deployments[modelID] = deployment
+	}
+
+	return &AzureOpenAIProvider{
+		httpProvider: newHTTPProvider("azure", config.RateLimit),
+		apiKey:       config.APIKey,
+		endpoint:     strings.TrimSuffix(config.Endpoint, "/"),
+		apiVersion:   apiVersion,
+		deployments:  deployments,
+	}
+}
+
+// LoadModel loads an Azure OpenAI model, resolving its deployment
+func (p *AzureOpenAIProvider) LoadModel(config *ModelConfig) (*Model, error) {
+	if p.endpoint == "" {
+		return nil, fmt.Errorf("azure: endpoint is not configured")
+	}
+
+	if deployment, ok := config.Config["deployment"].(string); ok && deployment != "" {
+		p.mu.Lock()
+		if _, exists := p.deployments[config.ID]; !exists {
+			p.deployments[config.ID] = deployment
+		}
+		p.mu.Unlock()
+	}
+
+	return p.loadModel(config), nil
+}
+
+// Predict performs inference against the model's deployment. Azure returns
+// OpenAI's response shapes, so results match the OpenAI provider's.
+func (p *AzureOpenAIProvider) Predict(ctx context.Context, modelID string, input *InferenceInput) (*InferenceOutput, error) {
+	startTime := time.Now()
+
+	var result interface{}
+	var err error
+
+	switch input.Parameters["type"] {
+	case "completion":
+		result, err = p.completion(ctx, modelID, input)
+	case "embedding":
+		result, err = p.post(ctx, modelID, "embeddings", map[string]interface{}{"input": input.Data})
+	default:
+		result, err = p.chatCompletion(ctx, modelID, input) // Default to chat
+	}
+
+	return p.output(modelID, startTime, result, err)
+}
+
+// chatCompletion performs chat completion
+func (p *AzureOpenAIProvider) chatCompletion(ctx context.Context, modelID string, input *InferenceInput) (interface{}, error) {
+	return p.post(ctx, modelID, "chat/completions", chatRequest(input))
+}
+
+// completion performs text completion
+func (p *AzureOpenAIProvider) completion(ctx context.Context, modelID string, input *InferenceInput) (interface{}, error) {
+	requestBody := map[string]interface{}{"prompt": input.Data}
+	addSamplingParameters(requestBody, input)
+
+	return p.post(ctx, modelID, "completions", requestBody)
+}
+
+// PredictStream streams a chat or completion reply. Embeddings are
+// delivered whole.
+func (p *AzureOpenAIProvider) PredictStream(ctx context.Context, modelID string, input *InferenceInput, fn StreamFunc) error {
+	var operation string
+	var requestBody map[string]interface{}
+
+	switch input.Parameters["type"] {
+	case "embedding":
+		return predictAsStream(ctx, p, modelID, input, fn)
+	case "completion":
+		operation = "completions"
+		requestBody = map[string]interface{}{"prompt": input.Data}
+		addSamplingParameters(requestBody, input)
+	default:
+		operation = "chat/completions"
+		requestBody = chatRequest(input)
+	}
+	requestBody["stream"] = true
+
+	startTime := time.Now()
+	err := p.streamSSE(ctx, p.operationURL(modelID, operation), map[string]string{"api-key": p.apiKey},
+		requestBody, openAIStream(fn))
+	p.recordMetrics(modelID, time.Since(startTime), err != nil)
+	return err
+}
+
+// post sends a request to an operation of the model's deployment
+func (p *AzureOpenAIProvider) post(ctx context.Context, modelID, operation string, requestBody map[string]interface{}) (interface{}, error) {
+	var result map[string]interface{}
+	if err := p.postJSON(ctx, p.operationURL(modelID, operation), map[string]string{"api-key": p.apiKey}, requestBody, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// operationURL returns the URL of an operation of the model's deployment
+func (p *AzureOpenAIProvider) operationURL(modelID, operation string) string {
+	return fmt.Sprintf("%s/openai/deployments/%s/%s?api-version=%s",
+		p.endpoint, url.PathEscape(p.deployment(modelID)), operation, url.QueryEscape(p.apiVersion))
+}
+
+// deployment returns the deployment serving a model
+func (p *AzureOpenAIProvider) deployment(modelID string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if deployment, ok := p.deployments[modelID]; ok {
+		return deployment
+	}
+	return modelID
+}
+
+// chatRequest builds an OpenAI chat completion request without the model
+func chatRequest(input *InferenceInput) map[string]interface{} {
+	messages := []map[string]string{
+		{"role": "user", "content": promptText(input)},
+	}
+
+	// Add system message if provided
+	if systemMsg, ok := input.Parameters["system"]; ok {
+		messages = append([]map[string]string{
+			{"role": "system", "content": fmt.Sprintf("%v", systemMsg)},
+		}, messages...)
+	}
+
+	requestBody := map[string]interface{}{"messages": messages}
+	addSamplingParameters(requestBody, input)
+	return requestBody
+}
+
+// addSamplingParameters copies optional OpenAI sampling parameters
+func addSamplingParameters(requestBody map[string]interface{}, input *InferenceInput) {
+	if temp, ok := input.Parameters["temperature"]; ok {
+		requestBody["temperature"] = temp
+	}
+	if maxTokens, ok := input.Parameters["max_tokens"]; ok {
+		requestBody["max_tokens"] = maxTokens
+	}
+}
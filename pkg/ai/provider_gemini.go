@@ -0,0 +1,318 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// GeminiProvider provider for the Google Gemini API
+type GeminiProvider struct {
+	*httpProvider
+	apiKey  string
+	baseURL string
+}
+
+// GeminiConfig configuration for Gemini
+type GeminiConfig struct {
+	APIKey    string
+	BaseURL   string // Optional, defaults to https://generativelanguage.googleapis.com/v1beta
+	RateLimit RateLimitConfig
+}
+
+// LoadGeminiConfig loads Gemini configuration from environment, falling
+// back to GOOGLE_API_KEY for the key
+func LoadGeminiConfig() *GeminiConfig {
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = os.Getenv("GOOGLE_API_KEY")
+	}
+
+	return &GeminiConfig{
+		APIKey:    apiKey,
+		BaseURL:   os.Getenv("GEMINI_BASE_URL"),
+		RateLimit: loadRateLimitConfig("GEMINI"),
+	}
+}
+
+// NewGeminiProvider creates a new Gemini provider. Rate-limited (429) and
+// unavailable (503) requests are retried, honoring the RetryInfo delay
+// Gemini returns in error bodies.
+func NewGeminiProvider(config *GeminiConfig) *GeminiProvider {
+	baseURL := strings.TrimSuffix(config.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = "https://generativelanguage.googleapis.com/v1beta"
+	}
+
+	base := newHTTPProvider("gemini", config.RateLimit, http.StatusServiceUnavailable)
+	base.retryHint = geminiRetryDelay
+
+	return &GeminiProvider{
+		httpProvider: base,
+		apiKey:       config.APIKey,
+		baseURL:      baseURL,
+	}
+}
+
+// LoadModel loads a Gemini model
+func (p *GeminiProvider) LoadModel(config *ModelConfig) (*Model, error) {
+	return p.loadModel(config), nil
+}
+
+// Predict performs inference using generateContent, or embedContent for
+// embeddings. A []string input is embedded as a batch.
+func (p *GeminiProvider) Predict(ctx context.Context, modelID string, input *InferenceInput) (*InferenceOutput, error) {
+	startTime := time.Now()
+
+	var result interface{}
+	var err error
+
+	switch input.Parameters["type"] {
+	case "embedding":
+		result, err = p.embedding(ctx, modelID, input)
+	case "completion":
+		result, err = p.generateContent(ctx, modelID, input, false)
+	default:
+		result, err = p.generateContent(ctx, modelID, input, true) // Default to chat
+	}
+
+	return p.output(modelID, startTime, result, err)
+}
+
+// geminiPart is a content part
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+// geminiContent is a message
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiResponse is a generateContent response
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion string `json:"modelVersion"`
+	ResponseID   string `json:"responseId"`
+}
+
+// generateContent sends a generateContent request and maps the first
+// candidate
+func (p *GeminiProvider) generateContent(ctx context.Context, modelID string, input *InferenceInput, chat bool) (interface{}, error) {
+	var response geminiResponse
+	if err := p.postJSON(ctx, p.modelURL(modelID, "generateContent"), p.headers(), generateRequest(input), &response); err != nil {
+		return nil, err
+	}
+
+	// A blocked prompt has no candidates
+	text, finishReason := "", "content_filter"
+	if len(response.Candidates) > 0 {
+		candidate := response.Candidates[0]
+		var b strings.Builder
+		for _, part := range candidate.Content.Parts {
+			b.WriteString(part.Text)
+		}
+		text, finishReason = b.String(), geminiFinishReason(candidate.FinishReason)
+	}
+
+	model := response.ModelVersion
+	if model == "" {
+		model = modelID
+	}
+	usage := response.UsageMetadata
+	if chat {
+		return chatResult(response.ResponseID, model, text, finishReason,
+			usage.PromptTokenCount, usage.CandidatesTokenCount), nil
+	}
+	return completionResult(response.ResponseID, model, text, finishReason,
+		usage.PromptTokenCount, usage.CandidatesTokenCount), nil
+}
+
+// PredictStream streams a reply with streamGenerateContent. Embeddings are
+// delivered whole.
+func (p *GeminiProvider) PredictStream(ctx context.Context, modelID string, input *InferenceInput, fn StreamFunc) error {
+	if input.Parameters["type"] == "embedding" {
+		return predictAsStream(ctx, p, modelID, input, fn)
+	}
+
+	startTime := time.Now()
+	url := p.modelURL(modelID, "streamGenerateContent") + "?alt=sse"
+
+	// Each event is a partial response; usage totals grow as it streams
+	var response geminiResponse
+	finishReason := ""
+	err := p.streamSSE(ctx, url, p.headers(), generateRequest(input), func(event string, data []byte) error {
+		response = geminiResponse{}
+		if err := json.Unmarshal(data, &response); err != nil {
+			return fmt.Errorf("gemini: invalid stream event: %w", err)
+		}
+		if len(response.Candidates) == 0 {
+			finishReason = "content_filter" // Blocked prompt
+			return nil
+		}
+
+		candidate := response.Candidates[0]
+		for _, part := range candidate.Content.Parts {
+			if part.Text != "" {
+				if err := fn(StreamChunk{Delta: part.Text}); err != nil {
+					return err
+				}
+			}
+		}
+		if candidate.FinishReason != "" {
+			finishReason = geminiFinishReason(candidate.FinishReason)
+		}
+		return nil
+	})
+	if err == nil {
+		usage := response.UsageMetadata
+		err = fn(StreamChunk{
+			FinishReason: finishReason,
+			Usage:        usageResult(usage.PromptTokenCount, usage.CandidatesTokenCount),
+			Done:         true,
+		})
+	}
+
+	p.recordMetrics(modelID, time.Since(startTime), err != nil)
+	return err
+}
+
+// generateRequest builds a generateContent request
+func generateRequest(input *InferenceInput) map[string]interface{} {
+	requestBody := map[string]interface{}{
+		"contents": []geminiContent{
+			{Role: "user", Parts: []geminiPart{{Text: promptText(input)}}},
+		},
+	}
+
+	// Add optional parameters
+	if systemMsg, ok := input.Parameters["system"]; ok {
+		requestBody["systemInstruction"] = geminiContent{Parts: []geminiPart{{Text: fmt.Sprintf("%v", systemMsg)}}}
+	}
+	generationConfig := map[string]interface{}{}
+	if temp, ok := input.Parameters["temperature"]; ok {
+		generationConfig["temperature"] = temp
+	}
+	if maxTokens, ok := input.Parameters["max_tokens"]; ok {
+		generationConfig["maxOutputTokens"] = maxTokens
+	}
+	if len(generationConfig) > 0 {
+		requestBody["generationConfig"] = generationConfig
+	}
+
+	return requestBody
+}
+
+// geminiEmbedding is an embedding in embedContent responses
+type geminiEmbedding struct {
+	Values []float64 `json:"values"`
+}
+
+// embedding embeds a text with embedContent, or a []string with
+// batchEmbedContents
+func (p *GeminiProvider) embedding(ctx context.Context, modelID string, input *InferenceInput) (interface{}, error) {
+	texts, batch := input.Data.([]string)
+	if !batch {
+		var response struct {
+			Embedding geminiEmbedding `json:"embedding"`
+		}
+		requestBody := map[string]interface{}{
+			"content": geminiContent{Parts: []geminiPart{{Text: promptText(input)}}},
+		}
+		if err := p.postJSON(ctx, p.modelURL(modelID, "embedContent"), p.headers(), requestBody, &response); err != nil {
+			return nil, err
+		}
+		return embeddingResult(modelID, [][]float64{response.Embedding.Values}), nil
+	}
+
+	requests := make([]map[string]interface{}, len(texts))
+	for i, text := range texts {
+		requests[i] = map[string]interface{}{
+			"model":   geminiModelName(modelID),
+			"content": geminiContent{Parts: []geminiPart{{Text: text}}},
+		}
+	}
+
+	var response struct {
+		Embeddings []geminiEmbedding `json:"embeddings"`
+	}
+	requestBody := map[string]interface{}{"requests": requests}
+	if err := p.postJSON(ctx, p.modelURL(modelID, "batchEmbedContents"), p.headers(), requestBody, &response); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float64, len(response.Embeddings))
+	for i, embedding := range response.Embeddings {
+		vectors[i] = embedding.Values
+	}
+	return embeddingResult(modelID, vectors), nil
+}
+
+func (p *GeminiProvider) modelURL(modelID, method string) string {
+	return p.baseURL + "/" + geminiModelName(modelID) + ":" + method
+}
+
+func (p *GeminiProvider) headers() map[string]string {
+	return map[string]string{"x-goog-api-key": p.apiKey}
+}
+
+// geminiModelName returns the resource name of a model, e.g.
+// models/gemini-1.5-flash
+func geminiModelName(modelID string) string {
+	if strings.HasPrefix(modelID, "models/") || strings.HasPrefix(modelID, "tunedModels/") {
+		return modelID
+	}
+	return "models/" + modelID
+}
+
+// geminiFinishReason maps a finish reason to OpenAI's finish reasons
+func geminiFinishReason(reason string) string {
+	switch reason {
+	case "STOP":
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return "content_filter"
+	default:
+		return strings.ToLower(reason)
+	}
+}
+
+// geminiRetryDelay reads the retryDelay of a RetryInfo error detail, e.g.
+// "17s"
+func geminiRetryDelay(body []byte) time.Duration {
+	var response struct {
+		Error struct {
+			Details []struct {
+				Type       string `json:"@type"`
+				RetryDelay string `json:"retryDelay"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &response) != nil {
+		return 0
+	}
+
+	for _, detail := range response.Error.Details {
+		if !strings.HasSuffix(detail.Type, "RetryInfo") {
+			continue
+		}
+		if delay, err := time.ParseDuration(detail.RetryDelay); err == nil && delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
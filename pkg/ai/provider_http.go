@@ -0,0 +1,439 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/httpclient"
+)
+
+// ErrEmbeddingsUnsupported is returned for embedding requests to providers
+// without an embeddings API
+var ErrEmbeddingsUnsupported = errors.New("provider does not support embeddings")
+
+// RateLimitConfig controls how a provider paces requests and retries
+// rate-limited or overloaded ones
+type RateLimitConfig struct {
+	RequestsPerMinute int           // Client-side cap; 0 disables pacing
+	MaxRetries        int           // Retries of rate-limited requests; negative disables
+	BaseDelay         time.Duration // First backoff when the API gives no retry hint
+	MaxDelay          time.Duration // Longest single wait; longer hints fail fast
+}
+
+// DefaultRateLimitConfig returns the default rate limit configuration
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		MaxRetries: 3,
+		BaseDelay:  1 * time.Second,
+		MaxDelay:   30 * time.Second,
+	}
+}
+
+// loadRateLimitConfig loads rate limits from <prefix>_RPM and
+// <prefix>_MAX_RETRIES
+func loadRateLimitConfig(prefix string) RateLimitConfig {
+	config := DefaultRateLimitConfig()
+
+	if rpm, err := strconv.Atoi(os.Getenv(prefix + "_RPM")); err == nil && rpm >= 0 {
+		config.RequestsPerMinute = rpm
+	}
+	if retries, err := strconv.Atoi(os.Getenv(prefix + "_MAX_RETRIES")); err == nil {
+		config.MaxRetries = retries
+		if retries == 0 {
+			config.MaxRetries = -1 // Zero in the environment disables retries
+		}
+	}
+
+	return config
+}
+
+// RateLimitError is returned when a provider keeps rejecting a request for
+// rate or capacity limits after all retries
+type RateLimitError struct {
+	Provider   string
+	StatusCode int
+	RetryAfter time.Duration // Wait suggested by the API, if any
+	Message    string
+}
+
+// Error implements error
+func (e *RateLimitError) Error() string {
+	msg := fmt.Sprintf("%s rate limited: %d", e.Provider, e.StatusCode)
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(" (retry after %s)", e.RetryAfter)
+	}
+	if e.Message != "" {
+		msg += " - " + e.Message
+	}
+	return msg
+}
+
+// httpProvider is the shared base of HTTP API providers: JSON requests,
+// per-provider pacing and retries, and metrics
+type httpProvider struct {
+	name         string
+	client       *http.Client
+	streamClient *http.Client // No timeout; streams end with their context
+	limits       RateLimitConfig
+
+	// retryStatuses are the statuses retried besides 429, e.g. Anthropic's
+	// 529 overloaded
+	retryStatuses []int
+
+	// retryHint reads a retry delay from an error body, for APIs that do
+	// not send Retry-After
+	retryHint func(body []byte) time.Duration
+
+	nextSlot time.Time // Earliest start of the next request
+	paceMu   sync.Mutex
+
+	metrics map[string]*ModelMetrics
+	mu      sync.RWMutex
+}
+
+func newHTTPProvider(name string, limits RateLimitConfig, retryStatuses ...int) *httpProvider {
+	defaults := DefaultRateLimitConfig()
+	if limits.MaxRetries == 0 {
+		limits.MaxRetries = defaults.MaxRetries
+	}
+	if limits.BaseDelay <= 0 {
+		limits.BaseDelay = defaults.BaseDelay
+	}
+	if limits.MaxDelay <= 0 {
+		limits.MaxDelay = defaults.MaxDelay
+	}
+
+	return &httpProvider{
+		name:          name,
+		client:        httpclient.New(60 * time.Second),
+		streamClient:  httpclient.New(0),
+		limits:        limits,
+		retryStatuses: retryStatuses,
+		metrics:       make(map[string]*ModelMetrics),
+	}
+}
+
+// loadModel builds the model for a config and initializes its metrics
+func (p *httpProvider) loadModel(config *ModelConfig) *Model {
+	p.mu.Lock()
+	p.metrics[config.ID] = &ModelMetrics{ModelID: config.ID}
+	p.mu.Unlock()
+
+	return &Model{
+		ID:       config.ID,
+		Name:     config.Name,
+		Version:  config.Version,
+		Type:     config.Type,
+		Status:   ModelStatusReady,
+		Endpoint: config.Endpoint,
+		Provider: p.name,
+		Config:   config.Config,
+		Metadata: config.Metadata,
+		LoadedAt: time.Now(),
+	}
+}
+
+// UnloadModel unloads a model (no-op for hosted APIs)
+func (p *httpProvider) UnloadModel(modelID string) error {
+	p.mu.Lock()
+	delete(p.metrics, modelID)
+	p.mu.Unlock()
+	return nil
+}
+
+// GetMetrics returns model metrics
+func (p *httpProvider) GetMetrics(modelID string) *ModelMetrics {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.metrics[modelID]
+}
+
+// output wraps a mapped result, recording metrics
+func (p *httpProvider) output(modelID string, startTime time.Time, result interface{}, err error) (*InferenceOutput, error) {
+	p.recordMetrics(modelID, time.Since(startTime), err != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InferenceOutput{
+		ModelID:   modelID,
+		Result:    result,
+		Metadata:  map[string]interface{}{"provider": p.name},
+		Latency:   time.Since(startTime),
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// recordMetrics records inference metrics
+func (p *httpProvider) recordMetrics(modelID string, latency time.Duration, isError bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	metrics := p.metrics[modelID]
+	if metrics == nil {
+		metrics = &ModelMetrics{ModelID: modelID}
+		p.metrics[modelID] = metrics
+	}
+
+	metrics.RequestCount++
+	metrics.TotalLatency += latency
+	metrics.AvgLatency = metrics.TotalLatency / time.Duration(metrics.RequestCount)
+	metrics.LastRequestAt = time.Now()
+
+	if isError {
+		metrics.ErrorCount++
+	}
+}
+
+// postJSON posts body to url and decodes the response into out
+func (p *httpProvider) postJSON(ctx context.Context, url string, headers map[string]string, body interface{}, out interface{}) error {
+	resp, err := p.send(ctx, p.client, url, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// streamSSE posts body to url and passes each Server-Sent Event of the
+// response to onEvent, until the stream ends or onEvent fails
+func (p *httpProvider) streamSSE(ctx context.Context, url string, headers map[string]string, body interface{}, onEvent func(event string, data []byte) error) error {
+	resp, err := p.send(ctx, p.streamClient, url, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return readSSE(resp.Body, onEvent)
+}
+
+// streamLines posts body to url and passes each line of a newline-delimited
+// JSON response to onLine
+func (p *httpProvider) streamLines(ctx context.Context, url string, headers map[string]string, body interface{}, onLine func(line []byte) error) error {
+	resp, err := p.send(ctx, p.streamClient, url, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := newStreamScanner(resp.Body)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			if err := onLine(line); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// send posts body as JSON and returns the 200 response, whose body the
+// caller closes. Rate-limited requests are retried after the API's
+// Retry-After (or retry-after-ms) hint, or with exponential backoff, and a
+// 429 also holds back every other request to the provider until the hint
+// has passed.
+func (p *httpProvider) send(ctx context.Context, client *http.Client, url string, headers map[string]string, body interface{}) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := p.pace(ctx); err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if !p.retryable(resp.StatusCode) {
+			return nil, fmt.Errorf("%s API error: %d - %s", p.name, resp.StatusCode, string(data))
+		}
+
+		hint := retryAfter(resp.Header)
+		if hint == 0 && p.retryHint != nil {
+			hint = p.retryHint(data)
+		}
+		if attempt >= p.limits.MaxRetries || hint > p.limits.MaxDelay {
+			return nil, &RateLimitError{
+				Provider:   p.name,
+				StatusCode: resp.StatusCode,
+				RetryAfter: hint,
+				Message:    string(data),
+			}
+		}
+
+		delay := hint
+		if delay == 0 {
+			delay = min(p.limits.BaseDelay<<attempt, p.limits.MaxDelay)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			p.holdUntil(time.Now().Add(delay))
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *httpProvider) retryable(status int) bool {
+	if status == http.StatusTooManyRequests {
+		return true
+	}
+	for _, s := range p.retryStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// pace waits for the next request slot under RequestsPerMinute, and for
+// any hold placed by a 429
+func (p *httpProvider) pace(ctx context.Context) error {
+	p.paceMu.Lock()
+	now := time.Now()
+	slot := p.nextSlot
+	if slot.Before(now) {
+		slot = now
+	}
+	if p.limits.RequestsPerMinute > 0 {
+		p.nextSlot = slot.Add(time.Minute / time.Duration(p.limits.RequestsPerMinute))
+	}
+	p.paceMu.Unlock()
+
+	return sleepContext(ctx, slot.Sub(now))
+}
+
+// holdUntil delays every request to the provider until t
+func (p *httpProvider) holdUntil(t time.Time) {
+	p.paceMu.Lock()
+	defer p.paceMu.Unlock()
+	if t.After(p.nextSlot) {
+		p.nextSlot = t
+	}
+}
+
+// retryAfter reads the retry-after-ms or Retry-After header, the latter in
+// seconds or as an HTTP date
+func retryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// promptText returns the prompt of an inference input
+func promptText(input *InferenceInput) string {
+	return fmt.Sprintf("%v", input.Data)
+}
+
+// chatResult maps a reply into OpenAI's chat completion shape, which
+// callers of every provider read
+func chatResult(id, model, content, finishReason string, promptTokens, completionTokens int) map[string]interface{} {
+	return map[string]interface{}{
+		"id":     id,
+		"object": "chat.completion",
+		"model":  model,
+		"choices": []interface{}{map[string]interface{}{
+			"index":         0,
+			"message":       map[string]interface{}{"role": "assistant", "content": content},
+			"finish_reason": finishReason,
+		}},
+		"usage": usageResult(promptTokens, completionTokens),
+	}
+}
+
+// completionResult maps a reply into OpenAI's text completion shape
+func completionResult(id, model, text, finishReason string, promptTokens, completionTokens int) map[string]interface{} {
+	return map[string]interface{}{
+		"id":     id,
+		"object": "text_completion",
+		"model":  model,
+		"choices": []interface{}{map[string]interface{}{
+			"index":         0,
+			"text":          text,
+			"finish_reason": finishReason,
+		}},
+		"usage": usageResult(promptTokens, completionTokens),
+	}
+}
+
+// embeddingResult maps vectors into OpenAI's embedding shape
+func embeddingResult(model string, vectors [][]float64) map[string]interface{} {
+	data := make([]interface{}, len(vectors))
+	for i, vector := range vectors {
+		data[i] = map[string]interface{}{"object": "embedding", "index": i, "embedding": vector}
+	}
+	return map[string]interface{}{
+		"object": "list",
+		"model":  model,
+		"data":   data,
+	}
+}
+
+func usageResult(promptTokens, completionTokens int) map[string]interface{} {
+	return map[string]interface{}{
+		"prompt_tokens":     promptTokens,
+		"completion_tokens": completionTokens,
+		"total_tokens":      promptTokens + completionTokens,
+	}
+}
@@ -0,0 +1,379 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/httpclient"
+)
+
+// OllamaProvider provider for a local Ollama server. Models are pulled into
+// Ollama beforehand, or registered from a local GGUF file with
+// ModelConfig.Path.
+type OllamaProvider struct {
+	*httpProvider
+	baseURL  string
+	uploader *http.Client      // No timeout, for model file uploads
+	models   map[string]string // Model ID -> Ollama model name
+}
+
+// OllamaConfig configuration for Ollama
+type OllamaConfig struct {
+	BaseURL string // Optional, defaults to http://localhost:11434
+}
+
+// LoadOllamaConfig loads Ollama configuration from environment
+func LoadOllamaConfig() *OllamaConfig {
+	return &OllamaConfig{
+		BaseURL: os.Getenv("OLLAMA_HOST"),
+	}
+}
+
+// NewOllamaProvider creates a new Ollama provider
+func NewOllamaProvider(config *OllamaConfig) *OllamaProvider {
+	baseURL := strings.TrimSuffix(config.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL // OLLAMA_HOST is often host:port
+	}
+
+	// A busy local server answers 503 while a model loads
+	base := newHTTPProvider("ollama", RateLimitConfig{}, http.StatusServiceUnavailable)
+	base.client = httpclient.New(5 * time.Minute) // Local generation is slow on CPU
+
+	return &OllamaProvider{
+		httpProvider: base,
+		baseURL:      baseURL,
+		uploader:     httpclient.New(0),
+		models:       make(map[string]string),
+	}
+}
+
+// LoadModel loads an Ollama model. The Ollama model name is
+// Config["model"], or the model ID. With a Path, the GGUF file is uploaded
+// and created under that name unless Ollama already has it.
+func (p *OllamaProvider) LoadModel(config *ModelConfig) (*Model, error) {
+	name := config.ID
+	if model, ok := config.Config["model"].(string); ok && model != "" {
+		name = model
+	}
+
+	if config.Path != "" {
+		if err := p.createFromFile(context.Background(), name, config.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	p.mu.Lock()
+	p.models[config.ID] = name
+	p.mu.Unlock()
+
+	model := p.loadModel(config)
+	if config.Path != "" {
+		model.Endpoint = config.Path
+	}
+	return model, nil
+}
+
+// UnloadModel asks Ollama to release the model's memory. This is best
+// effort; Ollama also unloads idle models itself.
+func (p *OllamaProvider) UnloadModel(modelID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	requestBody := map[string]interface{}{"model": p.modelName(modelID), "keep_alive": 0}
+	var response map[string]interface{}
+	_ = p.postJSON(ctx, p.baseURL+"/api/generate", nil, requestBody, &response)
+
+	p.mu.Lock()
+	delete(p.models, modelID)
+	delete(p.metrics, modelID)
+	p.mu.Unlock()
+	return nil
+}
+
+// Predict performs inference with /api/chat, /api/generate or /api/embed.
+// Embeddings accept a string or a []string.
+func (p *OllamaProvider) Predict(ctx context.Context, modelID string, input *InferenceInput) (*InferenceOutput, error) {
+	startTime := time.Now()
+
+	var result interface{}
+	var err error
+
+	switch input.Parameters["type"] {
+	case "completion":
+		result, err = p.generate(ctx, modelID, input)
+	case "embedding":
+		result, err = p.embedding(ctx, modelID, input)
+	default:
+		result, err = p.chat(ctx, modelID, input) // Default to chat
+	}
+
+	return p.output(modelID, startTime, result, err)
+}
+
+// ollamaResponse is a non-streaming chat or generate response
+type ollamaResponse struct {
+	Model   string `json:"model"`
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Response        string `json:"response"`
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+}
+
+// chat performs a chat request
+func (p *OllamaProvider) chat(ctx context.Context, modelID string, input *InferenceInput) (interface{}, error) {
+	var response ollamaResponse
+	if err := p.postJSON(ctx, p.baseURL+"/api/chat", nil, p.chatRequest(modelID, input, false), &response); err != nil {
+		return nil, err
+	}
+	return chatResult("", response.Model, response.Message.Content, ollamaFinishReason(response.DoneReason),
+		response.PromptEvalCount, response.EvalCount), nil
+}
+
+// generate performs a raw completion request
+func (p *OllamaProvider) generate(ctx context.Context, modelID string, input *InferenceInput) (interface{}, error) {
+	var response ollamaResponse
+	if err := p.postJSON(ctx, p.baseURL+"/api/generate", nil, p.generateRequest(modelID, input, false), &response); err != nil {
+		return nil, err
+	}
+	return completionResult("", response.Model, response.Response, ollamaFinishReason(response.DoneReason),
+		response.PromptEvalCount, response.EvalCount), nil
+}
+
+// PredictStream streams a chat or completion reply, which Ollama sends as
+// newline-delimited JSON. Embeddings are delivered whole.
+func (p *OllamaProvider) PredictStream(ctx context.Context, modelID string, input *InferenceInput, fn StreamFunc) error {
+	var url string
+	var requestBody map[string]interface{}
+
+	switch input.Parameters["type"] {
+	case "embedding":
+		return predictAsStream(ctx, p, modelID, input, fn)
+	case "completion":
+		url, requestBody = p.baseURL+"/api/generate", p.generateRequest(modelID, input, true)
+	default:
+		url, requestBody = p.baseURL+"/api/chat", p.chatRequest(modelID, input, true)
+	}
+
+	startTime := time.Now()
+	err := p.streamLines(ctx, url, nil, requestBody, func(line []byte) error {
+		var response struct {
+			ollamaResponse
+			Done  bool   `json:"done"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(line, &response); err != nil {
+			return fmt.Errorf("ollama: invalid stream line: %w", err)
+		}
+		if response.Error != "" {
+			return fmt.Errorf("ollama stream error: %s", response.Error)
+		}
+
+		if text := response.Message.Content + response.Response; text != "" {
+			if err := fn(StreamChunk{Delta: text}); err != nil {
+				return err
+			}
+		}
+		if response.Done {
+			return fn(StreamChunk{
+				FinishReason: ollamaFinishReason(response.DoneReason),
+				Usage:        usageResult(response.PromptEvalCount, response.EvalCount),
+				Done:         true,
+			})
+		}
+		return nil
+	})
+
+	p.recordMetrics(modelID, time.Since(startTime), err != nil)
+	return err
+}
+
+// chatRequest builds an /api/chat request
+func (p *OllamaProvider) chatRequest(modelID string, input *InferenceInput, stream bool) map[string]interface{} {
+	messages := []map[string]string{
+		{"role": "user", "content": promptText(input)},
+	}
+
+	// Add system message if provided
+	if systemMsg, ok := input.Parameters["system"]; ok {
+		messages = append([]map[string]string{
+			{"role": "system", "content": fmt.Sprintf("%v", systemMsg)},
+		}, messages...)
+	}
+
+	requestBody := map[string]interface{}{
+		"model":    p.modelName(modelID),
+		"messages": messages,
+		"stream":   stream,
+	}
+	addOllamaOptions(requestBody, input)
+	return requestBody
+}
+
+// generateRequest builds an /api/generate request
+func (p *OllamaProvider) generateRequest(modelID string, input *InferenceInput, stream bool) map[string]interface{} {
+	requestBody := map[string]interface{}{
+		"model":  p.modelName(modelID),
+		"prompt": promptText(input),
+		"stream": stream,
+	}
+	if systemMsg, ok := input.Parameters["system"]; ok {
+		requestBody["system"] = fmt.Sprintf("%v", systemMsg)
+	}
+	addOllamaOptions(requestBody, input)
+	return requestBody
+}
+
+// embedding embeds a text or a batch of texts
+func (p *OllamaProvider) embedding(ctx context.Context, modelID string, input *InferenceInput) (interface{}, error) {
+	var data interface{} = promptText(input)
+	if texts, ok := input.Data.([]string); ok {
+		data = texts
+	}
+
+	requestBody := map[string]interface{}{
+		"model": p.modelName(modelID),
+		"input": data,
+	}
+
+	var response struct {
+		Embeddings [][]float64 `json:"embeddings"`
+	}
+	if err := p.postJSON(ctx, p.baseURL+"/api/embed", nil, requestBody, &response); err != nil {
+		return nil, err
+	}
+	return embeddingResult(modelID, response.Embeddings), nil
+}
+
+// createFromFile uploads a GGUF file as a blob, if Ollama lacks it, and
+// creates a model from it
+func (p *OllamaProvider) createFromFile(ctx context.Context, name, path string) error {
+	digest, err := fileDigest(path)
+	if err != nil {
+		return fmt.Errorf("ollama: failed to read model file: %w", err)
+	}
+
+	exists, err := p.blobExists(ctx, digest)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := p.uploadBlob(ctx, digest, path); err != nil {
+			return err
+		}
+	}
+
+	requestBody := map[string]interface{}{
+		"model":  name,
+		"files":  map[string]string{filepath.Base(path): digest},
+		"stream": false,
+	}
+	var response map[string]interface{}
+	if err := p.postJSON(ctx, p.baseURL+"/api/create", nil, requestBody, &response); err != nil {
+		return fmt.Errorf("ollama: failed to create model %s: %w", name, err)
+	}
+	return nil
+}
+
+func (p *OllamaProvider) blobExists(ctx context.Context, digest string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", p.baseURL+"/api/blobs/"+digest, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := p.uploader.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+func (p *OllamaProvider) uploadBlob(ctx context.Context, digest, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/blobs/"+digest, file)
+	if err != nil {
+		return err
+	}
+	if info, err := file.Stat(); err == nil {
+		req.ContentLength = info.Size()
+	}
+
+	resp, err := p.uploader.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ollama: failed to upload model file: %d - %s", resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
+
+// modelName returns the Ollama model serving a model ID
+func (p *OllamaProvider) modelName(modelID string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if name, ok := p.models[modelID]; ok {
+		return name
+	}
+	return modelID
+}
+
+// addOllamaOptions maps the common sampling parameters to Ollama options
+func addOllamaOptions(requestBody map[string]interface{}, input *InferenceInput) {
+	options := map[string]interface{}{}
+	if temp, ok := input.Parameters["temperature"]; ok {
+		options["temperature"] = temp
+	}
+	if maxTokens, ok := input.Parameters["max_tokens"]; ok {
+		options["num_predict"] = maxTokens
+	}
+	if len(options) > 0 {
+		requestBody["options"] = options
+	}
+}
+
+// ollamaFinishReason maps a done reason to OpenAI's finish reasons
+func ollamaFinishReason(reason string) string {
+	if reason == "" {
+		return "stop"
+	}
+	return reason // Ollama already uses stop and length
+}
+
+// fileDigest returns the sha256 digest of a file in Ollama's blob format
+func fileDigest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,551 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrONNXRuntimeUnavailable is returned when no ONNX runtime is configured
+// and the binary was built without the onnxruntime tag
+var ErrONNXRuntimeUnavailable = errors.New("ONNX Runtime support not built in (build with -tags onnxruntime)")
+
+// ONNXTensor is a model input or output. Exactly one of Float and Int64
+// holds the data, in row-major order.
+type ONNXTensor struct {
+	Shape []int64
+	Float []float32
+	Int64 []int64
+}
+
+// ONNXSession runs one loaded ONNX model
+type ONNXSession interface {
+	InputNames() []string
+	OutputNames() []string
+	Run(inputs map[string]ONNXTensor) (map[string]ONNXTensor, error)
+	Close() error
+}
+
+// ONNXRuntime opens ONNX model files
+type ONNXRuntime interface {
+	Open(path string) (ONNXSession, error)
+}
+
+// defaultONNXRuntime creates the built-in runtime; set when building with
+// the onnxruntime tag
+var defaultONNXRuntime func(config *ONNXConfig) (ONNXRuntime, error)
+
+// ONNXConfig configuration for ONNX models
+type ONNXConfig struct {
+	// Runtime opens models; defaults to ONNX Runtime when built with the
+	// onnxruntime tag
+	Runtime ONNXRuntime
+
+	LibraryPath string // onnxruntime shared library, for the built-in runtime
+	Threads     int    // Intra-op threads per session; 0 lets ONNX Runtime decide
+}
+
+// LoadONNXConfig loads ONNX configuration from environment
+func LoadONNXConfig() *ONNXConfig {
+	threads, _ := strconv.Atoi(os.Getenv("ONNXRUNTIME_THREADS"))
+	return &ONNXConfig{
+		LibraryPath: os.Getenv("ONNXRUNTIME_LIB"),
+		Threads:     threads,
+	}
+}
+
+// onnxModel is a loaded ONNX model and its processing options
+type onnxModel struct {
+	session   ONNXSession
+	kind      string // classification or embedding
+	input     string
+	output    string
+	labels    []string
+	softmax   bool
+	normalize bool
+	mu        sync.Mutex // Sessions are run one at a time
+}
+
+// ONNXProvider runs ONNX models from local files in process, for offline
+// classification and embeddings. Models are registered with
+// ModelConfig.Path.
+type ONNXProvider struct {
+	runtime    ONNXRuntime
+	runtimeErr error
+	models     map[string]*onnxModel
+	metrics    map[string]*ModelMetrics
+	mu         sync.RWMutex
+}
+
+// NewONNXProvider creates a new ONNX provider. Without a Runtime and the
+// onnxruntime build tag, loading models fails with
+// ErrONNXRuntimeUnavailable.
+func NewONNXProvider(config *ONNXConfig) *ONNXProvider {
+	p := &ONNXProvider{
+		runtime: config.Runtime,
+		models:  make(map[string]*onnxModel),
+		metrics: make(map[string]*ModelMetrics),
+	}
+	if p.runtime == nil {
+		if defaultONNXRuntime == nil {
+			p.runtimeErr = ErrONNXRuntimeUnavailable
+		} else {
+			p.runtime, p.runtimeErr = defaultONNXRuntime(config)
+		}
+	}
+	return p
+}
+
+// LoadModel opens the model file at config.Path. Config options:
+//
+//	task       "classification" or "embedding"; defaults from the model type
+//	input      input tensor name; defaults to the first input
+//	output     output tensor name; defaults to the first output
+//	labels     class names, in output order
+//	softmax    apply softmax to classification outputs (default true)
+//	normalize  L2-normalize embeddings (default true)
+func (p *ONNXProvider) LoadModel(config *ModelConfig) (*Model, error) {
+	if p.runtimeErr != nil {
+		return nil, p.runtimeErr
+	}
+	path := config.Path
+	if path == "" {
+		path = config.Endpoint
+	}
+	if path == "" {
+		return nil, fmt.Errorf("onnx: model %s has no path", config.ID)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("onnx: %w", err)
+	}
+
+	session, err := p.runtime.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("onnx: failed to open %s: %w", path, err)
+	}
+
+	loaded := &onnxModel{
+		session:   session,
+		kind:      "classification",
+		softmax:   configBool(config.Config, "softmax", true),
+		normalize: configBool(config.Config, "normalize", true),
+		labels:    configStrings(config.Config, "labels"),
+	}
+	if config.Type == ModelTypeEmbedding {
+		loaded.kind = "embedding"
+	}
+	if task, ok := config.Config["task"].(string); ok && task != "" {
+		loaded.kind = task
+	}
+	if names := session.InputNames(); len(names) > 0 {
+		loaded.input = names[0]
+	}
+	if input, ok := config.Config["input"].(string); ok && input != "" {
+		loaded.input = input
+	}
+	if names := session.OutputNames(); len(names) > 0 {
+		loaded.output = names[0]
+	}
+	if output, ok := config.Config["output"].(string); ok && output != "" {
+		loaded.output = output
+	}
+
+	p.mu.Lock()
+	if previous, exists := p.models[config.ID]; exists {
+		previous.session.Close()
+	}
+	p.models[config.ID] = loaded
+	p.metrics[config.ID] = &ModelMetrics{ModelID: config.ID}
+	p.mu.Unlock()
+
+	return &Model{
+		ID:       config.ID,
+		Name:     config.Name,
+		Version:  config.Version,
+		Type:     config.Type,
+		Status:   ModelStatusReady,
+		Endpoint: path,
+		Provider: "onnx",
+		Config:   config.Config,
+		Metadata: config.Metadata,
+		LoadedAt: time.Now(),
+	}, nil
+}
+
+// UnloadModel closes a model's session
+func (p *ONNXProvider) UnloadModel(modelID string) error {
+	p.mu.Lock()
+	loaded, exists := p.models[modelID]
+	delete(p.models, modelID)
+	delete(p.metrics, modelID)
+	p.mu.Unlock()
+
+	if exists {
+		return loaded.session.Close()
+	}
+	return nil
+}
+
+// Predict runs a model. Input data is a feature vector ([]float32,
+// []float64), a batch of them ([][]float32, [][]float64), token IDs
+// ([]int64, [][]int64) or named tensors (map[string]ONNXTensor). Token
+// models get an all-ones attention_mask and zero token_type_ids when they
+// declare those inputs and none are given.
+//
+// Classification returns {"predictions": [{"label", "score", "scores"}]}
+// per row; embedding returns OpenAI's embedding shape, mean-pooling
+// per-token outputs.
+func (p *ONNXProvider) Predict(ctx context.Context, modelID string, input *InferenceInput) (*InferenceOutput, error) {
+	startTime := time.Now()
+
+	p.mu.RLock()
+	loaded, exists := p.models[modelID]
+	p.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("onnx: model not loaded: %s", modelID)
+	}
+
+	result, err := p.run(ctx, modelID, loaded, input)
+	p.recordMetrics(modelID, time.Since(startTime), err != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InferenceOutput{
+		ModelID:   modelID,
+		Result:    result,
+		Metadata:  map[string]interface{}{"provider": "onnx"},
+		Latency:   time.Since(startTime),
+		Timestamp: time.Now(),
+	}, nil
+}
+
+func (p *ONNXProvider) run(ctx context.Context, modelID string, loaded *onnxModel, input *InferenceInput) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	inputs, err := onnxInputs(loaded, input.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded.mu.Lock()
+	outputs, err := loaded.session.Run(inputs)
+	loaded.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("onnx: inference failed: %w", err)
+	}
+
+	output, ok := outputs[loaded.output]
+	if !ok || len(output.Float) == 0 {
+		return nil, fmt.Errorf("onnx: model produced no float output %q", loaded.output)
+	}
+
+	kind := loaded.kind
+	if requested, ok := input.Parameters["type"].(string); ok && requested == "embedding" {
+		kind = "embedding"
+	}
+	if kind == "embedding" {
+		vectors, err := onnxEmbeddings(output, inputs["attention_mask"], loaded.normalize)
+		if err != nil {
+			return nil, err
+		}
+		return embeddingResult(modelID, vectors), nil
+	}
+	return onnxClassifications(output, loaded.labels, loaded.softmax)
+}
+
+// GetMetrics returns model metrics
+func (p *ONNXProvider) GetMetrics(modelID string) *ModelMetrics {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.metrics[modelID]
+}
+
+// recordMetrics records inference metrics
+func (p *ONNXProvider) recordMetrics(modelID string, latency time.Duration, isError bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	metrics := p.metrics[modelID]
+	if metrics == nil {
+		metrics = &ModelMetrics{ModelID: modelID}
+		p.metrics[modelID] = metrics
+	}
+
+	metrics.RequestCount++
+	metrics.TotalLatency += latency
+	metrics.AvgLatency = metrics.TotalLatency / time.Duration(metrics.RequestCount)
+	metrics.LastRequestAt = time.Now()
+
+	if isError {
+		metrics.ErrorCount++
+	}
+}
+
+// onnxInputs converts inference data into the model's input tensors
+func onnxInputs(loaded *onnxModel, data interface{}) (map[string]ONNXTensor, error) {
+	var tensor ONNXTensor
+	switch v := data.(type) {
+	case map[string]ONNXTensor:
+		return v, nil
+	case ONNXTensor:
+		tensor = v
+	case []float32:
+		tensor = ONNXTensor{Shape: []int64{1, int64(len(v))}, Float: v}
+	case []float64:
+		tensor = ONNXTensor{Shape: []int64{1, int64(len(v))}, Float: toFloat32(v)}
+	case [][]float32:
+		rows := make([][]float64, len(v))
+		for i, row := range v {
+			rows[i] = make([]float64, len(row))
+			for j, x := range row {
+				rows[i][j] = float64(x)
+			}
+		}
+		return onnxFloatBatch(loaded.input, rows)
+	case [][]float64:
+		return onnxFloatBatch(loaded.input, v)
+	case []int64:
+		tensor = ONNXTensor{Shape: []int64{1, int64(len(v))}, Int64: v}
+	case [][]int64:
+		flat, err := flattenRows(v)
+		if err != nil {
+			return nil, err
+		}
+		tensor = ONNXTensor{Shape: []int64{int64(len(v)), int64(len(v[0]))}, Int64: flat}
+	default:
+		return nil, fmt.Errorf("onnx: unsupported input type %T", data)
+	}
+
+	inputs := map[string]ONNXTensor{loaded.input: tensor}
+	if tensor.Int64 != nil {
+		// Token models commonly also take a mask and segment IDs
+		for _, name := range loaded.session.InputNames() {
+			switch name {
+			case "attention_mask":
+				inputs[name] = ONNXTensor{Shape: tensor.Shape, Int64: filledInt64(len(tensor.Int64), 1)}
+			case "token_type_ids":
+				inputs[name] = ONNXTensor{Shape: tensor.Shape, Int64: filledInt64(len(tensor.Int64), 0)}
+			}
+		}
+	}
+	return inputs, nil
+}
+
+func onnxFloatBatch(name string, rows [][]float64) (map[string]ONNXTensor, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("onnx: empty batch")
+	}
+	width := len(rows[0])
+	flat := make([]float32, 0, len(rows)*width)
+	for _, row := range rows {
+		if len(row) != width {
+			return nil, errors.New("onnx: batch rows differ in length")
+		}
+		flat = append(flat, toFloat32(row)...)
+	}
+	return map[string]ONNXTensor{
+		name: {Shape: []int64{int64(len(rows)), int64(width)}, Float: flat},
+	}, nil
+}
+
+// onnxClassifications maps [batch, classes] scores to predictions
+func onnxClassifications(output ONNXTensor, labels []string, softmax bool) (interface{}, error) {
+	rows, width, err := onnxRows(output)
+	if err != nil {
+		return nil, err
+	}
+
+	predictions := make([]interface{}, 0, rows)
+	for r := 0; r < rows; r++ {
+		scores := make([]float64, width)
+		for i := range scores {
+			scores[i] = float64(output.Float[r*width+i])
+		}
+		if softmax {
+			scores = softmaxScores(scores)
+		}
+
+		best := 0
+		byLabel := make(map[string]float64, width)
+		for i, score := range scores {
+			byLabel[classLabel(labels, i)] = score
+			if score > scores[best] {
+				best = i
+			}
+		}
+		predictions = append(predictions, map[string]interface{}{
+			"label":  classLabel(labels, best),
+			"score":  scores[best],
+			"scores": byLabel,
+		})
+	}
+	return map[string]interface{}{"predictions": predictions}, nil
+}
+
+// onnxEmbeddings maps [batch, dim] outputs, or [batch, tokens, dim] outputs
+// mean-pooled over the attention mask, to vectors
+func onnxEmbeddings(output ONNXTensor, mask ONNXTensor, normalize bool) ([][]float64, error) {
+	var vectors [][]float64
+	switch len(output.Shape) {
+	case 1, 2:
+		rows, width, err := onnxRows(output)
+		if err != nil {
+			return nil, err
+		}
+		for r := 0; r < rows; r++ {
+			vector := make([]float64, width)
+			for i := range vector {
+				vector[i] = float64(output.Float[r*width+i])
+			}
+			vectors = append(vectors, vector)
+		}
+	case 3:
+		batch, tokens, dim := int(output.Shape[0]), int(output.Shape[1]), int(output.Shape[2])
+		if batch*tokens*dim != len(output.Float) {
+			return nil, errors.New("onnx: output shape does not match its data")
+		}
+		for b := 0; b < batch; b++ {
+			vector := make([]float64, dim)
+			counted := 0
+			for t := 0; t < tokens; t++ {
+				if len(mask.Int64) == batch*tokens && mask.Int64[b*tokens+t] == 0 {
+					continue
+				}
+				counted++
+				offset := (b*tokens + t) * dim
+				for i := range vector {
+					vector[i] += float64(output.Float[offset+i])
+				}
+			}
+			if counted > 0 {
+				for i := range vector {
+					vector[i] /= float64(counted)
+				}
+			}
+			vectors = append(vectors, vector)
+		}
+	default:
+		return nil, fmt.Errorf("onnx: unsupported embedding output rank %d", len(output.Shape))
+	}
+
+	if normalize {
+		for _, vector := range vectors {
+			normalizeVector(vector)
+		}
+	}
+	return vectors, nil
+}
+
+// onnxRows returns the rows and row width of a rank 1 or 2 output
+func onnxRows(output ONNXTensor) (int, int, error) {
+	switch len(output.Shape) {
+	case 1:
+		return 1, len(output.Float), nil
+	case 2:
+		rows, width := int(output.Shape[0]), int(output.Shape[1])
+		if rows*width != len(output.Float) {
+			return 0, 0, errors.New("onnx: output shape does not match its data")
+		}
+		return rows, width, nil
+	default:
+		return 0, 0, fmt.Errorf("onnx: expected a rank 1 or 2 output, got rank %d", len(output.Shape))
+	}
+}
+
+func softmaxScores(scores []float64) []float64 {
+	maxScore := math.Inf(-1)
+	for _, s := range scores {
+		maxScore = math.Max(maxScore, s)
+	}
+	sum := 0.0
+	result := make([]float64, len(scores))
+	for i, s := range scores {
+		result[i] = math.Exp(s - maxScore)
+		sum += result[i]
+	}
+	for i := range result {
+		result[i] /= sum
+	}
+	return result
+}
+
+func normalizeVector(vector []float64) {
+	norm := 0.0
+	for _, x := range vector {
+		norm += x * x
+	}
+	if norm = math.Sqrt(norm); norm > 0 {
+		for i := range vector {
+			vector[i] /= norm
+		}
+	}
+}
+
+func classLabel(labels []string, i int) string {
+	if i < len(labels) {
+		return labels[i]
+	}
+	return strconv.Itoa(i)
+}
+
+func flattenRows(rows [][]int64) ([]int64, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("onnx: empty batch")
+	}
+	width := len(rows[0])
+	flat := make([]int64, 0, len(rows)*width)
+	for _, row := range rows {
+		if len(row) != width {
+			return nil, errors.New("onnx: batch rows differ in length; pad them")
+		}
+		flat = append(flat, row...)
+	}
+	return flat, nil
+}
+
+func filledInt64(n int, value int64) []int64 {
+	values := make([]int64, n)
+	for i := range values {
+		values[i] = value
+	}
+	return values
+}
+
+func toFloat32(values []float64) []float32 {
+	result := make([]float32, len(values))
+	for i, v := range values {
+		result[i] = float32(v)
+	}
+	return result
+}
+
+// configBool reads a bool option with a default
+func configBool(config map[string]interface{}, key string, fallback bool) bool {
+	if value, ok := config[key].(bool); ok {
+		return value
+	}
+	return fallback
+}
+
+// configStrings reads a string list option, from []string or decoded JSON
+func configStrings(config map[string]interface{}, key string) []string {
+	switch value := config[key].(type) {
+	case []string:
+		return value
+	case []interface{}:
+		result := make([]string, len(value))
+		for i, v := range value {
+			result[i] = fmt.Sprintf("%v", v)
+		}
+		return result
+	}
+	return nil
+}
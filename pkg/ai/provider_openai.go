@@ -7,17 +7,21 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"sync"
 	"time"
+
+	"neonexcore/pkg/httpclient"
 )
 
 // OpenAIProvider provider for OpenAI API
 type OpenAIProvider struct {
-	apiKey  string
-	baseURL string
-	client  *http.Client
-	metrics map[string]*ModelMetrics
-	mu      sync.RWMutex
+	apiKey       string
+	baseURL      string
+	client       *http.Client
+	streamClient *http.Client // No timeout; streams end with their context
+	metrics      map[string]*ModelMetrics
+	mu           sync.RWMutex
 }
 
 // OpenAIConfig configuration for OpenAI
@@ -26,6 +30,14 @@ type OpenAIConfig struct {
 	BaseURL string // Optional, defaults to https://api.openai.com/v1
 }
 
+// LoadOpenAIConfig loads OpenAI configuration from environment
+func LoadOpenAIConfig() *OpenAIConfig {
+	return &OpenAIConfig{
+		APIKey:  os.Getenv("OPENAI_API_KEY"),
+		BaseURL: os.Getenv("OPENAI_BASE_URL"),
+	}
+}
+
 // NewOpenAIProvider creates a new OpenAI provider
 func NewOpenAIProvider(config *OpenAIConfig) *OpenAIProvider {
 	baseURL := config.BaseURL
@@ -34,12 +46,11 @@ func NewOpenAIProvider(config *OpenAIConfig) *OpenAIProvider {
 	}
 
 	return &OpenAIProvider{
-		apiKey:  config.APIKey,
-		baseURL: baseURL,
-		client: &http.Client{
-			Timeout: 60 * time.Second,
-		},
-		metrics: make(map[string]*ModelMetrics),
+		apiKey:       config.APIKey,
+		baseURL:      baseURL,
+		client:       httpclient.New(60 * time.Second),
+		streamClient: httpclient.New(0),
+		metrics:      make(map[string]*ModelMetrics),
 	}
 }
 
@@ -255,6 +266,62 @@ func (p *OpenAIProvider) embedding(ctx context.Context, modelID string, input *I
 	return result, nil
 }
 
+// PredictStream streams a chat or completion reply as Server-Sent Events.
+// Embeddings are delivered whole.
+func (p *OpenAIProvider) PredictStream(ctx context.Context, modelID string, input *InferenceInput, fn StreamFunc) error {
+	var path string
+	var requestBody map[string]interface{}
+
+	switch input.Parameters["type"] {
+	case "embedding":
+		return predictAsStream(ctx, p, modelID, input, fn)
+	case "completion":
+		path = "/completions"
+		requestBody = map[string]interface{}{"prompt": input.Data}
+		addSamplingParameters(requestBody, input)
+	default:
+		path = "/chat/completions"
+		requestBody = chatRequest(input)
+	}
+	requestBody["model"] = modelID
+	requestBody["stream"] = true
+	requestBody["stream_options"] = map[string]interface{}{"include_usage": true}
+
+	startTime := time.Now()
+	err := p.stream(ctx, path, requestBody, fn)
+	p.recordMetrics(modelID, time.Since(startTime), err != nil)
+	return err
+}
+
+// stream posts a streaming request and maps its events
+func (p *OpenAIProvider) stream(ctx context.Context, path string, requestBody map[string]interface{}, fn StreamFunc) error {
+	body, err := json.Marshal(requestBody)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.streamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API error: %d - %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return readSSE(resp.Body, openAIStream(fn))
+}
+
 // GetMetrics returns model metrics
 func (p *OpenAIProvider) GetMetrics(modelID string) *ModelMetrics {
 	p.mu.RLock()
@@ -0,0 +1,193 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SandboxChatResponse is the content of every sandbox chat and completion
+// response. It is valid JSON, so structured-output callers such as
+// classifiers read it as an empty verdict.
+const SandboxChatResponse = `{"sandbox": true}`
+
+// sandboxEmbeddingSize is the length of sandbox embeddings
+const sandboxEmbeddingSize = 8
+
+// SandboxResponse is a scripted sandbox answer for chat and completion
+// requests
+type SandboxResponse struct {
+	ModelID  string // Matches any model when empty
+	Contains string // Matches any input containing it; any input when empty
+	Content  string // Returned as the response content
+	Err      error  // Fails the request instead when set
+}
+
+// matches reports whether the response answers a request
+func (r SandboxResponse) matches(modelID string, data interface{}) bool {
+	if r.ModelID != "" && r.ModelID != modelID {
+		return false
+	}
+	return strings.Contains(fmt.Sprintf("%v", data), r.Contains)
+}
+
+// SandboxProvider answers test mode inference without calling a real model.
+// Responses are deterministic and shaped like OpenAI's, and nothing is
+// billed. ModelManager routes test mode requests to it automatically, and
+// also registers it as the "sandbox" provider so models can be loaded
+// offline.
+//
+// Chat and completion requests get the first scripted response matching
+// them, or SandboxChatResponse.
+type SandboxProvider struct {
+	responses []SandboxResponse
+	metrics   map[string]*ModelMetrics
+	mu        sync.RWMutex
+}
+
+// NewSandboxProvider creates a sandbox provider
+func NewSandboxProvider() *SandboxProvider {
+	return &SandboxProvider{
+		metrics: make(map[string]*ModelMetrics),
+	}
+}
+
+// Script appends scripted responses. Earlier responses win.
+func (p *SandboxProvider) Script(responses ...SandboxResponse) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.responses = append(p.responses, responses...)
+}
+
+// Reset drops all scripted responses
+func (p *SandboxProvider) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.responses = nil
+}
+
+// LoadModel loads a sandbox model
+func (p *SandboxProvider) LoadModel(config *ModelConfig) (*Model, error) {
+	return &Model{
+		ID:       config.ID,
+		Name:     config.Name,
+		Version:  config.Version,
+		Type:     config.Type,
+		Status:   ModelStatusReady,
+		Provider: "sandbox",
+		Config:   config.Config,
+		Metadata: config.Metadata,
+		LoadedAt: time.Now(),
+	}, nil
+}
+
+// UnloadModel unloads a sandbox model
+func (p *SandboxProvider) UnloadModel(modelID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.metrics, modelID)
+	return nil
+}
+
+// Predict returns the scripted or canned response for the request type
+func (p *SandboxProvider) Predict(ctx context.Context, modelID string, input *InferenceInput) (*InferenceOutput, error) {
+	p.recordRequest(modelID)
+
+	var result map[string]interface{}
+	requestType := input.Parameters["type"]
+	if requestType == "embedding" {
+		// A []string is a batch, embedded per text
+		texts, ok := input.Data.([]string)
+		if !ok {
+			texts = []string{fmt.Sprintf("%v", input.Data)}
+		}
+		data := make([]interface{}, len(texts))
+		for i, text := range texts {
+			data[i] = map[string]interface{}{"index": i, "embedding": sandboxEmbedding(text)}
+		}
+		result = map[string]interface{}{
+			"model": modelID,
+			"data":  data,
+		}
+		return p.output(modelID, result), nil
+	}
+
+	content := SandboxChatResponse
+	if response, ok := p.match(modelID, input.Data); ok {
+		if response.Err != nil {
+			return nil, response.Err
+		}
+		content = response.Content
+	}
+
+	if requestType == "completion" {
+		result = map[string]interface{}{
+			"model":   modelID,
+			"choices": []interface{}{map[string]interface{}{"text": content}},
+		}
+	} else {
+		result = map[string]interface{}{
+			"model": modelID,
+			"choices": []interface{}{map[string]interface{}{
+				"message": map[string]interface{}{"role": "assistant", "content": content},
+			}},
+		}
+	}
+	return p.output(modelID, result), nil
+}
+
+// match returns the first scripted response for a request
+func (p *SandboxProvider) match(modelID string, data interface{}) (SandboxResponse, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, response := range p.responses {
+		if response.matches(modelID, data) {
+			return response, true
+		}
+	}
+	return SandboxResponse{}, false
+}
+
+func (p *SandboxProvider) output(modelID string, result map[string]interface{}) *InferenceOutput {
+	return &InferenceOutput{
+		ModelID:   modelID,
+		Result:    result,
+		Metadata:  map[string]interface{}{"sandbox": true},
+		Timestamp: time.Now(),
+	}
+}
+
+// GetMetrics returns model metrics
+func (p *SandboxProvider) GetMetrics(modelID string) *ModelMetrics {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.metrics[modelID]
+}
+
+func (p *SandboxProvider) recordRequest(modelID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	metrics, exists := p.metrics[modelID]
+	if !exists {
+		metrics = &ModelMetrics{ModelID: modelID}
+		p.metrics[modelID] = metrics
+	}
+	metrics.RequestCount++
+	metrics.LastRequestAt = time.Now()
+}
+
+// sandboxEmbedding derives a stable unit-range vector from the input, so
+// equal inputs embed equally
+func sandboxEmbedding(data interface{}) []float64 {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v", data)))
+	vector := make([]float64, sandboxEmbeddingSize)
+	for i := range vector {
+		vector[i] = float64(sum[i])/127.5 - 1
+	}
+	return vector
+}
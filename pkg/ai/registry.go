@@ -0,0 +1,397 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// Model version rollout stages
+const (
+	StageStaging    = "staging"    // Registered, served as name@staging
+	StageProduction = "production" // Served as name@production; one per model
+	StageArchived   = "archived"   // Retired and unloaded
+)
+
+var stages = map[string]bool{StageStaging: true, StageProduction: true, StageArchived: true}
+
+// ErrModelVersionNotFound is returned for unknown model versions
+var ErrModelVersionNotFound = errors.New("model version not found")
+
+// ModelVersion is a registered version of a model. It is loaded into the
+// model manager as "name@version" while in staging or production.
+//
+// Credentials are not stored; providers take them from the environment.
+type ModelVersion struct {
+	ID              uint                   `gorm:"primarykey" json:"id"`
+	Name            string                 `gorm:"size:100;uniqueIndex:idx_ai_model_versions_name_version;not null" json:"name"`
+	Version         string                 `gorm:"size:50;uniqueIndex:idx_ai_model_versions_name_version;not null" json:"version"`
+	Stage           string                 `gorm:"size:20;index;not null" json:"stage"`
+	Description     string                 `gorm:"size:1000" json:"description,omitempty"`
+	Type            ModelType              `gorm:"size:50" json:"type"`
+	Provider        string                 `gorm:"size:50;not null" json:"provider"`
+	Endpoint        string                 `gorm:"size:500" json:"endpoint,omitempty"`
+	Path            string                 `gorm:"size:500" json:"path,omitempty"`
+	Config          map[string]interface{} `gorm:"serializer:json" json:"config,omitempty"`
+	Metadata        map[string]string      `gorm:"serializer:json" json:"metadata,omitempty"`
+	PreviousVersion string                 `gorm:"size:50" json:"previous_version,omitempty"` // Production version it replaced
+	PromotedAt      *time.Time             `json:"promoted_at,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+}
+
+// TableName specifies the table name for ModelVersion
+func (ModelVersion) TableName() string {
+	return "ai_model_versions"
+}
+
+// ModelID returns the ID the version is loaded under
+func (v *ModelVersion) ModelID() string {
+	return v.Name + "@" + v.Version
+}
+
+func (v *ModelVersion) modelConfig() *ModelConfig {
+	return &ModelConfig{
+		ID:       v.ModelID(),
+		Name:     v.Name,
+		Version:  v.Version,
+		Type:     v.Type,
+		Provider: v.Provider,
+		Endpoint: v.Endpoint,
+		Path:     v.Path,
+		Config:   v.Config,
+		Metadata: v.Metadata,
+	}
+}
+
+// ModelRegistry persists model versions and their rollout stages, loads
+// them into a ModelManager and points the "name@production" and
+// "name@staging" aliases at the right version
+type ModelRegistry struct {
+	db      *gorm.DB
+	manager *ModelManager
+	mu      sync.Mutex // Serializes stage changes
+}
+
+// NewModelRegistry creates a registry serving versions through the manager
+// and makes it the manager's registry
+func NewModelRegistry(db *gorm.DB, manager *ModelManager) *ModelRegistry {
+	registry := &ModelRegistry{db: db, manager: manager}
+	manager.mu.Lock()
+	manager.registry = registry
+	manager.mu.Unlock()
+	return registry
+}
+
+// Load loads every staging and production version into the manager and
+// sets their aliases. Versions that fail to load are logged and skipped.
+func (r *ModelRegistry) Load(ctx context.Context) error {
+	var versions []ModelVersion
+	err := r.db.WithContext(ctx).
+		Where("stage IN ?", []string{StageStaging, StageProduction}).
+		Order("name ASC, created_at ASC").
+		Find(&versions).Error
+	if err != nil {
+		return fmt.Errorf("failed to load model versions: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make(map[string]bool)
+	for i := range versions {
+		version := &versions[i]
+		if _, err := r.manager.LoadModel(version.modelConfig()); err != nil {
+			logger.Warn("Failed to load registered model", logger.Fields{"model": version.ModelID(), "error": err.Error()})
+			continue
+		}
+		names[version.Name] = true
+	}
+	for name := range names {
+		if err := r.refreshAliases(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Register adds a model version. It enters staging unless its stage is set
+// to production, in which case it is promoted once registered.
+func (r *ModelRegistry) Register(ctx context.Context, version *ModelVersion) (*ModelVersion, error) {
+	if err := validateVersion(version); err != nil {
+		return nil, err
+	}
+	production := version.Stage == StageProduction
+	if version.Stage == "" || production {
+		version.Stage = StageStaging
+	}
+	if version.Stage != StageStaging {
+		return nil, fmt.Errorf("new versions must enter staging or production, not %s", version.Stage)
+	}
+
+	r.mu.Lock()
+	existing, err := r.find(ctx, version.Name, version.Version)
+	if err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	if existing != nil {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("model version already registered: %s", version.ModelID())
+	}
+
+	// Load first so broken versions never reach the registry
+	if _, err := r.manager.LoadModel(version.modelConfig()); err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	now := time.Now()
+	version.PromotedAt = &now
+	if err := r.db.WithContext(ctx).Create(version).Error; err != nil {
+		r.mu.Unlock()
+		r.unload(version)
+		return nil, fmt.Errorf("failed to register model version: %w", err)
+	}
+	err = r.refreshAliases(ctx, version.Name)
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if production {
+		return r.Promote(ctx, version.Name, version.Version, StageProduction)
+	}
+	return version, nil
+}
+
+// Get returns a model version
+func (r *ModelRegistry) Get(ctx context.Context, name, version string) (*ModelVersion, error) {
+	found, err := r.find(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %s@%s", ErrModelVersionNotFound, name, version)
+	}
+	return found, nil
+}
+
+// Versions lists a model's versions, newest first, or every model's when
+// name is empty
+func (r *ModelRegistry) Versions(ctx context.Context, name string) ([]ModelVersion, error) {
+	var versions []ModelVersion
+	query := r.db.WithContext(ctx)
+	if name != "" {
+		query = query.Where("name = ?", name)
+	}
+	if err := query.Order("name ASC, created_at DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list model versions: %w", err)
+	}
+	return versions, nil
+}
+
+// Production returns a model's production version, or nil
+func (r *ModelRegistry) Production(ctx context.Context, name string) (*ModelVersion, error) {
+	return r.findInStage(ctx, name, StageProduction)
+}
+
+// Promote moves a version to a stage. Promoting to production archives the
+// current production version and remembers it for RollbackModel; the
+// production version itself cannot be archived directly.
+func (r *ModelRegistry) Promote(ctx context.Context, name, version, stage string) (*ModelVersion, error) {
+	if !stages[stage] {
+		return nil, fmt.Errorf("unknown stage: %s", stage)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	target, err := r.Get(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	if target.Stage == stage {
+		return target, nil
+	}
+	if target.Stage == StageProduction {
+		return nil, fmt.Errorf("%s is in production; promote or roll back to another version first", target.ModelID())
+	}
+
+	if stage == StageArchived {
+		target.Stage = StageArchived
+		if err := r.db.WithContext(ctx).Save(target).Error; err != nil {
+			return nil, fmt.Errorf("failed to archive model version: %w", err)
+		}
+		r.unload(target)
+		return target, r.refreshAliases(ctx, name)
+	}
+
+	if _, err := r.manager.LoadModel(target.modelConfig()); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	target.PromotedAt = &now
+	target.Stage = stage
+
+	var replaced *ModelVersion
+	if stage == StageProduction {
+		if replaced, err = r.findInStage(ctx, name, StageProduction); err != nil {
+			return nil, err
+		}
+		if replaced != nil {
+			target.PreviousVersion = replaced.Version
+		}
+	}
+
+	if err := r.swap(ctx, replaced, target); err != nil {
+		return nil, err
+	}
+	logger.Info("Model version promoted", logger.Fields{"model": target.ModelID(), "stage": stage})
+	return target, nil
+}
+
+// Rollback returns a model's production to the version it replaced and
+// archives the current one. Repeated rollbacks walk further back.
+func (r *ModelRegistry) Rollback(ctx context.Context, name string) (*ModelVersion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.findInStage(ctx, name, StageProduction)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, fmt.Errorf("%s has no production version", name)
+	}
+	if current.PreviousVersion == "" {
+		return nil, fmt.Errorf("%s has no earlier production version to roll back to", current.ModelID())
+	}
+
+	previous, err := r.Get(ctx, name, current.PreviousVersion)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.manager.LoadModel(previous.modelConfig()); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	previous.Stage = StageProduction
+	previous.PromotedAt = &now
+	if err := r.swap(ctx, current, previous); err != nil {
+		return nil, err
+	}
+	logger.Info("Model version rolled back", logger.Fields{"model": previous.ModelID(), "from": current.ModelID()})
+	return previous, nil
+}
+
+// swap saves the promoted version and archives the version it replaces, if
+// any, then updates aliases and unloads the archived version
+func (r *ModelRegistry) swap(ctx context.Context, replaced, promoted *ModelVersion) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if replaced != nil {
+			replaced.Stage = StageArchived
+			if err := tx.Save(replaced).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(promoted).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update model stages: %w", err)
+	}
+
+	if err := r.refreshAliases(ctx, promoted.Name); err != nil {
+		return err
+	}
+	if replaced != nil {
+		r.unload(replaced)
+	}
+	return nil
+}
+
+// refreshAliases points name@production at the production version and
+// name@staging at the most recently staged version
+func (r *ModelRegistry) refreshAliases(ctx context.Context, name string) error {
+	for _, stage := range []string{StageProduction, StageStaging} {
+		version, err := r.findInStage(ctx, name, stage)
+		if err != nil {
+			return err
+		}
+		if version != nil {
+			r.manager.setAlias(name+"@"+stage, version.ModelID())
+		} else {
+			r.manager.setAlias(name+"@"+stage, "")
+		}
+	}
+	return nil
+}
+
+func (r *ModelRegistry) unload(version *ModelVersion) {
+	if r.manager.getModel(version.ModelID()) == nil {
+		return
+	}
+	if err := r.manager.UnloadModel(version.ModelID()); err != nil {
+		logger.Warn("Failed to unload model version", logger.Fields{"model": version.ModelID(), "error": err.Error()})
+	}
+}
+
+func (r *ModelRegistry) find(ctx context.Context, name, version string) (*ModelVersion, error) {
+	var found ModelVersion
+	err := r.db.WithContext(ctx).Where("name = ? AND version = ?", name, version).First(&found).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load model version: %w", err)
+	}
+	return &found, nil
+}
+
+func (r *ModelRegistry) findInStage(ctx context.Context, name, stage string) (*ModelVersion, error) {
+	var found ModelVersion
+	err := r.db.WithContext(ctx).
+		Where("name = ? AND stage = ?", name, stage).
+		Order("promoted_at DESC, id DESC").
+		First(&found).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load model version: %w", err)
+	}
+	return &found, nil
+}
+
+func validateVersion(version *ModelVersion) error {
+	if version.Name == "" || version.Version == "" {
+		return fmt.Errorf("model name and version are required")
+	}
+	if strings.Contains(version.Name, "@") || strings.Contains(version.Version, "@") {
+		return fmt.Errorf("model names and versions cannot contain @")
+	}
+	if stages[version.Version] {
+		return fmt.Errorf("model version cannot be named after a stage: %s", version.Version)
+	}
+	if version.Provider == "" {
+		return fmt.Errorf("model provider is required")
+	}
+	return nil
+}
+
+// splitAlias splits "name@stage" into its parts
+func splitAlias(modelID string) (string, string, bool) {
+	name, stage, ok := strings.Cut(modelID, "@")
+	if !ok || !stages[stage] {
+		return "", "", false
+	}
+	return name, stage, true
+}
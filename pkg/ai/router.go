@@ -0,0 +1,504 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/metrics"
+)
+
+// RoutingKeyMetadata is the input metadata key whose value pins a request
+// to a variant, e.g. a user or session ID. Requests without one are pinned
+// by caller, or split at random when there is no caller either.
+const RoutingKeyMetadata = "routing_key"
+
+// Router defaults
+const (
+	defaultShadowTimeout     = 30 * time.Second
+	defaultShadowConcurrency = 10
+	shadowRecordLimit        = 100
+)
+
+// latencyBuckets are the latency histogram buckets, in milliseconds
+var latencyBuckets = []float64{50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000}
+
+// Variant is one model version receiving a share of a route's traffic
+type Variant struct {
+	Name    string `json:"name"`     // e.g. "control", "candidate"
+	ModelID string `json:"model_id"` // Model ID or alias, e.g. "sentiment@staging"
+	Weight  int    `json:"weight"`   // Relative share of traffic
+}
+
+// CompareFunc reports whether a shadow output agrees with the primary one
+type CompareFunc func(primary, shadow *InferenceOutput) bool
+
+// Route splits requests for a route name between variants and optionally
+// mirrors them to a shadow model
+type Route struct {
+	Name     string    `json:"name"` // Model ID callers use, e.g. "sentiment"
+	Variants []Variant `json:"variants"`
+
+	// Shadow runs this model on a sample of requests in the background.
+	// Its outputs are recorded and compared, never returned.
+	Shadow     string      `json:"shadow,omitempty"`
+	ShadowRate float64     `json:"shadow_rate,omitempty"` // Share of requests to mirror, 0-1; all when 0
+	Compare    CompareFunc `json:"-"`                     // Agreement check; compares result text by default
+}
+
+// VariantStats are the outcomes of one variant, or of a shadow model
+type VariantStats struct {
+	Name          string           `json:"name"`
+	ModelID       string           `json:"model_id"`
+	Requests      int64            `json:"requests"`
+	Errors        int64            `json:"errors"`
+	AvgLatencyMs  float64          `json:"avg_latency_ms"`
+	MaxLatencyMs  float64          `json:"max_latency_ms"`
+	Outcomes      map[string]int64 `json:"outcomes,omitempty"`       // From RecordOutcome
+	Agreements    int64            `json:"agreements,omitempty"`     // Shadow outputs agreeing with the primary
+	AgreementRate float64          `json:"agreement_rate,omitempty"` // Agreements per successful shadow request
+	Skipped       int64            `json:"skipped,omitempty"`        // Shadow requests dropped under load
+
+	totalLatency time.Duration
+}
+
+// RouteStats compares a route's variants
+type RouteStats struct {
+	Route    string         `json:"route"`
+	Since    time.Time      `json:"since"`
+	Variants []VariantStats `json:"variants"`
+	Shadow   *VariantStats  `json:"shadow,omitempty"`
+	Recent   []ShadowRecord `json:"recent_shadow,omitempty"`
+}
+
+// ShadowRecord is a shadow model's output next to the primary output it
+// mirrored
+type ShadowRecord struct {
+	Route          string        `json:"route"`
+	PrimaryModel   string        `json:"primary_model"`
+	ShadowModel    string        `json:"shadow_model"`
+	PrimaryResult  interface{}   `json:"primary_result"`
+	ShadowResult   interface{}   `json:"shadow_result,omitempty"`
+	Error          string        `json:"error,omitempty"`
+	Agreed         bool          `json:"agreed"`
+	PrimaryLatency time.Duration `json:"primary_latency"`
+	ShadowLatency  time.Duration `json:"shadow_latency"`
+	Timestamp      time.Time     `json:"timestamp"`
+}
+
+// routeState is a route with its running stats
+type routeState struct {
+	route       Route
+	totalWeight int
+	since       time.Time
+	variants    map[string]*VariantStats
+	shadow      *VariantStats
+	recent      []ShadowRecord
+}
+
+// ModelRouter splits inference traffic between model versions by weight for
+// A/B tests, and shadows candidate models to compare them with production
+// without affecting responses. Requests for model IDs without a route pass
+// straight to the manager.
+type ModelRouter struct {
+	manager   *ModelManager
+	collector *metrics.Collector
+	onShadow  func(ShadowRecord)
+
+	mu     sync.RWMutex
+	routes map[string]*routeState
+
+	shadowSlots   chan struct{}
+	shadowTimeout time.Duration
+}
+
+// NewModelRouter creates a router serving requests through the manager
+func NewModelRouter(manager *ModelManager) *ModelRouter {
+	return &ModelRouter{
+		manager:       manager,
+		routes:        make(map[string]*routeState),
+		shadowSlots:   make(chan struct{}, defaultShadowConcurrency),
+		shadowTimeout: defaultShadowTimeout,
+	}
+}
+
+// SetMetrics reports per-variant requests, errors and latency to a metrics
+// collector
+func (r *ModelRouter) SetMetrics(collector *metrics.Collector) {
+	r.collector = collector
+}
+
+// OnShadow calls fn with every shadow record, e.g. to persist it
+func (r *ModelRouter) OnShadow(fn func(ShadowRecord)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onShadow = fn
+}
+
+// SetShadowLimits bounds how many shadow requests run at once and how long
+// each may take. Requests beyond the limit are skipped.
+func (r *ModelRouter) SetShadowLimits(concurrency int, timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if concurrency > 0 {
+		r.shadowSlots = make(chan struct{}, concurrency)
+	}
+	if timeout > 0 {
+		r.shadowTimeout = timeout
+	}
+}
+
+// SetRoute adds or replaces a route and resets its stats
+func (r *ModelRouter) SetRoute(route Route) error {
+	if route.Name == "" {
+		return fmt.Errorf("route name is required")
+	}
+	if len(route.Variants) == 0 {
+		return fmt.Errorf("route %s needs at least one variant", route.Name)
+	}
+	if route.ShadowRate < 0 || route.ShadowRate > 1 {
+		return fmt.Errorf("shadow rate must be between 0 and 1")
+	}
+
+	state := &routeState{
+		route:    route,
+		since:    time.Now(),
+		variants: make(map[string]*VariantStats, len(route.Variants)),
+	}
+	state.route.Variants = append([]Variant(nil), route.Variants...)
+	for i := range state.route.Variants {
+		variant := &state.route.Variants[i]
+		if variant.ModelID == "" {
+			return fmt.Errorf("variant %d of route %s has no model", i, route.Name)
+		}
+		if variant.ModelID == route.Name {
+			return fmt.Errorf("variant %d of route %s routes to itself", i, route.Name)
+		}
+		if variant.Weight < 0 {
+			return fmt.Errorf("variant weights cannot be negative")
+		}
+		if variant.Name == "" {
+			variant.Name = variant.ModelID
+		}
+		if state.variants[variant.Name] != nil {
+			return fmt.Errorf("duplicate variant %s in route %s", variant.Name, route.Name)
+		}
+		state.variants[variant.Name] = &VariantStats{Name: variant.Name, ModelID: variant.ModelID}
+		state.totalWeight += variant.Weight
+	}
+	if state.totalWeight == 0 {
+		return fmt.Errorf("route %s has no weighted variant", route.Name)
+	}
+	if route.Shadow != "" {
+		state.shadow = &VariantStats{Name: "shadow", ModelID: route.Shadow}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[route.Name] = state
+	return nil
+}
+
+// RemoveRoute stops routing a route name
+func (r *ModelRouter) RemoveRoute(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.routes, name)
+}
+
+// Routes returns the configured routes
+func (r *ModelRouter) Routes() []Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	routes := make([]Route, 0, len(r.routes))
+	for _, state := range r.routes {
+		routes = append(routes, state.route)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Name < routes[j].Name })
+	return routes
+}
+
+// Predict serves a request through its route's variant and mirrors it to
+// the route's shadow model. The output's "variant" metadata names the
+// variant that served it.
+func (r *ModelRouter) Predict(ctx context.Context, input *InferenceInput) (*InferenceOutput, error) {
+	state, variant := r.choose(ctx, input)
+	if state == nil {
+		return r.manager.Predict(ctx, input)
+	}
+
+	routed := *input
+	routed.ModelID = variant.ModelID
+	started := time.Now()
+	output, err := r.manager.Predict(ctx, &routed)
+	latency := time.Since(started)
+	r.record(state, state.variants[variant.Name], latency, err)
+	if err != nil {
+		return nil, err
+	}
+
+	if output.Metadata == nil {
+		output.Metadata = make(map[string]interface{})
+	}
+	output.Metadata["route"] = state.route.Name
+	output.Metadata["variant"] = variant.Name
+
+	if state.shadow != nil && (state.route.ShadowRate == 0 || rand.Float64() < state.route.ShadowRate) {
+		r.runShadow(ctx, state, &routed, output, latency)
+	}
+	return output, nil
+}
+
+// PredictStream streams a request through its route's variant. Streams are
+// not shadowed.
+func (r *ModelRouter) PredictStream(ctx context.Context, input *InferenceInput, fn StreamFunc) error {
+	state, variant := r.choose(ctx, input)
+	if state == nil {
+		return r.manager.PredictStream(ctx, input, fn)
+	}
+
+	routed := *input
+	routed.ModelID = variant.ModelID
+	started := time.Now()
+	err := r.manager.PredictStream(ctx, &routed, fn)
+	r.record(state, state.variants[variant.Name], time.Since(started), err)
+	return err
+}
+
+// RecordOutcome counts a downstream outcome for the variant that served a
+// request, e.g. "accepted" or "thumbs_down", to compare variants beyond
+// latency and errors
+func (r *ModelRouter) RecordOutcome(route, variant, outcome string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.routes[route]
+	if state == nil {
+		return
+	}
+	stats := state.variants[variant]
+	if stats == nil {
+		return
+	}
+	if stats.Outcomes == nil {
+		stats.Outcomes = make(map[string]int64)
+	}
+	stats.Outcomes[outcome]++
+
+	if r.collector != nil {
+		r.collector.NewCounter(r.metricName(route, variant)+"_outcome_"+metricPart(outcome)+"_total",
+			"Outcome "+outcome+" of "+variant+" on route "+route,
+			map[string]string{"route": route, "variant": variant, "outcome": outcome}).Inc()
+	}
+}
+
+// Stats returns a route's per-variant and shadow stats, or nil for unknown
+// routes
+func (r *ModelRouter) Stats(route string) *RouteStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	state := r.routes[route]
+	if state == nil {
+		return nil
+	}
+
+	stats := &RouteStats{Route: route, Since: state.since}
+	for _, variant := range state.route.Variants {
+		stats.Variants = append(stats.Variants, copyStats(state.variants[variant.Name]))
+	}
+	if state.shadow != nil {
+		shadow := copyStats(state.shadow)
+		stats.Shadow = &shadow
+	}
+	stats.Recent = append([]ShadowRecord(nil), state.recent...)
+	return stats
+}
+
+// choose returns the route for the input's model ID and the variant to
+// serve it, or nil when the ID is not routed
+func (r *ModelRouter) choose(ctx context.Context, input *InferenceInput) (*routeState, Variant) {
+	r.mu.RLock()
+	state := r.routes[input.ModelID]
+	r.mu.RUnlock()
+	if state == nil {
+		return nil, Variant{}
+	}
+
+	var pick int
+	if key := routingKey(ctx, input); key != "" {
+		hash := fnv.New32a()
+		hash.Write([]byte(state.route.Name + "\x00" + key))
+		pick = int(hash.Sum32() % uint32(state.totalWeight))
+	} else {
+		pick = rand.Intn(state.totalWeight)
+	}
+
+	for _, variant := range state.route.Variants {
+		if pick < variant.Weight {
+			return state, variant
+		}
+		pick -= variant.Weight
+	}
+	return state, state.route.Variants[len(state.route.Variants)-1]
+}
+
+// runShadow mirrors a request to the route's shadow model in the
+// background, charging its usage to "shadow:<route>"
+func (r *ModelRouter) runShadow(ctx context.Context, state *routeState, input *InferenceInput, primary *InferenceOutput, primaryLatency time.Duration) {
+	r.mu.RLock()
+	slots, timeout, onShadow := r.shadowSlots, r.shadowTimeout, r.onShadow
+	r.mu.RUnlock()
+
+	select {
+	case slots <- struct{}{}:
+	default:
+		r.mu.Lock()
+		state.shadow.Skipped++
+		r.mu.Unlock()
+		return
+	}
+
+	mirrored := *input
+	mirrored.ModelID = state.route.Shadow
+	shadowCtx := WithCaller(context.WithoutCancel(ctx), "shadow:"+state.route.Name)
+
+	go func() {
+		defer func() { <-slots }()
+
+		shadowCtx, cancel := context.WithTimeout(shadowCtx, timeout)
+		defer cancel()
+
+		started := time.Now()
+		output, err := r.manager.Predict(shadowCtx, &mirrored)
+		record := ShadowRecord{
+			Route:          state.route.Name,
+			PrimaryModel:   primary.ModelID,
+			ShadowModel:    mirrored.ModelID,
+			PrimaryResult:  primary.Result,
+			PrimaryLatency: primaryLatency,
+			ShadowLatency:  time.Since(started),
+			Timestamp:      time.Now(),
+		}
+		if err != nil {
+			record.Error = err.Error()
+		} else {
+			record.ShadowResult = output.Result
+			compare := state.route.Compare
+			if compare == nil {
+				compare = sameResult
+			}
+			record.Agreed = compare(primary, output)
+		}
+
+		r.record(state, state.shadow, record.ShadowLatency, err)
+		r.mu.Lock()
+		if record.Agreed {
+			state.shadow.Agreements++
+		}
+		state.recent = append(state.recent, record)
+		if len(state.recent) > shadowRecordLimit {
+			state.recent = state.recent[len(state.recent)-shadowRecordLimit:]
+		}
+		r.mu.Unlock()
+
+		if r.collector != nil && record.Agreed {
+			r.collector.NewCounter(r.metricName(state.route.Name, "shadow")+"_agreements_total",
+				"Shadow outputs agreeing with production on route "+state.route.Name,
+				map[string]string{"route": state.route.Name, "variant": "shadow"}).Inc()
+		}
+		if onShadow != nil {
+			onShadow(record)
+		}
+		if err != nil {
+			logger.Debug("Shadow request failed", logger.Fields{"route": state.route.Name, "model": mirrored.ModelID, "error": err.Error()})
+		}
+	}()
+}
+
+// record counts a request, its latency and any error for a variant
+func (r *ModelRouter) record(state *routeState, stats *VariantStats, latency time.Duration, err error) {
+	r.mu.Lock()
+	stats.Requests++
+	if err != nil {
+		stats.Errors++
+	}
+	stats.totalLatency += latency
+	ms := float64(latency) / float64(time.Millisecond)
+	if ms > stats.MaxLatencyMs {
+		stats.MaxLatencyMs = ms
+	}
+	r.mu.Unlock()
+
+	if r.collector == nil {
+		return
+	}
+	name := r.metricName(state.route.Name, stats.Name)
+	labels := map[string]string{"route": state.route.Name, "variant": stats.Name, "model": stats.ModelID}
+	r.collector.NewCounter(name+"_requests_total", "Requests served by "+stats.Name+" on route "+state.route.Name, labels).Inc()
+	if err != nil {
+		r.collector.NewCounter(name+"_errors_total", "Failed requests of "+stats.Name+" on route "+state.route.Name, labels).Inc()
+	}
+	r.collector.NewHistogram(name+"_latency_ms", "Latency of "+stats.Name+" on route "+state.route.Name, labels, latencyBuckets).Observe(ms)
+}
+
+func (r *ModelRouter) metricName(route, variant string) string {
+	return "ai_route_" + metricPart(route) + "_" + metricPart(variant)
+}
+
+// routingKey returns the value pinning a request to a variant
+func routingKey(ctx context.Context, input *InferenceInput) string {
+	if key := input.Metadata[RoutingKeyMetadata]; key != "" {
+		return key
+	}
+	return CallerFromContext(ctx)
+}
+
+// sameResult compares chat and completion text, ignoring case and
+// surrounding space, or whole results for other models
+func sameResult(primary, shadow *InferenceOutput) bool {
+	primaryText, _ := resultText(primary.Result)
+	shadowText, _ := resultText(shadow.Result)
+	if primaryText != "" || shadowText != "" {
+		return strings.EqualFold(strings.TrimSpace(primaryText), strings.TrimSpace(shadowText))
+	}
+	return reflect.DeepEqual(primary.Result, shadow.Result)
+}
+
+func copyStats(stats *VariantStats) VariantStats {
+	result := *stats
+	if stats.Requests > 0 {
+		result.AvgLatencyMs = float64(stats.totalLatency) / float64(time.Millisecond) / float64(stats.Requests)
+	}
+	if compared := stats.Requests - stats.Errors; stats.Agreements > 0 && compared > 0 {
+		result.AgreementRate = float64(stats.Agreements) / float64(compared)
+	}
+	if stats.Outcomes != nil {
+		result.Outcomes = make(map[string]int64, len(stats.Outcomes))
+		for outcome, count := range stats.Outcomes {
+			result.Outcomes[outcome] = count
+		}
+	}
+	return result
+}
+
+// metricPart makes a name safe for use in metric names
+func metricPart(name string) string {
+	return strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+			return c
+		case c >= 'A' && c <= 'Z':
+			return c + ('a' - 'A')
+		}
+		return '_'
+	}, name)
+}
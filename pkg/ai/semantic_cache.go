@@ -0,0 +1,277 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/metrics"
+)
+
+// SemanticCacheConfig configures the semantic cache of a model
+type SemanticCacheConfig struct {
+	EmbeddingModel string        // Model embedding the prompts
+	Store          VectorStore   // Where prompt embeddings and responses are kept
+	Threshold      float32       // Minimum similarity for a hit (default 0.95)
+	TTL            time.Duration // How long a response is reused (default 24h)
+}
+
+// DefaultSemanticCacheThreshold is the similarity a cached prompt needs
+// by default. Cosine similarities this high mean rewordings of the same
+// question; lower values start returning answers to different ones.
+const DefaultSemanticCacheThreshold = 0.95
+
+// SemanticCacheStats reports a model's semantic cache
+type SemanticCacheStats struct {
+	ModelID   string  `json:"model_id"`
+	Threshold float32 `json:"threshold"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	HitRate   float64 `json:"hit_rate"`
+	Errors    int64   `json:"errors"` // Failed embeddings, lookups and stores, served uncached
+}
+
+// EnableSemanticCache answers text prompts to a model with the cached
+// response to the most similar earlier prompt, when it is at least
+// Threshold similar. Prompts must otherwise match exactly: same model,
+// system prompt and parameters. Model IDs may be aliases such as
+// "name@production".
+func (m *ModelManager) EnableSemanticCache(modelID string, config SemanticCacheConfig) error {
+	if config.EmbeddingModel == "" {
+		return fmt.Errorf("semantic cache for %s: embedding model required", modelID)
+	}
+	if config.Store == nil {
+		return fmt.Errorf("semantic cache for %s: vector store required", modelID)
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = DefaultSemanticCacheThreshold
+	}
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.semantic[modelID] = &semanticCache{modelID: modelID, config: config, collector: m.collector}
+	return nil
+}
+
+// DisableSemanticCache stops semantic caching for a model. Stored
+// responses stay in the vector store.
+func (m *ModelManager) DisableSemanticCache(modelID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.semantic, modelID)
+}
+
+// SemanticCacheStats returns the stats of every model with a semantic
+// cache
+func (m *ModelManager) SemanticCacheStats() []SemanticCacheStats {
+	m.mu.RLock()
+	caches := make([]*semanticCache, 0, len(m.semantic))
+	for _, c := range m.semantic {
+		caches = append(caches, c)
+	}
+	m.mu.RUnlock()
+
+	stats := make([]SemanticCacheStats, 0, len(caches))
+	for _, c := range caches {
+		stats = append(stats, c.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ModelID < stats[j].ModelID })
+	return stats
+}
+
+// getSemanticCache returns the semantic cache for the resolved model ID or
+// the alias it was requested by
+func (m *ModelManager) getSemanticCache(modelID, requested string) *semanticCache {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if c, ok := m.semantic[modelID]; ok {
+		return c
+	}
+	return m.semantic[requested]
+}
+
+// semanticCache looks up and stores one model's responses
+type semanticCache struct {
+	modelID   string
+	config    SemanticCacheConfig
+	collector *metrics.Collector
+	hits      int64
+	misses    int64
+	errors    int64
+	mu        sync.Mutex
+}
+
+// semanticEntry is a prompt that missed, kept to store its response
+type semanticEntry struct {
+	cache  *semanticCache
+	id     string
+	vector []float32
+	prompt string
+	scope  string
+}
+
+// lookup returns a cached response for the input, or the entry to store
+// its response under once it is generated. Both are nil for inputs that
+// are not text prompts, or when the prompt could not be embedded.
+func (c *semanticCache) lookup(ctx context.Context, m *ModelManager, input *InferenceInput) (*InferenceOutput, *semanticEntry) {
+	prompt, ok := input.Data.(string)
+	if !ok || prompt == "" || input.Parameters["type"] == "embedding" {
+		return nil, nil
+	}
+
+	embedding, err := m.Predict(ctx, &InferenceInput{
+		ModelID:    c.config.EmbeddingModel,
+		Data:       prompt,
+		Parameters: map[string]interface{}{"type": "embedding"},
+	})
+	if err != nil {
+		c.fail()
+		return nil, nil
+	}
+	vectors, err := EmbeddingVectors(embedding)
+	if err != nil || len(vectors) != 1 {
+		c.fail()
+		return nil, nil
+	}
+
+	scope := semanticScope(input)
+	entry := &semanticEntry{
+		cache:  c,
+		id:     semanticID(scope, prompt),
+		vector: vectors[0],
+		prompt: prompt,
+		scope:  scope,
+	}
+
+	matches, err := c.config.Store.Query(ctx, VectorQuery{
+		Vector: vectors[0],
+		TopK:   1,
+		Filter: VectorFilter{"semantic_scope": scope},
+	})
+	if err != nil {
+		c.fail()
+		return nil, entry
+	}
+	if len(matches) == 0 || matches[0].Score < c.config.Threshold {
+		c.record(false)
+		return nil, entry
+	}
+
+	match := matches[0]
+	expiresAt, _ := toFloat(match.Metadata["expires_at"])
+	response, _ := match.Metadata["response"].(string)
+	var result interface{}
+	if time.Now().Unix() > int64(expiresAt) || json.Unmarshal([]byte(response), &result) != nil {
+		c.config.Store.Delete(ctx, []string{match.ID})
+		c.record(false)
+		return nil, entry
+	}
+
+	c.record(true)
+	return &InferenceOutput{
+		ModelID: input.ModelID,
+		Result:  result,
+		Metadata: map[string]interface{}{
+			"semantic_cache": true,
+			"similarity":     match.Score,
+			"cached_prompt":  match.Metadata["prompt"],
+		},
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// store keeps a generated response for similar prompts
+func (e *semanticEntry) store(ctx context.Context, output *InferenceOutput) {
+	response, err := json.Marshal(output.Result)
+	if err != nil {
+		e.cache.fail()
+		return
+	}
+	err = e.cache.config.Store.Upsert(ctx, []Vector{{
+		ID:     e.id,
+		Values: e.vector,
+		Metadata: map[string]interface{}{
+			"semantic_scope": e.scope,
+			"model_id":       e.cache.modelID,
+			"prompt":         e.prompt,
+			"response":       string(response),
+			"expires_at":     time.Now().Add(e.cache.config.TTL).Unix(),
+		},
+	}})
+	if err != nil {
+		e.cache.fail()
+	}
+}
+
+// semanticScope identifies what besides the prompt must match: the model,
+// and the parameters including the system prompt
+func semanticScope(input *InferenceInput) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"model_id":   input.ModelID,
+		"parameters": input.Parameters,
+	})
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:32]
+}
+
+func semanticID(scope, prompt string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(scope+"\x00"+prompt)))[:32]
+}
+
+func (c *semanticCache) record(hit bool) {
+	c.mu.Lock()
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	hits, total := c.hits, c.hits+c.misses
+	collector := c.collector
+	c.mu.Unlock()
+
+	if collector == nil {
+		return
+	}
+	labels := map[string]string{"model": c.modelID}
+	if hit {
+		collector.NewCounter("ai_semantic_cache_hits_total_"+c.modelID, "Semantic cache hits for "+c.modelID, labels).Inc()
+	} else {
+		collector.NewCounter("ai_semantic_cache_misses_total_"+c.modelID, "Semantic cache misses for "+c.modelID, labels).Inc()
+	}
+	collector.NewGauge("ai_semantic_cache_hit_rate_"+c.modelID,
+		"Semantic cache hit rate for "+c.modelID+", in percent", labels).Set(hits * 100 / total)
+}
+
+func (c *semanticCache) fail() {
+	c.mu.Lock()
+	c.errors++
+	collector := c.collector
+	c.mu.Unlock()
+
+	if collector != nil {
+		collector.NewCounter("ai_semantic_cache_errors_total", "Semantic cache lookups and stores that failed", nil).Inc()
+	}
+}
+
+func (c *semanticCache) stats() SemanticCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := SemanticCacheStats{
+		ModelID:   c.modelID,
+		Threshold: c.config.Threshold,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Errors:    c.errors,
+	}
+	if total := c.hits + c.misses; total > 0 {
+		stats.HitRate = float64(c.hits) / float64(total)
+	}
+	return stats
+}
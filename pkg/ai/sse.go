@@ -0,0 +1,73 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StreamSSE streams an inference to the client as Server-Sent Events: one
+// data event per StreamChunk, then "data: [DONE]", or an "error" event if
+// inference fails midway. The provider request is cancelled when the
+// client disconnects. Model IDs may be aliases such as "name@production".
+func StreamSSE(c *fiber.Ctx, manager *ModelManager, input *InferenceInput) error {
+	if manager.GetModel(manager.ResolveModelID(input.ModelID)) == nil {
+		return api.NotFound(c, fmt.Sprintf("model not found: %s", input.ModelID))
+	}
+	// Budgets are checked up front, so rejections get a status code
+	if err := manager.CheckBudget(c.UserContext(), input); err != nil {
+		return api.Error(c, fiber.StatusPaymentRequired, err.Error(), nil)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // Keep proxies from buffering the stream
+
+	// The body is written after the handler returns; the derived context
+	// keeps request values such as test mode, and is cancelled once the
+	// stream ends
+	ctx, cancel := context.WithCancel(c.UserContext())
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+
+		err := manager.PredictStream(ctx, input, func(chunk StreamChunk) error {
+			data, err := json.Marshal(chunk)
+			if err != nil {
+				return err
+			}
+			// A failed flush means the client has gone
+			return writeSSE(w, "", data)
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Warn("Inference stream failed", logger.Fields{"model": input.ModelID, "error": err.Error()})
+			}
+			data, _ := json.Marshal(map[string]string{"message": err.Error()})
+			writeSSE(w, "error", data)
+			return
+		}
+		writeSSE(w, "", []byte("[DONE]"))
+	})
+	return nil
+}
+
+// writeSSE writes and flushes one event
+func writeSSE(w *bufio.Writer, event string, data []byte) error {
+	if event != "" {
+		if _, err := fmt.Fprintf(w, "event: %s\n", event); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,262 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/sandbox"
+)
+
+// StreamChunk is a piece of a streamed inference result
+type StreamChunk struct {
+	ModelID      string                 `json:"model_id"`
+	Index        int                    `json:"index"`                   // Position in the stream, from 0
+	Delta        string                 `json:"delta"`                   // Text added by this chunk
+	FinishReason string                 `json:"finish_reason,omitempty"` // Set on the final chunk
+	Usage        map[string]interface{} `json:"usage,omitempty"`         // Token usage, on the final chunk when reported
+	Result       interface{}            `json:"result,omitempty"`        // Whole result, when streamed from a non-streaming provider
+	Done         bool                   `json:"done"`
+}
+
+// StreamFunc receives stream chunks in order. Returning an error stops the
+// stream and cancels the provider request.
+type StreamFunc func(chunk StreamChunk) error
+
+// StreamingProvider is implemented by providers that can stream results.
+// Providers send text chunks, then a final chunk with Done set.
+type StreamingProvider interface {
+	PredictStream(ctx context.Context, modelID string, input *InferenceInput, fn StreamFunc) error
+}
+
+// PredictStream performs inference, passing the result to fn as it is
+// generated. Providers without streaming support deliver the whole result
+// as a single final chunk. Streamed results are not cached.
+func (m *ModelManager) PredictStream(ctx context.Context, input *InferenceInput, fn StreamFunc) error {
+	input, err := m.resolveInput(input)
+	if err != nil {
+		return err
+	}
+
+	model := m.getModel(input.ModelID)
+	if model == nil {
+		return fmt.Errorf("model not found: %s", input.ModelID)
+	}
+
+	if model.Status != ModelStatusReady {
+		return fmt.Errorf("model not ready: %s (status: %s)", input.ModelID, model.Status)
+	}
+
+	provider := m.getProvider(model.Provider)
+	if sandbox.IsTest(ctx) {
+		provider = m.getSandboxProvider()
+	}
+	if provider == nil {
+		return fmt.Errorf("provider not found: %s", model.Provider)
+	}
+
+	if err := m.CheckBudget(ctx, input); err != nil {
+		return err
+	}
+	tracker := m.UsageTracker()
+	if sandbox.IsTest(ctx) {
+		tracker = nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Number chunks and stop the provider as soon as fn fails
+	var fnErr error
+	var text strings.Builder
+	var usage interface{}
+	index, done := 0, false
+	emit := func(chunk StreamChunk) error {
+		if done {
+			return nil
+		}
+		chunk.ModelID = input.ModelID
+		chunk.Index = index
+		index++
+		done = chunk.Done
+		if tracker != nil {
+			text.WriteString(chunk.Delta)
+			if chunk.Usage != nil {
+				usage = chunk.Usage
+			} else if chunk.Result != nil {
+				usage = chunk.Result
+			}
+		}
+		if fnErr = fn(chunk); fnErr != nil {
+			cancel()
+		}
+		return fnErr
+	}
+
+	if streaming, ok := provider.(StreamingProvider); ok {
+		err = streaming.PredictStream(ctx, input.ModelID, input, emit)
+	} else {
+		err = predictAsStream(ctx, provider, input.ModelID, input, emit)
+	}
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return fmt.Errorf("inference failed: %w", err)
+	}
+	if !done {
+		if err := emit(StreamChunk{Done: true}); err != nil {
+			return err
+		}
+	}
+
+	model.mu.Lock()
+	model.LastUsedAt = time.Now()
+	model.RequestCount++
+	model.mu.Unlock()
+
+	if tracker != nil {
+		promptTokens, completionTokens, ok := ExtractUsage(usage)
+		if !ok {
+			promptTokens, completionTokens = EstimateTokens(promptOf(input)), EstimateTokens(text.String())
+		}
+		tracker.RecordTokens(requestCaller(ctx, input), input.ModelID, promptTokens, completionTokens, !ok)
+	}
+	return nil
+}
+
+// predictAsStream runs a regular prediction and delivers the whole result
+// as a single final chunk, for providers or request types that cannot
+// stream
+func predictAsStream(ctx context.Context, provider ModelProvider, modelID string, input *InferenceInput, fn StreamFunc) error {
+	output, err := provider.Predict(ctx, modelID, input)
+	if err != nil {
+		return err
+	}
+	text, finishReason := resultText(output.Result)
+	return fn(StreamChunk{Delta: text, FinishReason: finishReason, Result: output.Result, Done: true})
+}
+
+// resultText returns the text and finish reason of a chat or completion
+// result in OpenAI's shape
+func resultText(result interface{}) (string, string) {
+	data, ok := result.(map[string]interface{})
+	if !ok {
+		return "", ""
+	}
+	choices, _ := data["choices"].([]interface{})
+	if len(choices) == 0 {
+		return "", ""
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	finishReason, _ := choice["finish_reason"].(string)
+	if message, ok := choice["message"].(map[string]interface{}); ok {
+		content, _ := message["content"].(string)
+		return content, finishReason
+	}
+	text, _ := choice["text"].(string)
+	return text, finishReason
+}
+
+// openAIStream maps the chunks of an OpenAI-style stream, as sent by OpenAI
+// and Azure OpenAI, to fn. The finish reason is held back for the final
+// chunk, which carries usage when the API reports it.
+func openAIStream(fn StreamFunc) func(event string, data []byte) error {
+	var finishReason string
+	return func(event string, data []byte) error {
+		if string(data) == "[DONE]" {
+			return fn(StreamChunk{FinishReason: finishReason, Done: true})
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+				Text         string `json:"text"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *struct {
+				PromptTokens     int `json:"prompt_tokens"`
+				CompletionTokens int `json:"completion_tokens"`
+			} `json:"usage"`
+		}
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("invalid stream chunk: %w", err)
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+			if text := choice.Delta.Content + choice.Text; text != "" {
+				if err := fn(StreamChunk{Delta: text}); err != nil {
+					return err
+				}
+			}
+		}
+
+		// With include_usage, usage arrives in a last chunk without choices
+		if chunk.Usage != nil && len(chunk.Choices) == 0 {
+			return fn(StreamChunk{
+				FinishReason: finishReason,
+				Usage:        usageResult(chunk.Usage.PromptTokens, chunk.Usage.CompletionTokens),
+				Done:         true,
+			})
+		}
+		return nil
+	}
+}
+
+// readSSE reads a Server-Sent Events stream, passing each event's type and
+// data to fn
+func readSSE(r io.Reader, fn func(event string, data []byte) error) error {
+	scanner := newStreamScanner(r)
+
+	var event string
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if data.Len() > 0 {
+				if err := fn(event, bytes.TrimSuffix(data.Bytes(), []byte("\n"))); err != nil {
+					return err
+				}
+			}
+			event = ""
+			data.Reset()
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// A stream may end without a trailing blank line
+	if data.Len() > 0 {
+		return fn(event, bytes.TrimSuffix(data.Bytes(), []byte("\n")))
+	}
+	return nil
+}
+
+// newStreamScanner scans stream lines, allowing long JSON payloads
+func newStreamScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	return scanner
+}
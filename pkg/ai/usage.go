@@ -0,0 +1,820 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/metrics"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrBudgetExceeded is matched by budget rejections with errors.Is
+var ErrBudgetExceeded = errors.New("AI budget exceeded")
+
+// AnonymousCaller records usage of requests without a caller
+const AnonymousCaller = "anonymous"
+
+// TokenUsage is the AI usage of a caller on a model during one day
+type TokenUsage struct {
+	ID               uint      `gorm:"primarykey" json:"-"`
+	Caller           string    `gorm:"size:100;uniqueIndex:idx_ai_token_usage_bucket;not null" json:"caller"`
+	ModelID          string    `gorm:"size:100;uniqueIndex:idx_ai_token_usage_bucket;not null" json:"model_id"`
+	Bucket           time.Time `gorm:"uniqueIndex:idx_ai_token_usage_bucket;not null" json:"bucket"`
+	Requests         int64     `gorm:"default:0" json:"requests"`
+	PromptTokens     int64     `gorm:"default:0" json:"prompt_tokens"`
+	CompletionTokens int64     `gorm:"default:0" json:"completion_tokens"`
+	Cost             float64   `gorm:"default:0" json:"cost"` // Estimated USD
+}
+
+// TableName specifies the table name for TokenUsage
+func (TokenUsage) TableName() string {
+	return "ai_token_usage"
+}
+
+func (u *TokenUsage) add(other TokenUsage) {
+	u.Requests += other.Requests
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.Cost += other.Cost
+}
+
+// ModelPrice is what a model costs, in USD per million tokens
+type ModelPrice struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// cost prices a number of tokens
+func (p ModelPrice) cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Prompt + float64(completionTokens)*p.Completion) / 1e6
+}
+
+// Budget caps estimated spend. Caller is a caller ID, "*" for each caller
+// separately, or "" for all callers combined; ModelID is a model or "" for
+// all models. Zero limits are not enforced.
+type Budget struct {
+	Caller     string  `json:"caller"`
+	ModelID    string  `json:"model_id,omitempty"`
+	Monthly    float64 `json:"monthly,omitempty"`     // USD per calendar month (UTC)
+	PerRequest float64 `json:"per_request,omitempty"` // USD per request, estimated before it is sent
+}
+
+// applies reports whether the budget covers a caller's request to a model
+func (b Budget) applies(caller, modelID string) bool {
+	if b.ModelID != "" && b.ModelID != modelID {
+		return false
+	}
+	return b.Caller == "" || b.Caller == "*" || b.Caller == caller
+}
+
+// BudgetError is returned when a request would exceed a budget
+type BudgetError struct {
+	Budget   Budget
+	Caller   string
+	Spent    float64 // Spent this month, for monthly budgets
+	Estimate float64 // Estimated cost of the rejected request
+}
+
+func (e *BudgetError) Error() string {
+	if e.Budget.PerRequest > 0 && e.Estimate > e.Budget.PerRequest {
+		return fmt.Sprintf("%s: request estimated at $%.4f exceeds the $%.4f per-request limit",
+			ErrBudgetExceeded, e.Estimate, e.Budget.PerRequest)
+	}
+	if e.Spent >= e.Budget.Monthly {
+		return fmt.Sprintf("%s: $%.4f of the $%.2f monthly budget spent", ErrBudgetExceeded, e.Spent, e.Budget.Monthly)
+	}
+	return fmt.Sprintf("%s: request estimated at $%.4f would exceed the $%.2f monthly budget ($%.4f spent)",
+		ErrBudgetExceeded, e.Estimate, e.Budget.Monthly, e.Spent)
+}
+
+// Is makes errors.Is(err, ErrBudgetExceeded) match
+func (e *BudgetError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// UsageRecord is the usage of a single request
+type UsageRecord struct {
+	Caller           string
+	ModelID          string
+	PromptTokens     int
+	CompletionTokens int
+	Estimated        bool // Token counts were estimated, as the provider reported none
+	Cost             float64
+	At               time.Time
+}
+
+// UsageFilter narrows a usage query. Zero fields match everything.
+type UsageFilter struct {
+	Caller  string
+	ModelID string
+	Since   time.Time
+	Until   time.Time
+}
+
+// UsageSummary totals usage per model
+type UsageSummary struct {
+	ModelID          string  `json:"model_id"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// UsageConfig holds usage tracker configuration
+type UsageConfig struct {
+	FlushInterval time.Duration         // How often buffered usage is written
+	Prices        map[string]ModelPrice // By model ID; "*" prices unlisted models
+	Budgets       []Budget
+}
+
+// DefaultUsageConfig returns default usage tracker configuration
+func DefaultUsageConfig() UsageConfig {
+	return UsageConfig{
+		FlushInterval: 10 * time.Second,
+		Prices:        make(map[string]ModelPrice),
+	}
+}
+
+// LoadUsageConfig loads prices and budgets from the environment.
+// AI_MODEL_PRICES lists model=prompt:completion pairs in USD per million
+// tokens, comma separated. AI_BUDGET_MONTHLY caps each caller's monthly
+// spend, AI_BUDGET_MONTHLY_TOTAL all callers' and AI_BUDGET_PER_REQUEST
+// single requests.
+func LoadUsageConfig() UsageConfig {
+	config := DefaultUsageConfig()
+
+	for _, pair := range strings.Split(os.Getenv("AI_MODEL_PRICES"), ",") {
+		modelID, prices, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || modelID == "" {
+			continue
+		}
+		prompt, completion, _ := strings.Cut(prices, ":")
+		promptPrice, err1 := strconv.ParseFloat(strings.TrimSpace(prompt), 64)
+		completionPrice, err2 := strconv.ParseFloat(strings.TrimSpace(completion), 64)
+		if err1 != nil || err2 != nil {
+			logger.Warn("Ignoring invalid AI model price", logger.Fields{"model": modelID})
+			continue
+		}
+		config.Prices[strings.TrimSpace(modelID)] = ModelPrice{Prompt: promptPrice, Completion: completionPrice}
+	}
+
+	envFloat := func(name string) float64 {
+		value, _ := strconv.ParseFloat(os.Getenv(name), 64)
+		return math.Max(value, 0)
+	}
+	if limit := envFloat("AI_BUDGET_MONTHLY"); limit > 0 {
+		config.Budgets = append(config.Budgets, Budget{Caller: "*", Monthly: limit})
+	}
+	if limit := envFloat("AI_BUDGET_MONTHLY_TOTAL"); limit > 0 {
+		config.Budgets = append(config.Budgets, Budget{Monthly: limit})
+	}
+	if limit := envFloat("AI_BUDGET_PER_REQUEST"); limit > 0 {
+		config.Budgets = append(config.Budgets, Budget{Caller: "*", PerRequest: limit})
+	}
+	return config
+}
+
+type usageKey struct {
+	caller  string
+	modelID string
+	bucket  time.Time
+}
+
+// spendKey identifies a monthly spend total; empty fields mean all
+type spendKey struct {
+	caller  string
+	modelID string
+}
+
+// UsageTracker counts tokens and estimated cost per model and caller,
+// persists daily aggregates, reports metrics and enforces budgets. Attach
+// it to a ModelManager with SetUsageTracker.
+type UsageTracker struct {
+	db        *gorm.DB
+	config    UsageConfig
+	collector *metrics.Collector
+
+	mu      sync.Mutex
+	prices  map[string]ModelPrice
+	budgets []Budget
+	pending map[usageKey]*TokenUsage
+	month   time.Time            // Month the spend totals are for
+	spend   map[spendKey]float64 // Monthly spend, loaded on first use
+	flushMu sync.Mutex           // Keeps flushes and spend loads apart
+	started bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewUsageTracker creates a usage tracker. The database may be nil to
+// track in memory only.
+func NewUsageTracker(db *gorm.DB, config UsageConfig) *UsageTracker {
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultUsageConfig().FlushInterval
+	}
+	prices := make(map[string]ModelPrice, len(config.Prices))
+	for modelID, price := range config.Prices {
+		prices[modelID] = price
+	}
+	return &UsageTracker{
+		db:      db,
+		config:  config,
+		prices:  prices,
+		budgets: append([]Budget(nil), config.Budgets...),
+		pending: make(map[usageKey]*TokenUsage),
+		spend:   make(map[spendKey]float64),
+	}
+}
+
+// SetMetrics reports usage to a metrics collector
+func (t *UsageTracker) SetMetrics(collector *metrics.Collector) {
+	t.collector = collector
+}
+
+// SetPrice sets a model's price; "*" prices unlisted models
+func (t *UsageTracker) SetPrice(modelID string, price ModelPrice) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prices[modelID] = price
+}
+
+// Price returns a model's price and whether one is known
+func (t *UsageTracker) Price(modelID string) (ModelPrice, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.price(modelID)
+}
+
+func (t *UsageTracker) price(modelID string) (ModelPrice, bool) {
+	if price, ok := t.prices[modelID]; ok {
+		return price, true
+	}
+	price, ok := t.prices["*"]
+	return price, ok
+}
+
+// SetBudget adds a budget, replacing any for the same caller and model
+func (t *UsageTracker) SetBudget(budget Budget) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, existing := range t.budgets {
+		if existing.Caller == budget.Caller && existing.ModelID == budget.ModelID {
+			t.budgets[i] = budget
+			return
+		}
+	}
+	t.budgets = append(t.budgets, budget)
+}
+
+// RemoveBudget drops the budget for a caller and model
+func (t *UsageTracker) RemoveBudget(caller, modelID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, existing := range t.budgets {
+		if existing.Caller == caller && existing.ModelID == modelID {
+			t.budgets = append(t.budgets[:i], t.budgets[i+1:]...)
+			return
+		}
+	}
+}
+
+// Budgets returns the configured budgets
+func (t *UsageTracker) Budgets() []Budget {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Budget(nil), t.budgets...)
+}
+
+// ==================== Enforcement ====================
+
+// Check rejects a request that would exceed a budget with a *BudgetError.
+// Its cost is estimated from the prompt and the max_tokens parameter.
+func (t *UsageTracker) Check(ctx context.Context, input *InferenceInput) error {
+	caller := requestCaller(ctx, input)
+
+	t.mu.Lock()
+	var budgets []Budget
+	for _, budget := range t.budgets {
+		if budget.applies(caller, input.ModelID) {
+			budgets = append(budgets, budget)
+		}
+	}
+	price, _ := t.price(input.ModelID)
+	t.mu.Unlock()
+	if len(budgets) == 0 {
+		return nil
+	}
+
+	estimate := price.cost(EstimateTokens(promptOf(input)), intParam(input.Parameters, "max_tokens", 0))
+	for _, budget := range budgets {
+		if budget.PerRequest > 0 && estimate > budget.PerRequest {
+			return t.reject(&BudgetError{Budget: budget, Caller: caller, Estimate: estimate})
+		}
+	}
+	for _, budget := range budgets {
+		if budget.Monthly <= 0 {
+			continue
+		}
+
+		key := spendKey{modelID: budget.ModelID}
+		if budget.Caller != "" {
+			key.caller = caller
+		}
+		spent, err := t.monthlySpend(ctx, key)
+		if err != nil {
+			// Fail open; a database hiccup should not stop inference
+			logger.Warn("Failed to load AI spend", logger.Fields{"error": err.Error()})
+			continue
+		}
+		if spent >= budget.Monthly || spent+estimate > budget.Monthly {
+			return t.reject(&BudgetError{Budget: budget, Caller: caller, Spent: spent, Estimate: estimate})
+		}
+	}
+	return nil
+}
+
+func (t *UsageTracker) reject(err *BudgetError) error {
+	if t.collector != nil {
+		t.collector.NewCounter("ai_budget_rejections_total", "AI requests rejected by budgets", nil).Inc()
+	}
+	logger.Warn("AI request rejected by budget", logger.Fields{"caller": err.Caller, "error": err.Error()})
+	return err
+}
+
+// MonthlySpend returns a caller's estimated spend this month; an empty
+// caller or model totals all of them
+func (t *UsageTracker) MonthlySpend(ctx context.Context, caller, modelID string) (float64, error) {
+	return t.monthlySpend(ctx, spendKey{caller: caller, modelID: modelID})
+}
+
+// monthlySpend returns a cached spend total, loading it on first use
+func (t *UsageTracker) monthlySpend(ctx context.Context, key spendKey) (float64, error) {
+	month := monthStart(time.Now())
+
+	t.mu.Lock()
+	t.rollMonth(month)
+	if spent, ok := t.spend[key]; ok {
+		t.mu.Unlock()
+		return spent, nil
+	}
+	t.mu.Unlock()
+
+	t.flushMu.Lock()
+	defer t.flushMu.Unlock()
+
+	var stored float64
+	if t.db != nil {
+		query := t.db.WithContext(ctx).Model(&TokenUsage{}).Where("bucket >= ?", month)
+		if key.caller != "" {
+			query = query.Where("caller = ?", key.caller)
+		}
+		if key.modelID != "" {
+			query = query.Where("model_id = ?", key.modelID)
+		}
+		if err := query.Select("COALESCE(SUM(cost), 0)").Scan(&stored).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	// Add pending usage and cache under one lock, so no record is missed
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if spent, ok := t.spend[key]; ok {
+		return spent, nil
+	}
+	for k, usage := range t.pending {
+		if !k.bucket.Before(month) && matchesSpend(key, k.caller, k.modelID) {
+			stored += usage.Cost
+		}
+	}
+	t.spend[key] = stored
+	return stored, nil
+}
+
+// rollMonth drops spend totals from an earlier month
+func (t *UsageTracker) rollMonth(month time.Time) {
+	if !t.month.Equal(month) {
+		t.month = month
+		t.spend = make(map[spendKey]float64)
+	}
+}
+
+func matchesSpend(key spendKey, caller, modelID string) bool {
+	return (key.caller == "" || key.caller == caller) && (key.modelID == "" || key.modelID == modelID)
+}
+
+// ==================== Recording ====================
+
+// Record records a completed request. Token counts come from the result's
+// usage when the provider reports it, and are estimated otherwise.
+func (t *UsageTracker) Record(ctx context.Context, input *InferenceInput, output *InferenceOutput) *UsageRecord {
+	promptTokens, completionTokens, ok := ExtractUsage(output.Result)
+	if !ok {
+		text, _ := resultText(output.Result)
+		promptTokens, completionTokens = EstimateTokens(promptOf(input)), EstimateTokens(text)
+	}
+	return t.RecordTokens(requestCaller(ctx, input), input.ModelID, promptTokens, completionTokens, !ok)
+}
+
+// RecordTokens records a request's token counts directly
+func (t *UsageTracker) RecordTokens(caller, modelID string, promptTokens, completionTokens int, estimated bool) *UsageRecord {
+	if caller == "" {
+		caller = AnonymousCaller
+	}
+	now := time.Now().UTC()
+
+	t.mu.Lock()
+	price, _ := t.price(modelID)
+	record := &UsageRecord{
+		Caller:           caller,
+		ModelID:          modelID,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		Estimated:        estimated,
+		Cost:             price.cost(promptTokens, completionTokens),
+		At:               now,
+	}
+
+	key := usageKey{caller: caller, modelID: modelID, bucket: now.Truncate(24 * time.Hour)}
+	usage, ok := t.pending[key]
+	if !ok {
+		usage = &TokenUsage{Caller: caller, ModelID: modelID, Bucket: key.bucket}
+		t.pending[key] = usage
+	}
+	usage.add(TokenUsage{
+		Requests:         1,
+		PromptTokens:     int64(promptTokens),
+		CompletionTokens: int64(completionTokens),
+		Cost:             record.Cost,
+	})
+
+	t.rollMonth(monthStart(now))
+	for key := range t.spend {
+		if matchesSpend(key, caller, modelID) {
+			t.spend[key] += record.Cost
+		}
+	}
+	t.mu.Unlock()
+
+	t.recordMetrics(record)
+	return record
+}
+
+func (t *UsageTracker) recordMetrics(record *UsageRecord) {
+	if t.collector == nil {
+		return
+	}
+	labels := map[string]string{"model": record.ModelID}
+	micros := uint64(math.Round(record.Cost * 1e6))
+
+	t.collector.NewCounter("ai_requests_total", "Total AI inference requests", nil).Inc()
+	t.collector.NewCounter("ai_prompt_tokens_total", "Total AI prompt tokens", nil).Add(uint64(record.PromptTokens))
+	t.collector.NewCounter("ai_completion_tokens_total", "Total AI completion tokens", nil).Add(uint64(record.CompletionTokens))
+	t.collector.NewCounter("ai_cost_microusd_total", "Estimated AI cost in millionths of a USD", nil).Add(micros)
+
+	t.collector.NewCounter("ai_tokens_model_"+record.ModelID,
+		"AI tokens used with "+record.ModelID, labels).Add(uint64(record.PromptTokens + record.CompletionTokens))
+	t.collector.NewCounter("ai_cost_microusd_model_"+record.ModelID,
+		"Estimated cost of "+record.ModelID+" in millionths of a USD", labels).Add(micros)
+}
+
+// ==================== Persistence ====================
+
+// Flush writes buffered usage to the database, adding to existing days.
+// Usage that fails to write is kept for the next flush.
+func (t *UsageTracker) Flush(ctx context.Context) error {
+	if t.db == nil {
+		return nil
+	}
+	t.flushMu.Lock()
+	defer t.flushMu.Unlock()
+
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[usageKey]*TokenUsage)
+	t.mu.Unlock()
+
+	var firstErr error
+	for key, usage := range pending {
+		err := t.db.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "caller"}, {Name: "model_id"}, {Name: "bucket"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"requests":          gorm.Expr("ai_token_usage.requests + ?", usage.Requests),
+				"prompt_tokens":     gorm.Expr("ai_token_usage.prompt_tokens + ?", usage.PromptTokens),
+				"completion_tokens": gorm.Expr("ai_token_usage.completion_tokens + ?", usage.CompletionTokens),
+				"cost":              gorm.Expr("ai_token_usage.cost + ?", usage.Cost),
+			}),
+		}).Create(&TokenUsage{
+			Caller:           usage.Caller,
+			ModelID:          usage.ModelID,
+			Bucket:           usage.Bucket,
+			Requests:         usage.Requests,
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			Cost:             usage.Cost,
+		}).Error
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			t.requeue(key, usage)
+		}
+	}
+	return firstErr
+}
+
+func (t *UsageTracker) requeue(key usageKey, usage *TokenUsage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if existing, ok := t.pending[key]; ok {
+		existing.add(*usage)
+		return
+	}
+	t.pending[key] = usage
+}
+
+// Start begins flushing in the background. It is safe to call more than once.
+func (t *UsageTracker) Start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.started || t.db == nil {
+		return
+	}
+	t.started = true
+	t.stop = make(chan struct{})
+	t.done = make(chan struct{})
+	go t.run(t.stop, t.done)
+}
+
+// Stop ends background flushing and writes any buffered usage
+func (t *UsageTracker) Stop() {
+	t.mu.Lock()
+	if !t.started {
+		t.mu.Unlock()
+		return
+	}
+	t.started = false
+	close(t.stop)
+	done := t.done
+	t.mu.Unlock()
+
+	<-done
+}
+
+func (t *UsageTracker) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(t.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.flushAndLog()
+		case <-stop:
+			t.flushAndLog()
+			return
+		}
+	}
+}
+
+func (t *UsageTracker) flushAndLog() {
+	if err := t.Flush(context.Background()); err != nil {
+		logger.Warn("Failed to flush AI usage", logger.Fields{"error": err.Error()})
+	}
+}
+
+// Usage returns daily usage matching a filter, including usage not
+// flushed yet, oldest first
+func (t *UsageTracker) Usage(ctx context.Context, filter UsageFilter) ([]TokenUsage, error) {
+	var stored []TokenUsage
+	if t.db != nil {
+		query := t.db.WithContext(ctx).Model(&TokenUsage{})
+		if filter.Caller != "" {
+			query = query.Where("caller = ?", filter.Caller)
+		}
+		if filter.ModelID != "" {
+			query = query.Where("model_id = ?", filter.ModelID)
+		}
+		if !filter.Since.IsZero() {
+			query = query.Where("bucket >= ?", filter.Since.UTC().Truncate(24*time.Hour))
+		}
+		if !filter.Until.IsZero() {
+			query = query.Where("bucket < ?", filter.Until.UTC())
+		}
+		if err := query.Find(&stored).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	merged := make(map[usageKey]*TokenUsage, len(stored))
+	for i := range stored {
+		usage := &stored[i]
+		usage.Bucket = usage.Bucket.UTC()
+		merged[usageKey{caller: usage.Caller, modelID: usage.ModelID, bucket: usage.Bucket}] = usage
+	}
+
+	t.mu.Lock()
+	for key, usage := range t.pending {
+		if (filter.Caller != "" && key.caller != filter.Caller) ||
+			(filter.ModelID != "" && key.modelID != filter.ModelID) ||
+			(!filter.Since.IsZero() && key.bucket.Before(filter.Since.UTC().Truncate(24*time.Hour))) ||
+			(!filter.Until.IsZero() && !key.bucket.Before(filter.Until.UTC())) {
+			continue
+		}
+		if existing, ok := merged[key]; ok {
+			existing.add(*usage)
+			continue
+		}
+		copied := *usage
+		merged[key] = &copied
+	}
+	t.mu.Unlock()
+
+	result := make([]TokenUsage, 0, len(merged))
+	for _, usage := range merged {
+		result = append(result, *usage)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Bucket.Equal(result[j].Bucket) {
+			return result[i].Bucket.Before(result[j].Bucket)
+		}
+		if result[i].Caller != result[j].Caller {
+			return result[i].Caller < result[j].Caller
+		}
+		return result[i].ModelID < result[j].ModelID
+	})
+	return result, nil
+}
+
+// Summarize totals usage matching a filter per model
+func (t *UsageTracker) Summarize(ctx context.Context, filter UsageFilter) ([]UsageSummary, error) {
+	usage, err := t.Usage(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	byModel := make(map[string]*UsageSummary)
+	for _, u := range usage {
+		summary, ok := byModel[u.ModelID]
+		if !ok {
+			summary = &UsageSummary{ModelID: u.ModelID}
+			byModel[u.ModelID] = summary
+		}
+		summary.Requests += u.Requests
+		summary.PromptTokens += u.PromptTokens
+		summary.CompletionTokens += u.CompletionTokens
+		summary.Cost += u.Cost
+	}
+
+	result := make([]UsageSummary, 0, len(byModel))
+	for _, summary := range byModel {
+		result = append(result, *summary)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ModelID < result[j].ModelID })
+	return result, nil
+}
+
+// ==================== Callers ====================
+
+type callerKey struct{}
+
+// callerLocal is the Fiber locals key holding the request's caller
+const callerLocal = "ai_caller"
+
+// WithCaller returns a copy of ctx whose AI usage is charged to caller,
+// e.g. "user:42" or "api_key:7"
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller AI usage in ctx is charged to
+func CallerFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if caller, ok := ctx.Value(callerKey{}).(string); ok {
+		return caller
+	}
+	// Fiber locals are visible through c.Context()
+	caller, _ := ctx.Value(callerLocal).(string)
+	return caller
+}
+
+// SetCaller charges a request's AI usage to caller
+func SetCaller(c *fiber.Ctx, caller string) {
+	c.Locals(callerLocal, caller)
+	c.SetUserContext(WithCaller(c.UserContext(), caller))
+}
+
+// UserCaller is the caller ID of a user
+func UserCaller(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// CallerMiddleware charges AI usage to the authenticated user, unless an
+// earlier handler, such as API key authentication, set a caller
+func CallerMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals(callerLocal).(string); !ok {
+			if userID, ok := c.Locals("user_id").(uint); ok && userID != 0 {
+				SetCaller(c, UserCaller(userID))
+			}
+		}
+		return c.Next()
+	}
+}
+
+// requestCaller returns the caller from the context, or the input's
+// "caller" metadata
+func requestCaller(ctx context.Context, input *InferenceInput) string {
+	if caller := CallerFromContext(ctx); caller != "" {
+		return caller
+	}
+	return input.Metadata["caller"]
+}
+
+// ==================== Tokens ====================
+
+// ExtractUsage reads token counts from a result carrying OpenAI-style
+// usage ({"usage": {"prompt_tokens", "completion_tokens"}}) or a usage map
+// itself. Anthropic-style input_tokens and output_tokens are accepted.
+func ExtractUsage(result interface{}) (int, int, bool) {
+	data, ok := result.(map[string]interface{})
+	if !ok {
+		return 0, 0, false
+	}
+	if usage, ok := data["usage"].(map[string]interface{}); ok {
+		data = usage
+	}
+
+	prompt, promptOK := countValue(data, "prompt_tokens", "input_tokens")
+	completion, completionOK := countValue(data, "completion_tokens", "output_tokens")
+	if !promptOK && !completionOK {
+		return 0, 0, false
+	}
+	return prompt, completion, true
+}
+
+func countValue(data map[string]interface{}, keys ...string) (int, bool) {
+	for _, key := range keys {
+		switch v := data[key].(type) {
+		case int:
+			return v, true
+		case int64:
+			return int(v), true
+		case float64:
+			return int(v), true
+		}
+	}
+	return 0, false
+}
+
+// EstimateTokens approximates the token count of text at four characters
+// per token, the usual ratio for English with BPE tokenizers
+func EstimateTokens(text string) int {
+	n := utf8.RuneCountInString(text)
+	if n == 0 {
+		return 0
+	}
+	return (n + 3) / 4
+}
+
+// promptOf returns the text sent to the model for an input
+func promptOf(input *InferenceInput) string {
+	var b strings.Builder
+	if system, ok := input.Parameters["system"].(string); ok {
+		b.WriteString(system)
+		b.WriteString("\n")
+	}
+	switch data := input.Data.(type) {
+	case string:
+		b.WriteString(data)
+	case []string:
+		b.WriteString(strings.Join(data, "\n"))
+	default:
+		b.WriteString(promptText(input))
+	}
+	return b.String()
+}
+
+// monthStart returns the first instant of t's month in UTC
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,104 @@
+package ai
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryVectorStore is an in-process vector index with exact
+// (brute-force) search, for tests, development and small collections
+type MemoryVectorStore struct {
+	config  VectorStoreConfig
+	vectors map[string]Vector
+	mu      sync.RWMutex
+}
+
+// NewMemoryVectorStore creates an empty in-memory vector store
+func NewMemoryVectorStore(config VectorStoreConfig) (*MemoryVectorStore, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &MemoryVectorStore{
+		config:  config,
+		vectors: make(map[string]Vector),
+	}, nil
+}
+
+// Upsert inserts or replaces vectors
+func (s *MemoryVectorStore) Upsert(ctx context.Context, vectors []Vector) error {
+	if err := checkVectors(vectors, s.config.Dimensions); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range vectors {
+		// Copy so later changes by the caller do not leak into the index
+		stored := Vector{
+			ID:       v.ID,
+			Values:   append([]float32(nil), v.Values...),
+			Metadata: make(map[string]interface{}, len(v.Metadata)),
+		}
+		for key, value := range v.Metadata {
+			stored.Metadata[key] = value
+		}
+		s.vectors[v.ID] = stored
+	}
+	return nil
+}
+
+// Query scores every vector that passes the filter and returns the best
+func (s *MemoryVectorStore) Query(ctx context.Context, query VectorQuery) ([]VectorMatch, error) {
+	if err := checkQuery(&query, s.config.Dimensions); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	matches := make([]VectorMatch, 0, len(s.vectors))
+	for _, v := range s.vectors {
+		if !matchesFilter(v.Metadata, query.Filter) {
+			continue
+		}
+		match := VectorMatch{
+			ID:       v.ID,
+			Score:    vectorScore(s.config.Metric, query.Vector, v.Values),
+			Metadata: v.Metadata,
+		}
+		if query.IncludeValues {
+			match.Values = v.Values
+		}
+		matches = append(matches, match)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if len(matches) > query.TopK {
+		matches = matches[:query.TopK]
+	}
+	return matches, nil
+}
+
+// Delete removes vectors by ID
+func (s *MemoryVectorStore) Delete(ctx context.Context, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.vectors, id)
+	}
+	return nil
+}
+
+// Count returns the number of stored vectors
+func (s *MemoryVectorStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.vectors)
+}
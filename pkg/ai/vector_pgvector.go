@@ -0,0 +1,225 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// PGVectorStore keeps a collection in a PostgreSQL table using the
+// pgvector extension, with an HNSW index for approximate search. Each
+// collection is its own table, ai_vectors_<collection>.
+type PGVectorStore struct {
+	db     *gorm.DB
+	config VectorStoreConfig
+	table  string
+}
+
+// pgvector distance operators and index operator classes by metric
+var pgvectorOps = map[DistanceMetric]struct{ operator, opclass string }{
+	MetricCosine:     {"<=>", "vector_cosine_ops"},
+	MetricDotProduct: {"<#>", "vector_ip_ops"},
+	MetricEuclidean:  {"<->", "vector_l2_ops"},
+}
+
+// NewPGVectorStore creates the extension, table and index if needed
+func NewPGVectorStore(db *gorm.DB, config VectorStoreConfig) (*PGVectorStore, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	store := &PGVectorStore{
+		db:     db,
+		config: config,
+		table:  "ai_vectors_" + strings.ToLower(config.Collection),
+	}
+	if err := store.migrate(); err != nil {
+		return nil, fmt.Errorf("pgvector: %w", err)
+	}
+	return store, nil
+}
+
+// migrate creates the collection's table and index
+func (s *PGVectorStore) migrate() error {
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS vector",
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id text PRIMARY KEY,
+			embedding vector(%d) NOT NULL,
+			metadata jsonb NOT NULL DEFAULT '{}',
+			updated_at timestamptz NOT NULL DEFAULT now()
+		)`, s.table, s.config.Dimensions),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_embedding_idx ON %s USING hnsw (embedding %s)",
+			s.table, s.table, pgvectorOps[s.config.Metric].opclass),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_metadata_idx ON %s USING gin (metadata jsonb_path_ops)", s.table, s.table),
+	}
+	for _, statement := range statements {
+		if err := s.db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Upsert inserts or replaces vectors in one statement
+func (s *PGVectorStore) Upsert(ctx context.Context, vectors []Vector) error {
+	if len(vectors) == 0 {
+		return nil
+	}
+	if err := checkVectors(vectors, s.config.Dimensions); err != nil {
+		return err
+	}
+
+	rows := make([]string, 0, len(vectors))
+	args := make([]interface{}, 0, len(vectors)*3)
+	for _, v := range vectors {
+		metadata := v.Metadata
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+		}
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, "(?, ?::vector, ?::jsonb, now())")
+		args = append(args, v.ID, vectorLiteral(v.Values), string(data))
+	}
+
+	sql := fmt.Sprintf(`INSERT INTO %s (id, embedding, metadata, updated_at) VALUES %s
+		ON CONFLICT (id) DO UPDATE SET embedding = EXCLUDED.embedding, metadata = EXCLUDED.metadata, updated_at = now()`,
+		s.table, strings.Join(rows, ", "))
+	return s.db.WithContext(ctx).Exec(sql, args...).Error
+}
+
+// Query orders by the metric's distance operator so the HNSW index is used
+func (s *PGVectorStore) Query(ctx context.Context, query VectorQuery) ([]VectorMatch, error) {
+	if err := checkQuery(&query, s.config.Dimensions); err != nil {
+		return nil, err
+	}
+
+	where, args, err := pgvectorFilter(query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := "id, metadata::text AS metadata"
+	if query.IncludeValues {
+		columns += ", embedding::text AS embedding"
+	}
+	operator := pgvectorOps[s.config.Metric].operator
+	literal := vectorLiteral(query.Vector)
+	sql := fmt.Sprintf(`SELECT %s, embedding %s ?::vector AS distance
+		FROM %s %s ORDER BY embedding %s ?::vector LIMIT ?`, columns, operator, s.table, where, operator)
+	args = append([]interface{}{literal}, args...)
+	args = append(args, literal, query.TopK)
+
+	var rows []struct {
+		ID        string
+		Metadata  string
+		Embedding string
+		Distance  float64
+	}
+	if err := s.db.WithContext(ctx).Raw(sql, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	matches := make([]VectorMatch, 0, len(rows))
+	for _, row := range rows {
+		match := VectorMatch{ID: row.ID, Score: s.score(row.Distance)}
+		if err := json.Unmarshal([]byte(row.Metadata), &match.Metadata); err != nil {
+			return nil, err
+		}
+		if query.IncludeValues {
+			if match.Values, err = parseVectorLiteral(row.Embedding); err != nil {
+				return nil, err
+			}
+		}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
+
+// Delete removes vectors by ID
+func (s *PGVectorStore) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Exec(fmt.Sprintf("DELETE FROM %s WHERE id IN ?", s.table), ids).Error
+}
+
+// score turns a pgvector distance into a higher-is-closer score: <=> is
+// one minus the cosine similarity and <#> the negated inner product
+func (s *PGVectorStore) score(distance float64) float32 {
+	switch s.config.Metric {
+	case MetricCosine:
+		return float32(1 - distance)
+	default:
+		return float32(-distance)
+	}
+}
+
+// pgvectorFilter builds a WHERE clause of jsonb containment tests, which
+// the GIN index on metadata serves
+func pgvectorFilter(filter VectorFilter) (string, []interface{}, error) {
+	if len(filter) == 0 {
+		return "", nil, nil
+	}
+
+	clauses := make([]string, 0, len(filter))
+	args := make([]interface{}, 0, len(filter))
+	for key, want := range filter {
+		values := filterValues(want)
+		alternatives := make([]string, 0, len(values))
+		for _, value := range values {
+			data, err := json.Marshal(map[string]interface{}{key: value})
+			if err != nil {
+				return "", nil, err
+			}
+			alternatives = append(alternatives, "metadata @> ?::jsonb")
+			args = append(args, string(data))
+		}
+		clauses = append(clauses, "("+strings.Join(alternatives, " OR ")+")")
+	}
+	return "WHERE " + strings.Join(clauses, " AND "), args, nil
+}
+
+// vectorLiteral formats values as a pgvector text literal, [1,2,3]
+func vectorLiteral(values []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, v := range values {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// parseVectorLiteral parses pgvector's text output
+func parseVectorLiteral(literal string) ([]float32, error) {
+	literal = strings.TrimSpace(literal)
+	if !strings.HasPrefix(literal, "[") || !strings.HasSuffix(literal, "]") {
+		return nil, fmt.Errorf("pgvector: invalid vector literal")
+	}
+	body := literal[1 : len(literal)-1]
+	if body == "" {
+		return []float32{}, nil
+	}
+
+	parts := strings.Split(body, ",")
+	values := make([]float32, len(parts))
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return nil, fmt.Errorf("pgvector: invalid vector literal: %w", err)
+		}
+		values[i] = float32(f)
+	}
+	return values, nil
+}
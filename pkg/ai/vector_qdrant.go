@@ -0,0 +1,246 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/httpclient"
+)
+
+// qdrantIDKey is the payload key holding a point's original ID, since
+// Qdrant point IDs must be integers or UUIDs
+const qdrantIDKey = "_id"
+
+// QdrantConfig holds Qdrant connection settings
+type QdrantConfig struct {
+	URL     string // Defaults to http://localhost:6333
+	APIKey  string // Optional, for Qdrant Cloud or secured instances
+	Timeout time.Duration
+}
+
+// LoadQdrantConfig loads Qdrant configuration from environment
+func LoadQdrantConfig() *QdrantConfig {
+	return &QdrantConfig{
+		URL:    os.Getenv("QDRANT_URL"),
+		APIKey: os.Getenv("QDRANT_API_KEY"),
+	}
+}
+
+// QdrantVectorStore keeps a collection in Qdrant over its REST API
+type QdrantVectorStore struct {
+	baseURL string
+	apiKey  string
+	config  VectorStoreConfig
+	client  *http.Client
+}
+
+// Qdrant distance names by metric
+var qdrantDistances = map[DistanceMetric]string{
+	MetricCosine:     "Cosine",
+	MetricDotProduct: "Dot",
+	MetricEuclidean:  "Euclid",
+}
+
+// NewQdrantVectorStore connects to Qdrant and creates the collection if it
+// does not exist
+func NewQdrantVectorStore(qdrant *QdrantConfig, config VectorStoreConfig) (*QdrantVectorStore, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	baseURL := strings.TrimSuffix(qdrant.URL, "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:6333"
+	}
+	timeout := qdrant.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	store := &QdrantVectorStore{
+		baseURL: baseURL,
+		apiKey:  qdrant.APIKey,
+		config:  config,
+		client:  httpclient.New(timeout),
+	}
+	if err := store.ensureCollection(context.Background()); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// ensureCollection creates the collection when it is missing
+func (s *QdrantVectorStore) ensureCollection(ctx context.Context) error {
+	status, err := s.do(ctx, "GET", s.collectionPath(""), nil, nil)
+	if err == nil {
+		return nil
+	}
+	if status != http.StatusNotFound {
+		return err
+	}
+
+	body := map[string]interface{}{
+		"vectors": map[string]interface{}{
+			"size":     s.config.Dimensions,
+			"distance": qdrantDistances[s.config.Metric],
+		},
+	}
+	_, err = s.do(ctx, "PUT", s.collectionPath(""), body, nil)
+	return err
+}
+
+// qdrantPoint is a point in upsert requests and search results
+type qdrantPoint struct {
+	ID      string                 `json:"id"`
+	Vector  []float32              `json:"vector,omitempty"`
+	Payload map[string]interface{} `json:"payload,omitempty"`
+	Score   float32                `json:"score,omitempty"`
+}
+
+// Upsert inserts or replaces points and waits until they are searchable
+func (s *QdrantVectorStore) Upsert(ctx context.Context, vectors []Vector) error {
+	if len(vectors) == 0 {
+		return nil
+	}
+	if err := checkVectors(vectors, s.config.Dimensions); err != nil {
+		return err
+	}
+
+	points := make([]qdrantPoint, len(vectors))
+	for i, v := range vectors {
+		payload := make(map[string]interface{}, len(v.Metadata)+1)
+		for key, value := range v.Metadata {
+			payload[key] = value
+		}
+		payload[qdrantIDKey] = v.ID
+		points[i] = qdrantPoint{ID: qdrantPointID(v.ID), Vector: v.Values, Payload: payload}
+	}
+
+	_, err := s.do(ctx, "PUT", s.collectionPath("/points?wait=true"), map[string]interface{}{"points": points}, nil)
+	return err
+}
+
+// Query searches the collection, translating the filter into Qdrant match
+// conditions
+func (s *QdrantVectorStore) Query(ctx context.Context, query VectorQuery) ([]VectorMatch, error) {
+	if err := checkQuery(&query, s.config.Dimensions); err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"vector":       query.Vector,
+		"limit":        query.TopK,
+		"with_payload": true,
+		"with_vector":  query.IncludeValues,
+	}
+	if len(query.Filter) > 0 {
+		must := make([]interface{}, 0, len(query.Filter))
+		for key, want := range query.Filter {
+			values := filterValues(want)
+			match := map[string]interface{}{"value": values[0]}
+			if len(values) != 1 {
+				match = map[string]interface{}{"any": values}
+			}
+			must = append(must, map[string]interface{}{"key": key, "match": match})
+		}
+		body["filter"] = map[string]interface{}{"must": must}
+	}
+
+	var response struct {
+		Result []qdrantPoint `json:"result"`
+	}
+	if _, err := s.do(ctx, "POST", s.collectionPath("/points/search"), body, &response); err != nil {
+		return nil, err
+	}
+
+	matches := make([]VectorMatch, len(response.Result))
+	for i, point := range response.Result {
+		id, _ := point.Payload[qdrantIDKey].(string)
+		delete(point.Payload, qdrantIDKey)
+
+		score := point.Score
+		if s.config.Metric == MetricEuclidean {
+			// Qdrant reports the distance itself for Euclid
+			score = -score
+		}
+		matches[i] = VectorMatch{ID: id, Score: score, Metadata: point.Payload}
+		if query.IncludeValues {
+			matches[i].Values = point.Vector
+		}
+	}
+	return matches, nil
+}
+
+// Delete removes points by ID
+func (s *QdrantVectorStore) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	points := make([]string, len(ids))
+	for i, id := range ids {
+		points[i] = qdrantPointID(id)
+	}
+	_, err := s.do(ctx, "POST", s.collectionPath("/points/delete?wait=true"), map[string]interface{}{"points": points}, nil)
+	return err
+}
+
+// collectionPath returns the URL of the collection or a path under it
+func (s *QdrantVectorStore) collectionPath(path string) string {
+	return s.baseURL + "/collections/" + s.config.Collection + path
+}
+
+// do sends a JSON request and decodes the response into out when given.
+// The status is returned alongside errors so callers can tell a 404.
+func (s *QdrantVectorStore) do(ctx context.Context, method, url string, body interface{}, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("api-key", s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, fmt.Errorf("qdrant API error: %d - %s", resp.StatusCode, string(data))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// qdrantPointID derives a stable name-based UUID from an ID
+func qdrantPointID(id string) string {
+	sum := sha1.Sum([]byte("neonexcore/vector/" + id))
+	sum[6] = (sum[6] & 0x0f) | 0x50 // version 5
+	sum[8] = (sum[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,285 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// VectorStore persists embeddings and finds the nearest ones to a query.
+// A store is bound to one collection with a fixed dimension and metric.
+type VectorStore interface {
+	// Upsert inserts vectors, replacing any with the same ID
+	Upsert(ctx context.Context, vectors []Vector) error
+
+	// Query returns the TopK vectors closest to the query vector, best first
+	Query(ctx context.Context, query VectorQuery) ([]VectorMatch, error)
+
+	// Delete removes vectors by ID; unknown IDs are ignored
+	Delete(ctx context.Context, ids []string) error
+}
+
+// Vector is an embedding with its ID and metadata
+type Vector struct {
+	ID       string                 `json:"id"`
+	Values   []float32              `json:"values"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// VectorQuery selects the nearest vectors
+type VectorQuery struct {
+	Vector        []float32
+	TopK          int          // Defaults to 10
+	Filter        VectorFilter // Optional metadata filter
+	IncludeValues bool         // Return the stored vectors with the matches
+}
+
+// VectorFilter restricts a query by metadata. Each key must equal its
+// value; a slice value matches any of its elements. Keys are ANDed.
+type VectorFilter map[string]interface{}
+
+// VectorMatch is a query result. Score is higher for closer vectors: the
+// cosine similarity, the dot product, or the negated Euclidean distance.
+type VectorMatch struct {
+	ID       string                 `json:"id"`
+	Score    float32                `json:"score"`
+	Values   []float32              `json:"values,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// DistanceMetric is how vectors are compared
+type DistanceMetric string
+
+const (
+	MetricCosine     DistanceMetric = "cosine"
+	MetricDotProduct DistanceMetric = "dot"
+	MetricEuclidean  DistanceMetric = "euclidean"
+)
+
+// ErrDimensionMismatch is returned for a vector of the wrong length
+var ErrDimensionMismatch = errors.New("vector dimension mismatch")
+
+// VectorStoreConfig configures a vector store collection
+type VectorStoreConfig struct {
+	Collection string         // Letters, digits and underscores
+	Dimensions int            // Embedding length, e.g. 1536 for text-embedding-3-small
+	Metric     DistanceMetric // Defaults to cosine
+}
+
+// collectionName restricts collections to names safe in SQL identifiers
+// and URL paths
+var collectionName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,47}$`)
+
+// validate checks the config and fills in defaults
+func (c *VectorStoreConfig) validate() error {
+	if !collectionName.MatchString(c.Collection) {
+		return fmt.Errorf("invalid vector collection name: %q", c.Collection)
+	}
+	if c.Dimensions <= 0 {
+		return fmt.Errorf("vector dimensions must be positive")
+	}
+	switch c.Metric {
+	case "":
+		c.Metric = MetricCosine
+	case MetricCosine, MetricDotProduct, MetricEuclidean:
+	default:
+		return fmt.Errorf("unknown distance metric: %s", c.Metric)
+	}
+	return nil
+}
+
+// checkVectors validates the IDs and lengths of vectors to upsert
+func checkVectors(vectors []Vector, dimensions int) error {
+	for _, v := range vectors {
+		if v.ID == "" {
+			return fmt.Errorf("vector ID is required")
+		}
+		if len(v.Values) != dimensions {
+			return fmt.Errorf("%w: %s has %d values, want %d", ErrDimensionMismatch, v.ID, len(v.Values), dimensions)
+		}
+	}
+	return nil
+}
+
+// checkQuery validates a query and applies the default TopK
+func checkQuery(query *VectorQuery, dimensions int) error {
+	if len(query.Vector) != dimensions {
+		return fmt.Errorf("%w: query has %d values, want %d", ErrDimensionMismatch, len(query.Vector), dimensions)
+	}
+	if query.TopK <= 0 {
+		query.TopK = 10
+	}
+	return nil
+}
+
+// NewVectorStoreFromEnv creates the store selected by VECTOR_STORE:
+// memory (default), pgvector (using db) or qdrant (QDRANT_URL and
+// QDRANT_API_KEY). VECTOR_METRIC overrides the config's metric.
+func NewVectorStoreFromEnv(db *gorm.DB, config VectorStoreConfig) (VectorStore, error) {
+	if metric := os.Getenv("VECTOR_METRIC"); metric != "" {
+		config.Metric = DistanceMetric(metric)
+	}
+
+	switch backend := os.Getenv("VECTOR_STORE"); backend {
+	case "", "memory":
+		return NewMemoryVectorStore(config)
+	case "pgvector":
+		if db == nil {
+			return nil, fmt.Errorf("pgvector store requires a database")
+		}
+		return NewPGVectorStore(db, config)
+	case "qdrant":
+		return NewQdrantVectorStore(LoadQdrantConfig(), config)
+	default:
+		return nil, fmt.Errorf("unknown vector store: %s", backend)
+	}
+}
+
+// EmbeddingVectors extracts the vectors from an embedding inference output
+// in OpenAI's shape, which every provider returns
+func EmbeddingVectors(output *InferenceOutput) ([][]float32, error) {
+	result, ok := output.Result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("inference result is not an embedding")
+	}
+	data, ok := result["data"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("inference result is not an embedding")
+	}
+
+	vectors := make([][]float32, 0, len(data))
+	for _, item := range data {
+		entry, _ := item.(map[string]interface{})
+		vector, err := toFloat32s(entry["embedding"])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
+
+// toFloat32s converts a decoded or native embedding to float32s
+func toFloat32s(value interface{}) ([]float32, error) {
+	switch v := value.(type) {
+	case []float32:
+		return v, nil
+	case []float64:
+		out := make([]float32, len(v))
+		for i, f := range v {
+			out[i] = float32(f)
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]float32, len(v))
+		for i, item := range v {
+			f, ok := item.(float64)
+			if !ok {
+				return nil, fmt.Errorf("embedding value is not a number")
+			}
+			out[i] = float32(f)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("embedding has unexpected type %T", value)
+	}
+}
+
+// vectorScore compares two vectors of equal length; higher is closer
+func vectorScore(metric DistanceMetric, a, b []float32) float32 {
+	var dot, normA, normB, dist float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		normA += x * x
+		normB += y * y
+		dist += (x - y) * (x - y)
+	}
+
+	switch metric {
+	case MetricDotProduct:
+		return float32(dot)
+	case MetricEuclidean:
+		return float32(-math.Sqrt(dist))
+	default:
+		if normA == 0 || normB == 0 {
+			return 0
+		}
+		return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+	}
+}
+
+// matchesFilter reports whether metadata satisfies every filter key
+func matchesFilter(metadata map[string]interface{}, filter VectorFilter) bool {
+	for key, want := range filter {
+		got, ok := metadata[key]
+		if !ok {
+			return false
+		}
+		if !matchesValue(got, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesValue compares a metadata value with a filter value, where a
+// slice filter value matches any element
+func matchesValue(got, want interface{}) bool {
+	for _, value := range filterValues(want) {
+		if scalarEqual(got, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// scalarEqual compares values, treating numbers of any type as equal when
+// their values are, so filters work on JSON-decoded metadata
+func scalarEqual(a, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toFloat converts a numeric value to float64
+func toFloat(value interface{}) (float64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.String:
+		if n, ok := value.(interface{ String() string }); ok {
+			// json.Number
+			f, err := strconv.ParseFloat(n.String(), 64)
+			return f, err == nil
+		}
+	}
+	return 0, false
+}
+
+// filterValues lists the values a filter key accepts
+func filterValues(want interface{}) []interface{} {
+	rv := reflect.ValueOf(want)
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		values := make([]interface{}, rv.Len())
+		for i := range values {
+			values[i] = rv.Index(i).Interface()
+		}
+		return values
+	}
+	return []interface{}{want}
+}
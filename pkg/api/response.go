@@ -3,6 +3,8 @@ package api
 import (
 	"time"
 
+	"neonexcore/pkg/errors"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -138,6 +140,19 @@ func Error(c *fiber.Ctx, statusCode int, message string, errors interface{}) err
 	})
 }
 
+// RespondError sends the status, message and details of an
+// *errors.AppError, or a 500 for any other error
+func RespondError(c *fiber.Ctx, err error) error {
+	if appErr, ok := errors.GetAppError(err); ok {
+		var details interface{}
+		if appErr.Details != nil {
+			details = appErr.Details
+		}
+		return Error(c, appErr.StatusCode, appErr.Message, details)
+	}
+	return InternalError(c, err.Error())
+}
+
 // BadRequest sends a 400 Bad Request response
 func BadRequest(c *fiber.Ctx, message string, errors interface{}) error {
 	return Error(c, fiber.StatusBadRequest, message, errors)
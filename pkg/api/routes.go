@@ -0,0 +1,329 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	Method     string   `json:"method"` // "USE" for middleware
+	Path       string   `json:"path"`
+	Module     string   `json:"module"`
+	Middleware []string `json:"middleware,omitempty"` // Handlers that run first, outside the global ones
+	Handler    string   `json:"handler"`
+	Auth       []string `json:"auth,omitempty"` // Authentication and authorization checks in the chain
+
+	key *fiber.Handler // Identifies one registration across the methods it is added under
+}
+
+// RouteConflict is a pair of registrations that cannot both be served
+type RouteConflict struct {
+	Kind    string    `json:"kind"` // "duplicate" or "shadowed"
+	Method  string    `json:"method"`
+	First   RouteInfo `json:"first"`
+	Second  RouteInfo `json:"second"`
+	Message string    `json:"message"`
+}
+
+// RouteTable is every route an app registered, in registration order
+type RouteTable struct {
+	Global    []string        `json:"global"` // Middleware in front of every route
+	Routes    []RouteInfo     `json:"routes"`
+	Conflicts []RouteConflict `json:"conflicts"`
+}
+
+// authHandlers maps handler names to the check they make, see
+// RegisterAuthHandler
+var (
+	authHandlers = map[string]string{
+		"auth.AuthMiddleware":         "jwt",
+		"auth.OptionalAuthMiddleware": "jwt (optional)",
+		"auth.RequireStepUp":          "step-up",
+		"rbac.RequirePermission":      "permission",
+		"rbac.RequireAnyPermission":   "permission",
+		"rbac.RequireAllPermissions":  "permission",
+		"rbac.RequireRole":            "role",
+		"signing.Middleware":          "signed URL",
+		"tenancy.AdminMiddleware":     "tenant admin",
+	}
+	authHandlersMu sync.RWMutex
+)
+
+// RegisterAuthHandler names the check an authentication or authorization
+// handler makes, for route listings. name is as returned by HandlerName.
+func RegisterAuthHandler(name, check string) {
+	authHandlersMu.Lock()
+	defer authHandlersMu.Unlock()
+	authHandlers[name] = check
+}
+
+func authCheck(name string) string {
+	authHandlersMu.RLock()
+	defer authHandlersMu.RUnlock()
+	return authHandlers[name]
+}
+
+var closureSuffix = regexp.MustCompile(`(\.func\d+)+$`)
+
+// HandlerName returns a readable name for a handler, such as
+// "auth.AuthMiddleware" or "links.(*Controller).List"
+func HandlerName(handler fiber.Handler) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(handler).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	if slash := strings.LastIndex(name, "/"); slash >= 0 {
+		name = name[slash+1:]
+	}
+	name = strings.TrimSuffix(name, "-fm")
+	return closureSuffix.ReplaceAllString(name, "")
+}
+
+// RouteRecorder records route registrations on an app along with the
+// module making them. fiber keeps neither the owner nor registrations it
+// merges, so conflicts can only be found while routes are added.
+type RouteRecorder struct {
+	owner   string
+	records []RouteInfo
+	mu      sync.Mutex
+}
+
+// NewRouteRecorder creates a recorder attributing routes to owner until
+// SetOwner is called
+func NewRouteRecorder(owner string) *RouteRecorder {
+	return &RouteRecorder{owner: owner}
+}
+
+// Attach starts recording routes registered on app. Attach before any
+// route is added.
+func (r *RouteRecorder) Attach(app *fiber.App) {
+	app.Hooks().OnRoute(func(route fiber.Route) error {
+		r.record(route)
+		return nil
+	})
+}
+
+// SetOwner attributes the routes registered from now on to a module
+func (r *RouteRecorder) SetOwner(owner string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.owner = owner
+}
+
+func (r *RouteRecorder) record(route fiber.Route) {
+	if len(route.Handlers) == 0 {
+		return // Mounted app
+	}
+
+	info := RouteInfo{Method: route.Method, Path: route.Path, key: &route.Handlers[0]}
+	use := reflect.ValueOf(route).FieldByName("use").Bool()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info.Module = r.owner
+	if use {
+		// Middleware is added under every method; keep it once
+		if n := len(r.records); n > 0 && r.records[n-1].Method == "USE" && r.records[n-1].key == info.key {
+			return
+		}
+		info.Method = "USE"
+	}
+
+	handlers := make([]string, len(route.Handlers))
+	for i, handler := range route.Handlers {
+		handlers[i] = HandlerName(handler)
+	}
+	if use {
+		info.Middleware = handlers
+	} else {
+		info.Middleware, info.Handler = handlers[:len(handlers)-1], handlers[len(handlers)-1]
+	}
+	r.records = append(r.records, info)
+}
+
+// Table returns the recorded routes, each with the middleware that runs
+// before it, and the conflicts between them
+func (r *RouteRecorder) Table() *RouteTable {
+	r.mu.Lock()
+	records := make([]RouteInfo, len(r.records))
+	copy(records, r.records)
+	r.mu.Unlock()
+
+	// fiber adds HEAD with every GET
+	gets := make(map[*fiber.Handler]bool)
+	for _, record := range records {
+		if record.Method == fiber.MethodGet {
+			gets[record.key] = true
+		}
+	}
+
+	table := &RouteTable{Global: []string{}, Routes: []RouteInfo{}}
+	var middleware []RouteInfo
+	for _, record := range records {
+		if record.Method == "USE" {
+			if normalizePath(record.Path) == "/" {
+				table.Global = append(table.Global, record.Middleware...)
+			} else {
+				middleware = append(middleware, record)
+			}
+			continue
+		}
+		if record.Method == fiber.MethodHead && gets[record.key] {
+			continue
+		}
+
+		// Middleware registered earlier on a prefix of the path runs first
+		route := record
+		var chain []string
+		for _, use := range middleware {
+			if hasPathPrefix(normalizePath(route.Path), normalizePath(use.Path)) {
+				chain = append(chain, use.Middleware...)
+			}
+		}
+		route.Middleware = append(chain, route.Middleware...)
+		for _, name := range append(append([]string{}, table.Global...), route.Middleware...) {
+			if check := authCheck(name); check != "" && !containsName(route.Auth, check) {
+				route.Auth = append(route.Auth, check)
+			}
+		}
+		table.Routes = append(table.Routes, route)
+	}
+
+	table.Conflicts = FindRouteConflicts(table.Routes)
+	return table
+}
+
+// FindRouteConflicts reports routes registered twice for the same method
+// and path, and routes that can never match because an earlier route with
+// parameters matches their path first
+func FindRouteConflicts(routes []RouteInfo) []RouteConflict {
+	conflicts := []RouteConflict{}
+	byMethod := make(map[string][]RouteInfo)
+	var methods []string
+	for _, route := range routes {
+		if _, ok := byMethod[route.Method]; !ok {
+			methods = append(methods, route.Method)
+		}
+		byMethod[route.Method] = append(byMethod[route.Method], route)
+	}
+	sort.Strings(methods)
+
+	for _, method := range methods {
+		list := byMethod[method]
+		for j := range list {
+			for i := 0; i < j; i++ {
+				first, second := list[i], list[j]
+				switch {
+				case routePattern(first.Path) == routePattern(second.Path):
+					message := fmt.Sprintf("%s %s is registered by %s and again by %s", method, second.Path, first.Module, second.Module)
+					if first.Module == second.Module {
+						message = fmt.Sprintf("%s %s is registered twice by %s", method, second.Path, first.Module)
+					}
+					conflicts = append(conflicts, RouteConflict{
+						Kind:    "duplicate",
+						Method:  method,
+						First:   first,
+						Second:  second,
+						Message: message,
+					})
+				case shadows(first.Path, second.Path):
+					conflicts = append(conflicts, RouteConflict{
+						Kind:    "shadowed",
+						Method:  method,
+						First:   first,
+						Second:  second,
+						Message: fmt.Sprintf("%s %s (%s) is never reached, %s (%s) matches it first", method, second.Path, second.Module, first.Path, first.Module),
+					})
+				default:
+					continue
+				}
+				break // Report each route once
+			}
+		}
+	}
+	return conflicts
+}
+
+// routePattern normalizes a path so that routes differing only in
+// parameter names compare equal
+func routePattern(path string) string {
+	segments := pathSegments(path)
+	for i, segment := range segments {
+		if isParamSegment(segment) {
+			segments[i] = segment[:1]
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// shadows reports whether every request matching later also matches
+// earlier, which fiber tries first
+func shadows(earlier, later string) bool {
+	e, l := pathSegments(earlier), pathSegments(later)
+	for i, segment := range e {
+		if segment == "*" || segment == "+" {
+			return true
+		}
+		if i >= len(l) {
+			return strings.HasSuffix(segment, "?") && i == len(e)-1
+		}
+		switch {
+		case isParamSegment(segment):
+		case isParamSegment(l[i]):
+			return false // A parameter matches more than a literal
+		case segment != l[i]:
+			return false
+		}
+	}
+	return len(e) == len(l)
+}
+
+func isParamSegment(segment string) bool {
+	return strings.HasPrefix(segment, ":") || segment == "*" || segment == "+"
+}
+
+func pathSegments(path string) []string {
+	path = strings.Trim(normalizePath(path), "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+// normalizePath applies fiber's default matching: case-insensitive, no
+// trailing slash
+func normalizePath(path string) string {
+	path = strings.ToLower(path)
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	if prefix == "/" {
+		return true
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
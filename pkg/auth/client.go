@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type clientKey struct{}
+
+// Client describes where a request came from, for security records
+type Client struct {
+	IP        string
+	UserAgent string
+	// Geo holds proxy-supplied location headers (lowercased names), such
+	// as Cloudflare's cf-ipcountry and cf-iplatitude. They can be forged
+	// when the app is not behind that proxy; consumers decide whether to
+	// trust them.
+	Geo map[string]string
+}
+
+// geoHeaders are the location headers copied into a Client
+var geoHeaders = []string{"cf-ipcountry", "cf-ipcity", "cf-iplatitude", "cf-iplongitude"}
+
+// WithClient returns a context carrying client
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext returns the client stored in ctx
+func ClientFromContext(ctx context.Context) (Client, bool) {
+	client, ok := ctx.Value(clientKey{}).(Client)
+	return client, ok
+}
+
+// RequestContext returns the request's user context with its client
+// attached. Values are copied, so the context outlives the request.
+func RequestContext(c *fiber.Ctx) context.Context {
+	client := Client{
+		IP:        c.IP(),
+		UserAgent: strings.Clone(c.Get(fiber.HeaderUserAgent)),
+	}
+	for _, name := range geoHeaders {
+		if value := c.Get(name); value != "" {
+			if client.Geo == nil {
+				client.Geo = make(map[string]string, len(geoHeaders))
+			}
+			client.Geo[name] = strings.Clone(value)
+		}
+	}
+	return WithClient(c.UserContext(), client)
+}
+
+// ClientFields returns the client of ctx as event data fields
+func ClientFields(ctx context.Context) map[string]interface{} {
+	client, ok := ClientFromContext(ctx)
+	if !ok {
+		return map[string]interface{}{}
+	}
+	fields := map[string]interface{}{
+		"ip":         client.IP,
+		"user_agent": client.UserAgent,
+	}
+	if len(client.Geo) > 0 {
+		fields["geo"] = client.Geo
+	}
+	return fields
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -32,6 +33,39 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
+// Claims metadata keys set for API key requests
+const (
+	MetadataAPIKeyID = "api_key_id"
+	MetadataScopes   = "scopes" // Comma-separated permissions the key is limited to
+	MetadataMode     = "mode"   // live or test
+)
+
+// IsAPIKey reports whether the claims were issued for an API key
+func (c *Claims) IsAPIKey() bool {
+	_, ok := c.Metadata[MetadataAPIKeyID]
+	return ok
+}
+
+// HasScope reports whether the claims allow a permission. Claims without
+// scopes, such as user tokens, are limited only by the user's roles.
+// Scopes may be "*" or end in ".*" to allow a permission prefix.
+func (c *Claims) HasScope(permission string) bool {
+	scopes, ok := c.Metadata[MetadataScopes]
+	if !ok {
+		return true
+	}
+	for _, scope := range strings.Split(scopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "*" || scope == permission {
+			return true
+		}
+		if strings.HasSuffix(scope, ".*") && strings.HasPrefix(permission, strings.TrimSuffix(scope, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
 // JWTManager handles JWT operations
 type JWTManager struct {
 	config *JWTConfig
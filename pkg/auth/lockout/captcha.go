@@ -0,0 +1,97 @@
+package lockout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/httpclient"
+)
+
+// CaptchaVerifier checks the response token of a CAPTCHA widget
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token, ip string) (bool, error)
+}
+
+// Site verification endpoints of supported CAPTCHA providers
+var captchaEndpoints = map[string]string{
+	"recaptcha": "https://www.google.com/recaptcha/api/siteverify",
+	"hcaptcha":  "https://api.hcaptcha.com/siteverify",
+	"turnstile": "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+}
+
+// SiteVerifier verifies tokens with a provider's siteverify API, which
+// reCAPTCHA, hCaptcha and Cloudflare Turnstile share
+type SiteVerifier struct {
+	endpoint string
+	secret   string
+	client   *http.Client
+}
+
+// NewSiteVerifier creates a verifier for a provider (recaptcha, hcaptcha
+// or turnstile) or a siteverify URL
+func NewSiteVerifier(provider, secret string) (*SiteVerifier, error) {
+	endpoint, ok := captchaEndpoints[strings.ToLower(provider)]
+	if !ok {
+		if !strings.HasPrefix(provider, "https://") {
+			return nil, fmt.Errorf("unknown CAPTCHA provider: %s", provider)
+		}
+		endpoint = provider
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("CAPTCHA secret is required")
+	}
+
+	return &SiteVerifier{
+		endpoint: endpoint,
+		secret:   secret,
+		client:   httpclient.New(10 * time.Second),
+	}, nil
+}
+
+// LoadCaptchaVerifier creates the verifier configured by CAPTCHA_PROVIDER
+// and CAPTCHA_SECRET, or returns nil when none is configured
+func LoadCaptchaVerifier() (CaptchaVerifier, error) {
+	provider := os.Getenv("CAPTCHA_PROVIDER")
+	if provider == "" {
+		return nil, nil
+	}
+	return NewSiteVerifier(provider, os.Getenv("CAPTCHA_SECRET"))
+}
+
+// Verify checks a token with the provider
+func (v *SiteVerifier) Verify(ctx context.Context, token, ip string) (bool, error) {
+	form := url.Values{"secret": {v.secret}, "response": {token}}
+	if ip != "" {
+		form.Set("remoteip", ip)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", v.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("CAPTCHA verification error: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	return result.Success, nil
+}
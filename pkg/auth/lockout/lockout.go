@@ -0,0 +1,425 @@
+// Package lockout protects logins against brute force: it tracks failed
+// attempts per account and per IP, slows repeated failures down, locks
+// accounts and blocks IPs for a while, asks for a CAPTCHA, and reports
+// security events.
+package lockout
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+)
+
+// Reasons an attempt is refused
+const (
+	ReasonAccountLocked = "account_locked"
+	ReasonIPBlocked     = "ip_blocked"
+	ReasonThrottled     = "throttled"
+)
+
+// keyPrefix namespaces the cache keys of the guard
+const keyPrefix = "lockout:"
+
+// Config configures a Guard
+type Config struct {
+	Enabled            bool
+	Window             time.Duration // Failures older than this are forgotten
+	MaxAccountFailures int           // Failures before an account is locked
+	MaxIPFailures      int           // Failures before an IP is blocked, across accounts
+	LockoutDuration    time.Duration // First lockout; each further one doubles
+	MaxLockoutDuration time.Duration
+	IPBlockDuration    time.Duration
+	DelayAfter         int           // Failures allowed before delays start
+	BaseDelay          time.Duration // First delay; each further failure doubles it
+	MaxDelay           time.Duration
+	CaptchaAfter       int  // Failures before a CAPTCHA is required, 0 to never
+	NotifyOnLockout    bool // Email the account owner when it is locked
+}
+
+// DefaultConfig returns the production configuration
+func DefaultConfig() Config {
+	return Config{
+		Enabled:            true,
+		Window:             15 * time.Minute,
+		MaxAccountFailures: 5,
+		MaxIPFailures:      50,
+		LockoutDuration:    15 * time.Minute,
+		MaxLockoutDuration: 24 * time.Hour,
+		IPBlockDuration:    time.Hour,
+		DelayAfter:         2,
+		BaseDelay:          time.Second,
+		MaxDelay:           30 * time.Second,
+		CaptchaAfter:       3,
+		NotifyOnLockout:    true,
+	}
+}
+
+// ConfigForEnvironment returns the configuration for an APP_ENV value.
+// Development keeps the protection on but lenient, so it is exercised
+// without getting in the way; tests turn it off.
+func ConfigForEnvironment(env string) Config {
+	config := DefaultConfig()
+
+	switch strings.ToLower(env) {
+	case "development", "dev", "local":
+		config.MaxAccountFailures = 20
+		config.MaxIPFailures = 200
+		config.LockoutDuration = time.Minute
+		config.MaxLockoutDuration = 5 * time.Minute
+		config.IPBlockDuration = time.Minute
+		config.DelayAfter = 10
+		config.CaptchaAfter = 0
+		config.NotifyOnLockout = false
+	case "test", "testing":
+		config.Enabled = false
+	}
+
+	return config
+}
+
+// LoadConfig loads the configuration for APP_ENV, then applies LOGIN_*
+// overrides from environment
+func LoadConfig() Config {
+	config := ConfigForEnvironment(os.Getenv("APP_ENV"))
+
+	if enabled, err := strconv.ParseBool(os.Getenv("LOGIN_PROTECTION")); err == nil {
+		config.Enabled = enabled
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_MAX_ATTEMPTS")); err == nil && n > 0 {
+		config.MaxAccountFailures = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_MAX_ATTEMPTS_PER_IP")); err == nil && n > 0 {
+		config.MaxIPFailures = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("LOGIN_ATTEMPT_WINDOW")); err == nil && d > 0 {
+		config.Window = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_DURATION")); err == nil && d > 0 {
+		config.LockoutDuration = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("LOGIN_MAX_LOCKOUT_DURATION")); err == nil && d > 0 {
+		config.MaxLockoutDuration = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("LOGIN_IP_BLOCK_DURATION")); err == nil && d > 0 {
+		config.IPBlockDuration = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_DELAY_AFTER")); err == nil && n >= 0 {
+		config.DelayAfter = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("LOGIN_BASE_DELAY")); err == nil && d >= 0 {
+		config.BaseDelay = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("LOGIN_MAX_DELAY")); err == nil && d >= 0 {
+		config.MaxDelay = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_CAPTCHA_AFTER")); err == nil && n >= 0 {
+		config.CaptchaAfter = n
+	}
+	if notify, err := strconv.ParseBool(os.Getenv("LOGIN_NOTIFY_LOCKOUT")); err == nil {
+		config.NotifyOnLockout = notify
+	}
+
+	return config
+}
+
+// Notifier emails account owners about lockouts
+type Notifier interface {
+	SendEmail(ctx context.Context, to, subject, body string) error
+}
+
+// Decision is the outcome of checking or recording an attempt
+type Decision struct {
+	Allowed         bool          `json:"allowed"`
+	Reason          string        `json:"reason,omitempty"`
+	RetryAfter      time.Duration `json:"-"`
+	CaptchaRequired bool          `json:"captcha_required"`
+}
+
+// Guard tracks login attempts in a cache. Instances must share the cache
+// (e.g. Redis) for limits to hold across them.
+type Guard struct {
+	config   Config
+	store    cache.Cache
+	captcha  CaptchaVerifier
+	notifier Notifier
+}
+
+// NewGuard creates a guard. A nil cache uses an in-memory one; without a
+// CAPTCHA verifier no CAPTCHA is asked for, and without a notifier no
+// lockout emails are sent.
+func NewGuard(config Config, store cache.Cache, captcha CaptchaVerifier, notifier Notifier) *Guard {
+	defaults := DefaultConfig()
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	if config.LockoutDuration <= 0 {
+		config.LockoutDuration = defaults.LockoutDuration
+	}
+	if config.MaxLockoutDuration < config.LockoutDuration {
+		config.MaxLockoutDuration = config.LockoutDuration
+	}
+	if config.IPBlockDuration <= 0 {
+		config.IPBlockDuration = defaults.IPBlockDuration
+	}
+	if store == nil {
+		store = cache.NewMemoryCache(cache.DefaultMemoryCacheConfig())
+	}
+
+	return &Guard{config: config, store: store, captcha: captcha, notifier: notifier}
+}
+
+// Enabled reports whether the guard is on
+func (g *Guard) Enabled() bool {
+	return g.config.Enabled
+}
+
+// Check decides whether a login attempt may proceed, before the password
+// is verified. Cache errors allow the attempt, so a cache outage cannot
+// lock everyone out.
+func (g *Guard) Check(ctx context.Context, account, ip string) *Decision {
+	if !g.config.Enabled {
+		return &Decision{Allowed: true}
+	}
+	keys := g.keys(account, ip)
+
+	if ttl := g.remaining(ctx, keys.ipBlocked); ttl > 0 {
+		return &Decision{Reason: ReasonIPBlocked, RetryAfter: ttl}
+	}
+	if ttl := g.remaining(ctx, keys.locked); ttl > 0 {
+		return &Decision{Reason: ReasonAccountLocked, RetryAfter: ttl}
+	}
+	if ttl := g.remaining(ctx, keys.wait); ttl > 0 {
+		return &Decision{Reason: ReasonThrottled, RetryAfter: ttl}
+	}
+
+	return &Decision{Allowed: true, CaptchaRequired: g.captchaRequired(ctx, keys)}
+}
+
+// VerifyCaptcha checks a CAPTCHA response; without a verifier it passes
+func (g *Guard) VerifyCaptcha(ctx context.Context, token, ip string) (bool, error) {
+	if g.captcha == nil {
+		return true, nil
+	}
+	if token == "" {
+		return false, nil
+	}
+	return g.captcha.Verify(ctx, token, ip)
+}
+
+// RecordFailure records a failed login and returns the decision for the
+// next attempt
+func (g *Guard) RecordFailure(ctx context.Context, account, ip string) *Decision {
+	if !g.config.Enabled {
+		return &Decision{Allowed: true}
+	}
+	keys := g.keys(account, ip)
+	decision := &Decision{Allowed: true}
+
+	failures := g.count(ctx, keys.failures, g.config.Window)
+	ipFailures := int64(0)
+	if ip != "" {
+		ipFailures = g.count(ctx, keys.ipFailures, g.config.Window)
+	}
+
+	events.DispatchAsync(ctx, events.Event{
+		Name: events.EventSecurityLoginFailed,
+		Data: map[string]interface{}{
+			"account":     account,
+			"ip":          ip,
+			"failures":    failures,
+			"ip_failures": ipFailures,
+		},
+	})
+
+	if g.config.MaxIPFailures > 0 && ipFailures >= int64(g.config.MaxIPFailures) {
+		g.blockIP(ctx, keys, ip)
+		decision.Allowed, decision.Reason, decision.RetryAfter = false, ReasonIPBlocked, g.config.IPBlockDuration
+	}
+
+	switch {
+	case g.config.MaxAccountFailures > 0 && failures >= int64(g.config.MaxAccountFailures):
+		duration := g.lock(ctx, keys, account, ip)
+		if decision.Allowed || duration > decision.RetryAfter {
+			decision.Allowed, decision.Reason, decision.RetryAfter = false, ReasonAccountLocked, duration
+		}
+	case failures > int64(g.config.DelayAfter) && g.config.BaseDelay > 0:
+		delay := backoff(g.config.BaseDelay, failures-int64(g.config.DelayAfter)-1, g.config.MaxDelay)
+		g.set(ctx, keys.wait, delay)
+		if decision.Allowed {
+			decision.Allowed, decision.Reason, decision.RetryAfter = false, ReasonThrottled, delay
+		}
+	}
+
+	if g.config.CaptchaAfter > 0 && failures >= int64(g.config.CaptchaAfter) {
+		g.set(ctx, keys.captcha, g.config.Window)
+	}
+	decision.CaptchaRequired = g.captchaRequired(ctx, keys)
+
+	return decision
+}
+
+// RecordSuccess clears an account's failures after a successful login.
+// IP failures are kept, since one valid account does not vouch for the
+// other attempts from the same address.
+func (g *Guard) RecordSuccess(ctx context.Context, account, ip string) {
+	if !g.config.Enabled {
+		return
+	}
+	g.Unlock(ctx, account)
+}
+
+// Unlock clears an account's failures, lockout and escalation history
+func (g *Guard) Unlock(ctx context.Context, account string) error {
+	keys := g.keys(account, "")
+	return g.store.DeleteMulti(ctx, []string{keys.failures, keys.locked, keys.lockouts, keys.wait, keys.captcha})
+}
+
+// UnblockIP lifts an IP block and forgets its failures
+func (g *Guard) UnblockIP(ctx context.Context, ip string) error {
+	keys := g.keys("", ip)
+	return g.store.DeleteMulti(ctx, []string{keys.ipFailures, keys.ipBlocked})
+}
+
+// lock locks an account, doubling the duration for each lockout within
+// the maximum duration
+func (g *Guard) lock(ctx context.Context, keys guardKeys, account, ip string) time.Duration {
+	lockouts := g.count(ctx, keys.lockouts, g.config.MaxLockoutDuration*2)
+	if lockouts < 1 {
+		lockouts = 1
+	}
+	duration := backoff(g.config.LockoutDuration, lockouts-1, g.config.MaxLockoutDuration)
+
+	g.set(ctx, keys.locked, duration)
+	g.store.DeleteMulti(ctx, []string{keys.failures, keys.wait})
+
+	lockedUntil := time.Now().Add(duration)
+	logger.Warn("Account locked after repeated failed logins", logger.Fields{
+		"account": account,
+		"ip":      ip,
+		"until":   lockedUntil,
+	})
+	events.DispatchAsync(ctx, events.Event{
+		Name: events.EventSecurityAccountLocked,
+		Data: map[string]interface{}{
+			"account":      account,
+			"ip":           ip,
+			"lockouts":     lockouts,
+			"locked_until": lockedUntil,
+		},
+	})
+
+	if g.config.NotifyOnLockout && g.notifier != nil && strings.Contains(account, "@") {
+		// Notify in the background; the login response should not wait on mail
+		go func() {
+			body := fmt.Sprintf("Your account was temporarily locked after several failed sign-in attempts from %s. "+
+				"It will unlock at %s. If this was not you, change your password once you can sign in again.",
+				ip, lockedUntil.UTC().Format(time.RFC1123))
+			if err := g.notifier.SendEmail(context.Background(), account, "Your account was temporarily locked", body); err != nil {
+				logger.Warn("Failed to send lockout notification", logger.Fields{"error": err.Error()})
+			}
+		}()
+	}
+
+	return duration
+}
+
+// blockIP blocks an address for the configured duration
+func (g *Guard) blockIP(ctx context.Context, keys guardKeys, ip string) {
+	g.set(ctx, keys.ipBlocked, g.config.IPBlockDuration)
+	g.store.Delete(ctx, keys.ipFailures)
+
+	logger.Warn("IP blocked after repeated failed logins", logger.Fields{"ip": ip})
+	events.DispatchAsync(ctx, events.Event{
+		Name: events.EventSecurityIPBlocked,
+		Data: map[string]interface{}{
+			"ip":            ip,
+			"blocked_until": time.Now().Add(g.config.IPBlockDuration),
+		},
+	})
+}
+
+// captchaRequired reports whether the next attempt needs a CAPTCHA
+func (g *Guard) captchaRequired(ctx context.Context, keys guardKeys) bool {
+	if g.captcha == nil || g.config.CaptchaAfter <= 0 {
+		return false
+	}
+	return g.remaining(ctx, keys.captcha) > 0
+}
+
+// count increments a windowed counter, starting the window on the first
+// increment. Errors count as zero.
+func (g *Guard) count(ctx context.Context, key string, window time.Duration) int64 {
+	n, err := g.store.Increment(ctx, key, 1)
+	if err != nil {
+		logger.Warn("Login attempt tracking failed", logger.Fields{"error": err.Error()})
+		return 0
+	}
+	if n == 1 {
+		g.store.Expire(ctx, key, window)
+	}
+	return n
+}
+
+// set stores a flag that expires after ttl
+func (g *Guard) set(ctx context.Context, key string, ttl time.Duration) {
+	if err := g.store.Set(ctx, key, true, ttl); err != nil {
+		logger.Warn("Login attempt tracking failed", logger.Fields{"error": err.Error()})
+	}
+}
+
+// remaining returns how long a flag has left, or zero if it is not set
+func (g *Guard) remaining(ctx context.Context, key string) time.Duration {
+	ttl, err := g.store.TTL(ctx, key)
+	if err != nil || ttl <= 0 {
+		return 0
+	}
+	return ttl
+}
+
+// guardKeys are the cache keys of an account and IP
+type guardKeys struct {
+	failures, locked, lockouts, wait, captcha string
+	ipFailures, ipBlocked                     string
+}
+
+// keys derives cache keys; accounts are hashed to keep emails out of the
+// cache and normalized so case variants share a counter
+func (g *Guard) keys(account, ip string) guardKeys {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(account))))
+	acct := keyPrefix + "acct:" + hex.EncodeToString(sum[:16]) + ":"
+	addr := keyPrefix + "ip:" + ip + ":"
+
+	return guardKeys{
+		failures:   acct + "failures",
+		locked:     acct + "locked",
+		lockouts:   acct + "lockouts",
+		wait:       acct + "wait",
+		captcha:    acct + "captcha",
+		ipFailures: addr + "failures",
+		ipBlocked:  addr + "blocked",
+	}
+}
+
+// backoff doubles base n times, up to max when max is positive
+func backoff(base time.Duration, n int64, max time.Duration) time.Duration {
+	delay := base
+	for i := int64(0); i < n; i++ {
+		delay *= 2
+		if max > 0 && delay >= max {
+			return max
+		}
+	}
+	if max > 0 && delay > max {
+		return max
+	}
+	return delay
+}
@@ -0,0 +1,51 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+
+	"neonexcore/pkg/cache"
+)
+
+func TestGuardsSharingAStoreShareCounters(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultConfig()
+	config.MaxAccountFailures = 4
+	config.DelayAfter = 10
+	config.NotifyOnLockout = false
+
+	// Two instances behind a load balancer, on one cache
+	store := cache.NewMemoryCache(cache.DefaultMemoryCacheConfig())
+	first := NewGuard(config, store, nil, nil)
+	second := NewGuard(config, store, nil, nil)
+
+	for i := 0; i < config.MaxAccountFailures-1; i++ {
+		guard := first
+		if i%2 == 1 {
+			guard = second
+		}
+		if decision := guard.RecordFailure(ctx, "owner@example.com", "203.0.113.7"); !decision.Allowed {
+			t.Fatalf("failure %d refused the next attempt: %+v", i+1, decision)
+		}
+	}
+
+	// The last allowed failure lands on the instance that saw fewer of them
+	if decision := second.RecordFailure(ctx, "Owner@example.com", "203.0.113.7"); decision.Allowed || decision.Reason != ReasonAccountLocked {
+		t.Fatalf("decision after %d failures = %+v, want %s", config.MaxAccountFailures, decision, ReasonAccountLocked)
+	}
+	if decision := first.Check(ctx, "owner@example.com", "198.51.100.2"); decision.Allowed || decision.Reason != ReasonAccountLocked {
+		t.Errorf("other instance's check = %+v, want %s", decision, ReasonAccountLocked)
+	}
+
+	// A guard with its own store has seen nothing
+	if decision := NewGuard(config, nil, nil, nil).Check(ctx, "owner@example.com", "203.0.113.7"); !decision.Allowed {
+		t.Errorf("separate guard's check = %+v, want allowed", decision)
+	}
+
+	if err := first.Unlock(ctx, "owner@example.com"); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if decision := second.Check(ctx, "owner@example.com", "203.0.113.7"); !decision.Allowed {
+		t.Errorf("check after unlocking on the other instance = %+v, want allowed", decision)
+	}
+}
@@ -0,0 +1,131 @@
+package lockout
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/errors"
+	"neonexcore/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CaptchaHeader carries the CAPTCHA response token when it is not in the
+// body's captcha_token field
+const CaptchaHeader = "X-Captcha-Token"
+
+// CaptchaRequiredHeader tells the client to show a CAPTCHA next time
+const CaptchaRequiredHeader = "X-Captcha-Required"
+
+// Protect guards a login handler. The account is read from accountField of
+// the JSON or form body. Refused attempts get 423 for locked accounts and
+// 429 otherwise, with Retry-After; a missing or wrong CAPTCHA gets 428.
+// The handler's result is recorded: invalid credentials count as a
+// failure and a successful response clears the account's failures.
+func Protect(guard *Guard, accountField string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !guard.Enabled() {
+			return c.Next()
+		}
+
+		ctx := c.UserContext()
+		ip := c.IP()
+		account, captchaToken := readLoginFields(c, accountField)
+
+		decision := guard.Check(ctx, account, ip)
+		if !decision.Allowed {
+			return refuse(c, decision)
+		}
+
+		if decision.CaptchaRequired {
+			if captchaToken == "" {
+				captchaToken = c.Get(CaptchaHeader)
+			}
+			ok, err := guard.VerifyCaptcha(ctx, captchaToken, ip)
+			if err != nil {
+				logger.Warn("CAPTCHA verification failed", logger.Fields{"error": err.Error()})
+			}
+			if !ok {
+				c.Set(CaptchaRequiredHeader, "true")
+				return api.Error(c, fiber.StatusPreconditionRequired, "CAPTCHA verification required", fiber.Map{
+					"code":             errors.ErrCodeCaptchaRequired,
+					"captcha_required": true,
+				})
+			}
+		}
+
+		err := c.Next()
+
+		switch {
+		case isInvalidCredentials(c, err):
+			next := guard.RecordFailure(ctx, account, ip)
+			if next.CaptchaRequired {
+				c.Set(CaptchaRequiredHeader, "true")
+			}
+			if !next.Allowed {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfterSeconds(next)))
+			}
+		case err == nil && c.Response().StatusCode() < fiber.StatusBadRequest:
+			guard.RecordSuccess(ctx, account, ip)
+		}
+
+		return err
+	}
+}
+
+// refuse responds to an attempt the guard does not allow
+func refuse(c *fiber.Ctx, decision *Decision) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfterSeconds(decision)))
+	details := fiber.Map{
+		"reason":      decision.Reason,
+		"retry_after": retryAfterSeconds(decision),
+	}
+
+	switch decision.Reason {
+	case ReasonAccountLocked:
+		details["code"] = errors.ErrCodeAccountLocked
+		return api.Error(c, fiber.StatusLocked, "Account is temporarily locked after too many failed logins", details)
+	case ReasonIPBlocked:
+		details["code"] = errors.ErrCodeTooManyRequests
+		return api.Error(c, fiber.StatusTooManyRequests, "Too many failed logins from this address", details)
+	default:
+		details["code"] = errors.ErrCodeTooManyRequests
+		return api.Error(c, fiber.StatusTooManyRequests, "Too many failed logins; try again shortly", details)
+	}
+}
+
+// readLoginFields reads the account and CAPTCHA token from the body
+func readLoginFields(c *fiber.Ctx, accountField string) (string, string) {
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		var body map[string]interface{}
+		if err := json.Unmarshal(c.Body(), &body); err == nil {
+			account, _ := body[accountField].(string)
+			token, _ := body["captcha_token"].(string)
+			return strings.TrimSpace(account), token
+		}
+	}
+	return strings.TrimSpace(c.FormValue(accountField)), c.FormValue("captcha_token")
+}
+
+// isInvalidCredentials reports whether the handler rejected the
+// credentials, as an error or a written 401
+func isInvalidCredentials(c *fiber.Ctx, err error) bool {
+	if err != nil {
+		if appErr, ok := errors.GetAppError(err); ok {
+			return appErr.Code == errors.ErrCodeInvalidCredentials || appErr.StatusCode == fiber.StatusUnauthorized
+		}
+		if fiberErr, ok := err.(*fiber.Error); ok {
+			return fiberErr.Code == fiber.StatusUnauthorized
+		}
+		return false
+	}
+	return c.Response().StatusCode() == fiber.StatusUnauthorized
+}
+
+// retryAfterSeconds rounds the wait up to whole seconds
+func retryAfterSeconds(decision *Decision) int {
+	return int(math.Ceil(decision.RetryAfter.Seconds()))
+}
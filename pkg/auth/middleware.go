@@ -1,14 +1,51 @@
 package auth
 
 import (
+	"context"
 	"strings"
 
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/ownership"
+
 	"github.com/gofiber/fiber/v2"
 )
 
-// AuthMiddleware creates authentication middleware
-func AuthMiddleware(jwtManager *JWTManager) fiber.Handler {
+// AuthOption configures AuthMiddleware
+type AuthOption func(*authOptions)
+
+type authOptions struct {
+	acceptAPIKeys bool
+}
+
+// AcceptAPIKeys lets requests authenticated upstream by an API key through.
+// API keys are limited to their scopes, so every route behind the
+// middleware must check one, with rbac.RequirePermission or RequireScope.
+func AcceptAPIKeys() AuthOption {
+	return func(o *authOptions) {
+		o.acceptAPIKeys = true
+	}
+}
+
+// AuthMiddleware creates authentication middleware. API keys are refused
+// unless the routes opt in with AcceptAPIKeys.
+func AuthMiddleware(jwtManager *JWTManager, opts ...AuthOption) fiber.Handler {
+	options := &authOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	return func(c *fiber.Ctx) error {
+		// Already authenticated upstream, e.g. by an API key
+		if claims, ok := GetClaims(c); ok {
+			if claims.IsAPIKey() && !options.acceptAPIKeys {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error":   "forbidden",
+					"message": "API keys are not accepted here",
+				})
+			}
+			return c.Next()
+		}
+
 		// Get token from Authorization header
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -39,11 +76,7 @@ func AuthMiddleware(jwtManager *JWTManager) fiber.Handler {
 		}
 
 		// Store claims in context
-		c.Locals("user_id", claims.UserID)
-		c.Locals("email", claims.Email)
-		c.Locals("role", claims.Role)
-		c.Locals("permissions", claims.Permissions)
-		c.Locals("claims", claims)
+		SetClaims(c, claims)
 
 		return c.Next()
 	}
@@ -56,16 +89,15 @@ func OptionalAuthMiddleware(jwtManager *JWTManager) fiber.Handler {
 		if authHeader == "" {
 			return c.Next()
 		}
+		if _, ok := GetClaims(c); ok {
+			return c.Next()
+		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) == 2 && parts[0] == "Bearer" {
 			claims, err := jwtManager.ValidateToken(parts[1])
 			if err == nil {
-				c.Locals("user_id", claims.UserID)
-				c.Locals("email", claims.Email)
-				c.Locals("role", claims.Role)
-				c.Locals("permissions", claims.Permissions)
-				c.Locals("claims", claims)
+				SetClaims(c, claims)
 			}
 		}
 
@@ -73,6 +105,52 @@ func OptionalAuthMiddleware(jwtManager *JWTManager) fiber.Handler {
 	}
 }
 
+// RequireScope refuses API keys whose scopes don't allow a permission.
+// User tokens pass; check their permissions with rbac.RequirePermission.
+func RequireScope(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if claims, ok := GetClaims(c); ok && !claims.HasScope(permission) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   "forbidden",
+				"message": "API key scope does not allow this action",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// SetClaims stores authenticated claims in context
+func SetClaims(c *fiber.Ctx, claims *Claims) {
+	c.Locals("user_id", claims.UserID)
+	c.Locals("email", claims.Email)
+	c.Locals("role", claims.Role)
+	c.Locals("permissions", claims.Permissions)
+	c.Locals("claims", claims)
+
+	// Correlate downstream logs with the authenticated user
+	logger.AddRequestFields(c, logger.Fields{"user_id": claims.UserID})
+
+	// Scope queries on owned models to the user
+	c.SetUserContext(ownership.WithUser(c.UserContext(), claims.UserID))
+
+	// Let services check API key scopes
+	c.SetUserContext(WithClaims(c.UserContext(), claims))
+}
+
+type claimsKey struct{}
+
+// WithClaims returns a context carrying authenticated claims, so services
+// can check API key scopes
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims stored by WithClaims
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
 // GetUserID gets user ID from context
 func GetUserID(c *fiber.Ctx) (uint, bool) {
 	userID, ok := c.Locals("user_id").(uint)
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiKeyClaims are claims as the portal's API key authenticator sets them
+func apiKeyClaims(scopes string) *Claims {
+	return &Claims{
+		UserID: 1,
+		Role:   "api_key",
+		Metadata: map[string]string{
+			MetadataAPIKeyID: "7",
+			MetadataScopes:   scopes,
+			MetadataMode:     "live",
+		},
+	}
+}
+
+// newTestApp mounts a vault-like group refusing API keys and an
+// analytics-like group accepting them behind a scope check
+func newTestApp(jwtManager *JWTManager) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if scopes := c.Get("X-API-Key-Scopes"); scopes != "" {
+			SetClaims(c, apiKeyClaims(scopes))
+		}
+		return c.Next()
+	})
+
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+
+	vault := app.Group("/vault", AuthMiddleware(jwtManager))
+	vault.Get("/secrets", ok)
+
+	reports := app.Group("/reports", AuthMiddleware(jwtManager, AcceptAPIKeys()))
+	reports.Get("", RequireScope("reports.read"), ok)
+	return app
+}
+
+func TestAuthMiddlewareAPIKeys(t *testing.T) {
+	jwtManager := NewJWTManager(&JWTConfig{SecretKey: "test-secret", AccessExpiry: time.Minute, Issuer: "test"})
+	token, err := jwtManager.GenerateAccessToken(1, "owner@example.com", "user", nil)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	app := newTestApp(jwtManager)
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		scopes string
+		want   int
+	}{
+		{"scoped key on vault secrets", "/vault/secrets", "", "analytics.read", fiber.StatusForbidden},
+		{"wildcard key on vault secrets", "/vault/secrets", "", "*", fiber.StatusForbidden},
+		{"owner token on vault secrets", "/vault/secrets", token, "", fiber.StatusOK},
+		{"anonymous on vault secrets", "/vault/secrets", "", "", fiber.StatusUnauthorized},
+		{"key in scope on opted-in route", "/reports", "", "reports.read", fiber.StatusOK},
+		{"key with prefix scope on opted-in route", "/reports", "", "reports.*", fiber.StatusOK},
+		{"key out of scope on opted-in route", "/reports", "", "analytics.read", fiber.StatusForbidden},
+		{"owner token on opted-in route", "/reports", token, "", fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.scopes != "" {
+				req.Header.Set("X-API-Key-Scopes", tt.scopes)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimsFromContext(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		SetClaims(c, apiKeyClaims("reports.read"))
+		claims, ok := ClaimsFromContext(c.UserContext())
+		if !ok || !claims.IsAPIKey() || claims.HasScope("vault.read") {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"sync"
+)
+
+// PasswordLoginPolicy decides whether a user may sign in with a password,
+// e.g. after switching to passkeys
+type PasswordLoginPolicy func(ctx context.Context, userID uint) (bool, error)
+
+var (
+	passwordLoginPolicy PasswordLoginPolicy
+	passwordPolicyMu    sync.RWMutex
+)
+
+// SetPasswordLoginPolicy installs the password login policy. Without one,
+// password login is always allowed.
+func SetPasswordLoginPolicy(policy PasswordLoginPolicy) {
+	passwordPolicyMu.Lock()
+	defer passwordPolicyMu.Unlock()
+	passwordLoginPolicy = policy
+}
+
+// PasswordLoginAllowed reports whether a user may sign in with a password.
+// Policy errors allow the login, so a failing store cannot lock users out.
+func PasswordLoginAllowed(ctx context.Context, userID uint) bool {
+	passwordPolicyMu.RLock()
+	policy := passwordLoginPolicy
+	passwordPolicyMu.RUnlock()
+
+	if policy == nil {
+		return true
+	}
+	allowed, err := policy(ctx, userID)
+	return err != nil || allowed
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StepUpPolicy reports whether a user must verify their identity again
+// before sensitive actions, e.g. after a suspicious login
+type StepUpPolicy func(ctx context.Context, userID uint) (bool, error)
+
+var (
+	stepUpPolicy   StepUpPolicy
+	stepUpPolicyMu sync.RWMutex
+)
+
+// SetStepUpPolicy installs the step-up policy. Without one, step-up is
+// never required.
+func SetStepUpPolicy(policy StepUpPolicy) {
+	stepUpPolicyMu.Lock()
+	defer stepUpPolicyMu.Unlock()
+	stepUpPolicy = policy
+}
+
+// StepUpRequired reports whether a user must step up. Policy errors do not
+// require it, so a failing store cannot lock users out.
+func StepUpRequired(ctx context.Context, userID uint) bool {
+	stepUpPolicyMu.RLock()
+	policy := stepUpPolicy
+	stepUpPolicyMu.RUnlock()
+
+	if policy == nil {
+		return false
+	}
+	required, err := policy(ctx, userID)
+	return err == nil && required
+}
+
+// RequireStepUp guards sensitive routes after AuthMiddleware. Users with a
+// pending step-up get 403 until they complete it.
+func RequireStepUp() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := GetUserID(c)
+		if !ok || !StepUpRequired(c.UserContext(), userID) {
+			return c.Next()
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":   "step_up_required",
+			"message": "additional verification required",
+		})
+	}
+}
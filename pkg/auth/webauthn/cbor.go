@@ -0,0 +1,146 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// errCBOR is returned for malformed or unsupported CBOR
+var errCBOR = errors.New("webauthn: malformed CBOR")
+
+// maxCBORDepth bounds nesting, since input comes from clients
+const maxCBORDepth = 16
+
+// decodeCBOR decodes one CBOR data item, as used by attestation objects
+// and COSE keys, and returns the rest of the input. Maps decode to
+// map[interface{}]interface{} with int64 or string keys, byte strings to
+// []byte, integers to int64, and tags to their content.
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth || len(data) == 0 {
+		return nil, nil, errCBOR
+	}
+
+	major, info := data[0]>>5, data[0]&0x1f
+	data = data[1:]
+
+	// Simple values and floats carry no length argument
+	if major == 7 {
+		return decodeCBORSimple(info, data)
+	}
+
+	arg, data, err := cborArgument(info, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0: // Unsigned integer
+		if arg > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return int64(arg), data, nil
+	case 1: // Negative integer
+		if arg > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3: // Byte and text strings
+		if arg > uint64(len(data)) {
+			return nil, nil, errCBOR
+		}
+		value := data[:arg]
+		if major == 3 {
+			return string(value), data[arg:], nil
+		}
+		return append([]byte(nil), value...), data[arg:], nil
+	case 4: // Array
+		if arg > uint64(len(data)) {
+			return nil, nil, errCBOR
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var item interface{}
+			if item, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+	case 5: // Map
+		if arg > uint64(len(data)) {
+			return nil, nil, errCBOR
+		}
+		entries := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key, value interface{}
+			if key, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errCBOR
+			}
+			if value, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			entries[key] = value
+		}
+		return entries, data, nil
+	default: // Tag; keep the content
+		return decodeCBORItem(data, depth+1)
+	}
+}
+
+// cborArgument reads the length or value argument of an item header.
+// Indefinite lengths are not used by authenticators and are rejected.
+func cborArgument(info byte, data []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24 && len(data) >= 1:
+		return uint64(data[0]), data[1:], nil
+	case info == 25 && len(data) >= 2:
+		return uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case info == 26 && len(data) >= 4:
+		return uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case info == 27 && len(data) >= 8:
+		return binary.BigEndian.Uint64(data), data[8:], nil
+	default:
+		return 0, nil, errCBOR
+	}
+}
+
+func decodeCBORSimple(info byte, data []byte) (interface{}, []byte, error) {
+	switch {
+	case info == 20:
+		return false, data, nil
+	case info == 21:
+		return true, data, nil
+	case info == 22 || info == 23:
+		return nil, data, nil
+	case info == 26 && len(data) >= 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), data[4:], nil
+	case info == 27 && len(data) >= 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), data[8:], nil
+	default:
+		return nil, nil, errCBOR
+	}
+}
+
+// cborInt reads an integer map entry
+func cborInt(m map[interface{}]interface{}, key interface{}) (int64, bool) {
+	value, ok := m[key].(int64)
+	return value, ok
+}
+
+// cborBytes reads a byte string map entry
+func cborBytes(m map[interface{}]interface{}, key interface{}) ([]byte, bool) {
+	value, ok := m[key].([]byte)
+	return value, ok
+}
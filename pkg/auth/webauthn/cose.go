@@ -0,0 +1,158 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers
+const (
+	AlgES256 int64 = -7
+	AlgES384 int64 = -35
+	AlgES512 int64 = -36
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// SupportedAlgorithms are offered to authenticators, in order of preference
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256, AlgES384, AlgES512}
+
+// COSE key types and parameters
+const (
+	coseKeyType  = 1
+	coseKeyAlg   = 3
+	coseKeyCurve = -1 // n for RSA
+	coseKeyX     = -2 // e for RSA
+	coseKeyY     = -3
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+)
+
+// ErrUnsupportedKey is returned for credential keys of an unsupported type
+// or algorithm
+var ErrUnsupportedKey = errors.New("webauthn: unsupported credential key")
+
+// publicKey is a parsed COSE_Key
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// parsePublicKey parses a COSE_Key and returns the rest of the input
+func parsePublicKey(data []byte) (*publicKey, []byte, error) {
+	item, rest, err := decodeCBOR(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, nil, ErrUnsupportedKey
+	}
+
+	kty, _ := cborInt(m, int64(coseKeyType))
+	alg, _ := cborInt(m, int64(coseKeyAlg))
+
+	switch kty {
+	case coseKeyTypeEC2:
+		crv, _ := cborInt(m, int64(coseKeyCurve))
+		x, okX := cborBytes(m, int64(coseKeyX))
+		y, okY := cborBytes(m, int64(coseKeyY))
+		var curve elliptic.Curve
+		switch {
+		case crv == 1 && alg == AlgES256:
+			curve = elliptic.P256()
+		case crv == 2 && alg == AlgES384:
+			curve = elliptic.P384()
+		case crv == 3 && alg == AlgES512:
+			curve = elliptic.P521()
+		default:
+			return nil, nil, ErrUnsupportedKey
+		}
+		if !okX || !okY {
+			return nil, nil, ErrUnsupportedKey
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, nil, ErrUnsupportedKey
+		}
+		return &publicKey{alg: alg, key: key}, rest, nil
+
+	case coseKeyTypeOKP:
+		crv, _ := cborInt(m, int64(coseKeyCurve))
+		x, ok := cborBytes(m, int64(coseKeyX))
+		if crv != 6 || alg != AlgEdDSA || !ok || len(x) != ed25519.PublicKeySize {
+			return nil, nil, ErrUnsupportedKey
+		}
+		return &publicKey{alg: alg, key: ed25519.PublicKey(x)}, rest, nil
+
+	case coseKeyTypeRSA:
+		n, okN := cborBytes(m, int64(coseKeyCurve))
+		e, okE := cborBytes(m, int64(coseKeyX))
+		if alg != AlgRS256 || !okN || !okE || len(e) > 4 {
+			return nil, nil, ErrUnsupportedKey
+		}
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 | int(b)
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}
+		if key.N.BitLen() < 2048 {
+			return nil, nil, ErrUnsupportedKey
+		}
+		return &publicKey{alg: alg, key: key}, rest, nil
+	}
+
+	return nil, nil, ErrUnsupportedKey
+}
+
+// verify checks a signature over data
+func (k *publicKey) verify(data, signature []byte) error {
+	return verifySignature(k.key, k.alg, data, signature)
+}
+
+// verifySignature checks a WebAuthn signature: DER-encoded for ECDSA,
+// PKCS #1 v1.5 for RSA, raw for Ed25519
+func verifySignature(key crypto.PublicKey, alg int64, data, signature []byte) error {
+	valid := false
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		switch alg {
+		case AlgES256:
+			digest := sha256.Sum256(data)
+			valid = ecdsa.VerifyASN1(k, digest[:], signature)
+		case AlgES384:
+			digest := sha512.Sum384(data)
+			valid = ecdsa.VerifyASN1(k, digest[:], signature)
+		case AlgES512:
+			digest := sha512.Sum512(data)
+			valid = ecdsa.VerifyASN1(k, digest[:], signature)
+		default:
+			return fmt.Errorf("%w: algorithm %d for an EC key", ErrUnsupportedKey, alg)
+		}
+	case ed25519.PublicKey:
+		valid = alg == AlgEdDSA && ed25519.Verify(k, data, signature)
+	case *rsa.PublicKey:
+		if alg != AlgRS256 {
+			return fmt.Errorf("%w: algorithm %d for an RSA key", ErrUnsupportedKey, alg)
+		}
+		digest := sha256.Sum256(data)
+		valid = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return ErrUnsupportedKey
+	}
+
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
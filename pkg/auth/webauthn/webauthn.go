@@ -0,0 +1,604 @@
+// Package webauthn implements the relying party side of WebAuthn passkey
+// registration and login ceremonies, without third-party dependencies.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Ceremony errors
+var (
+	ErrInvalidResponse      = errors.New("webauthn: invalid credential response")
+	ErrInvalidSignature     = errors.New("webauthn: invalid signature")
+	ErrChallengeMismatch    = errors.New("webauthn: challenge does not match")
+	ErrOriginMismatch       = errors.New("webauthn: origin is not allowed")
+	ErrRPIDMismatch         = errors.New("webauthn: relying party ID does not match")
+	ErrUserNotPresent       = errors.New("webauthn: user presence was not confirmed")
+	ErrUserNotVerified      = errors.New("webauthn: user verification is required")
+	ErrSessionExpired       = errors.New("webauthn: ceremony has expired")
+	ErrCredentialNotAllowed = errors.New("webauthn: credential is not allowed for this login")
+	ErrCloneDetected        = errors.New("webauthn: signature counter went backwards; the authenticator may be cloned")
+	ErrInvalidAttestation   = errors.New("webauthn: invalid attestation statement")
+)
+
+// User verification requirements
+const (
+	VerificationRequired    = "required"
+	VerificationPreferred   = "preferred"
+	VerificationDiscouraged = "discouraged"
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagBackupState    = 0x10
+	flagAttestedData   = 0x40
+	flagExtensionData  = 0x80
+)
+
+// Config configures a RelyingParty
+type Config struct {
+	RPID             string   // Domain the passkeys are scoped to, e.g. "example.com"
+	RPName           string   // Shown by the authenticator
+	Origins          []string // Allowed origins, e.g. "https://app.example.com"
+	Timeout          time.Duration
+	UserVerification string // required, preferred or discouraged
+}
+
+// DefaultConfig returns the default configuration, for local development
+func DefaultConfig() Config {
+	return Config{
+		RPID:             "localhost",
+		RPName:           "NeonEx",
+		Origins:          []string{"http://localhost:3000"},
+		Timeout:          5 * time.Minute,
+		UserVerification: VerificationPreferred,
+	}
+}
+
+// LoadConfig loads WebAuthn configuration from environment
+func LoadConfig() Config {
+	config := DefaultConfig()
+
+	if rpID := os.Getenv("WEBAUTHN_RP_ID"); rpID != "" {
+		config.RPID = rpID
+	}
+	if rpName := os.Getenv("WEBAUTHN_RP_NAME"); rpName != "" {
+		config.RPName = rpName
+	}
+	if origins := os.Getenv("WEBAUTHN_ORIGINS"); origins != "" {
+		config.Origins = nil
+		for _, origin := range strings.Split(origins, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				config.Origins = append(config.Origins, strings.TrimSuffix(origin, "/"))
+			}
+		}
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("WEBAUTHN_TIMEOUT")); err == nil && timeout > 0 {
+		config.Timeout = timeout
+	}
+	switch uv := os.Getenv("WEBAUTHN_USER_VERIFICATION"); uv {
+	case VerificationRequired, VerificationPreferred, VerificationDiscouraged:
+		config.UserVerification = uv
+	}
+
+	return config
+}
+
+// URLEncodedBytes is binary data encoded as unpadded base64url in JSON, as
+// browsers' PublicKeyCredential.toJSON() does. Padded and standard
+// encodings are accepted when decoding.
+type URLEncodedBytes []byte
+
+// MarshalJSON encodes the bytes as base64url
+func (b URLEncodedBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// UnmarshalJSON decodes base64url or standard base64
+func (b *URLEncodedBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := DecodeBase64(s)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// DecodeBase64 decodes base64url or standard base64, padded or not
+func DecodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	s = strings.NewReplacer("+", "-", "/", "_").Replace(s)
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// EncodeBase64 encodes bytes as unpadded base64url, the form used for
+// credential IDs
+func EncodeBase64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// User is the account a passkey is registered for
+type User struct {
+	ID          []byte // Opaque user handle, at most 64 bytes and free of personal data
+	Name        string // e.g. an email address
+	DisplayName string
+}
+
+// Credential is a registered passkey, as stored by the application
+type Credential struct {
+	ID                []byte
+	PublicKey         []byte // COSE_Key
+	SignCount         uint32
+	AAGUID            []byte // Authenticator model
+	Transports        []string
+	AttestationFormat string
+	UserVerified      bool
+	BackupEligible    bool // Multi-device passkey, e.g. synced by a password manager
+	BackupState       bool // Currently backed up
+}
+
+// Session is the server-side state of a ceremony, kept by the caller
+// between Begin and Finish
+type Session struct {
+	Challenge          string   `json:"challenge"` // base64url
+	UserID             []byte   `json:"user_id,omitempty"`
+	AllowedCredentials [][]byte `json:"allowed_credentials,omitempty"`
+	UserVerification   string   `json:"user_verification"`
+	ExpiresAt          int64    `json:"expires_at"` // Unix seconds
+}
+
+// RelyingParty runs registration and login ceremonies
+type RelyingParty struct {
+	config Config
+}
+
+// New creates a relying party
+func New(config Config) *RelyingParty {
+	defaults := DefaultConfig()
+	if config.RPID == "" {
+		config.RPID = defaults.RPID
+	}
+	if config.RPName == "" {
+		config.RPName = config.RPID
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.UserVerification == "" {
+		config.UserVerification = defaults.UserVerification
+	}
+	return &RelyingParty{config: config}
+}
+
+// Config returns the relying party configuration
+func (rp *RelyingParty) Config() Config {
+	return rp.config
+}
+
+// RPEntity identifies the relying party to the authenticator
+type RPEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity identifies the user to the authenticator
+type UserEntity struct {
+	ID          URLEncodedBytes `json:"id"`
+	Name        string          `json:"name"`
+	DisplayName string          `json:"displayName"`
+}
+
+// CredentialParameter is an acceptable credential algorithm
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// CredentialDescriptor references an existing credential
+type CredentialDescriptor struct {
+	Type       string          `json:"type"`
+	ID         URLEncodedBytes `json:"id"`
+	Transports []string        `json:"transports,omitempty"`
+}
+
+// AuthenticatorSelection states authenticator requirements
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	RequireResident  bool   `json:"requireResidentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions are passed to navigator.credentials.create() as publicKey
+type CreationOptions struct {
+	Challenge              URLEncodedBytes        `json:"challenge"`
+	RP                     RPEntity               `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"` // Milliseconds
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are passed to navigator.credentials.get() as publicKey
+type RequestOptions struct {
+	Challenge        URLEncodedBytes        `json:"challenge"`
+	Timeout          int64                  `json:"timeout"` // Milliseconds
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// RegistrationResponse is the JSON form of the credential returned by
+// navigator.credentials.create()
+type RegistrationResponse struct {
+	ID       string          `json:"id"`
+	RawID    URLEncodedBytes `json:"rawId"`
+	Type     string          `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+		AttestationObject URLEncodedBytes `json:"attestationObject"`
+		Transports        []string        `json:"transports,omitempty"`
+	} `json:"response"`
+}
+
+// LoginResponse is the JSON form of the credential returned by
+// navigator.credentials.get()
+type LoginResponse struct {
+	ID       string          `json:"id"`
+	RawID    URLEncodedBytes `json:"rawId"`
+	Type     string          `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+		AuthenticatorData URLEncodedBytes `json:"authenticatorData"`
+		Signature         URLEncodedBytes `json:"signature"`
+		UserHandle        URLEncodedBytes `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+// CredentialID returns the raw credential ID, falling back to the
+// base64url id when rawId is missing
+func (r *LoginResponse) CredentialID() []byte {
+	if len(r.RawID) > 0 {
+		return r.RawID
+	}
+	id, _ := DecodeBase64(r.ID)
+	return id
+}
+
+// BeginRegistration starts registering a passkey for a user. Existing
+// credentials are excluded so an authenticator is not registered twice.
+func (rp *RelyingParty) BeginRegistration(user User, existing []Credential) (*CreationOptions, *Session, error) {
+	if len(user.ID) == 0 || len(user.ID) > 64 {
+		return nil, nil, fmt.Errorf("webauthn: user handle must be 1 to 64 bytes")
+	}
+
+	challenge, err := newChallenge()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	displayName := user.DisplayName
+	if displayName == "" {
+		displayName = user.Name
+	}
+
+	options := &CreationOptions{
+		Challenge: challenge,
+		RP:        RPEntity{ID: rp.config.RPID, Name: rp.config.RPName},
+		User:      UserEntity{ID: user.ID, Name: user.Name, DisplayName: displayName},
+		Timeout:   rp.config.Timeout.Milliseconds(),
+		AuthenticatorSelection: AuthenticatorSelection{
+			// Passkeys are discoverable credentials, so login needs no username
+			ResidentKey:      "required",
+			RequireResident:  true,
+			UserVerification: rp.config.UserVerification,
+		},
+		Attestation: "none",
+	}
+	for _, alg := range SupportedAlgorithms {
+		options.PubKeyCredParams = append(options.PubKeyCredParams, CredentialParameter{Type: "public-key", Alg: alg})
+	}
+	for _, credential := range existing {
+		options.ExcludeCredentials = append(options.ExcludeCredentials, CredentialDescriptor{
+			Type:       "public-key",
+			ID:         credential.ID,
+			Transports: credential.Transports,
+		})
+	}
+
+	return options, rp.newSession(challenge, user.ID, nil), nil
+}
+
+// FinishRegistration verifies the authenticator's attestation and returns
+// the credential to store
+func (rp *RelyingParty) FinishRegistration(session *Session, response *RegistrationResponse) (*Credential, error) {
+	if err := rp.checkSession(session); err != nil {
+		return nil, err
+	}
+	if response.Type != "public-key" {
+		return nil, ErrInvalidResponse
+	}
+	if err := rp.checkClientData(response.Response.ClientDataJSON, "webauthn.create", session); err != nil {
+		return nil, err
+	}
+
+	item, _, err := decodeCBOR(response.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation object: %v", ErrInvalidResponse, err)
+	}
+	attestation, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+	format, _ := attestation["fmt"].(string)
+	statement, _ := attestation["attStmt"].(map[interface{}]interface{})
+	rawAuthData, ok := cborBytes(attestation, "authData")
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+
+	authData, err := rp.parseAuthenticatorData(rawAuthData, session)
+	if err != nil {
+		return nil, err
+	}
+	if authData.flags&flagAttestedData == 0 || authData.credential == nil {
+		return nil, fmt.Errorf("%w: no attested credential data", ErrInvalidResponse)
+	}
+	if len(response.RawID) > 0 && !bytes.Equal(response.RawID, authData.credentialID) {
+		return nil, fmt.Errorf("%w: credential ID does not match", ErrInvalidResponse)
+	}
+
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	if err := verifyAttestation(format, statement, rawAuthData, clientDataHash[:], authData); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:                authData.credentialID,
+		PublicKey:         authData.rawPublicKey,
+		SignCount:         authData.signCount,
+		AAGUID:            authData.aaguid,
+		Transports:        response.Response.Transports,
+		AttestationFormat: format,
+		UserVerified:      authData.flags&flagUserVerified != 0,
+		BackupEligible:    authData.flags&flagBackupEligible != 0,
+		BackupState:       authData.flags&flagBackupState != 0,
+	}, nil
+}
+
+// BeginLogin starts a login. With no credentials, any discoverable passkey
+// for the relying party may be used and the user is identified by it.
+func (rp *RelyingParty) BeginLogin(allowed []Credential) (*RequestOptions, *Session, error) {
+	challenge, err := newChallenge()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	options := &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.config.Timeout.Milliseconds(),
+		RPID:             rp.config.RPID,
+		UserVerification: rp.config.UserVerification,
+	}
+	var ids [][]byte
+	for _, credential := range allowed {
+		options.AllowCredentials = append(options.AllowCredentials, CredentialDescriptor{
+			Type:       "public-key",
+			ID:         credential.ID,
+			Transports: credential.Transports,
+		})
+		ids = append(ids, credential.ID)
+	}
+
+	return options, rp.newSession(challenge, nil, ids), nil
+}
+
+// FinishLogin verifies an assertion against the stored credential it names
+// and returns the credential with its updated counter and backup state,
+// which the caller should save
+func (rp *RelyingParty) FinishLogin(session *Session, stored *Credential, response *LoginResponse) (*Credential, error) {
+	if err := rp.checkSession(session); err != nil {
+		return nil, err
+	}
+	if response.Type != "public-key" {
+		return nil, ErrInvalidResponse
+	}
+	if !bytes.Equal(response.CredentialID(), stored.ID) {
+		return nil, ErrCredentialNotAllowed
+	}
+	if len(session.AllowedCredentials) > 0 {
+		allowed := false
+		for _, id := range session.AllowedCredentials {
+			if bytes.Equal(id, stored.ID) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, ErrCredentialNotAllowed
+		}
+	}
+	if err := rp.checkClientData(response.Response.ClientDataJSON, "webauthn.get", session); err != nil {
+		return nil, err
+	}
+
+	authData, err := rp.parseAuthenticatorData(response.Response.AuthenticatorData, session)
+	if err != nil {
+		return nil, err
+	}
+
+	key, _, err := parsePublicKey(stored.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), response.Response.AuthenticatorData...), clientDataHash[:]...)
+	if err := key.verify(signed, response.Response.Signature); err != nil {
+		return nil, err
+	}
+
+	// Authenticators that keep a counter must increase it on every use
+	if (authData.signCount != 0 || stored.SignCount != 0) && authData.signCount <= stored.SignCount {
+		return nil, ErrCloneDetected
+	}
+
+	updated := *stored
+	updated.SignCount = authData.signCount
+	updated.UserVerified = authData.flags&flagUserVerified != 0
+	updated.BackupState = authData.flags&flagBackupState != 0
+	return &updated, nil
+}
+
+func (rp *RelyingParty) newSession(challenge []byte, userID []byte, allowed [][]byte) *Session {
+	return &Session{
+		Challenge:          EncodeBase64(challenge),
+		UserID:             userID,
+		AllowedCredentials: allowed,
+		UserVerification:   rp.config.UserVerification,
+		ExpiresAt:          time.Now().Add(rp.config.Timeout).Unix(),
+	}
+}
+
+func (rp *RelyingParty) checkSession(session *Session) error {
+	if session == nil || session.Challenge == "" {
+		return ErrChallengeMismatch
+	}
+	if time.Now().Unix() > session.ExpiresAt {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// clientData is the collected client data signed by the authenticator
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// checkClientData checks the ceremony type, challenge and origin
+func (rp *RelyingParty) checkClientData(raw []byte, ceremony string, session *Session) error {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("%w: client data: %v", ErrInvalidResponse, err)
+	}
+	if data.Type != ceremony {
+		return fmt.Errorf("%w: expected %s, got %q", ErrInvalidResponse, ceremony, data.Type)
+	}
+
+	challenge, err := DecodeBase64(data.Challenge)
+	if err != nil {
+		return ErrChallengeMismatch
+	}
+	expected, err := DecodeBase64(session.Challenge)
+	if err != nil || !bytes.Equal(challenge, expected) {
+		return ErrChallengeMismatch
+	}
+
+	if data.CrossOrigin {
+		return ErrOriginMismatch
+	}
+	for _, origin := range rp.config.Origins {
+		if data.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrOriginMismatch, data.Origin)
+}
+
+// authenticatorData is parsed authenticator data
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	rawPublicKey []byte
+	credential   *publicKey
+}
+
+// parseAuthenticatorData parses authenticator data and checks the RP ID
+// hash and the presence and verification flags
+func (rp *RelyingParty) parseAuthenticatorData(data []byte, session *Session) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrInvalidResponse)
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.config.RPID))
+	if !bytes.Equal(data[:32], rpIDHash[:]) {
+		return nil, ErrRPIDMismatch
+	}
+
+	authData := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if authData.flags&flagUserPresent == 0 {
+		return nil, ErrUserNotPresent
+	}
+	if session.UserVerification == VerificationRequired && authData.flags&flagUserVerified == 0 {
+		return nil, ErrUserNotVerified
+	}
+
+	rest := data[37:]
+	if authData.flags&flagAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrInvalidResponse)
+		}
+		authData.aaguid = append([]byte(nil), rest[:16]...)
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+			return nil, fmt.Errorf("%w: invalid credential ID", ErrInvalidResponse)
+		}
+		authData.credentialID = append([]byte(nil), rest[:idLength]...)
+		rest = rest[idLength:]
+
+		key, after, err := parsePublicKey(rest)
+		if err != nil {
+			return nil, err
+		}
+		authData.credential = key
+		authData.rawPublicKey = append([]byte(nil), rest[:len(rest)-len(after)]...)
+		rest = after
+	}
+	if authData.flags&flagExtensionData != 0 {
+		var err error
+		if _, rest, err = decodeCBOR(rest); err != nil {
+			return nil, fmt.Errorf("%w: extensions: %v", ErrInvalidResponse, err)
+		}
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing authenticator data", ErrInvalidResponse)
+	}
+
+	return authData, nil
+}
+
+func newChallenge() ([]byte, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
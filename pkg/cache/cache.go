@@ -10,8 +10,9 @@ type Cache interface {
 	// Get retrieves a value from the cache
 	Get(ctx context.Context, key string) (interface{}, error)
 	
-	// Set stores a value in the cache with TTL
-	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	// Set stores a value in the cache with TTL. Pass WithTags to attach
+	// tags for group invalidation.
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration, opts ...SetOption) error
 	
 	// Delete removes a value from the cache
 	Delete(ctx context.Context, key string) error
@@ -46,10 +47,45 @@ type Cache interface {
 	// DeleteMulti removes multiple values
 	DeleteMulti(ctx context.Context, keys []string) error
 	
+	// InvalidateTag removes every value tagged with tag
+	InvalidateTag(ctx context.Context, tag string) error
+	
 	// Close closes the cache connection
 	Close() error
 }
 
+// SetOptions holds optional Set parameters
+type SetOptions struct {
+	Tags []string
+}
+
+// SetOption configures a Set call
+type SetOption func(*SetOptions)
+
+// WithTags attaches tags to a cached value, e.g. WithTags("user:1", "users").
+// InvalidateTag removes every value carrying the tag.
+func WithTags(tags ...string) SetOption {
+	return func(o *SetOptions) {
+		o.Tags = append(o.Tags, tags...)
+	}
+}
+
+// applySetOptions collects Set options
+func applySetOptions(opts []SetOption) SetOptions {
+	var options SetOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// tagLister is implemented by caches that can list the keys of a tag. The
+// multi-tier cache uses it to invalidate values promoted into tiers that
+// never saw their tags.
+type tagLister interface {
+	tagKeys(ctx context.Context, tag string) ([]string, error)
+}
+
 // Stats represents cache statistics
 type Stats struct {
 	Hits        uint64
@@ -58,6 +94,17 @@ type Stats struct {
 	Memory      uint64 // bytes
 	Evictions   uint64
 	Connections uint64
+
+	// Loader statistics (see Loader)
+	Loads              uint64 // Loader calls that reached the source
+	StampedesPrevented uint64 // Callers served by another caller's load or refresh
+	StaleHits          uint64 // Stale values served while refreshing
+
+	// Multi-tier policy statistics (see MultiTierConfig)
+	WriteBehindPending  uint64 // Keys queued for the lower tiers
+	WriteBehindFailures uint64 // Queued writes dropped after all retries
+	Refreshes           uint64 // Successful refresh-ahead reloads
+	RefreshFailures     uint64
 }
 
 // StatsProvider provides cache statistics
@@ -65,6 +112,32 @@ type StatsProvider interface {
 	Stats(ctx context.Context) (*Stats, error)
 }
 
+// Scanner is implemented by caches that can decode a value straight into a
+// typed destination
+type Scanner interface {
+	Scan(ctx context.Context, key string, dest interface{}) error
+}
+
+// GetInto retrieves a value into dest, which must be a non-nil pointer.
+// Unlike Get it preserves struct types across serializing caches:
+//
+//	var user User
+//	err := cache.GetInto(ctx, c, "user:1", &user)
+func GetInto(ctx context.Context, c Cache, key string, dest interface{}) error {
+	if s, ok := c.(Scanner); ok {
+		return s.Scan(ctx, key, dest)
+	}
+
+	value, err := c.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := assignValue(dest, value); err != nil {
+		return &CacheError{Op: "scan", Key: key, Err: err}
+	}
+	return nil
+}
+
 // CacheError represents a cache error
 type CacheError struct {
 	Op  string
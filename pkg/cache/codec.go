@@ -0,0 +1,271 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Codec serializes cache values. Unmarshal receives either a pointer to a
+// typed destination or a *interface{} for untyped reads.
+type Codec interface {
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes values as JSON. Untyped reads return maps, slices,
+// float64s and strings.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string { return "json" }
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// MsgpackCodec encodes values as MessagePack. Struct fields use their json
+// tags so models serialize the same way as with JSONCodec.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Name() string { return "msgpack" }
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	dec.UseLooseInterfaceDecoding(true)
+	return dec.Decode(v)
+}
+
+// GobCodec encodes values with encoding/gob as interface values, so untyped
+// reads return the original concrete type. Types other than the Go
+// built-ins must be registered with gob.Register.
+type GobCodec struct{}
+
+func (GobCodec) Name() string { return "gob" }
+
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	var value interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return err
+	}
+	return assignValue(v, value)
+}
+
+// ProtobufCodec encodes proto.Message values wrapped in an Any, so untyped
+// reads return the original message type. Message types must be linked
+// into the binary; other values are rejected.
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) Name() string { return "protobuf" }
+
+func (ProtobufCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf codec: %T is not a proto.Message", v)
+	}
+	wrapped, err := anypb.New(msg)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(wrapped)
+}
+
+func (ProtobufCodec) Unmarshal(data []byte, v interface{}) error {
+	var wrapped anypb.Any
+	if err := proto.Unmarshal(data, &wrapped); err != nil {
+		return err
+	}
+
+	if msg, ok := v.(proto.Message); ok {
+		return wrapped.UnmarshalTo(msg)
+	}
+	msg, err := wrapped.UnmarshalNew()
+	if err != nil {
+		return err
+	}
+	return assignValue(v, msg)
+}
+
+// Compression selects how large values are compressed
+type Compression byte
+
+const (
+	CompressionNone   Compression = 0
+	CompressionGzip   Compression = 1
+	CompressionSnappy Compression = 2
+)
+
+// DefaultCompressionThreshold is the encoded size in bytes from which values
+// are compressed
+const DefaultCompressionThreshold = 1024
+
+// frameMagic starts every framed value. Plain JSON never begins with a NUL
+// byte, so values written before framing still decode.
+const frameMagic = 0x00
+
+// serializer turns values into stored bytes and back. Values are framed as
+// [frameMagic, compression, payload...] except uncompressed JSON, which is
+// stored as is so other clients and INCRBY keep working on it.
+type serializer struct {
+	codec       Codec
+	compression Compression
+	threshold   int
+}
+
+func newSerializer(codec Codec, compression Compression, threshold int) *serializer {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	if threshold <= 0 {
+		threshold = DefaultCompressionThreshold
+	}
+	return &serializer{codec: codec, compression: compression, threshold: threshold}
+}
+
+// encode serializes a value, compressing it above the threshold
+func (s *serializer) encode(v interface{}) ([]byte, error) {
+	data, err := s.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	compression := CompressionNone
+	if s.compression != CompressionNone && len(data) >= s.threshold {
+		compressed, err := compress(s.compression, data)
+		if err != nil {
+			return nil, err
+		}
+		// Keep the original when compression does not help
+		if len(compressed) < len(data) {
+			data, compression = compressed, s.compression
+		}
+	}
+
+	if _, plain := s.codec.(JSONCodec); plain && compression == CompressionNone {
+		return data, nil
+	}
+
+	framed := make([]byte, 0, len(data)+2)
+	framed = append(framed, frameMagic, byte(compression))
+	return append(framed, data...), nil
+}
+
+// decode deserializes stored bytes into v. framed reports whether the data
+// carried a frame header, i.e. whether it was written by encode.
+func (s *serializer) decode(data []byte, v interface{}) (framed bool, err error) {
+	if len(data) == 0 || data[0] != frameMagic {
+		return false, s.codec.Unmarshal(data, v)
+	}
+	if len(data) < 2 {
+		return true, fmt.Errorf("truncated frame")
+	}
+
+	payload, err := decompress(Compression(data[1]), data[2:])
+	if err != nil {
+		return true, err
+	}
+	return true, s.codec.Unmarshal(payload, v)
+}
+
+func compress(compression Compression, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionSnappy:
+		return s2.EncodeSnappy(nil, data), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %d", compression)
+	}
+}
+
+func decompress(compression Compression, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	case CompressionSnappy:
+		return s2.Decode(nil, data)
+	default:
+		return nil, fmt.Errorf("unknown compression %d", compression)
+	}
+}
+
+// assignValue stores value in the pointer dest. Values of a different type,
+// such as maps from untyped decoding, are converted through JSON.
+func assignValue(dest interface{}, value interface{}) error {
+	target := reflect.ValueOf(dest)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("destination must be a non-nil pointer, got %T", dest)
+	}
+	elem := target.Elem()
+
+	if value == nil {
+		elem.Set(reflect.Zero(elem.Type()))
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Type().AssignableTo(elem.Type()) {
+		elem.Set(v)
+		return nil
+	}
+	if v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Type().AssignableTo(elem.Type()) {
+		elem.Set(v.Elem())
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("cannot assign %T to %T: %w", value, dest, err)
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("cannot assign %T to %T: %w", value, dest, err)
+	}
+	return nil
+}
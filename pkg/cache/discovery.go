@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"neonexcore/pkg/servicemesh"
+)
+
+// PeerDiscovery lists the base URLs of a distributed cache's peers
+type PeerDiscovery interface {
+	Peers(ctx context.Context) ([]string, error)
+}
+
+// StaticPeers is a fixed peer list
+type StaticPeers []string
+
+// Peers returns the fixed peer list
+func (p StaticPeers) Peers(ctx context.Context) ([]string, error) {
+	return []string(p), nil
+}
+
+// ServiceMeshPeers discovers peers as the healthy instances of a service in
+// the service mesh registry. Each node registers itself under the service:
+//
+//	registry.Register(&servicemesh.ServiceInstance{
+//	    ServiceName: "cache",
+//	    Host:        "10.0.0.5",
+//	    Port:        8080,
+//	    Protocol:    "http",
+//	})
+type ServiceMeshPeers struct {
+	registry *servicemesh.ServiceRegistry
+	service  string
+}
+
+// NewServiceMeshPeers creates registry-backed peer discovery
+func NewServiceMeshPeers(registry *servicemesh.ServiceRegistry, service string) *ServiceMeshPeers {
+	return &ServiceMeshPeers{registry: registry, service: service}
+}
+
+// Peers returns the base URLs of the service's healthy instances
+func (p *ServiceMeshPeers) Peers(ctx context.Context) ([]string, error) {
+	instances := p.registry.GetServiceInstances(p.service)
+
+	peers := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		if instance.Health != servicemesh.HealthStatusHealthy {
+			continue
+		}
+		protocol := instance.Protocol
+		if protocol != "https" {
+			protocol = "http"
+		}
+		peers = append(peers, fmt.Sprintf("%s://%s:%d", protocol, instance.Host, instance.Port))
+	}
+	sort.Strings(peers)
+	return peers, nil
+}
@@ -0,0 +1,802 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Peer protocol headers
+const (
+	peerSecretHeader = "X-Cache-Secret"
+	peerTTLHeader    = "X-Cache-TTL" // Remaining TTL in milliseconds, 0 for none
+)
+
+// DistributedCache is a peer-to-peer in-memory cache in the style of
+// groupcache. Every node holds the keys the consistent hash ring assigns
+// it and forwards other keys to their owner over HTTP, so a cluster pools
+// its memory without a separate cache server. It works well as the L2 tier
+// under a per-node memory L1.
+//
+// Each node must serve Handler at BasePath on its Self URL, and all nodes
+// must share the codec and compression settings.
+type DistributedCache struct {
+	config     DistributedCacheConfig
+	store      *MemoryCache // Keys this node owns, as encoded bytes
+	hot        *MemoryCache // Values recently fetched from peers, if enabled
+	serializer *serializer
+	client     *http.Client
+
+	ring   atomic.Pointer[HashRing]
+	incrMu sync.Mutex
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	closeOnce sync.Once
+	closeChan chan struct{}
+}
+
+// DistributedCacheConfig configures the distributed cache
+type DistributedCacheConfig struct {
+	Config
+	Self            string        // This node's base URL as peers reach it, e.g. http://10.0.0.5:8080
+	BasePath        string        // Path Handler is mounted on
+	Discovery       PeerDiscovery // Source of peer base URLs; Self is always a peer
+	RefreshInterval time.Duration // How often peers are rediscovered
+	Replicas        int           // Virtual nodes per peer on the hash ring
+	Secret          string        // Shared secret peers authenticate with
+	MaxSize         int           // Maximum number of items held by this node
+	HotTTL          time.Duration // How long values fetched from peers are kept locally, 0 disables
+
+	// Value serialization, as for RedisCacheConfig
+	Codec                Codec
+	Compression          Compression
+	CompressionThreshold int
+}
+
+// DefaultDistributedCacheConfig returns the default distributed cache configuration
+func DefaultDistributedCacheConfig() DistributedCacheConfig {
+	config := DefaultConfig()
+	config.Timeout = 2 * time.Second
+
+	return DistributedCacheConfig{
+		Config:          config,
+		BasePath:        "/_cache",
+		RefreshInterval: 10 * time.Second,
+		Replicas:        DefaultHashRingReplicas,
+		MaxSize:         10000,
+
+		Codec:                JSONCodec{},
+		Compression:          CompressionNone,
+		CompressionThreshold: DefaultCompressionThreshold,
+	}
+}
+
+// NewDistributedCache creates a distributed cache node and discovers its
+// peers. Without Discovery the node runs alone.
+func NewDistributedCache(config DistributedCacheConfig) (*DistributedCache, error) {
+	if config.Self == "" {
+		return nil, &CacheError{Op: "connect", Err: fmt.Errorf("distributed cache requires Self")}
+	}
+	config.Self = strings.TrimRight(config.Self, "/")
+	config.BasePath = "/" + strings.Trim(config.BasePath, "/")
+
+	// Expiry is applied by the node, never defaulted by its stores
+	storeConfig := DefaultMemoryCacheConfig()
+	storeConfig.DefaultTTL = 0
+	storeConfig.MaxSize = config.MaxSize
+
+	dc := &DistributedCache{
+		config:     config,
+		store:      NewMemoryCache(storeConfig),
+		serializer: newSerializer(config.Codec, config.Compression, config.CompressionThreshold),
+		client:     &http.Client{Timeout: config.Timeout},
+		closeChan:  make(chan struct{}),
+	}
+	if config.HotTTL > 0 {
+		hotConfig := storeConfig
+		hotConfig.MaxSize = config.MaxSize / 10
+		dc.hot = NewMemoryCache(hotConfig)
+	}
+
+	dc.ring.Store(NewHashRing(config.Replicas, config.Self))
+	if config.Discovery != nil {
+		if err := dc.Refresh(context.Background()); err != nil {
+			dc.Close()
+			return nil, &CacheError{Op: "connect", Err: err}
+		}
+		if config.RefreshInterval > 0 {
+			go dc.refreshLoop(config.RefreshInterval)
+		}
+	}
+
+	return dc, nil
+}
+
+// Refresh rediscovers peers and rebuilds the hash ring
+func (dc *DistributedCache) Refresh(ctx context.Context) error {
+	peers, err := dc.config.Discovery.Peers(ctx)
+	if err != nil {
+		return err
+	}
+
+	normalized := make([]string, 0, len(peers)+1)
+	normalized = append(normalized, dc.config.Self)
+	for _, peer := range peers {
+		normalized = append(normalized, strings.TrimRight(peer, "/"))
+	}
+	dc.ring.Store(NewHashRing(dc.config.Replicas, normalized...))
+	return nil
+}
+
+func (dc *DistributedCache) refreshLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Keep the last known ring if discovery fails
+			dc.Refresh(context.Background())
+		case <-dc.closeChan:
+			return
+		}
+	}
+}
+
+// Peers returns the peers on the current hash ring
+func (dc *DistributedCache) Peers() []string {
+	return dc.ring.Load().Peers()
+}
+
+// owner returns the peer owning key, or "" when this node does
+func (dc *DistributedCache) owner(key string) string {
+	peer := dc.ring.Load().Get(key)
+	if peer == dc.config.Self {
+		return ""
+	}
+	return peer
+}
+
+// Get retrieves a value from the cache
+func (dc *DistributedCache) Get(ctx context.Context, key string) (interface{}, error) {
+	data, err := dc.fetch(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	if _, err := dc.serializer.decode(data, &result); err != nil {
+		return nil, &CacheError{Op: "decode", Key: key, Err: err}
+	}
+	return result, nil
+}
+
+// Scan retrieves a value into dest, which must be a non-nil pointer
+func (dc *DistributedCache) Scan(ctx context.Context, key string, dest interface{}) error {
+	data, err := dc.fetch(ctx, key)
+	if err != nil {
+		return err
+	}
+	if _, err := dc.serializer.decode(data, dest); err != nil {
+		return &CacheError{Op: "decode", Key: key, Err: err}
+	}
+	return nil
+}
+
+// fetch returns a key's encoded value from its owner
+func (dc *DistributedCache) fetch(ctx context.Context, key string) ([]byte, error) {
+	peer := dc.owner(key)
+
+	var data []byte
+	var ttl time.Duration
+	var err error
+	switch {
+	case peer == "":
+		data, err = dc.localGet(ctx, key)
+	case dc.hot != nil:
+		if value, hotErr := dc.hot.Get(ctx, key); hotErr == nil {
+			dc.hits.Add(1)
+			return value.([]byte), nil
+		}
+		fallthrough
+	default:
+		data, ttl, err = dc.remoteGet(ctx, peer, key)
+	}
+
+	if err == ErrKeyNotFound {
+		dc.misses.Add(1)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dc.hits.Add(1)
+	if peer != "" && dc.hot != nil {
+		hotTTL := dc.config.HotTTL
+		if ttl > 0 && ttl < hotTTL {
+			hotTTL = ttl
+		}
+		dc.hot.Set(ctx, key, data, hotTTL)
+	}
+	return data, nil
+}
+
+// Set stores a value in the cache with TTL
+func (dc *DistributedCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration, opts ...SetOption) error {
+	if ttl == 0 {
+		ttl = dc.config.DefaultTTL
+	}
+
+	data, err := dc.serializer.encode(value)
+	if err != nil {
+		return &CacheError{Op: "set", Key: key, Err: err}
+	}
+
+	dc.forgetHot(ctx, key)
+	tags := applySetOptions(opts).Tags
+	if peer := dc.owner(key); peer != "" {
+		query := url.Values{"ttl": {strconv.FormatInt(ttl.Milliseconds(), 10)}, "tag": tags}
+		_, err := dc.call(ctx, peer, http.MethodPut, "/v/"+url.PathEscape(key), query, data)
+		if err != nil {
+			return &CacheError{Op: "set", Key: key, Err: err}
+		}
+		return nil
+	}
+	return dc.store.Set(ctx, key, data, ttl, WithTags(tags...))
+}
+
+// Delete removes a value from the cache
+func (dc *DistributedCache) Delete(ctx context.Context, key string) error {
+	dc.forgetHot(ctx, key)
+	if peer := dc.owner(key); peer != "" {
+		if _, err := dc.call(ctx, peer, http.MethodDelete, "/v/"+url.PathEscape(key), nil, nil); err != nil {
+			return &CacheError{Op: "delete", Key: key, Err: err}
+		}
+		return nil
+	}
+	return dc.store.Delete(ctx, key)
+}
+
+// Exists checks if a key exists in the cache
+func (dc *DistributedCache) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := dc.TTL(ctx, key)
+	if err == ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Clear removes all values from every node
+func (dc *DistributedCache) Clear(ctx context.Context) error {
+	if dc.hot != nil {
+		dc.hot.Clear(ctx)
+	}
+	if err := dc.store.Clear(ctx); err != nil {
+		return err
+	}
+	return dc.broadcast(ctx, "clear", http.MethodPost, "/clear", nil)
+}
+
+// Keys returns all keys matching the pattern across every node
+func (dc *DistributedCache) Keys(ctx context.Context, pattern string) ([]string, error) {
+	keys, err := dc.store.Keys(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, peer := range dc.Peers() {
+		if peer == dc.config.Self {
+			continue
+		}
+		body, err := dc.call(ctx, peer, http.MethodGet, "/keys", url.Values{"pattern": {pattern}}, nil)
+		if err != nil {
+			return nil, &CacheError{Op: "keys", Err: err}
+		}
+		var peerKeys []string
+		if err := json.Unmarshal(body, &peerKeys); err != nil {
+			return nil, &CacheError{Op: "keys", Err: err}
+		}
+		keys = append(keys, peerKeys...)
+	}
+	return keys, nil
+}
+
+// TTL returns the remaining time to live for a key
+func (dc *DistributedCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	peer := dc.owner(key)
+	if peer == "" {
+		return dc.store.TTL(ctx, key)
+	}
+
+	req, err := dc.request(ctx, peer, http.MethodHead, "/v/"+url.PathEscape(key), nil, nil)
+	if err != nil {
+		return 0, &CacheError{Op: "ttl", Key: key, Err: err}
+	}
+	resp, err := dc.client.Do(req)
+	if err != nil {
+		return 0, &CacheError{Op: "ttl", Key: key, Err: err}
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return parseTTLHeader(resp.Header), nil
+	case http.StatusNotFound:
+		return 0, ErrKeyNotFound
+	default:
+		return 0, &CacheError{Op: "ttl", Key: key, Err: fmt.Errorf("peer %s responded with status %d", peer, resp.StatusCode)}
+	}
+}
+
+// Expire sets a new TTL for a key
+func (dc *DistributedCache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	dc.forgetHot(ctx, key)
+	if peer := dc.owner(key); peer != "" {
+		query := url.Values{"ttl": {strconv.FormatInt(ttl.Milliseconds(), 10)}}
+		if _, err := dc.call(ctx, peer, http.MethodPost, "/expire/"+url.PathEscape(key), query, nil); err != nil {
+			if err == ErrKeyNotFound {
+				return err
+			}
+			return &CacheError{Op: "expire", Key: key, Err: err}
+		}
+		return nil
+	}
+	return dc.store.Expire(ctx, key, ttl)
+}
+
+// Increment atomically increments a counter on its owner
+func (dc *DistributedCache) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	dc.forgetHot(ctx, key)
+	if peer := dc.owner(key); peer != "" {
+		query := url.Values{"delta": {strconv.FormatInt(delta, 10)}}
+		body, err := dc.call(ctx, peer, http.MethodPost, "/incr/"+url.PathEscape(key), query, nil)
+		if err != nil {
+			return 0, &CacheError{Op: "increment", Key: key, Err: err}
+		}
+		val, err := strconv.ParseInt(string(body), 10, 64)
+		if err != nil {
+			return 0, &CacheError{Op: "increment", Key: key, Err: err}
+		}
+		return val, nil
+	}
+	return dc.localIncrement(ctx, key, delta)
+}
+
+// Decrement atomically decrements a counter on its owner
+func (dc *DistributedCache) Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	return dc.Increment(ctx, key, -delta)
+}
+
+// GetMulti retrieves multiple values, batching requests per owner
+func (dc *DistributedCache) GetMulti(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	byPeer := make(map[string][]string)
+	for _, key := range keys {
+		peer := dc.owner(key)
+		byPeer[peer] = append(byPeer[peer], key)
+	}
+
+	encoded := make(map[string][]byte, len(keys))
+	for peer, peerKeys := range byPeer {
+		if peer == "" {
+			for _, key := range peerKeys {
+				if data, err := dc.localGet(ctx, key); err == nil {
+					encoded[key] = data
+				}
+			}
+			continue
+		}
+
+		payload, _ := json.Marshal(peerKeys)
+		body, err := dc.call(ctx, peer, http.MethodPost, "/getmulti", nil, payload)
+		if err != nil {
+			return nil, &CacheError{Op: "getmulti", Err: err}
+		}
+		var values map[string][]byte
+		if err := json.Unmarshal(body, &values); err != nil {
+			return nil, &CacheError{Op: "getmulti", Err: err}
+		}
+		for key, data := range values {
+			encoded[key] = data
+		}
+	}
+
+	result := make(map[string]interface{}, len(encoded))
+	for key, data := range encoded {
+		var value interface{}
+		if _, err := dc.serializer.decode(data, &value); err == nil {
+			result[key] = value
+		}
+	}
+
+	dc.hits.Add(uint64(len(result)))
+	dc.misses.Add(uint64(len(keys) - len(result)))
+	return result, nil
+}
+
+// SetMulti stores multiple values
+func (dc *DistributedCache) SetMulti(ctx context.Context, items map[string]interface{}, ttl time.Duration) error {
+	for key, value := range items {
+		if err := dc.Set(ctx, key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMulti removes multiple values
+func (dc *DistributedCache) DeleteMulti(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if err := dc.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidateTag removes every value tagged with tag on every node. Tags
+// are tracked by the node owning each tagged key.
+func (dc *DistributedCache) InvalidateTag(ctx context.Context, tag string) error {
+	if dc.hot != nil {
+		dc.hot.Clear(ctx)
+	}
+	if err := dc.store.InvalidateTag(ctx, tag); err != nil {
+		return err
+	}
+	return dc.broadcast(ctx, "invalidate", http.MethodDelete, "/tags/"+url.PathEscape(tag), nil)
+}
+
+// tagKeys returns the keys tagged with tag on every node
+func (dc *DistributedCache) tagKeys(ctx context.Context, tag string) ([]string, error) {
+	keys, err := dc.store.tagKeys(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, peer := range dc.Peers() {
+		if peer == dc.config.Self {
+			continue
+		}
+		body, err := dc.call(ctx, peer, http.MethodGet, "/tags/"+url.PathEscape(tag), nil, nil)
+		if err != nil {
+			return nil, &CacheError{Op: "tag", Key: tag, Err: err}
+		}
+		var peerKeys []string
+		if err := json.Unmarshal(body, &peerKeys); err != nil {
+			return nil, &CacheError{Op: "tag", Key: tag, Err: err}
+		}
+		keys = append(keys, peerKeys...)
+	}
+	return keys, nil
+}
+
+// Stats returns this node's statistics. Keys and Memory count only the
+// keys this node owns.
+func (dc *DistributedCache) Stats(ctx context.Context) (*Stats, error) {
+	local, err := dc.store.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Stats{
+		Hits:        dc.hits.Load(),
+		Misses:      dc.misses.Load(),
+		Keys:        local.Keys,
+		Memory:      local.Memory,
+		Evictions:   local.Evictions,
+		Connections: uint64(len(dc.Peers())),
+	}, nil
+}
+
+// Close stops peer discovery and releases this node's memory
+func (dc *DistributedCache) Close() error {
+	dc.closeOnce.Do(func() {
+		close(dc.closeChan)
+		dc.store.Close()
+		if dc.hot != nil {
+			dc.hot.Close()
+		}
+	})
+	return nil
+}
+
+// ==================== Local operations ====================
+
+func (dc *DistributedCache) localGet(ctx context.Context, key string) ([]byte, error) {
+	value, err := dc.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := value.([]byte)
+	if !ok {
+		return nil, &CacheError{Op: "get", Key: key, Err: fmt.Errorf("unexpected stored type %T", value)}
+	}
+	return data, nil
+}
+
+// localIncrement adds delta to an owned counter, keeping its TTL
+func (dc *DistributedCache) localIncrement(ctx context.Context, key string, delta int64) (int64, error) {
+	dc.incrMu.Lock()
+	defer dc.incrMu.Unlock()
+
+	var current int64
+	var ttl time.Duration
+	data, err := dc.localGet(ctx, key)
+	switch {
+	case err == nil:
+		if _, err := dc.serializer.decode(data, &current); err != nil {
+			return 0, &CacheError{Op: "increment", Key: key, Err: err}
+		}
+		if ttl, err = dc.store.TTL(ctx, key); err != nil {
+			return 0, err
+		}
+	case err != ErrKeyNotFound:
+		return 0, err
+	}
+
+	current += delta
+	encoded, err := dc.serializer.encode(current)
+	if err != nil {
+		return 0, &CacheError{Op: "increment", Key: key, Err: err}
+	}
+	if err := dc.store.Set(ctx, key, encoded, ttl); err != nil {
+		return 0, err
+	}
+	return current, nil
+}
+
+func (dc *DistributedCache) forgetHot(ctx context.Context, key string) {
+	if dc.hot != nil {
+		dc.hot.Delete(ctx, key)
+	}
+}
+
+// ==================== Peer protocol ====================
+
+// remoteGet fetches an encoded value and its TTL from a peer
+func (dc *DistributedCache) remoteGet(ctx context.Context, peer, key string) ([]byte, time.Duration, error) {
+	req, err := dc.request(ctx, peer, http.MethodGet, "/v/"+url.PathEscape(key), nil, nil)
+	if err != nil {
+		return nil, 0, &CacheError{Op: "get", Key: key, Err: err}
+	}
+	resp, err := dc.client.Do(req)
+	if err != nil {
+		return nil, 0, &CacheError{Op: "get", Key: key, Err: err}
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, 0, &CacheError{Op: "get", Key: key, Err: err}
+		}
+		return data, parseTTLHeader(resp.Header), nil
+	case http.StatusNotFound:
+		return nil, 0, ErrKeyNotFound
+	default:
+		return nil, 0, &CacheError{Op: "get", Key: key, Err: fmt.Errorf("peer %s responded with status %d", peer, resp.StatusCode)}
+	}
+}
+
+// call performs a peer request, returning the body of a 2xx response.
+// A 404 is reported as ErrKeyNotFound.
+func (dc *DistributedCache) call(ctx context.Context, peer, method, path string, query url.Values, body []byte) ([]byte, error) {
+	req, err := dc.request(ctx, peer, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := dc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrKeyNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("peer %s responded with status %d: %s", peer, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+func (dc *DistributedCache) request(ctx context.Context, peer, method, path string, query url.Values, body []byte) (*http.Request, error) {
+	target := peer + dc.config.BasePath + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if dc.config.Secret != "" {
+		req.Header.Set(peerSecretHeader, dc.config.Secret)
+	}
+	return req, nil
+}
+
+// broadcast sends a request to every other peer, returning the first error
+func (dc *DistributedCache) broadcast(ctx context.Context, op, method, path string, query url.Values) error {
+	var firstErr error
+	for _, peer := range dc.Peers() {
+		if peer == dc.config.Self {
+			continue
+		}
+		if _, err := dc.call(ctx, peer, method, path, query, nil); err != nil && firstErr == nil {
+			firstErr = &CacheError{Op: op, Err: err}
+		}
+	}
+	return firstErr
+}
+
+func parseTTLHeader(header http.Header) time.Duration {
+	ms, _ := strconv.ParseInt(header.Get(peerTTLHeader), 10, 64)
+	return time.Duration(ms) * time.Millisecond
+}
+
+// Handler serves the peer protocol. Mount it at BasePath on every node,
+// e.g. with Fiber's adaptor:
+//
+//	app.All("/_cache/*", adaptor.HTTPHandler(dc.Handler()))
+//
+// Requests always act on this node's own store; they are never forwarded.
+func (dc *DistributedCache) Handler() http.Handler {
+	return http.HandlerFunc(dc.serveHTTP)
+}
+
+func (dc *DistributedCache) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if dc.config.Secret != "" && r.Header.Get(peerSecretHeader) != dc.config.Secret {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.EscapedPath(), dc.config.BasePath)
+	op, escaped, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	name, err := url.PathUnescape(escaped)
+	if err != nil {
+		http.Error(w, "invalid key", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	switch {
+	case op == "v" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		data, err := dc.localGet(ctx, name)
+		if err != nil {
+			peerError(w, err)
+			return
+		}
+		ttl, _ := dc.store.TTL(ctx, name)
+		w.Header().Set(peerTTLHeader, strconv.FormatInt(ttl.Milliseconds(), 10))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+
+	case op == "v" && r.Method == http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ms, _ := strconv.ParseInt(r.URL.Query().Get("ttl"), 10, 64)
+		if err := dc.store.Set(ctx, name, data, time.Duration(ms)*time.Millisecond, WithTags(r.URL.Query()["tag"]...)); err != nil {
+			peerError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case op == "v" && r.Method == http.MethodDelete:
+		if err := dc.store.Delete(ctx, name); err != nil {
+			peerError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case op == "incr" && r.Method == http.MethodPost:
+		delta, err := strconv.ParseInt(r.URL.Query().Get("delta"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid delta", http.StatusBadRequest)
+			return
+		}
+		val, err := dc.localIncrement(ctx, name, delta)
+		if err != nil {
+			peerError(w, err)
+			return
+		}
+		io.WriteString(w, strconv.FormatInt(val, 10))
+
+	case op == "expire" && r.Method == http.MethodPost:
+		ms, _ := strconv.ParseInt(r.URL.Query().Get("ttl"), 10, 64)
+		if err := dc.store.Expire(ctx, name, time.Duration(ms)*time.Millisecond); err != nil {
+			peerError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case op == "getmulti" && r.Method == http.MethodPost:
+		var keys []string
+		if err := json.NewDecoder(r.Body).Decode(&keys); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		values := make(map[string][]byte, len(keys))
+		for _, key := range keys {
+			if data, err := dc.localGet(ctx, key); err == nil {
+				values[key] = data
+			}
+		}
+		writePeerJSON(w, values)
+
+	case op == "keys" && r.Method == http.MethodGet:
+		keys, err := dc.store.Keys(ctx, r.URL.Query().Get("pattern"))
+		if err != nil {
+			peerError(w, err)
+			return
+		}
+		writePeerJSON(w, keys)
+
+	case op == "tags" && r.Method == http.MethodGet:
+		keys, err := dc.store.tagKeys(ctx, name)
+		if err != nil {
+			peerError(w, err)
+			return
+		}
+		writePeerJSON(w, keys)
+
+	case op == "tags" && r.Method == http.MethodDelete:
+		if dc.hot != nil {
+			dc.hot.Clear(ctx)
+		}
+		if err := dc.store.InvalidateTag(ctx, name); err != nil {
+			peerError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case op == "clear" && r.Method == http.MethodPost:
+		if dc.hot != nil {
+			dc.hot.Clear(ctx)
+		}
+		if err := dc.store.Clear(ctx); err != nil {
+			peerError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "unknown cache operation", http.StatusBadRequest)
+	}
+}
+
+func peerError(w http.ResponseWriter, err error) {
+	if err == ErrKeyNotFound {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func writePeerJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
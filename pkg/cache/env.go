@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NewFromEnv creates the cache selected by CACHE_DRIVER: memory (default),
+// redis (REDIS_URL, e.g. redis://:password@localhost:6379/0) or memcached
+// (MEMCACHED_SERVERS, comma separated)
+func NewFromEnv() (Cache, error) {
+	switch driver := os.Getenv("CACHE_DRIVER"); driver {
+	case "", "memory":
+		return NewMemoryCache(DefaultMemoryCacheConfig()), nil
+	case "redis":
+		config := DefaultRedisCacheConfig()
+		if url := os.Getenv("REDIS_URL"); url != "" {
+			options, err := redis.ParseURL(url)
+			if err != nil {
+				return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+			}
+			config.Addr, config.Password, config.DB = options.Addr, options.Password, options.DB
+		}
+		return NewRedisCache(config)
+	case "memcached":
+		config := DefaultMemcachedCacheConfig()
+		if servers := os.Getenv("MEMCACHED_SERVERS"); servers != "" {
+			config.Servers = nil
+			for _, server := range strings.Split(servers, ",") {
+				if server = strings.TrimSpace(server); server != "" {
+					config.Servers = append(config.Servers, server)
+				}
+			}
+		}
+		return NewMemcachedCache(config)
+	default:
+		return nil, fmt.Errorf("unknown cache driver: %s", driver)
+	}
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// DefaultHashRingReplicas is the number of virtual nodes per peer
+const DefaultHashRingReplicas = 100
+
+// HashRing assigns keys to peers by consistent hashing, so adding or
+// removing a peer only moves the keys that peer gains or loses. A ring is
+// immutable; build a new one when peers change.
+type HashRing struct {
+	hashes []uint32
+	owners map[uint32]string
+	peers  []string
+}
+
+// NewHashRing creates a ring with replicas virtual nodes per peer
+func NewHashRing(replicas int, peers ...string) *HashRing {
+	if replicas <= 0 {
+		replicas = DefaultHashRingReplicas
+	}
+
+	ring := &HashRing{owners: make(map[uint32]string, len(peers)*replicas)}
+	seen := make(map[string]bool)
+	for _, peer := range peers {
+		if peer == "" || seen[peer] {
+			continue
+		}
+		seen[peer] = true
+		ring.peers = append(ring.peers, peer)
+
+		for i := 0; i < replicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + peer))
+			if _, taken := ring.owners[hash]; taken {
+				continue
+			}
+			ring.owners[hash] = peer
+			ring.hashes = append(ring.hashes, hash)
+		}
+	}
+	sort.Strings(ring.peers)
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+	return ring
+}
+
+// Get returns the peer owning key, or "" for an empty ring
+func (r *HashRing) Get(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= hash })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
+
+// Peers returns the ring's peers, sorted
+func (r *HashRing) Peers() []string {
+	return append([]string(nil), r.peers...)
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"neonexcore/pkg/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LeaderElector elects one leader among the instances campaigning for the
+// same name, using a renewed Lock. Use it for work that must run on only
+// one instance at a time, such as scheduled jobs or workflow workers.
+//
+// Leadership is lost when the lock cannot be renewed before it expires;
+// work started in OnElected must stop when its context is cancelled.
+type LeaderElector struct {
+	lock   *Lock
+	name   string
+	config LeaderElectorConfig
+	leader atomic.Bool
+
+	mu      sync.Mutex
+	started bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// LeaderElectorConfig configures a leader elector
+type LeaderElectorConfig struct {
+	TTL           time.Duration // Lease length; a crashed leader is replaced after at most this long
+	RenewInterval time.Duration // How often the leader renews its lease
+	RetryInterval time.Duration // How often followers campaign
+
+	OnElected func(ctx context.Context) // Runs in its own goroutine; ctx ends when leadership is lost
+	OnRevoked func()                    // Called after leadership is lost or given up
+}
+
+// DefaultLeaderElectorConfig returns the default leader elector configuration
+func DefaultLeaderElectorConfig() LeaderElectorConfig {
+	return LeaderElectorConfig{
+		TTL:           15 * time.Second,
+		RenewInterval: 5 * time.Second,
+		RetryInterval: 5 * time.Second,
+	}
+}
+
+// NewLeaderElector creates an elector campaigning for name
+func NewLeaderElector(client redis.Cmdable, name string, config LeaderElectorConfig) *LeaderElector {
+	defaults := DefaultLeaderElectorConfig()
+	if config.TTL <= 0 {
+		config.TTL = defaults.TTL
+	}
+	if config.RenewInterval <= 0 || config.RenewInterval >= config.TTL {
+		config.RenewInterval = config.TTL / 3
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = config.RenewInterval
+	}
+
+	return &LeaderElector{
+		lock:   NewLock(client, "leader:"+name, config.TTL),
+		name:   name,
+		config: config,
+	}
+}
+
+// NewLeaderElector creates a leader elector stored in this Redis cache
+func (rc *RedisCache) NewLeaderElector(name string, config LeaderElectorConfig) *LeaderElector {
+	return NewLeaderElector(rc.client, name, config)
+}
+
+// IsLeader reports whether this instance currently holds leadership
+func (e *LeaderElector) IsLeader() bool {
+	return e.leader.Load() && e.lock.Held()
+}
+
+// Start campaigns in the background until Stop is called
+func (e *LeaderElector) Start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.started {
+		return
+	}
+	e.started = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.done = make(chan struct{})
+	go func(done chan struct{}) {
+		defer close(done)
+		e.Run(ctx)
+	}(e.done)
+}
+
+// Stop ends the campaign, giving up leadership if held
+func (e *LeaderElector) Stop() {
+	e.mu.Lock()
+	if !e.started {
+		e.mu.Unlock()
+		return
+	}
+	e.started = false
+	e.cancel()
+	done := e.done
+	e.mu.Unlock()
+
+	<-done
+}
+
+// Run campaigns until ctx is done, then gives up leadership if held
+func (e *LeaderElector) Run(ctx context.Context) {
+	var cancelLeader context.CancelFunc
+
+	for {
+		if cancelLeader == nil {
+			ok, err := e.lock.TryAcquire(ctx)
+			if err != nil && ctx.Err() == nil {
+				logger.Warn("Leader election failed", logger.Fields{"name": e.name, "error": err.Error()})
+			}
+			if ok {
+				cancelLeader = e.elected(ctx)
+			}
+		} else if err := e.lock.Renew(ctx); err != nil && ctx.Err() == nil {
+			// Ride out Redis errors while the lease is still valid
+			if errors.Is(err, ErrLockNotHeld) || !e.lock.Held() {
+				logger.Warn("Leadership lost", logger.Fields{"name": e.name, "error": err.Error()})
+				e.revoked(cancelLeader)
+				cancelLeader = nil
+			}
+		}
+
+		wait := e.config.RetryInterval
+		if cancelLeader != nil {
+			wait = e.config.RenewInterval
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			if cancelLeader != nil {
+				e.revoked(cancelLeader)
+				releaseCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+				e.lock.Release(releaseCtx)
+				cancel()
+			}
+			return
+		}
+	}
+}
+
+func (e *LeaderElector) elected(ctx context.Context) context.CancelFunc {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	e.leader.Store(true)
+	logger.Info("Elected leader", logger.Fields{"name": e.name})
+
+	if e.config.OnElected != nil {
+		go e.config.OnElected(leaderCtx)
+	}
+	return cancel
+}
+
+func (e *LeaderElector) revoked(cancel context.CancelFunc) {
+	cancel()
+	e.leader.Store(false)
+	if e.config.OnRevoked != nil {
+		e.config.OnRevoked()
+	}
+}
@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"context"
+	"encoding/gob"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// LoaderFunc loads a value from the source of truth on a cache miss
+type LoaderFunc func(ctx context.Context) (interface{}, error)
+
+// LoaderConfig configures a Loader
+type LoaderConfig struct {
+	// StaleTTL is how long a value may be served after its TTL while one
+	// goroutine refreshes it in the background. Zero disables
+	// stale-while-revalidate.
+	StaleTTL time.Duration
+
+	// RefreshTimeout bounds background refreshes
+	RefreshTimeout time.Duration
+}
+
+// DefaultLoaderConfig returns the default loader configuration
+func DefaultLoaderConfig() LoaderConfig {
+	return LoaderConfig{
+		RefreshTimeout: 10 * time.Second,
+	}
+}
+
+// Loader wraps a cache with read-through loading that protects the
+// source from stampedes: concurrent misses for a key share one load, and
+// with a StaleTTL, expired values keep being served while a single
+// background refresh runs.
+type Loader struct {
+	cache      Cache
+	config     LoaderConfig
+	group      singleflight.Group
+	refreshing sync.Map // key -> struct{}
+
+	loads     atomic.Uint64
+	prevented atomic.Uint64
+	staleHits atomic.Uint64
+}
+
+// loadedEntry wraps values stored with a soft TTL
+type loadedEntry struct {
+	Value      interface{} `json:"v"`
+	SoftExpiry int64       `json:"se"` // Unix milliseconds; 0 = never stale
+}
+
+// Let GobCodec round-trip entries as their own type
+func init() {
+	gob.Register(&loadedEntry{})
+}
+
+// NewLoader creates a loader over c
+func NewLoader(c Cache, config LoaderConfig) *Loader {
+	if config.RefreshTimeout <= 0 {
+		config.RefreshTimeout = DefaultLoaderConfig().RefreshTimeout
+	}
+	return &Loader{cache: c, config: config}
+}
+
+// GetOrLoad returns the cached value for key, calling loader on a miss and
+// caching its result for ttl. Loader errors are returned and not cached.
+//
+// With a StaleTTL, values are stored wrapped with their soft expiry, so
+// keys written through a Loader should also be read through it.
+func (l *Loader) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc, opts ...SetOption) (interface{}, error) {
+	if raw, err := l.cache.Get(ctx, key); err == nil {
+		if l.config.StaleTTL <= 0 {
+			return raw, nil
+		}
+		if entry, ok := decodeLoadedEntry(raw); ok {
+			if entry.SoftExpiry > 0 && time.Now().UnixMilli() >= entry.SoftExpiry {
+				l.staleHits.Add(1)
+				l.refresh(ctx, key, ttl, loader, opts)
+			}
+			return entry.Value, nil
+		}
+		// Written without a soft TTL; treat as fresh
+		return raw, nil
+	}
+
+	executed := false
+	value, err, _ := l.group.Do(key, func() (interface{}, error) {
+		executed = true
+		return l.load(ctx, key, ttl, loader, opts)
+	})
+	if !executed {
+		l.prevented.Add(1)
+	}
+	return value, err
+}
+
+// Stats returns the wrapped cache's statistics with loader counters added
+func (l *Loader) Stats(ctx context.Context) (*Stats, error) {
+	stats := &Stats{}
+	if sp, ok := l.cache.(StatsProvider); ok {
+		s, err := sp.Stats(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stats = s
+	}
+
+	stats.Loads += l.loads.Load()
+	stats.StampedesPrevented += l.prevented.Load()
+	stats.StaleHits += l.staleHits.Load()
+	return stats, nil
+}
+
+// Cache returns the wrapped cache
+func (l *Loader) Cache() Cache {
+	return l.cache
+}
+
+// load calls the loader and stores its result
+func (l *Loader) load(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc, opts []SetOption) (interface{}, error) {
+	l.loads.Add(1)
+	value, err := loader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.config.StaleTTL <= 0 {
+		l.cache.Set(ctx, key, value, ttl, opts...)
+		return value, nil
+	}
+
+	entry := &loadedEntry{Value: value}
+	hardTTL := ttl
+	if ttl > 0 {
+		entry.SoftExpiry = time.Now().Add(ttl).UnixMilli()
+		hardTTL = ttl + l.config.StaleTTL
+	}
+	l.cache.Set(ctx, key, entry, hardTTL, opts...)
+	return value, nil
+}
+
+// refresh reloads a stale key in the background unless a refresh for it
+// is already running
+func (l *Loader) refresh(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc, opts []SetOption) {
+	if _, running := l.refreshing.LoadOrStore(key, struct{}{}); running {
+		l.prevented.Add(1)
+		return
+	}
+
+	go func() {
+		defer l.refreshing.Delete(key)
+
+		refreshCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), l.config.RefreshTimeout)
+		defer cancel()
+
+		// Share the load with any caller that misses meanwhile
+		l.group.Do(key, func() (interface{}, error) {
+			return l.load(refreshCtx, key, ttl, loader, opts)
+		})
+	}()
+}
+
+// decodeLoadedEntry unwraps an entry from memory caches or from the map
+// form returned by Redis with the JSON or msgpack codecs
+func decodeLoadedEntry(raw interface{}) (*loadedEntry, bool) {
+	switch v := raw.(type) {
+	case *loadedEntry:
+		return v, true
+	case map[string]interface{}:
+		value, hasValue := v["v"]
+		if !hasValue || len(v) != 2 {
+			return nil, false
+		}
+		var softExpiry int64
+		switch se := v["se"].(type) {
+		case float64:
+			softExpiry = int64(se)
+		case int64:
+			softExpiry = se
+		case uint64:
+			softExpiry = int64(se)
+		default:
+			return nil, false
+		}
+		return &loadedEntry{Value: value, SoftExpiry: softExpiry}, true
+	}
+	return nil, false
+}
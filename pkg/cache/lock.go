@@ -0,0 +1,220 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// lockKeyPrefix namespaces lock keys
+const lockKeyPrefix = "lock:"
+
+// Lock errors
+var (
+	ErrLockNotAcquired = errors.New("lock is held by another owner")
+	ErrLockNotHeld     = errors.New("lock is not held")
+)
+
+// releaseScript deletes the lock only if it still holds our token
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// renewScript extends the lock only if it still holds our token.
+// ARGV[2] is the new TTL in ms.
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// clockDriftFactor bounds the clock drift between us and Redis as a
+// fraction of the TTL, as in the Redlock algorithm
+const clockDriftFactor = 0.01
+
+// Lock is a Redis-backed distributed mutex. It follows the single-instance
+// Redlock algorithm: a random token is set with NX and a TTL, and renewal
+// and release only act while the key still holds that token, so an owner
+// whose lock expired can never release someone else's.
+//
+// A Lock is held by one owner at a time; create one Lock per owner.
+type Lock struct {
+	client     redis.Cmdable
+	key        string
+	ttl        time.Duration
+	retryDelay time.Duration
+
+	mu       sync.Mutex
+	token    string
+	deadline time.Time // Local estimate of when the lock expires
+}
+
+// LockOption configures a Lock
+type LockOption func(*Lock)
+
+// WithRetryDelay sets how often Acquire retries a held lock (default 100ms)
+func WithRetryDelay(delay time.Duration) LockOption {
+	return func(l *Lock) {
+		l.retryDelay = delay
+	}
+}
+
+// NewLock creates a lock on key that expires ttl after it is acquired or
+// renewed, unless released first
+func NewLock(client redis.Cmdable, key string, ttl time.Duration, opts ...LockOption) *Lock {
+	l := &Lock{
+		client:     client,
+		key:        lockKeyPrefix + key,
+		ttl:        ttl,
+		retryDelay: 100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// NewLock creates a lock stored in this Redis cache
+func (rc *RedisCache) NewLock(key string, ttl time.Duration, opts ...LockOption) *Lock {
+	return NewLock(rc.client, key, ttl, opts...)
+}
+
+// Key returns the Redis key backing the lock
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// TryAcquire takes the lock if it is free, without waiting
+func (l *Lock) TryAcquire(ctx context.Context) (bool, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return false, &CacheError{Op: "lock", Key: l.key, Err: err}
+	}
+
+	start := time.Now()
+	ok, err := l.client.SetNX(ctx, l.key, token, l.ttl).Result()
+	if err != nil {
+		return false, &CacheError{Op: "lock", Key: l.key, Err: err}
+	}
+	if !ok {
+		return false, nil
+	}
+
+	l.mu.Lock()
+	l.token = token
+	l.deadline = l.validUntil(start)
+	l.mu.Unlock()
+	return true, nil
+}
+
+// Acquire waits for the lock until ctx is done, returning
+// ErrLockNotAcquired if it never became free
+func (l *Lock) Acquire(ctx context.Context) error {
+	ticker := time.NewTicker(l.retryDelay)
+	defer ticker.Stop()
+
+	for {
+		ok, err := l.TryAcquire(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ErrLockNotAcquired
+		}
+	}
+}
+
+// Renew extends a held lock by its TTL. It returns ErrLockNotHeld if the
+// lock expired and may have been taken by another owner.
+func (l *Lock) Renew(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.token == "" {
+		return ErrLockNotHeld
+	}
+
+	start := time.Now()
+	res, err := renewScript.Run(ctx, l.client, []string{l.key}, l.token, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return &CacheError{Op: "lock", Key: l.key, Err: err}
+	}
+	if res == 0 {
+		l.token = ""
+		return ErrLockNotHeld
+	}
+	l.deadline = l.validUntil(start)
+	return nil
+}
+
+// Release frees a held lock. It returns ErrLockNotHeld if the lock had
+// already expired.
+func (l *Lock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.token == "" {
+		return ErrLockNotHeld
+	}
+
+	token := l.token
+	l.token = ""
+	res, err := releaseScript.Run(ctx, l.client, []string{l.key}, token).Int()
+	if err != nil {
+		return &CacheError{Op: "unlock", Key: l.key, Err: err}
+	}
+	if res == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Validity returns how much longer the lock is safely held, allowing for
+// clock drift, or 0 if it is not held
+func (l *Lock) Validity() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.token == "" {
+		return 0
+	}
+	if remaining := time.Until(l.deadline); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Held reports whether the lock is believed held, without asking Redis
+func (l *Lock) Held() bool {
+	return l.Validity() > 0
+}
+
+// validUntil returns the lock's safe expiry for an acquire or renew that
+// started at start
+func (l *Lock) validUntil(start time.Time) time.Time {
+	drift := time.Duration(float64(l.ttl)*clockDriftFactor) + 2*time.Millisecond
+	return start.Add(l.ttl - drift)
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,431 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// ErrNotSupported is returned for operations a backend cannot perform, such
+// as listing keys on memcached
+var ErrNotSupported = errors.New("operation not supported by this cache")
+
+// memcachedRelativeLimit is the longest expiration memcached accepts as a
+// relative duration; longer ones must be absolute Unix times
+const memcachedRelativeLimit = 30 * 24 * time.Hour
+
+// MemcachedCache is a memcached-based cache implementation. Keys are
+// sharded across servers by the client.
+//
+// Memcached cannot enumerate keys, so Keys returns ErrNotSupported, and
+// counters cannot go below zero. Clear flushes every server entirely.
+type MemcachedCache struct {
+	client     *memcache.Client
+	config     Config
+	prefix     string
+	serializer *serializer
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+}
+
+// MemcachedCacheConfig configures the memcached cache
+type MemcachedCacheConfig struct {
+	Config
+	Servers      []string // Memcached addresses (host:port)
+	Prefix       string   // Prepended to every key
+	MaxIdleConns int      // Idle connections kept per server
+
+	// Value serialization, as for RedisCacheConfig
+	Codec                Codec
+	Compression          Compression
+	CompressionThreshold int
+}
+
+// DefaultMemcachedCacheConfig returns the default memcached cache configuration
+func DefaultMemcachedCacheConfig() MemcachedCacheConfig {
+	config := DefaultConfig()
+	config.Timeout = 500 * time.Millisecond
+
+	return MemcachedCacheConfig{
+		Config:       config,
+		Servers:      []string{"localhost:11211"},
+		MaxIdleConns: 10,
+
+		Codec:                JSONCodec{},
+		Compression:          CompressionNone,
+		CompressionThreshold: DefaultCompressionThreshold,
+	}
+}
+
+// NewMemcachedCache creates a new memcached cache
+func NewMemcachedCache(config MemcachedCacheConfig) (*MemcachedCache, error) {
+	client := memcache.New(config.Servers...)
+	client.Timeout = config.Timeout
+	client.MaxIdleConns = config.MaxIdleConns
+
+	// Test connection
+	if err := client.Ping(); err != nil {
+		return nil, &CacheError{Op: "connect", Err: err}
+	}
+
+	return &MemcachedCache{
+		client:     client,
+		config:     config.Config,
+		prefix:     config.Prefix,
+		serializer: newSerializer(config.Codec, config.Compression, config.CompressionThreshold),
+	}, nil
+}
+
+// Get retrieves a value from the cache
+func (mc *MemcachedCache) Get(ctx context.Context, key string) (interface{}, error) {
+	item, err := mc.get(key)
+	if err != nil {
+		return nil, err
+	}
+	return mc.decode(key, item.Value)
+}
+
+// Scan retrieves a value into dest, which must be a non-nil pointer
+func (mc *MemcachedCache) Scan(ctx context.Context, key string, dest interface{}) error {
+	item, err := mc.get(key)
+	if err != nil {
+		return err
+	}
+	if _, err := mc.serializer.decode(item.Value, dest); err != nil {
+		return &CacheError{Op: "decode", Key: key, Err: err}
+	}
+	return nil
+}
+
+func (mc *MemcachedCache) get(key string) (*memcache.Item, error) {
+	item, err := mc.client.Get(mc.key(key))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		mc.misses.Add(1)
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, &CacheError{Op: "get", Key: key, Err: err}
+	}
+
+	mc.hits.Add(1)
+	return item, nil
+}
+
+// decode deserializes a stored value. Unframed values that do not decode
+// are returned as strings.
+func (mc *MemcachedCache) decode(key string, val []byte) (interface{}, error) {
+	var result interface{}
+	framed, err := mc.serializer.decode(val, &result)
+	if err != nil {
+		if !framed {
+			return string(val), nil
+		}
+		return nil, &CacheError{Op: "decode", Key: key, Err: err}
+	}
+	return result, nil
+}
+
+// Set stores a value in the cache with TTL
+func (mc *MemcachedCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration, opts ...SetOption) error {
+	if ttl == 0 {
+		ttl = mc.config.DefaultTTL
+	}
+
+	data, err := mc.serializer.encode(value)
+	if err != nil {
+		return &CacheError{Op: "set", Key: key, Err: err}
+	}
+
+	if err := mc.client.Set(mc.item(key, data, ttl)); err != nil {
+		return &CacheError{Op: "set", Key: key, Err: err}
+	}
+
+	for _, tag := range applySetOptions(opts).Tags {
+		if err := mc.tag(tag, key); err != nil {
+			return &CacheError{Op: "tag", Key: key, Err: err}
+		}
+	}
+	return nil
+}
+
+// item builds a memcached item. The absolute expiry is kept in the item's
+// flags so TTL can report it.
+func (mc *MemcachedCache) item(key string, data []byte, ttl time.Duration) *memcache.Item {
+	item := &memcache.Item{Key: mc.key(key), Value: data}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		item.Flags = uint32(expiresAt.Unix())
+		if ttl <= memcachedRelativeLimit {
+			item.Expiration = int32((ttl + time.Second - 1) / time.Second)
+		} else {
+			item.Expiration = int32(expiresAt.Unix())
+		}
+	}
+	return item
+}
+
+// tag appends key to the tag's newline-separated key list
+func (mc *MemcachedCache) tag(tag, key string) error {
+	tagKey := mc.key(tagKeyPrefix + tag)
+	line := []byte(key + "\n")
+
+	for attempt := 0; attempt < 3; attempt++ {
+		err := mc.client.Append(&memcache.Item{Key: tagKey, Value: line})
+		if !errors.Is(err, memcache.ErrNotStored) {
+			return err
+		}
+		// No list yet; create it unless another writer just did
+		err = mc.client.Add(&memcache.Item{Key: tagKey, Value: line})
+		if !errors.Is(err, memcache.ErrNotStored) {
+			return err
+		}
+	}
+	return memcache.ErrNotStored
+}
+
+// Delete removes a value from the cache
+func (mc *MemcachedCache) Delete(ctx context.Context, key string) error {
+	err := mc.client.Delete(mc.key(key))
+	if err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+		return &CacheError{Op: "delete", Key: key, Err: err}
+	}
+	return nil
+}
+
+// Exists checks if a key exists in the cache
+func (mc *MemcachedCache) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := mc.client.Get(mc.key(key))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		return false, &CacheError{Op: "exists", Key: key, Err: err}
+	}
+	return true, nil
+}
+
+// Clear flushes every memcached server, including keys outside Prefix
+func (mc *MemcachedCache) Clear(ctx context.Context) error {
+	if err := mc.client.FlushAll(); err != nil {
+		return &CacheError{Op: "clear", Err: err}
+	}
+	return nil
+}
+
+// Keys is not supported by memcached
+func (mc *MemcachedCache) Keys(ctx context.Context, pattern string) ([]string, error) {
+	return nil, &CacheError{Op: "keys", Err: ErrNotSupported}
+}
+
+// TTL returns the remaining time to live for a key
+func (mc *MemcachedCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	item, err := mc.client.Get(mc.key(key))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return 0, ErrKeyNotFound
+	}
+	if err != nil {
+		return 0, &CacheError{Op: "ttl", Key: key, Err: err}
+	}
+	if item.Flags == 0 {
+		return 0, nil // No expiration
+	}
+
+	ttl := time.Until(time.Unix(int64(item.Flags), 0))
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Expire sets a new TTL for a key
+func (mc *MemcachedCache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	for attempt := 0; attempt < 3; attempt++ {
+		item, err := mc.client.Get(mc.key(key))
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			return ErrKeyNotFound
+		}
+		if err != nil {
+			return &CacheError{Op: "expire", Key: key, Err: err}
+		}
+
+		updated := mc.item(key, item.Value, ttl)
+		item.Flags = updated.Flags
+		item.Expiration = updated.Expiration
+		err = mc.client.CompareAndSwap(item)
+		if errors.Is(err, memcache.ErrCASConflict) {
+			continue
+		}
+		if errors.Is(err, memcache.ErrNotStored) {
+			return ErrKeyNotFound
+		}
+		if err != nil {
+			return &CacheError{Op: "expire", Key: key, Err: err}
+		}
+		return nil
+	}
+	return &CacheError{Op: "expire", Key: key, Err: memcache.ErrCASConflict}
+}
+
+// Increment atomically increments a counter, creating it if missing.
+// Memcached counters are unsigned, so decrements stop at zero.
+func (mc *MemcachedCache) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	if delta < 0 {
+		return mc.Decrement(ctx, key, -delta)
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		val, err := mc.client.Increment(mc.key(key), uint64(delta))
+		if err == nil {
+			return int64(val), nil
+		}
+		if !errors.Is(err, memcache.ErrCacheMiss) {
+			return 0, &CacheError{Op: "increment", Key: key, Err: err}
+		}
+
+		err = mc.client.Add(&memcache.Item{Key: mc.key(key), Value: []byte(strconv.FormatInt(delta, 10))})
+		if err == nil {
+			return delta, nil
+		}
+		if !errors.Is(err, memcache.ErrNotStored) {
+			return 0, &CacheError{Op: "increment", Key: key, Err: err}
+		}
+	}
+	return 0, &CacheError{Op: "increment", Key: key, Err: memcache.ErrNotStored}
+}
+
+// Decrement atomically decrements a counter, stopping at zero
+func (mc *MemcachedCache) Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	if delta < 0 {
+		return mc.Increment(ctx, key, -delta)
+	}
+
+	val, err := mc.client.Decrement(mc.key(key), uint64(delta))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		if err := mc.client.Add(&memcache.Item{Key: mc.key(key), Value: []byte("0")}); err != nil && !errors.Is(err, memcache.ErrNotStored) {
+			return 0, &CacheError{Op: "decrement", Key: key, Err: err}
+		}
+		return 0, nil
+	}
+	if err != nil {
+		return 0, &CacheError{Op: "decrement", Key: key, Err: err}
+	}
+	return int64(val), nil
+}
+
+// GetMulti retrieves multiple values
+func (mc *MemcachedCache) GetMulti(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	if len(keys) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	names := make(map[string]string, len(keys))
+	mapped := make([]string, len(keys))
+	for i, key := range keys {
+		mapped[i] = mc.key(key)
+		names[mapped[i]] = key
+	}
+
+	items, err := mc.client.GetMulti(mapped)
+	if err != nil {
+		return nil, &CacheError{Op: "getmulti", Err: err}
+	}
+
+	result := make(map[string]interface{}, len(items))
+	for mappedKey, item := range items {
+		key := names[mappedKey]
+		value, err := mc.decode(key, item.Value)
+		if err != nil {
+			continue
+		}
+		result[key] = value
+	}
+
+	mc.hits.Add(uint64(len(result)))
+	mc.misses.Add(uint64(len(keys) - len(result)))
+	return result, nil
+}
+
+// SetMulti stores multiple values
+func (mc *MemcachedCache) SetMulti(ctx context.Context, items map[string]interface{}, ttl time.Duration) error {
+	for key, value := range items {
+		if err := mc.Set(ctx, key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMulti removes multiple values
+func (mc *MemcachedCache) DeleteMulti(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if err := mc.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidateTag removes every value tagged with tag
+func (mc *MemcachedCache) InvalidateTag(ctx context.Context, tag string) error {
+	keys, err := mc.tagKeys(ctx, tag)
+	if err != nil {
+		return err
+	}
+	if err := mc.DeleteMulti(ctx, keys); err != nil {
+		return err
+	}
+	return mc.Delete(ctx, tagKeyPrefix+tag)
+}
+
+// tagKeys returns the keys tagged with tag
+func (mc *MemcachedCache) tagKeys(ctx context.Context, tag string) ([]string, error) {
+	item, err := mc.client.Get(mc.key(tagKeyPrefix + tag))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, &CacheError{Op: "tag", Key: tag, Err: err}
+	}
+
+	seen := make(map[string]bool)
+	keys := make([]string, 0)
+	for _, key := range strings.Split(string(item.Value), "\n") {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Stats returns cache statistics. Memcached server statistics are not
+// collected.
+func (mc *MemcachedCache) Stats(ctx context.Context) (*Stats, error) {
+	return &Stats{
+		Hits:   mc.hits.Load(),
+		Misses: mc.misses.Load(),
+	}, nil
+}
+
+// Close closes the memcached connections
+func (mc *MemcachedCache) Close() error {
+	return mc.client.Close()
+}
+
+// key applies the prefix and hashes keys memcached would reject: longer
+// than 250 bytes or containing whitespace or control characters
+func (mc *MemcachedCache) key(key string) string {
+	full := mc.prefix + key
+	if len(full) <= 250 && !strings.ContainsFunc(full, func(r rune) bool { return r <= ' ' || r == 0x7f }) {
+		return full
+	}
+	sum := sha256.Sum256([]byte(full))
+	return mc.prefix + "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -12,6 +12,7 @@ type MemoryCache struct {
 	mu        sync.RWMutex
 	items     map[string]*list.Element
 	lru       *list.List
+	tags      map[string]map[string]struct{} // tag -> keys
 	maxSize   int
 	stats     Stats
 	config    Config
@@ -24,6 +25,7 @@ type cacheItem struct {
 	key       string
 	value     interface{}
 	expiresAt time.Time
+	tags      []string
 }
 
 // MemoryCacheConfig configures the memory cache
@@ -47,6 +49,7 @@ func NewMemoryCache(config MemoryCacheConfig) *MemoryCache {
 	mc := &MemoryCache{
 		items:     make(map[string]*list.Element),
 		lru:       list.New(),
+		tags:      make(map[string]map[string]struct{}),
 		maxSize:   config.MaxSize,
 		config:    config.Config,
 		closeChan: make(chan struct{}),
@@ -92,7 +95,9 @@ func (mc *MemoryCache) Get(ctx context.Context, key string) (interface{}, error)
 }
 
 // Set stores a value in the cache with TTL
-func (mc *MemoryCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+func (mc *MemoryCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration, opts ...SetOption) error {
+	options := applySetOptions(opts)
+	
 	mc.mu.Lock()
 	defer mc.mu.Unlock()
 	
@@ -115,6 +120,8 @@ func (mc *MemoryCache) Set(ctx context.Context, key string, value interface{}, t
 		item := elem.Value.(*cacheItem)
 		item.value = value
 		item.expiresAt = expiresAt
+		mc.untag(item)
+		mc.tag(item, options.Tags)
 		mc.lru.MoveToFront(elem)
 		return nil
 	}
@@ -128,6 +135,7 @@ func (mc *MemoryCache) Set(ctx context.Context, key string, value interface{}, t
 	
 	elem := mc.lru.PushFront(item)
 	mc.items[key] = elem
+	mc.tag(item, options.Tags)
 	mc.stats.Keys++
 	
 	// Evict if necessary
@@ -188,6 +196,7 @@ func (mc *MemoryCache) Clear(ctx context.Context) error {
 	}
 	
 	mc.items = make(map[string]*list.Element)
+	mc.tags = make(map[string]map[string]struct{})
 	mc.lru.Init()
 	mc.stats.Keys = 0
 	
@@ -284,6 +293,13 @@ func (mc *MemoryCache) Increment(ctx context.Context, key string, delta int64) (
 	}
 	
 	elem, found := mc.items[key]
+	if found {
+		// An expired counter starts over, as in Redis
+		if item := elem.Value.(*cacheItem); !item.expiresAt.IsZero() && time.Now().After(item.expiresAt) {
+			mc.removeElement(elem)
+			found = false
+		}
+	}
 	if !found {
 		// Create new counter
 		item := &cacheItem{
@@ -347,6 +363,41 @@ func (mc *MemoryCache) DeleteMulti(ctx context.Context, keys []string) error {
 	return nil
 }
 
+// InvalidateTag removes every value tagged with tag
+func (mc *MemoryCache) InvalidateTag(ctx context.Context, tag string) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	
+	if mc.closed {
+		return ErrClosed
+	}
+	
+	for key := range mc.tags[tag] {
+		if elem, found := mc.items[key]; found {
+			mc.removeElement(elem)
+		}
+	}
+	delete(mc.tags, tag)
+	
+	return nil
+}
+
+// tagKeys returns the keys currently tagged with tag
+func (mc *MemoryCache) tagKeys(ctx context.Context, tag string) ([]string, error) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	
+	if mc.closed {
+		return nil, ErrClosed
+	}
+	
+	keys := make([]string, 0, len(mc.tags[tag]))
+	for key := range mc.tags[tag] {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
 // Stats returns cache statistics
 func (mc *MemoryCache) Stats(ctx context.Context) (*Stats, error) {
 	mc.mu.RLock()
@@ -370,6 +421,7 @@ func (mc *MemoryCache) Close() error {
 	mc.closed = true
 	close(mc.closeChan)
 	mc.items = nil
+	mc.tags = nil
 	mc.lru = nil
 	
 	return nil
@@ -379,10 +431,37 @@ func (mc *MemoryCache) Close() error {
 func (mc *MemoryCache) removeElement(elem *list.Element) {
 	item := elem.Value.(*cacheItem)
 	delete(mc.items, item.key)
+	mc.untag(item)
 	mc.lru.Remove(elem)
 	mc.stats.Keys--
 }
 
+// tag indexes an item under each of its tags
+func (mc *MemoryCache) tag(item *cacheItem, tags []string) {
+	item.tags = tags
+	for _, tag := range tags {
+		keys, ok := mc.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			mc.tags[tag] = keys
+		}
+		keys[item.key] = struct{}{}
+	}
+}
+
+// untag drops an item from the tag index
+func (mc *MemoryCache) untag(item *cacheItem) {
+	for _, tag := range item.tags {
+		if keys, ok := mc.tags[tag]; ok {
+			delete(keys, item.key)
+			if len(keys) == 0 {
+				delete(mc.tags, tag)
+			}
+		}
+	}
+	item.tags = nil
+}
+
 // evict removes the least recently used item
 func (mc *MemoryCache) evict() {
 	elem := mc.lru.Back()
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"reflect"
 	"sync"
 	"time"
 )
@@ -17,13 +18,24 @@ const (
 
 // MultiTierCache implements a multi-tier caching strategy
 type MultiTierCache struct {
-	tiers      []cacheWithLevel
-	mu         sync.RWMutex
-	config     Config
-	promoteL1  bool // Promote hits to L1 cache
-	writeThru  bool // Write-through to all tiers
-	writeBack  bool // Write-back strategy
-	stats      Stats
+	tiers     []cacheWithLevel
+	mu        sync.RWMutex
+	config    Config
+	promoteL1 bool // Promote hits to L1 cache
+	writeThru bool // Write-through to all tiers
+	writeBack bool // Write-back strategy
+	stats     Stats
+
+	writeBehind *writeBehind // Nil unless WriteBehind is enabled
+
+	refreshWindow time.Duration
+	loaders       []refreshLoader
+	loadersMu     sync.RWMutex
+	refreshing    sync.Map // key -> struct{}
+
+	keyMetrics    map[string]*KeyMetrics
+	maxMetricKeys int
+	keyMetricsMu  sync.Mutex
 }
 
 type cacheWithLevel struct {
@@ -37,27 +49,78 @@ type MultiTierConfig struct {
 	PromoteL1 bool // Promote cache hits to L1
 	WriteThru bool // Write to all tiers immediately
 	WriteBack bool // Write to lower tiers asynchronously
+
+	// WriteBehind writes L1 synchronously and queues writes to lower
+	// tiers, retrying failures up to MaxRetries times. It takes precedence
+	// over WriteThru and WriteBack.
+	WriteBehind          bool
+	WriteBehindWorkers   int // Goroutines draining the queue
+	WriteBehindQueueSize int // Queued keys before writes turn synchronous
+
+	// RefreshAhead reloads keys read with less than this TTL left, in the
+	// background, using the loader registered with RegisterLoader. Zero
+	// disables refresh-ahead.
+	RefreshAhead time.Duration
+
+	// MaxMetricKeys bounds the keys tracked by KeyMetrics
+	MaxMetricKeys int
+}
+
+// KeyMetrics are per-key statistics of the write-behind and refresh-ahead
+// policies
+type KeyMetrics struct {
+	Writes        uint64        // Write-behind writes that reached the lower tiers
+	WriteFailures uint64        // Write-behind writes dropped after all retries
+	WriteRetries  uint64        // Retried lower tier writes
+	LastWriteLag  time.Duration // Time from Set to reaching the lower tiers
+	MaxWriteLag   time.Duration
+
+	Refreshes        uint64 // Successful refresh-ahead reloads
+	RefreshFailures  uint64
+	LastRefreshAt    time.Time
+	LastRefreshError string
 }
 
 // DefaultMultiTierConfig returns default configuration
 func DefaultMultiTierConfig() MultiTierConfig {
 	return MultiTierConfig{
-		Config:    DefaultConfig(),
-		PromoteL1: true,
-		WriteThru: true,
-		WriteBack: false,
+		Config:               DefaultConfig(),
+		PromoteL1:            true,
+		WriteThru:            true,
+		WriteBack:            false,
+		WriteBehindWorkers:   4,
+		WriteBehindQueueSize: 10000,
+		MaxMetricKeys:        10000,
 	}
 }
 
 // NewMultiTierCache creates a new multi-tier cache
 func NewMultiTierCache(config MultiTierConfig) *MultiTierCache {
-	return &MultiTierCache{
-		tiers:     []cacheWithLevel{},
-		config:    config.Config,
-		promoteL1: config.PromoteL1,
-		writeThru: config.WriteThru,
-		writeBack: config.WriteBack,
+	defaults := DefaultMultiTierConfig()
+	if config.WriteBehindWorkers <= 0 {
+		config.WriteBehindWorkers = defaults.WriteBehindWorkers
+	}
+	if config.WriteBehindQueueSize <= 0 {
+		config.WriteBehindQueueSize = defaults.WriteBehindQueueSize
+	}
+	if config.MaxMetricKeys <= 0 {
+		config.MaxMetricKeys = defaults.MaxMetricKeys
+	}
+
+	mtc := &MultiTierCache{
+		tiers:         []cacheWithLevel{},
+		config:        config.Config,
+		promoteL1:     config.PromoteL1,
+		writeThru:     config.WriteThru,
+		writeBack:     config.WriteBack,
+		refreshWindow: config.RefreshAhead,
+		keyMetrics:    make(map[string]*KeyMetrics),
+		maxMetricKeys: config.MaxMetricKeys,
+	}
+	if config.WriteBehind {
+		mtc.writeBehind = newWriteBehind(mtc, config.WriteBehindWorkers, config.WriteBehindQueueSize)
 	}
+	return mtc
 }
 
 // AddTier adds a cache tier
@@ -89,6 +152,7 @@ func (mtc *MultiTierCache) Get(ctx context.Context, key string) (interface{}, er
 				go mtc.promoteToHigherTiers(key, value, i)
 			}
 
+			mtc.refreshAhead(ctx, key, tier.cache)
 			return value, nil
 		}
 
@@ -105,8 +169,38 @@ func (mtc *MultiTierCache) Get(ctx context.Context, key string) (interface{}, er
 	return nil, ErrKeyNotFound
 }
 
+// Scan retrieves a value into dest from the first tier holding it. The
+// decoded value is what gets promoted, so typed reads stay typed in L1.
+func (mtc *MultiTierCache) Scan(ctx context.Context, key string, dest interface{}) error {
+	mtc.mu.RLock()
+	defer mtc.mu.RUnlock()
+
+	for i, tier := range mtc.tiers {
+		err := GetInto(ctx, tier.cache, key, dest)
+		if err == nil {
+			mtc.stats.Hits++
+
+			if mtc.promoteL1 && i > 0 {
+				go mtc.promoteToHigherTiers(key, reflect.ValueOf(dest).Elem().Interface(), i)
+			}
+
+			mtc.refreshAhead(ctx, key, tier.cache)
+			return nil
+		}
+
+		if err == ErrKeyNotFound {
+			continue
+		}
+
+		return err
+	}
+
+	mtc.stats.Misses++
+	return ErrKeyNotFound
+}
+
 // Set stores a value in all cache tiers
-func (mtc *MultiTierCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+func (mtc *MultiTierCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration, opts ...SetOption) error {
 	mtc.mu.RLock()
 	defer mtc.mu.RUnlock()
 
@@ -114,10 +208,14 @@ func (mtc *MultiTierCache) Set(ctx context.Context, key string, value interface{
 		ttl = mtc.config.DefaultTTL
 	}
 
+	if mtc.writeBehind != nil {
+		return mtc.setBehind(ctx, key, value, ttl, opts)
+	}
+
 	if mtc.writeThru {
 		// Write to all tiers synchronously
 		for _, tier := range mtc.tiers {
-			if err := tier.cache.Set(ctx, key, value, ttl); err != nil {
+			if err := tier.cache.Set(ctx, key, value, ttl, opts...); err != nil {
 				return err
 			}
 		}
@@ -126,12 +224,12 @@ func (mtc *MultiTierCache) Set(ctx context.Context, key string, value interface{
 
 	// Write to L1 only, write-back to others asynchronously
 	if len(mtc.tiers) > 0 {
-		if err := mtc.tiers[0].cache.Set(ctx, key, value, ttl); err != nil {
+		if err := mtc.tiers[0].cache.Set(ctx, key, value, ttl, opts...); err != nil {
 			return err
 		}
 
 		if mtc.writeBack && len(mtc.tiers) > 1 {
-			go mtc.writeToLowerTiers(key, value, ttl)
+			go mtc.writeToLowerTiers(key, value, ttl, opts)
 		}
 	}
 
@@ -143,6 +241,10 @@ func (mtc *MultiTierCache) Delete(ctx context.Context, key string) error {
 	mtc.mu.RLock()
 	defer mtc.mu.RUnlock()
 
+	if mtc.writeBehind != nil {
+		mtc.writeBehind.cancel(key)
+	}
+
 	var lastErr error
 	for _, tier := range mtc.tiers {
 		if err := tier.cache.Delete(ctx, key); err != nil {
@@ -311,6 +413,15 @@ func (mtc *MultiTierCache) SetMulti(ctx context.Context, items map[string]interf
 		ttl = mtc.config.DefaultTTL
 	}
 
+	if mtc.writeBehind != nil {
+		for key, value := range items {
+			if err := mtc.setBehind(ctx, key, value, ttl, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	if mtc.writeThru {
 		// Write to all tiers
 		for _, tier := range mtc.tiers {
@@ -340,6 +451,12 @@ func (mtc *MultiTierCache) DeleteMulti(ctx context.Context, keys []string) error
 	mtc.mu.RLock()
 	defer mtc.mu.RUnlock()
 
+	if mtc.writeBehind != nil {
+		for _, key := range keys {
+			mtc.writeBehind.cancel(key)
+		}
+	}
+
 	var lastErr error
 	for _, tier := range mtc.tiers {
 		if err := tier.cache.DeleteMulti(ctx, keys); err != nil {
@@ -350,6 +467,65 @@ func (mtc *MultiTierCache) DeleteMulti(ctx context.Context, keys []string) error
 	return lastErr
 }
 
+// InvalidateTag removes every value tagged with tag from all tiers. Values
+// promoted from a lower tier carry no tags in the upper tiers, so keys known
+// to any tier are deleted everywhere.
+func (mtc *MultiTierCache) InvalidateTag(ctx context.Context, tag string) error {
+	mtc.mu.RLock()
+	defer mtc.mu.RUnlock()
+
+	seen := make(map[string]struct{})
+	keys := []string{}
+	for _, tier := range mtc.tiers {
+		lister, ok := tier.cache.(tagLister)
+		if !ok {
+			continue
+		}
+		tierKeys, err := lister.tagKeys(ctx, tag)
+		if err != nil {
+			continue
+		}
+		for _, key := range tierKeys {
+			if _, dup := seen[key]; !dup {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	var lastErr error
+	for _, tier := range mtc.tiers {
+		if err := tier.cache.InvalidateTag(ctx, tag); err != nil {
+			lastErr = err
+		}
+		if len(keys) > 0 {
+			if err := tier.cache.DeleteMulti(ctx, keys); err != nil {
+				lastErr = err
+			}
+		}
+	}
+
+	return lastErr
+}
+
+// tagKeys returns the keys tagged with tag in any tier
+func (mtc *MultiTierCache) tagKeys(ctx context.Context, tag string) ([]string, error) {
+	mtc.mu.RLock()
+	defer mtc.mu.RUnlock()
+
+	keys := []string{}
+	for _, tier := range mtc.tiers {
+		if lister, ok := tier.cache.(tagLister); ok {
+			tierKeys, err := lister.tagKeys(ctx, tag)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, tierKeys...)
+		}
+	}
+	return keys, nil
+}
+
 // Stats returns combined statistics from all tiers
 func (mtc *MultiTierCache) Stats(ctx context.Context) (*Stats, error) {
 	mtc.mu.RLock()
@@ -359,6 +535,17 @@ func (mtc *MultiTierCache) Stats(ctx context.Context) (*Stats, error) {
 		Hits:   mtc.stats.Hits,
 		Misses: mtc.stats.Misses,
 	}
+	if mtc.writeBehind != nil {
+		combined.WriteBehindPending = uint64(mtc.writeBehind.len())
+	}
+
+	mtc.keyMetricsMu.Lock()
+	for _, metrics := range mtc.keyMetrics {
+		combined.WriteBehindFailures += metrics.WriteFailures
+		combined.Refreshes += metrics.Refreshes
+		combined.RefreshFailures += metrics.RefreshFailures
+	}
+	mtc.keyMetricsMu.Unlock()
 
 	for _, tier := range mtc.tiers {
 		if sp, ok := tier.cache.(StatsProvider); ok {
@@ -374,8 +561,16 @@ func (mtc *MultiTierCache) Stats(ctx context.Context) (*Stats, error) {
 	return combined, nil
 }
 
-// Close closes all cache tiers
+// Close flushes queued writes, waiting up to the configured timeout, and
+// closes all cache tiers
 func (mtc *MultiTierCache) Close() error {
+	if mtc.writeBehind != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), mtc.config.Timeout)
+		mtc.writeBehind.flush(ctx)
+		cancel()
+		mtc.writeBehind.close()
+	}
+
 	mtc.mu.Lock()
 	defer mtc.mu.Unlock()
 
@@ -390,8 +585,115 @@ func (mtc *MultiTierCache) Close() error {
 	return lastErr
 }
 
+// Flush waits until every write-behind write has reached the lower tiers
+// or failed
+func (mtc *MultiTierCache) Flush(ctx context.Context) error {
+	if mtc.writeBehind == nil {
+		return nil
+	}
+	return mtc.writeBehind.flush(ctx)
+}
+
+// KeyMetrics returns the write-behind and refresh-ahead statistics of a key
+func (mtc *MultiTierCache) KeyMetrics(key string) (KeyMetrics, bool) {
+	mtc.keyMetricsMu.Lock()
+	defer mtc.keyMetricsMu.Unlock()
+
+	metrics, exists := mtc.keyMetrics[key]
+	if !exists {
+		return KeyMetrics{}, false
+	}
+	return *metrics, true
+}
+
+// AllKeyMetrics returns the statistics of every tracked key
+func (mtc *MultiTierCache) AllKeyMetrics() map[string]KeyMetrics {
+	mtc.keyMetricsMu.Lock()
+	defer mtc.keyMetricsMu.Unlock()
+
+	all := make(map[string]KeyMetrics, len(mtc.keyMetrics))
+	for key, metrics := range mtc.keyMetrics {
+		all[key] = *metrics
+	}
+	return all
+}
+
 // Helper methods
 
+// setBehind writes L1 and queues the lower tiers. The caller must hold
+// mtc.mu.
+func (mtc *MultiTierCache) setBehind(ctx context.Context, key string, value interface{}, ttl time.Duration, opts []SetOption) error {
+	if len(mtc.tiers) == 0 {
+		return nil
+	}
+	if err := mtc.tiers[0].cache.Set(ctx, key, value, ttl, opts...); err != nil {
+		return err
+	}
+	if len(mtc.tiers) == 1 || mtc.writeBehind.enqueue(key, value, ttl, opts) {
+		return nil
+	}
+
+	// Queue full: write through
+	for _, tier := range mtc.tiers[1:] {
+		if err := tier.cache.Set(ctx, key, value, ttl, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// metricsFor returns the metrics of a key, or nil once MaxMetricKeys keys
+// are tracked. The caller must hold mtc.keyMetricsMu.
+func (mtc *MultiTierCache) metricsFor(key string) *KeyMetrics {
+	metrics, exists := mtc.keyMetrics[key]
+	if !exists {
+		if len(mtc.keyMetrics) >= mtc.maxMetricKeys {
+			return nil
+		}
+		metrics = &KeyMetrics{}
+		mtc.keyMetrics[key] = metrics
+	}
+	return metrics
+}
+
+func (mtc *MultiTierCache) recordWrite(key string, lag time.Duration, retries int, err error) {
+	mtc.keyMetricsMu.Lock()
+	defer mtc.keyMetricsMu.Unlock()
+
+	metrics := mtc.metricsFor(key)
+	if metrics == nil {
+		return
+	}
+	metrics.WriteRetries += uint64(retries)
+	if err != nil {
+		metrics.WriteFailures++
+		return
+	}
+	metrics.Writes++
+	metrics.LastWriteLag = lag
+	if lag > metrics.MaxWriteLag {
+		metrics.MaxWriteLag = lag
+	}
+}
+
+func (mtc *MultiTierCache) recordRefresh(key string, err error) {
+	mtc.keyMetricsMu.Lock()
+	defer mtc.keyMetricsMu.Unlock()
+
+	metrics := mtc.metricsFor(key)
+	if metrics == nil {
+		return
+	}
+	metrics.LastRefreshAt = time.Now()
+	if err != nil {
+		metrics.RefreshFailures++
+		metrics.LastRefreshError = err.Error()
+		return
+	}
+	metrics.Refreshes++
+	metrics.LastRefreshError = ""
+}
+
 func (mtc *MultiTierCache) sortTiers() {
 	// Simple bubble sort by tier level
 	for i := 0; i < len(mtc.tiers); i++ {
@@ -417,10 +719,10 @@ func (mtc *MultiTierCache) promoteMultiToHigherTiers(values map[string]interface
 	}
 }
 
-func (mtc *MultiTierCache) writeToLowerTiers(key string, value interface{}, ttl time.Duration) {
+func (mtc *MultiTierCache) writeToLowerTiers(key string, value interface{}, ttl time.Duration, opts []SetOption) {
 	ctx := context.Background()
 	for i := 1; i < len(mtc.tiers); i++ {
-		mtc.tiers[i].cache.Set(ctx, key, value, ttl)
+		mtc.tiers[i].cache.Set(ctx, key, value, ttl, opts...)
 	}
 }
 
@@ -2,17 +2,41 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// tagKeyPrefix namespaces the Redis sets that index tagged keys
+const tagKeyPrefix = "cache:tag:"
+
+// tagScript adds a key to its tag sets. A tag set lives at least as long as
+// its longest-lived member, and never expires while it holds a key without
+// TTL. KEYS are tag sets; ARGV[1] is the key, ARGV[2] its TTL in ms (0 for none).
+var tagScript = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+for _, tag in ipairs(KEYS) do
+	redis.call("SADD", tag, ARGV[1])
+	if ttl <= 0 then
+		redis.call("PERSIST", tag)
+	else
+		local current = redis.call("PTTL", tag)
+		if current >= 0 and current < ttl then
+			redis.call("PEXPIRE", tag, ttl)
+		elseif current == -1 and redis.call("SCARD", tag) == 1 then
+			redis.call("PEXPIRE", tag, ttl)
+		end
+	end
+end
+return 1
+`)
+
 // RedisCache is a Redis-based cache implementation
 type RedisCache struct {
-	client *redis.Client
-	config Config
-	stats  Stats
+	client     *redis.Client
+	config     Config
+	serializer *serializer
+	stats      Stats
 }
 
 // RedisCacheConfig configures the Redis cache
@@ -27,6 +51,12 @@ type RedisCacheConfig struct {
 	DialTimeout  time.Duration
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+
+	// Value serialization. Changing the codec of a populated cache
+	// requires clearing it.
+	Codec                Codec       // JSONCodec (default), MsgpackCodec, GobCodec or ProtobufCodec
+	Compression          Compression // CompressionNone, CompressionGzip or CompressionSnappy
+	CompressionThreshold int         // Minimum encoded size in bytes to compress
 }
 
 // DefaultRedisCacheConfig returns the default Redis cache configuration
@@ -42,6 +72,10 @@ func DefaultRedisCacheConfig() RedisCacheConfig {
 		DialTimeout:  5 * time.Second,
 		ReadTimeout:  3 * time.Second,
 		WriteTimeout: 3 * time.Second,
+
+		Codec:                JSONCodec{},
+		Compression:          CompressionNone,
+		CompressionThreshold: DefaultCompressionThreshold,
 	}
 }
 
@@ -68,14 +102,15 @@ func NewRedisCache(config RedisCacheConfig) (*RedisCache, error) {
 	}
 
 	return &RedisCache{
-		client: client,
-		config: config.Config,
+		client:     client,
+		config:     config.Config,
+		serializer: newSerializer(config.Codec, config.Compression, config.CompressionThreshold),
 	}, nil
 }
 
 // Get retrieves a value from the cache
 func (rc *RedisCache) Get(ctx context.Context, key string) (interface{}, error) {
-	val, err := rc.client.Get(ctx, key).Result()
+	val, err := rc.client.Get(ctx, key).Bytes()
 	if err == redis.Nil {
 		rc.stats.Misses++
 		return nil, ErrKeyNotFound
@@ -85,25 +120,48 @@ func (rc *RedisCache) Get(ctx context.Context, key string) (interface{}, error)
 	}
 
 	rc.stats.Hits++
+	return rc.decode(key, val)
+}
 
-	// Try to unmarshal as JSON
-	var result interface{}
-	if err := json.Unmarshal([]byte(val), &result); err != nil {
-		// Return as string if not JSON
-		return val, nil
+// Scan retrieves a value into dest, which must be a non-nil pointer
+func (rc *RedisCache) Scan(ctx context.Context, key string, dest interface{}) error {
+	val, err := rc.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		rc.stats.Misses++
+		return ErrKeyNotFound
+	}
+	if err != nil {
+		return &CacheError{Op: "get", Key: key, Err: err}
 	}
 
+	rc.stats.Hits++
+	if _, err := rc.serializer.decode(val, dest); err != nil {
+		return &CacheError{Op: "decode", Key: key, Err: err}
+	}
+	return nil
+}
+
+// decode deserializes a stored value. Unframed values that do not decode,
+// such as strings written by other clients, are returned as strings.
+func (rc *RedisCache) decode(key string, val []byte) (interface{}, error) {
+	var result interface{}
+	framed, err := rc.serializer.decode(val, &result)
+	if err != nil {
+		if !framed {
+			return string(val), nil
+		}
+		return nil, &CacheError{Op: "decode", Key: key, Err: err}
+	}
 	return result, nil
 }
 
 // Set stores a value in the cache with TTL
-func (rc *RedisCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+func (rc *RedisCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration, opts ...SetOption) error {
 	if ttl == 0 {
 		ttl = rc.config.DefaultTTL
 	}
 
-	// Serialize value to JSON
-	data, err := json.Marshal(value)
+	data, err := rc.serializer.encode(value)
 	if err != nil {
 		return &CacheError{Op: "set", Key: key, Err: err}
 	}
@@ -112,6 +170,16 @@ func (rc *RedisCache) Set(ctx context.Context, key string, value interface{}, tt
 		return &CacheError{Op: "set", Key: key, Err: err}
 	}
 
+	if options := applySetOptions(opts); len(options.Tags) > 0 {
+		tagKeys := make([]string, len(options.Tags))
+		for i, tag := range options.Tags {
+			tagKeys[i] = tagKeyPrefix + tag
+		}
+		if err := tagScript.Run(ctx, rc.client, tagKeys, key, ttl.Milliseconds()).Err(); err != nil {
+			return &CacheError{Op: "tag", Key: key, Err: err}
+		}
+	}
+
 	return nil
 }
 
@@ -201,13 +269,11 @@ func (rc *RedisCache) GetMulti(ctx context.Context, keys []string) (map[string]i
 	result := make(map[string]interface{})
 	for i, val := range vals {
 		if val != nil {
-			// Try to unmarshal as JSON
-			var v interface{}
-			if err := json.Unmarshal([]byte(val.(string)), &v); err == nil {
-				result[keys[i]] = v
-			} else {
-				result[keys[i]] = val
+			v, err := rc.decode(keys[i], []byte(val.(string)))
+			if err != nil {
+				return nil, err
 			}
+			result[keys[i]] = v
 		}
 	}
 
@@ -223,7 +289,7 @@ func (rc *RedisCache) SetMulti(ctx context.Context, items map[string]interface{}
 	pipe := rc.client.Pipeline()
 
 	for key, value := range items {
-		data, err := json.Marshal(value)
+		data, err := rc.serializer.encode(value)
 		if err != nil {
 			return &CacheError{Op: "mset", Key: key, Err: err}
 		}
@@ -251,6 +317,31 @@ func (rc *RedisCache) DeleteMulti(ctx context.Context, keys []string) error {
 	return nil
 }
 
+// InvalidateTag removes every value tagged with tag. Keys that were
+// overwritten without the tag since are removed as well.
+func (rc *RedisCache) InvalidateTag(ctx context.Context, tag string) error {
+	keys, err := rc.tagKeys(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	keys = append(keys, tagKeyPrefix+tag)
+	if err := rc.client.Del(ctx, keys...).Err(); err != nil {
+		return &CacheError{Op: "invalidate", Key: tag, Err: err}
+	}
+
+	return nil
+}
+
+// tagKeys returns the keys tagged with tag
+func (rc *RedisCache) tagKeys(ctx context.Context, tag string) ([]string, error) {
+	keys, err := rc.client.SMembers(ctx, tagKeyPrefix+tag).Result()
+	if err != nil {
+		return nil, &CacheError{Op: "invalidate", Key: tag, Err: err}
+	}
+	return keys, nil
+}
+
 // Stats returns cache statistics
 func (rc *RedisCache) Stats(ctx context.Context) (*Stats, error) {
 	info, err := rc.client.Info(ctx, "stats", "memory").Result()
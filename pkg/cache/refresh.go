@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// RefreshFunc reloads a key for refresh-ahead, returning its new value and
+// TTL (zero for the cache's default)
+type RefreshFunc func(ctx context.Context, key string) (interface{}, time.Duration, error)
+
+// refreshLoader is a RefreshFunc registered for a key prefix
+type refreshLoader struct {
+	prefix string
+	load   RefreshFunc
+}
+
+// RegisterLoader registers the loader refreshing keys with prefix ahead of
+// their expiry. An empty prefix matches every key; the longest matching
+// prefix wins. Refresh-ahead must be enabled with
+// MultiTierConfig.RefreshAhead.
+func (mtc *MultiTierCache) RegisterLoader(prefix string, load RefreshFunc) {
+	mtc.loadersMu.Lock()
+	defer mtc.loadersMu.Unlock()
+
+	for i, loader := range mtc.loaders {
+		if loader.prefix == prefix {
+			mtc.loaders[i].load = load
+			return
+		}
+	}
+	mtc.loaders = append(mtc.loaders, refreshLoader{prefix: prefix, load: load})
+}
+
+// loaderFor returns the loader registered for key, if any
+func (mtc *MultiTierCache) loaderFor(key string) RefreshFunc {
+	mtc.loadersMu.RLock()
+	defer mtc.loadersMu.RUnlock()
+
+	var match *refreshLoader
+	for i, loader := range mtc.loaders {
+		if strings.HasPrefix(key, loader.prefix) && (match == nil || len(loader.prefix) > len(match.prefix)) {
+			match = &mtc.loaders[i]
+		}
+	}
+	if match == nil {
+		return nil
+	}
+	return match.load
+}
+
+// refreshAhead reloads key in the background if its remaining TTL in tier
+// has fallen within the refresh-ahead window. The caller must hold mtc.mu.
+func (mtc *MultiTierCache) refreshAhead(ctx context.Context, key string, tier Cache) {
+	if mtc.refreshWindow <= 0 {
+		return
+	}
+	load := mtc.loaderFor(key)
+	if load == nil {
+		return
+	}
+
+	ttl, err := tier.TTL(ctx, key)
+	if err != nil || ttl <= 0 || ttl > mtc.refreshWindow {
+		return
+	}
+	if _, running := mtc.refreshing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	go func() {
+		defer mtc.refreshing.Delete(key)
+
+		refreshCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), mtc.config.Timeout)
+		defer cancel()
+
+		value, ttl, err := load(refreshCtx, key)
+		if err == nil {
+			err = mtc.Set(refreshCtx, key, value, ttl)
+		}
+		mtc.recordRefresh(key, err)
+	}()
+}
@@ -0,0 +1,340 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ResponseCacheOptions configures ResponseCache
+type ResponseCacheOptions struct {
+	// KeyPrefix namespaces cache keys and tags
+	KeyPrefix string
+
+	// DefaultTTL applies to responses without a max-age or route TTL
+	DefaultTTL time.Duration
+
+	// Routes overrides DefaultTTL per route, keyed by the route's
+	// registered path, e.g. "/api/v1/products/:id". A negative TTL
+	// disables caching for the route. A response's own s-maxage or
+	// max-age still wins.
+	Routes map[string]time.Duration
+
+	// VaryHeaders are request headers every response varies by, on top of
+	// the response's own Vary header
+	VaryHeaders []string
+
+	// StatusCodes are the cacheable response statuses
+	StatusCodes []int
+
+	// Skip bypasses the cache for a request
+	Skip func(c *fiber.Ctx) bool
+
+	// Tags returns extra tags for a response, so related responses can be
+	// purged together, e.g. "products"
+	Tags func(c *fiber.Ctx) []string
+}
+
+// DefaultResponseCacheOptions returns the default response cache options
+func DefaultResponseCacheOptions() ResponseCacheOptions {
+	return ResponseCacheOptions{
+		KeyPrefix:   "http:",
+		DefaultTTL:  time.Minute,
+		VaryHeaders: []string{fiber.HeaderAcceptEncoding},
+		StatusCodes: []int{fiber.StatusOK},
+	}
+}
+
+// Response cache headers
+const (
+	HeaderCacheStatus = "X-Cache"
+	cacheStatusHit    = "HIT"
+	cacheStatusMiss   = "MISS"
+	cacheStatusBypass = "BYPASS"
+)
+
+// responseHeadersSkipped are response headers never replayed from cache
+var responseHeadersSkipped = map[string]bool{
+	fiber.HeaderSetCookie:        true,
+	fiber.HeaderDate:             true,
+	fiber.HeaderConnection:       true,
+	fiber.HeaderContentLength:    true,
+	fiber.HeaderTransferEncoding: true,
+	fiber.HeaderXRequestID:       true,
+	HeaderCacheStatus:            true,
+}
+
+// cachedResponse is a stored response
+type cachedResponse struct {
+	Status   int               `json:"status"`
+	Headers  map[string]string `json:"headers"`
+	Body     []byte            `json:"body"`
+	StoredAt int64             `json:"stored_at"` // Unix seconds
+}
+
+// varyIndex records which request headers the responses of a URL vary by
+type varyIndex struct {
+	Headers []string `json:"headers"`
+}
+
+// Let GobCodec round-trip entries as their own types
+func init() {
+	gob.Register(&cachedResponse{})
+	gob.Register(&varyIndex{})
+}
+
+// ResponseCache returns a middleware caching GET responses in c, keyed by
+// path, query and the request headers named in Vary.
+//
+// Request Cache-Control no-store bypasses the cache and no-cache (or
+// max-age=0) skips the lookup but stores the fresh response. Responses
+// marked no-store or private, or setting cookies, are not stored.
+// Requests with an Authorization header are never served from the cache,
+// and their responses are only stored when marked public, unless
+// Authorization is one of the VaryHeaders. Responses carry X-Cache (HIT,
+// MISS or BYPASS) and, on hits, Age.
+func ResponseCache(c Cache, opts ResponseCacheOptions) fiber.Handler {
+	defaults := DefaultResponseCacheOptions()
+	if opts.KeyPrefix == "" {
+		opts.KeyPrefix = defaults.KeyPrefix
+	}
+	if opts.DefaultTTL <= 0 {
+		opts.DefaultTTL = defaults.DefaultTTL
+	}
+	if len(opts.StatusCodes) == 0 {
+		opts.StatusCodes = defaults.StatusCodes
+	}
+	vary := make([]string, len(opts.VaryHeaders))
+	for i, header := range opts.VaryHeaders {
+		vary[i] = http.CanonicalHeaderKey(header)
+	}
+	opts.VaryHeaders = vary
+
+	return func(ctx *fiber.Ctx) error {
+		method := ctx.Method()
+		if method != fiber.MethodGet && method != fiber.MethodHead {
+			return ctx.Next()
+		}
+
+		directives := parseCacheControl(ctx.Get(fiber.HeaderCacheControl))
+		if _, noStore := directives["no-store"]; noStore || (opts.Skip != nil && opts.Skip(ctx)) {
+			ctx.Set(HeaderCacheStatus, cacheStatusBypass)
+			return ctx.Next()
+		}
+
+		urlKey := opts.KeyPrefix + hashKey(ctx.Path()+"?"+string(ctx.Request().URI().QueryString()))
+		_, noCache := directives["no-cache"]
+		if maxAge, ok := directives["max-age"]; ok && maxAge == "0" {
+			noCache = true
+		}
+		if isSharedAuthorized(ctx, opts) {
+			noCache = true
+		}
+
+		if !noCache {
+			var index varyIndex
+			if err := GetInto(ctx.Context(), c, urlKey, &index); err == nil {
+				var response cachedResponse
+				if err := GetInto(ctx.Context(), c, variantKey(ctx, urlKey, index.Headers), &response); err == nil {
+					return replayResponse(ctx, &response)
+				}
+			}
+		}
+
+		if err := ctx.Next(); err != nil {
+			return err
+		}
+		ctx.Set(HeaderCacheStatus, cacheStatusMiss)
+
+		if method == fiber.MethodGet {
+			storeResponse(ctx, c, opts, urlKey)
+		}
+		return nil
+	}
+}
+
+// RegisterPurgeRoutes registers operator endpoints purging responses
+// cached by ResponseCache with the same options. Mount them on a
+// protected router:
+//
+//	DELETE /responses              purges every cached response
+//	DELETE /responses/path?path=/x purges all variants of a path
+//	DELETE /responses/tags/:tag    purges responses tagged by Options.Tags
+func RegisterPurgeRoutes(router fiber.Router, c Cache, opts ResponseCacheOptions) {
+	if opts.KeyPrefix == "" {
+		opts.KeyPrefix = DefaultResponseCacheOptions().KeyPrefix
+	}
+
+	purge := func(ctx *fiber.Ctx, tag string) error {
+		if err := c.InvalidateTag(ctx.Context(), tag); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+		return ctx.JSON(fiber.Map{"success": true, "purged": tag})
+	}
+
+	router.Delete("/responses", func(ctx *fiber.Ctx) error {
+		return purge(ctx, allResponsesTag(opts.KeyPrefix))
+	})
+	router.Delete("/responses/path", func(ctx *fiber.Ctx) error {
+		path := ctx.Query("path")
+		if path == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "path is required")
+		}
+		return purge(ctx, pathTag(opts.KeyPrefix, path))
+	})
+	router.Delete("/responses/tags/:tag", func(ctx *fiber.Ctx) error {
+		return purge(ctx, opts.KeyPrefix+"tag:"+ctx.Params("tag"))
+	})
+}
+
+// storeResponse caches the response of ctx if it is cacheable
+func storeResponse(ctx *fiber.Ctx, c Cache, opts ResponseCacheOptions, urlKey string) {
+	response := ctx.Response()
+	if !containsStatus(opts.StatusCodes, response.StatusCode()) || len(response.Header.Peek(fiber.HeaderSetCookie)) > 0 {
+		return
+	}
+
+	directives := parseCacheControl(string(response.Header.Peek(fiber.HeaderCacheControl)))
+	_, noStore := directives["no-store"]
+	_, private := directives["private"]
+	_, public := directives["public"]
+	if noStore || private || (isSharedAuthorized(ctx, opts) && !public) {
+		return
+	}
+
+	ttl := opts.DefaultTTL
+	if routeTTL, ok := opts.Routes[ctx.Route().Path]; ok {
+		if routeTTL < 0 {
+			return
+		}
+		ttl = routeTTL
+	}
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[directive]; ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			if seconds <= 0 {
+				return
+			}
+			ttl = time.Duration(seconds) * time.Second
+			break
+		}
+	}
+
+	vary := append([]string{}, opts.VaryHeaders...)
+	for _, header := range strings.Split(string(response.Header.Peek(fiber.HeaderVary)), ",") {
+		header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+		if header == "*" {
+			return
+		}
+		if header != "" && !containsString(vary, header) {
+			vary = append(vary, header)
+		}
+	}
+	sort.Strings(vary)
+
+	stored := &cachedResponse{
+		Status:   response.StatusCode(),
+		Headers:  make(map[string]string),
+		Body:     append([]byte(nil), response.Body()...),
+		StoredAt: time.Now().Unix(),
+	}
+	response.Header.VisitAll(func(key, value []byte) {
+		if name := string(key); !responseHeadersSkipped[name] {
+			stored.Headers[name] = string(value)
+		}
+	})
+
+	tags := []string{allResponsesTag(opts.KeyPrefix), pathTag(opts.KeyPrefix, ctx.Path())}
+	if opts.Tags != nil {
+		for _, tag := range opts.Tags(ctx) {
+			tags = append(tags, opts.KeyPrefix+"tag:"+tag)
+		}
+	}
+
+	requestCtx := ctx.Context()
+	c.Set(requestCtx, urlKey, &varyIndex{Headers: vary}, ttl, WithTags(tags...))
+	c.Set(requestCtx, variantKey(ctx, urlKey, vary), stored, ttl, WithTags(tags...))
+}
+
+// replayResponse writes a cached response
+func replayResponse(ctx *fiber.Ctx, response *cachedResponse) error {
+	for key, value := range response.Headers {
+		ctx.Set(key, value)
+	}
+	ctx.Set(HeaderCacheStatus, cacheStatusHit)
+	ctx.Set(fiber.HeaderAge, strconv.FormatInt(max(time.Now().Unix()-response.StoredAt, 0), 10))
+	return ctx.Status(response.Status).Send(response.Body)
+}
+
+// variantKey keys a response by its URL and the request's values of the
+// headers it varies by
+func variantKey(ctx *fiber.Ctx, urlKey string, headers []string) string {
+	var b strings.Builder
+	for _, header := range headers {
+		b.WriteString(header)
+		b.WriteByte('=')
+		b.WriteString(ctx.Get(header))
+		b.WriteByte('\n')
+	}
+	return urlKey + ":" + hashKey(b.String())
+}
+
+// isSharedAuthorized reports whether an authorized request would share
+// cache entries with other users
+func isSharedAuthorized(ctx *fiber.Ctx, opts ResponseCacheOptions) bool {
+	return ctx.Get(fiber.HeaderAuthorization) != "" && !containsString(opts.VaryHeaders, fiber.HeaderAuthorization)
+}
+
+func allResponsesTag(prefix string) string {
+	return prefix + "responses"
+}
+
+func pathTag(prefix, path string) string {
+	return prefix + "path:" + path
+}
+
+func hashKey(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:16])
+}
+
+// parseCacheControl splits a Cache-Control header into lowercased
+// directives and their values
+func parseCacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			directives[name] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return directives
+}
+
+func containsStatus(statuses []int, status int) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
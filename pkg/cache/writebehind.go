@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/logger"
+)
+
+// writeBehind queues writes to the lower tiers of a MultiTierCache. Each
+// key is owned by one worker, so writes to a key land in order, and
+// repeated writes to a key still queued are coalesced into the latest.
+type writeBehind struct {
+	mtc     *MultiTierCache
+	queues  []chan string
+	pending map[string]*pendingWrite
+	size    int
+	closed  bool
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+}
+
+// pendingWrite is the latest queued write of a key
+type pendingWrite struct {
+	value    interface{}
+	ttl      time.Duration
+	opts     []SetOption
+	version  int       // Bumped by each coalesced write
+	queuedAt time.Time // First write not yet in the lower tiers
+	deleted  bool      // Set when the key is deleted while queued
+}
+
+func newWriteBehind(mtc *MultiTierCache, workers, size int) *writeBehind {
+	wb := &writeBehind{
+		mtc:     mtc,
+		queues:  make([]chan string, workers),
+		pending: make(map[string]*pendingWrite),
+		size:    size,
+	}
+	for i := range wb.queues {
+		// Sends never block; enqueue falls back to synchronous writes
+		wb.queues[i] = make(chan string, size)
+		wb.wg.Add(1)
+		go wb.worker(wb.queues[i])
+	}
+	return wb
+}
+
+// enqueue queues a write, reporting false when the queue is full or closed
+// and the caller must write synchronously
+func (wb *writeBehind) enqueue(key string, value interface{}, ttl time.Duration, opts []SetOption) bool {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	if wb.closed {
+		return false
+	}
+	if write, queued := wb.pending[key]; queued {
+		write.value, write.ttl, write.opts = value, ttl, opts
+		write.version++
+		return true
+	}
+	if len(wb.pending) >= wb.size {
+		return false
+	}
+
+	select {
+	case wb.queues[wb.shard(key)] <- key:
+		wb.pending[key] = &pendingWrite{value: value, ttl: ttl, opts: opts, queuedAt: time.Now()}
+		return true
+	default:
+		// Still full of keys deleted while queued
+		return false
+	}
+}
+
+// cancel drops the queued write of a deleted key
+func (wb *writeBehind) cancel(key string) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	if write, queued := wb.pending[key]; queued {
+		write.deleted = true
+		delete(wb.pending, key)
+	}
+}
+
+// len returns the number of keys waiting for the lower tiers
+func (wb *writeBehind) len() int {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return len(wb.pending)
+}
+
+// flush waits until every queued write has landed or failed
+func (wb *writeBehind) flush(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for wb.len() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// close stops accepting writes and stops the workers once their queues
+// are drained
+func (wb *writeBehind) close() {
+	wb.mu.Lock()
+	if wb.closed {
+		wb.mu.Unlock()
+		return
+	}
+	wb.closed = true
+	for _, queue := range wb.queues {
+		close(queue)
+	}
+	wb.mu.Unlock()
+
+	wb.wg.Wait()
+}
+
+func (wb *writeBehind) shard(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(wb.queues)))
+}
+
+func (wb *writeBehind) worker(queue <-chan string) {
+	defer wb.wg.Done()
+
+	for key := range queue {
+		for {
+			wb.mu.Lock()
+			write, queued := wb.pending[key]
+			if !queued {
+				wb.mu.Unlock()
+				break // Deleted meanwhile
+			}
+			version, value, ttl, opts := write.version, write.value, write.ttl, write.opts
+			wb.mu.Unlock()
+
+			retries, err := wb.write(key, write, value, ttl, opts)
+
+			// Writes coalesced while this one ran are written next
+			wb.mu.Lock()
+			done := wb.pending[key] != write || write.version == version
+			if wb.pending[key] == write && done {
+				delete(wb.pending, key)
+			}
+			wb.mu.Unlock()
+
+			wb.mtc.recordWrite(key, time.Since(write.queuedAt), retries, err)
+			if err != nil {
+				logger.Warn("Cache write-behind dropped", logger.Fields{"key": key, "error": err.Error()})
+			}
+			if done {
+				break
+			}
+		}
+	}
+}
+
+// write stores a value in every lower tier, retrying failures with a
+// linear backoff. It gives up early if the key is deleted.
+func (wb *writeBehind) write(key string, write *pendingWrite, value interface{}, ttl time.Duration, opts []SetOption) (int, error) {
+	mtc := wb.mtc
+	mtc.mu.RLock()
+	defer mtc.mu.RUnlock()
+
+	var lastErr error
+	retries := 0
+	for i := 1; i < len(mtc.tiers); i++ {
+		for attempt := 0; ; attempt++ {
+			wb.mu.Lock()
+			deleted := write.deleted
+			wb.mu.Unlock()
+			if deleted {
+				return retries, nil
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), mtc.config.Timeout)
+			err := mtc.tiers[i].cache.Set(ctx, key, value, ttl, opts...)
+			cancel()
+			if err == nil {
+				break
+			}
+			if attempt >= mtc.config.MaxRetries {
+				lastErr = err
+				break
+			}
+			retries++
+			time.Sleep(mtc.config.RetryDelay * time.Duration(attempt+1))
+		}
+	}
+	return retries, lastErr
+}
@@ -0,0 +1,12 @@
+// Package contracts defines the services modules publish to each other.
+// A module implements a contract and publishes it with
+// core.ProvideService; others resolve it with core.Service, without
+// importing the module. Each contract has a stub, registered by the app,
+// used while the providing module is disabled; its methods return
+// ErrUnavailable.
+package contracts
+
+import "errors"
+
+// ErrUnavailable is returned when no enabled module provides a service
+var ErrUnavailable = errors.New("service unavailable")
@@ -0,0 +1,23 @@
+package contracts
+
+import "context"
+
+// GeoLocatorVersion is the version of GeoLocator the security module
+// publishes
+const GeoLocatorVersion = "1.0.0"
+
+// GeoLocator resolves IP addresses to countries, published by the security
+// module when SECURITY_GEOIP_URL is set
+type GeoLocator interface {
+	// Country returns the ISO country code of a public IP address, empty
+	// when unknown
+	Country(ctx context.Context, ip string) (string, error)
+}
+
+// NoGeoLocator is the GeoLocator stub
+type NoGeoLocator struct{}
+
+// Country implements GeoLocator
+func (NoGeoLocator) Country(ctx context.Context, ip string) (string, error) {
+	return "", ErrUnavailable
+}
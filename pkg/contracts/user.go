@@ -0,0 +1,35 @@
+package contracts
+
+import "context"
+
+// UserLookupVersion is the version of UserLookup the user module publishes
+const UserLookupVersion = "1.0.0"
+
+// UserInfo is the public profile of a user
+type UserInfo struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	Active   bool   `json:"active"`
+}
+
+// UserLookup finds users, published by the user module. Both methods
+// return a nil user and no error when there is no such user.
+type UserLookup interface {
+	LookupUser(ctx context.Context, id uint) (*UserInfo, error)
+	LookupUserByEmail(ctx context.Context, email string) (*UserInfo, error)
+}
+
+// NoUserLookup is the UserLookup stub
+type NoUserLookup struct{}
+
+// LookupUser implements UserLookup
+func (NoUserLookup) LookupUser(ctx context.Context, id uint) (*UserInfo, error) {
+	return nil, ErrUnavailable
+}
+
+// LookupUserByEmail implements UserLookup
+func (NoUserLookup) LookupUserByEmail(ctx context.Context, email string) (*UserInfo, error) {
+	return nil, ErrUnavailable
+}
@@ -1,15 +1,18 @@
 package database
 
 import (
+	"context"
 	"fmt"
 
 	"gorm.io/gorm"
 )
 
-// Migrator handles database migrations
+// Migrator handles database migrations: models registered with
+// RegisterModels, and the schema each module owns, see RegisterModule
 type Migrator struct {
-	db     *gorm.DB
-	models []interface{}
+	db      *gorm.DB
+	models  []interface{}
+	modules []*moduleSchema
 }
 
 // NewMigrator creates a new migrator
@@ -20,14 +23,21 @@ func NewMigrator(db *gorm.DB) *Migrator {
 	}
 }
 
+// WithDB returns a migrator for the same models and modules on another
+// database
+func (m *Migrator) WithDB(db *gorm.DB) *Migrator {
+	return &Migrator{db: db, models: m.models, modules: m.modules}
+}
+
 // RegisterModels registers models for migration
 func (m *Migrator) RegisterModels(models ...interface{}) {
 	m.models = append(m.models, models...)
 }
 
-// AutoMigrate runs auto migration for all registered models
+// AutoMigrate runs auto migration for all registered models, then
+// installs or migrates every registered module
 func (m *Migrator) AutoMigrate() error {
-	if len(m.models) == 0 {
+	if len(m.models) == 0 && len(m.modules) == 0 {
 		fmt.Println("⚠️  No models registered for migration")
 		return nil
 	}
@@ -40,6 +50,10 @@ func (m *Migrator) AutoMigrate() error {
 		}
 	}
 
+	if err := m.MigrateModules(context.Background()); err != nil {
+		return err
+	}
+
 	fmt.Println("✅ Database migration completed")
 	return nil
 }
@@ -0,0 +1,618 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	// ErrUnknownModule is returned for a module that registered no schema
+	ErrUnknownModule = errors.New("module has no registered schema")
+	// ErrModuleDependency is returned when installing a module whose
+	// tables reference a module that is not installed, or uninstalling one
+	// whose tables are referenced by an installed module
+	ErrModuleDependency = errors.New("module schema dependency")
+)
+
+// SchemaModule records a module whose schema is installed, or was
+// uninstalled and stays so until installed again
+type SchemaModule struct {
+	Name          string `gorm:"primaryKey"`
+	InstalledAt   time.Time
+	UninstalledAt *time.Time
+}
+
+// TableName keeps module schemas apart from the modules' own tables
+func (SchemaModule) TableName() string {
+	return "schema_modules"
+}
+
+// SchemaMigration records a migration applied to a module's schema
+type SchemaMigration struct {
+	ID        uint   `gorm:"primaryKey"`
+	Module    string `gorm:"uniqueIndex:idx_schema_migration;not null"`
+	Version   string `gorm:"uniqueIndex:idx_schema_migration;not null"`
+	Batch     int    `gorm:"not null"`
+	AppliedAt time.Time
+}
+
+// TableName keeps migrations apart from the modules' own tables
+func (SchemaMigration) TableName() string {
+	return "schema_module_migrations"
+}
+
+// ModuleMigration is one SQL migration of a module, read from a pair of
+// files in its migration directory:
+//
+//	20240601120000_create_links.up.sql
+//	20240601120000_create_links.down.sql
+//
+// Migrations are applied in version order, the file name before
+// ".up.sql". Each file runs as one statement batch in a transaction; MySQL
+// needs multiStatements=true in its DSN for files with several statements.
+type ModuleMigration struct {
+	Version string
+	Up      string
+	Down    string // Empty if the migration cannot be rolled back
+}
+
+// ModuleSchemaStatus describes a module's schema
+type ModuleSchemaStatus struct {
+	Module    string   `json:"module"`
+	Installed bool     `json:"installed"`
+	Applied   []string `json:"applied"`
+	Pending   []string `json:"pending"`
+	Tables    []string `json:"tables"`     // Tables the module owns
+	DependsOn []string `json:"depends_on"` // Modules its foreign keys reference
+}
+
+// moduleSchema is what a module registered: its models and migrations
+type moduleSchema struct {
+	name       string
+	models     []interface{}
+	migrations fs.FS
+}
+
+// RegisterModule registers the schema a module owns: models migrated with
+// AutoMigrate, and a directory of SQL migrations, which may be nil.
+// Registering a module again adds to its models and replaces its
+// migrations if given.
+func (m *Migrator) RegisterModule(name string, migrations fs.FS, models ...interface{}) {
+	for _, module := range m.modules {
+		if module.name == name {
+			module.models = append(module.models, models...)
+			if migrations != nil {
+				module.migrations = migrations
+			}
+			return
+		}
+	}
+	m.modules = append(m.modules, &moduleSchema{name: name, models: models, migrations: migrations})
+}
+
+// MigrateModules installs every registered module, or applies its pending
+// migrations if installed, in the order their foreign keys require.
+// Modules uninstalled with UninstallModule are skipped until installed
+// again with InstallModule.
+func (m *Migrator) MigrateModules(ctx context.Context) error {
+	if len(m.modules) == 0 {
+		return nil
+	}
+	if err := m.migrateTracking(); err != nil {
+		return err
+	}
+
+	graph, err := m.dependencies()
+	if err != nil {
+		return err
+	}
+	order, err := installOrder(m.modules, graph)
+	if err != nil {
+		return err
+	}
+	records, err := m.moduleRecords(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range order {
+		if record, ok := records[name]; ok && record.UninstalledAt != nil {
+			fmt.Printf("⏹️  Module %s is uninstalled, skipping migrations\n", name)
+			continue
+		}
+		if err := m.install(ctx, m.module(name), graph); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InstallModule installs a module's schema, or applies its pending
+// migrations if installed. The modules its foreign keys reference must be
+// installed first.
+func (m *Migrator) InstallModule(ctx context.Context, name string) error {
+	module := m.module(name)
+	if module == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownModule, name)
+	}
+	if err := m.migrateTracking(); err != nil {
+		return err
+	}
+	graph, err := m.dependencies()
+	if err != nil {
+		return err
+	}
+	return m.install(ctx, module, graph)
+}
+
+// UninstallModule rolls back a module's migrations, newest first, and
+// drops the tables of its models. It fails while an installed module
+// references its tables, or if an applied migration has no down file.
+func (m *Migrator) UninstallModule(ctx context.Context, name string) error {
+	module := m.module(name)
+	if module == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownModule, name)
+	}
+	if err := m.migrateTracking(); err != nil {
+		return err
+	}
+	graph, err := m.dependencies()
+	if err != nil {
+		return err
+	}
+	installed, err := m.installedModules(ctx)
+	if err != nil {
+		return err
+	}
+	for _, other := range m.modules {
+		if other.name != name && installed[other.name] && contains(graph[other.name], name) {
+			return fmt.Errorf("%w: %s references tables of %s; uninstall it first", ErrModuleDependency, other.name, name)
+		}
+	}
+
+	fmt.Printf("⚠️  Uninstalling module %s...\n", name)
+	if err := m.rollback(ctx, module, 0); err != nil {
+		return err
+	}
+	for i := len(module.models) - 1; i >= 0; i-- {
+		if err := m.db.WithContext(ctx).Migrator().DropTable(module.models[i]); err != nil {
+			return fmt.Errorf("module %s: failed to drop table: %w", name, err)
+		}
+	}
+	now := time.Now()
+	result := m.db.WithContext(ctx).Model(&SchemaModule{}).Where("name = ?", name).Update("uninstalled_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		record := &SchemaModule{Name: name, InstalledAt: now, UninstalledAt: &now}
+		if err := m.db.WithContext(ctx).Create(record).Error; err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("✅ Module %s uninstalled\n", name)
+	return nil
+}
+
+// RollbackModule rolls back the last batch of migrations applied to a
+// module, newest first
+func (m *Migrator) RollbackModule(ctx context.Context, name string) error {
+	module := m.module(name)
+	if module == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownModule, name)
+	}
+	if err := m.migrateTracking(); err != nil {
+		return err
+	}
+
+	var batch int
+	if err := m.db.WithContext(ctx).Model(&SchemaMigration{}).Where("module = ?", name).
+		Select("COALESCE(MAX(batch), 0)").Scan(&batch).Error; err != nil {
+		return err
+	}
+	if batch == 0 {
+		return nil
+	}
+	return m.rollback(ctx, module, batch)
+}
+
+// ModuleStatus describes the schema of every registered module
+func (m *Migrator) ModuleStatus(ctx context.Context) ([]ModuleSchemaStatus, error) {
+	if err := m.migrateTracking(); err != nil {
+		return nil, err
+	}
+	graph, err := m.dependencies()
+	if err != nil {
+		return nil, err
+	}
+	installed, err := m.installedModules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	owners, err := m.owners()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]ModuleSchemaStatus, 0, len(m.modules))
+	for _, module := range m.modules {
+		migrations, err := module.read()
+		if err != nil {
+			return nil, err
+		}
+		applied, err := m.applied(ctx, module.name)
+		if err != nil {
+			return nil, err
+		}
+
+		status := ModuleSchemaStatus{
+			Module:    module.name,
+			Installed: installed[module.name],
+			Applied:   []string{},
+			Pending:   []string{},
+			Tables:    []string{},
+			DependsOn: append([]string{}, graph[module.name]...),
+		}
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; ok {
+				status.Applied = append(status.Applied, migration.Version)
+			} else {
+				status.Pending = append(status.Pending, migration.Version)
+			}
+		}
+		for table, owner := range owners {
+			if owner == module.name {
+				status.Tables = append(status.Tables, table)
+			}
+		}
+		sort.Strings(status.Tables)
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// ModuleDependencies returns the modules each module's foreign keys
+// reference, from its models' relations and the REFERENCES clauses of its
+// migrations. Tables no module owns, e.g. those registered with
+// RegisterModels, are not dependencies.
+func (m *Migrator) ModuleDependencies() (map[string][]string, error) {
+	return m.dependencies()
+}
+
+// install migrates a module's models and applies its pending migrations
+// in one batch
+func (m *Migrator) install(ctx context.Context, module *moduleSchema, graph map[string][]string) error {
+	installed, err := m.installedModules(ctx)
+	if err != nil {
+		return err
+	}
+	for _, dependency := range graph[module.name] {
+		if !installed[dependency] {
+			return fmt.Errorf("%w: %s references tables of %s, which is not installed", ErrModuleDependency, module.name, dependency)
+		}
+	}
+
+	for _, model := range module.models {
+		if err := m.db.WithContext(ctx).AutoMigrate(model); err != nil {
+			return fmt.Errorf("module %s: failed to migrate model: %w", module.name, err)
+		}
+	}
+
+	migrations, err := module.read()
+	if err != nil {
+		return err
+	}
+	applied, err := m.applied(ctx, module.name)
+	if err != nil {
+		return err
+	}
+	var batch int
+	if err := m.db.WithContext(ctx).Model(&SchemaMigration{}).Where("module = ?", module.name).
+		Select("COALESCE(MAX(batch), 0)").Scan(&batch).Error; err != nil {
+		return err
+	}
+	batch++
+
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		fmt.Printf("🔄 Migrating %s: %s\n", module.name, migration.Version)
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Module:    module.name,
+				Version:   migration.Version,
+				Batch:     batch,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("module %s: migration %s failed: %w", module.name, migration.Version, err)
+		}
+	}
+
+	if !installed[module.name] {
+		record := &SchemaModule{Name: module.name, InstalledAt: time.Now()}
+		if err := m.db.WithContext(ctx).Save(record).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback rolls back a module's applied migrations of a batch, or all of
+// them for batch 0, newest first
+func (m *Migrator) rollback(ctx context.Context, module *moduleSchema, batch int) error {
+	migrations, err := module.read()
+	if err != nil {
+		return err
+	}
+	byVersion := make(map[string]ModuleMigration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	query := m.db.WithContext(ctx).Where("module = ?", module.name)
+	if batch > 0 {
+		query = query.Where("batch = ?", batch)
+	}
+	var records []SchemaMigration
+	if err := query.Order("version DESC").Find(&records).Error; err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		migration, ok := byVersion[record.Version]
+		if !ok || migration.Down == "" {
+			return fmt.Errorf("module %s: migration %s has no down migration", module.name, record.Version)
+		}
+		fmt.Printf("↩️  Rolling back %s: %s\n", module.name, record.Version)
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(migration.Down).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, record.ID).Error
+		})
+		if err != nil {
+			return fmt.Errorf("module %s: rollback of %s failed: %w", module.name, record.Version, err)
+		}
+	}
+	return nil
+}
+
+// migrateTracking creates the tables recording installed modules and
+// applied migrations
+func (m *Migrator) migrateTracking() error {
+	if err := m.db.AutoMigrate(&SchemaModule{}, &SchemaMigration{}); err != nil {
+		return fmt.Errorf("failed to migrate schema tracking tables: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) moduleRecords(ctx context.Context) (map[string]SchemaModule, error) {
+	var records []SchemaModule
+	if err := m.db.WithContext(ctx).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	byName := make(map[string]SchemaModule, len(records))
+	for _, record := range records {
+		byName[record.Name] = record
+	}
+	return byName, nil
+}
+
+func (m *Migrator) installedModules(ctx context.Context) (map[string]bool, error) {
+	records, err := m.moduleRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	installed := make(map[string]bool, len(records))
+	for name, record := range records {
+		installed[name] = record.UninstalledAt == nil
+	}
+	return installed, nil
+}
+
+// applied returns a module's applied migrations by version
+func (m *Migrator) applied(ctx context.Context, module string) (map[string]SchemaMigration, error) {
+	var records []SchemaMigration
+	if err := m.db.WithContext(ctx).Where("module = ?", module).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[string]SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+func (m *Migrator) module(name string) *moduleSchema {
+	for _, module := range m.modules {
+		if module.name == name {
+			return module
+		}
+	}
+	return nil
+}
+
+var (
+	createTablePattern = regexp.MustCompile("(?i)create\\s+table\\s+(?:if\\s+not\\s+exists\\s+)?[\"`]?(?:\\w+[\"`]?\\.[\"`]?)?(\\w+)")
+	referencesPattern  = regexp.MustCompile("(?i)references\\s+[\"`]?(?:\\w+[\"`]?\\.[\"`]?)?(\\w+)")
+)
+
+// owners maps each table to the module owning it: the tables of its
+// models and those its migrations create
+func (m *Migrator) owners() (map[string]string, error) {
+	owners := make(map[string]string)
+	claim := func(table, module string) error {
+		if owner, ok := owners[table]; ok && owner != module {
+			return fmt.Errorf("table %s is owned by both %s and %s", table, owner, module)
+		}
+		owners[table] = module
+		return nil
+	}
+
+	for _, module := range m.modules {
+		for _, model := range module.models {
+			parsed, err := m.parse(model)
+			if err != nil {
+				return nil, fmt.Errorf("module %s: %w", module.name, err)
+			}
+			if err := claim(parsed.Table, module.name); err != nil {
+				return nil, err
+			}
+		}
+		migrations, err := module.read()
+		if err != nil {
+			return nil, err
+		}
+		for _, migration := range migrations {
+			for _, match := range createTablePattern.FindAllStringSubmatch(migration.Up, -1) {
+				if err := claim(match[1], module.name); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return owners, nil
+}
+
+// dependencies maps each module to the other modules its foreign keys
+// reference
+func (m *Migrator) dependencies() (map[string][]string, error) {
+	owners, err := m.owners()
+	if err != nil {
+		return nil, err
+	}
+
+	graph := make(map[string][]string)
+	depend := func(module, table string) {
+		owner, ok := owners[table]
+		if ok && owner != module && !contains(graph[module], owner) {
+			graph[module] = append(graph[module], owner)
+		}
+	}
+
+	for _, module := range m.modules {
+		for _, model := range module.models {
+			parsed, err := m.parse(model)
+			if err != nil {
+				return nil, fmt.Errorf("module %s: %w", module.name, err)
+			}
+			for _, relation := range parsed.Relationships.Relations {
+				constraint := relation.ParseConstraint()
+				// The table holding the foreign key depends on the one it
+				// references; only this module's tables count
+				if constraint == nil || owners[constraint.Schema.Table] != module.name {
+					continue
+				}
+				depend(module.name, constraint.ReferenceSchema.Table)
+			}
+		}
+		migrations, err := module.read()
+		if err != nil {
+			return nil, err
+		}
+		for _, migration := range migrations {
+			for _, match := range referencesPattern.FindAllStringSubmatch(migration.Up, -1) {
+				depend(module.name, match[1])
+			}
+		}
+		sort.Strings(graph[module.name])
+	}
+	return graph, nil
+}
+
+// parse parses a model's schema with the database's naming strategy
+func (m *Migrator) parse(model interface{}) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: m.db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("failed to parse model: %w", err)
+	}
+	return stmt.Schema, nil
+}
+
+// installOrder sorts modules so each comes after those it references,
+// keeping registration order otherwise
+func installOrder(modules []*moduleSchema, graph map[string][]string) ([]string, error) {
+	order := make([]string, 0, len(modules))
+	state := make(map[string]int) // 1 visiting, 2 done
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("%w: foreign keys form a cycle: %s", ErrModuleDependency, strings.Join(append(path, name), " -> "))
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, dependency := range graph[name] {
+			if err := visit(dependency, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		order = append(order, name)
+		return nil
+	}
+
+	for _, module := range modules {
+		if err := visit(module.name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// read reads a module's migrations, sorted by version
+func (module *moduleSchema) read() ([]ModuleMigration, error) {
+	if module.migrations == nil {
+		return nil, nil
+	}
+	entries, err := fs.ReadDir(module.migrations, ".")
+	if err != nil {
+		return nil, fmt.Errorf("module %s: failed to read migrations: %w", module.name, err)
+	}
+
+	var migrations []ModuleMigration
+	for _, entry := range entries {
+		version, ok := strings.CutSuffix(entry.Name(), ".up.sql")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		up, err := fs.ReadFile(module.migrations, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("module %s: %w", module.name, err)
+		}
+		down, err := fs.ReadFile(module.migrations, version+".down.sql")
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("module %s: %w", module.name, err)
+		}
+		migrations = append(migrations, ModuleMigration{Version: version, Up: string(up), Down: string(down)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,662 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TimeSeriesPoint is a single raw sample in a time-series table
+type TimeSeriesPoint struct {
+	ID     uint64    `gorm:"primarykey" json:"-"`
+	Series string    `gorm:"size:255;not null;index:idx_ts_series_time,priority:1" json:"series"`
+	Time   time.Time `gorm:"not null;index:idx_ts_series_time,priority:2" json:"time"`
+	Value  float64   `json:"value"`
+	Tags   string    `gorm:"type:text" json:"-"`
+}
+
+// TimeSeriesRollup is a pre-aggregated bucket of raw points
+type TimeSeriesRollup struct {
+	Series      string    `gorm:"primaryKey;size:255" json:"series"`
+	BucketStart time.Time `gorm:"primaryKey" json:"bucket_start"`
+	Count       int64     `json:"count"`
+	Sum         float64   `json:"sum"`
+	Min         float64   `json:"min"`
+	Max         float64   `json:"max"`
+}
+
+// TimeSeriesBucket is an aggregated query result
+type TimeSeriesBucket struct {
+	Start time.Time `json:"start"`
+	Value float64   `json:"value"`
+	Count int64     `json:"count"`
+}
+
+// Aggregation functions supported by Query
+const (
+	AggAvg   = "avg"
+	AggSum   = "sum"
+	AggMin   = "min"
+	AggMax   = "max"
+	AggCount = "count"
+)
+
+// TimeSeriesQuery describes a bucketed read
+type TimeSeriesQuery struct {
+	Series      string
+	From        time.Time
+	To          time.Time
+	Bucket      time.Duration     // Zero returns one bucket for the whole range
+	Aggregation string            // avg, sum, min, max, count
+	Tags        map[string]string // Raw points only: all tags must match
+}
+
+// TimeSeriesConfig holds time-series store configuration
+type TimeSeriesConfig struct {
+	Table             string        // Raw points table
+	RollupTable       string        // Rollup table
+	RollupInterval    time.Duration // Rollup bucket size
+	Retention         time.Duration // Raw point retention (0 = keep forever)
+	RollupRetention   time.Duration // Rollup retention (0 = keep forever)
+	PartitionInterval time.Duration // Postgres native partition size
+	PartitionAhead    int           // Partitions to create ahead of now
+	UseTimescale      bool          // Use TimescaleDB hypertables when available
+	JobInterval       time.Duration // How often rollup and pruning run
+}
+
+// DefaultTimeSeriesConfig returns default time-series configuration
+func DefaultTimeSeriesConfig() TimeSeriesConfig {
+	return TimeSeriesConfig{
+		Table:             "timeseries_points",
+		RollupTable:       "timeseries_rollups",
+		RollupInterval:    time.Hour,
+		Retention:         30 * 24 * time.Hour,
+		RollupRetention:   365 * 24 * time.Hour,
+		PartitionInterval: 24 * time.Hour,
+		PartitionAhead:    3,
+		UseTimescale:      true,
+		JobInterval:       5 * time.Minute,
+	}
+}
+
+// TimeSeriesStore manages append-heavy time-series tables for business
+// telemetry. On Postgres it uses TimescaleDB hypertables when the
+// extension is installed and falls back to native range partitions;
+// other drivers use a plain indexed table.
+type TimeSeriesStore struct {
+	db         *gorm.DB
+	config     TimeSeriesConfig
+	mode       string // "plain", "partitioned", or "hypertable"
+	lastRollup time.Time
+	mu         sync.Mutex
+	rollupMu   sync.Mutex // Serializes rollups, so a late re-roll is not overwritten by an older read
+	stopCh     chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewTimeSeriesStore creates a new time-series store
+func NewTimeSeriesStore(db *gorm.DB, config TimeSeriesConfig) *TimeSeriesStore {
+	defaults := DefaultTimeSeriesConfig()
+	if config.Table == "" {
+		config.Table = defaults.Table
+	}
+	if config.RollupTable == "" {
+		config.RollupTable = defaults.RollupTable
+	}
+	if config.RollupInterval <= 0 {
+		config.RollupInterval = defaults.RollupInterval
+	}
+	if config.PartitionInterval <= 0 {
+		config.PartitionInterval = defaults.PartitionInterval
+	}
+	if config.JobInterval <= 0 {
+		config.JobInterval = defaults.JobInterval
+	}
+
+	return &TimeSeriesStore{
+		db:     db,
+		config: config,
+		mode:   "plain",
+	}
+}
+
+// isPostgres reports whether the store runs on Postgres
+func (s *TimeSeriesStore) isPostgres() bool {
+	return s.db.Dialector.Name() == "postgres"
+}
+
+// Setup creates the raw and rollup tables
+func (s *TimeSeriesStore) Setup(ctx context.Context) error {
+	db := s.db.WithContext(ctx)
+
+	if err := db.Table(s.config.RollupTable).AutoMigrate(&TimeSeriesRollup{}); err != nil {
+		return fmt.Errorf("failed to migrate rollup table: %w", err)
+	}
+
+	if !s.isPostgres() {
+		if err := db.Table(s.config.Table).AutoMigrate(&TimeSeriesPoint{}); err != nil {
+			return fmt.Errorf("failed to migrate time-series table: %w", err)
+		}
+		return nil
+	}
+
+	if s.config.UseTimescale && s.hasTimescale(ctx) {
+		if err := db.Table(s.config.Table).AutoMigrate(&TimeSeriesPoint{}); err != nil {
+			return fmt.Errorf("failed to migrate time-series table: %w", err)
+		}
+		// Hypertables require the time column in every unique index
+		stmts := []string{
+			fmt.Sprintf(`ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s_pkey`, s.config.Table, s.config.Table),
+			fmt.Sprintf(`SELECT create_hypertable('%s', 'time', chunk_time_interval => INTERVAL '%d seconds', if_not_exists => TRUE, migrate_data => TRUE)`,
+				s.config.Table, int64(s.config.PartitionInterval.Seconds())),
+		}
+		for _, stmt := range stmts {
+			if err := db.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to create hypertable: %w", err)
+			}
+		}
+		s.mode = "hypertable"
+		return nil
+	}
+
+	stmt := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id BIGSERIAL,
+		series VARCHAR(255) NOT NULL,
+		time TIMESTAMPTZ NOT NULL,
+		value DOUBLE PRECISION,
+		tags TEXT
+	) PARTITION BY RANGE (time)`, s.config.Table)
+	if err := db.Exec(stmt).Error; err != nil {
+		return fmt.Errorf("failed to create partitioned table: %w", err)
+	}
+
+	index := fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_%s_series_time ON %s (series, time)`, s.config.Table, s.config.Table)
+	if err := db.Exec(index).Error; err != nil {
+		return fmt.Errorf("failed to create time-series index: %w", err)
+	}
+
+	s.mode = "partitioned"
+	return s.EnsurePartitions(ctx, time.Now())
+}
+
+// hasTimescale reports whether the timescaledb extension is installed
+func (s *TimeSeriesStore) hasTimescale(ctx context.Context) bool {
+	var count int64
+	err := s.db.WithContext(ctx).
+		Raw("SELECT COUNT(*) FROM pg_extension WHERE extname = 'timescaledb'").
+		Scan(&count).Error
+	return err == nil && count > 0
+}
+
+// partitionName returns the partition table name for a range start
+func (s *TimeSeriesStore) partitionName(start time.Time) string {
+	return fmt.Sprintf("%s_p%s", s.config.Table, start.UTC().Format("20060102150405"))
+}
+
+// EnsurePartitions creates native partitions covering the interval that
+// contains "at" plus PartitionAhead future intervals
+func (s *TimeSeriesStore) EnsurePartitions(ctx context.Context, at time.Time) error {
+	if s.mode != "partitioned" {
+		return nil
+	}
+
+	start := at.UTC().Truncate(s.config.PartitionInterval)
+	for i := 0; i <= s.config.PartitionAhead; i++ {
+		from := start.Add(time.Duration(i) * s.config.PartitionInterval)
+		to := from.Add(s.config.PartitionInterval)
+
+		stmt := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`,
+			s.partitionName(from), s.config.Table,
+			from.Format(time.RFC3339), to.Format(time.RFC3339))
+		if err := s.db.WithContext(ctx).Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to create partition: %w", err)
+		}
+	}
+	return nil
+}
+
+// Write appends a single point
+func (s *TimeSeriesStore) Write(ctx context.Context, series string, value float64, tags map[string]string, at time.Time) error {
+	return s.WriteBatch(ctx, []TimeSeriesPoint{{
+		Series: series,
+		Time:   at,
+		Value:  value,
+		Tags:   encodeTags(tags),
+	}})
+}
+
+// WriteBatch appends points in batches. Points landing in buckets already
+// rolled up are late: their buckets are rolled up again.
+func (s *TimeSeriesStore) WriteBatch(ctx context.Context, points []TimeSeriesPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	lastRollup := s.lastRollup
+	s.mu.Unlock()
+
+	late := make(map[time.Time]struct{})
+	for i := range points {
+		if points[i].Time.IsZero() {
+			points[i].Time = time.Now()
+		}
+		points[i].Time = points[i].Time.UTC()
+		if points[i].Time.Before(lastRollup) {
+			late[points[i].Time.Truncate(s.config.RollupInterval)] = struct{}{}
+		}
+	}
+
+	// Omit the ID so partitioned tables can use their own sequence
+	err := s.db.WithContext(ctx).
+		Table(s.config.Table).
+		Omit("ID").
+		CreateInBatches(points, 500).Error
+	if err != nil {
+		return err
+	}
+
+	for start := range late {
+		if err := s.Rollup(ctx, start, start.Add(s.config.RollupInterval)); err != nil {
+			return fmt.Errorf("failed to roll up late points: %w", err)
+		}
+	}
+	return nil
+}
+
+// Query returns aggregated buckets for a series. When the bucket is a
+// multiple of the rollup interval, From starts a rollup bucket and no tag
+// filter is set, the range rolled up so far is read from rollups and the
+// rest, such as the current bucket, from raw points.
+func (s *TimeSeriesStore) Query(ctx context.Context, q TimeSeriesQuery) ([]TimeSeriesBucket, error) {
+	if q.Aggregation == "" {
+		q.Aggregation = AggAvg
+	}
+	if q.To.IsZero() {
+		q.To = time.Now()
+	}
+
+	switch q.Aggregation {
+	case AggAvg, AggSum, AggMin, AggMax, AggCount:
+	default:
+		return nil, fmt.Errorf("unsupported aggregation: %s", q.Aggregation)
+	}
+
+	acc := newBucketAccumulator(q)
+	rawFrom := q.From
+	if end, ok := s.rollupEnd(q); ok {
+		if err := s.queryRollups(ctx, q.Series, q.From, end, acc); err != nil {
+			return nil, err
+		}
+		rawFrom = end
+	}
+	if rawFrom.Before(q.To) {
+		if err := s.queryRaw(ctx, q, rawFrom, q.To, acc); err != nil {
+			return nil, err
+		}
+	}
+	return acc.result(q.Aggregation), nil
+}
+
+// rollupEnd returns where rollups stop answering a query: at the last
+// rollup, or the start of the bucket containing To if earlier. ok is false
+// when rollups cannot answer any of it.
+func (s *TimeSeriesStore) rollupEnd(q TimeSeriesQuery) (time.Time, bool) {
+	interval := s.config.RollupInterval
+	if len(q.Tags) > 0 || q.Bucket <= 0 || q.Bucket%interval != 0 {
+		return time.Time{}, false
+	}
+	// Rollup buckets would straddle the query's
+	from := q.From.UTC()
+	if !from.Equal(from.Truncate(interval)) {
+		return time.Time{}, false
+	}
+
+	s.mu.Lock()
+	end := s.lastRollup
+	s.mu.Unlock()
+	if to := q.To.UTC().Truncate(interval); to.Before(end) {
+		end = to
+	}
+	if !end.After(from) {
+		return time.Time{}, false
+	}
+	return end, true
+}
+
+// queryRaw adds the raw points of a query between from and to
+func (s *TimeSeriesStore) queryRaw(ctx context.Context, q TimeSeriesQuery, from, to time.Time, acc *bucketAccumulator) error {
+	var points []TimeSeriesPoint
+	err := s.db.WithContext(ctx).
+		Table(s.config.Table).
+		Where("series = ? AND time >= ? AND time < ?", q.Series, from.UTC(), to.UTC()).
+		Order("time ASC").
+		Find(&points).Error
+	if err != nil {
+		return err
+	}
+
+	for _, p := range points {
+		if len(q.Tags) > 0 && !matchTags(p.Tags, q.Tags) {
+			continue
+		}
+		acc.add(p.Time, 1, p.Value, p.Value, p.Value)
+	}
+	return nil
+}
+
+// queryRollups adds the rollup buckets of a series starting between from
+// and to
+func (s *TimeSeriesStore) queryRollups(ctx context.Context, series string, from, to time.Time, acc *bucketAccumulator) error {
+	var rollups []TimeSeriesRollup
+	err := s.db.WithContext(ctx).
+		Table(s.config.RollupTable).
+		Where("series = ? AND bucket_start >= ? AND bucket_start < ?", series, from.UTC(), to.UTC()).
+		Order("bucket_start ASC").
+		Find(&rollups).Error
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rollups {
+		acc.add(r.BucketStart, r.Count, r.Sum, r.Min, r.Max)
+	}
+	return nil
+}
+
+// Rollup aggregates raw points between from and to into the rollup table.
+// Buckets are recomputed in full, so re-running a range is idempotent.
+func (s *TimeSeriesStore) Rollup(ctx context.Context, from, to time.Time) error {
+	from = from.UTC().Truncate(s.config.RollupInterval)
+	to = to.UTC().Truncate(s.config.RollupInterval)
+	if !to.After(from) {
+		return nil
+	}
+
+	s.rollupMu.Lock()
+	defer s.rollupMu.Unlock()
+
+	var points []TimeSeriesPoint
+	err := s.db.WithContext(ctx).
+		Table(s.config.Table).
+		Select("series, time, value").
+		Where("time >= ? AND time < ?", from, to).
+		Find(&points).Error
+	if err != nil {
+		return fmt.Errorf("failed to read points for rollup: %w", err)
+	}
+
+	type key struct {
+		series string
+		start  time.Time
+	}
+	buckets := make(map[key]*TimeSeriesRollup)
+	for _, p := range points {
+		k := key{series: p.Series, start: p.Time.UTC().Truncate(s.config.RollupInterval)}
+		r, ok := buckets[k]
+		if !ok {
+			r = &TimeSeriesRollup{Series: k.series, BucketStart: k.start, Min: p.Value, Max: p.Value}
+			buckets[k] = r
+		}
+		r.Count++
+		r.Sum += p.Value
+		r.Min = math.Min(r.Min, p.Value)
+		r.Max = math.Max(r.Max, p.Value)
+	}
+
+	if len(buckets) > 0 {
+		rows := make([]TimeSeriesRollup, 0, len(buckets))
+		for _, r := range buckets {
+			rows = append(rows, *r)
+		}
+
+		err = s.db.WithContext(ctx).
+			Table(s.config.RollupTable).
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "series"}, {Name: "bucket_start"}},
+				DoUpdates: clause.AssignmentColumns([]string{"count", "sum", "min", "max"}),
+			}).
+			CreateInBatches(rows, 500).Error
+		if err != nil {
+			return fmt.Errorf("failed to write rollups: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	if to.After(s.lastRollup) {
+		s.lastRollup = to
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Prune deletes raw points and rollups older than their retention. On
+// partitioned Postgres tables, expired partitions are dropped instead.
+func (s *TimeSeriesStore) Prune(ctx context.Context) error {
+	now := time.Now().UTC()
+	db := s.db.WithContext(ctx)
+
+	if s.config.Retention > 0 {
+		cutoff := now.Add(-s.config.Retention)
+
+		switch s.mode {
+		case "hypertable":
+			stmt := fmt.Sprintf(`SELECT drop_chunks('%s', older_than => TIMESTAMPTZ '%s')`, s.config.Table, cutoff.Format(time.RFC3339))
+			if err := db.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to drop chunks: %w", err)
+			}
+		case "partitioned":
+			if err := s.dropPartitionsBefore(ctx, cutoff); err != nil {
+				return err
+			}
+		}
+
+		if err := db.Table(s.config.Table).Where("time < ?", cutoff).Delete(&TimeSeriesPoint{}).Error; err != nil {
+			return fmt.Errorf("failed to prune points: %w", err)
+		}
+	}
+
+	if s.config.RollupRetention > 0 {
+		cutoff := now.Add(-s.config.RollupRetention)
+		if err := db.Table(s.config.RollupTable).Where("bucket_start < ?", cutoff).Delete(&TimeSeriesRollup{}).Error; err != nil {
+			return fmt.Errorf("failed to prune rollups: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// dropPartitionsBefore drops native partitions that end before cutoff
+func (s *TimeSeriesStore) dropPartitionsBefore(ctx context.Context, cutoff time.Time) error {
+	var names []string
+	err := s.db.WithContext(ctx).Raw(`
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = ?`, s.config.Table).Scan(&names).Error
+	if err != nil {
+		return fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	prefix := s.config.Table + "_p"
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		start, err := time.Parse("20060102150405", strings.TrimPrefix(name, prefix))
+		if err != nil {
+			continue
+		}
+		if start.Add(s.config.PartitionInterval).After(cutoff) {
+			continue
+		}
+		if err := s.db.WithContext(ctx).Exec(fmt.Sprintf(`DROP TABLE IF EXISTS %s`, name)).Error; err != nil {
+			return fmt.Errorf("failed to drop partition %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Start runs partition maintenance, rollup, and pruning in the background
+func (s *TimeSeriesStore) Start(ctx context.Context) {
+	s.mu.Lock()
+	if s.stopCh != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.stopCh = make(chan struct{})
+	stopCh := s.stopCh
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.JobInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.runJobs(ctx)
+			case <-stopCh:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops background jobs
+func (s *TimeSeriesStore) Stop() {
+	s.mu.Lock()
+	stopCh := s.stopCh
+	s.stopCh = nil
+	s.mu.Unlock()
+
+	if stopCh != nil {
+		close(stopCh)
+		s.wg.Wait()
+	}
+}
+
+// runJobs performs one maintenance cycle
+func (s *TimeSeriesStore) runJobs(ctx context.Context) {
+	now := time.Now()
+
+	if err := s.EnsurePartitions(ctx, now); err != nil {
+		fmt.Printf("⚠️  Time-series partition maintenance failed: %v\n", err)
+	}
+
+	s.mu.Lock()
+	from := s.lastRollup
+	s.mu.Unlock()
+	if from.IsZero() {
+		from = now.Add(-s.config.RollupInterval * 24)
+	}
+	if err := s.Rollup(ctx, from, now); err != nil {
+		fmt.Printf("⚠️  Time-series rollup failed: %v\n", err)
+	}
+
+	if err := s.Prune(ctx); err != nil {
+		fmt.Printf("⚠️  Time-series pruning failed: %v\n", err)
+	}
+}
+
+// bucketAccumulator merges samples into fixed-size time buckets
+type bucketAccumulator struct {
+	from    time.Time
+	bucket  time.Duration
+	buckets map[time.Time]*TimeSeriesRollup
+}
+
+func newBucketAccumulator(q TimeSeriesQuery) *bucketAccumulator {
+	return &bucketAccumulator{
+		from:    q.From.UTC(),
+		bucket:  q.Bucket,
+		buckets: make(map[time.Time]*TimeSeriesRollup),
+	}
+}
+
+func (a *bucketAccumulator) add(t time.Time, count int64, sum, min, max float64) {
+	start := a.from
+	if a.bucket > 0 {
+		start = a.from.Add(t.UTC().Sub(a.from) / a.bucket * a.bucket)
+	}
+
+	b, ok := a.buckets[start]
+	if !ok {
+		a.buckets[start] = &TimeSeriesRollup{BucketStart: start, Count: count, Sum: sum, Min: min, Max: max}
+		return
+	}
+	b.Count += count
+	b.Sum += sum
+	b.Min = math.Min(b.Min, min)
+	b.Max = math.Max(b.Max, max)
+}
+
+func (a *bucketAccumulator) result(aggregation string) []TimeSeriesBucket {
+	result := make([]TimeSeriesBucket, 0, len(a.buckets))
+	for _, b := range a.buckets {
+		bucket := TimeSeriesBucket{Start: b.BucketStart, Count: b.Count}
+		switch aggregation {
+		case AggSum:
+			bucket.Value = b.Sum
+		case AggMin:
+			bucket.Value = b.Min
+		case AggMax:
+			bucket.Value = b.Max
+		case AggCount:
+			bucket.Value = float64(b.Count)
+		default:
+			if b.Count > 0 {
+				bucket.Value = b.Sum / float64(b.Count)
+			}
+		}
+		result = append(result, bucket)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result
+}
+
+// encodeTags serializes tags deterministically
+func encodeTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(tags) // map keys are sorted by encoding/json
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// matchTags reports whether encoded tags contain all wanted pairs
+func matchTags(encoded string, want map[string]string) bool {
+	if encoded == "" {
+		return false
+	}
+	var tags map[string]string
+	if err := json.Unmarshal([]byte(encoded), &tags); err != nil {
+		return false
+	}
+	for k, v := range want {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
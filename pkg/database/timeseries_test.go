@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestTimeSeriesStore(t *testing.T) *TimeSeriesStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "timeseries.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	store := NewTimeSeriesStore(db, TimeSeriesConfig{RollupInterval: time.Hour})
+	if err := store.Setup(context.Background()); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	return store
+}
+
+// writeHourly writes value i+1 ten minutes into each of the n hours from
+// base
+func writeHourly(t *testing.T, store *TimeSeriesStore, base time.Time, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		at := base.Add(time.Duration(i)*time.Hour + 10*time.Minute)
+		if err := store.Write(context.Background(), "orders", float64(i+1), nil, at); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+}
+
+func assertBuckets(t *testing.T, got []TimeSeriesBucket, starts []time.Time, values []float64) {
+	t.Helper()
+	if len(got) != len(values) {
+		t.Fatalf("got %d buckets %+v, want %d", len(got), got, len(values))
+	}
+	for i, bucket := range got {
+		if !bucket.Start.Equal(starts[i]) || bucket.Value != values[i] {
+			t.Errorf("bucket %d = %s %v, want %s %v", i, bucket.Start, bucket.Value, starts[i], values[i])
+		}
+	}
+}
+
+func TestTimeSeriesQueryToNowMergesRawPoints(t *testing.T) {
+	ctx := context.Background()
+	store := newTestTimeSeriesStore(t)
+
+	// Three rolled up hours, then a point in the current one
+	current := time.Now().UTC().Add(-time.Millisecond).Truncate(time.Hour)
+	base := current.Add(-3 * time.Hour)
+	writeHourly(t, store, base, 3)
+	if err := store.Write(ctx, "orders", 4, nil, current); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := store.Rollup(ctx, base, time.Now()); err != nil {
+		t.Fatalf("Rollup: %v", err)
+	}
+
+	// Drop the rolled up raw points, so only rollups can answer for them
+	if err := store.db.Table(store.config.Table).Where("time < ?", current).Delete(&TimeSeriesPoint{}).Error; err != nil {
+		t.Fatalf("delete points: %v", err)
+	}
+
+	buckets, err := store.Query(ctx, TimeSeriesQuery{Series: "orders", From: base, Bucket: time.Hour, Aggregation: AggSum})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	assertBuckets(t, buckets,
+		[]time.Time{base, base.Add(time.Hour), base.Add(2 * time.Hour), current},
+		[]float64{1, 2, 3, 4})
+}
+
+func TestTimeSeriesLateWritesAreRolledUpAgain(t *testing.T) {
+	ctx := context.Background()
+	store := newTestTimeSeriesStore(t)
+
+	base := time.Now().UTC().Truncate(time.Hour).Add(-3 * time.Hour)
+	writeHourly(t, store, base, 3)
+	if err := store.Rollup(ctx, base, base.Add(3*time.Hour)); err != nil {
+		t.Fatalf("Rollup: %v", err)
+	}
+
+	if err := store.Write(ctx, "orders", 10, nil, base.Add(20*time.Minute)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	var rollup TimeSeriesRollup
+	if err := store.db.Table(store.config.RollupTable).Where("series = ? AND bucket_start = ?", "orders", base).First(&rollup).Error; err != nil {
+		t.Fatalf("load rollup: %v", err)
+	}
+	if rollup.Count != 2 || rollup.Sum != 11 || rollup.Max != 10 {
+		t.Errorf("rollup = %+v, want count 2, sum 11, max 10", rollup)
+	}
+
+	buckets, err := store.Query(ctx, TimeSeriesQuery{Series: "orders", From: base, To: base.Add(3 * time.Hour), Bucket: time.Hour, Aggregation: AggSum})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	assertBuckets(t, buckets,
+		[]time.Time{base, base.Add(time.Hour), base.Add(2 * time.Hour)},
+		[]float64{11, 2, 3})
+}
+
+func TestTimeSeriesQueryUnalignedFrom(t *testing.T) {
+	ctx := context.Background()
+	store := newTestTimeSeriesStore(t)
+
+	base := time.Now().UTC().Truncate(time.Hour).Add(-4 * time.Hour)
+	writeHourly(t, store, base, 4)
+	if err := store.Write(ctx, "orders", 100, nil, base.Add(time.Hour+50*time.Minute)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := store.Rollup(ctx, base, base.Add(4*time.Hour)); err != nil {
+		t.Fatalf("Rollup: %v", err)
+	}
+
+	// Hours starting at :30 split the second rollup bucket
+	from := base.Add(30 * time.Minute)
+	buckets, err := store.Query(ctx, TimeSeriesQuery{Series: "orders", From: from, To: from.Add(3 * time.Hour), Bucket: time.Hour, Aggregation: AggSum})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	assertBuckets(t, buckets,
+		[]time.Time{from, from.Add(time.Hour), from.Add(2 * time.Hour)},
+		[]float64{2, 103, 4})
+}
@@ -0,0 +1,397 @@
+package deploy
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"neonexcore/pkg/envconfig"
+)
+
+// Paths inside the app container
+const (
+	AppDir     = "/app"
+	DataDir    = "/app/data"    // SQLite database
+	StorageDir = "/app/storage" // Local object storage
+)
+
+// Options are deployment settings that are not part of the app's
+// configuration
+type Options struct {
+	Name        string   // Defaults to APP_NAME
+	Image       string   // Defaults to <name>:latest
+	GoVersion   string   // Of the build image, e.g. 1.25
+	Replicas    int      // Default 2
+	MaxReplicas int      // Upper bound of the autoscaler, default 10
+	CPUTarget   int      // Average CPU utilization the autoscaler aims for, in percent, default 70
+	Context     string   // App directory, relative to the generated files
+	Dockerfile  string   // Generated Dockerfile, relative to the app directory
+	EnvFiles    []string // Environment files, relative to the generated files
+	Resolved    []string // Keys the profile set, which the env files do not
+}
+
+// Spec is what the app needs to run in an environment, derived from its
+// configuration
+type Spec struct {
+	Name        string
+	Environment string
+	Profile     string
+	Image       string
+	GoVersion   string
+	Port        int
+	Config      map[string]string // Non-secret values, for the config map
+	Adjusted    map[string]string // Values of Config differing from the env files
+	Secrets     []string          // Keys of secret values, supplied at deploy time
+	Overrides   map[string]string // Values pointing the app at the Compose services
+	Services    []Service         // Backing services run next to the app by Compose
+	Volumes     []Volume
+	Replicas    int
+	MaxReplicas int
+	CPUTarget   int
+	Scalable    bool // False when state lives on the container's disk, e.g. SQLite
+	Warnings    []string
+	Options     Options
+}
+
+// Service is a backing service run by Compose
+type Service struct {
+	Name        string
+	Image       string
+	Port        int
+	Command     []string
+	Env         map[string]string
+	Data        string   // Data directory, kept in a volume
+	Healthcheck []string // Empty when the image has no way to check
+}
+
+// Volume is persistent app state
+type Volume struct {
+	Name string
+	Path string // Mount path in the app container
+	Size string // Requested by the Kubernetes claim
+}
+
+// Service images, pinned to a major version
+var (
+	imagePostgres  = "postgres:16-alpine"
+	imagePGVector  = "pgvector/pgvector:pg16"
+	imageMySQL     = "mysql:8.0"
+	imageRedis     = "redis:7-alpine"
+	imageMemcached = "memcached:1.6-alpine"
+	imageQdrant    = "qdrant/qdrant:v1.12.4"
+)
+
+// Build derives a spec from an environment's values, after any profile
+// has been resolved. schema tells secret keys apart.
+func Build(env *envconfig.Environment, schema *envconfig.Schema, options Options) (*Spec, error) {
+	values := env.Values
+	get := func(key, fallback string) string {
+		if value := values[key]; value != "" {
+			return value
+		}
+		return fallback
+	}
+
+	spec := &Spec{
+		Environment: env.Name,
+		Profile:     values["APP_PROFILE"],
+		Config:      make(map[string]string),
+		Adjusted:    make(map[string]string),
+		Overrides:   make(map[string]string),
+		Scalable:    true,
+		Options:     options,
+	}
+
+	spec.Name = options.Name
+	if spec.Name == "" {
+		spec.Name = get("APP_NAME", "neonexcore")
+	}
+	spec.Name = dnsLabel(spec.Name)
+	if spec.Name == "" {
+		return nil, fmt.Errorf("app name %q has no letters or digits", options.Name+values["APP_NAME"])
+	}
+	spec.Image = options.Image
+	if spec.Image == "" {
+		spec.Image = spec.Name + ":latest"
+	}
+	spec.GoVersion = options.GoVersion
+	if spec.GoVersion == "" {
+		spec.GoVersion = "1.25"
+	}
+
+	port, err := strconv.Atoi(get("HTTP_PORT", "8080"))
+	if err != nil || port < 1 || port > 65535 {
+		return nil, fmt.Errorf("invalid HTTP_PORT %q", values["HTTP_PORT"])
+	}
+	spec.Port = port
+
+	spec.Replicas = positive(options.Replicas, 2)
+	spec.MaxReplicas = positive(options.MaxReplicas, 10)
+	spec.CPUTarget = positive(options.CPUTarget, 70)
+
+	for key, value := range values {
+		if schema.Secret(key) {
+			if value != "" {
+				spec.Secrets = append(spec.Secrets, key)
+			}
+		} else {
+			spec.Config[key] = value
+		}
+	}
+	sort.Strings(spec.Secrets)
+	for _, key := range options.Resolved {
+		if value, ok := spec.Config[key]; ok {
+			spec.Adjusted[key] = value
+		}
+	}
+	if spec.Profile != "" {
+		spec.warn("APP_PROFILE %s replaces the configured backends; leave it unset outside development", spec.Profile)
+	}
+
+	// The container must listen on every interface
+	switch host := values["HTTP_HOST"]; host {
+	case "", "0.0.0.0", "::":
+	default:
+		spec.set("HTTP_HOST", "0.0.0.0")
+		spec.warn("HTTP_HOST %s is only reachable inside the container; using 0.0.0.0", host)
+	}
+	spec.set("HTTP_PORT", strconv.Itoa(port))
+
+	spec.addDatabase(get)
+	if !spec.Scalable {
+		spec.Replicas, spec.MaxReplicas = 1, 1
+	}
+	spec.addRedis(values, get)
+	if get("CACHE_DRIVER", "memory") == "memcached" {
+		spec.addMemcached(get)
+	}
+	if get("VECTOR_STORE", "memory") == "qdrant" {
+		spec.addQdrant(get)
+	}
+	if get("STORAGE_DRIVER", "local") == "local" {
+		spec.set("STORAGE_ROOT", StorageDir)
+		spec.Volumes = append(spec.Volumes, Volume{Name: "storage", Path: StorageDir, Size: "10Gi"})
+		if spec.Replicas > 1 {
+			spec.warn("local storage is shared by %d replicas; its claim needs a ReadWriteMany storage class", spec.Replicas)
+		}
+	}
+	if get("LOG_OUTPUT", "console") != "console" {
+		spec.warn("LOG_OUTPUT %s writes logs inside the container; console is collected by Docker and Kubernetes", values["LOG_OUTPUT"])
+	}
+	return spec, nil
+}
+
+func (s *Spec) addDatabase(get func(key, fallback string) string) {
+	switch driver := get("DB_DRIVER", "sqlite"); driver {
+	case "sqlite":
+		file := path.Base(strings.ReplaceAll(get("DB_DATABASE", "neonex.db"), "\\", "/"))
+		s.set("DB_DATABASE", path.Join(DataDir, file))
+		s.Volumes = append(s.Volumes, Volume{Name: "data", Path: DataDir, Size: "1Gi"})
+		s.Scalable = false
+		s.warn("SQLite keeps the database on one volume; running a single replica without autoscaling")
+
+	case "postgres", "postgresql", "mysql":
+		// The app's defaults suit SQLite and MySQL
+		if get("DB_DATABASE", "") == "" {
+			s.set("DB_DATABASE", "neonex")
+		}
+		if get("DB_PORT", "") == "" && driver != "mysql" {
+			s.set("DB_PORT", "5432")
+		}
+
+		host := get("DB_HOST", "localhost")
+		if !local(host) {
+			return // A managed or external server
+		}
+		user, database := get("DB_USERNAME", "root"), get("DB_DATABASE", "neonex")
+		hasPassword := get("DB_PASSWORD", "") != ""
+
+		service := Service{Env: make(map[string]string)}
+		if driver == "mysql" {
+			service.Name, service.Image, service.Port, service.Data = "mysql", imageMySQL, 3306, "/var/lib/mysql"
+			service.Env["MYSQL_DATABASE"] = database
+			switch {
+			case user != "root" && hasPassword:
+				service.Env["MYSQL_USER"] = user
+				service.Env["MYSQL_PASSWORD"] = interpolate("DB_PASSWORD")
+				service.Env["MYSQL_RANDOM_ROOT_PASSWORD"] = "yes"
+			case hasPassword:
+				service.Env["MYSQL_ROOT_PASSWORD"] = interpolate("DB_PASSWORD")
+			default:
+				service.Env["MYSQL_ALLOW_EMPTY_PASSWORD"] = "yes"
+				if user != "root" {
+					service.Env["MYSQL_USER"] = user
+				}
+			}
+			service.Healthcheck = []string{"CMD", "mysqladmin", "ping", "-h", "127.0.0.1"}
+		} else {
+			service.Name, service.Image, service.Port, service.Data = "postgres", imagePostgres, 5432, "/var/lib/postgresql/data"
+			if get("VECTOR_STORE", "memory") == "pgvector" {
+				service.Image = imagePGVector
+			}
+			service.Env["POSTGRES_USER"] = user
+			service.Env["POSTGRES_DB"] = database
+			if hasPassword {
+				service.Env["POSTGRES_PASSWORD"] = interpolate("DB_PASSWORD")
+			} else {
+				service.Env["POSTGRES_HOST_AUTH_METHOD"] = "trust"
+			}
+			service.Healthcheck = []string{"CMD-SHELL", fmt.Sprintf("pg_isready -U %s -d %s", user, database)}
+		}
+		s.Services = append(s.Services, service)
+		s.Overrides["DB_HOST"] = service.Name
+		s.Overrides["DB_PORT"] = strconv.Itoa(service.Port)
+		s.warn("DB_HOST %s points at this machine; point it at the database in Kubernetes", host)
+	}
+}
+
+// addRedis adds Redis when the cache or the queue uses it
+func (s *Spec) addRedis(values map[string]string, get func(key, fallback string) string) {
+	keys := map[string]string{}
+	if get("CACHE_DRIVER", "memory") == "redis" {
+		keys["REDIS_URL"] = get("REDIS_URL", "redis://localhost:6379")
+	}
+	if get("QUEUE_DRIVER", "memory") == "redis" {
+		if values["QUEUE_REDIS_URL"] != "" {
+			keys["QUEUE_REDIS_URL"] = values["QUEUE_REDIS_URL"]
+		} else {
+			keys["REDIS_URL"] = get("REDIS_URL", "redis://localhost:6379")
+		}
+	}
+
+	added := false
+	for _, key := range sortedKeys(keys) {
+		u, err := url.Parse(keys[key])
+		if err != nil || !local(u.Hostname()) {
+			continue
+		}
+		if u.User != nil {
+			s.warn("%s has a password; the Compose Redis runs without one, so set %s to redis://redis:6379 for it", key, key)
+			continue
+		}
+		if !added {
+			s.Services = append(s.Services, Service{
+				Name:        "redis",
+				Image:       imageRedis,
+				Port:        6379,
+				Command:     []string{"redis-server", "--appendonly", "yes"},
+				Data:        "/data",
+				Healthcheck: []string{"CMD", "redis-cli", "ping"},
+			})
+			added = true
+		}
+		u.Host = "redis:6379"
+		s.Overrides[key] = u.String()
+		s.warn("%s points at this machine; point it at Redis in Kubernetes", key)
+	}
+}
+
+func (s *Spec) addMemcached(get func(key, fallback string) string) {
+	for _, server := range strings.Split(get("MEMCACHED_SERVERS", "localhost:11211"), ",") {
+		host, _, err := net.SplitHostPort(strings.TrimSpace(server))
+		if err != nil || !local(host) {
+			return
+		}
+	}
+	s.Services = append(s.Services, Service{Name: "memcached", Image: imageMemcached, Port: 11211})
+	s.Overrides["MEMCACHED_SERVERS"] = "memcached:11211"
+	s.warn("MEMCACHED_SERVERS points at this machine; point it at Memcached in Kubernetes")
+}
+
+func (s *Spec) addQdrant(get func(key, fallback string) string) {
+	u, err := url.Parse(get("QDRANT_URL", "http://localhost:6333"))
+	if err != nil || !local(u.Hostname()) {
+		return
+	}
+	s.Services = append(s.Services, Service{Name: "qdrant", Image: imageQdrant, Port: 6333, Data: "/qdrant/storage"})
+	s.Overrides["QDRANT_URL"] = "http://qdrant:6333"
+	s.warn("QDRANT_URL points at this machine; point it at Qdrant in Kubernetes")
+}
+
+// Generate renders every deployment file, by path relative to the output
+// directory
+func Generate(spec *Spec) (map[string][]byte, error) {
+	dockerfile, err := Dockerfile(spec)
+	if err != nil {
+		return nil, err
+	}
+	compose, err := Compose(spec)
+	if err != nil {
+		return nil, err
+	}
+	manifests, err := Kubernetes(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{
+		"Dockerfile":              dockerfile,
+		"Dockerfile.dockerignore": Dockerignore(spec),
+		"docker-compose.yml":      compose,
+	}
+	for name, data := range manifests {
+		files[path.Join("k8s", name)] = data
+	}
+	return files, nil
+}
+
+// set adjusts a value for running in a container
+func (s *Spec) set(key, value string) {
+	if current, ok := s.Config[key]; ok && current == value {
+		return
+	}
+	s.Config[key] = value
+	s.Adjusted[key] = value
+}
+
+func (s *Spec) warn(format string, args ...interface{}) {
+	s.Warnings = append(s.Warnings, fmt.Sprintf(format, args...))
+}
+
+// local reports whether a host is this machine, which in a container
+// means a service Compose has to run
+func local(host string) bool {
+	switch host {
+	case "", "localhost", "127.0.0.1", "::1", "0.0.0.0":
+		return true
+	}
+	return false
+}
+
+// interpolate refers to a variable Compose substitutes from its
+// environment files, failing when it is not set
+func interpolate(key string) string {
+	return fmt.Sprintf("${%s:?%s is not set}", key, key)
+}
+
+var nonLabel = regexp.MustCompile(`[^a-z0-9]+`)
+
+// dnsLabel makes a name usable for Kubernetes objects and Compose services
+func dnsLabel(name string) string {
+	label := strings.Trim(nonLabel.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(label) > 53 {
+		label = strings.TrimRight(label[:53], "-")
+	}
+	return label
+}
+
+func positive(value, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,198 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+var dockerfileTemplate = template.Must(template.New("Dockerfile").Parse(`{{.Header}}
+FROM golang:{{.GoVersion}}-alpine AS build
+WORKDIR /src
+COPY go.mod go.sum ./
+RUN go mod download
+COPY . .
+RUN CGO_ENABLED=0 go build -trimpath -ldflags="-s -w" -o /out/app .
+
+FROM alpine:3.20
+RUN apk add --no-cache ca-certificates tzdata \
+    && adduser -D -H -u 10001 app
+WORKDIR {{.AppDir}}
+COPY --from=build /out/app ./app
+# Module manifests are read at startup
+COPY --from=build /src/modules ./modules
+{{- if .Volumes}}
+RUN mkdir -p{{range .Volumes}} {{.Path}}{{end}} \
+    && chown app{{range .Volumes}} {{.Path}}{{end}}
+{{- end}}
+USER app
+ENV HTTP_HOST=0.0.0.0 HTTP_PORT={{.Port}}
+EXPOSE {{.Port}}
+HEALTHCHECK --interval=30s --timeout=3s --start-period=30s \
+    CMD wget -qO- http://127.0.0.1:{{.Port}}/health/live >/dev/null || exit 1
+ENTRYPOINT ["./app"]
+`))
+
+// dockerignore keeps environment files, local databases and uploads out
+// of the build context, so they are never baked into the image
+const dockerignore = `.git
+.env
+.env.*
+!.env.example
+*.db
+*.db-*
+storage/
+logs/
+tmp/
+`
+
+// Dockerfile renders a two-stage build of the app into a small image
+// running as an unprivileged user
+func Dockerfile(spec *Spec) ([]byte, error) {
+	var buf bytes.Buffer
+	err := dockerfileTemplate.Execute(&buf, struct {
+		*Spec
+		Header string
+		AppDir string
+	}{spec, header(spec), AppDir})
+	return buf.Bytes(), err
+}
+
+// Dockerignore renders the ignore file of the generated Dockerfile
+func Dockerignore(spec *Spec) []byte {
+	return []byte(header(spec) + "\n" + dockerignore)
+}
+
+type composeFile struct {
+	Name     string                    `yaml:"name"`
+	Services map[string]composeService `yaml:"services"`
+	Volumes  map[string]struct{}       `yaml:"volumes,omitempty"`
+}
+
+type composeService struct {
+	Image       string                       `yaml:"image"`
+	Build       *composeBuild                `yaml:"build,omitempty"`
+	Command     []string                     `yaml:"command,omitempty"`
+	Restart     string                       `yaml:"restart"`
+	Ports       []string                     `yaml:"ports,omitempty"`
+	EnvFile     []string                     `yaml:"env_file,omitempty"`
+	Environment map[string]string            `yaml:"environment,omitempty"`
+	Volumes     []string                     `yaml:"volumes,omitempty"`
+	DependsOn   map[string]composeDependency `yaml:"depends_on,omitempty"`
+	Healthcheck *composeHealthcheck          `yaml:"healthcheck,omitempty"`
+}
+
+type composeBuild struct {
+	Context    string `yaml:"context"`
+	Dockerfile string `yaml:"dockerfile"`
+}
+
+type composeDependency struct {
+	Condition string `yaml:"condition"`
+}
+
+type composeHealthcheck struct {
+	Test     []string `yaml:"test"`
+	Interval string   `yaml:"interval"`
+	Timeout  string   `yaml:"timeout"`
+	Retries  int      `yaml:"retries"`
+}
+
+// Compose renders a Compose file running the app with its backing
+// services. The app reads the environment files at runtime, with the
+// values adjusted for containers on top.
+func Compose(spec *Spec) ([]byte, error) {
+	file := composeFile{
+		Name:     spec.Name,
+		Services: make(map[string]composeService),
+		Volumes:  make(map[string]struct{}),
+	}
+
+	environment := make(map[string]string, len(spec.Adjusted)+len(spec.Overrides))
+	for key, value := range spec.Adjusted {
+		environment[key] = value
+	}
+	for key, value := range spec.Overrides {
+		environment[key] = value
+	}
+	app := composeService{
+		Image:       spec.Image,
+		Build:       &composeBuild{Context: spec.Options.Context, Dockerfile: spec.Options.Dockerfile},
+		Restart:     "unless-stopped",
+		Ports:       []string{fmt.Sprintf("%d:%d", spec.Port, spec.Port)},
+		EnvFile:     spec.Options.EnvFiles,
+		Environment: environment,
+	}
+	for _, volume := range spec.Volumes {
+		app.Volumes = append(app.Volumes, volume.Name+":"+volume.Path)
+		file.Volumes[volume.Name] = struct{}{}
+	}
+
+	for _, service := range spec.Services {
+		s := composeService{
+			Image:       service.Image,
+			Command:     service.Command,
+			Restart:     "unless-stopped",
+			Environment: service.Env,
+		}
+		if service.Data != "" {
+			volume := service.Name + "-data"
+			s.Volumes = []string{volume + ":" + service.Data}
+			file.Volumes[volume] = struct{}{}
+		}
+		condition := "service_started"
+		if len(service.Healthcheck) > 0 {
+			s.Healthcheck = &composeHealthcheck{Test: service.Healthcheck, Interval: "5s", Timeout: "3s", Retries: 20}
+			condition = "service_healthy"
+		}
+		if app.DependsOn == nil {
+			app.DependsOn = make(map[string]composeDependency)
+		}
+		app.DependsOn[service.Name] = composeDependency{Condition: condition}
+		file.Services[service.Name] = s
+	}
+	file.Services[spec.Name] = app
+
+	return marshal(header(spec), file)
+}
+
+// header marks a file as generated, naming what it was generated from
+func header(spec *Spec) string {
+	environment := spec.Environment
+	if environment == "" {
+		environment = "default"
+	}
+	sources := "the " + environment + " environment"
+	if files := spec.Options.EnvFiles; len(files) > 0 {
+		names := make([]string, len(files))
+		for i, file := range files {
+			names[i] = file[strings.LastIndexAny(file, `/\`)+1:]
+		}
+		sources += " (" + strings.Join(names, ", ") + ")"
+	}
+	return "# " + GeneratedMarker + " from " + sources + ".\n" +
+		"# Change the configuration and regenerate instead of editing this file."
+}
+
+// GeneratedMarker starts the first line of every generated file
+const GeneratedMarker = "Generated by neonex deploy:generate"
+
+func marshal(header string, documents ...interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(header + "\n")
+	for i, document := range documents {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(document); err != nil {
+			return nil, err
+		}
+		encoder.Close()
+	}
+	return buf.Bytes(), nil
+}
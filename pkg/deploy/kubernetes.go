@@ -0,0 +1,212 @@
+package deploy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type k8sMeta struct {
+	Name        string            `yaml:"name"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type k8sObject struct {
+	APIVersion string      `yaml:"apiVersion"`
+	Kind       string      `yaml:"kind"`
+	Metadata   k8sMeta     `yaml:"metadata"`
+	Data       interface{} `yaml:"data,omitempty"`
+	Spec       interface{} `yaml:"spec,omitempty"`
+}
+
+// ConfigMapName returns the name of the app's config map
+func ConfigMapName(spec *Spec) string {
+	return spec.Name + "-config"
+}
+
+// SecretName returns the name of the secret holding the app's secret
+// values. It is not generated, so the values never leave the environment
+// files.
+func SecretName(spec *Spec) string {
+	return spec.Name + "-secrets"
+}
+
+// Kubernetes renders the app's manifests by file name: a config map, a
+// deployment, a service, an autoscaler when the app can scale out, and a
+// claim per volume
+func Kubernetes(spec *Spec) (map[string][]byte, error) {
+	labels := map[string]string{"app.kubernetes.io/name": spec.Name}
+	if spec.Environment != "" {
+		labels["app.kubernetes.io/instance"] = spec.Name + "-" + dnsLabel(spec.Environment)
+	}
+	selector := map[string]string{"app.kubernetes.io/name": spec.Name}
+	files := make(map[string][]byte)
+	add := func(name string, documents ...interface{}) error {
+		data, err := marshal(header(spec), documents...)
+		files[name] = data
+		return err
+	}
+
+	configMap := k8sObject{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Metadata:   k8sMeta{Name: ConfigMapName(spec), Labels: labels},
+		Data:       spec.Config,
+	}
+	if err := add("configmap.yaml", configMap); err != nil {
+		return nil, err
+	}
+
+	if err := add("deployment.yaml", deployment(spec, labels, selector)); err != nil {
+		return nil, err
+	}
+
+	service := k8sObject{
+		APIVersion: "v1",
+		Kind:       "Service",
+		Metadata:   k8sMeta{Name: spec.Name, Labels: labels},
+		Spec: map[string]interface{}{
+			"selector": selector,
+			"ports": []map[string]interface{}{
+				{"name": "http", "port": 80, "targetPort": "http"},
+			},
+		},
+	}
+	if err := add("service.yaml", service); err != nil {
+		return nil, err
+	}
+
+	if spec.Scalable {
+		hpa := k8sObject{
+			APIVersion: "autoscaling/v2",
+			Kind:       "HorizontalPodAutoscaler",
+			Metadata:   k8sMeta{Name: spec.Name, Labels: labels},
+			Spec: map[string]interface{}{
+				"scaleTargetRef": map[string]string{"apiVersion": "apps/v1", "kind": "Deployment", "name": spec.Name},
+				"minReplicas":    spec.Replicas,
+				"maxReplicas":    max(spec.MaxReplicas, spec.Replicas),
+				"metrics": []map[string]interface{}{{
+					"type": "Resource",
+					"resource": map[string]interface{}{
+						"name":   "cpu",
+						"target": map[string]interface{}{"type": "Utilization", "averageUtilization": spec.CPUTarget},
+					},
+				}},
+			},
+		}
+		if err := add("hpa.yaml", hpa); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(spec.Volumes) > 0 {
+		// Volumes shared by several replicas must be mountable by all of them
+		access := "ReadWriteOnce"
+		if spec.Replicas > 1 {
+			access = "ReadWriteMany"
+		}
+		claims := make([]interface{}, 0, len(spec.Volumes))
+		for _, volume := range spec.Volumes {
+			claims = append(claims, k8sObject{
+				APIVersion: "v1",
+				Kind:       "PersistentVolumeClaim",
+				Metadata:   k8sMeta{Name: spec.Name + "-" + volume.Name, Labels: labels},
+				Spec: map[string]interface{}{
+					"accessModes": []string{access},
+					"resources":   map[string]interface{}{"requests": map[string]string{"storage": volume.Size}},
+				},
+			})
+		}
+		if err := add("volumes.yaml", claims...); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func deployment(spec *Spec, labels, selector map[string]string) k8sObject {
+	envFrom := []map[string]interface{}{
+		{"configMapRef": map[string]string{"name": ConfigMapName(spec)}},
+	}
+	if len(spec.Secrets) > 0 {
+		envFrom = append(envFrom, map[string]interface{}{"secretRef": map[string]string{"name": SecretName(spec)}})
+	}
+
+	container := map[string]interface{}{
+		"name":    "app",
+		"image":   spec.Image,
+		"ports":   []map[string]interface{}{{"name": "http", "containerPort": spec.Port}},
+		"envFrom": envFrom,
+		"readinessProbe": map[string]interface{}{
+			"httpGet":       map[string]string{"path": "/health/ready", "port": "http"},
+			"periodSeconds": 10,
+		},
+		"livenessProbe": map[string]interface{}{
+			"httpGet":             map[string]string{"path": "/health/live", "port": "http"},
+			"initialDelaySeconds": 10,
+			"periodSeconds":       20,
+		},
+		// The autoscaler measures CPU against the request
+		"resources": map[string]interface{}{
+			"requests": map[string]string{"cpu": "100m", "memory": "128Mi"},
+			"limits":   map[string]string{"memory": "512Mi"},
+		},
+	}
+	pod := map[string]interface{}{
+		"securityContext": map[string]interface{}{"runAsNonRoot": true, "runAsUser": 10001, "fsGroup": 10001},
+		"containers":      []interface{}{container},
+	}
+	if len(spec.Volumes) > 0 {
+		var mounts, volumes []map[string]interface{}
+		for _, volume := range spec.Volumes {
+			mounts = append(mounts, map[string]interface{}{"name": volume.Name, "mountPath": volume.Path})
+			volumes = append(volumes, map[string]interface{}{
+				"name":                  volume.Name,
+				"persistentVolumeClaim": map[string]string{"claimName": spec.Name + "-" + volume.Name},
+			})
+		}
+		container["volumeMounts"] = mounts
+		pod["volumes"] = volumes
+	}
+
+	deploymentSpec := map[string]interface{}{
+		"replicas": spec.Replicas,
+		"selector": map[string]interface{}{"matchLabels": selector},
+		"template": map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"labels": labels,
+				// Pods restart when the configuration changes
+				"annotations": map[string]string{"neonex.io/config-checksum": checksum(spec.Config)},
+			},
+			"spec": pod,
+		},
+	}
+	if !spec.Scalable {
+		// A single writer: the old pod releases the volume first
+		deploymentSpec["strategy"] = map[string]string{"type": "Recreate"}
+	}
+
+	return k8sObject{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Metadata:   k8sMeta{Name: spec.Name, Labels: labels},
+		Spec:       deploymentSpec,
+	}
+}
+
+func checksum(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", key, values[key])
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
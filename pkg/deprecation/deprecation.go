@@ -0,0 +1,396 @@
+package deprecation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/logger"
+)
+
+// ErrSunset is returned for deprecated things used after their sunset
+// once they are disabled
+var ErrSunset = errors.New("deprecation: past its sunset")
+
+// Kind is what a notice deprecates
+type Kind string
+
+const (
+	KindRoute  Kind = "route"  // An HTTP route, named "METHOD /path" with :params and a trailing *
+	KindConfig Kind = "config" // An environment variable
+	KindAPI    Kind = "api"    // A module API, e.g. "orders.Service.ExportCSV"
+)
+
+// dateLayout is the layout of dates in deprecation files
+const dateLayout = "2006-01-02"
+
+// Notice marks something deprecated
+type Notice struct {
+	Kind        Kind      `json:"kind"`
+	Name        string    `json:"name"`
+	Message     string    `json:"message,omitempty"`     // Why, or what to do instead
+	Replacement string    `json:"replacement,omitempty"` // e.g. "GET /api/v2/orders" or NEW_KEY
+	Link        string    `json:"link,omitempty"`        // Migration guide
+	Since       time.Time `json:"since"`                 // When it was deprecated; registration time when zero
+	Sunset      time.Time `json:"sunset"`                // When it is removed; none when zero
+	Disable     bool      `json:"disable,omitempty"`     // Refuse it after the sunset
+}
+
+// UnmarshalJSON reads dates as either 2006-01-02 or RFC 3339
+func (n *Notice) UnmarshalJSON(data []byte) error {
+	type plain Notice
+	var raw struct {
+		plain
+		Since  string `json:"since"`
+		Sunset string `json:"sunset"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*n = Notice(raw.plain)
+
+	var err error
+	if n.Since, err = parseDate(raw.Since); err != nil {
+		return fmt.Errorf("deprecation %s: since: %w", n.Name, err)
+	}
+	if n.Sunset, err = parseDate(raw.Sunset); err != nil {
+		return fmt.Errorf("deprecation %s: sunset: %w", n.Name, err)
+	}
+	return nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// key identifies a notice in the registry
+func (n *Notice) key() string {
+	return string(n.Kind) + ":" + n.Name
+}
+
+// Warning describes the notice for a Warning header or log line
+func (n *Notice) Warning() string {
+	var sb strings.Builder
+	sb.WriteString(n.Name + " is deprecated")
+	if !n.Sunset.IsZero() {
+		sb.WriteString(" and will be removed on " + n.Sunset.Format(dateLayout))
+	}
+	if n.Replacement != "" {
+		sb.WriteString("; use " + n.Replacement + " instead")
+	}
+	if n.Message != "" {
+		sb.WriteString(". " + n.Message)
+	}
+	return sb.String()
+}
+
+// Status is a notice and how it has been used
+type Status struct {
+	Notice
+	Disabled bool      `json:"disabled"`
+	Uses     int64     `json:"uses"`
+	Blocked  int64     `json:"blocked"` // Uses refused after the sunset
+	LastUsed time.Time `json:"last_used,omitempty"`
+}
+
+// entry is a registered notice and its usage
+type entry struct {
+	notice   Notice
+	pattern  []string // Path segments of route notices
+	method   string
+	uses     int64
+	blocked  int64
+	lastUsed time.Time
+	lastLog  time.Time
+}
+
+// Config configures a Registry
+type Config struct {
+	File        string        // JSON list of notices to load, if any
+	Enforce     bool          // Disable notices with Disable set after their sunset
+	LogInterval time.Duration // Logs each notice's use at most this often
+}
+
+// DefaultConfig returns the default deprecation configuration
+func DefaultConfig() Config {
+	return Config{Enforce: true, LogInterval: time.Hour}
+}
+
+// LoadConfig loads deprecation configuration from environment
+func LoadConfig() Config {
+	config := DefaultConfig()
+
+	config.File = os.Getenv("DEPRECATIONS_FILE")
+	if enforce, err := strconv.ParseBool(os.Getenv("DEPRECATION_ENFORCE")); err == nil {
+		config.Enforce = enforce
+	}
+	if interval, err := time.ParseDuration(os.Getenv("DEPRECATION_LOG_INTERVAL")); err == nil && interval >= 0 {
+		config.LogInterval = interval
+	}
+
+	return config
+}
+
+// Metrics counts uses of deprecated names. metrics.Hooks implements it for
+// a collector.
+type Metrics interface {
+	AddCounter(name, description string, labels map[string]string, delta uint64)
+}
+
+// Registry holds deprecated routes, config keys and module APIs. Each use
+// is counted and logged; once past its sunset, a notice with Disable set
+// refuses further use while the registry enforces sunsets.
+type Registry struct {
+	config  Config
+	metrics Metrics
+	entries map[string]*entry
+	mu      sync.Mutex
+}
+
+// New creates a registry, loading the notices of the config's file. With
+// nil metrics, uses are only logged.
+func New(config Config, metrics Metrics) (*Registry, error) {
+	r := &Registry{config: config, metrics: metrics, entries: make(map[string]*entry)}
+	if config.File == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(config.File)
+	if err != nil {
+		return nil, err
+	}
+	var notices []Notice
+	if err := json.Unmarshal(data, &notices); err != nil {
+		return nil, fmt.Errorf("%s: %w", config.File, err)
+	}
+	for _, notice := range notices {
+		if err := r.Deprecate(notice); err != nil {
+			return nil, fmt.Errorf("%s: %w", config.File, err)
+		}
+	}
+	return r, nil
+}
+
+// Deprecate registers a notice, replacing one of the same kind and name
+func (r *Registry) Deprecate(notice Notice) error {
+	if notice.Name == "" {
+		return errors.New("deprecation: notice has no name")
+	}
+	e := &entry{notice: notice}
+	switch notice.Kind {
+	case KindRoute:
+		method, path, ok := strings.Cut(notice.Name, " ")
+		if !ok || !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("deprecation: route %q must be \"METHOD /path\"", notice.Name)
+		}
+		e.method, e.pattern = strings.ToUpper(method), splitPath(path)
+	case KindConfig, KindAPI:
+	default:
+		return fmt.Errorf("deprecation: unknown kind %q", notice.Kind)
+	}
+	if e.notice.Since.IsZero() {
+		e.notice.Since = time.Now()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[notice.key()] = e
+	return nil
+}
+
+// Use records a use of a deprecated config key or module API, returning
+// an error wrapping ErrSunset when it is disabled. Things without a
+// notice are not deprecated and always allowed.
+//
+//	func (s *Service) ExportCSV(ctx context.Context) ([]byte, error) {
+//		if err := s.deprecations.Use(ctx, deprecation.KindAPI, "orders.Service.ExportCSV"); err != nil {
+//			return nil, err
+//		}
+//		...
+func (r *Registry) Use(ctx context.Context, kind Kind, name string) error {
+	r.mu.Lock()
+	e, ok := r.entries[string(kind)+":"+name]
+	r.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return r.use(ctx, e)
+}
+
+// Getenv reads a config key, recording its use when it is deprecated and
+// set. A disabled key reads as unset.
+func (r *Registry) Getenv(key string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return ""
+	}
+	if err := r.Use(context.Background(), KindConfig, key); err != nil {
+		return ""
+	}
+	return value
+}
+
+// CheckEnvironment warns about each deprecated config key that is set,
+// and returns an error naming those that are disabled
+func (r *Registry) CheckEnvironment() error {
+	r.mu.Lock()
+	var set []*entry
+	for _, e := range r.entries {
+		if e.notice.Kind == KindConfig && os.Getenv(e.notice.Name) != "" {
+			set = append(set, e)
+		}
+	}
+	r.mu.Unlock()
+	sort.Slice(set, func(i, j int) bool { return set[i].notice.Name < set[j].notice.Name })
+
+	var disabled []string
+	for _, e := range set {
+		if err := r.use(context.Background(), e); err != nil {
+			disabled = append(disabled, e.notice.Name)
+		}
+	}
+	if len(disabled) > 0 {
+		return fmt.Errorf("%w: config keys %s are no longer read", ErrSunset, strings.Join(disabled, ", "))
+	}
+	return nil
+}
+
+// route returns the notice of a route, if it is deprecated
+func (r *Registry) route(method, path string) *entry {
+	segments := splitPath(path)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var best *entry
+	for _, e := range r.entries {
+		if e.notice.Kind != KindRoute || (e.method != method && e.method != "*") || !matchPath(e.pattern, segments) {
+			continue
+		}
+		// The most specific pattern wins
+		if best == nil || len(e.pattern) > len(best.pattern) {
+			best = e
+		}
+	}
+	return best
+}
+
+// use counts a use of a notice, logs it and checks its sunset
+func (r *Registry) use(ctx context.Context, e *entry) error {
+	now := time.Now()
+	disabled := r.disabled(&e.notice, now)
+
+	r.mu.Lock()
+	e.uses++
+	e.lastUsed = now
+	if disabled {
+		e.blocked++
+	}
+	shouldLog := r.config.LogInterval == 0 || now.Sub(e.lastLog) >= r.config.LogInterval
+	if shouldLog {
+		e.lastLog = now
+	}
+	notice := e.notice
+	r.mu.Unlock()
+
+	if r.metrics != nil {
+		name := metricName(notice)
+		labels := map[string]string{"kind": string(notice.Kind), "name": notice.Name}
+		r.metrics.AddCounter("deprecated_"+name+"_used_total", "Uses of deprecated "+notice.Name, labels, 1)
+		if disabled {
+			r.metrics.AddCounter("deprecated_"+name+"_blocked_total", "Uses of "+notice.Name+" refused after its sunset", labels, 1)
+		}
+	}
+
+	if shouldLog {
+		fields := logger.Fields{"kind": string(notice.Kind), "name": notice.Name}
+		if !notice.Sunset.IsZero() {
+			fields["sunset"] = notice.Sunset.Format(dateLayout)
+		}
+		if disabled {
+			logger.Default().WithContext(ctx).Error("Deprecated "+string(notice.Kind)+" used after its sunset", fields)
+		} else {
+			logger.Default().WithContext(ctx).Warn(notice.Warning(), fields)
+		}
+	}
+
+	if disabled {
+		return fmt.Errorf("%w: %s was removed on %s", ErrSunset, notice.Name, notice.Sunset.Format(dateLayout))
+	}
+	return nil
+}
+
+// disabled reports whether a notice refuses use at a time
+func (r *Registry) disabled(notice *Notice, now time.Time) bool {
+	return r.config.Enforce && notice.Disable && !notice.Sunset.IsZero() && !now.Before(notice.Sunset)
+}
+
+// Notices returns every notice and its usage, by kind and name
+func (r *Registry) Notices() []Status {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statuses := make([]Status, 0, len(r.entries))
+	for _, e := range r.entries {
+		statuses = append(statuses, Status{
+			Notice:   e.notice,
+			Disabled: r.disabled(&e.notice, now),
+			Uses:     e.uses,
+			Blocked:  e.blocked,
+			LastUsed: e.lastUsed,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].key() < statuses[j].key()
+	})
+	return statuses
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// matchPath matches path segments against a pattern, where :params match
+// one segment and a trailing * the rest
+func matchPath(pattern, segments []string) bool {
+	for i, part := range pattern {
+		if part == "*" && i == len(pattern)-1 {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if !strings.HasPrefix(part, ":") && part != segments[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(segments)
+}
+
+// metricName turns a notice into a metric name fragment
+func metricName(notice Notice) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '_'
+		}
+	}, notice.Name)
+	return string(notice.Kind) + "_" + strings.Trim(name, "_")
+}
@@ -0,0 +1,43 @@
+package deprecation
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"neonexcore/pkg/api"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Middleware marks responses of deprecated routes with Deprecation,
+// Sunset, Link and Warning headers, and answers 410 Gone once a disabled
+// route is past its sunset
+func (r *Registry) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		e := r.route(c.Method(), c.Path())
+		if e == nil {
+			return c.Next()
+		}
+
+		notice := e.notice
+		setHeaders(c, &notice)
+		if err := r.use(c.UserContext(), e); err != nil {
+			message := fmt.Sprintf("%s was removed on %s", notice.Name, notice.Sunset.Format(dateLayout))
+			return api.Error(c, fiber.StatusGone, message, nil)
+		}
+		return c.Next()
+	}
+}
+
+// setHeaders sets the deprecation headers of RFC 9745 and RFC 8594
+func setHeaders(c *fiber.Ctx, notice *Notice) {
+	c.Set("Deprecation", "@"+strconv.FormatInt(notice.Since.Unix(), 10))
+	if !notice.Sunset.IsZero() {
+		c.Set("Sunset", notice.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if notice.Link != "" {
+		c.Append("Link", fmt.Sprintf(`<%s>; rel="deprecation"`, notice.Link))
+	}
+	c.Set("Warning", fmt.Sprintf(`299 - %q`, notice.Warning()))
+}
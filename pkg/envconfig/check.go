@@ -0,0 +1,172 @@
+package envconfig
+
+import "sort"
+
+// Issue is a problem with one key
+type Issue struct {
+	Key     string `json:"key"`
+	Module  string `json:"module,omitempty"`
+	Source  string `json:"source,omitempty"` // File the value came from
+	Message string `json:"message"`
+}
+
+// Report is the result of checking an environment against a schema
+type Report struct {
+	Environment string   `json:"environment"`
+	Files       []string `json:"files"`
+	Checked     int      `json:"checked"` // Keys set in the environment
+	Invalid     []Issue  `json:"invalid"` // Values that do not parse or are out of range
+	Missing     []Issue  `json:"missing"` // Required keys that are not set
+	Unknown     []Issue  `json:"unknown"` // Keys no module reads, usually typos
+}
+
+// OK reports whether the check found no problems
+func (r *Report) OK() bool {
+	return len(r.Invalid) == 0 && len(r.Missing) == 0 && len(r.Unknown) == 0
+}
+
+// Check validates every set value, and reports required keys that are not
+// set and set keys the schema does not know
+func (s *Schema) Check(env *Environment) *Report {
+	report := &Report{
+		Environment: env.Name,
+		Files:       env.Files,
+		Checked:     len(env.Values),
+		Invalid:     []Issue{},
+		Missing:     []Issue{},
+		Unknown:     []Issue{},
+	}
+
+	for _, key := range sortedKeys(env.Values) {
+		value := env.Values[key]
+		if !s.known[key] {
+			issue := Issue{Key: key, Source: env.Sources[key], Message: "not read by any module"}
+			if suggestion := s.suggest(key); suggestion != "" {
+				issue.Message += ", did you mean " + suggestion + "?"
+			}
+			report.Unknown = append(report.Unknown, issue)
+			continue
+		}
+		if v := s.vars[key]; v != nil {
+			if err := v.Validate(value); err != nil {
+				message := err.Error()
+				if s.Secret(key) {
+					message = "invalid " + v.typeName()
+				}
+				report.Invalid = append(report.Invalid, Issue{Key: key, Module: v.Module, Source: env.Sources[key], Message: message})
+			}
+		}
+	}
+
+	for _, key := range s.Keys() {
+		v := s.vars[key]
+		if v == nil || !v.RequiredFor(env.Name) {
+			continue
+		}
+		if env.Values[key] == "" {
+			message := "required"
+			if !v.Required {
+				message = "required in " + env.Name
+			}
+			report.Missing = append(report.Missing, Issue{Key: key, Module: v.Module, Message: message})
+		}
+	}
+
+	return report
+}
+
+// Difference is a key whose values differ between two environments
+type Difference struct {
+	Key      string `json:"key"`
+	Module   string `json:"module,omitempty"`
+	Left     string `json:"left"`
+	Right    string `json:"right"`
+	LeftSet  bool   `json:"left_set"`
+	RightSet bool   `json:"right_set"`
+}
+
+// Diff lists the keys set differently in two environments, sorted by key.
+// Secret values are masked.
+func (s *Schema) Diff(left, right *Environment) []Difference {
+	keys := make(map[string]bool)
+	for key := range left.Values {
+		keys[key] = true
+	}
+	for key := range right.Values {
+		keys[key] = true
+	}
+
+	differences := []Difference{}
+	for _, key := range sortedKeys(keys) {
+		l, lok := left.Values[key]
+		r, rok := right.Values[key]
+		if lok == rok && l == r {
+			continue
+		}
+		difference := Difference{Key: key, Left: l, Right: r, LeftSet: lok, RightSet: rok}
+		if v := s.vars[key]; v != nil {
+			difference.Module = v.Module
+		}
+		if s.Secret(key) {
+			difference.Left, difference.Right = mask(l), mask(r)
+		}
+		differences = append(differences, difference)
+	}
+	return differences
+}
+
+func (v *Var) typeName() string {
+	if v.Type == "" {
+		return TypeString
+	}
+	return v.Type
+}
+
+func mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "********"
+}
+
+// suggest returns the known key closest to an unknown one, if it is close
+// enough to be a typo
+func (s *Schema) suggest(key string) string {
+	best, bestDistance := "", len(key)/3+1
+	for known := range s.known {
+		if d := distance(key, known); d < bestDistance || (d == bestDistance && best != "" && known < best) {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance between two strings
+func distance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
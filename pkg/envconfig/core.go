@@ -0,0 +1,202 @@
+package envconfig
+
+// CoreVars returns the keys the framework itself reads, outside modules:
+// profile, logging, database, startup, cache, queue, server, AI, vector
+// store, login protection, passkeys, storage, signing, sandbox, event
+// capture and warehouse streaming
+func CoreVars() []Var {
+	return []Var{
+		{Key: "APP_NAME"},
+		{Key: "APP_ENV"},
+		{Key: "APP_DEBUG", Type: TypeBool},
+		{Key: "APP_PROFILE", Type: TypeEnum, Values: []string{"dev"}},
+
+		{Key: "LOG_LEVEL", Type: TypeEnum, Values: []string{"debug", "info", "warn", "warning", "error", "fatal"}},
+		{Key: "LOG_FORMAT", Type: TypeEnum, Values: []string{"text", "json"}},
+		{Key: "LOG_OUTPUT", Type: TypeEnum, Values: []string{"console", "file", "both"}},
+		{Key: "LOG_FILE_PATH"},
+		{Key: "LOG_REDACT", Type: TypeBool},
+		{Key: "LOG_REDACT_FIELDS", Type: TypeList},
+		{Key: "LOG_SHIP_TARGET", Type: TypeEnum, Values: []string{"loki", "elasticsearch", "elastic", "otlp"}},
+		{Key: "LOG_SHIP_ENDPOINT", Type: TypeURL},
+		{Key: "LOG_ASYNC", Type: TypeBool},
+		{Key: "LOG_ASYNC_BUFFER", Type: TypeInt, Min: bound(1)},
+		{Key: "LOG_ASYNC_POLICY", Type: TypeEnum, Values: []string{"block", "drop_oldest", "drop_newest"}},
+
+		{Key: "DB_DRIVER", Type: TypeEnum, Values: []string{"sqlite", "mysql", "postgres", "postgresql", "turso"}},
+		{Key: "DB_HOST"},
+		{Key: "DB_PORT", Type: TypeInt, Min: bound(1), Max: bound(65535)},
+		{Key: "DB_USERNAME"},
+		{Key: "DB_PASSWORD", Secret: true},
+		{Key: "DB_DATABASE"},
+		{Key: "DB_CHARSET"},
+		{Key: "DB_PARSE_TIME", Type: TypeBool},
+		{Key: "DB_LOC"},
+
+		{Key: "ID_STRATEGY", Type: TypeEnum, Values: []string{"uuidv7", "ulid", "snowflake"}},
+		{Key: "ID_NODE", Type: TypeInt, Min: bound(0), Max: bound(1023)},
+		{Key: "ID_EPOCH"},
+
+		{Key: "BOOT_WAIT_FOR", Type: TypeList},
+		{Key: "BOOT_WAIT_DATABASE", Type: TypeBool},
+		{Key: "BOOT_WAIT_TIMEOUT", Type: TypeDuration},
+		{Key: "BOOT_WAIT_INTERVAL", Type: TypeDuration},
+		{Key: "BOOT_WAIT_MAX_INTERVAL", Type: TypeDuration},
+
+		{Key: "MONITOR_TARGETS", Type: TypeList, Secret: true},
+		{Key: "MONITOR_INTERVAL", Type: TypeDuration},
+		{Key: "MONITOR_TIMEOUT", Type: TypeDuration},
+		{Key: "MONITOR_FAILURE_THRESHOLD", Type: TypeInt, Min: bound(1)},
+		{Key: "MONITOR_SLOW_THRESHOLD", Type: TypeDuration},
+
+		{Key: "CACHE_DRIVER", Type: TypeEnum, Values: []string{"memory", "redis", "memcached"}},
+		{Key: "REDIS_URL", Type: TypeURL, Secret: true},
+		{Key: "MEMCACHED_SERVERS", Type: TypeList},
+
+		{Key: "QUEUE_DRIVER", Type: TypeEnum, Values: []string{"memory", "redis"}},
+		{Key: "QUEUE_REDIS_URL", Type: TypeURL, Secret: true},
+		{Key: "QUEUE_PREFIX"},
+		{Key: "QUEUE_CONCURRENCY", Type: TypeInt, Min: bound(1)},
+		{Key: "QUEUE_MAX_ATTEMPTS", Type: TypeInt, Min: bound(1)},
+		{Key: "QUEUE_RETRY_DELAY", Type: TypeDuration},
+		{Key: "QUEUE_ACK_TIMEOUT", Type: TypeDuration},
+
+		{Key: "WS_FANOUT_WORKERS", Type: TypeInt, Min: bound(1)},
+		{Key: "WS_FANOUT_QUEUE_SIZE", Type: TypeInt, Min: bound(1)},
+		{Key: "WS_SEND_BUFFER_SIZE", Type: TypeInt, Min: bound(1)},
+		{Key: "WS_SLOW_CLIENT_THRESHOLD", Type: TypeInt, Min: bound(1)},
+		{Key: "WS_SLOW_CLIENT_POLICY", Type: TypeEnum, Values: []string{"disconnect", "drop"}},
+		{Key: "WS_POLL_BUFFER_SIZE", Type: TypeInt, Min: bound(1)},
+		{Key: "WS_POLL_MAX_WAIT", Type: TypeDuration},
+		{Key: "WS_BUS_DRIVER", Type: TypeEnum, Values: []string{"none", "redis"}},
+		{Key: "WS_BUS_REDIS_URL", Type: TypeURL, Secret: true},
+		{Key: "WS_BUS_CHANNEL"},
+
+		{Key: "HTTP_PORT", Type: TypeInt, Min: bound(1), Max: bound(65535)},
+		{Key: "HTTP_HOST"},
+
+		{Key: "OPENAI_API_KEY", Secret: true},
+		{Key: "ANTHROPIC_API_KEY", Secret: true},
+		{Key: "ANTHROPIC_RPM", Type: TypeInt, Min: bound(0)},
+		{Key: "GEMINI_API_KEY", Secret: true},
+		{Key: "GEMINI_RPM", Type: TypeInt, Min: bound(0)},
+		{Key: "AZURE_OPENAI_ENDPOINT", Type: TypeURL},
+		{Key: "AZURE_OPENAI_API_KEY", Secret: true},
+		{Key: "AZURE_OPENAI_API_VERSION"},
+		{Key: "AZURE_OPENAI_DEPLOYMENTS", Type: TypeList},
+		{Key: "OLLAMA_HOST", Type: TypeURL},
+		{Key: "ONNXRUNTIME_LIB"},
+		{Key: "ONNXRUNTIME_THREADS", Type: TypeInt, Min: bound(0)},
+		{Key: "AI_MODEL_PRICES", Type: TypeList},
+		{Key: "AI_BUDGET_MONTHLY", Type: TypeFloat, Min: bound(0)},
+		{Key: "AI_BUDGET_MONTHLY_TOTAL", Type: TypeFloat, Min: bound(0)},
+		{Key: "AI_BUDGET_PER_REQUEST", Type: TypeFloat, Min: bound(0)},
+		{Key: "AI_BATCH_MODELS", Type: TypeList},
+
+		{Key: "VECTOR_STORE", Type: TypeEnum, Values: []string{"memory", "pgvector", "qdrant"}},
+		{Key: "VECTOR_METRIC", Type: TypeEnum, Values: []string{"cosine", "dot", "euclidean"}},
+		{Key: "QDRANT_URL", Type: TypeURL},
+		{Key: "QDRANT_API_KEY", Secret: true},
+
+		{Key: "LOGIN_PROTECTION", Type: TypeBool},
+		{Key: "LOGIN_MAX_ATTEMPTS", Type: TypeInt, Min: bound(1)},
+		{Key: "LOGIN_MAX_ATTEMPTS_PER_IP", Type: TypeInt, Min: bound(1)},
+		{Key: "LOGIN_ATTEMPT_WINDOW", Type: TypeDuration},
+		{Key: "LOGIN_LOCKOUT_DURATION", Type: TypeDuration},
+		{Key: "LOGIN_MAX_LOCKOUT_DURATION", Type: TypeDuration},
+		{Key: "LOGIN_IP_BLOCK_DURATION", Type: TypeDuration},
+		{Key: "LOGIN_DELAY_AFTER", Type: TypeInt, Min: bound(0)},
+		{Key: "LOGIN_BASE_DELAY", Type: TypeDuration},
+		{Key: "LOGIN_MAX_DELAY", Type: TypeDuration},
+		{Key: "LOGIN_CAPTCHA_AFTER", Type: TypeInt, Min: bound(0)},
+		{Key: "LOGIN_NOTIFY_LOCKOUT", Type: TypeBool},
+		{Key: "CAPTCHA_PROVIDER", Type: TypeEnum, Values: []string{"recaptcha", "hcaptcha", "turnstile"}},
+		{Key: "CAPTCHA_SECRET", Secret: true},
+
+		{Key: "WEBAUTHN_RP_ID"},
+		{Key: "WEBAUTHN_RP_NAME"},
+		{Key: "WEBAUTHN_ORIGINS", Type: TypeList},
+		{Key: "WEBAUTHN_TIMEOUT", Type: TypeDuration},
+		{Key: "WEBAUTHN_USER_VERIFICATION", Type: TypeEnum, Values: []string{"required", "preferred", "discouraged"}},
+
+		{Key: "STORAGE_DRIVER", Type: TypeEnum, Values: []string{"local"}},
+		{Key: "STORAGE_ROOT"},
+		{Key: "STORAGE_BASE_URL"},
+
+		{Key: "SIGNING_SECRET", Secret: true, RequiredIn: []string{"production"}},
+		{Key: "SIGNING_DEFAULT_TTL", Type: TypeDuration},
+		{Key: "REQUEST_SIGNING_KEYS", Type: TypeList, Secret: true},
+		{Key: "REQUEST_SIGNING_MAX_SKEW", Type: TypeDuration},
+		{Key: "SESSION_KEYS", Type: TypeList, Secret: true, RequiredIn: []string{"production"}},
+		{Key: "SESSION_COOKIE"},
+		{Key: "SESSION_TTL", Type: TypeDuration},
+		{Key: "SESSION_COOKIE_DOMAIN"},
+		{Key: "SESSION_COOKIE_SECURE", Type: TypeBool},
+		{Key: "SESSION_COOKIE_SAMESITE", Type: TypeEnum, Values: []string{"Strict", "Lax", "None"}},
+
+		{Key: "DEFAULT_LOCALE"},
+		{Key: "LOCALES", Type: TypeList},
+
+		{Key: "VIEWS_DIR"},
+		{Key: "VIEWS_LAYOUT"},
+		{Key: "VIEWS_RELOAD", Type: TypeBool},
+
+		{Key: "SERVICE_REGISTRY_DRIVER", Type: TypeEnum, Values: []string{"memory", "consul", "etcd", "kubernetes"}},
+		{Key: "SERVICE_REGISTRY_TIMEOUT", Type: TypeDuration},
+		{Key: "CONSUL_HTTP_ADDR"},
+		{Key: "CONSUL_HTTP_TOKEN", Secret: true},
+		{Key: "CONSUL_DATACENTER"},
+		{Key: "ETCD_ENDPOINTS", Type: TypeList},
+		{Key: "ETCD_PREFIX"},
+		{Key: "ETCD_USERNAME"},
+		{Key: "ETCD_PASSWORD", Secret: true},
+		{Key: "KUBECONFIG"},
+		{Key: "K8S_NAMESPACE"},
+		{Key: "K8S_PORT_NAME"},
+		{Key: "K8S_POD_LABELS", Type: TypeList},
+
+		{Key: "METRICS_PUSH_URL", Type: TypeURL},
+		{Key: "METRICS_PUSH_FORMAT", Type: TypeEnum, Values: []string{"prometheus", "json"}},
+		{Key: "METRICS_PUSH_JOB"},
+		{Key: "METRICS_PUSH_RUN_ID"},
+		{Key: "METRICS_PUSH_INTERVAL", Type: TypeDuration},
+		{Key: "METRICS_PUSH_TOKEN", Secret: true},
+
+		{Key: "WAREHOUSE_DRIVER", Type: TypeEnum, Values: []string{"clickhouse", "bigquery"}},
+		{Key: "WAREHOUSE_TABLE_PREFIX"},
+		{Key: "WAREHOUSE_EVENTS", Type: TypeBool},
+		{Key: "WAREHOUSE_EVENT_NAMES", Type: TypeList},
+		{Key: "WAREHOUSE_REQUESTS", Type: TypeBool},
+		{Key: "WAREHOUSE_METRICS_INTERVAL", Type: TypeDuration},
+		{Key: "WAREHOUSE_RETENTION_DAYS", Type: TypeInt, Min: bound(0)},
+		{Key: "WAREHOUSE_BATCH_SIZE", Type: TypeInt, Min: bound(1)},
+		{Key: "WAREHOUSE_BUFFER_SIZE", Type: TypeInt, Min: bound(1)},
+		{Key: "WAREHOUSE_FLUSH_INTERVAL", Type: TypeDuration},
+		{Key: "CLICKHOUSE_URL", Type: TypeURL},
+		{Key: "CLICKHOUSE_DATABASE"},
+		{Key: "CLICKHOUSE_USERNAME"},
+		{Key: "CLICKHOUSE_PASSWORD", Secret: true},
+		{Key: "BIGQUERY_PROJECT"},
+		{Key: "BIGQUERY_DATASET"},
+		{Key: "BIGQUERY_CREDENTIALS_FILE"},
+		{Key: "BIGQUERY_ACCESS_TOKEN", Secret: true},
+		{Key: "BIGQUERY_ENDPOINT", Type: TypeURL},
+
+		{Key: "DEPRECATIONS_FILE"},
+		{Key: "DEPRECATION_ENFORCE", Type: TypeBool},
+		{Key: "DEPRECATION_LOG_INTERVAL", Type: TypeDuration},
+
+		{Key: "SANDBOX_ENABLED", Type: TypeBool},
+		{Key: "SANDBOX_DB_DATABASE"},
+
+		{Key: "EVENT_CAPTURE_ENABLED", Type: TypeBool},
+		{Key: "EVENT_CAPTURE_WINDOW", Type: TypeDuration},
+		{Key: "EVENT_CAPTURE_MAX_EVENTS", Type: TypeInt, Min: bound(1)},
+		{Key: "EVENT_CAPTURE_EVENTS", Type: TypeList},
+		{Key: "EVENT_REPLAY_ENABLED", Type: TypeBool},
+	}
+}
+
+func bound(value float64) *float64 {
+	return &value
+}
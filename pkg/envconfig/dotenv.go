@@ -0,0 +1,174 @@
+package envconfig
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Environment is the configuration of one deployment environment, read
+// from .env files
+type Environment struct {
+	Name    string
+	Values  map[string]string
+	Sources map[string]string // File each value was read from
+	Files   []string          // Files read, in order
+}
+
+// Lookup returns a value and whether it is set
+func (e *Environment) Lookup(key string) (string, bool) {
+	value, ok := e.Values[key]
+	return value, ok
+}
+
+// Load reads the configuration of an environment from dir: .env, then
+// .env.<name> on top of it. An empty name reads .env alone. At least one
+// of the files must exist.
+func Load(dir, name string) (*Environment, error) {
+	env := &Environment{
+		Name:    name,
+		Values:  make(map[string]string),
+		Sources: make(map[string]string),
+	}
+
+	files := []string{filepath.Join(dir, ".env")}
+	if name != "" {
+		files = append(files, filepath.Join(dir, ".env."+name))
+	}
+	for _, path := range files {
+		values, err := ParseFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			env.Values[key] = value
+			env.Sources[key] = path
+		}
+		env.Files = append(env.Files, path)
+	}
+	if len(env.Files) == 0 {
+		return nil, fmt.Errorf("no configuration found for %q: %s: %w", name, strings.Join(files, ", "), fs.ErrNotExist)
+	}
+	return env, nil
+}
+
+// Overlay sets values from the process environment for the given keys,
+// as the application would see them when both are present
+func (e *Environment) Overlay(keys []string) {
+	for _, key := range keys {
+		if value, ok := os.LookupEnv(key); ok {
+			e.Values[key] = value
+			e.Sources[key] = "process environment"
+		}
+	}
+}
+
+// ParseFile parses a .env file
+func ParseFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values, err := Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// Parse parses KEY=VALUE lines. Blank lines and # comments are skipped,
+// an "export " prefix is allowed, double-quoted values understand \n, \t,
+// \" and \\, single-quoted values are literal, and unquoted values end at
+// a " #" comment.
+func Parse(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !validKey(key) {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		value, err := parseValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func parseValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	switch quote := value[0]; quote {
+	case '\'':
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quote")
+		}
+		return value[1 : end+1], nil
+	case '"':
+		var out strings.Builder
+		for i := 1; i < len(value); i++ {
+			c := value[i]
+			switch {
+			case c == '"':
+				return out.String(), nil
+			case c == '\\' && i+1 < len(value):
+				i++
+				switch value[i] {
+				case 'n':
+					out.WriteByte('\n')
+				case 't':
+					out.WriteByte('\t')
+				default:
+					out.WriteByte(value[i])
+				}
+			default:
+				out.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated quote")
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value), nil
+}
+
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
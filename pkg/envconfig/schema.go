@@ -0,0 +1,266 @@
+package envconfig
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Value types
+const (
+	TypeString   = "string"
+	TypeBool     = "bool"
+	TypeInt      = "int"
+	TypeFloat    = "float"
+	TypeDuration = "duration"
+	TypeURL      = "url"
+	TypeList     = "list"   // Comma separated
+	TypeEnum     = "enum"   // One of Values
+	TypeBase64   = "base64" // Standard base64, Bytes long when set
+)
+
+// Var describes one configuration key. Modules declare theirs in the
+// "env" list of their module.json.
+type Var struct {
+	Key         string   `json:"key"`
+	Type        string   `json:"type,omitempty"` // Defaults to string
+	Description string   `json:"description,omitempty"`
+	Default     string   `json:"default,omitempty"`
+	Required    bool     `json:"required,omitempty"`
+	RequiredIn  []string `json:"required_in,omitempty"` // Environments it is required in
+	Values      []string `json:"values,omitempty"`      // Allowed values of an enum, or of each list item
+	Min         *float64 `json:"min,omitempty"`
+	Max         *float64 `json:"max,omitempty"`
+	Bytes       int      `json:"bytes,omitempty"`
+	Secret      bool     `json:"secret,omitempty"` // Masked in reports and diffs
+	Module      string   `json:"-"`
+}
+
+// RequiredFor reports whether the key must be set in an environment
+func (v *Var) RequiredFor(environment string) bool {
+	return v.Required || containsString(v.RequiredIn, environment)
+}
+
+// Validate checks a set value. Empty values mean "use the default" and
+// are always valid; whether they are allowed is up to RequiredFor.
+func (v *Var) Validate(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	var number float64
+	var checkRange bool
+	switch v.Type {
+	case "", TypeString:
+	case TypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("not a boolean: %q", value)
+		}
+	case TypeInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("not an integer: %q", value)
+		}
+		number, checkRange = float64(n), true
+	case TypeFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("not a number: %q", value)
+		}
+		number, checkRange = f, true
+	case TypeDuration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("not a duration: %q", value)
+		}
+		number, checkRange = d.Seconds(), true
+	case TypeURL:
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("not an absolute URL: %q", value)
+		}
+	case TypeList:
+		if len(v.Values) > 0 {
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" && !v.allowed(item) {
+					return fmt.Errorf("unknown value %q, expected one of %s", item, strings.Join(v.Values, ", "))
+				}
+			}
+		}
+	case TypeEnum:
+		if !v.allowed(value) {
+			return fmt.Errorf("unknown value %q, expected one of %s", value, strings.Join(v.Values, ", "))
+		}
+	case TypeBase64:
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return fmt.Errorf("not base64")
+		}
+		if v.Bytes > 0 && len(decoded) != v.Bytes {
+			return fmt.Errorf("decodes to %d bytes, expected %d", len(decoded), v.Bytes)
+		}
+	default:
+		return fmt.Errorf("unknown type %q in schema", v.Type)
+	}
+
+	if checkRange {
+		if v.Min != nil && number < *v.Min {
+			return fmt.Errorf("%s is below the minimum of %s", value, formatBound(v.Type, *v.Min))
+		}
+		if v.Max != nil && number > *v.Max {
+			return fmt.Errorf("%s is above the maximum of %s", value, formatBound(v.Type, *v.Max))
+		}
+	}
+	return nil
+}
+
+func (v *Var) allowed(value string) bool {
+	return containsString(v.Values, value)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func formatBound(kind string, bound float64) string {
+	if kind == TypeDuration {
+		return (time.Duration(bound * float64(time.Second))).String()
+	}
+	return strconv.FormatFloat(bound, 'f', -1, 64)
+}
+
+// Schema is the set of configuration keys the application reads. Keys
+// can be typed Vars or merely known, e.g. documented in .env.example.
+type Schema struct {
+	vars  map[string]*Var
+	known map[string]bool
+}
+
+// NewSchema creates an empty schema
+func NewSchema() *Schema {
+	return &Schema{vars: make(map[string]*Var), known: make(map[string]bool)}
+}
+
+// Add adds a module's vars. A key declared by several modules keeps the
+// first declaration.
+func (s *Schema) Add(module string, vars ...Var) error {
+	for _, v := range vars {
+		if !validKey(v.Key) {
+			return fmt.Errorf("%s: invalid key %q", module, v.Key)
+		}
+		if v.Type == TypeEnum && len(v.Values) == 0 {
+			return fmt.Errorf("%s: enum %s has no values", module, v.Key)
+		}
+		if existing, ok := s.vars[v.Key]; ok {
+			if !containsString(strings.Split(existing.Module, ","), module) {
+				existing.Module += "," + module
+			}
+			continue
+		}
+		v.Module = module
+		s.vars[v.Key] = &v
+		s.known[v.Key] = true
+	}
+	return nil
+}
+
+// AddKnown adds keys without validation rules
+func (s *Schema) AddKnown(keys ...string) {
+	for _, key := range keys {
+		s.known[key] = true
+	}
+}
+
+// LoadModules adds the vars each module in dir declares in its
+// module.json, under the module's name
+func (s *Schema) LoadModules(dir string) error {
+	manifests, err := filepath.Glob(filepath.Join(dir, "*", "module.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(manifests)
+	for _, path := range manifests {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var manifest struct {
+			Name string `json:"name"`
+			Env  []Var  `json:"env"`
+		}
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if manifest.Name == "" {
+			manifest.Name = filepath.Base(filepath.Dir(path))
+		}
+		if err := s.Add(manifest.Name, manifest.Env...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadExample marks every key in an example file, such as .env.example,
+// as known. A missing file is not an error.
+func (s *Schema) LoadExample(path string) error {
+	values, err := ParseFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for key := range values {
+		s.known[key] = true
+	}
+	return nil
+}
+
+// Var returns a key's var, or nil
+func (s *Schema) Var(key string) *Var {
+	return s.vars[key]
+}
+
+// Known reports whether a key is part of the schema
+func (s *Schema) Known(key string) bool {
+	return s.known[key]
+}
+
+// Keys returns every known key, sorted
+func (s *Schema) Keys() []string {
+	keys := make([]string, 0, len(s.known))
+	for key := range s.known {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Secret reports whether a key's values should be masked: declared
+// secret, or named like a password, secret, token, key or salt
+func (s *Schema) Secret(key string) bool {
+	if v := s.vars[key]; v != nil && v.Secret {
+		return true
+	}
+	for _, word := range []string{"PASSWORD", "SECRET", "TOKEN", "API_KEY", "MASTER_KEY", "SALT"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
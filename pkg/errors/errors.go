@@ -25,6 +25,8 @@ const (
 	ErrCodeTokenInvalid       ErrorCode = "TOKEN_INVALID"
 	ErrCodeAccountLocked      ErrorCode = "ACCOUNT_LOCKED"
 	ErrCodeAccountDisabled    ErrorCode = "ACCOUNT_DISABLED"
+	ErrCodeCaptchaRequired    ErrorCode = "CAPTCHA_REQUIRED"
+	ErrCodeStepUpRequired     ErrorCode = "STEP_UP_REQUIRED"
 
 	// Database errors
 	ErrCodeDatabaseConnection ErrorCode = "DATABASE_CONNECTION"
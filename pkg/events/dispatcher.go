@@ -4,6 +4,10 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"neonexcore/pkg/sandbox"
 )
 
 // Event represents an event with data
@@ -15,12 +19,29 @@ type Event struct {
 // Handler is a function that handles an event
 type Handler func(ctx context.Context, event Event) error
 
+// Dispatched is an event as observers see it, numbered by its dispatcher
+type Dispatched struct {
+	ID     uint64 // Sequence number, from 1
+	Parent uint64 // Event whose handler dispatched this one, 0 for none
+	Event  Event
+	At     time.Time
+	Mode   sandbox.Mode
+}
+
+// Observer sees every event dispatched, before its handlers run
+type Observer func(ctx context.Context, dispatched Dispatched)
+
 // EventDispatcher manages events and handlers
 type EventDispatcher struct {
-	mu       sync.RWMutex
-	handlers map[string][]Handler
+	mu        sync.RWMutex
+	handlers  map[string][]Handler
+	observers []Observer
+	seq       atomic.Uint64
 }
 
+// currentKey holds the ID of the event whose handlers run with a context
+type currentKey struct{}
+
 // NewEventDispatcher creates a new event dispatcher
 func NewEventDispatcher() *EventDispatcher {
 	return &EventDispatcher{
@@ -36,23 +57,57 @@ func (d *EventDispatcher) Register(eventName string, handler Handler) {
 	d.handlers[eventName] = append(d.handlers[eventName], handler)
 }
 
+// Observe registers an observer of every event dispatched
+func (d *EventDispatcher) Observe(observer Observer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.observers = append(d.observers, observer)
+}
+
 // Dispatch dispatches an event to all registered handlers
 func (d *EventDispatcher) Dispatch(ctx context.Context, event Event) error {
 	d.mu.RLock()
 	handlers := d.handlers[event.Name]
+	observers := d.observers
 	d.mu.RUnlock()
 
+	dispatched := Dispatched{
+		ID:    d.seq.Add(1),
+		Event: event,
+		At:    time.Now(),
+		Mode:  sandbox.FromContext(ctx),
+	}
+	dispatched.Parent, _ = ctx.Value(currentKey{}).(uint64)
+	for _, observer := range observers {
+		observer(ctx, dispatched)
+	}
+	// Events dispatched by the handlers are caused by this one
+	ctx = context.WithValue(ctx, currentKey{}, dispatched.ID)
+
+	var err error
 	for _, handler := range handlers {
-		if err := handler(ctx, event); err != nil {
-			return fmt.Errorf("handler failed for event %s: %w", event.Name, err)
+		if err = handler(ctx, event); err != nil {
+			err = fmt.Errorf("handler failed for event %s: %w", event.Name, err)
+			break
 		}
 	}
 
-	return nil
+	if session := replayFrom(ctx); session != nil {
+		session.handled(dispatched, err)
+	}
+	return err
 }
 
-// DispatchAsync dispatches event asynchronously
+// DispatchAsync dispatches event asynchronously, or before returning
+// while replaying, so a replay runs in the order it recorded
 func (d *EventDispatcher) DispatchAsync(ctx context.Context, event Event) {
+	if IsReplay(ctx) {
+		d.Dispatch(ctx, event)
+		return
+	}
+	// Pin the mode now; a request context may be reused once the request ends
+	ctx = sandbox.WithMode(ctx, sandbox.FromContext(ctx))
 	go d.Dispatch(ctx, event)
 }
 
@@ -66,12 +121,20 @@ func (d *EventDispatcher) HasHandlers(eventName string) bool {
 // Common event names
 const (
 	// User events
-	EventUserCreated       = "user.created"
-	EventUserUpdated       = "user.updated"
-	EventUserDeleted       = "user.deleted"
-	EventUserLoggedIn      = "user.logged_in"
-	EventUserLoggedOut     = "user.logged_out"
-	EventUserPasswordReset = "user.password_reset"
+	EventUserCreated         = "user.created"
+	EventUserUpdated         = "user.updated"
+	EventUserDeleted         = "user.deleted"
+	EventUserLoggedIn        = "user.logged_in"
+	EventUserLoggedOut       = "user.logged_out"
+	EventUserPasswordReset   = "user.password_reset"
+	EventUserPasswordChanged = "user.password_changed"
+	EventUserRoleAssigned    = "user.role_assigned"
+
+	// Security events
+	EventSecurityLoginFailed   = "security.login_failed"
+	EventSecurityAccountLocked = "security.account_locked"
+	EventSecurityIPBlocked     = "security.ip_blocked"
+	EventSecurityAlert         = "security.alert"
 
 	// Module events
 	EventModuleInstalled   = "module.installed"
@@ -102,3 +165,9 @@ func Dispatch(ctx context.Context, event Event) error {
 func DispatchAsync(ctx context.Context, event Event) {
 	defaultDispatcher.DispatchAsync(ctx, event)
 }
+
+// Default returns the global dispatcher, e.g. to record or replay its
+// events
+func Default() *EventDispatcher {
+	return defaultDispatcher
+}
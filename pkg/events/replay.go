@@ -0,0 +1,456 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/sandbox"
+	"neonexcore/pkg/storage"
+)
+
+// CaptureConfig configures event capture and replay
+type CaptureConfig struct {
+	// Enabled records the events dispatched, so a window of them can be
+	// saved to storage
+	Enabled bool
+	// Replay lets this instance replay saved captures. Handlers run for
+	// real against its database; enable it on local or staging instances.
+	Replay bool
+	// Window is how long events are kept (default 15m)
+	Window time.Duration
+	// MaxEvents is how many events are kept at most (default 10000)
+	MaxEvents int
+	// Events are the names recorded, or prefixes ending in "*"; all if
+	// empty
+	Events []string
+	// Prefix is the storage key prefix of saved captures (default
+	// "event-captures/")
+	Prefix string
+}
+
+// DefaultCaptureConfig returns the default capture configuration
+func DefaultCaptureConfig() CaptureConfig {
+	return CaptureConfig{
+		Window:    15 * time.Minute,
+		MaxEvents: 10000,
+		Prefix:    "event-captures/",
+	}
+}
+
+// LoadCaptureConfig loads the capture configuration from the environment
+func LoadCaptureConfig() CaptureConfig {
+	config := DefaultCaptureConfig()
+
+	if enabled, err := strconv.ParseBool(os.Getenv("EVENT_CAPTURE_ENABLED")); err == nil {
+		config.Enabled = enabled
+	}
+	if replay, err := strconv.ParseBool(os.Getenv("EVENT_REPLAY_ENABLED")); err == nil {
+		config.Replay = replay
+	}
+	if d, err := time.ParseDuration(os.Getenv("EVENT_CAPTURE_WINDOW")); err == nil && d > 0 {
+		config.Window = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("EVENT_CAPTURE_MAX_EVENTS")); err == nil && n > 0 {
+		config.MaxEvents = n
+	}
+	if names := os.Getenv("EVENT_CAPTURE_EVENTS"); names != "" {
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				config.Events = append(config.Events, name)
+			}
+		}
+	}
+
+	return config
+}
+
+// RecordedEvent is an event in a capture, its data encoded as JSON
+type RecordedEvent struct {
+	ID     uint64          `json:"id"`
+	Parent uint64          `json:"parent,omitempty"` // Event whose handler dispatched it
+	Name   string          `json:"name"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Type   string          `json:"type,omitempty"`  // Go type of the data, e.g. *cms.Content
+	Error  string          `json:"error,omitempty"` // Why the data could not be encoded
+	Mode   sandbox.Mode    `json:"mode,omitempty"`
+	At     time.Time       `json:"at"`
+}
+
+// Capture is a window of recorded events, saved to storage to replay
+// elsewhere
+type Capture struct {
+	Host       string          `json:"host,omitempty"`
+	From       time.Time       `json:"from"`
+	To         time.Time       `json:"to"`
+	CapturedAt time.Time       `json:"captured_at"`
+	Events     []RecordedEvent `json:"events"`
+}
+
+// Recorder keeps the events a dispatcher dispatched during the last
+// Window, so a bug can be captured after it happened
+type Recorder struct {
+	config CaptureConfig
+
+	mu     sync.Mutex
+	events []RecordedEvent
+}
+
+// NewRecorder records the events of a dispatcher
+func NewRecorder(d *EventDispatcher, config CaptureConfig) *Recorder {
+	defaults := DefaultCaptureConfig()
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	if config.MaxEvents <= 0 {
+		config.MaxEvents = defaults.MaxEvents
+	}
+
+	r := &Recorder{config: config}
+	d.Observe(r.record)
+	return r
+}
+
+// record keeps a dispatched event, dropping those out of the window
+func (r *Recorder) record(_ context.Context, dispatched Dispatched) {
+	if !matchName(r.config.Events, dispatched.Event.Name) {
+		return
+	}
+
+	recorded := RecordedEvent{
+		ID:     dispatched.ID,
+		Parent: dispatched.Parent,
+		Name:   dispatched.Event.Name,
+		Mode:   dispatched.Mode,
+		At:     dispatched.At,
+	}
+	if dispatched.Event.Data != nil {
+		recorded.Type = fmt.Sprintf("%T", dispatched.Event.Data)
+		// Encoded now: the data may change once handlers run
+		if data, err := json.Marshal(dispatched.Event.Data); err == nil {
+			recorded.Data = data
+		} else {
+			recorded.Error = err.Error()
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, recorded)
+	cutoff := time.Now().Add(-r.config.Window)
+	drop := 0
+	for drop < len(r.events) && (len(r.events)-drop > r.config.MaxEvents || r.events[drop].At.Before(cutoff)) {
+		drop++
+	}
+	// Appending reallocates once capacity runs out, releasing dropped events
+	r.events = r.events[drop:]
+}
+
+// Capture returns the events recorded between from and to, the whole
+// window for zero times
+func (r *Recorder) Capture(from, to time.Time) *Capture {
+	now := time.Now()
+	if from.IsZero() {
+		from = now.Add(-r.config.Window)
+	}
+	if to.IsZero() {
+		to = now
+	}
+
+	capture := &Capture{From: from, To: to, CapturedAt: now, Events: []RecordedEvent{}}
+	capture.Host, _ = os.Hostname()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, event := range r.events {
+		if !event.At.Before(from) && !event.At.After(to) {
+			capture.Events = append(capture.Events, event)
+		}
+	}
+	return capture
+}
+
+// Save writes a capture to storage as JSON
+func (c *Capture) Save(ctx context.Context, store storage.Storage, key string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode capture: %w", err)
+	}
+	return store.Put(ctx, key, bytes.NewReader(data), "application/json")
+}
+
+// LoadCapture reads a capture saved to storage
+func LoadCapture(ctx context.Context, store storage.Storage, key string) (*Capture, error) {
+	reader, _, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var capture Capture
+	if err := json.Unmarshal(data, &capture); err != nil {
+		return nil, fmt.Errorf("invalid capture %s: %w", key, err)
+	}
+	return &capture, nil
+}
+
+// ReplayOptions configures a replay
+type ReplayOptions struct {
+	// Events are the names of the recorded events replayed, or prefixes
+	// ending in "*"; all if empty. Events a handler dispatched are not
+	// replayed themselves: replaying their cause dispatches them again.
+	Events []string
+	// Mode is the mode handlers run in, e.g. sandbox.ModeTest to write to
+	// the sandbox database; the recorded mode if empty
+	Mode sandbox.Mode
+	// Decode decodes the data of a recorded event. By default it decodes
+	// JSON generically: objects as map[string]interface{} and numbers as
+	// float64, which is what most handlers expect of map payloads; handlers
+	// of struct payloads need a decoder for their type.
+	Decode func(name string, data json.RawMessage) (interface{}, error)
+}
+
+// ReplayedEvent is an event dispatched during a replay
+type ReplayedEvent struct {
+	ID       uint64 `json:"id"`
+	Parent   uint64 `json:"parent,omitempty"`
+	Recorded uint64 `json:"recorded,omitempty"` // ID in the capture of a replayed event
+	Name     string `json:"name"`
+	Error    string `json:"error,omitempty"`
+}
+
+// StubbedEffect is a side effect a handler skipped during a replay
+type StubbedEffect struct {
+	Event  uint64      `json:"event"` // Replayed event whose handler skipped it
+	Kind   string      `json:"kind"`
+	Detail interface{} `json:"detail,omitempty"`
+}
+
+// ReplayReport is what a replay did: the events dispatched, including
+// those handlers dispatched, the side effects stubbed, and where the
+// events handlers dispatched differ from the capture
+type ReplayReport struct {
+	Replayed    int             `json:"replayed"`
+	Events      []ReplayedEvent `json:"events"`
+	Stubbed     []StubbedEffect `json:"stubbed"`
+	Differences []string        `json:"differences"`
+}
+
+// replaySession collects a replay's events and stubbed effects
+type replaySession struct {
+	mu       sync.Mutex
+	recorded uint64 // Capture ID of the event being replayed
+	events   []ReplayedEvent
+	stubbed  []StubbedEffect
+}
+
+type replayKey struct{}
+
+func replayFrom(ctx context.Context) *replaySession {
+	session, _ := ctx.Value(replayKey{}).(*replaySession)
+	return session
+}
+
+func (s *replaySession) handled(dispatched Dispatched, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event := ReplayedEvent{ID: dispatched.ID, Parent: dispatched.Parent, Name: dispatched.Event.Name}
+	if dispatched.Parent == 0 {
+		event.Recorded = s.recorded
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	s.events = append(s.events, event)
+}
+
+// IsReplay reports whether ctx belongs to a replayed event, so handlers
+// can tell; those with side effects should use Stubbed instead
+func IsReplay(ctx context.Context) bool {
+	return ctx != nil && replayFrom(ctx) != nil
+}
+
+// Stubbed reports whether a side effect must be skipped because ctx
+// belongs to a replayed event, recording it in the replay's report:
+//
+//	if events.Stubbed(ctx, "email", map[string]interface{}{"to": to}) {
+//		return nil
+//	}
+func Stubbed(ctx context.Context, kind string, detail interface{}) bool {
+	if ctx == nil {
+		return false
+	}
+	session := replayFrom(ctx)
+	if session == nil {
+		return false
+	}
+
+	event, _ := ctx.Value(currentKey{}).(uint64)
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.stubbed = append(session.stubbed, StubbedEffect{Event: event, Kind: kind, Detail: detail})
+	return true
+}
+
+// Replay dispatches the recorded events of a capture again on d, in
+// order, to reproduce a bug in a handler. Handlers run for real, but the
+// events they dispatch asynchronously are dispatched before returning, and
+// side effects guarded by Stubbed are skipped. Handler errors are
+// reported, not returned.
+func Replay(ctx context.Context, d *EventDispatcher, capture *Capture, opts ReplayOptions) (*ReplayReport, error) {
+	decode := opts.Decode
+	if decode == nil {
+		decode = decodeJSON
+	}
+
+	inCapture := make(map[uint64]bool, len(capture.Events))
+	for _, event := range capture.Events {
+		inCapture[event.ID] = true
+	}
+
+	session := &replaySession{}
+	ctx = context.WithValue(ctx, replayKey{}, session)
+	// Replayed events are roots, whatever event ctx comes from
+	ctx = context.WithValue(ctx, currentKey{}, uint64(0))
+
+	report := &ReplayReport{}
+	for _, recorded := range capture.Events {
+		// Dispatched again by the handlers of their cause
+		if recorded.Parent != 0 && inCapture[recorded.Parent] {
+			continue
+		}
+		if !matchName(opts.Events, recorded.Name) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if recorded.Error != "" {
+			return nil, fmt.Errorf("event %d (%s) was recorded without its data: %s", recorded.ID, recorded.Name, recorded.Error)
+		}
+
+		var data interface{}
+		if len(recorded.Data) > 0 {
+			var err error
+			if data, err = decode(recorded.Name, recorded.Data); err != nil {
+				return nil, fmt.Errorf("event %d (%s): %w", recorded.ID, recorded.Name, err)
+			}
+		}
+
+		mode := opts.Mode
+		if mode == "" {
+			mode = recorded.Mode
+		}
+		eventCtx := ctx
+		if mode.Valid() {
+			eventCtx = sandbox.WithMode(ctx, mode)
+		}
+
+		session.mu.Lock()
+		session.recorded = recorded.ID
+		session.mu.Unlock()
+		d.Dispatch(eventCtx, Event{Name: recorded.Name, Data: data})
+		report.Replayed++
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	sort.Slice(session.events, func(i, j int) bool { return session.events[i].ID < session.events[j].ID })
+	report.Events = append([]ReplayedEvent{}, session.events...)
+	report.Stubbed = append([]StubbedEffect{}, session.stubbed...)
+	report.Differences = compareCascades(capture, report.Events)
+	return report, nil
+}
+
+// compareCascades lists the replayed events whose handlers dispatched
+// other events than they did when recorded
+func compareCascades(capture *Capture, replayed []ReplayedEvent) []string {
+	recordedChildren := make(map[uint64][]string)
+	for _, event := range capture.Events {
+		if event.Parent != 0 {
+			recordedChildren[event.Parent] = append(recordedChildren[event.Parent], event.Name)
+		}
+	}
+	replayedChildren := make(map[uint64][]string)
+	for _, event := range replayed {
+		if event.Parent != 0 {
+			replayedChildren[event.Parent] = append(replayedChildren[event.Parent], event.Name)
+		}
+	}
+
+	// Only the children of replayed roots can be matched up
+	differences := []string{}
+	for _, event := range replayed {
+		if event.Recorded == 0 {
+			continue
+		}
+		want := countNames(recordedChildren[event.Recorded])
+		got := countNames(replayedChildren[event.ID])
+		names := make([]string, 0, len(want)+len(got))
+		for name := range want {
+			names = append(names, name)
+		}
+		for name := range got {
+			if _, ok := want[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if want[name] != got[name] {
+				differences = append(differences, fmt.Sprintf("%s (recorded #%d) dispatched %s %d times, %d when recorded",
+					event.Name, event.Recorded, name, got[name], want[name]))
+			}
+		}
+	}
+	return differences
+}
+
+func countNames(names []string) map[string]int {
+	counts := make(map[string]int, len(names))
+	for _, name := range names {
+		counts[name]++
+	}
+	return counts
+}
+
+func decodeJSON(_ string, data json.RawMessage) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, errors.New("invalid recorded data: " + err.Error())
+	}
+	return value, nil
+}
+
+// matchName reports whether an event name is one of names or starts with
+// one ending in "*"; every name matches no names
+func matchName(names []string, name string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	for _, pattern := range names {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/sandbox"
+	"neonexcore/pkg/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var captureName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// CaptureRequest is the body of a capture: the window saved, the whole
+// recorded window by default
+type CaptureRequest struct {
+	Name string    `json:"name"` // Storage name, a timestamp by default
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// ReplayRequest is the body of a replay
+type ReplayRequest struct {
+	Events []string     `json:"events"`
+	Mode   sandbox.Mode `json:"mode"`
+}
+
+// RegisterCaptureRoutes registers endpoints saving the events recorder
+// recorded to store, when it is not nil, and replaying saved captures on
+// d, when config.Replay is set, on a router that authenticates
+// administrators:
+//
+//	POST /captures                 save the recorded window
+//	GET  /captures/:name           download a capture
+//	POST /captures/:name/replay    replay a capture
+func RegisterCaptureRoutes(router fiber.Router, d *EventDispatcher, recorder *Recorder, store storage.Storage, config CaptureConfig) {
+	if config.Prefix == "" {
+		config.Prefix = DefaultCaptureConfig().Prefix
+	}
+	key := func(name string) string {
+		return config.Prefix + name + ".json"
+	}
+
+	if recorder != nil {
+		router.Post("/captures", func(c *fiber.Ctx) error {
+			var req CaptureRequest
+			if len(c.Body()) > 0 {
+				if err := c.BodyParser(&req); err != nil {
+					return api.BadRequest(c, "Invalid request body", nil)
+				}
+			}
+			if req.Name == "" {
+				req.Name = time.Now().UTC().Format("20060102T150405Z")
+			}
+			if !captureName.MatchString(req.Name) {
+				return api.BadRequest(c, "Name may only contain letters, digits, dots, dashes and underscores", nil)
+			}
+
+			capture := recorder.Capture(req.From, req.To)
+			if err := capture.Save(c.UserContext(), store, key(req.Name)); err != nil {
+				return api.InternalError(c, err.Error())
+			}
+			return api.Created(c, "Events captured", fiber.Map{
+				"name":   req.Name,
+				"from":   capture.From,
+				"to":     capture.To,
+				"events": len(capture.Events),
+			})
+		})
+	}
+
+	router.Get("/captures/:name", func(c *fiber.Ctx) error {
+		capture, err := loadNamedCapture(c, store, key)
+		if capture == nil {
+			return err
+		}
+		return api.Success(c, capture)
+	})
+
+	if config.Replay {
+		router.Post("/captures/:name/replay", func(c *fiber.Ctx) error {
+			var req ReplayRequest
+			if len(c.Body()) > 0 {
+				if err := c.BodyParser(&req); err != nil {
+					return api.BadRequest(c, "Invalid request body", nil)
+				}
+			}
+			if req.Mode != "" && !req.Mode.Valid() {
+				return api.BadRequest(c, fmt.Sprintf("Unknown mode %q", req.Mode), nil)
+			}
+
+			capture, err := loadNamedCapture(c, store, key)
+			if capture == nil {
+				return err
+			}
+			report, err := Replay(c.UserContext(), d, capture, ReplayOptions{Events: req.Events, Mode: req.Mode})
+			if err != nil {
+				return api.BadRequest(c, err.Error(), nil)
+			}
+			return api.Success(c, report)
+		})
+	}
+}
+
+// loadNamedCapture loads the capture named in the path; when it cannot,
+// it returns nil and the result of writing the error response
+func loadNamedCapture(c *fiber.Ctx, store storage.Storage, key func(string) string) (*Capture, error) {
+	name := c.Params("name")
+	if !captureName.MatchString(name) {
+		return nil, api.BadRequest(c, "Invalid capture name", nil)
+	}
+	capture, err := LoadCapture(c.UserContext(), store, key(name))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, api.NotFound(c, "Capture not found")
+	}
+	if err != nil {
+		return nil, api.InternalError(c, err.Error())
+	}
+	return capture, nil
+}
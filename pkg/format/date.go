@@ -0,0 +1,113 @@
+package format
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Style is how much of a date to write
+type Style int
+
+const (
+	Short  Style = iota // 10/16/26
+	Medium              // Oct 16, 2026
+	Long                // October 16, 2026
+)
+
+// Date formats the date of t, in t's location, in the locale's pattern
+// for style. Patterns use the CLDR letters: yyyy and yy for the year, d
+// and dd for the day, M, MM, MMM (short name) and MMMM (name) for the
+// month, H, HH, h, hh, mm and ss for the time, a for AM or PM and
+// 'quotes' around literal text.
+func (l *Locale) Date(t time.Time, style Style) string {
+	switch style {
+	case Short:
+		return l.Pattern(t, l.ShortDate)
+	case Long:
+		return l.Pattern(t, l.LongDate)
+	default:
+		return l.Pattern(t, l.MediumDate)
+	}
+}
+
+// TimeOfDay formats the time of t
+func (l *Locale) TimeOfDay(t time.Time) string {
+	return l.Pattern(t, l.Time)
+}
+
+// DateTime formats the date of t in style, then its time
+func (l *Locale) DateTime(t time.Time, style Style) string {
+	return l.Date(t, style) + " " + l.TimeOfDay(t)
+}
+
+// Pattern formats t with a date pattern, see Date
+func (l *Locale) Pattern(t time.Time, pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+
+		if c == '\'' {
+			end := strings.IndexByte(pattern[i+1:], '\'')
+			if end < 0 {
+				b.WriteString(pattern[i+1:])
+				break
+			}
+			b.WriteString(pattern[i+1 : i+1+end])
+			i += end + 2
+			continue
+		}
+
+		n := 1
+		for i+n < len(pattern) && pattern[i+n] == c {
+			n++
+		}
+		switch c {
+		case 'y':
+			year := t.Year() + l.YearOffset
+			if n == 2 {
+				b.WriteString(pad(year%100, 2))
+			} else {
+				b.WriteString(strconv.Itoa(year))
+			}
+		case 'M':
+			switch {
+			case n >= 4:
+				b.WriteString(l.Months[t.Month()-1])
+			case n == 3:
+				b.WriteString(l.ShortMonths[t.Month()-1])
+			default:
+				b.WriteString(pad(int(t.Month()), n))
+			}
+		case 'd':
+			b.WriteString(pad(t.Day(), n))
+		case 'H':
+			b.WriteString(pad(t.Hour(), n))
+		case 'h':
+			hour := t.Hour() % 12
+			if hour == 0 {
+				hour = 12
+			}
+			b.WriteString(pad(hour, n))
+		case 'm':
+			b.WriteString(pad(t.Minute(), 2))
+		case 's':
+			b.WriteString(pad(t.Second(), 2))
+		case 'a':
+			b.WriteString(l.DayPeriods[t.Hour()/12])
+		default:
+			b.WriteString(pattern[i : i+n])
+		}
+		i += n
+	}
+	return b.String()
+}
+
+// pad writes n with at least width digits
+func pad(n, width int) string {
+	s := strconv.Itoa(n)
+	for len(s) < width {
+		s = "0" + s
+	}
+	return s
+}
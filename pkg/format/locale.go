@@ -0,0 +1,196 @@
+// Package format formats numbers, money and dates for a locale, and picks
+// each request's locale, so responses and reports render amounts and dates
+// the same way everywhere.
+package format
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is used when a request names no supported locale
+const DefaultLocale = "en-US"
+
+// Locale is how a language and region write numbers, money and dates
+type Locale struct {
+	Tag     string // BCP 47, e.g. "de-DE"
+	Decimal string
+	Group   string
+
+	SymbolAfter  bool // "1.234,56 €" rather than "€1,234.56"
+	SymbolSpace  bool // A space between symbol and amount
+	PercentSpace bool // "12,5 %"
+
+	// Date and time patterns, see Date
+	ShortDate  string
+	MediumDate string
+	LongDate   string
+	Time       string
+
+	Months      [12]string
+	ShortMonths [12]string
+	DayPeriods  [2]string // AM and PM, for patterns with "a"
+	YearOffset  int       // Added to years, 543 for the Thai Buddhist era
+}
+
+const (
+	nbsp       = "\u00a0"
+	narrowNbsp = "\u202f"
+)
+
+var (
+	englishMonths      = [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+	englishShortMonths = [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+	numberedMonths     = [12]string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"}
+	amPM               = [2]string{"AM", "PM"}
+)
+
+var (
+	locales   = make(map[string]*Locale)
+	languages = make(map[string]string) // Base language to its first registered locale
+	localesMu sync.RWMutex
+)
+
+func init() {
+	for _, locale := range []*Locale{
+		{
+			Tag: "en-US", Decimal: ".", Group: ",",
+			ShortDate: "M/d/yy", MediumDate: "MMM d, yyyy", LongDate: "MMMM d, yyyy", Time: "h:mm a",
+			Months: englishMonths, ShortMonths: englishShortMonths,
+		},
+		{
+			Tag: "en-GB", Decimal: ".", Group: ",",
+			ShortDate: "dd/MM/yyyy", MediumDate: "d MMM yyyy", LongDate: "d MMMM yyyy", Time: "HH:mm",
+			Months: englishMonths, ShortMonths: englishShortMonths,
+		},
+		{
+			Tag: "de-DE", Decimal: ",", Group: ".", SymbolAfter: true, SymbolSpace: true, PercentSpace: true,
+			ShortDate: "dd.MM.yy", MediumDate: "dd.MM.yyyy", LongDate: "d. MMMM yyyy", Time: "HH:mm",
+			Months:      [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+			ShortMonths: [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		},
+		{
+			Tag: "fr-FR", Decimal: ",", Group: narrowNbsp, SymbolAfter: true, SymbolSpace: true, PercentSpace: true,
+			ShortDate: "dd/MM/yyyy", MediumDate: "d MMM yyyy", LongDate: "d MMMM yyyy", Time: "HH:mm",
+			Months:      [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+			ShortMonths: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		},
+		{
+			Tag: "es-ES", Decimal: ",", Group: ".", SymbolAfter: true, SymbolSpace: true, PercentSpace: true,
+			ShortDate: "d/M/yy", MediumDate: "d MMM yyyy", LongDate: "d 'de' MMMM 'de' yyyy", Time: "H:mm",
+			Months:      [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+			ShortMonths: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		},
+		{
+			Tag: "it-IT", Decimal: ",", Group: ".", SymbolAfter: true, SymbolSpace: true,
+			ShortDate: "dd/MM/yy", MediumDate: "d MMM yyyy", LongDate: "d MMMM yyyy", Time: "HH:mm",
+			Months:      [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+			ShortMonths: [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		},
+		{
+			Tag: "pt-BR", Decimal: ",", Group: ".", SymbolSpace: true,
+			ShortDate: "dd/MM/yyyy", MediumDate: "d 'de' MMM 'de' yyyy", LongDate: "d 'de' MMMM 'de' yyyy", Time: "HH:mm",
+			Months:      [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+			ShortMonths: [12]string{"jan.", "fev.", "mar.", "abr.", "mai.", "jun.", "jul.", "ago.", "set.", "out.", "nov.", "dez."},
+		},
+		{
+			Tag: "nl-NL", Decimal: ",", Group: ".", SymbolSpace: true,
+			ShortDate: "dd-MM-yyyy", MediumDate: "d MMM yyyy", LongDate: "d MMMM yyyy", Time: "HH:mm",
+			Months:      [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+			ShortMonths: [12]string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		},
+		{
+			Tag: "ja-JP", Decimal: ".", Group: ",",
+			ShortDate: "yyyy/MM/dd", MediumDate: "yyyy/MM/dd", LongDate: "yyyy年M月d日", Time: "H:mm",
+			Months: numberedMonths, ShortMonths: numberedMonths,
+		},
+		{
+			Tag: "zh-CN", Decimal: ".", Group: ",",
+			ShortDate: "yyyy/M/d", MediumDate: "yyyy年M月d日", LongDate: "yyyy年M月d日", Time: "HH:mm",
+			Months: numberedMonths, ShortMonths: numberedMonths,
+		},
+		{
+			Tag: "th-TH", Decimal: ".", Group: ",",
+			ShortDate: "d/M/yy", MediumDate: "d MMM yyyy", LongDate: "d MMMM yyyy", Time: "HH:mm",
+			Months:      [12]string{"มกราคม", "กุมภาพันธ์", "มีนาคม", "เมษายน", "พฤษภาคม", "มิถุนายน", "กรกฎาคม", "สิงหาคม", "กันยายน", "ตุลาคม", "พฤศจิกายน", "ธันวาคม"},
+			ShortMonths: [12]string{"ม.ค.", "ก.พ.", "มี.ค.", "เม.ย.", "พ.ค.", "มิ.ย.", "ก.ค.", "ส.ค.", "ก.ย.", "ต.ค.", "พ.ย.", "ธ.ค."},
+			YearOffset:  543,
+		},
+	} {
+		Register(locale)
+	}
+}
+
+// Register adds or replaces a locale
+func Register(locale *Locale) {
+	if locale.DayPeriods == ([2]string{}) {
+		locale.DayPeriods = amPM
+	}
+
+	localesMu.Lock()
+	defer localesMu.Unlock()
+	locales[strings.ToLower(locale.Tag)] = locale
+	base := baseLanguage(locale.Tag)
+	if _, exists := languages[base]; !exists {
+		languages[base] = strings.ToLower(locale.Tag)
+	}
+}
+
+// Get returns the locale of a tag: the registered locale with the tag, or
+// else the first one of its language, e.g. de-DE for "de-AT", or else
+// the default locale
+func Get(tag string) *Locale {
+	localesMu.RLock()
+	defer localesMu.RUnlock()
+
+	if locale, exists := locales[strings.ToLower(tag)]; exists {
+		return locale
+	}
+	if key, exists := languages[baseLanguage(tag)]; exists {
+		return locales[key]
+	}
+	return locales[strings.ToLower(DefaultLocale)]
+}
+
+// Locales returns the tags of the registered locales, sorted
+func Locales() []string {
+	localesMu.RLock()
+	defer localesMu.RUnlock()
+
+	tags := make([]string, 0, len(locales))
+	for _, locale := range locales {
+		tags = append(tags, locale.Tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Negotiate returns the locale among supported that best matches an
+// Accept-Language header, or fallback when none does
+func Negotiate(acceptLanguage string, supported []string, fallback string) *Locale {
+	if acceptLanguage == "" || len(supported) == 0 {
+		return Get(fallback)
+	}
+	wanted, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(wanted) == 0 {
+		return Get(fallback)
+	}
+
+	tags := make([]language.Tag, len(supported))
+	for i, tag := range supported {
+		tags[i] = language.Make(tag)
+	}
+	_, index, confidence := language.NewMatcher(tags).Match(wanted...)
+	if confidence == language.No {
+		return Get(fallback)
+	}
+	return Get(supported[index])
+}
+
+func baseLanguage(tag string) string {
+	base, _, _ := strings.Cut(strings.ReplaceAll(strings.ToLower(tag), "_", "-"), "-")
+	return base
+}
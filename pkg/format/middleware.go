@@ -0,0 +1,71 @@
+package format
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Config configures locale negotiation
+type Config struct {
+	Default   string   // Locale of requests that name no supported one
+	Supported []string // Locales responses may use; every registered one when empty
+}
+
+// DefaultConfig returns the default locale configuration
+func DefaultConfig() Config {
+	return Config{Default: DefaultLocale}
+}
+
+// LoadConfig loads locale configuration from environment
+func LoadConfig() Config {
+	config := DefaultConfig()
+
+	if locale := os.Getenv("DEFAULT_LOCALE"); locale != "" {
+		config.Default = locale
+	}
+	for _, locale := range strings.Split(os.Getenv("LOCALES"), ",") {
+		if locale = strings.TrimSpace(locale); locale != "" {
+			config.Supported = append(config.Supported, locale)
+		}
+	}
+
+	return config
+}
+
+// Middleware picks each request's locale from its ?locale= parameter, or
+// else its Accept-Language header, for handlers to read with FromContext,
+// and names it in the Content-Language header
+func Middleware(config Config) fiber.Handler {
+	if config.Default == "" {
+		config.Default = DefaultLocale
+	}
+
+	return func(c *fiber.Ctx) error {
+		supported := config.Supported
+		if len(supported) == 0 {
+			supported = Locales()
+		}
+
+		var locale *Locale
+		if requested := c.Query("locale"); requested != "" {
+			locale = Negotiate(requested, supported, config.Default)
+		} else {
+			locale = Negotiate(c.Get(fiber.HeaderAcceptLanguage), supported, config.Default)
+		}
+
+		c.Locals("locale", locale)
+		c.Set(fiber.HeaderContentLanguage, locale.Tag)
+		return c.Next()
+	}
+}
+
+// FromContext returns the locale picked for a request, or the default
+// locale outside Middleware
+func FromContext(c *fiber.Ctx) *Locale {
+	if locale, ok := c.Locals("locale").(*Locale); ok {
+		return locale
+	}
+	return Get(DefaultLocale)
+}
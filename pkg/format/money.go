@@ -0,0 +1,364 @@
+package format
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/currency"
+)
+
+var (
+	ErrCurrencyMismatch = errors.New("currencies differ")
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrPrecision        = errors.New("more decimals than the currency has")
+	ErrOverflow         = errors.New("amount out of range")
+)
+
+// symbols are the signs written for common currencies; others are written
+// as their code
+var symbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "CNY": "¥", "THB": "฿",
+	"BRL": "R$", "INR": "₹", "KRW": "₩", "CHF": "CHF", "CAD": "CA$", "AUD": "A$",
+	"SGD": "S$", "HKD": "HK$", "MXN": "MX$", "VND": "₫", "PHP": "₱", "ILS": "₪",
+	"TRY": "₺", "RUB": "₽", "PLN": "zł", "NGN": "₦",
+}
+
+// Money is an amount of a currency in its minor unit, e.g. cents, so sums
+// are exact. It stores in one column as "12.34 USD", or in two with
+// `gorm:"embedded;embeddedPrefix:price_"`.
+type Money struct {
+	Amount   int64  `json:"amount"`   // Minor units
+	Currency string `json:"currency"` // ISO 4217 code
+}
+
+// NewMoney creates an amount of minor units, e.g. NewMoney(1250, "USD")
+// for $12.50
+func NewMoney(minor int64, code string) Money {
+	return Money{Amount: minor, Currency: strings.ToUpper(code)}
+}
+
+// Zero is no money of a currency
+func Zero(code string) Money {
+	return NewMoney(0, code)
+}
+
+// ParseMoney reads a decimal amount of a currency, e.g. "12.50", exactly
+func ParseMoney(amount, code string) (Money, error) {
+	scale, err := Scale(code)
+	if err != nil {
+		return Money{}, err
+	}
+
+	amount = strings.TrimSpace(amount)
+	negative := strings.HasPrefix(amount, "-")
+	amount = strings.TrimLeft(amount, "+-")
+	whole, fraction, _ := strings.Cut(amount, ".")
+	if whole == "" && fraction == "" || strings.Trim(whole+fraction, "0123456789") != "" {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	if trimmed := strings.TrimRight(fraction, "0"); len(trimmed) > scale {
+		return Money{}, fmt.Errorf("%s %s: %w", amount, code, ErrPrecision)
+	}
+	fraction = (fraction + strings.Repeat("0", scale))[:scale]
+
+	minor, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if whole+fraction == "" {
+		minor, err = 0, nil
+	}
+	if err != nil {
+		return Money{}, fmt.Errorf("%s %s: %w", amount, code, ErrOverflow)
+	}
+	if negative {
+		minor = -minor
+	}
+	return NewMoney(minor, code), nil
+}
+
+// MoneyFromFloat converts a float amount, e.g. from a legacy column,
+// rounding half away from zero to the currency's minor unit
+func MoneyFromFloat(amount float64, code string) (Money, error) {
+	scale, err := Scale(code)
+	if err != nil {
+		return Money{}, err
+	}
+	minor := math.Round(amount * math.Pow10(scale))
+	if math.IsNaN(minor) || minor > math.MaxInt64 || minor < math.MinInt64 {
+		return Money{}, fmt.Errorf("%v %s: %w", amount, code, ErrOverflow)
+	}
+	return NewMoney(int64(minor), code), nil
+}
+
+// Scale returns the number of decimals of a currency, e.g. 2 for USD and
+// 0 for JPY
+func Scale(code string) (int, error) {
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		return 0, fmt.Errorf("%q: %w", code, ErrUnknownCurrency)
+	}
+	scale, _ := currency.Standard.Rounding(unit)
+	return scale, nil
+}
+
+// Symbol returns a currency's sign, or its code when it has no common one
+func Symbol(code string) string {
+	code = strings.ToUpper(code)
+	if symbol, exists := symbols[code]; exists {
+		return symbol
+	}
+	return code
+}
+
+// Add returns m plus o, which must be of the same currency
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.same(o); err != nil {
+		return Money{}, err
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Sub returns m minus o, which must be of the same currency
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(o.Neg())
+}
+
+// Mul returns m times n
+func (m Money) Mul(n int64) (Money, error) {
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(n))
+	if !product.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: product.Int64(), Currency: m.Currency}, nil
+}
+
+// Ratio returns m times num/den, rounded half away from zero, e.g. a tax
+// rate of 7.5% as m.Ratio(75, 1000)
+func (m Money) Ratio(num, den int64) (Money, error) {
+	if den == 0 {
+		return Money{}, errors.New("ratio with a zero denominator")
+	}
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(num))
+	quotient := divRound(product, big.NewInt(den))
+	if !quotient.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: quotient.Int64(), Currency: m.Currency}, nil
+}
+
+// Allocate splits m by ratios without losing a minor unit: the remainder
+// of the rounded-down shares goes one unit at a time to the first ones,
+// e.g. $100 by 1:1:1 is $33.34, $33.33 and $33.33
+func (m Money) Allocate(ratios ...int) ([]Money, error) {
+	total := int64(0)
+	for _, ratio := range ratios {
+		if ratio < 0 {
+			return nil, errors.New("negative allocation ratio")
+		}
+		total += int64(ratio)
+	}
+	if total == 0 {
+		return nil, errors.New("allocation ratios sum to zero")
+	}
+
+	shares := make([]Money, len(ratios))
+	remainder := m.Amount
+	for i, ratio := range ratios {
+		share := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(int64(ratio)))
+		share.Quo(share, big.NewInt(total))
+		shares[i] = Money{Amount: share.Int64(), Currency: m.Currency}
+		remainder -= share.Int64()
+	}
+
+	unit := int64(1)
+	if remainder < 0 {
+		unit = -1
+	}
+	for i := 0; remainder != 0; i = (i + 1) % len(shares) {
+		if ratios[i] == 0 {
+			continue
+		}
+		shares[i].Amount += unit
+		remainder -= unit
+	}
+	return shares, nil
+}
+
+// Split divides m into n shares as equal as they can be
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, errors.New("split into fewer than one share")
+	}
+	ratios := make([]int, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Abs returns m without its sign
+func (m Money) Abs() Money {
+	if m.Amount < 0 {
+		return m.Neg()
+	}
+	return m
+}
+
+// Cmp returns -1, 0 or 1 as m is less than, equal to or more than o
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.same(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// IsZero reports whether m is no money
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsNegative reports whether m is below zero
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Decimal returns the amount in major units, e.g. "-12.50"
+func (m Money) Decimal() string {
+	negative, whole, fraction := m.digits()
+	s := whole
+	if fraction != "" {
+		s += "." + fraction
+	}
+	if negative {
+		return "-" + s
+	}
+	return s
+}
+
+// Float returns the amount in major units, for display and charts only
+func (m Money) Float() float64 {
+	f, _ := strconv.ParseFloat(m.Decimal(), 64)
+	return f
+}
+
+// String returns the amount and currency, e.g. "12.50 USD"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// Money formats m in the locale, e.g. "$1,234.50" or "1.234,50 €"
+func (l *Locale) Money(m Money) string {
+	negative, whole, fraction := m.digits()
+	amount := l.join(false, whole, fraction)
+
+	symbol := Symbol(m.Currency)
+	separator := ""
+	if l.SymbolSpace || symbol == m.Currency {
+		separator = nbsp
+	}
+
+	var s string
+	if l.SymbolAfter {
+		s = amount + separator + symbol
+	} else {
+		s = symbol + separator + amount
+	}
+	if negative {
+		return "-" + s
+	}
+	return s
+}
+
+// Value stores m in one column, as "12.50 USD"
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan reads an amount stored by Value
+func (m *Money) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*m = Money{}
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+
+	amount, code, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok {
+		return fmt.Errorf("invalid money %q", s)
+	}
+	parsed, err := ParseMoney(amount, code)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// GormDataType is the column type of Money stored in one column
+func (Money) GormDataType() string {
+	return "string"
+}
+
+func (m Money) same(o Money) error {
+	if m.Currency != o.Currency {
+		return fmt.Errorf("%s and %s: %w", m.Currency, o.Currency, ErrCurrencyMismatch)
+	}
+	return nil
+}
+
+// digits returns the sign, whole and fractional digits of m
+func (m Money) digits() (negative bool, whole, fraction string) {
+	scale, err := Scale(m.Currency)
+	if err != nil {
+		scale = 2
+	}
+	digits := new(big.Int).Abs(big.NewInt(m.Amount)).String()
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	split := len(digits) - scale
+	return m.Amount < 0, digits[:split], digits[split:]
+}
+
+// divRound divides, rounding half away from zero
+func divRound(num, den *big.Int) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(num, den, new(big.Int))
+	twice := new(big.Int).Abs(remainder)
+	twice.Lsh(twice, 1)
+	if twice.Cmp(new(big.Int).Abs(den)) >= 0 {
+		if num.Sign()*den.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return quotient
+}
@@ -0,0 +1,51 @@
+package format
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Number formats v with decimals digits after the decimal separator,
+// grouping thousands, e.g. 1234.5 as "1,234.50" or "1.234,50"
+func (l *Locale) Number(v float64, decimals int) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	digits := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	whole, fraction, _ := strings.Cut(digits, ".")
+	return l.join(v < 0 && strings.Trim(digits, "0.") != "", whole, fraction)
+}
+
+// Integer formats n, grouping thousands
+func (l *Locale) Integer(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	return l.join(n < 0, strings.TrimPrefix(digits, "-"), "")
+}
+
+// Percent formats a ratio as a percentage, e.g. 0.125 as "12.5%"
+func (l *Locale) Percent(ratio float64, decimals int) string {
+	if l.PercentSpace {
+		return l.Number(ratio*100, decimals) + nbsp + "%"
+	}
+	return l.Number(ratio*100, decimals) + "%"
+}
+
+// join writes a sign, grouped whole digits and a fraction
+func (l *Locale) join(negative bool, whole, fraction string) string {
+	var b strings.Builder
+	if negative {
+		b.WriteByte('-')
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(l.Group)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(l.Decimal)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
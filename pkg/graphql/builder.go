@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"time"
 )
 
 // Builder helps build GraphQL schemas fluently
@@ -158,6 +159,23 @@ func WithDeprecated(reason string) FieldOption {
 	}
 }
 
+// WithCacheControl caches the field's results for maxAge when a
+// ResolverCache is applied, shared or per user by scope. Mutations
+// returning the field's type, or one of types, invalidate them.
+func WithCacheControl(maxAge time.Duration, scope CacheScope, types ...string) FieldOption {
+	return func(f *Field) {
+		f.CacheControl = &CacheHint{MaxAge: maxAge, Scope: scope, Types: types}
+	}
+}
+
+// WithInvalidates lists the types a mutation changes besides the one it
+// returns, whose cached results it invalidates
+func WithInvalidates(types ...string) FieldOption {
+	return func(f *Field) {
+		f.Invalidates = append(f.Invalidates, types...)
+	}
+}
+
 // WithElementType sets the element type for lists/non-null
 func WithElementType(elementType string) FieldOption {
 	return func(f *Field) {
@@ -0,0 +1,288 @@
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/cache"
+)
+
+// CacheScope is who may share a cached resolver result
+type CacheScope string
+
+const (
+	// CachePublic results are shared by every caller
+	CachePublic CacheScope = "PUBLIC"
+	// CachePrivate results are cached per auth scope, e.g. per user
+	CachePrivate CacheScope = "PRIVATE"
+)
+
+// CacheHint is a field's @cacheControl directive: how long its results
+// are cached, for whom, and which types' mutations invalidate them besides
+// the type it returns
+type CacheHint struct {
+	MaxAge time.Duration
+	Scope  CacheScope
+	Types  []string
+}
+
+// CacheControlDirective declares @cacheControl and the scope enum it
+// takes, added to a schema by ResolverCache.Apply
+func CacheControlDirective() (*Directive, *EnumType) {
+	return &Directive{
+		Name:        "cacheControl",
+		Description: "Caches the field's results for maxAge seconds, per user when scope is PRIVATE",
+		Locations:   []string{"FIELD_DEFINITION"},
+		Args: []*Argument{
+			{Name: "maxAge", Type: TypeInt},
+			{Name: "scope", Type: TypeEnum, ElementType: "CacheControlScope"},
+		},
+	}, &EnumType{
+		Name:   "CacheControlScope",
+		Values: []*EnumValue{{Name: string(CachePublic)}, {Name: string(CachePrivate)}},
+	}
+}
+
+// ScopeFunc returns the auth scope of a request private results are
+// cached under, or "" for anonymous callers
+type ScopeFunc func(ctx context.Context) string
+
+// userScope is the user set by auth.AuthMiddleware, which the handler's
+// context carries as a Fiber local
+func userScope(ctx context.Context) string {
+	if userID, ok := ctx.Value("user_id").(uint); ok && userID != 0 {
+		return "user:" + strconv.FormatUint(uint64(userID), 10)
+	}
+	return ""
+}
+
+// InvalidateHook is called after cached results are invalidated for
+// types, with the error of the invalidation if it failed
+type InvalidateHook func(ctx context.Context, types []string, err error)
+
+// ResolverCacheConfig configures a ResolverCache
+type ResolverCacheConfig struct {
+	// KeyPrefix namespaces cache keys and tags
+	KeyPrefix string
+
+	// Scope returns the auth scope of private results; nil uses the
+	// user ID set by auth.AuthMiddleware
+	Scope ScopeFunc
+
+	// StaleTTL serves expired results while one refresh runs, see
+	// cache.LoaderConfig
+	StaleTTL time.Duration
+}
+
+// DefaultResolverCacheConfig returns the default resolver cache
+// configuration
+func DefaultResolverCacheConfig() ResolverCacheConfig {
+	return ResolverCacheConfig{
+		KeyPrefix: "gql:",
+	}
+}
+
+// ResolverCache memoizes resolvers in a cache.Cache by field, arguments
+// and auth scope, following the @cacheControl hints of their fields.
+// Each result is tagged with the types it depends on, and mutations
+// invalidate the results of the type they return.
+type ResolverCache struct {
+	loader *cache.Loader
+	config ResolverCacheConfig
+
+	mu    sync.RWMutex
+	hooks []InvalidateHook
+}
+
+// NewResolverCache creates a resolver cache on c
+func NewResolverCache(c cache.Cache, config ResolverCacheConfig) *ResolverCache {
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = DefaultResolverCacheConfig().KeyPrefix
+	}
+	if config.Scope == nil {
+		config.Scope = userScope
+	}
+	loaderConfig := cache.DefaultLoaderConfig()
+	loaderConfig.StaleTTL = config.StaleTTL
+	return &ResolverCache{
+		loader: cache.NewLoader(c, loaderConfig),
+		config: config,
+	}
+}
+
+// Apply decorates the resolvers of an executor's schema: fields of
+// queries and types with a cache hint are memoized, and every mutation
+// invalidates the types it returns or lists with WithInvalidates. Call it
+// once, after registering resolvers; it also declares @cacheControl in
+// the schema.
+func (rc *ResolverCache) Apply(executor *Executor) {
+	schema := executor.schema
+	rc.declare(schema)
+
+	decorate := func(objType *ObjectType, wrap func(typeName string, field *Field, resolver ResolverFunc) ResolverFunc) {
+		if objType == nil {
+			return
+		}
+		for _, field := range objType.Fields {
+			key := fmt.Sprintf("%s.%s", objType.Name, field.Name)
+			resolver := executor.resolvers[key]
+			if resolver == nil {
+				resolver = field.Resolver
+			}
+			if resolver == nil {
+				continue
+			}
+			executor.resolvers[key] = wrap(objType.Name, field, resolver)
+		}
+	}
+
+	decorate(schema.QueryType, rc.Memoize)
+	for _, objType := range schema.Types {
+		decorate(objType, rc.Memoize)
+	}
+	decorate(schema.MutationType, func(_ string, field *Field, resolver ResolverFunc) ResolverFunc {
+		return rc.Invalidating(field, resolver)
+	})
+}
+
+// declare adds @cacheControl and its scope enum to a schema once
+func (rc *ResolverCache) declare(schema *Schema) {
+	directive, scope := CacheControlDirective()
+	for _, d := range schema.Directives {
+		if d.Name == directive.Name {
+			return
+		}
+	}
+	schema.Directives = append(schema.Directives, directive)
+	if _, exists := schema.Enums[scope.Name]; !exists {
+		schema.AddEnum(scope)
+	}
+}
+
+// Memoize decorates the resolver of typeName.field with the field's cache
+// hint. Concurrent misses for a key share one call, and errors are not
+// cached. A field without a hint, or with a MaxAge of 0, is not cached.
+//
+// Results cached in a serializing cache such as Redis come back decoded,
+// e.g. structs as maps, which encode to the same response.
+func (rc *ResolverCache) Memoize(typeName string, field *Field, resolver ResolverFunc) ResolverFunc {
+	hint := field.CacheControl
+	if hint == nil || hint.MaxAge <= 0 {
+		return resolver
+	}
+
+	var tags []string
+	for _, t := range cacheTypes(field, hint.Types) {
+		tags = append(tags, rc.typeTag(t))
+	}
+	name := typeName + "." + field.Name
+
+	return func(ctx context.Context, parent interface{}, args map[string]interface{}) (interface{}, error) {
+		scope := ""
+		if hint.Scope == CachePrivate {
+			scope = rc.config.Scope(ctx)
+		}
+		key, err := rc.key(name, parent, args, scope)
+		if err != nil {
+			// Arguments the cache cannot key, resolved uncached
+			return resolver(ctx, parent, args)
+		}
+		return rc.loader.GetOrLoad(ctx, key, hint.MaxAge, func(ctx context.Context) (interface{}, error) {
+			return resolver(ctx, parent, args)
+		}, cache.WithTags(tags...))
+	}
+}
+
+// Invalidating decorates a mutation resolver to invalidate the cached
+// results of the types the mutation returns or lists with
+// WithInvalidates once it succeeds
+func (rc *ResolverCache) Invalidating(field *Field, resolver ResolverFunc) ResolverFunc {
+	types := cacheTypes(field, field.Invalidates)
+	if len(types) == 0 {
+		return resolver
+	}
+
+	return func(ctx context.Context, parent interface{}, args map[string]interface{}) (interface{}, error) {
+		value, err := resolver(ctx, parent, args)
+		if err != nil {
+			return nil, err
+		}
+		// The mutation is done; a failed invalidation is for the hooks
+		rc.Invalidate(ctx, types...)
+		return value, nil
+	}
+}
+
+// Invalidate removes the cached results depending on types, e.g. when
+// they change outside a mutation, and calls the invalidation hooks
+func (rc *ResolverCache) Invalidate(ctx context.Context, types ...string) error {
+	var errs []error
+	for _, t := range types {
+		if err := rc.loader.Cache().InvalidateTag(ctx, rc.typeTag(t)); err != nil {
+			errs = append(errs, fmt.Errorf("type %s: %w", t, err))
+		}
+	}
+	err := errors.Join(errs...)
+
+	rc.mu.RLock()
+	hooks := rc.hooks
+	rc.mu.RUnlock()
+	for _, hook := range hooks {
+		hook(ctx, types, err)
+	}
+	return err
+}
+
+// OnInvalidate registers a hook called after every invalidation, e.g. to
+// purge a response cache or notify other instances
+func (rc *ResolverCache) OnInvalidate(hook InvalidateHook) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.hooks = append(rc.hooks, hook)
+}
+
+// Stats returns the cache's statistics with loads and stampedes prevented
+func (rc *ResolverCache) Stats(ctx context.Context) (*cache.Stats, error) {
+	return rc.loader.Stats(ctx)
+}
+
+// key is the cache key of a field's result for a parent, arguments and
+// auth scope
+func (rc *ResolverCache) key(name string, parent interface{}, args map[string]interface{}, scope string) (string, error) {
+	// Maps encode with sorted keys, so equal arguments hash the same
+	material, err := json.Marshal([]interface{}{parent, args})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(material)
+	key := rc.config.KeyPrefix + name + ":" + hex.EncodeToString(sum[:16])
+	if scope != "" {
+		key += ":" + scope
+	}
+	return key, nil
+}
+
+func (rc *ResolverCache) typeTag(typeName string) string {
+	return rc.config.KeyPrefix + "type:" + typeName
+}
+
+// cacheTypes are the type a field returns and extra, without duplicates
+func cacheTypes(field *Field, extra []string) []string {
+	var types []string
+	seen := make(map[string]bool)
+	for _, t := range append([]string{field.ElementType}, extra...) {
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		types = append(types, t)
+	}
+	return types
+}
@@ -35,6 +35,8 @@ type Field struct {
 	Resolver    ResolverFunc
 	Deprecated  bool
 	DeprecationReason string
+	CacheControl *CacheHint // Memoized by a ResolverCache
+	Invalidates  []string   // Mutations: types whose cached results they change, besides ElementType
 }
 
 // Argument represents a field argument
@@ -413,6 +415,14 @@ func (s *Schema) fieldToSDL(f *Field, indent string) string {
 		sb.WriteString(fmt.Sprintf(" @deprecated(reason: \"%s\")", f.DeprecationReason))
 	}
 
+	if hint := f.CacheControl; hint != nil {
+		sb.WriteString(fmt.Sprintf(" @cacheControl(maxAge: %d", int(hint.MaxAge.Seconds())))
+		if hint.Scope != "" {
+			sb.WriteString(fmt.Sprintf(", scope: %s", hint.Scope))
+		}
+		sb.WriteString(")")
+	}
+
 	sb.WriteString("\n")
 
 	return sb.String()
@@ -0,0 +1,259 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// cassetteVersion is the version of the cassette file format
+const cassetteVersion = 1
+
+// Cassette is a recorded series of HTTP interactions
+type Cassette struct {
+	Version      int            `json:"version"`
+	RecordedAt   time.Time      `json:"recorded_at"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request and its response
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a sanitized request
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    Body        `json:"body,omitempty"`
+}
+
+// RecordedResponse is a sanitized response
+type RecordedResponse struct {
+	Status   int           `json:"status"`
+	Headers  http.Header   `json:"headers,omitempty"`
+	Body     Body          `json:"body,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Body is a recorded body. Text is stored as is and binary as base64, so
+// cassettes stay readable in review.
+type Body []byte
+
+// MarshalJSON implements json.Marshaler
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = Body(text)
+		return nil
+	}
+
+	var binary struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &binary); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(binary.Base64)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	if cassette.Version != cassetteVersion {
+		return nil, fmt.Errorf("unsupported cassette version %d in %s", cassette.Version, path)
+	}
+	return &cassette, nil
+}
+
+// Save writes the cassette file, creating its directory
+func (c *Cassette) Save(path string) error {
+	c.Version = cassetteVersion
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false) // Keep URLs and bodies readable
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// Redacted replaces sanitized values in cassettes
+const Redacted = "[REDACTED]"
+
+// Sanitizer scrubs credentials from interactions before they are written,
+// and from live requests before they are matched against a cassette
+type Sanitizer struct {
+	Headers     []string // Header names, case-insensitive
+	QueryParams []string // Query parameter names
+	BodyFields  []string // JSON object keys, at any depth
+	Values      []string // Literal secrets, replaced wherever they appear
+}
+
+// DefaultSanitizer scrubs common credential headers, parameters and fields
+func DefaultSanitizer() Sanitizer {
+	return Sanitizer{
+		Headers: []string{
+			"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie",
+			"X-Api-Key", "Api-Key", "X-Goog-Api-Key", "OpenAI-Organization", "X-Neonex-Signature",
+		},
+		QueryParams: []string{"key", "api_key", "apikey", "token", "access_token", "signature"},
+		BodyFields: []string{
+			"api_key", "apiKey", "password", "secret", "client_secret", "token",
+			"access_token", "refresh_token", "private_key", "card_number", "cvc",
+		},
+	}
+}
+
+// request returns the sanitized form of a request
+func (s Sanitizer) request(method string, u *url.URL, headers http.Header, body []byte) RecordedRequest {
+	return RecordedRequest{
+		Method:  method,
+		URL:     s.url(u),
+		Headers: s.headers(headers),
+		Body:    s.body(body),
+	}
+}
+
+// response returns the sanitized form of a response. Length and date
+// headers are dropped, as replays recompute them.
+func (s Sanitizer) response(status int, headers http.Header, body []byte, duration time.Duration) RecordedResponse {
+	sanitized := s.headers(headers)
+	sanitized.Del("Content-Length")
+	sanitized.Del("Date")
+	return RecordedResponse{
+		Status:   status,
+		Headers:  sanitized,
+		Body:     s.body(body),
+		Duration: duration,
+	}
+}
+
+func (s Sanitizer) url(u *url.URL) string {
+	sanitized := *u
+	sanitized.User = nil
+	query := sanitized.Query()
+	for _, param := range s.QueryParams {
+		if query.Has(param) {
+			query.Set(param, Redacted)
+		}
+	}
+	// Encode sorts parameters, so equal queries compare equal
+	sanitized.RawQuery = query.Encode()
+	return s.scrub(sanitized.String())
+}
+
+func (s Sanitizer) headers(headers http.Header) http.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+	sanitized := headers.Clone()
+	for _, name := range s.Headers {
+		if sanitized.Get(name) != "" {
+			sanitized.Set(name, Redacted)
+		}
+	}
+	for name, values := range sanitized {
+		for i, value := range values {
+			values[i] = s.scrub(value)
+		}
+		sanitized[name] = values
+	}
+	return sanitized
+}
+
+func (s Sanitizer) body(body []byte) Body {
+	if len(body) == 0 {
+		return nil
+	}
+
+	if len(s.BodyFields) > 0 {
+		// UseNumber keeps large integers exact
+		var document interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if decoder.Decode(&document) == nil && !decoder.More() {
+			if redacted, err := json.Marshal(s.redactFields(document)); err == nil {
+				body = redacted
+			}
+		}
+	}
+	if !utf8.Valid(body) {
+		return body
+	}
+	return Body(s.scrub(string(body)))
+}
+
+// redactFields replaces the values of sensitive JSON keys
+func (s Sanitizer) redactFields(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if s.sensitiveField(key) {
+				v[key] = Redacted
+			} else {
+				v[key] = s.redactFields(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = s.redactFields(item)
+		}
+	}
+	return value
+}
+
+func (s Sanitizer) sensitiveField(key string) bool {
+	for _, field := range s.BodyFields {
+		if strings.EqualFold(field, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// scrub replaces literal secrets
+func (s Sanitizer) scrub(text string) string {
+	for _, value := range s.Values {
+		if value != "" {
+			text = strings.ReplaceAll(text, value, Redacted)
+		}
+	}
+	return text
+}
@@ -0,0 +1,57 @@
+package httpclient
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// shared is the transport behind every client created with New. Swapping
+// its delegate reroutes all of them, including clients created earlier.
+var shared = &sharedTransport{}
+
+type sharedTransport struct {
+	delegate http.RoundTripper
+	mu       sync.RWMutex
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *sharedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.current().RoundTrip(req)
+}
+
+func (t *sharedTransport) current() http.RoundTripper {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.delegate == nil {
+		return http.DefaultTransport
+	}
+	return t.delegate
+}
+
+// New creates a client for external APIs on the shared transport
+func New(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: shared}
+}
+
+// Transport returns the shared transport, for libraries that take a
+// RoundTripper rather than a client
+func Transport() http.RoundTripper {
+	return shared
+}
+
+// SetTransport routes the shared transport through rt, nil restoring
+// http.DefaultTransport. It returns a function restoring the previous
+// transport.
+func SetTransport(rt http.RoundTripper) (restore func()) {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+
+	previous := shared.delegate
+	shared.delegate = rt
+	return func() {
+		shared.mu.Lock()
+		defer shared.mu.Unlock()
+		shared.delegate = previous
+	}
+}
@@ -0,0 +1,109 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ErrPrivateAddress is returned for a host resolving to an address outside
+// the public internet
+var ErrPrivateAddress = errors.New("address is not public")
+
+// PublicIP reports whether ip is routable on the public internet, rejecting
+// loopback, private, link-local and unspecified addresses
+func PublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 0 {
+		return false // 0.0.0.0/8 reaches the local host
+	}
+	return ip != nil && !ip.IsLoopback() && !ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsUnspecified()
+}
+
+// CheckPublicHost resolves host and fails with ErrPrivateAddress unless
+// every address it resolves to is public
+func CheckPublicHost(ctx context.Context, host string) error {
+	_, err := resolvePublic(ctx, host)
+	return err
+}
+
+func resolvePublic(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if !PublicIP(ip) {
+			return nil, fmt.Errorf("%s: %w", host, ErrPrivateAddress)
+		}
+		return []net.IP{ip}, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if !PublicIP(addr.IP) {
+			return nil, fmt.Errorf("%s resolves to %s: %w", host, addr.IP, ErrPrivateAddress)
+		}
+		ips = append(ips, addr.IP)
+	}
+	return ips, nil
+}
+
+// NewPublic creates a client for URLs supplied by users, such as webhooks.
+// It only connects to public addresses, checking the addresses it dials
+// rather than the URL so redirects and DNS rebinding cannot reach internal
+// services. Cassettes set with SetTransport still apply to it.
+func NewPublic(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // The proxy would be dialed instead of the host
+	transport.DialContext = dialPublic
+	return &http.Client{Timeout: timeout, Transport: &publicTransport{public: transport}}
+}
+
+// publicTransport sends requests through the shared transport's delegate
+// when one is set, and through the public-only transport otherwise
+type publicTransport struct {
+	public http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *publicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	shared.mu.RLock()
+	delegate := shared.delegate
+	shared.mu.RUnlock()
+	if delegate != nil {
+		return delegate.RoundTrip(req)
+	}
+	return t.public.RoundTrip(req)
+}
+
+var publicDialer = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+// dialPublic resolves the host once and dials the checked addresses, so a
+// second lookup cannot return a different one
+func dialPublic(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := resolvePublic(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ip := range ips {
+		var conn net.Conn
+		conn, err = publicDialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("%s: no addresses", host)
+	}
+	return nil, err
+}
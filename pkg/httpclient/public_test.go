@@ -0,0 +1,63 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPublicIP(t *testing.T) {
+	for ip, want := range map[string]bool{
+		"203.0.113.7":      true,
+		"2001:db8::1":      true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"0.0.0.0":          false,
+		"0.1.2.3":          false,
+		"::1":              false,
+		"::ffff:127.0.0.1": false,
+		"fd00::1":          false,
+		"fe80::1":          false,
+	} {
+		if got := PublicIP(net.ParseIP(ip)); got != want {
+			t.Errorf("PublicIP(%s) = %v, want %v", ip, got, want)
+		}
+	}
+}
+
+func TestCheckPublicHost(t *testing.T) {
+	ctx := context.Background()
+	for _, host := range []string{"127.0.0.1", "169.254.169.254", "localhost"} {
+		if err := CheckPublicHost(ctx, host); !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("CheckPublicHost(%s) = %v, want ErrPrivateAddress", host, err)
+		}
+	}
+	if err := CheckPublicHost(ctx, "203.0.113.7"); err != nil {
+		t.Errorf("CheckPublicHost(203.0.113.7) = %v", err)
+	}
+}
+
+func TestNewPublicRefusesLoopback(t *testing.T) {
+	hit := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hit = true }))
+	defer server.Close()
+
+	// The URL passed validation elsewhere; the dialer still refuses it
+	resp, err := NewPublic(time.Second).Post(server.URL+"/hooks", "application/json", nil)
+	if err == nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("posting to %s = %v, want ErrPrivateAddress", server.URL, err)
+	}
+	if hit {
+		t.Error("the loopback server received the request")
+	}
+}
@@ -0,0 +1,283 @@
+package httpclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RecorderMode selects whether a Recorder records, replays or both
+type RecorderMode string
+
+const (
+	// ModeRecord sends every request and records it, replacing the cassette
+	ModeRecord RecorderMode = "record"
+	// ModeReplay answers from the cassette only; unmatched requests fail
+	ModeReplay RecorderMode = "replay"
+	// ModeReplayOrRecord replays matches and records the rest, so new
+	// requests can be added to an existing cassette
+	ModeReplayOrRecord RecorderMode = "replay_or_record"
+	// ModePassthrough sends every request without recording
+	ModePassthrough RecorderMode = "passthrough"
+)
+
+// MatchMode selects how requests are matched against a cassette
+type MatchMode string
+
+const (
+	// MatchStrict requires the method, URL, query and body to be equal,
+	// and replays interactions once each, in recorded order
+	MatchStrict MatchMode = "strict"
+	// MatchLoose matches on method, host and path, in any order. The last
+	// matching interaction is reused once all matches have been replayed.
+	MatchLoose MatchMode = "loose"
+)
+
+// ErrNoInteraction is returned for a request a cassette has no match for
+var ErrNoInteraction = errors.New("no matching interaction in cassette")
+
+// RecorderOptions configures a Recorder
+type RecorderOptions struct {
+	Mode      RecorderMode
+	Match     MatchMode
+	Sanitizer Sanitizer
+
+	// Transport sends live requests; the shared transport's current
+	// delegate by default
+	Transport http.RoundTripper
+}
+
+// DefaultRecorderOptions returns the default recorder options: replay or
+// record, strict matching, default sanitizer
+func DefaultRecorderOptions() RecorderOptions {
+	return RecorderOptions{
+		Mode:      ModeReplayOrRecord,
+		Match:     MatchStrict,
+		Sanitizer: DefaultSanitizer(),
+	}
+}
+
+// Recorder is a VCR-style http.RoundTripper recording interactions with
+// external APIs into a cassette file and replaying them
+type Recorder struct {
+	path     string
+	options  RecorderOptions
+	cassette *Cassette
+	used     []bool
+	next     int // Next interaction for strict matching
+	changed  bool
+	restore  func()
+	mu       sync.Mutex
+}
+
+// NewRecorder creates a recorder for the cassette at path. Replay mode
+// requires the cassette to exist.
+func NewRecorder(path string, options RecorderOptions) (*Recorder, error) {
+	defaults := DefaultRecorderOptions()
+	if options.Mode == "" {
+		options.Mode = defaults.Mode
+	}
+	if options.Match == "" {
+		options.Match = defaults.Match
+	}
+	if options.Transport == nil {
+		options.Transport = shared.current()
+	}
+
+	r := &Recorder{path: path, options: options, cassette: &Cassette{}}
+	if options.Mode == ModeReplay || options.Mode == ModeReplayOrRecord {
+		cassette, err := LoadCassette(path)
+		switch {
+		case err == nil:
+			r.cassette = cassette
+		case errors.Is(err, os.ErrNotExist) && options.Mode == ModeReplayOrRecord:
+		default:
+			return nil, fmt.Errorf("failed to load cassette: %w", err)
+		}
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// Start creates a recorder and installs it on the shared transport, so
+// every client from New goes through it until Stop
+func Start(path string, options RecorderOptions) (*Recorder, error) {
+	r, err := NewRecorder(path, options)
+	if err != nil {
+		return nil, err
+	}
+	r.restore = SetTransport(r)
+	return r, nil
+}
+
+// Stop uninstalls a started recorder and saves the cassette if anything
+// was recorded
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.restore != nil {
+		r.restore()
+		r.restore = nil
+	}
+	if !r.changed {
+		return nil
+	}
+	r.cassette.RecordedAt = time.Now().UTC()
+	if err := r.cassette.Save(r.path); err != nil {
+		return fmt.Errorf("failed to save cassette: %w", err)
+	}
+	r.changed = false
+	return nil
+}
+
+// Cassette returns the recorder's cassette
+func (r *Recorder) Cassette() *Cassette {
+	return r.cassette
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.options.Mode == ModePassthrough {
+		return r.options.Transport.RoundTrip(req)
+	}
+
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded := r.options.Sanitizer.request(req.Method, req.URL, req.Header, body)
+
+	if r.options.Mode != ModeRecord {
+		if interaction := r.match(recorded); interaction != nil {
+			return replay(req, interaction), nil
+		}
+		if r.options.Mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, recorded.Method, recorded.URL)
+		}
+	}
+
+	return r.record(req, recorded)
+}
+
+// match finds the interaction answering a request and marks it used
+func (r *Recorder) match(req RecordedRequest) *Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	interactions := r.cassette.Interactions
+	if r.options.Match == MatchStrict {
+		for r.next < len(interactions) && r.used[r.next] {
+			r.next++
+		}
+		if r.next < len(interactions) && strictMatch(interactions[r.next].Request, req) {
+			r.used[r.next] = true
+			return interactions[r.next]
+		}
+		return nil
+	}
+
+	last := -1
+	for i, interaction := range interactions {
+		if !looseMatch(interaction.Request, req) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return interaction
+		}
+		last = i
+	}
+	if last >= 0 {
+		return interactions[last]
+	}
+	return nil
+}
+
+// record sends a request and appends the interaction to the cassette
+func (r *Recorder) record(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	start := time.Now()
+	resp, err := r.options.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := &Interaction{
+		Request:  recorded,
+		Response: r.options.Sanitizer.response(resp.StatusCode, resp.Header, body, time.Since(start)),
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.used = append(r.used, true)
+	r.changed = true
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// replay builds the response of an interaction
+func replay(req *http.Request, interaction *Interaction) *http.Response {
+	response := interaction.Response
+	header := response.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(response.Status) + " " + http.StatusText(response.Status),
+		StatusCode:    response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(response.Body)),
+		ContentLength: int64(len(response.Body)),
+		Request:       req,
+	}
+}
+
+// readBody reads a request body and restores it for sending
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func strictMatch(recorded, req RecordedRequest) bool {
+	return recorded.Method == req.Method &&
+		recorded.URL == req.URL &&
+		bytes.Equal(recorded.Body, req.Body)
+}
+
+func looseMatch(recorded, req RecordedRequest) bool {
+	if recorded.Method != req.Method {
+		return false
+	}
+	a, errA := url.Parse(recorded.URL)
+	b, errB := url.Parse(req.URL)
+	if errA != nil || errB != nil {
+		return recorded.URL == req.URL
+	}
+	return a.Host == b.Host && a.Path == b.Path
+}
@@ -0,0 +1,260 @@
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Strategy is how IDs are generated
+type Strategy string
+
+const (
+	StrategyUUIDv7    Strategy = "uuidv7"    // 36-character UUIDs ordered by creation time
+	StrategyULID      Strategy = "ulid"      // 26-character Crockford base32, ordered by creation time
+	StrategySnowflake Strategy = "snowflake" // 64-bit integers: time, node and sequence
+)
+
+// Generator mints unique IDs, sortable by when they were minted
+type Generator interface {
+	NewID() string
+	Strategy() Strategy
+}
+
+// TimedGenerator mints IDs as if at another time, e.g. a row's creation
+// time when backfilling, so they sort as the rows were created
+type TimedGenerator interface {
+	Generator
+	NewIDAt(t time.Time) string
+}
+
+// Config configures the default generator
+type Config struct {
+	Strategy Strategy
+	Node     int64     // Snowflake node, 0-1023; unique per process
+	Epoch    time.Time // Snowflake epoch
+}
+
+// DefaultConfig returns the default ID configuration
+func DefaultConfig() Config {
+	return Config{
+		Strategy: StrategyUUIDv7,
+		Node:     hostNode(),
+		Epoch:    defaultEpoch,
+	}
+}
+
+// LoadConfig loads ID configuration from environment
+func LoadConfig() Config {
+	config := DefaultConfig()
+
+	if strategy := os.Getenv("ID_STRATEGY"); strategy != "" {
+		config.Strategy = Strategy(strings.ToLower(strategy))
+	}
+	if node, err := strconv.ParseInt(os.Getenv("ID_NODE"), 10, 64); err == nil {
+		config.Node = node
+	}
+	if epoch, err := time.Parse("2006-01-02", os.Getenv("ID_EPOCH")); err == nil {
+		config.Epoch = epoch
+	}
+
+	return config
+}
+
+// New creates a generator for a configuration
+func New(config Config) (Generator, error) {
+	switch config.Strategy {
+	case StrategyUUIDv7, "":
+		return UUIDv7{}, nil
+	case StrategyULID:
+		return NewULID(), nil
+	case StrategySnowflake:
+		return NewSnowflake(config.Node, config.Epoch)
+	default:
+		return nil, fmt.Errorf("ids: unknown strategy %q", config.Strategy)
+	}
+}
+
+// hostNode derives a snowflake node from the hostname, for processes
+// without ID_NODE. Nodes may collide; set ID_NODE when running several.
+func hostNode() int64 {
+	hostname, _ := os.Hostname()
+	h := fnv.New32a()
+	h.Write([]byte(hostname))
+	return int64(h.Sum32() % (maxNode + 1))
+}
+
+// UUIDv7 mints version 7 UUIDs: a millisecond timestamp and random bits
+type UUIDv7 struct{}
+
+// NewID implements Generator
+func (UUIDv7) NewID() string {
+	return UUIDv7{}.NewIDAt(time.Now())
+}
+
+// NewIDAt implements TimedGenerator
+func (UUIDv7) NewIDAt(t time.Time) string {
+	var b [16]byte
+	rand.Read(b[6:])
+	ms := uint64(t.UnixMilli())
+	b[0], b[1], b[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	b[3], b[4], b[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	b[6] = b[6]&0x0f | 0x70 // Version 7
+	b[8] = b[8]&0x3f | 0x80 // RFC 9562 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Strategy implements Generator
+func (UUIDv7) Strategy() Strategy {
+	return StrategyUUIDv7
+}
+
+// crockford is the base32 alphabet of ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID mints ULIDs: 48 bits of milliseconds and 80 random bits. IDs minted
+// in the same millisecond increment the random bits, so they still sort
+// in order.
+type ULID struct {
+	mu       sync.Mutex
+	lastMs   uint64
+	lastRand [10]byte
+}
+
+// NewULID creates a ULID generator
+func NewULID() *ULID {
+	return &ULID{}
+}
+
+// NewID implements Generator
+func (g *ULID) NewID() string {
+	ms := uint64(time.Now().UnixMilli())
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if ms <= g.lastMs {
+		// Same millisecond, or the clock went back: keep counting
+		ms = g.lastMs
+		for i := len(g.lastRand) - 1; i >= 0; i-- {
+			g.lastRand[i]++
+			if g.lastRand[i] != 0 {
+				break
+			}
+		}
+	} else {
+		g.lastMs = ms
+		rand.Read(g.lastRand[:])
+	}
+	return encodeULID(ms, g.lastRand)
+}
+
+// NewIDAt implements TimedGenerator
+func (g *ULID) NewIDAt(t time.Time) string {
+	var random [10]byte
+	rand.Read(random[:])
+	return encodeULID(uint64(t.UnixMilli()), random)
+}
+
+// Strategy implements Generator
+func (g *ULID) Strategy() Strategy {
+	return StrategyULID
+}
+
+// encodeULID encodes 128 bits as 26 base32 characters, 5 bits each from
+// the most significant
+func encodeULID(ms uint64, random [10]byte) string {
+	var b [16]byte
+	b[0], b[1], b[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	b[3], b[4], b[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	copy(b[6:], random[:])
+
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// Snowflake layout
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+	maxNode      = 1<<nodeBits - 1
+	maxSequence  = 1<<sequenceBits - 1
+)
+
+// defaultEpoch is when snowflake time starts, giving them 69 years
+var defaultEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflake mints 64-bit IDs: 41 bits of milliseconds since the epoch,
+// 10 bits of node and a 12-bit sequence, so up to 4096 IDs per node and
+// millisecond. Nodes minting IDs at once must differ.
+type Snowflake struct {
+	node     int64
+	epoch    int64 // Unix milliseconds
+	mu       sync.Mutex
+	lastMs   int64
+	sequence int64
+}
+
+// NewSnowflake creates a snowflake generator for a node
+func NewSnowflake(node int64, epoch time.Time) (*Snowflake, error) {
+	if node < 0 || node > maxNode {
+		return nil, fmt.Errorf("ids: snowflake node must be 0-%d, got %d", maxNode, node)
+	}
+	if epoch.IsZero() {
+		epoch = defaultEpoch
+	}
+	if epoch.After(time.Now()) {
+		return nil, fmt.Errorf("ids: snowflake epoch %s is in the future", epoch.Format(time.RFC3339))
+	}
+	return &Snowflake{node: node, epoch: epoch.UnixMilli()}, nil
+}
+
+// Next mints an ID. When a millisecond's sequence runs out, it waits for
+// the next millisecond; when the clock goes back, it keeps counting from
+// the last one.
+func (g *Snowflake) Next() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := time.Now().UnixMilli() - g.epoch
+	if ms <= g.lastMs {
+		ms = g.lastMs
+		g.sequence = (g.sequence + 1) & maxSequence
+		if g.sequence == 0 {
+			ms++
+			for time.Now().UnixMilli()-g.epoch < ms {
+				time.Sleep(100 * time.Microsecond)
+			}
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastMs = ms
+	return ms<<(nodeBits+sequenceBits) | g.node<<sequenceBits | g.sequence
+}
+
+// NewID implements Generator, in decimal
+func (g *Snowflake) NewID() string {
+	return strconv.FormatInt(g.Next(), 10)
+}
+
+// Strategy implements Generator
+func (g *Snowflake) Strategy() Strategy {
+	return StrategySnowflake
+}
+
+// Time returns when a snowflake ID was minted
+func (g *Snowflake) Time(id int64) time.Time {
+	return time.UnixMilli(id>>(nodeBits+sequenceBits) + g.epoch)
+}
@@ -0,0 +1,194 @@
+package ids
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Conversion moves an existing table from auto-increment IDs to generated
+// ones. Backfill adds the new ID column next to the old key and fills it;
+// the tables referencing it get matching columns with BackfillReferences.
+// Switching the primary and foreign keys over is then a module migration
+// of its own, since it differs per database.
+type Conversion struct {
+	Table      string
+	Key        string // The auto-increment key, "id" when empty
+	Column     string // The new ID column, e.g. "uid"
+	TimeColumn string // Rows' creation time, so IDs sort as rows were created; "created_at" when it exists
+	BatchSize  int    // Rows updated per transaction (default 1000)
+}
+
+// Reference is a column holding a converted table's old keys, e.g.
+// orders.user_id, and the column to hold its new IDs, e.g. orders.user_uid
+type Reference struct {
+	Table     string
+	Column    string
+	NewColumn string
+}
+
+func (c Conversion) withDefaults(db *gorm.DB) Conversion {
+	if c.Key == "" {
+		c.Key = "id"
+	}
+	if c.TimeColumn == "" && db.Migrator().HasColumn(c.Table, "created_at") {
+		c.TimeColumn = "created_at"
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 1000
+	}
+	return c
+}
+
+// Backfill adds the conversion's ID column when missing, gives every row
+// without an ID one, and indexes the column as unique. It can be run again
+// to fill rows created meanwhile, and returns how many rows it filled.
+// Timed generators mint IDs at the rows' creation time.
+func Backfill(ctx context.Context, db *gorm.DB, generator Generator, c Conversion) (int64, error) {
+	if c.Table == "" || c.Column == "" {
+		return 0, fmt.Errorf("ids: conversion needs a table and column")
+	}
+	db = db.WithContext(ctx)
+	c = c.withDefaults(db)
+
+	if err := addColumn(db, c.Table, c.Column, generator.Strategy()); err != nil {
+		return 0, err
+	}
+
+	timed, _ := generator.(TimedGenerator)
+	var filled int64
+	for {
+		n, err := backfillBatch(db, generator, timed, c)
+		filled += n
+		if err != nil {
+			return filled, fmt.Errorf("ids: backfilling %s.%s: %w", c.Table, c.Column, err)
+		}
+		if n < int64(c.BatchSize) {
+			break
+		}
+	}
+
+	index := "idx_" + c.Table + "_" + c.Column
+	if !db.Migrator().HasIndex(c.Table, index) {
+		if err := db.Exec("CREATE UNIQUE INDEX ? ON ? (?)",
+			clause.Column{Name: index}, clause.Table{Name: c.Table}, clause.Column{Name: c.Column}).Error; err != nil {
+			return filled, fmt.Errorf("ids: indexing %s.%s: %w", c.Table, c.Column, err)
+		}
+	}
+	return filled, nil
+}
+
+// backfillBatch fills the IDs of the next batch of rows without one
+func backfillBatch(db *gorm.DB, generator Generator, timed TimedGenerator, c Conversion) (int64, error) {
+	columns := []string{c.Key}
+	if timed != nil && c.TimeColumn != "" {
+		columns = append(columns, c.TimeColumn)
+	}
+
+	var filled int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		rows, err := tx.Table(c.Table).
+			Select(columns).
+			Where("? IS NULL", clause.Column{Name: c.Column}).
+			Order(clause.OrderByColumn{Column: clause.Column{Name: c.Key}}).
+			Limit(c.BatchSize).
+			Rows()
+		if err != nil {
+			return err
+		}
+
+		type pending struct {
+			key     interface{}
+			created sql.NullTime
+		}
+		var batch []pending
+		for rows.Next() {
+			var row pending
+			dest := []interface{}{&row.key}
+			if len(columns) > 1 {
+				dest = append(dest, &row.created)
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, row)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, row := range batch {
+			var value interface{}
+			switch snowflake, ok := generator.(*Snowflake); {
+			case ok:
+				value = snowflake.Next()
+			case timed != nil && row.created.Valid:
+				value = timed.NewIDAt(row.created.Time)
+			default:
+				value = generator.NewID()
+			}
+			if err := tx.Table(c.Table).
+				Where("? = ?", clause.Column{Name: c.Key}, row.key).
+				Update(c.Column, value).Error; err != nil {
+				return err
+			}
+		}
+		filled = int64(len(batch))
+		return nil
+	})
+	return filled, err
+}
+
+// BackfillReferences adds each reference's new column when missing and
+// copies into it the new ID of the row its old column points to. Run it
+// after Backfill; references to missing rows stay NULL.
+func BackfillReferences(ctx context.Context, db *gorm.DB, generator Generator, c Conversion, refs ...Reference) error {
+	db = db.WithContext(ctx)
+	c = c.withDefaults(db)
+
+	for _, ref := range refs {
+		if ref.Table == "" || ref.Column == "" || ref.NewColumn == "" {
+			return fmt.Errorf("ids: reference needs a table, column and new column")
+		}
+		if err := addColumn(db, ref.Table, ref.NewColumn, generator.Strategy()); err != nil {
+			return err
+		}
+
+		err := db.Exec("UPDATE ? SET ? = (SELECT ? FROM ? WHERE ? = ?) WHERE ? IS NULL AND ? IS NOT NULL",
+			clause.Table{Name: ref.Table},
+			clause.Column{Name: ref.NewColumn},
+			clause.Column{Name: c.Column},
+			clause.Table{Name: c.Table},
+			clause.Column{Table: c.Table, Name: c.Key},
+			clause.Column{Table: ref.Table, Name: ref.Column},
+			clause.Column{Name: ref.NewColumn},
+			clause.Column{Name: ref.Column},
+		).Error
+		if err != nil {
+			return fmt.Errorf("ids: backfilling %s.%s: %w", ref.Table, ref.NewColumn, err)
+		}
+	}
+	return nil
+}
+
+// addColumn adds a nullable ID column sized for a strategy, unless the
+// table has it
+func addColumn(db *gorm.DB, table, column string, strategy Strategy) error {
+	if db.Migrator().HasColumn(table, column) {
+		return nil
+	}
+	columnType := "VARCHAR(36)"
+	if strategy == StrategySnowflake {
+		columnType = "BIGINT"
+	}
+	err := db.Exec("ALTER TABLE ? ADD COLUMN ? "+columnType, clause.Table{Name: table}, clause.Column{Name: column}).Error
+	if err != nil {
+		return fmt.Errorf("ids: adding %s.%s: %w", table, column, err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package ids
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Tag marks the fields the plugin assigns IDs to on create: `ids:"auto"`
+// for the default strategy, or a strategy such as `ids:"ulid"`. String
+// fields take any strategy; integer fields need snowflake.
+const Tag = "ids"
+
+// Model is gorm.Model with a generated string ID instead of an
+// auto-increment one
+type Model struct {
+	ID        string         `gorm:"primaryKey;size:36" ids:"auto" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Plugin is a GORM plugin assigning IDs to the tagged fields of created
+// records that are still zero, so IDs set by hand are kept
+type Plugin struct {
+	config     Config
+	generator  Generator
+	mu         sync.Mutex
+	generators map[Strategy]Generator // Of strategies named in tags
+}
+
+// NewPlugin creates a plugin minting IDs with the configured strategy by
+// default
+func NewPlugin(config Config) (*Plugin, error) {
+	generator, err := New(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Plugin{
+		config:     config,
+		generator:  generator,
+		generators: map[Strategy]Generator{generator.Strategy(): generator},
+	}, nil
+}
+
+// Generator returns the default generator
+func (p *Plugin) Generator() Generator {
+	return p.generator
+}
+
+// Name implements gorm.Plugin
+func (p *Plugin) Name() string {
+	return "ids:assign"
+}
+
+// Initialize implements gorm.Plugin
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	return db.Callback().Create().Before("gorm:create").Register("ids:assign", p.assign)
+}
+
+// assign sets the zero tagged fields of the records being created
+func (p *Plugin) assign(db *gorm.DB) {
+	if db.Statement.Schema == nil {
+		return
+	}
+	for _, field := range db.Statement.Schema.Fields {
+		tag := field.Tag.Get(Tag)
+		if tag == "" {
+			continue
+		}
+		generator, err := p.strategy(tag)
+		if err != nil {
+			db.AddError(fmt.Errorf("%s.%s: %w", db.Statement.Schema.Name, field.Name, err))
+			return
+		}
+
+		rv := db.Statement.ReflectValue
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				if err := p.set(db, field, reflect.Indirect(rv.Index(i)), generator); err != nil {
+					db.AddError(err)
+					return
+				}
+			}
+		case reflect.Struct:
+			if err := p.set(db, field, rv, generator); err != nil {
+				db.AddError(err)
+				return
+			}
+		}
+	}
+}
+
+// set mints an ID into a record's field when it is zero
+func (p *Plugin) set(db *gorm.DB, field *schema.Field, record reflect.Value, generator Generator) error {
+	ctx := db.Statement.Context
+	if _, zero := field.ValueOf(ctx, record); !zero {
+		return nil
+	}
+
+	switch field.FieldType.Kind() {
+	case reflect.String:
+		return field.Set(ctx, record, generator.NewID())
+	case reflect.Int64, reflect.Uint64:
+		snowflake, ok := generator.(*Snowflake)
+		if !ok {
+			return fmt.Errorf("%s.%s: integer IDs need the snowflake strategy", db.Statement.Schema.Name, field.Name)
+		}
+		return field.Set(ctx, record, snowflake.Next())
+	default:
+		return fmt.Errorf("%s.%s: cannot assign IDs to %s fields", db.Statement.Schema.Name, field.Name, field.FieldType)
+	}
+}
+
+// strategy returns the generator a tag names
+func (p *Plugin) strategy(tag string) (Generator, error) {
+	if tag == "auto" {
+		return p.generator, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	strategy := Strategy(tag)
+	if generator, ok := p.generators[strategy]; ok {
+		return generator, nil
+	}
+	config := p.config
+	config.Strategy = strategy
+	generator, err := New(config)
+	if err != nil {
+		return nil, err
+	}
+	p.generators[strategy] = generator
+	return generator, nil
+}
@@ -0,0 +1,261 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy decides what happens when the async buffer is full
+type OverflowPolicy string
+
+const (
+	OverflowBlock      OverflowPolicy = "block"       // Wait for space (no loss, may slow callers)
+	OverflowDropOldest OverflowPolicy = "drop_oldest" // Discard the oldest buffered entry
+	OverflowDropNewest OverflowPolicy = "drop_newest" // Discard the entry being logged
+)
+
+// AsyncConfig holds asynchronous logging configuration
+type AsyncConfig struct {
+	BufferSize int
+	Overflow   OverflowPolicy
+}
+
+// DefaultAsyncConfig returns default async configuration
+func DefaultAsyncConfig() AsyncConfig {
+	return AsyncConfig{
+		BufferSize: 8192,
+		Overflow:   OverflowBlock,
+	}
+}
+
+// AsyncStats reports async logging counters
+type AsyncStats struct {
+	Buffered int    `json:"buffered"`
+	Written  uint64 `json:"written"`
+	Dropped  uint64 `json:"dropped"`
+}
+
+// asyncRecord is a log entry waiting to be formatted and written
+type asyncRecord struct {
+	entry     *Entry
+	formatter Formatter
+	writers   []io.Writer
+	redactor  *Redactor
+}
+
+// asyncDispatcher writes log entries from a bounded queue on a background
+// goroutine. Loggers derived with With/WithContext share the dispatcher.
+type asyncDispatcher struct {
+	queue  chan *asyncRecord
+	policy OverflowPolicy
+
+	mu     sync.RWMutex // Guards closed against concurrent enqueue
+	closed bool
+	done   chan struct{}
+
+	pending int64 // Enqueued but not yet written
+	written uint64
+	dropped uint64
+}
+
+func newAsyncDispatcher(config AsyncConfig) *asyncDispatcher {
+	if config.BufferSize <= 0 {
+		config.BufferSize = DefaultAsyncConfig().BufferSize
+	}
+	switch config.Overflow {
+	case OverflowBlock, OverflowDropOldest, OverflowDropNewest:
+	default:
+		config.Overflow = OverflowBlock
+	}
+
+	d := &asyncDispatcher{
+		queue:  make(chan *asyncRecord, config.BufferSize),
+		policy: config.Overflow,
+		done:   make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// enqueue buffers a record according to the overflow policy. After Close
+// records are written synchronously so nothing is lost during shutdown.
+func (d *asyncDispatcher) enqueue(rec *asyncRecord) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		writeEntry(rec.entry, rec.formatter, rec.writers, rec.redactor)
+		return
+	}
+
+	atomic.AddInt64(&d.pending, 1)
+
+	switch d.policy {
+	case OverflowDropNewest:
+		select {
+		case d.queue <- rec:
+		default:
+			atomic.AddInt64(&d.pending, -1)
+			atomic.AddUint64(&d.dropped, 1)
+		}
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case d.queue <- rec:
+				return
+			default:
+			}
+			// Make room by discarding the oldest entry
+			select {
+			case <-d.queue:
+				atomic.AddInt64(&d.pending, -1)
+				atomic.AddUint64(&d.dropped, 1)
+			default:
+			}
+		}
+
+	default:
+		d.queue <- rec
+	}
+}
+
+// run drains the queue until it is closed
+func (d *asyncDispatcher) run() {
+	defer close(d.done)
+	for rec := range d.queue {
+		writeEntry(rec.entry, rec.formatter, rec.writers, rec.redactor)
+		atomic.AddUint64(&d.written, 1)
+		atomic.AddInt64(&d.pending, -1)
+	}
+}
+
+// flush waits until every entry buffered so far has been written
+func (d *asyncDispatcher) flush(ctx context.Context) error {
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+
+	for atomic.LoadInt64(&d.pending) > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("log flush: %w (%d entries pending)", ctx.Err(), atomic.LoadInt64(&d.pending))
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// close flushes and stops the background goroutine
+func (d *asyncDispatcher) close(ctx context.Context) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	close(d.queue)
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("log close: %w", ctx.Err())
+	}
+}
+
+func (d *asyncDispatcher) stats() AsyncStats {
+	return AsyncStats{
+		Buffered: len(d.queue),
+		Written:  atomic.LoadUint64(&d.written),
+		Dropped:  atomic.LoadUint64(&d.dropped),
+	}
+}
+
+// writeEntry redacts, formats and writes an entry
+func writeEntry(entry *Entry, formatter Formatter, writers []io.Writer, redactor *Redactor) {
+	// Mask secrets before they reach any formatter or writer
+	if redactor != nil {
+		redactor.Redact(entry)
+	}
+
+	// Format the entry
+	formatted, err := formatter.Format(entry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to format log entry: %v\n", err)
+		return
+	}
+
+	// Write to all writers
+	for _, writer := range writers {
+		writer.Write(formatted)
+	}
+}
+
+// EnableAsync switches the logger to asynchronous mode. Entries are queued
+// in a bounded buffer and written by a background goroutine; when the
+// buffer is full the overflow policy applies. Loggers derived afterwards
+// with With/WithContext share the same buffer.
+func (l *StandardLogger) EnableAsync(config AsyncConfig) {
+	l.mu.Lock()
+	previous := l.async
+	l.async = newAsyncDispatcher(config)
+	l.mu.Unlock()
+
+	if previous != nil {
+		previous.close(context.Background())
+	}
+}
+
+// Flush blocks until all buffered entries are written or ctx is done. It
+// is a no-op for synchronous loggers.
+func (l *StandardLogger) Flush(ctx context.Context) error {
+	l.mu.RLock()
+	async := l.async
+	l.mu.RUnlock()
+
+	if async == nil {
+		return nil
+	}
+	return async.flush(ctx)
+}
+
+// Close flushes buffered entries and returns the logger to synchronous mode
+func (l *StandardLogger) Close(ctx context.Context) error {
+	l.mu.Lock()
+	async := l.async
+	l.async = nil
+	l.mu.Unlock()
+
+	if async == nil {
+		return nil
+	}
+	return async.close(ctx)
+}
+
+// AsyncStats returns async buffer counters (zero for synchronous loggers)
+func (l *StandardLogger) AsyncStats() AsyncStats {
+	l.mu.RLock()
+	async := l.async
+	l.mu.RUnlock()
+
+	if async == nil {
+		return AsyncStats{}
+	}
+	return async.stats()
+}
+
+// EnableGlobalAsync switches the global logger to asynchronous mode
+func EnableGlobalAsync(config AsyncConfig) {
+	defaultLogger.EnableAsync(config)
+}
+
+// Flush blocks until the global logger has written all buffered entries
+func Flush(ctx context.Context) error {
+	return defaultLogger.Flush(ctx)
+}
@@ -1,8 +1,11 @@
 package logger
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // Config holds logger configuration
@@ -17,7 +20,14 @@ type Config struct {
 	EnableCaller bool
 	EnableColor  bool
 	RotateOnDate bool
-	PrettyPrint  bool // For JSON format
+	PrettyPrint  bool     // For JSON format
+	ShipTarget   string   // "", "loki", "elasticsearch", or "otlp"
+	ShipEndpoint string   // Base URL of the shipping backend
+	Redact       bool     // Mask sensitive fields and patterns
+	RedactFields []string // Extra field names to mask
+	Async        bool     // Write entries from a background goroutine
+	AsyncBuffer  int      // Async buffer size in entries
+	AsyncPolicy  string   // "block", "drop_oldest", or "drop_newest"
 }
 
 // DefaultConfig returns default logger configuration
@@ -34,6 +44,9 @@ func DefaultConfig() Config {
 		EnableColor:  true,
 		RotateOnDate: false,
 		PrettyPrint:  false,
+		Redact:       true,
+		AsyncBuffer:  8192,
+		AsyncPolicy:  string(OverflowBlock),
 	}
 }
 
@@ -53,6 +66,31 @@ func LoadConfig() Config {
 	if path := os.Getenv("LOG_FILE_PATH"); path != "" {
 		config.FilePath = path
 	}
+	if redact := os.Getenv("LOG_REDACT"); redact != "" {
+		config.Redact = redact != "false" && redact != "0"
+	}
+	if fields := os.Getenv("LOG_REDACT_FIELDS"); fields != "" {
+		for _, f := range strings.Split(fields, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				config.RedactFields = append(config.RedactFields, f)
+			}
+		}
+	}
+	if target := os.Getenv("LOG_SHIP_TARGET"); target != "" {
+		config.ShipTarget = target
+	}
+	if endpoint := os.Getenv("LOG_SHIP_ENDPOINT"); endpoint != "" {
+		config.ShipEndpoint = endpoint
+	}
+	if async := os.Getenv("LOG_ASYNC"); async != "" {
+		config.Async = async == "true" || async == "1"
+	}
+	if size, err := strconv.Atoi(os.Getenv("LOG_ASYNC_BUFFER")); err == nil && size > 0 {
+		config.AsyncBuffer = size
+	}
+	if policy := os.Getenv("LOG_ASYNC_POLICY"); policy != "" {
+		config.AsyncPolicy = policy
+	}
 
 	return config
 }
@@ -76,6 +114,19 @@ func Setup(config Config) error {
 	}
 	SetGlobalFormatter(formatter)
 
+	// Set redaction
+	if config.Redact {
+		redactConfig := DefaultRedactConfig()
+		redactConfig.Fields = append(redactConfig.Fields, config.RedactFields...)
+		redactor, err := NewRedactor(redactConfig)
+		if err != nil {
+			return err
+		}
+		SetGlobalRedactor(redactor)
+	} else {
+		SetGlobalRedactor(nil)
+	}
+
 	// Enable/disable caller
 	EnableGlobalCaller(config.EnableCaller)
 	EnableGlobalColor(config.EnableColor)
@@ -102,9 +153,53 @@ func Setup(config Config) error {
 		}
 	}
 
+	// Setup network shipping
+	if config.ShipTarget != "" {
+		shipper, err := newShipper(config.ShipTarget, config.ShipEndpoint)
+		if err != nil {
+			return err
+		}
+		AddGlobalWriter(shipper)
+		registerShipper(shipper)
+	}
+
+	// Buffer writes on a background goroutine
+	if config.Async {
+		EnableGlobalAsync(AsyncConfig{
+			BufferSize: config.AsyncBuffer,
+			Overflow:   OverflowPolicy(config.AsyncPolicy),
+		})
+	}
+
 	return nil
 }
 
+// newShipper creates a network writer for the given target
+func newShipper(target, endpoint string) (Shipper, error) {
+	switch target {
+	case "loki":
+		cfg := DefaultLokiConfig()
+		if endpoint != "" {
+			cfg.URL = endpoint
+		}
+		return NewLokiWriter(cfg), nil
+	case "elasticsearch", "elastic":
+		cfg := DefaultElasticsearchConfig()
+		if endpoint != "" {
+			cfg.URL = endpoint
+		}
+		return NewElasticsearchWriter(cfg), nil
+	case "otlp":
+		cfg := DefaultOTLPConfig()
+		if endpoint != "" {
+			cfg.Endpoint = endpoint
+		}
+		return NewOTLPWriter(cfg), nil
+	default:
+		return nil, fmt.Errorf("unsupported log ship target: %s", target)
+	}
+}
+
 // parseLevel parses string level to LogLevel
 func parseLevel(level string) LogLevel {
 	switch level {
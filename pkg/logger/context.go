@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"context"
+
+	"neonexcore/pkg/tracing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type contextKey struct{}
+
+// localsKey is the Fiber locals key holding the request-scoped logger.
+// Fiber locals are fasthttp user values, so the logger is also visible
+// through the context.Context returned by c.Context().
+const localsKey = "logger"
+
+// NewContext returns a copy of ctx carrying logger
+func NewContext(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx. Both c.UserContext() and
+// c.Context() of a request that passed RequestIDMiddleware carry the
+// request-scoped logger with request_id, trace_id, span_id and (once
+// authenticated) user_id fields. Otherwise the default logger is returned,
+// annotated with the trace of ctx if it has one.
+func FromContext(ctx context.Context) Logger {
+	if ctx == nil {
+		return defaultLogger
+	}
+	if logger, ok := ctx.Value(contextKey{}).(Logger); ok {
+		return logger
+	}
+	if logger, ok := ctx.Value(localsKey).(Logger); ok {
+		return logger
+	}
+	if span, ok := tracing.SpanFromContext(ctx); ok {
+		return defaultLogger.With(spanFields(span))
+	}
+	return defaultLogger
+}
+
+// AddRequestFields adds fields to the request-scoped logger, so that every
+// later FromContext/GetLogger call for the request includes them
+func AddRequestFields(c *fiber.Ctx, fields Fields) {
+	logger := GetLogger(c).With(fields)
+	c.Locals(localsKey, logger)
+	c.SetUserContext(NewContext(c.UserContext(), logger))
+}
+
+// spanFields returns correlation fields for a span
+func spanFields(span tracing.SpanContext) Fields {
+	return Fields{
+		"trace_id": span.TraceID,
+		"span_id":  span.SpanID,
+	}
+}
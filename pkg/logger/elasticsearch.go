@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ElasticsearchConfig holds configuration for the Elasticsearch bulk writer
+type ElasticsearchConfig struct {
+	URL        string // Base URL, e.g. http://localhost:9200
+	Index      string // Index name or time pattern prefix
+	DailyIndex bool   // Append -YYYY.MM.DD to the index name
+	Username   string
+	Password   string
+	APIKey     string // Sent as "Authorization: ApiKey <key>"
+	Shipper    ShipperConfig
+}
+
+// DefaultElasticsearchConfig returns default Elasticsearch configuration
+func DefaultElasticsearchConfig() ElasticsearchConfig {
+	return ElasticsearchConfig{
+		URL:        "http://localhost:9200",
+		Index:      "neonexcore-logs",
+		DailyIndex: true,
+		Shipper:    DefaultShipperConfig(),
+	}
+}
+
+// ElasticsearchWriter ships log lines to Elasticsearch using the bulk API
+type ElasticsearchWriter struct {
+	*batchShipper
+	config ElasticsearchConfig
+}
+
+// NewElasticsearchWriter creates a new Elasticsearch writer
+func NewElasticsearchWriter(config ElasticsearchConfig) *ElasticsearchWriter {
+	w := &ElasticsearchWriter{config: config}
+	w.batchShipper = newBatchShipper("elasticsearch", config.Shipper, w.bulk)
+	return w
+}
+
+// indexFor returns the target index for an entry time
+func (w *ElasticsearchWriter) indexFor(t time.Time) string {
+	if w.config.DailyIndex {
+		return w.config.Index + "-" + t.UTC().Format("2006.01.02")
+	}
+	return w.config.Index
+}
+
+// bulk sends a batch to /_bulk as NDJSON. JSON-formatted lines are indexed
+// as-is; text lines are wrapped in a document with a message field.
+func (w *ElasticsearchWriter) bulk(ctx context.Context, batch []shippedEntry) error {
+	var buf bytes.Buffer
+
+	for _, entry := range batch {
+		action := map[string]map[string]string{
+			"index": {"_index": w.indexFor(entry.Time)},
+		}
+		meta, err := json.Marshal(action)
+		if err != nil {
+			return err
+		}
+		buf.Write(meta)
+		buf.WriteByte('\n')
+
+		doc, err := elasticsearchDocument(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(doc)
+		buf.WriteByte('\n')
+	}
+
+	headers := copyHeaders(w.config.Shipper.Headers)
+	if w.config.APIKey != "" {
+		headers["Authorization"] = "ApiKey " + w.config.APIKey
+	}
+
+	url := strings.TrimRight(w.config.URL, "/") + "/_bulk"
+	return w.postBulk(ctx, url, buf.Bytes(), headers)
+}
+
+// postBulk posts the bulk payload and checks for per-item failures
+func (w *ElasticsearchWriter) postBulk(ctx context.Context, url string, body []byte, headers map[string]string) error {
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+
+	resp, err := postShipment(ctx, w.batchShipper.config.HTTPClient, url, "application/x-ndjson", body, headers, w.config.Username, w.config.Password)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(resp, &result); err != nil || !result.Errors {
+		return nil
+	}
+
+	for _, item := range result.Items {
+		for _, res := range item {
+			if res.Status >= 300 {
+				return &shipperHTTPError{
+					StatusCode: res.Status,
+					Body:       fmt.Sprintf("%s: %s", res.Error.Type, res.Error.Reason),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// elasticsearchDocument converts an entry into an indexable JSON document
+func elasticsearchDocument(entry shippedEntry) ([]byte, error) {
+	line := bytes.TrimSpace(entry.Line)
+
+	if len(line) > 0 && line[0] == '{' {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(line, &doc); err == nil {
+			if _, ok := doc["@timestamp"]; !ok {
+				doc["@timestamp"] = entry.Time.UTC().Format(time.RFC3339Nano)
+			}
+			return json.Marshal(doc)
+		}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"@timestamp": entry.Time.UTC().Format(time.RFC3339Nano),
+		"level":      extractLevel(line),
+		"message":    string(line),
+	})
+}
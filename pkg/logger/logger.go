@@ -2,7 +2,6 @@ package logger
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"os"
 	"runtime"
@@ -102,6 +101,8 @@ type StandardLogger struct {
 	ctx       context.Context
 	caller    bool
 	colorize  bool
+	redactor  *Redactor
+	async     *asyncDispatcher
 }
 
 // NewLogger creates a new logger instance
@@ -113,6 +114,7 @@ func NewLogger() *StandardLogger {
 		fields:    make(Fields),
 		caller:    true,
 		colorize:  true,
+		redactor:  NewDefaultRedactor(),
 	}
 }
 
@@ -137,6 +139,13 @@ func (l *StandardLogger) AddWriter(writer io.Writer) {
 	l.writers = append(l.writers, writer)
 }
 
+// SetRedactor sets the redactor applied before formatting (nil disables redaction)
+func (l *StandardLogger) SetRedactor(redactor *Redactor) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redactor = redactor
+}
+
 // EnableCaller enables/disables caller information
 func (l *StandardLogger) EnableCaller(enabled bool) {
 	l.mu.Lock()
@@ -172,6 +181,8 @@ func (l *StandardLogger) With(fields Fields) Logger {
 		ctx:       l.ctx,
 		caller:    l.caller,
 		colorize:  l.colorize,
+		redactor:  l.redactor,
+		async:     l.async,
 	}
 }
 
@@ -188,6 +199,8 @@ func (l *StandardLogger) WithContext(ctx context.Context) Logger {
 		ctx:       ctx,
 		caller:    l.caller,
 		colorize:  l.colorize,
+		redactor:  l.redactor,
+		async:     l.async,
 	}
 }
 
@@ -214,6 +227,12 @@ func (l *StandardLogger) Error(msg string, fields ...Fields) {
 // Fatal logs a fatal message and exits
 func (l *StandardLogger) Fatal(msg string, fields ...Fields) {
 	l.log(FatalLevel, msg, fields...)
+
+	// Drain buffered entries so the fatal message is not lost
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	l.Flush(ctx)
+	cancel()
+
 	os.Exit(1)
 }
 
@@ -265,24 +284,31 @@ func (l *StandardLogger) log(level LogLevel, msg string, fieldsSlice ...Fields)
 
 	formatter := l.formatter
 	writers := l.writers
+	redactor := l.redactor
+	async := l.async
 	l.mu.RUnlock()
 
-	// Format the entry
-	formatted, err := formatter.Format(entry)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to format log entry: %v\n", err)
+	if async != nil {
+		async.enqueue(&asyncRecord{
+			entry:     entry,
+			formatter: formatter,
+			writers:   writers,
+			redactor:  redactor,
+		})
 		return
 	}
 
-	// Write to all writers
-	for _, writer := range writers {
-		writer.Write(formatted)
-	}
+	writeEntry(entry, formatter, writers, redactor)
 }
 
 // Global logger instance
 var defaultLogger = NewLogger()
 
+// Default returns the global logger configured by Setup
+func Default() Logger {
+	return defaultLogger
+}
+
 // SetGlobalLevel sets the global logger level
 func SetGlobalLevel(level LogLevel) {
 	defaultLogger.SetLevel(level)
@@ -293,6 +319,11 @@ func SetGlobalFormatter(formatter Formatter) {
 	defaultLogger.SetFormatter(formatter)
 }
 
+// SetGlobalRedactor sets the global logger redactor
+func SetGlobalRedactor(redactor *Redactor) {
+	defaultLogger.SetRedactor(redactor)
+}
+
 // AddGlobalWriter adds a writer to the global logger
 func AddGlobalWriter(writer io.Writer) {
 	defaultLogger.AddWriter(writer)
@@ -0,0 +1,162 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// LokiConfig holds configuration for the Loki push writer
+type LokiConfig struct {
+	URL      string            // Base URL, e.g. http://localhost:3100
+	Labels   map[string]string // Static stream labels
+	TenantID string            // X-Scope-OrgID for multi-tenant Loki
+	Username string
+	Password string
+	Shipper  ShipperConfig
+}
+
+// DefaultLokiConfig returns default Loki configuration
+func DefaultLokiConfig() LokiConfig {
+	return LokiConfig{
+		URL:     "http://localhost:3100",
+		Labels:  map[string]string{"app": "neonexcore"},
+		Shipper: DefaultShipperConfig(),
+	}
+}
+
+// LokiWriter ships log lines to Grafana Loki using the push API
+type LokiWriter struct {
+	*batchShipper
+	config LokiConfig
+}
+
+// NewLokiWriter creates a new Loki writer
+func NewLokiWriter(config LokiConfig) *LokiWriter {
+	w := &LokiWriter{config: config}
+	w.batchShipper = newBatchShipper("loki", config.Shipper, w.push)
+	return w
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type lokiPushRequest struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+// push sends a batch to /loki/api/v1/push, grouping lines by level label
+func (w *LokiWriter) push(ctx context.Context, batch []shippedEntry) error {
+	streams := make(map[string]*lokiStream)
+	order := make([]string, 0)
+
+	for _, entry := range batch {
+		line := strings.TrimRight(string(entry.Line), "\n")
+		level := strings.ToLower(extractLevel(entry.Line))
+
+		stream, ok := streams[level]
+		if !ok {
+			labels := make(map[string]string, len(w.config.Labels)+1)
+			for k, v := range w.config.Labels {
+				labels[k] = v
+			}
+			if level != "" {
+				labels["level"] = level
+			}
+			stream = &lokiStream{Stream: labels}
+			streams[level] = stream
+			order = append(order, level)
+		}
+
+		stream.Values = append(stream.Values, [2]string{
+			strconv.FormatInt(entry.Time.UnixNano(), 10),
+			line,
+		})
+	}
+
+	req := lokiPushRequest{Streams: make([]lokiStream, 0, len(order))}
+	for _, level := range order {
+		req.Streams = append(req.Streams, *streams[level])
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	headers := copyHeaders(w.config.Shipper.Headers)
+	if w.config.TenantID != "" {
+		headers["X-Scope-OrgID"] = w.config.TenantID
+	}
+
+	url := strings.TrimRight(w.config.URL, "/") + "/loki/api/v1/push"
+	_, err = postShipment(ctx, w.batchShipper.config.HTTPClient, url, "application/json", body, headers, w.config.Username, w.config.Password)
+	return err
+}
+
+// postShipment posts a payload and returns the response body. Non-2xx
+// responses are converted to errors.
+func postShipment(ctx context.Context, client *http.Client, url, contentType string, body []byte, headers map[string]string, username, password string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &shipperHTTPError{StatusCode: resp.StatusCode, Body: string(msg)}
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// extractLevel returns the level of a formatted line when it can be
+// determined (JSON "level" field or a known text level token).
+func extractLevel(line []byte) string {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var doc struct {
+			Level string `json:"level"`
+		}
+		if json.Unmarshal(trimmed, &doc) == nil {
+			return doc.Level
+		}
+	}
+
+	// Text format: the level is the first level token after the timestamp
+	found, pos := "", -1
+	for _, level := range []LogLevel{DebugLevel, InfoLevel, WarnLevel, ErrorLevel, FatalLevel} {
+		if idx := bytes.Index(line, []byte(level.String())); idx >= 0 && (pos < 0 || idx < pos) {
+			found, pos = level.String(), idx
+		}
+	}
+	return found
+}
+
+func copyHeaders(headers map[string]string) map[string]string {
+	result := make(map[string]string, len(headers))
+	for k, v := range headers {
+		result[k] = v
+	}
+	return result
+}
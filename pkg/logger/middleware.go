@@ -3,6 +3,8 @@ package logger
 import (
 	"time"
 
+	"neonexcore/pkg/tracing"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -35,6 +37,11 @@ func HTTPMiddleware(logger Logger) fiber.Handler {
 			fields["query"] = c.Context().QueryArgs().String()
 		}
 
+		// Prefer the request-scoped logger for correlation fields
+		if requestLogger, ok := c.Locals(localsKey).(Logger); ok {
+			logger = requestLogger
+		}
+
 		// Log based on status code
 		msg := "HTTP Request"
 		if err != nil {
@@ -52,7 +59,9 @@ func HTTPMiddleware(logger Logger) fiber.Handler {
 	}
 }
 
-// RequestIDMiddleware adds a request ID to each request
+// RequestIDMiddleware adds a request ID to each request and creates the
+// request-scoped logger carrying request_id, trace_id and span_id. The
+// logger is retrievable with GetLogger(c) or FromContext(ctx).
 func RequestIDMiddleware(logger Logger) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		requestID := c.Get("X-Request-ID")
@@ -61,9 +70,17 @@ func RequestIDMiddleware(logger Logger) fiber.Handler {
 			c.Set("X-Request-ID", requestID)
 		}
 
-		// Store request ID in context for later use
+		// Continue the caller's trace or start a new one
+		span := tracing.Start(c)
+
+		fields := spanFields(span)
+		fields["request_id"] = requestID
+		requestLogger := logger.With(fields)
+
+		// Store request ID and logger in context for later use
 		c.Locals("request_id", requestID)
-		c.Locals("logger", logger.With(Fields{"request_id": requestID}))
+		c.Locals(localsKey, requestLogger)
+		c.SetUserContext(NewContext(c.UserContext(), requestLogger))
 
 		return c.Next()
 	}
@@ -71,7 +88,7 @@ func RequestIDMiddleware(logger Logger) fiber.Handler {
 
 // GetLogger retrieves the logger from Fiber context
 func GetLogger(c *fiber.Ctx) Logger {
-	if logger, ok := c.Locals("logger").(Logger); ok {
+	if logger, ok := c.Locals(localsKey).(Logger); ok {
 		return logger
 	}
 	return defaultLogger
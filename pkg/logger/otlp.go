@@ -0,0 +1,176 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// OTLPConfig holds configuration for the OTLP/HTTP logs exporter
+type OTLPConfig struct {
+	Endpoint           string            // Collector base URL, e.g. http://localhost:4318
+	ServiceName        string            // resource service.name
+	ResourceAttributes map[string]string // Extra resource attributes
+	Shipper            ShipperConfig
+}
+
+// DefaultOTLPConfig returns default OTLP configuration
+func DefaultOTLPConfig() OTLPConfig {
+	return OTLPConfig{
+		Endpoint:           "http://localhost:4318",
+		ServiceName:        "neonexcore",
+		ResourceAttributes: make(map[string]string),
+		Shipper:            DefaultShipperConfig(),
+	}
+}
+
+// OTLPWriter exports log lines to an OpenTelemetry collector using the
+// OTLP/HTTP JSON encoding
+type OTLPWriter struct {
+	*batchShipper
+	config OTLPConfig
+}
+
+// NewOTLPWriter creates a new OTLP logs exporter
+func NewOTLPWriter(config OTLPConfig) *OTLPWriter {
+	w := &OTLPWriter{config: config}
+	w.batchShipper = newBatchShipper("otlp", config.Shipper, w.export)
+	return w
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber,omitempty"`
+	SeverityText   string         `json:"severityText,omitempty"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID        string         `json:"traceId,omitempty"`
+	SpanID         string         `json:"spanId,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      map[string]string `json:"scope"`
+	LogRecords []otlpLogRecord   `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpExportRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+// otlpSeverity maps logger levels to OTLP severity numbers
+var otlpSeverity = map[string]int{
+	"DEBUG": 5,
+	"INFO":  9,
+	"WARN":  13,
+	"ERROR": 17,
+	"FATAL": 21,
+}
+
+// export sends a batch to /v1/logs
+func (w *OTLPWriter) export(ctx context.Context, batch []shippedEntry) error {
+	resource := otlpResourceLogs{}
+	resource.Resource.Attributes = append(resource.Resource.Attributes, otlpKeyValue{
+		Key:   "service.name",
+		Value: otlpAnyValue{StringValue: w.config.ServiceName},
+	})
+	for k, v := range w.config.ResourceAttributes {
+		resource.Resource.Attributes = append(resource.Resource.Attributes, otlpKeyValue{
+			Key:   k,
+			Value: otlpAnyValue{StringValue: v},
+		})
+	}
+
+	scope := otlpScopeLogs{
+		Scope:      map[string]string{"name": "neonexcore/pkg/logger"},
+		LogRecords: make([]otlpLogRecord, 0, len(batch)),
+	}
+	for _, entry := range batch {
+		scope.LogRecords = append(scope.LogRecords, otlpRecord(entry))
+	}
+	resource.ScopeLogs = []otlpScopeLogs{scope}
+
+	body, err := json.Marshal(otlpExportRequest{ResourceLogs: []otlpResourceLogs{resource}})
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimRight(w.config.Endpoint, "/") + "/v1/logs"
+	_, err = postShipment(ctx, w.batchShipper.config.HTTPClient, url, "application/json", body, w.config.Shipper.Headers, "", "")
+	return err
+}
+
+// otlpRecord converts an entry to an OTLP log record. Fields of JSON lines
+// become string attributes; message is used as the body.
+func otlpRecord(entry shippedEntry) otlpLogRecord {
+	line := bytes.TrimSpace(entry.Line)
+	level := strings.ToUpper(extractLevel(line))
+
+	record := otlpLogRecord{
+		TimeUnixNano:   strconv.FormatInt(entry.Time.UnixNano(), 10),
+		SeverityText:   level,
+		SeverityNumber: otlpSeverity[level],
+		Body:           otlpAnyValue{StringValue: string(line)},
+	}
+
+	if len(line) == 0 || line[0] != '{' {
+		return record
+	}
+
+	var doc map[string]interface{}
+	if json.Unmarshal(line, &doc) != nil {
+		return record
+	}
+
+	if msg, ok := doc["message"].(string); ok {
+		record.Body.StringValue = msg
+	}
+	if traceID, ok := doc["trace_id"].(string); ok {
+		record.TraceID = traceID
+	}
+	if spanID, ok := doc["span_id"].(string); ok {
+		record.SpanID = spanID
+	}
+
+	for k, v := range doc {
+		switch k {
+		case "message", "level", "time", "trace_id", "span_id":
+			continue
+		}
+		record.Attributes = append(record.Attributes, otlpKeyValue{
+			Key:   k,
+			Value: otlpAnyValue{StringValue: stringify(v)},
+		})
+	}
+
+	return record
+}
+
+// stringify renders an arbitrary JSON value as a string
+func stringify(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
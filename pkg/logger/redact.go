@@ -0,0 +1,245 @@
+package logger
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultRedactMask replaces redacted values
+const DefaultRedactMask = "[REDACTED]"
+
+// DefaultRedactFields are field names masked by default
+var DefaultRedactFields = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"authorization",
+	"cookie",
+	"api_key",
+	"apikey",
+	"credit_card",
+	"card_number",
+	"cvv",
+}
+
+// DefaultRedactPatterns are regex patterns masked in message text and
+// string field values by default
+var DefaultRedactPatterns = []string{
+	// Bearer / Basic credentials
+	`(?i)\b(bearer|basic)\s+[a-z0-9\-._~+/]+=*`,
+	// JWTs
+	`\beyJ[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+`,
+	// key=value pairs for sensitive keys (query strings, form bodies)
+	`(?i)\b(password|passwd|secret|token|access_token|refresh_token|api_key|apikey)=[^&\s]+`,
+}
+
+// cardPattern finds candidate card numbers; candidates are masked only
+// when they pass the Luhn check and start with a known card prefix
+var cardPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+
+// RedactConfig holds redaction configuration
+type RedactConfig struct {
+	Fields   []string // Field names to mask (case-insensitive)
+	Patterns []string // Regex patterns to mask in strings
+	Cards    bool     // Mask Luhn-valid card numbers in strings
+	Mask     string
+}
+
+// DefaultRedactConfig returns default redaction configuration
+func DefaultRedactConfig() RedactConfig {
+	return RedactConfig{
+		Fields:   append([]string(nil), DefaultRedactFields...),
+		Patterns: append([]string(nil), DefaultRedactPatterns...),
+		Cards:    true,
+		Mask:     DefaultRedactMask,
+	}
+}
+
+// Redactor masks sensitive field values and text patterns
+type Redactor struct {
+	fields   map[string]struct{}
+	patterns []*regexp.Regexp
+	cards    bool
+	mask     string
+}
+
+// NewRedactor creates a new redactor
+func NewRedactor(config RedactConfig) (*Redactor, error) {
+	r := &Redactor{
+		fields: make(map[string]struct{}, len(config.Fields)),
+		cards:  config.Cards,
+		mask:   config.Mask,
+	}
+	if r.mask == "" {
+		r.mask = DefaultRedactMask
+	}
+
+	for _, name := range config.Fields {
+		r.fields[normalizeFieldName(name)] = struct{}{}
+	}
+
+	for _, pattern := range config.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+
+	return r, nil
+}
+
+// NewDefaultRedactor creates a redactor with default rules
+func NewDefaultRedactor() *Redactor {
+	r, _ := NewRedactor(DefaultRedactConfig())
+	return r
+}
+
+// normalizeFieldName lowercases and unifies separators
+func normalizeFieldName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "-", "_")
+}
+
+// IsSensitive reports whether a field name should be masked. A name
+// matches when it equals a configured name or contains it as a
+// separator-delimited segment (e.g. "access_token", "X-Api-Key").
+func (r *Redactor) IsSensitive(name string) bool {
+	n := normalizeFieldName(name)
+	if _, ok := r.fields[n]; ok {
+		return true
+	}
+	for field := range r.fields {
+		if strings.HasPrefix(n, field+"_") ||
+			strings.HasSuffix(n, "_"+field) ||
+			strings.Contains(n, "_"+field+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactString masks pattern matches in s
+func (r *Redactor) RedactString(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllStringFunc(s, func(match string) string {
+			// Keep the key of key=value pairs readable
+			if idx := strings.IndexByte(match, '='); idx > 0 {
+				return match[:idx+1] + r.mask
+			}
+			return r.mask
+		})
+	}
+	if r.cards {
+		s = cardPattern.ReplaceAllStringFunc(s, func(match string) string {
+			if isCardNumber(match) {
+				return r.mask
+			}
+			return match
+		})
+	}
+	return s
+}
+
+// isCardNumber reports whether s looks like a payment card number
+func isCardNumber(s string) bool {
+	digits := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			digits = append(digits, s[i]-'0')
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	// Known issuer prefixes: 3 (Amex/Diners/JCB), 4 (Visa), 5 and 2221-2720 (Mastercard), 6 (Discover)
+	switch digits[0] {
+	case 3, 4, 5, 6:
+	case 2:
+		prefix := int(digits[0])*1000 + int(digits[1])*100 + int(digits[2])*10 + int(digits[3])
+		if prefix < 2221 || prefix > 2720 {
+			return false
+		}
+	default:
+		return false
+	}
+
+	// Luhn checksum
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i])
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// RedactFields returns a copy of fields with sensitive values masked
+func (r *Redactor) RedactFields(fields Fields) Fields {
+	if len(fields) == 0 {
+		return fields
+	}
+
+	result := make(Fields, len(fields))
+	for k, v := range fields {
+		if r.IsSensitive(k) {
+			result[k] = r.mask
+			continue
+		}
+		result[k] = r.redactValue(v)
+	}
+	return result
+}
+
+// redactValue masks nested values
+func (r *Redactor) redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return r.RedactString(val)
+	case error:
+		return r.RedactString(val.Error())
+	case Fields:
+		return r.RedactFields(val)
+	case map[string]interface{}:
+		return map[string]interface{}(r.RedactFields(Fields(val)))
+	case map[string]string:
+		result := make(map[string]string, len(val))
+		for k, s := range val {
+			if r.IsSensitive(k) {
+				result[k] = r.mask
+			} else {
+				result[k] = r.RedactString(s)
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i, item := range val {
+			result[i] = r.redactValue(item)
+		}
+		return result
+	case []string:
+		result := make([]string, len(val))
+		for i, item := range val {
+			result[i] = r.RedactString(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+// Redact masks an entry in place
+func (r *Redactor) Redact(entry *Entry) {
+	entry.Message = r.RedactString(entry.Message)
+	entry.Fields = r.RedactFields(entry.Fields)
+}
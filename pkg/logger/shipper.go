@@ -0,0 +1,338 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrShipperClosed is returned when writing to a closed shipper
+var ErrShipperClosed = errors.New("logger: shipper is closed")
+
+// ShipperConfig holds common configuration for network log shippers
+type ShipperConfig struct {
+	BatchSize     int           // Max entries per request
+	BufferSize    int           // Max entries buffered before dropping
+	FlushInterval time.Duration // Max time an entry waits in the batch
+	MaxRetries    int           // Retries per batch after the first attempt
+	RetryBackoff  time.Duration // Initial backoff, doubled on each retry
+	MaxBackoff    time.Duration // Upper bound for backoff
+	Timeout       time.Duration // Per-request timeout
+	Headers       map[string]string
+	HTTPClient    *http.Client
+}
+
+// DefaultShipperConfig returns default shipper configuration
+func DefaultShipperConfig() ShipperConfig {
+	return ShipperConfig{
+		BatchSize:     500,
+		BufferSize:    10000,
+		FlushInterval: 2 * time.Second,
+		MaxRetries:    5,
+		RetryBackoff:  500 * time.Millisecond,
+		MaxBackoff:    30 * time.Second,
+		Timeout:       10 * time.Second,
+		Headers:       make(map[string]string),
+	}
+}
+
+// ShipperStats holds shipper counters
+type ShipperStats struct {
+	Sent    uint64 `json:"sent"`
+	Dropped uint64 `json:"dropped"`
+	Failed  uint64 `json:"failed"`
+	Retries uint64 `json:"retries"`
+}
+
+// shippedEntry is a formatted log line with the time it was written
+type shippedEntry struct {
+	Time time.Time
+	Line []byte
+}
+
+// sendFunc delivers a batch of entries to the remote backend
+type sendFunc func(ctx context.Context, batch []shippedEntry) error
+
+// batchShipper buffers formatted log lines and ships them asynchronously
+// in batches. It implements io.Writer so it can be added to any logger.
+type batchShipper struct {
+	name    string
+	config  ShipperConfig
+	send    sendFunc
+	entries chan shippedEntry
+	flushCh chan chan error
+	done    chan struct{}
+	wg      sync.WaitGroup
+	closed  atomic.Bool
+	once    sync.Once
+
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+	retries atomic.Uint64
+}
+
+// newBatchShipper creates and starts a batch shipper
+func newBatchShipper(name string, config ShipperConfig, send sendFunc) *batchShipper {
+	defaults := DefaultShipperConfig()
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaults.BufferSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaults.RetryBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaults.MaxBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: config.Timeout}
+	}
+
+	s := &batchShipper{
+		name:    name,
+		config:  config,
+		send:    send,
+		entries: make(chan shippedEntry, config.BufferSize),
+		flushCh: make(chan chan error),
+		done:    make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s
+}
+
+// Write buffers a formatted log line. It never blocks; when the buffer
+// is full the line is dropped and counted.
+func (s *batchShipper) Write(p []byte) (int, error) {
+	if s.closed.Load() {
+		return 0, ErrShipperClosed
+	}
+
+	line := make([]byte, len(p))
+	copy(line, p)
+
+	select {
+	case s.entries <- shippedEntry{Time: time.Now(), Line: line}:
+	default:
+		s.dropped.Add(1)
+	}
+
+	return len(p), nil
+}
+
+// Flush ships all buffered entries and waits for completion
+func (s *batchShipper) Flush(ctx context.Context) error {
+	if s.closed.Load() {
+		return ErrShipperClosed
+	}
+
+	result := make(chan error, 1)
+	select {
+	case s.flushCh <- result:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes remaining entries and stops the shipper
+func (s *batchShipper) Close() error {
+	s.once.Do(func() {
+		s.closed.Store(true)
+		close(s.done)
+		s.wg.Wait()
+	})
+	return nil
+}
+
+// Stats returns shipper counters
+func (s *batchShipper) Stats() ShipperStats {
+	return ShipperStats{
+		Sent:    s.sent.Load(),
+		Dropped: s.dropped.Load(),
+		Failed:  s.failed.Load(),
+		Retries: s.retries.Load(),
+	}
+}
+
+// run is the background batching loop
+func (s *batchShipper) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]shippedEntry, 0, s.config.BatchSize)
+
+	ship := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := s.deliver(batch)
+		batch = make([]shippedEntry, 0, s.config.BatchSize)
+		return err
+	}
+
+	// drain moves everything currently buffered into batches
+	drain := func() error {
+		var lastErr error
+		for {
+			select {
+			case entry := <-s.entries:
+				batch = append(batch, entry)
+				if len(batch) >= s.config.BatchSize {
+					if err := ship(); err != nil {
+						lastErr = err
+					}
+				}
+			default:
+				if err := ship(); err != nil {
+					lastErr = err
+				}
+				return lastErr
+			}
+		}
+	}
+
+	for {
+		select {
+		case entry := <-s.entries:
+			batch = append(batch, entry)
+			if len(batch) >= s.config.BatchSize {
+				ship()
+			}
+
+		case <-ticker.C:
+			ship()
+
+		case result := <-s.flushCh:
+			result <- drain()
+
+		case <-s.done:
+			drain()
+			return
+		}
+	}
+}
+
+// deliver sends a batch with exponential backoff retries
+func (s *batchShipper) deliver(batch []shippedEntry) error {
+	backoff := s.config.RetryBackoff
+	var err error
+
+	for attempt := 0; attempt <= s.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			s.retries.Add(1)
+			select {
+			case <-time.After(backoff):
+			case <-s.done:
+				// Shutting down: keep retrying without sleeping so the
+				// final flush is bounded by MaxRetries * Timeout.
+			}
+			backoff *= 2
+			if backoff > s.config.MaxBackoff {
+				backoff = s.config.MaxBackoff
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+		err = s.send(ctx, batch)
+		cancel()
+
+		if err == nil {
+			s.sent.Add(uint64(len(batch)))
+			return nil
+		}
+
+		if !isRetryable(err) {
+			break
+		}
+	}
+
+	s.failed.Add(uint64(len(batch)))
+	fmt.Fprintf(os.Stderr, "logger: %s shipper dropped %d entries: %v\n", s.name, len(batch), err)
+	return err
+}
+
+// shipperHTTPError is returned when the backend responds with a non-2xx status
+type shipperHTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *shipperHTTPError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// isRetryable reports whether a failed delivery should be retried
+func isRetryable(err error) bool {
+	var httpErr *shipperHTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+	}
+	return true
+}
+
+// Shipper is a network log writer with async buffering
+type Shipper interface {
+	Write(p []byte) (int, error)
+	Flush(ctx context.Context) error
+	Close() error
+	Stats() ShipperStats
+}
+
+var (
+	shippersMu sync.Mutex
+	shippers   []Shipper
+)
+
+// registerShipper tracks a shipper so Shutdown can flush it
+func registerShipper(s Shipper) {
+	shippersMu.Lock()
+	defer shippersMu.Unlock()
+	shippers = append(shippers, s)
+}
+
+// Shutdown drains the global logger's async buffer, then flushes and
+// closes all shippers attached by Setup
+func Shutdown(ctx context.Context) error {
+	asyncErr := defaultLogger.Close(ctx)
+
+	shippersMu.Lock()
+	list := shippers
+	shippers = nil
+	shippersMu.Unlock()
+
+	firstErr := asyncErr
+	for _, s := range list {
+		if err := s.Flush(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := s.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,341 @@
+package metering
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Usage is the traffic recorded for a subject during one bucket
+type Usage struct {
+	ID         uint      `gorm:"primarykey" json:"-"`
+	Subject    string    `gorm:"size:100;uniqueIndex:idx_metering_usage_subject_bucket;not null" json:"subject"`
+	Bucket     time.Time `gorm:"uniqueIndex:idx_metering_usage_subject_bucket;not null" json:"bucket"`
+	Requests   int64     `gorm:"default:0" json:"requests"`
+	Errors     int64     `gorm:"default:0" json:"errors"` // Responses with status >= 400
+	BytesIn    int64     `gorm:"default:0" json:"bytes_in"`
+	BytesOut   int64     `gorm:"default:0" json:"bytes_out"`
+	DurationMs int64     `gorm:"default:0" json:"duration_ms"` // Total handling time
+}
+
+// TableName specifies the table name for Usage
+func (Usage) TableName() string {
+	return "metering_usage"
+}
+
+func (u *Usage) add(other Usage) {
+	u.Requests += other.Requests
+	u.Errors += other.Errors
+	u.BytesIn += other.BytesIn
+	u.BytesOut += other.BytesOut
+	u.DurationMs += other.DurationMs
+}
+
+// Event is a single metered request
+type Event struct {
+	Subject  string
+	Status   int
+	BytesIn  int64
+	BytesOut int64
+	Duration time.Duration
+	At       time.Time
+}
+
+// Summary totals usage over a period
+type Summary struct {
+	Subject       string    `json:"subject"`
+	Since         time.Time `json:"since"`
+	Until         time.Time `json:"until"`
+	Requests      int64     `json:"requests"`
+	Errors        int64     `json:"errors"`
+	ErrorRate     float64   `json:"error_rate"` // Percent of requests that failed
+	BytesIn       int64     `json:"bytes_in"`
+	BytesOut      int64     `json:"bytes_out"`
+	AvgDurationMs float64   `json:"avg_duration_ms"`
+}
+
+// Config holds meter configuration
+type Config struct {
+	FlushInterval time.Duration // How often buffered usage is written
+	Granularity   time.Duration // Bucket size
+}
+
+// DefaultConfig returns default meter configuration
+func DefaultConfig() Config {
+	return Config{
+		FlushInterval: 10 * time.Second,
+		Granularity:   time.Hour,
+	}
+}
+
+type bucketKey struct {
+	subject string
+	bucket  time.Time
+}
+
+// Meter aggregates request usage per subject in memory and periodically
+// flushes it to the database
+type Meter struct {
+	db     *gorm.DB
+	config Config
+
+	mu      sync.Mutex
+	pending map[bucketKey]*Usage
+	started bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewMeter creates a meter
+func NewMeter(db *gorm.DB, config Config) *Meter {
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultConfig().FlushInterval
+	}
+	if config.Granularity <= 0 {
+		config.Granularity = DefaultConfig().Granularity
+	}
+	return &Meter{
+		db:      db,
+		config:  config,
+		pending: make(map[bucketKey]*Usage),
+	}
+}
+
+// Granularity returns the bucket size
+func (m *Meter) Granularity() time.Duration {
+	return m.config.Granularity
+}
+
+// Record buffers a metered request
+func (m *Meter) Record(event Event) {
+	if event.Subject == "" {
+		return
+	}
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	key := bucketKey{subject: event.Subject, bucket: event.At.UTC().Truncate(m.config.Granularity)}
+	delta := Usage{
+		Requests:   1,
+		BytesIn:    event.BytesIn,
+		BytesOut:   event.BytesOut,
+		DurationMs: event.Duration.Milliseconds(),
+	}
+	if event.Status >= 400 {
+		delta.Errors = 1
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	usage, ok := m.pending[key]
+	if !ok {
+		usage = &Usage{Subject: key.subject, Bucket: key.bucket}
+		m.pending[key] = usage
+	}
+	usage.add(delta)
+}
+
+// Flush writes buffered usage to the database, adding to existing buckets.
+// Usage that fails to write is kept for the next flush.
+func (m *Meter) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = make(map[bucketKey]*Usage)
+	m.mu.Unlock()
+
+	var firstErr error
+	for key, usage := range pending {
+		err := m.db.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "subject"}, {Name: "bucket"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"requests":    gorm.Expr("metering_usage.requests + ?", usage.Requests),
+				"errors":      gorm.Expr("metering_usage.errors + ?", usage.Errors),
+				"bytes_in":    gorm.Expr("metering_usage.bytes_in + ?", usage.BytesIn),
+				"bytes_out":   gorm.Expr("metering_usage.bytes_out + ?", usage.BytesOut),
+				"duration_ms": gorm.Expr("metering_usage.duration_ms + ?", usage.DurationMs),
+			}),
+		}).Create(&Usage{
+			Subject:    usage.Subject,
+			Bucket:     usage.Bucket,
+			Requests:   usage.Requests,
+			Errors:     usage.Errors,
+			BytesIn:    usage.BytesIn,
+			BytesOut:   usage.BytesOut,
+			DurationMs: usage.DurationMs,
+		}).Error
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			m.requeue(key, usage)
+		}
+	}
+	return firstErr
+}
+
+func (m *Meter) requeue(key bucketKey, usage *Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.pending[key]; ok {
+		existing.add(*usage)
+		return
+	}
+	m.pending[key] = usage
+}
+
+// Start begins flushing in the background. It is safe to call more than once.
+func (m *Meter) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.started {
+		return
+	}
+	m.started = true
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.run(m.stop, m.done)
+}
+
+// Stop ends background flushing and writes any buffered usage
+func (m *Meter) Stop() {
+	m.mu.Lock()
+	if !m.started {
+		m.mu.Unlock()
+		return
+	}
+	m.started = false
+	close(m.stop)
+	done := m.done
+	m.mu.Unlock()
+
+	<-done
+}
+
+func (m *Meter) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(m.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.flushAndLog()
+		case <-stop:
+			m.flushAndLog()
+			return
+		}
+	}
+}
+
+func (m *Meter) flushAndLog() {
+	if err := m.Flush(context.Background()); err != nil {
+		logger.Warn("Failed to flush metered usage", logger.Fields{"error": err.Error()})
+	}
+}
+
+// Usage returns a subject's usage buckets in [since, until), including
+// usage not flushed yet, oldest first
+func (m *Meter) Usage(ctx context.Context, subject string, since, until time.Time) ([]Usage, error) {
+	var stored []Usage
+	err := m.db.WithContext(ctx).
+		Where("subject = ? AND bucket >= ? AND bucket < ?", subject, since.UTC().Truncate(m.config.Granularity), until.UTC()).
+		Order("bucket ASC").
+		Find(&stored).Error
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make(map[time.Time]*Usage, len(stored))
+	for i := range stored {
+		buckets[stored[i].Bucket.UTC()] = &stored[i]
+	}
+
+	m.mu.Lock()
+	for key, usage := range m.pending {
+		if key.subject != subject || key.bucket.Before(since.UTC().Truncate(m.config.Granularity)) || !key.bucket.Before(until.UTC()) {
+			continue
+		}
+		if existing, ok := buckets[key.bucket]; ok {
+			existing.add(*usage)
+			continue
+		}
+		copied := *usage
+		buckets[key.bucket] = &copied
+	}
+	m.mu.Unlock()
+
+	result := make([]Usage, 0, len(buckets))
+	for _, usage := range buckets {
+		usage.Bucket = usage.Bucket.UTC()
+		result = append(result, *usage)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Bucket.Before(result[j].Bucket) })
+	return result, nil
+}
+
+// Summarize totals a subject's usage in [since, until)
+func (m *Meter) Summarize(ctx context.Context, subject string, since, until time.Time) (*Summary, error) {
+	buckets, err := m.Usage(ctx, subject, since, until)
+	if err != nil {
+		return nil, err
+	}
+	return Summarize(subject, since, until, buckets), nil
+}
+
+// Summarize totals usage buckets
+func Summarize(subject string, since, until time.Time, buckets []Usage) *Summary {
+	var total Usage
+	for _, usage := range buckets {
+		total.add(usage)
+	}
+
+	summary := &Summary{
+		Subject:  subject,
+		Since:    since,
+		Until:    until,
+		Requests: total.Requests,
+		Errors:   total.Errors,
+		BytesIn:  total.BytesIn,
+		BytesOut: total.BytesOut,
+	}
+	if total.Requests > 0 {
+		summary.ErrorRate = float64(total.Errors) / float64(total.Requests) * 100
+		summary.AvgDurationMs = float64(total.DurationMs) / float64(total.Requests)
+	}
+	return summary
+}
+
+// Rollup merges buckets into coarser buckets of the given size, e.g. hourly
+// usage into days
+func Rollup(buckets []Usage, size time.Duration) []Usage {
+	merged := make(map[time.Time]*Usage)
+	order := make([]time.Time, 0)
+	for _, usage := range buckets {
+		bucket := usage.Bucket.UTC().Truncate(size)
+		existing, ok := merged[bucket]
+		if !ok {
+			existing = &Usage{Subject: usage.Subject, Bucket: bucket}
+			merged[bucket] = existing
+			order = append(order, bucket)
+		}
+		existing.add(usage)
+	}
+
+	sort.Slice(order, func(i, j int) bool { return order[i].Before(order[j]) })
+	result := make([]Usage, 0, len(order))
+	for _, bucket := range order {
+		result = append(result, *merged[bucket])
+	}
+	return result
+}
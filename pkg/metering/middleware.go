@@ -0,0 +1,45 @@
+package metering
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SubjectFunc returns the subject a request is metered against, or "" to
+// skip metering it
+type SubjectFunc func(c *fiber.Ctx) string
+
+// Middleware meters requests with a subject. Handler errors are counted
+// with the status the error handler will respond with.
+func Middleware(meter *Meter, subject SubjectFunc) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		name := subject(c)
+		if name == "" {
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+
+		meter.Record(Event{
+			Subject:  name,
+			Status:   status,
+			BytesIn:  int64(len(c.Request().Body())),
+			BytesOut: int64(len(c.Response().Body())),
+			Duration: time.Since(start),
+			At:       start,
+		})
+		return err
+	}
+}
@@ -78,6 +78,7 @@ type Collector struct {
 	gauges     map[string]*Gauge
 	histograms map[string]*Histogram
 	summaries  map[string]*Summary
+	pushed     map[string]PushBatch // Metrics pushed by other processes, by job and run ID
 	mu         sync.RWMutex
 
 	// System metrics
@@ -89,11 +90,12 @@ type Collector struct {
 
 // CollectorConfig holds collector configuration
 type CollectorConfig struct {
-	CollectSystemMetrics bool
+	CollectSystemMetrics  bool
 	SystemMetricsInterval time.Duration
-	EnableHistory        bool
-	HistorySize          int
-	DefaultBuckets       []float64
+	EnableHistory         bool
+	HistorySize           int
+	DefaultBuckets        []float64
+	PushedRetention       time.Duration // How long metrics pushed by a run are kept after its last push
 }
 
 // DefaultCollectorConfig returns default collector configuration
@@ -104,6 +106,7 @@ func DefaultCollectorConfig() CollectorConfig {
 		EnableHistory:         true,
 		HistorySize:           100,
 		DefaultBuckets:        []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		PushedRetention:       time.Hour,
 	}
 }
 
@@ -114,6 +117,7 @@ func NewCollector(config CollectorConfig) *Collector {
 		gauges:     make(map[string]*Gauge),
 		histograms: make(map[string]*Histogram),
 		summaries:  make(map[string]*Summary),
+		pushed:     make(map[string]PushBatch),
 		startTime:  time.Now(),
 		config:     config,
 	}
@@ -130,10 +134,20 @@ func NewCollector(config CollectorConfig) *Collector {
 
 // NewCounter creates a new counter metric
 func (c *Collector) NewCounter(name, description string, labels map[string]string) *Counter {
+	return c.counter(name, name, description, labels)
+}
+
+// NewLabeledCounter returns the counter of one label set in a family of
+// counters sharing a name. NewCounter keys counters by name alone.
+func (c *Collector) NewLabeledCounter(name, description string, labels map[string]string) *Counter {
+	return c.counter(seriesKey(name, labels), name, description, labels)
+}
+
+func (c *Collector) counter(key, name, description string, labels map[string]string) *Counter {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if counter, exists := c.counters[name]; exists {
+	if counter, exists := c.counters[key]; exists {
 		return counter
 	}
 
@@ -142,7 +156,7 @@ func (c *Collector) NewCounter(name, description string, labels map[string]strin
 		description: description,
 		labels:      labels,
 	}
-	c.counters[name] = counter
+	c.counters[key] = counter
 	return counter
 }
 
@@ -170,10 +184,20 @@ func (counter *Counter) Reset() {
 
 // NewGauge creates a new gauge metric
 func (c *Collector) NewGauge(name, description string, labels map[string]string) *Gauge {
+	return c.gauge(name, name, description, labels)
+}
+
+// NewLabeledGauge returns the gauge of one label set in a family of gauges
+// sharing a name. NewGauge keys gauges by name alone.
+func (c *Collector) NewLabeledGauge(name, description string, labels map[string]string) *Gauge {
+	return c.gauge(seriesKey(name, labels), name, description, labels)
+}
+
+func (c *Collector) gauge(key, name, description string, labels map[string]string) *Gauge {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if gauge, exists := c.gauges[name]; exists {
+	if gauge, exists := c.gauges[key]; exists {
 		return gauge
 	}
 
@@ -182,10 +206,16 @@ func (c *Collector) NewGauge(name, description string, labels map[string]string)
 		description: description,
 		labels:      labels,
 	}
-	c.gauges[name] = gauge
+	c.gauges[key] = gauge
 	return gauge
 }
 
+// seriesKey identifies a series of a labeled family, e.g.
+// websocket_room_messages_total{room="lobby"}
+func seriesKey(name string, labels map[string]string) string {
+	return name + prometheusLabels(labels, "")
+}
+
 // Set sets the gauge to the given value
 func (gauge *Gauge) Set(value int64) {
 	gauge.value.Store(value)
@@ -364,8 +394,28 @@ func (c *Collector) collectSystemMetrics(ctx context.Context) {
 	}
 }
 
-// GetAllMetrics returns all collected metrics
+// GetAllMetrics returns all collected metrics, and those pushed by other
+// processes, see Ingest
 func (c *Collector) GetAllMetrics() []Metric {
+	metrics := c.localMetrics()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	retention := c.config.PushedRetention
+	if retention <= 0 {
+		retention = DefaultCollectorConfig().PushedRetention
+	}
+	for _, batch := range c.pushed {
+		if time.Since(batch.PushedAt) <= retention {
+			metrics = append(metrics, batch.Metrics...)
+		}
+	}
+	return metrics
+}
+
+// localMetrics returns the metrics collected by this process
+func (c *Collector) localMetrics() []Metric {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -18,9 +18,13 @@ type Dashboard struct {
 	mu        sync.RWMutex
 
 	// Alert configuration
-	alerts []Alert
+	alerts        []Alert
+	alertHandlers []AlertHandler
 }
 
+// AlertHandler is notified when an alert fires
+type AlertHandler func(alert Alert, metric Metric)
+
 // Alert represents a metric alert
 type Alert struct {
 	Name        string                 `json:"name"`
@@ -168,6 +172,19 @@ func (d *Dashboard) fireAlert(alert *Alert, metric Metric) {
 	if d.hub != nil {
 		d.hub.BroadcastJSON(data)
 	}
+
+	// Handlers run outside the alert lock and must not block broadcasting
+	for _, handler := range d.alertHandlers {
+		go handler(*alert, metric)
+	}
+}
+
+// OnAlert registers a handler called every time an alert fires
+func (d *Dashboard) OnAlert(handler AlertHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.alertHandlers = append(d.alertHandlers, handler)
 }
 
 // AddAlert adds a new alert
@@ -706,15 +723,15 @@ const dashboardHTML = `<!DOCTYPE html>
 
             // Update metrics list
             const metricsList = document.getElementById('metricsList');
-            metricsList.innerHTML = metrics.map(metric => `
-                <li class="metric-item">
-                    <span class="metric-name">
-                        <span class="badge badge-${metric.type}">${metric.type}</span>
-                        ${metric.name}
-                    </span>
-                    <span class="metric-value">${formatValue(metric.value, metric.type)}</span>
-                </li>
-            `).join('');
+            metricsList.innerHTML = metrics.map(metric =>
+                '<li class="metric-item">' +
+                    '<span class="metric-name">' +
+                        '<span class="badge badge-' + metric.type + '">' + metric.type + '</span> ' +
+                        metric.name +
+                    '</span>' +
+                    '<span class="metric-value">' + formatValue(metric.value, metric.type) + '</span>' +
+                '</li>'
+            ).join('');
         }
 
         function updateChart(chart, label, value) {
@@ -734,10 +751,8 @@ const dashboardHTML = `<!DOCTYPE html>
             
             const alertEl = document.createElement('div');
             alertEl.className = 'alert ' + (isCritical ? 'alert-critical' : '');
-            alertEl.innerHTML = `
-                <strong>⚠️ ${alert.name}</strong><br>
-                ${alert.description} (${data.metric.name}: ${formatValue(data.metric.value)})
-            `;
+            alertEl.innerHTML = '<strong>⚠️ ' + alert.name + '</strong><br>' +
+                alert.description + ' (' + data.metric.name + ': ' + formatValue(data.metric.value) + ')';
             
             alertsDiv.insertBefore(alertEl, alertsDiv.firstChild);
             
@@ -0,0 +1,64 @@
+package metrics
+
+// Hooks adapts a collector, and a dashboard for alerts, to the metric
+// hooks of packages that don't import this one, such as monitors,
+// workflow, warehouse and deprecation. Each declares an interface with
+// the methods it needs; Hooks implements them all.
+type Hooks struct {
+	collector *Collector
+	dashboard *Dashboard
+}
+
+// NewHooks creates hooks recording in collector. dashboard may be nil, in
+// which case alerts are dropped.
+func NewHooks(collector *Collector, dashboard *Dashboard) *Hooks {
+	return &Hooks{collector: collector, dashboard: dashboard}
+}
+
+// AddCounter adds delta to a counter
+func (h *Hooks) AddCounter(name, description string, labels map[string]string, delta uint64) {
+	h.collector.NewCounter(name, description, labels).Add(delta)
+}
+
+// SetGauge sets a gauge
+func (h *Hooks) SetGauge(name, description string, labels map[string]string, value int64) {
+	h.collector.NewGauge(name, description, labels).Set(value)
+}
+
+// Observe records a histogram observation. nil buckets use the defaults.
+func (h *Hooks) Observe(name, description string, labels map[string]string, buckets []float64, value float64) {
+	h.collector.NewHistogram(name, description, labels, buckets).Observe(value)
+}
+
+// SetAlert adds, or replaces, a dashboard alert firing while a metric is
+// above threshold
+func (h *Hooks) SetAlert(name, description, metric string, threshold float64, metadata map[string]interface{}) {
+	if h.dashboard == nil {
+		return
+	}
+	h.dashboard.RemoveAlert(name)
+	h.dashboard.AddAlert(Alert{
+		Name:        name,
+		Description: description,
+		Metric:      metric,
+		Condition:   ConditionGreaterThan,
+		Threshold:   threshold,
+		Metadata:    metadata,
+	})
+}
+
+// RemoveAlert removes a dashboard alert
+func (h *Hooks) RemoveAlert(name string) {
+	if h.dashboard == nil {
+		return
+	}
+	h.dashboard.RemoveAlert(name)
+}
+
+// EachMetric calls fn with every metric of the collector, including those
+// pushed by other processes
+func (h *Hooks) EachMetric(fn func(name, kind string, value float64, labels map[string]string)) {
+	for _, metric := range h.collector.GetAllMetrics() {
+		fn(metric.Name, string(metric.Type), metric.Value, metric.Labels)
+	}
+}
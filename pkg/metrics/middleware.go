@@ -3,6 +3,8 @@ package metrics
 import (
 	"time"
 
+	"neonexcore/pkg/sandbox"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -42,6 +44,12 @@ func Middleware(collector *Collector) fiber.Handler {
 		[]float64{100, 1000, 10000, 100000, 1000000},
 	)
 
+	testRequestCounter := collector.NewCounter(
+		"http_requests_test_mode_total",
+		"Number of HTTP requests made in sandbox test mode",
+		map[string]string{"mode": string(sandbox.ModeTest)},
+	)
+
 	statusCounter := make(map[int]*Counter)
 	for _, status := range []int{200, 201, 204, 400, 401, 403, 404, 500, 502, 503} {
 		statusCounter[status] = collector.NewCounter(
@@ -72,6 +80,9 @@ func Middleware(collector *Collector) fiber.Handler {
 		// Update metrics
 		requestCounter.Inc()
 		requestDuration.Observe(duration)
+		if sandbox.IsTestRequest(c) {
+			testRequestCounter.Inc()
+		}
 
 		// Track response size
 		responseSize.Observe(float64(len(c.Response().Body())))
@@ -0,0 +1,403 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Push formats
+const (
+	PushFormatPrometheus = "prometheus" // Prometheus Pushgateway
+	PushFormatJSON       = "json"       // The main app's /metrics/ingest
+)
+
+// PushConfig configures pushing a process's metrics, for workers and CLI
+// commands that exit before they are scraped
+type PushConfig struct {
+	URL      string        // Pushgateway, or the main app's /metrics/ingest; pushing is off when empty
+	Format   string        // PushFormatPrometheus or PushFormatJSON
+	Job      string        // Name of the process, e.g. "neonexcore-migrate"
+	RunID    string        // Of this run; a new one for each process when empty
+	Token    string        // Bearer token sent with pushes, and required by IngestHandler when set
+	Interval time.Duration // Between pushes while running; 0 pushes only on Close
+	Timeout  time.Duration // Of each push
+}
+
+// DefaultPushConfig returns the default push configuration, with pushing
+// off
+func DefaultPushConfig() PushConfig {
+	return PushConfig{
+		Format:  PushFormatPrometheus,
+		Job:     defaultJob(),
+		Timeout: 5 * time.Second,
+	}
+}
+
+// defaultJob names the process after its program and command, e.g.
+// "neonexcore-migrate" for `neonexcore migrate up`
+func defaultJob() string {
+	job := filepath.Base(os.Args[0])
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		job += "-" + os.Args[1]
+	}
+	return job
+}
+
+// LoadPushConfig loads push configuration from environment
+func LoadPushConfig() PushConfig {
+	config := DefaultPushConfig()
+
+	config.URL = strings.TrimSuffix(os.Getenv("METRICS_PUSH_URL"), "/")
+	if format := os.Getenv("METRICS_PUSH_FORMAT"); format != "" {
+		config.Format = format
+	}
+	if job := os.Getenv("METRICS_PUSH_JOB"); job != "" {
+		config.Job = job
+	}
+	config.RunID = os.Getenv("METRICS_PUSH_RUN_ID")
+	config.Token = os.Getenv("METRICS_PUSH_TOKEN")
+	if interval, err := time.ParseDuration(os.Getenv("METRICS_PUSH_INTERVAL")); err == nil {
+		config.Interval = interval
+	}
+
+	return config
+}
+
+// PushBatch is the metrics of one run of a job, as pushed in the JSON
+// format
+type PushBatch struct {
+	Job      string    `json:"job"`
+	RunID    string    `json:"run_id"`
+	Metrics  []Metric  `json:"metrics"`
+	PushedAt time.Time `json:"pushed_at"`
+}
+
+// Pusher pushes a collector's metrics, tagged with the job name and run
+// ID, periodically and once more on Close
+type Pusher struct {
+	collector *Collector
+	config    PushConfig
+	client    *http.Client
+	stop      chan struct{}
+	done      chan struct{}
+	once      sync.Once
+}
+
+// NewPusher creates a pusher for collector and starts its periodic pushes
+// when config.Interval is set
+func NewPusher(collector *Collector, config PushConfig) *Pusher {
+	defaults := DefaultPushConfig()
+	if config.Format == "" {
+		config.Format = defaults.Format
+	}
+	if config.Job == "" {
+		config.Job = defaults.Job
+	}
+	if config.RunID == "" {
+		config.RunID = uuid.NewString()
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+
+	p := &Pusher{
+		collector: collector,
+		config:    config,
+		client:    &http.Client{Timeout: config.Timeout},
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if config.Interval > 0 {
+		go p.pushLoop()
+	} else {
+		close(p.done)
+	}
+	return p
+}
+
+// RunID returns the run ID pushed metrics are tagged with
+func (p *Pusher) RunID() string {
+	return p.config.RunID
+}
+
+func (p *Pusher) pushLoop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), p.config.Timeout)
+			if err := p.Push(ctx); err != nil {
+				log.Printf("Failed to push metrics: %v", err)
+			}
+			cancel()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Close stops the periodic pushes and pushes the final metrics. Call it
+// as the process exits; later calls do nothing.
+func (p *Pusher) Close(ctx context.Context) error {
+	closed := false
+	p.once.Do(func() {
+		close(p.stop)
+		closed = true
+	})
+	if !closed {
+		return nil
+	}
+	<-p.done
+	return p.Push(ctx)
+}
+
+// Push pushes the collector's current metrics, replacing those pushed
+// before for this run
+func (p *Pusher) Push(ctx context.Context) error {
+	metrics := p.collector.localMetrics()
+
+	var (
+		body        bytes.Buffer
+		method      string
+		target      string
+		contentType string
+	)
+	switch p.config.Format {
+	case PushFormatPrometheus:
+		if err := WritePrometheus(&body, metrics); err != nil {
+			return err
+		}
+		method = http.MethodPut
+		target = p.config.URL + "/metrics/job/" + url.PathEscape(p.config.Job) + "/run_id/" + url.PathEscape(p.config.RunID)
+		contentType = "text/plain; version=0.0.4"
+	case PushFormatJSON:
+		batch := PushBatch{Job: p.config.Job, RunID: p.config.RunID, Metrics: metrics, PushedAt: time.Now()}
+		if err := json.NewEncoder(&body).Encode(batch); err != nil {
+			return err
+		}
+		method = http.MethodPost
+		target = p.config.URL
+		contentType = "application/json"
+	default:
+		return fmt.Errorf("unknown metrics push format: %s", p.config.Format)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if p.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.config.Token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("metrics push failed: %d %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Ingest keeps the metrics a job's run pushed, labeled with the job and
+// run ID, until config.PushedRetention passes without another push from
+// the run. A push replaces the run's earlier metrics.
+func (c *Collector) Ingest(batch PushBatch) {
+	now := time.Now()
+	metrics := make([]Metric, len(batch.Metrics))
+	for i, metric := range batch.Metrics {
+		labels := make(map[string]string, len(metric.Labels)+2)
+		for key, value := range metric.Labels {
+			labels[key] = value
+		}
+		labels["job"] = batch.Job
+		labels["run_id"] = batch.RunID
+		metric.Labels = labels
+		metric.Timestamp = now
+		metrics[i] = metric
+	}
+	batch.Metrics = metrics
+	batch.PushedAt = now
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prunePushed(now)
+	c.pushed[batch.Job+"/"+batch.RunID] = batch
+}
+
+// prunePushed drops runs that have not pushed within the retention; the
+// caller holds the lock
+func (c *Collector) prunePushed(now time.Time) {
+	retention := c.config.PushedRetention
+	if retention <= 0 {
+		retention = DefaultCollectorConfig().PushedRetention
+	}
+	for key, batch := range c.pushed {
+		if now.Sub(batch.PushedAt) > retention {
+			delete(c.pushed, key)
+		}
+	}
+}
+
+// IngestHandler accepts metrics pushed in the JSON format by the workers
+// and commands of the app, to show them with its own. With a token, pushes
+// must send it as a bearer token.
+func IngestHandler(collector *Collector, token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token != "" {
+			sent := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				return c.Status(401).JSON(fiber.Map{
+					"success": false,
+					"error":   "Invalid push token",
+				})
+			}
+		}
+
+		var batch PushBatch
+		if err := json.Unmarshal(c.Body(), &batch); err != nil || batch.Job == "" || batch.RunID == "" {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"error":   "Invalid request body",
+			})
+		}
+
+		collector.Ingest(batch)
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Metrics ingested",
+			"count":   len(batch.Metrics),
+		})
+	}
+}
+
+// WritePrometheus writes metrics in the Prometheus text format, as
+// Pushgateway accepts them. Summaries carry only their sum and count.
+// Metrics sharing a name are written as one family, differing by labels.
+func WritePrometheus(w io.Writer, metrics []Metric) error {
+	sorted := make([]Metric, len(metrics))
+	copy(sorted, metrics)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return prometheusLabels(sorted[i].Labels, "") < prometheusLabels(sorted[j].Labels, "")
+	})
+
+	var b strings.Builder
+	for i, metric := range sorted {
+		name := prometheusName(metric.Name)
+		if i == 0 || sorted[i-1].Name != metric.Name {
+			if metric.Description != "" {
+				fmt.Fprintf(&b, "# HELP %s %s\n", name, escapeHelp(metric.Description))
+			}
+			fmt.Fprintf(&b, "# TYPE %s %s\n", name, metric.Type)
+		}
+		labels := prometheusLabels(metric.Labels, "")
+
+		switch metric.Type {
+		case TypeHistogram:
+			count, _ := metric.Metadata["count"].(uint64)
+			buckets, _ := metric.Metadata["buckets"].(map[float64]uint64)
+			bounds := make([]float64, 0, len(buckets))
+			for bound := range buckets {
+				bounds = append(bounds, bound)
+			}
+			sort.Float64s(bounds)
+			for _, bound := range bounds {
+				le := strconv.FormatFloat(bound, 'g', -1, 64)
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, prometheusLabels(metric.Labels, le), buckets[bound])
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", name, prometheusLabels(metric.Labels, "+Inf"), count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", name, labels, formatValue(metric.Value))
+			fmt.Fprintf(&b, "%s_count%s %d\n", name, labels, count)
+		case TypeSummary:
+			count, _ := metric.Metadata["count"].(uint64)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", name, labels, formatValue(metric.Value))
+			fmt.Fprintf(&b, "%s_count%s %d\n", name, labels, count)
+		default:
+			fmt.Fprintf(&b, "%s%s %s\n", name, labels, formatValue(metric.Value))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// prometheusName replaces the characters Prometheus does not allow in a
+// metric or label name with underscores
+func prometheusName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// prometheusLabels formats labels, with an le label for a histogram bucket
+func prometheusLabels(labels map[string]string, le string) string {
+	if len(labels) == 0 && le == "" {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys)+1)
+	for _, key := range keys {
+		parts = append(parts, prometheusName(key)+`="`+escapeLabel(labels[key])+`"`)
+	}
+	if le != "" {
+		parts = append(parts, `le="`+le+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// routeBuckets are the latency buckets of each route, in seconds
+var routeBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 2, 5}
+
+// RouteMetrics counts requests, server errors and latency per route. The
+// series of a route are registered with the route, so a request only looks
+// its route up in an index; the hot path builds no names or label sets and
+// takes no lock.
+type RouteMetrics struct {
+	collector *Collector
+	table     atomic.Pointer[routeTable]
+	unmatched *routeSeries // Requests no route handled, such as 404s
+	mu        sync.Mutex   // Serializes registrations
+}
+
+// routeSeries are the metrics of one route
+type routeSeries struct {
+	requests *Counter
+	errors   *Counter // 5xx responses and errors returned by handlers
+	duration *Histogram
+}
+
+// routeKey identifies a route as fiber reports it on a request
+type routeKey struct {
+	method string
+	path   string
+}
+
+// routeTable indexes the series of the registered routes. It is replaced,
+// never modified, so requests read it without locking.
+type routeTable struct {
+	index  map[routeKey]int
+	series []*routeSeries
+}
+
+// NewRouteMetrics creates per-route metrics; Attach them to an app before
+// it starts listening
+func NewRouteMetrics(collector *Collector) *RouteMetrics {
+	r := &RouteMetrics{
+		collector: collector,
+		unmatched: newRouteSeries(collector, "unmatched", map[string]string{"route": "unmatched"}),
+	}
+	r.table.Store(&routeTable{index: map[routeKey]int{}})
+	return r
+}
+
+// Attach registers the series of the app's routes when it starts
+// listening, once every route has been added
+func (r *RouteMetrics) Attach(app *fiber.App) {
+	app.Hooks().OnListen(func(fiber.ListenData) error {
+		r.RegisterRoutes(app)
+		return nil
+	})
+}
+
+// RegisterRoutes registers the series of the routes added to app so far.
+// Middleware is skipped: it is added under every method and handles no
+// route itself. Attach calls it; call it directly for an app that is served
+// without listening, such as one driven through app.Test.
+func (r *RouteMetrics) RegisterRoutes(app *fiber.App) {
+	for _, route := range app.GetRoutes(true) {
+		if len(route.Handlers) == 0 {
+			continue
+		}
+		r.Register(route.Method, route.Path)
+	}
+}
+
+// Register registers the series of a route, as a method and the path it
+// was registered with, e.g. "/api/v1/users/:id". RegisterRoutes calls it
+// for every route; registering a route again is a no-op.
+func (r *RouteMetrics) Register(method, path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := routeKey{method: method, path: path}
+	current := r.table.Load()
+	if _, ok := current.index[key]; ok {
+		return
+	}
+
+	next := &routeTable{
+		index:  make(map[routeKey]int, len(current.index)+1),
+		series: make([]*routeSeries, len(current.series), len(current.series)+1),
+	}
+	for k, i := range current.index {
+		next.index[k] = i
+	}
+	copy(next.series, current.series)
+
+	labels := map[string]string{"method": method, "route": path}
+	next.index[key] = len(next.series)
+	next.series = append(next.series, newRouteSeries(r.collector, routeMetricName(method, path), labels))
+	r.table.Store(next)
+}
+
+// Middleware records each request against the series of the route that
+// handled it
+func (r *RouteMetrics) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		// After the chain, the context holds the last route that ran
+		series := r.unmatched
+		route := c.Route()
+		table := r.table.Load()
+		if i, ok := table.index[routeKey{method: route.Method, path: route.Path}]; ok {
+			series = table.series[i]
+		}
+
+		series.requests.Inc()
+		series.duration.Observe(time.Since(start).Seconds())
+		if serverError(c, err) {
+			series.errors.Inc()
+		}
+		return err
+	}
+}
+
+// serverError reports whether a request failed on the server's side. A
+// handler's error has not been turned into a response yet, so its status is
+// the error's.
+func serverError(c *fiber.Ctx, err error) bool {
+	if err == nil {
+		return c.Response().StatusCode() >= fiber.StatusInternalServerError
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code >= fiber.StatusInternalServerError
+	}
+	return true
+}
+
+// newRouteSeries registers the metrics of a route
+func newRouteSeries(collector *Collector, name string, labels map[string]string) *routeSeries {
+	route := labels["route"]
+	return &routeSeries{
+		requests: collector.NewCounter(
+			"http_route_requests_total_"+name,
+			"Number of requests to "+route,
+			labels,
+		),
+		errors: collector.NewCounter(
+			"http_route_errors_total_"+name,
+			"Number of 5xx responses from "+route,
+			labels,
+		),
+		duration: collector.NewHistogram(
+			"http_route_duration_seconds_"+name,
+			"Duration of requests to "+route+" in seconds",
+			labels,
+			routeBuckets,
+		),
+	}
+}
+
+// routeMetricName turns a route into a metric name fragment, e.g.
+// "get_api_v1_users_id"
+func routeMetricName(method, path string) string {
+	parts := strings.FieldsFunc(strings.ToLower(method+"/"+path), func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+	return strings.Join(parts, "_")
+}
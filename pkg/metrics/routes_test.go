@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+func newRouteTestApp(routeMetrics *RouteMetrics) *fiber.App {
+	app := fiber.New()
+	app.Use(routeMetrics.Middleware())
+	app.Get("/users/:id", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+	app.Post("/users", func(c *fiber.Ctx) error { return fiber.ErrBadGateway })
+	routeMetrics.RegisterRoutes(app)
+	return app
+}
+
+func TestRouteMetricsSkipsMiddleware(t *testing.T) {
+	collector := NewCollector(CollectorConfig{})
+	routeMetrics := NewRouteMetrics(collector)
+	newRouteTestApp(routeMetrics)
+
+	table := routeMetrics.table.Load()
+	// fiber adds a HEAD route with each GET route
+	if len(table.series) != 3 {
+		t.Fatalf("registered %d routes %v, want 3", len(table.series), table.index)
+	}
+	for _, key := range []routeKey{{fiber.MethodGet, "/users/:id"}, {fiber.MethodHead, "/users/:id"}, {fiber.MethodPost, "/users"}} {
+		if _, ok := table.index[key]; !ok {
+			t.Errorf("route %v not registered", key)
+		}
+	}
+}
+
+func TestRouteMetricsMiddleware(t *testing.T) {
+	collector := NewCollector(CollectorConfig{})
+	routeMetrics := NewRouteMetrics(collector)
+	app := newRouteTestApp(routeMetrics)
+
+	for _, req := range []struct{ method, path string }{
+		{fiber.MethodGet, "/users/1"},
+		{fiber.MethodGet, "/users/2"},
+		{fiber.MethodPost, "/users"},
+		{fiber.MethodGet, "/missing"},
+	} {
+		if _, err := app.Test(httptest.NewRequest(req.method, req.path, nil)); err != nil {
+			t.Fatalf("request: %v", err)
+		}
+	}
+
+	table := routeMetrics.table.Load()
+	get := table.series[table.index[routeKey{fiber.MethodGet, "/users/:id"}]]
+	post := table.series[table.index[routeKey{fiber.MethodPost, "/users"}]]
+	if got := get.requests.Get(); got != 2 {
+		t.Errorf("GET /users/:id requests = %d, want 2", got)
+	}
+	if got := get.errors.Get(); got != 0 {
+		t.Errorf("GET /users/:id errors = %d, want 0", got)
+	}
+	if got := post.errors.Get(); got != 1 {
+		t.Errorf("POST /users errors = %d, want 1", got)
+	}
+	if got := routeMetrics.unmatched.requests.Get(); got != 1 {
+		t.Errorf("unmatched requests = %d, want 1", got)
+	}
+}
+
+func BenchmarkRouteMetricsMiddleware(b *testing.B) {
+	routeMetrics := NewRouteMetrics(NewCollector(CollectorConfig{}))
+	handler := newRouteTestApp(routeMetrics).Handler()
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fiber.MethodGet)
+	ctx.Request.SetRequestURI("/users/1")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler(ctx)
+	}
+}
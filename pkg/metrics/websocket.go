@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"sync"
+
+	"neonexcore/pkg/websocket"
+)
+
+// MaxRoomLabels is how many rooms InstrumentHub labels by name when no
+// rooms are listed. Clients name rooms, so the rest share OtherRoom.
+const MaxRoomLabels = 100
+
+// OtherRoom is the room label of rooms not listed, or past MaxRoomLabels
+const OtherRoom = "other"
+
+// InstrumentHub records the hub's broadcasts: latency until every fan-out
+// worker has queued a message, messages delivered and dropped, slow clients
+// and open connections. Rooms are a room label of the
+// websocket_room_messages_total and _delivered_total counters and
+// _members and _users gauges: the listed rooms, or without a list the
+// first MaxRoomLabels rooms seen. Other rooms count as OtherRoom, without
+// gauges.
+func InstrumentHub(collector *Collector, hub *websocket.Hub, rooms ...string) {
+	latency := collector.NewHistogram(
+		"websocket_broadcast_latency_seconds",
+		"Time from a broadcast until every fan-out worker queued it",
+		nil,
+		[]float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1},
+	)
+	broadcasts := collector.NewCounter(
+		"websocket_broadcasts_total",
+		"Number of WebSocket broadcasts",
+		nil,
+	)
+	delivered := collector.NewCounter(
+		"websocket_messages_delivered_total",
+		"Broadcast messages queued in a connection's send buffer",
+		nil,
+	)
+	dropped := collector.NewCounter(
+		"websocket_messages_dropped_total",
+		"Broadcast messages dropped because a connection's send buffer was full",
+		nil,
+	)
+	slowClients := collector.NewCounter(
+		"websocket_slow_clients_total",
+		"Connections found too slow to keep up with broadcasts",
+		nil,
+	)
+	slowDisconnects := collector.NewCounter(
+		"websocket_slow_disconnects_total",
+		"Slow connections closed by the slow client policy",
+		nil,
+	)
+	connections := collector.NewGauge(
+		"websocket_connections",
+		"Number of open WebSocket connections",
+		nil,
+	)
+
+	labels := newRoomLabels(rooms)
+
+	hub.OnBroadcast(func(result websocket.BroadcastResult) {
+		latency.Observe(result.Latency.Seconds())
+		broadcasts.Inc()
+		delivered.Add(uint64(result.Delivered))
+		dropped.Add(uint64(result.Dropped))
+		connections.Set(int64(hub.ConnectionCount()))
+		if result.Room != "" {
+			room := map[string]string{"room": labels.label(result.Room)}
+			collector.NewLabeledCounter("websocket_room_messages_total", "Messages broadcast to a room", room).Inc()
+			collector.NewLabeledCounter("websocket_room_delivered_total", "Room messages queued in a connection's send buffer", room).Add(uint64(result.Delivered))
+		}
+	})
+	hub.OnPresence(func(event websocket.PresenceEvent) {
+		// Members of different rooms do not add up
+		name := labels.label(event.Room)
+		if name == OtherRoom {
+			return
+		}
+		room := map[string]string{"room": name}
+		collector.NewLabeledGauge("websocket_room_members", "Connections in a room", room).Set(int64(event.Members))
+		collector.NewLabeledGauge("websocket_room_users", "Users online in a room", room).Set(int64(event.Users))
+	})
+	hub.OnSlowClient(func(conn *websocket.Connection, policy websocket.SlowClientPolicy) {
+		slowClients.Inc()
+		if policy == websocket.SlowClientDisconnect {
+			slowDisconnects.Inc()
+		}
+	})
+}
+
+// roomLabels bounds the room names used as labels
+type roomLabels struct {
+	listed bool
+	rooms  map[string]struct{}
+	mu     sync.RWMutex
+}
+
+func newRoomLabels(rooms []string) *roomLabels {
+	l := &roomLabels{listed: len(rooms) > 0, rooms: make(map[string]struct{}, len(rooms))}
+	for _, room := range rooms {
+		l.rooms[room] = struct{}{}
+	}
+	return l
+}
+
+// label returns a room's label: its name if listed, or among the first
+// MaxRoomLabels seen without a list, and OtherRoom otherwise
+func (l *roomLabels) label(room string) string {
+	l.mu.RLock()
+	_, ok := l.rooms[room]
+	l.mu.RUnlock()
+	if ok {
+		return room
+	}
+	if l.listed {
+		return OtherRoom
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.rooms[room]; ok {
+		return room
+	}
+	if len(l.rooms) >= MaxRoomLabels {
+		return OtherRoom
+	}
+	l.rooms[room] = struct{}{}
+	return room
+}
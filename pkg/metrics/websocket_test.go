@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRoomLabelsAreBounded(t *testing.T) {
+	labels := newRoomLabels(nil)
+	for i := 0; i < MaxRoomLabels; i++ {
+		room := fmt.Sprintf("room-%d", i)
+		if got := labels.label(room); got != room {
+			t.Fatalf("label(%q) = %q, want the room", room, got)
+		}
+	}
+	if got := labels.label("one-too-many"); got != OtherRoom {
+		t.Errorf("label past the limit = %q, want %q", got, OtherRoom)
+	}
+	if got := labels.label("room-0"); got != "room-0" {
+		t.Errorf("label of a seen room = %q, want room-0", got)
+	}
+
+	listed := newRoomLabels([]string{"lobby"})
+	if got := listed.label("lobby"); got != "lobby" {
+		t.Errorf("label of a listed room = %q, want lobby", got)
+	}
+	if got := listed.label("random"); got != OtherRoom {
+		t.Errorf("label of an unlisted room = %q, want %q", got, OtherRoom)
+	}
+}
+
+func TestLabeledCountersShareAFamily(t *testing.T) {
+	collector := NewCollector(CollectorConfig{})
+	collector.NewLabeledCounter("websocket_room_messages_total", "Messages broadcast to a room", map[string]string{"room": "lobby"}).Add(2)
+	collector.NewLabeledCounter("websocket_room_messages_total", "Messages broadcast to a room", map[string]string{"room": "other"}).Inc()
+	collector.NewLabeledCounter("websocket_room_messages_total", "Messages broadcast to a room", map[string]string{"room": "lobby"}).Inc()
+
+	var out strings.Builder
+	if err := WritePrometheus(&out, collector.GetAllMetrics()); err != nil {
+		t.Fatalf("WritePrometheus: %v", err)
+	}
+	want := "# HELP websocket_room_messages_total Messages broadcast to a room\n" +
+		"# TYPE websocket_room_messages_total counter\n" +
+		"websocket_room_messages_total{room=\"lobby\"} 3\n" +
+		"websocket_room_messages_total{room=\"other\"} 1\n"
+	if out.String() != want {
+		t.Errorf("WritePrometheus =\n%s\nwant\n%s", out.String(), want)
+	}
+}
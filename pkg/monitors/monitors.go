@@ -0,0 +1,438 @@
+// Package monitors probes external endpoints the app depends on, such as
+// a payment API, RPC nodes or model providers, on an interval. Each probe
+// records availability and latency as metrics; targets become health
+// checks, so the status page shows their uptime, and dashboard alerts that
+// fire when a target fails several checks in a row.
+package monitors
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/events"
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/probe"
+	"neonexcore/pkg/scheduler"
+)
+
+// Monitor event names
+const (
+	EventDown      = "monitor.down"
+	EventRecovered = "monitor.recovered"
+)
+
+// Target is an endpoint to probe. Kinds and addresses are those of
+// probe.Dependency; HTTP targets pass with any response below 500.
+type Target struct {
+	Name     string
+	Kind     string
+	Address  string
+	Interval time.Duration // Overrides Config.Interval
+	Timeout  time.Duration // Overrides Config.Timeout
+}
+
+// Config configures the monitors
+type Config struct {
+	Targets          []Target
+	Interval         time.Duration // Between probes of a target (default 30s)
+	Timeout          time.Duration // Limit on each probe (default 5s)
+	FailureThreshold int           // Failures in a row before a target is down and alerts (default 3)
+	SlowThreshold    time.Duration // Latency above which a target is degraded (default 2s)
+}
+
+// DefaultConfig returns the default configuration, with no targets
+func DefaultConfig() Config {
+	return Config{
+		Interval:         30 * time.Second,
+		Timeout:          5 * time.Second,
+		FailureThreshold: 3,
+		SlowThreshold:    2 * time.Second,
+	}
+}
+
+// LoadConfig loads targets from MONITOR_TARGETS, a comma separated list of
+// URLs such as "payments=https://api.example.com/health,rpc=http://node:8545",
+// each optionally named and with ?interval= and ?timeout= of its own.
+// MONITOR_INTERVAL, MONITOR_TIMEOUT, MONITOR_FAILURE_THRESHOLD and
+// MONITOR_SLOW_THRESHOLD set the defaults.
+func LoadConfig() (Config, error) {
+	config := DefaultConfig()
+
+	for key, target := range map[string]*time.Duration{
+		"MONITOR_INTERVAL":       &config.Interval,
+		"MONITOR_TIMEOUT":        &config.Timeout,
+		"MONITOR_SLOW_THRESHOLD": &config.SlowThreshold,
+	} {
+		if value := os.Getenv(key); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return config, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			*target = d
+		}
+	}
+	if value := os.Getenv("MONITOR_FAILURE_THRESHOLD"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return config, fmt.Errorf("invalid MONITOR_FAILURE_THRESHOLD %q", value)
+		}
+		config.FailureThreshold = n
+	}
+
+	for _, entry := range strings.Split(os.Getenv("MONITOR_TARGETS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		target, err := ParseTarget(entry)
+		if err != nil {
+			return config, err
+		}
+		config.Targets = append(config.Targets, target)
+	}
+	return config, nil
+}
+
+// ParseTarget parses "[name=]scheme://host[:port][/path][?interval=1m&timeout=10s]"
+func ParseTarget(entry string) (Target, error) {
+	var interval time.Duration
+	if name, rest, found := strings.Cut(entry, "="); found && !strings.Contains(name, "://") {
+		entry = name + "=" + stripInterval(rest, &interval)
+	} else {
+		entry = stripInterval(entry, &interval)
+	}
+	if interval < 0 {
+		return Target{}, fmt.Errorf("invalid interval in monitor target %q", entry)
+	}
+
+	dep, err := probe.ParseDependency(entry)
+	if err != nil {
+		return Target{}, err
+	}
+	return Target{
+		Name:     dep.Name,
+		Kind:     dep.Kind,
+		Address:  dep.Address,
+		Interval: interval,
+		Timeout:  dep.Timeout,
+	}, nil
+}
+
+// stripInterval removes ?interval= from a URL, storing it in interval
+// (negative when invalid)
+func stripInterval(rawURL string, interval *time.Duration) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || !u.Query().Has("interval") {
+		return rawURL
+	}
+	query := u.Query()
+	d, err := time.ParseDuration(query.Get("interval"))
+	if err != nil || d <= 0 {
+		d = -1
+	}
+	*interval = d
+	query.Del("interval")
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Status is a target's latest probe and its record since the app started
+type Status struct {
+	Name                string     `json:"name"`
+	Kind                string     `json:"kind"`
+	Address             string     `json:"address"`
+	State               string     `json:"state"` // healthy, degraded, unhealthy; empty before the first probe
+	Up                  bool       `json:"up"`
+	Latency             float64    `json:"latency_ms"`
+	LastCheck           *time.Time `json:"last_check,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Checks              int64      `json:"checks"`
+	Failures            int64      `json:"failures"`
+	Availability        float64    `json:"availability"` // Percent of checks that passed
+}
+
+// Metrics records the availability and latency of checks.
+// metrics.Hooks implements it for a collector.
+type Metrics interface {
+	AddCounter(name, description string, labels map[string]string, delta uint64)
+	SetGauge(name, description string, labels map[string]string, value int64)
+	Observe(name, description string, labels map[string]string, buckets []float64, value float64)
+}
+
+// Alerts registers alerts on metrics. metrics.Hooks implements it for a
+// dashboard.
+type Alerts interface {
+	SetAlert(name, description, metric string, threshold float64, metadata map[string]interface{})
+}
+
+// Monitor probes targets in the background between Start and Stop
+type Monitor struct {
+	config    Config
+	metrics   Metrics
+	scheduler *scheduler.Scheduler
+	check     func(ctx context.Context, dep probe.Dependency, timeout time.Duration) error
+
+	mu       sync.RWMutex
+	statuses map[string]*Status
+}
+
+// New creates a monitor for the configured targets. metrics may be nil.
+func New(config Config, metrics Metrics) (*Monitor, error) {
+	defaults := DefaultConfig()
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.FailureThreshold < 1 {
+		config.FailureThreshold = defaults.FailureThreshold
+	}
+	if config.SlowThreshold <= 0 {
+		config.SlowThreshold = defaults.SlowThreshold
+	}
+
+	m := &Monitor{
+		config:    config,
+		metrics:   metrics,
+		scheduler: scheduler.New(),
+		check:     probe.Check,
+		statuses:  make(map[string]*Status),
+	}
+	for _, target := range config.Targets {
+		if target.Name == "" || target.Address == "" {
+			return nil, fmt.Errorf("monitors: target needs a name and an address")
+		}
+		if _, exists := m.statuses[target.Name]; exists {
+			return nil, fmt.Errorf("monitors: duplicate target %s", target.Name)
+		}
+		m.statuses[target.Name] = &Status{Name: target.Name, Kind: target.Kind, Address: displayAddress(target.Address)}
+
+		interval := config.Interval
+		if target.Interval > 0 {
+			interval = target.Interval
+		}
+		if err := m.scheduler.Add(scheduler.Job{
+			Name:       "monitor:" + target.Name,
+			Interval:   interval,
+			RunAtStart: true,
+			Run: func(ctx context.Context) error {
+				m.Probe(ctx, target)
+				return nil
+			},
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Start probes every target now and then on its interval
+func (m *Monitor) Start() {
+	m.scheduler.Start()
+}
+
+// Stop ends probing, waiting for probes in progress
+func (m *Monitor) Stop() {
+	m.scheduler.Stop()
+}
+
+// Probe checks a target once and records the result
+func (m *Monitor) Probe(ctx context.Context, target Target) Status {
+	timeout := m.config.Timeout
+	if target.Timeout > 0 {
+		timeout = target.Timeout
+	}
+	start := time.Now()
+	err := m.check(ctx, probe.Dependency{Name: target.Name, Kind: target.Kind, Address: target.Address}, timeout)
+	latency := time.Since(start)
+
+	m.mu.Lock()
+	status, ok := m.statuses[target.Name]
+	if !ok {
+		status = &Status{Name: target.Name, Kind: target.Kind, Address: displayAddress(target.Address)}
+		m.statuses[target.Name] = status
+	}
+	wasDown := status.ConsecutiveFailures >= m.config.FailureThreshold
+	status.LastCheck = &start
+	status.Latency = float64(latency.Microseconds()) / 1000
+	status.Checks++
+	status.Up = err == nil
+	if err == nil {
+		status.ConsecutiveFailures = 0
+		status.LastError = ""
+		status.LastSuccess = &start
+		status.State = string(api.HealthStatusHealthy)
+		if latency > m.config.SlowThreshold {
+			status.State = string(api.HealthStatusDegraded)
+		}
+	} else {
+		status.ConsecutiveFailures++
+		status.Failures++
+		status.LastError = err.Error()
+		// Failures below the threshold may be blips
+		status.State = string(api.HealthStatusDegraded)
+		if status.ConsecutiveFailures >= m.config.FailureThreshold {
+			status.State = string(api.HealthStatusUnhealthy)
+		}
+	}
+	status.Availability = float64(status.Checks-status.Failures) / float64(status.Checks) * 100
+	isDown := status.ConsecutiveFailures >= m.config.FailureThreshold
+	result := *status
+	m.mu.Unlock()
+
+	m.record(target, result, latency)
+
+	fields := logger.Fields{"monitor": target.Name, "address": result.Address, "failures": result.ConsecutiveFailures}
+	switch {
+	case isDown && !wasDown:
+		fields["error"] = result.LastError
+		logger.Error("Monitored endpoint is down", fields)
+		m.dispatch(ctx, EventDown, result)
+	case wasDown && !isDown:
+		logger.Info("Monitored endpoint recovered", fields)
+		m.dispatch(ctx, EventRecovered, result)
+	case err != nil:
+		fields["error"] = result.LastError
+		logger.Warn("Monitored endpoint check failed", fields)
+	}
+	return result
+}
+
+// record updates a target's metrics
+func (m *Monitor) record(target Target, status Status, latency time.Duration) {
+	if m.metrics == nil {
+		return
+	}
+	name := MetricPrefix(target.Name)
+	labels := map[string]string{"target": target.Name, "kind": target.Kind}
+
+	up := int64(0)
+	if status.Up {
+		up = 1
+	}
+	m.metrics.SetGauge(name+"_up", target.Name+" answered its latest check", labels, up)
+	m.metrics.SetGauge(name+"_consecutive_failures", "Checks of "+target.Name+" failed in a row", labels, int64(status.ConsecutiveFailures))
+	m.metrics.SetGauge(name+"_latency_ms", "Latency of the latest check of "+target.Name, labels, latency.Milliseconds())
+	m.metrics.Observe(name+"_latency_seconds", "Latency of checks of "+target.Name, labels, nil, latency.Seconds())
+	m.metrics.AddCounter(name+"_checks_total", "Checks of "+target.Name, labels, 1)
+	if !status.Up {
+		m.metrics.AddCounter(name+"_failures_total", "Failed checks of "+target.Name, labels, 1)
+	}
+}
+
+func (m *Monitor) dispatch(ctx context.Context, name string, status Status) {
+	events.DispatchAsync(ctx, events.Event{
+		Name: name,
+		Data: map[string]interface{}{
+			"monitor":              status.Name,
+			"address":              status.Address,
+			"consecutive_failures": status.ConsecutiveFailures,
+			"error":                status.LastError,
+			"last_success":         status.LastSuccess,
+		},
+	})
+}
+
+// Statuses returns every target's status, by name
+func (m *Monitor) Statuses() []Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(m.statuses))
+	for _, status := range m.statuses {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// Status returns a target's status
+func (m *Monitor) Status(name string) (Status, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status, ok := m.statuses[name]
+	if !ok {
+		return Status{}, false
+	}
+	return *status, true
+}
+
+// RegisterHealthChecks adds a health check per target reporting its
+// latest probe, so the status page samples its uptime. Checks do not
+// probe themselves.
+func (m *Monitor) RegisterHealthChecks(checker *api.HealthChecker) {
+	for _, target := range m.config.Targets {
+		name := target.Name
+		checker.RegisterCheck(name, func() api.CheckResult {
+			status, _ := m.Status(name)
+			if status.State == "" {
+				return api.CheckResult{Status: api.HealthStatusHealthy, Message: "Not checked yet"}
+			}
+			result := api.CheckResult{
+				Status:  api.HealthStatus(status.State),
+				Details: map[string]interface{}{"latency_ms": status.Latency, "availability": status.Availability},
+			}
+			switch {
+			case !status.Up:
+				result.Message = fmt.Sprintf("%d failed checks in a row: %s", status.ConsecutiveFailures, status.LastError)
+			case result.Status == api.HealthStatusDegraded:
+				result.Message = fmt.Sprintf("Slow: %.0fms", status.Latency)
+			}
+			return result
+		})
+	}
+}
+
+// RegisterAlerts adds an alert per target that fires while it has failed
+// FailureThreshold checks in a row. Alerts carry a "high" severity and the
+// target's metrics, for the incidents module.
+func (m *Monitor) RegisterAlerts(alerts Alerts) {
+	for _, target := range m.config.Targets {
+		name := MetricPrefix(target.Name)
+		alerts.SetAlert("monitor:"+target.Name,
+			fmt.Sprintf("%s failed %d checks in a row", target.Name, m.config.FailureThreshold),
+			name+"_consecutive_failures",
+			float64(m.config.FailureThreshold-1),
+			map[string]interface{}{
+				"severity":        "high",
+				"related_metrics": name + "_*",
+			})
+	}
+}
+
+// MetricPrefix returns the prefix of a target's metric names, e.g.
+// "monitor_payments"
+func MetricPrefix(name string) string {
+	var b strings.Builder
+	b.WriteString("monitor_")
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// displayAddress hides the password of a URL with credentials
+func displayAddress(address string) string {
+	if u, err := url.Parse(address); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return address
+}
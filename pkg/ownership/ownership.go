@@ -0,0 +1,163 @@
+package ownership
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"neonexcore/pkg/tenancy"
+)
+
+// Tag declares the fields holding who a record belongs to: `owner:"user"`
+// for the ID of the user owning it, `owner:"tenant"` for its tenant's ID.
+// Records of models with such fields are only visible to, and only
+// written for, the principal of the context.
+const Tag = "owner"
+
+// Kinds of owner fields
+const (
+	KindUser   = "user"
+	KindTenant = "tenant"
+)
+
+var (
+	// ErrNoPrincipal is returned for statements on owned models run without
+	// a principal, e.g. from background jobs, unless the context is
+	// Privileged
+	ErrNoPrincipal = errors.New("ownership: no principal in context")
+	// ErrForbidden is returned for writes giving a record to another user
+	// or tenant than the principal's
+	ErrForbidden = errors.New("ownership: record belongs to another principal")
+)
+
+// Owned is embedded in models owned by a user
+type Owned struct {
+	OwnerID uint `gorm:"index;not null" json:"owner_id" owner:"user"`
+}
+
+// Tenanted is embedded in models owned by a tenant
+type Tenanted struct {
+	TenantID string `gorm:"size:100;index;not null" json:"tenant_id" owner:"tenant"`
+}
+
+// Principal is who a request or job acts for
+type Principal struct {
+	UserID   uint
+	TenantID string
+}
+
+type principalKey struct{}
+type privilegedKey struct{}
+
+// WithPrincipal returns a context acting for principal
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// WithUser returns a context acting for a user, keeping the tenant of ctx
+func WithUser(ctx context.Context, userID uint) context.Context {
+	principal, _ := ctx.Value(principalKey{}).(Principal)
+	principal.UserID = userID
+	return WithPrincipal(ctx, principal)
+}
+
+// FromContext returns the principal of ctx. Without a tenant of its own it
+// has the tenant resolved by the tenancy middleware, if any.
+func FromContext(ctx context.Context) Principal {
+	principal, _ := ctx.Value(principalKey{}).(Principal)
+	if principal.TenantID == "" {
+		if tenant, err := tenancy.GetTenant(ctx); err == nil {
+			principal.TenantID = tenant.ID
+		}
+	}
+	return principal
+}
+
+// Privileged returns a context whose statements are not scoped to a
+// principal, for admin tools, migrations and jobs working across users
+func Privileged(ctx context.Context) context.Context {
+	return context.WithValue(ctx, privilegedKey{}, true)
+}
+
+// IsPrivileged reports whether ctx was returned by Privileged
+func IsPrivileged(ctx context.Context) bool {
+	privileged, _ := ctx.Value(privilegedKey{}).(bool)
+	return privileged
+}
+
+// value returns the principal's ID for a kind of owner field
+func (p Principal) value(kind string) (interface{}, error) {
+	switch kind {
+	case KindUser:
+		if p.UserID == 0 {
+			return nil, ErrNoPrincipal
+		}
+		return p.UserID, nil
+	case KindTenant:
+		if p.TenantID == "" {
+			return nil, ErrNoPrincipal
+		}
+		return p.TenantID, nil
+	default:
+		return nil, fmt.Errorf("ownership: unknown owner kind %q", kind)
+	}
+}
+
+// Authorize checks that a record belongs to the principal of ctx, e.g. one
+// loaded with a Privileged context before acting on it for a user
+func Authorize(ctx context.Context, record interface{}) error {
+	if IsPrivileged(ctx) {
+		return nil
+	}
+	rv := reflect.Indirect(reflect.ValueOf(record))
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("ownership: cannot authorize %T", record)
+	}
+
+	principal := FromContext(ctx)
+	var err error
+	walkFields(rv, func(kind string, field reflect.Value) {
+		if err != nil {
+			return
+		}
+		want, e := principal.value(kind)
+		if e != nil {
+			err = e
+			return
+		}
+		if !sameOwner(field.Interface(), want) {
+			err = ErrForbidden
+		}
+	})
+	return err
+}
+
+// walkFields calls fn with the owner fields of a struct, including those
+// of embedded structs
+func walkFields(rv reflect.Value, fn func(kind string, field reflect.Value)) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if kind := field.Tag.Get(Tag); kind != "" {
+			fn(kind, rv.Field(i))
+			continue
+		}
+		if field.Anonymous {
+			if embedded := reflect.Indirect(rv.Field(i)); embedded.Kind() == reflect.Struct {
+				walkFields(embedded, fn)
+			}
+		}
+	}
+}
+
+// sameOwner compares owner IDs of any integer or string type
+func sameOwner(value, want interface{}) bool {
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return false
+		}
+		value = rv.Elem().Interface()
+	}
+	return fmt.Sprint(value) == fmt.Sprint(want)
+}
@@ -0,0 +1,284 @@
+package ownership
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Plugin is a GORM plugin scoping the statements on models with owner
+// fields to the principal of their context (db.WithContext(ctx)).
+// Repositories need no changes:
+//
+//   - Queries, counts, updates and deletes only match the principal's rows;
+//     other rows look like they do not exist
+//   - Creates and saves fill zero owner fields with the principal's IDs and
+//     refuse records given to anyone else
+//   - Upserts only update conflicting rows of the principal; on MySQL, which
+//     cannot condition them, conflicting rows are left as they are
+//
+// Statements without a principal fail with ErrNoPrincipal, unless their
+// context is Privileged. Raw SQL, joined tables and models without owner
+// fields are not scoped.
+type Plugin struct{}
+
+// NewPlugin creates the plugin
+func NewPlugin() *Plugin {
+	return &Plugin{}
+}
+
+// Name implements gorm.Plugin
+func (p *Plugin) Name() string {
+	return "ownership:scope"
+}
+
+// Initialize implements gorm.Plugin
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("gorm:create").Register("ownership:assign", p.assign),
+		callbacks.Query().Before("gorm:query").Register("ownership:scope", p.scope),
+		callbacks.Update().Before("gorm:update").Register("ownership:scope", p.update),
+		callbacks.Delete().Before("gorm:delete").Register("ownership:scope", p.delete),
+		callbacks.Row().Before("gorm:row").Register("ownership:scope", p.scope),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// owner is an owner field of a model with the principal's ID for it
+type owner struct {
+	field *schema.Field
+	value interface{}
+}
+
+// owners returns the owner fields of a statement's model with the
+// principal's IDs. ok is false for models without owner fields and
+// Privileged contexts, and after errors.
+func (p *Plugin) owners(db *gorm.DB) (owners []owner, ok bool) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil, false
+	}
+	ctx := db.Statement.Context
+	var principal *Principal
+	for _, field := range db.Statement.Schema.Fields {
+		kind := field.Tag.Get(Tag)
+		if kind == "" {
+			continue
+		}
+		if IsPrivileged(ctx) {
+			return nil, false
+		}
+		if principal == nil {
+			p := FromContext(ctx)
+			principal = &p
+		}
+		value, err := principal.value(kind)
+		if err != nil {
+			db.AddError(fmt.Errorf("%s.%s: %w", db.Statement.Schema.Name, field.Name, err))
+			return nil, false
+		}
+		owners = append(owners, owner{field: field, value: value})
+	}
+	return owners, len(owners) > 0
+}
+
+// conditions returns the conditions matching the principal's rows
+func conditions(owners []owner) []clause.Expression {
+	exprs := make([]clause.Expression, 0, len(owners))
+	for _, o := range owners {
+		exprs = append(exprs, clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: o.field.DBName},
+			Value:  o.value,
+		})
+	}
+	return exprs
+}
+
+// scope restricts a query to the principal's rows
+func (p *Plugin) scope(db *gorm.DB) {
+	if owners, ok := p.owners(db); ok {
+		restrict(db, owners)
+	}
+}
+
+// restrict adds the principal's conditions to a statement's own. These are
+// grouped, so scoping cannot be escaped with an OR.
+func restrict(db *gorm.DB, owners []owner) {
+	exprs := conditions(owners)
+	if c, ok := db.Statement.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+			exprs = append([]clause.Expression{grouped(where)}, exprs...)
+		}
+		c.Expression = clause.Where{Exprs: exprs}
+		db.Statement.Clauses["WHERE"] = c
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: exprs})
+}
+
+// delete restricts a delete to the principal's rows. Deletes without
+// conditions of their own are left to GORM to refuse, rather than turned
+// into deletes of every row of the principal.
+func (p *Plugin) delete(db *gorm.DB) {
+	if owners, ok := p.owners(db); ok && conditioned(db) {
+		restrict(db, owners)
+	}
+}
+
+// update restricts an update to the principal's rows, after checking it
+// gives no record away. Like deletes, updates without conditions of their
+// own are left to GORM to refuse.
+func (p *Plugin) update(db *gorm.DB) {
+	owners, ok := p.owners(db)
+	if !ok {
+		return
+	}
+	if err := claim(db, owners, db.Statement.ReflectValue); err != nil {
+		db.AddError(err)
+		return
+	}
+	if err := check(db, owners, reflect.Indirect(reflect.ValueOf(db.Statement.Dest))); err != nil {
+		db.AddError(err)
+		return
+	}
+	if conditioned(db) {
+		restrict(db, owners)
+	}
+}
+
+// assign fills the owner fields of created records, and restricts upserts
+// to the principal's rows
+func (p *Plugin) assign(db *gorm.DB) {
+	owners, ok := p.owners(db)
+	if !ok {
+		return
+	}
+	if err := claim(db, owners, db.Statement.ReflectValue); err != nil {
+		db.AddError(err)
+		return
+	}
+
+	c, ok := db.Statement.Clauses["ON CONFLICT"]
+	if !ok {
+		return
+	}
+	onConflict, ok := c.Expression.(clause.OnConflict)
+	if !ok || onConflict.DoNothing || (!onConflict.UpdateAll && len(onConflict.DoUpdates) == 0) {
+		return
+	}
+	if db.Dialector.Name() == "mysql" {
+		onConflict.UpdateAll, onConflict.DoUpdates, onConflict.DoNothing = false, nil, true
+	} else {
+		// The conflicting row goes by its table's name
+		for _, o := range owners {
+			onConflict.Where.Exprs = append(onConflict.Where.Exprs, clause.Eq{
+				Column: clause.Column{Table: db.Statement.Table, Name: o.field.DBName},
+				Value:  o.value,
+			})
+		}
+	}
+	db.Statement.AddClause(onConflict)
+}
+
+// claim fills zero owner fields of records with the principal's IDs, and
+// refuses records owned by anyone else
+func claim(db *gorm.DB, owners []owner, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := claim(db, owners, reflect.Indirect(rv.Index(i))); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		ctx := db.Statement.Context
+		for _, o := range owners {
+			value, zero := o.field.ValueOf(ctx, rv)
+			if zero {
+				if err := o.field.Set(ctx, rv, o.value); err != nil {
+					return err
+				}
+			} else if !sameOwner(value, o.value) {
+				return fmt.Errorf("%s.%s: %w", db.Statement.Schema.Name, o.field.Name, ErrForbidden)
+			}
+		}
+	case reflect.Map:
+		if values, ok := rv.Interface().(map[string]interface{}); ok {
+			for _, o := range owners {
+				if _, set := values[o.field.DBName]; !set {
+					if _, set := values[o.field.Name]; !set {
+						values[o.field.DBName] = o.value
+					}
+				}
+			}
+		}
+		return check(db, owners, rv)
+	}
+	return nil
+}
+
+// check refuses updated values owned by anyone else: a struct of another
+// model, or a map of columns or field names
+func check(db *gorm.DB, owners []owner, rv reflect.Value) error {
+	for _, o := range owners {
+		var value reflect.Value
+		switch rv.Kind() {
+		case reflect.Map:
+			if rv.Type().Key().Kind() != reflect.String {
+				return nil
+			}
+			for _, key := range []string{o.field.DBName, o.field.Name} {
+				if v := rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key())); v.IsValid() {
+					value = v
+				}
+			}
+		case reflect.Struct:
+			value = rv.FieldByName(o.field.Name)
+		}
+		if !value.IsValid() || value.IsZero() {
+			continue
+		}
+		if !sameOwner(value.Interface(), o.value) {
+			return fmt.Errorf("%s.%s: %w", db.Statement.Schema.Name, o.field.Name, ErrForbidden)
+		}
+	}
+	return nil
+}
+
+// conditioned reports whether an update or delete has conditions of its
+// own: a WHERE clause or the primary keys of its records
+func conditioned(db *gorm.DB) bool {
+	if db.AllowGlobalUpdate {
+		return true
+	}
+	if _, ok := db.Statement.Clauses["WHERE"]; ok {
+		return true
+	}
+	ctx := db.Statement.Context
+	for _, rv := range []reflect.Value{db.Statement.ReflectValue, reflect.Indirect(reflect.ValueOf(db.Statement.Model))} {
+		if !rv.IsValid() {
+			continue
+		}
+		if _, values := schema.GetIdentityFieldValuesMap(ctx, rv, db.Statement.Schema.PrimaryFields); len(values) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// grouped is a statement's own conditions in parentheses
+type grouped clause.Where
+
+// Build implements clause.Expression
+func (g grouped) Build(builder clause.Builder) {
+	builder.WriteByte('(')
+	clause.Where(g).Build(builder)
+	builder.WriteByte(')')
+}
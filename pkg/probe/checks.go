@@ -0,0 +1,139 @@
+package probe
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Check makes one attempt to reach a dependency. Besides connecting, it
+// checks that database and Redis servers answer their protocol, so a port
+// that is open before the server is accepting queries does not count.
+func Check(ctx context.Context, dep Dependency, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch dep.Kind {
+	case KindHTTP, KindHTTPS:
+		return checkHTTP(ctx, dep.Address)
+	case KindTCP, KindGRPC, KindPostgres, KindMySQL, KindRedis:
+	default:
+		return fmt.Errorf("unknown dependency kind %q", dep.Kind)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", dep.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	switch dep.Kind {
+	case KindPostgres:
+		return checkPostgres(conn)
+	case KindMySQL:
+		return checkMySQL(conn)
+	case KindRedis:
+		return checkRedis(conn)
+	}
+	return nil
+}
+
+// checkHTTP passes any response below 500
+func checkHTTP(ctx context.Context, address string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// checkPostgres sends an SSLRequest, which a ready server answers with
+// 'S' or 'N' before any authentication
+func checkPostgres(conn net.Conn) error {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], 80877103)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+	reply := make([]byte, 1)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("no reply to SSL request: %w", err)
+	}
+	switch reply[0] {
+	case 'S', 'N':
+		return nil
+	case 'E':
+		return fmt.Errorf("server refused the connection, it may still be starting")
+	default:
+		return fmt.Errorf("unexpected reply %q, not a Postgres server?", reply[0])
+	}
+}
+
+// checkMySQL reads the handshake a ready server sends on connect. Errors,
+// such as too many connections, come as an 0xff packet instead.
+func checkMySQL(conn net.Conn) error {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("no handshake: %w", err)
+	}
+	switch header[4] {
+	case 10, 9: // Protocol versions
+		return nil
+	case 0xff:
+		length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+		body := make([]byte, max(length-1, 0))
+		io.ReadFull(conn, body)
+		if len(body) > 2 {
+			body = body[2:] // Error code
+		}
+		if len(body) > 6 && body[0] == '#' {
+			body = body[6:] // SQL state
+		}
+		return fmt.Errorf("server error: %s", body)
+	default:
+		return fmt.Errorf("unexpected handshake, not a MySQL server?")
+	}
+}
+
+// checkRedis sends PING. Errors other than the server still loading its
+// data or syncing, e.g. NOAUTH, still mean it is up.
+func checkRedis(conn net.Conn) error {
+	if _, err := conn.Write([]byte("*1\r\n$4\r\nPING\r\n")); err != nil {
+		return err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("no reply to PING: %w", err)
+	}
+	line = strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(line, "+"):
+		return nil
+	case strings.HasPrefix(line, "-LOADING"), strings.HasPrefix(line, "-BUSY"), strings.HasPrefix(line, "-MASTERDOWN"):
+		return fmt.Errorf("%s", strings.TrimPrefix(line, "-"))
+	case strings.HasPrefix(line, "-"):
+		return nil
+	default:
+		return fmt.Errorf("unexpected reply %q, not a Redis server?", line)
+	}
+}
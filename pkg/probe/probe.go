@@ -0,0 +1,281 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/logger"
+)
+
+// ErrNotReady is returned when dependencies are still unreachable at
+// their deadline
+var ErrNotReady = errors.New("probe: dependencies not ready")
+
+// Kinds of dependency, named by the scheme of their target URL
+const (
+	KindTCP      = "tcp"
+	KindHTTP     = "http"
+	KindHTTPS    = "https"
+	KindPostgres = "postgres"
+	KindMySQL    = "mysql"
+	KindRedis    = "redis"
+	KindGRPC     = "grpc"
+)
+
+// Dependency is a service the app needs before it can start
+type Dependency struct {
+	Name    string        // Shown in logs; defaults to the kind
+	Kind    string        // One of the Kind constants
+	Address string        // host:port, or the URL for HTTP
+	Timeout time.Duration // Overrides Config.Timeout
+}
+
+// Config configures the wait for dependencies
+type Config struct {
+	Dependencies []Dependency
+	Timeout      time.Duration // How long to wait for each dependency (default 60s)
+	Interval     time.Duration // First delay between attempts, doubled after each (default 500ms)
+	MaxInterval  time.Duration // Longest delay between attempts (default 5s)
+	DialTimeout  time.Duration // Limit on each attempt (default 3s)
+}
+
+// DefaultConfig returns the default wait configuration, with no
+// dependencies
+func DefaultConfig() Config {
+	return Config{
+		Timeout:     60 * time.Second,
+		Interval:    500 * time.Millisecond,
+		MaxInterval: 5 * time.Second,
+		DialTimeout: 3 * time.Second,
+	}
+}
+
+// LoadConfig loads the dependencies from BOOT_WAIT_FOR, a comma separated
+// list of URLs such as "redis://cache:6379,http://rpc:8545/health", each
+// optionally prefixed with a name ("chain=http://rpc:8545") and with a
+// ?timeout= of its own. Unless BOOT_WAIT_DATABASE is false, a MySQL or
+// Postgres database configured by DB_DRIVER, DB_HOST and DB_PORT is added.
+// BOOT_WAIT_TIMEOUT, BOOT_WAIT_INTERVAL and BOOT_WAIT_MAX_INTERVAL set the
+// timing.
+func LoadConfig() (Config, error) {
+	config := DefaultConfig()
+
+	for key, target := range map[string]*time.Duration{
+		"BOOT_WAIT_TIMEOUT":      &config.Timeout,
+		"BOOT_WAIT_INTERVAL":     &config.Interval,
+		"BOOT_WAIT_MAX_INTERVAL": &config.MaxInterval,
+	} {
+		if value := os.Getenv(key); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return config, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			*target = d
+		}
+	}
+
+	if os.Getenv("BOOT_WAIT_DATABASE") != "false" {
+		if dep, ok := databaseDependency(); ok {
+			config.Dependencies = append(config.Dependencies, dep)
+		}
+	}
+
+	for _, entry := range strings.Split(os.Getenv("BOOT_WAIT_FOR"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		dep, err := ParseDependency(entry)
+		if err != nil {
+			return config, err
+		}
+		config.Dependencies = append(config.Dependencies, dep)
+	}
+	return config, nil
+}
+
+// ParseDependency parses "[name=]scheme://host:port[?timeout=30s]"
+func ParseDependency(entry string) (Dependency, error) {
+	var dep Dependency
+	if name, rest, found := strings.Cut(entry, "="); found && !strings.Contains(name, "://") {
+		dep.Name, entry = strings.TrimSpace(name), strings.TrimSpace(rest)
+	}
+
+	u, err := url.Parse(entry)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return dep, fmt.Errorf("invalid dependency %q, expected scheme://host:port", entry)
+	}
+	if timeout := u.Query().Get("timeout"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return dep, fmt.Errorf("invalid timeout for %s: %w", entry, err)
+		}
+		dep.Timeout = d
+		query := u.Query()
+		query.Del("timeout")
+		u.RawQuery = query.Encode()
+	}
+
+	dep.Kind = strings.ToLower(u.Scheme)
+	if dep.Kind == "postgresql" {
+		dep.Kind = KindPostgres
+	}
+	switch dep.Kind {
+	case KindHTTP, KindHTTPS:
+		dep.Address = u.String()
+	case KindTCP, KindPostgres, KindMySQL, KindRedis, KindGRPC, "rediss":
+		dep.Address = u.Host
+		if u.Port() == "" {
+			dep.Address = net.JoinHostPort(u.Hostname(), defaultPorts[dep.Kind])
+		}
+		if dep.Kind == "rediss" {
+			dep.Kind = KindTCP // PING would need TLS; reachability is enough
+		}
+	default:
+		return dep, fmt.Errorf("unknown dependency kind %q in %s", u.Scheme, entry)
+	}
+	if dep.Name == "" {
+		dep.Name = dep.Kind
+	}
+	return dep, nil
+}
+
+var defaultPorts = map[string]string{
+	KindPostgres: "5432",
+	KindMySQL:    "3306",
+	KindRedis:    "6379",
+	"rediss":     "6379",
+	KindGRPC:     "443",
+	KindTCP:      "80",
+}
+
+// databaseDependency returns the configured database server, if the
+// driver connects to one
+func databaseDependency() (Dependency, bool) {
+	kind := ""
+	switch os.Getenv("DB_DRIVER") {
+	case "mysql":
+		kind = KindMySQL
+	case "postgres", "postgresql":
+		kind = KindPostgres
+	default:
+		return Dependency{}, false
+	}
+	host, port := os.Getenv("DB_HOST"), os.Getenv("DB_PORT")
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = defaultPorts[kind]
+	}
+	return Dependency{Name: "database", Kind: kind, Address: net.JoinHostPort(host, port)}, true
+}
+
+// Wait checks every dependency until it is ready or its timeout passes,
+// retrying with exponential backoff and logging progress. Dependencies
+// are checked concurrently. The error lists the ones that never became
+// ready and matches ErrNotReady.
+func Wait(ctx context.Context, log logger.Logger, config Config) error {
+	defaults := DefaultConfig()
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.MaxInterval < config.Interval {
+		config.MaxInterval = max(defaults.MaxInterval, config.Interval)
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = defaults.DialTimeout
+	}
+
+	var wg sync.WaitGroup
+	failures := make([]error, len(config.Dependencies))
+	for i, dep := range config.Dependencies {
+		wg.Add(1)
+		go func(i int, dep Dependency) {
+			defer wg.Done()
+			failures[i] = waitFor(ctx, log, config, dep)
+		}(i, dep)
+	}
+	wg.Wait()
+
+	var messages []string
+	for _, err := range failures {
+		if err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("%w: %s", ErrNotReady, strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+func waitFor(ctx context.Context, log logger.Logger, config Config, dep Dependency) error {
+	timeout := config.Timeout
+	if dep.Timeout > 0 {
+		timeout = dep.Timeout
+	}
+
+	fields := logger.Fields{"dependency": dep.Name, "kind": dep.Kind, "address": displayAddress(dep.Address)}
+	start := time.Now()
+	deadline := start.Add(timeout)
+	interval := config.Interval
+	for attempt := 1; ; attempt++ {
+		err := Check(ctx, dep, config.DialTimeout)
+		if err == nil {
+			log.Info("Dependency ready", merge(fields, logger.Fields{
+				"attempts": attempt,
+				"waited":   time.Since(start).Round(time.Millisecond).String(),
+			}))
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			log.Error("Dependency not ready", merge(fields, logger.Fields{"attempts": attempt, "error": err.Error()}))
+			return fmt.Errorf("%s (%s) after %s: %v", dep.Name, displayAddress(dep.Address), timeout, err)
+		}
+		wait := min(interval, remaining)
+		log.Warn("Waiting for dependency", merge(fields, logger.Fields{
+			"attempt":  attempt,
+			"error":    err.Error(),
+			"retry_in": wait.Round(time.Millisecond).String(),
+		}))
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s (%s): %v", dep.Name, displayAddress(dep.Address), ctx.Err())
+		case <-time.After(wait):
+		}
+		interval = min(interval*2, config.MaxInterval)
+	}
+}
+
+// displayAddress hides the password of a URL with credentials
+func displayAddress(address string) string {
+	if u, err := url.Parse(address); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return address
+}
+
+func merge(a, b logger.Fields) logger.Fields {
+	merged := make(logger.Fields, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
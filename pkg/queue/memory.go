@@ -0,0 +1,146 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"neonexcore/pkg/logger"
+)
+
+// MemoryQueue is an embedded queue delivering messages within the process.
+// It needs no server, which suits development and tests; messages waiting
+// when the process exits are lost, and messages published to a topic
+// before a group subscribes are not delivered to it.
+type MemoryQueue struct {
+	config Config
+	groups map[string][]*memoryGroup // By topic
+	seq    atomic.Int64
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+}
+
+// memoryGroup holds a group's undelivered messages for one topic
+type memoryGroup struct {
+	name     string
+	messages chan *Message
+}
+
+// NewMemoryQueue creates an embedded queue
+func NewMemoryQueue(config Config) *MemoryQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &MemoryQueue{
+		config: withDefaults(config),
+		groups: make(map[string][]*memoryGroup),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Publish queues a message for every group subscribed to topic, waiting
+// while a group's buffer is full
+func (q *MemoryQueue) Publish(ctx context.Context, topic string, payload []byte) error {
+	q.mu.RLock()
+	groups := q.groups[topic]
+	q.mu.RUnlock()
+	if q.ctx.Err() != nil {
+		return ErrClosed
+	}
+
+	id := strconv.FormatInt(q.seq.Add(1), 10)
+	now := time.Now()
+	for _, group := range groups {
+		msg := &Message{ID: id, Topic: topic, Payload: payload, Attempt: 1, PublishedAt: now}
+		select {
+		case group.messages <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-q.ctx.Done():
+			return ErrClosed
+		}
+	}
+	return nil
+}
+
+// Subscribe starts Concurrency handlers for the group. Subscribing the
+// same group again adds handlers sharing its messages.
+func (q *MemoryQueue) Subscribe(topic, group string, handler Handler) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.ctx.Err() != nil {
+		return ErrClosed
+	}
+
+	var target *memoryGroup
+	for _, g := range q.groups[topic] {
+		if g.name == group {
+			target = g
+		}
+	}
+	if target == nil {
+		target = &memoryGroup{name: group, messages: make(chan *Message, q.config.BufferSize)}
+		q.groups[topic] = append(q.groups[topic], target)
+	}
+
+	for i := 0; i < q.config.Concurrency; i++ {
+		q.wg.Add(1)
+		go q.work(target, handler)
+	}
+	return nil
+}
+
+func (q *MemoryQueue) work(group *memoryGroup, handler Handler) {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case msg := <-group.messages:
+			err := handle(q.ctx, handler, msg)
+			if err == nil {
+				continue
+			}
+			if msg.Attempt >= q.config.MaxAttempts {
+				logger.Error("Queue message dropped after its last attempt", logger.Fields{
+					"topic": msg.Topic, "group": group.name, "id": msg.ID, "attempts": msg.Attempt, "error": err.Error(),
+				})
+				continue
+			}
+			logger.Warn("Queue message failed, retrying", logger.Fields{
+				"topic": msg.Topic, "group": group.name, "id": msg.ID, "attempt": msg.Attempt, "error": err.Error(),
+			})
+			retry := *msg
+			retry.Attempt++
+			q.redeliver(group, &retry, retryDelay(q.config, msg.Attempt))
+		}
+	}
+}
+
+// redeliver queues a message again after delay
+func (q *MemoryQueue) redeliver(group *memoryGroup, msg *Message, delay time.Duration) {
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		select {
+		case <-time.After(delay):
+		case <-q.ctx.Done():
+			return
+		}
+		select {
+		case group.messages <- msg:
+		case <-q.ctx.Done():
+		}
+	}()
+}
+
+// Close stops the handlers, waiting for those running to return.
+// Undelivered messages are dropped.
+func (q *MemoryQueue) Close() error {
+	q.cancel()
+	q.wg.Wait()
+	return nil
+}
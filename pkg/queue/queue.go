@@ -0,0 +1,154 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ErrClosed is returned when publishing to or subscribing on a closed queue
+var ErrClosed = errors.New("queue: closed")
+
+// Message is a published message as delivered to a handler
+type Message struct {
+	ID          string
+	Topic       string
+	Payload     []byte
+	Attempt     int // Delivery attempt, from 1
+	PublishedAt time.Time
+}
+
+// Handler processes a message. Returning an error redelivers the message
+// after a delay, until it has been attempted MaxAttempts times.
+type Handler func(ctx context.Context, msg *Message) error
+
+// Queue publishes messages to topics and delivers each one to every
+// subscribed group. Within a group, each message goes to one handler, so
+// instances of an app subscribing with the same group share the work.
+type Queue interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	Subscribe(topic, group string, handler Handler) error
+	Close() error
+}
+
+// Config configures a queue
+type Config struct {
+	Driver      string        // memory (default) or redis
+	RedisURL    string        // redis://[:password@]host:port[/db], for the redis driver
+	Prefix      string        // Prepended to Redis stream keys (default "queue:")
+	Concurrency int           // Handlers running at once per subscription (default 4)
+	MaxAttempts int           // Deliveries before a message is dropped (default 5)
+	RetryDelay  time.Duration // Delay before the first redelivery, doubled after each (default 1s)
+	BufferSize  int           // Messages waiting per subscription, for the memory driver (default 1024)
+	AckTimeout  time.Duration // Redis: how long a delivered message may go unacknowledged before another consumer takes it over (default 30s)
+	MaxLen      int64         // Redis: messages kept per stream, approximately (default 100000)
+}
+
+// DefaultConfig returns the default queue configuration
+func DefaultConfig() Config {
+	return Config{
+		Driver:      "memory",
+		RedisURL:    "redis://localhost:6379",
+		Prefix:      "queue:",
+		Concurrency: 4,
+		MaxAttempts: 5,
+		RetryDelay:  time.Second,
+		BufferSize:  1024,
+		AckTimeout:  30 * time.Second,
+		MaxLen:      100000,
+	}
+}
+
+// LoadConfig loads the queue configuration from QUEUE_DRIVER,
+// QUEUE_REDIS_URL (REDIS_URL when unset), QUEUE_PREFIX, QUEUE_CONCURRENCY,
+// QUEUE_MAX_ATTEMPTS, QUEUE_RETRY_DELAY and QUEUE_ACK_TIMEOUT
+func LoadConfig() Config {
+	config := DefaultConfig()
+
+	if driver := os.Getenv("QUEUE_DRIVER"); driver != "" {
+		config.Driver = driver
+	}
+	if url := os.Getenv("QUEUE_REDIS_URL"); url != "" {
+		config.RedisURL = url
+	} else if url := os.Getenv("REDIS_URL"); url != "" {
+		config.RedisURL = url
+	}
+	if prefix := os.Getenv("QUEUE_PREFIX"); prefix != "" {
+		config.Prefix = prefix
+	}
+	if n, err := strconv.Atoi(os.Getenv("QUEUE_CONCURRENCY")); err == nil && n > 0 {
+		config.Concurrency = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("QUEUE_MAX_ATTEMPTS")); err == nil && n > 0 {
+		config.MaxAttempts = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("QUEUE_RETRY_DELAY")); err == nil && d > 0 {
+		config.RetryDelay = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("QUEUE_ACK_TIMEOUT")); err == nil && d > 0 {
+		config.AckTimeout = d
+	}
+	return config
+}
+
+// New creates the queue selected by config
+func New(config Config) (Queue, error) {
+	switch config.Driver {
+	case "", "memory":
+		return NewMemoryQueue(config), nil
+	case "redis":
+		return NewRedisQueue(config)
+	default:
+		return nil, fmt.Errorf("queue: unsupported driver %s", config.Driver)
+	}
+}
+
+// withDefaults fills unset values from DefaultConfig
+func withDefaults(config Config) Config {
+	defaults := DefaultConfig()
+	if config.Prefix == "" {
+		config.Prefix = defaults.Prefix
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaults.Concurrency
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaults.RetryDelay
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaults.BufferSize
+	}
+	if config.AckTimeout <= 0 {
+		config.AckTimeout = defaults.AckTimeout
+	}
+	if config.MaxLen <= 0 {
+		config.MaxLen = defaults.MaxLen
+	}
+	return config
+}
+
+// retryDelay is the delay before redelivering a message that failed its
+// attempt
+func retryDelay(config Config, attempt int) time.Duration {
+	delay := config.RetryDelay
+	for i := 1; i < attempt && delay < time.Minute; i++ {
+		delay *= 2
+	}
+	return min(delay, time.Minute)
+}
+
+// handle runs a handler, turning a panic into an error
+func handle(ctx context.Context, handler Handler, msg *Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return handler(ctx, msg)
+}
@@ -0,0 +1,259 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisQueue is a durable queue on Redis streams, one stream per topic
+// and a consumer group per subscribed group. Messages survive restarts,
+// and messages a crashed instance was handling are taken over by another
+// after AckTimeout, so handlers taking longer than that may see a message
+// twice.
+type RedisQueue struct {
+	client   *redis.Client
+	config   Config
+	consumer string
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewRedisQueue connects to the Redis server at config.RedisURL
+func NewRedisQueue(config Config) (*RedisQueue, error) {
+	config = withDefaults(config)
+	options, err := redis.ParseURL(config.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("queue: invalid Redis URL: %w", err)
+	}
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("queue: connecting to Redis: %w", err)
+	}
+
+	host, _ := os.Hostname()
+	q := &RedisQueue{
+		client:   client,
+		config:   config,
+		consumer: fmt.Sprintf("%s-%d", host, os.Getpid()),
+	}
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+	return q, nil
+}
+
+// Publish appends a message to the topic's stream
+func (q *RedisQueue) Publish(ctx context.Context, topic string, payload []byte) error {
+	if q.ctx.Err() != nil {
+		return ErrClosed
+	}
+	return q.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.config.Prefix + topic,
+		MaxLen: q.config.MaxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"payload":      payload,
+			"published_at": time.Now().UnixMilli(),
+		},
+	}).Err()
+}
+
+// Subscribe creates the group if needed, starting from messages published
+// from now on, and starts Concurrency handlers reading from it
+func (q *RedisQueue) Subscribe(topic, group string, handler Handler) error {
+	if q.ctx.Err() != nil {
+		return ErrClosed
+	}
+	stream := q.config.Prefix + topic
+	err := q.client.XGroupCreateMkStream(q.ctx, stream, group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("queue: creating group %s on %s: %w", group, stream, err)
+	}
+
+	sub := &redisSubscription{queue: q, topic: topic, stream: stream, group: group, handler: handler}
+	for i := 0; i < q.config.Concurrency; i++ {
+		q.wg.Add(1)
+		go sub.read()
+	}
+	q.wg.Add(1)
+	go sub.reclaim()
+	return nil
+}
+
+// Close stops the handlers, waiting for those running to return, and
+// disconnects. Messages being retried stay pending and are taken over
+// after AckTimeout.
+func (q *RedisQueue) Close() error {
+	q.cancel()
+	q.wg.Wait()
+	return q.client.Close()
+}
+
+// redisSubscription is a group's handlers for one topic
+type redisSubscription struct {
+	queue   *RedisQueue
+	topic   string
+	stream  string
+	group   string
+	handler Handler
+}
+
+// read handles new messages
+func (s *redisSubscription) read() {
+	q := s.queue
+	defer q.wg.Done()
+	for q.ctx.Err() == nil {
+		streams, err := q.client.XReadGroup(q.ctx, &redis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: q.consumer,
+			Streams:  []string{s.stream, ">"},
+			Count:    1,
+			Block:    5 * time.Second,
+		}).Result()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) && q.ctx.Err() == nil {
+				logger.Warn("Queue read failed", logger.Fields{"topic": s.topic, "group": s.group, "error": err.Error()})
+				s.sleep(q.config.RetryDelay)
+			}
+			continue
+		}
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				s.process(message, 1)
+			}
+		}
+	}
+}
+
+// reclaim takes over messages left unacknowledged for AckTimeout, by
+// instances that stopped or crashed while handling them
+func (s *redisSubscription) reclaim() {
+	q := s.queue
+	defer q.wg.Done()
+	for s.sleep(q.config.AckTimeout / 2) {
+		start := "0-0"
+		for {
+			messages, next, err := q.client.XAutoClaim(q.ctx, &redis.XAutoClaimArgs{
+				Stream:   s.stream,
+				Group:    s.group,
+				Consumer: q.consumer,
+				MinIdle:  q.config.AckTimeout,
+				Start:    start,
+				Count:    10,
+			}).Result()
+			if err != nil {
+				if q.ctx.Err() == nil {
+					logger.Warn("Queue reclaim failed", logger.Fields{"topic": s.topic, "group": s.group, "error": err.Error()})
+				}
+				break
+			}
+			for _, message := range messages {
+				s.process(message, s.deliveries(message.ID))
+			}
+			if next == "0-0" || next == "" || q.ctx.Err() != nil {
+				break
+			}
+			start = next
+		}
+	}
+}
+
+// process handles a message until it succeeds or runs out of attempts,
+// then acknowledges it
+func (s *redisSubscription) process(message redis.XMessage, attempt int) {
+	q := s.queue
+	msg := &Message{ID: message.ID, Topic: s.topic, Attempt: attempt}
+	if payload, ok := message.Values["payload"].(string); ok {
+		msg.Payload = []byte(payload)
+	}
+	if published, ok := message.Values["published_at"].(string); ok {
+		if ms, err := strconv.ParseInt(published, 10, 64); err == nil {
+			msg.PublishedAt = time.UnixMilli(ms)
+		}
+	}
+	fields := logger.Fields{"topic": s.topic, "group": s.group, "id": msg.ID}
+
+	// Taken over after its last attempt, e.g. from an instance that crashed
+	if msg.Attempt > q.config.MaxAttempts {
+		logger.Error("Queue message dropped after its last attempt", merge(fields, logger.Fields{"attempts": msg.Attempt - 1}))
+	}
+	for msg.Attempt <= q.config.MaxAttempts {
+		err := handle(q.ctx, s.handler, msg)
+		if err == nil {
+			break
+		}
+		if msg.Attempt == q.config.MaxAttempts {
+			logger.Error("Queue message dropped after its last attempt", merge(fields, logger.Fields{"attempts": msg.Attempt, "error": err.Error()}))
+			break
+		}
+		logger.Warn("Queue message failed, retrying", merge(fields, logger.Fields{"attempt": msg.Attempt, "error": err.Error()}))
+
+		// Retry before another instance could take the message over
+		if !s.sleep(min(retryDelay(q.config, msg.Attempt), q.config.AckTimeout/2)) {
+			return // Left pending for another instance
+		}
+		claimed, err := q.client.XClaim(q.ctx, &redis.XClaimArgs{
+			Stream:   s.stream,
+			Group:    s.group,
+			Consumer: q.consumer,
+			Messages: []string{msg.ID},
+		}).Result()
+		if err != nil || len(claimed) == 0 {
+			return // Taken over or trimmed
+		}
+		msg.Attempt++
+	}
+
+	if err := q.client.XAck(context.Background(), s.stream, s.group, msg.ID).Err(); err != nil {
+		logger.Warn("Queue acknowledgement failed", merge(fields, logger.Fields{"error": err.Error()}))
+	}
+}
+
+// deliveries returns how many times a pending message has been delivered
+func (s *redisSubscription) deliveries(id string) int {
+	pending, err := s.queue.client.XPendingExt(s.queue.ctx, &redis.XPendingExtArgs{
+		Stream: s.stream,
+		Group:  s.group,
+		Start:  id,
+		End:    id,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return 1
+	}
+	return int(pending[0].RetryCount)
+}
+
+// sleep waits for d, returning false if the queue closed meanwhile
+func (s *redisSubscription) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-s.queue.ctx.Done():
+		return false
+	}
+}
+
+func merge(a, b logger.Fields) logger.Fields {
+	merged := make(logger.Fields, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
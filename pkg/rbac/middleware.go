@@ -3,6 +3,8 @@ package rbac
 import (
 	"context"
 
+	"neonexcore/pkg/auth"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -33,6 +35,11 @@ func RequirePermission(manager *Manager, permission string) fiber.Handler {
 			})
 		}
 
+		// API keys may be limited to a subset of their owner's permissions
+		if claims, ok := auth.GetClaims(c); ok && !claims.HasScope(permission) {
+			return scopeForbidden(c)
+		}
+
 		return c.Next()
 	}
 }
@@ -79,8 +86,13 @@ func RequireAnyPermission(manager *Manager, permissions ...string) fiber.Handler
 			})
 		}
 
+		allowed := scopedPermissions(c, permissions)
+		if len(allowed) == 0 {
+			return scopeForbidden(c)
+		}
+
 		ctx := context.Background()
-		hasAny, err := manager.HasAnyPermission(ctx, userID, permissions)
+		hasAny, err := manager.HasAnyPermission(ctx, userID, allowed)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error":   "internal_error",
@@ -110,6 +122,10 @@ func RequireAllPermissions(manager *Manager, permissions ...string) fiber.Handle
 			})
 		}
 
+		if len(scopedPermissions(c, permissions)) != len(permissions) {
+			return scopeForbidden(c)
+		}
+
 		ctx := context.Background()
 		hasAll, err := manager.HasAllPermissions(ctx, userID, permissions)
 		if err != nil {
@@ -129,3 +145,27 @@ func RequireAllPermissions(manager *Manager, permissions ...string) fiber.Handle
 		return c.Next()
 	}
 }
+
+// scopedPermissions filters permissions to those the request's API key
+// scopes allow
+func scopedPermissions(c *fiber.Ctx, permissions []string) []string {
+	claims, ok := auth.GetClaims(c)
+	if !ok {
+		return permissions
+	}
+
+	allowed := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		if claims.HasScope(permission) {
+			allowed = append(allowed, permission)
+		}
+	}
+	return allowed
+}
+
+func scopeForbidden(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error":   "forbidden",
+		"message": "API key scope does not allow this action",
+	})
+}
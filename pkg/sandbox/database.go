@@ -0,0 +1,145 @@
+package sandbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// DefaultSharedTables are tables test mode reads and writes in the live
+// database: accounts, permissions and the credentials test keys are
+// checked against.
+var DefaultSharedTables = []string{
+	"users",
+	"roles",
+	"permissions",
+	"user_roles",
+	"user_permissions",
+	"modules",
+	"module_dependencies",
+	"module_migrations",
+	"portal_api_keys",
+	"portal_webhooks",
+	"portal_webhook_deliveries",
+	"metering_usage",
+}
+
+// Partition is a GORM plugin that sends test mode queries to a separate
+// sandbox database. Repositories need no changes: every statement run with
+// a test mode context (db.WithContext(ctx)) executes on the sandbox
+// database, except statements on shared tables.
+//
+// Transactions stay on the database they began on, so a transaction begun
+// in test mode also reads shared tables from the sandbox database. Raw SQL
+// always follows the context's mode. Prepared statement caching
+// (gorm.Config.PrepareStmt) is not supported.
+type Partition struct {
+	test   *gorm.DB
+	shared map[string]bool
+}
+
+// NewPartition creates a partition onto the sandbox database test, which
+// must use the same driver as the live database
+func NewPartition(test *gorm.DB, shared ...string) *Partition {
+	p := &Partition{test: test, shared: make(map[string]bool, len(shared))}
+	for _, table := range shared {
+		p.shared[table] = true
+	}
+	return p
+}
+
+// DB returns the sandbox database, e.g. to migrate it
+func (p *Partition) DB() *gorm.DB {
+	return p.test
+}
+
+// Name implements gorm.Plugin
+func (p *Partition) Name() string {
+	return "sandbox:partition"
+}
+
+// Initialize implements gorm.Plugin, routing the live database's
+// connections by mode
+func (p *Partition) Initialize(db *gorm.DB) error {
+	live, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("sandbox: live database: %w", err)
+	}
+	test, err := p.test.DB()
+	if err != nil {
+		return fmt.Errorf("sandbox: sandbox database: %w", err)
+	}
+
+	pool := &router{live: live, test: test}
+	db.ConnPool = pool
+	db.Statement.ConnPool = pool
+
+	// Shared statements switch mode before their default transaction begins
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("*").Register("sandbox:shared", p.shareTable),
+		callbacks.Query().Before("*").Register("sandbox:shared", p.shareTable),
+		callbacks.Update().Before("*").Register("sandbox:shared", p.shareTable),
+		callbacks.Delete().Before("*").Register("sandbox:shared", p.shareTable),
+		callbacks.Row().Before("*").Register("sandbox:shared", p.shareTable),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shareTable runs test mode statements on shared tables in live mode
+func (p *Partition) shareTable(db *gorm.DB) {
+	if p.shared[db.Statement.Table] && IsTest(db.Statement.Context) {
+		db.Statement.Context = WithMode(db.Statement.Context, ModeLive)
+	}
+}
+
+// router is a gorm.ConnPool choosing the live or sandbox database by the
+// mode of each call's context
+type router struct {
+	live *sql.DB
+	test *sql.DB
+}
+
+func (r *router) pool(ctx context.Context) *sql.DB {
+	if IsTest(ctx) {
+		return r.test
+	}
+	return r.live
+}
+
+func (r *router) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return r.pool(ctx).PrepareContext(ctx, query)
+}
+
+func (r *router) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.pool(ctx).ExecContext(ctx, query, args...)
+}
+
+func (r *router) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.pool(ctx).QueryContext(ctx, query, args...)
+}
+
+func (r *router) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return r.pool(ctx).QueryRowContext(ctx, query, args...)
+}
+
+// BeginTx implements gorm.TxBeginner
+func (r *router) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return r.pool(ctx).BeginTx(ctx, opts)
+}
+
+// GetDBConn implements gorm.GetDBConnector, exposing the live database
+func (r *router) GetDBConn() (*sql.DB, error) {
+	return r.live, nil
+}
+
+// Ping checks the live database
+func (r *router) Ping() error {
+	return r.live.Ping()
+}
@@ -0,0 +1,80 @@
+package sandbox
+
+import (
+	"context"
+
+	"neonexcore/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Mode selects live or sandbox behavior for a request
+type Mode string
+
+const (
+	ModeLive Mode = "live"
+	ModeTest Mode = "test"
+)
+
+// HeaderMode is set on responses to test mode requests
+const HeaderMode = "X-Neonex-Mode"
+
+// localsKey is the Fiber locals key holding the request's mode
+const localsKey = "sandbox_mode"
+
+type contextKey struct{}
+
+// Valid reports whether m is a known mode
+func (m Mode) Valid() bool {
+	return m == ModeLive || m == ModeTest
+}
+
+// WithMode returns a copy of ctx running in mode
+func WithMode(ctx context.Context, mode Mode) context.Context {
+	return context.WithValue(ctx, contextKey{}, mode)
+}
+
+// FromContext returns the mode of ctx, ModeLive unless set
+func FromContext(ctx context.Context) Mode {
+	if ctx == nil {
+		return ModeLive
+	}
+	if mode, ok := ctx.Value(contextKey{}).(Mode); ok {
+		return mode
+	}
+	// Fiber locals are visible through c.Context()
+	if mode, ok := ctx.Value(localsKey).(Mode); ok {
+		return mode
+	}
+	return ModeLive
+}
+
+// IsTest reports whether ctx runs in test mode
+func IsTest(ctx context.Context) bool {
+	return FromContext(ctx) == ModeTest
+}
+
+// Set puts the request in mode. Downstream code sees it through
+// c.UserContext(), request logs carry a mode field, and the response is
+// tagged with HeaderMode.
+func Set(c *fiber.Ctx, mode Mode) {
+	c.Locals(localsKey, mode)
+	c.SetUserContext(WithMode(c.UserContext(), mode))
+	if mode == ModeTest {
+		logger.AddRequestFields(c, logger.Fields{"mode": string(mode)})
+		c.Set(HeaderMode, string(mode))
+	}
+}
+
+// FromFiber returns the mode of a request, ModeLive unless set
+func FromFiber(c *fiber.Ctx) Mode {
+	if mode, ok := c.Locals(localsKey).(Mode); ok {
+		return mode
+	}
+	return ModeLive
+}
+
+// IsTestRequest reports whether a request runs in test mode
+func IsTestRequest(c *fiber.Ctx) bool {
+	return FromFiber(c) == ModeTest
+}
@@ -0,0 +1,208 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed cron schedule
+type Cron struct {
+	expr     string
+	minute   uint64
+	hour     uint64
+	dom      uint64
+	month    uint64
+	dow      uint64
+	domStar  bool // Day fields starting with * don't restrict the other one
+	dowStar  bool
+	location *time.Location
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = cronField{min: 0, max: 59}
+	hourField   = cronField{min: 0, max: 23}
+	domField    = cronField{min: 1, max: 31}
+	monthField  = cronField{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is Sunday too
+	dowField = cronField{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronShorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a five field cron expression: minute, hour, day of
+// month, month and day of week. Fields take *, values, ranges (1-5),
+// steps (*/15, 0-30/10) and lists (MON,WED,FRI); months and days may be
+// named. @hourly, @daily, @weekly, @monthly and @yearly are shorthands.
+// Times are local unless the expression starts with CRON_TZ=<zone>:
+//
+//	0 9 * * MON                        Mondays at 09:00
+//	CRON_TZ=Europe/Berlin 30 6 1 * *   06:30 Berlin time on the 1st
+func ParseCron(expr string) (*Cron, error) {
+	c := &Cron{expr: strings.TrimSpace(expr), location: time.Local}
+
+	spec := c.expr
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		zone, rest, _ := strings.Cut(spec, " ")
+		_, name, _ := strings.Cut(zone, "=")
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("scheduler: cron %q: %w", expr, err)
+		}
+		c.location = location
+		spec = strings.TrimSpace(rest)
+	}
+	if shorthand, ok := cronShorthands[strings.ToLower(spec)]; ok {
+		spec = shorthand
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("scheduler: cron %q: want 5 fields, got %d", expr, len(fields))
+	}
+	var err error
+	targets := []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&c.minute, minuteField},
+		{&c.hour, hourField},
+		{&c.dom, domField},
+		{&c.month, monthField},
+		{&c.dow, dowField},
+	}
+	for i, target := range targets {
+		if *target.bits, err = target.field.parse(fields[i]); err != nil {
+			return nil, fmt.Errorf("scheduler: cron %q: %w", expr, err)
+		}
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = strings.HasPrefix(fields[2], "*")
+	c.dowStar = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// parse returns the values a field matches as bits
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = f.value(from); err != nil {
+				return 0, err
+			}
+			if high, err = f.value(to); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			value, err := f.value(rangePart)
+			if err != nil {
+				return 0, err
+			}
+			low = value
+			if !hasStep {
+				high = value // 5/10 runs from 5 to the maximum
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%q is not between %d and %d", s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// String returns the expression the schedule was parsed from
+func (c *Cron) String() string {
+	return c.expr
+}
+
+// Next returns the first time after t the schedule fires, or the zero
+// time if it never does (e.g. 30 February)
+func (c *Cron) Next(t time.Time) time.Time {
+	original := t.Location()
+	t = t.In(c.location).Truncate(time.Minute).Add(time.Minute)
+
+	// Every schedule that fires at all does so within five years
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.location)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.location)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.location)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t.In(original)
+	}
+	return time.Time{}
+}
+
+// dayMatches applies cron's rule that when both day fields are
+// restricted, either may match
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
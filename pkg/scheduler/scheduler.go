@@ -0,0 +1,292 @@
+// Package scheduler runs named jobs at fixed intervals or on cron
+// schedules. Runs of a job never overlap, and with a leader check jobs run
+// on only one instance.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/logger"
+)
+
+var (
+	// ErrJobNotFound is returned for jobs that were never added
+	ErrJobNotFound = errors.New("scheduler: job not found")
+	// ErrJobRunning is returned by RunNow while the job is already running
+	ErrJobRunning = errors.New("scheduler: job already running")
+)
+
+// Job is work run every Interval, or at the times of a Cron expression
+type Job struct {
+	Name       string
+	Interval   time.Duration
+	Cron       string        // Instead of Interval, e.g. "0 9 * * MON"; see ParseCron
+	Timeout    time.Duration // Per run; defaults to Interval, or to the gap between cron runs
+	RunAtStart bool          // Run once as soon as the scheduler starts
+	Run        func(ctx context.Context) error
+}
+
+// JobStatus describes a job's schedule and its latest run
+type JobStatus struct {
+	Name         string        `json:"name"`
+	Interval     time.Duration `json:"interval"`
+	Cron         string        `json:"cron,omitempty"`
+	Running      bool          `json:"running"`
+	Runs         int64         `json:"runs"`
+	Failures     int64         `json:"failures"`
+	LastRun      *time.Time    `json:"last_run,omitempty"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error,omitempty"`
+	NextRun      *time.Time    `json:"next_run,omitempty"`
+}
+
+// Scheduler runs jobs in the background between Start and Stop
+type Scheduler struct {
+	mu       sync.Mutex
+	jobs     map[string]*entry
+	isLeader func() bool
+	started  bool
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+type entry struct {
+	job    Job
+	cron   *Cron
+	status JobStatus
+	stop   chan struct{}
+}
+
+// New creates a scheduler with no jobs
+func New() *Scheduler {
+	return &Scheduler{jobs: make(map[string]*entry)}
+}
+
+// SetLeaderCheck makes jobs run only while isLeader reports true, e.g. a
+// cache.LeaderElector's IsLeader, so they run on one instance at a time
+func (s *Scheduler) SetLeaderCheck(isLeader func() bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.isLeader = isLeader
+}
+
+// Add schedules a job. Jobs added after Start begin at once.
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return fmt.Errorf("scheduler: job needs a name and a run function")
+	}
+	var cron *Cron
+	if job.Cron != "" {
+		var err error
+		if cron, err = ParseCron(job.Cron); err != nil {
+			return err
+		}
+		next := cron.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("scheduler: cron %q of job %s never fires", job.Cron, job.Name)
+		}
+		if job.Timeout <= 0 {
+			job.Timeout = cron.Next(next).Sub(next)
+		}
+	} else if job.Interval <= 0 {
+		return fmt.Errorf("scheduler: job %s needs a positive interval or a cron expression", job.Name)
+	}
+	if job.Timeout <= 0 {
+		job.Timeout = job.Interval
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.jobs[job.Name]; exists {
+		return fmt.Errorf("scheduler: job %s already exists", job.Name)
+	}
+	e := &entry{job: job, cron: cron, status: JobStatus{Name: job.Name, Interval: job.Interval, Cron: job.Cron}}
+	s.jobs[job.Name] = e
+	if s.started {
+		s.launch(e)
+	}
+	return nil
+}
+
+// Replace schedules a job, replacing any job with the same name
+func (s *Scheduler) Replace(job Job) error {
+	s.Remove(job.Name)
+	return s.Add(job)
+}
+
+// Remove unschedules a job. A run in progress is allowed to finish.
+func (s *Scheduler) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.jobs[name]; ok {
+		if e.stop != nil {
+			close(e.stop)
+		}
+		delete(s.jobs, name)
+	}
+}
+
+// Start runs jobs in the background. It is safe to call more than once.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+	s.started = true
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	for _, e := range s.jobs {
+		s.launch(e)
+	}
+}
+
+// Stop ends background runs, cancelling and waiting for runs in progress
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = false
+	s.cancel()
+	for _, e := range s.jobs {
+		e.stop = nil
+		e.status.NextRun = nil
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// RunNow runs a job immediately and waits for it, regardless of leadership
+func (s *Scheduler) RunNow(ctx context.Context, name string) error {
+	s.mu.Lock()
+	e, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return ErrJobNotFound
+	}
+	return s.run(ctx, e)
+}
+
+// Jobs returns the status of every job, by name
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		statuses = append(statuses, e.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// launch starts a job's loop. The caller holds s.mu.
+func (s *Scheduler) launch(e *entry) {
+	stop := make(chan struct{})
+	e.stop = stop
+	next := e.next(time.Now())
+	e.status.NextRun = &next
+
+	s.wg.Add(1)
+	go func(ctx context.Context) {
+		defer s.wg.Done()
+
+		if e.job.RunAtStart {
+			s.tick(ctx, e, next)
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+				// Runs that overran the next run time skip it
+				if next = e.next(next); next.Before(time.Now()) {
+					next = e.next(time.Now())
+				}
+				s.tick(ctx, e, next)
+				timer.Reset(time.Until(next))
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(s.ctx)
+}
+
+// next returns a job's first run time after t
+func (e *entry) next(t time.Time) time.Time {
+	if e.cron != nil {
+		return e.cron.Next(t)
+	}
+	return t.Add(e.job.Interval)
+}
+
+// tick runs a scheduled job if this instance leads
+func (s *Scheduler) tick(ctx context.Context, e *entry, next time.Time) {
+	s.mu.Lock()
+	isLeader := s.isLeader
+	e.status.NextRun = &next
+	s.mu.Unlock()
+
+	if isLeader != nil && !isLeader() {
+		return
+	}
+	if err := s.run(ctx, e); err != nil && !errors.Is(err, ErrJobRunning) {
+		logger.Error("Scheduled job failed", logger.Fields{"job": e.job.Name, "error": err.Error()})
+	}
+}
+
+// run runs a job once unless it is already running
+func (s *Scheduler) run(ctx context.Context, e *entry) error {
+	s.mu.Lock()
+	if e.status.Running {
+		s.mu.Unlock()
+		return ErrJobRunning
+	}
+	e.status.Running = true
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, e.job.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := runSafely(ctx, e.job.Run)
+
+	s.mu.Lock()
+	e.status.Running = false
+	e.status.Runs++
+	e.status.LastRun = &start
+	e.status.LastDuration = time.Since(start)
+	e.status.LastError = ""
+	if err != nil {
+		e.status.Failures++
+		e.status.LastError = err.Error()
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// runSafely turns a panicking job into an error
+func runSafely(ctx context.Context, run func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run(ctx)
+}
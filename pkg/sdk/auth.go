@@ -0,0 +1,83 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// APIKeyHeader carries a portal API key, as the portal module reads it
+const APIKeyHeader = "X-API-Key"
+
+// Auth adds credentials to requests
+type Auth interface {
+	Apply(ctx context.Context, req *http.Request) error
+}
+
+// AuthFunc adapts a function to Auth
+type AuthFunc func(ctx context.Context, req *http.Request) error
+
+// Apply implements Auth
+func (f AuthFunc) Apply(ctx context.Context, req *http.Request) error {
+	return f(ctx, req)
+}
+
+// BearerToken sends a JWT access token, or a portal API key, as
+// "Authorization: Bearer <token>"
+func BearerToken(token string) Auth {
+	return AuthFunc(func(ctx context.Context, req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// APIKey sends a portal API key in the X-API-Key header
+func APIKey(key string) Auth {
+	return AuthFunc(func(ctx context.Context, req *http.Request) error {
+		req.Header.Set(APIKeyHeader, key)
+		return nil
+	})
+}
+
+// TokenFunc returns a bearer token and when it expires; a zero expiry
+// means it is fetched again for every request
+type TokenFunc func(ctx context.Context) (token string, expiresAt time.Time, err error)
+
+// TokenSource sends bearer tokens from fetch, such as a login or token
+// refresh, reusing each until a minute before it expires
+func TokenSource(fetch TokenFunc) Auth {
+	return &tokenSource{fetch: fetch}
+}
+
+type tokenSource struct {
+	fetch     TokenFunc
+	token     string
+	expiresAt time.Time
+	mu        sync.Mutex
+}
+
+// Apply implements Auth
+func (s *tokenSource) Apply(ctx context.Context, req *http.Request) error {
+	token, err := s.current(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (s *tokenSource) current(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Until(s.expiresAt) > time.Minute {
+		return s.token, nil
+	}
+	token, expiresAt, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.expiresAt = token, expiresAt
+	return token, nil
+}
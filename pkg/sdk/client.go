@@ -0,0 +1,363 @@
+// Package sdk is the runtime of the API clients generated by
+// `neonex client:generate`: requests in the API's response envelope,
+// retries with backoff and authentication.
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/httpclient"
+)
+
+// Client sends requests to a NeonexCore API. Generated module clients
+// share one.
+type Client struct {
+	baseURL   string
+	http      *http.Client
+	auth      Auth
+	retry     RetryPolicy
+	header    http.Header
+	userAgent string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// New creates a client for the API at baseURL, such as
+// "https://api.example.com". Requests are retried by DefaultRetryPolicy
+// and sent without credentials unless configured otherwise.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		http:      httpclient.New(30 * time.Second),
+		retry:     DefaultRetryPolicy(),
+		header:    make(http.Header),
+		userAgent: "neonexcore-sdk",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithHTTPClient sends requests with client
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) { c.http = client }
+}
+
+// WithTimeout limits each attempt of a request
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		client := *c.http
+		client.Timeout = timeout
+		c.http = &client
+	}
+}
+
+// WithAuth authenticates every request with auth
+func WithAuth(auth Auth) Option {
+	return func(c *Client) { c.auth = auth }
+}
+
+// WithBearerToken authenticates with a JWT or API key as a bearer token
+func WithBearerToken(token string) Option {
+	return WithAuth(BearerToken(token))
+}
+
+// WithAPIKey authenticates with a portal API key in the X-API-Key header
+func WithAPIKey(key string) Option {
+	return WithAuth(APIKey(key))
+}
+
+// WithHeader adds a header to every request
+func WithHeader(key, value string) Option {
+	return func(c *Client) { c.header.Add(key, value) }
+}
+
+// WithUserAgent sets the User-Agent of requests
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// WithRetry retries requests by policy
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// WithoutRetry sends every request once
+func WithoutRetry() Option {
+	return WithRetry(RetryPolicy{MaxAttempts: 1})
+}
+
+// RetryPolicy decides which failed requests are sent again. Network errors
+// and 502, 503 and 504 responses are retried for idempotent methods
+// (GET, HEAD, PUT, DELETE, OPTIONS); 429 responses for every method, as
+// the request was not processed. A Retry-After header is honored up to
+// MaxDelay.
+type RetryPolicy struct {
+	MaxAttempts int           // Including the first; 0 or 1 disables retries
+	MinDelay    time.Duration // Before the second attempt, doubling after
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy makes three attempts, 200ms and 400ms apart give or
+// take jitter
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, MinDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second}
+}
+
+// delay returns the wait before the attempt after attempt, with up to a
+// fifth of jitter
+func (p RetryPolicy) delay(attempt int, retryAfter time.Duration) time.Duration {
+	delay := p.MinDelay << (attempt - 1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay > 0 {
+		delay += time.Duration(rand.Int63n(int64(delay)/5 + 1))
+	}
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// Meta is the pagination of a list response
+type Meta struct {
+	Page        int   `json:"page,omitempty"`
+	Limit       int   `json:"limit,omitempty"`
+	Total       int64 `json:"total,omitempty"`
+	TotalPages  int   `json:"total_pages,omitempty"`
+	HasNextPage bool  `json:"has_next_page,omitempty"`
+	HasPrevPage bool  `json:"has_prev_page,omitempty"`
+	NextPage    *int  `json:"next_page,omitempty"`
+	PrevPage    *int  `json:"prev_page,omitempty"`
+}
+
+// envelope is the API's response format, see api.Response
+type envelope struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Errors  json.RawMessage `json:"errors"`
+	Meta    *Meta           `json:"meta"`
+}
+
+// Error is a response with an error status
+type Error struct {
+	StatusCode int
+	Message    string
+	Errors     json.RawMessage // Validation errors by field, when sent
+	Body       []byte
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+	}
+	return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// StatusCode returns the status of an *Error, or 0 for other errors
+func StatusCode(err error) int {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// Do sends a request with a JSON body, unless body is nil, and decodes the
+// data of the response envelope into out, unless out is nil. It returns
+// the envelope's pagination, if any.
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body, out interface{}) (*Meta, error) {
+	data, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var env envelope
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("sdk: decoding %s %s: %w", method, path, err)
+	}
+	if out != nil && len(env.Data) > 0 && string(env.Data) != "null" {
+		if err := json.Unmarshal(env.Data, out); err != nil {
+			return nil, fmt.Errorf("sdk: decoding %s %s: %w", method, path, err)
+		}
+	}
+	return env.Meta, nil
+}
+
+// DoRaw sends a request like Do for a response that is not in the
+// envelope, decoding the whole body into out
+func (c *Client) DoRaw(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	data, err := c.send(ctx, method, path, query, body)
+	if err != nil || out == nil || len(bytes.TrimSpace(data)) == 0 {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("sdk: decoding %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// Download sends a request for a file, image or stream, returning the
+// body unread. The caller closes it.
+func (c *Client) Download(ctx context.Context, method, path string, query url.Values, body interface{}) (io.ReadCloser, error) {
+	resp, err := c.roundTrip(ctx, method, path, query, body, "*/*")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// send makes a request and reads its body
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body interface{}) ([]byte, error) {
+	resp, err := c.roundTrip(ctx, method, path, query, body, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// roundTrip makes a request, retrying by the client's policy, and returns
+// a response with a success status
+func (c *Client) roundTrip(ctx context.Context, method, path string, query url.Values, body interface{}, accept string) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("sdk: encoding %s %s: %w", method, path, err)
+		}
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	attempts := max(c.retry.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		resp, err := c.attempt(ctx, method, target, payload, accept)
+		retry, retryAfter := c.retryable(method, resp, err)
+		if !retry || attempt >= attempts || ctx.Err() != nil {
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode >= 400 {
+				return nil, responseError(resp)
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(c.retry.delay(attempt, retryAfter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, target string, payload []byte, accept string) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	req.Header.Set("Accept", accept)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if c.auth != nil {
+		if err := c.auth.Apply(ctx, req); err != nil {
+			return nil, fmt.Errorf("sdk: authenticating: %w", err)
+		}
+	}
+	return c.http.Do(req)
+}
+
+// retryable reports whether a request should be sent again and how long
+// the server asked to wait
+func (c *Client) retryable(method string, resp *http.Response, err error) (bool, time.Duration) {
+	idempotent := method == http.MethodGet || method == http.MethodHead || method == http.MethodPut ||
+		method == http.MethodDelete || method == http.MethodOptions
+	if err != nil {
+		return idempotent, 0
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true, retryAfter(resp)
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent, retryAfter(resp)
+	}
+	return false, 0
+}
+
+// retryAfter reads a Retry-After header in seconds or as a date
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// responseError reads an error response, in the envelope or not
+func responseError(resp *http.Response) error {
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	apiErr := &Error{StatusCode: resp.StatusCode, Body: data}
+	var env envelope
+	if json.Unmarshal(data, &env) == nil {
+		apiErr.Message = env.Message
+		if len(env.Errors) > 0 && string(env.Errors) != "null" {
+			apiErr.Errors = env.Errors
+		}
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(data))
+		if len(apiErr.Message) > 200 {
+			apiErr.Message = apiErr.Message[:200]
+		}
+	}
+	return apiErr
+}
@@ -0,0 +1,66 @@
+package sdk
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Pathf formats a request path, escaping each argument as a path segment:
+//
+//	sdk.Pathf("/api/v1/vault/secrets/%v", "db/password")
+//	// "/api/v1/vault/secrets/db%2Fpassword"
+func Pathf(format string, args ...interface{}) string {
+	escaped := make([]interface{}, len(args))
+	for i, arg := range args {
+		escaped[i] = url.PathEscape(fmt.Sprint(arg))
+	}
+	return fmt.Sprintf(format, escaped...)
+}
+
+// Values encodes the exported fields of a struct, or a pointer to one,
+// as query parameters named by their url tags. Zero values are left out,
+// pointers are dereferenced and slices add a value each. A nil pointer
+// encodes as no parameters.
+func Values(params interface{}) url.Values {
+	values := make(url.Values)
+	v := reflect.ValueOf(params)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return values
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return values
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("url"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		value := v.Field(i)
+		if value.IsZero() {
+			continue
+		}
+		if value.Kind() == reflect.Pointer {
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Slice {
+			for j := 0; j < value.Len(); j++ {
+				values.Add(name, fmt.Sprint(value.Index(j).Interface()))
+			}
+			continue
+		}
+		values.Set(name, fmt.Sprint(value.Interface()))
+	}
+	return values
+}
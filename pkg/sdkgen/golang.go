@@ -0,0 +1,307 @@
+package sdkgen
+
+import (
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+)
+
+const sdkImport = "neonexcore/pkg/sdk"
+
+// goWriter renders Go source, noting the packages it uses
+type goWriter struct {
+	strings.Builder
+	imports map[string]bool
+}
+
+func (w *goWriter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(w, format, args...)
+}
+
+// comment writes text as a comment, indented by indent
+func (w *goWriter) comment(indent, text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		w.printf("%s// %s\n", indent, strings.TrimSpace(line))
+	}
+}
+
+// source formats the file with the imports used
+func (w *goWriter) source(pkgName, doc string) ([]byte, error) {
+	var head strings.Builder
+	fmt.Fprintf(&head, "// %s\n\n", GeneratedMarker)
+	if doc != "" {
+		fmt.Fprintf(&head, "// %s\n", doc)
+	}
+	fmt.Fprintf(&head, "package %s\n\n", pkgName)
+	if len(w.imports) > 0 {
+		// The standard library first, as goimports groups them
+		head.WriteString("import (\n")
+		for _, path := range sortedNames(w.imports) {
+			if path != sdkImport {
+				fmt.Fprintf(&head, "\t%q\n", path)
+			}
+		}
+		if w.imports[sdkImport] {
+			fmt.Fprintf(&head, "\n\t%q\n", sdkImport)
+		}
+		head.WriteString(")\n\n")
+	}
+	src := head.String() + w.String()
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return formatted, nil
+}
+
+// typeName writes a type as Go
+func (w *goWriter) typeName(t *Type) string {
+	switch t.Kind {
+	case KindBasic, KindNamed:
+		return t.Name
+	case KindSlice:
+		return "[]" + w.typeName(t.Elem)
+	case KindMap:
+		return "map[" + w.typeName(t.Key) + "]" + w.typeName(t.Elem)
+	case KindPointer:
+		return "*" + w.typeName(t.Elem)
+	case KindStruct:
+		var b strings.Builder
+		b.WriteString("struct {\n")
+		for _, field := range t.Fields {
+			b.WriteString(w.field(field))
+		}
+		b.WriteString("}")
+		return b.String()
+	case KindTime:
+		w.imports["time"] = true
+		return "time.Time"
+	case KindDuration:
+		w.imports["time"] = true
+		return "time.Duration"
+	case KindBytes:
+		return "[]byte"
+	}
+	w.imports["encoding/json"] = true
+	return "json.RawMessage"
+}
+
+// field writes a struct field line
+func (w *goWriter) field(f *Field) string {
+	var b strings.Builder
+	if f.Embedded {
+		return w.typeName(f.Type) + "\n"
+	}
+	fmt.Fprintf(&b, "%s %s", f.Name, w.typeName(f.Type))
+	if f.Tag != "" {
+		fmt.Fprintf(&b, " `json:%q`", f.Tag)
+	}
+	if f.Doc != "" {
+		fmt.Fprintf(&b, " // %s", strings.Join(strings.Fields(f.Doc), " "))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// GenerateGo renders a module's Go client: client.go with a method per
+// endpoint and types.go with the types they use
+func GenerateGo(m *Module) (map[string][]byte, error) {
+	pkgName := GoPackage(m)
+
+	taken := map[string]bool{"Client": true, "New": true, "NewWithSDK": true}
+	structs := make(map[string]bool)
+	for _, decl := range m.Types {
+		taken[decl.Name] = true
+		structs[decl.Name] = decl.Type.Kind == KindStruct
+	}
+
+	types := &goWriter{imports: make(map[string]bool)}
+	for _, decl := range m.Types {
+		writeGoDecl(types, decl, taken)
+	}
+
+	client := &goWriter{imports: map[string]bool{"context": true, sdkImport: true}}
+	client.printf(`// Client calls the endpoints of the %[1]s module
+type Client struct {
+	sdk *sdk.Client
+}
+
+// New creates a client for the API at baseURL, such as
+// "https://api.example.com"
+func New(baseURL string, opts ...sdk.Option) *Client {
+	return &Client{sdk: sdk.New(baseURL, opts...)}
+}
+
+// NewWithSDK creates a client sharing an SDK client, and its connections,
+// credentials and retries, with other modules' clients
+func NewWithSDK(client *sdk.Client) *Client {
+	return &Client{sdk: client}
+}
+`, m.Name)
+
+	for _, e := range m.Endpoints {
+		writeGoEndpoint(client, e, taken, structs)
+	}
+
+	files := make(map[string][]byte)
+	doc := fmt.Sprintf("Package %s is a client of the %s module's REST API.", pkgName, m.Name)
+	data, err := client.source(pkgName, doc)
+	if err != nil {
+		return nil, err
+	}
+	files["client.go"] = data
+	if len(m.Types) > 0 {
+		if data, err = types.source(pkgName, ""); err != nil {
+			return nil, err
+		}
+		files["types.go"] = data
+	}
+	return files, nil
+}
+
+func writeGoDecl(w *goWriter, decl *TypeDecl, taken map[string]bool) {
+	w.printf("\n")
+	if decl.Doc != "" {
+		w.comment("", decl.Doc)
+	}
+	if decl.Type.Kind == KindStruct {
+		w.printf("type %s struct {\n", decl.Name)
+		for _, field := range decl.Type.Fields {
+			w.printf("%s", w.field(field))
+		}
+		w.printf("}\n")
+	} else {
+		w.printf("type %s %s\n", decl.Name, w.typeName(decl.Type))
+	}
+
+	var values []*Value
+	for _, value := range decl.Values {
+		if !taken[value.Name] {
+			taken[value.Name] = true
+			values = append(values, value)
+		}
+	}
+	if len(values) > 0 {
+		w.printf("\nconst (\n")
+		for _, value := range values {
+			w.printf("%s %s = %s\n", value.Name, decl.Name, value.Literal)
+		}
+		w.printf(")\n")
+	}
+}
+
+// goReserved are names generated methods use for their own variables
+var goReserved = map[string]bool{
+	"ctx": true, "params": true, "input": true, "out": true, "meta": true, "err": true, "c": true,
+	"break": true, "case": true, "chan": true, "const": true, "continue": true, "default": true,
+	"defer": true, "else": true, "fallthrough": true, "for": true, "func": true, "go": true,
+	"goto": true, "if": true, "import": true, "interface": true, "map": true, "package": true,
+	"range": true, "return": true, "select": true, "struct": true, "switch": true, "type": true, "var": true,
+}
+
+// writeGoEndpoint writes a method calling an endpoint. Structs are passed
+// and returned by pointer.
+func writeGoEndpoint(w *goWriter, e *Endpoint, taken, structs map[string]bool) {
+	// Query parameters are a struct, so adding one does not break callers
+	paramsType := ""
+	if len(e.QueryParams) > 0 {
+		paramsType = e.Name + "Params"
+		for taken[paramsType] {
+			paramsType += "_"
+		}
+		taken[paramsType] = true
+		w.printf("\n// %s are the query parameters of %s\n", paramsType, e.Name)
+		w.printf("type %s struct {\n", paramsType)
+		for _, param := range e.QueryParams {
+			w.printf("%s %s `url:\"%s,omitempty\"`", identifier(param.Name, true), w.typeName(param.Type), param.Name)
+			if param.Description != "" {
+				w.printf(" // %s", param.Description)
+			}
+			w.printf("\n")
+		}
+		w.printf("}\n")
+	}
+
+	// Arguments: path parameters in order, the query and the body
+	args := []string{"ctx context.Context"}
+	var pathArgs []string
+	for _, param := range e.PathParams {
+		name := identifier(param.Name, false)
+		if goReserved[name] {
+			name += "Param"
+		}
+		pathArgs = append(pathArgs, name)
+		args = append(args, name+" "+w.typeName(param.Type))
+	}
+	query := "nil"
+	if paramsType != "" {
+		args = append(args, "params *"+paramsType)
+		query = "sdk.Values(params)"
+	}
+	body := "nil"
+	if e.Body != nil {
+		bodyType := w.typeName(e.Body)
+		if e.Body.Kind == KindNamed && structs[e.Body.Name] {
+			bodyType = "*" + bodyType
+		}
+		args = append(args, "input "+bodyType)
+		body = "input"
+	}
+
+	path := strconv.Quote(e.Path)
+	if len(pathArgs) > 0 {
+		path = fmt.Sprintf("sdk.Pathf(%q, %s)", braceParam.ReplaceAllString(e.Path, "%v"), strings.Join(pathArgs, ", "))
+	}
+
+	w.printf("\n")
+	w.comment("", goMethodDoc(e))
+	w.printf("//\n//\t%s %s\n", e.Method, e.Path)
+	signature := fmt.Sprintf("func (c *Client) %s(%s)", e.Name, strings.Join(args, ", "))
+	call := fmt.Sprintf("%q, %s, %s, %s", e.Method, path, query, body)
+
+	switch e.Response {
+	case ResponseNone:
+		w.printf("%s error {\n", signature)
+		w.printf("_, err := c.sdk.Do(ctx, %s, nil)\nreturn err\n}\n", call)
+
+	case ResponsePage:
+		dataType := w.typeName(e.Data)
+		w.printf("%s (%s, *sdk.Meta, error) {\n", signature, dataType)
+		w.printf("var out %s\nmeta, err := c.sdk.Do(ctx, %s, &out)\nreturn out, meta, err\n}\n", dataType, call)
+
+	case ResponseFile:
+		w.imports["io"] = true
+		w.printf("%s (io.ReadCloser, error) {\n", signature)
+		w.printf("return c.sdk.Download(ctx, %s)\n}\n", call)
+
+	default:
+		dataType := w.typeName(e.Data)
+		do := "_, err := c.sdk.Do(ctx, %s, &out)\n"
+		if e.Response == ResponseJSON {
+			do = "err := c.sdk.DoRaw(ctx, %s, &out)\n"
+		}
+		if e.Data.Kind == KindNamed && structs[e.Data.Name] {
+			w.printf("%s (*%s, error) {\n", signature, dataType)
+			w.printf("var out %s\n"+do+"if err != nil {\nreturn nil, err\n}\nreturn &out, nil\n}\n", dataType, call)
+		} else {
+			w.printf("%s (%s, error) {\n", signature, dataType)
+			w.printf("var out %s\n"+do+"return out, err\n}\n", dataType, call)
+		}
+	}
+}
+
+// goMethodDoc is the handler's doc comment when it describes the method
+// under its generated name, and its summary otherwise
+func goMethodDoc(e *Endpoint) string {
+	if strings.HasPrefix(e.Doc, e.Name+" ") {
+		return e.Doc
+	}
+	summary := e.Summary
+	if summary == "" {
+		summary = "calls " + e.Method + " " + e.Path
+	} else {
+		summary = strings.ToLower(summary[:1]) + summary[1:]
+	}
+	return e.Name + " " + summary
+}
@@ -0,0 +1,352 @@
+package sdkgen
+
+import (
+	"go/ast"
+	"go/token"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"neonexcore/pkg/api"
+)
+
+// handler is a fiber handler method of a module and its annotations
+type handler struct {
+	receiver string // Without the pointer, e.g. "Controller"
+	method   string
+	file     *ast.File
+	pos      token.Pos
+	doc      []string // Doc comment lines that are not annotations
+
+	summary  string
+	router   string // @Router path
+	verb     string // @Router method
+	params   []paramAnnotation
+	success  []string // @Success lines after the status
+	produces []string
+	secured  bool
+}
+
+type paramAnnotation struct {
+	name, in, typ, description string
+	required                   bool
+}
+
+// findHandlers returns a package's methods taking a *fiber.Ctx, by
+// "(*Receiver).Method"
+func findHandlers(p *pkg) map[string]*handler {
+	handlers := make(map[string]*handler)
+	for _, file := range p.files {
+		var ctxPackage string
+		for name, path := range imports(file) {
+			if path == "github.com/gofiber/fiber/v2" {
+				ctxPackage = name
+			}
+		}
+		if ctxPackage == "" {
+			continue
+		}
+
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || !fn.Name.IsExported() || !takesCtx(fn, ctxPackage) {
+				continue
+			}
+			receiver := fn.Recv.List[0].Type
+			if star, ok := receiver.(*ast.StarExpr); ok {
+				receiver = star.X
+			}
+			ident, ok := receiver.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			h := &handler{receiver: ident.Name, method: fn.Name.Name, file: file, pos: fn.Pos()}
+			h.parseDoc(fn.Doc)
+			handlers["(*"+h.receiver+")."+h.method] = h
+		}
+	}
+	return handlers
+}
+
+// takesCtx reports whether a function is func(*fiber.Ctx) error
+func takesCtx(fn *ast.FuncDecl, ctxPackage string) bool {
+	params := fn.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) > 1 {
+		return false
+	}
+	star, ok := params[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Ctx" {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == ctxPackage
+}
+
+var annotationFields = regexp.MustCompile(`"[^"]*"|\S+`)
+
+// parseDoc reads swag annotations from a doc comment
+func (h *handler) parseDoc(doc *ast.CommentGroup) {
+	if doc == nil {
+		return
+	}
+	for _, line := range strings.Split(doc.Text(), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "@") {
+			if line != "" {
+				h.doc = append(h.doc, line)
+			}
+			continue
+		}
+		key, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+		switch strings.ToLower(key) {
+		case "@summary":
+			h.summary = rest
+		case "@router":
+			fields := strings.Fields(rest)
+			if len(fields) == 2 {
+				h.router = fields[0]
+				h.verb = strings.ToUpper(strings.Trim(fields[1], "[]"))
+			}
+		case "@param":
+			fields := annotationFields.FindAllString(rest, -1)
+			if len(fields) < 4 {
+				continue
+			}
+			param := paramAnnotation{name: fields[0], in: fields[1], typ: fields[2]}
+			param.required, _ = strconv.ParseBool(fields[3])
+			if len(fields) > 4 {
+				param.description = strings.Trim(fields[4], `"`)
+			}
+			h.params = append(h.params, param)
+		case "@success":
+			h.success = append(h.success, rest)
+		case "@produce":
+			h.produces = append(h.produces, strings.Fields(strings.ReplaceAll(rest, ",", " "))...)
+		case "@security":
+			h.secured = true
+		}
+	}
+}
+
+var (
+	colonParam = regexp.MustCompile(`:([A-Za-z0-9_]+)\??`)
+	braceParam = regexp.MustCompile(`\{([^}]+)\}`)
+)
+
+// annotatedEndpoints builds endpoints from handlers with @Router, in
+// source order
+func (l *loader) annotatedEndpoints(m *Module, p *pkg, handlers map[string]*handler, basePath string) []*Endpoint {
+	var ordered []*handler
+	for _, h := range handlers {
+		if h.router != "" {
+			ordered = append(ordered, h)
+		}
+	}
+	// Files are parsed in name order, so positions are source order
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].pos < ordered[j].pos })
+
+	var endpoints []*Endpoint
+	for _, h := range ordered {
+		path := strings.TrimRight(basePath, "/") + h.router
+		if e := l.endpoint(m, p, h, h.verb, path); e != nil {
+			endpoints = append(endpoints, e)
+		}
+	}
+	return endpoints
+}
+
+// routeEndpoints builds endpoints from the routes a module registered,
+// typed by the annotations of their handlers
+func (l *loader) routeEndpoints(m *Module, p *pkg, handlers map[string]*handler, table *api.RouteTable) []*Endpoint {
+	var endpoints []*Endpoint
+	for _, route := range table.Routes {
+		if route.Module != m.Name || route.Method == "USE" {
+			continue
+		}
+		name, found := strings.CutPrefix(route.Handler, p.name+".")
+		h := handlers[name]
+		if !found || h == nil {
+			m.Warnings = append(m.Warnings, route.Method+" "+route.Path+": handler "+route.Handler+" is not a method of the module")
+			continue
+		}
+
+		path := colonParam.ReplaceAllString(route.Path, "{$1}")
+		e := l.endpoint(m, p, h, route.Method, path)
+		if e == nil {
+			continue
+		}
+		if len(route.Auth) > 0 {
+			e.Auth = true
+		}
+		endpoints = append(endpoints, e)
+	}
+	return endpoints
+}
+
+// endpoint types a route by its handler's annotations; a handler without
+// any takes and returns any JSON. It returns nil for redirects.
+func (l *loader) endpoint(m *Module, p *pkg, h *handler, method, path string) *Endpoint {
+	e := &Endpoint{
+		Name:    h.method,
+		Summary: h.summary,
+		Doc:     strings.Join(h.doc, "\n"),
+		Method:  method,
+		Path:    path,
+		Auth:    h.secured,
+	}
+	where := method + " " + path
+
+	annotated := make(map[string]paramAnnotation)
+	for _, param := range h.params {
+		switch param.in {
+		case "path":
+			annotated[param.name] = param
+		case "query":
+			e.QueryParams = append(e.QueryParams, &Param{
+				Name:        param.name,
+				Type:        paramType(param.typ),
+				Required:    param.required,
+				Description: param.description,
+			})
+		case "body":
+			t, err := l.parseType(p, h.file, param.typ)
+			if err != nil {
+				m.Warnings = append(m.Warnings, where+": "+err.Error())
+				t = anyType
+			}
+			e.Body = t
+		}
+	}
+	for _, match := range braceParam.FindAllStringSubmatch(path, -1) {
+		param := &Param{Name: match[1], Type: &Type{Kind: KindBasic, Name: "string"}, Required: true}
+		if a, ok := annotated[match[1]]; ok {
+			param.Type, param.Description = paramType(a.typ), a.description
+		}
+		e.PathParams = append(e.PathParams, param)
+	}
+
+	if h.router == "" {
+		if method == "POST" || method == "PUT" || method == "PATCH" {
+			e.Body = anyType
+		}
+		e.Response, e.Data = ResponseData, anyType
+		return e
+	}
+
+	if !l.response(m, p, h, e) {
+		m.Warnings = append(m.Warnings, where+": redirects")
+		return nil
+	}
+	if e.Response == ResponsePage && !hasParam(e.QueryParams, "page") {
+		e.QueryParams = append(e.QueryParams,
+			&Param{Name: "page", Type: &Type{Kind: KindBasic, Name: "int"}, Description: "Page, from 1"},
+			&Param{Name: "limit", Type: &Type{Kind: KindBasic, Name: "int"}, Description: "Items per page, at most 100"})
+	}
+	return e
+}
+
+// response reads the first 2xx @Success of a handler, returning false for
+// a redirect
+func (l *loader) response(m *Module, p *pkg, h *handler, e *Endpoint) bool {
+	for _, produce := range h.produces {
+		if produce != "json" && produce != "application/json" {
+			e.Response = ResponseFile
+			return true
+		}
+	}
+
+	for _, success := range h.success {
+		fields := strings.Fields(success)
+		if len(fields) == 0 {
+			continue
+		}
+		status, _ := strconv.Atoi(fields[0])
+		if status >= 300 && status < 400 {
+			return false
+		}
+		if status < 200 || status >= 300 {
+			continue
+		}
+		if status == 204 || len(fields) < 3 || !strings.HasPrefix(fields[1], "{") {
+			e.Response = ResponseNone
+			return true
+		}
+
+		kind, typeText := strings.Trim(fields[1], "{}"), fields[2]
+		switch kind {
+		case "file", "string":
+			e.Response = ResponseFile
+			return true
+		}
+
+		base, data, enveloped := strings.Cut(typeText, "{")
+		switch base {
+		case "api.Response", "api.PaginatedResponse":
+			e.Response = ResponseNone
+			if !enveloped {
+				if base == "api.PaginatedResponse" {
+					e.Response, e.Data = ResponsePage, &Type{Kind: KindSlice, Elem: anyType}
+				}
+				return true
+			}
+			data = strings.TrimSuffix(strings.TrimPrefix(data, "data="), "}")
+			t, err := l.parseType(p, h.file, data)
+			if err != nil {
+				m.Warnings = append(m.Warnings, e.Method+" "+e.Path+": "+err.Error())
+				t = anyType
+			}
+			e.Response, e.Data = ResponseData, t
+			if base == "api.PaginatedResponse" {
+				if t.Kind != KindSlice {
+					t = &Type{Kind: KindSlice, Elem: t}
+				}
+				e.Response, e.Data = ResponsePage, t
+			}
+		default:
+			t, err := l.parseType(p, h.file, typeText)
+			if err != nil {
+				m.Warnings = append(m.Warnings, e.Method+" "+e.Path+": "+err.Error())
+				t = anyType
+			}
+			if kind == "array" {
+				t = &Type{Kind: KindSlice, Elem: t}
+			}
+			e.Response, e.Data = ResponseJSON, t
+		}
+		return true
+	}
+	e.Response = ResponseNone
+	return true
+}
+
+// paramType maps a swag parameter type to a Go type
+func paramType(typ string) *Type {
+	switch strings.ToLower(typ) {
+	case "int", "integer":
+		return &Type{Kind: KindBasic, Name: "int"}
+	case "int64", "uint", "uint64", "int32":
+		return &Type{Kind: KindBasic, Name: strings.ToLower(typ)}
+	case "number", "float64":
+		return &Type{Kind: KindBasic, Name: "float64"}
+	case "bool", "boolean":
+		return &Type{Kind: KindBasic, Name: "bool"}
+	case "array", "[]string":
+		return &Type{Kind: KindSlice, Elem: &Type{Kind: KindBasic, Name: "string"}}
+	}
+	return &Type{Kind: KindBasic, Name: "string"}
+}
+
+func hasParam(params []*Param, name string) bool {
+	for _, param := range params {
+		if param.Name == name {
+			return true
+		}
+	}
+	return false
+}
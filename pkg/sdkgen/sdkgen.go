@@ -0,0 +1,241 @@
+// Package sdkgen generates typed clients for modules' REST endpoints, in Go
+// on top of pkg/sdk and in TypeScript. Endpoints and their request and
+// response types come from the swag annotations of module handlers and
+// the Go types they name; when a route table is given, methods and paths
+// come from the routes actually registered.
+package sdkgen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"neonexcore/pkg/api"
+)
+
+// GeneratedMarker is the comment starting every generated file
+const GeneratedMarker = "Code generated by neonex client:generate. DO NOT EDIT."
+
+// Options are where endpoints come from
+type Options struct {
+	// Routes are the app's registered routes. Without them paths are
+	// those of @Router annotations under BasePath.
+	Routes   *api.RouteTable
+	BasePath string // Default "/api/v1"
+}
+
+// Module is the REST API of one module
+type Module struct {
+	Name      string // As registered, e.g. "links"
+	Endpoints []*Endpoint
+	Types     []*TypeDecl // Named types the endpoints use, in order of use
+	Warnings  []string    // Handlers left out and why
+}
+
+// ResponseKind is how an endpoint answers
+type ResponseKind int
+
+const (
+	ResponseNone ResponseKind = iota // Envelope without data, or 204
+	ResponseData                     // Data in the envelope
+	ResponsePage                     // A list in the envelope with pagination
+	ResponseJSON                     // JSON outside the envelope
+	ResponseFile                     // A file, image, text or event stream
+)
+
+// Endpoint is one route of a module
+type Endpoint struct {
+	Name        string // Client method, from the handler
+	Summary     string
+	Doc         string // The handler's doc comment without annotations
+	Method      string
+	Path        string // Full path with {param} placeholders
+	PathParams  []*Param
+	QueryParams []*Param
+	Body        *Type // nil when the request has none
+	Response    ResponseKind
+	Data        *Type // Of ResponseData, ResponsePage (the list) and ResponseJSON
+	Auth        bool  // Needs credentials
+}
+
+// Param is a path or query parameter
+type Param struct {
+	Name        string
+	Type        *Type
+	Required    bool
+	Description string
+}
+
+// Load reads the module in dir, whose package is named in routes as name
+func Load(dir, name string, opts Options) (*Module, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	root, modulePath, err := findModuleRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	if opts.BasePath == "" {
+		opts.BasePath = "/api/v1"
+	}
+
+	l := newLoader(root, modulePath)
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := l.load(modulePath + "/" + filepath.ToSlash(rel))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Module{Name: name}
+	handlers := findHandlers(pkg)
+	if opts.Routes != nil {
+		m.Endpoints = l.routeEndpoints(m, pkg, handlers, opts.Routes)
+	} else {
+		m.Endpoints = l.annotatedEndpoints(m, pkg, handlers, opts.BasePath)
+	}
+	nameEndpoints(m.Endpoints)
+	m.Types = l.decls
+	return m, nil
+}
+
+// LoadAll reads every module under modulesDir with handlers. Modules are
+// named by their directory, as the registry names them.
+func LoadAll(modulesDir string, opts Options) ([]*Module, error) {
+	entries, err := os.ReadDir(modulesDir)
+	if err != nil {
+		return nil, err
+	}
+	var modules []*Module
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		m, err := Load(filepath.Join(modulesDir, entry.Name()), entry.Name(), opts)
+		if err != nil {
+			return nil, fmt.Errorf("module %s: %w", entry.Name(), err)
+		}
+		if len(m.Endpoints) > 0 {
+			modules = append(modules, m)
+		}
+	}
+	return modules, nil
+}
+
+// Generate renders the clients of modules in the languages asked for
+// ("go", "typescript"), by slash-separated path relative to the output
+// directory: <module>client/ for Go and typescript/ for TypeScript.
+func Generate(modules []*Module, languages []string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, language := range languages {
+		switch strings.ToLower(language) {
+		case "go":
+			for _, m := range modules {
+				generated, err := GenerateGo(m)
+				if err != nil {
+					return nil, fmt.Errorf("module %s: %w", m.Name, err)
+				}
+				for name, data := range generated {
+					files[GoPackage(m)+"/"+name] = data
+				}
+			}
+		case "typescript", "ts":
+			for name, data := range GenerateTypeScript(modules) {
+				files["typescript/"+name] = data
+			}
+		default:
+			return nil, fmt.Errorf("unknown language %q (go, typescript)", language)
+		}
+	}
+	return files, nil
+}
+
+// GoPackage returns the name of a module's Go client package
+func GoPackage(m *Module) string {
+	return strings.ToLower(identifier(m.Name, false)) + "client"
+}
+
+// nameEndpoints makes client method names unique, qualifying clashing
+// ones with their verb and then a number
+func nameEndpoints(endpoints []*Endpoint) {
+	count := make(map[string]int)
+	for _, e := range endpoints {
+		count[e.Name]++
+	}
+	used := make(map[string]bool)
+	for _, e := range endpoints {
+		if count[e.Name] > 1 {
+			e.Name = identifier(strings.ToLower(e.Method), true) + e.Name
+		}
+		name := e.Name
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s%d", e.Name, i)
+		}
+		e.Name = name
+		used[name] = true
+	}
+}
+
+// findModuleRoot walks up from dir, an absolute path, to the directory
+// with go.mod
+func findModuleRoot(dir string) (root, modulePath string, err error) {
+	for current := dir; ; current = filepath.Dir(current) {
+		data, err := os.ReadFile(filepath.Join(current, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "module" {
+					return current, strings.Trim(fields[1], `"`), nil
+				}
+			}
+			return "", "", fmt.Errorf("no module line in %s", filepath.Join(current, "go.mod"))
+		}
+		if filepath.Dir(current) == current {
+			return "", "", fmt.Errorf("no go.mod above %s", dir)
+		}
+	}
+}
+
+// initialisms are written in capitals in Go names
+var initialisms = map[string]bool{
+	"api": true, "id": true, "ids": true, "ip": true, "json": true, "qr": true,
+	"uri": true, "url": true, "uuid": true, "http": true, "html": true, "csv": true,
+}
+
+// identifier turns a name such as "user_id" or "rotate-secret" into
+// "UserID" or, unexported, "userID"
+func identifier(name string, exported bool) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	var b strings.Builder
+	for i, word := range words {
+		switch {
+		case i == 0 && !exported:
+			b.WriteString(strings.ToLower(word[:1]) + word[1:])
+		case initialisms[strings.ToLower(word)]:
+			b.WriteString(strings.ToUpper(word))
+		default:
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	result := b.String()
+	if result == "" || result[0] >= '0' && result[0] <= '9' {
+		result = "P" + result
+	}
+	return result
+}
+
+// sortedNames returns the keys of a map in order
+func sortedNames[V any](values map[string]V) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
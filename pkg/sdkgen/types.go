@@ -0,0 +1,406 @@
+package sdkgen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Kind is the shape of a type
+type Kind int
+
+const (
+	KindBasic    Kind = iota // bool, string and numbers, by Go name
+	KindNamed                // A declared type, see TypeDecl
+	KindSlice                // Elem
+	KindMap                  // Key to Elem
+	KindPointer              // Elem, possibly null
+	KindStruct               // Inline struct of Fields
+	KindTime                 // time.Time, an RFC 3339 string
+	KindDuration             // time.Duration, nanoseconds
+	KindBytes                // []byte, a base64 string
+	KindAny                  // Any JSON value
+)
+
+// Type is a request or response type as JSON sees it
+type Type struct {
+	Kind   Kind
+	Name   string // Of KindBasic and KindNamed
+	Key    *Type
+	Elem   *Type
+	Fields []*Field
+}
+
+// Field is a struct field
+type Field struct {
+	Name     string // Go name
+	JSON     string // Key in JSON
+	Tag      string // The json tag, kept in Go clients
+	Type     *Type
+	Optional bool // omitempty
+	Embedded bool // Fields promoted from a named struct
+	Doc      string
+}
+
+// TypeDecl is a named type copied into a client
+type TypeDecl struct {
+	Name   string
+	Doc    string
+	Type   *Type
+	Values []*Value // Constants of the type
+}
+
+// Value is a constant of a named type
+type Value struct {
+	Name    string
+	Literal string // Go literal, e.g. "\"open\""
+}
+
+var anyType = &Type{Kind: KindAny}
+
+// pkg is a parsed Go package
+type pkg struct {
+	path   string
+	name   string
+	files  []*ast.File
+	types  map[string]*typeSpec
+	values map[string][]*Value // Constants by the name of their type
+}
+
+type typeSpec struct {
+	spec *ast.TypeSpec
+	doc  string
+	file *ast.File
+}
+
+// loader parses packages of the Go module on demand and copies the types
+// endpoints use into declarations
+type loader struct {
+	root       string
+	modulePath string
+	fset       *token.FileSet
+	pkgs       map[string]*pkg
+
+	decls    []*TypeDecl
+	declared map[string]*TypeDecl // By import path and name
+	names    map[string]bool      // Declaration names taken
+}
+
+func newLoader(root, modulePath string) *loader {
+	return &loader{
+		root:       root,
+		modulePath: modulePath,
+		fset:       token.NewFileSet(),
+		pkgs:       make(map[string]*pkg),
+		declared:   make(map[string]*TypeDecl),
+		names:      make(map[string]bool),
+	}
+}
+
+// load parses a package of the module by import path
+func (l *loader) load(path string) (*pkg, error) {
+	if p, ok := l.pkgs[path]; ok {
+		return p, nil
+	}
+	dir := filepath.Join(l.root, filepath.FromSlash(strings.TrimPrefix(path, l.modulePath)))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pkg{path: path, types: make(map[string]*typeSpec), values: make(map[string][]*Value)}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(l.fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		p.name = file.Name.Name
+		p.files = append(p.files, file)
+		p.index(file)
+	}
+	l.pkgs[path] = p
+	return p, nil
+}
+
+// index records a file's type declarations and typed constants
+func (p *pkg) index(file *ast.File) {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		switch gen.Tok {
+		case token.TYPE:
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				doc := ts.Doc
+				if doc == nil && len(gen.Specs) == 1 {
+					doc = gen.Doc
+				}
+				p.types[ts.Name.Name] = &typeSpec{spec: ts, doc: doc.Text(), file: file}
+			}
+		case token.CONST:
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				ident, ok := vs.Type.(*ast.Ident)
+				if !ok || len(vs.Names) != len(vs.Values) {
+					continue
+				}
+				for i, name := range vs.Names {
+					if lit, ok := vs.Values[i].(*ast.BasicLit); ok && name.IsExported() {
+						p.values[ident.Name] = append(p.values[ident.Name], &Value{Name: name.Name, Literal: lit.Value})
+					}
+				}
+			}
+		}
+	}
+}
+
+// imports maps the names a file refers to packages by to import paths
+func imports(file *ast.File) map[string]string {
+	names := make(map[string]string)
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := packageName(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		names[name] = path
+	}
+	return names
+}
+
+var majorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// packageName guesses the name of a package from its import path, as
+// "fiber" for github.com/gofiber/fiber/v2 and "yaml" for gopkg.in/yaml.v3
+func packageName(path string) string {
+	parts := strings.Split(path, "/")
+	name := parts[len(parts)-1]
+	if majorVersion.MatchString(name) && len(parts) > 1 {
+		name = parts[len(parts)-2]
+	}
+	name, _, _ = strings.Cut(name, ".")
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+var basicTypes = map[string]bool{
+	"bool": true, "string": true, "byte": true, "rune": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true,
+}
+
+// resolve converts a type expression in file of p. Types the client
+// cannot reproduce, such as ones from other modules, become any JSON.
+func (l *loader) resolve(p *pkg, file *ast.File, expr ast.Expr) *Type {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if basicTypes[e.Name] {
+			return &Type{Kind: KindBasic, Name: e.Name}
+		}
+		if e.Name == "any" || e.Name == "error" {
+			return anyType
+		}
+		return l.named(p, e.Name)
+
+	case *ast.SelectorExpr:
+		x, ok := e.X.(*ast.Ident)
+		if !ok {
+			return anyType
+		}
+		path := imports(file)[x.Name]
+		switch path + "." + e.Sel.Name {
+		case "time.Time":
+			return &Type{Kind: KindTime}
+		case "time.Duration":
+			return &Type{Kind: KindDuration}
+		case "gorm.io/gorm.DeletedAt", "database/sql.NullTime":
+			return &Type{Kind: KindPointer, Elem: &Type{Kind: KindTime}}
+		case "database/sql.NullString":
+			return &Type{Kind: KindPointer, Elem: &Type{Kind: KindBasic, Name: "string"}}
+		}
+		if !strings.HasPrefix(path, l.modulePath+"/") {
+			return anyType
+		}
+		other, err := l.load(path)
+		if err != nil {
+			return anyType
+		}
+		return l.named(other, e.Sel.Name)
+
+	case *ast.StarExpr:
+		return &Type{Kind: KindPointer, Elem: l.resolve(p, file, e.X)}
+
+	case *ast.ArrayType:
+		if ident, ok := e.Elt.(*ast.Ident); ok && (ident.Name == "byte" || ident.Name == "uint8") && e.Len == nil {
+			return &Type{Kind: KindBytes}
+		}
+		return &Type{Kind: KindSlice, Elem: l.resolve(p, file, e.Elt)}
+
+	case *ast.MapType:
+		return &Type{Kind: KindMap, Key: l.resolve(p, file, e.Key), Elem: l.resolve(p, file, e.Value)}
+
+	case *ast.StructType:
+		return &Type{Kind: KindStruct, Fields: l.fields(p, file, e)}
+
+	case *ast.ParenExpr:
+		return l.resolve(p, file, e.X)
+	}
+	// Interfaces, generics, functions and channels
+	return anyType
+}
+
+// named declares a type of p, once, and refers to it
+func (l *loader) named(p *pkg, name string) *Type {
+	key := p.path + "." + name
+	if decl, ok := l.declared[key]; ok {
+		if decl == nil {
+			return anyType
+		}
+		return &Type{Kind: KindNamed, Name: decl.Name}
+	}
+
+	spec, ok := p.types[name]
+	if !ok || spec.spec.TypeParams != nil || !ast.IsExported(name) {
+		l.declared[key] = nil
+		return anyType
+	}
+
+	// Other packages' types keep their name unless it is taken
+	declName := name
+	if l.names[declName] {
+		declName = identifier(p.name, true) + name
+	}
+	decl := &TypeDecl{Name: declName, Doc: spec.doc}
+	l.declared[key] = decl
+	l.names[declName] = true
+	l.decls = append(l.decls, decl)
+
+	decl.Type = l.resolve(p, spec.file, spec.spec.Type)
+	if decl.Type.Kind == KindBasic {
+		decl.Values = p.values[name]
+	}
+	return &Type{Kind: KindNamed, Name: declName}
+}
+
+// fields converts the fields of a struct that JSON encodes
+func (l *loader) fields(p *pkg, file *ast.File, st *ast.StructType) []*Field {
+	var fields []*Field
+	for _, f := range st.Fields.List {
+		tag := ""
+		if f.Tag != nil {
+			raw, _ := strconv.Unquote(f.Tag.Value)
+			tag = reflect.StructTag(raw).Get("json")
+		}
+		jsonName, options, _ := strings.Cut(tag, ",")
+		if jsonName == "-" && options == "" {
+			continue
+		}
+		doc := strings.TrimSpace(f.Doc.Text())
+		if doc == "" {
+			doc = strings.TrimSpace(f.Comment.Text())
+		}
+
+		if len(f.Names) == 0 {
+			fields = append(fields, l.embedded(p, file, f.Type, tag, jsonName, options, doc)...)
+			continue
+		}
+
+		for _, ident := range f.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			field := &Field{
+				Name:     ident.Name,
+				JSON:     jsonName,
+				Tag:      tag,
+				Type:     l.resolve(p, file, f.Type),
+				Optional: hasOption(options, "omitempty"),
+				Doc:      doc,
+			}
+			if field.JSON == "" {
+				field.JSON = ident.Name
+			}
+			if hasOption(options, "string") {
+				field.Type = &Type{Kind: KindBasic, Name: "string"}
+			}
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// embedded converts an embedded field: gorm.Model is spelled out, a
+// named struct is embedded and a tagged one is an ordinary field
+func (l *loader) embedded(p *pkg, file *ast.File, expr ast.Expr, tag, jsonName, options, doc string) []*Field {
+	typeExpr := expr
+	if star, ok := typeExpr.(*ast.StarExpr); ok {
+		typeExpr = star.X
+	}
+	var name string
+	switch e := typeExpr.(type) {
+	case *ast.Ident:
+		name = e.Name
+	case *ast.SelectorExpr:
+		name = e.Sel.Name
+		if x, ok := e.X.(*ast.Ident); ok && imports(file)[x.Name] == "gorm.io/gorm" && name == "Model" && jsonName == "" {
+			return gormModelFields()
+		}
+	}
+	if !ast.IsExported(name) {
+		return nil
+	}
+
+	t := l.resolve(p, file, expr)
+	if jsonName != "" {
+		return []*Field{{Name: name, JSON: jsonName, Tag: tag, Type: t, Optional: hasOption(options, "omitempty"), Doc: doc}}
+	}
+	if named := t; named.Kind == KindPointer {
+		t = named.Elem
+	}
+	if t.Kind != KindNamed {
+		return nil
+	}
+	return []*Field{{Name: t.Name, Type: t, Embedded: true, Doc: doc}}
+}
+
+// gormModelFields are the fields of gorm.Model, which has no json tags
+func gormModelFields() []*Field {
+	return []*Field{
+		{Name: "ID", JSON: "ID", Type: &Type{Kind: KindBasic, Name: "uint"}},
+		{Name: "CreatedAt", JSON: "CreatedAt", Type: &Type{Kind: KindTime}},
+		{Name: "UpdatedAt", JSON: "UpdatedAt", Type: &Type{Kind: KindTime}},
+		{Name: "DeletedAt", JSON: "DeletedAt", Type: &Type{Kind: KindPointer, Elem: &Type{Kind: KindTime}}},
+	}
+}
+
+func hasOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// parseType resolves a type written in an annotation, such as "[]Link" or
+// "api.SwaggerSpec", in the file of its handler
+func (l *loader) parseType(p *pkg, file *ast.File, text string) (*Type, error) {
+	expr, err := parser.ParseExpr(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid type %q", text)
+	}
+	return l.resolve(p, file, expr), nil
+}
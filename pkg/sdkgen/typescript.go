@@ -0,0 +1,446 @@
+package sdkgen
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// GenerateTypeScript renders TypeScript clients: client.ts with the
+// fetch-based runtime, a file per module and index.ts exporting them all
+func GenerateTypeScript(modules []*Module) map[string][]byte {
+	files := map[string][]byte{"client.ts": []byte(tsHeader + tsRuntime)}
+
+	var index strings.Builder
+	index.WriteString(tsHeader)
+	index.WriteString("export * from \"./client\";\n")
+	for _, m := range modules {
+		name := tsFileName(m)
+		files[name+".ts"] = []byte(generateTypeScriptModule(m))
+		fmt.Fprintf(&index, "export * as %s from \"./%s\";\n", identifier(m.Name, false), name)
+	}
+	files["index.ts"] = []byte(index.String())
+	return files
+}
+
+const tsHeader = "// " + GeneratedMarker + "\n\n"
+
+func tsFileName(m *Module) string {
+	return strings.ToLower(strings.ReplaceAll(m.Name, "_", "-"))
+}
+
+func generateTypeScriptModule(m *Module) string {
+	var b strings.Builder
+	b.WriteString(tsHeader)
+	imports := []string{"Client"}
+	for _, e := range m.Endpoints {
+		if e.Response == ResponsePage {
+			imports = append(imports, "Page")
+			break
+		}
+	}
+	fmt.Fprintf(&b, "import { %s } from \"./client\";\n", strings.Join(imports, ", "))
+
+	for _, decl := range m.Types {
+		b.WriteString("\n")
+		tsComment(&b, "", decl.Doc)
+		if decl.Type.Kind != KindStruct {
+			fmt.Fprintf(&b, "export type %s = %s;\n", decl.Name, tsEnum(decl))
+			continue
+		}
+		var extends []string
+		for _, field := range decl.Type.Fields {
+			if field.Embedded {
+				extends = append(extends, field.Type.Name)
+			}
+		}
+		fmt.Fprintf(&b, "export interface %s", decl.Name)
+		if len(extends) > 0 {
+			fmt.Fprintf(&b, " extends %s", strings.Join(extends, ", "))
+		}
+		b.WriteString(" ")
+		b.WriteString(tsFields(decl.Type.Fields, ""))
+		b.WriteString("\n")
+	}
+
+	className := identifier(m.Name, true) + "Client"
+	taken := map[string]bool{"Client": true, "Page": true, className: true}
+	for _, decl := range m.Types {
+		taken[decl.Name] = true
+	}
+	paramsTypes := make(map[*Endpoint]string)
+	for _, e := range m.Endpoints {
+		if len(e.QueryParams) == 0 {
+			continue
+		}
+		paramsType := e.Name + "Params"
+		for taken[paramsType] {
+			paramsType += "_"
+		}
+		taken[paramsType] = true
+		paramsTypes[e] = paramsType
+		fmt.Fprintf(&b, "\n/** Query parameters of %s */\n", tsMethodName(e))
+		fmt.Fprintf(&b, "export interface %s {\n", paramsType)
+		for _, param := range e.QueryParams {
+			tsComment(&b, "  ", param.Description)
+			fmt.Fprintf(&b, "  %s?: %s;\n", tsKey(param.Name), tsType(param.Type))
+		}
+		b.WriteString("}\n")
+	}
+
+	fmt.Fprintf(&b, "\n/** Calls the endpoints of the %s module */\n", m.Name)
+	fmt.Fprintf(&b, "export class %s {\n  constructor(private readonly client: Client) {}\n", className)
+	for _, e := range m.Endpoints {
+		writeTypeScriptEndpoint(&b, e, paramsTypes[e])
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func writeTypeScriptEndpoint(b *strings.Builder, e *Endpoint, paramsType string) {
+	var args []string
+	path := e.Path
+	for _, param := range e.PathParams {
+		name := identifier(param.Name, false)
+		args = append(args, name+": "+tsType(param.Type))
+		path = strings.ReplaceAll(path, "{"+param.Name+"}", "${encodeURIComponent(String("+name+"))}")
+	}
+	query := "undefined"
+	if paramsType != "" {
+		args = append(args, "params?: "+paramsType)
+		query = "params"
+	}
+	body := "undefined"
+	if e.Body != nil {
+		args = append(args, "input: "+tsType(e.Body))
+		body = "input"
+	}
+
+	var result, call string
+	request := fmt.Sprintf("%q, `%s`, %s, %s", e.Method, path, query, body)
+	switch e.Response {
+	case ResponseNone:
+		result, call = "void", "this.client.request<void>("+request+")"
+	case ResponsePage:
+		elem := tsType(e.Data.Elem)
+		result, call = "Page<"+elem+">", "this.client.page<"+elem+">("+request+")"
+	case ResponseFile:
+		result, call = "Blob", "this.client.blob("+request+")"
+	case ResponseJSON:
+		result = tsType(e.Data)
+		call = "this.client.raw<" + result + ">(" + request + ")"
+	default:
+		result = tsType(e.Data)
+		call = "this.client.request<" + result + ">(" + request + ")"
+	}
+
+	b.WriteString("\n")
+	doc := e.Summary
+	if doc == "" {
+		doc = e.Method + " " + e.Path
+	} else {
+		doc += "\n\n" + e.Method + " " + e.Path
+	}
+	tsComment(b, "  ", doc)
+	fmt.Fprintf(b, "  %s(%s): Promise<%s> {\n    return %s;\n  }\n", tsMethodName(e), strings.Join(args, ", "), result, call)
+}
+
+// tsMethodName lowers the leading capitals of the endpoint's name, so
+// QRCode becomes qrCode
+func tsMethodName(e *Endpoint) string {
+	name := e.Name
+	n := 0
+	for n < len(name) && name[n] >= 'A' && name[n] <= 'Z' {
+		n++
+	}
+	if n > 1 && n < len(name) {
+		n--
+	}
+	return strings.ToLower(name[:n]) + name[n:]
+}
+
+// tsType writes a type as TypeScript
+func tsType(t *Type) string {
+	switch t.Kind {
+	case KindBasic:
+		switch t.Name {
+		case "string":
+			return "string"
+		case "bool":
+			return "boolean"
+		}
+		return "number"
+	case KindNamed:
+		return t.Name
+	case KindSlice:
+		elem := tsType(t.Elem)
+		if strings.ContainsAny(elem, "| ") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case KindMap:
+		return "Record<string, " + tsType(t.Elem) + ">"
+	case KindPointer:
+		return tsType(t.Elem) + " | null"
+	case KindStruct:
+		return tsFields(t.Fields, "")
+	case KindTime, KindBytes:
+		return "string"
+	case KindDuration:
+		return "number"
+	}
+	return "unknown"
+}
+
+// tsFields writes struct fields as an object type. Embedded structs are
+// left to the caller.
+func tsFields(fields []*Field, indent string) string {
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, field := range fields {
+		if field.Embedded {
+			continue
+		}
+		tsComment(&b, indent+"  ", field.Doc)
+		optional := ""
+		if field.Optional {
+			optional = "?"
+		}
+		t := tsType(field.Type)
+		if field.Type.Kind == KindStruct {
+			t = tsFields(field.Type.Fields, indent+"  ")
+		}
+		fmt.Fprintf(&b, "%s  %s%s: %s;\n", indent, tsKey(field.JSON), optional, t)
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+// tsEnum writes a named basic type, as a union of its constants if it has
+// any
+func tsEnum(decl *TypeDecl) string {
+	if len(decl.Values) == 0 {
+		return tsType(decl.Type)
+	}
+	values := make([]string, len(decl.Values))
+	for i, value := range decl.Values {
+		values[i] = value.Literal
+		if strings.HasPrefix(value.Literal, "`") {
+			values[i] = fmt.Sprintf("%q", strings.Trim(value.Literal, "`"))
+		}
+	}
+	return strings.Join(values, " | ")
+}
+
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func tsKey(name string) string {
+	if tsIdentifier.MatchString(name) {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+func tsComment(b *strings.Builder, indent, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) == 1 {
+		fmt.Fprintf(b, "%s/** %s */\n", indent, strings.ReplaceAll(lines[0], "*/", "* /"))
+		return
+	}
+	fmt.Fprintf(b, "%s/**\n", indent)
+	for _, line := range lines {
+		line = strings.TrimRight(strings.ReplaceAll(line, "*/", "* /"), " ")
+		if line == "" {
+			fmt.Fprintf(b, "%s *\n", indent)
+			continue
+		}
+		fmt.Fprintf(b, "%s * %s\n", indent, line)
+	}
+	fmt.Fprintf(b, "%s */\n", indent)
+}
+
+// tsRuntime is client.ts: requests in the API's envelope, retries and
+// authentication, as pkg/sdk does for Go
+const tsRuntime = `export interface RetryOptions {
+  /** Including the first; 1 disables retries */
+  maxAttempts: number;
+  /** Before the second attempt, doubling after */
+  minDelayMs: number;
+  maxDelayMs: number;
+}
+
+export interface ClientOptions {
+  /** The API's origin, such as "https://api.example.com" */
+  baseURL: string;
+  /** A JWT access token or portal API key sent as a bearer token, or a function returning one for each request */
+  token?: string | (() => string | Promise<string>);
+  /** A portal API key sent in the X-API-Key header */
+  apiKey?: string;
+  headers?: Record<string, string>;
+  /**
+   * Network errors and 502, 503 and 504 responses are retried for
+   * idempotent methods, 429 responses for all; false disables retries
+   */
+  retry?: RetryOptions | false;
+  fetch?: typeof fetch;
+}
+
+/** Pagination of a list response */
+export interface Meta {
+  page?: number;
+  limit?: number;
+  total?: number;
+  total_pages?: number;
+  has_next_page?: boolean;
+  has_prev_page?: boolean;
+  next_page?: number;
+  prev_page?: number;
+}
+
+export interface Page<T> {
+  data: T[];
+  meta?: Meta;
+}
+
+/** A response with an error status */
+export class APIError extends Error {
+  constructor(
+    readonly status: number,
+    message: string,
+    /** Validation errors by field, when sent */
+    readonly errors?: unknown,
+    readonly body?: string,
+  ) {
+    super(message || ` + "`HTTP ${status}`" + `);
+    this.name = "APIError";
+  }
+}
+
+export type Query = Record<string, string | number | boolean | Array<string | number> | null | undefined>;
+
+interface Envelope<T> {
+  success: boolean;
+  message?: string;
+  data?: T;
+  errors?: unknown;
+  meta?: Meta;
+}
+
+const defaultRetry: RetryOptions = { maxAttempts: 3, minDelayMs: 200, maxDelayMs: 5000 };
+const idempotent = new Set(["GET", "HEAD", "PUT", "DELETE", "OPTIONS"]);
+
+export class Client {
+  private readonly baseURL: string;
+  private readonly retry: RetryOptions;
+  private readonly fetchFn: typeof fetch;
+
+  constructor(private readonly options: ClientOptions) {
+    this.baseURL = options.baseURL.replace(/\/+$/, "");
+    this.retry = options.retry === false ? { ...defaultRetry, maxAttempts: 1 } : options.retry ?? defaultRetry;
+    this.fetchFn = options.fetch ?? globalThis.fetch.bind(globalThis);
+  }
+
+  /** Sends a request and returns the data of the response envelope */
+  async request<T>(method: string, path: string, query?: Query | object, body?: unknown): Promise<T> {
+    const envelope = await this.json<Envelope<T>>(method, path, query, body);
+    return envelope?.data as T;
+  }
+
+  /** Sends a request for a list and returns it with its pagination */
+  async page<T>(method: string, path: string, query?: Query | object, body?: unknown): Promise<Page<T>> {
+    const envelope = await this.json<Envelope<T[]>>(method, path, query, body);
+    return { data: envelope?.data ?? [], meta: envelope?.meta };
+  }
+
+  /** Sends a request whose response is JSON outside the envelope */
+  async raw<T>(method: string, path: string, query?: Query | object, body?: unknown): Promise<T> {
+    return this.json<T>(method, path, query, body);
+  }
+
+  /** Sends a request for a file, image or text */
+  async blob(method: string, path: string, query?: Query | object, body?: unknown): Promise<Blob> {
+    const response = await this.send(method, path, query, body, "*/*");
+    return response.blob();
+  }
+
+  private async json<T>(method: string, path: string, query?: Query | object, body?: unknown): Promise<T> {
+    const response = await this.send(method, path, query, body, "application/json");
+    const text = await response.text();
+    return (text ? JSON.parse(text) : undefined) as T;
+  }
+
+  private async send(method: string, path: string, query: Query | object | undefined, body: unknown, accept: string): Promise<Response> {
+    const url = this.baseURL + path + encodeQuery(query as Query | undefined);
+    const payload = body === undefined ? undefined : JSON.stringify(body);
+
+    for (let attempt = 1; ; attempt++) {
+      const headers: Record<string, string> = { Accept: accept, ...this.options.headers };
+      if (payload !== undefined) headers["Content-Type"] = "application/json";
+      await this.authenticate(headers);
+
+      let response: Response | undefined;
+      let failure: unknown;
+      try {
+        response = await this.fetchFn(url, { method, headers, body: payload });
+      } catch (error) {
+        failure = error;
+      }
+
+      const retryable = response
+        ? response.status === 429 || ([502, 503, 504].includes(response.status) && idempotent.has(method))
+        : idempotent.has(method);
+      if (!retryable || attempt >= this.retry.maxAttempts) {
+        if (!response) throw failure;
+        if (!response.ok) throw await responseError(response);
+        return response;
+      }
+      await sleep(this.delay(attempt, response));
+    }
+  }
+
+  private async authenticate(headers: Record<string, string>): Promise<void> {
+    const { token, apiKey } = this.options;
+    if (token !== undefined) {
+      headers["Authorization"] = "Bearer " + (typeof token === "function" ? await token() : token);
+    }
+    if (apiKey !== undefined) headers["X-API-Key"] = apiKey;
+  }
+
+  private delay(attempt: number, response?: Response): number {
+    let delay = Math.min(this.retry.minDelayMs * 2 ** (attempt - 1), this.retry.maxDelayMs);
+    delay += Math.random() * delay * 0.2;
+    const after = Number(response?.headers.get("Retry-After"));
+    if (after > 0) delay = Math.max(delay, after * 1000);
+    return Math.min(delay, this.retry.maxDelayMs);
+  }
+}
+
+function encodeQuery(query?: Query): string {
+  if (!query) return "";
+  const params = new URLSearchParams();
+  for (const [key, value] of Object.entries(query)) {
+    if (value === undefined || value === null || value === "") continue;
+    for (const item of Array.isArray(value) ? value : [value]) params.append(key, String(item));
+  }
+  const encoded = params.toString();
+  return encoded ? "?" + encoded : "";
+}
+
+async function responseError(response: Response): Promise<APIError> {
+  const text = await response.text();
+  try {
+    const envelope = JSON.parse(text) as Envelope<unknown>;
+    return new APIError(response.status, envelope.message ?? "", envelope.errors ?? undefined, text);
+  } catch {
+    return new APIError(response.status, text.slice(0, 200), undefined, text);
+  }
+}
+
+function sleep(ms: number): Promise<void> {
+  return new Promise((resolve) => setTimeout(resolve, ms));
+}
+`
@@ -0,0 +1,148 @@
+package servicemesh
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Backend stores a registry's instances where other processes discover
+// them, such as Consul or etcd. Instances are leased for their LeaseTTL and
+// removed by the backend when not renewed.
+type Backend interface {
+	// Register adds or replaces an instance, leased for its LeaseTTL
+	Register(ctx context.Context, instance *ServiceInstance) error
+	// Renew extends the lease of an instance this process registered; it
+	// returns ErrInstanceNotFound when the lease has expired
+	Renew(ctx context.Context, serviceName, instanceID string) error
+	// Deregister removes an instance
+	Deregister(ctx context.Context, serviceName, instanceID string) error
+	// Instances returns the registered instances of a service
+	Instances(ctx context.Context, serviceName string) ([]*ServiceInstance, error)
+	// Watch calls update with a service's instances, then again whenever
+	// they change, until ctx is done or the watch fails
+	Watch(ctx context.Context, serviceName string, update func([]*ServiceInstance)) error
+	// Close releases the backend's connections
+	Close() error
+}
+
+// RegistryConfig configures the backend of a service registry
+type RegistryConfig struct {
+	Driver  string        // memory, consul, etcd or kubernetes
+	Timeout time.Duration // Of each request, except watches
+
+	ConsulAddress    string // e.g. http://127.0.0.1:8500, of the local agent
+	ConsulToken      string
+	ConsulDatacenter string
+
+	EtcdEndpoints []string // e.g. http://127.0.0.1:2379
+	EtcdPrefix    string   // Instances are kept under <prefix>/<service>/<instance>
+	EtcdUsername  string
+	EtcdPassword  string
+
+	KubeconfigPath      string   // Outside a cluster; the pod's service account is used when empty
+	KubernetesNamespace string   // Of services named without one; the pod's or kubeconfig context's when empty
+	KubernetesPortName  string   // Port instances are discovered on; a Service's first when empty
+	KubernetesLabels    []string // Pod labels copied into instance metadata
+}
+
+// DefaultRegistryConfig returns the default registry configuration, an
+// in-memory registry
+func DefaultRegistryConfig() RegistryConfig {
+	return RegistryConfig{
+		Driver:        "memory",
+		Timeout:       5 * time.Second,
+		ConsulAddress: "http://127.0.0.1:8500",
+		EtcdEndpoints: []string{"http://127.0.0.1:2379"},
+		EtcdPrefix:    "/neonex/services",
+
+		KubernetesLabels: []string{"version", "region"},
+	}
+}
+
+// LoadRegistryConfig loads registry configuration from environment
+func LoadRegistryConfig() RegistryConfig {
+	config := DefaultRegistryConfig()
+
+	if driver := os.Getenv("SERVICE_REGISTRY_DRIVER"); driver != "" {
+		config.Driver = driver
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("SERVICE_REGISTRY_TIMEOUT")); err == nil && timeout > 0 {
+		config.Timeout = timeout
+	}
+
+	if address := os.Getenv("CONSUL_HTTP_ADDR"); address != "" {
+		if !strings.Contains(address, "://") {
+			address = "http://" + address
+		}
+		config.ConsulAddress = address
+	}
+	config.ConsulToken = os.Getenv("CONSUL_HTTP_TOKEN")
+	config.ConsulDatacenter = os.Getenv("CONSUL_DATACENTER")
+
+	if endpoints := os.Getenv("ETCD_ENDPOINTS"); endpoints != "" {
+		config.EtcdEndpoints = nil
+		for _, endpoint := range strings.Split(endpoints, ",") {
+			if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+				config.EtcdEndpoints = append(config.EtcdEndpoints, endpoint)
+			}
+		}
+	}
+	if prefix := os.Getenv("ETCD_PREFIX"); prefix != "" {
+		config.EtcdPrefix = prefix
+	}
+	config.EtcdUsername = os.Getenv("ETCD_USERNAME")
+	config.EtcdPassword = os.Getenv("ETCD_PASSWORD")
+
+	// KUBECONFIG may list several files; the first is used
+	if kubeconfig := os.Getenv("KUBECONFIG"); kubeconfig != "" {
+		config.KubeconfigPath = strings.Split(kubeconfig, string(os.PathListSeparator))[0]
+	}
+	config.KubernetesNamespace = os.Getenv("K8S_NAMESPACE")
+	config.KubernetesPortName = os.Getenv("K8S_PORT_NAME")
+	if labels := os.Getenv("K8S_POD_LABELS"); labels != "" {
+		config.KubernetesLabels = nil
+		for _, label := range strings.Split(labels, ",") {
+			if label = strings.TrimSpace(label); label != "" {
+				config.KubernetesLabels = append(config.KubernetesLabels, label)
+			}
+		}
+	}
+
+	return config
+}
+
+// NewBackend creates the backend selected by config.Driver, nil for the
+// in-memory registry
+func NewBackend(config RegistryConfig) (Backend, error) {
+	switch config.Driver {
+	case "", "memory":
+		return nil, nil
+	case "consul":
+		return NewConsulBackend(config), nil
+	case "etcd":
+		return NewEtcdBackend(config)
+	case "kubernetes":
+		return NewKubernetesBackend(config)
+	default:
+		return nil, fmt.Errorf("unknown service registry driver: %s", config.Driver)
+	}
+}
+
+// NewServiceRegistryFromEnv creates a registry with the backend selected
+// by SERVICE_REGISTRY_DRIVER
+func NewServiceRegistryFromEnv() (*ServiceRegistry, error) {
+	config := LoadRegistryConfig()
+	backend, err := NewBackend(config)
+	if err != nil {
+		return nil, err
+	}
+	if backend == nil {
+		return NewServiceRegistry(""), nil
+	}
+	registry := NewServiceRegistryWithBackend(backend)
+	registry.timeout = config.Timeout
+	return registry, nil
+}
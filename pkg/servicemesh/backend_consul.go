@@ -0,0 +1,274 @@
+package servicemesh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// consulWait is how long a Consul watch blocks waiting for a change
+const consulWait = 5 * time.Minute
+
+// ConsulBackend registers instances with the local Consul agent, over its
+// HTTP API. Each instance gets a TTL check that renewals pass; Consul marks
+// an instance critical once its lease runs out, and deregisters it a
+// minute later.
+type ConsulBackend struct {
+	address    string
+	token      string
+	datacenter string
+	client     *http.Client
+}
+
+// NewConsulBackend creates a Consul backend for the agent at
+// config.ConsulAddress
+func NewConsulBackend(config RegistryConfig) *ConsulBackend {
+	return &ConsulBackend{
+		address:    strings.TrimSuffix(config.ConsulAddress, "/"),
+		token:      config.ConsulToken,
+		datacenter: config.ConsulDatacenter,
+		client:     &http.Client{},
+	}
+}
+
+type consulRegistration struct {
+	ID      string
+	Name    string
+	Address string
+	Port    int
+	Meta    map[string]string `json:",omitempty"`
+	Check   consulCheck
+}
+
+type consulCheck struct {
+	CheckID                        string
+	Name                           string
+	TTL                            string
+	Status                         string
+	DeregisterCriticalServiceAfter string
+}
+
+type consulServiceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		ID      string
+		Service string
+		Address string
+		Port    int
+		Meta    map[string]string
+	}
+	Checks []struct {
+		Status string
+	}
+}
+
+// checkID is the ID of an instance's TTL check
+func (c *ConsulBackend) checkID(instanceID string) string {
+	return "service:" + instanceID
+}
+
+// Register registers an instance with the agent, with a TTL check of its
+// lease. Metadata becomes service meta, with the protocol as "protocol".
+func (c *ConsulBackend) Register(ctx context.Context, instance *ServiceInstance) error {
+	meta := make(map[string]string, len(instance.Metadata)+1)
+	for key, value := range instance.Metadata {
+		meta[key] = value
+	}
+	if instance.Protocol != "" {
+		meta["protocol"] = instance.Protocol
+	}
+
+	// Consul reaps critical services a minute after at the earliest
+	deregisterAfter := 2 * instance.LeaseTTL
+	if deregisterAfter < time.Minute {
+		deregisterAfter = time.Minute
+	}
+
+	registration := consulRegistration{
+		ID:      instance.InstanceID,
+		Name:    instance.ServiceName,
+		Address: instance.Host,
+		Port:    instance.Port,
+		Meta:    meta,
+		Check: consulCheck{
+			CheckID:                        c.checkID(instance.InstanceID),
+			Name:                           "Lease of " + instance.InstanceID,
+			TTL:                            instance.LeaseTTL.String(),
+			Status:                         "passing",
+			DeregisterCriticalServiceAfter: deregisterAfter.String(),
+		},
+	}
+	_, _, err := c.do(ctx, http.MethodPut, "/v1/agent/service/register", nil, registration)
+	return err
+}
+
+// Renew passes the instance's TTL check
+func (c *ConsulBackend) Renew(ctx context.Context, serviceName, instanceID string) error {
+	_, status, err := c.do(ctx, http.MethodPut, "/v1/agent/check/pass/"+url.PathEscape(c.checkID(instanceID)), nil, nil)
+	// Older agents answer 500 for an unknown check
+	if status == http.StatusNotFound || err != nil && strings.Contains(err.Error(), "Unknown check") {
+		return fmt.Errorf("%w: %s/%s", ErrInstanceNotFound, serviceName, instanceID)
+	}
+	return err
+}
+
+// Deregister removes an instance and its check from the agent
+func (c *ConsulBackend) Deregister(ctx context.Context, serviceName, instanceID string) error {
+	_, status, err := c.do(ctx, http.MethodPut, "/v1/agent/service/deregister/"+url.PathEscape(instanceID), nil, nil)
+	if status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// Instances returns a service's instances in the datacenter; those with a
+// critical check are unhealthy
+func (c *ConsulBackend) Instances(ctx context.Context, serviceName string) ([]*ServiceInstance, error) {
+	instances, _, err := c.query(ctx, serviceName, 0)
+	return instances, err
+}
+
+// Watch follows a service's instances with blocking queries
+func (c *ConsulBackend) Watch(ctx context.Context, serviceName string, update func([]*ServiceInstance)) error {
+	var index uint64
+	for {
+		instances, next, err := c.query(ctx, serviceName, index)
+		if err != nil {
+			return err
+		}
+		if index == 0 || next != index {
+			update(instances)
+		}
+		// The index may go backwards, e.g. after a snapshot restore, and
+		// must stay above zero for the next query to block
+		switch {
+		case next < index:
+			index = 0
+		case next == 0:
+			index = 1
+		default:
+			index = next
+		}
+	}
+}
+
+// Close releases the backend's idle connections
+func (c *ConsulBackend) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// query returns a service's instances and the index of the result, blocking
+// until the index passes index when it is not zero
+func (c *ConsulBackend) query(ctx context.Context, serviceName string, index uint64) ([]*ServiceInstance, uint64, error) {
+	query := url.Values{}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", consulWait.String())
+	}
+
+	body, _, header, err := c.request(ctx, http.MethodGet, "/v1/health/service/"+url.PathEscape(serviceName), query, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var entries []consulServiceEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, 0, fmt.Errorf("consul: invalid response: %w", err)
+	}
+	next, _ := strconv.ParseUint(header.Get("X-Consul-Index"), 10, 64)
+
+	instances := make([]*ServiceInstance, 0, len(entries))
+	for _, entry := range entries {
+		instance := &ServiceInstance{
+			ServiceName: entry.Service.Service,
+			InstanceID:  entry.Service.ID,
+			Host:        entry.Service.Address,
+			Port:        entry.Service.Port,
+			Protocol:    entry.Service.Meta["protocol"],
+			Metadata:    make(map[string]string, len(entry.Service.Meta)),
+			Health:      HealthStatusHealthy,
+		}
+		if instance.Host == "" {
+			instance.Host = entry.Node.Address
+		}
+		if instance.Protocol == "" {
+			instance.Protocol = "http"
+		}
+		for key, value := range entry.Service.Meta {
+			if key != "protocol" {
+				instance.Metadata[key] = value
+			}
+		}
+		for _, check := range entry.Checks {
+			if check.Status == "critical" {
+				instance.Health = HealthStatusUnhealthy
+			}
+		}
+		instances = append(instances, instance)
+	}
+	return instances, next, nil
+}
+
+// do sends a request to the agent and returns the response body and status
+func (c *ConsulBackend) do(ctx context.Context, method, path string, query url.Values, payload interface{}) ([]byte, int, error) {
+	body, status, _, err := c.request(ctx, method, path, query, payload)
+	return body, status, err
+}
+
+func (c *ConsulBackend) request(ctx context.Context, method, path string, query url.Values, payload interface{}) ([]byte, int, http.Header, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	if c.datacenter != "" && method == http.MethodGet {
+		query.Set("dc", c.datacenter)
+	}
+	target := c.address + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return body, resp.StatusCode, resp.Header, fmt.Errorf("consul %s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, resp.StatusCode, resp.Header, nil
+}
@@ -0,0 +1,405 @@
+package servicemesh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// etcdAuthPath is where the gateway issues auth tokens
+const etcdAuthPath = "/v3/auth/authenticate"
+
+// EtcdBackend keeps instances in etcd, over its v3 JSON gateway, as
+// <prefix>/<service>/<instance> keys attached to a lease per instance.
+// etcd deletes an instance's key once its lease runs out.
+type EtcdBackend struct {
+	endpoints []string
+	prefix    string
+	username  string
+	password  string
+	client    *http.Client
+	current   int              // Index of the endpoint that last answered
+	token     string           // Auth token, when a username is set
+	leases    map[string]int64 // Lease of each instance this process registered, by key
+	mu        sync.Mutex
+}
+
+// NewEtcdBackend creates an etcd backend for config.EtcdEndpoints
+func NewEtcdBackend(config RegistryConfig) (*EtcdBackend, error) {
+	if len(config.EtcdEndpoints) == 0 {
+		return nil, errors.New("etcd: no endpoints configured")
+	}
+	endpoints := make([]string, len(config.EtcdEndpoints))
+	for i, endpoint := range config.EtcdEndpoints {
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "http://" + endpoint
+		}
+		endpoints[i] = strings.TrimSuffix(endpoint, "/")
+	}
+
+	return &EtcdBackend{
+		endpoints: endpoints,
+		prefix:    strings.TrimSuffix(config.EtcdPrefix, "/"),
+		username:  config.EtcdUsername,
+		password:  config.EtcdPassword,
+		client:    &http.Client{},
+		leases:    make(map[string]int64),
+	}, nil
+}
+
+// etcdInt is an int64, which the gateway writes as a string
+type etcdInt int64
+
+func (i etcdInt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatInt(int64(i), 10))
+}
+
+func (i *etcdInt) UnmarshalJSON(data []byte) error {
+	value, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = etcdInt(value)
+	return nil
+}
+
+type etcdHeader struct {
+	Revision etcdInt `json:"revision"`
+}
+
+type etcdKeyValue struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type etcdRangeResponse struct {
+	Header etcdHeader     `json:"header"`
+	Kvs    []etcdKeyValue `json:"kvs"`
+}
+
+type etcdLeaseResponse struct {
+	ID  etcdInt `json:"ID"`
+	TTL etcdInt `json:"TTL"`
+}
+
+type etcdWatchResponse struct {
+	Result *struct {
+		Canceled     bool            `json:"canceled"`
+		CancelReason string          `json:"cancel_reason"`
+		Events       json.RawMessage `json:"events"`
+	} `json:"result"`
+	Error *etcdError `json:"error"`
+}
+
+type etcdError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// key returns the key of an instance, or with an empty instance ID the
+// prefix of a service's keys
+func (e *EtcdBackend) key(serviceName, instanceID string) string {
+	return e.prefix + "/" + serviceName + "/" + instanceID
+}
+
+// Register puts an instance under a new lease of its LeaseTTL, revoking
+// the lease it was registered with before
+func (e *EtcdBackend) Register(ctx context.Context, instance *ServiceInstance) error {
+	ttl := int64((instance.LeaseTTL + time.Second - 1) / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+	var lease etcdLeaseResponse
+	if err := e.call(ctx, "/v3/lease/grant", map[string]interface{}{"TTL": etcdInt(ttl)}, &lease); err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(instance)
+	if err != nil {
+		return err
+	}
+	key := e.key(instance.ServiceName, instance.InstanceID)
+	put := map[string]interface{}{"key": []byte(key), "value": value, "lease": lease.ID}
+	if err := e.call(ctx, "/v3/kv/put", put, nil); err != nil {
+		e.revoke(ctx, int64(lease.ID))
+		return err
+	}
+
+	e.mu.Lock()
+	previous, ok := e.leases[key]
+	e.leases[key] = int64(lease.ID)
+	e.mu.Unlock()
+	if ok {
+		e.revoke(ctx, previous)
+	}
+	return nil
+}
+
+// Renew keeps the instance's lease alive
+func (e *EtcdBackend) Renew(ctx context.Context, serviceName, instanceID string) error {
+	key := e.key(serviceName, instanceID)
+	e.mu.Lock()
+	leaseID, ok := e.leases[key]
+	e.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s/%s", ErrInstanceNotFound, serviceName, instanceID)
+	}
+
+	var keepAlive struct {
+		Result etcdLeaseResponse `json:"result"`
+	}
+	if err := e.call(ctx, "/v3/lease/keepalive", map[string]interface{}{"ID": etcdInt(leaseID)}, &keepAlive); err != nil {
+		return err
+	}
+	if keepAlive.Result.TTL <= 0 {
+		e.mu.Lock()
+		if e.leases[key] == leaseID {
+			delete(e.leases, key)
+		}
+		e.mu.Unlock()
+		return fmt.Errorf("%w: %s/%s", ErrInstanceNotFound, serviceName, instanceID)
+	}
+	return nil
+}
+
+// Deregister deletes an instance's key, and revokes its lease if this
+// process registered it
+func (e *EtcdBackend) Deregister(ctx context.Context, serviceName, instanceID string) error {
+	key := e.key(serviceName, instanceID)
+	e.mu.Lock()
+	leaseID, ok := e.leases[key]
+	delete(e.leases, key)
+	e.mu.Unlock()
+
+	if err := e.call(ctx, "/v3/kv/deleterange", map[string]interface{}{"key": []byte(key)}, nil); err != nil {
+		return err
+	}
+	if ok {
+		e.revoke(ctx, leaseID)
+	}
+	return nil
+}
+
+// Instances returns the instances under a service's prefix
+func (e *EtcdBackend) Instances(ctx context.Context, serviceName string) ([]*ServiceInstance, error) {
+	instances, _, err := e.instances(ctx, serviceName)
+	return instances, err
+}
+
+// Watch follows a service's prefix, reading its instances again after
+// every change
+func (e *EtcdBackend) Watch(ctx context.Context, serviceName string, update func([]*ServiceInstance)) error {
+	instances, revision, err := e.instances(ctx, serviceName)
+	if err != nil {
+		return err
+	}
+	update(instances)
+
+	prefix := e.key(serviceName, "")
+	create := map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":            []byte(prefix),
+			"range_end":      prefixEnd(prefix),
+			"start_revision": revision + 1,
+		},
+	}
+	body, err := e.stream(ctx, "/v3/watch", create)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	for {
+		var message etcdWatchResponse
+		if err := decoder.Decode(&message); err != nil {
+			return err
+		}
+		if message.Error != nil {
+			return fmt.Errorf("etcd watch: %s", message.Error.Message)
+		}
+		if message.Result == nil {
+			continue
+		}
+		if message.Result.Canceled {
+			return fmt.Errorf("etcd watch canceled: %s", message.Result.CancelReason)
+		}
+		if len(message.Result.Events) == 0 || string(message.Result.Events) == "null" {
+			continue
+		}
+
+		instances, _, err := e.instances(ctx, serviceName)
+		if err != nil {
+			return err
+		}
+		update(instances)
+	}
+}
+
+// Close releases the backend's idle connections. Leases are left to
+// expire; release them with Deregister first.
+func (e *EtcdBackend) Close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// instances returns a service's instances and the revision they were
+// read at
+func (e *EtcdBackend) instances(ctx context.Context, serviceName string) ([]*ServiceInstance, int64, error) {
+	prefix := e.key(serviceName, "")
+	var result etcdRangeResponse
+	if err := e.call(ctx, "/v3/kv/range", map[string]interface{}{"key": []byte(prefix), "range_end": prefixEnd(prefix)}, &result); err != nil {
+		return nil, 0, err
+	}
+
+	instances := make([]*ServiceInstance, 0, len(result.Kvs))
+	for _, kv := range result.Kvs {
+		var instance ServiceInstance
+		if err := json.Unmarshal(kv.Value, &instance); err != nil {
+			log.Printf("Skipping invalid service instance %s: %v", kv.Key, err)
+			continue
+		}
+		// etcd expires the key, not the registry
+		instance.LeaseTTL = 0
+		instance.ExpiresAt = time.Time{}
+		instances = append(instances, &instance)
+	}
+	return instances, int64(result.Header.Revision), nil
+}
+
+// revoke revokes a lease, deleting its keys; a failure only delays that
+// until the lease expires
+func (e *EtcdBackend) revoke(ctx context.Context, leaseID int64) {
+	if err := e.call(ctx, "/v3/lease/revoke", map[string]interface{}{"ID": etcdInt(leaseID)}, nil); err != nil {
+		log.Printf("Failed to revoke etcd lease %d: %v", leaseID, err)
+	}
+}
+
+// call sends a request to the gateway and decodes its first response
+func (e *EtcdBackend) call(ctx context.Context, path string, payload, result interface{}) error {
+	body, err := e.stream(ctx, path, payload)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(body).Decode(result); err != nil {
+		return fmt.Errorf("etcd %s: invalid response: %w", path, err)
+	}
+	return nil
+}
+
+// stream sends a request to the first endpoint that answers, and returns
+// the response body. A request rejected for an expired auth token is
+// sent again with a new one.
+func (e *EtcdBackend) stream(ctx context.Context, path string, payload interface{}) (io.ReadCloser, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := e.post(ctx, path, data)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && e.username != "" && path != etcdAuthPath {
+		resp.Body.Close()
+		if err = e.authenticate(ctx); err == nil {
+			resp, err = e.post(ctx, path, data)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var failure etcdError
+		body, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(body, &failure) == nil && failure.Message != "" {
+			return nil, fmt.Errorf("etcd %s: %d %s", path, resp.StatusCode, failure.Message)
+		}
+		return nil, fmt.Errorf("etcd %s: %d %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp.Body, nil
+}
+
+// post sends a request, trying each endpoint from the one that last
+// answered
+func (e *EtcdBackend) post(ctx context.Context, path string, data []byte) (*http.Response, error) {
+	e.mu.Lock()
+	current, token := e.current, e.token
+	e.mu.Unlock()
+	if token == "" && e.username != "" && path != etcdAuthPath {
+		if err := e.authenticate(ctx); err != nil {
+			return nil, err
+		}
+		e.mu.Lock()
+		token = e.token
+		e.mu.Unlock()
+	}
+
+	var lastErr error
+	for i := range e.endpoints {
+		index := (current + i) % len(e.endpoints)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoints[index]+path, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+
+		resp, err := e.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			lastErr = err
+			continue
+		}
+		e.mu.Lock()
+		e.current = index
+		e.mu.Unlock()
+		return resp, nil
+	}
+	return nil, lastErr
+}
+
+// authenticate gets a new auth token for the configured user
+func (e *EtcdBackend) authenticate(ctx context.Context) error {
+	var auth struct {
+		Token string `json:"token"`
+	}
+	credentials := map[string]string{"name": e.username, "password": e.password}
+	if err := e.call(ctx, etcdAuthPath, credentials, &auth); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.token = auth.Token
+	e.mu.Unlock()
+	return nil
+}
+
+// prefixEnd returns the end of the range of keys starting with prefix
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return []byte{0}
+}
@@ -0,0 +1,580 @@
+package servicemesh
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kubernetesServiceAccount is where pods find their API credentials
+const kubernetesServiceAccount = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubernetesBackend discovers the ready endpoints of Kubernetes Services by
+// watching their EndpointSlices through the API server. Kubernetes
+// registers pods itself, from their readiness probes, so Register, Renew
+// and Deregister do nothing.
+//
+// A service is named "<service>" in the configured namespace, or
+// "<service>.<namespace>". Each endpoint becomes an instance, with the
+// configured labels of its pod, such as version and region, as metadata
+// the TrafficManager's versions select. The service account needs to list
+// and watch endpointslices, and get pods.
+type KubernetesBackend struct {
+	server    string
+	namespace string
+	portName  string
+	labels    []string
+	token     string // Bearer token, or tokenFile to read it from
+	tokenFile string
+	client    *http.Client
+
+	podLabels map[string]map[string]map[string]string // Labels of each service's pods, by pod UID
+	mu        sync.Mutex
+}
+
+type kubernetesList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []kubernetesEndpointSlice `json:"items"`
+}
+
+type kubernetesEndpointSlice struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Endpoints []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready       *bool `json:"ready"`
+			Terminating *bool `json:"terminating"`
+		} `json:"conditions"`
+		NodeName  string `json:"nodeName"`
+		Zone      string `json:"zone"`
+		TargetRef *struct {
+			Kind      string `json:"kind"`
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+			UID       string `json:"uid"`
+		} `json:"targetRef"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name        string  `json:"name"`
+		Port        int     `json:"port"`
+		AppProtocol *string `json:"appProtocol"`
+	} `json:"ports"`
+}
+
+type kubernetesWatchEvent struct {
+	Type   string          `json:"type"` // ADDED, MODIFIED, DELETED, BOOKMARK or ERROR
+	Object json.RawMessage `json:"object"`
+}
+
+// NewKubernetesBackend creates a Kubernetes backend. It uses the kubeconfig
+// at config.KubeconfigPath when set, the pod's service account when
+// running in a cluster, and ~/.kube/config otherwise.
+func NewKubernetesBackend(config RegistryConfig) (*KubernetesBackend, error) {
+	backend := &KubernetesBackend{
+		namespace: config.KubernetesNamespace,
+		portName:  config.KubernetesPortName,
+		labels:    config.KubernetesLabels,
+		podLabels: make(map[string]map[string]map[string]string),
+	}
+
+	path := config.KubeconfigPath
+	if path == "" && os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes: not in a cluster and no kubeconfig: %w", err)
+		}
+		path = filepath.Join(home, ".kube", "config")
+	}
+
+	var tlsConfig *tls.Config
+	var err error
+	if path != "" {
+		tlsConfig, err = backend.loadKubeconfig(path)
+	} else {
+		tlsConfig, err = backend.loadInCluster()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if backend.namespace == "" {
+		backend.namespace = "default"
+	}
+
+	backend.client = &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}}
+	return backend, nil
+}
+
+// loadInCluster configures the backend from the pod's service account
+func (k *KubernetesBackend) loadInCluster() (*tls.Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if port == "" {
+		port = "443"
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	k.server = "https://" + host + ":" + port
+	// Bound service account tokens rotate, so the file is read per request
+	k.tokenFile = filepath.Join(kubernetesServiceAccount, "token")
+
+	if k.namespace == "" {
+		if namespace, err := os.ReadFile(filepath.Join(kubernetesServiceAccount, "namespace")); err == nil {
+			k.namespace = strings.TrimSpace(string(namespace))
+		}
+	}
+
+	ca, err := os.ReadFile(filepath.Join(kubernetesServiceAccount, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: reading service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("kubernetes: invalid service account CA")
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// loadKubeconfig configures the backend from the current context of a
+// kubeconfig file. Credentials from exec and auth provider plugins are not
+// supported.
+func (k *KubernetesBackend) loadKubeconfig(path string) (*tls.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: reading kubeconfig: %w", err)
+	}
+
+	var kubeconfig struct {
+		CurrentContext string `yaml:"current-context"`
+		Contexts       []struct {
+			Name    string `yaml:"name"`
+			Context struct {
+				Cluster   string `yaml:"cluster"`
+				User      string `yaml:"user"`
+				Namespace string `yaml:"namespace"`
+			} `yaml:"context"`
+		} `yaml:"contexts"`
+		Clusters []struct {
+			Name    string `yaml:"name"`
+			Cluster struct {
+				Server                   string `yaml:"server"`
+				CertificateAuthority     string `yaml:"certificate-authority"`
+				CertificateAuthorityData string `yaml:"certificate-authority-data"`
+				InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+			} `yaml:"cluster"`
+		} `yaml:"clusters"`
+		Users []struct {
+			Name string `yaml:"name"`
+			User struct {
+				Token                 string      `yaml:"token"`
+				TokenFile             string      `yaml:"tokenFile"`
+				ClientCertificate     string      `yaml:"client-certificate"`
+				ClientCertificateData string      `yaml:"client-certificate-data"`
+				ClientKey             string      `yaml:"client-key"`
+				ClientKeyData         string      `yaml:"client-key-data"`
+				Exec                  interface{} `yaml:"exec"`
+				AuthProvider          interface{} `yaml:"auth-provider"`
+			} `yaml:"user"`
+		} `yaml:"users"`
+	}
+	if err := yaml.Unmarshal(data, &kubeconfig); err != nil {
+		return nil, fmt.Errorf("kubernetes: invalid kubeconfig: %w", err)
+	}
+
+	var clusterName, userName string
+	found := false
+	for _, entry := range kubeconfig.Contexts {
+		if entry.Name == kubeconfig.CurrentContext {
+			clusterName, userName = entry.Context.Cluster, entry.Context.User
+			if k.namespace == "" {
+				k.namespace = entry.Context.Namespace
+			}
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("kubernetes: kubeconfig context %q not found", kubeconfig.CurrentContext)
+	}
+
+	// Relative paths in a kubeconfig are relative to the file
+	dir := filepath.Dir(path)
+	resolve := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(dir, file)
+	}
+	// read returns inline base64 data, or else the contents of a file
+	read := func(inline, file string) ([]byte, error) {
+		if inline != "" {
+			return base64.StdEncoding.DecodeString(inline)
+		}
+		if file != "" {
+			return os.ReadFile(resolve(file))
+		}
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	found = false
+	for _, cluster := range kubeconfig.Clusters {
+		if cluster.Name != clusterName {
+			continue
+		}
+		found = true
+		k.server = strings.TrimSuffix(cluster.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = cluster.Cluster.InsecureSkipTLSVerify
+		ca, err := read(cluster.Cluster.CertificateAuthorityData, cluster.Cluster.CertificateAuthority)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes: reading cluster CA: %w", err)
+		}
+		if ca != nil {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, errors.New("kubernetes: invalid cluster CA")
+			}
+			tlsConfig.RootCAs = pool
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("kubernetes: kubeconfig cluster %q not found", clusterName)
+	}
+
+	for _, user := range kubeconfig.Users {
+		if user.Name != userName {
+			continue
+		}
+		if user.User.Exec != nil || user.User.AuthProvider != nil {
+			return nil, fmt.Errorf("kubernetes: kubeconfig user %q uses a credential plugin, which is not supported", userName)
+		}
+		k.token = user.User.Token
+		k.tokenFile = resolve(user.User.TokenFile)
+
+		cert, err := read(user.User.ClientCertificateData, user.User.ClientCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes: reading client certificate: %w", err)
+		}
+		key, err := read(user.User.ClientKeyData, user.User.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes: reading client key: %w", err)
+		}
+		if cert != nil && key != nil {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("kubernetes: invalid client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+	return tlsConfig, nil
+}
+
+// Register does nothing; Kubernetes adds a pod to its Services' endpoints
+// once it is ready
+func (k *KubernetesBackend) Register(ctx context.Context, instance *ServiceInstance) error {
+	return nil
+}
+
+// Renew does nothing; Kubernetes follows pods with their readiness probes
+func (k *KubernetesBackend) Renew(ctx context.Context, serviceName, instanceID string) error {
+	return nil
+}
+
+// Deregister does nothing; Kubernetes removes the endpoints of pods that
+// stop or fail their readiness probes
+func (k *KubernetesBackend) Deregister(ctx context.Context, serviceName, instanceID string) error {
+	return nil
+}
+
+// Instances returns a service's endpoints; those not ready are unhealthy
+func (k *KubernetesBackend) Instances(ctx context.Context, serviceName string) ([]*ServiceInstance, error) {
+	instances, _, err := k.instances(ctx, serviceName)
+	return instances, err
+}
+
+// Watch follows a service's EndpointSlices, watching again from the last
+// change seen when the API server ends a watch
+func (k *KubernetesBackend) Watch(ctx context.Context, serviceName string, update func([]*ServiceInstance)) error {
+	instances, resourceVersion, err := k.instances(ctx, serviceName)
+	if err != nil {
+		return err
+	}
+	update(instances)
+
+	name, namespace := k.split(serviceName)
+	for {
+		query := k.selector(name)
+		query.Set("watch", "true")
+		query.Set("allowWatchBookmarks", "true")
+		query.Set("resourceVersion", resourceVersion)
+		body, err := k.get(ctx, "/apis/discovery.k8s.io/v1/namespaces/"+url.PathEscape(namespace)+"/endpointslices", query)
+		if err != nil {
+			return err
+		}
+
+		resourceVersion, err = k.follow(ctx, body, serviceName, resourceVersion, update)
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// follow reads the events of a watch until the API server ends it, and
+// returns the last resource version seen
+func (k *KubernetesBackend) follow(ctx context.Context, body io.Reader, serviceName, resourceVersion string, update func([]*ServiceInstance)) (string, error) {
+	decoder := json.NewDecoder(body)
+	for {
+		var event kubernetesWatchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return resourceVersion, nil
+			}
+			return resourceVersion, err
+		}
+
+		if event.Type == "ERROR" {
+			// e.g. 410 Gone, when resourceVersion is too old
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			json.Unmarshal(event.Object, &status)
+			return resourceVersion, fmt.Errorf("kubernetes watch: %d %s", status.Code, status.Message)
+		}
+
+		var slice kubernetesEndpointSlice
+		if err := json.Unmarshal(event.Object, &slice); err != nil {
+			return resourceVersion, fmt.Errorf("kubernetes watch: invalid event: %w", err)
+		}
+		if slice.Metadata.ResourceVersion != "" {
+			resourceVersion = slice.Metadata.ResourceVersion
+		}
+		if event.Type == "BOOKMARK" {
+			continue
+		}
+
+		instances, _, err := k.instances(ctx, serviceName)
+		if err != nil {
+			return resourceVersion, err
+		}
+		update(instances)
+	}
+}
+
+// Close releases the backend's idle connections
+func (k *KubernetesBackend) Close() error {
+	k.client.CloseIdleConnections()
+	return nil
+}
+
+// split returns the Service name and namespace of a service
+func (k *KubernetesBackend) split(serviceName string) (string, string) {
+	if name, namespace, ok := strings.Cut(serviceName, "."); ok {
+		return name, namespace
+	}
+	return serviceName, k.namespace
+}
+
+// selector returns the query selecting a Service's EndpointSlices
+func (k *KubernetesBackend) selector(name string) url.Values {
+	return url.Values{"labelSelector": {"kubernetes.io/service-name=" + name}}
+}
+
+// instances returns a service's endpoints and the resource version they
+// were listed at
+func (k *KubernetesBackend) instances(ctx context.Context, serviceName string) ([]*ServiceInstance, string, error) {
+	name, namespace := k.split(serviceName)
+	body, err := k.get(ctx, "/apis/discovery.k8s.io/v1/namespaces/"+url.PathEscape(namespace)+"/endpointslices", k.selector(name))
+	if err != nil {
+		return nil, "", err
+	}
+	defer body.Close()
+
+	var list kubernetesList
+	if err := json.NewDecoder(body).Decode(&list); err != nil {
+		return nil, "", fmt.Errorf("kubernetes: invalid response: %w", err)
+	}
+
+	k.mu.Lock()
+	known := k.podLabels[serviceName]
+	k.mu.Unlock()
+	podLabels := make(map[string]map[string]string)
+
+	var instances []*ServiceInstance
+	seen := make(map[string]bool)
+	for _, slice := range list.Items {
+		port, protocol, ok := k.port(slice)
+		if !ok {
+			continue
+		}
+
+		for _, endpoint := range slice.Endpoints {
+			if len(endpoint.Addresses) == 0 {
+				continue
+			}
+			instance := &ServiceInstance{
+				ServiceName: serviceName,
+				InstanceID:  endpoint.Addresses[0],
+				Host:        endpoint.Addresses[0],
+				Port:        port,
+				Protocol:    protocol,
+				Metadata:    map[string]string{"namespace": namespace},
+				Health:      HealthStatusHealthy,
+			}
+			// Unset conditions mean ready, and not terminating
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready ||
+				endpoint.Conditions.Terminating != nil && *endpoint.Conditions.Terminating {
+				instance.Health = HealthStatusUnhealthy
+			}
+			if endpoint.NodeName != "" {
+				instance.Metadata["node"] = endpoint.NodeName
+			}
+			if endpoint.Zone != "" {
+				instance.Metadata["zone"] = endpoint.Zone
+			}
+
+			if ref := endpoint.TargetRef; ref != nil && ref.Kind == "Pod" {
+				instance.InstanceID = ref.Name
+				instance.Metadata["pod"] = ref.Name
+				labels, ok := known[ref.UID]
+				if !ok {
+					labels = k.labelsOf(ctx, namespace, ref.Name)
+				}
+				podLabels[ref.UID] = labels
+				for key, value := range labels {
+					instance.Metadata[key] = value
+				}
+			}
+
+			// An endpoint is in more than one slice while they are updated
+			if seen[instance.InstanceID] {
+				continue
+			}
+			seen[instance.InstanceID] = true
+			instances = append(instances, instance)
+		}
+	}
+
+	k.mu.Lock()
+	k.podLabels[serviceName] = podLabels
+	k.mu.Unlock()
+	return instances, list.Metadata.ResourceVersion, nil
+}
+
+// port returns the port and protocol instances are discovered on: the
+// configured port name, or the slice's first port
+func (k *KubernetesBackend) port(slice kubernetesEndpointSlice) (int, string, bool) {
+	for _, port := range slice.Ports {
+		if k.portName != "" && port.Name != k.portName {
+			continue
+		}
+
+		protocol := "http"
+		switch {
+		case port.AppProtocol != nil && (*port.AppProtocol == "https" || *port.AppProtocol == "grpc"):
+			protocol = *port.AppProtocol
+		case strings.HasPrefix(port.Name, "grpc"):
+			protocol = "grpc"
+		case strings.HasPrefix(port.Name, "https"):
+			protocol = "https"
+		}
+		return port.Port, protocol, true
+	}
+	return 0, "", false
+}
+
+// labelsOf returns the configured labels of a pod. A pod that cannot be
+// read has none, and is not read again while it remains an endpoint.
+func (k *KubernetesBackend) labelsOf(ctx context.Context, namespace, pod string) map[string]string {
+	labels := make(map[string]string)
+	if len(k.labels) == 0 {
+		return labels
+	}
+
+	body, err := k.get(ctx, "/api/v1/namespaces/"+url.PathEscape(namespace)+"/pods/"+url.PathEscape(pod), nil)
+	if err != nil {
+		log.Printf("Failed to read labels of pod %s/%s: %v", namespace, pod, err)
+		return labels
+	}
+	defer body.Close()
+
+	var object struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if err := json.NewDecoder(body).Decode(&object); err != nil {
+		log.Printf("Failed to read labels of pod %s/%s: %v", namespace, pod, err)
+		return labels
+	}
+	for _, label := range k.labels {
+		if value, ok := object.Metadata.Labels[label]; ok {
+			labels[label] = value
+		}
+	}
+	return labels
+}
+
+// get sends a GET request to the API server and returns the response body
+func (k *KubernetesBackend) get(ctx context.Context, path string, query url.Values) (io.ReadCloser, error) {
+	target := k.server + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	token := k.token
+	if k.tokenFile != "" {
+		data, err := os.ReadFile(k.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes: reading token: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		var status struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &status) == nil && status.Message != "" {
+			return nil, fmt.Errorf("kubernetes GET %s: %d %s", path, resp.StatusCode, status.Message)
+		}
+		return nil, fmt.Errorf("kubernetes GET %s: %d %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,387 @@
+package servicemesh
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// More load balancing strategies, alongside those of TrafficPolicy
+const (
+	StrategyWeighted       LoadBalancingStrategy = "weighted"        // Smooth weighted round-robin on the "weight" metadata
+	StrategyEWMA           LoadBalancingStrategy = "ewma"            // Lowest moving average latency, scaled by requests in flight
+	StrategyConsistentHash LoadBalancingStrategy = "consistent_hash" // Same instance for the same header or cookie value
+)
+
+const (
+	// defaultEWMADecay is how long a latency sample takes to fade to 1/e of
+	// its weight
+	defaultEWMADecay = 10 * time.Second
+	// ewmaErrorPenalty is the latency a failed request counts as, at least
+	ewmaErrorPenalty = time.Second
+	// hashReplicas is the number of points each instance has on the hash
+	// ring, so keys spread evenly and few move when instances change
+	hashReplicas = 100
+)
+
+// LoadBalancerConfig selects how the sidecar spreads requests over the
+// instances of an upstream service
+type LoadBalancerConfig struct {
+	Strategy   LoadBalancingStrategy // StrategyRoundRobin by default
+	HashHeader string                // Consistent hashing: header keying requests, e.g. X-User-ID
+	HashCookie string                // Consistent hashing: cookie keying requests, when the header is absent
+	EWMADecay  time.Duration         // EWMA: how fast old latencies fade (default 10s)
+}
+
+// Balancer picks an instance of a service for each request. Pick gets the
+// service's healthy instances and the request's hash key, empty unless
+// the strategy hashes; Done reports how the request to the picked
+// instance went.
+type Balancer interface {
+	Pick(instances []*ServiceInstance, key string) *ServiceInstance
+	Done(instance *ServiceInstance, latency time.Duration, err error)
+}
+
+// NewBalancer creates the balancer of a strategy
+func NewBalancer(config LoadBalancerConfig) (Balancer, error) {
+	switch config.Strategy {
+	case "", StrategyRoundRobin:
+		return &roundRobinBalancer{}, nil
+	case StrategyRandom:
+		return &randomBalancer{}, nil
+	case StrategyWeighted, StrategyWeightedRR:
+		return &weightedBalancer{current: make(map[string]int)}, nil
+	case StrategyLeastConn:
+		return &leastConnBalancer{inflight: make(map[string]int)}, nil
+	case StrategyEWMA:
+		decay := config.EWMADecay
+		if decay <= 0 {
+			decay = defaultEWMADecay
+		}
+		return &ewmaBalancer{decay: decay, stats: make(map[string]*ewmaStats)}, nil
+	case StrategyConsistentHash, StrategyIPHash:
+		if config.Strategy == StrategyConsistentHash && config.HashHeader == "" && config.HashCookie == "" {
+			return nil, fmt.Errorf("consistent hashing needs a hash header or cookie")
+		}
+		return &hashBalancer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown load balancing strategy: %s", config.Strategy)
+	}
+}
+
+// instanceKey identifies an instance across discoveries, which may return
+// new copies of it
+func instanceKey(instance *ServiceInstance) string {
+	if instance.InstanceID != "" {
+		return instance.InstanceID
+	}
+	return instance.Host + ":" + strconv.Itoa(instance.Port)
+}
+
+// roundRobinBalancer picks each instance in turn
+type roundRobinBalancer struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (b *roundRobinBalancer) Pick(instances []*ServiceInstance, key string) *ServiceInstance {
+	if len(instances) == 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	instance := instances[b.next%len(instances)]
+	b.next++
+	return instance
+}
+
+func (b *roundRobinBalancer) Done(instance *ServiceInstance, latency time.Duration, err error) {}
+
+// randomBalancer picks an instance at random
+type randomBalancer struct{}
+
+func (b *randomBalancer) Pick(instances []*ServiceInstance, key string) *ServiceInstance {
+	if len(instances) == 0 {
+		return nil
+	}
+	return instances[rand.Intn(len(instances))]
+}
+
+func (b *randomBalancer) Done(instance *ServiceInstance, latency time.Duration, err error) {}
+
+// weightedBalancer picks instances in proportion to their "weight"
+// metadata, 1 when unset, interleaving them as nginx does rather than in
+// runs
+type weightedBalancer struct {
+	mu      sync.Mutex
+	current map[string]int
+}
+
+func (b *weightedBalancer) Pick(instances []*ServiceInstance, key string) *ServiceInstance {
+	if len(instances) == 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var best *ServiceInstance
+	bestKey, total := "", 0
+	for _, instance := range instances {
+		weight := instanceWeight(instance)
+		k := instanceKey(instance)
+		b.current[k] += weight
+		total += weight
+		if best == nil || b.current[k] > b.current[bestKey] {
+			best, bestKey = instance, k
+		}
+	}
+	b.current[bestKey] -= total
+
+	// Forget instances that are gone
+	if len(b.current) > 2*len(instances) {
+		live := make(map[string]int, len(instances))
+		for _, instance := range instances {
+			k := instanceKey(instance)
+			live[k] = b.current[k]
+		}
+		b.current = live
+	}
+	return best
+}
+
+func (b *weightedBalancer) Done(instance *ServiceInstance, latency time.Duration, err error) {}
+
+func instanceWeight(instance *ServiceInstance) int {
+	if weight, err := strconv.Atoi(instance.Metadata["weight"]); err == nil && weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// leastConnBalancer picks the instance with the fewest requests in flight,
+// taking turns among ties
+type leastConnBalancer struct {
+	mu       sync.Mutex
+	inflight map[string]int
+	next     int
+}
+
+func (b *leastConnBalancer) Pick(instances []*ServiceInstance, key string) *ServiceInstance {
+	if len(instances) == 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := b.next % len(instances)
+	b.next++
+	var best *ServiceInstance
+	least := math.MaxInt
+	for i := range instances {
+		instance := instances[(start+i)%len(instances)]
+		if n := b.inflight[instanceKey(instance)]; n < least {
+			best, least = instance, n
+		}
+	}
+	b.inflight[instanceKey(best)]++
+	return best
+}
+
+func (b *leastConnBalancer) Done(instance *ServiceInstance, latency time.Duration, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	k := instanceKey(instance)
+	if b.inflight[k] <= 1 {
+		delete(b.inflight, k)
+		return
+	}
+	b.inflight[k]--
+}
+
+// ewmaStats is an instance's moving average latency
+type ewmaStats struct {
+	latency  float64 // Nanoseconds
+	updated  time.Time
+	inflight int
+}
+
+// ewmaBalancer picks the instance with the lowest moving average latency,
+// times the requests it has in flight plus one, so a slow or busy instance
+// gets less traffic. Instances without samples are tried first. Failed
+// requests count as at least ewmaErrorPenalty.
+type ewmaBalancer struct {
+	mu    sync.Mutex
+	decay time.Duration
+	stats map[string]*ewmaStats
+	next  int
+}
+
+func (b *ewmaBalancer) Pick(instances []*ServiceInstance, key string) *ServiceInstance {
+	if len(instances) == 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := b.next % len(instances)
+	b.next++
+	var best *ServiceInstance
+	var bestStats *ewmaStats
+	lowest := math.Inf(1)
+	for i := range instances {
+		instance := instances[(start+i)%len(instances)]
+		stats := b.stats[instanceKey(instance)]
+		if stats == nil {
+			stats = &ewmaStats{}
+			b.stats[instanceKey(instance)] = stats
+		}
+		if score := stats.latency * float64(stats.inflight+1); score < lowest {
+			best, bestStats, lowest = instance, stats, score
+		}
+	}
+	bestStats.inflight++
+
+	if len(b.stats) > 2*len(instances) {
+		live := make(map[string]*ewmaStats, len(instances))
+		for _, instance := range instances {
+			live[instanceKey(instance)] = b.stats[instanceKey(instance)]
+		}
+		b.stats = live
+	}
+	return best
+}
+
+func (b *ewmaBalancer) Done(instance *ServiceInstance, latency time.Duration, err error) {
+	if err != nil && latency < ewmaErrorPenalty {
+		latency = ewmaErrorPenalty
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := b.stats[instanceKey(instance)]
+	if stats == nil {
+		return
+	}
+	if stats.inflight > 0 {
+		stats.inflight--
+	}
+
+	now := time.Now()
+	if stats.updated.IsZero() {
+		stats.latency = float64(latency)
+	} else {
+		// Older samples weigh less the longer ago they were taken
+		weight := math.Exp(-float64(now.Sub(stats.updated)) / float64(b.decay))
+		stats.latency = stats.latency*weight + float64(latency)*(1-weight)
+	}
+	stats.updated = now
+}
+
+// hashBalancer maps each key to an instance on a hash ring, so requests
+// with the same key reach the same instance while the instances stay the
+// same, and most keys stay put when they change. Requests without a key
+// take turns.
+type hashBalancer struct {
+	mu      sync.Mutex
+	members string // Instance keys the ring was built from
+	ring    []uint32
+	owners  map[uint32]string
+	next    int
+}
+
+func (b *hashBalancer) Pick(instances []*ServiceInstance, key string) *ServiceInstance {
+	if len(instances) == 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if key == "" {
+		instance := instances[b.next%len(instances)]
+		b.next++
+		return instance
+	}
+
+	byKey := make(map[string]*ServiceInstance, len(instances))
+	keys := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		k := instanceKey(instance)
+		byKey[k] = instance
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if members := strings.Join(keys, ","); members != b.members {
+		b.build(keys)
+		b.members = members
+	}
+
+	point := hash32(key)
+	i := sort.Search(len(b.ring), func(i int) bool { return b.ring[i] >= point })
+	if i == len(b.ring) {
+		i = 0
+	}
+	return byKey[b.owners[b.ring[i]]]
+}
+
+func (b *hashBalancer) Done(instance *ServiceInstance, latency time.Duration, err error) {}
+
+// build places each instance's points on the ring
+func (b *hashBalancer) build(keys []string) {
+	b.ring = make([]uint32, 0, len(keys)*hashReplicas)
+	b.owners = make(map[uint32]string, len(keys)*hashReplicas)
+	for _, k := range keys {
+		for replica := 0; replica < hashReplicas; replica++ {
+			point := hash32(k + "#" + strconv.Itoa(replica))
+			if _, taken := b.owners[point]; taken {
+				continue
+			}
+			b.owners[point] = k
+			b.ring = append(b.ring, point)
+		}
+	}
+	sort.Slice(b.ring, func(i, j int) bool { return b.ring[i] < b.ring[j] })
+}
+
+func hash32(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// BalancerMetrics counts the picks of one load balancing strategy and how
+// the requests they routed went
+type BalancerMetrics struct {
+	Picks        int64         // Instances picked
+	PickDuration time.Duration // Total time spent picking
+	Requests     int64         // Requests sent to picked instances
+	Errors       int64         // Of those, failed or answered with a 5xx
+	NoInstance   int64         // Requests no healthy instance could take
+}
+
+// snapshot summarizes the metrics
+func (m *BalancerMetrics) snapshot() map[string]interface{} {
+	avgPick := 0.0
+	if m.Picks > 0 {
+		avgPick = float64(m.PickDuration.Microseconds()) / float64(m.Picks)
+	}
+	errorRate := 0.0
+	if m.Requests > 0 {
+		errorRate = float64(m.Errors) / float64(m.Requests)
+	}
+	return map[string]interface{}{
+		"picks":       m.Picks,
+		"avg_pick_us": avgPick,
+		"requests":    m.Requests,
+		"errors":      m.Errors,
+		"error_rate":  errorRate,
+		"no_instance": m.NoInstance,
+	}
+}
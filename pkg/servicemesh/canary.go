@@ -0,0 +1,299 @@
+package servicemesh
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/events"
+)
+
+// Canary analysis defaults
+const (
+	defaultCanaryInterval    = time.Minute
+	defaultCanaryMinRequests = 20
+	canaryTick               = time.Second
+)
+
+// Events dispatched for the controller's decisions, with a CanaryDecision
+const (
+	EventCanaryAdvanced   = "mesh.canary.advanced"
+	EventCanaryHeld       = "mesh.canary.held"
+	EventCanaryPromoted   = "mesh.canary.promoted"
+	EventCanaryRolledBack = "mesh.canary.rolled_back"
+)
+
+// CanaryAction is what the controller did with a canary after a step
+type CanaryAction string
+
+const (
+	CanaryAdvance  CanaryAction = "advance"  // Weight raised by IncrementStep
+	CanaryHold     CanaryAction = "hold"     // Too few requests to judge; the step goes on
+	CanaryPromote  CanaryAction = "promote"  // Healthy at MaxWeight; now stable
+	CanaryRollback CanaryAction = "rollback" // SLOs violated; all traffic back to stable
+)
+
+// VersionStats counts the requests a sidecar sent to a version of a
+// service, since it started
+type VersionStats struct {
+	Requests int64
+	Failures int64         // Requests failing or answered with a 5xx status
+	Latency  time.Duration // Total, over requests
+}
+
+// sub returns the stats gathered since earlier ones
+func (v VersionStats) sub(earlier VersionStats) VersionStats {
+	return VersionStats{
+		Requests: v.Requests - earlier.Requests,
+		Failures: v.Failures - earlier.Failures,
+		Latency:  v.Latency - earlier.Latency,
+	}
+}
+
+// VersionStatsSource reports per-version request stats; SidecarProxy is one
+type VersionStatsSource interface {
+	VersionStats(service, version string) VersionStats
+}
+
+// CanaryDecision is the outcome of a canary step
+type CanaryDecision struct {
+	Service       string        `json:"service"`
+	StableVersion string        `json:"stable_version"`
+	NewVersion    string        `json:"new_version"`
+	Action        CanaryAction  `json:"action"`
+	Reason        string        `json:"reason"`
+	Weight        int           `json:"weight"` // Of the new version, after the decision
+	Requests      int64         `json:"requests"`
+	SuccessRate   float64       `json:"success_rate"`
+	Latency       time.Duration `json:"latency"` // Mean, over the step
+	At            time.Time     `json:"at"`
+}
+
+// CanaryController drives the canaries of a traffic manager: every
+// IncrementDelay it judges the new version by the requests sent to it
+// during the step, raising its weight while it meets SuccessRate and
+// MaxLatency, promoting it once healthy at MaxWeight, and rolling back to
+// the stable version as soon as it misses either.
+type CanaryController struct {
+	traffic    *TrafficManager
+	stats      VersionStatsSource
+	dispatcher *events.EventDispatcher
+	mu         sync.Mutex
+	steps      map[string]*canaryStep
+	decisions  []CanaryDecision
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+// canaryStep is a canary's step in progress
+type canaryStep struct {
+	newVersion string
+	weight     int
+	started    time.Time
+	baseline   VersionStats
+}
+
+// maxCanaryDecisions bounds the decisions kept for Decisions
+const maxCanaryDecisions = 100
+
+// NewCanaryController creates a controller of a traffic manager's canaries,
+// dispatching its decisions through dispatcher, or the default dispatcher
+// when nil
+func NewCanaryController(traffic *TrafficManager, stats VersionStatsSource, dispatcher *events.EventDispatcher) *CanaryController {
+	if dispatcher == nil {
+		dispatcher = events.Default()
+	}
+	return &CanaryController{
+		traffic:    traffic,
+		stats:      stats,
+		dispatcher: dispatcher,
+		steps:      make(map[string]*canaryStep),
+		stop:       make(chan struct{}),
+	}
+}
+
+// Start judges the enabled canaries as their steps end, until Stop
+func (cc *CanaryController) Start() {
+	go func() {
+		ticker := time.NewTicker(canaryTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				cc.tick()
+			case <-cc.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the controller
+func (cc *CanaryController) Stop() {
+	cc.stopOnce.Do(func() { close(cc.stop) })
+}
+
+// tick judges the canaries whose step has ended
+func (cc *CanaryController) tick() {
+	now := time.Now()
+	for _, service := range cc.traffic.ListPolicies() {
+		config, enabled := cc.traffic.canary(service)
+		if !enabled {
+			cc.mu.Lock()
+			delete(cc.steps, service)
+			cc.mu.Unlock()
+			continue
+		}
+
+		cc.mu.Lock()
+		step := cc.step(service, config, now)
+		due := now.Sub(step.started) >= canaryInterval(config)
+		cc.mu.Unlock()
+		if !due {
+			continue
+		}
+		if _, err := cc.Evaluate(service); err != nil {
+			log.Printf("Canary analysis of %s failed: %v", service, err)
+		}
+	}
+}
+
+// step returns a canary's current step, starting one when the canary or
+// its weight changed; the caller holds the lock
+func (cc *CanaryController) step(service string, config CanaryConfig, now time.Time) *canaryStep {
+	step, ok := cc.steps[service]
+	if !ok || step.newVersion != config.NewVersion || step.weight != config.InitialWeight {
+		step = &canaryStep{
+			newVersion: config.NewVersion,
+			weight:     config.InitialWeight,
+			started:    now,
+			baseline:   cc.stats.VersionStats(service, config.NewVersion),
+		}
+		cc.steps[service] = step
+	}
+	return step
+}
+
+// Evaluate ends a service's canary step now and acts on it
+func (cc *CanaryController) Evaluate(service string) (CanaryDecision, error) {
+	config, enabled := cc.traffic.canary(service)
+	if !enabled {
+		return CanaryDecision{}, fmt.Errorf("canary not configured for service: %s", service)
+	}
+
+	now := time.Now()
+	cc.mu.Lock()
+	step := cc.step(service, config, now)
+	stats := cc.stats.VersionStats(service, config.NewVersion)
+	delta := stats.sub(step.baseline)
+	cc.mu.Unlock()
+
+	decision := CanaryDecision{
+		Service:       service,
+		StableVersion: config.StableVersion,
+		NewVersion:    config.NewVersion,
+		Weight:        config.InitialWeight,
+		Requests:      delta.Requests,
+		At:            now,
+	}
+	if delta.Requests > 0 {
+		decision.SuccessRate = 1 - float64(delta.Failures)/float64(delta.Requests)
+		decision.Latency = delta.Latency / time.Duration(delta.Requests)
+	}
+
+	minRequests := int64(config.MinRequests)
+	if minRequests <= 0 {
+		minRequests = defaultCanaryMinRequests
+	}
+	maxWeight := canaryMaxWeight(config)
+
+	var err error
+	switch {
+	case config.InitialWeight > 0 && delta.Requests < minRequests:
+		// Nothing to judge by yet; the step goes on
+		decision.Action = CanaryHold
+		decision.Reason = fmt.Sprintf("%d of %d requests needed", delta.Requests, minRequests)
+	case config.SuccessRate > 0 && delta.Requests > 0 && decision.SuccessRate < config.SuccessRate:
+		decision.Action = CanaryRollback
+		decision.Reason = fmt.Sprintf("success rate %.4f below %.4f", decision.SuccessRate, config.SuccessRate)
+		decision.Weight = 0
+		err = cc.traffic.RollbackCanary(service)
+	case config.MaxLatency > 0 && decision.Latency > config.MaxLatency:
+		decision.Action = CanaryRollback
+		decision.Reason = fmt.Sprintf("mean latency %s above %s", decision.Latency, config.MaxLatency)
+		decision.Weight = 0
+		err = cc.traffic.RollbackCanary(service)
+	case config.InitialWeight >= maxWeight:
+		decision.Action = CanaryPromote
+		decision.Reason = fmt.Sprintf("healthy at %d%%", config.InitialWeight)
+		decision.Weight = 100
+		err = cc.traffic.PromoteCanary(service)
+	default:
+		decision.Action = CanaryAdvance
+		decision.Reason = fmt.Sprintf("healthy at %d%%", config.InitialWeight)
+		decision.Weight = min(config.InitialWeight+config.IncrementStep, maxWeight)
+		err = cc.traffic.IncrementCanary(service)
+	}
+	if err != nil {
+		return decision, err
+	}
+
+	cc.mu.Lock()
+	if decision.Action == CanaryHold {
+		// Judge again after another interval, counting from the step's start
+		step.started = now
+	} else {
+		delete(cc.steps, service)
+	}
+	cc.decisions = append(cc.decisions, decision)
+	if len(cc.decisions) > maxCanaryDecisions {
+		cc.decisions = cc.decisions[len(cc.decisions)-maxCanaryDecisions:]
+	}
+	cc.mu.Unlock()
+
+	cc.dispatcher.DispatchAsync(context.Background(), events.Event{
+		Name: decision.Action.event(),
+		Data: decision,
+	})
+	return decision, nil
+}
+
+// Decisions returns the controller's latest decisions, oldest first
+func (cc *CanaryController) Decisions() []CanaryDecision {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return append([]CanaryDecision(nil), cc.decisions...)
+}
+
+// event returns the name of the event dispatched for an action
+func (a CanaryAction) event() string {
+	switch a {
+	case CanaryAdvance:
+		return EventCanaryAdvanced
+	case CanaryPromote:
+		return EventCanaryPromoted
+	case CanaryRollback:
+		return EventCanaryRolledBack
+	default:
+		return EventCanaryHeld
+	}
+}
+
+// canaryInterval returns how long a canary's steps last
+func canaryInterval(config CanaryConfig) time.Duration {
+	if config.IncrementDelay <= 0 {
+		return defaultCanaryInterval
+	}
+	return time.Duration(config.IncrementDelay) * time.Second
+}
+
+// canaryMaxWeight returns the weight a canary is promoted from, 100 when
+// unset
+func canaryMaxWeight(config CanaryConfig) int {
+	if config.MaxWeight <= 0 {
+		return 100
+	}
+	return config.MaxWeight
+}
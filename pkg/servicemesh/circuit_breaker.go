@@ -3,6 +3,8 @@ package servicemesh
 import (
 	"sync"
 	"time"
+
+	"google.golang.org/grpc/codes"
 )
 
 // CircuitBreakerState represents circuit breaker state
@@ -31,6 +33,9 @@ type CircuitBreakerConfig struct {
 	SuccessThreshold int           // Number of successes before closing from half-open
 	Timeout          time.Duration // Time to wait before half-open
 	HalfOpenRequests int           // Max requests allowed in half-open state
+	// gRPC status codes counted as failures; Unknown, DeadlineExceeded,
+	// Internal, Unavailable and DataLoss when empty
+	GRPCFailureCodes []codes.Code
 }
 
 // NewCircuitBreaker creates a new circuit breaker
@@ -0,0 +1,654 @@
+package servicemesh
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrPolicyNotFound is returned for services without a stored policy
+var ErrPolicyNotFound = errors.New("traffic policy not found")
+
+const (
+	// defaultPolicyPoll is how often a control plane reads the policies
+	// other replicas stored
+	defaultPolicyPoll = 5 * time.Second
+	// defaultWatchHeartbeat is how often idle watch streams get a heartbeat
+	defaultWatchHeartbeat = 15 * time.Second
+	// watchBuffer is how many changes a watcher may fall behind before its
+	// stream is ended, for it to start over from a snapshot
+	watchBuffer = 64
+)
+
+// PolicyRecord is a traffic policy stored by a control plane. Deleted
+// policies are kept with the revision of their deletion, so every replica
+// of the control plane notices it.
+type PolicyRecord struct {
+	ServiceName string `gorm:"primaryKey;size:255"`
+	Spec        string `gorm:"type:text;not null"` // The policy as YAML
+	Revision    int64  `gorm:"uniqueIndex;not null"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+// TableName of policy records
+func (PolicyRecord) TableName() string {
+	return "mesh_policies"
+}
+
+// PolicyEventType is the kind of a PolicyEvent
+type PolicyEventType string
+
+const (
+	PolicySnapshot  PolicyEventType = "snapshot"  // Every policy, first on a stream
+	PolicyPut       PolicyEventType = "put"       // A policy was created or changed
+	PolicyDelete    PolicyEventType = "delete"    // A policy was deleted
+	PolicyHeartbeat PolicyEventType = "heartbeat" // Nothing changed
+)
+
+// PolicyEvent is a line of a control plane's watch stream. Policies are
+// JSON objects with the fields of their YAML.
+type PolicyEvent struct {
+	Type     PolicyEventType   `json:"type"`
+	Revision int64             `json:"revision"`
+	Service  string            `json:"service,omitempty"`
+	Policy   json.RawMessage   `json:"policy,omitempty"`
+	Policies []json.RawMessage `json:"policies,omitempty"`
+}
+
+// PolicyInfo is a stored policy, as returned by the API
+type PolicyInfo struct {
+	Service   string          `json:"service"`
+	Revision  int64           `json:"revision"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Policy    json.RawMessage `json:"policy"`
+}
+
+// ApplyOptions control how policies are applied
+type ApplyOptions struct {
+	Prune  bool // Deletes the policies of the services not applied
+	DryRun bool // Checks the policies and reports the changes without making them
+}
+
+// ApplyResult reports the changes an apply made, by service
+type ApplyResult struct {
+	Revision  int64    `json:"revision"`
+	Created   []string `json:"created,omitempty"`
+	Updated   []string `json:"updated,omitempty"`
+	Unchanged []string `json:"unchanged,omitempty"`
+	Deleted   []string `json:"deleted,omitempty"`
+	DryRun    bool     `json:"dry_run,omitempty"`
+}
+
+// ControlPlaneConfig configures a control plane
+type ControlPlaneConfig struct {
+	DB    *gorm.DB
+	Token string // Bearer token the API requires; none when empty
+	// How often policies stored by other replicas are picked up
+	// (default 5s)
+	PollInterval time.Duration
+	// How often idle watch streams get a heartbeat (default 15s)
+	Heartbeat time.Duration
+}
+
+// ControlPlane stores the traffic policies of the mesh in the database and
+// pushes them to sidecars watching it, so policies change without
+// redeploying or calling SetPolicy in every process. Replicas share the
+// database; each serves the API and watch streams.
+type ControlPlane struct {
+	db       *gorm.DB
+	config   ControlPlaneConfig
+	policies map[string]*storedPolicy
+	revision int64
+	watchers map[chan PolicyEvent]struct{}
+	closed   bool
+	mu       sync.RWMutex
+	writeMu  sync.Mutex // Serializes this replica's writes
+	app      *fiber.App // Serving Listen; nil until then
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// storedPolicy is a stored policy as cached by a control plane
+type storedPolicy struct {
+	spec      string
+	json      json.RawMessage
+	revision  int64
+	updatedAt time.Time
+}
+
+// NewControlPlane creates a control plane, migrating its table and loading
+// the stored policies
+func NewControlPlane(config ControlPlaneConfig) (*ControlPlane, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("control plane needs a database")
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPolicyPoll
+	}
+	if config.Heartbeat <= 0 {
+		config.Heartbeat = defaultWatchHeartbeat
+	}
+	if err := config.DB.AutoMigrate(&PolicyRecord{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate policies: %w", err)
+	}
+
+	cp := &ControlPlane{
+		db:       config.DB,
+		config:   config,
+		policies: make(map[string]*storedPolicy),
+		watchers: make(map[chan PolicyEvent]struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := cp.sync(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to load policies: %w", err)
+	}
+	go cp.pollLoop()
+	return cp, nil
+}
+
+// Revision returns the revision of the latest change
+func (cp *ControlPlane) Revision() int64 {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+	return cp.revision
+}
+
+// Policy returns the stored policy of a service
+func (cp *ControlPlane) Policy(service string) (*TrafficPolicy, error) {
+	cp.mu.RLock()
+	stored, ok := cp.policies[service]
+	cp.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPolicyNotFound, service)
+	}
+	return parsePolicy([]byte(stored.spec))
+}
+
+// Policies returns the stored policies, by service name
+func (cp *ControlPlane) Policies() []PolicyInfo {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
+	infos := make([]PolicyInfo, 0, len(cp.policies))
+	for service, stored := range cp.policies {
+		infos = append(infos, stored.info(service))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Service < infos[j].Service })
+	return infos
+}
+
+func (p *storedPolicy) info(service string) PolicyInfo {
+	return PolicyInfo{Service: service, Revision: p.revision, UpdatedAt: p.updatedAt, Policy: p.json}
+}
+
+// Apply stores policies, each replacing the service's policy, and pushes
+// the changed ones to watching sidecars. Policies are checked first, and
+// stored in one transaction, so either all are applied or none.
+func (cp *ControlPlane) Apply(ctx context.Context, policies []*TrafficPolicy, opts ApplyOptions) (*ApplyResult, error) {
+	specs := make(map[string]string, len(policies))
+	names := make([]string, 0, len(policies))
+	for i, policy := range policies {
+		if err := policy.validate(); err != nil {
+			return nil, fmt.Errorf("%w: policy %d: %v", ErrInvalidPolicy, i+1, err)
+		}
+		if _, ok := specs[policy.ServiceName]; ok {
+			return nil, fmt.Errorf("%w: %s has more than one policy", ErrInvalidPolicy, policy.ServiceName)
+		}
+		spec, err := yaml.Marshal(policy)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPolicy, policy.ServiceName, err)
+		}
+		specs[policy.ServiceName] = string(spec)
+		names = append(names, policy.ServiceName)
+	}
+	sort.Strings(names)
+
+	cp.writeMu.Lock()
+	defer cp.writeMu.Unlock()
+
+	result := &ApplyResult{DryRun: opts.DryRun}
+	err := cp.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var records []PolicyRecord
+		if err := tx.Find(&records).Error; err != nil {
+			return err
+		}
+		current := make(map[string]string, len(records))
+		for _, record := range records {
+			current[record.ServiceName] = record.Spec
+		}
+		revision, err := latestRevision(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			spec, ok := current[name]
+			switch {
+			case ok && spec == specs[name]:
+				result.Unchanged = append(result.Unchanged, name)
+				continue
+			case ok:
+				result.Updated = append(result.Updated, name)
+			default:
+				result.Created = append(result.Created, name)
+			}
+			revision++
+			if opts.DryRun {
+				continue
+			}
+			// Also restores a deleted policy of the service
+			record := PolicyRecord{ServiceName: name, Spec: specs[name], Revision: revision}
+			if err := tx.Unscoped().Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "service_name"}},
+				DoUpdates: clause.AssignmentColumns([]string{"spec", "revision", "updated_at", "deleted_at"}),
+			}).Create(&record).Error; err != nil {
+				return err
+			}
+		}
+
+		if opts.Prune {
+			for _, record := range records {
+				if _, ok := specs[record.ServiceName]; ok {
+					continue
+				}
+				result.Deleted = append(result.Deleted, record.ServiceName)
+				revision++
+				if opts.DryRun {
+					continue
+				}
+				if err := deletePolicy(tx, record.ServiceName, revision); err != nil {
+					return err
+				}
+			}
+			sort.Strings(result.Deleted)
+		}
+
+		result.Revision = revision
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply policies: %w", err)
+	}
+
+	if !opts.DryRun {
+		if err := cp.sync(ctx); err != nil {
+			log.Printf("Failed to load applied policies: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// Delete deletes the policy of a service, returning the revision of the
+// deletion
+func (cp *ControlPlane) Delete(ctx context.Context, service string) (int64, error) {
+	cp.writeMu.Lock()
+	defer cp.writeMu.Unlock()
+
+	var revision int64
+	err := cp.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&PolicyRecord{}).Where("service_name = ?", service).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: %s", ErrPolicyNotFound, service)
+		}
+		latest, err := latestRevision(tx)
+		if err != nil {
+			return err
+		}
+		revision = latest + 1
+		return deletePolicy(tx, service, revision)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := cp.sync(ctx); err != nil {
+		log.Printf("Failed to load policies: %v", err)
+	}
+	return revision, nil
+}
+
+// latestRevision returns the revision of the latest change, deletions
+// included
+func latestRevision(tx *gorm.DB) (int64, error) {
+	var revision int64
+	err := tx.Unscoped().Model(&PolicyRecord{}).Select("COALESCE(MAX(revision), 0)").Scan(&revision).Error
+	return revision, err
+}
+
+// deletePolicy marks a service's policy deleted at a revision
+func deletePolicy(tx *gorm.DB, service string, revision int64) error {
+	return tx.Model(&PolicyRecord{}).Where("service_name = ?", service).Updates(map[string]interface{}{
+		"revision":   revision,
+		"deleted_at": time.Now(),
+	}).Error
+}
+
+// Watch returns every policy and a channel of the changes after them. The
+// channel is closed when the watcher falls behind, to start over, or the
+// control plane is closed. stop ends the watch.
+func (cp *ControlPlane) Watch() (snapshot PolicyEvent, changes <-chan PolicyEvent, stop func()) {
+	ch := make(chan PolicyEvent, watchBuffer)
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	snapshot = PolicyEvent{Type: PolicySnapshot, Revision: cp.revision, Policies: make([]json.RawMessage, 0, len(cp.policies))}
+	for _, stored := range cp.policies {
+		snapshot.Policies = append(snapshot.Policies, stored.json)
+	}
+	if cp.closed {
+		close(ch)
+		return snapshot, ch, func() {}
+	}
+	cp.watchers[ch] = struct{}{}
+
+	stop = func() {
+		cp.mu.Lock()
+		defer cp.mu.Unlock()
+		if _, ok := cp.watchers[ch]; ok {
+			delete(cp.watchers, ch)
+			close(ch)
+		}
+	}
+	return snapshot, ch, stop
+}
+
+// broadcast sends a change to the watchers, ending the watches of those
+// that fell behind. cp.mu must be held.
+func (cp *ControlPlane) broadcast(event PolicyEvent) {
+	for ch := range cp.watchers {
+		select {
+		case ch <- event:
+		default:
+			delete(cp.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// sync loads the stored policies, pushing the changes since the last sync
+// to the watchers in the order they were made, whichever replica made them
+func (cp *ControlPlane) sync(ctx context.Context) error {
+	var records []PolicyRecord
+	if err := cp.db.WithContext(ctx).Unscoped().Order("revision").Find(&records).Error; err != nil {
+		return err
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	live := make(map[string]bool, len(records))
+	for _, record := range records {
+		if record.Revision > cp.revision {
+			cp.revision = record.Revision
+		}
+		stored, ok := cp.policies[record.ServiceName]
+		if record.DeletedAt.Valid {
+			if ok {
+				delete(cp.policies, record.ServiceName)
+				cp.broadcast(PolicyEvent{Type: PolicyDelete, Revision: record.Revision, Service: record.ServiceName})
+			}
+			continue
+		}
+		live[record.ServiceName] = true
+		if ok && stored.revision == record.Revision {
+			continue
+		}
+
+		data, err := yamlToJSON([]byte(record.Spec))
+		if err != nil {
+			log.Printf("Skipping unreadable policy of %s: %v", record.ServiceName, err)
+			continue
+		}
+		cp.policies[record.ServiceName] = &storedPolicy{spec: record.Spec, json: data, revision: record.Revision, updatedAt: record.UpdatedAt}
+		cp.broadcast(PolicyEvent{Type: PolicyPut, Revision: record.Revision, Service: record.ServiceName, Policy: data})
+	}
+
+	// Rows removed from the table by hand
+	for service := range cp.policies {
+		if !live[service] {
+			delete(cp.policies, service)
+			cp.broadcast(PolicyEvent{Type: PolicyDelete, Revision: cp.revision, Service: service})
+		}
+	}
+	return nil
+}
+
+// pollLoop picks up the changes of other replicas until closed
+func (cp *ControlPlane) pollLoop() {
+	defer close(cp.done)
+
+	ticker := time.NewTicker(cp.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := cp.sync(context.Background()); err != nil {
+				log.Printf("Failed to load policies: %v", err)
+			}
+		case <-cp.stop:
+			return
+		}
+	}
+}
+
+// Close stops the control plane, ending its watch streams
+func (cp *ControlPlane) Close(ctx context.Context) error {
+	cp.mu.Lock()
+	if cp.closed {
+		cp.mu.Unlock()
+		return nil
+	}
+	cp.closed = true
+	for ch := range cp.watchers {
+		delete(cp.watchers, ch)
+		close(ch)
+	}
+	app := cp.app
+	cp.mu.Unlock()
+
+	close(cp.stop)
+	<-cp.done
+	if app != nil {
+		return app.ShutdownWithContext(ctx)
+	}
+	return nil
+}
+
+// Listen serves the API under /api/v1/mesh on addr, for a control plane
+// running on its own
+func (cp *ControlPlane) Listen(addr string) error {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	cp.Register(app.Group("/api/v1/mesh"))
+
+	cp.mu.Lock()
+	cp.app = app
+	cp.mu.Unlock()
+
+	log.Printf("Starting mesh control plane on %s", addr)
+	return app.Listen(addr)
+}
+
+// Register mounts the API on router:
+//
+//	GET    /policies          Every policy; YAML documents for Accept: application/yaml
+//	GET    /policies/:service A service's policy
+//	PUT    /policies/:service Sets a service's policy, in YAML or JSON
+//	DELETE /policies/:service Deletes a service's policy
+//	POST   /apply             Applies YAML or JSON documents; ?prune=true deletes the policies left out, ?dry_run=true only reports
+//	GET    /watch             Newline-delimited PolicyEvent JSON: a snapshot, then each change
+func (cp *ControlPlane) Register(router fiber.Router) {
+	router.Get("/policies", cp.authorize, cp.listHandler)
+	router.Get("/policies/:service", cp.authorize, cp.getHandler)
+	router.Put("/policies/:service", cp.authorize, cp.putHandler)
+	router.Delete("/policies/:service", cp.authorize, cp.deleteHandler)
+	router.Post("/apply", cp.authorize, cp.applyHandler)
+	router.Get("/watch", cp.authorize, cp.watchHandler)
+}
+
+// authorize requires the configured bearer token
+func (cp *ControlPlane) authorize(c *fiber.Ctx) error {
+	if cp.config.Token != "" && !cp.validToken(c.Get(fiber.HeaderAuthorization)) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid control plane token",
+		})
+	}
+	return c.Next()
+}
+
+// validToken checks an Authorization header's bearer token
+func (cp *ControlPlane) validToken(header string) bool {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(cp.config.Token)) == 1
+}
+
+func (cp *ControlPlane) listHandler(c *fiber.Ctx) error {
+	if strings.Contains(c.Get(fiber.HeaderAccept), "yaml") {
+		infos := cp.Policies()
+		policies := make([]*TrafficPolicy, 0, len(infos))
+		for _, info := range infos {
+			policy, err := cp.Policy(info.Service)
+			if err != nil {
+				continue
+			}
+			policies = append(policies, policy)
+		}
+		text, err := MarshalPolicies(policies)
+		if err != nil {
+			return policyError(c, err)
+		}
+		c.Set(fiber.HeaderContentType, "application/yaml")
+		return c.Send(text)
+	}
+	return c.JSON(fiber.Map{
+		"revision": cp.Revision(),
+		"policies": cp.Policies(),
+	})
+}
+
+func (cp *ControlPlane) getHandler(c *fiber.Ctx) error {
+	cp.mu.RLock()
+	stored, ok := cp.policies[c.Params("service")]
+	cp.mu.RUnlock()
+	if !ok {
+		return policyError(c, fmt.Errorf("%w: %s", ErrPolicyNotFound, c.Params("service")))
+	}
+	if strings.Contains(c.Get(fiber.HeaderAccept), "yaml") {
+		c.Set(fiber.HeaderContentType, "application/yaml")
+		return c.SendString(stored.spec)
+	}
+	return c.JSON(stored.info(c.Params("service")))
+}
+
+func (cp *ControlPlane) putHandler(c *fiber.Ctx) error {
+	policy, err := parsePolicy(c.Body())
+	if err != nil {
+		return policyError(c, err)
+	}
+	service := c.Params("service")
+	if policy.ServiceName == "" {
+		policy.ServiceName = service
+	}
+	if policy.ServiceName != service {
+		return policyError(c, fmt.Errorf("%w: policy is for %s, not %s", ErrInvalidPolicy, policy.ServiceName, service))
+	}
+
+	result, err := cp.Apply(c.UserContext(), []*TrafficPolicy{policy}, ApplyOptions{})
+	if err != nil {
+		return policyError(c, err)
+	}
+	return c.JSON(result)
+}
+
+func (cp *ControlPlane) deleteHandler(c *fiber.Ctx) error {
+	revision, err := cp.Delete(c.UserContext(), c.Params("service"))
+	if err != nil {
+		return policyError(c, err)
+	}
+	return c.JSON(fiber.Map{"revision": revision})
+}
+
+func (cp *ControlPlane) applyHandler(c *fiber.Ctx) error {
+	policies, err := ParsePolicies(c.Body())
+	if err != nil {
+		return policyError(c, err)
+	}
+	result, err := cp.Apply(c.UserContext(), policies, ApplyOptions{
+		Prune:  c.QueryBool("prune"),
+		DryRun: c.QueryBool("dry_run"),
+	})
+	if err != nil {
+		return policyError(c, err)
+	}
+	return c.JSON(result)
+}
+
+// watchHandler streams the policies and their changes, one PolicyEvent
+// per line, with heartbeats while nothing changes
+func (cp *ControlPlane) watchHandler(c *fiber.Ctx) error {
+	snapshot, changes, stop := cp.Watch()
+
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer stop()
+		encoder := json.NewEncoder(w)
+		if encoder.Encode(snapshot) != nil || w.Flush() != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(cp.config.Heartbeat)
+		defer heartbeat.Stop()
+		for {
+			var event PolicyEvent
+			select {
+			case change, ok := <-changes:
+				if !ok {
+					return
+				}
+				event = change
+			case <-heartbeat.C:
+				event = PolicyEvent{Type: PolicyHeartbeat, Revision: cp.Revision()}
+			}
+			// Writes fail once the sidecar is gone
+			if encoder.Encode(event) != nil || w.Flush() != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// policyError answers with the status of an API error
+func policyError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidPolicy):
+		status = fiber.StatusBadRequest
+	case errors.Is(err, ErrPolicyNotFound):
+		status = fiber.StatusNotFound
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,220 @@
+package servicemesh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The control plane's gRPC messages are JSON, so clients need no generated
+// code: they call with grpc.CallContentSubtype(JSONCodec{}.Name()).
+func init() {
+	encoding.RegisterCodec(JSONCodec{})
+}
+
+// JSONCodec encodes gRPC messages as JSON
+type JSONCodec struct{}
+
+// Marshal implements encoding.Codec
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal implements encoding.Codec
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// Name implements encoding.Codec
+func (JSONCodec) Name() string {
+	return "json"
+}
+
+// PolicyRequest names the service of a GetPolicy or DeletePolicy call
+type PolicyRequest struct {
+	Service string `json:"service"`
+}
+
+// PolicyList answers ListPolicies
+type PolicyList struct {
+	Revision int64        `json:"revision"`
+	Policies []PolicyInfo `json:"policies"`
+}
+
+// ApplyRequest holds the YAML or JSON documents of an ApplyPolicies call
+type ApplyRequest struct {
+	Documents string `json:"documents"`
+	Prune     bool   `json:"prune,omitempty"`
+	DryRun    bool   `json:"dry_run,omitempty"`
+}
+
+// DeleteResult answers DeletePolicy
+type DeleteResult struct {
+	Revision int64 `json:"revision"`
+}
+
+// WatchRequest starts a WatchPolicies stream
+type WatchRequest struct{}
+
+// controlPlaneServer is what ControlPlaneServiceDesc serves
+type controlPlaneServer interface {
+	grpcList(ctx context.Context, req *struct{}) (*PolicyList, error)
+	grpcGet(ctx context.Context, req *PolicyRequest) (*PolicyInfo, error)
+	grpcApply(ctx context.Context, req *ApplyRequest) (*ApplyResult, error)
+	grpcDelete(ctx context.Context, req *PolicyRequest) (*DeleteResult, error)
+	grpcWatch(req *WatchRequest, stream grpc.ServerStream) error
+}
+
+// ControlPlaneServiceDesc serves a control plane over gRPC, besides its
+// REST API:
+//
+//	grpcServer.RegisterService(&servicemesh.ControlPlaneServiceDesc, controlPlane)
+//
+// Methods of servicemesh.ControlPlane: ListPolicies, GetPolicy,
+// ApplyPolicies, DeletePolicy and the server stream WatchPolicies. Calls
+// carry the token as "authorization: Bearer <token>" metadata.
+var ControlPlaneServiceDesc = grpc.ServiceDesc{
+	ServiceName: "servicemesh.ControlPlane",
+	HandlerType: (*controlPlaneServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "ListPolicies", Handler: unaryHandler("ListPolicies", controlPlaneServer.grpcList)},
+		{MethodName: "GetPolicy", Handler: unaryHandler("GetPolicy", controlPlaneServer.grpcGet)},
+		{MethodName: "ApplyPolicies", Handler: unaryHandler("ApplyPolicies", controlPlaneServer.grpcApply)},
+		{MethodName: "DeletePolicy", Handler: unaryHandler("DeletePolicy", controlPlaneServer.grpcDelete)},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "WatchPolicies", Handler: watchStreamHandler, ServerStreams: true},
+	},
+	Metadata: "servicemesh/controlplane",
+}
+
+// unaryHandler adapts a method to a grpc.MethodDesc handler
+func unaryHandler[Req, Resp any](method string, fn func(controlPlaneServer, context.Context, *Req) (*Resp, error)) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := new(Req)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return fn(srv.(controlPlaneServer), ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/servicemesh.ControlPlane/" + method}
+		return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return fn(srv.(controlPlaneServer), ctx, req.(*Req))
+		})
+	}
+}
+
+func watchStreamHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(WatchRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(controlPlaneServer).grpcWatch(req, stream)
+}
+
+func (cp *ControlPlane) grpcList(ctx context.Context, _ *struct{}) (*PolicyList, error) {
+	if err := cp.authorizeGRPC(ctx); err != nil {
+		return nil, err
+	}
+	return &PolicyList{Revision: cp.Revision(), Policies: cp.Policies()}, nil
+}
+
+func (cp *ControlPlane) grpcGet(ctx context.Context, req *PolicyRequest) (*PolicyInfo, error) {
+	if err := cp.authorizeGRPC(ctx); err != nil {
+		return nil, err
+	}
+	cp.mu.RLock()
+	stored, ok := cp.policies[req.Service]
+	cp.mu.RUnlock()
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "%v: %s", ErrPolicyNotFound, req.Service)
+	}
+	info := stored.info(req.Service)
+	return &info, nil
+}
+
+func (cp *ControlPlane) grpcApply(ctx context.Context, req *ApplyRequest) (*ApplyResult, error) {
+	if err := cp.authorizeGRPC(ctx); err != nil {
+		return nil, err
+	}
+	policies, err := ParsePolicies([]byte(req.Documents))
+	if err != nil {
+		return nil, grpcPolicyError(err)
+	}
+	result, err := cp.Apply(ctx, policies, ApplyOptions{Prune: req.Prune, DryRun: req.DryRun})
+	if err != nil {
+		return nil, grpcPolicyError(err)
+	}
+	return result, nil
+}
+
+func (cp *ControlPlane) grpcDelete(ctx context.Context, req *PolicyRequest) (*DeleteResult, error) {
+	if err := cp.authorizeGRPC(ctx); err != nil {
+		return nil, err
+	}
+	revision, err := cp.Delete(ctx, req.Service)
+	if err != nil {
+		return nil, grpcPolicyError(err)
+	}
+	return &DeleteResult{Revision: revision}, nil
+}
+
+// grpcWatch streams the policies and their changes, like the REST watch
+func (cp *ControlPlane) grpcWatch(_ *WatchRequest, stream grpc.ServerStream) error {
+	if err := cp.authorizeGRPC(stream.Context()); err != nil {
+		return err
+	}
+	snapshot, changes, stop := cp.Watch()
+	defer stop()
+	if err := stream.SendMsg(&snapshot); err != nil {
+		return err
+	}
+	for {
+		select {
+		case event, ok := <-changes:
+			if !ok {
+				return status.Error(codes.Unavailable, "watch ended, start over")
+			}
+			if err := stream.SendMsg(&event); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// authorizeGRPC requires the configured bearer token in a call's metadata
+func (cp *ControlPlane) authorizeGRPC(ctx context.Context) error {
+	if cp.config.Token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if cp.validToken(value) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid control plane token")
+}
+
+// grpcPolicyError returns the status of an API error
+func grpcPolicyError(err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidPolicy):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrPolicyNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case strings.Contains(err.Error(), context.DeadlineExceeded.Error()):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,522 @@
+package servicemesh
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Protocols requests are counted by
+const (
+	ProtocolHTTP  = "http" // HTTP/1.x, on ProxyPort
+	ProtocolHTTP2 = "h2"   // HTTP/2 other than gRPC, on GRPCPort
+	ProtocolGRPC  = "grpc"
+)
+
+// maxGRPCTimeoutValue is the most digits a grpc-timeout value may have
+const maxGRPCTimeoutValue = 99999999
+
+// defaultGRPCFailureCodes are the gRPC status codes counted as failures
+// when the circuit breaker lists none: the server's fault or unreachable,
+// never the caller's
+var defaultGRPCFailureCodes = []codes.Code{
+	codes.Unknown,
+	codes.DeadlineExceeded,
+	codes.Internal,
+	codes.Unavailable,
+	codes.DataLoss,
+}
+
+// GRPCMethodMetrics counts the calls to one RPC method of a service
+type GRPCMethodMetrics struct {
+	Requests int64            `json:"requests"`
+	Failures int64            `json:"failures"` // Calls ending with a failure code
+	Latency  time.Duration    `json:"latency"`  // Total, over calls
+	Codes    map[string]int64 `json:"codes"`    // Calls by status code, e.g. "OK", "NotFound"
+}
+
+// snapshot returns a copy of the metrics, safe to read without the lock
+func (m *GRPCMethodMetrics) snapshot() GRPCMethodMetrics {
+	snapshot := *m
+	snapshot.Codes = make(map[string]int64, len(m.Codes))
+	for code, count := range m.Codes {
+		snapshot.Codes[code] = count
+	}
+	return snapshot
+}
+
+// newHTTP2Transport returns the client of HTTP/2 upstreams: cleartext
+// (h2c) for grpc and http instances, TLS for grpcs and https ones
+func newHTTP2Transport(tlsConfig *tls.Config) *http.Transport {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Transport{
+		Protocols:       protocols,
+		TLSClientConfig: tlsConfig,
+	}
+}
+
+// newGRPCServer returns the server of the HTTP/2 listener, which accepts
+// cleartext HTTP/2 (h2c) from the local service
+func (s *SidecarProxy) newGRPCServer() *http.Server {
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Server{
+		Addr:      fmt.Sprintf(":%d", s.config.GRPCPort),
+		Handler:   http.HandlerFunc(s.grpcHandler),
+		Protocols: protocols,
+	}
+}
+
+// grpcHandler proxies HTTP/2 requests, gRPC calls included. Bodies are
+// streamed both ways, so streaming RPCs work, and calls are not retried;
+// gRPC clients retry by their own service config. Each call's deadline,
+// from its grpc-timeout and the traffic policy of its method, is passed
+// on to the upstream.
+func (s *SidecarProxy) grpcHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	grpc := isGRPC(r.Header.Get("Content-Type"))
+	protocol := ProtocolHTTP2
+	if grpc {
+		protocol = ProtocolGRPC
+	}
+
+	s.metrics.mu.Lock()
+	s.metrics.RequestsTotal++
+	s.metrics.ActiveConnections++
+	s.metrics.Protocols[protocol]++
+	s.metrics.mu.Unlock()
+
+	defer func() {
+		s.metrics.mu.Lock()
+		s.metrics.ActiveConnections--
+		s.metrics.RequestDuration = append(s.metrics.RequestDuration, time.Since(startTime))
+		s.metrics.mu.Unlock()
+	}()
+
+	targetService := r.Header.Get("X-Target-Service")
+	if targetService == "" {
+		targetService = s.serviceName
+	}
+
+	if s.circuitBreaker != nil && s.circuitBreaker.IsOpen() {
+		s.metrics.mu.Lock()
+		s.metrics.CircuitBreakerOpen++
+		s.metrics.mu.Unlock()
+		writeUpstreamError(w, grpc, codes.Unavailable, http.StatusServiceUnavailable, "circuit breaker is open")
+		return
+	}
+
+	dest := s.traffic.Route(targetService, routeHTTPRequest(r))
+	if dest.Rule != "" {
+		s.metrics.mu.Lock()
+		s.metrics.RouteMatches[dest.Rule]++
+		s.metrics.mu.Unlock()
+	}
+	targetService = dest.Service
+
+	if err := s.requireMTLS(targetService); err != nil {
+		s.recordFailure()
+		writeUpstreamError(w, grpc, codes.Unavailable, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	lbConfig, balancer := s.balancer(targetService)
+	instances, err := s.registry.DiscoverHealthy(targetService)
+	if err == nil && dest.Version != "" {
+		instances, err = filterVersion(instances, targetService, dest.Version)
+	}
+	outlierConfig, detector := s.outlierDetector(targetService)
+	if err == nil && detector != nil {
+		instances = detector.filter(instances)
+	}
+	if err != nil {
+		s.recordNoInstance(lbConfig.Strategy)
+		s.recordFailure()
+		writeUpstreamError(w, grpc, codes.Unavailable, http.StatusServiceUnavailable, fmt.Sprintf("service discovery failed: %v", err))
+		return
+	}
+
+	// The call's deadline: the caller's, shortened by the method's policy
+	ctx := r.Context()
+	if timeout, ok := parseGRPCTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if _, timeout := s.traffic.RequestPolicy(targetService, r.URL.Path); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	instance := s.pick(balancer, lbConfig.Strategy, instances, httpBalancerKey(r, lbConfig))
+	scheme := s.upstreamScheme(targetService, instance)
+
+	rw := &grpcResponseWriter{ResponseWriter: w}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = scheme
+			pr.Out.URL.Host = net.JoinHostPort(instance.Host, strconv.Itoa(instance.Port))
+			pr.Out.Host = ""
+			if s.config.EnableTracing {
+				pr.Out.Header.Set("X-Request-ID", headerOr(r.Header, "X-Request-ID", generateRequestID()))
+				pr.Out.Header.Set("X-B3-TraceId", generateTraceID())
+				pr.Out.Header.Set("X-B3-SpanId", generateSpanID())
+			}
+			pr.Out.Header.Set("X-Mesh-Service", s.serviceName)
+			pr.Out.Header.Set("X-Mesh-Version", "1.0")
+			pr.Out.Header.Del("Grpc-Timeout")
+			if deadline, ok := ctx.Deadline(); ok && grpc {
+				pr.Out.Header.Set("Grpc-Timeout", encodeGRPCTimeout(time.Until(deadline)))
+			}
+		},
+		Transport:     s.h2,
+		FlushInterval: -1, // Stream messages as they come
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			switch code := abortCode(ctx, r.Context()); code {
+			case codes.DeadlineExceeded:
+				writeUpstreamError(w, grpc, code, http.StatusGatewayTimeout, fmt.Sprintf("upstream request timed out: %v", err))
+			case codes.Canceled:
+				writeUpstreamError(w, grpc, code, 499, "request canceled by the caller")
+			default:
+				if isTimeout(err) {
+					writeUpstreamError(w, grpc, codes.DeadlineExceeded, http.StatusGatewayTimeout, fmt.Sprintf("upstream request timed out: %v", err))
+					return
+				}
+				writeUpstreamError(w, grpc, code, http.StatusBadGateway, fmt.Sprintf("failed to forward request: %v", err))
+			}
+		},
+	}
+
+	// Streamed request bodies have no length; count them as they are sent
+	body := &countingReader{ReadCloser: r.Body}
+	r.Body = body
+
+	sent := time.Now()
+	aborted := false
+	if ctx.Err() != nil {
+		// Expired before it could be sent
+		writeUpstreamError(rw, grpc, codes.DeadlineExceeded, http.StatusGatewayTimeout, "deadline exceeded before the request was sent")
+	} else {
+		aborted = serveProxy(proxy, rw, r.WithContext(ctx))
+	}
+	latency := time.Since(sent)
+
+	// gRPC calls succeed or fail by the status in their trailers, whatever
+	// their HTTP status
+	var failed bool
+	if grpc {
+		code := grpcStatus(w.Header())
+		if aborted {
+			code = abortCode(ctx, r.Context())
+		}
+		failed = s.grpcFailure(code)
+		s.recordGRPCMethod(targetService, r.URL.Path, code, failed, latency)
+		if code == codes.DeadlineExceeded {
+			s.metrics.mu.Lock()
+			s.metrics.TimeoutsTotal++
+			s.metrics.mu.Unlock()
+		}
+	} else {
+		failed = aborted || rw.status >= http.StatusInternalServerError
+	}
+
+	var failure error
+	if failed {
+		failure = fmt.Errorf("upstream call to %s failed", r.URL.Path)
+	}
+	balancer.Done(instance, latency, failure)
+	s.recordBalanced(lbConfig.Strategy, failed)
+	if detector != nil && detector.record(outlierConfig, instance, len(instances), failed) {
+		s.recordEjection(targetService, instance)
+	}
+	if dest.Version != "" {
+		s.recordVersion(targetService, dest.Version, failed, latency)
+	}
+
+	s.metrics.mu.Lock()
+	s.metrics.BytesSent += body.n.Load()
+	s.metrics.BytesReceived += rw.written
+	s.metrics.mu.Unlock()
+	if failed {
+		s.recordFailure()
+	} else {
+		s.recordSuccess()
+	}
+	if aborted {
+		// Reset the caller's stream, as the upstream's was
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// abortCode returns the status of a call that ended without a response
+// from the upstream: past its deadline, canceled by the caller, or the
+// upstream unreachable
+func abortCode(ctx, callerCtx context.Context) codes.Code {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case callerCtx.Err() != nil:
+		return codes.Canceled
+	default:
+		return codes.Unavailable
+	}
+}
+
+// countingReader counts the bytes read from a request body. The proxy may
+// read it on another goroutine.
+type countingReader struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
+
+// serveProxy proxies a request, reporting whether the upstream failed
+// after the response had begun, which the proxy aborts the handler on
+func serveProxy(proxy *httputil.ReverseProxy, w http.ResponseWriter, r *http.Request) (aborted bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			if v != http.ErrAbortHandler {
+				panic(v)
+			}
+			aborted = true
+		}
+	}()
+	proxy.ServeHTTP(w, r)
+	return false
+}
+
+// grpcFailure reports whether a status code counts as a failure, by the
+// circuit breaker's GRPCFailureCodes or the defaults
+func (s *SidecarProxy) grpcFailure(code codes.Code) bool {
+	failures := defaultGRPCFailureCodes
+	if s.config.CircuitBreakerCfg != nil && len(s.config.CircuitBreakerCfg.GRPCFailureCodes) > 0 {
+		failures = s.config.CircuitBreakerCfg.GRPCFailureCodes
+	}
+	for _, failure := range failures {
+		if code == failure {
+			return true
+		}
+	}
+	return false
+}
+
+// recordGRPCMethod counts a call to an RPC method of a service
+func (s *SidecarProxy) recordGRPCMethod(service, method string, code codes.Code, failed bool, latency time.Duration) {
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+
+	key := service + method
+	metrics, ok := s.metrics.GRPCMethods[key]
+	if !ok {
+		metrics = &GRPCMethodMetrics{Codes: make(map[string]int64)}
+		s.metrics.GRPCMethods[key] = metrics
+	}
+	metrics.Requests++
+	if failed {
+		metrics.Failures++
+	}
+	metrics.Latency += latency
+	metrics.Codes[code.String()]++
+}
+
+// grpcResponseWriter records the status and size of a proxied response.
+// It unwraps to the server's writer, so the proxy can flush streamed
+// messages through it.
+type grpcResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (w *grpcResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *grpcResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *grpcResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// writeUpstreamError answers a call the sidecar could not complete: with a
+// trailers-only gRPC status for gRPC calls, else with an HTTP status
+func writeUpstreamError(w http.ResponseWriter, grpc bool, code codes.Code, status int, message string) {
+	if !grpc {
+		http.Error(w, message, status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(code)))
+	w.Header().Set("Grpc-Message", message)
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcStatus returns the status code of a proxied gRPC response, from its
+// trailers or, for trailers-only responses, its headers. A response
+// without one, such as a cut stream, is Unknown.
+func grpcStatus(header http.Header) codes.Code {
+	value := header.Get(http.TrailerPrefix + "Grpc-Status")
+	if value == "" {
+		value = header.Get("Grpc-Status")
+	}
+	code, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return codes.Unknown
+	}
+	return codes.Code(code)
+}
+
+// isGRPC reports whether a content type is gRPC's, e.g. "application/grpc+proto"
+func isGRPC(contentType string) bool {
+	return contentType == "application/grpc" ||
+		strings.HasPrefix(contentType, "application/grpc+") ||
+		strings.HasPrefix(contentType, "application/grpc;")
+}
+
+// parseGRPCTimeout parses a grpc-timeout header: at most 8 digits and a
+// unit, e.g. "250m" for 250 milliseconds
+func parseGRPCTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	var unit time.Duration
+	switch value[len(value)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+	if n > math.MaxInt64/int64(unit) {
+		return time.Duration(math.MaxInt64), true
+	}
+	return time.Duration(n) * unit, true
+}
+
+// encodeGRPCTimeout encodes a grpc-timeout header, in the finest unit
+// that fits
+func encodeGRPCTimeout(d time.Duration) string {
+	if d <= 0 {
+		return "0n"
+	}
+	for _, unit := range []struct {
+		d    time.Duration
+		name string
+	}{
+		{time.Nanosecond, "n"},
+		{time.Microsecond, "u"},
+		{time.Millisecond, "m"},
+		{time.Second, "S"},
+		{time.Minute, "M"},
+	} {
+		if d/unit.d <= maxGRPCTimeoutValue {
+			return strconv.FormatInt(int64(d/unit.d), 10) + unit.name
+		}
+	}
+	return strconv.FormatInt(min(int64(d/time.Hour), maxGRPCTimeoutValue), 10) + "H"
+}
+
+// routeHTTPRequest returns what an HTTP/2 request is routed by
+func routeHTTPRequest(r *http.Request) RouteRequest {
+	req := RouteRequest{
+		Path:    r.URL.Path,
+		Headers: make(map[string]string, len(r.Header)),
+		Query:   make(map[string]string),
+	}
+	for key, values := range r.Header {
+		if len(values) > 0 {
+			req.Headers[key] = values[0]
+		}
+	}
+	for key, values := range r.URL.Query() {
+		if len(values) > 0 {
+			req.Query[key] = values[0]
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		req.ClientIP = host
+	}
+	return req
+}
+
+// httpBalancerKey returns the key a hashing strategy maps an HTTP/2
+// request by, as balancerKey does for HTTP/1.x
+func httpBalancerKey(r *http.Request, lbConfig LoadBalancerConfig) string {
+	switch lbConfig.Strategy {
+	case StrategyIPHash:
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		return host
+	case StrategyConsistentHash:
+		if lbConfig.HashHeader != "" {
+			if key := r.Header.Get(lbConfig.HashHeader); key != "" {
+				return key
+			}
+		}
+		if lbConfig.HashCookie != "" {
+			if cookie, err := r.Cookie(lbConfig.HashCookie); err == nil {
+				return cookie.Value
+			}
+		}
+	}
+	return ""
+}
+
+// headerOr returns a header's value, or fallback when it is absent
+func headerOr(header http.Header, key, fallback string) string {
+	if value := header.Get(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// serveGRPC runs the HTTP/2 listener until Stop
+func (s *SidecarProxy) serveGRPC() {
+	log.Printf("Starting gRPC proxy for %s on port %d", s.serviceName, s.config.GRPCPort)
+	if err := s.grpcServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("gRPC proxy stopped: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package servicemesh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Mirroring defaults
+const (
+	defaultMirrorTimeout     = 5 * time.Second
+	defaultMaxMirrorRequests = 100
+)
+
+// MirrorHeader marks mirrored requests, so a shadow can skip side effects
+// such as charging a card or sending an email
+const MirrorHeader = "X-Mesh-Shadow"
+
+// MirrorConfig duplicates a share of a service's requests to a shadow:
+// another service, or instances of another version of the same one. The
+// sidecar sends the copies once the requests are answered and discards
+// the shadow's responses, comparing their status and latency to those of
+// the real ones.
+type MirrorConfig struct {
+	Service string        `yaml:"service,omitempty"` // The shadow service; the mirrored service when empty
+	Version string        `yaml:"version,omitempty"` // Only the shadow's instances of this "version" metadata; any when empty
+	Percent float64       `yaml:"percent"`           // Of the requests mirrored, 0-100
+	Timeout time.Duration `yaml:"timeout,omitempty"` // Of a mirrored request (default 5s)
+}
+
+// validate checks the share and timeout
+func (m *MirrorConfig) validate() error {
+	if m == nil {
+		return nil
+	}
+	if m.Percent < 0 || m.Percent > 100 {
+		return fmt.Errorf("mirror percent must be 0-100, got %g", m.Percent)
+	}
+	if m.Timeout < 0 {
+		return fmt.Errorf("mirror timeout must not be negative, got %s", m.Timeout)
+	}
+	return nil
+}
+
+// sampled reports whether to mirror a request
+func (m *MirrorConfig) sampled() bool {
+	return m != nil && m.Percent > 0 && rand.Float64()*100 < m.Percent
+}
+
+// MirrorMetrics compares a shadow's responses to those of the service it
+// mirrors
+type MirrorMetrics struct {
+	Mirrored         int64            // Copies sent
+	Dropped          int64            // Copies not sent, with too many in flight
+	Failed           int64            // Copies that got no response
+	StatusMismatches int64            // Responses whose status differed from the real one
+	Statuses         map[string]int64 // Mismatches by real and shadow status, e.g. "200->500"
+	LatencyDelta     time.Duration    // Total shadow latency less real latency, over responses
+	Responses        int64            // Copies answered
+}
+
+// snapshot summarizes the metrics
+func (m *MirrorMetrics) snapshot() map[string]interface{} {
+	mismatchRate, avgDelta := 0.0, 0.0
+	if m.Responses > 0 {
+		mismatchRate = float64(m.StatusMismatches) / float64(m.Responses)
+		avgDelta = float64(m.LatencyDelta.Microseconds()) / 1000 / float64(m.Responses)
+	}
+	statuses := make(map[string]int64, len(m.Statuses))
+	for pair, count := range m.Statuses {
+		statuses[pair] = count
+	}
+	return map[string]interface{}{
+		"mirrored":             m.Mirrored,
+		"dropped":              m.Dropped,
+		"failed":               m.Failed,
+		"responses":            m.Responses,
+		"status_mismatches":    m.StatusMismatches,
+		"status_mismatch_rate": mismatchRate,
+		"mismatched_statuses":  statuses,
+		"avg_latency_delta_ms": avgDelta,
+	}
+}
+
+// mirroredRequest is a copy of a request, kept past the handler since
+// Fiber reuses its buffers
+type mirroredRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+func copyRequest(c *fiber.Ctx) *mirroredRequest {
+	req := &mirroredRequest{
+		method: c.Method(),
+		path:   strings.Clone(c.Path()), // As the real request is forwarded
+		header: make(http.Header),
+		body:   bytes.Clone(c.Body()),
+	}
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		req.header.Set(string(key), string(value))
+	})
+	return req
+}
+
+// mirror sends a copy of a request to the shadow of its service without
+// waiting for it, given how the real request went. Copies are dropped
+// while MaxMirrorRequests are in flight.
+func (s *SidecarProxy) mirror(service string, config *MirrorConfig, req *mirroredRequest, status int, latency time.Duration) {
+	shadow := config.Service
+	if shadow == "" {
+		shadow = service
+	}
+	key := service + "->" + shadow
+	if config.Version != "" {
+		key += "@" + config.Version
+	}
+
+	select {
+	case s.mirrors <- struct{}{}:
+	default:
+		s.metrics.mu.Lock()
+		s.metrics.mirror(key).Dropped++
+		s.metrics.mu.Unlock()
+		return
+	}
+
+	go func() {
+		defer func() { <-s.mirrors }()
+
+		timeout := config.Timeout
+		if timeout == 0 {
+			timeout = defaultMirrorTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		sent := time.Now()
+		shadowStatus, err := s.sendMirror(ctx, shadow, config.Version, req)
+		shadowLatency := time.Since(sent)
+
+		s.metrics.mu.Lock()
+		defer s.metrics.mu.Unlock()
+		m := s.metrics.mirror(key)
+		m.Mirrored++
+		if err != nil {
+			m.Failed++
+			return
+		}
+		m.Responses++
+		m.LatencyDelta += shadowLatency - latency
+		if shadowStatus != status {
+			m.StatusMismatches++
+			m.Statuses[strconv.Itoa(status)+"->"+strconv.Itoa(shadowStatus)]++
+		}
+	}()
+}
+
+// sendMirror sends a mirrored request to an instance of the shadow,
+// returning its status
+func (s *SidecarProxy) sendMirror(ctx context.Context, shadow, version string, req *mirroredRequest) (int, error) {
+	if err := s.requireMTLS(shadow); err != nil {
+		return 0, err
+	}
+	instance, err := s.registry.DiscoverVersion(shadow, version)
+	if err != nil {
+		return 0, err
+	}
+
+	targetURL := fmt.Sprintf("%s://%s:%d%s", s.upstreamScheme(shadow, instance), instance.Host, instance.Port, req.path)
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, targetURL, bytes.NewReader(req.body))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header = req.header
+	httpReq.Header.Set("X-Mesh-Service", s.serviceName)
+	httpReq.Header.Set(MirrorHeader, "true")
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// mirror returns a shadow's metrics; the caller holds the lock
+func (m *ProxyMetrics) mirror(key string) *MirrorMetrics {
+	metrics, ok := m.Mirrors[key]
+	if !ok {
+		metrics = &MirrorMetrics{Statuses: make(map[string]int64)}
+		m.Mirrors[key] = metrics
+	}
+	return metrics
+}
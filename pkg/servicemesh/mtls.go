@@ -0,0 +1,66 @@
+package servicemesh
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MTLSMode is how sidecars reach a service's instances
+type MTLSMode string
+
+const (
+	// MTLSPermissive reaches each instance by its registered protocol
+	MTLSPermissive MTLSMode = "permissive"
+	// MTLSStrict only reaches the service over mutual TLS: http and grpc
+	// instances are reached as https and grpcs, and sidecars without mTLS
+	// refuse requests to it
+	MTLSStrict MTLSMode = "strict"
+)
+
+// ErrMTLSRequired is returned for requests to a service requiring mTLS
+// from a sidecar without it
+var ErrMTLSRequired = errors.New("service requires mTLS")
+
+// MTLSPolicy sets how sidecars reach a service
+type MTLSPolicy struct {
+	Mode MTLSMode `yaml:"mode"` // MTLSPermissive when empty
+}
+
+// validate checks the mode
+func (m *MTLSPolicy) validate() error {
+	if m == nil {
+		return nil
+	}
+	switch m.Mode {
+	case "", MTLSPermissive, MTLSStrict:
+		return nil
+	}
+	return fmt.Errorf("unknown mTLS mode: %s", m.Mode)
+}
+
+// strict reports whether the policy requires mTLS
+func (m *MTLSPolicy) strict() bool {
+	return m != nil && m.Mode == MTLSStrict
+}
+
+// requireMTLS refuses requests to a service requiring mTLS when the
+// sidecar has none
+func (s *SidecarProxy) requireMTLS(service string) error {
+	if policy := s.traffic.GetPolicy(service); policy != nil && policy.MTLS.strict() && s.tlsConfig == nil {
+		return fmt.Errorf("%w: %s", ErrMTLSRequired, service)
+	}
+	return nil
+}
+
+// upstreamScheme returns the URL scheme an instance of a service is
+// reached by: https for https and grpcs instances and services requiring
+// mTLS, http otherwise
+func (s *SidecarProxy) upstreamScheme(service string, instance *ServiceInstance) string {
+	if instance.Protocol == "https" || instance.Protocol == "grpcs" {
+		return "https"
+	}
+	if policy := s.traffic.GetPolicy(service); policy != nil && policy.MTLS.strict() {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,170 @@
+package servicemesh
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Outlier detection defaults
+const (
+	defaultConsecutive5xx     = 5
+	defaultBaseEjectionTime   = 30 * time.Second
+	defaultMaxEjectionTime    = 5 * time.Minute
+	defaultMaxEjectionPercent = 10
+)
+
+// OutlierDetection ejects the instances of a service that fail several
+// requests in a row, so the sidecar stops picking them for a while. 5xx
+// responses, connection failures and timeouts count as failures.
+type OutlierDetection struct {
+	Consecutive5xx     int           `yaml:"consecutive_5xx,omitempty"`      // Failures in a row that eject an instance (default 5)
+	BaseEjectionTime   time.Duration `yaml:"base_ejection_time,omitempty"`   // First ejection; each following one lasts that much longer (default 30s)
+	MaxEjectionTime    time.Duration `yaml:"max_ejection_time,omitempty"`    // Longest ejection (default 5m)
+	MaxEjectionPercent int           `yaml:"max_ejection_percent,omitempty"` // Of the service's instances ejected at once, at least one (default 10)
+}
+
+// validate checks the settings, negative values being invalid
+func (o *OutlierDetection) validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.Consecutive5xx < 0 || o.BaseEjectionTime < 0 || o.MaxEjectionTime < 0 {
+		return fmt.Errorf("outlier detection settings must not be negative")
+	}
+	if o.MaxEjectionPercent < 0 || o.MaxEjectionPercent > 100 {
+		return fmt.Errorf("max ejection percent must be 0-100, got %d", o.MaxEjectionPercent)
+	}
+	return nil
+}
+
+// withDefaults returns the settings with zero values defaulted
+func (o OutlierDetection) withDefaults() OutlierDetection {
+	if o.Consecutive5xx == 0 {
+		o.Consecutive5xx = defaultConsecutive5xx
+	}
+	if o.BaseEjectionTime == 0 {
+		o.BaseEjectionTime = defaultBaseEjectionTime
+	}
+	if o.MaxEjectionTime == 0 {
+		o.MaxEjectionTime = defaultMaxEjectionTime
+	}
+	if o.MaxEjectionPercent == 0 {
+		o.MaxEjectionPercent = defaultMaxEjectionPercent
+	}
+	return o
+}
+
+// outlierDetector tracks the failures in a row of a service's instances
+type outlierDetector struct {
+	mu    sync.Mutex
+	hosts map[string]*outlierHost // By instanceKey
+}
+
+// outlierHost is the state of an instance
+type outlierHost struct {
+	failures     int       // In a row
+	ejections    int       // So far, lengthening the next
+	ejectedUntil time.Time // Zero when never ejected
+}
+
+func newOutlierDetector() *outlierDetector {
+	return &outlierDetector{hosts: make(map[string]*outlierHost)}
+}
+
+// filter returns the instances not ejected. When every instance is, all
+// are returned, since an ejected instance beats none.
+func (d *outlierDetector) filter(instances []*ServiceInstance) []*ServiceInstance {
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	available := make([]*ServiceInstance, 0, len(instances))
+	for _, instance := range instances {
+		if host, ok := d.hosts[instanceKey(instance)]; ok && now.Before(host.ejectedUntil) {
+			continue
+		}
+		available = append(available, instance)
+	}
+	if len(available) == 0 {
+		return instances
+	}
+	return available
+}
+
+// record counts how a request to an instance went, of total instances,
+// and reports whether it ejected the instance
+func (d *outlierDetector) record(config OutlierDetection, instance *ServiceInstance, total int, failed bool) bool {
+	now := time.Now()
+	key := instanceKey(instance)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	host, ok := d.hosts[key]
+	if !ok {
+		if !failed {
+			return false
+		}
+		host = &outlierHost{}
+		d.hosts[key] = host
+	}
+
+	if !failed {
+		host.failures = 0
+		// Forget past ejections once the instance has been well for as
+		// long as the longest ejection
+		if host.ejections > 0 && now.After(host.ejectedUntil.Add(config.MaxEjectionTime)) {
+			delete(d.hosts, key)
+		}
+		return false
+	}
+
+	host.failures++
+	if host.failures < config.Consecutive5xx || now.Before(host.ejectedUntil) {
+		return false
+	}
+
+	// Keep enough instances to take the traffic
+	maxEjected := total * config.MaxEjectionPercent / 100
+	if maxEjected < 1 {
+		maxEjected = 1
+	}
+	if d.ejected(now) >= maxEjected {
+		return false
+	}
+
+	host.ejections++
+	ejection := config.BaseEjectionTime * time.Duration(host.ejections)
+	if ejection > config.MaxEjectionTime {
+		ejection = config.MaxEjectionTime
+	}
+	host.ejectedUntil = now.Add(ejection)
+	host.failures = 0
+	return true
+}
+
+// ejected counts the instances ejected at now; the caller holds the lock
+func (d *outlierDetector) ejected(now time.Time) int {
+	count := 0
+	for _, host := range d.hosts {
+		if now.Before(host.ejectedUntil) {
+			count++
+		}
+	}
+	return count
+}
+
+// ejectedKeys returns the keys of the instances ejected now
+func (d *outlierDetector) ejectedKeys() []string {
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var keys []string
+	for key, host := range d.hosts {
+		if now.Before(host.ejectedUntil) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,106 @@
+package servicemesh
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidPolicy is returned for policies that do not parse or validate
+var ErrInvalidPolicy = errors.New("invalid traffic policy")
+
+// ParsePolicies parses traffic policies from YAML or JSON: a policy or a
+// list of policies per document, documents separated by "---". Durations
+// are strings such as "250ms" or "1m", and unknown fields are refused.
+//
+//	service: orders
+//	timeout: 5s
+//	retry:
+//	  max_attempts: 3
+//	rate_limits:
+//	  - requests: 100
+//	    per: 1m
+//	mtls:
+//	  mode: strict
+func ParsePolicies(data []byte) ([]*TrafficPolicy, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var policies []*TrafficPolicy
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		// Decoded again from its own text, so unknown fields are refused
+		text, err := yaml.Marshal(&doc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+		}
+		strict := yaml.NewDecoder(bytes.NewReader(text))
+		strict.KnownFields(true)
+		if doc.Content[0].Kind == yaml.SequenceNode {
+			var list []*TrafficPolicy
+			if err := strict.Decode(&list); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+			}
+			policies = append(policies, list...)
+			continue
+		}
+		policy := &TrafficPolicy{}
+		if err := strict.Decode(policy); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// MarshalPolicies returns policies as YAML documents, which ParsePolicies
+// reads back
+func MarshalPolicies(policies []*TrafficPolicy) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, policy := range policies {
+		if err := encoder.Encode(policy); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlToJSON converts a YAML document to JSON with the same fields, so
+// policies read the same in both
+func yamlToJSON(text []byte) (json.RawMessage, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(text, &value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// parsePolicy parses a single policy in YAML or JSON
+func parsePolicy(data []byte) (*TrafficPolicy, error) {
+	policies, err := ParsePolicies(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) != 1 {
+		return nil, fmt.Errorf("%w: expected one policy, got %d", ErrInvalidPolicy, len(policies))
+	}
+	return policies[0], nil
+}
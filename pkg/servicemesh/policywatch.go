@@ -0,0 +1,169 @@
+package servicemesh
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// policyWatchIdle is how long a watch stream may stay silent, missing
+// heartbeats, before it is dropped and opened again
+const policyWatchIdle = 3 * defaultWatchHeartbeat
+
+// PolicyWatcher keeps the policies of a TrafficManager in line with a
+// control plane, from its watch stream. Policies set otherwise are left
+// alone unless the control plane has one for the same service.
+type PolicyWatcher struct {
+	server   string
+	token    string
+	traffic  *TrafficManager
+	client   *http.Client
+	managed  map[string]bool // Services with a policy from the control plane
+	revision atomic.Int64
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+	mu       sync.Mutex
+}
+
+// NewPolicyWatcher creates a watcher of the control plane at server, e.g.
+// "http://mesh-control:7070"
+func NewPolicyWatcher(server, token string, traffic *TrafficManager) *PolicyWatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PolicyWatcher{
+		server:  strings.TrimRight(server, "/"),
+		token:   token,
+		traffic: traffic,
+		client:  &http.Client{},
+		managed: make(map[string]bool),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+}
+
+// Start watches the control plane until Stop, watching again when the
+// stream fails
+func (w *PolicyWatcher) Start() {
+	go func() {
+		defer close(w.done)
+		for {
+			err := w.watch()
+			if w.ctx.Err() != nil {
+				return
+			}
+			log.Printf("Watch of policies failed, retrying in %s: %v", watchRetry, err)
+
+			select {
+			case <-time.After(watchRetry):
+			case <-w.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops watching, keeping the policies last received
+func (w *PolicyWatcher) Stop() {
+	w.cancel()
+	<-w.done
+}
+
+// Revision returns the revision of the policies last received
+func (w *PolicyWatcher) Revision() int64 {
+	return w.revision.Load()
+}
+
+// watch follows a watch stream until it fails
+func (w *PolicyWatcher) watch() error {
+	ctx, cancel := context.WithCancel(w.ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.server+"/api/v1/mesh/watch", nil)
+	if err != nil {
+		return err
+	}
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("control plane returned %d", resp.StatusCode)
+	}
+
+	// Heartbeats keep a healthy stream busy
+	idle := time.AfterFunc(policyWatchIdle, cancel)
+	defer idle.Stop()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		idle.Reset(policyWatchIdle)
+		var event PolicyEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("invalid event: %w", err)
+		}
+		if err := w.apply(event); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("stream ended")
+}
+
+// apply applies an event to the traffic manager
+func (w *PolicyWatcher) apply(event PolicyEvent) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch event.Type {
+	case PolicySnapshot:
+		received := make(map[string]bool, len(event.Policies))
+		for _, data := range event.Policies {
+			policy, err := parsePolicy(data)
+			if err != nil {
+				return err
+			}
+			if err := w.traffic.SetPolicy(policy); err != nil {
+				return fmt.Errorf("policy of %s: %w", policy.ServiceName, err)
+			}
+			received[policy.ServiceName] = true
+		}
+		// Deleted while the stream was down
+		for service := range w.managed {
+			if !received[service] {
+				w.traffic.RemovePolicy(service)
+			}
+		}
+		w.managed = received
+	case PolicyPut:
+		policy, err := parsePolicy(event.Policy)
+		if err != nil {
+			return err
+		}
+		if err := w.traffic.SetPolicy(policy); err != nil {
+			return fmt.Errorf("policy of %s: %w", policy.ServiceName, err)
+		}
+		w.managed[policy.ServiceName] = true
+	case PolicyDelete:
+		if w.managed[event.Service] {
+			w.traffic.RemovePolicy(event.Service)
+			delete(w.managed, event.Service)
+		}
+	}
+	w.revision.Store(event.Revision)
+	return nil
+}
@@ -0,0 +1,311 @@
+package servicemesh
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimitKey is what a rate limit counts requests by
+type RateLimitKey string
+
+const (
+	RateLimitByIP      RateLimitKey = "ip"      // The client's IP
+	RateLimitByHeader  RateLimitKey = "header"  // A header's value, e.g. an API key
+	RateLimitByService RateLimitKey = "service" // The calling service, from its sidecar's X-Mesh-Service header
+)
+
+const (
+	// defaultRateLimitSync is how often sidecars share their consumption
+	defaultRateLimitSync = 500 * time.Millisecond
+	// rateLimitPrefix starts the Redis keys of rate limits
+	rateLimitPrefix = "mesh:ratelimit:"
+)
+
+// RateLimitPolicy limits the requests to a service to Requests every Per,
+// for each client IP, header value or calling service. Requests come in
+// bursts of up to Burst. Clients without the header or service identity
+// are counted by IP.
+type RateLimitPolicy struct {
+	Name     string        `yaml:"name,omitempty"`   // In metrics and Redis keys; derived from the limit when empty
+	Requests int           `yaml:"requests"`         // Allowed every Per
+	Per      time.Duration `yaml:"per"`              // e.g. time.Second or time.Minute
+	Burst    int           `yaml:"burst,omitempty"`  // Requests when zero
+	Key      RateLimitKey  `yaml:"key,omitempty"`    // RateLimitByIP when empty
+	Header   string        `yaml:"header,omitempty"` // RateLimitByHeader: the header, e.g. X-API-Key
+}
+
+// validate checks the limit and its key
+func (p RateLimitPolicy) validate() error {
+	if p.Requests <= 0 || p.Per <= 0 {
+		return fmt.Errorf("rate limit needs requests and a period, got %d per %s", p.Requests, p.Per)
+	}
+	if p.Burst < 0 {
+		return fmt.Errorf("rate limit burst must not be negative, got %d", p.Burst)
+	}
+	switch p.Key {
+	case "", RateLimitByIP, RateLimitByService:
+	case RateLimitByHeader:
+		if p.Header == "" {
+			return fmt.Errorf("rate limit by header needs a header")
+		}
+	default:
+		return fmt.Errorf("unknown rate limit key: %s", p.Key)
+	}
+	return nil
+}
+
+// name returns the policy's name, e.g. "100_per_1m0s_by_ip"
+func (p RateLimitPolicy) name() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	key := p.Key
+	if key == "" {
+		key = RateLimitByIP
+	}
+	return fmt.Sprintf("%d_per_%s_by_%s", p.Requests, p.Per, key)
+}
+
+func (p RateLimitPolicy) rate() float64 {
+	return float64(p.Requests) / p.Per.Seconds()
+}
+
+func (p RateLimitPolicy) burst() int {
+	if p.Burst > 0 {
+		return p.Burst
+	}
+	return p.Requests
+}
+
+// clientKey returns the client a request counts against
+func (p RateLimitPolicy) clientKey(c *fiber.Ctx) string {
+	switch p.Key {
+	case RateLimitByHeader:
+		if value := c.Get(p.Header); value != "" {
+			// Fiber reuses the request's buffers once the handler returns
+			return "header:" + strings.Clone(value)
+		}
+	case RateLimitByService:
+		if service := c.Get("X-Mesh-Service"); service != "" {
+			return "service:" + strings.Clone(service)
+		}
+	}
+	return "ip:" + c.IP()
+}
+
+// rateBucket is a client's token bucket under a policy, as of updated.
+// Tokens may go below zero when the sidecars together took more than the
+// burst between syncs; the debt is paid off as the bucket refills.
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+	rate    float64
+	burst   int
+	pending int // Tokens taken since the last sync
+}
+
+// refill brings the bucket's tokens up to now
+func (b *rateBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.burst), b.tokens+elapsed*b.rate)
+	}
+	b.updated = now
+}
+
+// until returns how long until the bucket holds tokens
+func (b *rateBucket) until(tokens float64) time.Duration {
+	if b.tokens >= tokens {
+		return 0
+	}
+	return time.Duration((tokens - b.tokens) / b.rate * float64(time.Second))
+}
+
+// rateLimitCheck is a policy a request is checked against
+type rateLimitCheck struct {
+	policy RateLimitPolicy
+	key    string // Bucket and Redis key: service, policy and client
+}
+
+// rateLimitResult is how a request fared against the policies of its
+// service, reported by the most restrictive
+type rateLimitResult struct {
+	allowed   bool
+	policy    string
+	limit     int
+	remaining int
+	reset     time.Duration // Until the bucket is full again
+	retry     time.Duration // Until a request is allowed, when not
+}
+
+// rateLimiter holds token buckets in the process. With Redis, each sync
+// takes the tokens this sidecar took from a bucket in Redis shared by
+// every sidecar, and the local buckets start over from the shared ones.
+// Requests never wait on Redis, so sidecars may together let a little
+// more through than the limit between syncs.
+type rateLimiter struct {
+	client  redis.Cmdable // Nil for limits per sidecar
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+func newRateLimiter(client redis.Cmdable) *rateLimiter {
+	return &rateLimiter{client: client, buckets: make(map[string]*rateBucket)}
+}
+
+// take takes a token of each check's bucket, or none when any is empty
+func (l *rateLimiter) take(checks []rateLimitCheck) rateLimitResult {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buckets := make([]*rateBucket, len(checks))
+	result := rateLimitResult{allowed: true, remaining: math.MaxInt}
+	for i, check := range checks {
+		bucket, ok := l.buckets[check.key]
+		if !ok {
+			bucket = &rateBucket{tokens: float64(check.policy.burst()), updated: now}
+			l.buckets[check.key] = bucket
+		}
+		bucket.rate, bucket.burst = check.policy.rate(), check.policy.burst()
+		bucket.refill(now)
+		buckets[i] = bucket
+
+		if wait := bucket.until(1); wait > 0 && (result.allowed || wait > result.retry) {
+			result.allowed = false
+			result.retry = wait
+			result.policy = check.policy.name()
+			result.limit = check.policy.Requests
+			result.remaining = 0
+			result.reset = bucket.until(float64(bucket.burst))
+		}
+	}
+	if !result.allowed {
+		return result
+	}
+
+	for i, bucket := range buckets {
+		bucket.tokens--
+		bucket.pending++
+		if remaining := int(math.Max(0, bucket.tokens)); remaining < result.remaining {
+			result.policy = checks[i].policy.name()
+			result.limit = checks[i].policy.Requests
+			result.remaining = remaining
+			result.reset = bucket.until(float64(bucket.burst))
+		}
+	}
+	return result
+}
+
+// takeScript refills a shared bucket to the Redis server's time, takes
+// the tokens a sidecar took and returns those left
+var takeScript = redis.NewScript(`
+local rate, burst, taken, ttl = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), ARGV[4]
+local time = redis.call('TIME')
+local now = tonumber(time[1]) + tonumber(time[2]) / 1e6
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens'))
+local updated = tonumber(redis.call('HGET', KEYS[1], 'updated'))
+if tokens == nil or updated == nil then
+	tokens, updated = burst, now
+end
+if now > updated then
+	tokens = math.min(burst, tokens + (now - updated) * rate)
+end
+tokens = math.max(tokens - taken, -burst)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], ttl)
+return tostring(tokens)
+`)
+
+// sync takes the tokens each bucket took since the last sync from its
+// shared bucket, then has it hold the shared bucket's tokens less those
+// taken meanwhile. Buckets idle until full again are dropped.
+func (l *rateLimiter) sync(ctx context.Context) error {
+	type syncing struct {
+		key     string
+		bucket  *rateBucket
+		pending int
+		tokens  *redis.Cmd
+	}
+
+	now := time.Now()
+	l.mu.Lock()
+	var batch []*syncing
+	for key, bucket := range l.buckets {
+		refilled := bucket.updated.Add(bucket.until(float64(bucket.burst)))
+		if bucket.pending == 0 && now.After(refilled) {
+			delete(l.buckets, key)
+			continue
+		}
+		batch = append(batch, &syncing{key: key, bucket: bucket, pending: bucket.pending})
+		bucket.pending = 0
+	}
+	l.mu.Unlock()
+	if l.client == nil || len(batch) == 0 {
+		return nil
+	}
+
+	pipe := l.client.Pipeline()
+	for _, s := range batch {
+		// Shared buckets expire once full again
+		ttl := time.Duration(float64(s.bucket.burst)/s.bucket.rate*float64(time.Second)) + time.Second
+		s.tokens = takeScript.Eval(ctx, pipe, []string{rateLimitPrefix + s.key},
+			s.bucket.rate, s.bucket.burst, s.pending, ttl.Milliseconds())
+	}
+	_, err := pipe.Exec(ctx)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil {
+		// Take the tokens on the next sync
+		for _, s := range batch {
+			s.bucket.pending += s.pending
+		}
+		return err
+	}
+	now = time.Now()
+	for _, s := range batch {
+		text, err := s.tokens.Text()
+		if err != nil {
+			continue
+		}
+		tokens, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			continue
+		}
+		s.bucket.tokens = tokens - float64(s.bucket.pending)
+		s.bucket.updated = now
+	}
+	return nil
+}
+
+// rateLimitChecks returns the rate limits of a request to a service
+func rateLimitChecks(c *fiber.Ctx, service string, policies []RateLimitPolicy) []rateLimitCheck {
+	checks := make([]rateLimitCheck, len(policies))
+	for i, policy := range policies {
+		checks[i] = rateLimitCheck{
+			policy: policy,
+			key:    service + ":" + policy.name() + ":" + policy.clientKey(c),
+		}
+	}
+	return checks
+}
+
+// setHeaders sets the X-RateLimit headers of a response, and Retry-After
+// when the request was refused
+func (r rateLimitResult) setHeaders(c *fiber.Ctx) {
+	c.Set("X-RateLimit-Limit", strconv.Itoa(r.limit))
+	c.Set("X-RateLimit-Remaining", strconv.Itoa(r.remaining))
+	c.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(r.reset).Unix(), 10))
+	if !r.allowed {
+		c.Set("Retry-After", strconv.Itoa(int(math.Ceil(r.retry.Seconds()))))
+	}
+}
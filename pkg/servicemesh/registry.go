@@ -1,19 +1,43 @@
 package servicemesh
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"sync"
 	"time"
 )
 
+// DefaultLeaseTTL is how long an instance stays registered without a
+// heartbeat
+const DefaultLeaseTTL = 30 * time.Second
+
+// ErrInstanceNotFound is returned when renewing an instance that is not
+// registered, or whose lease has expired
+var ErrInstanceNotFound = errors.New("service instance not registered")
+
+// watchRetry is how long a registry waits to watch a service again after
+// its backend's watch failed
+const watchRetry = 5 * time.Second
+
 // ServiceRegistry manages service discovery
 type ServiceRegistry struct {
 	controlPlane string
+	backend      Backend
 	services     map[string][]*ServiceInstance
+	watched      map[string]chan struct{} // Closed once a service's first backend sync finished
+	leaseTTL     time.Duration
+	timeout      time.Duration // Of backend requests
 	mu           sync.RWMutex
 	lastSync     time.Time
+	ctx          context.Context // Done once the registry is closed
+	cancel       context.CancelFunc
+	done         chan struct{}
+	closeOnce    sync.Once
 }
 
 // ServiceInstance represents a service instance
@@ -22,11 +46,30 @@ type ServiceInstance struct {
 	InstanceID  string            `json:"instance_id"`
 	Host        string            `json:"host"`
 	Port        int               `json:"port"`
-	Protocol    string            `json:"protocol"` // http, https, grpc
+	Protocol    string            `json:"protocol"` // http, https, grpc, grpcs
 	Metadata    map[string]string `json:"metadata"`
 	Health      HealthStatus      `json:"health"`
 	RegisteredAt time.Time        `json:"registered_at"`
 	LastHeartbeat time.Time       `json:"last_heartbeat"`
+	// Registered for LeaseTTL after each heartbeat, the registry's default
+	// when zero. Instances without ExpiresAt, such as from an older control
+	// plane, do not expire.
+	LeaseTTL  time.Duration `json:"lease_ttl,omitempty"`
+	ExpiresAt time.Time     `json:"expires_at"`
+}
+
+// expired reports whether the instance's lease has run out
+func (i *ServiceInstance) expired(now time.Time) bool {
+	return !i.ExpiresAt.IsZero() && !now.Before(i.ExpiresAt)
+}
+
+// renew extends the instance's lease from now. Instances without a
+// LeaseTTL, such as those a backend expires, keep no lease locally.
+func (i *ServiceInstance) renew(now time.Time) {
+	i.LastHeartbeat = now
+	if i.LeaseTTL > 0 {
+		i.ExpiresAt = now.Add(i.LeaseTTL)
+	}
 }
 
 // HealthStatus health check status
@@ -40,18 +83,66 @@ const (
 
 // NewServiceRegistry creates a new service registry
 func NewServiceRegistry(controlPlane string) *ServiceRegistry {
+	return newServiceRegistry(controlPlane, nil)
+}
+
+// NewServiceRegistryWithBackend creates a service registry that registers
+// instances with backend, such as Consul or etcd, and discovers the
+// instances other processes registered there. Each discovered service is
+// watched, so discovery reads a local copy kept current by the backend.
+func NewServiceRegistryWithBackend(backend Backend) *ServiceRegistry {
+	return newServiceRegistry("", backend)
+}
+
+func newServiceRegistry(controlPlane string, backend Backend) *ServiceRegistry {
+	ctx, cancel := context.WithCancel(context.Background())
 	registry := &ServiceRegistry{
 		controlPlane: controlPlane,
+		backend:      backend,
 		services:     make(map[string][]*ServiceInstance),
+		watched:      make(map[string]chan struct{}),
+		leaseTTL:     DefaultLeaseTTL,
+		timeout:      DefaultRegistryConfig().Timeout,
+		ctx:          ctx,
+		cancel:       cancel,
+		done:         make(chan struct{}),
 	}
 
-	// Start background sync
+	// Start background sync and removal of expired instances
 	go registry.syncLoop()
+	go registry.expiryLoop()
 
 	return registry
 }
 
-// Register registers a service instance
+// SetLeaseTTL sets the lease of instances registered without their own
+func (r *ServiceRegistry) SetLeaseTTL(ttl time.Duration) {
+	r.mu.Lock()
+	r.leaseTTL = ttl
+	r.mu.Unlock()
+}
+
+// Close stops the registry's background sync, expiry and watches, and
+// closes its backend
+func (r *ServiceRegistry) Close() {
+	r.closeOnce.Do(func() {
+		close(r.done)
+		r.cancel()
+		if r.backend != nil {
+			if err := r.backend.Close(); err != nil {
+				log.Printf("Failed to close service registry backend: %v", err)
+			}
+		}
+	})
+}
+
+// requestContext returns the context of a backend request
+func (r *ServiceRegistry) requestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.ctx, r.timeout)
+}
+
+// Register registers a service instance, leased for its LeaseTTL. An
+// instance registered again under the same ID replaces the old entry.
 func (r *ServiceRegistry) Register(instance *ServiceInstance) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -59,18 +150,37 @@ func (r *ServiceRegistry) Register(instance *ServiceInstance) error {
 	if instance.InstanceID == "" {
 		instance.InstanceID = fmt.Sprintf("%s-%d", instance.ServiceName, time.Now().UnixNano())
 	}
+	if instance.LeaseTTL <= 0 {
+		instance.LeaseTTL = r.leaseTTL
+	}
 
-	instance.RegisteredAt = time.Now()
-	instance.LastHeartbeat = time.Now()
+	now := time.Now()
+	instance.RegisteredAt = now
+	instance.renew(now)
 	instance.Health = HealthStatusHealthy
 
 	// Add to local cache
-	r.services[instance.ServiceName] = append(r.services[instance.ServiceName], instance)
+	instances := r.services[instance.ServiceName]
+	replaced := false
+	for i, existing := range instances {
+		if existing.InstanceID == instance.InstanceID {
+			instances[i] = instance
+			replaced = true
+		}
+	}
+	if !replaced {
+		r.services[instance.ServiceName] = append(instances, instance)
+	}
 
 	// Register with control plane if configured
 	if r.controlPlane != "" {
 		return r.registerWithControlPlane(instance)
 	}
+	if r.backend != nil {
+		ctx, cancel := r.requestContext()
+		defer cancel()
+		return r.backend.Register(ctx, instance)
+	}
 
 	return nil
 }
@@ -80,28 +190,100 @@ func (r *ServiceRegistry) Deregister(serviceName string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	instances := r.services[serviceName]
 	delete(r.services, serviceName)
 
 	if r.controlPlane != "" {
 		return r.deregisterFromControlPlane(serviceName)
 	}
+	if r.backend != nil {
+		ctx, cancel := r.requestContext()
+		defer cancel()
+		var errs []error
+		for _, inst := range instances {
+			errs = append(errs, r.backend.Deregister(ctx, serviceName, inst.InstanceID))
+		}
+		return errors.Join(errs...)
+	}
 
 	return nil
 }
 
-// Discover discovers a service instance
-func (r *ServiceRegistry) Discover(serviceName string) (*ServiceInstance, error) {
-	r.mu.RLock()
+// DeregisterInstance removes one instance of a service, leaving the others
+func (r *ServiceRegistry) DeregisterInstance(serviceName, instanceID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removeInstance(serviceName, instanceID)
+
+	if r.controlPlane != "" {
+		return r.deregisterInstanceFromControlPlane(serviceName, instanceID)
+	}
+	if r.backend != nil {
+		ctx, cancel := r.requestContext()
+		defer cancel()
+		return r.backend.Deregister(ctx, serviceName, instanceID)
+	}
+
+	return nil
+}
+
+// removeInstance drops an instance from the local cache; the caller holds
+// the lock
+func (r *ServiceRegistry) removeInstance(serviceName, instanceID string) {
 	instances := r.services[serviceName]
-	r.mu.RUnlock()
+	remaining := make([]*ServiceInstance, 0, len(instances))
+	for _, inst := range instances {
+		if inst.InstanceID != instanceID {
+			remaining = append(remaining, inst)
+		}
+	}
+	if len(remaining) == 0 {
+		delete(r.services, serviceName)
+	} else {
+		r.services[serviceName] = remaining
+	}
+}
+
+// live returns a service's instances whose leases have not expired
+func (r *ServiceRegistry) live(serviceName string) []*ServiceInstance {
+	r.watch(serviceName)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	instances := make([]*ServiceInstance, 0, len(r.services[serviceName]))
+	for _, inst := range r.services[serviceName] {
+		if !inst.expired(now) {
+			instances = append(instances, inst)
+		}
+	}
+	return instances
+}
+
+// Discover discovers a service instance. Instances whose leases have
+// expired are never returned, even before they are removed.
+func (r *ServiceRegistry) Discover(serviceName string) (*ServiceInstance, error) {
+	healthy, err := r.DiscoverHealthy(serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Simple round-robin; the sidecar proxy balances with a Balancer
+	return healthy[time.Now().UnixNano()%int64(len(healthy))], nil
+}
+
+// DiscoverHealthy discovers the healthy live instances of a service, for
+// a Balancer to pick from
+func (r *ServiceRegistry) DiscoverHealthy(serviceName string) ([]*ServiceInstance, error) {
+	instances := r.live(serviceName)
 
 	if len(instances) == 0 {
 		// Try to fetch from control plane
 		if r.controlPlane != "" {
 			if err := r.syncFromControlPlane(serviceName); err == nil {
-				r.mu.RLock()
-				instances = r.services[serviceName]
-				r.mu.RUnlock()
+				instances = r.live(serviceName)
 			}
 		}
 	}
@@ -122,15 +304,33 @@ func (r *ServiceRegistry) Discover(serviceName string) (*ServiceInstance, error)
 		return nil, fmt.Errorf("no healthy instances for service: %s", serviceName)
 	}
 
-	// Simple round-robin (can be enhanced with load balancing)
+	return healthy, nil
+}
+
+// DiscoverVersion discovers a healthy instance of one version of a
+// service, as chosen by the TrafficManager, matching the instances'
+// "version" metadata. An empty version matches every instance.
+func (r *ServiceRegistry) DiscoverVersion(serviceName, version string) (*ServiceInstance, error) {
+	if version == "" {
+		return r.Discover(serviceName)
+	}
+
+	healthy := make([]*ServiceInstance, 0)
+	for _, inst := range r.live(serviceName) {
+		if inst.Health == HealthStatusHealthy && inst.Metadata["version"] == version {
+			healthy = append(healthy, inst)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil, fmt.Errorf("no healthy instances of %s version %s", serviceName, version)
+	}
+
 	return healthy[time.Now().UnixNano()%int64(len(healthy))], nil
 }
 
-// DiscoverAll discovers all instances of a service
+// DiscoverAll discovers all live instances of a service
 func (r *ServiceRegistry) DiscoverAll(serviceName string) ([]*ServiceInstance, error) {
-	r.mu.RLock()
-	instances := r.services[serviceName]
-	r.mu.RUnlock()
+	instances := r.live(serviceName)
 
 	if len(instances) == 0 {
 		return nil, fmt.Errorf("no instances found for service: %s", serviceName)
@@ -144,14 +344,59 @@ func (r *ServiceRegistry) Heartbeat(serviceName string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	now := time.Now()
 	instances := r.services[serviceName]
 	for _, inst := range instances {
-		inst.LastHeartbeat = time.Now()
+		inst.renew(now)
 	}
 
 	if r.controlPlane != "" {
 		return r.heartbeatToControlPlane(serviceName)
 	}
+	if r.backend != nil {
+		// Renew the instances this process registered; others' leases are
+		// not held here
+		ctx, cancel := r.requestContext()
+		defer cancel()
+		for _, inst := range instances {
+			if err := r.backend.Renew(ctx, serviceName, inst.InstanceID); err != nil && !errors.Is(err, ErrInstanceNotFound) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Renew extends the lease of one instance. It returns ErrInstanceNotFound
+// when the instance has expired or was removed, to be registered again.
+func (r *ServiceRegistry) Renew(serviceName, instanceID string) error {
+	if r.backend != nil {
+		// The backend holds the lease
+		ctx, cancel := r.requestContext()
+		defer cancel()
+		return r.backend.Renew(ctx, serviceName, instanceID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var instance *ServiceInstance
+	for _, inst := range r.services[serviceName] {
+		if inst.InstanceID == instanceID {
+			instance = inst
+		}
+	}
+	if instance == nil || instance.expired(now) {
+		r.removeInstance(serviceName, instanceID)
+		return fmt.Errorf("%w: %s/%s", ErrInstanceNotFound, serviceName, instanceID)
+	}
+	instance.renew(now)
+
+	if r.controlPlane != "" {
+		return r.renewWithControlPlane(serviceName, instanceID)
+	}
 
 	return nil
 }
@@ -182,12 +427,9 @@ func (r *ServiceRegistry) ListServices() []string {
 	return services
 }
 
-// GetServiceInstances gets all instances for a service
+// GetServiceInstances gets all live instances for a service
 func (r *ServiceRegistry) GetServiceInstances(serviceName string) []*ServiceInstance {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	return r.services[serviceName]
+	return r.live(serviceName)
 }
 
 // syncLoop periodically syncs with control plane
@@ -199,9 +441,122 @@ func (r *ServiceRegistry) syncLoop() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		r.syncAllFromControlPlane()
+	for {
+		select {
+		case <-ticker.C:
+			r.syncAllFromControlPlane()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// watch starts keeping a service's instances current from the backend,
+// the first time the service is discovered, and waits for its first sync
+func (r *ServiceRegistry) watch(serviceName string) {
+	if r.backend == nil {
+		return
+	}
+
+	r.mu.Lock()
+	synced, ok := r.watched[serviceName]
+	if !ok {
+		synced = make(chan struct{})
+		r.watched[serviceName] = synced
+	}
+	r.mu.Unlock()
+	if ok {
+		select {
+		case <-synced:
+		case <-r.ctx.Done():
+		}
+		return
+	}
+
+	ctx, cancel := r.requestContext()
+	instances, err := r.backend.Instances(ctx, serviceName)
+	cancel()
+	if err != nil {
+		log.Printf("Failed to discover %s: %v", serviceName, err)
+	} else {
+		r.replace(serviceName, instances)
+	}
+	close(synced)
+
+	go r.watchLoop(serviceName)
+}
+
+// watchLoop keeps a service's instances current until the registry is
+// closed, watching again when a watch fails
+func (r *ServiceRegistry) watchLoop(serviceName string) {
+	for {
+		err := r.backend.Watch(r.ctx, serviceName, func(instances []*ServiceInstance) {
+			r.replace(serviceName, instances)
+		})
+		if r.ctx.Err() != nil {
+			return
+		}
+		log.Printf("Watch of %s failed, retrying in %s: %v", serviceName, watchRetry, err)
+
+		select {
+		case <-time.After(watchRetry):
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+// replace sets a service's instances to those its backend returned
+func (r *ServiceRegistry) replace(serviceName string, instances []*ServiceInstance) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(instances) == 0 {
+		delete(r.services, serviceName)
+	} else {
+		r.services[serviceName] = instances
+	}
+}
+
+// expiryLoop removes instances whose leases have expired
+func (r *ServiceRegistry) expiryLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.RemoveExpired()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// RemoveExpired removes the instances whose leases have expired and
+// returns them
+func (r *ServiceRegistry) RemoveExpired() []*ServiceInstance {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var expired []*ServiceInstance
+	for serviceName, instances := range r.services {
+		live := make([]*ServiceInstance, 0, len(instances))
+		for _, inst := range instances {
+			if inst.expired(now) {
+				expired = append(expired, inst)
+			} else {
+				live = append(live, inst)
+			}
+		}
+		if len(live) == 0 {
+			delete(r.services, serviceName)
+		} else {
+			r.services[serviceName] = live
+		}
 	}
+	return expired
 }
 
 // registerWithControlPlane registers with control plane
@@ -214,7 +569,7 @@ func (r *ServiceRegistry) registerWithControlPlane(instance *ServiceInstance) er
 	resp, err := http.Post(
 		fmt.Sprintf("%s/api/v1/services/register", r.controlPlane),
 		"application/json",
-		nil,
+		bytes.NewReader(body),
 	)
 	if err != nil {
 		return err
@@ -248,6 +603,27 @@ func (r *ServiceRegistry) deregisterFromControlPlane(serviceName string) error {
 	return nil
 }
 
+// deregisterInstanceFromControlPlane deregisters one instance from control
+// plane
+func (r *ServiceRegistry) deregisterInstanceFromControlPlane(serviceName, instanceID string) error {
+	req, err := http.NewRequest(
+		http.MethodDelete,
+		fmt.Sprintf("%s/api/v1/services/%s/instances/%s", r.controlPlane, serviceName, instanceID),
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
 // syncFromControlPlane syncs a specific service from control plane
 func (r *ServiceRegistry) syncFromControlPlane(serviceName string) error {
 	resp, err := http.Get(
@@ -316,6 +692,25 @@ func (r *ServiceRegistry) heartbeatToControlPlane(serviceName string) error {
 	return nil
 }
 
+// renewWithControlPlane renews one instance's lease with control plane
+func (r *ServiceRegistry) renewWithControlPlane(serviceName, instanceID string) error {
+	resp, err := http.Post(
+		fmt.Sprintf("%s/api/v1/services/%s/instances/%s/heartbeat", r.controlPlane, serviceName, instanceID),
+		"application/json",
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s/%s", ErrInstanceNotFound, serviceName, instanceID)
+	}
+
+	return nil
+}
+
 // CleanupStaleInstances removes instances that haven't sent heartbeat
 func (r *ServiceRegistry) CleanupStaleInstances(timeout time.Duration) {
 	r.mu.Lock()
@@ -332,3 +727,87 @@ func (r *ServiceRegistry) CleanupStaleInstances(timeout time.Duration) {
 		r.services[serviceName] = healthy
 	}
 }
+
+// Lease keeps an instance registered while its process is alive. It
+// renews the instance every third of its lease until released, and
+// registers it again if the lease expired meanwhile, for instance after
+// the process was paused.
+type Lease struct {
+	registry *ServiceRegistry
+	instance *ServiceInstance
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// RegisterWithLease registers an instance and renews its lease in the
+// background. Release it on shutdown, so discovery stops returning the
+// instance at once rather than when the lease expires:
+//
+//	lease, err := registry.RegisterWithLease(instance)
+//	app.OnShutdown("service registry", lease.Release)
+func (r *ServiceRegistry) RegisterWithLease(instance *ServiceInstance) (*Lease, error) {
+	if err := r.Register(instance); err != nil {
+		return nil, err
+	}
+
+	lease := &Lease{
+		registry: r,
+		instance: instance,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go lease.renewLoop()
+	return lease, nil
+}
+
+// Instance returns the leased instance
+func (l *Lease) Instance() *ServiceInstance {
+	return l.instance
+}
+
+func (l *Lease) renewLoop() {
+	defer close(l.done)
+
+	interval := l.instance.LeaseTTL / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := l.registry.Renew(l.instance.ServiceName, l.instance.InstanceID)
+			if errors.Is(err, ErrInstanceNotFound) {
+				err = l.registry.Register(l.instance)
+			}
+			if err != nil {
+				log.Printf("Failed to renew lease of %s/%s: %v", l.instance.ServiceName, l.instance.InstanceID, err)
+			}
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// Release stops renewing the lease and deregisters the instance. It may
+// be called more than once.
+func (l *Lease) Release(ctx context.Context) error {
+	released := false
+	l.once.Do(func() {
+		close(l.stop)
+		released = true
+	})
+	if !released {
+		return nil
+	}
+
+	select {
+	case <-l.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return l.registry.DeregisterInstance(l.instance.ServiceName, l.instance.InstanceID)
+}
@@ -0,0 +1,109 @@
+package servicemesh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Conditions a RetryPolicy retries on, alongside status codes such as "503"
+const (
+	RetryOn5xx            = "5xx"             // 5xx responses, connection failures and timeouts
+	RetryOnGatewayError   = "gateway-error"   // 502, 503 and 504 responses
+	RetryOnConnectFailure = "connect-failure" // Requests that got no response
+	RetryOnTimeout        = "timeout"         // Attempts exceeding the per-try timeout
+	RetryOnRetriable4xx   = "retriable-4xx"   // 409 responses
+)
+
+const (
+	// defaultTryTimeout limits each attempt without a timeout of its own
+	defaultTryTimeout = 30 * time.Second
+	// retryBackoff is the wait before the first retry, growing with each
+	retryBackoff = 100 * time.Millisecond
+)
+
+// validate checks the policy's attempts and conditions; a nil policy is
+// valid
+func (p *RetryPolicy) validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxAttempts < 0 {
+		return fmt.Errorf("retry attempts must not be negative, got %d", p.MaxAttempts)
+	}
+	if p.PerTryTimeout < 0 {
+		return fmt.Errorf("per-try timeout must not be negative, got %s", p.PerTryTimeout)
+	}
+	for _, condition := range p.RetryOn {
+		switch condition {
+		case RetryOn5xx, RetryOnGatewayError, RetryOnConnectFailure, RetryOnTimeout, RetryOnRetriable4xx:
+		default:
+			if code, err := strconv.Atoi(condition); err != nil || code < 100 || code > 599 {
+				return fmt.Errorf("unknown retry condition: %s", condition)
+			}
+		}
+	}
+	return nil
+}
+
+// attempts returns how many times a request is sent at most
+func (p *RetryPolicy) attempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// retriable reports whether an attempt that ended with status, or failed
+// with err, is retried. Without conditions, 5xx responses and failures
+// are.
+func (p *RetryPolicy) retriable(status int, err error) bool {
+	conditions := p.RetryOn
+	if len(conditions) == 0 {
+		conditions = []string{RetryOn5xx}
+	}
+
+	timedOut := err != nil && isTimeout(err)
+	for _, condition := range conditions {
+		switch condition {
+		case RetryOn5xx:
+			if err != nil || status >= 500 {
+				return true
+			}
+		case RetryOnGatewayError:
+			if status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout {
+				return true
+			}
+		case RetryOnConnectFailure:
+			if err != nil && !timedOut {
+				return true
+			}
+		case RetryOnTimeout:
+			if timedOut {
+				return true
+			}
+		case RetryOnRetriable4xx:
+			if status == http.StatusConflict {
+				return true
+			}
+		default:
+			if err == nil && strconv.Itoa(status) == condition {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isTimeout reports whether a request failed by running out of time
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
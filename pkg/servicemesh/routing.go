@@ -0,0 +1,159 @@
+package servicemesh
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxRouteHops bounds how many services a request may be routed through,
+// in case match rules send services to each other
+const maxRouteHops = 4
+
+// MatchRule routes the requests of a service it matches to a version, or
+// to another service. Every condition set must hold: an exact path, a
+// path prefix or a path regex, and header and query values. A header or
+// query value of "*" only requires it to be present.
+type MatchRule struct {
+	Name       string            `yaml:"name,omitempty"` // In metrics; the rule's position when empty
+	Path       string            `yaml:"path,omitempty"`
+	PathPrefix string            `yaml:"path_prefix,omitempty"`
+	PathRegex  string            `yaml:"path_regex,omitempty"` // e.g. ^/api/v2/orders/[0-9]+$
+	Headers    map[string]string `yaml:"headers,omitempty"`
+	Query      map[string]string `yaml:"query,omitempty"`
+	// Where matching requests go: instances of Version ("version"
+	// metadata) of Service. The routed service when Service is empty; its
+	// canary, A/B test or splits pick the version when Version is empty.
+	Service string `yaml:"service,omitempty"`
+	Version string `yaml:"version,omitempty"`
+
+	pathRegex *regexp.Regexp
+}
+
+// compile checks the rule and compiles its path regex
+func (r *MatchRule) compile() error {
+	if r.Service == "" && r.Version == "" {
+		return fmt.Errorf("match rule %s routes nowhere: set a service or version", r.Name)
+	}
+	if r.PathRegex != "" {
+		re, err := regexp.Compile(r.PathRegex)
+		if err != nil {
+			return fmt.Errorf("match rule %s: invalid path regex: %w", r.Name, err)
+		}
+		r.pathRegex = re
+	}
+	return nil
+}
+
+// matches reports whether a request meets every condition of the rule
+func (r *MatchRule) matches(req RouteRequest) bool {
+	if r.Path != "" && req.Path != r.Path {
+		return false
+	}
+	if r.PathPrefix != "" && !strings.HasPrefix(req.Path, r.PathPrefix) {
+		return false
+	}
+	if r.pathRegex != nil && !r.pathRegex.MatchString(req.Path) {
+		return false
+	}
+	return matchValues(r.Headers, req.Headers) && matchValues(r.Query, req.Query)
+}
+
+// matchValues reports whether values hold each wanted value, "*" meaning
+// any present one
+func matchValues(want, values map[string]string) bool {
+	for key, value := range want {
+		got, ok := values[key]
+		if !ok || (value != "*" && got != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// RouteRequest is what match rules, A/B tests and splits route a request by
+type RouteRequest struct {
+	Path     string
+	Headers  map[string]string // Canonical header names, e.g. X-Beta
+	Query    map[string]string
+	ClientIP string
+}
+
+// Destination is where a request is routed
+type Destination struct {
+	Service string
+	Version string // Any instance when empty
+	Rule    string // The match rule that routed it, if any
+}
+
+// Route returns where a request to a service goes. The first match rule
+// of the service's policy that matches decides; a rule routing to another
+// service hands the request to that service's policy. Without a rule
+// setting the version, the canary, A/B test or splits of the final
+// service pick it.
+func (tm *TrafficManager) Route(serviceName string, req RouteRequest) Destination {
+	dest := Destination{Service: serviceName}
+	for hop := 0; hop < maxRouteHops; hop++ {
+		policy := tm.GetPolicy(dest.Service)
+		if policy == nil {
+			return dest
+		}
+
+		var matched *MatchRule
+		for i := range policy.Matches {
+			if policy.Matches[i].matches(req) {
+				matched = &policy.Matches[i]
+				break
+			}
+		}
+		if matched == nil {
+			dest.Version = tm.SelectVersion(dest.Service, req.Headers, req.ClientIP)
+			return dest
+		}
+
+		dest.Rule = dest.Service + "/" + matched.Name
+		if matched.Version != "" {
+			if matched.Service != "" {
+				dest.Service = matched.Service
+			}
+			dest.Version = matched.Version
+			return dest
+		}
+		if matched.Service == dest.Service {
+			dest.Version = tm.SelectVersion(dest.Service, req.Headers, req.ClientIP)
+			return dest
+		}
+		dest.Service = matched.Service
+	}
+	return dest
+}
+
+// routeRequest returns what a request is routed by
+func routeRequest(c *fiber.Ctx) RouteRequest {
+	req := RouteRequest{
+		Path:     c.Path(),
+		Headers:  make(map[string]string),
+		Query:    c.Queries(),
+		ClientIP: c.IP(),
+	}
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		req.Headers[string(key)] = string(value)
+	})
+	return req
+}
+
+// filterVersion returns the instances of a version, by "version" metadata
+func filterVersion(instances []*ServiceInstance, service, version string) ([]*ServiceInstance, error) {
+	matching := make([]*ServiceInstance, 0, len(instances))
+	for _, instance := range instances {
+		if instance.Metadata["version"] == version {
+			matching = append(matching, instance)
+		}
+	}
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("no healthy instances of %s version %s", service, version)
+	}
+	return matching, nil
+}
@@ -1,9 +1,11 @@
 package servicemesh
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -13,6 +15,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
 )
 
 // SidecarProxy represents a service mesh sidecar proxy
@@ -24,9 +27,20 @@ type SidecarProxy struct {
 	config         *SidecarConfig
 	metrics        *ProxyMetrics
 	registry       *ServiceRegistry
+	lease          *Lease
 	tlsConfig      *tls.Config
 	routingRules   map[string]*RoutingRule
 	circuitBreaker *CircuitBreaker
+	balancers      map[string]Balancer
+	traffic        *TrafficManager
+	outliers       map[string]*outlierDetector
+	rateLimiter    *rateLimiter
+	mirrors        chan struct{} // Mirrored requests in flight
+	canary         *CanaryController
+	policies       *PolicyWatcher // Nil without PolicyServer
+	client         *http.Client
+	h2             *http.Transport // HTTP/2 and gRPC upstreams
+	grpcServer     *http.Server    // Listens on GRPCPort, nil without it
 	mu             sync.RWMutex
 	app            *fiber.App
 	shutdown       chan struct{}
@@ -38,6 +52,7 @@ type SidecarConfig struct {
 	ServicePort       int
 	ProxyPort         int
 	ControlPlane      string
+	RegistryBackend   Backend // Consul or etcd, instead of ControlPlane
 	EnableMTLS        bool
 	EnableTracing     bool
 	EnableMetrics     bool
@@ -48,6 +63,33 @@ type SidecarConfig struct {
 	TLSCertFile       string
 	TLSKeyFile        string
 	TLSCAFile         string
+	// How requests spread over the instances of each upstream service, with
+	// "*" for services not listed; round-robin by default
+	LoadBalancing map[string]LoadBalancerConfig
+	// Retries, deadlines, outlier detection and rate limits of upstream
+	// services; an empty manager when nil
+	Traffic *TrafficManager
+	// Shares rate limits between the sidecars of a service, syncing every
+	// RateLimitSync (default 500ms); limits are per sidecar when nil
+	RateLimitRedis redis.Cmdable
+	RateLimitSync  time.Duration
+	// Mirrored requests in flight at once, beyond which copies are dropped
+	// (default 100)
+	MaxMirrorRequests int
+	// Advances, promotes and rolls back the canaries of Traffic by the
+	// requests this sidecar sends them, dispatching an event for each
+	// decision
+	CanaryAnalysis bool
+	// Proxies HTTP/2 and gRPC, cleartext (h2c), on this port besides
+	// ProxyPort; none when 0
+	GRPCPort int
+	// Protocol the service is registered with: "http" (default), "https",
+	// "grpc" or "grpcs"
+	ServiceProtocol string
+	// Control plane whose policies replace those of Traffic as they change,
+	// e.g. "http://mesh-control:7070"; none when empty
+	PolicyServer string
+	PolicyToken  string
 }
 
 // ProxyMetrics metrics collected by sidecar
@@ -61,6 +103,17 @@ type ProxyMetrics struct {
 	ActiveConnections  int64
 	CircuitBreakerOpen int64
 	RetriesTotal       int64
+	TimeoutsTotal      int64 // Requests past their deadline
+	Ejections          int64 // Instances ejected by outlier detection
+	RateLimited        int64 // Requests refused with 429
+	RateLimitErrors    int64 // Failed syncs of rate limits with Redis
+	RateLimits         map[string]*RateLimitMetrics
+	Mirrors            map[string]*MirrorMetrics // By "service->shadow"
+	RouteMatches       map[string]int64          // Requests routed by each match rule, by "service/rule"
+	Versions           map[string]*VersionStats  // Requests to each version chosen by routing, by "service@version"
+	Balancing          map[LoadBalancingStrategy]*BalancerMetrics
+	Protocols          map[string]int64              // Requests by protocol: "http", "h2" or "grpc"
+	GRPCMethods        map[string]*GRPCMethodMetrics // Calls to each RPC method, by "service/package.Service/Method"
 	mu                 sync.RWMutex
 }
 
@@ -77,9 +130,9 @@ type RoutingRule struct {
 
 // RetryPolicy retry configuration
 type RetryPolicy struct {
-	MaxAttempts int
-	PerTryTimeout time.Duration
-	RetryOn []string // HTTP status codes or error types
+	MaxAttempts   int           `yaml:"max_attempts"`
+	PerTryTimeout time.Duration `yaml:"per_try_timeout,omitempty"`
+	RetryOn       []string      `yaml:"retry_on,omitempty"` // HTTP status codes or RetryOn* conditions; RetryOn5xx when empty
 }
 
 // NewSidecarProxy creates a new sidecar proxy
@@ -94,10 +147,31 @@ func NewSidecarProxy(config *SidecarConfig) (*SidecarProxy, error) {
 		proxyPort:    config.ProxyPort,
 		controlPlane: config.ControlPlane,
 		config:       config,
-		metrics:      &ProxyMetrics{},
+		metrics:      &ProxyMetrics{Balancing: make(map[LoadBalancingStrategy]*BalancerMetrics), RateLimits: make(map[string]*RateLimitMetrics), Mirrors: make(map[string]*MirrorMetrics), RouteMatches: make(map[string]int64), Versions: make(map[string]*VersionStats), Protocols: make(map[string]int64), GRPCMethods: make(map[string]*GRPCMethodMetrics)},
 		routingRules: make(map[string]*RoutingRule),
+		balancers:    make(map[string]Balancer),
+		traffic:      config.Traffic,
+		outliers:     make(map[string]*outlierDetector),
+		rateLimiter:  newRateLimiter(config.RateLimitRedis),
 		shutdown:     make(chan struct{}),
 	}
+	maxMirrors := config.MaxMirrorRequests
+	if maxMirrors <= 0 {
+		maxMirrors = defaultMaxMirrorRequests
+	}
+	proxy.mirrors = make(chan struct{}, maxMirrors)
+	if proxy.traffic == nil {
+		proxy.traffic = NewTrafficManager()
+	}
+
+	// Create the configured balancers, checking their settings
+	for service, lbConfig := range config.LoadBalancing {
+		balancer, err := NewBalancer(lbConfig)
+		if err != nil {
+			return nil, fmt.Errorf("load balancing for %s: %w", service, err)
+		}
+		proxy.balancers[service] = balancer
+	}
 
 	// Initialize TLS if enabled
 	if config.EnableMTLS {
@@ -108,13 +182,26 @@ func NewSidecarProxy(config *SidecarConfig) (*SidecarProxy, error) {
 		proxy.tlsConfig = tlsConfig
 	}
 
+	// Attempts are limited by their context, so the client has no timeout
+	proxy.client = &http.Client{}
+	if proxy.tlsConfig != nil {
+		proxy.client.Transport = &http.Transport{
+			TLSClientConfig: proxy.tlsConfig,
+		}
+	}
+	proxy.h2 = newHTTP2Transport(proxy.tlsConfig)
+
 	// Initialize circuit breaker
 	if config.CircuitBreakerCfg != nil {
 		proxy.circuitBreaker = NewCircuitBreaker(config.CircuitBreakerCfg)
 	}
 
 	// Initialize service registry
-	proxy.registry = NewServiceRegistry(config.ControlPlane)
+	if config.RegistryBackend != nil {
+		proxy.registry = NewServiceRegistryWithBackend(config.RegistryBackend)
+	} else {
+		proxy.registry = NewServiceRegistry(config.ControlPlane)
+	}
 
 	// Setup Fiber app for proxy
 	proxy.app = fiber.New(fiber.Config{
@@ -122,6 +209,24 @@ func NewSidecarProxy(config *SidecarConfig) (*SidecarProxy, error) {
 	})
 
 	proxy.setupRoutes()
+	if config.GRPCPort > 0 {
+		proxy.grpcServer = proxy.newGRPCServer()
+	}
+
+	// Sync rate limits, and drop the buckets of idle clients
+	go proxy.syncRateLimits()
+
+	// Follow the policies of the control plane
+	if config.PolicyServer != "" {
+		proxy.policies = NewPolicyWatcher(config.PolicyServer, config.PolicyToken, proxy.traffic)
+		proxy.policies.Start()
+	}
+
+	// Judge canaries by the requests sent to them
+	if config.CanaryAnalysis {
+		proxy.canary = NewCanaryController(proxy.traffic, proxy, nil)
+		proxy.canary.Start()
+	}
 
 	return proxy, nil
 }
@@ -180,6 +285,7 @@ func (s *SidecarProxy) proxyHandler(c *fiber.Ctx) error {
 	s.metrics.mu.Lock()
 	s.metrics.RequestsTotal++
 	s.metrics.ActiveConnections++
+	s.metrics.Protocols[ProtocolHTTP]++
 	s.metrics.mu.Unlock()
 
 	defer func() {
@@ -198,6 +304,15 @@ func (s *SidecarProxy) proxyHandler(c *fiber.Ctx) error {
 	// Get routing rule
 	rule := s.getRoutingRule(targetService)
 
+	// Check rate limits
+	limit, limited := s.rateLimit(c, targetService)
+	if limited {
+		limit.setHeaders(c)
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error": "rate limit exceeded",
+		})
+	}
+
 	// Check circuit breaker
 	if s.circuitBreaker != nil && s.circuitBreaker.IsOpen() {
 		s.metrics.mu.Lock()
@@ -208,55 +323,154 @@ func (s *SidecarProxy) proxyHandler(c *fiber.Ctx) error {
 		})
 	}
 
-	// Discover service instance
-	instance, err := s.registry.Discover(targetService)
+	// Route the request by its path, headers and query, then by the
+	// canary, A/B test or splits of the service it lands on
+	dest := s.traffic.Route(targetService, routeRequest(c))
+	if dest.Rule != "" {
+		s.metrics.mu.Lock()
+		s.metrics.RouteMatches[dest.Rule]++
+		s.metrics.mu.Unlock()
+	}
+	targetService = dest.Service
+
+	// Services requiring mTLS are only reached with it
+	if err := s.requireMTLS(targetService); err != nil {
+		s.recordFailure()
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Discover the service's healthy instances
+	lbConfig, balancer := s.balancer(targetService)
+	instances, err := s.registry.DiscoverHealthy(targetService)
+	if err == nil && dest.Version != "" {
+		instances, err = filterVersion(instances, targetService, dest.Version)
+	}
 	if err != nil {
+		s.recordNoInstance(lbConfig.Strategy)
 		s.recordFailure()
 		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
 			"error": fmt.Sprintf("service discovery failed: %v", err),
 		})
 	}
+	hashKey := balancerKey(c, lbConfig)
 
-	// Build target URL
-	targetURL := fmt.Sprintf("%s://%s:%d%s",
-		instance.Protocol,
-		instance.Host,
-		instance.Port,
-		c.Path(),
-	)
+	// Retries and deadline: the traffic policy's, else the routing rule's
+	retry, timeout := s.traffic.RequestPolicy(targetService, c.Path())
+	if retry == nil && s.config.EnableRetry && rule != nil {
+		retry = rule.RetryPolicy
+	}
+	tryTimeout := defaultTryTimeout
+	if retry != nil && retry.PerTryTimeout > 0 {
+		tryTimeout = retry.PerTryTimeout
+	} else if rule != nil && rule.Timeout > 0 {
+		tryTimeout = rule.Timeout
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Skip the instances outlier detection ejected
+	outlierConfig, detector := s.outlierDetector(targetService)
+	if detector != nil {
+		instances = detector.filter(instances)
+	}
 
 	// Perform request with retries
-	var resp *http.Response
+	var resp *upstreamResponse
 	var lastErr error
-	
-	maxRetries := 1
-	if s.config.EnableRetry && rule != nil && rule.RetryPolicy != nil {
-		maxRetries = rule.RetryPolicy.MaxAttempts
-	}
+	maxAttempts := retry.attempts()
+	started := time.Now()
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
 		if attempt > 0 {
 			s.metrics.mu.Lock()
 			s.metrics.RetriesTotal++
 			s.metrics.mu.Unlock()
-			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+			select {
+			case <-time.After(time.Duration(attempt) * retryBackoff):
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
 		}
 
-		resp, lastErr = s.forwardRequest(c, targetURL, rule)
-		if lastErr == nil && resp.StatusCode < 500 {
+		// Each attempt picks again, so a retry may reach another instance
+		instance := s.pick(balancer, lbConfig.Strategy, instances, hashKey)
+		targetURL := fmt.Sprintf("%s://%s:%d%s",
+			s.upstreamScheme(targetService, instance),
+			instance.Host,
+			instance.Port,
+			c.Path(),
+		)
+
+		sent := time.Now()
+		tryCtx, cancel := context.WithTimeout(ctx, tryTimeout)
+		resp, lastErr = s.forwardRequest(tryCtx, c, targetURL)
+		cancel()
+
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		failure := lastErr
+		if failure == nil && status >= 500 {
+			failure = fmt.Errorf("upstream returned %d", status)
+		}
+		balancer.Done(instance, time.Since(sent), failure)
+		s.recordBalanced(lbConfig.Strategy, failure != nil)
+		if detector != nil && detector.record(outlierConfig, instance, len(instances), failure != nil) {
+			s.recordEjection(targetService, instance)
+			// Leave it out of the retries too
+			if len(instances) > 1 {
+				instances = detector.filter(instances)
+			}
+		}
+
+		// The request's deadline ends the retries
+		if ctx.Err() != nil || !retry.retriable(status, lastErr) {
 			break
 		}
 	}
 
+	// Count the request against the version it was routed to, for canary
+	// analysis
+	if dest.Version != "" {
+		s.recordVersion(targetService, dest.Version, lastErr != nil || resp.StatusCode >= 500, time.Since(started))
+	}
+
+	// Copy a share of the requests to the service's shadow
+	if policy := s.traffic.GetPolicy(targetService); policy != nil && policy.Mirror.sampled() {
+		status := fiber.StatusBadGateway
+		if resp != nil && lastErr == nil {
+			status = resp.StatusCode
+		} else if errors.Is(ctx.Err(), context.DeadlineExceeded) || isTimeout(lastErr) {
+			status = fiber.StatusGatewayTimeout
+		}
+		s.mirror(targetService, policy.Mirror, copyRequest(c), status, time.Since(started))
+	}
+
 	if lastErr != nil {
 		s.recordFailure()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) || isTimeout(lastErr) {
+			s.metrics.mu.Lock()
+			s.metrics.TimeoutsTotal++
+			s.metrics.mu.Unlock()
+			return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{
+				"error": fmt.Sprintf("upstream request timed out: %v", lastErr),
+			})
+		}
 		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
 			"error": fmt.Sprintf("failed to forward request: %v", lastErr),
 		})
 	}
 
-	defer resp.Body.Close()
-
 	// Record success
 	s.recordSuccess()
 
@@ -266,42 +480,30 @@ func (s *SidecarProxy) proxyHandler(c *fiber.Ctx) error {
 			c.Response().Header.Add(key, value)
 		}
 	}
-
-	// Copy response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
-			"error": "failed to read response",
-		})
+	if limit != nil {
+		limit.setHeaders(c)
 	}
 
 	s.metrics.mu.Lock()
-	s.metrics.BytesReceived += int64(len(body))
+	s.metrics.BytesReceived += int64(len(resp.Body))
 	s.metrics.mu.Unlock()
 
 	c.Status(resp.StatusCode)
-	return c.Send(body)
+	return c.Send(resp.Body)
 }
 
-// forwardRequest forwards HTTP request to target service
-func (s *SidecarProxy) forwardRequest(c *fiber.Ctx, targetURL string, rule *RoutingRule) (*http.Response, error) {
-	// Create HTTP client
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	if rule != nil && rule.Timeout > 0 {
-		client.Timeout = rule.Timeout
-	}
-
-	if s.tlsConfig != nil {
-		client.Transport = &http.Transport{
-			TLSClientConfig: s.tlsConfig,
-		}
-	}
+// upstreamResponse is a response read in full from an upstream instance,
+// within the attempt's timeout
+type upstreamResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
 
-	// Create request
-	req, err := http.NewRequest(c.Method(), targetURL, c.Context().RequestBodyStream())
+// forwardRequest forwards HTTP request to target service
+func (s *SidecarProxy) forwardRequest(ctx context.Context, c *fiber.Ctx, targetURL string) (*upstreamResponse, error) {
+	// Create request; the body is read from memory, so every attempt sends it
+	req, err := http.NewRequestWithContext(ctx, c.Method(), targetURL, bytes.NewReader(c.Body()))
 	if err != nil {
 		return nil, err
 	}
@@ -326,7 +528,17 @@ func (s *SidecarProxy) forwardRequest(c *fiber.Ctx, targetURL string, rule *Rout
 	s.metrics.BytesSent += int64(c.Request().Header.ContentLength())
 	s.metrics.mu.Unlock()
 
-	return client.Do(req)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return &upstreamResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
 }
 
 // AddRoutingRule adds a routing rule
@@ -343,6 +555,185 @@ func (s *SidecarProxy) getRoutingRule(serviceName string) *RoutingRule {
 	return s.routingRules[serviceName]
 }
 
+// Traffic returns the traffic policies the proxy applies
+func (s *SidecarProxy) Traffic() *TrafficManager {
+	return s.traffic
+}
+
+// outlierDetector returns the outlier detection of an upstream service,
+// nil when its policy has none
+func (s *SidecarProxy) outlierDetector(service string) (OutlierDetection, *outlierDetector) {
+	policy := s.traffic.GetPolicy(service)
+	if policy == nil || policy.OutlierDetection == nil {
+		return OutlierDetection{}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	detector, ok := s.outliers[service]
+	if !ok {
+		detector = newOutlierDetector()
+		s.outliers[service] = detector
+	}
+	return policy.OutlierDetection.withDefaults(), detector
+}
+
+// rateLimit takes a request's tokens under the rate limits of its
+// service. The result is nil when the service has none.
+func (s *SidecarProxy) rateLimit(c *fiber.Ctx, service string) (*rateLimitResult, bool) {
+	policy := s.traffic.GetPolicy(service)
+	if policy == nil || len(policy.RateLimits) == 0 {
+		return nil, false
+	}
+	result := s.rateLimiter.take(rateLimitChecks(c, service, policy.RateLimits))
+
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+	if result.allowed {
+		for _, limit := range policy.RateLimits {
+			s.metrics.rateLimit(service, limit.name()).Allowed++
+		}
+		return &result, false
+	}
+	s.metrics.RateLimited++
+	s.metrics.rateLimit(service, result.policy).Throttled++
+	return &result, true
+}
+
+// syncRateLimits shares rate limit consumption with the other sidecars
+// until the proxy stops
+func (s *SidecarProxy) syncRateLimits() {
+	interval := s.config.RateLimitSync
+	if interval <= 0 {
+		interval = defaultRateLimitSync
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := s.rateLimiter.sync(ctx)
+			cancel()
+			if err != nil {
+				s.metrics.mu.Lock()
+				s.metrics.RateLimitErrors++
+				s.metrics.mu.Unlock()
+			}
+		case <-s.shutdown:
+			return
+		}
+	}
+}
+
+// recordEjection records an instance ejected by outlier detection
+func (s *SidecarProxy) recordEjection(service string, instance *ServiceInstance) {
+	s.metrics.mu.Lock()
+	s.metrics.Ejections++
+	s.metrics.mu.Unlock()
+	log.Printf("Ejected %s instance %s after consecutive failures", service, instanceKey(instance))
+}
+
+// balancer returns the load balancing of an upstream service: its own, the
+// "*" one, or round-robin
+func (s *SidecarProxy) balancer(service string) (LoadBalancerConfig, Balancer) {
+	name := service
+	if _, ok := s.config.LoadBalancing[name]; !ok {
+		name = "*"
+	}
+	lbConfig := s.config.LoadBalancing[name]
+	if lbConfig.Strategy == "" {
+		lbConfig.Strategy = StrategyRoundRobin
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	balancer, ok := s.balancers[name]
+	if !ok {
+		balancer, _ = NewBalancer(lbConfig)
+		s.balancers[name] = balancer
+	}
+	return lbConfig, balancer
+}
+
+// balancerKey returns the key a hashing strategy maps a request by
+func balancerKey(c *fiber.Ctx, lbConfig LoadBalancerConfig) string {
+	switch lbConfig.Strategy {
+	case StrategyIPHash:
+		return c.IP()
+	case StrategyConsistentHash:
+		if lbConfig.HashHeader != "" {
+			if key := c.Get(lbConfig.HashHeader); key != "" {
+				return key
+			}
+		}
+		if lbConfig.HashCookie != "" {
+			return c.Cookies(lbConfig.HashCookie)
+		}
+	}
+	return ""
+}
+
+// pick picks an instance, timing the pick
+func (s *SidecarProxy) pick(balancer Balancer, strategy LoadBalancingStrategy, instances []*ServiceInstance, key string) *ServiceInstance {
+	start := time.Now()
+	instance := balancer.Pick(instances, key)
+	elapsed := time.Since(start)
+
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+	m := s.metrics.balancing(strategy)
+	m.Picks++
+	m.PickDuration += elapsed
+	return instance
+}
+
+// recordBalanced records how a request to a picked instance went
+func (s *SidecarProxy) recordBalanced(strategy LoadBalancingStrategy, failed bool) {
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+	m := s.metrics.balancing(strategy)
+	m.Requests++
+	if failed {
+		m.Errors++
+	}
+}
+
+// recordNoInstance records a request no instance could take
+func (s *SidecarProxy) recordNoInstance(strategy LoadBalancingStrategy) {
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+	s.metrics.balancing(strategy).NoInstance++
+}
+
+// RateLimitMetrics counts the requests a rate limit allowed and refused
+type RateLimitMetrics struct {
+	Allowed   int64 `json:"allowed"`
+	Throttled int64 `json:"throttled"`
+}
+
+// rateLimit returns a rate limit's metrics; the caller holds the lock
+func (m *ProxyMetrics) rateLimit(service, policy string) *RateLimitMetrics {
+	key := service + "/" + policy
+	metrics, ok := m.RateLimits[key]
+	if !ok {
+		metrics = &RateLimitMetrics{}
+		m.RateLimits[key] = metrics
+	}
+	return metrics
+}
+
+// balancing returns a strategy's metrics; the caller holds the lock
+func (m *ProxyMetrics) balancing(strategy LoadBalancingStrategy) *BalancerMetrics {
+	metrics, ok := m.Balancing[strategy]
+	if !ok {
+		metrics = &BalancerMetrics{}
+		m.Balancing[strategy] = metrics
+	}
+	return metrics
+}
+
 // recordSuccess records successful request
 func (s *SidecarProxy) recordSuccess() {
 	s.metrics.mu.Lock()
@@ -365,6 +756,40 @@ func (s *SidecarProxy) recordFailure() {
 	}
 }
 
+// recordVersion counts a request to a version of a service
+func (s *SidecarProxy) recordVersion(service, version string, failed bool, latency time.Duration) {
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+
+	key := service + "@" + version
+	stats, ok := s.metrics.Versions[key]
+	if !ok {
+		stats = &VersionStats{}
+		s.metrics.Versions[key] = stats
+	}
+	stats.Requests++
+	if failed {
+		stats.Failures++
+	}
+	stats.Latency += latency
+}
+
+// VersionStats returns the requests sent to a version of a service
+func (s *SidecarProxy) VersionStats(service, version string) VersionStats {
+	s.metrics.mu.RLock()
+	defer s.metrics.mu.RUnlock()
+
+	if stats, ok := s.metrics.Versions[service+"@"+version]; ok {
+		return *stats
+	}
+	return VersionStats{}
+}
+
+// Canary returns the canary controller, nil without CanaryAnalysis
+func (s *SidecarProxy) Canary() *CanaryController {
+	return s.canary
+}
+
 // GetMetrics returns proxy metrics
 func (s *SidecarProxy) GetMetrics() map[string]interface{} {
 	s.metrics.mu.RLock()
@@ -379,7 +804,42 @@ func (s *SidecarProxy) GetMetrics() map[string]interface{} {
 		avgDuration = total / time.Duration(len(s.metrics.RequestDuration))
 	}
 
-	return map[string]interface{}{
+	balancing := make(map[string]interface{}, len(s.metrics.Balancing))
+	for strategy, metrics := range s.metrics.Balancing {
+		balancing[string(strategy)] = metrics.snapshot()
+	}
+
+	rateLimits := make(map[string]RateLimitMetrics, len(s.metrics.RateLimits))
+	for name, metrics := range s.metrics.RateLimits {
+		rateLimits[name] = *metrics
+	}
+
+	routeMatches := make(map[string]int64, len(s.metrics.RouteMatches))
+	for rule, count := range s.metrics.RouteMatches {
+		routeMatches[rule] = count
+	}
+
+	mirrors := make(map[string]interface{}, len(s.metrics.Mirrors))
+	for key, metrics := range s.metrics.Mirrors {
+		mirrors[key] = metrics.snapshot()
+	}
+
+	versions := make(map[string]VersionStats, len(s.metrics.Versions))
+	for key, stats := range s.metrics.Versions {
+		versions[key] = *stats
+	}
+
+	protocols := make(map[string]int64, len(s.metrics.Protocols))
+	for protocol, count := range s.metrics.Protocols {
+		protocols[protocol] = count
+	}
+
+	grpcMethods := make(map[string]GRPCMethodMetrics, len(s.metrics.GRPCMethods))
+	for method, metrics := range s.metrics.GRPCMethods {
+		grpcMethods[method] = metrics.snapshot()
+	}
+
+	metrics := map[string]interface{}{
 		"requests_total":        s.metrics.RequestsTotal,
 		"requests_success":      s.metrics.RequestsSuccess,
 		"requests_failed":       s.metrics.RequestsFailed,
@@ -389,56 +849,99 @@ func (s *SidecarProxy) GetMetrics() map[string]interface{} {
 		"active_connections":    s.metrics.ActiveConnections,
 		"circuit_breaker_open":  s.circuitBreaker != nil && s.circuitBreaker.IsOpen(),
 		"retries_total":         s.metrics.RetriesTotal,
+		"timeouts_total":        s.metrics.TimeoutsTotal,
+		"outlier_ejections":     s.metrics.Ejections,
+		"ejected_instances":     s.ejectedInstances(),
+		"rate_limited_total":    s.metrics.RateLimited,
+		"rate_limit_errors":     s.metrics.RateLimitErrors,
+		"rate_limits":           rateLimits,
+		"mirrors":               mirrors,
+		"route_matches":         routeMatches,
+		"load_balancing":        balancing,
+		"versions":              versions,
+		"protocols":             protocols,
+		"grpc_methods":          grpcMethods,
+	}
+	if s.canary != nil {
+		metrics["canary_decisions"] = s.canary.Decisions()
+	}
+	if s.policies != nil {
+		metrics["policy_revision"] = s.policies.Revision()
+	}
+	return metrics
+}
+
+// ejectedInstances returns the keys of the instances ejected now, by
+// service
+func (s *SidecarProxy) ejectedInstances() map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ejected := make(map[string][]string)
+	for service, detector := range s.outliers {
+		if keys := detector.ejectedKeys(); len(keys) > 0 {
+			ejected[service] = keys
+		}
 	}
+	return ejected
 }
 
 // Start starts the sidecar proxy
 func (s *SidecarProxy) Start() error {
 	log.Printf("Starting sidecar proxy for %s on port %d", s.serviceName, s.proxyPort)
 	
-	// Register service with control plane
-	if err := s.registry.Register(&ServiceInstance{
+	// Register service with control plane, leased until Stop
+	protocol := s.config.ServiceProtocol
+	if protocol == "" {
+		protocol = "http"
+	}
+	lease, err := s.registry.RegisterWithLease(&ServiceInstance{
 		ServiceName: s.serviceName,
 		Host:        "localhost",
 		Port:        s.servicePort,
-		Protocol:    "http",
+		Protocol:    protocol,
 		Metadata:    map[string]string{"version": "1.0"},
-	}); err != nil {
+	})
+	if err != nil {
 		return fmt.Errorf("failed to register service: %w", err)
 	}
+	s.mu.Lock()
+	s.lease = lease
+	s.mu.Unlock()
 
-	// Start heartbeat
-	go s.heartbeat()
+	if s.grpcServer != nil {
+		go s.serveGRPC()
+	}
 
 	return s.app.Listen(fmt.Sprintf(":%d", s.proxyPort))
 }
 
-// heartbeat sends periodic heartbeats to control plane
-func (s *SidecarProxy) heartbeat() {
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			if err := s.registry.Heartbeat(s.serviceName); err != nil {
-				log.Printf("Heartbeat failed: %v", err)
-			}
-		case <-s.shutdown:
-			return
-		}
-	}
-}
-
 // Stop stops the sidecar proxy
 func (s *SidecarProxy) Stop(ctx context.Context) error {
 	close(s.shutdown)
+	if s.canary != nil {
+		s.canary.Stop()
+	}
+	if s.policies != nil {
+		s.policies.Stop()
+	}
 	
 	// Deregister from control plane
-	if err := s.registry.Deregister(s.serviceName); err != nil {
-		log.Printf("Failed to deregister: %v", err)
+	s.mu.RLock()
+	lease := s.lease
+	s.mu.RUnlock()
+	if lease != nil {
+		if err := lease.Release(ctx); err != nil {
+			log.Printf("Failed to deregister: %v", err)
+		}
 	}
+	s.registry.Close()
 
+	if s.grpcServer != nil {
+		if err := s.grpcServer.Shutdown(ctx); err != nil {
+			log.Printf("Failed to stop gRPC proxy: %v", err)
+		}
+	}
 	return s.app.ShutdownWithContext(ctx)
 }
 
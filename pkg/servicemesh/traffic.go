@@ -2,7 +2,11 @@ package servicemesh
 
 import (
 	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 // TrafficManager manages traffic routing and load balancing
@@ -13,11 +17,29 @@ type TrafficManager struct {
 
 // TrafficPolicy defines traffic routing policy
 type TrafficPolicy struct {
-	ServiceName string
-	Strategy    LoadBalancingStrategy
-	Splits      []TrafficSplit
-	Canary      *CanaryConfig
-	ABTest      *ABTestConfig
+	ServiceName string                `yaml:"service"`
+	Strategy    LoadBalancingStrategy `yaml:"strategy,omitempty"`
+	Matches     []MatchRule           `yaml:"matches,omitempty"` // Tried in order before Canary, ABTest and Splits
+	Splits      []TrafficSplit        `yaml:"splits,omitempty"`
+	Canary      *CanaryConfig         `yaml:"canary,omitempty"`
+	ABTest      *ABTestConfig         `yaml:"ab_test,omitempty"`
+	// Applied by the sidecar to requests to the service, unless a route
+	// overrides them
+	Retry            *RetryPolicy      `yaml:"retry,omitempty"`
+	Timeout          time.Duration     `yaml:"timeout,omitempty"` // Deadline of a request, across its retries; none when 0
+	Routes           []RoutePolicy     `yaml:"routes,omitempty"`
+	OutlierDetection *OutlierDetection `yaml:"outlier_detection,omitempty"` // Ejects failing instances; off when nil
+	RateLimits       []RateLimitPolicy `yaml:"rate_limits,omitempty"`       // Each must allow a request; the sidecar answers 429 otherwise
+	Mirror           *MirrorConfig     `yaml:"mirror,omitempty"`            // Copies a share of the requests to a shadow; off when nil
+	MTLS             *MTLSPolicy       `yaml:"mtls,omitempty"`              // How sidecars reach the service; by each instance's protocol when nil
+}
+
+// RoutePolicy overrides the retries and deadline of a service's requests
+// whose path starts with PathPrefix. The longest matching prefix applies.
+type RoutePolicy struct {
+	PathPrefix string        `yaml:"path_prefix"`
+	Retry      *RetryPolicy  `yaml:"retry,omitempty"`   // The service's when nil
+	Timeout    time.Duration `yaml:"timeout,omitempty"` // The service's when 0
 }
 
 // LoadBalancingStrategy load balancing strategies
@@ -33,31 +55,36 @@ const (
 
 // TrafficSplit splits traffic between versions
 type TrafficSplit struct {
-	Version string
-	Weight  int // Percentage 0-100
-	Headers map[string]string
+	Version string            `yaml:"version"`
+	Weight  int               `yaml:"weight"` // Percentage 0-100
+	Headers map[string]string `yaml:"headers,omitempty"`
 }
 
 // CanaryConfig configuration for canary deployment
 type CanaryConfig struct {
-	Enabled        bool
-	NewVersion     string
-	StableVersion  string
-	InitialWeight  int // Starting percentage for new version
-	IncrementStep  int // Percentage to increment per step
-	IncrementDelay int // Seconds between increments
-	MaxWeight      int // Maximum percentage for new version
-	SuccessRate    float64 // Required success rate to continue
+	Enabled        bool    `yaml:"enabled"`
+	NewVersion     string  `yaml:"new_version"`
+	StableVersion  string  `yaml:"stable_version"`
+	InitialWeight  int     `yaml:"initial_weight,omitempty"`  // Starting percentage for new version
+	IncrementStep  int     `yaml:"increment_step,omitempty"`  // Percentage to increment per step
+	IncrementDelay int     `yaml:"increment_delay,omitempty"` // Seconds between increments
+	MaxWeight      int     `yaml:"max_weight,omitempty"`      // Maximum percentage for new version; 100 when 0
+	SuccessRate    float64 `yaml:"success_rate,omitempty"`    // Required success rate to continue
+	// Judged by a CanaryController: the mean latency of the new version
+	// over a step, unchecked when 0, and the requests a step needs before
+	// it is judged (default 20)
+	MaxLatency  time.Duration `yaml:"max_latency,omitempty"`
+	MinRequests int           `yaml:"min_requests,omitempty"`
 }
 
 // ABTestConfig configuration for A/B testing
 type ABTestConfig struct {
-	Enabled  bool
-	VersionA string
-	VersionB string
-	SplitKey string // Header or cookie to use for splitting
-	WeightA  int    // Percentage for version A
-	WeightB  int    // Percentage for version B
+	Enabled  bool   `yaml:"enabled"`
+	VersionA string `yaml:"version_a"`
+	VersionB string `yaml:"version_b"`
+	SplitKey string `yaml:"split_key"` // Header or cookie to use for splitting
+	WeightA  int    `yaml:"weight_a"`  // Percentage for version A
+	WeightB  int    `yaml:"weight_b"`  // Percentage for version B
 }
 
 // NewTrafficManager creates a new traffic manager
@@ -69,14 +96,36 @@ func NewTrafficManager() *TrafficManager {
 
 // SetPolicy sets traffic policy for a service
 func (tm *TrafficManager) SetPolicy(policy *TrafficPolicy) error {
-	if policy == nil || policy.ServiceName == "" {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.policies[policy.ServiceName] = policy
+	return nil
+}
+
+// RemovePolicy removes the traffic policy of a service, reporting whether
+// it had one
+func (tm *TrafficManager) RemovePolicy(serviceName string) bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	_, ok := tm.policies[serviceName]
+	delete(tm.policies, serviceName)
+	return ok
+}
+
+// validate checks a policy, naming and compiling its match rules
+func (p *TrafficPolicy) validate() error {
+	if p == nil || p.ServiceName == "" {
 		return fmt.Errorf("invalid policy")
 	}
 
 	// Validate weights
-	if len(policy.Splits) > 0 {
+	if len(p.Splits) > 0 {
 		totalWeight := 0
-		for _, split := range policy.Splits {
+		for _, split := range p.Splits {
 			totalWeight += split.Weight
 		}
 		if totalWeight != 100 {
@@ -84,9 +133,53 @@ func (tm *TrafficManager) SetPolicy(policy *TrafficPolicy) error {
 		}
 	}
 
-	tm.mu.Lock()
-	defer tm.mu.Unlock()
-	tm.policies[policy.ServiceName] = policy
+	// Validate retries, deadlines and outlier detection
+	if err := p.Retry.validate(); err != nil {
+		return err
+	}
+	if p.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative, got %s", p.Timeout)
+	}
+	for _, route := range p.Routes {
+		if route.PathPrefix == "" {
+			return fmt.Errorf("route policy needs a path prefix")
+		}
+		if err := route.Retry.validate(); err != nil {
+			return fmt.Errorf("route %s: %w", route.PathPrefix, err)
+		}
+		if route.Timeout < 0 {
+			return fmt.Errorf("route %s: timeout must not be negative, got %s", route.PathPrefix, route.Timeout)
+		}
+	}
+	if err := p.OutlierDetection.validate(); err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(p.RateLimits))
+	for _, limit := range p.RateLimits {
+		if err := limit.validate(); err != nil {
+			return err
+		}
+		if names[limit.name()] {
+			return fmt.Errorf("duplicate rate limit: %s", limit.name())
+		}
+		names[limit.name()] = true
+	}
+	if err := p.Mirror.validate(); err != nil {
+		return err
+	}
+	if err := p.MTLS.validate(); err != nil {
+		return err
+	}
+	for i := range p.Matches {
+		rule := &p.Matches[i]
+		if rule.Name == "" {
+			rule.Name = strconv.Itoa(i)
+		}
+		if err := rule.compile(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -97,6 +190,46 @@ func (tm *TrafficManager) GetPolicy(serviceName string) *TrafficPolicy {
 	return tm.policies[serviceName]
 }
 
+// canary returns a copy of a service's canary configuration, and whether
+// it is enabled
+func (tm *TrafficManager) canary(serviceName string) (CanaryConfig, bool) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	policy := tm.policies[serviceName]
+	if policy == nil || policy.Canary == nil {
+		return CanaryConfig{}, false
+	}
+	return *policy.Canary, policy.Canary.Enabled
+}
+
+// RequestPolicy returns the retries and deadline of a request to a service:
+// those of the longest route prefix matching path, else the service's.
+// Both are zero without a policy.
+func (tm *TrafficManager) RequestPolicy(serviceName, path string) (*RetryPolicy, time.Duration) {
+	policy := tm.GetPolicy(serviceName)
+	if policy == nil {
+		return nil, 0
+	}
+
+	retry, timeout := policy.Retry, policy.Timeout
+	longest := -1
+	for _, route := range policy.Routes {
+		if !strings.HasPrefix(path, route.PathPrefix) || len(route.PathPrefix) <= longest {
+			continue
+		}
+		longest = len(route.PathPrefix)
+		retry, timeout = policy.Retry, policy.Timeout
+		if route.Retry != nil {
+			retry = route.Retry
+		}
+		if route.Timeout > 0 {
+			timeout = route.Timeout
+		}
+	}
+	return retry, timeout
+}
+
 // SelectVersion selects version based on policy
 func (tm *TrafficManager) SelectVersion(serviceName string, headers map[string]string, clientIP string) string {
 	policy := tm.GetPolicy(serviceName)
@@ -197,8 +330,8 @@ func (tm *TrafficManager) IncrementCanary(serviceName string) error {
 
 	canary := policy.Canary
 	newWeight := canary.InitialWeight + canary.IncrementStep
-	if newWeight > canary.MaxWeight {
-		newWeight = canary.MaxWeight
+	if maxWeight := canaryMaxWeight(*canary); newWeight > maxWeight {
+		newWeight = maxWeight
 	}
 
 	canary.InitialWeight = newWeight
@@ -254,7 +387,5 @@ func (tm *TrafficManager) ListPolicies() []string {
 
 // randomInt returns random int between 0 and max (exclusive)
 func (tm *TrafficManager) randomInt(max int) int {
-	// Simple pseudo-random for demonstration
-	// In production, use crypto/rand or math/rand with seed
-	return int(tm.mu.RLock() + tm.mu.RUnlock()) % max
+	return rand.Intn(max)
 }
@@ -0,0 +1,98 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SameSite modes of the session cookie
+const (
+	SameSiteStrict = "Strict"
+	SameSiteLax    = "Lax"
+	SameSiteNone   = "None"
+)
+
+// Config configures a Store and the cookies it sets
+type Config struct {
+	Environment string        // APP_ENV the configuration was loaded for
+	CookieName  string        // Default "neonex_session"
+	TTL         time.Duration // Idle time after which a session ends
+	Path        string
+	Domain      string
+	Secure      bool   // Cookies are only sent over HTTPS
+	SameSite    string // Strict, Lax or None, in any case
+}
+
+// DefaultConfig returns the production configuration
+func DefaultConfig() Config {
+	return Config{
+		CookieName: "neonex_session",
+		TTL:        24 * time.Hour,
+		Path:       "/",
+		Secure:     true,
+		SameSite:   SameSiteLax,
+	}
+}
+
+// ConfigForEnvironment returns the configuration for an APP_ENV value.
+// Development and tests allow cookies over plain HTTP, for localhost.
+func ConfigForEnvironment(env string) Config {
+	config := DefaultConfig()
+	config.Environment = env
+
+	switch strings.ToLower(env) {
+	case "development", "dev", "local", "test", "testing":
+		config.Secure = false
+	}
+
+	return config
+}
+
+// LoadConfig loads the configuration for APP_ENV, then applies SESSION_*
+// overrides from environment
+func LoadConfig() Config {
+	config := ConfigForEnvironment(os.Getenv("APP_ENV"))
+
+	if name := os.Getenv("SESSION_COOKIE"); name != "" {
+		config.CookieName = name
+	}
+	if d, err := time.ParseDuration(os.Getenv("SESSION_TTL")); err == nil && d > 0 {
+		config.TTL = d
+	}
+	if domain := os.Getenv("SESSION_COOKIE_DOMAIN"); domain != "" {
+		config.Domain = domain
+	}
+	if secure, err := strconv.ParseBool(os.Getenv("SESSION_COOKIE_SECURE")); err == nil {
+		config.Secure = secure
+	}
+	if sameSite := os.Getenv("SESSION_COOKIE_SAMESITE"); sameSite != "" {
+		config.SameSite = sameSite
+	}
+
+	return config
+}
+
+// Validate refuses cookie settings browsers ignore or that leak sessions:
+// SameSite=None without Secure anywhere, and cookies over plain HTTP
+// outside development and tests
+func (c Config) Validate() error {
+	if c.CookieName == "" {
+		return fmt.Errorf("session: cookie name is empty")
+	}
+	switch {
+	case strings.EqualFold(c.SameSite, SameSiteStrict), strings.EqualFold(c.SameSite, SameSiteLax):
+	case strings.EqualFold(c.SameSite, SameSiteNone):
+		if !c.Secure {
+			return fmt.Errorf("session: SameSite=None cookies must be Secure")
+		}
+	default:
+		return fmt.Errorf("session: unknown SameSite %q, expected %s, %s or %s", c.SameSite, SameSiteStrict, SameSiteLax, SameSiteNone)
+	}
+	if !c.Secure && ConfigForEnvironment(c.Environment).Secure {
+		return fmt.Errorf("session: cookies must be Secure in the %q environment", c.Environment)
+	}
+	return nil
+}
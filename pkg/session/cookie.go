@@ -0,0 +1,205 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Cookie errors
+var (
+	ErrInvalidCookie = errors.New("session: invalid cookie")
+	ErrExpiredCookie = errors.New("session: cookie has expired")
+	ErrUnknownKey    = errors.New("session: cookie encrypted with an unknown key")
+)
+
+// Cookie layout: an 8-byte issue time, a 16-byte IV, the ciphertext and a
+// 32-byte MAC
+const (
+	cookieTimeSize = 8
+	cookieIVSize   = aes.BlockSize
+	cookieMACSize  = sha256.Size
+	minSecretSize  = 32
+)
+
+// CookieKey encrypts and authenticates cookies. Its secret is at least 32
+// bytes; the encryption and MAC keys are derived from it.
+type CookieKey struct {
+	ID     string
+	Secret []byte
+}
+
+// cookieKey is a CookieKey with its derived keys
+type cookieKey struct {
+	id     string
+	block  cipher.Block
+	macKey []byte
+}
+
+// Codec encrypts cookie values with AES-256-CTR and authenticates them with
+// HMAC-SHA256, encrypt-then-MAC. The MAC covers the cookie's name, so a
+// value cannot be moved to another cookie, and its issue time, so stale
+// values are refused. The first key encrypts and every key decrypts: to
+// rotate, put the new key first, and remove the old one once the cookies
+// it encrypted have expired.
+type Codec struct {
+	keys []cookieKey
+}
+
+// NewCodec creates a codec encrypting with the first key
+func NewCodec(keys ...CookieKey) (*Codec, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("session: codec needs a key")
+	}
+	codec := &Codec{}
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key.ID == "" || strings.ContainsAny(key.ID, ".,: ") {
+			return nil, fmt.Errorf("session: invalid key ID %q", key.ID)
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("session: duplicate key ID %q", key.ID)
+		}
+		if len(key.Secret) < minSecretSize {
+			return nil, fmt.Errorf("session: key %s needs a secret of at least %d bytes", key.ID, minSecretSize)
+		}
+		seen[key.ID] = true
+
+		block, err := aes.NewCipher(deriveKey(key.Secret, "encrypt"))
+		if err != nil {
+			return nil, err
+		}
+		codec.keys = append(codec.keys, cookieKey{
+			id:     key.ID,
+			block:  block,
+			macKey: deriveKey(key.Secret, "mac"),
+		})
+	}
+	return codec, nil
+}
+
+// NewRandomCodec creates a codec with a random key. Its cookies stop
+// working on restart and on other instances.
+func NewRandomCodec() *Codec {
+	secret := make([]byte, minSecretSize)
+	rand.Read(secret)
+	codec, _ := NewCodec(CookieKey{ID: "random", Secret: secret})
+	return codec
+}
+
+// LoadKeys loads cookie keys from SESSION_KEYS, a comma-separated list of
+// id:base64-secret with the encrypting key first. The list is empty when
+// the variable is unset.
+func LoadKeys() ([]CookieKey, error) {
+	value := os.Getenv("SESSION_KEYS")
+	if value == "" {
+		return nil, nil
+	}
+
+	var keys []CookieKey
+	for _, entry := range strings.Split(value, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("session: invalid key %q, want id:base64-secret", entry)
+		}
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("session: key %s is not base64: %w", id, err)
+		}
+		keys = append(keys, CookieKey{ID: id, Secret: secret})
+	}
+	return keys, nil
+}
+
+// Encode encrypts the value of a cookie
+func (c *Codec) Encode(name string, value []byte) (string, error) {
+	key := c.keys[0]
+
+	payload := make([]byte, cookieTimeSize+cookieIVSize+len(value), cookieTimeSize+cookieIVSize+len(value)+cookieMACSize)
+	binary.BigEndian.PutUint64(payload, uint64(time.Now().Unix()))
+	iv := payload[cookieTimeSize : cookieTimeSize+cookieIVSize]
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	cipher.NewCTR(key.block, iv).XORKeyStream(payload[cookieTimeSize+cookieIVSize:], value)
+
+	payload = append(payload, key.mac(name, payload)...)
+	return key.id + "." + base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+// Decode authenticates and decrypts the value of a cookie. Values issued
+// more than maxAge ago are refused, unless maxAge is zero.
+func (c *Codec) Decode(name, encoded string, maxAge time.Duration) ([]byte, error) {
+	id, data, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return nil, ErrInvalidCookie
+	}
+	key, ok := c.key(id)
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil || len(payload) < cookieTimeSize+cookieIVSize+cookieMACSize {
+		return nil, ErrInvalidCookie
+	}
+
+	mac := payload[len(payload)-cookieMACSize:]
+	payload = payload[:len(payload)-cookieMACSize]
+	if !hmac.Equal(mac, key.mac(name, payload)) {
+		return nil, ErrInvalidCookie
+	}
+
+	issued := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+	if maxAge > 0 && time.Since(issued) > maxAge {
+		return nil, ErrExpiredCookie
+	}
+
+	iv := payload[cookieTimeSize : cookieTimeSize+cookieIVSize]
+	value := make([]byte, len(payload)-cookieTimeSize-cookieIVSize)
+	cipher.NewCTR(key.block, iv).XORKeyStream(value, payload[cookieTimeSize+cookieIVSize:])
+	return value, nil
+}
+
+// Stale reports whether an encoded value was encrypted with a key other
+// than the current one, and should be encoded again
+func (c *Codec) Stale(encoded string) bool {
+	id, _, _ := strings.Cut(encoded, ".")
+	return id != c.keys[0].id
+}
+
+// key returns the key with an ID
+func (c *Codec) key(id string) (cookieKey, bool) {
+	for _, key := range c.keys {
+		if key.id == id {
+			return key, true
+		}
+	}
+	return cookieKey{}, false
+}
+
+// mac authenticates a cookie's payload under its name and the key's ID
+func (k cookieKey) mac(name string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, k.macKey)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(k.id))
+	mac.Write([]byte{0})
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// deriveKey derives a 32-byte key for one purpose from a secret
+func deriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("neonex-session-" + purpose))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,111 @@
+package session
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Session is a visitor's server-side state, identified by an encrypted
+// cookie. Changes are saved when the request ends. Changing who the
+// session belongs to or their roles gives it a new ID, so an ID planted
+// or seen before the change is worthless after it.
+type Session struct {
+	id         string
+	record     record
+	privileges string // Of the session as loaded
+	isNew      bool
+	changed    bool
+	regenerate bool
+	destroyed  bool
+	stale      bool
+}
+
+// record is a session as stored in the cache
+type record struct {
+	UserID     uint              `json:"user_id,omitempty"`
+	Roles      []string          `json:"roles,omitempty"`
+	Values     map[string]string `json:"values,omitempty"`
+	Generation int64             `json:"gen,omitempty"` // Of the user's privileges, see Store.RotateUser
+	CreatedAt  time.Time         `json:"created_at"`
+	SavedAt    time.Time         `json:"saved_at"`
+}
+
+// ID returns the session's ID; it changes when the session is regenerated
+func (s *Session) ID() string {
+	return s.id
+}
+
+// IsNew reports whether the session started with this request
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+// UserID returns the user signed in to the session, 0 for none
+func (s *Session) UserID() uint {
+	return s.record.UserID
+}
+
+// Roles returns the roles of the signed in user, as set by SetUser
+func (s *Session) Roles() []string {
+	return append([]string(nil), s.record.Roles...)
+}
+
+// Stale reports whether the user's privileges changed elsewhere since the
+// session last saw them, e.g. a role was granted by an admin. The session
+// has been given a new ID; the roles it holds should be loaded again with
+// SetUser.
+func (s *Session) Stale() bool {
+	return s.stale
+}
+
+// Get returns a value of the session
+func (s *Session) Get(key string) string {
+	return s.record.Values[key]
+}
+
+// Set sets a value of the session
+func (s *Session) Set(key, value string) {
+	if s.record.Values == nil {
+		s.record.Values = make(map[string]string)
+	}
+	s.record.Values[key] = value
+	s.changed = true
+}
+
+// Delete removes a value of the session
+func (s *Session) Delete(key string) {
+	if _, ok := s.record.Values[key]; ok {
+		delete(s.record.Values, key)
+		s.changed = true
+	}
+}
+
+// SetUser signs a user in to the session with their roles, or signs them
+// out with 0. The session gets a new ID when this changes its privileges.
+func (s *Session) SetUser(userID uint, roles ...string) {
+	roles = slices.Clone(roles)
+	slices.Sort(roles)
+	s.record.UserID = userID
+	s.record.Roles = slices.Compact(roles)
+	s.stale = false
+	s.changed = true
+}
+
+// Regenerate gives the session a new ID when the request ends, keeping
+// its values, e.g. after a step-up verification
+func (s *Session) Regenerate() {
+	s.regenerate = true
+	s.changed = true
+}
+
+// Destroy ends the session and clears its cookie
+func (s *Session) Destroy() {
+	s.destroyed = true
+}
+
+// privilegeKey identifies the session's privileges, to notice changes
+func (r record) privilegeKey() string {
+	return strconv.FormatUint(uint64(r.UserID), 10) + ":" + strings.Join(r.Roles, ",")
+}
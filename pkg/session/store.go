@@ -0,0 +1,311 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/events"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Cache keys of sessions and of users' privilege generations
+const (
+	keyPrefix        = "session:"
+	generationPrefix = "session:gen:"
+)
+
+// localsKey holds the request's session
+const localsKey = "session"
+
+// PrivilegeEvents change a user's privileges outside their session; the
+// store rotates the user's sessions on them, see ListenPrivilegeEvents
+var PrivilegeEvents = []string{
+	events.EventUserRoleAssigned,
+	events.EventUserPasswordChanged,
+	events.EventUserPasswordReset,
+}
+
+// Store keeps sessions in the cache, under IDs carried by encrypted
+// cookies. It defends against session fixation: IDs it did not issue or
+// no longer holds are never adopted, and a session gets a new ID whenever
+// its privileges change, in the session or elsewhere. Instances must share
+// the cache (e.g. Redis) and the cookie keys.
+type Store struct {
+	cache  cache.Cache
+	codec  *Codec
+	config Config
+}
+
+// NewStore creates a session store. Without a codec cookies are encrypted
+// with a random key; without a cache sessions are kept in memory.
+func NewStore(config Config, codec *Codec, c cache.Cache) (*Store, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultConfig().TTL
+	}
+	if config.Path == "" {
+		config.Path = "/"
+	}
+	if codec == nil {
+		codec = NewRandomCodec()
+	}
+	if c == nil {
+		c = cache.NewMemoryCache(cache.DefaultMemoryCacheConfig())
+	}
+	return &Store{cache: c, codec: codec, config: config}, nil
+}
+
+// Config returns the store's configuration
+func (st *Store) Config() Config {
+	return st.config
+}
+
+// Middleware loads the request's session, for Get, and saves it once the
+// handlers are done
+func (st *Store) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		s := st.Load(c)
+		c.Locals(localsKey, s)
+
+		if err := c.Next(); err != nil {
+			// A handler may have signed the user out before failing
+			st.Save(c, s)
+			return err
+		}
+		return st.Save(c, s)
+	}
+}
+
+// Get returns the request's session, loaded by the middleware
+func Get(c *fiber.Ctx) (*Session, bool) {
+	s, ok := c.Locals(localsKey).(*Session)
+	return s, ok
+}
+
+// Load returns the session of a request's cookie, or a new one when the
+// cookie is missing, invalid or names a session the store does not hold
+func (st *Store) Load(c *fiber.Ctx) *Session {
+	ctx := c.UserContext()
+	if encoded := c.Cookies(st.config.CookieName); encoded != "" {
+		if id, err := st.codec.Decode(st.config.CookieName, encoded, st.config.TTL); err == nil {
+			if rec, ok := st.get(ctx, string(id)); ok {
+				s := &Session{id: string(id), record: rec, privileges: rec.privilegeKey()}
+				// Encrypted with a retired key: set the cookie again
+				s.changed = st.codec.Stale(encoded)
+				if rec.UserID != 0 {
+					if generation := st.generation(ctx, rec.UserID); generation != rec.Generation {
+						s.record.Generation = generation
+						s.stale = true
+						s.Regenerate()
+					}
+				}
+				return s
+			}
+		}
+	}
+
+	rec := record{CreatedAt: time.Now()}
+	return &Session{
+		id:         newSessionID(),
+		record:     rec,
+		privileges: rec.privilegeKey(),
+		isNew:      true,
+	}
+}
+
+// Save stores a session and sets its cookie, giving it a new ID first if
+// its privileges changed. New sessions nothing was set in are not stored.
+// Unchanged sessions are stored again once half their TTL has passed, so
+// active visitors stay signed in.
+func (st *Store) Save(c *fiber.Ctx, s *Session) error {
+	ctx := c.UserContext()
+	if s.destroyed {
+		st.clearCookie(c, st.config.CookieName)
+		if s.isNew {
+			return nil
+		}
+		return st.cache.Delete(ctx, keyPrefix+s.id)
+	}
+
+	if privileges := s.record.privilegeKey(); privileges != s.privileges {
+		s.regenerate = true
+		s.changed = true
+		if s.record.UserID != 0 {
+			s.record.Generation = st.generation(ctx, s.record.UserID)
+		}
+	}
+	if s.isNew && !s.changed {
+		return nil
+	}
+	if !s.changed && time.Since(s.record.SavedAt) < st.config.TTL/2 {
+		return nil
+	}
+
+	if s.regenerate && !s.isNew {
+		if err := st.cache.Delete(ctx, keyPrefix+s.id); err != nil {
+			return err
+		}
+		s.id = newSessionID()
+	}
+	s.record.SavedAt = time.Now()
+	if err := st.set(ctx, s.id, s.record); err != nil {
+		return err
+	}
+	if err := st.SetCookie(c, st.config.CookieName, []byte(s.id), st.config.TTL); err != nil {
+		return err
+	}
+
+	s.privileges = s.record.privilegeKey()
+	s.changed, s.regenerate = false, false
+	return nil
+}
+
+// get returns a stored session
+func (st *Store) get(ctx context.Context, id string) (record, bool) {
+	var rec record
+	value, err := st.cache.Get(ctx, keyPrefix+id)
+	if err != nil {
+		return rec, false
+	}
+	data, ok := value.(string)
+	return rec, ok && json.Unmarshal([]byte(data), &rec) == nil
+}
+
+// set stores a session. It is stored as JSON, so sessions loaded from an
+// in-memory cache share no maps with each other.
+func (st *Store) set(ctx context.Context, id string, rec record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return st.cache.Set(ctx, keyPrefix+id, string(data), st.config.TTL)
+}
+
+// RotateUser gives every session of a user a new ID on its next request,
+// and marks it Stale, e.g. after their roles changed
+func (st *Store) RotateUser(ctx context.Context, userID uint) error {
+	_, err := st.cache.Increment(ctx, generationPrefix+strconv.FormatUint(uint64(userID), 10), 1)
+	return err
+}
+
+// generation returns the generation of a user's privileges, bumped by
+// RotateUser; 0 until then
+func (st *Store) generation(ctx context.Context, userID uint) int64 {
+	value, err := st.cache.Get(ctx, generationPrefix+strconv.FormatUint(uint64(userID), 10))
+	if err != nil {
+		return 0
+	}
+	// Counters come back as numbers or strings, depending on the cache
+	switch n := value.(type) {
+	case int64:
+		return n
+	case int:
+		return int64(n)
+	case float64:
+		return int64(n)
+	case string:
+		generation, _ := strconv.ParseInt(n, 10, 64)
+		return generation
+	}
+	return 0
+}
+
+// ListenPrivilegeEvents rotates the sessions of the user of each
+// PrivilegeEvents event, dispatched by dispatcher or the default
+// dispatcher when nil
+func (st *Store) ListenPrivilegeEvents(dispatcher *events.EventDispatcher) {
+	if dispatcher == nil {
+		dispatcher = events.Default()
+	}
+	for _, name := range PrivilegeEvents {
+		dispatcher.Register(name, st.handlePrivilegeEvent)
+	}
+}
+
+// handlePrivilegeEvent rotates the sessions of an event's user_id
+func (st *Store) handlePrivilegeEvent(ctx context.Context, event events.Event) error {
+	data, ok := event.Data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	var userID uint
+	switch id := data["user_id"].(type) {
+	case uint:
+		userID = id
+	case int:
+		userID = uint(id)
+	case int64:
+		userID = uint(id)
+	case uint64:
+		userID = uint(id)
+	case float64:
+		userID = uint(id)
+	}
+	if userID == 0 {
+		return nil
+	}
+	return st.RotateUser(ctx, userID)
+}
+
+// SetCookie sets an encrypted cookie with the store's cookie attributes,
+// for state kept on the client, such as preferences
+func (st *Store) SetCookie(c *fiber.Ctx, name string, value []byte, maxAge time.Duration) error {
+	encoded, err := st.codec.Encode(name, value)
+	if err != nil {
+		return err
+	}
+	c.Cookie(st.cookie(name, encoded, maxAge))
+	return nil
+}
+
+// Cookie returns the value of a cookie set by SetCookie, refusing values
+// older than maxAge unless it is zero
+func (st *Store) Cookie(c *fiber.Ctx, name string, maxAge time.Duration) ([]byte, error) {
+	encoded := c.Cookies(name)
+	if encoded == "" {
+		return nil, ErrInvalidCookie
+	}
+	return st.codec.Decode(name, encoded, maxAge)
+}
+
+// clearCookie expires a cookie in the client
+func (st *Store) clearCookie(c *fiber.Ctx, name string) {
+	cookie := st.cookie(name, "", 0)
+	cookie.Expires = time.Unix(0, 0)
+	cookie.MaxAge = -1
+	c.Cookie(cookie)
+}
+
+// cookie returns a cookie with the store's attributes. Cookies are never
+// readable by scripts.
+func (st *Store) cookie(name, value string, maxAge time.Duration) *fiber.Cookie {
+	cookie := &fiber.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     st.config.Path,
+		Domain:   st.config.Domain,
+		Secure:   st.config.Secure,
+		HTTPOnly: true,
+		SameSite: st.config.SameSite,
+	}
+	if maxAge > 0 {
+		cookie.MaxAge = int(maxAge.Seconds())
+		cookie.Expires = time.Now().Add(maxAge)
+	}
+	return cookie
+}
+
+// newSessionID returns a random session ID
+func newSessionID() string {
+	id := make([]byte, 32)
+	rand.Read(id)
+	return base64.RawURLEncoding.EncodeToString(id)
+}
@@ -0,0 +1,112 @@
+package signing
+
+import (
+	"errors"
+
+	"neonexcore/pkg/api"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TokenQueryParam is the query parameter SignURL puts tokens in
+const TokenQueryParam = "token"
+
+// TokenHeader carries a token when it is not in the URL
+const TokenHeader = "X-Signed-Token"
+
+// grantLocalsKey stores the redeemed grant in fiber locals
+const grantLocalsKey = "signed_grant"
+
+// requestKeyLocalsKey stores the key a request was signed with in fiber locals
+const requestKeyLocalsKey = "request_signing_key"
+
+// Middleware requires a valid token for action, from the token query
+// parameter or the X-Signed-Token header, and stores its grant for
+// GetGrant. Single-use tokens are redeemed before the handler runs, so a
+// failing handler still uses them up; tokens minted by SignURL only work
+// on the path they were signed for.
+func Middleware(signer *Signer, action string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Query(TokenQueryParam)
+		if token == "" {
+			token = c.Get(TokenHeader)
+		}
+		if token == "" {
+			return api.Unauthorized(c, "missing signed token")
+		}
+
+		// Check the path before redeeming, so a token cannot be burned
+		// against the wrong URL
+		grant, err := signer.parse(token, action)
+		if err == nil && grant.Path != "" && grant.Path != c.Path() {
+			err = ErrWrongAction
+		}
+		if err == nil {
+			err = signer.redeem(c.UserContext(), grant)
+		}
+		if err != nil {
+			return respondTokenError(c, err)
+		}
+
+		c.Locals(grantLocalsKey, grant)
+		return c.Next()
+	}
+}
+
+// GetGrant returns the grant of the request's signed token
+func GetGrant(c *fiber.Ctx) (*Grant, bool) {
+	grant, ok := c.Locals(grantLocalsKey).(*Grant)
+	return grant, ok
+}
+
+// RequestMiddleware requires internal requests to be signed with a key of
+// the signer's ring, within its clock skew and not replayed, and stores
+// the key's ID for GetRequestKey
+func RequestMiddleware(signer *RequestSigner) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := func(key string) string { return c.Get(key) }
+		keyID, err := signer.Verify(c.UserContext(), c.Method(), c.OriginalURL(), header, c.Body())
+		if err != nil {
+			return respondRequestError(c, err)
+		}
+
+		c.Locals(requestKeyLocalsKey, keyID)
+		return c.Next()
+	}
+}
+
+// GetRequestKey returns the ID of the key the request was signed with
+func GetRequestKey(c *fiber.Ctx) (string, bool) {
+	keyID, ok := c.Locals(requestKeyLocalsKey).(string)
+	return keyID, ok
+}
+
+func respondRequestError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrUnsignedRequest):
+		return api.Unauthorized(c, "missing request signature")
+	case errors.Is(err, ErrUnknownKey), errors.Is(err, ErrInvalidSignature):
+		return api.Unauthorized(c, "invalid request signature")
+	case errors.Is(err, ErrStaleRequest):
+		return api.Unauthorized(c, "request timestamp is too far from server time")
+	case errors.Is(err, ErrReplayedRequest):
+		return api.Unauthorized(c, "request has already been received")
+	default:
+		return api.InternalError(c, err.Error())
+	}
+}
+
+func respondTokenError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrExpiredToken):
+		return api.Error(c, fiber.StatusGone, "link has expired", nil)
+	case errors.Is(err, ErrUsedToken):
+		return api.Error(c, fiber.StatusGone, "link has already been used", nil)
+	case errors.Is(err, ErrWrongAction):
+		return api.Forbidden(c, "token does not grant this action")
+	case errors.Is(err, ErrInvalidToken):
+		return api.Unauthorized(c, "invalid signed token")
+	default:
+		return api.InternalError(c, err.Error())
+	}
+}
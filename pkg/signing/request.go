@@ -0,0 +1,414 @@
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/httpclient"
+)
+
+// Request signature errors
+var (
+	ErrUnsignedRequest  = errors.New("signing: request is not signed")
+	ErrUnknownKey       = errors.New("signing: request signed with an unknown key")
+	ErrInvalidSignature = errors.New("signing: invalid request signature")
+	ErrStaleRequest     = errors.New("signing: request timestamp outside the allowed skew")
+	ErrReplayedRequest  = errors.New("signing: request has already been received")
+)
+
+// Request signature headers
+const (
+	SignatureKeyHeader       = "X-Signature-Key"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureNonceHeader     = "X-Signature-Nonce"
+	SignatureHeader          = "X-Signature"
+)
+
+// nonceKeyPrefix namespaces the cache keys of received request nonces
+const nonceKeyPrefix = "signing:nonce:"
+
+// RequestAlgorithm is how a key signs requests
+type RequestAlgorithm string
+
+const (
+	AlgorithmHMAC    RequestAlgorithm = "hmac-sha256" // A secret shared by every service
+	AlgorithmEd25519 RequestAlgorithm = "ed25519"     // Callers hold the private key, receivers the public key
+)
+
+// RequestKey signs or verifies internal requests. HMAC keys need Secret;
+// Ed25519 keys need PrivateKey to sign and PublicKey, or PrivateKey, to
+// verify.
+type RequestKey struct {
+	ID         string
+	Algorithm  RequestAlgorithm
+	Secret     []byte
+	PrivateKey ed25519.PrivateKey
+	PublicKey  ed25519.PublicKey
+	ExpiresAt  time.Time // Requests signed with the key are refused after; never when zero
+}
+
+// validate checks the key has what its algorithm needs
+func (k RequestKey) validate() error {
+	if k.ID == "" || strings.ContainsAny(k.ID, ",: ") {
+		return fmt.Errorf("signing: invalid key ID %q", k.ID)
+	}
+	switch k.Algorithm {
+	case AlgorithmHMAC:
+		if len(k.Secret) < 16 {
+			return fmt.Errorf("signing: key %s needs a secret of at least 16 bytes", k.ID)
+		}
+	case AlgorithmEd25519:
+		if len(k.PrivateKey) != ed25519.PrivateKeySize && len(k.PublicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("signing: key %s needs an Ed25519 private or public key", k.ID)
+		}
+	default:
+		return fmt.Errorf("signing: key %s has unknown algorithm %q", k.ID, k.Algorithm)
+	}
+	return nil
+}
+
+func (k RequestKey) canSign() bool {
+	return k.Algorithm == AlgorithmHMAC || len(k.PrivateKey) == ed25519.PrivateKeySize
+}
+
+func (k RequestKey) publicKey() ed25519.PublicKey {
+	if len(k.PublicKey) == ed25519.PublicKeySize {
+		return k.PublicKey
+	}
+	return k.PrivateKey.Public().(ed25519.PublicKey)
+}
+
+// sign returns the signature of a canonical request
+func (k RequestKey) sign(canonical []byte) string {
+	if k.Algorithm == AlgorithmEd25519 {
+		return base64.RawURLEncoding.EncodeToString(ed25519.Sign(k.PrivateKey, canonical))
+	}
+	mac := hmac.New(sha256.New, k.Secret)
+	mac.Write(canonical)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks the signature of a canonical request
+func (k RequestKey) verify(canonical []byte, signature string) bool {
+	if k.Algorithm == AlgorithmEd25519 {
+		raw, err := base64.RawURLEncoding.DecodeString(signature)
+		return err == nil && ed25519.Verify(k.publicKey(), canonical, raw)
+	}
+	return hmac.Equal([]byte(k.sign(canonical)), []byte(signature))
+}
+
+// KeyRing holds the keys of internal requests: one signs, all of them
+// verify. To rotate, add the new key to every service, then Use it, then
+// remove the old one once requests signed with it have drained.
+type KeyRing struct {
+	keys    map[string]RequestKey
+	current string
+	mu      sync.RWMutex
+}
+
+// NewKeyRing creates a key ring signing with the first key that can sign.
+// A ring of Ed25519 public keys only verifies.
+func NewKeyRing(keys ...RequestKey) (*KeyRing, error) {
+	ring := &KeyRing{keys: make(map[string]RequestKey)}
+	for _, key := range keys {
+		if err := ring.Add(key); err != nil {
+			return nil, err
+		}
+		if ring.current == "" && key.canSign() {
+			ring.current = key.ID
+		}
+	}
+	return ring, nil
+}
+
+// Add adds or replaces a key
+func (r *KeyRing) Add(key RequestKey) error {
+	if err := key.validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[key.ID] = key
+	return nil
+}
+
+// Use signs requests with a key from now on
+func (r *KeyRing) Use(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.keys[id]
+	if !ok {
+		return fmt.Errorf("signing: unknown key %s", id)
+	}
+	if !key.canSign() {
+		return fmt.Errorf("signing: key %s has no private key", id)
+	}
+	r.current = id
+	return nil
+}
+
+// Remove stops accepting a key. Requests are no longer signed when it is
+// the current key.
+func (r *KeyRing) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.keys, id)
+	if r.current == id {
+		r.current = ""
+	}
+}
+
+// signingKey returns the key requests are signed with
+func (r *KeyRing) signingKey() (RequestKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[r.current]
+	return key, ok
+}
+
+// key returns a key requests are verified with
+func (r *KeyRing) key(id string) (RequestKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[id]
+	return key, ok
+}
+
+// RequestConfig configures a RequestSigner
+type RequestConfig struct {
+	// MaxSkew is how far a request's timestamp may be from the receiver's
+	// clock; nonces are remembered for twice as long
+	MaxSkew time.Duration
+}
+
+// DefaultRequestConfig returns the default request signing configuration
+func DefaultRequestConfig() RequestConfig {
+	return RequestConfig{MaxSkew: 5 * time.Minute}
+}
+
+// LoadRequestConfig loads request signing configuration from environment
+func LoadRequestConfig() RequestConfig {
+	config := DefaultRequestConfig()
+
+	if skew, err := time.ParseDuration(os.Getenv("REQUEST_SIGNING_MAX_SKEW")); err == nil && skew > 0 {
+		config.MaxSkew = skew
+	}
+
+	return config
+}
+
+// LoadKeyRing loads request signing keys from REQUEST_SIGNING_KEYS, a
+// comma-separated list of id:algorithm:base64-key with the signing key
+// first. Ed25519 keys are 64-byte private or 32-byte public keys. The
+// ring is nil when the variable is unset.
+func LoadKeyRing() (*KeyRing, error) {
+	value := os.Getenv("REQUEST_SIGNING_KEYS")
+	if value == "" {
+		return nil, nil
+	}
+
+	var keys []RequestKey
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("signing: invalid key %q, want id:algorithm:key", entry)
+		}
+		raw, err := base64.StdEncoding.DecodeString(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("signing: key %s is not base64: %w", parts[0], err)
+		}
+
+		key := RequestKey{ID: parts[0], Algorithm: RequestAlgorithm(parts[1])}
+		switch {
+		case key.Algorithm != AlgorithmEd25519:
+			key.Secret = raw
+		case len(raw) == ed25519.PrivateKeySize:
+			key.PrivateKey = raw
+		default:
+			key.PublicKey = raw
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeyRing(keys...)
+}
+
+// RequestSigner signs internal service-to-service requests and verifies
+// them on the receiving side, so internal traffic is authenticated
+// without mesh mTLS. The signature covers the method, the path with its
+// query, a timestamp, a nonce and the body. Nonces are remembered in the
+// cache, so services must share it (e.g. Redis) to refuse a request
+// replayed against another instance.
+type RequestSigner struct {
+	keys    *KeyRing
+	maxSkew time.Duration
+	nonces  cache.Cache
+}
+
+// NewRequestSigner creates a request signer. A nil cache uses an
+// in-memory one.
+func NewRequestSigner(keys *KeyRing, config RequestConfig, nonces cache.Cache) *RequestSigner {
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = DefaultRequestConfig().MaxSkew
+	}
+	if nonces == nil {
+		nonces = cache.NewMemoryCache(cache.DefaultMemoryCacheConfig())
+	}
+	return &RequestSigner{keys: keys, maxSkew: config.MaxSkew, nonces: nonces}
+}
+
+// Keys returns the signer's key ring, for rotating keys
+func (s *RequestSigner) Keys() *KeyRing {
+	return s.keys
+}
+
+// Sign adds signature headers to a request, reading and restoring its body
+func (s *RequestSigner) Sign(req *http.Request) error {
+	key, ok := s.keys.signingKey()
+	if !ok {
+		return errors.New("signing: no key to sign requests with")
+	}
+
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+	nonce, err := newTokenID()
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	canonical := canonicalRequest(req.Method, req.URL.RequestURI(), timestamp, nonce, body)
+	req.Header.Set(SignatureKeyHeader, key.ID)
+	req.Header.Set(SignatureTimestampHeader, timestamp)
+	req.Header.Set(SignatureNonceHeader, nonce)
+	req.Header.Set(SignatureHeader, key.sign(canonical))
+	return nil
+}
+
+// Verify checks a request's signature, timestamp and nonce, given its
+// method, path with query, headers and body. It returns the key the
+// request was signed with.
+func (s *RequestSigner) Verify(ctx context.Context, method, requestURI string, header func(string) string, body []byte) (string, error) {
+	keyID, timestamp := header(SignatureKeyHeader), header(SignatureTimestampHeader)
+	nonce, signature := header(SignatureNonceHeader), header(SignatureHeader)
+	if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+		return "", ErrUnsignedRequest
+	}
+
+	now := time.Now()
+	key, ok := s.keys.key(keyID)
+	if !ok || (!key.ExpiresAt.IsZero() && now.After(key.ExpiresAt)) {
+		return "", ErrUnknownKey
+	}
+	if !key.verify(canonicalRequest(method, requestURI, timestamp, nonce, body), signature) {
+		return "", ErrInvalidSignature
+	}
+
+	// Check the time only once the signature proves it was not tampered with
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	if skew := now.Sub(time.Unix(unix, 0)); skew > s.maxSkew || skew < -s.maxSkew {
+		return "", ErrStaleRequest
+	}
+
+	// Requests outside the skew are refused anyway, so nonces need only
+	// outlive it
+	nonceKey := nonceKeyPrefix + keyID + ":" + nonce
+	count, err := s.nonces.Increment(ctx, nonceKey, 1)
+	if err != nil {
+		return "", err
+	}
+	if count > 1 {
+		return "", ErrReplayedRequest
+	}
+	if err := s.nonces.Expire(ctx, nonceKey, 2*s.maxSkew); err != nil {
+		return "", err
+	}
+	return keyID, nil
+}
+
+// VerifyRequest verifies a net/http request, restoring its body
+func (s *RequestSigner) VerifyRequest(req *http.Request) (string, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return "", err
+	}
+	return s.Verify(req.Context(), req.Method, req.URL.RequestURI(), req.Header.Get, body)
+}
+
+// Transport signs requests before sending them through base, nil using
+// the shared transport of httpclient
+func (s *RequestSigner) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = httpclient.Transport()
+	}
+	return &signingTransport{signer: s, base: base}
+}
+
+// Client creates a client for internal services on the shared transport
+// of httpclient, signing every request
+func (s *RequestSigner) Client(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: s.Transport(nil)}
+}
+
+type signingTransport struct {
+	signer *RequestSigner
+	base   http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	signed := req.Clone(req.Context())
+	if err := t.signer.Sign(signed); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(signed)
+}
+
+// canonicalRequest is what a request's signature covers
+func canonicalRequest(method, requestURI, timestamp, nonce string, body []byte) []byte {
+	digest := sha256.Sum256(body)
+	return []byte(strings.Join([]string{
+		strings.ToUpper(method), requestURI, timestamp, nonce, hex.EncodeToString(digest[:]),
+	}, "\n"))
+}
+
+// readBody reads a request's body and puts back a copy
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
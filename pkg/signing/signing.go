@@ -0,0 +1,248 @@
+package signing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/cache"
+)
+
+// Token errors
+var (
+	ErrInvalidToken = errors.New("signing: invalid token")
+	ErrExpiredToken = errors.New("signing: token has expired")
+	ErrUsedToken    = errors.New("signing: token has already been used")
+	ErrWrongAction  = errors.New("signing: token does not grant this action")
+)
+
+// usedKeyPrefix namespaces the cache keys of redeemed single-use tokens
+const usedKeyPrefix = "signing:used:"
+
+// Grant is the access a token carries: one action, optionally on one
+// resource, for one user, until it expires
+type Grant struct {
+	ID        string            `json:"id"`
+	Action    string            `json:"act"`            // e.g. "download", "confirm_email", "accept_invite"
+	Resource  string            `json:"res,omitempty"`  // e.g. a file key or invite ID
+	UserID    uint              `json:"sub,omitempty"`  // User the grant was issued to, if any
+	Path      string            `json:"path,omitempty"` // Set by SignURL; the token only works on this path
+	Data      map[string]string `json:"data,omitempty"`
+	SingleUse bool              `json:"once,omitempty"`
+	ExpiresAt time.Time         `json:"-"`
+}
+
+// claims is the signed wire form of a Grant
+type claims struct {
+	Grant
+	Expires int64 `json:"exp"`
+}
+
+// Config configures a Signer
+type Config struct {
+	// Secret signs tokens. When empty a random secret is generated, and
+	// tokens then stop working on restart and on other instances.
+	Secret     string
+	DefaultTTL time.Duration
+}
+
+// DefaultConfig returns the default signing configuration
+func DefaultConfig() Config {
+	return Config{DefaultTTL: time.Hour}
+}
+
+// LoadConfig loads signing configuration from environment
+func LoadConfig() Config {
+	config := DefaultConfig()
+
+	config.Secret = os.Getenv("SIGNING_SECRET")
+	if ttl, err := time.ParseDuration(os.Getenv("SIGNING_DEFAULT_TTL")); err == nil && ttl > 0 {
+		config.DefaultTTL = ttl
+	}
+
+	return config
+}
+
+// Signer mints and verifies HMAC-signed, expiring tokens and URLs scoped
+// to an action. Single-use tokens are redeemed through the cache, so
+// instances must share it (e.g. Redis) for the guarantee to hold across
+// them.
+type Signer struct {
+	secret     []byte
+	defaultTTL time.Duration
+	used       cache.Cache
+}
+
+// NewSigner creates a signer. A nil cache uses an in-memory one.
+func NewSigner(config Config, used cache.Cache) *Signer {
+	secret := []byte(config.Secret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+	if config.DefaultTTL <= 0 {
+		config.DefaultTTL = time.Hour
+	}
+	if used == nil {
+		used = cache.NewMemoryCache(cache.DefaultMemoryCacheConfig())
+	}
+	return &Signer{secret: secret, defaultTTL: config.DefaultTTL, used: used}
+}
+
+// Issue mints a token for a grant. A zero ExpiresAt expires after the
+// default TTL; an empty ID is generated.
+func (s *Signer) Issue(grant Grant) (string, time.Time, error) {
+	if grant.Action == "" {
+		return "", time.Time{}, errors.New("signing: grant has no action")
+	}
+	if grant.ID == "" {
+		id, err := newTokenID()
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		grant.ID = id
+	}
+	if grant.ExpiresAt.IsZero() {
+		grant.ExpiresAt = time.Now().Add(s.defaultTTL)
+	}
+
+	payload, err := json.Marshal(claims{Grant: grant, Expires: grant.ExpiresAt.Unix()})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.sign(encoded), grant.ExpiresAt, nil
+}
+
+// SignURL adds a token for a grant to a URL, as the token query parameter.
+// The token is bound to the URL's path.
+func (s *Signer) SignURL(rawURL string, grant Grant) (string, time.Time, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	grant.Path = u.Path
+
+	token, expiresAt, err := s.Issue(grant)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	query := u.Query()
+	query.Set(TokenQueryParam, token)
+	u.RawQuery = query.Encode()
+	return u.String(), expiresAt, nil
+}
+
+// Verify checks a token's signature, expiry and action, and that a
+// single-use token has not been redeemed, without redeeming it
+func (s *Signer) Verify(ctx context.Context, token, action string) (*Grant, error) {
+	grant, err := s.parse(token, action)
+	if err != nil {
+		return nil, err
+	}
+	if grant.SingleUse {
+		used, err := s.used.Exists(ctx, usedKeyPrefix+grant.ID)
+		if err != nil {
+			return nil, err
+		}
+		if used {
+			return nil, ErrUsedToken
+		}
+	}
+	return grant, nil
+}
+
+// Redeem verifies a token and, for single-use tokens, marks it used. Of
+// concurrent redemptions of the same token exactly one succeeds.
+func (s *Signer) Redeem(ctx context.Context, token, action string) (*Grant, error) {
+	grant, err := s.parse(token, action)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redeem(ctx, grant); err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// redeem marks a parsed single-use grant used
+func (s *Signer) redeem(ctx context.Context, grant *Grant) error {
+	if !grant.SingleUse {
+		return nil
+	}
+	return s.claim(ctx, grant)
+}
+
+// Revoke makes a grant's token unusable before it expires
+func (s *Signer) Revoke(ctx context.Context, grant *Grant) error {
+	err := s.claim(ctx, grant)
+	if errors.Is(err, ErrUsedToken) {
+		return nil
+	}
+	return err
+}
+
+// claim atomically marks a grant used, keeping the marker until the token
+// would have expired anyway
+func (s *Signer) claim(ctx context.Context, grant *Grant) error {
+	key := usedKeyPrefix + grant.ID
+	count, err := s.used.Increment(ctx, key, 1)
+	if err != nil {
+		return err
+	}
+	if count > 1 {
+		return ErrUsedToken
+	}
+	return s.used.Expire(ctx, key, time.Until(grant.ExpiresAt)+time.Minute)
+}
+
+// parse checks a token's signature, expiry and action
+func (s *Signer) parse(token, action string) (*Grant, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(s.sign(encoded)), []byte(signature)) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	grant := c.Grant
+	grant.ExpiresAt = time.Unix(c.Expires, 0)
+	if time.Now().After(grant.ExpiresAt) {
+		return nil, ErrExpiredToken
+	}
+	if grant.Action != action {
+		return nil, ErrWrongAction
+	}
+	return &grant, nil
+}
+
+func (s *Signer) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
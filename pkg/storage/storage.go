@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("storage: object not found")
+
+// Object describes a stored object
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+}
+
+// Storage stores binary objects under slash-separated keys
+type Storage interface {
+	// Put writes an object, replacing any existing one
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+
+	// Get opens an object for reading. Callers must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+
+	// Exists reports whether an object exists
+	Exists(ctx context.Context, key string) (bool, error)
+
+	// Delete removes an object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// URL returns the public URL of an object, if the backend serves one
+	URL(key string) string
+}
+
+// Config configures the storage backend
+type Config struct {
+	Driver  string // local
+	Root    string // Root directory for the local driver
+	BaseURL string // Public URL prefix for stored objects
+}
+
+// DefaultConfig returns the default storage configuration
+func DefaultConfig() Config {
+	return Config{
+		Driver:  "local",
+		Root:    "./storage",
+		BaseURL: "/storage",
+	}
+}
+
+// LoadConfig loads storage configuration from environment
+func LoadConfig() Config {
+	config := DefaultConfig()
+
+	if driver := os.Getenv("STORAGE_DRIVER"); driver != "" {
+		config.Driver = driver
+	}
+	if root := os.Getenv("STORAGE_ROOT"); root != "" {
+		config.Root = root
+	}
+	if baseURL := os.Getenv("STORAGE_BASE_URL"); baseURL != "" {
+		config.BaseURL = baseURL
+	}
+
+	return config
+}
+
+// New creates the storage backend selected by config
+func New(config Config) (Storage, error) {
+	switch config.Driver {
+	case "", "local":
+		return NewLocalStorage(config.Root, config.BaseURL), nil
+	default:
+		return nil, errors.New("storage: unsupported driver " + config.Driver)
+	}
+}
@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// TraceparentHeader is the W3C trace context propagation header
+const TraceparentHeader = "traceparent"
+
+// Middleware starts a server span for each request. An incoming
+// traceparent header continues the caller's trace; otherwise a new trace
+// is started. The span is echoed back in the traceparent response header.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		Start(c)
+		return c.Next()
+	}
+}
+
+// Start returns the request's span, starting one if none exists yet. The
+// span is stored in both the Fiber locals and the user context, so
+// SpanFromContext works with either c.Context() or c.UserContext().
+func Start(c *fiber.Ctx) SpanContext {
+	if span, ok := c.Locals(spanKey{}).(SpanContext); ok {
+		return span
+	}
+
+	var span SpanContext
+	if parent, err := ParseTraceparent(c.Get(TraceparentHeader)); err == nil {
+		span = parent.Child()
+	} else {
+		span = NewRoot()
+	}
+
+	c.Locals(spanKey{}, span)
+	c.SetUserContext(ContextWithSpan(c.UserContext(), span))
+	c.Set(TraceparentHeader, span.Traceparent())
+
+	return span
+}
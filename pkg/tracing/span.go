@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidTraceparent is returned for malformed traceparent headers
+var ErrInvalidTraceparent = errors.New("invalid traceparent header")
+
+// SpanContext identifies a span within a distributed trace (W3C Trace Context)
+type SpanContext struct {
+	TraceID      string // 32 lowercase hex characters
+	SpanID       string // 16 lowercase hex characters
+	ParentSpanID string // Empty for root spans
+	Sampled      bool
+}
+
+type spanKey struct{}
+
+// NewTraceID generates a random 16-byte trace ID
+func NewTraceID() string {
+	return randomHex(16)
+}
+
+// NewSpanID generates a random 8-byte span ID
+func NewSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("tracing: failed to read random bytes: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// NewRoot starts a new sampled trace
+func NewRoot() SpanContext {
+	return SpanContext{
+		TraceID: NewTraceID(),
+		SpanID:  NewSpanID(),
+		Sampled: true,
+	}
+}
+
+// Child returns a new span in the same trace with s as its parent
+func (s SpanContext) Child() SpanContext {
+	return SpanContext{
+		TraceID:      s.TraceID,
+		SpanID:       NewSpanID(),
+		ParentSpanID: s.SpanID,
+		Sampled:      s.Sampled,
+	}
+}
+
+// IsValid reports whether the trace and span IDs are well-formed and non-zero
+func (s SpanContext) IsValid() bool {
+	return isHexID(s.TraceID, 32) && isHexID(s.SpanID, 16)
+}
+
+// Traceparent formats the span as a W3C traceparent header value
+func (s SpanContext) Traceparent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return "00-" + s.TraceID + "-" + s.SpanID + "-" + flags
+}
+
+// ParseTraceparent parses a W3C traceparent header value
+func ParseTraceparent(header string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || version == "ff" || !isHex(version) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	// Version 00 has exactly four fields; later versions may append more
+	if version == "00" && len(parts) != 4 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	if len(flags) != 2 || !isHex(flags) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	traceFlags, _ := strconv.ParseUint(flags, 16, 8)
+
+	span := SpanContext{
+		TraceID: traceID,
+		SpanID:  spanID,
+		Sampled: traceFlags&1 == 1,
+	}
+	if !span.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	return span, nil
+}
+
+func isHexID(s string, length int) bool {
+	return len(s) == length && isHex(s) && strings.Trim(s, "0") != ""
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ContextWithSpan returns a copy of ctx carrying span
+func ContextWithSpan(ctx context.Context, span SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by ctx, if any
+func SpanFromContext(ctx context.Context) (SpanContext, bool) {
+	if ctx == nil {
+		return SpanContext{}, false
+	}
+	span, ok := ctx.Value(spanKey{}).(SpanContext)
+	return span, ok
+}
@@ -0,0 +1,274 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schema is a parsed JSON Schema document. Only the subset needed for
+// content validation is supported: type, properties, required, items,
+// enum, minLength, maxLength, minimum, maximum, minItems, maxItems,
+// pattern, format (email, uri, date, date-time), and
+// additionalProperties (bool).
+type Schema map[string]interface{}
+
+// ParseSchema parses and sanity-checks a JSON schema
+func ParseSchema(raw string) (Schema, error) {
+	var schema Schema
+	if err := json.Unmarshal([]byte(raw), &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema JSON: %w", err)
+	}
+	if err := schema.check(""); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// check verifies keywords have the expected shapes
+func (s Schema) check(path string) error {
+	if t, ok := s["type"]; ok {
+		if _, ok := t.(string); !ok {
+			return fmt.Errorf("%s: type must be a string", schemaPath(path))
+		}
+	}
+	if p, ok := s["pattern"].(string); ok {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("%s: invalid pattern: %w", schemaPath(path), err)
+		}
+	}
+	if props, ok := s["properties"].(map[string]interface{}); ok {
+		for name, sub := range props {
+			subSchema, ok := sub.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: property schema must be an object", schemaPath(joinPath(path, name)))
+			}
+			if err := Schema(subSchema).check(joinPath(path, name)); err != nil {
+				return err
+			}
+		}
+	}
+	if items, ok := s["items"].(map[string]interface{}); ok {
+		if err := Schema(items).check(path + "[]"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate validates data against the schema and returns a map of
+// JSON path to error message, or nil when data is valid
+func (s Schema) Validate(data interface{}) map[string]string {
+	errs := make(map[string]string)
+	s.validate("", data, errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ValidateJSON decodes raw JSON and validates it
+func (s Schema) ValidateJSON(raw string) map[string]string {
+	var data interface{}
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return map[string]string{"$": "must be valid JSON"}
+	}
+	return s.Validate(data)
+}
+
+func (s Schema) validate(path string, value interface{}, errs map[string]string) {
+	key := schemaPath(path)
+
+	if enum, ok := s["enum"].([]interface{}); ok && !inEnum(value, enum) {
+		errs[key] = fmt.Sprintf("%s must be one of %s", key, formatEnum(enum))
+		return
+	}
+
+	typ, _ := s["type"].(string)
+	if typ != "" && !matchesType(typ, value) {
+		errs[key] = fmt.Sprintf("%s must be of type %s", key, typ)
+		return
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := len([]rune(v))
+		if min, ok := number(s["minLength"]); ok && float64(length) < min {
+			errs[key] = fmt.Sprintf("%s must be at least %d characters", key, int(min))
+			return
+		}
+		if max, ok := number(s["maxLength"]); ok && float64(length) > max {
+			errs[key] = fmt.Sprintf("%s must not exceed %d characters", key, int(max))
+			return
+		}
+		if p, ok := s["pattern"].(string); ok {
+			if re, err := regexp.Compile(p); err == nil && !re.MatchString(v) {
+				errs[key] = fmt.Sprintf("%s must match pattern %s", key, p)
+				return
+			}
+		}
+		if format, ok := s["format"].(string); ok {
+			if msg := checkFormat(format, v); msg != "" {
+				errs[key] = fmt.Sprintf("%s %s", key, msg)
+			}
+		}
+
+	case float64:
+		if min, ok := number(s["minimum"]); ok && v < min {
+			errs[key] = fmt.Sprintf("%s must be greater than or equal to %v", key, min)
+			return
+		}
+		if max, ok := number(s["maximum"]); ok && v > max {
+			errs[key] = fmt.Sprintf("%s must be less than or equal to %v", key, max)
+		}
+
+	case []interface{}:
+		if min, ok := number(s["minItems"]); ok && float64(len(v)) < min {
+			errs[key] = fmt.Sprintf("%s must contain at least %d items", key, int(min))
+			return
+		}
+		if max, ok := number(s["maxItems"]); ok && float64(len(v)) > max {
+			errs[key] = fmt.Sprintf("%s must not contain more than %d items", key, int(max))
+			return
+		}
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				Schema(items).validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+
+	case map[string]interface{}:
+		props, _ := s["properties"].(map[string]interface{})
+
+		if required, ok := s["required"].([]interface{}); ok {
+			for _, r := range required {
+				name, _ := r.(string)
+				if _, present := v[name]; !present {
+					field := schemaPath(joinPath(path, name))
+					errs[field] = fmt.Sprintf("%s is required", field)
+				}
+			}
+		}
+
+		if additional, ok := s["additionalProperties"].(bool); ok && !additional {
+			for name := range v {
+				if _, known := props[name]; !known {
+					field := schemaPath(joinPath(path, name))
+					errs[field] = fmt.Sprintf("%s is not allowed", field)
+				}
+			}
+		}
+
+		for name, sub := range props {
+			child, present := v[name]
+			if !present {
+				continue
+			}
+			if subSchema, ok := sub.(map[string]interface{}); ok {
+				Schema(subSchema).validate(joinPath(path, name), child, errs)
+			}
+		}
+	}
+}
+
+// matchesType checks a decoded JSON value against a JSON schema type
+func matchesType(typ string, value interface{}) bool {
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "null":
+		return value == nil
+	default:
+		return true
+	}
+}
+
+var (
+	schemaEmailRegex = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	schemaURIRegex   = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://\S+$`)
+)
+
+// checkFormat validates well-known string formats
+func checkFormat(format, value string) string {
+	switch format {
+	case "email":
+		if !schemaEmailRegex.MatchString(value) {
+			return "must be a valid email address"
+		}
+	case "uri", "url":
+		if !schemaURIRegex.MatchString(value) {
+			return "must be a valid URI"
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return "must be a valid date (YYYY-MM-DD)"
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return "must be a valid RFC 3339 date-time"
+		}
+	}
+	return ""
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func inEnum(value interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatEnum(enum []interface{}) string {
+	values := make([]string, len(enum))
+	for i, e := range enum {
+		values[i] = fmt.Sprint(e)
+	}
+	sort.Strings(values)
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func schemaPath(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return path
+}
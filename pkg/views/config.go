@@ -0,0 +1,39 @@
+package views
+
+import (
+	"os"
+	"strconv"
+)
+
+// Config configures the view engine
+type Config struct {
+	Dir       string // App-wide views, layouts and partials; skipped when missing
+	Extension string // Of template files
+	Layout    string // Views are rendered in when Render names none; empty for none
+	Reload    bool   // Parse templates on every render, to edit them without a restart
+}
+
+// DefaultConfig returns the default view configuration
+func DefaultConfig() Config {
+	return Config{
+		Dir:       "views",
+		Extension: ".html",
+	}
+}
+
+// LoadConfig loads view configuration from environment. Templates reload
+// in development unless VIEWS_RELOAD says otherwise.
+func LoadConfig() Config {
+	config := DefaultConfig()
+
+	if dir := os.Getenv("VIEWS_DIR"); dir != "" {
+		config.Dir = dir
+	}
+	config.Layout = os.Getenv("VIEWS_LAYOUT")
+	config.Reload = os.Getenv("APP_ENV") == "development"
+	if reload, err := strconv.ParseBool(os.Getenv("VIEWS_RELOAD")); err == nil {
+		config.Reload = reload
+	}
+
+	return config
+}
@@ -0,0 +1,363 @@
+package views
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	layoutsDir  = "layouts"
+	partialsDir = "partials"
+	contentName = "content" // Template a view's body is parsed as, for layouts to include
+)
+
+// ErrNotFound is returned when rendering a view or layout no namespace has
+var ErrNotFound = errors.New("view not found")
+
+// Engine renders html/template views of namespaces, one per module and an
+// app-wide one, with layouts and partials. A view "status:page" is
+// page.html in the status namespace; a view without a namespace, such as
+// "admin/users", is in the app-wide one.
+//
+// Each namespace is a tree of template files:
+//
+//	layouts/   - Pages views are rendered in, by name, e.g. "layouts/main"
+//	partials/  - Templates views and layouts include, e.g. {{template "partials/nav" .}}
+//	*          - Views
+//
+// A layout includes the view with {{template "content" .}}, and may leave
+// blocks for views to fill with {{define}}. The app-wide layouts and
+// partials are shared by every namespace; a namespace's own replace them.
+type Engine struct {
+	config     Config
+	funcs      template.FuncMap
+	namespaces map[string]*namespace
+	mu         sync.RWMutex
+}
+
+// namespace is the templates of a module, or the app-wide ones
+type namespace struct {
+	fsys  fs.FS
+	funcs template.FuncMap
+	views map[string]*template.Template // Parsed views, nil while reloading
+}
+
+// New creates an engine; the app-wide namespace is read from config.Dir
+// when it exists
+func New(config Config) (*Engine, error) {
+	if config.Extension == "" {
+		config.Extension = DefaultConfig().Extension
+	}
+	e := &Engine{
+		config:     config,
+		funcs:      template.FuncMap{},
+		namespaces: make(map[string]*namespace),
+	}
+	if config.Dir != "" {
+		if info, err := os.Stat(config.Dir); err == nil && info.IsDir() {
+			if err := e.Register("", os.DirFS(config.Dir), nil); err != nil {
+				return e, err
+			}
+		}
+	}
+	return e, nil
+}
+
+// Reloading reports whether templates are parsed on every render
+func (e *Engine) Reloading() bool {
+	return e.config.Reload
+}
+
+// Funcs adds functions every namespace's templates may call
+func (e *Engine) Funcs(funcs template.FuncMap) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for name, fn := range funcs {
+		e.funcs[name] = fn
+	}
+	return e.parseAll()
+}
+
+// Register adds the templates of a namespace, replacing any registered
+// before, with functions only they may call. Unless reloading, every view
+// is parsed now, so a broken template fails at startup.
+func (e *Engine) Register(name string, fsys fs.FS, funcs template.FuncMap) error {
+	if strings.Contains(name, ":") {
+		return fmt.Errorf("views: namespace %q contains ':'", name)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	previous, replaced := e.namespaces[name]
+	e.namespaces[name] = &namespace{fsys: fsys, funcs: funcs}
+	var err error
+	if name == "" {
+		// Every namespace shares the app-wide layouts and partials
+		err = e.parseAll()
+	} else {
+		err = e.parse(name)
+	}
+	if err == nil {
+		return nil
+	}
+
+	// Keep rendering what was registered before
+	if replaced {
+		e.namespaces[name] = previous
+	} else {
+		delete(e.namespaces, name)
+	}
+	if name == "" {
+		e.parseAll()
+	}
+	return err
+}
+
+// RegisterDir adds the templates of a namespace from a directory
+func (e *Engine) RegisterDir(name, dir string, funcs template.FuncMap) error {
+	return e.Register(name, os.DirFS(dir), funcs)
+}
+
+// Source returns the templates a module embeds, or their directory on disk
+// when reloading and it exists, so edits show without a rebuild. dir is
+// relative to the working directory, e.g. "modules/status/templates".
+func (e *Engine) Source(embedded fs.FS, dir string) fs.FS {
+	if e.config.Reload {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return os.DirFS(dir)
+		}
+	}
+	return embedded
+}
+
+// Load parses every namespace's views, implementing fiber.Views
+func (e *Engine) Load() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.parseAll()
+}
+
+// Render writes a view, in the layout named by layouts or else the
+// configured one. An empty layout name renders the view alone, e.g. for an
+// email body. Render implements fiber.Views, so handlers can call
+// c.Render("status:page", data).
+func (e *Engine) Render(w io.Writer, name string, data interface{}, layouts ...string) error {
+	layout := e.config.Layout
+	if len(layouts) > 0 {
+		layout = layouts[0]
+	}
+	nsName, view := splitName(name)
+
+	tmpl, err := e.view(nsName, view)
+	if err != nil {
+		return err
+	}
+
+	entry := contentName
+	if layout != "" {
+		if !strings.HasPrefix(layout, layoutsDir+"/") {
+			layout = layoutsDir + "/" + layout
+		}
+		if tmpl.Lookup(layout) == nil {
+			return fmt.Errorf("%w: layout %s of %s", ErrNotFound, layout, name)
+		}
+		entry = layout
+	}
+	return tmpl.ExecuteTemplate(w, entry, data)
+}
+
+// RenderString returns a rendered view, e.g. to preview an email
+func (e *Engine) RenderString(name string, data interface{}, layouts ...string) (string, error) {
+	var buf bytes.Buffer
+	if err := e.Render(&buf, name, data, layouts...); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Views returns the names of every view, e.g. for an index of previews
+func (e *Engine) Views() ([]string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var names []string
+	for nsName, ns := range e.namespaces {
+		files, err := e.files(ns)
+		if err != nil {
+			return nil, err
+		}
+		for _, view := range files.views {
+			names = append(names, joinName(nsName, view.name))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// view returns a parsed view, parsing it now when reloading
+func (e *Engine) view(nsName, view string) (*template.Template, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ns, ok := e.namespaces[nsName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, joinName(nsName, view))
+	}
+	if !e.config.Reload {
+		if tmpl, ok := ns.views[view]; ok {
+			return tmpl, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, joinName(nsName, view))
+	}
+
+	views, err := e.compile(nsName, view)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl, ok := views[view]; ok {
+		return tmpl, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, joinName(nsName, view))
+}
+
+// parseAll parses the views of every namespace, unless reloading; the
+// caller holds the write lock
+func (e *Engine) parseAll() error {
+	var errs []error
+	for name := range e.namespaces {
+		if err := e.parse(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parse parses the views of a namespace, unless reloading; the caller
+// holds the write lock
+func (e *Engine) parse(name string) error {
+	ns := e.namespaces[name]
+	if e.config.Reload {
+		ns.views = nil
+		return nil
+	}
+	views, err := e.compile(name, "")
+	if err != nil {
+		return err
+	}
+	ns.views = views
+	return nil
+}
+
+// compile parses the views of a namespace, or only the one named by only,
+// each with the shared and the namespace's layouts and partials
+func (e *Engine) compile(nsName, only string) (map[string]*template.Template, error) {
+	ns := e.namespaces[nsName]
+	funcs := template.FuncMap{}
+	for name, fn := range e.funcs {
+		funcs[name] = fn
+	}
+	for name, fn := range ns.funcs {
+		funcs[name] = fn
+	}
+	base := template.New("").Funcs(funcs)
+
+	// The app-wide layouts and partials first, so a namespace's replace them
+	var sources []*namespace
+	if shared, ok := e.namespaces[""]; ok && nsName != "" {
+		sources = append(sources, shared)
+	}
+	sources = append(sources, ns)
+
+	var own tree
+	for _, source := range sources {
+		files, err := e.files(source)
+		if err != nil {
+			return nil, fmt.Errorf("views: %s: %w", nsName, err)
+		}
+		for _, file := range files.shared {
+			if _, err := base.New(file.name).Parse(file.source); err != nil {
+				return nil, fmt.Errorf("views: %s: %w", joinName(nsName, file.name), err)
+			}
+		}
+		own = files
+	}
+
+	views := make(map[string]*template.Template)
+	for _, file := range own.views {
+		if only != "" && file.name != only {
+			continue
+		}
+		tmpl, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tmpl.New(contentName).Parse(file.source); err != nil {
+			return nil, fmt.Errorf("views: %s: %w", joinName(nsName, file.name), err)
+		}
+		views[file.name] = tmpl
+	}
+	return views, nil
+}
+
+// tree is the template files of a namespace
+type tree struct {
+	shared []file // Layouts and partials
+	views  []file
+}
+
+type file struct {
+	name   string // Path without the extension, e.g. "partials/nav"
+	source string
+}
+
+// files reads the template files of a namespace
+func (e *Engine) files(ns *namespace) (tree, error) {
+	var files tree
+	err := fs.WalkDir(ns.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != e.config.Extension {
+			return nil
+		}
+		source, err := fs.ReadFile(ns.fsys, p)
+		if err != nil {
+			return err
+		}
+
+		f := file{name: strings.TrimSuffix(p, e.config.Extension), source: string(source)}
+		if dir, _, _ := strings.Cut(p, "/"); dir == layoutsDir || dir == partialsDir {
+			files.shared = append(files.shared, f)
+		} else {
+			files.views = append(files.views, f)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// splitName splits "status:page" into its namespace and view
+func splitName(name string) (string, string) {
+	if nsName, view, ok := strings.Cut(name, ":"); ok {
+		return nsName, view
+	}
+	return "", name
+}
+
+func joinName(nsName, view string) string {
+	if nsName == "" {
+		return view
+	}
+	return nsName + ":" + view
+}
@@ -0,0 +1,393 @@
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	bigQueryScope    = "https://www.googleapis.com/auth/bigquery"
+	metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// BigQueryConfig configures the BigQuery store, written to with streaming
+// inserts. It authenticates with AccessToken if set, else with the
+// service account key in CredentialsFile, else as the instance's service
+// account on Google Cloud.
+type BigQueryConfig struct {
+	Project         string
+	Dataset         string
+	CredentialsFile string // Service account key, JSON
+	AccessToken     string // e.g. for an emulator
+	Endpoint        string // API root (default https://bigquery.googleapis.com)
+}
+
+// DefaultBigQueryConfig returns the default BigQuery configuration
+func DefaultBigQueryConfig() BigQueryConfig {
+	return BigQueryConfig{
+		Dataset:  "neonex",
+		Endpoint: "https://bigquery.googleapis.com",
+	}
+}
+
+// bigQueryTypes maps column types to BigQuery's
+var bigQueryTypes = map[string]string{
+	TypeString: "STRING",
+	TypeInt:    "INT64",
+	TypeFloat:  "FLOAT64",
+	TypeBool:   "BOOL",
+	TypeTime:   "TIMESTAMP",
+	TypeJSON:   "JSON",
+}
+
+// bigQueryField is a field of a table schema
+type bigQueryField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode,omitempty"`
+}
+
+// bigQueryTable is the part of a table resource the store manages
+type bigQueryTable struct {
+	TableReference   *bigQueryTableReference `json:"tableReference,omitempty"`
+	Schema           bigQuerySchema          `json:"schema"`
+	TimePartitioning *bigQueryPartitioning   `json:"timePartitioning,omitempty"`
+	Clustering       *bigQueryClustering     `json:"clustering,omitempty"`
+}
+
+type bigQueryTableReference struct {
+	ProjectID string `json:"projectId"`
+	DatasetID string `json:"datasetId"`
+	TableID   string `json:"tableId"`
+}
+
+type bigQuerySchema struct {
+	Fields []bigQueryField `json:"fields"`
+}
+
+type bigQueryPartitioning struct {
+	Type         string `json:"type"`
+	Field        string `json:"field"`
+	ExpirationMs string `json:"expirationMs,omitempty"` // Milliseconds, as a string
+}
+
+type bigQueryClustering struct {
+	Fields []string `json:"fields"`
+}
+
+// BigQueryStore writes rows to tables partitioned by day
+type BigQueryStore struct {
+	config    BigQueryConfig
+	retention int
+	client    *http.Client
+	tokens    *tokenSource
+}
+
+// NewBigQueryStore creates a BigQuery store. Partitions older than
+// retention days are deleted by BigQuery, unless it is 0.
+func NewBigQueryStore(config BigQueryConfig, retention int, client *http.Client) (*BigQueryStore, error) {
+	defaults := DefaultBigQueryConfig()
+	if config.Dataset == "" {
+		config.Dataset = defaults.Dataset
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = defaults.Endpoint
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	tokens := &tokenSource{client: client, token: config.AccessToken}
+	if config.AccessToken == "" && config.CredentialsFile != "" {
+		data, err := os.ReadFile(config.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("bigquery: read credentials: %w", err)
+		}
+		if err := json.Unmarshal(data, &tokens.key); err != nil {
+			return nil, fmt.Errorf("bigquery: parse credentials: %w", err)
+		}
+		if tokens.key.PrivateKey == "" || tokens.key.ClientEmail == "" {
+			return nil, fmt.Errorf("bigquery: credentials are not a service account key")
+		}
+		if config.Project == "" {
+			config.Project = tokens.key.ProjectID
+		}
+	}
+	if config.Project == "" {
+		return nil, fmt.Errorf("bigquery: project is required")
+	}
+
+	return &BigQueryStore{config: config, retention: retention, client: client, tokens: tokens}, nil
+}
+
+// EnsureTable creates the table or adds the columns it lacks. Columns are
+// never dropped or retyped.
+func (s *BigQueryStore) EnsureTable(ctx context.Context, table Table) error {
+	fields := make([]bigQueryField, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		fieldType, ok := bigQueryTypes[column.Type]
+		if !ok {
+			return fmt.Errorf("bigquery: column %s: unknown type %q", column.Name, column.Type)
+		}
+		fields = append(fields, bigQueryField{Name: column.Name, Type: fieldType, Mode: "NULLABLE"})
+	}
+
+	var existing bigQueryTable
+	status, err := s.call(ctx, http.MethodGet, s.tablePath(table.Name), nil, &existing)
+	if status == http.StatusNotFound {
+		resource := bigQueryTable{
+			TableReference:   &bigQueryTableReference{ProjectID: s.config.Project, DatasetID: s.config.Dataset, TableID: table.Name},
+			Schema:           bigQuerySchema{Fields: fields},
+			TimePartitioning: &bigQueryPartitioning{Type: "DAY", Field: table.timeColumn()},
+		}
+		if s.retention > 0 {
+			resource.TimePartitioning.ExpirationMs = fmt.Sprint((time.Duration(s.retention) * 24 * time.Hour).Milliseconds())
+		}
+		if len(table.Key) > 0 {
+			resource.Clustering = &bigQueryClustering{Fields: table.Key}
+		}
+
+		status, err = s.call(ctx, http.MethodPost, s.datasetPath()+"/tables", resource, nil)
+		if status != http.StatusConflict {
+			return err
+		}
+		// Created by another instance meanwhile
+		_, err = s.call(ctx, http.MethodGet, s.tablePath(table.Name), nil, &existing)
+	}
+	if err != nil {
+		return err
+	}
+
+	have := make(map[string]bool, len(existing.Schema.Fields))
+	for _, field := range existing.Schema.Fields {
+		have[field.Name] = true
+	}
+	schema := existing.Schema.Fields
+	for _, field := range fields {
+		if !have[field.Name] {
+			schema = append(schema, field)
+		}
+	}
+	if len(schema) == len(existing.Schema.Fields) {
+		return nil
+	}
+
+	// A patch replaces the schema, which may only gain nullable fields
+	patch := bigQueryTable{Schema: bigQuerySchema{Fields: schema}}
+	_, err = s.call(ctx, http.MethodPatch, s.tablePath(table.Name), patch, nil)
+	return err
+}
+
+// Insert streams rows into the table. Each row's insert ID is a hash of
+// its values, so BigQuery drops the copies a retry may write.
+func (s *BigQueryStore) Insert(ctx context.Context, table Table, rows []Row) error {
+	type insertRow struct {
+		InsertID string                 `json:"insertId"`
+		JSON     map[string]interface{} `json:"json"`
+	}
+	request := struct {
+		IgnoreUnknownValues bool        `json:"ignoreUnknownValues"`
+		Rows                []insertRow `json:"rows"`
+	}{IgnoreUnknownValues: true, Rows: make([]insertRow, 0, len(rows))}
+
+	for _, row := range rows {
+		values := make(map[string]interface{}, len(row))
+		for name, value := range row {
+			if t, ok := value.(time.Time); ok {
+				value = t.UTC().Format(time.RFC3339Nano)
+			}
+			values[name] = value
+		}
+		encoded, err := json.Marshal(values)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(encoded)
+		request.Rows = append(request.Rows, insertRow{InsertID: hex.EncodeToString(sum[:16]), JSON: values})
+	}
+
+	var response struct {
+		InsertErrors []struct {
+			Index  int `json:"index"`
+			Errors []struct {
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"insertErrors"`
+	}
+	if _, err := s.call(ctx, http.MethodPost, s.tablePath(table.Name)+"/insertAll", request, &response); err != nil {
+		return err
+	}
+	if len(response.InsertErrors) > 0 {
+		reason := "unknown"
+		if errs := response.InsertErrors[0].Errors; len(errs) > 0 {
+			reason = errs[0].Reason + ": " + errs[0].Message
+		}
+		return &RowsError{Rejected: len(response.InsertErrors), Reason: reason}
+	}
+	return nil
+}
+
+func (s *BigQueryStore) datasetPath() string {
+	return "/bigquery/v2/projects/" + url.PathEscape(s.config.Project) + "/datasets/" + url.PathEscape(s.config.Dataset)
+}
+
+func (s *BigQueryStore) tablePath(name string) string {
+	return s.datasetPath() + "/tables/" + url.PathEscape(name)
+}
+
+// call sends a JSON request to the API and decodes the response into out.
+// It returns the response status, also with the error of a non-2xx one.
+func (s *BigQueryStore) call(ctx context.Context, method, path string, in, out interface{}) (int, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	token, err := s.tokens.get(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("bigquery: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.config.Endpoint+path, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("bigquery: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("bigquery: decode response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// serviceAccountKey is the part of a service account key file used to
+// sign token requests
+type serviceAccountKey struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// tokenSource returns OAuth access tokens, refreshing them before they
+// expire
+type tokenSource struct {
+	client *http.Client
+	key    serviceAccountKey
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time // Zero for a fixed token
+}
+
+func (t *tokenSource) get(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && (t.expires.IsZero() || time.Until(t.expires) > time.Minute) {
+		return t.token, nil
+	}
+
+	var req *http.Request
+	var err error
+	if t.key.PrivateKey != "" {
+		req, err = t.assertionRequest(ctx)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, metadataTokenURL+"?scopes="+url.QueryEscape(bigQueryScope), nil)
+		if req != nil {
+			req.Header.Set("Metadata-Flavor", "Google")
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch access token: %w", &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))})
+	}
+	if err := json.Unmarshal(data, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("fetch access token: invalid response")
+	}
+
+	t.token = token.AccessToken
+	t.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return t.token, nil
+}
+
+// assertionRequest exchanges a JWT signed with the service account's key
+// for an access token
+func (t *tokenSource) assertionRequest(ctx context.Context) (*http.Request, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(t.key.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+	tokenURI := t.key.TokenURI
+	if tokenURI == "" {
+		tokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   t.key.ClientEmail,
+		"scope": bigQueryScope,
+		"aud":   tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return nil, fmt.Errorf("sign assertion: %w", err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
@@ -0,0 +1,189 @@
+package warehouse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ClickHouseConfig configures the ClickHouse store, written to over its
+// HTTP interface
+type ClickHouseConfig struct {
+	URL      string // e.g. http://localhost:8123
+	Database string
+	Username string
+	Password string
+}
+
+// DefaultClickHouseConfig returns the default ClickHouse configuration
+func DefaultClickHouseConfig() ClickHouseConfig {
+	return ClickHouseConfig{
+		URL:      "http://localhost:8123",
+		Database: "default",
+	}
+}
+
+// clickHouseTypes maps column types to ClickHouse's
+var clickHouseTypes = map[string]string{
+	TypeString: "String",
+	TypeInt:    "Int64",
+	TypeFloat:  "Float64",
+	TypeBool:   "Bool",
+	TypeTime:   "DateTime64(3, 'UTC')",
+	TypeJSON:   "String",
+}
+
+// ClickHouseStore writes rows to MergeTree tables partitioned by month
+type ClickHouseStore struct {
+	config    ClickHouseConfig
+	retention int
+	client    *http.Client
+}
+
+// NewClickHouseStore creates a ClickHouse store. Rows older than
+// retention days are deleted by ClickHouse, unless it is 0.
+func NewClickHouseStore(config ClickHouseConfig, retention int, client *http.Client) (*ClickHouseStore, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("clickhouse: URL is required")
+	}
+	if config.Database == "" {
+		config.Database = DefaultClickHouseConfig().Database
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &ClickHouseStore{config: config, retention: retention, client: client}, nil
+}
+
+// EnsureTable creates the table or adds the columns it lacks. Columns are
+// never dropped or retyped.
+func (s *ClickHouseStore) EnsureTable(ctx context.Context, table Table) error {
+	columns := make([]string, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		columnType, ok := clickHouseTypes[column.Type]
+		if !ok {
+			return fmt.Errorf("clickhouse: column %s: unknown type %q", column.Name, column.Type)
+		}
+		columns = append(columns, quoteIdentifier(column.Name)+" "+columnType)
+	}
+
+	timeColumn := quoteIdentifier(table.timeColumn())
+	orderBy := []string{timeColumn}
+	if len(table.Key) > 0 {
+		orderBy = orderBy[:0]
+		for _, key := range table.Key {
+			orderBy = append(orderBy, quoteIdentifier(key))
+		}
+		orderBy = append(orderBy, timeColumn)
+	}
+
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = MergeTree PARTITION BY toYYYYMM(%s) ORDER BY (%s)",
+		s.tableName(table.Name), strings.Join(columns, ", "), timeColumn, strings.Join(orderBy, ", "))
+	if s.retention > 0 {
+		create += fmt.Sprintf(" TTL toDateTime(%s) + INTERVAL %d DAY", timeColumn, s.retention)
+	}
+	if _, err := s.exec(ctx, create, nil); err != nil {
+		return err
+	}
+
+	// Add the columns of tables created by earlier versions
+	body, err := s.exec(ctx, "DESCRIBE TABLE "+s.tableName(table.Name)+" FORMAT JSONEachRow", nil)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		var column struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &column); err == nil {
+			existing[column.Name] = true
+		}
+	}
+
+	for i, column := range table.Columns {
+		if existing[column.Name] {
+			continue
+		}
+		alter := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s", s.tableName(table.Name), columns[i])
+		if _, err := s.exec(ctx, alter, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert writes rows as JSONEachRow; fields the table lacks are skipped
+func (s *ClickHouseStore) Insert(ctx context.Context, table Table, rows []Row) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, row := range rows {
+		values := make(map[string]interface{}, len(row))
+		for name, value := range row {
+			if t, ok := value.(time.Time); ok {
+				value = t.UTC().Format("2006-01-02 15:04:05.000")
+			}
+			values[name] = value
+		}
+		if err := encoder.Encode(values); err != nil {
+			return err
+		}
+	}
+
+	query := "INSERT INTO " + s.tableName(table.Name) + " FORMAT JSONEachRow"
+	_, err := s.exec(ctx, query, &body)
+	return err
+}
+
+// exec runs a statement. Inserts send their data as the body, with the
+// statement in the URL.
+func (s *ClickHouseStore) exec(ctx context.Context, query string, data io.Reader) ([]byte, error) {
+	params := url.Values{}
+	params.Set("database", s.config.Database)
+	body := data
+	if data == nil {
+		body = strings.NewReader(query)
+	} else {
+		params.Set("query", query)
+		params.Set("input_format_skip_unknown_fields", "1")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL+"/?"+params.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	if s.config.Username != "" {
+		req.Header.Set("X-ClickHouse-User", s.config.Username)
+		req.Header.Set("X-ClickHouse-Key", s.config.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: %w", err)
+	}
+	defer resp.Body.Close()
+
+	result, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(result))}
+	}
+	return result, nil
+}
+
+func (s *ClickHouseStore) tableName(name string) string {
+	return quoteIdentifier(s.config.Database) + "." + quoteIdentifier(name)
+}
+
+// quoteIdentifier quotes a table or column name, which the sink has
+// checked holds only letters, digits and underscores
+func quoteIdentifier(name string) string {
+	return "`" + name + "`"
+}
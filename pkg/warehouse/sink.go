@@ -0,0 +1,317 @@
+package warehouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSinkClosed is returned when flushing a closed sink
+var ErrSinkClosed = errors.New("warehouse: sink is closed")
+
+// maxBackoff bounds the wait between retries
+const maxBackoff = time.Minute
+
+// identifierPattern is what table and column names may hold, so they are
+// safe to quote in DDL
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Stats holds sink counters
+type Stats struct {
+	Sent    uint64 `json:"sent"`
+	Dropped uint64 `json:"dropped"` // Buffer full, or unknown table
+	Failed  uint64 `json:"failed"`  // Rejected, or still failing after the retries
+	Retries uint64 `json:"retries"`
+}
+
+// record is a row waiting to be written
+type record struct {
+	table string
+	row   Row
+}
+
+// Sink buffers rows and writes them to a store in batches per table,
+// retrying failed batches with backoff. Recording never blocks: rows are
+// dropped, and counted, while the buffer is full.
+type Sink struct {
+	store  Store
+	config Config
+
+	mu     sync.RWMutex
+	tables map[string]Table // By name, without the prefix
+
+	records chan record
+	flushCh chan chan error
+	done    chan struct{}
+	wg      sync.WaitGroup
+	closed  atomic.Bool
+	once    sync.Once
+
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+	retries atomic.Uint64
+}
+
+// New creates a sink writing to the configured store
+func New(config Config) (*Sink, error) {
+	store, err := NewStore(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewSink(store, config), nil
+}
+
+// NewSink creates a sink writing to store, with the events, HTTP request
+// and metric sample tables registered, and starts it
+func NewSink(store Store, config Config) *Sink {
+	defaults := DefaultConfig()
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaults.BufferSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaults.RetryBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.Host == "" {
+		config.Host, _ = os.Hostname()
+	}
+
+	s := &Sink{
+		store:   store,
+		config:  config,
+		tables:  make(map[string]Table),
+		records: make(chan record, config.BufferSize),
+		flushCh: make(chan chan error),
+		done:    make(chan struct{}),
+	}
+	for _, table := range []Table{EventsTable, RequestsTable, MetricsTable} {
+		s.Register(table)
+	}
+
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+// Register adds a table rows may be recorded to, e.g. a module's own
+// facts. The table is created, or its new columns added, before its
+// first batch is written.
+func (s *Sink) Register(table Table) error {
+	names := append([]string{table.Name, table.timeColumn()}, table.Key...)
+	for _, column := range table.Columns {
+		names = append(names, column.Name)
+	}
+	for _, name := range names {
+		if !identifierPattern.MatchString(name) {
+			return fmt.Errorf("warehouse: invalid name %q in table %s", name, table.Name)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tables[table.Name] = table
+	return nil
+}
+
+// Record buffers a row for a registered table
+func (s *Sink) Record(table string, row Row) {
+	if s.closed.Load() {
+		s.dropped.Add(1)
+		return
+	}
+	select {
+	case s.records <- record{table: table, row: row}:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Config returns the sink's configuration
+func (s *Sink) Config() Config {
+	return s.config
+}
+
+// Stats returns the sink's counters
+func (s *Sink) Stats() Stats {
+	return Stats{
+		Sent:    s.sent.Load(),
+		Dropped: s.dropped.Load(),
+		Failed:  s.failed.Load(),
+		Retries: s.retries.Load(),
+	}
+}
+
+// Flush writes every buffered row and waits until done
+func (s *Sink) Flush(ctx context.Context) error {
+	if s.closed.Load() {
+		return ErrSinkClosed
+	}
+
+	result := make(chan error, 1)
+	select {
+	case s.flushCh <- result:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close writes the buffered rows and stops the sink, waiting until ctx
+// is done at most
+func (s *Sink) Close(ctx context.Context) error {
+	s.once.Do(func() {
+		s.closed.Store(true)
+		close(s.done)
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run batches recorded rows per table and writes them
+func (s *Sink) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	batches := make(map[string][]Row)
+	ensured := make(map[string]bool)
+
+	write := func(name string) error {
+		rows := batches[name]
+		if len(rows) == 0 {
+			return nil
+		}
+		delete(batches, name)
+		return s.deliver(name, rows, ensured)
+	}
+	writeAll := func() error {
+		var lastErr error
+		for name := range batches {
+			if err := write(name); err != nil {
+				lastErr = err
+			}
+		}
+		return lastErr
+	}
+	add := func(r record) {
+		batches[r.table] = append(batches[r.table], r.row)
+		if len(batches[r.table]) >= s.config.BatchSize {
+			write(r.table)
+		}
+	}
+	// drain moves everything buffered into batches and writes them
+	drain := func() error {
+		for {
+			select {
+			case r := <-s.records:
+				add(r)
+			default:
+				return writeAll()
+			}
+		}
+	}
+
+	for {
+		select {
+		case r := <-s.records:
+			add(r)
+		case <-ticker.C:
+			writeAll()
+		case result := <-s.flushCh:
+			result <- drain()
+		case <-s.done:
+			drain()
+			return
+		}
+	}
+}
+
+// deliver writes a batch to its table, making sure the table exists
+// first, retrying with exponential backoff
+func (s *Sink) deliver(name string, rows []Row, ensured map[string]bool) error {
+	s.mu.RLock()
+	table, ok := s.tables[name]
+	s.mu.RUnlock()
+	if !ok {
+		s.dropped.Add(uint64(len(rows)))
+		return fmt.Errorf("warehouse: unknown table %s", name)
+	}
+	table.Name = s.config.TablePrefix + table.Name
+
+	backoff := s.config.RetryBackoff
+	var err error
+	for attempt := 0; attempt <= s.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			s.retries.Add(1)
+			select {
+			case <-time.After(backoff):
+			case <-s.done:
+				// Shutting down: retry without waiting, so the final
+				// flush ends after MaxRetries attempts
+			}
+			backoff = min(backoff*2, maxBackoff)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+		err = nil
+		if !ensured[name] {
+			if err = s.store.EnsureTable(ctx, table); err == nil {
+				ensured[name] = true
+			}
+		}
+		if err == nil {
+			err = s.store.Insert(ctx, table, rows)
+		}
+		cancel()
+
+		if err == nil {
+			s.sent.Add(uint64(len(rows)))
+			return nil
+		}
+		if !retryable(err) {
+			break
+		}
+	}
+
+	// The rows a store did not reject were written
+	var rowsErr *RowsError
+	if errors.As(err, &rowsErr) && rowsErr.Rejected < len(rows) {
+		s.sent.Add(uint64(len(rows) - rowsErr.Rejected))
+		s.failed.Add(uint64(rowsErr.Rejected))
+		fmt.Fprintf(os.Stderr, "warehouse: %s: %v\n", table.Name, err)
+		return err
+	}
+	s.failed.Add(uint64(len(rows)))
+	fmt.Fprintf(os.Stderr, "warehouse: dropped %d rows of %s: %v\n", len(rows), table.Name, err)
+	return err
+}
@@ -0,0 +1,186 @@
+package warehouse
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/events"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Built-in tables, named without the configured prefix
+var (
+	// EventsTable holds dispatched domain events
+	EventsTable = Table{
+		Name: "events",
+		Columns: []Column{
+			{Name: "time", Type: TypeTime},
+			{Name: "name", Type: TypeString},
+			{Name: "id", Type: TypeInt},
+			{Name: "parent", Type: TypeInt},
+			{Name: "data", Type: TypeJSON},
+			{Name: "mode", Type: TypeString},
+			{Name: "host", Type: TypeString},
+		},
+		Key: []string{"name"},
+	}
+
+	// RequestsTable holds HTTP request logs
+	RequestsTable = Table{
+		Name: "http_requests",
+		Columns: []Column{
+			{Name: "time", Type: TypeTime},
+			{Name: "method", Type: TypeString},
+			{Name: "route", Type: TypeString},
+			{Name: "path", Type: TypeString},
+			{Name: "status", Type: TypeInt},
+			{Name: "duration_ms", Type: TypeFloat},
+			{Name: "bytes_in", Type: TypeInt},
+			{Name: "bytes_out", Type: TypeInt},
+			{Name: "ip", Type: TypeString},
+			{Name: "user_agent", Type: TypeString},
+			{Name: "user_id", Type: TypeInt},
+			{Name: "request_id", Type: TypeString},
+			{Name: "host", Type: TypeString},
+		},
+		Key: []string{"route"},
+	}
+
+	// MetricsTable holds samples of the metrics collector
+	MetricsTable = Table{
+		Name: "metric_samples",
+		Columns: []Column{
+			{Name: "time", Type: TypeTime},
+			{Name: "name", Type: TypeString},
+			{Name: "type", Type: TypeString},
+			{Name: "value", Type: TypeFloat},
+			{Name: "labels", Type: TypeJSON},
+			{Name: "host", Type: TypeString},
+		},
+		Key: []string{"name"},
+	}
+)
+
+// ObserveEvents records the events a dispatcher dispatches, except those
+// of replays
+func (s *Sink) ObserveEvents(d *events.EventDispatcher) {
+	d.Observe(func(ctx context.Context, dispatched events.Dispatched) {
+		if events.IsReplay(ctx) || !matchName(s.config.EventNames, dispatched.Event.Name) {
+			return
+		}
+
+		data, err := json.Marshal(dispatched.Event.Data)
+		if err != nil {
+			data, _ = json.Marshal(map[string]string{"error": err.Error()})
+		}
+		s.Record(EventsTable.Name, Row{
+			"time":   dispatched.At.UTC(),
+			"name":   dispatched.Event.Name,
+			"id":     int64(dispatched.ID),
+			"parent": int64(dispatched.Parent),
+			"data":   string(data),
+			"mode":   string(dispatched.Mode),
+			"host":   s.config.Host,
+		})
+	})
+}
+
+// Middleware records each HTTP request once it is handled
+func (s *Sink) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The error handler sets the status after the middleware returns
+			status = fiber.StatusInternalServerError
+			if fiberErr, ok := err.(*fiber.Error); ok {
+				status = fiberErr.Code
+			}
+		}
+		userID, _ := c.Locals("user_id").(uint)
+		requestID, _ := c.Locals("request_id").(string)
+
+		// Fiber reuses the request's buffers once the handler returns
+		s.Record(RequestsTable.Name, Row{
+			"time":        start.UTC(),
+			"method":      strings.Clone(c.Method()),
+			"route":       c.Route().Path,
+			"path":        strings.Clone(c.Path()),
+			"status":      status,
+			"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes_in":    len(c.Request().Body()),
+			"bytes_out":   len(c.Response().Body()),
+			"ip":          strings.Clone(c.IP()),
+			"user_agent":  strings.Clone(c.Get(fiber.HeaderUserAgent)),
+			"user_id":     userID,
+			"request_id":  strings.Clone(requestID),
+			"host":        s.config.Host,
+		})
+		return err
+	}
+}
+
+// MetricSource lists the metrics to sample. metrics.Hooks implements it
+// for a collector.
+type MetricSource interface {
+	EachMetric(fn func(name, kind string, value float64, labels map[string]string))
+}
+
+// SampleMetrics records the source's metrics every interval until the
+// sink is closed
+func (s *Sink) SampleMetrics(source MetricSource, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.sampleMetrics(source)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+func (s *Sink) sampleMetrics(source MetricSource) {
+	now := time.Now().UTC()
+	source.EachMetric(func(name, kind string, value float64, labels map[string]string) {
+		encoded := "{}"
+		if len(labels) > 0 {
+			if data, err := json.Marshal(labels); err == nil {
+				encoded = string(data)
+			}
+		}
+		s.Record(MetricsTable.Name, Row{
+			"time":   now,
+			"name":   name,
+			"type":   kind,
+			"value":  value,
+			"labels": encoded,
+			"host":   s.config.Host,
+		})
+	})
+}
+
+// matchName reports whether an event name is one of names, or starts with
+// one of them ending in "*". Every name matches an empty list.
+func matchName(names []string, name string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	for _, pattern := range names {
+		if pattern == name || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(name, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+// Package warehouse streams domain events, HTTP request logs and metric
+// samples to an analytical store, ClickHouse or BigQuery, in batches, for
+// analysis over longer periods than the in-process metrics keep. Tables
+// are created, and missing columns added, before rows are first written.
+package warehouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Store drivers
+const (
+	DriverClickHouse = "clickhouse"
+	DriverBigQuery   = "bigquery"
+)
+
+// Column types, mapped to each store's own
+const (
+	TypeString = "string"
+	TypeInt    = "int"
+	TypeFloat  = "float"
+	TypeBool   = "bool"
+	TypeTime   = "time"
+	TypeJSON   = "json" // A JSON document, written as a string
+)
+
+// Column is a column of a table
+type Column struct {
+	Name string
+	Type string
+}
+
+// Table is the schema of a table rows are written to. Every table has a
+// time column, which stores partition it by.
+type Table struct {
+	Name       string
+	Columns    []Column
+	TimeColumn string   // Partitions the table; "time" by default
+	Key        []string // Sorts (ClickHouse) or clusters (BigQuery) rows after the time
+}
+
+// Row is a row to write, by column name. Values of columns the table does
+// not have are ignored.
+type Row map[string]interface{}
+
+// Store is an analytical store
+type Store interface {
+	// EnsureTable creates the table, or adds the columns it lacks
+	EnsureTable(ctx context.Context, table Table) error
+	// Insert writes rows to the table
+	Insert(ctx context.Context, table Table, rows []Row) error
+}
+
+// Config configures streaming to an analytical store
+type Config struct {
+	Driver          string        // DriverClickHouse or DriverBigQuery; streaming is off when empty
+	TablePrefix     string        // Prepended to table names (default "neonex_")
+	Host            string        // Recorded with each row (default the hostname)
+	Events          bool          // Stream dispatched events
+	EventNames      []string      // Names of the events streamed, or prefixes ending in "*"; all if empty
+	Requests        bool          // Stream HTTP request logs
+	MetricsInterval time.Duration // Between metric samples; 0 disables sampling
+	RetentionDays   int           // Days rows are kept; forever when 0
+	BatchSize       int           // Rows per insert
+	BufferSize      int           // Rows buffered before new ones are dropped
+	FlushInterval   time.Duration // Longest a row waits in a batch
+	MaxRetries      int           // Retries of a failed insert
+	RetryBackoff    time.Duration // Before the first retry, doubled on each
+	Timeout         time.Duration // Of each request to the store
+	ClickHouse      ClickHouseConfig
+	BigQuery        BigQueryConfig
+}
+
+// DefaultConfig returns the default configuration, with streaming off
+func DefaultConfig() Config {
+	host, _ := os.Hostname()
+	return Config{
+		TablePrefix:     "neonex_",
+		Host:            host,
+		Events:          true,
+		Requests:        true,
+		MetricsInterval: time.Minute,
+		BatchSize:       1000,
+		BufferSize:      50000,
+		FlushInterval:   5 * time.Second,
+		MaxRetries:      5,
+		RetryBackoff:    time.Second,
+		Timeout:         30 * time.Second,
+		ClickHouse:      DefaultClickHouseConfig(),
+		BigQuery:        DefaultBigQueryConfig(),
+	}
+}
+
+// LoadConfig loads the configuration from the environment
+func LoadConfig() Config {
+	config := DefaultConfig()
+
+	config.Driver = strings.ToLower(os.Getenv("WAREHOUSE_DRIVER"))
+	if prefix, ok := os.LookupEnv("WAREHOUSE_TABLE_PREFIX"); ok {
+		config.TablePrefix = prefix
+	}
+	if events, err := strconv.ParseBool(os.Getenv("WAREHOUSE_EVENTS")); err == nil {
+		config.Events = events
+	}
+	if names := os.Getenv("WAREHOUSE_EVENT_NAMES"); names != "" {
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				config.EventNames = append(config.EventNames, name)
+			}
+		}
+	}
+	if requests, err := strconv.ParseBool(os.Getenv("WAREHOUSE_REQUESTS")); err == nil {
+		config.Requests = requests
+	}
+	if interval, err := time.ParseDuration(os.Getenv("WAREHOUSE_METRICS_INTERVAL")); err == nil && interval >= 0 {
+		config.MetricsInterval = interval
+	}
+	if days, err := strconv.Atoi(os.Getenv("WAREHOUSE_RETENTION_DAYS")); err == nil && days >= 0 {
+		config.RetentionDays = days
+	}
+	if size, err := strconv.Atoi(os.Getenv("WAREHOUSE_BATCH_SIZE")); err == nil && size > 0 {
+		config.BatchSize = size
+	}
+	if size, err := strconv.Atoi(os.Getenv("WAREHOUSE_BUFFER_SIZE")); err == nil && size > 0 {
+		config.BufferSize = size
+	}
+	if interval, err := time.ParseDuration(os.Getenv("WAREHOUSE_FLUSH_INTERVAL")); err == nil && interval > 0 {
+		config.FlushInterval = interval
+	}
+
+	if url := os.Getenv("CLICKHOUSE_URL"); url != "" {
+		config.ClickHouse.URL = strings.TrimSuffix(url, "/")
+	}
+	if database := os.Getenv("CLICKHOUSE_DATABASE"); database != "" {
+		config.ClickHouse.Database = database
+	}
+	config.ClickHouse.Username = os.Getenv("CLICKHOUSE_USERNAME")
+	config.ClickHouse.Password = os.Getenv("CLICKHOUSE_PASSWORD")
+
+	config.BigQuery.Project = os.Getenv("BIGQUERY_PROJECT")
+	if dataset := os.Getenv("BIGQUERY_DATASET"); dataset != "" {
+		config.BigQuery.Dataset = dataset
+	}
+	config.BigQuery.CredentialsFile = os.Getenv("BIGQUERY_CREDENTIALS_FILE")
+	config.BigQuery.AccessToken = os.Getenv("BIGQUERY_ACCESS_TOKEN")
+	if endpoint := os.Getenv("BIGQUERY_ENDPOINT"); endpoint != "" {
+		config.BigQuery.Endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+
+	return config
+}
+
+// NewStore creates the store of the configured driver
+func NewStore(config Config) (Store, error) {
+	client := &http.Client{Timeout: config.Timeout}
+	switch config.Driver {
+	case DriverClickHouse:
+		return NewClickHouseStore(config.ClickHouse, config.RetentionDays, client)
+	case DriverBigQuery:
+		return NewBigQueryStore(config.BigQuery, config.RetentionDays, client)
+	default:
+		return nil, fmt.Errorf("unknown warehouse driver: %q", config.Driver)
+	}
+}
+
+// StatusError is returned when a store answers with a non-2xx status
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// retryable reports whether a failed insert may succeed if retried
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var rowsErr *RowsError
+	return !errors.As(err, &rowsErr)
+}
+
+// RowsError is returned when a store rejected some rows of an insert
+type RowsError struct {
+	Rejected int
+	Reason   string
+}
+
+func (e *RowsError) Error() string {
+	return fmt.Sprintf("%d rows rejected: %s", e.Rejected, e.Reason)
+}
+
+// timeColumn returns the column a table is partitioned by
+func (t Table) timeColumn() string {
+	if t.TimeColumn != "" {
+		return t.TimeColumn
+	}
+	return "time"
+}
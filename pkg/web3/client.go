@@ -3,15 +3,21 @@ package web3
 import (
 	"context"
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"math/big"
 	"sync"
 	"time"
 
+	"neonexcore/pkg/httpclient"
+	"neonexcore/pkg/sandbox"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 // Network represents a blockchain network
@@ -31,6 +37,23 @@ const (
 	NetworkBSCTestnet    Network = "bsc-testnet"
 )
 
+// testnets are networks whose coins have no value
+var testnets = map[Network]bool{
+	NetworkGoerli:     true,
+	NetworkSepolia:    true,
+	NetworkMumbai:     true,
+	NetworkBSCTestnet: true,
+}
+
+// IsTestnet reports whether the network is a testnet
+func (n Network) IsTestnet() bool {
+	return testnets[n]
+}
+
+// ErrLiveNetworkInTestMode is returned when a test mode request tries to
+// transact on a network with real value
+var ErrLiveNetworkInTestMode = errors.New("test mode cannot transact on a live network")
+
 // NetworkConfig network configuration
 type NetworkConfig struct {
 	Network    Network
@@ -41,10 +64,22 @@ type NetworkConfig struct {
 	NativeCoin string
 }
 
+// Backend is the chain RPC a Web3Client talks to. *ethclient.Client
+// implements it; SandboxBackend fakes it offline.
+type Backend interface {
+	bind.ContractBackend
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+	Close()
+}
+
 // Web3Client blockchain client
 type Web3Client struct {
 	config      *NetworkConfig
-	client      *ethclient.Client
+	client      Backend
 	wsClient    *ethclient.Client
 	chainID     *big.Int
 	mu          sync.RWMutex
@@ -83,8 +118,11 @@ type Wallet struct {
 
 // Web3Manager manages Web3 connections
 type Web3Manager struct {
-	clients map[Network]*Web3Client
-	mu      sync.RWMutex
+	clients        map[Network]*Web3Client
+	fakes          map[Network]*Web3Client // Offline chains serving test mode
+	sandboxNetwork Network                 // Serves test mode requests for live networks
+	sandboxFakes   bool
+	mu             sync.RWMutex
 }
 
 // DefaultNetworkConfigs default network configurations
@@ -129,23 +167,71 @@ var DefaultNetworkConfigs = map[Network]*NetworkConfig{
 // NewWeb3Manager creates a new Web3 manager
 func NewWeb3Manager() *Web3Manager {
 	return &Web3Manager{
-		clients: make(map[Network]*Web3Client),
+		clients:        make(map[Network]*Web3Client),
+		fakes:          make(map[Network]*Web3Client),
+		sandboxNetwork: NetworkSepolia,
+		sandboxFakes:   true,
 	}
 }
 
+// SetSandboxNetwork sets the testnet test mode requests use in place of
+// live networks
+func (m *Web3Manager) SetSandboxNetwork(network Network) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sandboxNetwork = network
+}
+
+// UseSandboxFakes chooses whether test mode runs on offline SandboxBackend
+// chains (the default) or on the connected testnet clients
+func (m *Web3Manager) UseSandboxFakes(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sandboxFakes = enabled
+}
+
+// SandboxBackend returns the offline chain serving test mode requests for a
+// testnet, e.g. to fund accounts or script transactions
+func (m *Web3Manager) SandboxBackend(network Network) *SandboxBackend {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.fakeClient(network).client.(*SandboxBackend)
+}
+
+// fakeClient returns the offline client for a network, creating it on
+// first use. The caller must hold m.mu.
+func (m *Web3Manager) fakeClient(network Network) *Web3Client {
+	if client, exists := m.fakes[network]; exists {
+		return client
+	}
+
+	config, exists := DefaultNetworkConfigs[network]
+	if !exists {
+		config = &NetworkConfig{Network: network, ChainID: big.NewInt(SandboxChainID)}
+	}
+	client := &Web3Client{
+		config:  config,
+		client:  NewSandboxBackend(config.ChainID),
+		chainID: config.ChainID,
+	}
+	m.fakes[network] = client
+	return client
+}
+
 // Connect connects to a blockchain network
 func (m *Web3Manager) Connect(config *NetworkConfig) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	client, err := ethclient.Dial(config.RPCURL)
+	// HTTP RPC goes through the shared client, so it can be recorded
+	rpcClient, err := rpc.DialOptions(context.Background(), config.RPCURL, rpc.WithHTTPClient(httpclient.New(0)))
 	if err != nil {
 		return fmt.Errorf("failed to connect to network %s: %w", config.Network, err)
 	}
 
 	web3Client := &Web3Client{
 		config:  config,
-		client:  client,
+		client:  ethclient.NewClient(rpcClient),
 		chainID: config.ChainID,
 	}
 
@@ -161,6 +247,19 @@ func (m *Web3Manager) Connect(config *NetworkConfig) error {
 	return nil
 }
 
+// ConnectBackend connects to a network through backend instead of dialing
+// its RPC URL
+func (m *Web3Manager) ConnectBackend(config *NetworkConfig, backend Backend) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clients[config.Network] = &Web3Client{
+		config:  config,
+		client:  backend,
+		chainID: config.ChainID,
+	}
+}
+
 // GetClient gets a client for a network
 func (m *Web3Manager) GetClient(network Network) (*Web3Client, error) {
 	m.mu.RLock()
@@ -174,6 +273,31 @@ func (m *Web3Manager) GetClient(network Network) (*Web3Client, error) {
 	return client, nil
 }
 
+// ClientFor gets the client for a network as seen by ctx. Test mode
+// requests for a live network get the sandbox testnet instead, served by
+// an offline SandboxBackend unless UseSandboxFakes(false) was called.
+func (m *Web3Manager) ClientFor(ctx context.Context, network Network) (*Web3Client, error) {
+	if !sandbox.IsTest(ctx) {
+		return m.GetClient(network)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !network.IsTestnet() {
+		network = m.sandboxNetwork
+	}
+	if m.sandboxFakes {
+		return m.fakeClient(network), nil
+	}
+
+	client, exists := m.clients[network]
+	if !exists {
+		return nil, fmt.Errorf("client not found for network: %s", network)
+	}
+	return client, nil
+}
+
 // Disconnect disconnects from a network
 func (m *Web3Manager) Disconnect(network Network) error {
 	m.mu.Lock()
@@ -273,6 +397,10 @@ func (c *Web3Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
 
 // SendTransaction sends a transaction
 func (c *Web3Client) SendTransaction(ctx context.Context, wallet *Wallet, to common.Address, value *big.Int, data []byte) (*Transaction, error) {
+	if err := c.checkMode(ctx); err != nil {
+		return nil, err
+	}
+
 	nonce, err := c.GetNonce(ctx, wallet.Address)
 	if err != nil {
 		return nil, err
@@ -314,6 +442,14 @@ func (c *Web3Client) SendTransaction(ctx context.Context, wallet *Wallet, to com
 	}, nil
 }
 
+// checkMode keeps test mode requests off live networks
+func (c *Web3Client) checkMode(ctx context.Context) error {
+	if sandbox.IsTest(ctx) && !c.config.Network.IsTestnet() {
+		return ErrLiveNetworkInTestMode
+	}
+	return nil
+}
+
 // GetTransaction gets transaction by hash
 func (c *Web3Client) GetTransaction(ctx context.Context, hash common.Hash) (*Transaction, error) {
 	tx, isPending, err := c.client.TransactionByHash(ctx, hash)
@@ -396,6 +532,23 @@ func (c *Web3Client) GetBlock(ctx context.Context, blockNumber *big.Int) (*types
 	return block, nil
 }
 
+// Config returns the configuration of the client's network
+func (c *Web3Client) Config() *NetworkConfig {
+	return c.config
+}
+
+// ChainID returns the chain ID of the client's network
+func (c *Web3Client) ChainID() *big.Int {
+	return c.chainID
+}
+
+// Sandbox returns the offline chain behind a test mode client, nil for
+// clients of real networks
+func (c *Web3Client) Sandbox() *SandboxBackend {
+	backend, _ := c.client.(*SandboxBackend)
+	return backend
+}
+
 // Close closes the client connection
 func (c *Web3Client) Close() {
 	c.mu.Lock()
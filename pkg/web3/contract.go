@@ -11,6 +11,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 // ContractManager manages smart contract interactions
@@ -133,6 +134,10 @@ func (m *ContractManager) SendMethod(ctx context.Context, wallet *Wallet, contra
 
 // DeployContract deploys a new contract
 func (m *ContractManager) DeployContract(ctx context.Context, wallet *Wallet, abiJSON string, bytecode []byte, args ...interface{}) (*Transaction, common.Address, error) {
+	if err := m.client.checkMode(ctx); err != nil {
+		return nil, common.Address{}, err
+	}
+
 	// Parse ABI
 	parsedABI, err := abi.JSON(strings.NewReader(abiJSON))
 	if err != nil {
@@ -0,0 +1,202 @@
+package web3
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TransferEventTopic is the topic of ERC-20 Transfer(address,address,uint256)
+// logs
+var TransferEventTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+const (
+	defaultConfirmations = 12
+	defaultScanBlocks    = 500
+)
+
+// Transfer is a payment found on chain: the native currency sent by a
+// transaction, or an ERC-20 Transfer log
+type Transfer struct {
+	Token       *common.Address // ERC-20 contract; nil for the native currency
+	From        common.Address
+	To          common.Address
+	Amount      *big.Int
+	TxHash      common.Hash
+	LogIndex    int // -1 for native transfers
+	BlockNumber uint64
+	Timestamp   time.Time // Of the block; zero for ERC-20 transfers
+}
+
+// TransferFilter selects the transfers a scan returns
+type TransferFilter struct {
+	To     []common.Address // Recipients; a scan without any returns nothing
+	Tokens []common.Address // ERC-20 contracts whose transfers to return
+	Native bool             // Also return native transfers, reading every block
+}
+
+// IndexerConfig configures a TransferIndexer
+type IndexerConfig struct {
+	// Blocks mined on top of a transfer's before it is returned, so
+	// reorganizations do not undo it (default 12)
+	Confirmations uint64
+	// Blocks read per scan at most (default 500)
+	MaxBlocks uint64
+}
+
+// TransferIndexer finds confirmed payments to a set of addresses, block
+// range by block range. It keeps no state: callers keep the next block to
+// scan, e.g. in the database.
+//
+// Native transfers are those of transactions sent to an address directly;
+// value moved by contracts (internal transactions) is not seen.
+type TransferIndexer struct {
+	client *Web3Client
+	config IndexerConfig
+}
+
+// NewTransferIndexer creates an indexer of a client's network. Sandbox
+// networks need no confirmations.
+func NewTransferIndexer(client *Web3Client, config IndexerConfig) *TransferIndexer {
+	if config.Confirmations == 0 && client.Sandbox() == nil {
+		config.Confirmations = defaultConfirmations
+	}
+	if config.MaxBlocks == 0 {
+		config.MaxBlocks = defaultScanBlocks
+	}
+	return &TransferIndexer{client: client, config: config}
+}
+
+// Head returns the latest block with enough confirmations
+func (ix *TransferIndexer) Head(ctx context.Context) (uint64, error) {
+	head, err := ix.client.GetBlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if head < ix.config.Confirmations {
+		return 0, nil
+	}
+	return head - ix.config.Confirmations, nil
+}
+
+// Scan returns the transfers matching filter from block from on, up to
+// MaxBlocks blocks and the confirmed head, with the block to scan next.
+// next is from when there is nothing new to scan.
+func (ix *TransferIndexer) Scan(ctx context.Context, from uint64, filter TransferFilter) (transfers []Transfer, next uint64, err error) {
+	head, err := ix.Head(ctx)
+	if err != nil {
+		return nil, from, err
+	}
+	if from > head {
+		return nil, from, nil
+	}
+	to := head
+	if to-from >= ix.config.MaxBlocks {
+		to = from + ix.config.MaxBlocks - 1
+	}
+	if len(filter.To) == 0 {
+		return nil, to + 1, nil
+	}
+
+	if len(filter.Tokens) > 0 {
+		tokens, err := ix.tokenTransfers(ctx, from, to, filter)
+		if err != nil {
+			return nil, from, err
+		}
+		transfers = append(transfers, tokens...)
+	}
+	if filter.Native {
+		recipients := make(map[common.Address]bool, len(filter.To))
+		for _, address := range filter.To {
+			recipients[address] = true
+		}
+		for number := from; number <= to; number++ {
+			native, err := ix.nativeTransfers(ctx, number, recipients)
+			if err != nil {
+				return nil, from, err
+			}
+			transfers = append(transfers, native...)
+		}
+	}
+	return transfers, to + 1, nil
+}
+
+// tokenTransfers returns the ERC-20 transfers to the filter's recipients
+func (ix *TransferIndexer) tokenTransfers(ctx context.Context, from, to uint64, filter TransferFilter) ([]Transfer, error) {
+	recipients := make([]common.Hash, len(filter.To))
+	for i, address := range filter.To {
+		recipients[i] = common.BytesToHash(address.Bytes())
+	}
+	logs, err := ix.client.client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: filter.Tokens,
+		Topics:    [][]common.Hash{{TransferEventTopic}, nil, recipients},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter transfer logs: %w", err)
+	}
+
+	transfers := make([]Transfer, 0, len(logs))
+	for _, log := range logs {
+		// ERC-721 transfers share the topic, with the token ID indexed
+		if log.Removed || len(log.Topics) != 3 || len(log.Data) != 32 {
+			continue
+		}
+		token := log.Address
+		transfers = append(transfers, Transfer{
+			Token:       &token,
+			From:        common.BytesToAddress(log.Topics[1].Bytes()),
+			To:          common.BytesToAddress(log.Topics[2].Bytes()),
+			Amount:      new(big.Int).SetBytes(log.Data),
+			TxHash:      log.TxHash,
+			LogIndex:    int(log.Index),
+			BlockNumber: log.BlockNumber,
+		})
+	}
+	return transfers, nil
+}
+
+// nativeTransfers returns the successful transactions of a block sending
+// value to recipients
+func (ix *TransferIndexer) nativeTransfers(ctx context.Context, number uint64, recipients map[common.Address]bool) ([]Transfer, error) {
+	block, err := ix.client.GetBlock(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return nil, err
+	}
+
+	var transfers []Transfer
+	signer := types.LatestSignerForChainID(ix.client.chainID)
+	for _, tx := range block.Transactions() {
+		if tx.To() == nil || !recipients[*tx.To()] || tx.Value().Sign() <= 0 {
+			continue
+		}
+		receipt, err := ix.client.client.TransactionReceipt(ctx, tx.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get receipt: %w", err)
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			continue
+		}
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sender of %s: %w", tx.Hash().Hex(), err)
+		}
+		transfers = append(transfers, Transfer{
+			From:        from,
+			To:          *tx.To(),
+			Amount:      tx.Value(),
+			TxHash:      tx.Hash(),
+			LogIndex:    -1,
+			BlockNumber: number,
+			Timestamp:   time.Unix(int64(block.Time()), 0),
+		})
+	}
+	return transfers, nil
+}
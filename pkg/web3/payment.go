@@ -0,0 +1,91 @@
+package web3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// PaymentRequest describes a payment a wallet can pay by scanning or
+// opening an EIP-681 URI
+type PaymentRequest struct {
+	To      common.Address
+	ChainID *big.Int
+	Amount  *big.Int        // In wei, or in token base units when Token is set
+	Token   *common.Address // ERC-20 contract; nil for the native currency
+}
+
+// URI encodes the request as an EIP-681 URI, e.g.
+// ethereum:0xabc...@1?value=1000000000000000000
+func (p PaymentRequest) URI() string {
+	chain := ""
+	if p.ChainID != nil && p.ChainID.Sign() > 0 {
+		chain = "@" + p.ChainID.String()
+	}
+
+	if p.Token != nil {
+		query := url.Values{}
+		query.Set("address", p.To.Hex())
+		if p.Amount != nil {
+			query.Set("uint256", p.Amount.String())
+		}
+		return fmt.Sprintf("ethereum:%s%s/transfer?%s", p.Token.Hex(), chain, query.Encode())
+	}
+
+	uri := fmt.Sprintf("ethereum:%s%s", p.To.Hex(), chain)
+	if p.Amount != nil && p.Amount.Sign() > 0 {
+		uri += "?value=" + p.Amount.String()
+	}
+	return uri
+}
+
+// DeriveWallet derives the wallet of a payment address from a secret seed
+// and a per-payment salt, so every invoice gets its own address and the
+// seed alone recovers the keys to sweep them
+func DeriveWallet(seed, salt []byte) (*Wallet, error) {
+	if len(seed) < 32 {
+		return nil, fmt.Errorf("seed must be at least 32 bytes")
+	}
+	mac := hmac.New(sha256.New, seed)
+	mac.Write(salt)
+	privateKey, err := crypto.ToECDSA(mac.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return &Wallet{
+		PrivateKey: privateKey,
+		PublicKey:  &privateKey.PublicKey,
+		Address:    crypto.PubkeyToAddress(privateKey.PublicKey),
+	}, nil
+}
+
+// ParseUnits converts a decimal amount such as "12.5" to base units of a
+// currency with the given decimals, e.g. 12500000 for 6 decimals
+func ParseUnits(amount string, decimals int) (*big.Int, error) {
+	value, ok := new(big.Rat).SetString(amount)
+	if !ok || value.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount: %q", amount)
+	}
+	value.Mul(value, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	if !value.IsInt() {
+		return nil, fmt.Errorf("amount %q has more than %d decimals", amount, decimals)
+	}
+	return value.Num(), nil
+}
+
+// FormatUnits converts base units to a decimal amount, the reverse of
+// ParseUnits
+func FormatUnits(amount *big.Int, decimals int) string {
+	value := new(big.Rat).SetFrac(amount, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+	text := value.FloatString(decimals)
+	if decimals > 0 {
+		text = strings.TrimRight(strings.TrimRight(text, "0"), ".")
+	}
+	return text
+}
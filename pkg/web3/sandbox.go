@@ -0,0 +1,465 @@
+package web3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// SandboxChainID is the chain ID of sandbox networks without a default
+// configuration
+const SandboxChainID = 1337
+
+const (
+	sandboxGenesisTime = 1700000000 // Timestamp of block 0
+	sandboxBlockTime   = 12         // Seconds between blocks
+	sandboxGasLimit    = 30000000
+)
+
+var (
+	// DefaultSandboxBalance is the balance of sandbox accounts not set
+	// with SetBalance: 100 ether
+	DefaultSandboxBalance = new(big.Int).Mul(big.NewInt(100), big.NewInt(1e18))
+
+	// DefaultTxScript is the script of sandbox transactions not scripted
+	// otherwise: pending on the first lookup, confirmed on the next
+	DefaultTxScript = []TransactionStatus{TxStatusPending, TxStatusConfirmed}
+
+	sandboxGasPrice = big.NewInt(1e9) // 1 gwei
+
+	errSandboxInsufficientFunds = errors.New("insufficient funds for gas * price + value")
+)
+
+// SandboxBackend is an offline, deterministic chain implementing Backend.
+// Web3Manager serves test mode requests from it automatically.
+//
+// Every account starts with DefaultSandboxBalance, and deployed contracts
+// with the value sent to them. Each sent transaction plays a script of
+// states, advancing one state per lookup (TransactionByHash or
+// TransactionReceipt) until it leaves pending; it is then mined into a new
+// block and its effects applied. A script ending in
+// pending never confirms. The chain also mines an empty block each time
+// its height is read, so confirmation waits always progress.
+//
+// Signing is deterministic, so the same wallet sending the same
+// transactions always gets the same hashes, blocks and receipts.
+type SandboxBackend struct {
+	chainID  *big.Int
+	signer   types.Signer
+	head     uint64
+	balances map[common.Address]*big.Int
+	nonces   map[common.Address]uint64
+	code     map[common.Address][]byte
+	calls    map[sandboxCall][]byte
+	scripts  map[common.Address][]TransactionStatus
+	next     [][]TransactionStatus
+	txs      map[common.Hash]*sandboxTx
+	blocks   map[uint64][]*types.Transaction // Transactions mined in each block
+	logs     []types.Log
+	mu       sync.Mutex
+}
+
+// sandboxCall keys a scripted contract call by contract and method selector
+type sandboxCall struct {
+	contract common.Address
+	selector string
+}
+
+// sandboxTx is a sent sandbox transaction
+type sandboxTx struct {
+	tx      *types.Transaction
+	from    common.Address
+	script  []TransactionStatus
+	receipt *types.Receipt // Set once mined
+}
+
+// NewSandboxBackend creates an offline chain with the given chain ID
+func NewSandboxBackend(chainID *big.Int) *SandboxBackend {
+	return &SandboxBackend{
+		chainID:  chainID,
+		signer:   types.LatestSignerForChainID(chainID),
+		balances: make(map[common.Address]*big.Int),
+		nonces:   make(map[common.Address]uint64),
+		code:     make(map[common.Address][]byte),
+		calls:    make(map[sandboxCall][]byte),
+		scripts:  make(map[common.Address][]TransactionStatus),
+		txs:      make(map[common.Hash]*sandboxTx),
+		blocks:   make(map[uint64][]*types.Transaction),
+	}
+}
+
+// SetBalance sets an account's balance
+func (b *SandboxBackend) SetBalance(account common.Address, balance *big.Int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.balances[account] = new(big.Int).Set(balance)
+}
+
+// ScriptNext scripts the states of the next sent transaction. Calls queue
+// up, one script per transaction.
+func (b *SandboxBackend) ScriptNext(states ...TransactionStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.next = append(b.next, states)
+}
+
+// ScriptAddress scripts the states of every transaction sent to an
+// address, e.g. to make payments to it fail
+func (b *SandboxBackend) ScriptAddress(to common.Address, states ...TransactionStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.scripts[to] = states
+}
+
+// ScriptCall sets the ABI-encoded output of calls to a contract method,
+// identified by its 4-byte selector. The contract is given code if it has
+// none, so bound contracts accept it.
+func (b *SandboxBackend) ScriptCall(contract common.Address, selector []byte, output []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.calls[sandboxCall{contract: contract, selector: string(selector)}] = output
+	if len(b.code[contract]) == 0 {
+		b.code[contract] = []byte{0x00}
+	}
+}
+
+// BalanceAt returns an account's current balance
+func (b *SandboxBackend) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return new(big.Int).Set(b.balance(account)), nil
+}
+
+// PendingNonceAt returns the number of transactions an account has sent
+func (b *SandboxBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nonces[account], nil
+}
+
+// SuggestGasPrice returns a fixed 1 gwei
+func (b *SandboxBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(sandboxGasPrice), nil
+}
+
+// SuggestGasTipCap returns a fixed 1 gwei
+func (b *SandboxBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(sandboxGasPrice), nil
+}
+
+// EstimateGas returns the gas of a transfer, or of a contract interaction
+// when the call carries data
+func (b *SandboxBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	if len(call.Data) > 0 {
+		return 100000, nil
+	}
+	return 21000, nil
+}
+
+// SendTransaction accepts a signed transaction and starts its script
+func (b *SandboxBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	from, err := types.Sender(b.signer, tx)
+	if err != nil {
+		return fmt.Errorf("invalid sender: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.txs[tx.Hash()]; exists {
+		return errors.New("already known")
+	}
+	if nonce := b.nonces[from]; tx.Nonce() != nonce {
+		return fmt.Errorf("invalid nonce: have %d, want %d", tx.Nonce(), nonce)
+	}
+	if b.balance(from).Cmp(tx.Cost()) < 0 {
+		return errSandboxInsufficientFunds
+	}
+
+	script := DefaultTxScript
+	if len(b.next) > 0 {
+		script, b.next = b.next[0], b.next[1:]
+	} else if tx.To() != nil && len(b.scripts[*tx.To()]) > 0 {
+		script = b.scripts[*tx.To()]
+	}
+	if len(script) == 0 {
+		script = DefaultTxScript
+	}
+
+	b.nonces[from]++
+	b.txs[tx.Hash()] = &sandboxTx{
+		tx:     tx,
+		from:   from,
+		script: append([]TransactionStatus(nil), script...),
+	}
+	return nil
+}
+
+// TransactionByHash looks a transaction up, advancing its script
+func (b *SandboxBackend) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stx, exists := b.txs[hash]
+	if !exists {
+		return nil, false, ethereum.NotFound
+	}
+	b.advance(stx)
+	return stx.tx, stx.receipt == nil, nil
+}
+
+// TransactionReceipt returns a mined transaction's receipt, advancing its
+// script. Pending transactions have none.
+func (b *SandboxBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stx, exists := b.txs[txHash]
+	if !exists {
+		return nil, ethereum.NotFound
+	}
+	b.advance(stx)
+	if stx.receipt == nil {
+		return nil, ethereum.NotFound
+	}
+	return stx.receipt, nil
+}
+
+// BlockNumber mines an empty block and returns the new height
+func (b *SandboxBackend) BlockNumber(ctx context.Context) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.head++
+	return b.head, nil
+}
+
+// HeaderByNumber returns a block header, the latest when number is nil
+func (b *SandboxBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.header(number)
+}
+
+// BlockByNumber returns a block with the transactions mined in it, the
+// latest when number is nil
+func (b *SandboxBackend) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	header, err := b.header(number)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewBlockWithHeader(header).WithBody(b.blocks[header.Number.Uint64()], nil), nil
+}
+
+// CodeAt returns the code of a contract deployed or scripted in the sandbox
+func (b *SandboxBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.code[contract], nil
+}
+
+// PendingCodeAt returns the code of a contract deployed or scripted in the
+// sandbox
+func (b *SandboxBackend) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return b.CodeAt(ctx, account, nil)
+}
+
+// CallContract returns the output scripted with ScriptCall, or nothing
+func (b *SandboxBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if call.To == nil || len(call.Data) < 4 {
+		return nil, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls[sandboxCall{contract: *call.To, selector: string(call.Data[:4])}], nil
+}
+
+// FilterLogs returns the logs of simulated token payments matching query.
+// Sandbox transactions emit none.
+func (b *SandboxBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var logs []types.Log
+	for _, log := range b.logs {
+		if query.FromBlock != nil && log.BlockNumber < query.FromBlock.Uint64() {
+			continue
+		}
+		if query.ToBlock != nil && log.BlockNumber > query.ToBlock.Uint64() {
+			continue
+		}
+		if len(query.Addresses) > 0 && !containsAddress(query.Addresses, log.Address) {
+			continue
+		}
+		if matchTopics(query.Topics, log.Topics) {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+// SubscribeFilterLogs returns a subscription that never delivers a log
+func (b *SandboxBackend) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	}), nil
+}
+
+// Close implements Backend
+func (b *SandboxBackend) Close() {}
+
+// balance returns an account's balance. The caller must hold b.mu.
+func (b *SandboxBackend) balance(account common.Address) *big.Int {
+	if balance, exists := b.balances[account]; exists {
+		return balance
+	}
+	return DefaultSandboxBalance
+}
+
+// header builds a block header. The caller must hold b.mu.
+func (b *SandboxBackend) header(number *big.Int) (*types.Header, error) {
+	height := b.head
+	if number != nil {
+		if !number.IsUint64() || number.Uint64() > b.head {
+			return nil, ethereum.NotFound
+		}
+		height = number.Uint64()
+	}
+	return &types.Header{
+		Number:   new(big.Int).SetUint64(height),
+		GasLimit: sandboxGasLimit,
+		Time:     sandboxGenesisTime + height*sandboxBlockTime,
+	}, nil
+}
+
+// advance moves a pending transaction to its next scripted state, mining
+// it once the state is final. The caller must hold b.mu.
+func (b *SandboxBackend) advance(stx *sandboxTx) {
+	if stx.receipt != nil {
+		return
+	}
+
+	state := stx.script[0]
+	if len(stx.script) > 1 {
+		stx.script = stx.script[1:]
+	}
+	if state == TxStatusPending {
+		return
+	}
+
+	b.head++
+	b.blocks[b.head] = []*types.Transaction{stx.tx}
+	tx := stx.tx
+	receipt := &types.Receipt{
+		Type:              tx.Type(),
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: tx.Gas(),
+		TxHash:            tx.Hash(),
+		GasUsed:           tx.Gas(),
+		EffectiveGasPrice: tx.GasPrice(),
+		BlockNumber:       new(big.Int).SetUint64(b.head),
+	}
+
+	// Gas is spent either way; value and code only move on success
+	fee := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(tx.Gas()))
+	b.balances[stx.from] = new(big.Int).Sub(b.balance(stx.from), fee)
+
+	if state == TxStatusFailed {
+		receipt.Status = types.ReceiptStatusFailed
+	} else {
+		b.balances[stx.from] = new(big.Int).Sub(b.balance(stx.from), tx.Value())
+		if tx.To() == nil {
+			receipt.ContractAddress = crypto.CreateAddress(stx.from, tx.Nonce())
+			b.code[receipt.ContractAddress] = tx.Data()
+			b.balances[receipt.ContractAddress] = new(big.Int).Set(tx.Value())
+		} else {
+			b.balances[*tx.To()] = new(big.Int).Add(b.balance(*tx.To()), tx.Value())
+		}
+	}
+
+	stx.receipt = receipt
+}
+
+// sandboxPayerKey signs the payments SimulatePayment makes
+var sandboxPayerKey, _ = crypto.ToECDSA(crypto.Keccak256([]byte("neonexcore sandbox payer")))
+
+// SimulatePayment mines a block paying amount to an address from a
+// sandbox payer: a transaction sending the native currency, or a Transfer
+// log of the ERC-20 token when token is set. It returns the transaction's
+// hash.
+func (b *SandboxBackend) SimulatePayment(to common.Address, token *common.Address, amount *big.Int) (common.Hash, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	payer := crypto.PubkeyToAddress(sandboxPayerKey.PublicKey)
+	if token != nil {
+		b.head++
+		hash := crypto.Keccak256Hash(token.Bytes(), to.Bytes(), amount.Bytes(), new(big.Int).SetUint64(b.head).Bytes())
+		b.logs = append(b.logs, types.Log{
+			Address:     *token,
+			Topics:      []common.Hash{TransferEventTopic, common.BytesToHash(payer.Bytes()), common.BytesToHash(to.Bytes())},
+			Data:        common.LeftPadBytes(amount.Bytes(), 32),
+			BlockNumber: b.head,
+			TxHash:      hash,
+		})
+		return hash, nil
+	}
+
+	tx, err := types.SignTx(types.NewTransaction(b.nonces[payer], to, amount, 21000, sandboxGasPrice, nil), b.signer, sandboxPayerKey)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	// The payer never runs out
+	b.balances[payer] = new(big.Int).Add(b.balance(payer), tx.Cost())
+	b.nonces[payer]++
+	stx := &sandboxTx{tx: tx, from: payer, script: []TransactionStatus{TxStatusConfirmed}}
+	b.txs[tx.Hash()] = stx
+	b.advance(stx)
+	return tx.Hash(), nil
+}
+
+func containsAddress(addresses []common.Address, address common.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTopics reports whether log topics match a filter's, position by
+// position, where an empty position matches any topic
+func matchTopics(filter [][]common.Hash, topics []common.Hash) bool {
+	if len(filter) > len(topics) {
+		return false
+	}
+	for i, wanted := range filter {
+		if len(wanted) == 0 {
+			continue
+		}
+		found := false
+		for _, topic := range wanted {
+			if topic == topics[i] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
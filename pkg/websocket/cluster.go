@@ -0,0 +1,229 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"neonexcore/pkg/logger"
+)
+
+const (
+	clusterQueueSize      = 1024
+	clusterPublishTimeout = 5 * time.Second
+	clusterRetry          = time.Second
+	clusterMaxRetry       = 30 * time.Second
+)
+
+// Bus carries hub messages between instances. Delivery is at most once:
+// messages published while an instance is not subscribed are lost to it.
+// RedisBus implements it; NATS or any other pub/sub can too.
+type Bus interface {
+	// Publish sends a payload to every subscribed instance, this one
+	// included
+	Publish(ctx context.Context, payload []byte) error
+	// Subscribe receives payloads until ctx is done. The channel closes
+	// when the subscription is lost.
+	Subscribe(ctx context.Context) (<-chan []byte, error)
+	Close() error
+}
+
+// Envelope kinds
+const (
+	envelopeBroadcast = "broadcast"
+	envelopeRoom      = "room"
+	envelopeUser      = "user"
+)
+
+// envelope is a hub message on the bus
+type envelope struct {
+	Node    string   `json:"node"` // Publishing instance, which already delivered it
+	Kind    string   `json:"kind"`
+	Room    string   `json:"room,omitempty"`
+	UserID  uint     `json:"user_id,omitempty"`
+	Exclude []string `json:"exclude,omitempty"` // Connection IDs
+	Data    []byte   `json:"data"`
+}
+
+// ClusterStats summarizes messages exchanged with other instances
+type ClusterStats struct {
+	Node           string `json:"node"`
+	Subscribed     bool   `json:"subscribed"`
+	Published      uint64 `json:"published"`
+	PublishErrors  uint64 `json:"publish_errors"`
+	PublishDropped uint64 `json:"publish_dropped"` // Queue full
+	Received       uint64 `json:"received"`        // From other instances
+	Invalid        uint64 `json:"invalid"`
+}
+
+// cluster links a hub to the other instances behind a load balancer.
+// Connections, rooms and users stay registered with the instance they
+// are connected to: every message is published once and each instance
+// delivers it to its own connections.
+type cluster struct {
+	bus    Bus
+	node   string
+	queue  chan []byte
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	subscribed     atomic.Bool
+	failing        atomic.Bool
+	published      atomic.Uint64
+	publishErrors  atomic.Uint64
+	publishDropped atomic.Uint64
+	received       atomic.Uint64
+	invalid        atomic.Uint64
+}
+
+// UseBus fans the hub's broadcasts, room broadcasts and messages to users
+// out to the other instances on bus, and delivers theirs here. Call it
+// once, before serving connections.
+func (h *Hub) UseBus(bus Bus) {
+	node := make([]byte, 8)
+	rand.Read(node)
+
+	c := &cluster{
+		bus:   bus,
+		node:  hex.EncodeToString(node),
+		queue: make(chan []byte, clusterQueueSize),
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	h.cluster = c
+
+	go h.runPublisher(c)
+	go h.runSubscriber(c)
+}
+
+// ClusterStats returns the hub's bus totals, or nil for a single instance
+func (h *Hub) ClusterStats() *ClusterStats {
+	c := h.cluster
+	if c == nil {
+		return nil
+	}
+	return &ClusterStats{
+		Node:           c.node,
+		Subscribed:     c.subscribed.Load(),
+		Published:      c.published.Load(),
+		PublishErrors:  c.publishErrors.Load(),
+		PublishDropped: c.publishDropped.Load(),
+		Received:       c.received.Load(),
+		Invalid:        c.invalid.Load(),
+	}
+}
+
+// publish queues a message for the other instances. It never blocks: with
+// the queue full the message stays on this instance.
+func (h *Hub) publish(e envelope) {
+	c := h.cluster
+	if c == nil {
+		return
+	}
+	e.Node = c.node
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	select {
+	case c.queue <- payload:
+	default:
+		c.publishDropped.Add(1)
+	}
+}
+
+// runPublisher publishes queued messages in order, without retries
+func (h *Hub) runPublisher(c *cluster) {
+	for {
+		select {
+		case payload := <-c.queue:
+			ctx, cancel := context.WithTimeout(c.ctx, clusterPublishTimeout)
+			err := c.bus.Publish(ctx, payload)
+			cancel()
+			if err != nil {
+				c.publishErrors.Add(1)
+				// Logged once per outage
+				if !c.failing.Swap(true) {
+					logger.Warn("WebSocket bus publish failed; messages stay on this instance", logger.Fields{"error": err.Error()})
+				}
+				continue
+			}
+			c.published.Add(1)
+			c.failing.Store(false)
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// runSubscriber delivers the other instances' messages, subscribing again
+// with backoff when the subscription is lost
+func (h *Hub) runSubscriber(c *cluster) {
+	retry := clusterRetry
+	for {
+		messages, err := c.bus.Subscribe(c.ctx)
+		if err == nil {
+			c.subscribed.Store(true)
+			retry = clusterRetry
+			for payload := range messages {
+				h.receive(c, payload)
+			}
+			c.subscribed.Store(false)
+		}
+		if c.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Warn("WebSocket bus subscription failed", logger.Fields{"error": err.Error(), "retry_in": retry.String()})
+		}
+
+		select {
+		case <-time.After(retry):
+		case <-c.ctx.Done():
+			return
+		}
+		if retry *= 2; retry > clusterMaxRetry {
+			retry = clusterMaxRetry
+		}
+	}
+}
+
+// receive delivers another instance's message to this instance's
+// connections, without publishing it again
+func (h *Hub) receive(c *cluster, payload []byte) {
+	var e envelope
+	if err := json.Unmarshal(payload, &e); err != nil {
+		c.invalid.Add(1)
+		return
+	}
+	if e.Node == c.node {
+		return
+	}
+	c.received.Add(1)
+
+	switch e.Kind {
+	case envelopeBroadcast:
+		h.broadcastLocal(e.Data)
+	case envelopeRoom:
+		exclude := make(map[string]bool, len(e.Exclude))
+		for _, id := range e.Exclude {
+			exclude[id] = true
+		}
+		room, _ := h.GetRoom(e.Room)
+		h.roomLocal(e.Room, room, e.Data, exclude)
+	case envelopeUser:
+		h.sendToUserLocal(e.UserID, e.Data)
+	default:
+		c.invalid.Add(1)
+	}
+}
+
+// closeCluster stops exchanging messages with the other instances
+func (h *Hub) closeCluster() {
+	if c := h.cluster; c != nil {
+		c.cancel()
+		c.bus.Close()
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/contrib/websocket"
@@ -33,10 +34,22 @@ type Connection struct {
 	mu        sync.RWMutex
 	sendCh    chan []byte
 	done      chan struct{}
+	writeWait time.Duration // Limit of each write, none when zero
+	stopped   chan struct{} // Closed when writePump returns
+	dropped   atomic.Int64 // Messages dropped in a row, buffer full
+	slow      atomic.Bool  // Slow client policy applied since the last delivery
 }
 
+// DefaultSendBufferSize is the number of messages a connection queues
+// before further ones are dropped
+const DefaultSendBufferSize = 256
+
 // NewConnection creates a new WebSocket connection wrapper
 func NewConnection(id string, userID uint, conn *websocket.Conn) *Connection {
+	return newConnection(id, userID, conn, DefaultSendBufferSize, 0)
+}
+
+func newConnection(id string, userID uint, conn *websocket.Conn, bufferSize int, writeWait time.Duration) *Connection {
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	c := &Connection{
@@ -49,8 +62,10 @@ func NewConnection(id string, userID uint, conn *websocket.Conn) *Connection {
 		Metadata:  make(map[string]interface{}),
 		CreatedAt: time.Now(),
 		LastPing:  time.Now(),
-		sendCh:    make(chan []byte, 256),
+		sendCh:    make(chan []byte, bufferSize),
 		done:      make(chan struct{}),
+		writeWait: writeWait,
+		stopped:   make(chan struct{}),
 	}
 	
 	// Start send pump
@@ -70,14 +85,31 @@ func (c *Connection) Send(message []byte) error {
 	
 	select {
 	case c.sendCh <- message:
+		if c.dropped.Load() != 0 {
+			c.dropped.Store(0)
+			c.slow.Store(false)
+		}
 		return nil
 	case <-c.done:
 		return ErrConnectionClosed
 	default:
+		c.dropped.Add(1)
 		return ErrSendBufferFull
 	}
 }
 
+// Dropped returns the number of messages dropped in a row because the send
+// buffer was full
+func (c *Connection) Dropped() int64 {
+	return c.dropped.Load()
+}
+
+// markSlow reports whether the connection was not already marked slow
+// since its last delivery
+func (c *Connection) markSlow() bool {
+	return c.slow.CompareAndSwap(false, true)
+}
+
 // SendJSON sends a JSON message to the connection
 func (c *Connection) SendJSON(v interface{}) error {
 	data, err := json.Marshal(v)
@@ -89,25 +121,37 @@ func (c *Connection) SendJSON(v interface{}) error {
 
 // Close closes the connection gracefully
 func (c *Connection) Close() error {
+	return c.closeWith(websocket.CloseNormalClosure, "")
+}
+
+// closeWith closes the connection with a close code. The close frame is
+// written outside the lock, so senders are not held up by a slow peer.
+func (c *Connection) closeWith(code int, text string) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	
 	if c.Status == StatusClosed || c.Status == StatusClosing {
+		c.mu.Unlock()
 		return nil
 	}
-	
 	c.Status = StatusClosing
 	c.Cancel()
 	close(c.done)
-	
+	c.mu.Unlock()
+
 	// Send close message
 	err := c.Conn.WriteControl(
 		websocket.CloseMessage,
-		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		websocket.FormatCloseMessage(code, text),
 		time.Now().Add(time.Second),
 	)
-	
+	// Fiber only closes the socket once the handler returns: unblock its
+	// read loop, and a write stuck on a peer that stopped reading
+	if netConn := c.Conn.NetConn(); netConn != nil {
+		netConn.SetDeadline(time.Now())
+	}
+
+	c.mu.Lock()
 	c.Status = StatusClosed
+	c.mu.Unlock()
 	return err
 }
 
@@ -145,7 +189,9 @@ func (c *Connection) writePump() {
 	ticker := time.NewTicker(54 * time.Second)
 	defer func() {
 		ticker.Stop()
+		c.closeWith(websocket.CloseGoingAway, "")
 		c.Conn.Close()
+		close(c.stopped)
 	}()
 	
 	for {
@@ -156,12 +202,18 @@ func (c *Connection) writePump() {
 				return
 			}
 			
+			if c.writeWait > 0 {
+				c.Conn.SetWriteDeadline(time.Now().Add(c.writeWait))
+			}
 			if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				return
 			}
 			
 		case <-ticker.C:
 			// Send ping
+			if c.writeWait > 0 {
+				c.Conn.SetWriteDeadline(time.Now().Add(c.writeWait))
+			}
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
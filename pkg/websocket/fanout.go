@@ -0,0 +1,310 @@
+package websocket
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+)
+
+// SlowClientPolicy is what the hub does with a connection whose send
+// buffer stays full
+type SlowClientPolicy string
+
+const (
+	// SlowClientDisconnect closes the connection with 1013 (try again
+	// later), so the client reconnects and resynchronizes
+	SlowClientDisconnect SlowClientPolicy = "disconnect"
+	// SlowClientDrop keeps the connection, dropping messages until its
+	// buffer drains
+	SlowClientDrop SlowClientPolicy = "drop"
+)
+
+// BroadcastResult is the outcome of one broadcast across all fan-out
+// workers
+type BroadcastResult struct {
+	Room       string        // Empty for hub-wide broadcasts
+	Recipients int           // Connections the message was offered to
+	Delivered  int           // Queued in a send buffer
+	Dropped    int           // Send buffer full or connection closing
+	Latency    time.Duration // From the broadcast call until every worker queued it
+}
+
+// BroadcastHandler is called after every broadcast
+type BroadcastHandler func(result BroadcastResult)
+
+// SlowClientHandler is called when a connection is found slow, before the
+// policy is applied
+type SlowClientHandler func(conn *Connection, policy SlowClientPolicy)
+
+// FanoutStats summarizes broadcasts since the hub started
+type FanoutStats struct {
+	Workers         int           `json:"workers"`
+	Broadcasts      uint64        `json:"broadcasts"`
+	Delivered       uint64        `json:"delivered"`
+	Dropped         uint64        `json:"dropped"`
+	SlowClients     uint64        `json:"slow_clients"`
+	SlowDisconnects uint64        `json:"slow_disconnects"`
+	AvgLatency      time.Duration `json:"avg_latency_ns"`
+	MaxLatency      time.Duration `json:"max_latency_ns"`
+	Shards          []ShardStats  `json:"shards"`
+}
+
+// ShardStats is the load of one fan-out worker
+type ShardStats struct {
+	Connections int `json:"connections"`
+	Queued      int `json:"queued"` // Broadcasts waiting for the worker
+}
+
+// shard is a fan-out worker and the connections it delivers to.
+// Connections are assigned by a hash of their ID, so broadcasts are split
+// across workers instead of one goroutine writing to every buffer.
+type shard struct {
+	mu    sync.RWMutex
+	conns map[string]*Connection
+	jobs  chan shardJob
+}
+
+// shardJob is a broadcast's share for one shard: all of its connections,
+// or the listed ones for a room
+type shardJob struct {
+	b       *broadcast
+	targets []*Connection
+}
+
+// broadcast tracks a message until every shard has delivered it
+type broadcast struct {
+	room      string
+	target    *Room // Counts the room's deliveries
+	message   []byte
+	exclude   map[string]bool
+	started   time.Time
+	pending   atomic.Int32
+	offered   atomic.Int64
+	delivered atomic.Int64
+	dropped   atomic.Int64
+}
+
+// fanoutCounters accumulate FanoutStats
+type fanoutCounters struct {
+	broadcasts      atomic.Uint64
+	delivered       atomic.Uint64
+	dropped         atomic.Uint64
+	slowClients     atomic.Uint64
+	slowDisconnects atomic.Uint64
+	latencyTotal    atomic.Int64
+	latencyMax      atomic.Int64
+}
+
+// startShards starts the fan-out workers
+func (h *Hub) startShards(workers, queueSize int) {
+	h.shards = make([]*shard, workers)
+	for i := range h.shards {
+		s := &shard{conns: make(map[string]*Connection), jobs: make(chan shardJob, queueSize)}
+		h.shards[i] = s
+		go h.runShard(s)
+	}
+}
+
+// shardFor returns the shard a connection belongs to
+func (h *Hub) shardFor(connID string) *shard {
+	hash := fnv.New32a()
+	hash.Write([]byte(connID))
+	return h.shards[hash.Sum32()%uint32(len(h.shards))]
+}
+
+func (s *shard) add(conn *Connection) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns[conn.ID] = conn
+}
+
+func (s *shard) remove(connID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, connID)
+}
+
+// runShard delivers broadcasts to the shard's connections until the hub
+// closes
+func (h *Hub) runShard(s *shard) {
+	for {
+		select {
+		case job := <-s.jobs:
+			h.deliver(s, job)
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// deliver offers a broadcast to the shard's connections. Offering never
+// blocks: a full send buffer drops the message for that connection.
+func (h *Hub) deliver(s *shard, job shardJob) {
+	b := job.b
+	var slow []*Connection
+	offer := func(conn *Connection) {
+		if b.exclude[conn.ID] {
+			return
+		}
+		b.offered.Add(1)
+		if conn.Send(b.message) == nil {
+			b.delivered.Add(1)
+			return
+		}
+		b.dropped.Add(1)
+		if conn.Dropped() >= int64(h.slowClientThreshold) {
+			slow = append(slow, conn)
+		}
+	}
+
+	if job.targets != nil {
+		for _, conn := range job.targets {
+			offer(conn)
+		}
+	} else {
+		s.mu.RLock()
+		for _, conn := range s.conns {
+			offer(conn)
+		}
+		s.mu.RUnlock()
+	}
+
+	for _, conn := range slow {
+		h.handleSlowClient(conn)
+	}
+	if b.pending.Add(-1) == 0 {
+		h.finishBroadcast(b)
+	}
+}
+
+// fanout splits a message across the shards. Queueing blocks only while
+// a worker's queue is full, which pushes back on the broadcaster rather
+// than losing the message for a whole shard.
+func (h *Hub) fanout(b *broadcast, targets map[*shard][]*Connection) {
+	b.started = time.Now()
+	shards := h.shards
+	if targets != nil {
+		shards = make([]*shard, 0, len(targets))
+		for s := range targets {
+			shards = append(shards, s)
+		}
+	}
+	if len(shards) == 0 {
+		h.finishBroadcast(b)
+		return
+	}
+
+	b.pending.Store(int32(len(shards)))
+	for _, s := range shards {
+		job := shardJob{b: b}
+		if targets != nil {
+			job.targets = targets[s]
+		}
+		select {
+		case s.jobs <- job:
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// finishBroadcast records a delivered broadcast and reports it
+func (h *Hub) finishBroadcast(b *broadcast) {
+	latency := time.Since(b.started)
+	result := BroadcastResult{
+		Room:       b.room,
+		Recipients: int(b.offered.Load()),
+		Delivered:  int(b.delivered.Load()),
+		Dropped:    int(b.dropped.Load()),
+		Latency:    latency,
+	}
+
+	if b.target != nil {
+		b.target.counters.delivered.Add(uint64(result.Delivered))
+		b.target.counters.dropped.Add(uint64(result.Dropped))
+	}
+
+	h.stats.broadcasts.Add(1)
+	h.stats.delivered.Add(uint64(result.Delivered))
+	h.stats.dropped.Add(uint64(result.Dropped))
+	h.stats.latencyTotal.Add(int64(latency))
+	for {
+		current := h.stats.latencyMax.Load()
+		if int64(latency) <= current || h.stats.latencyMax.CompareAndSwap(current, int64(latency)) {
+			break
+		}
+	}
+
+	h.handlersMu.RLock()
+	handlers := h.broadcastHandlers
+	h.handlersMu.RUnlock()
+	for _, handler := range handlers {
+		handler(result)
+	}
+}
+
+// handleSlowClient applies the slow client policy, once each time a
+// connection falls behind
+func (h *Hub) handleSlowClient(conn *Connection) {
+	if !conn.markSlow() {
+		return
+	}
+	h.stats.slowClients.Add(1)
+
+	h.handlersMu.RLock()
+	handlers := h.slowClientHandlers
+	h.handlersMu.RUnlock()
+	for _, handler := range handlers {
+		handler(conn, h.slowClientPolicy)
+	}
+
+	if h.slowClientPolicy == SlowClientDisconnect {
+		h.stats.slowDisconnects.Add(1)
+		// Closing writes a close frame, so keep it off the worker
+		go func() {
+			conn.closeWith(websocket.CloseTryAgainLater, "send buffer full")
+			h.Unregister(conn.ID)
+		}()
+	}
+}
+
+// OnBroadcast registers a handler called after every broadcast, from a
+// fan-out worker
+func (h *Hub) OnBroadcast(handler BroadcastHandler) {
+	h.handlersMu.Lock()
+	defer h.handlersMu.Unlock()
+	h.broadcastHandlers = append(h.broadcastHandlers, handler)
+}
+
+// OnSlowClient registers a handler called when a connection is found slow
+func (h *Hub) OnSlowClient(handler SlowClientHandler) {
+	h.handlersMu.Lock()
+	defer h.handlersMu.Unlock()
+	h.slowClientHandlers = append(h.slowClientHandlers, handler)
+}
+
+// FanoutStats returns broadcast totals and the load of each worker
+func (h *Hub) FanoutStats() FanoutStats {
+	stats := FanoutStats{
+		Workers:         len(h.shards),
+		Broadcasts:      h.stats.broadcasts.Load(),
+		Delivered:       h.stats.delivered.Load(),
+		Dropped:         h.stats.dropped.Load(),
+		SlowClients:     h.stats.slowClients.Load(),
+		SlowDisconnects: h.stats.slowDisconnects.Load(),
+		MaxLatency:      time.Duration(h.stats.latencyMax.Load()),
+		Shards:          make([]ShardStats, len(h.shards)),
+	}
+	if stats.Broadcasts > 0 {
+		stats.AvgLatency = time.Duration(h.stats.latencyTotal.Load() / int64(stats.Broadcasts))
+	}
+	for i, s := range h.shards {
+		s.mu.RLock()
+		stats.Shards[i] = ShardStats{Connections: len(s.conns), Queued: len(s.jobs)}
+		s.mu.RUnlock()
+	}
+	return stats
+}
@@ -2,6 +2,7 @@ package websocket
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
@@ -48,16 +49,19 @@ func (h *Handler) HandleConnection(c *websocket.Conn) {
 	}
 	
 	// Create connection
-	conn := NewConnection(connID, userID, c)
+	conn := h.hub.NewConnection(connID, userID, c)
 	
 	// Register with hub
 	if err := h.hub.Register(conn); err != nil {
 		conn.Close()
+		<-conn.stopped
 		return
 	}
 	
 	defer func() {
 		h.hub.Unregister(connID)
+		// Fiber reuses c once the handler returns
+		<-conn.stopped
 		if h.onDisconnect != nil {
 			h.onDisconnect(conn)
 		}
@@ -119,29 +123,20 @@ func (h *Handler) defaultMessageHandler(conn *Connection, msg *Message) error {
 			return fmt.Errorf("room name required")
 		}
 		
-		// Create room if not exists
+		// Create room if not exists; joining reports the user's presence
+		// to the room
 		room := h.hub.CreateRoom(msg.Room)
 		room.Join(conn)
 		
-		// Send confirmation
+		// Send confirmation with the users online
 		roomMsg := NewMessage(TypeSystem, RoomPayload{
 			Room:    msg.Room,
 			Action:  "joined",
+			Data:    room.Presence(),
 			Members: room.MemberCount(),
 		})
 		conn.SendJSON(roomMsg)
 		
-		// Notify room members
-		notifyMsg := NewMessage(TypeSystem, SystemPayload{
-			Event:   "user_joined",
-			Message: fmt.Sprintf("User %d joined room", conn.UserID),
-			Data: map[string]interface{}{
-				"user_id": conn.UserID,
-				"room":    msg.Room,
-			},
-		})
-		room.Broadcast([]byte(fmt.Sprintf("%v", notifyMsg)), conn.ID)
-		
 	case TypeLeaveRoom:
 		// Leave room
 		if msg.Room == "" {
@@ -165,11 +160,32 @@ func (h *Handler) defaultMessageHandler(conn *Connection, msg *Message) error {
 			return fmt.Errorf("room name required")
 		}
 		
+		room, ok := h.hub.GetRoom(msg.Room)
+		if !ok || !room.HasMember(conn.ID) {
+			return fmt.Errorf("not a member of room %s", msg.Room)
+		}
+		
 		msg.From = conn.UserID
-		msg.Timestamp = msg.Timestamp
+		msg.Timestamp = time.Now()
 		
 		data, _ := msg.ToJSON()
-		return h.hub.BroadcastToRoom(msg.Room, data)
+		room.Broadcast(data)
+		
+	case TypePresence:
+		// List the users online in a room
+		if msg.Room == "" {
+			return fmt.Errorf("room name required")
+		}
+		
+		presence, err := h.hub.Presence(msg.Room)
+		if err != nil {
+			return err
+		}
+		return conn.SendJSON(NewMessage(TypePresence, RoomPayload{
+			Room:   msg.Room,
+			Action: "presence",
+			Data:   presence,
+		}).WithRoom(msg.Room))
 		
 	case TypeUserMessage:
 		// Send message to specific user
@@ -197,7 +213,7 @@ func (h *Handler) defaultMessageHandler(conn *Connection, msg *Message) error {
 // Middleware creates a Fiber middleware for WebSocket upgrade
 func (h *Handler) Middleware() fiber.Handler {
 	return websocket.New(h.HandleConnection, websocket.Config{
-		RecoveryHandler: func(conn *websocket.Conn) {
+		RecoverHandler: func(conn *websocket.Conn) {
 			if err := recover(); err != nil {
 				fmt.Printf("WebSocket panic: %v\n", err)
 			}
@@ -219,14 +235,15 @@ func SetupRoutes(app fiber.Router, hub *Hub, messageHandler MessageHandler) {
 	})
 	
 	// WebSocket upgrade endpoint
-	app.Use("/ws", func(c *fiber.Ctx) error {
+	app.Get("/ws", func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
 			return c.Next()
 		}
 		return fiber.ErrUpgradeRequired
-	})
+	}, handler.Middleware())
 	
-	app.Get("/ws", handler.Middleware())
+	// Long polling, for clients whose proxies block WebSockets
+	app.Get("/ws/poll", hub.PollHandler())
 	
 	// Stats endpoint
 	app.Get("/ws/stats", func(c *fiber.Ctx) error {
@@ -235,6 +252,9 @@ func SetupRoutes(app fiber.Router, hub *Hub, messageHandler MessageHandler) {
 			"users":       hub.UserCount(),
 			"rooms":       hub.RoomCount(),
 			"room_list":   hub.ListRooms(),
+			"room_stats":  hub.RoomStats(),
+			"fanout":      hub.FanoutStats(),
+			"cluster":     hub.ClusterStats(),
 		})
 	})
 }
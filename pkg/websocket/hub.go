@@ -1,9 +1,15 @@
 package websocket
 
 import (
+	"encoding/json"
 	"errors"
+	"os"
+	"runtime"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/gofiber/contrib/websocket"
 )
 
 var (
@@ -30,6 +36,23 @@ type Hub struct {
 	cleanupInterval time.Duration
 	cleanupTicker   *time.Ticker
 	done            chan struct{}
+
+	// Fan-out, see fanout.go
+	shards              []*shard
+	sendBufferSize      int
+	slowClientPolicy    SlowClientPolicy
+	slowClientThreshold int
+	stats               fanoutCounters
+	handlersMu          sync.RWMutex
+	broadcastHandlers   []BroadcastHandler
+	slowClientHandlers  []SlowClientHandler
+	presenceHandlers    []PresenceHandler // See presence.go
+
+	// Long polling, see poll.go
+	poll *pollLog
+
+	// Other instances, see cluster.go; nil for a single instance
+	cluster *cluster
 }
 
 // HubConfig configures the Hub
@@ -39,6 +62,26 @@ type HubConfig struct {
 	WriteTimeout    time.Duration
 	MaxMessageSize  int64
 	CleanupInterval time.Duration
+
+	// FanoutWorkers deliver broadcasts, each to its share of the
+	// connections. Default: the number of CPUs.
+	FanoutWorkers int
+	// FanoutQueueSize is the number of broadcasts a worker queues before
+	// broadcasting blocks
+	FanoutQueueSize int
+	// SendBufferSize is the number of messages a connection queues before
+	// further ones are dropped
+	SendBufferSize int
+	// SlowClientThreshold is the number of messages dropped in a row after
+	// which SlowClientPolicy applies
+	SlowClientThreshold int
+	SlowClientPolicy    SlowClientPolicy
+
+	// PollBufferSize is the number of messages kept for long polling
+	// clients to resume from; clients further behind are told to reset
+	PollBufferSize int
+	// PollMaxWait bounds how long a poll waits for a message
+	PollMaxWait time.Duration
 }
 
 // DefaultHubConfig returns default Hub configuration
@@ -49,7 +92,48 @@ func DefaultHubConfig() HubConfig {
 		WriteTimeout:    10 * time.Second,
 		MaxMessageSize:  512 * 1024, // 512 KB
 		CleanupInterval: 30 * time.Second,
+
+		FanoutWorkers:       runtime.NumCPU(),
+		FanoutQueueSize:     1024,
+		SendBufferSize:      DefaultSendBufferSize,
+		SlowClientThreshold: 32,
+		SlowClientPolicy:    SlowClientDisconnect,
+
+		PollBufferSize: DefaultPollBufferSize,
+		PollMaxWait:    DefaultPollMaxWait,
+	}
+}
+
+// LoadHubConfig loads the hub configuration from WS_FANOUT_WORKERS,
+// WS_FANOUT_QUEUE_SIZE, WS_SEND_BUFFER_SIZE, WS_SLOW_CLIENT_THRESHOLD,
+// WS_SLOW_CLIENT_POLICY, WS_POLL_BUFFER_SIZE and WS_POLL_MAX_WAIT over the
+// defaults
+func LoadHubConfig() HubConfig {
+	config := DefaultHubConfig()
+
+	if n, err := strconv.Atoi(os.Getenv("WS_FANOUT_WORKERS")); err == nil && n > 0 {
+		config.FanoutWorkers = n
 	}
+	if n, err := strconv.Atoi(os.Getenv("WS_FANOUT_QUEUE_SIZE")); err == nil && n > 0 {
+		config.FanoutQueueSize = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("WS_SEND_BUFFER_SIZE")); err == nil && n > 0 {
+		config.SendBufferSize = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("WS_SLOW_CLIENT_THRESHOLD")); err == nil && n > 0 {
+		config.SlowClientThreshold = n
+	}
+	switch policy := SlowClientPolicy(os.Getenv("WS_SLOW_CLIENT_POLICY")); policy {
+	case SlowClientDisconnect, SlowClientDrop:
+		config.SlowClientPolicy = policy
+	}
+	if n, err := strconv.Atoi(os.Getenv("WS_POLL_BUFFER_SIZE")); err == nil && n > 0 {
+		config.PollBufferSize = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("WS_POLL_MAX_WAIT")); err == nil && d > 0 {
+		config.PollMaxWait = d
+	}
+	return config
 }
 
 // NewHub creates a new WebSocket hub
@@ -64,7 +148,30 @@ func NewHub(config HubConfig) *Hub {
 		maxMessageSize:  config.MaxMessageSize,
 		cleanupInterval: config.CleanupInterval,
 		done:            make(chan struct{}),
+
+		sendBufferSize:      config.SendBufferSize,
+		slowClientPolicy:    config.SlowClientPolicy,
+		slowClientThreshold: config.SlowClientThreshold,
+
+		poll: newPollLog(config.PollBufferSize, config.PollMaxWait),
+	}
+	if h.sendBufferSize <= 0 {
+		h.sendBufferSize = DefaultSendBufferSize
+	}
+	if h.slowClientPolicy == "" {
+		h.slowClientPolicy = SlowClientDisconnect
+	}
+	if h.slowClientThreshold <= 0 {
+		h.slowClientThreshold = 32
+	}
+	workers, queueSize := config.FanoutWorkers, config.FanoutQueueSize
+	if workers <= 0 {
+		workers = runtime.NumCPU()
 	}
+	if queueSize <= 0 {
+		queueSize = 1024
+	}
+	h.startShards(workers, queueSize)
 	
 	// Start cleanup goroutine
 	h.startCleanup()
@@ -72,6 +179,12 @@ func NewHub(config HubConfig) *Hub {
 	return h
 }
 
+// NewConnection wraps a WebSocket connection with the hub's send buffer
+// size and write timeout
+func (h *Hub) NewConnection(id string, userID uint, conn *websocket.Conn) *Connection {
+	return newConnection(id, userID, conn, h.sendBufferSize, h.writeTimeout)
+}
+
 // Register adds a new connection to the hub
 func (h *Hub) Register(conn *Connection) error {
 	h.mu.Lock()
@@ -88,6 +201,7 @@ func (h *Hub) Register(conn *Connection) error {
 		h.userConns[conn.UserID] = make(map[string]*Connection)
 	}
 	h.userConns[conn.UserID][conn.ID] = conn
+	h.shardFor(conn.ID).add(conn)
 	
 	return nil
 }
@@ -95,15 +209,16 @@ func (h *Hub) Register(conn *Connection) error {
 // Unregister removes a connection from the hub
 func (h *Hub) Unregister(connID string) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
 	
 	conn, exists := h.connections[connID]
 	if !exists {
+		h.mu.Unlock()
 		return
 	}
 	
 	// Remove from connections
 	delete(h.connections, connID)
+	h.shardFor(connID).remove(connID)
 	
 	// Remove from user connections
 	if userConns, ok := h.userConns[conn.UserID]; ok {
@@ -113,8 +228,15 @@ func (h *Hub) Unregister(connID string) {
 		}
 	}
 	
-	// Remove from all rooms
+	rooms := make([]*Room, 0, len(h.rooms))
 	for _, room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	h.mu.Unlock()
+	
+	// Remove from all rooms, unlocked: leaving reports presence, and
+	// presence handlers may use the hub
+	for _, room := range rooms {
 		room.Leave(connID)
 	}
 	
@@ -147,29 +269,41 @@ func (h *Hub) GetUserConnections(userID uint) []*Connection {
 	return conns
 }
 
-// Broadcast sends a message to all connections
+// Broadcast sends a message to all connections, on every instance when
+// the hub uses a bus. The fan-out workers deliver it, so it returns before
+// every connection has it queued.
 func (h *Hub) Broadcast(message []byte) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-	
-	for _, conn := range h.connections {
-		conn.Send(message)
-	}
+	h.broadcastLocal(message)
+	h.publish(envelope{Kind: envelopeBroadcast, Data: message})
+}
+
+// broadcastLocal sends a message to this instance's connections
+func (h *Hub) broadcastLocal(message []byte) {
+	h.poll.add(ChannelBroadcast, "", 0, message)
+	h.fanout(&broadcast{message: message}, nil)
 }
 
-// BroadcastJSON sends a JSON message to all connections
+// BroadcastJSON sends a JSON message to all connections, encoding it once
 func (h *Hub) BroadcastJSON(v interface{}) error {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-	
-	for _, conn := range h.connections {
-		conn.SendJSON(v)
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
 	}
+	h.Broadcast(data)
 	return nil
 }
 
-// SendToUser sends a message to all connections of a specific user
+// SendToUser sends a message to all connections of a specific user, on
+// every instance when the hub uses a bus
 func (h *Hub) SendToUser(userID uint, message []byte) {
+	h.sendToUserLocal(userID, message)
+	h.publish(envelope{Kind: envelopeUser, UserID: userID, Data: message})
+}
+
+// sendToUserLocal sends a message to the user's connections on this
+// instance
+func (h *Hub) sendToUserLocal(userID uint, message []byte) {
+	h.poll.add(ChannelUser, "", userID, message)
 	conns := h.GetUserConnections(userID)
 	for _, conn := range conns {
 		conn.Send(message)
@@ -178,10 +312,11 @@ func (h *Hub) SendToUser(userID uint, message []byte) {
 
 // SendToUserJSON sends a JSON message to all connections of a specific user
 func (h *Hub) SendToUserJSON(userID uint, v interface{}) error {
-	conns := h.GetUserConnections(userID)
-	for _, conn := range conns {
-		conn.SendJSON(v)
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
 	}
+	h.SendToUser(userID, data)
 	return nil
 }
 
@@ -202,6 +337,7 @@ func (h *Hub) UserCount() int {
 // Close shuts down the hub and closes all connections
 func (h *Hub) Close() {
 	close(h.done)
+	h.closeCluster()
 	
 	if h.cleanupTicker != nil {
 		h.cleanupTicker.Stop()
@@ -216,6 +352,11 @@ func (h *Hub) Close() {
 	}
 	
 	// Clear maps
+	for _, s := range h.shards {
+		s.mu.Lock()
+		s.conns = make(map[string]*Connection)
+		s.mu.Unlock()
+	}
 	h.connections = make(map[string]*Connection)
 	h.userConns = make(map[uint]map[string]*Connection)
 	h.rooms = make(map[string]*Room)
@@ -20,6 +20,7 @@ const (
 	TypeNotification MessageType = "notification"
 	TypeError        MessageType = "error"
 	TypeSystem       MessageType = "system"
+	TypePresence     MessageType = "presence" // Presence events; clients send it to list a room's users
 )
 
 // Message represents a WebSocket message
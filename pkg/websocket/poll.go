@@ -0,0 +1,261 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Long polling defaults
+const (
+	DefaultPollBufferSize = 1024
+	DefaultPollWait       = 25 * time.Second
+	DefaultPollMaxWait    = 30 * time.Second
+	maxPollMessages       = 100 // Returned by one poll
+)
+
+// Channels of polled messages
+const (
+	ChannelBroadcast = "broadcast"
+	ChannelRoom      = "room:" // Followed by the room name
+	ChannelUser      = "user"
+)
+
+// ErrInvalidCursor is returned for a poll cursor the hub did not issue
+var ErrInvalidCursor = errors.New("invalid poll cursor")
+
+// PollMessage is a message delivered by long polling
+type PollMessage struct {
+	Seq     uint64          `json:"seq"`
+	Channel string          `json:"channel"` // "broadcast", "room:<name>" or "user"
+	Data    json.RawMessage `json:"data"`
+
+	room   string
+	userID uint
+}
+
+// PollRequest asks for the messages after a cursor
+type PollRequest struct {
+	Cursor string        // From a previous result; only messages from now on when empty
+	Rooms  []string      // Rooms to receive, besides broadcasts
+	UserID uint          // Receives the user's messages when set
+	Wait   time.Duration // How long to wait for a message; bounded by the hub's PollMaxWait
+}
+
+// PollResult holds the messages after a poll's cursor
+type PollResult struct {
+	Cursor   string        `json:"cursor"` // To resume from in the next poll
+	Messages []PollMessage `json:"messages"`
+	// Messages were missed: the cursor is older than the messages kept, or
+	// from another server. The client should reload its state.
+	Reset bool `json:"reset,omitempty"`
+}
+
+// pollLog keeps a hub's latest messages in a ring, for clients that
+// cannot hold a WebSocket open to resume from
+type pollLog struct {
+	epoch   string // Identifies the log in cursors, so cursors of another process are caught
+	mu      sync.Mutex
+	entries []PollMessage
+	next    uint64        // Sequence of the next message, from 1
+	notify  chan struct{} // Closed when a message is added
+	maxWait time.Duration
+}
+
+func newPollLog(size int, maxWait time.Duration) *pollLog {
+	if size <= 0 {
+		size = DefaultPollBufferSize
+	}
+	if maxWait <= 0 {
+		maxWait = DefaultPollMaxWait
+	}
+	epoch := make([]byte, 4)
+	rand.Read(epoch)
+	return &pollLog{
+		epoch:   hex.EncodeToString(epoch),
+		entries: make([]PollMessage, size),
+		next:    1,
+		notify:  make(chan struct{}),
+		maxWait: maxWait,
+	}
+}
+
+// add records a message and wakes the waiting polls
+func (l *pollLog) add(channel, room string, userID uint, message []byte) {
+	// Copied, as senders may reuse their buffers
+	var data json.RawMessage
+	if json.Valid(message) {
+		data = append(data, message...)
+	} else {
+		data, _ = json.Marshal(string(message))
+	}
+
+	l.mu.Lock()
+	l.entries[l.next%uint64(len(l.entries))] = PollMessage{
+		Seq:     l.next,
+		Channel: channel,
+		Data:    data,
+		room:    room,
+		userID:  userID,
+	}
+	l.next++
+	close(l.notify)
+	l.notify = make(chan struct{})
+	l.mu.Unlock()
+}
+
+// cursor encodes a position in the log
+func (l *pollLog) cursor(seq uint64) string {
+	return l.epoch + "-" + strconv.FormatUint(seq, 36)
+}
+
+// parseCursor returns the sequence a cursor is at, and whether it is from
+// this log
+func (l *pollLog) parseCursor(cursor string) (uint64, bool, error) {
+	epoch, seq, ok := strings.Cut(cursor, "-")
+	if !ok {
+		return 0, false, ErrInvalidCursor
+	}
+	n, err := strconv.ParseUint(seq, 36, 64)
+	if err != nil {
+		return 0, false, ErrInvalidCursor
+	}
+	return n, epoch == l.epoch, nil
+}
+
+// read returns the messages after a sequence the request may see, and the
+// sequence the next read starts after; the caller holds the lock
+func (l *pollLog) read(after uint64, req PollRequest, rooms map[string]bool) ([]PollMessage, uint64, bool) {
+	last := l.next - 1
+	reset := false
+	oldest := uint64(1)
+	if l.next > uint64(len(l.entries)) {
+		oldest = l.next - uint64(len(l.entries))
+	}
+	if after > last {
+		// Past the end: not a cursor of this log's
+		return nil, last, true
+	}
+	if after+1 < oldest {
+		after, reset = oldest-1, true
+	}
+
+	var messages []PollMessage
+	for seq := after + 1; seq <= last; seq++ {
+		entry := l.entries[seq%uint64(len(l.entries))]
+		after = seq
+		switch {
+		case entry.Channel == ChannelBroadcast,
+			entry.Channel == ChannelUser && req.UserID != 0 && entry.userID == req.UserID,
+			entry.room != "" && rooms[entry.room]:
+			messages = append(messages, entry)
+		}
+		if len(messages) == maxPollMessages {
+			break
+		}
+	}
+	return messages, after, reset
+}
+
+// Poll returns the messages after a request's cursor: at once when there
+// are some, else as soon as one arrives or the wait ends
+func (h *Hub) Poll(ctx context.Context, req PollRequest) (*PollResult, error) {
+	l := h.poll
+	rooms := make(map[string]bool, len(req.Rooms))
+	for _, room := range req.Rooms {
+		rooms[room] = true
+	}
+
+	wait := req.Wait
+	if wait <= 0 {
+		wait = DefaultPollWait
+	}
+	if wait > l.maxWait {
+		wait = l.maxWait
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	l.mu.Lock()
+	after := l.next - 1
+	if req.Cursor != "" {
+		seq, current, err := l.parseCursor(req.Cursor)
+		if err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+		if !current {
+			l.mu.Unlock()
+			return &PollResult{Cursor: l.cursor(after), Messages: []PollMessage{}, Reset: true}, nil
+		}
+		after = seq
+	}
+
+	for {
+		messages, next, reset := l.read(after, req, rooms)
+		if len(messages) > 0 || reset {
+			l.mu.Unlock()
+			if messages == nil {
+				messages = []PollMessage{}
+			}
+			return &PollResult{Cursor: l.cursor(next), Messages: messages, Reset: reset}, nil
+		}
+		after = next
+		notify := l.notify
+		l.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-timer.C:
+			return &PollResult{Cursor: l.cursor(after), Messages: []PollMessage{}}, nil
+		case <-ctx.Done():
+			return &PollResult{Cursor: l.cursor(after), Messages: []PollMessage{}}, nil
+		case <-h.done:
+			return &PollResult{Cursor: l.cursor(after), Messages: []PollMessage{}}, nil
+		}
+		l.mu.Lock()
+	}
+}
+
+// PollHandler serves long polling, for clients behind proxies that block
+// WebSockets. Query parameters: cursor, from the previous response; rooms,
+// comma-separated; and wait, e.g. "25s". Authenticated users also receive
+// their own messages.
+func (h *Hub) PollHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req := PollRequest{Cursor: c.Query("cursor")}
+		if rooms := c.Query("rooms"); rooms != "" {
+			req.Rooms = strings.Split(rooms, ",")
+		}
+		if uid, ok := c.Locals("userID").(uint); ok {
+			req.UserID = uid
+		}
+		if wait := c.Query("wait"); wait != "" {
+			d, err := time.ParseDuration(wait)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid wait: " + wait,
+				})
+			}
+			req.Wait = d
+		}
+
+		result, err := h.Poll(c.Context(), req)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		// Proxies must not cache or buffer polls
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		return c.JSON(result)
+	}
+}
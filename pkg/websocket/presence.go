@@ -0,0 +1,164 @@
+package websocket
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Presence actions
+const (
+	PresenceJoin  = "join"
+	PresenceLeave = "leave"
+)
+
+// PresenceEvent reports a user coming online in a room, with their first
+// connection to join it, or going offline, with their last to leave.
+// Anonymous connections are members without presence.
+type PresenceEvent struct {
+	Room    string    `json:"room"`
+	UserID  uint      `json:"user_id"`
+	Action  string    `json:"action"`  // join or leave
+	Users   int       `json:"users"`   // Online in the room after the event
+	Members int       `json:"members"` // Connections in the room after the event
+	At      time.Time `json:"at"`
+}
+
+// PresenceHandler is called after a user joins or leaves a room
+type PresenceHandler func(event PresenceEvent)
+
+// PresenceEntry is a user online in a room
+type PresenceEntry struct {
+	UserID      uint      `json:"user_id"`
+	Connections int       `json:"connections"`
+	Since       time.Time `json:"since"`
+}
+
+// presence is a user's connections to a room
+type presence struct {
+	conns int
+	since time.Time
+}
+
+// RoomStats summarizes a room's members and messages on this instance
+type RoomStats struct {
+	Name          string     `json:"name"`
+	Members       int        `json:"members"` // Connections
+	Users         int        `json:"users"`   // Online users
+	Messages      uint64     `json:"messages"`
+	Delivered     uint64     `json:"delivered"`
+	Dropped       uint64     `json:"dropped"`
+	Joins         uint64     `json:"joins"`
+	Leaves        uint64     `json:"leaves"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastMessageAt *time.Time `json:"last_message_at,omitempty"`
+}
+
+// roomCounters accumulate RoomStats
+type roomCounters struct {
+	messages    atomic.Uint64
+	delivered   atomic.Uint64
+	dropped     atomic.Uint64
+	joins       atomic.Uint64
+	leaves      atomic.Uint64
+	lastMessage atomic.Int64 // Unix nanoseconds
+}
+
+// Presence returns the users online in the room, by user ID
+func (r *Room) Presence() []PresenceEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]PresenceEntry, 0, len(r.users))
+	for userID, p := range r.users {
+		entries = append(entries, PresenceEntry{UserID: userID, Connections: p.conns, Since: p.since})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].UserID < entries[j].UserID })
+	return entries
+}
+
+// IsOnline reports whether a user has a connection in the room
+func (r *Room) IsOnline(userID uint) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.users[userID]
+	return ok
+}
+
+// UserCount returns the number of users online in the room
+func (r *Room) UserCount() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.users)
+}
+
+// Stats returns the room's member counts and message totals
+func (r *Room) Stats() RoomStats {
+	r.mu.RLock()
+	members, users := len(r.connections), len(r.users)
+	r.mu.RUnlock()
+
+	stats := RoomStats{
+		Name:      r.Name,
+		Members:   members,
+		Users:     users,
+		Messages:  r.counters.messages.Load(),
+		Delivered: r.counters.delivered.Load(),
+		Dropped:   r.counters.dropped.Load(),
+		Joins:     r.counters.joins.Load(),
+		Leaves:    r.counters.leaves.Load(),
+		CreatedAt: r.createdAt,
+	}
+	if last := r.counters.lastMessage.Load(); last != 0 {
+		at := time.Unix(0, last)
+		stats.LastMessageAt = &at
+	}
+	return stats
+}
+
+// Presence returns the users online in a room on this instance
+func (h *Hub) Presence(roomName string) ([]PresenceEntry, error) {
+	room, ok := h.GetRoom(roomName)
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	return room.Presence(), nil
+}
+
+// RoomStats returns the stats of every room, by name
+func (h *Hub) RoomStats() []RoomStats {
+	h.mu.RLock()
+	rooms := make([]*Room, 0, len(h.rooms))
+	for _, room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	h.mu.RUnlock()
+
+	stats := make([]RoomStats, len(rooms))
+	for i, room := range rooms {
+		stats[i] = room.Stats()
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// OnPresence registers a handler called after a user joins or leaves a
+// room of the hub
+func (h *Hub) OnPresence(handler PresenceHandler) {
+	h.handlersMu.Lock()
+	defer h.handlersMu.Unlock()
+	h.presenceHandlers = append(h.presenceHandlers, handler)
+}
+
+// presenceChanged reports a presence event to the handlers and, as a
+// presence message, to the room's members on every instance
+func (h *Hub) presenceChanged(room *Room, event PresenceEvent) {
+	h.handlersMu.RLock()
+	handlers := h.presenceHandlers
+	h.handlersMu.RUnlock()
+	for _, handler := range handlers {
+		handler(event)
+	}
+
+	room.BroadcastJSON(NewMessage(TypePresence, event).WithRoom(room.Name))
+}
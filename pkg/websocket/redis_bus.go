@@ -0,0 +1,107 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultBusChannel is the Redis channel hubs exchange messages on
+const DefaultBusChannel = "ws:hub"
+
+// RedisBus is a Bus on a Redis pub/sub channel. Redis does not keep
+// published messages, so instances miss those sent while they reconnect.
+type RedisBus struct {
+	client  *redis.Client
+	channel string
+}
+
+// NewRedisBus connects to the Redis server at url, e.g.
+// redis://:password@localhost:6379/0
+func NewRedisBus(url, channel string) (*RedisBus, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("websocket bus: invalid Redis URL: %w", err)
+	}
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("websocket bus: connecting to Redis: %w", err)
+	}
+
+	if channel == "" {
+		channel = DefaultBusChannel
+	}
+	return &RedisBus{client: client, channel: channel}, nil
+}
+
+// NewBusFromEnv creates the bus selected by WS_BUS_DRIVER: none (default,
+// a single instance, returning nil) or redis (WS_BUS_REDIS_URL or
+// REDIS_URL, on channel WS_BUS_CHANNEL)
+func NewBusFromEnv() (Bus, error) {
+	switch driver := os.Getenv("WS_BUS_DRIVER"); driver {
+	case "", "none":
+		return nil, nil
+	case "redis":
+		url := os.Getenv("WS_BUS_REDIS_URL")
+		if url == "" {
+			url = os.Getenv("REDIS_URL")
+		}
+		if url == "" {
+			return nil, fmt.Errorf("websocket bus: WS_BUS_REDIS_URL or REDIS_URL is required")
+		}
+		return NewRedisBus(url, os.Getenv("WS_BUS_CHANNEL"))
+	default:
+		return nil, fmt.Errorf("unknown websocket bus driver: %s", driver)
+	}
+}
+
+// Publish sends a payload on the channel
+func (b *RedisBus) Publish(ctx context.Context, payload []byte) error {
+	return b.client.Publish(ctx, b.channel, payload).Err()
+}
+
+// Subscribe receives the channel's payloads until ctx is done. The
+// client reconnects by itself; messages sent meanwhile are lost.
+func (b *RedisBus) Subscribe(ctx context.Context) (<-chan []byte, error) {
+	sub := b.client.Subscribe(ctx, b.channel)
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, fmt.Errorf("websocket bus: subscribing: %w", err)
+	}
+
+	out := make(chan []byte, clusterQueueSize)
+	go func() {
+		defer close(out)
+		defer sub.Close()
+
+		messages := sub.Channel()
+		for {
+			select {
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case out <- []byte(msg.Payload):
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Close disconnects from Redis
+func (b *RedisBus) Close() error {
+	return b.client.Close()
+}
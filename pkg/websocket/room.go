@@ -1,15 +1,21 @@
 package websocket
 
 import (
+	"encoding/json"
 	"sync"
+	"time"
 )
 
 // Room represents a WebSocket room for group communication
 type Room struct {
 	Name        string
 	connections map[string]*Connection
+	users       map[uint]*presence // Authenticated members, see presence.go
 	mu          sync.RWMutex
 	Metadata    map[string]interface{}
+	hub         *Hub // Delivers broadcasts when the room was created by a hub
+	createdAt   time.Time
+	counters    roomCounters
 }
 
 // NewRoom creates a new room
@@ -17,56 +23,112 @@ func NewRoom(name string) *Room {
 	return &Room{
 		Name:        name,
 		connections: make(map[string]*Connection),
+		users:       make(map[uint]*presence),
 		Metadata:    make(map[string]interface{}),
+		createdAt:   time.Now(),
 	}
 }
 
-// Join adds a connection to the room
+// Join adds a connection to the room. In a room of a hub, a user's first
+// connection to join brings them online, which is reported to the hub's
+// presence handlers and the room's members.
 func (r *Room) Join(conn *Connection) {
 	r.mu.Lock()
-	defer r.mu.Unlock()
+	if _, ok := r.connections[conn.ID]; ok {
+		r.mu.Unlock()
+		return
+	}
 	r.connections[conn.ID] = conn
+	r.counters.joins.Add(1)
+
+	online := false
+	if conn.UserID != 0 {
+		p, ok := r.users[conn.UserID]
+		if !ok {
+			p = &presence{since: time.Now()}
+			r.users[conn.UserID] = p
+			online = true
+		}
+		p.conns++
+	}
+	event := r.presenceEvent(conn.UserID, PresenceJoin)
+	r.mu.Unlock()
+
+	if online && r.hub != nil {
+		r.hub.presenceChanged(r, event)
+	}
 }
 
-// Leave removes a connection from the room
+// Leave removes a connection from the room. A user's last connection to
+// leave takes them offline, see Join.
 func (r *Room) Leave(connID string) {
 	r.mu.Lock()
-	defer r.mu.Unlock()
+	conn, ok := r.connections[connID]
+	if !ok {
+		r.mu.Unlock()
+		return
+	}
 	delete(r.connections, connID)
+	r.counters.leaves.Add(1)
+
+	offline := false
+	if p, ok := r.users[conn.UserID]; ok {
+		if p.conns--; p.conns <= 0 {
+			delete(r.users, conn.UserID)
+			offline = true
+		}
+	}
+	event := r.presenceEvent(conn.UserID, PresenceLeave)
+	r.mu.Unlock()
+
+	if offline && r.hub != nil {
+		r.hub.presenceChanged(r, event)
+	}
+}
+
+// presenceEvent describes a presence change; the caller holds r.mu
+func (r *Room) presenceEvent(userID uint, action string) PresenceEvent {
+	return PresenceEvent{
+		Room:    r.Name,
+		UserID:  userID,
+		Action:  action,
+		Users:   len(r.users),
+		Members: len(r.connections),
+		At:      time.Now(),
+	}
 }
 
-// Broadcast sends a message to all connections in the room
+// Broadcast sends a message to all connections in the room. In a room of
+// a hub, the hub's fan-out workers deliver it to their members, and the
+// members of the room on other instances get it when the hub uses a bus.
 func (r *Room) Broadcast(message []byte, excludeConnID ...string) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	
 	exclude := make(map[string]bool)
 	for _, id := range excludeConnID {
 		exclude[id] = true
 	}
-	
-	for _, conn := range r.connections {
-		if !exclude[conn.ID] {
-			conn.Send(message)
+
+	if r.hub == nil {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		for _, conn := range r.connections {
+			if !exclude[conn.ID] {
+				conn.Send(message)
+			}
 		}
+		return
 	}
+	r.hub.roomLocal(r.Name, r, message, exclude)
+	r.hub.publish(envelope{Kind: envelopeRoom, Room: r.Name, Exclude: excludeConnID, Data: message})
 }
 
-// BroadcastJSON sends a JSON message to all connections in the room
+// BroadcastJSON sends a JSON message to all connections in the room,
+// encoding it once
 func (r *Room) BroadcastJSON(v interface{}, excludeConnID ...string) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	
-	exclude := make(map[string]bool)
-	for _, id := range excludeConnID {
-		exclude[id] = true
-	}
-	
-	for _, conn := range r.connections {
-		if !exclude[conn.ID] {
-			conn.SendJSON(v)
-		}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
 	}
+	r.Broadcast(data, excludeConnID...)
 }
 
 // MemberCount returns the number of connections in the room
@@ -106,6 +168,7 @@ func (h *Hub) CreateRoom(name string) *Room {
 	}
 	
 	room := NewRoom(name)
+	room.hub = h
 	h.rooms[name] = room
 	return room
 }
@@ -152,11 +215,18 @@ func (h *Hub) LeaveRoom(connID, roomName string) error {
 	return nil
 }
 
-// BroadcastToRoom sends a message to all connections in a room
+// BroadcastToRoom sends a message to all connections in a room. With a
+// bus, rooms not created on this instance may have members on others, so
+// the message is published instead of failing with ErrRoomNotFound.
 func (h *Hub) BroadcastToRoom(roomName string, message []byte, excludeConnID ...string) error {
 	room, ok := h.GetRoom(roomName)
 	if !ok {
-		return ErrRoomNotFound
+		if h.cluster == nil {
+			return ErrRoomNotFound
+		}
+		h.roomLocal(roomName, nil, message, nil)
+		h.publish(envelope{Kind: envelopeRoom, Room: roomName, Exclude: excludeConnID, Data: message})
+		return nil
 	}
 	
 	room.Broadcast(message, excludeConnID...)
@@ -165,13 +235,32 @@ func (h *Hub) BroadcastToRoom(roomName string, message []byte, excludeConnID ...
 
 // BroadcastToRoomJSON sends a JSON message to all connections in a room
 func (h *Hub) BroadcastToRoomJSON(roomName string, v interface{}, excludeConnID ...string) error {
-	room, ok := h.GetRoom(roomName)
-	if !ok {
-		return ErrRoomNotFound
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
 	}
-	
-	room.BroadcastJSON(v, excludeConnID...)
-	return nil
+	return h.BroadcastToRoom(roomName, data, excludeConnID...)
+}
+
+// roomLocal delivers a room message to the room's members on this
+// instance, if it has the room
+func (h *Hub) roomLocal(name string, room *Room, message []byte, exclude map[string]bool) {
+	h.poll.add(ChannelRoom+name, name, 0, message)
+	if room == nil {
+		return
+	}
+	room.counters.messages.Add(1)
+	room.counters.lastMessage.Store(time.Now().UnixNano())
+
+	room.mu.RLock()
+	targets := make(map[*shard][]*Connection)
+	for _, conn := range room.connections {
+		s := h.shardFor(conn.ID)
+		targets[s] = append(targets[s], conn)
+	}
+	room.mu.RUnlock()
+
+	h.fanout(&broadcast{room: name, target: room, message: message, exclude: exclude}, targets)
 }
 
 // RoomCount returns the total number of rooms
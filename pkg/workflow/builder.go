@@ -46,6 +46,20 @@ func (b *WorkflowBuilder) Config(key string, value interface{}) *WorkflowBuilder
 	return b
 }
 
+// Rollback makes a failed step compensate the steps completed before
+// it, in reverse order
+func (b *WorkflowBuilder) Rollback() *WorkflowBuilder {
+	b.workflow.Rollback = true
+	return b
+}
+
+// MaxConcurrent limits how many executions of the workflow run at once;
+// others wait to start
+func (b *WorkflowBuilder) MaxConcurrent(n int) *WorkflowBuilder {
+	b.workflow.MaxConcurrent = n
+	return b
+}
+
 // AddStep adds a new step to the workflow
 func (b *WorkflowBuilder) AddStep(id, name string) *StepBuilder {
 	step := &Step{
@@ -80,12 +94,38 @@ func (s *StepBuilder) Action(action ActionFunc) *StepBuilder {
 	return s
 }
 
+// Compensate sets the action undoing the step when a later step fails
+// and the workflow rolls back
+func (s *StepBuilder) Compensate(action ActionFunc) *StepBuilder {
+	s.step.Compensate = action
+	return s
+}
+
 // Condition sets step condition function
 func (s *StepBuilder) Condition(condition ConditionFunc) *StepBuilder {
 	s.step.Condition = condition
 	return s
 }
 
+// ConditionExpression makes the step a condition evaluating an
+// expression, see Expression. It panics if the expression does not
+// compile.
+func (s *StepBuilder) ConditionExpression(expression string) *StepBuilder {
+	s.step.Type = StepTypeCondition
+	s.step.Condition = MustCompileExpression(expression).Condition()
+	s.step.Parameters["condition"] = expression
+	return s
+}
+
+// When runs the step only when an expression is true, e.g.
+// "${input.amount > 100}"; otherwise it is skipped. It panics if the
+// expression does not compile.
+func (s *StepBuilder) When(expression string) *StepBuilder {
+	s.step.When = MustCompileExpression(expression).Condition()
+	s.step.Parameters["when"] = expression
+	return s
+}
+
 // OnSuccess sets next step IDs on success
 func (s *StepBuilder) OnSuccess(stepIDs ...string) *StepBuilder {
 	s.step.OnSuccess = stepIDs
@@ -98,6 +138,108 @@ func (s *StepBuilder) OnFailure(stepIDs ...string) *StepBuilder {
 	return s
 }
 
+// Wait makes the step wait for a duration
+func (s *StepBuilder) Wait(duration time.Duration) *StepBuilder {
+	s.step.Type = StepTypeWait
+	s.step.Parameters["duration"] = duration
+	return s
+}
+
+// WaitUntil makes the step wait until a time
+func (s *StepBuilder) WaitUntil(t time.Time) *StepBuilder {
+	s.step.Type = StepTypeWait
+	s.step.Parameters["until"] = t
+	return s
+}
+
+// WaitForCron makes the step wait until the next time of a cron
+// expression, e.g. "0 9 * * MON"
+func (s *StepBuilder) WaitForCron(expr string) *StepBuilder {
+	s.step.Type = StepTypeWait
+	s.step.Parameters["cron"] = expr
+	return s
+}
+
+// WaitForSignal makes the step wait until the signal is sent to the
+// execution, by ID or by correlation, a key literal or "$variable" to
+// read it from the execution, e.g. "$order_id". The signal's payload is
+// the step's output.
+func (s *StepBuilder) WaitForSignal(signal, correlation string) *StepBuilder {
+	s.step.Type = StepTypeWaitForSignal
+	s.step.Parameters["signal"] = signal
+	if correlation != "" {
+		s.step.Parameters["correlation"] = correlation
+	}
+	return s
+}
+
+// HumanTask makes the step wait for a person to complete a task, whose
+// decision is the step's output. assignee is a user ID, "$variable" to
+// read it from the execution, or empty to let anyone claim the task; form
+// is a JSON schema of the decision, or nil.
+func (s *StepBuilder) HumanTask(assignee string, form map[string]interface{}) *StepBuilder {
+	s.step.Type = StepTypeHumanTask
+	if assignee != "" {
+		s.step.Parameters["assignee"] = assignee
+	}
+	if form != nil {
+		s.step.Parameters["form"] = form
+	}
+	return s
+}
+
+// SubWorkflow makes the step start a registered workflow as a child
+// execution and wait for it to finish, the child's variables becoming the
+// step's output. A failed or cancelled child fails the step.
+func (s *StepBuilder) SubWorkflow(workflowID string) *StepBuilder {
+	s.step.Type = StepTypeSubWorkflow
+	s.step.Parameters["workflow_id"] = workflowID
+	return s
+}
+
+// FireAndForget makes a sub-workflow step start its child and go on
+// without waiting for it
+func (s *StepBuilder) FireAndForget() *StepBuilder {
+	s.step.Parameters["await"] = false
+	return s
+}
+
+// InheritVariables starts a sub-workflow's child with a copy of the
+// execution's variables, before MapInput
+func (s *StepBuilder) InheritVariables() *StepBuilder {
+	s.step.Parameters["inherit"] = true
+	return s
+}
+
+// MapInput sets a variable of a sub-workflow's child to value, or to the
+// execution's variable for "$variable"
+func (s *StepBuilder) MapInput(variable string, value interface{}) *StepBuilder {
+	s.mapping("input")[variable] = value
+	return s
+}
+
+// MapOutput sets a variable of the execution, once a sub-workflow's child
+// completes, to value, or to the child's variable for "$variable"
+func (s *StepBuilder) MapOutput(variable string, value interface{}) *StepBuilder {
+	s.mapping("output")[variable] = value
+	return s
+}
+
+func (s *StepBuilder) mapping(parameter string) map[string]interface{} {
+	rules, ok := s.step.Parameters[parameter].(map[string]interface{})
+	if !ok {
+		rules = make(map[string]interface{})
+		s.step.Parameters[parameter] = rules
+	}
+	return rules
+}
+
+// DueIn sets when a human task is due, counted from when it is created
+func (s *StepBuilder) DueIn(d time.Duration) *StepBuilder {
+	s.step.Parameters["due"] = d
+	return s
+}
+
 // Timeout sets step timeout
 func (s *StepBuilder) Timeout(timeout time.Duration) *StepBuilder {
 	s.step.Timeout = timeout
@@ -114,6 +256,26 @@ func (s *StepBuilder) Retry(maxAttempts int, delay time.Duration, backoffRate fl
 	return s
 }
 
+// Concurrency limits how many executions run the step at once
+func (s *StepBuilder) Concurrency(n int) *StepBuilder {
+	s.step.Concurrency = n
+	return s
+}
+
+// RateLimit limits the step's attempts to rate a second, in bursts of up
+// to burst
+func (s *StepBuilder) RateLimit(rate float64, burst int) *StepBuilder {
+	s.step.RateLimit = &RateLimit{Rate: rate, Burst: burst}
+	return s
+}
+
+// SharedRateLimit limits the step's attempts with the bucket key, shared
+// by every step naming it, e.g. the steps calling an API
+func (s *StepBuilder) SharedRateLimit(key string, rate float64, burst int) *StepBuilder {
+	s.step.RateLimit = &RateLimit{Rate: rate, Burst: burst, Key: key}
+	return s
+}
+
 // Parameter sets step parameter
 func (s *StepBuilder) Parameter(key string, value interface{}) *StepBuilder {
 	s.step.Parameters[key] = value
@@ -0,0 +1,504 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/logger"
+	"neonexcore/pkg/queue"
+	"neonexcore/pkg/scheduler"
+)
+
+var (
+	// ErrDispatchNotFound is returned when loading an unknown step dispatch
+	ErrDispatchNotFound = errors.New("step dispatch not found")
+	// ErrDispatchConflict is returned when a step dispatch changed since
+	// it was loaded
+	ErrDispatchConflict = errors.New("step dispatch changed meanwhile")
+)
+
+// DispatchStatus is where a dispatched step is
+type DispatchStatus string
+
+const (
+	DispatchQueued    DispatchStatus = "queued"    // Published, waiting for a worker
+	DispatchRunning   DispatchStatus = "running"   // Claimed by a worker
+	DispatchCompleted DispatchStatus = "completed" // Ran; the execution is resumed after it
+	DispatchFailed    DispatchStatus = "failed"    // Failed its retries, or too many workers gave it up
+)
+
+// StepDispatch is a task step sent to the worker pool. Its lease is the
+// queue's visibility timeout: a step not claimed, heartbeating or
+// finished by LeaseUntil is dispatched again.
+type StepDispatch struct {
+	ID          string `gorm:"primaryKey"` // <execution>:<step>
+	ExecutionID string `gorm:"index"`
+	WorkflowID  string
+	StepID      string
+	Status      DispatchStatus `gorm:"index"`
+	Attempt     int            // Workers that claimed the step
+	Worker      string         // Running: the worker holding the lease
+	LeaseUntil  time.Time      `gorm:"index"`
+	HeartbeatAt *time.Time
+	Result      string `gorm:"type:text"` // Finished: JSON serialized StepResult
+	Variables   string `gorm:"type:text"` // Finished: JSON serialized variables after the step
+	Version     int64  // Incremented by every UpdateDispatch
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// TableName keeps step dispatches next to the other workflow tables
+func (StepDispatch) TableName() string {
+	return "workflow_step_dispatches"
+}
+
+func dispatchID(executionID, stepID string) string {
+	return executionID + ":" + stepID
+}
+
+// dispatchJob is the queue message of a dispatched step
+type dispatchJob struct {
+	ID string `json:"id"`
+}
+
+// DispatcherConfig configures a StepDispatcher
+type DispatcherConfig struct {
+	// Topic steps are published to (default "workflow.steps")
+	Topic string
+	// Group workers subscribe with, sharing the steps (default
+	// "workflow-workers")
+	Group string
+	// VisibilityTimeout is how long a step may go unclaimed, or a worker
+	// without a heartbeat, before the step is dispatched again (default 1m)
+	VisibilityTimeout time.Duration
+	// HeartbeatInterval is how often a worker extends the lease of a
+	// running step (default a third of VisibilityTimeout)
+	HeartbeatInterval time.Duration
+	// MaxAttempts is how many workers may claim a step before it fails,
+	// counting those that stopped or crashed running it (default 3). Retries
+	// of the step's own RetryPolicy run within one attempt.
+	MaxAttempts int
+}
+
+// DefaultDispatcherConfig returns the default dispatcher configuration
+func DefaultDispatcherConfig() DispatcherConfig {
+	return DispatcherConfig{
+		Topic:             "workflow.steps",
+		Group:             "workflow-workers",
+		VisibilityTimeout: time.Minute,
+		MaxAttempts:       3,
+	}
+}
+
+// StepDispatcher runs the task steps of a stateful engine on a worker pool
+// shared by every instance, instead of in the goroutine of their
+// execution. An execution reaching a task step is saved as paused and the
+// step published to the queue; a worker claims it, runs it and resumes the
+// execution, which goes on to dispatch its next step. Instances that call
+// Work execute steps, and any instance can orchestrate, since executions
+// are resumed from the state store.
+//
+// Every instance must register the same workflows. Steps that a crashed
+// or stopped worker was running are dispatched again by
+// RedispatchExpired, so steps should be idempotent; see StepEffects.
+type StepDispatcher struct {
+	engine *StatefulWorkflowEngine
+	store  StateStore
+	queue  queue.Queue
+	config DispatcherConfig
+	worker string
+}
+
+// NewStepDispatcher attaches a dispatcher to an engine. Task steps of
+// executions started or resumed afterwards are dispatched through q.
+func NewStepDispatcher(engine *StatefulWorkflowEngine, q queue.Queue, config DispatcherConfig) *StepDispatcher {
+	defaults := DefaultDispatcherConfig()
+	if config.Topic == "" {
+		config.Topic = defaults.Topic
+	}
+	if config.Group == "" {
+		config.Group = defaults.Group
+	}
+	if config.VisibilityTimeout <= 0 {
+		config.VisibilityTimeout = defaults.VisibilityTimeout
+	}
+	if config.HeartbeatInterval <= 0 || config.HeartbeatInterval >= config.VisibilityTimeout {
+		config.HeartbeatInterval = config.VisibilityTimeout / 3
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+
+	host, _ := os.Hostname()
+	d := &StepDispatcher{
+		engine: engine,
+		store:  engine.stateStore,
+		queue:  q,
+		config: config,
+		worker: host + "-" + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+	engine.suspend[StepTypeTask] = d.dispatch
+	return d
+}
+
+// Work subscribes this instance to the worker pool. It runs as many steps
+// at once as the queue's concurrency; calling it again adds as many.
+func (d *StepDispatcher) Work() error {
+	return d.queue.Subscribe(d.config.Topic, d.config.Group, d.handle)
+}
+
+// SetScheduler dispatches expired steps again every interval (default
+// the visibility timeout) on s. With a leader check on s, one instance
+// does it.
+func (d *StepDispatcher) SetScheduler(s *scheduler.Scheduler, every time.Duration) error {
+	if every <= 0 {
+		every = d.config.VisibilityTimeout
+	}
+	return s.Replace(scheduler.Job{
+		Name:       "workflow:dispatch",
+		Interval:   every,
+		RunAtStart: true,
+		Run: func(ctx context.Context) error {
+			_, err := d.RedispatchExpired(ctx)
+			return err
+		},
+	})
+}
+
+// dispatch saves an execution reaching a task step as paused and
+// publishes the step, or fails the step if its dispatch failed
+func (d *StepDispatcher) dispatch(execution *Execution, step *Step) (bool, error) {
+	id := dispatchID(execution.ID, step.ID)
+	previous, err := d.store.LoadDispatch(id)
+	if err == nil && previous.Status == DispatchFailed {
+		if err := d.store.DeleteDispatch(id); err != nil {
+			return false, err
+		}
+		return false, previous.failure()
+	}
+
+	now := time.Now()
+	record := &StepDispatch{
+		ID:          id,
+		ExecutionID: execution.ID,
+		WorkflowID:  execution.WorkflowID,
+		StepID:      step.ID,
+		Status:      DispatchQueued,
+		LeaseUntil:  now.Add(d.config.VisibilityTimeout),
+		CreatedAt:   now,
+	}
+	if err := d.store.SaveDispatch(record); err != nil {
+		return false, fmt.Errorf("failed to save step dispatch: %w", err)
+	}
+
+	execution.mu.Lock()
+	execution.Status = StatusPaused
+	execution.mu.Unlock()
+	if err := d.store.SaveState(execution); err != nil {
+		d.store.DeleteDispatch(id)
+		execution.mu.Lock()
+		execution.Status = StatusRunning
+		execution.mu.Unlock()
+		return false, fmt.Errorf("failed to save state: %w", err)
+	}
+
+	d.store.LogEvent(execution.ID, step.ID, "dispatched", "Dispatched to workers", nil)
+	// Published again once the lease expires if this fails
+	d.publish(record)
+	return true, nil
+}
+
+// publish sends a dispatch to the workers
+func (d *StepDispatcher) publish(record *StepDispatch) {
+	payload, _ := json.Marshal(dispatchJob{ID: record.ID})
+	if err := d.queue.Publish(context.Background(), d.config.Topic, payload); err != nil {
+		logger.Warn("Workflow step dispatch failed, retrying after its lease", logger.Fields{
+			"execution_id": record.ExecutionID, "step_id": record.StepID, "error": err.Error(),
+		})
+	}
+}
+
+// handle runs a dispatched step on this worker
+func (d *StepDispatcher) handle(ctx context.Context, msg *queue.Message) error {
+	var job dispatchJob
+	if err := json.Unmarshal(msg.Payload, &job); err != nil || job.ID == "" {
+		return nil // Not worth retrying
+	}
+
+	record, err := d.store.LoadDispatch(job.ID)
+	if errors.Is(err, ErrDispatchNotFound) {
+		return nil // Cancelled, or finished by another worker
+	}
+	if err != nil {
+		return err
+	}
+	// Redelivered while another worker holds it, or already finished
+	now := time.Now()
+	if record.Status != DispatchQueued && !(record.Status == DispatchRunning && !now.Before(record.LeaseUntil)) {
+		return nil
+	}
+
+	workflow, err := d.engine.GetWorkflow(record.WorkflowID)
+	if err != nil {
+		return err // Registered on other instances, or once this one is updated
+	}
+	step := workflow.step(record.StepID)
+	if step == nil {
+		return d.fail(record, fmt.Errorf("workflow %s has no step %s", record.WorkflowID, record.StepID))
+	}
+
+	if record.Attempt >= d.config.MaxAttempts {
+		return d.fail(record, fmt.Errorf("step %s abandoned by %d workers", record.StepID, record.Attempt))
+	}
+	// Published when its lease last started
+	var lag time.Duration
+	if record.Status == DispatchQueued {
+		lag = now.Sub(record.LeaseUntil.Add(-d.config.VisibilityTimeout))
+	}
+	record.Status = DispatchRunning
+	record.Attempt++
+	record.Worker = d.worker
+	record.LeaseUntil = now.Add(d.config.VisibilityTimeout)
+	record.HeartbeatAt = &now
+	if err := d.store.UpdateDispatch(record); err != nil {
+		if errors.Is(err, ErrDispatchConflict) {
+			return nil // Claimed by another worker
+		}
+		return err
+	}
+	if lag > 0 {
+		d.engine.getMetrics().queueLag(record.WorkflowID, lag)
+	}
+
+	d.run(ctx, record, step)
+	return nil
+}
+
+// run executes a claimed step, heartbeating its lease, and finishes it
+func (d *StepDispatcher) run(ctx context.Context, record *StepDispatch, step *Step) {
+	execution, err := d.store.LoadState(record.ExecutionID)
+	if err != nil || execution.Status != StatusPaused || execution.CurrentStep != record.StepID {
+		// Gone, cancelled or resumed by hand meanwhile
+		d.store.DeleteDispatch(record.ID)
+		return
+	}
+	d.store.LogEvent(record.ExecutionID, record.StepID, "step_claimed", "Claimed by worker "+d.worker, map[string]interface{}{
+		"worker":  d.worker,
+		"attempt": record.Attempt,
+	})
+
+	stepCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var lost bool
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		lost = d.heartbeat(stepCtx, record, stop)
+		if lost {
+			cancel()
+		}
+	}()
+
+	result := d.engine.executeStep(stepCtx, step, execution.Context)
+	close(stop)
+	wg.Wait()
+	if lost {
+		logger.Warn("Workflow step lease lost, result discarded", logger.Fields{
+			"execution_id": record.ExecutionID, "step_id": record.StepID, "worker": d.worker,
+		})
+		return
+	}
+	// Stopping: the step is dispatched again once its lease expires
+	if ctx.Err() != nil {
+		return
+	}
+
+	record.Status = DispatchCompleted
+	if result.Error != nil {
+		record.Status = DispatchFailed
+	}
+	if data, err := json.Marshal(result); err == nil {
+		record.Result = string(data)
+	}
+	execution.Context.mu.RLock()
+	if data, err := json.Marshal(execution.Context.Variables); err == nil {
+		record.Variables = string(data)
+	}
+	execution.Context.mu.RUnlock()
+	// Finished by RedispatchExpired if this process stops before it is
+	record.LeaseUntil = time.Now().Add(d.config.VisibilityTimeout)
+	if err := d.store.UpdateDispatch(record); err != nil {
+		logger.Warn("Workflow step result not saved", logger.Fields{
+			"execution_id": record.ExecutionID, "step_id": record.StepID, "error": err.Error(),
+		})
+		return
+	}
+
+	if err := d.finish(record); err != nil {
+		logger.Warn("Workflow execution not resumed after its step, retrying after the lease", logger.Fields{
+			"execution_id": record.ExecutionID, "step_id": record.StepID, "error": err.Error(),
+		})
+	}
+}
+
+// heartbeat extends the lease of a running step until stop is closed,
+// reporting whether the lease was lost to another worker or a cancel
+func (d *StepDispatcher) heartbeat(ctx context.Context, record *StepDispatch, stop <-chan struct{}) bool {
+	ticker := time.NewTicker(d.config.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return false
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			now := time.Now()
+			previous := *record
+			record.LeaseUntil = now.Add(d.config.VisibilityTimeout)
+			record.HeartbeatAt = &now
+			err := d.store.UpdateDispatch(record)
+			if errors.Is(err, ErrDispatchConflict) {
+				return true
+			}
+			if err != nil {
+				// The lease runs on; the next heartbeat may get through
+				*record = previous
+				logger.Warn("Workflow step heartbeat failed", logger.Fields{
+					"execution_id": record.ExecutionID, "step_id": record.StepID, "error": err.Error(),
+				})
+			}
+		}
+	}
+}
+
+// fail records a step as failed without running it, and finishes it
+func (d *StepDispatcher) fail(record *StepDispatch, cause error) error {
+	now := time.Now()
+	result := &StepResult{StepID: record.StepID, Status: StatusFailed, Error: cause, Attempts: record.Attempt, StartedAt: record.CreatedAt, CompletedAt: &now}
+	data, _ := json.Marshal(result)
+	record.Status = DispatchFailed
+	record.Result = string(data)
+	record.LeaseUntil = now.Add(d.config.VisibilityTimeout)
+	if err := d.store.UpdateDispatch(record); err != nil {
+		if errors.Is(err, ErrDispatchConflict) {
+			return nil
+		}
+		return err
+	}
+	return d.finish(record)
+}
+
+// finish resumes the execution of a finished step: after it when it
+// completed, or failing it through dispatch when it failed
+func (d *StepDispatcher) finish(record *StepDispatch) error {
+	execution, err := d.store.LoadState(record.ExecutionID)
+	if errors.Is(err, ErrStateNotFound) {
+		return d.store.DeleteDispatch(record.ID)
+	}
+	if err != nil {
+		return err
+	}
+	// Cancelled or resumed by hand meanwhile
+	if execution.Status != StatusPaused || execution.CurrentStep != record.StepID {
+		return d.store.DeleteDispatch(record.ID)
+	}
+
+	if record.Status == DispatchCompleted {
+		var result StepResult
+		if err := json.Unmarshal([]byte(record.Result), &result); err != nil {
+			return fmt.Errorf("invalid step result: %w", err)
+		}
+		execution.StepResults[record.StepID] = &result
+		if record.Variables != "" {
+			json.Unmarshal([]byte(record.Variables), &execution.Context.Variables)
+		}
+		if err := d.store.SaveState(execution); err != nil {
+			return err
+		}
+	}
+	d.store.LogEvent(record.ExecutionID, record.StepID, "step_"+string(record.Status), "Step "+string(record.Status)+" on a worker", map[string]interface{}{
+		"worker":  record.Worker,
+		"attempt": record.Attempt,
+	})
+
+	if err := d.engine.ResumeExecution(context.Background(), execution.ID); err != nil {
+		return err
+	}
+	if record.Status == DispatchCompleted {
+		return d.store.DeleteDispatch(record.ID)
+	}
+	// Deleted by dispatch, which fails the step
+	return nil
+}
+
+// RedispatchExpired publishes again the steps whose lease expired: those
+// never claimed, e.g. lost with an embedded queue, and those whose worker
+// stopped heartbeating. Steps finished by a worker that could not resume
+// the execution are finished. It returns how many it handled.
+func (d *StepDispatcher) RedispatchExpired(ctx context.Context) (int, error) {
+	records, err := d.store.ExpiredDispatches(time.Now(), 100)
+	if err != nil {
+		return 0, err
+	}
+
+	handled := 0
+	var errs []error
+	for _, record := range records {
+		if ctx.Err() != nil {
+			break
+		}
+
+		status := record.Status
+		record.LeaseUntil = time.Now().Add(d.config.VisibilityTimeout)
+		if status == DispatchRunning {
+			record.Status = DispatchQueued
+			record.Worker = ""
+		}
+		if err := d.store.UpdateDispatch(record); err != nil {
+			if !errors.Is(err, ErrDispatchConflict) {
+				errs = append(errs, fmt.Errorf("dispatch %s: %w", record.ID, err))
+			}
+			continue // Handled by another instance
+		}
+
+		switch status {
+		case DispatchQueued, DispatchRunning:
+			d.publish(record)
+		default:
+			if err := d.finish(record); err != nil {
+				errs = append(errs, fmt.Errorf("dispatch %s: %w", record.ID, err))
+				continue
+			}
+		}
+		handled++
+	}
+	return handled, errors.Join(errs...)
+}
+
+// failure is the error a failed dispatch fails its step with
+func (record *StepDispatch) failure() error {
+	var result StepResult
+	if err := json.Unmarshal([]byte(record.Result), &result); err == nil && result.Error != nil {
+		return result.Error
+	}
+	return fmt.Errorf("step %s failed on a worker", record.StepID)
+}
+
+// step returns a workflow's step by ID, or nil
+func (w *Workflow) step(id string) *Step {
+	for i := range w.Steps {
+		if w.Steps[i].ID == id {
+			return &w.Steps[i]
+		}
+	}
+	return nil
+}
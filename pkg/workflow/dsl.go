@@ -10,25 +10,32 @@ import (
 
 // WorkflowDefinition YAML/JSON workflow definition
 type WorkflowDefinition struct {
-	Name        string                 `yaml:"name" json:"name"`
-	Description string                 `yaml:"description" json:"description"`
-	Version     string                 `yaml:"version" json:"version"`
-	Config      map[string]interface{} `yaml:"config" json:"config"`
-	Steps       []StepDefinition       `yaml:"steps" json:"steps"`
+	Name          string                 `yaml:"name" json:"name"`
+	Description   string                 `yaml:"description" json:"description"`
+	Version       string                 `yaml:"version" json:"version"`
+	Config        map[string]interface{} `yaml:"config" json:"config"`
+	Rollback      bool                   `yaml:"rollback,omitempty" json:"rollback,omitempty"`
+	MaxConcurrent int                    `yaml:"max_concurrent,omitempty" json:"max_concurrent,omitempty"` // Executions running at once
+	Steps         []StepDefinition       `yaml:"steps" json:"steps"`
 }
 
 // StepDefinition YAML/JSON step definition
 type StepDefinition struct {
-	ID         string                 `yaml:"id" json:"id"`
-	Name       string                 `yaml:"name" json:"name"`
-	Type       string                 `yaml:"type" json:"type"`
-	ActionType string                 `yaml:"action_type,omitempty" json:"action_type,omitempty"`
-	OnSuccess  []string               `yaml:"on_success,omitempty" json:"on_success,omitempty"`
-	OnFailure  []string               `yaml:"on_failure,omitempty" json:"on_failure,omitempty"`
-	Timeout    string                 `yaml:"timeout,omitempty" json:"timeout,omitempty"`
-	Retry      *RetryDefinition       `yaml:"retry,omitempty" json:"retry,omitempty"`
-	Parameters map[string]interface{} `yaml:"parameters,omitempty" json:"parameters,omitempty"`
-	Metadata   map[string]string      `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	ID          string                 `yaml:"id" json:"id"`
+	Name        string                 `yaml:"name" json:"name"`
+	Type        string                 `yaml:"type" json:"type"`
+	ActionType  string                 `yaml:"action_type,omitempty" json:"action_type,omitempty"`
+	Compensate  string                 `yaml:"compensate,omitempty" json:"compensate,omitempty"` // Action name
+	OnSuccess   []string               `yaml:"on_success,omitempty" json:"on_success,omitempty"`
+	OnFailure   []string               `yaml:"on_failure,omitempty" json:"on_failure,omitempty"`
+	When        string                 `yaml:"when,omitempty" json:"when,omitempty"`           // Expression; the step is skipped when false
+	Condition   string                 `yaml:"condition,omitempty" json:"condition,omitempty"` // Expression of a condition step
+	Timeout     string                 `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Retry       *RetryDefinition       `yaml:"retry,omitempty" json:"retry,omitempty"`
+	Concurrency int                    `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+	RateLimit   *RateLimitDefinition   `yaml:"rate_limit,omitempty" json:"rate_limit,omitempty"`
+	Parameters  map[string]interface{} `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+	Metadata    map[string]string      `yaml:"metadata,omitempty" json:"metadata,omitempty"`
 }
 
 // RetryDefinition YAML/JSON retry definition
@@ -38,6 +45,13 @@ type RetryDefinition struct {
 	BackoffRate float64 `yaml:"backoff_rate,omitempty" json:"backoff_rate,omitempty"`
 }
 
+// RateLimitDefinition YAML/JSON rate limit definition
+type RateLimitDefinition struct {
+	Rate  float64 `yaml:"rate" json:"rate"` // A second
+	Burst int     `yaml:"burst,omitempty" json:"burst,omitempty"`
+	Key   string  `yaml:"key,omitempty" json:"key,omitempty"`
+}
+
 // FromYAML creates a workflow from YAML
 func FromYAML(data []byte, actionRegistry map[string]ActionFunc) (*Workflow, error) {
 	var def WorkflowDefinition
@@ -61,12 +75,14 @@ func FromJSON(data []byte, actionRegistry map[string]ActionFunc) (*Workflow, err
 // buildWorkflowFromDefinition builds workflow from definition
 func buildWorkflowFromDefinition(def *WorkflowDefinition, actionRegistry map[string]ActionFunc) (*Workflow, error) {
 	workflow := &Workflow{
-		Name:        def.Name,
-		Description: def.Description,
-		Version:     def.Version,
-		Config:      def.Config,
-		Steps:       make([]Step, 0, len(def.Steps)),
-		CreatedAt:   time.Now(),
+		Name:          def.Name,
+		Description:   def.Description,
+		Version:       def.Version,
+		Config:        def.Config,
+		Rollback:      def.Rollback,
+		MaxConcurrent: def.MaxConcurrent,
+		Steps:         make([]Step, 0, len(def.Steps)),
+		CreatedAt:     time.Now(),
 	}
 
 	for _, stepDef := range def.Steps {
@@ -83,13 +99,14 @@ func buildWorkflowFromDefinition(def *WorkflowDefinition, actionRegistry map[str
 // buildStepFromDefinition builds step from definition
 func buildStepFromDefinition(def *StepDefinition, actionRegistry map[string]ActionFunc) (*Step, error) {
 	step := &Step{
-		ID:         def.ID,
-		Name:       def.Name,
-		Type:       StepType(def.Type),
-		OnSuccess:  def.OnSuccess,
-		OnFailure:  def.OnFailure,
-		Parameters: def.Parameters,
-		Metadata:   def.Metadata,
+		ID:          def.ID,
+		Name:        def.Name,
+		Type:        StepType(def.Type),
+		OnSuccess:   def.OnSuccess,
+		OnFailure:   def.OnFailure,
+		Concurrency: def.Concurrency,
+		Parameters:  def.Parameters,
+		Metadata:    def.Metadata,
 	}
 
 	// Parse timeout
@@ -114,6 +131,17 @@ func buildStepFromDefinition(def *StepDefinition, actionRegistry map[string]Acti
 		}
 	}
 
+	if def.RateLimit != nil {
+		if def.RateLimit.Rate <= 0 {
+			return nil, fmt.Errorf("rate limit needs a rate above 0")
+		}
+		step.RateLimit = &RateLimit{
+			Rate:  def.RateLimit.Rate,
+			Burst: def.RateLimit.Burst,
+			Key:   def.RateLimit.Key,
+		}
+	}
+
 	// Get action from registry
 	if def.ActionType != "" && actionRegistry != nil {
 		if action, exists := actionRegistry[def.ActionType]; exists {
@@ -121,6 +149,40 @@ func buildStepFromDefinition(def *StepDefinition, actionRegistry map[string]Acti
 		}
 	}
 
+	if def.Compensate != "" {
+		compensate, exists := actionRegistry[def.Compensate]
+		if !exists {
+			return nil, fmt.Errorf("unknown compensation action: %s", def.Compensate)
+		}
+		step.Compensate = compensate
+	}
+
+	// Compiled now, so a syntax error fails loading rather than the step.
+	// The sources stay in the parameters for exports.
+	if def.When != "" || def.Condition != "" {
+		parameters := make(map[string]interface{}, len(def.Parameters)+2)
+		for key, value := range def.Parameters {
+			parameters[key] = value
+		}
+		step.Parameters = parameters
+	}
+	if def.When != "" {
+		when, err := CompileExpression(def.When)
+		if err != nil {
+			return nil, fmt.Errorf("invalid when: %w", err)
+		}
+		step.When = when.Condition()
+		step.Parameters["when"] = def.When
+	}
+	if def.Condition != "" {
+		condition, err := CompileExpression(def.Condition)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition: %w", err)
+		}
+		step.Condition = condition.Condition()
+		step.Parameters["condition"] = def.Condition
+	}
+
 	return step, nil
 }
 
@@ -139,22 +201,36 @@ func ToJSON(workflow *Workflow) ([]byte, error) {
 // workflowToDefinition converts workflow to definition
 func workflowToDefinition(workflow *Workflow) *WorkflowDefinition {
 	def := &WorkflowDefinition{
-		Name:        workflow.Name,
-		Description: workflow.Description,
-		Version:     workflow.Version,
-		Config:      workflow.Config,
-		Steps:       make([]StepDefinition, 0, len(workflow.Steps)),
+		Name:          workflow.Name,
+		Description:   workflow.Description,
+		Version:       workflow.Version,
+		Config:        workflow.Config,
+		Rollback:      workflow.Rollback,
+		MaxConcurrent: workflow.MaxConcurrent,
+		Steps:         make([]StepDefinition, 0, len(workflow.Steps)),
 	}
 
 	for _, step := range workflow.Steps {
 		stepDef := StepDefinition{
-			ID:         step.ID,
-			Name:       step.Name,
-			Type:       string(step.Type),
-			OnSuccess:  step.OnSuccess,
-			OnFailure:  step.OnFailure,
-			Parameters: step.Parameters,
-			Metadata:   step.Metadata,
+			ID:          step.ID,
+			Name:        step.Name,
+			Type:        string(step.Type),
+			OnSuccess:   step.OnSuccess,
+			OnFailure:   step.OnFailure,
+			Concurrency: step.Concurrency,
+			Parameters:  definitionParameters(step.Parameters),
+			Metadata:    step.Metadata,
+		}
+		if when, ok := stepDef.Parameters["when"].(string); ok {
+			stepDef.When = when
+			delete(stepDef.Parameters, "when")
+		}
+		if condition, ok := stepDef.Parameters["condition"].(string); ok {
+			stepDef.Condition = condition
+			delete(stepDef.Parameters, "condition")
+		}
+		if len(stepDef.Parameters) == 0 {
+			stepDef.Parameters = nil
 		}
 
 		if step.Timeout > 0 {
@@ -169,8 +245,36 @@ func workflowToDefinition(workflow *Workflow) *WorkflowDefinition {
 			}
 		}
 
+		if step.RateLimit != nil {
+			stepDef.RateLimit = &RateLimitDefinition{
+				Rate:  step.RateLimit.Rate,
+				Burst: step.RateLimit.Burst,
+				Key:   step.RateLimit.Key,
+			}
+		}
+
 		def.Steps = append(def.Steps, stepDef)
 	}
 
 	return def
 }
+
+// definitionParameters writes durations and times as the strings wait
+// steps parse back, instead of nanoseconds and YAML timestamps
+func definitionParameters(parameters map[string]interface{}) map[string]interface{} {
+	if parameters == nil {
+		return nil
+	}
+	converted := make(map[string]interface{}, len(parameters))
+	for key, value := range parameters {
+		switch v := value.(type) {
+		case time.Duration:
+			converted[key] = v.String()
+		case time.Time:
+			converted[key] = v.Format(time.RFC3339)
+		default:
+			converted[key] = value
+		}
+	}
+	return converted
+}
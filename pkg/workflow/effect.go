@@ -0,0 +1,161 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrEffectNotFound is returned when loading an effect that was not recorded
+var ErrEffectNotFound = errors.New("workflow effect not found")
+
+// EffectRecord is a side effect a step completed, such as an email sent or
+// a payment charged, kept so a retried or resumed step skips it
+type EffectRecord struct {
+	ID          string `gorm:"primaryKey"` // Effects.Key of the effect
+	ExecutionID string `gorm:"index"`
+	StepID      string
+	Output      string `gorm:"type:text"` // JSON serialized
+	CreatedAt   time.Time
+}
+
+// TableName keeps effects next to the other workflow tables
+func (EffectRecord) TableName() string {
+	return "workflow_effects"
+}
+
+// effectStore records effects; a StateStore, or memoryEffects for an
+// engine without one
+type effectStore interface {
+	SaveEffect(effect *EffectRecord) error
+	LoadEffect(executionID, id string) (*EffectRecord, error)
+}
+
+// memoryEffects keeps the effects of one step run, so they are skipped by
+// its retries but not across a restart
+type memoryEffects struct {
+	mu      sync.Mutex
+	effects map[string]*EffectRecord
+}
+
+func (m *memoryEffects) SaveEffect(effect *EffectRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.effects[effect.ID] = effect
+	return nil
+}
+
+func (m *memoryEffects) LoadEffect(_, id string) (*EffectRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if effect, ok := m.effects[id]; ok {
+		return effect, nil
+	}
+	return nil, ErrEffectNotFound
+}
+
+// Effects runs the side effects of a step once, however often the step is
+// retried or its execution resumed. Steps get theirs from StepEffects:
+//
+//	effects := workflow.StepEffects(ctx)
+//	_, err := effects.Do("welcome-email", func() (interface{}, error) {
+//		return nil, mailer.Send(ctx, welcome)
+//	})
+//
+// A StatefulWorkflowEngine records effects in its state store; a
+// WorkflowEngine only for the retries of one step run.
+type Effects struct {
+	executionID string
+	stepID      string
+	store       effectStore
+}
+
+type effectsKey struct{}
+
+// withEffects returns a context carrying the effects of a step
+func withEffects(ctx context.Context, store effectStore, execCtx *ExecutionContext, stepID string) context.Context {
+	if store == nil {
+		store = &memoryEffects{effects: make(map[string]*EffectRecord)}
+	}
+	return context.WithValue(ctx, effectsKey{}, &Effects{
+		executionID: execCtx.ExecutionID,
+		stepID:      stepID,
+		store:       store,
+	})
+}
+
+// StepEffects returns the effects of the step running with ctx. Outside a
+// step, e.g. when testing an action on its own, Do runs every effect.
+func StepEffects(ctx context.Context) *Effects {
+	if effects, ok := ctx.Value(effectsKey{}).(*Effects); ok {
+		return effects
+	}
+	return &Effects{}
+}
+
+// Key returns the key of the named effect, derived from the execution and
+// step IDs: "<execution>:<step>:<name>", or "<execution>:<step>" for the
+// step's only effect, named "". It is also a good idempotency key for the
+// service the effect calls.
+func (e *Effects) Key(name string) string {
+	key := e.executionID + ":" + e.stepID
+	if name != "" {
+		key += ":" + name
+	}
+	return key
+}
+
+// Do runs fn unless the named effect completed before, in which case it
+// returns the output fn returned then, decoded from JSON. An effect that
+// fails is not recorded, so the step's next attempt runs it again.
+//
+// An effect runs at least once and, unless the process stops between fn
+// returning and the effect being recorded, exactly once; pass Key to
+// services that accept an idempotency key to close that gap.
+func (e *Effects) Do(name string, fn func() (interface{}, error)) (interface{}, error) {
+	if e.store == nil {
+		return fn()
+	}
+
+	key := e.Key(name)
+	recorded, err := e.store.LoadEffect(e.executionID, key)
+	if err == nil {
+		var output interface{}
+		if recorded.Output != "" {
+			if err := json.Unmarshal([]byte(recorded.Output), &output); err != nil {
+				return nil, fmt.Errorf("effect %s: invalid recorded output: %w", key, err)
+			}
+		}
+		return output, nil
+	}
+	if !errors.Is(err, ErrEffectNotFound) {
+		return nil, fmt.Errorf("effect %s: %w", key, err)
+	}
+
+	output, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	effect := &EffectRecord{
+		ID:          key,
+		ExecutionID: e.executionID,
+		StepID:      e.stepID,
+		CreatedAt:   time.Now(),
+	}
+	// Recorded without its output rather than run again
+	data, encodeErr := json.Marshal(output)
+	if encodeErr == nil {
+		effect.Output = string(data)
+	}
+	if err := e.store.SaveEffect(effect); err != nil {
+		return output, fmt.Errorf("effect %s: ran but was not recorded: %w", key, err)
+	}
+	if encodeErr != nil {
+		return output, fmt.Errorf("effect %s: output is not JSON: %w", key, encodeErr)
+	}
+	return output, nil
+}
@@ -0,0 +1,975 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errMissing is returned for references to variables, steps and keys that
+// are not set; has() turns it into false
+var errMissing = errors.New("not set")
+
+// Expression is a compiled expression over an execution's variables and
+// step outputs, for declarative conditions:
+//
+//	${input.amount > 100 && steps.validate.output.valid}
+//
+// References: input and vars are the execution's variables, which start
+// as its input; steps.<id>.output is a completed step's output;
+// metadata, workflow_id and execution_id; a bare name is a variable.
+// Keys are read with .name or ["name"], list items with [i].
+//
+// Operators, loosest first: ?:, ||, &&, == != < <= > >= in, + -, * / %,
+// and unary ! and -. Literals are numbers, 'strings' or "strings", true,
+// false, null and [lists]. Functions: has(ref), len(x), contains(x, y),
+// startsWith(s, prefix), endsWith(s, suffix), matches(s, regexp),
+// lower(s), upper(s), string(x) and number(x).
+//
+// ${ } may wrap the whole expression or parts of it. Numbers compare
+// numerically whatever their Go type; && || ! and conditions take
+// booleans only.
+type Expression struct {
+	source string
+	eval   exprFunc
+}
+
+// exprFunc evaluates a compiled expression node
+type exprFunc func(env *exprEnv) (interface{}, error)
+
+// CompileExpression parses an expression
+func CompileExpression(source string) (*Expression, error) {
+	tokens, err := lexExpression(source)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", source, err)
+	}
+	p := &exprParser{tokens: tokens}
+	eval, err := p.parseTernary()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = p.unexpected()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", source, err)
+	}
+	return &Expression{source: source, eval: eval}, nil
+}
+
+// MustCompileExpression is CompileExpression for expressions known to be
+// valid; it panics otherwise
+func MustCompileExpression(source string) *Expression {
+	expression, err := CompileExpression(source)
+	if err != nil {
+		panic(err)
+	}
+	return expression
+}
+
+// String returns the expression's source
+func (x *Expression) String() string {
+	return x.source
+}
+
+// Eval evaluates the expression against an execution context
+func (x *Expression) Eval(execCtx *ExecutionContext) (interface{}, error) {
+	value, err := x.eval(newExprEnv(execCtx))
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", x.source, err)
+	}
+	return value, nil
+}
+
+// EvalBool evaluates an expression that must be true or false
+func (x *Expression) EvalBool(execCtx *ExecutionContext) (bool, error) {
+	value, err := x.Eval(execCtx)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression %q: expected a boolean, got %s", x.source, typeName(value))
+	}
+	return result, nil
+}
+
+// Condition returns the expression as a condition, for condition steps
+// and Step.When
+func (x *Expression) Condition() ConditionFunc {
+	return x.EvalBool
+}
+
+// exprEnv is what an evaluation reads, copied from the execution context
+type exprEnv struct {
+	variables   map[string]interface{}
+	steps       map[string]interface{}
+	metadata    map[string]interface{}
+	workflowID  string
+	executionID string
+}
+
+func newExprEnv(execCtx *ExecutionContext) *exprEnv {
+	env := &exprEnv{
+		variables: make(map[string]interface{}),
+		steps:     make(map[string]interface{}),
+		metadata:  make(map[string]interface{}),
+	}
+	if execCtx == nil {
+		return env
+	}
+
+	execCtx.mu.RLock()
+	defer execCtx.mu.RUnlock()
+	env.workflowID = execCtx.WorkflowID
+	env.executionID = execCtx.ExecutionID
+	for name, value := range execCtx.Variables {
+		env.variables[name] = value
+	}
+	for stepID, output := range execCtx.StepResults {
+		env.steps[stepID] = map[string]interface{}{"output": output}
+	}
+	for key, value := range execCtx.Metadata {
+		env.metadata[key] = value
+	}
+	return env
+}
+
+// reference resolves a name at the root of a path
+func (env *exprEnv) reference(name string) (interface{}, error) {
+	switch name {
+	case "input", "vars":
+		return env.variables, nil
+	case "steps":
+		return env.steps, nil
+	case "metadata":
+		return env.metadata, nil
+	case "workflow_id":
+		return env.workflowID, nil
+	case "execution_id":
+		return env.executionID, nil
+	}
+	value, exists := env.variables[name]
+	if !exists {
+		return nil, fmt.Errorf("variable %s is %w", name, errMissing)
+	}
+	return normalize(value), nil
+}
+
+// ==================== Lexer ====================
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+// exprOperators are the operator tokens, longest first
+var exprOperators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "+", "-", "*", "/", "%", "!", "(", ")", "[", "]", ".", ",", "?", ":"}
+
+// lexExpression splits an expression into tokens. "${" opens a group and
+// "}" closes it, so ${ } can wrap any part of an expression.
+func lexExpression(source string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(source); {
+		r, size := utf8.DecodeRuneInString(source[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+
+		case strings.HasPrefix(source[i:], "${"):
+			tokens = append(tokens, token{kind: tokenOp, text: "(", pos: i})
+			i += 2
+
+		case r == '}':
+			tokens = append(tokens, token{kind: tokenOp, text: ")", pos: i})
+			i++
+
+		case r >= '0' && r <= '9':
+			start := i
+			for i < len(source) && (source[i] >= '0' && source[i] <= '9' || source[i] == '.' || source[i] == '_') {
+				i++
+			}
+			text := strings.ReplaceAll(source[start:i], "_", "")
+			num, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at %d", source[start:i], start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, num: num, pos: start})
+
+		case r == '\'' || r == '"':
+			start := i
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(source) {
+					return nil, fmt.Errorf("unterminated string at %d", start)
+				}
+				c := source[i]
+				if c == byte(r) {
+					i++
+					break
+				}
+				if c == '\\' && i+1 < len(source) {
+					i++
+					switch source[i] {
+					case 'n':
+						b.WriteByte('\n')
+					case 't':
+						b.WriteByte('\t')
+					default:
+						b.WriteByte(source[i])
+					}
+					i++
+					continue
+				}
+				b.WriteByte(c)
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenString, text: b.String(), pos: start})
+
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(source) {
+				c, n := utf8.DecodeRuneInString(source[i:])
+				if c != '_' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+					break
+				}
+				i += n
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: source[start:i], pos: start})
+
+		default:
+			matched := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(source[i:], op) {
+					tokens = append(tokens, token{kind: tokenOp, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected %q at %d", r, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(source)}), nil
+}
+
+// ==================== Parser ====================
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes an operator if it is next
+func (p *exprParser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokenOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.accept(op) {
+		return fmt.Errorf("expected %q, %w", op, p.unexpected())
+	}
+	return nil
+}
+
+func (p *exprParser) unexpected() error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return fmt.Errorf("unexpected end of expression")
+	}
+	return fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *exprParser) parseTernary() (exprFunc, error) {
+	cond, err := p.parseOr()
+	if err != nil || !p.accept("?") {
+		return cond, err
+	}
+	then, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	return func(env *exprEnv) (interface{}, error) {
+		ok, err := evalBool(env, cond)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return then(env)
+		}
+		return otherwise(env)
+	}, nil
+}
+
+func (p *exprParser) parseOr() (exprFunc, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		var right exprFunc
+		if right, err = p.parseAnd(); err == nil {
+			left = logical(left, right, true)
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) parseAnd() (exprFunc, error) {
+	left, err := p.parseComparison()
+	for err == nil && p.accept("&&") {
+		var right exprFunc
+		if right, err = p.parseComparison(); err == nil {
+			left = logical(left, right, false)
+		}
+	}
+	return left, err
+}
+
+// logical short-circuits: || stops at the first true, && at the first
+// false
+func logical(left, right exprFunc, or bool) exprFunc {
+	return func(env *exprEnv) (interface{}, error) {
+		ok, err := evalBool(env, left)
+		if err != nil || ok == or {
+			return ok, err
+		}
+		return evalBool(env, right)
+	}
+}
+
+func (p *exprParser) parseComparison() (exprFunc, error) {
+	left, err := p.parseAdditive()
+	for err == nil {
+		t := p.peek()
+		op := t.text
+		isComparison := t.kind == tokenOp && (op == "==" || op == "!=" || op == "<" || op == "<=" || op == ">" || op == ">=")
+		if !isComparison && !(t.kind == tokenIdent && op == "in") {
+			break
+		}
+		p.next()
+		var right exprFunc
+		if right, err = p.parseAdditive(); err == nil {
+			left = binary(left, right, func(a, b interface{}) (interface{}, error) { return compare(op, a, b) })
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) parseAdditive() (exprFunc, error) {
+	left, err := p.parseMultiplicative()
+	for err == nil {
+		t := p.peek()
+		if t.kind != tokenOp || (t.text != "+" && t.text != "-") {
+			break
+		}
+		p.next()
+		var right exprFunc
+		if right, err = p.parseMultiplicative(); err == nil {
+			left = binary(left, right, arithmetic(t.text))
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) parseMultiplicative() (exprFunc, error) {
+	left, err := p.parseUnary()
+	for err == nil {
+		t := p.peek()
+		if t.kind != tokenOp || (t.text != "*" && t.text != "/" && t.text != "%") {
+			break
+		}
+		p.next()
+		var right exprFunc
+		if right, err = p.parseUnary(); err == nil {
+			left = binary(left, right, arithmetic(t.text))
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) parseUnary() (exprFunc, error) {
+	switch {
+	case p.accept("!"):
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *exprEnv) (interface{}, error) {
+			ok, err := evalBool(env, operand)
+			return !ok, err
+		}, nil
+	case p.accept("-"):
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *exprEnv) (interface{}, error) {
+			value, err := operand(env)
+			if err != nil {
+				return nil, err
+			}
+			n, ok := value.(float64)
+			if !ok {
+				return nil, fmt.Errorf("cannot negate %s", typeName(value))
+			}
+			return -n, nil
+		}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (exprFunc, error) {
+	operand, err := p.parsePrimary()
+	for err == nil {
+		switch {
+		case p.accept("."):
+			t := p.next()
+			if t.kind != tokenIdent {
+				return nil, fmt.Errorf("expected a name after \".\" at %d", t.pos)
+			}
+			operand = member(operand, constant(t.text))
+		case p.accept("["):
+			var key exprFunc
+			if key, err = p.parseTernary(); err == nil {
+				err = p.expect("]")
+			}
+			operand = member(operand, key)
+		default:
+			return operand, nil
+		}
+	}
+	return nil, err
+}
+
+func (p *exprParser) parsePrimary() (exprFunc, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		return constant(t.num), nil
+	case tokenString:
+		return constant(t.text), nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return constant(true), nil
+		case "false":
+			return constant(false), nil
+		case "null", "nil":
+			return constant(nil), nil
+		}
+		if p.accept("(") {
+			return p.parseCall(t)
+		}
+		name := t.text
+		return func(env *exprEnv) (interface{}, error) { return env.reference(name) }, nil
+	case tokenOp:
+		switch t.text {
+		case "(":
+			inner, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		case "[":
+			items, err := p.parseArguments("]")
+			if err != nil {
+				return nil, err
+			}
+			return func(env *exprEnv) (interface{}, error) {
+				list := make([]interface{}, len(items))
+				for i, item := range items {
+					value, err := item(env)
+					if err != nil {
+						return nil, err
+					}
+					list[i] = value
+				}
+				return list, nil
+			}, nil
+		}
+	}
+	p.pos--
+	return nil, p.unexpected()
+}
+
+// parseArguments parses comma separated expressions up to end
+func (p *exprParser) parseArguments(end string) ([]exprFunc, error) {
+	var args []exprFunc
+	if p.accept(end) {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.accept(end) {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *exprParser) parseCall(name token) (exprFunc, error) {
+	args, err := p.parseArguments(")")
+	if err != nil {
+		return nil, err
+	}
+
+	if name.text == "has" {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("has takes one reference")
+		}
+		return func(env *exprEnv) (interface{}, error) {
+			_, err := args[0](env)
+			if errors.Is(err, errMissing) {
+				return false, nil
+			}
+			return err == nil, err
+		}, nil
+	}
+
+	fn, exists := exprFunctions[name.text]
+	if !exists {
+		return nil, fmt.Errorf("unknown function %s at %d", name.text, name.pos)
+	}
+	if len(args) != fn.args {
+		if fn.args == 1 {
+			return nil, fmt.Errorf("%s takes one argument", name.text)
+		}
+		return nil, fmt.Errorf("%s takes %d arguments", name.text, fn.args)
+	}
+	return func(env *exprEnv) (interface{}, error) {
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			value, err := arg(env)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		result, err := fn.call(values)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name.text, err)
+		}
+		return result, nil
+	}, nil
+}
+
+// ==================== Evaluation ====================
+
+func constant(value interface{}) exprFunc {
+	return func(*exprEnv) (interface{}, error) { return value, nil }
+}
+
+func binary(left, right exprFunc, op func(a, b interface{}) (interface{}, error)) exprFunc {
+	return func(env *exprEnv) (interface{}, error) {
+		a, err := left(env)
+		if err != nil {
+			return nil, err
+		}
+		b, err := right(env)
+		if err != nil {
+			return nil, err
+		}
+		return op(a, b)
+	}
+}
+
+func evalBool(env *exprEnv, eval exprFunc) (bool, error) {
+	value, err := eval(env)
+	if err != nil {
+		return false, err
+	}
+	ok, isBool := value.(bool)
+	if !isBool {
+		return false, fmt.Errorf("expected a boolean, got %s", typeName(value))
+	}
+	return ok, nil
+}
+
+// member reads a map key or list item
+func member(operand, key exprFunc) exprFunc {
+	return func(env *exprEnv) (interface{}, error) {
+		container, err := operand(env)
+		if err != nil {
+			return nil, err
+		}
+		k, err := key(env)
+		if err != nil {
+			return nil, err
+		}
+
+		switch c := container.(type) {
+		case map[string]interface{}:
+			name, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("map keys are strings, got %s", typeName(k))
+			}
+			value, exists := c[name]
+			if !exists {
+				return nil, fmt.Errorf("key %s is %w", name, errMissing)
+			}
+			return normalize(value), nil
+		case []interface{}:
+			n, ok := k.(float64)
+			if !ok || n != math.Trunc(n) {
+				return nil, fmt.Errorf("list indexes are integers, got %v", k)
+			}
+			if n < 0 || int(n) >= len(c) {
+				return nil, fmt.Errorf("index %d is %w", int(n), errMissing)
+			}
+			return normalize(c[int(n)]), nil
+		case nil:
+			return nil, fmt.Errorf("key %v of null is %w", k, errMissing)
+		default:
+			return nil, fmt.Errorf("cannot read %v of %s", k, typeName(container))
+		}
+	}
+}
+
+func compare(op string, a, b interface{}) (interface{}, error) {
+	switch op {
+	case "==":
+		return equal(a, b), nil
+	case "!=":
+		return !equal(a, b), nil
+	case "in":
+		switch c := b.(type) {
+		case []interface{}:
+			for _, item := range c {
+				if equal(a, normalize(item)) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]interface{}:
+			key, ok := a.(string)
+			if !ok {
+				return false, nil
+			}
+			_, exists := c[key]
+			return exists, nil
+		case string:
+			s, ok := a.(string)
+			if !ok {
+				return nil, fmt.Errorf("cannot look for %s in a string", typeName(a))
+			}
+			return strings.Contains(c, s), nil
+		default:
+			return nil, fmt.Errorf("cannot look in %s", typeName(b))
+		}
+	}
+
+	var order int
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare number with %s", typeName(b))
+		}
+		order = compareOrdered(x, y)
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string with %s", typeName(b))
+		}
+		order = strings.Compare(x, y)
+	default:
+		return nil, fmt.Errorf("cannot order %s", typeName(a))
+	}
+	switch op {
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	default:
+		return order >= 0, nil
+	}
+}
+
+func compareOrdered(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(deepNormalize(a), deepNormalize(b))
+}
+
+func arithmetic(op string) func(a, b interface{}) (interface{}, error) {
+	return func(a, b interface{}) (interface{}, error) {
+		if op == "+" {
+			switch x := a.(type) {
+			case string:
+				if y, ok := b.(string); ok {
+					return x + y, nil
+				}
+			case []interface{}:
+				if y, ok := b.([]interface{}); ok {
+					return append(append([]interface{}{}, x...), y...), nil
+				}
+			}
+		}
+
+		x, xok := a.(float64)
+		y, yok := b.(float64)
+		if !xok || !yok {
+			return nil, fmt.Errorf("cannot apply %s to %s and %s", op, typeName(a), typeName(b))
+		}
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "/":
+			if y == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return x / y, nil
+		default:
+			if y == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return math.Mod(x, y), nil
+		}
+	}
+}
+
+// exprFunction is a function expressions may call
+type exprFunction struct {
+	args int
+	call func(args []interface{}) (interface{}, error)
+}
+
+var exprFunctions = map[string]exprFunction{
+	"len": {1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case string:
+			return float64(utf8.RuneCountInString(v)), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		case nil:
+			return float64(0), nil
+		}
+		return nil, fmt.Errorf("no length of %s", typeName(args[0]))
+	}},
+	"contains": {2, func(args []interface{}) (interface{}, error) {
+		if s, ok := args[0].(string); ok {
+			sub, ok := args[1].(string)
+			if !ok {
+				return nil, fmt.Errorf("cannot look for %s in a string", typeName(args[1]))
+			}
+			return strings.Contains(s, sub), nil
+		}
+		return compare("in", args[1], args[0])
+	}},
+	"startsWith": {2, stringFunction(func(s, prefix string) interface{} { return strings.HasPrefix(s, prefix) })},
+	"endsWith":   {2, stringFunction(func(s, suffix string) interface{} { return strings.HasSuffix(s, suffix) })},
+	"matches": {2, func(args []interface{}) (interface{}, error) {
+		s, sok := args[0].(string)
+		pattern, pok := args[1].(string)
+		if !sok || !pok {
+			return nil, fmt.Errorf("expected strings")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	}},
+	"lower": {1, stringFunction(func(s, _ string) interface{} { return strings.ToLower(s) })},
+	"upper": {1, stringFunction(func(s, _ string) interface{} { return strings.ToUpper(s) })},
+	"string": {1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case nil:
+			return "", nil
+		}
+		return fmt.Sprint(args[0]), nil
+	}},
+	"number": {1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case float64:
+			return v, nil
+		case string:
+			return strconv.ParseFloat(strings.TrimSpace(v), 64)
+		case bool:
+			if v {
+				return float64(1), nil
+			}
+			return float64(0), nil
+		}
+		return nil, fmt.Errorf("cannot convert %s to a number", typeName(args[0]))
+	}},
+}
+
+// stringFunction adapts a function of one or two strings
+func stringFunction(fn func(s, arg string) interface{}) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		strs := make([]string, 2)
+		for i, arg := range args {
+			s, ok := arg.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a string, got %s", typeName(arg))
+			}
+			strs[i] = s
+		}
+		return fn(strs[0], strs[1]), nil
+	}
+}
+
+// normalize converts a Go value to the types expressions work with:
+// float64, string, bool, nil, []interface{} and map[string]interface{}.
+// Structs and other values are converted as encoding/json would.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, bool, string, float64, []interface{}, map[string]interface{}:
+		return v
+	case int:
+		return float64(v)
+	case int8:
+		return float64(v)
+	case int16:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case json.Number:
+		n, err := v.Float64()
+		if err != nil {
+			return v.String()
+		}
+		return n
+	case map[string]string:
+		converted := make(map[string]interface{}, len(v))
+		for key, s := range v {
+			converted[key] = s
+		}
+		return converted
+	case []string:
+		converted := make([]interface{}, len(v))
+		for i, s := range v {
+			converted[i] = s
+		}
+		return converted
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var converted interface{}
+	if err := json.Unmarshal(data, &converted); err != nil {
+		return value
+	}
+	return converted
+}
+
+// deepNormalize normalizes a value and everything in it, for equality
+func deepNormalize(value interface{}) interface{} {
+	switch v := normalize(value).(type) {
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, item := range v {
+			converted[i] = deepNormalize(item)
+		}
+		return converted
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[key] = deepNormalize(item)
+		}
+		return converted
+	default:
+		return v
+	}
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,298 @@
+package workflow
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// EdgeKind is why one step follows another in a workflow graph
+type EdgeKind string
+
+const (
+	EdgeNext    EdgeKind = "next"    // The following step, for steps without OnSuccess
+	EdgeSuccess EdgeKind = "success" // OnSuccess
+	EdgeFailure EdgeKind = "failure" // OnFailure
+)
+
+// Graph is a workflow definition as a directed graph of its steps, for
+// dashboards. Overlay colours it with an execution's progress.
+type Graph struct {
+	WorkflowID  string      `json:"workflow_id"`
+	Name        string      `json:"name"`
+	Version     string      `json:"version,omitempty"`
+	ExecutionID string      `json:"execution_id,omitempty"` // Set by Overlay
+	Nodes       []GraphNode `json:"nodes"`
+	Edges       []GraphEdge `json:"edges"`
+}
+
+// GraphNode is a step of a workflow graph
+type GraphNode struct {
+	ID           string         `json:"id"`
+	Name         string         `json:"name"`
+	Type         StepType       `json:"type"`
+	Start        bool           `json:"start,omitempty"`
+	End          bool           `json:"end,omitempty"`
+	MaxAttempts  int            `json:"max_attempts,omitempty"`
+	TimeoutMS    int64          `json:"timeout_ms,omitempty"`
+	Compensated  bool           `json:"compensated,omitempty"` // Has a compensation
+	SubWorkflow  string         `json:"sub_workflow,omitempty"`
+	Signal       string         `json:"signal,omitempty"`
+	Status       WorkflowStatus `json:"status,omitempty"` // Set by Overlay
+	Attempts     int            `json:"attempts,omitempty"`
+	DurationMS   int64          `json:"duration_ms,omitempty"`
+	Current      bool           `json:"current,omitempty"`
+	Unregistered bool           `json:"unregistered,omitempty"` // Named by an edge but not a step
+}
+
+// GraphEdge is a transition between steps
+type GraphEdge struct {
+	From string   `json:"from"`
+	To   string   `json:"to"`
+	Kind EdgeKind `json:"kind"`
+}
+
+// BuildGraph returns the graph of a workflow. Steps run in order, so a
+// step without OnSuccess leads to the one after it; OnSuccess and
+// OnFailure add their own edges.
+func BuildGraph(workflow *Workflow) *Graph {
+	graph := &Graph{
+		WorkflowID: workflow.ID,
+		Name:       workflow.Name,
+		Version:    workflow.Version,
+		Nodes:      make([]GraphNode, 0, len(workflow.Steps)),
+		Edges:      make([]GraphEdge, 0, len(workflow.Steps)),
+	}
+
+	known := make(map[string]bool, len(workflow.Steps))
+	for _, step := range workflow.Steps {
+		known[step.ID] = true
+	}
+	targets := make(map[string]bool)
+	var missing []string
+	edge := func(from, to string, kind EdgeKind) {
+		graph.Edges = append(graph.Edges, GraphEdge{From: from, To: to, Kind: kind})
+		targets[to] = true
+		if !known[to] {
+			known[to] = true
+			missing = append(missing, to)
+		}
+	}
+
+	for i, step := range workflow.Steps {
+		node := GraphNode{
+			ID:          step.ID,
+			Name:        step.Name,
+			Type:        step.Type,
+			Compensated: step.Compensate != nil,
+			TimeoutMS:   step.Timeout.Milliseconds(),
+		}
+		if node.Name == "" {
+			node.Name = step.ID
+		}
+		if step.RetryPolicy != nil {
+			node.MaxAttempts = step.RetryPolicy.MaxAttempts
+		}
+		switch step.Type {
+		case StepTypeSubWorkflow, StepTypeSubflow:
+			node.SubWorkflow, _ = step.Parameters["workflow_id"].(string)
+		case StepTypeWaitForSignal:
+			node.Signal, _ = step.Parameters["signal"].(string)
+		}
+		graph.Nodes = append(graph.Nodes, node)
+
+		for _, next := range step.OnSuccess {
+			edge(step.ID, next, EdgeSuccess)
+		}
+		if len(step.OnSuccess) == 0 && i+1 < len(workflow.Steps) {
+			edge(step.ID, workflow.Steps[i+1].ID, EdgeNext)
+		}
+		for _, next := range step.OnFailure {
+			edge(step.ID, next, EdgeFailure)
+		}
+	}
+
+	for _, id := range missing {
+		graph.Nodes = append(graph.Nodes, GraphNode{ID: id, Name: id, Unregistered: true})
+	}
+
+	outgoing := make(map[string]bool, len(graph.Edges))
+	for _, e := range graph.Edges {
+		outgoing[e.From] = true
+	}
+	for i := range graph.Nodes {
+		node := &graph.Nodes[i]
+		node.Start = i == 0 || (!targets[node.ID] && !node.Unregistered)
+		node.End = !outgoing[node.ID]
+	}
+	return graph
+}
+
+// Overlay marks the graph's nodes with the progress of an execution of
+// its workflow, as in its timeline
+func (g *Graph) Overlay(timeline *Timeline) {
+	g.ExecutionID = timeline.ExecutionID
+	steps := make(map[string]TimelineStep, len(timeline.Steps))
+	for _, step := range timeline.Steps {
+		steps[step.StepID] = step
+	}
+	for i := range g.Nodes {
+		node := &g.Nodes[i]
+		step, ok := steps[node.ID]
+		if !ok {
+			continue
+		}
+		node.Status = step.Status
+		node.Attempts = step.Attempts
+		node.DurationMS = step.DurationMS
+		node.Current = node.ID == timeline.CurrentStep && !timeline.finished()
+	}
+}
+
+// DOT renders the graph in Graphviz's DOT language. Failure edges are
+// dashed red; overlaid nodes are filled by status.
+func (g *Graph) DOT() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(g.WorkflowID))
+	b.WriteString("  rankdir=TB;\n")
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fillcolor=white, fontname=Helvetica];\n")
+	for _, node := range g.Nodes {
+		attrs := []string{"label=" + dotQuote(nodeLabel(node, "\\n"))}
+		switch node.Type {
+		case StepTypeCondition:
+			attrs = append(attrs, "shape=diamond")
+		case StepTypeWait, StepTypeWaitForSignal, StepTypeHumanTask:
+			attrs = append(attrs, "shape=ellipse")
+		case StepTypeSubWorkflow, StepTypeSubflow:
+			attrs = append(attrs, "peripheries=2")
+		}
+		if node.Unregistered {
+			attrs = append(attrs, "style=dashed")
+		}
+		if color := statusColors[node.Status]; color != "" {
+			attrs = append(attrs, "fillcolor="+dotQuote(color))
+		}
+		if node.Current {
+			attrs = append(attrs, "penwidth=3")
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(node.ID), strings.Join(attrs, ", "))
+	}
+	for _, edge := range g.Edges {
+		attrs := ""
+		switch edge.Kind {
+		case EdgeFailure:
+			attrs = " [label=\"failure\", color=red, style=dashed]"
+		case EdgeSuccess:
+			attrs = " [label=\"success\", color=darkgreen]"
+		}
+		fmt.Fprintf(&b, "  %s -> %s%s;\n", dotQuote(edge.From), dotQuote(edge.To), attrs)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart
+func (g *Graph) Mermaid() string {
+	ids := make(map[string]string, len(g.Nodes))
+	for i, node := range g.Nodes {
+		ids[node.ID] = fmt.Sprintf("s%d", i)
+	}
+
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	for _, node := range g.Nodes {
+		label := mermaidQuote(nodeLabel(node, "<br/>"))
+		switch node.Type {
+		case StepTypeCondition:
+			fmt.Fprintf(&b, "  %s{%s}\n", ids[node.ID], label)
+		case StepTypeWait, StepTypeWaitForSignal, StepTypeHumanTask:
+			fmt.Fprintf(&b, "  %s([%s])\n", ids[node.ID], label)
+		case StepTypeSubWorkflow, StepTypeSubflow:
+			fmt.Fprintf(&b, "  %s[[%s]]\n", ids[node.ID], label)
+		default:
+			fmt.Fprintf(&b, "  %s[%s]\n", ids[node.ID], label)
+		}
+	}
+	for _, edge := range g.Edges {
+		switch edge.Kind {
+		case EdgeFailure:
+			fmt.Fprintf(&b, "  %s -. failure .-> %s\n", ids[edge.From], ids[edge.To])
+		case EdgeSuccess:
+			fmt.Fprintf(&b, "  %s -- success --> %s\n", ids[edge.From], ids[edge.To])
+		default:
+			fmt.Fprintf(&b, "  %s --> %s\n", ids[edge.From], ids[edge.To])
+		}
+	}
+	if g.ExecutionID != "" {
+		for _, status := range overlaidStatuses {
+			fmt.Fprintf(&b, "  classDef %s fill:%s\n", status, statusColors[status])
+		}
+	}
+	for _, node := range g.Nodes {
+		if statusColors[node.Status] != "" {
+			fmt.Fprintf(&b, "  class %s %s\n", ids[node.ID], node.Status)
+		}
+		if node.Current {
+			fmt.Fprintf(&b, "  style %s stroke-width:3px\n", ids[node.ID])
+		}
+	}
+	return b.String()
+}
+
+// overlaidStatuses are the statuses with colours, in the order Mermaid
+// class definitions are written
+var overlaidStatuses = []WorkflowStatus{StatusRunning, StatusPaused, StatusCompleted, StatusFailed, StatusCancelled, StatusCompensating, StatusSkipped}
+
+// statusColors fill overlaid nodes in DOT and Mermaid
+var statusColors = map[WorkflowStatus]string{
+	StatusRunning:      "#bfdbfe",
+	StatusPaused:       "#fde68a",
+	StatusCompleted:    "#bbf7d0",
+	StatusFailed:       "#fecaca",
+	StatusCancelled:    "#e5e7eb",
+	StatusCompensating: "#fed7aa",
+	StatusSkipped:      "#f3f4f6",
+}
+
+// nodeLabel is a node's name, type and overlaid status on lines joined
+// by sep
+func nodeLabel(node GraphNode, sep string) string {
+	lines := []string{node.Name}
+	if node.Type != "" && node.Type != StepTypeTask {
+		kind := string(node.Type)
+		if node.SubWorkflow != "" {
+			kind += ": " + node.SubWorkflow
+		} else if node.Signal != "" {
+			kind += ": " + node.Signal
+		}
+		lines = append(lines, kind)
+	}
+	if node.Status != "" {
+		status := string(node.Status)
+		if node.Attempts > 1 {
+			status += fmt.Sprintf(", %d attempts", node.Attempts)
+		}
+		if node.DurationMS > 0 {
+			status += " " + (time.Duration(node.DurationMS) * time.Millisecond).String()
+		}
+		lines = append(lines, status)
+	}
+	return strings.Join(lines, sep)
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+var mermaidUnsafe = regexp.MustCompile(`["\[\]{}()<>|]`)
+
+// mermaidQuote quotes a label, dropping characters Mermaid would parse,
+// except the line breaks nodeLabel adds
+func mermaidQuote(s string) string {
+	parts := strings.Split(s, "<br/>")
+	for i, part := range parts {
+		parts[i] = mermaidUnsafe.ReplaceAllString(part, "")
+	}
+	return `"` + strings.Join(parts, "<br/>") + `"`
+}
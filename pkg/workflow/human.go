@@ -0,0 +1,532 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTaskNotFound is returned when loading an unknown human task
+	ErrTaskNotFound = errors.New("human task not found")
+	// ErrTaskClosed is returned when acting on a completed or cancelled task
+	ErrTaskClosed = errors.New("human task is closed")
+	// ErrTaskClaimed is returned when a task is claimed by another user
+	ErrTaskClaimed = errors.New("human task is claimed by another user")
+	// ErrNotAssignee is returned when a task is assigned to another user
+	ErrNotAssignee = errors.New("human task is assigned to another user")
+)
+
+// TaskStatus is the state of a human task
+type TaskStatus string
+
+const (
+	TaskOpen      TaskStatus = "open"      // Waiting for someone to claim or complete it
+	TaskClaimed   TaskStatus = "claimed"   // Being worked on by ClaimedBy
+	TaskCompleted TaskStatus = "completed" // Decided; the execution went on
+	TaskCancelled TaskStatus = "cancelled" // The execution was cancelled
+)
+
+// HumanTask is a persisted task of a human task step. The execution is
+// paused until someone completes it with a decision, which becomes the
+// step's output.
+type HumanTask struct {
+	ID          string `gorm:"primaryKey"`
+	WorkflowID  string `gorm:"index"`
+	ExecutionID string `gorm:"index"`
+	StepID      string
+	Name        string
+	Description string     `gorm:"type:text"`
+	Assignee    string     `gorm:"index"`     // Empty: anyone may claim it
+	Form        string     `gorm:"type:text"` // JSON schema of the decision, may be empty
+	Status      TaskStatus `gorm:"index"`
+	ClaimedBy   string     `gorm:"index"`
+	ClaimedAt   *time.Time
+	Decision    string `gorm:"type:text"` // JSON decision payload, once completed
+	CompletedBy string
+	CompletedAt *time.Time
+	DueAt       *time.Time
+	CreatedAt   time.Time
+}
+
+// TableName keeps tasks next to the other workflow tables
+func (HumanTask) TableName() string {
+	return "workflow_tasks"
+}
+
+// Open reports whether the task still waits for a decision
+func (t *HumanTask) Open() bool {
+	return t.Status == TaskOpen || t.Status == TaskClaimed
+}
+
+// Overdue reports whether an open task is past its due date
+func (t *HumanTask) Overdue(now time.Time) bool {
+	return t.Open() && t.DueAt != nil && now.After(*t.DueAt)
+}
+
+// ActionableBy reports whether user may claim or complete the task
+func (t *HumanTask) ActionableBy(user string) bool {
+	if !t.Open() || (t.Assignee != "" && t.Assignee != user) {
+		return false
+	}
+	return t.Status == TaskOpen || t.ClaimedBy == user
+}
+
+// FormSchema returns the JSON schema the decision must match, or nil
+func (t *HumanTask) FormSchema() (map[string]interface{}, error) {
+	if t.Form == "" {
+		return nil, nil
+	}
+	var form map[string]interface{}
+	if err := json.Unmarshal([]byte(t.Form), &form); err != nil {
+		return nil, fmt.Errorf("invalid task form: %w", err)
+	}
+	return form, nil
+}
+
+// DecisionPayload returns the decision of a completed task
+func (t *HumanTask) DecisionPayload() (map[string]interface{}, error) {
+	if t.Decision == "" {
+		return nil, nil
+	}
+	var decision map[string]interface{}
+	if err := json.Unmarshal([]byte(t.Decision), &decision); err != nil {
+		return nil, fmt.Errorf("invalid task decision: %w", err)
+	}
+	return decision, nil
+}
+
+// TaskFilter selects human tasks; empty fields match any task
+type TaskFilter struct {
+	WorkflowID  string
+	ExecutionID string
+	Assignee    string
+	Statuses    []TaskStatus
+	Limit       int
+}
+
+// matches reports whether a task matches the filter, for stores that
+// cannot query every field
+func (f TaskFilter) matches(task *HumanTask) bool {
+	if f.WorkflowID != "" && task.WorkflowID != f.WorkflowID {
+		return false
+	}
+	if f.ExecutionID != "" && task.ExecutionID != f.ExecutionID {
+		return false
+	}
+	if f.Assignee != "" && task.Assignee != f.Assignee {
+		return false
+	}
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	for _, status := range f.Statuses {
+		if task.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// openStatuses are the statuses of tasks waiting for a decision
+var openStatuses = []TaskStatus{TaskOpen, TaskClaimed}
+
+func humanTaskID(executionID, stepID string) string {
+	return executionID + ":" + stepID
+}
+
+// FormError is returned when a decision does not match a task's form
+type FormError struct {
+	Violations []string
+}
+
+func (e *FormError) Error() string {
+	return "invalid decision: " + strings.Join(e.Violations, "; ")
+}
+
+// TaskService pauses a stateful engine's executions at human task steps
+// until a person decides. An execution reaching one is saved as paused
+// with a HumanTask in the engine's state store; completing the task
+// records the decision as the step's output and resumes the execution,
+// in whichever process handles the request. Step parameters:
+//
+//	assignee     user ID, or "$variable" naming an execution variable
+//	             holding one; empty lets anyone claim the task
+//	description  shown to the assignee
+//	form         JSON schema of the decision
+//	due          time.Duration or a string such as "48h", from creation
+type TaskService struct {
+	engine *StatefulWorkflowEngine
+	store  StateStore
+	mu     sync.Mutex // Serializes claims and completions in this process
+}
+
+// NewTaskService attaches a task service to an engine. Human task steps
+// of executions started afterwards pause the execution until completed.
+func NewTaskService(engine *StatefulWorkflowEngine) *TaskService {
+	s := &TaskService{engine: engine, store: engine.stateStore}
+	engine.suspend[StepTypeHumanTask] = s.assign
+	return s
+}
+
+// Get returns a task
+func (s *TaskService) Get(taskID string) (*HumanTask, error) {
+	return s.store.LoadTask(taskID)
+}
+
+// List lists tasks matching a filter, oldest first
+func (s *TaskService) List(filter TaskFilter) ([]*HumanTask, error) {
+	return s.store.ListTasks(filter)
+}
+
+// Inbox lists the open tasks user may act on: those assigned to them,
+// unassigned ones nobody claimed and those they claimed, by due date
+func (s *TaskService) Inbox(user string) ([]*HumanTask, error) {
+	tasks, err := s.store.ListTasks(TaskFilter{Statuses: openStatuses})
+	if err != nil {
+		return nil, err
+	}
+	inbox := make([]*HumanTask, 0, len(tasks))
+	for _, task := range tasks {
+		if task.ActionableBy(user) {
+			inbox = append(inbox, task)
+		}
+	}
+	// Tasks without a due date last, each group oldest first
+	sort.SliceStable(inbox, func(i, j int) bool {
+		a, b := inbox[i].DueAt, inbox[j].DueAt
+		return a != nil && (b == nil || a.Before(*b))
+	})
+	return inbox, nil
+}
+
+// Claim marks a task as being worked on by user, so others cannot
+// complete it. Claiming a task again is not an error.
+func (s *TaskService) Claim(taskID, user string) (*HumanTask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, err := s.openTask(taskID, user)
+	if err != nil {
+		return nil, err
+	}
+	if task.Status == TaskClaimed {
+		return task, nil
+	}
+
+	now := time.Now()
+	task.Status, task.ClaimedBy, task.ClaimedAt = TaskClaimed, user, &now
+	if err := s.store.SaveTask(task); err != nil {
+		return nil, err
+	}
+	s.store.LogEvent(task.ExecutionID, task.StepID, "task_claimed", "Task claimed by "+user, map[string]interface{}{
+		"task_id": task.ID,
+	})
+	return task, nil
+}
+
+// Release gives up user's claim on a task
+func (s *TaskService) Release(taskID, user string) (*HumanTask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, err := s.openTask(taskID, user)
+	if err != nil {
+		return nil, err
+	}
+	if task.Status == TaskOpen {
+		return task, nil
+	}
+
+	task.Status, task.ClaimedBy, task.ClaimedAt = TaskOpen, "", nil
+	if err := s.store.SaveTask(task); err != nil {
+		return nil, err
+	}
+	s.store.LogEvent(task.ExecutionID, task.StepID, "task_released", "Task released by "+user, map[string]interface{}{
+		"task_id": task.ID,
+	})
+	return task, nil
+}
+
+// Complete records user's decision and resumes the execution after the
+// task's step, which outputs the decision. A decision not matching the
+// task's form returns a *FormError.
+func (s *TaskService) Complete(taskID, user string, decision map[string]interface{}) (*HumanTask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, err := s.openTask(taskID, user)
+	if err != nil {
+		return nil, err
+	}
+	if decision == nil {
+		decision = make(map[string]interface{})
+	}
+	form, err := task.FormSchema()
+	if err != nil {
+		return nil, err
+	}
+	if violations := validateDecision(form, decision); len(violations) > 0 {
+		return nil, &FormError{Violations: violations}
+	}
+	data, err := json.Marshal(decision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode decision: %w", err)
+	}
+
+	execution, err := s.store.LoadState(task.ExecutionID)
+	if err != nil && !errors.Is(err, ErrStateNotFound) {
+		return nil, err
+	}
+	// Cancelled or moved on meanwhile
+	if err != nil || execution.Status != StatusPaused || execution.CurrentStep != task.StepID {
+		task.Status = TaskCancelled
+		s.store.SaveTask(task)
+		return nil, ErrTaskClosed
+	}
+
+	// Decision first: a resume that fails leaves the task open to retry
+	now := time.Now()
+	execution.StepResults[task.StepID] = &StepResult{
+		StepID:      task.StepID,
+		Status:      StatusCompleted,
+		Output:      decision,
+		Attempts:    1,
+		StartedAt:   task.CreatedAt,
+		CompletedAt: &now,
+		Duration:    now.Sub(task.CreatedAt),
+	}
+	if err := s.store.SaveState(execution); err != nil {
+		return nil, err
+	}
+	if err := s.engine.ResumeExecution(context.Background(), execution.ID); err != nil {
+		return nil, err
+	}
+
+	task.Status = TaskCompleted
+	task.Decision = string(data)
+	task.CompletedBy, task.CompletedAt = user, &now
+	if task.ClaimedBy == "" {
+		task.ClaimedBy, task.ClaimedAt = user, &now
+	}
+	if err := s.store.SaveTask(task); err != nil {
+		return nil, err
+	}
+	s.store.LogEvent(task.ExecutionID, task.StepID, "task_completed", "Task completed by "+user, map[string]interface{}{
+		"task_id":  task.ID,
+		"decision": decision,
+	})
+	return task, nil
+}
+
+// openTask loads a task user may act on
+func (s *TaskService) openTask(taskID, user string) (*HumanTask, error) {
+	task, err := s.store.LoadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !task.Open():
+		return nil, ErrTaskClosed
+	case task.Assignee != "" && task.Assignee != user:
+		return nil, ErrNotAssignee
+	case task.Status == TaskClaimed && task.ClaimedBy != user:
+		return nil, ErrTaskClaimed
+	}
+	return task, nil
+}
+
+// assign saves an execution reaching a human task step as paused, with
+// its task. A task still open from before the execution was resumed by
+// hand is kept, with its claim.
+func (s *TaskService) assign(execution *Execution, step *Step) (bool, error) {
+	task, err := newHumanTask(execution, step, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := s.store.LoadTask(task.ID)
+	switch {
+	case err == nil && existing.Open():
+		task = existing
+	case err == nil || errors.Is(err, ErrTaskNotFound):
+		if err := s.store.SaveTask(task); err != nil {
+			return false, fmt.Errorf("failed to save task: %w", err)
+		}
+	default:
+		return false, err
+	}
+
+	execution.mu.Lock()
+	execution.Status = StatusPaused
+	execution.mu.Unlock()
+	if err := s.store.SaveState(execution); err != nil {
+		execution.mu.Lock()
+		execution.Status = StatusRunning
+		execution.mu.Unlock()
+		return false, fmt.Errorf("failed to save state: %w", err)
+	}
+
+	s.store.LogEvent(execution.ID, step.ID, "task_created", "Waiting for task "+task.Name, map[string]interface{}{
+		"task_id":  task.ID,
+		"assignee": task.Assignee,
+		"due_at":   task.DueAt,
+	})
+	return true, nil
+}
+
+// newHumanTask builds the task of a human task step from its parameters
+func newHumanTask(execution *Execution, step *Step, now time.Time) (*HumanTask, error) {
+	task := &HumanTask{
+		ID:          humanTaskID(execution.ID, step.ID),
+		WorkflowID:  execution.WorkflowID,
+		ExecutionID: execution.ID,
+		StepID:      step.ID,
+		Name:        step.Name,
+		Status:      TaskOpen,
+		CreatedAt:   now,
+	}
+	if task.Name == "" {
+		task.Name = step.ID
+	}
+	task.Description, _ = step.Parameters["description"].(string)
+
+	assignee, err := variableParameter(execution.Context, step.Parameters["assignee"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid task assignee: %w", err)
+	}
+	task.Assignee = assignee
+
+	if value, ok := step.Parameters["form"]; ok && value != nil {
+		var data []byte
+		if text, isText := value.(string); isText {
+			data = []byte(text)
+		} else {
+			var err error
+			if data, err = json.Marshal(value); err != nil {
+				return nil, fmt.Errorf("invalid task form: %w", err)
+			}
+		}
+		var form map[string]interface{}
+		if err := json.Unmarshal(data, &form); err != nil {
+			return nil, fmt.Errorf("invalid task form: %w", err)
+		}
+		task.Form = string(data)
+	}
+
+	if value, ok := step.Parameters["due"]; ok {
+		due, err := durationParameter(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid task due time: %w", err)
+		}
+		dueAt := now.Add(due)
+		task.DueAt = &dueAt
+	}
+	return task, nil
+}
+
+// cancelTasks cancels the open tasks of a cancelled execution
+func cancelTasks(store StateStore, executionID string) error {
+	tasks, err := store.ListTasks(TaskFilter{ExecutionID: executionID, Statuses: openStatuses})
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		task.Status = TaskCancelled
+		if err := store.SaveTask(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateDecision checks a decision against a form's JSON schema: its
+// required properties, and the type and enum of each property it
+// declares. With additionalProperties false, other properties are
+// rejected.
+func validateDecision(form, decision map[string]interface{}) []string {
+	if form == nil {
+		return nil
+	}
+
+	var violations []string
+	required, _ := form["required"].([]interface{})
+	for _, name := range required {
+		key, _ := name.(string)
+		if _, present := decision[key]; key != "" && !present {
+			violations = append(violations, key+" is required")
+		}
+	}
+
+	properties, _ := form["properties"].(map[string]interface{})
+	closed := form["additionalProperties"] == false
+	keys := make([]string, 0, len(decision))
+	for key := range decision {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := decision[key]
+		property, declared := properties[key].(map[string]interface{})
+		if !declared {
+			if closed {
+				violations = append(violations, key+" is not a field of the form")
+			}
+			continue
+		}
+		if expected, ok := property["type"].(string); ok && !isFormType(expected, value) {
+			violations = append(violations, fmt.Sprintf("%s must be %s", key, expected))
+			continue
+		}
+		if allowed, ok := property["enum"].([]interface{}); ok && !inEnum(allowed, value) {
+			violations = append(violations, fmt.Sprintf("%s must be one of %v", key, allowed))
+		}
+	}
+	return violations
+}
+
+// isFormType reports whether value, as decoded from JSON or passed from
+// Go, is of a JSON schema type
+func isFormType(expected string, value interface{}) bool {
+	switch expected {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number", "integer":
+		switch v := value.(type) {
+		case float64:
+			return expected == "number" || v == float64(int64(v))
+		case float32:
+			return expected == "number" || v == float32(int64(v))
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return true
+		}
+		return false
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func inEnum(allowed []interface{}, value interface{}) bool {
+	for _, candidate := range allowed {
+		if fmt.Sprint(candidate) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultIdempotencyTTL is how long an idempotency key returns the
+// execution it started, unless set with WithIdempotencyTTL
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// StartOption configures how StartExecution starts an execution
+type StartOption func(*startOptions)
+
+type startOptions struct {
+	idempotencyKey string
+	idempotencyTTL time.Duration
+}
+
+// WithIdempotencyKey starts at most one execution of the workflow for key,
+// such as a webhook delivery or event ID: until the key expires, starts
+// with it return the execution the first one started. A
+// StatefulWorkflowEngine keeps keys in its state store, so they hold
+// across instances and restarts.
+func WithIdempotencyKey(key string) StartOption {
+	return func(o *startOptions) {
+		o.idempotencyKey = key
+	}
+}
+
+// WithIdempotencyTTL sets how long the idempotency key of a start is kept,
+// DefaultIdempotencyTTL by default. It should cover how long the trigger
+// may be retried.
+func WithIdempotencyTTL(ttl time.Duration) StartOption {
+	return func(o *startOptions) {
+		o.idempotencyTTL = ttl
+	}
+}
+
+func newStartOptions(opts []StartOption) startOptions {
+	options := startOptions{idempotencyTTL: DefaultIdempotencyTTL}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.idempotencyTTL <= 0 {
+		options.idempotencyTTL = DefaultIdempotencyTTL
+	}
+	return options
+}
+
+// IdempotencyKey is the execution started with an idempotency key
+type IdempotencyKey struct {
+	ID          string `gorm:"primaryKey"` // <workflow>:<key>
+	WorkflowID  string
+	ExecutionID string
+	ExpiresAt   time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
+
+// TableName keeps idempotency keys next to the other workflow tables
+func (IdempotencyKey) TableName() string {
+	return "workflow_idempotency_keys"
+}
+
+// idempotencyStore keeps idempotency keys; a StateStore, or
+// memoryIdempotency for an engine without one
+type idempotencyStore interface {
+	ClaimIdempotencyKey(key *IdempotencyKey) (string, error)
+}
+
+// memoryIdempotency keeps the idempotency keys of one process
+type memoryIdempotency struct {
+	mu   sync.Mutex
+	keys map[string]*IdempotencyKey
+}
+
+func (m *memoryIdempotency) ClaimIdempotencyKey(key *IdempotencyKey) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, existing := range m.keys {
+		if !existing.ExpiresAt.After(now) {
+			delete(m.keys, id)
+		}
+	}
+	if existing, ok := m.keys[key.ID]; ok {
+		return existing.ExecutionID, nil
+	}
+	if key.ExpiresAt.After(now) {
+		m.keys[key.ID] = key
+	}
+	return key.ExecutionID, nil
+}
+
+// claimStart claims the idempotency key of a new execution, returning the
+// ID of the execution that holds it: the new one's, or the one an earlier
+// start with the key created
+func (e *WorkflowEngine) claimStart(execution *Execution, options startOptions) (string, error) {
+	if options.idempotencyKey == "" {
+		return execution.ID, nil
+	}
+	now := time.Now()
+	return e.starts.ClaimIdempotencyKey(&IdempotencyKey{
+		ID:          execution.WorkflowID + ":" + options.idempotencyKey,
+		WorkflowID:  execution.WorkflowID,
+		ExecutionID: execution.ID,
+		ExpiresAt:   now.Add(options.idempotencyTTL),
+		CreatedAt:   now,
+	})
+}
+
+// startedExecution returns the execution an earlier start with the same
+// idempotency key created: the running copy if this instance runs it, or
+// else the saved one, which the start that claimed the key may still be
+// saving
+func (e *StatefulWorkflowEngine) startedExecution(executionID string) (*Execution, error) {
+	if execution := e.running(executionID); execution != nil {
+		return execution, nil
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		execution, err := e.stateStore.LoadState(executionID)
+		if !errors.Is(err, ErrStateNotFound) || time.Now().After(deadline) {
+			return execution, err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// IdempotencyToken returns a token for the step running with ctx, the same
+// across its retries, resumes and redispatches, to pass to services that
+// accept an idempotency key, e.g. a payment API. It is the key of the
+// step's unnamed effect; see Effects.Key for a token per side effect.
+// Outside a step it is empty.
+func IdempotencyToken(ctx context.Context) string {
+	effects := StepEffects(ctx)
+	if effects.executionID == "" {
+		return ""
+	}
+	return effects.Key("")
+}
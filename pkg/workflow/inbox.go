@@ -0,0 +1,191 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/api"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TaskUserFunc returns the user acting on a request, or "" when it is
+// not authenticated
+type TaskUserFunc func(c *fiber.Ctx) string
+
+// userIDFromLocals is the user set by auth.AuthMiddleware
+func userIDFromLocals(c *fiber.Ctx) string {
+	if userID, ok := c.Locals("user_id").(uint); ok && userID != 0 {
+		return strconv.FormatUint(uint64(userID), 10)
+	}
+	return ""
+}
+
+// taskResponse is a HumanTask as the task endpoints return it
+type taskResponse struct {
+	ID          string                 `json:"id"`
+	WorkflowID  string                 `json:"workflow_id"`
+	ExecutionID string                 `json:"execution_id"`
+	StepID      string                 `json:"step_id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Assignee    string                 `json:"assignee,omitempty"`
+	Form        map[string]interface{} `json:"form,omitempty"`
+	Status      TaskStatus             `json:"status"`
+	ClaimedBy   string                 `json:"claimed_by,omitempty"`
+	ClaimedAt   *time.Time             `json:"claimed_at,omitempty"`
+	Decision    map[string]interface{} `json:"decision,omitempty"`
+	CompletedBy string                 `json:"completed_by,omitempty"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+	DueAt       *time.Time             `json:"due_at,omitempty"`
+	Overdue     bool                   `json:"overdue"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+func newTaskResponse(task *HumanTask, now time.Time) *taskResponse {
+	response := &taskResponse{
+		ID:          task.ID,
+		WorkflowID:  task.WorkflowID,
+		ExecutionID: task.ExecutionID,
+		StepID:      task.StepID,
+		Name:        task.Name,
+		Description: task.Description,
+		Assignee:    task.Assignee,
+		Status:      task.Status,
+		ClaimedBy:   task.ClaimedBy,
+		ClaimedAt:   task.ClaimedAt,
+		CompletedBy: task.CompletedBy,
+		CompletedAt: task.CompletedAt,
+		DueAt:       task.DueAt,
+		Overdue:     task.Overdue(now),
+		CreatedAt:   task.CreatedAt,
+	}
+	// Stored by the service, so they parse
+	response.Form, _ = task.FormSchema()
+	response.Decision, _ = task.DecisionPayload()
+	return response
+}
+
+func newTaskResponses(tasks []*HumanTask) []*taskResponse {
+	now := time.Now()
+	responses := make([]*taskResponse, len(tasks))
+	for i, task := range tasks {
+		responses[i] = newTaskResponse(task, now)
+	}
+	return responses
+}
+
+// RegisterTaskRoutes registers the task inbox endpoints on a router that
+// authenticates users. user identifies the caller; nil uses the user ID
+// set by auth.AuthMiddleware.
+//
+//	GET  /tasks                open tasks the caller may act on, by due date
+//	GET  /tasks/all            tasks by ?workflow_id, ?execution_id,
+//	                           ?assignee, ?status (comma separated) and ?limit
+//	GET  /tasks/:id            a task with its form schema
+//	POST /tasks/:id/claim      claims a task for the caller
+//	POST /tasks/:id/release    gives up the caller's claim
+//	POST /tasks/:id/complete   completes a task; the body is the decision
+//
+// Mount /tasks/all on an operator router, or leave it to RBAC: it lists
+// everyone's tasks.
+func RegisterTaskRoutes(router fiber.Router, tasks *TaskService, user TaskUserFunc) {
+	if user == nil {
+		user = userIDFromLocals
+	}
+	authenticated := func(handler func(c *fiber.Ctx, user string) error) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			id := user(c)
+			if id == "" {
+				return api.Unauthorized(c, "Authentication required")
+			}
+			return handler(c, id)
+		}
+	}
+
+	router.Get("/tasks", authenticated(func(c *fiber.Ctx, id string) error {
+		inbox, err := tasks.Inbox(id)
+		if err != nil {
+			return taskError(c, err)
+		}
+		return api.Success(c, newTaskResponses(inbox))
+	}))
+
+	router.Get("/tasks/all", func(c *fiber.Ctx) error {
+		filter := TaskFilter{
+			WorkflowID:  c.Query("workflow_id"),
+			ExecutionID: c.Query("execution_id"),
+			Assignee:    c.Query("assignee"),
+			Limit:       c.QueryInt("limit", 100),
+		}
+		if status := c.Query("status"); status != "" {
+			for _, s := range strings.Split(status, ",") {
+				filter.Statuses = append(filter.Statuses, TaskStatus(strings.TrimSpace(s)))
+			}
+		}
+		list, err := tasks.List(filter)
+		if err != nil {
+			return taskError(c, err)
+		}
+		return api.Success(c, newTaskResponses(list))
+	})
+
+	router.Get("/tasks/:id", authenticated(func(c *fiber.Ctx, _ string) error {
+		task, err := tasks.Get(c.Params("id"))
+		if err != nil {
+			return taskError(c, err)
+		}
+		return api.Success(c, newTaskResponse(task, time.Now()))
+	}))
+
+	router.Post("/tasks/:id/claim", authenticated(func(c *fiber.Ctx, id string) error {
+		task, err := tasks.Claim(c.Params("id"), id)
+		if err != nil {
+			return taskError(c, err)
+		}
+		return api.SuccessWithMessage(c, "Task claimed", newTaskResponse(task, time.Now()))
+	}))
+
+	router.Post("/tasks/:id/release", authenticated(func(c *fiber.Ctx, id string) error {
+		task, err := tasks.Release(c.Params("id"), id)
+		if err != nil {
+			return taskError(c, err)
+		}
+		return api.SuccessWithMessage(c, "Task released", newTaskResponse(task, time.Now()))
+	}))
+
+	router.Post("/tasks/:id/complete", authenticated(func(c *fiber.Ctx, id string) error {
+		var decision map[string]interface{}
+		if body := c.Body(); len(body) > 0 {
+			if err := json.Unmarshal(body, &decision); err != nil {
+				return api.BadRequest(c, "Decision must be a JSON object", nil)
+			}
+		}
+		task, err := tasks.Complete(c.Params("id"), id, decision)
+		if err != nil {
+			return taskError(c, err)
+		}
+		return api.SuccessWithMessage(c, "Task completed", newTaskResponse(task, time.Now()))
+	}))
+}
+
+// taskError writes the response for an error of the task service
+func taskError(c *fiber.Ctx, err error) error {
+	var formErr *FormError
+	switch {
+	case errors.As(err, &formErr):
+		return api.Error(c, fiber.StatusUnprocessableEntity, "Decision does not match the task form", formErr.Violations)
+	case errors.Is(err, ErrTaskNotFound):
+		return api.NotFound(c, "Task not found")
+	case errors.Is(err, ErrNotAssignee):
+		return api.Forbidden(c, "Task is assigned to another user")
+	case errors.Is(err, ErrTaskClaimed):
+		return api.Conflict(c, "Task is claimed by another user")
+	case errors.Is(err, ErrTaskClosed):
+		return api.Conflict(c, "Task is already closed")
+	}
+	return api.InternalError(c, err.Error())
+}
@@ -0,0 +1,77 @@
+package workflow
+
+import (
+	"errors"
+
+	"neonexcore/pkg/api"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Inspector is what the inspection endpoints read: a WorkflowEngine, or a
+// StatefulWorkflowEngine, whose timelines include logged events
+type Inspector interface {
+	Graph(workflowID string) (*Graph, error)
+	Timeline(executionID string) (*Timeline, error)
+}
+
+// RegisterInspectionRoutes registers endpoints showing workflows as graphs
+// and executions as timelines, for dashboards, on a router that
+// authenticates operators:
+//
+//	GET /workflows/:id/graph         the workflow's steps and transitions;
+//	                                 ?format=dot or mermaid renders them,
+//	                                 ?execution=<id> colours them by its progress
+//	GET /executions/:id/timeline     step starts, ends, attempts and
+//	                                 durations, and what the current step
+//	                                 waits for
+func RegisterInspectionRoutes(router fiber.Router, engine Inspector) {
+	router.Get("/workflows/:id/graph", func(c *fiber.Ctx) error {
+		graph, err := engine.Graph(c.Params("id"))
+		if err != nil {
+			return inspectionError(c, err)
+		}
+		if executionID := c.Query("execution"); executionID != "" {
+			timeline, err := engine.Timeline(executionID)
+			if err != nil {
+				return inspectionError(c, err)
+			}
+			if timeline.WorkflowID != graph.WorkflowID {
+				return api.BadRequest(c, "Execution is not of this workflow", nil)
+			}
+			graph.Overlay(timeline)
+		}
+
+		switch c.Query("format", "json") {
+		case "json":
+			return api.Success(c, graph)
+		case "dot":
+			c.Set(fiber.HeaderContentType, "text/vnd.graphviz; charset=utf-8")
+			return c.SendString(graph.DOT())
+		case "mermaid":
+			c.Set(fiber.HeaderContentType, "text/plain; charset=utf-8")
+			return c.SendString(graph.Mermaid())
+		default:
+			return api.BadRequest(c, "Format must be json, dot or mermaid", nil)
+		}
+	})
+
+	router.Get("/executions/:id/timeline", func(c *fiber.Ctx) error {
+		timeline, err := engine.Timeline(c.Params("id"))
+		if err != nil {
+			return inspectionError(c, err)
+		}
+		return api.Success(c, timeline)
+	})
+}
+
+// inspectionError writes the response for an error of an inspection
+func inspectionError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrWorkflowNotFound):
+		return api.NotFound(c, "Workflow not found")
+	case errors.Is(err, ErrExecutionNotFound), errors.Is(err, ErrStateNotFound):
+		return api.NotFound(c, "Execution not found")
+	}
+	return api.InternalError(c, err.Error())
+}
@@ -0,0 +1,316 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"neonexcore/pkg/cache"
+	"neonexcore/pkg/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimit limits how often a step runs, as a token bucket: Rate
+// attempts a second on average, in bursts of up to Burst
+type RateLimit struct {
+	Rate  float64
+	Burst int    // Rate, rounded up, when zero
+	Key   string // Shared by the steps naming it, e.g. an API they all call; the step's own by default
+}
+
+func (r *RateLimit) burst() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return int(math.Max(1, math.Ceil(r.Rate)))
+}
+
+// Limiter enforces concurrency and rate limits of workflows and steps. A
+// MemoryLimiter holds them within the process; a RedisLimiter across every
+// engine and worker sharing the Redis server.
+type Limiter interface {
+	// Acquire takes one of the limit slots of key, waiting until one is
+	// free or ctx is done. release frees the slot.
+	Acquire(ctx context.Context, key string, limit int) (release func(), err error)
+	// Wait takes a token of key's bucket, which refills at rate a second
+	// up to burst tokens, waiting until one is available or ctx is done
+	Wait(ctx context.Context, key string, rate float64, burst int) error
+}
+
+// SetLimiter sets the limiter enforcing MaxConcurrent, Concurrency and
+// RateLimit, a MemoryLimiter by default. Engines and workers on several
+// instances share limits through a RedisLimiter.
+func (e *WorkflowEngine) SetLimiter(limiter Limiter) {
+	e.mu.Lock()
+	e.limiter = limiter
+	e.mu.Unlock()
+}
+
+func (e *WorkflowEngine) getLimiter() Limiter {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.limiter
+}
+
+// acquireExecution waits for a slot of a workflow with MaxConcurrent
+func (e *WorkflowEngine) acquireExecution(ctx context.Context, workflow *Workflow) (func(), error) {
+	if workflow.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+	release, err := e.getLimiter().Acquire(ctx, "workflow:"+workflow.ID, workflow.MaxConcurrent)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for one of %d executions of workflow %s: %w", workflow.MaxConcurrent, workflow.ID, err)
+	}
+	return release, nil
+}
+
+// acquireStep waits for a step's concurrency slot and rate limit token,
+// before each attempt
+func (e *WorkflowEngine) acquireStep(ctx context.Context, step *Step, workflowID string) (func(), error) {
+	release := func() {}
+	if step.Concurrency > 0 {
+		var err error
+		release, err = e.getLimiter().Acquire(ctx, "step:"+workflowID+":"+step.ID, step.Concurrency)
+		if err != nil {
+			return nil, fmt.Errorf("waiting for one of %d runs of step %s: %w", step.Concurrency, step.ID, err)
+		}
+	}
+
+	if step.RateLimit != nil && step.RateLimit.Rate > 0 {
+		key := step.RateLimit.Key
+		if key == "" {
+			key = workflowID + ":" + step.ID
+		}
+		if err := e.getLimiter().Wait(ctx, "rate:"+key, step.RateLimit.Rate, step.RateLimit.burst()); err != nil {
+			release()
+			return nil, fmt.Errorf("rate limit of step %s: %w", step.ID, err)
+		}
+	}
+	return release, nil
+}
+
+// ==================== Memory ====================
+
+// MemoryLimiter enforces limits within the process
+type MemoryLimiter struct {
+	slots   map[string]chan struct{}
+	buckets map[string]*tokenBucket
+	mu      sync.Mutex
+}
+
+// tokenBucket is a bucket's tokens as of updated
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills the bucket to now and takes a token, or returns how long
+// until one is available
+func (b *tokenBucket) take(now time.Time, rate float64, burst int) time.Duration {
+	if b.updated.IsZero() {
+		b.tokens = float64(burst)
+	} else if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(burst), b.tokens+elapsed*rate)
+	}
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// NewMemoryLimiter creates an in-process limiter
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		slots:   make(map[string]chan struct{}),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Acquire takes a slot of key. Slots are sized by the first limit given
+// for a key.
+func (l *MemoryLimiter) Acquire(ctx context.Context, key string, limit int) (func(), error) {
+	l.mu.Lock()
+	slots, exists := l.slots[key]
+	if !exists {
+		slots = make(chan struct{}, limit)
+		l.slots[key] = slots
+	}
+	l.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-slots }) }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Wait takes a token of key's bucket
+func (l *MemoryLimiter) Wait(ctx context.Context, key string, rate float64, burst int) error {
+	for {
+		l.mu.Lock()
+		bucket, exists := l.buckets[key]
+		if !exists {
+			bucket = &tokenBucket{}
+			l.buckets[key] = bucket
+		}
+		wait := bucket.take(time.Now(), rate, burst)
+		l.mu.Unlock()
+
+		if wait == 0 {
+			return nil
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// ==================== Redis ====================
+
+// Redis limiter timings
+const (
+	limiterSlotTTL   = 30 * time.Second // Renewed while held, freed this long after a holder dies
+	limiterBucketTTL = 5 * time.Second
+	limiterRetry     = 100 * time.Millisecond
+)
+
+// RedisLimiter enforces limits across processes with the cache package's
+// distributed locks. The limit slots of a key are locks, each held by one
+// execution or step at a time and renewed while held, so the slots of a
+// crashed worker free up when their locks expire. A bucket is a hash
+// updated under its own lock.
+type RedisLimiter struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisLimiter creates a limiter whose keys start with prefix, e.g.
+// "workflow:limits:"
+func NewRedisLimiter(client redis.Cmdable, prefix string) *RedisLimiter {
+	return &RedisLimiter{client: client, prefix: prefix}
+}
+
+// Acquire takes a free slot of key, polling until one is
+func (l *RedisLimiter) Acquire(ctx context.Context, key string, limit int) (func(), error) {
+	for {
+		for i := 0; i < limit; i++ {
+			lock := cache.NewLock(l.client, l.prefix+key+":"+strconv.Itoa(i), limiterSlotTTL)
+			ok, err := lock.TryAcquire(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return l.hold(lock), nil
+			}
+		}
+		if err := sleepContext(ctx, limiterRetry); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// hold renews a slot's lock until released
+func (l *RedisLimiter) hold(lock *cache.Lock) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(limiterSlotTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := lock.Renew(context.Background()); err != nil {
+					logger.Warn("Workflow limit slot lost", logger.Fields{"key": lock.Key(), "error": err.Error()})
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+			if err := lock.Release(context.Background()); err != nil && !errors.Is(err, cache.ErrLockNotHeld) {
+				logger.Warn("Workflow limit slot not released", logger.Fields{"key": lock.Key(), "error": err.Error()})
+			}
+		})
+	}
+}
+
+// Wait takes a token of key's bucket
+func (l *RedisLimiter) Wait(ctx context.Context, key string, rate float64, burst int) error {
+	bucketKey := l.prefix + key
+	for {
+		wait, err := l.take(ctx, bucketKey, rate, burst)
+		if err != nil {
+			return err
+		}
+		if wait == 0 {
+			return nil
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// take updates a bucket under its lock
+func (l *RedisLimiter) take(ctx context.Context, bucketKey string, rate float64, burst int) (time.Duration, error) {
+	lock := cache.NewLock(l.client, bucketKey, limiterBucketTTL, cache.WithRetryDelay(5*time.Millisecond))
+	if err := lock.Acquire(ctx); err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, err
+	}
+	defer lock.Release(context.Background())
+
+	var bucket tokenBucket
+	values, err := l.client.HGetAll(ctx, bucketKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	if len(values) > 0 {
+		bucket.tokens, _ = strconv.ParseFloat(values["tokens"], 64)
+		if updated, err := strconv.ParseInt(values["updated"], 10, 64); err == nil {
+			bucket.updated = time.UnixMicro(updated)
+		}
+	}
+
+	wait := bucket.take(time.Now(), rate, burst)
+	pipe := l.client.TxPipeline()
+	pipe.HSet(ctx, bucketKey, "tokens", strconv.FormatFloat(bucket.tokens, 'f', -1, 64), "updated", bucket.updated.UnixMicro())
+	// Idle buckets are full again once they would have refilled
+	pipe.Expire(ctx, bucketKey, time.Duration(float64(burst)/rate*float64(time.Second))+time.Second)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return wait, nil
+}
+
+// sleepContext sleeps for d unless ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,288 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsRefresh is how often failure rates drop executions that left
+// their window
+const metricsRefresh = 10 * time.Second
+
+var (
+	stepDurationBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300}
+	queueLagBuckets     = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60}
+)
+
+// MetricsConfig configures the metrics of an engine, see SetMetrics
+type MetricsConfig struct {
+	Window        time.Duration // Of each workflow's failure rate
+	MinExecutions int           // Finished within the window before a failure rate is reported
+	FailureRate   int           // Percent of failed executions alerted above; negative for no alerts
+}
+
+// DefaultMetricsConfig returns the default metrics configuration: an
+// alert when a workflow fails more than 5% of at least 5 executions
+// within 10 minutes
+func DefaultMetricsConfig() MetricsConfig {
+	return MetricsConfig{
+		Window:        10 * time.Minute,
+		MinExecutions: 5,
+		FailureRate:   5,
+	}
+}
+
+// Metrics records an engine's executions and steps, and alerts on their
+// failure rates. metrics.Hooks implements it for a collector and
+// dashboard.
+type Metrics interface {
+	AddCounter(name, description string, labels map[string]string, delta uint64)
+	SetGauge(name, description string, labels map[string]string, value int64)
+	Observe(name, description string, labels map[string]string, buckets []float64, value float64)
+	SetAlert(name, description, metric string, threshold float64, metadata map[string]interface{})
+	RemoveAlert(name string)
+}
+
+// engineMetrics records an engine's executions and steps
+type engineMetrics struct {
+	metrics  Metrics
+	config   MetricsConfig
+	outcomes map[string][]outcome // Executions of each workflow finished within the window
+	stop     chan struct{}
+	mu       sync.Mutex
+}
+
+// outcome is whether an execution failed, and when it finished
+type outcome struct {
+	at     time.Time
+	failed bool
+}
+
+// SetMetrics records the engine's executions, steps and dispatch queue
+// lag as workflow_* metrics, and a failure rate gauge per workflow. Each
+// workflow, registered now or later, gets an alert on its failure rate.
+func (e *WorkflowEngine) SetMetrics(metrics Metrics, config MetricsConfig) {
+	defaults := DefaultMetricsConfig()
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	if config.MinExecutions <= 0 {
+		config.MinExecutions = defaults.MinExecutions
+	}
+	if config.FailureRate == 0 {
+		config.FailureRate = defaults.FailureRate
+	}
+
+	m := &engineMetrics{
+		metrics:  metrics,
+		config:   config,
+		outcomes: make(map[string][]outcome),
+		stop:     make(chan struct{}),
+	}
+
+	e.mu.Lock()
+	if e.metrics != nil {
+		close(e.metrics.stop)
+	}
+	e.metrics = m
+	workflows := make([]*Workflow, 0, len(e.workflows))
+	for _, workflow := range e.workflows {
+		workflows = append(workflows, workflow)
+	}
+	e.mu.Unlock()
+
+	for _, workflow := range workflows {
+		m.registered(workflow)
+	}
+	go m.refreshLoop()
+}
+
+func (e *WorkflowEngine) getMetrics() *engineMetrics {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.metrics
+}
+
+// prefix returns the prefix of a workflow's metric names, e.g.
+// "workflow_order_processing"
+func (m *engineMetrics) prefix(workflowID string) string {
+	return "workflow_" + metricName(workflowID)
+}
+
+func (m *engineMetrics) alertName(workflowID string) string {
+	return "workflow:" + workflowID + ":failure_rate"
+}
+
+// registered adds the failure rate alert of a workflow
+func (m *engineMetrics) registered(workflow *Workflow) {
+	if m == nil {
+		return
+	}
+	m.metrics.SetGauge(m.prefix(workflow.ID)+"_failure_rate",
+		"Percent of "+workflow.ID+" executions failed in the last "+m.config.Window.String(),
+		map[string]string{"workflow": workflow.ID}, 0)
+
+	if m.config.FailureRate < 0 {
+		return
+	}
+	name := workflow.Name
+	if name == "" {
+		name = workflow.ID
+	}
+	m.metrics.SetAlert(m.alertName(workflow.ID),
+		fmt.Sprintf("Workflow %s failed more than %d%% of executions in %s", name, m.config.FailureRate, m.config.Window),
+		m.prefix(workflow.ID)+"_failure_rate",
+		float64(m.config.FailureRate),
+		map[string]interface{}{
+			"severity":        "high",
+			"workflow_id":     workflow.ID,
+			"related_metrics": m.prefix(workflow.ID) + "_*",
+		})
+}
+
+// deleted removes the failure rate alert of a workflow
+func (m *engineMetrics) deleted(workflowID string) {
+	if m == nil {
+		return
+	}
+	m.metrics.RemoveAlert(m.alertName(workflowID))
+}
+
+// started counts a new execution
+func (m *engineMetrics) started(execution *Execution) {
+	if m == nil {
+		return
+	}
+	m.metrics.AddCounter("workflow_executions_started_total", "Workflow executions started", nil, 1)
+	m.metrics.AddCounter(m.prefix(execution.WorkflowID)+"_started_total", "Executions of "+execution.WorkflowID+" started",
+		map[string]string{"workflow": execution.WorkflowID}, 1)
+}
+
+// finished counts an execution that completed, failed or was cancelled,
+// and updates its workflow's failure rate
+func (m *engineMetrics) finished(execution *Execution) {
+	if m == nil {
+		return
+	}
+	execution.mu.RLock()
+	workflowID, status := execution.WorkflowID, execution.Status
+	execution.mu.RUnlock()
+
+	m.metrics.AddCounter("workflow_executions_"+string(status)+"_total", "Workflow executions "+string(status), nil, 1)
+	m.metrics.AddCounter(m.prefix(workflowID)+"_"+string(status)+"_total", "Executions of "+workflowID+" "+string(status),
+		map[string]string{"workflow": workflowID}, 1)
+
+	// Cancelled executions neither failed nor succeeded
+	if status == StatusCancelled {
+		return
+	}
+	m.mu.Lock()
+	m.outcomes[workflowID] = append(m.outcomes[workflowID], outcome{at: time.Now(), failed: status == StatusFailed})
+	m.mu.Unlock()
+	m.refresh(workflowID)
+}
+
+// step records the duration, retries and failure of a step run
+func (m *engineMetrics) step(workflowID string, step *Step, result *StepResult) {
+	if m == nil {
+		return
+	}
+	labels := map[string]string{"workflow": workflowID, "step": step.ID}
+	seconds := result.Duration.Seconds()
+	m.metrics.Observe("workflow_step_duration_seconds", "Workflow step run time in seconds", nil, stepDurationBuckets, seconds)
+	m.metrics.Observe(m.prefix(workflowID)+"_step_"+metricName(step.ID)+"_duration_seconds",
+		"Run time of step "+step.ID+" of "+workflowID+" in seconds", labels, stepDurationBuckets, seconds)
+
+	if retries := result.Attempts - 1; retries > 0 {
+		m.metrics.AddCounter("workflow_step_retries_total", "Workflow step attempts after the first", nil, uint64(retries))
+		m.metrics.AddCounter(m.prefix(workflowID)+"_step_retries_total", "Step attempts of "+workflowID+" after the first",
+			map[string]string{"workflow": workflowID}, uint64(retries))
+	}
+	if result.Status == StatusFailed {
+		m.metrics.AddCounter("workflow_steps_failed_total", "Workflow steps failed after their retries", nil, 1)
+		m.metrics.AddCounter(m.prefix(workflowID)+"_steps_failed_total", "Steps of "+workflowID+" failed after their retries",
+			map[string]string{"workflow": workflowID}, 1)
+	}
+}
+
+// queueLag records how long a dispatched step waited for a worker
+func (m *engineMetrics) queueLag(workflowID string, lag time.Duration) {
+	if m == nil {
+		return
+	}
+	m.metrics.Observe("workflow_dispatch_queue_lag_seconds", "Time dispatched workflow steps waited for a worker in seconds",
+		nil, queueLagBuckets, lag.Seconds())
+	m.metrics.Observe(m.prefix(workflowID)+"_dispatch_queue_lag_seconds", "Time dispatched steps of "+workflowID+" waited for a worker in seconds",
+		map[string]string{"workflow": workflowID}, queueLagBuckets, lag.Seconds())
+}
+
+// refresh sets a workflow's failure rate, as a whole percent rounded up,
+// from the executions finished within the window
+func (m *engineMetrics) refresh(workflowID string) {
+	m.mu.Lock()
+	cutoff := time.Now().Add(-m.config.Window)
+	outcomes := m.outcomes[workflowID]
+	kept := outcomes[:0]
+	failed := 0
+	for _, outcome := range outcomes {
+		if outcome.at.After(cutoff) {
+			kept = append(kept, outcome)
+			if outcome.failed {
+				failed++
+			}
+		}
+	}
+	if len(kept) == 0 {
+		delete(m.outcomes, workflowID)
+	} else {
+		m.outcomes[workflowID] = kept
+	}
+	m.mu.Unlock()
+
+	rate := 0
+	if len(kept) >= m.config.MinExecutions {
+		rate = (failed*100 + len(kept) - 1) / len(kept)
+	}
+	m.metrics.SetGauge(m.prefix(workflowID)+"_failure_rate", "Percent of "+workflowID+" executions failed in the last "+m.config.Window.String(),
+		map[string]string{"workflow": workflowID}, int64(rate))
+}
+
+// refreshLoop keeps failure rates current while no executions finish
+func (m *engineMetrics) refreshLoop() {
+	ticker := time.NewTicker(metricsRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.mu.Lock()
+			workflowIDs := make([]string, 0, len(m.outcomes))
+			for workflowID := range m.outcomes {
+				workflowIDs = append(workflowIDs, workflowID)
+			}
+			m.mu.Unlock()
+
+			for _, workflowID := range workflowIDs {
+				m.refresh(workflowID)
+			}
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// metricName turns an ID into a metric name part, e.g. "order-processing"
+// into "order_processing"
+func metricName(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r + ('a' - 'A')
+		}
+		return '_'
+	}, id)
+}
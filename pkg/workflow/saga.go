@@ -0,0 +1,102 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// compensate rolls back an execution whose step failed for good: the
+// Compensate actions of the steps completed before it run in reverse
+// order, each with its step's retry policy and timeout. A compensation
+// that fails is recorded and the rollback goes on with the steps before
+// it; the failures are returned together.
+func (e *WorkflowEngine) compensate(ctx context.Context, workflow *Workflow, execution *Execution) error {
+	// Undo what was done even when the failure was a cancellation
+	ctx = context.WithoutCancel(ctx)
+
+	execution.mu.Lock()
+	execution.Status = StatusCompensating
+	execution.mu.Unlock()
+
+	var errs []error
+	for i := len(workflow.Steps) - 1; i >= 0; i-- {
+		step := &workflow.Steps[i]
+		if step.Compensate == nil {
+			continue
+		}
+
+		execution.mu.RLock()
+		done := execution.StepResults[step.ID]
+		execution.mu.RUnlock()
+		if done == nil || done.Status != StatusCompleted {
+			continue
+		}
+
+		result := e.compensateStep(ctx, step, execution.Context)
+
+		execution.mu.Lock()
+		execution.Compensations = append(execution.Compensations, result)
+		execution.mu.Unlock()
+
+		if result.Error != nil {
+			errs = append(errs, fmt.Errorf("step %s: %w", step.ID, result.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compensateStep runs a step's Compensate action. Its effects are kept
+// apart from the action's, as those of step "compensate:<step>".
+func (e *WorkflowEngine) compensateStep(ctx context.Context, step *Step, execCtx *ExecutionContext) *StepResult {
+	result := &StepResult{
+		StepID:    step.ID,
+		Status:    StatusRunning,
+		StartedAt: time.Now(),
+	}
+	ctx = withEffects(ctx, e.effects, execCtx, "compensate:"+step.ID)
+
+	maxAttempts := 1
+	if step.RetryPolicy != nil && step.RetryPolicy.MaxAttempts > 1 {
+		maxAttempts = step.RetryPolicy.MaxAttempts
+	}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		result.Attempts = attempt
+
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if step.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, step.Timeout)
+		}
+		result.Output, result.Error = step.Compensate(attemptCtx, execCtx)
+		cancel()
+
+		if result.Error == nil {
+			break
+		}
+		if attempt < maxAttempts {
+			time.Sleep(step.RetryPolicy.backoff(attempt))
+		}
+	}
+
+	result.Status = StatusCompleted
+	if result.Error != nil {
+		result.Status = StatusFailed
+	}
+	now := time.Now()
+	result.CompletedAt = &now
+	result.Duration = now.Sub(result.StartedAt)
+	return result
+}
+
+// backoff returns the delay before the attempt after attempt
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.Delay
+	if p.BackoffRate > 0 {
+		for i := 1; i < attempt; i++ {
+			delay = time.Duration(float64(delay) * p.BackoffRate)
+		}
+	}
+	return delay
+}
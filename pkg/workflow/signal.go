@@ -0,0 +1,204 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"neonexcore/pkg/api"
+	"neonexcore/pkg/queue"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrNoWaiter is returned by Signal when no execution waits for the
+// signal, so a webhook or queue consumer can retry it later
+var ErrNoWaiter = errors.New("no execution is waiting for the signal")
+
+// SignalWait is an execution paused in a wait for signal step, found by
+// its execution ID or correlation key when the signal arrives
+type SignalWait struct {
+	ExecutionID    string `gorm:"primaryKey"` // One wait per execution
+	WorkflowID     string `gorm:"index"`
+	StepID         string
+	Signal         string `gorm:"column:signal_name;index"` // SIGNAL is reserved in MySQL
+	CorrelationKey string `gorm:"index"`                    // Empty: found by execution ID only
+	CreatedAt      time.Time
+}
+
+// TableName keeps signal waits next to the other workflow tables
+func (SignalWait) TableName() string {
+	return "workflow_signal_waits"
+}
+
+// awaitSignal saves an execution reaching a wait for signal step as
+// paused until Signal delivers the step's signal. Step parameters:
+//
+//	signal       name of the signal, e.g. "payment_confirmed"
+//	correlation  key the sender knows the execution by, literal or
+//	             "$variable", e.g. "$order_id"
+func (e *StatefulWorkflowEngine) awaitSignal(execution *Execution, step *Step) (bool, error) {
+	signal, _ := step.Parameters["signal"].(string)
+	if signal == "" {
+		return false, fmt.Errorf("step %s waits for a signal without a name", step.ID)
+	}
+	key, err := variableParameter(execution.Context, step.Parameters["correlation"])
+	if err != nil {
+		return false, fmt.Errorf("invalid correlation key: %w", err)
+	}
+
+	wait := &SignalWait{
+		ExecutionID:    execution.ID,
+		WorkflowID:     execution.WorkflowID,
+		StepID:         step.ID,
+		Signal:         signal,
+		CorrelationKey: key,
+		CreatedAt:      time.Now(),
+	}
+	if err := e.stateStore.SaveSignalWait(wait); err != nil {
+		return false, fmt.Errorf("failed to save signal wait: %w", err)
+	}
+
+	execution.mu.Lock()
+	execution.Status = StatusPaused
+	execution.mu.Unlock()
+	if err := e.stateStore.SaveState(execution); err != nil {
+		e.stateStore.DeleteSignalWait(execution.ID)
+		execution.mu.Lock()
+		execution.Status = StatusRunning
+		execution.mu.Unlock()
+		return false, fmt.Errorf("failed to save state: %w", err)
+	}
+
+	e.stateStore.LogEvent(execution.ID, step.ID, "waiting_for_signal", "Waiting for signal "+signal, map[string]interface{}{
+		"signal":      signal,
+		"correlation": key,
+	})
+	return true, nil
+}
+
+// Signal delivers a signal to the executions waiting for it, found by
+// execution ID or by the correlation key of their wait for signal step.
+// Each one resumes after the step, whose output is payload. It returns
+// ErrNoWaiter when no execution waits for the signal, including when it
+// arrives before the execution reaches the step; senders should retry.
+func (e *StatefulWorkflowEngine) Signal(ctx context.Context, target, signal string, payload interface{}) error {
+	waits, err := e.stateStore.SignalWaits(signal, target)
+	if err != nil {
+		return err
+	}
+
+	delivered := 0
+	var errs []error
+	for _, wait := range waits {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		ok, err := e.deliverSignal(wait, payload)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("execution %s: %w", wait.ExecutionID, err))
+			continue
+		}
+		if ok {
+			delivered++
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if delivered == 0 {
+		return fmt.Errorf("%w: %s for %s", ErrNoWaiter, signal, target)
+	}
+	return nil
+}
+
+// deliverSignal completes the wait for signal step of a paused execution
+// and resumes it, reporting false for a wait that is stale
+func (e *StatefulWorkflowEngine) deliverSignal(wait *SignalWait, payload interface{}) (bool, error) {
+	execution, err := e.stateStore.LoadState(wait.ExecutionID)
+	if errors.Is(err, ErrStateNotFound) {
+		return false, e.stateStore.DeleteSignalWait(wait.ExecutionID)
+	}
+	if err != nil {
+		return false, err
+	}
+	// Cancelled or resumed by hand meanwhile
+	if execution.Status != StatusPaused || execution.CurrentStep != wait.StepID {
+		return false, e.stateStore.DeleteSignalWait(wait.ExecutionID)
+	}
+
+	// Result first: a resume that fails leaves the wait to retry
+	now := time.Now()
+	execution.StepResults[wait.StepID] = &StepResult{
+		StepID:      wait.StepID,
+		Status:      StatusCompleted,
+		Output:      payload,
+		Attempts:    1,
+		StartedAt:   wait.CreatedAt,
+		CompletedAt: &now,
+		Duration:    now.Sub(wait.CreatedAt),
+	}
+	if err := e.stateStore.SaveState(execution); err != nil {
+		return false, err
+	}
+	e.stateStore.LogEvent(execution.ID, wait.StepID, "signal_received", "Received signal "+wait.Signal, map[string]interface{}{
+		"signal":  wait.Signal,
+		"payload": payload,
+	})
+	if err := e.ResumeExecution(context.Background(), execution.ID); err != nil {
+		return false, err
+	}
+	return true, e.stateStore.DeleteSignalWait(wait.ExecutionID)
+}
+
+// SignalHandler returns a queue handler signalling the executions
+// correlated with each message. The payload is a JSON object; the value
+// of its correlationField is the target, and the object the signal's
+// payload. Messages for which no execution waits yet are redelivered by
+// the queue's retries.
+//
+//	q.Subscribe("payments.confirmed", "workflows",
+//		engine.SignalHandler("payment_confirmed", "order_id"))
+func (e *StatefulWorkflowEngine) SignalHandler(signal, correlationField string) queue.Handler {
+	return func(ctx context.Context, msg *queue.Message) error {
+		var payload map[string]interface{}
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("signal %s: invalid message: %w", signal, err)
+		}
+		target, ok := payload[correlationField]
+		if !ok || target == nil {
+			return fmt.Errorf("signal %s: message has no %s", signal, correlationField)
+		}
+		return e.Signal(ctx, fmt.Sprint(target), signal, payload)
+	}
+}
+
+// RegisterSignalRoutes registers a webhook endpoint signalling
+// executions, for a router that authenticates the sender:
+//
+//	POST /signals/:signal/:target   the JSON body, if any, is the payload
+//
+// It responds 404 when no execution waits for the signal, so webhook
+// senders retry.
+func RegisterSignalRoutes(router fiber.Router, engine *StatefulWorkflowEngine) {
+	router.Post("/signals/:signal/:target", func(c *fiber.Ctx) error {
+		var payload interface{}
+		if body := c.Body(); len(body) > 0 {
+			if err := json.Unmarshal(body, &payload); err != nil {
+				return api.BadRequest(c, "Payload must be JSON", nil)
+			}
+		}
+
+		signal, target := c.Params("signal"), c.Params("target")
+		if err := engine.Signal(c.UserContext(), target, signal, payload); err != nil {
+			if errors.Is(err, ErrNoWaiter) {
+				return api.NotFound(c, "No execution is waiting for the signal")
+			}
+			return api.InternalError(c, err.Error())
+		}
+		return api.SuccessWithMessage(c, "Signal delivered", fiber.Map{"signal": signal, "target": target})
+	})
+}
@@ -3,35 +3,123 @@ package workflow
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	gormlogger "gorm.io/gorm/logger"
 )
 
-// StateStore stores workflow execution state
-type StateStore struct {
+// ErrStateNotFound is returned when loading the state of an unknown execution
+var ErrStateNotFound = errors.New("workflow state not found")
+
+// StateStore stores workflow execution state, so executions can be
+// resumed after a restart. NewStateStore keeps it in a PostgreSQL or MySQL
+// database, NewSQLiteStateStore in a file next to the binary and
+// NewRedisStateStore in Redis.
+type StateStore interface {
+	// SaveState saves an execution, replacing its previous state
+	SaveState(execution *Execution) error
+	// LoadState loads an execution, or returns ErrStateNotFound
+	LoadState(executionID string) (*Execution, error)
+	// DeleteState deletes an execution's state
+	DeleteState(executionID string) error
+	// ListStates lists states, newest first, optionally of one workflow or
+	// status; a limit of 0 lists all
+	ListStates(workflowID string, status WorkflowStatus, limit int) ([]*WorkflowState, error)
+	// ListChildren lists the executions started by an execution's
+	// sub-workflow steps, oldest first
+	ListChildren(parentExecutionID string) ([]*WorkflowState, error)
+	// LogEvent records an event of an execution
+	LogEvent(executionID, stepID, eventType, message string, data map[string]interface{}) error
+	// GetEvents lists an execution's events, newest first; a limit of 0
+	// lists all
+	GetEvents(executionID string, limit int) ([]*EventLog, error)
+	// CleanupOldStates deletes completed, failed and cancelled executions
+	// that finished more than olderThan ago, returning how many
+	CleanupOldStates(olderThan time.Duration) (int64, error)
+	// SaveTimer saves a timer, replacing one with the same ID
+	SaveTimer(timer *Timer) error
+	// DeleteTimer deletes a timer; unknown timers are not an error
+	DeleteTimer(id string) error
+	// ListTimers lists timers by fire time, optionally of one workflow
+	ListTimers(workflowID string) ([]*Timer, error)
+	// DueTimers lists timers firing at or before now, earliest first; a
+	// limit of 0 lists all
+	DueTimers(now time.Time, limit int) ([]*Timer, error)
+	// SaveTask saves a human task, replacing one with the same ID
+	SaveTask(task *HumanTask) error
+	// LoadTask loads a human task, or returns ErrTaskNotFound
+	LoadTask(id string) (*HumanTask, error)
+	// ListTasks lists human tasks matching a filter, oldest first
+	ListTasks(filter TaskFilter) ([]*HumanTask, error)
+	// SaveSignalWait saves an execution's wait for a signal, replacing
+	// its previous one
+	SaveSignalWait(wait *SignalWait) error
+	// DeleteSignalWait deletes an execution's wait; unknown waits are not
+	// an error
+	DeleteSignalWait(executionID string) error
+	// SignalWaits lists the waits for a signal whose execution ID or
+	// correlation key is target, oldest first
+	SignalWaits(signal, target string) ([]*SignalWait, error)
+	// SaveEffect records a step's completed side effect, replacing one
+	// with the same ID. Deleting or cleaning up the execution's state
+	// deletes its effects.
+	SaveEffect(effect *EffectRecord) error
+	// LoadEffect loads an effect of an execution, or returns
+	// ErrEffectNotFound
+	LoadEffect(executionID, id string) (*EffectRecord, error)
+	// SaveDispatch saves a step dispatch, replacing one with the same ID
+	SaveDispatch(dispatch *StepDispatch) error
+	// UpdateDispatch saves a step dispatch if the stored one still has its
+	// Version, incrementing it, or returns ErrDispatchConflict
+	UpdateDispatch(dispatch *StepDispatch) error
+	// LoadDispatch loads a step dispatch, or returns ErrDispatchNotFound
+	LoadDispatch(id string) (*StepDispatch, error)
+	// DeleteDispatch deletes a step dispatch; unknown dispatches are not
+	// an error
+	DeleteDispatch(id string) error
+	// ExpiredDispatches lists step dispatches whose lease ended at or
+	// before now, earliest first; a limit of 0 lists all
+	ExpiredDispatches(now time.Time, limit int) ([]*StepDispatch, error)
+	// ClaimIdempotencyKey saves a key unless an unexpired one with its ID
+	// exists, and returns the execution ID of the key kept. Keys expired
+	// when claimed are not kept.
+	ClaimIdempotencyKey(key *IdempotencyKey) (string, error)
+}
+
+// SQLStateStore stores workflow execution state in a database
+type SQLStateStore struct {
 	db *gorm.DB
 	mu sync.RWMutex
 }
 
 // WorkflowState persisted workflow state
 type WorkflowState struct {
-	ID           string                 `gorm:"primaryKey"`
-	WorkflowID   string                 `gorm:"index"`
-	ExecutionID  string                 `gorm:"uniqueIndex"`
-	Status       WorkflowStatus         `gorm:"index"`
-	CurrentStep  string                 `gorm:"index"`
-	Input        string                 `gorm:"type:jsonb"` // JSON serialized
-	Output       string                 `gorm:"type:jsonb"` // JSON serialized
-	Variables    string                 `gorm:"type:jsonb"` // JSON serialized
-	StepResults  string                 `gorm:"type:jsonb"` // JSON serialized
-	Error        string                 `gorm:"type:text"`
-	StartedAt    time.Time              `gorm:"index"`
-	CompletedAt  *time.Time             `gorm:"index"`
-	UpdatedAt    time.Time              `gorm:"autoUpdateTime"`
-	Metadata     map[string]interface{} `gorm:"-"` // Not stored in DB
+	ID            string                 `gorm:"primaryKey"`
+	WorkflowID    string                 `gorm:"index"`
+	ExecutionID   string                 `gorm:"uniqueIndex"`
+	Status        WorkflowStatus         `gorm:"index"`
+	CurrentStep   string                 `gorm:"index"`
+	Input         string                 `gorm:"type:jsonb"` // JSON serialized
+	Output        string                 `gorm:"type:jsonb"` // JSON serialized
+	Variables     string                 `gorm:"type:jsonb"` // JSON serialized
+	StepResults   string                 `gorm:"type:jsonb"` // JSON serialized
+	Compensations string                 `gorm:"type:jsonb"` // JSON serialized
+	Error         string                 `gorm:"type:text"`
+	StartedAt     time.Time              `gorm:"index"`
+	CompletedAt   *time.Time             `gorm:"index"`
+	UpdatedAt     time.Time              `gorm:"autoUpdateTime"`
+	Metadata      map[string]interface{} `gorm:"-"` // Not stored in DB
+
+	// Executions started by a sub-workflow step
+	ParentExecutionID string `gorm:"index"`
+	ParentStepID      string
+	Awaited           bool
 }
 
 // EventLog workflow event log
@@ -46,22 +134,459 @@ type EventLog struct {
 }
 
 // NewStateStore creates a new state store
-func NewStateStore(db *gorm.DB) (*StateStore, error) {
+func NewStateStore(db *gorm.DB) (*SQLStateStore, error) {
 	// Auto-migrate tables
-	if err := db.AutoMigrate(&WorkflowState{}, &EventLog{}); err != nil {
+	if err := db.AutoMigrate(&WorkflowState{}, &EventLog{}, &Timer{}, &HumanTask{}, &SignalWait{}, &EffectRecord{}, &StepDispatch{}, &IdempotencyKey{}); err != nil {
 		return nil, fmt.Errorf("failed to migrate tables: %w", err)
 	}
 
-	return &StateStore{
+	return &SQLStateStore{
 		db: db,
 	}, nil
 }
 
+// NewSQLiteStateStore creates a state store in a SQLite file, for single
+// binary deployments without a database server
+func NewSQLiteStateStore(path string) (*SQLStateStore, error) {
+	// One writer at a time; WAL lets readers run alongside it
+	db, err := gorm.Open(sqlite.Open(path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return NewStateStore(db)
+}
+
 // SaveState saves workflow execution state
-func (s *StateStore) SaveState(execution *Execution) error {
+func (s *SQLStateStore) SaveState(execution *Execution) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Save(newWorkflowState(execution)).Error
+}
+
+// LoadState loads workflow execution state
+func (s *SQLStateStore) LoadState(executionID string) (*Execution, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var state WorkflowState
+	if err := s.db.Where("execution_id = ?", executionID).First(&state).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to load state: %w", ErrStateNotFound)
+		}
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	return state.execution(), nil
+}
+
+// DeleteState deletes workflow execution state and its effects
+func (s *SQLStateStore) DeleteState(executionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("execution_id = ?", executionID).Delete(&EffectRecord{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("execution_id = ?", executionID).Delete(&WorkflowState{}).Error
+	})
+}
+
+// ListStates lists all workflow states
+func (s *SQLStateStore) ListStates(workflowID string, status WorkflowStatus, limit int) ([]*WorkflowState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var states []*WorkflowState
+	query := s.db.Model(&WorkflowState{})
+
+	if workflowID != "" {
+		query = query.Where("workflow_id = ?", workflowID)
+	}
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.Order("started_at DESC").Find(&states).Error; err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// ListChildren lists the executions started by an execution's sub-workflow
+// steps
+func (s *SQLStateStore) ListChildren(parentExecutionID string) ([]*WorkflowState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var states []*WorkflowState
+	err := s.db.Where("parent_execution_id = ?", parentExecutionID).Order("started_at").Find(&states).Error
+	return states, err
+}
+
+// LogEvent logs a workflow event
+func (s *SQLStateStore) LogEvent(executionID, stepID, eventType, message string, data map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Create(newEventLog(executionID, stepID, eventType, message, data)).Error
+}
+
+// GetEvents gets events for an execution
+func (s *SQLStateStore) GetEvents(executionID string, limit int) ([]*EventLog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []*EventLog
+	query := s.db.Where("execution_id = ?", executionID)
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.Order("timestamp DESC, id DESC").Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// CleanupOldStates removes old completed/failed states and their effects,
+// and expired idempotency keys
+func (s *SQLStateStore) CleanupOldStates(olderThan time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := time.Now().Add(-olderThan)
+
+	var deleted int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		old := tx.Model(&WorkflowState{}).
+			Select("execution_id").
+			Where("completed_at < ? AND status IN ?", cutoff, terminalStatuses)
+		if err := tx.Where("execution_id IN (?)", old).Delete(&EffectRecord{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("expires_at <= ?", time.Now().UTC()).Delete(&IdempotencyKey{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("completed_at < ? AND status IN ?", cutoff, terminalStatuses).
+			Delete(&WorkflowState{})
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// SaveTimer saves a timer
+func (s *SQLStateStore) SaveTimer(timer *Timer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *timer
+	saved.FireAt = timer.FireAt.UTC() // Compared as text by SQLite
+	return s.db.Save(&saved).Error
+}
+
+// DeleteTimer deletes a timer
+func (s *SQLStateStore) DeleteTimer(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Where("id = ?", id).Delete(&Timer{}).Error
+}
+
+// ListTimers lists timers by fire time
+func (s *SQLStateStore) ListTimers(workflowID string) ([]*Timer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var timers []*Timer
+	query := s.db.Model(&Timer{})
+
+	if workflowID != "" {
+		query = query.Where("workflow_id = ?", workflowID)
+	}
+
+	if err := query.Order("fire_at, id").Find(&timers).Error; err != nil {
+		return nil, err
+	}
+
+	return timers, nil
+}
+
+// DueTimers lists timers due at now
+func (s *SQLStateStore) DueTimers(now time.Time, limit int) ([]*Timer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var timers []*Timer
+	query := s.db.Where("fire_at <= ?", now.UTC())
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.Order("fire_at, id").Find(&timers).Error; err != nil {
+		return nil, err
+	}
+
+	return timers, nil
+}
+
+// SaveTask saves a human task
+func (s *SQLStateStore) SaveTask(task *HumanTask) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Save(task).Error
+}
+
+// LoadTask loads a human task
+func (s *SQLStateStore) LoadTask(id string) (*HumanTask, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var task HumanTask
+	if err := s.db.Where("id = ?", id).First(&task).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to load task: %w", ErrTaskNotFound)
+		}
+		return nil, fmt.Errorf("failed to load task: %w", err)
+	}
+
+	return &task, nil
+}
+
+// ListTasks lists human tasks, oldest first
+func (s *SQLStateStore) ListTasks(filter TaskFilter) ([]*HumanTask, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tasks []*HumanTask
+	query := s.db.Model(&HumanTask{})
+
+	if filter.WorkflowID != "" {
+		query = query.Where("workflow_id = ?", filter.WorkflowID)
+	}
+
+	if filter.ExecutionID != "" {
+		query = query.Where("execution_id = ?", filter.ExecutionID)
+	}
+
+	if filter.Assignee != "" {
+		query = query.Where("assignee = ?", filter.Assignee)
+	}
+
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	if err := query.Order("created_at, id").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// SaveSignalWait saves a signal wait
+func (s *SQLStateStore) SaveSignalWait(wait *SignalWait) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Save(wait).Error
+}
+
+// DeleteSignalWait deletes a signal wait
+func (s *SQLStateStore) DeleteSignalWait(executionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Where("execution_id = ?", executionID).Delete(&SignalWait{}).Error
+}
+
+// SignalWaits lists the waits for a signal by execution ID or correlation
+// key
+func (s *SQLStateStore) SignalWaits(signal, target string) ([]*SignalWait, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var waits []*SignalWait
+	err := s.db.Where("signal_name = ? AND (execution_id = ? OR correlation_key = ?)", signal, target, target).
+		Order("created_at, execution_id").
+		Find(&waits).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return waits, nil
+}
+
+// SaveEffect records a completed side effect
+func (s *SQLStateStore) SaveEffect(effect *EffectRecord) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.db.Save(effect).Error
+}
+
+// LoadEffect loads a recorded side effect
+func (s *SQLStateStore) LoadEffect(executionID, id string) (*EffectRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var effect EffectRecord
+	if err := s.db.Where("id = ? AND execution_id = ?", id, executionID).First(&effect).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEffectNotFound
+		}
+		return nil, err
+	}
+
+	return &effect, nil
+}
+
+// SaveDispatch saves a step dispatch
+func (s *SQLStateStore) SaveDispatch(dispatch *StepDispatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *dispatch
+	saved.LeaseUntil = dispatch.LeaseUntil.UTC() // Compared as text by SQLite
+	return s.db.Save(&saved).Error
+}
+
+// UpdateDispatch saves a step dispatch unless it changed meanwhile
+func (s *SQLStateStore) UpdateDispatch(dispatch *StepDispatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *dispatch
+	saved.LeaseUntil = dispatch.LeaseUntil.UTC()
+	saved.Version++
+	result := s.db.Model(&StepDispatch{}).
+		Where("id = ? AND version = ?", dispatch.ID, dispatch.Version).
+		Select("*").
+		Updates(&saved)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDispatchConflict
+	}
+
+	dispatch.Version = saved.Version
+	return nil
+}
+
+// LoadDispatch loads a step dispatch
+func (s *SQLStateStore) LoadDispatch(id string) (*StepDispatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var dispatch StepDispatch
+	if err := s.db.Where("id = ?", id).First(&dispatch).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDispatchNotFound
+		}
+		return nil, err
+	}
+
+	return &dispatch, nil
+}
+
+// DeleteDispatch deletes a step dispatch
+func (s *SQLStateStore) DeleteDispatch(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Where("id = ?", id).Delete(&StepDispatch{}).Error
+}
+
+// ExpiredDispatches lists step dispatches whose lease ended
+func (s *SQLStateStore) ExpiredDispatches(now time.Time, limit int) ([]*StepDispatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var dispatches []*StepDispatch
+	query := s.db.Where("lease_until <= ?", now.UTC())
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.Order("lease_until, id").Find(&dispatches).Error; err != nil {
+		return nil, err
+	}
+
+	return dispatches, nil
+}
+
+// ClaimIdempotencyKey saves an idempotency key unless an unexpired one
+// exists
+func (s *SQLStateStore) ClaimIdempotencyKey(key *IdempotencyKey) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC() // Compared as text by SQLite
+	if !key.ExpiresAt.After(now) {
+		return key.ExecutionID, nil
+	}
+	saved := *key
+	saved.ExpiresAt = key.ExpiresAt.UTC()
+
+	var executionID string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND expires_at <= ?", key.ID, now).Delete(&IdempotencyKey{}).Error; err != nil {
+			return err
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&saved)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			executionID = key.ExecutionID
+			return nil
+		}
+
+		var existing IdempotencyKey
+		if err := tx.Where("id = ?", key.ID).First(&existing).Error; err != nil {
+			return err
+		}
+		executionID = existing.ExecutionID
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return executionID, nil
+}
+
+// terminalStatuses are the statuses of executions that have finished
+var terminalStatuses = []WorkflowStatus{StatusCompleted, StatusFailed, StatusCancelled}
+
+// newWorkflowState serializes an execution
+func newWorkflowState(execution *Execution) *WorkflowState {
 	execution.mu.RLock()
 	defer execution.mu.RUnlock()
 
@@ -73,6 +598,10 @@ func (s *StateStore) SaveState(execution *Execution) error {
 		CurrentStep: execution.CurrentStep,
 		StartedAt:   execution.StartedAt,
 		CompletedAt: execution.CompletedAt,
+
+		ParentExecutionID: execution.ParentExecutionID,
+		ParentStepID:      execution.ParentStepID,
+		Awaited:           execution.Awaited,
 	}
 
 	if execution.Error != nil {
@@ -100,19 +629,17 @@ func (s *StateStore) SaveState(execution *Execution) error {
 		state.StepResults = string(resultsJSON)
 	}
 
-	return s.db.Save(state).Error
-}
-
-// LoadState loads workflow execution state
-func (s *StateStore) LoadState(executionID string) (*Execution, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	var state WorkflowState
-	if err := s.db.Where("execution_id = ?", executionID).First(&state).Error; err != nil {
-		return nil, fmt.Errorf("failed to load state: %w", err)
+	if len(execution.Compensations) > 0 {
+		if compensationsJSON, err := json.Marshal(execution.Compensations); err == nil {
+			state.Compensations = string(compensationsJSON)
+		}
 	}
 
+	return state
+}
+
+// execution deserializes a state
+func (state *WorkflowState) execution() *Execution {
 	execution := &Execution{
 		ID:          state.ID,
 		WorkflowID:  state.WorkflowID,
@@ -130,6 +657,10 @@ func (s *StateStore) LoadState(executionID string) (*Execution, error) {
 			StepResults: make(map[string]interface{}),
 			Metadata:    make(map[string]string),
 		},
+
+		ParentExecutionID: state.ParentExecutionID,
+		ParentStepID:      state.ParentStepID,
+		Awaited:           state.Awaited,
 	}
 
 	if state.Error != "" {
@@ -153,49 +684,73 @@ func (s *StateStore) LoadState(executionID string) (*Execution, error) {
 		json.Unmarshal([]byte(state.StepResults), &execution.StepResults)
 	}
 
-	return execution, nil
-}
+	if state.Compensations != "" {
+		json.Unmarshal([]byte(state.Compensations), &execution.Compensations)
+	}
 
-// DeleteState deletes workflow execution state
-func (s *StateStore) DeleteState(executionID string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	// Steps after a resumed one can read the outputs of those before it
+	for stepID, result := range execution.StepResults {
+		if result != nil && result.Status == StatusCompleted {
+			execution.Context.StepResults[stepID] = result.Output
+		}
+	}
 
-	return s.db.Where("execution_id = ?", executionID).Delete(&WorkflowState{}).Error
+	return execution
 }
 
-// ListStates lists all workflow states
-func (s *StateStore) ListStates(workflowID string, status WorkflowStatus, limit int) ([]*WorkflowState, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	var states []*WorkflowState
-	query := s.db.Model(&WorkflowState{})
+// stepResultJSON is a StepResult with its error as a message, which
+// encoding/json would otherwise write as {}
+type stepResultJSON struct {
+	StepID      string
+	Status      WorkflowStatus
+	Output      interface{}
+	Error       interface{} `json:",omitempty"`
+	Attempts    int
+	StartedAt   time.Time
+	CompletedAt *time.Time
+	Duration    time.Duration
+}
 
-	if workflowID != "" {
-		query = query.Where("workflow_id = ?", workflowID)
+// MarshalJSON keeps the error message of a persisted result
+func (r *StepResult) MarshalJSON() ([]byte, error) {
+	encoded := stepResultJSON{
+		StepID:      r.StepID,
+		Status:      r.Status,
+		Output:      r.Output,
+		Attempts:    r.Attempts,
+		StartedAt:   r.StartedAt,
+		CompletedAt: r.CompletedAt,
+		Duration:    r.Duration,
 	}
-
-	if status != "" {
-		query = query.Where("status = ?", status)
+	if r.Error != nil {
+		encoded.Error = r.Error.Error()
 	}
+	return json.Marshal(encoded)
+}
 
-	if limit > 0 {
-		query = query.Limit(limit)
+// UnmarshalJSON restores a result written by MarshalJSON
+func (r *StepResult) UnmarshalJSON(data []byte) error {
+	var decoded stepResultJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
 	}
-
-	if err := query.Order("started_at DESC").Find(&states).Error; err != nil {
-		return nil, err
+	*r = StepResult{
+		StepID:      decoded.StepID,
+		Status:      decoded.Status,
+		Output:      decoded.Output,
+		Attempts:    decoded.Attempts,
+		StartedAt:   decoded.StartedAt,
+		CompletedAt: decoded.CompletedAt,
+		Duration:    decoded.Duration,
 	}
-
-	return states, nil
+	// Results saved before errors were kept have {} instead
+	if message, ok := decoded.Error.(string); ok && message != "" {
+		r.Error = errors.New(message)
+	}
+	return nil
 }
 
-// LogEvent logs a workflow event
-func (s *StateStore) LogEvent(executionID, stepID, eventType, message string, data map[string]interface{}) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
+func newEventLog(executionID, stepID, eventType, message string, data map[string]interface{}) *EventLog {
 	event := &EventLog{
 		ExecutionID: executionID,
 		StepID:      stepID,
@@ -208,65 +763,41 @@ func (s *StateStore) LogEvent(executionID, stepID, eventType, message string, da
 		event.Data = string(dataJSON)
 	}
 
-	return s.db.Create(event).Error
-}
-
-// GetEvents gets events for an execution
-func (s *StateStore) GetEvents(executionID string, limit int) ([]*EventLog, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	var events []*EventLog
-	query := s.db.Where("execution_id = ?", executionID)
-
-	if limit > 0 {
-		query = query.Limit(limit)
-	}
-
-	if err := query.Order("timestamp DESC").Find(&events).Error; err != nil {
-		return nil, err
-	}
-
-	return events, nil
-}
-
-// CleanupOldStates removes old completed/failed states
-func (s *StateStore) CleanupOldStates(olderThan time.Duration) (int64, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	cutoff := time.Now().Add(-olderThan)
-
-	result := s.db.Where("completed_at < ? AND status IN ?", cutoff, []WorkflowStatus{StatusCompleted, StatusFailed, StatusCancelled}).
-		Delete(&WorkflowState{})
-
-	if result.Error != nil {
-		return 0, result.Error
-	}
-
-	return result.RowsAffected, nil
+	return event
 }
 
 // StatefulWorkflowEngine workflow engine with state persistence
 type StatefulWorkflowEngine struct {
 	*WorkflowEngine
-	stateStore *StateStore
+	stateStore StateStore
 }
 
 // NewStatefulWorkflowEngine creates a new stateful workflow engine
-func NewStatefulWorkflowEngine(stateStore *StateStore) *StatefulWorkflowEngine {
-	return &StatefulWorkflowEngine{
+func NewStatefulWorkflowEngine(stateStore StateStore) *StatefulWorkflowEngine {
+	e := &StatefulWorkflowEngine{
 		WorkflowEngine: NewWorkflowEngine(),
 		stateStore:     stateStore,
 	}
+	e.suspend[StepTypeWaitForSignal] = e.awaitSignal
+	e.suspend[StepTypeSubWorkflow] = e.startSubWorkflow
+	e.suspend[StepTypeSubflow] = e.startSubWorkflow
+	e.finished = e.executionFinished
+	e.effects = stateStore
+	e.starts = stateStore
+	return e
 }
 
-// StartExecution starts a workflow execution with state persistence
-func (e *StatefulWorkflowEngine) StartExecution(ctx context.Context, workflowID string, input map[string]interface{}) (*Execution, error) {
-	execution, err := e.WorkflowEngine.StartExecution(ctx, workflowID, input)
+// StartExecution starts a workflow execution with state persistence. With
+// WithIdempotencyKey, a key already used on any instance sharing the
+// state store returns the execution it started instead.
+func (e *StatefulWorkflowEngine) StartExecution(ctx context.Context, workflowID string, input map[string]interface{}, opts ...StartOption) (*Execution, error) {
+	execution, duplicate, err := e.start(ctx, workflowID, input, opts)
 	if err != nil {
 		return nil, err
 	}
+	if duplicate != "" {
+		return e.startedExecution(duplicate)
+	}
 
 	// Save initial state
 	if err := e.stateStore.SaveState(execution); err != nil {
@@ -290,13 +821,24 @@ func (e *StatefulWorkflowEngine) monitorExecution(ctx context.Context, execution
 	for {
 		select {
 		case <-ctx.Done():
-			e.stateStore.SaveState(execution)
+			execution.mu.RLock()
+			paused := execution.Status == StatusPaused
+			execution.mu.RUnlock()
+			if !paused {
+				e.stateStore.SaveState(execution)
+			}
 			return
 		case <-ticker.C:
 			execution.mu.RLock()
 			status := execution.Status
 			execution.mu.RUnlock()
 
+			// Saved by the step that paused it, and possibly resumed since
+			// by a worker or timer; this copy is stale
+			if status == StatusPaused {
+				return
+			}
+
 			// Save current state
 			e.stateStore.SaveState(execution)
 
@@ -320,6 +862,10 @@ func (e *StatefulWorkflowEngine) ResumeExecution(ctx context.Context, executionI
 	if execution.Status != StatusPaused && execution.Status != StatusFailed {
 		return fmt.Errorf("execution cannot be resumed: status=%s", execution.Status)
 	}
+	// Resuming after the rollback would run later steps without earlier ones
+	if len(execution.Compensations) > 0 {
+		return fmt.Errorf("execution cannot be resumed: rolled back")
+	}
 
 	// Get workflow
 	workflow, err := e.GetWorkflow(execution.WorkflowID)
@@ -338,8 +884,69 @@ func (e *StatefulWorkflowEngine) ResumeExecution(ctx context.Context, executionI
 	// Log resume event
 	e.stateStore.LogEvent(execution.ID, "", "resumed", "Workflow execution resumed", nil)
 
-	// Continue execution
+	e.mu.Lock()
+	e.executions[execution.ID] = execution
+	e.mu.Unlock()
+
+	// Continue execution after the steps already completed
 	go e.executeWorkflow(ctx, workflow, execution)
+	go e.monitorExecution(ctx, execution)
+
+	return nil
+}
+
+// CancelExecution cancels a running execution, or one paused in a wait,
+// human task, wait for signal, dispatched step or sub-workflow, deleting
+// or cancelling what it waits for
+func (e *StatefulWorkflowEngine) CancelExecution(executionID string) error {
+	if execution, err := e.GetExecution(executionID); err == nil {
+		execution.mu.RLock()
+		status := execution.Status
+		execution.mu.RUnlock()
+		if status == StatusRunning {
+			return e.WorkflowEngine.CancelExecution(executionID)
+		}
+	}
+
+	execution, err := e.stateStore.LoadState(executionID)
+	if err != nil {
+		return fmt.Errorf("failed to load execution state: %w", err)
+	}
+	if execution.Status != StatusPaused {
+		return fmt.Errorf("execution not running: %s", executionID)
+	}
+
+	now := time.Now()
+	execution.Status = StatusCancelled
+	execution.CompletedAt = &now
+	if err := e.stateStore.SaveState(execution); err != nil {
+		return err
+	}
+	if err := e.stateStore.DeleteTimer(waitTimerID(executionID)); err != nil {
+		return err
+	}
+	if err := e.stateStore.DeleteSignalWait(executionID); err != nil {
+		return err
+	}
+	// A worker running the step finds it gone and discards its result
+	if err := e.stateStore.DeleteDispatch(dispatchID(executionID, execution.CurrentStep)); err != nil {
+		return err
+	}
+	if err := cancelTasks(e.stateStore, executionID); err != nil {
+		return err
+	}
+	if err := e.cancelChildren(execution); err != nil {
+		return err
+	}
+	e.stateStore.LogEvent(executionID, "", "cancelled", "Workflow execution cancelled", nil)
+	// A parent waiting for this execution goes on
+	e.executionFinished(execution)
+
+	e.mu.Lock()
+	if _, exists := e.executions[executionID]; exists {
+		e.executions[executionID] = execution
+	}
+	e.mu.Unlock()
 
 	return nil
 }
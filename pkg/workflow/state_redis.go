@@ -0,0 +1,777 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStateStore stores workflow execution state in Redis. Each state is
+// a JSON value, indexed by start time overall, per workflow and per parent
+// execution, and by completion time once finished; events are a list per
+// execution.
+// Deleting or cleaning up a state deletes its events and effects too, the
+// latter a hash of JSON values per execution. Timers are JSON values
+// indexed by fire time, human tasks JSON values indexed by creation time
+// overall and while open, signal waits JSON values indexed by signal and
+// target, step dispatches JSON values indexed by lease end, updated in
+// WATCH transactions, and idempotency keys strings expiring with the key.
+type RedisStateStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStateStore creates a state store on a Redis client, with keys
+// under prefix (default "workflow:")
+func NewRedisStateStore(client *redis.Client, prefix string) *RedisStateStore {
+	if prefix == "" {
+		prefix = "workflow:"
+	}
+	return &RedisStateStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStateStore) stateKey(executionID string) string {
+	return s.prefix + "state:" + executionID
+}
+
+func (s *RedisStateStore) eventsKey(executionID string) string {
+	return s.prefix + "events:" + executionID
+}
+
+func (s *RedisStateStore) effectsKey(executionID string) string {
+	return s.prefix + "effects:" + executionID
+}
+
+func (s *RedisStateStore) idempotencyKey(id string) string {
+	return s.prefix + "idempotency:" + id
+}
+
+func (s *RedisStateStore) dispatchKey(id string) string {
+	return s.prefix + "dispatch:" + id
+}
+
+func (s *RedisStateStore) workflowKey(workflowID string) string {
+	return s.prefix + "workflow:" + workflowID
+}
+
+// childrenKey indexes the executions started by an execution's
+// sub-workflow steps by start time
+func (s *RedisStateStore) childrenKey(executionID string) string {
+	return s.prefix + "children:" + executionID
+}
+
+func (s *RedisStateStore) timerKey(id string) string {
+	return s.prefix + "timer:" + id
+}
+
+func (s *RedisStateStore) taskKey(id string) string {
+	return s.prefix + "task:" + id
+}
+
+func (s *RedisStateStore) signalWaitKey(executionID string) string {
+	return s.prefix + "signal-wait:" + executionID
+}
+
+// signalKey indexes the waits for a signal by execution ID or correlation
+// key
+func (s *RedisStateStore) signalKey(signal, target string) string {
+	return s.prefix + "signal:" + signal + ":" + target
+}
+
+// Indexes of every state by start time, of finished states by completion
+// time, of timers by fire time and of tasks by creation time
+func (s *RedisStateStore) startedKey() string   { return s.prefix + "started" }
+func (s *RedisStateStore) completedKey() string { return s.prefix + "completed" }
+func (s *RedisStateStore) timersKey() string    { return s.prefix + "timers" }
+func (s *RedisStateStore) tasksKey() string     { return s.prefix + "tasks" }
+func (s *RedisStateStore) openTasksKey() string { return s.prefix + "tasks:open" }
+func (s *RedisStateStore) leasesKey() string    { return s.prefix + "dispatches" }
+
+// SaveState saves workflow execution state
+func (s *RedisStateStore) SaveState(execution *Execution) error {
+	ctx := context.Background()
+	state := newWorkflowState(execution)
+	state.UpdatedAt = time.Now()
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	started := redis.Z{Score: float64(state.StartedAt.UnixNano()), Member: state.ExecutionID}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.stateKey(state.ExecutionID), data, 0)
+		pipe.ZAdd(ctx, s.startedKey(), started)
+		pipe.ZAdd(ctx, s.workflowKey(state.WorkflowID), started)
+		if state.ParentExecutionID != "" {
+			pipe.ZAdd(ctx, s.childrenKey(state.ParentExecutionID), started)
+		}
+		if finished(state) {
+			pipe.ZAdd(ctx, s.completedKey(), redis.Z{Score: float64(state.CompletedAt.UnixNano()), Member: state.ExecutionID})
+		} else {
+			pipe.ZRem(ctx, s.completedKey(), state.ExecutionID)
+		}
+		return nil
+	})
+	return err
+}
+
+// LoadState loads workflow execution state
+func (s *RedisStateStore) LoadState(executionID string) (*Execution, error) {
+	state, err := s.load(context.Background(), executionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	return state.execution(), nil
+}
+
+func (s *RedisStateStore) load(ctx context.Context, executionID string) (*WorkflowState, error) {
+	data, err := s.client.Get(ctx, s.stateKey(executionID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrStateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state WorkflowState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// DeleteState deletes workflow execution state, its events and effects
+func (s *RedisStateStore) DeleteState(executionID string) error {
+	ctx := context.Background()
+	state, err := s.load(ctx, executionID)
+	if errors.Is(err, ErrStateNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.delete(ctx, state)
+}
+
+func (s *RedisStateStore) delete(ctx context.Context, state *WorkflowState) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.stateKey(state.ExecutionID), s.eventsKey(state.ExecutionID), s.effectsKey(state.ExecutionID), s.childrenKey(state.ExecutionID))
+		pipe.ZRem(ctx, s.startedKey(), state.ExecutionID)
+		if state.ParentExecutionID != "" {
+			pipe.ZRem(ctx, s.childrenKey(state.ParentExecutionID), state.ExecutionID)
+		}
+		pipe.ZRem(ctx, s.workflowKey(state.WorkflowID), state.ExecutionID)
+		pipe.ZRem(ctx, s.completedKey(), state.ExecutionID)
+		return nil
+	})
+	return err
+}
+
+// ListStates lists workflow states, newest first
+func (s *RedisStateStore) ListStates(workflowID string, status WorkflowStatus, limit int) ([]*WorkflowState, error) {
+	ctx := context.Background()
+	index := s.startedKey()
+	if workflowID != "" {
+		index = s.workflowKey(workflowID)
+	}
+
+	// Statuses are not indexed, so read pages until enough match
+	const page = 100
+	var states []*WorkflowState
+	for start := int64(0); ; start += page {
+		ids, err := s.client.ZRevRange(ctx, index, start, start+page-1).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return states, nil
+		}
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = s.stateKey(id)
+		}
+		values, err := s.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			data, ok := value.(string)
+			if !ok {
+				continue // Deleted meanwhile
+			}
+			var state WorkflowState
+			if err := json.Unmarshal([]byte(data), &state); err != nil {
+				return nil, err
+			}
+			if status != "" && state.Status != status {
+				continue
+			}
+			states = append(states, &state)
+			if limit > 0 && len(states) == limit {
+				return states, nil
+			}
+		}
+	}
+}
+
+// ListChildren lists the executions started by an execution's sub-workflow
+// steps
+func (s *RedisStateStore) ListChildren(parentExecutionID string) ([]*WorkflowState, error) {
+	ctx := context.Background()
+	ids, err := s.client.ZRange(ctx, s.childrenKey(parentExecutionID), 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.stateKey(id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]*WorkflowState, 0, len(values))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue // Deleted meanwhile
+		}
+		var state WorkflowState
+		if err := json.Unmarshal([]byte(data), &state); err != nil {
+			return nil, err
+		}
+		states = append(states, &state)
+	}
+	return states, nil
+}
+
+// LogEvent logs a workflow event
+func (s *RedisStateStore) LogEvent(executionID, stepID, eventType, message string, data map[string]interface{}) error {
+	ctx := context.Background()
+	event := newEventLog(executionID, stepID, eventType, message, data)
+	id, err := s.client.Incr(ctx, s.prefix+"event-id").Result()
+	if err != nil {
+		return err
+	}
+	event.ID = uint(id)
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	return s.client.RPush(ctx, s.eventsKey(executionID), encoded).Err()
+}
+
+// GetEvents gets events for an execution, newest first
+func (s *RedisStateStore) GetEvents(executionID string, limit int) ([]*EventLog, error) {
+	start := int64(0)
+	if limit > 0 {
+		start = -int64(limit)
+	}
+	values, err := s.client.LRange(context.Background(), s.eventsKey(executionID), start, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*EventLog, 0, len(values))
+	for i := len(values) - 1; i >= 0; i-- {
+		var event EventLog
+		if err := json.Unmarshal([]byte(values[i]), &event); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	return events, nil
+}
+
+// CleanupOldStates removes old completed/failed states, their events and
+// effects
+func (s *RedisStateStore) CleanupOldStates(olderThan time.Duration) (int64, error) {
+	ctx := context.Background()
+	cutoff := time.Now().Add(-olderThan).UnixNano()
+	ids, err := s.client.ZRangeByScore(ctx, s.completedKey(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + strconv.FormatInt(cutoff, 10),
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, id := range ids {
+		state, err := s.load(ctx, id)
+		if errors.Is(err, ErrStateNotFound) {
+			s.client.ZRem(ctx, s.completedKey(), id)
+			continue
+		}
+		if err != nil {
+			return deleted, err
+		}
+		if err := s.delete(ctx, state); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// SaveTimer saves a timer
+func (s *RedisStateStore) SaveTimer(timer *Timer) error {
+	ctx := context.Background()
+	data, err := json.Marshal(timer)
+	if err != nil {
+		return fmt.Errorf("failed to encode timer: %w", err)
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.timerKey(timer.ID), data, 0)
+		pipe.ZAdd(ctx, s.timersKey(), redis.Z{Score: float64(timer.FireAt.UnixNano()), Member: timer.ID})
+		return nil
+	})
+	return err
+}
+
+// DeleteTimer deletes a timer
+func (s *RedisStateStore) DeleteTimer(id string) error {
+	ctx := context.Background()
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.timerKey(id))
+		pipe.ZRem(ctx, s.timersKey(), id)
+		return nil
+	})
+	return err
+}
+
+// ListTimers lists timers by fire time
+func (s *RedisStateStore) ListTimers(workflowID string) ([]*Timer, error) {
+	ids, err := s.client.ZRange(context.Background(), s.timersKey(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	timers, err := s.timers(ids)
+	if err != nil || workflowID == "" {
+		return timers, err
+	}
+
+	matching := timers[:0]
+	for _, timer := range timers {
+		if timer.WorkflowID == workflowID {
+			matching = append(matching, timer)
+		}
+	}
+	return matching, nil
+}
+
+// DueTimers lists timers due at now
+func (s *RedisStateStore) DueTimers(now time.Time, limit int) ([]*Timer, error) {
+	ids, err := s.client.ZRangeByScore(context.Background(), s.timersKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixNano(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return s.timers(ids)
+}
+
+// timers loads timers in the order of ids
+func (s *RedisStateStore) timers(ids []string) ([]*Timer, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.timerKey(id)
+	}
+	values, err := s.client.MGet(context.Background(), keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	timers := make([]*Timer, 0, len(values))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue // Deleted meanwhile
+		}
+		var timer Timer
+		if err := json.Unmarshal([]byte(data), &timer); err != nil {
+			return nil, err
+		}
+		timers = append(timers, &timer)
+	}
+	return timers, nil
+}
+
+// SaveTask saves a human task
+func (s *RedisStateStore) SaveTask(task *HumanTask) error {
+	ctx := context.Background()
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to encode task: %w", err)
+	}
+	created := redis.Z{Score: float64(task.CreatedAt.UnixNano()), Member: task.ID}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.taskKey(task.ID), data, 0)
+		pipe.ZAdd(ctx, s.tasksKey(), created)
+		if task.Open() {
+			pipe.ZAdd(ctx, s.openTasksKey(), created)
+		} else {
+			pipe.ZRem(ctx, s.openTasksKey(), task.ID)
+		}
+		return nil
+	})
+	return err
+}
+
+// LoadTask loads a human task
+func (s *RedisStateStore) LoadTask(id string) (*HumanTask, error) {
+	data, err := s.client.Get(context.Background(), s.taskKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to load task: %w", ErrTaskNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load task: %w", err)
+	}
+	var task HumanTask
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, fmt.Errorf("failed to load task: %w", err)
+	}
+	return &task, nil
+}
+
+// ListTasks lists human tasks, oldest first
+func (s *RedisStateStore) ListTasks(filter TaskFilter) ([]*HumanTask, error) {
+	ctx := context.Background()
+	index := s.tasksKey()
+	if len(filter.Statuses) > 0 && onlyOpen(filter.Statuses) {
+		index = s.openTasksKey()
+	}
+
+	// Only creation time is indexed, so read pages until enough match
+	const page = 100
+	var tasks []*HumanTask
+	for start := int64(0); ; start += page {
+		ids, err := s.client.ZRange(ctx, index, start, start+page-1).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return tasks, nil
+		}
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = s.taskKey(id)
+		}
+		values, err := s.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			data, ok := value.(string)
+			if !ok {
+				continue // Deleted meanwhile
+			}
+			var task HumanTask
+			if err := json.Unmarshal([]byte(data), &task); err != nil {
+				return nil, err
+			}
+			if !filter.matches(&task) {
+				continue
+			}
+			tasks = append(tasks, &task)
+			if filter.Limit > 0 && len(tasks) == filter.Limit {
+				return tasks, nil
+			}
+		}
+	}
+}
+
+// SaveSignalWait saves a signal wait
+func (s *RedisStateStore) SaveSignalWait(wait *SignalWait) error {
+	ctx := context.Background()
+	previous, err := s.signalWait(ctx, wait.ExecutionID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(wait)
+	if err != nil {
+		return fmt.Errorf("failed to encode signal wait: %w", err)
+	}
+
+	created := redis.Z{Score: float64(wait.CreatedAt.UnixNano()), Member: wait.ExecutionID}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if previous != nil {
+			s.unindexSignalWait(ctx, pipe, previous)
+		}
+		pipe.Set(ctx, s.signalWaitKey(wait.ExecutionID), data, 0)
+		pipe.ZAdd(ctx, s.signalKey(wait.Signal, wait.ExecutionID), created)
+		if wait.CorrelationKey != "" {
+			pipe.ZAdd(ctx, s.signalKey(wait.Signal, wait.CorrelationKey), created)
+		}
+		return nil
+	})
+	return err
+}
+
+// DeleteSignalWait deletes a signal wait
+func (s *RedisStateStore) DeleteSignalWait(executionID string) error {
+	ctx := context.Background()
+	wait, err := s.signalWait(ctx, executionID)
+	if err != nil || wait == nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.unindexSignalWait(ctx, pipe, wait)
+		pipe.Del(ctx, s.signalWaitKey(executionID))
+		return nil
+	})
+	return err
+}
+
+// SignalWaits lists the waits for a signal by execution ID or correlation
+// key
+func (s *RedisStateStore) SignalWaits(signal, target string) ([]*SignalWait, error) {
+	ctx := context.Background()
+	ids, err := s.client.ZRange(ctx, s.signalKey(signal, target), 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.signalWaitKey(id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	waits := make([]*SignalWait, 0, len(values))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue // Deleted meanwhile
+		}
+		var wait SignalWait
+		if err := json.Unmarshal([]byte(data), &wait); err != nil {
+			return nil, err
+		}
+		waits = append(waits, &wait)
+	}
+	return waits, nil
+}
+
+// SaveEffect records a completed side effect
+func (s *RedisStateStore) SaveEffect(effect *EffectRecord) error {
+	data, err := json.Marshal(effect)
+	if err != nil {
+		return fmt.Errorf("failed to encode effect: %w", err)
+	}
+	return s.client.HSet(context.Background(), s.effectsKey(effect.ExecutionID), effect.ID, data).Err()
+}
+
+// LoadEffect loads a recorded side effect
+func (s *RedisStateStore) LoadEffect(executionID, id string) (*EffectRecord, error) {
+	data, err := s.client.HGet(context.Background(), s.effectsKey(executionID), id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrEffectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var effect EffectRecord
+	if err := json.Unmarshal(data, &effect); err != nil {
+		return nil, err
+	}
+	return &effect, nil
+}
+
+// ClaimIdempotencyKey sets an idempotency key's execution ID unless it is
+// set, expiring with the key
+func (s *RedisStateStore) ClaimIdempotencyKey(key *IdempotencyKey) (string, error) {
+	ctx := context.Background()
+	for {
+		ttl := time.Until(key.ExpiresAt)
+		if ttl <= 0 {
+			return key.ExecutionID, nil
+		}
+		claimed, err := s.client.SetNX(ctx, s.idempotencyKey(key.ID), key.ExecutionID, ttl).Result()
+		if err != nil {
+			return "", err
+		}
+		if claimed {
+			return key.ExecutionID, nil
+		}
+
+		executionID, err := s.client.Get(ctx, s.idempotencyKey(key.ID)).Result()
+		if errors.Is(err, redis.Nil) {
+			continue // Expired meanwhile
+		}
+		if err != nil {
+			return "", err
+		}
+		return executionID, nil
+	}
+}
+
+// SaveDispatch saves a step dispatch
+func (s *RedisStateStore) SaveDispatch(dispatch *StepDispatch) error {
+	ctx := context.Background()
+	data, err := json.Marshal(dispatch)
+	if err != nil {
+		return fmt.Errorf("failed to encode step dispatch: %w", err)
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.dispatchKey(dispatch.ID), data, 0)
+		pipe.ZAdd(ctx, s.leasesKey(), redis.Z{Score: float64(dispatch.LeaseUntil.UnixNano()), Member: dispatch.ID})
+		return nil
+	})
+	return err
+}
+
+// UpdateDispatch saves a step dispatch unless it changed meanwhile
+func (s *RedisStateStore) UpdateDispatch(dispatch *StepDispatch) error {
+	ctx := context.Background()
+	key := s.dispatchKey(dispatch.ID)
+	saved := *dispatch
+	saved.Version++
+	saved.UpdatedAt = time.Now()
+	data, err := json.Marshal(&saved)
+	if err != nil {
+		return fmt.Errorf("failed to encode step dispatch: %w", err)
+	}
+
+	err = s.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := s.dispatch(ctx, tx, dispatch.ID)
+		if err != nil {
+			return err
+		}
+		if current.Version != dispatch.Version {
+			return ErrDispatchConflict
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, 0)
+			pipe.ZAdd(ctx, s.leasesKey(), redis.Z{Score: float64(saved.LeaseUntil.UnixNano()), Member: saved.ID})
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) || errors.Is(err, ErrDispatchNotFound) {
+		return ErrDispatchConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	dispatch.Version = saved.Version
+	dispatch.UpdatedAt = saved.UpdatedAt
+	return nil
+}
+
+// LoadDispatch loads a step dispatch
+func (s *RedisStateStore) LoadDispatch(id string) (*StepDispatch, error) {
+	return s.dispatch(context.Background(), s.client, id)
+}
+
+// DeleteDispatch deletes a step dispatch
+func (s *RedisStateStore) DeleteDispatch(id string) error {
+	ctx := context.Background()
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.dispatchKey(id))
+		pipe.ZRem(ctx, s.leasesKey(), id)
+		return nil
+	})
+	return err
+}
+
+// ExpiredDispatches lists step dispatches whose lease ended
+func (s *RedisStateStore) ExpiredDispatches(now time.Time, limit int) ([]*StepDispatch, error) {
+	ctx := context.Background()
+	ids, err := s.client.ZRangeByScore(ctx, s.leasesKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixNano(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	dispatches := make([]*StepDispatch, 0, len(ids))
+	for _, id := range ids {
+		dispatch, err := s.dispatch(ctx, s.client, id)
+		if errors.Is(err, ErrDispatchNotFound) {
+			continue // Deleted meanwhile
+		}
+		if err != nil {
+			return nil, err
+		}
+		dispatches = append(dispatches, dispatch)
+	}
+	return dispatches, nil
+}
+
+// dispatch loads a step dispatch, within a WATCH transaction or not
+func (s *RedisStateStore) dispatch(ctx context.Context, client redis.Cmdable, id string) (*StepDispatch, error) {
+	data, err := client.Get(ctx, s.dispatchKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrDispatchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var dispatch StepDispatch
+	if err := json.Unmarshal(data, &dispatch); err != nil {
+		return nil, err
+	}
+	return &dispatch, nil
+}
+
+// signalWait loads an execution's signal wait, or nil
+func (s *RedisStateStore) signalWait(ctx context.Context, executionID string) (*SignalWait, error) {
+	data, err := s.client.Get(ctx, s.signalWaitKey(executionID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var wait SignalWait
+	if err := json.Unmarshal(data, &wait); err != nil {
+		return nil, err
+	}
+	return &wait, nil
+}
+
+func (s *RedisStateStore) unindexSignalWait(ctx context.Context, pipe redis.Pipeliner, wait *SignalWait) {
+	pipe.ZRem(ctx, s.signalKey(wait.Signal, wait.ExecutionID), wait.ExecutionID)
+	if wait.CorrelationKey != "" {
+		pipe.ZRem(ctx, s.signalKey(wait.Signal, wait.CorrelationKey), wait.ExecutionID)
+	}
+}
+
+// onlyOpen reports whether statuses are all of open tasks
+func onlyOpen(statuses []TaskStatus) bool {
+	for _, status := range statuses {
+		if status != TaskOpen && status != TaskClaimed {
+			return false
+		}
+	}
+	return true
+}
+
+// finished reports whether a state is of an execution that has finished
+func finished(state *WorkflowState) bool {
+	if state.CompletedAt == nil {
+		return false
+	}
+	for _, status := range terminalStatuses {
+		if state.Status == status {
+			return true
+		}
+	}
+	return false
+}
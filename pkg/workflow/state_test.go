@@ -0,0 +1,78 @@
+package workflow_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"neonexcore/pkg/workflow"
+	"neonexcore/pkg/workflow/statetest"
+
+	"github.com/glebarez/sqlite"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestSQLiteStateStore(t *testing.T) {
+	statetest.Run(t, func(t *testing.T) workflow.StateStore {
+		store, err := workflow.NewSQLiteStateStore(filepath.Join(t.TempDir(), "workflow.db"))
+		if err != nil {
+			t.Fatalf("NewSQLiteStateStore: %v", err)
+		}
+		return store
+	})
+}
+
+func TestInMemoryStateStore(t *testing.T) {
+	statetest.Run(t, func(t *testing.T) workflow.StateStore {
+		// A shared cache keeps one database across the pool's connections
+		dsn := "file:" + filepath.Base(t.Name()) + "?mode=memory&cache=shared"
+		db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+		if err != nil {
+			t.Fatalf("open database: %v", err)
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf("database handle: %v", err)
+		}
+		t.Cleanup(func() { sqlDB.Close() })
+
+		store, err := workflow.NewStateStore(db)
+		if err != nil {
+			t.Fatalf("NewStateStore: %v", err)
+		}
+		return store
+	})
+}
+
+// TestRedisStateStore runs against the server at REDIS_URL, e.g.
+// redis://localhost:6379/15, and is skipped without one
+func TestRedisStateStore(t *testing.T) {
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		t.Skip("REDIS_URL not set")
+	}
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		t.Fatalf("invalid REDIS_URL: %v", err)
+	}
+	client := redis.NewClient(options)
+	t.Cleanup(func() { client.Close() })
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	statetest.Run(t, func(t *testing.T) workflow.StateStore {
+		prefix := "test:" + t.Name() + ":"
+		t.Cleanup(func() {
+			ctx := context.Background()
+			keys, _ := client.Keys(ctx, prefix+"*").Result()
+			if len(keys) > 0 {
+				client.Del(ctx, keys...)
+			}
+		})
+		return workflow.NewRedisStateStore(client, prefix)
+	})
+}
@@ -0,0 +1,696 @@
+// Package statetest checks that a workflow.StateStore implementation
+// behaves like the others, so a store can be swapped without changing how
+// executions are resumed, listed and cleaned up, when timers fire, which
+// human tasks an inbox shows, which executions a signal reaches, which
+// side effects a retried step skips, which dispatched steps a worker may
+// claim or which execution a repeated start returns.
+//
+//	func TestRedisStateStore(t *testing.T) {
+//		statetest.Run(t, func(t *testing.T) workflow.StateStore {
+//			return workflow.NewRedisStateStore(client, "test:"+t.Name()+":")
+//		})
+//	}
+package statetest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"neonexcore/pkg/workflow"
+)
+
+// Run runs the conformance suite. newStore must return an empty store
+// each time it is called.
+func Run(t *testing.T, newStore func(t *testing.T) workflow.StateStore) {
+	tests := []struct {
+		name string
+		test func(*testing.T, workflow.StateStore)
+	}{
+		{"LoadUnknown", testLoadUnknown},
+		{"RoundTrip", testRoundTrip},
+		{"SaveReplaces", testSaveReplaces},
+		{"List", testList},
+		{"Events", testEvents},
+		{"Delete", testDelete},
+		{"Cleanup", testCleanup},
+		{"Timers", testTimers},
+		{"Tasks", testTasks},
+		{"SignalWaits", testSignalWaits},
+		{"Effects", testEffects},
+		{"Dispatches", testDispatches},
+		{"IdempotencyKeys", testIdempotencyKeys},
+		{"Children", testChildren},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+// base is a fixed start time, truncated to what every backend keeps
+var base = time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+
+func execution(id, workflowID string, status workflow.WorkflowStatus, started time.Time) *workflow.Execution {
+	return &workflow.Execution{
+		ID:          id,
+		WorkflowID:  workflowID,
+		Status:      status,
+		CurrentStep: "step-1",
+		Input:       map[string]interface{}{"order": "A-1"},
+		Output:      map[string]interface{}{},
+		StepResults: map[string]*workflow.StepResult{},
+		Context: &workflow.ExecutionContext{
+			WorkflowID:  workflowID,
+			ExecutionID: id,
+			Variables:   map[string]interface{}{},
+			StepResults: map[string]interface{}{},
+			Metadata:    map[string]string{},
+		},
+		StartedAt: started,
+	}
+}
+
+func save(t *testing.T, store workflow.StateStore, executions ...*workflow.Execution) {
+	t.Helper()
+	for _, e := range executions {
+		if err := store.SaveState(e); err != nil {
+			t.Fatalf("SaveState(%s): %v", e.ID, err)
+		}
+	}
+}
+
+func testLoadUnknown(t *testing.T, store workflow.StateStore) {
+	_, err := store.LoadState("missing")
+	if !errors.Is(err, workflow.ErrStateNotFound) {
+		t.Fatalf("LoadState of an unknown execution: got %v, want ErrStateNotFound", err)
+	}
+}
+
+func testRoundTrip(t *testing.T, store workflow.StateStore) {
+	completed := base.Add(time.Minute)
+	e := execution("exec-1", "orders", workflow.StatusFailed, base)
+	e.Output = map[string]interface{}{"total": 42.5}
+	e.Context.Variables["retries"] = 2
+	e.StepResults["step-1"] = &workflow.StepResult{StepID: "step-1", Status: workflow.StatusCompleted, Output: "ok", Attempts: 1}
+	e.CompletedAt = &completed
+	e.Error = errors.New("payment declined")
+	save(t, store, e)
+
+	loaded, err := store.LoadState("exec-1")
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	check(t, "ID", loaded.ID, "exec-1")
+	check(t, "WorkflowID", loaded.WorkflowID, "orders")
+	check(t, "Status", loaded.Status, workflow.StatusFailed)
+	check(t, "CurrentStep", loaded.CurrentStep, "step-1")
+	check(t, "Input", fmt.Sprint(loaded.Input), "map[order:A-1]")
+	check(t, "Output", fmt.Sprint(loaded.Output), "map[total:42.5]")
+	check(t, "Variables", fmt.Sprint(loaded.Context.Variables), "map[retries:2]")
+	if result := loaded.StepResults["step-1"]; result == nil || result.Status != workflow.StatusCompleted || result.Output != "ok" {
+		t.Errorf("StepResults: got %+v", loaded.StepResults)
+	}
+	if loaded.Error == nil || loaded.Error.Error() != "payment declined" {
+		t.Errorf("Error: got %v, want payment declined", loaded.Error)
+	}
+	if !loaded.StartedAt.Equal(base) {
+		t.Errorf("StartedAt: got %v, want %v", loaded.StartedAt, base)
+	}
+	if loaded.CompletedAt == nil || !loaded.CompletedAt.Equal(completed) {
+		t.Errorf("CompletedAt: got %v, want %v", loaded.CompletedAt, completed)
+	}
+}
+
+func testSaveReplaces(t *testing.T, store workflow.StateStore) {
+	e := execution("exec-1", "orders", workflow.StatusRunning, base)
+	save(t, store, e)
+	e.Status, e.CurrentStep = workflow.StatusPaused, "step-2"
+	save(t, store, e)
+
+	loaded, err := store.LoadState("exec-1")
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	check(t, "Status", loaded.Status, workflow.StatusPaused)
+	check(t, "CurrentStep", loaded.CurrentStep, "step-2")
+
+	states, err := store.ListStates("", "", 0)
+	if err != nil {
+		t.Fatalf("ListStates: %v", err)
+	}
+	check(t, "states after saving twice", len(states), 1)
+}
+
+func testList(t *testing.T, store workflow.StateStore) {
+	save(t, store,
+		execution("a", "orders", workflow.StatusRunning, base),
+		execution("b", "orders", workflow.StatusCompleted, base.Add(time.Second)),
+		execution("c", "refunds", workflow.StatusRunning, base.Add(2*time.Second)),
+		execution("d", "orders", workflow.StatusRunning, base.Add(3*time.Second)),
+	)
+
+	cases := []struct {
+		workflowID string
+		status     workflow.WorkflowStatus
+		limit      int
+		want       string
+	}{
+		{"", "", 0, "[d c b a]"},
+		{"orders", "", 0, "[d b a]"},
+		{"", workflow.StatusRunning, 0, "[d c a]"},
+		{"orders", workflow.StatusRunning, 0, "[d a]"},
+		{"", "", 2, "[d c]"},
+		{"orders", workflow.StatusRunning, 1, "[d]"},
+		{"unknown", "", 0, "[]"},
+	}
+	for _, c := range cases {
+		states, err := store.ListStates(c.workflowID, c.status, c.limit)
+		if err != nil {
+			t.Fatalf("ListStates(%q, %q, %d): %v", c.workflowID, c.status, c.limit, err)
+		}
+		ids := make([]string, len(states))
+		for i, state := range states {
+			ids[i] = state.ExecutionID
+		}
+		check(t, fmt.Sprintf("ListStates(%q, %q, %d)", c.workflowID, c.status, c.limit), fmt.Sprint(ids), c.want)
+	}
+}
+
+func testEvents(t *testing.T, store workflow.StateStore) {
+	save(t, store, execution("exec-1", "orders", workflow.StatusRunning, base))
+	for i, eventType := range []string{"started", "step_completed", "completed"} {
+		if err := store.LogEvent("exec-1", "step-1", eventType, eventType, map[string]interface{}{"n": i}); err != nil {
+			t.Fatalf("LogEvent: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond) // Distinct timestamps
+	}
+	if err := store.LogEvent("exec-2", "", "started", "other execution", nil); err != nil {
+		t.Fatalf("LogEvent: %v", err)
+	}
+
+	events, err := store.GetEvents("exec-1", 0)
+	if err != nil {
+		t.Fatalf("GetEvents: %v", err)
+	}
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.EventType
+	}
+	check(t, "events, newest first", fmt.Sprint(types), "[completed step_completed started]")
+	if len(events) > 0 {
+		check(t, "event data", events[0].Data, `{"n":2}`)
+		check(t, "event step", events[0].StepID, "step-1")
+	}
+
+	limited, err := store.GetEvents("exec-1", 2)
+	if err != nil {
+		t.Fatalf("GetEvents: %v", err)
+	}
+	if check(t, "events with limit 2", len(limited), 2) {
+		check(t, "newest event with limit", limited[0].EventType, "completed")
+	}
+}
+
+func testDelete(t *testing.T, store workflow.StateStore) {
+	save(t, store,
+		execution("a", "orders", workflow.StatusRunning, base),
+		execution("b", "orders", workflow.StatusRunning, base.Add(time.Second)),
+	)
+	if err := store.DeleteState("a"); err != nil {
+		t.Fatalf("DeleteState: %v", err)
+	}
+	if err := store.DeleteState("missing"); err != nil {
+		t.Errorf("DeleteState of an unknown execution: %v", err)
+	}
+
+	if _, err := store.LoadState("a"); !errors.Is(err, workflow.ErrStateNotFound) {
+		t.Errorf("LoadState after DeleteState: got %v, want ErrStateNotFound", err)
+	}
+	states, err := store.ListStates("orders", "", 0)
+	if err != nil {
+		t.Fatalf("ListStates: %v", err)
+	}
+	check(t, "states after DeleteState", len(states), 1)
+}
+
+func testChildren(t *testing.T, store workflow.StateStore) {
+	parent := execution("parent", "orders", workflow.StatusPaused, base)
+	first := execution("child-1", "payments", workflow.StatusCompleted, base.Add(2*time.Second))
+	first.ParentExecutionID, first.ParentStepID = "parent", "pay"
+	second := execution("child-2", "shipping", workflow.StatusRunning, base.Add(time.Second))
+	second.ParentExecutionID, second.ParentStepID, second.Awaited = "parent", "ship", true
+	save(t, store, parent, first, second, execution("other", "payments", workflow.StatusRunning, base))
+
+	loaded, err := store.LoadState("child-2")
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	check(t, "ParentExecutionID", loaded.ParentExecutionID, "parent")
+	check(t, "ParentStepID", loaded.ParentStepID, "ship")
+	check(t, "Awaited", loaded.Awaited, true)
+
+	children, err := store.ListChildren("parent")
+	if err != nil {
+		t.Fatalf("ListChildren: %v", err)
+	}
+	if check(t, "children", len(children), 2) {
+		check(t, "oldest child", children[0].ExecutionID, "child-2")
+		check(t, "newest child", children[1].ExecutionID, "child-1")
+		check(t, "child step", children[1].ParentStepID, "pay")
+	}
+
+	// Saving again keeps one entry
+	second.Awaited = false
+	save(t, store, second)
+	if err := store.DeleteState("child-1"); err != nil {
+		t.Fatalf("DeleteState: %v", err)
+	}
+	children, err = store.ListChildren("parent")
+	if err != nil {
+		t.Fatalf("ListChildren: %v", err)
+	}
+	if check(t, "children after DeleteState", len(children), 1) {
+		check(t, "Awaited after save", children[0].Awaited, false)
+	}
+	if children, err := store.ListChildren("child-1"); err != nil || len(children) != 0 {
+		t.Errorf("ListChildren of an execution without children: got %d, %v", len(children), err)
+	}
+}
+
+func testCleanup(t *testing.T, store workflow.StateStore) {
+	old := time.Now().Add(-48 * time.Hour)
+	recent := time.Now().Add(-time.Minute)
+
+	oldCompleted := execution("old-completed", "orders", workflow.StatusCompleted, old)
+	oldCompleted.CompletedAt = &old
+	oldCancelled := execution("old-cancelled", "orders", workflow.StatusCancelled, old)
+	oldCancelled.CompletedAt = &old
+	recentFailed := execution("recent-failed", "orders", workflow.StatusFailed, recent)
+	recentFailed.CompletedAt = &recent
+	oldRunning := execution("old-running", "orders", workflow.StatusRunning, old)
+	oldPaused := execution("old-paused", "orders", workflow.StatusPaused, old)
+	oldPaused.CompletedAt = &old // Not finished, whatever CompletedAt says
+	save(t, store, oldCompleted, oldCancelled, recentFailed, oldRunning, oldPaused)
+
+	deleted, err := store.CleanupOldStates(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("CleanupOldStates: %v", err)
+	}
+	check(t, "deleted", deleted, int64(2))
+
+	for _, id := range []string{"old-completed", "old-cancelled"} {
+		if _, err := store.LoadState(id); !errors.Is(err, workflow.ErrStateNotFound) {
+			t.Errorf("LoadState(%s) after cleanup: got %v, want ErrStateNotFound", id, err)
+		}
+	}
+	for _, id := range []string{"recent-failed", "old-running", "old-paused"} {
+		if _, err := store.LoadState(id); err != nil {
+			t.Errorf("LoadState(%s) after cleanup: %v", id, err)
+		}
+	}
+}
+
+func check[T comparable](t *testing.T, what string, got, want T) bool {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s: got %v, want %v", what, got, want)
+		return false
+	}
+	return true
+}
+
+func testTimers(t *testing.T, store workflow.StateStore) {
+	timers := []*workflow.Timer{
+		{ID: "wait:exec-1", Kind: workflow.TimerWait, WorkflowID: "orders", ExecutionID: "exec-1", StepID: "step-2", FireAt: base.Add(2 * time.Minute)},
+		{ID: "cron:weekly", Kind: workflow.TimerCron, WorkflowID: "reports", Cron: "0 9 * * MON", Input: `{"period":"week"}`, FireAt: base.Add(time.Minute)},
+		{ID: "wait:exec-2", Kind: workflow.TimerWait, WorkflowID: "orders", ExecutionID: "exec-2", StepID: "step-2", FireAt: base.Add(48 * time.Hour)},
+	}
+	for _, timer := range timers {
+		if err := store.SaveTimer(timer); err != nil {
+			t.Fatalf("SaveTimer(%s): %v", timer.ID, err)
+		}
+	}
+
+	ids := func(what string, timers []*workflow.Timer, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", what, err)
+		}
+		ids := make([]string, len(timers))
+		for i, timer := range timers {
+			ids[i] = timer.ID
+		}
+		return fmt.Sprint(ids)
+	}
+	all, err := store.ListTimers("")
+	check(t, "ListTimers", ids("ListTimers", all, err), "[cron:weekly wait:exec-1 wait:exec-2]")
+	orders, err := store.ListTimers("orders")
+	check(t, "ListTimers(orders)", ids("ListTimers", orders, err), "[wait:exec-1 wait:exec-2]")
+	due, err := store.DueTimers(base.Add(time.Hour), 0)
+	check(t, "DueTimers", ids("DueTimers", due, err), "[cron:weekly wait:exec-1]")
+	limited, err := store.DueTimers(base.Add(time.Hour), 1)
+	check(t, "DueTimers with limit 1", ids("DueTimers", limited, err), "[cron:weekly]")
+	exact, err := store.DueTimers(base.Add(time.Minute), 0)
+	check(t, "DueTimers at a fire time", ids("DueTimers", exact, err), "[cron:weekly]")
+
+	if len(all) > 0 {
+		trigger := all[0]
+		check(t, "Kind", trigger.Kind, workflow.TimerCron)
+		check(t, "Cron", trigger.Cron, "0 9 * * MON")
+		check(t, "Input", trigger.Input, `{"period":"week"}`)
+		if !trigger.FireAt.Equal(base.Add(time.Minute)) {
+			t.Errorf("FireAt: got %v, want %v", trigger.FireAt, base.Add(time.Minute))
+		}
+	}
+	if len(orders) > 0 {
+		check(t, "ExecutionID", orders[0].ExecutionID, "exec-1")
+		check(t, "StepID", orders[0].StepID, "step-2")
+	}
+
+	// Moving a timer replaces it
+	moved := *timers[1]
+	moved.FireAt = base.Add(72 * time.Hour)
+	if err := store.SaveTimer(&moved); err != nil {
+		t.Fatalf("SaveTimer: %v", err)
+	}
+	due, err = store.DueTimers(base.Add(time.Hour), 0)
+	check(t, "DueTimers after moving a timer", ids("DueTimers", due, err), "[wait:exec-1]")
+
+	if err := store.DeleteTimer("wait:exec-1"); err != nil {
+		t.Fatalf("DeleteTimer: %v", err)
+	}
+	if err := store.DeleteTimer("missing"); err != nil {
+		t.Errorf("DeleteTimer of an unknown timer: %v", err)
+	}
+	all, err = store.ListTimers("")
+	check(t, "ListTimers after DeleteTimer", ids("ListTimers", all, err), "[wait:exec-2 cron:weekly]")
+}
+
+func testTasks(t *testing.T, store workflow.StateStore) {
+	_, err := store.LoadTask("missing")
+	if !errors.Is(err, workflow.ErrTaskNotFound) {
+		t.Fatalf("LoadTask of an unknown task: got %v, want ErrTaskNotFound", err)
+	}
+
+	due := base.Add(48 * time.Hour)
+	tasks := []*workflow.HumanTask{
+		{ID: "exec-1:approve", WorkflowID: "orders", ExecutionID: "exec-1", StepID: "approve", Name: "Approve",
+			Assignee: "7", Form: `{"type":"object"}`, Status: workflow.TaskOpen, DueAt: &due, CreatedAt: base},
+		{ID: "exec-2:approve", WorkflowID: "orders", ExecutionID: "exec-2", StepID: "approve", Name: "Approve",
+			Status: workflow.TaskClaimed, ClaimedBy: "9", CreatedAt: base.Add(time.Second)},
+		{ID: "exec-3:review", WorkflowID: "refunds", ExecutionID: "exec-3", StepID: "review", Name: "Review",
+			Assignee: "7", Status: workflow.TaskCompleted, Decision: `{"approved":true}`, CreatedAt: base.Add(2 * time.Second)},
+	}
+	for _, task := range tasks {
+		if err := store.SaveTask(task); err != nil {
+			t.Fatalf("SaveTask(%s): %v", task.ID, err)
+		}
+	}
+
+	loaded, err := store.LoadTask("exec-1:approve")
+	if err != nil {
+		t.Fatalf("LoadTask: %v", err)
+	}
+	check(t, "Assignee", loaded.Assignee, "7")
+	check(t, "Status", loaded.Status, workflow.TaskOpen)
+	check(t, "Form", loaded.Form, `{"type":"object"}`)
+	if loaded.DueAt == nil || !loaded.DueAt.Equal(due) {
+		t.Errorf("DueAt: got %v, want %v", loaded.DueAt, due)
+	}
+	if !loaded.CreatedAt.Equal(base) {
+		t.Errorf("CreatedAt: got %v, want %v", loaded.CreatedAt, base)
+	}
+
+	ids := func(filter workflow.TaskFilter) string {
+		t.Helper()
+		tasks, err := store.ListTasks(filter)
+		if err != nil {
+			t.Fatalf("ListTasks(%+v): %v", filter, err)
+		}
+		ids := make([]string, len(tasks))
+		for i, task := range tasks {
+			ids[i] = task.ID
+		}
+		return fmt.Sprint(ids)
+	}
+	open := []workflow.TaskStatus{workflow.TaskOpen, workflow.TaskClaimed}
+	cases := []struct {
+		filter workflow.TaskFilter
+		want   string
+	}{
+		{workflow.TaskFilter{}, "[exec-1:approve exec-2:approve exec-3:review]"},
+		{workflow.TaskFilter{WorkflowID: "orders"}, "[exec-1:approve exec-2:approve]"},
+		{workflow.TaskFilter{ExecutionID: "exec-3"}, "[exec-3:review]"},
+		{workflow.TaskFilter{Assignee: "7"}, "[exec-1:approve exec-3:review]"},
+		{workflow.TaskFilter{Statuses: open}, "[exec-1:approve exec-2:approve]"},
+		{workflow.TaskFilter{Assignee: "7", Statuses: open}, "[exec-1:approve]"},
+		{workflow.TaskFilter{Statuses: []workflow.TaskStatus{workflow.TaskCompleted}}, "[exec-3:review]"},
+		{workflow.TaskFilter{Limit: 2}, "[exec-1:approve exec-2:approve]"},
+		{workflow.TaskFilter{WorkflowID: "unknown"}, "[]"},
+	}
+	for _, c := range cases {
+		check(t, fmt.Sprintf("ListTasks(%+v)", c.filter), ids(c.filter), c.want)
+	}
+
+	// Completing a task replaces it and takes it out of open lists
+	completed := *tasks[0]
+	completed.Status, completed.Decision = workflow.TaskCompleted, `{"approved":false}`
+	if err := store.SaveTask(&completed); err != nil {
+		t.Fatalf("SaveTask: %v", err)
+	}
+	check(t, "open tasks after completing one", ids(workflow.TaskFilter{Statuses: open}), "[exec-2:approve]")
+	check(t, "tasks after completing one", ids(workflow.TaskFilter{}), "[exec-1:approve exec-2:approve exec-3:review]")
+	if loaded, err := store.LoadTask("exec-1:approve"); err == nil {
+		check(t, "Decision", loaded.Decision, `{"approved":false}`)
+	}
+}
+
+func testSignalWaits(t *testing.T, store workflow.StateStore) {
+	waits := []*workflow.SignalWait{
+		{ExecutionID: "exec-1", WorkflowID: "orders", StepID: "paid", Signal: "payment_confirmed", CorrelationKey: "order-42", CreatedAt: base},
+		{ExecutionID: "exec-2", WorkflowID: "invoices", StepID: "paid", Signal: "payment_confirmed", CorrelationKey: "order-42", CreatedAt: base.Add(time.Second)},
+		{ExecutionID: "exec-3", WorkflowID: "orders", StepID: "shipped", Signal: "shipped", CreatedAt: base.Add(2 * time.Second)},
+	}
+	for _, wait := range waits {
+		if err := store.SaveSignalWait(wait); err != nil {
+			t.Fatalf("SaveSignalWait(%s): %v", wait.ExecutionID, err)
+		}
+	}
+
+	ids := func(signal, target string) string {
+		t.Helper()
+		waits, err := store.SignalWaits(signal, target)
+		if err != nil {
+			t.Fatalf("SignalWaits(%q, %q): %v", signal, target, err)
+		}
+		ids := make([]string, len(waits))
+		for i, wait := range waits {
+			ids[i] = wait.ExecutionID
+		}
+		return fmt.Sprint(ids)
+	}
+	check(t, "waits by correlation key", ids("payment_confirmed", "order-42"), "[exec-1 exec-2]")
+	check(t, "waits by execution ID", ids("payment_confirmed", "exec-2"), "[exec-2]")
+	check(t, "waits without a correlation key", ids("shipped", "exec-3"), "[exec-3]")
+	check(t, "waits for another signal", ids("shipped", "order-42"), "[]")
+	check(t, "waits for an unknown target", ids("payment_confirmed", "order-7"), "[]")
+
+	if found, err := store.SignalWaits("payment_confirmed", "exec-1"); err == nil && len(found) == 1 {
+		check(t, "WorkflowID", found[0].WorkflowID, "orders")
+		check(t, "StepID", found[0].StepID, "paid")
+		check(t, "CorrelationKey", found[0].CorrelationKey, "order-42")
+		if !found[0].CreatedAt.Equal(base) {
+			t.Errorf("CreatedAt: got %v, want %v", found[0].CreatedAt, base)
+		}
+	}
+
+	// Saving an execution's wait again replaces it
+	moved := *waits[0]
+	moved.Signal, moved.CorrelationKey = "shipped", "order-43"
+	if err := store.SaveSignalWait(&moved); err != nil {
+		t.Fatalf("SaveSignalWait: %v", err)
+	}
+	check(t, "waits after replacing one", ids("payment_confirmed", "order-42"), "[exec-2]")
+	check(t, "replaced wait", ids("shipped", "order-43"), "[exec-1]")
+
+	if err := store.DeleteSignalWait("exec-2"); err != nil {
+		t.Fatalf("DeleteSignalWait: %v", err)
+	}
+	if err := store.DeleteSignalWait("missing"); err != nil {
+		t.Errorf("DeleteSignalWait of an unknown wait: %v", err)
+	}
+	check(t, "waits after DeleteSignalWait", ids("payment_confirmed", "order-42"), "[]")
+	check(t, "waits by execution ID after DeleteSignalWait", ids("payment_confirmed", "exec-2"), "[]")
+}
+
+func testEffects(t *testing.T, store workflow.StateStore) {
+	save(t, store,
+		execution("exec-1", "orders", workflow.StatusRunning, base),
+		execution("exec-2", "orders", workflow.StatusRunning, base),
+	)
+	effects := []*workflow.EffectRecord{
+		{ID: "exec-1:charge", ExecutionID: "exec-1", StepID: "charge", Output: `{"charge":"ch_1"}`, CreatedAt: base},
+		{ID: "exec-1:notify:email", ExecutionID: "exec-1", StepID: "notify", Output: "null", CreatedAt: base},
+		{ID: "exec-2:charge", ExecutionID: "exec-2", StepID: "charge", Output: `{"charge":"ch_2"}`, CreatedAt: base},
+	}
+	for _, effect := range effects {
+		if err := store.SaveEffect(effect); err != nil {
+			t.Fatalf("SaveEffect(%s): %v", effect.ID, err)
+		}
+	}
+
+	loaded, err := store.LoadEffect("exec-1", "exec-1:charge")
+	if err != nil {
+		t.Fatalf("LoadEffect: %v", err)
+	}
+	check(t, "StepID", loaded.StepID, "charge")
+	check(t, "Output", loaded.Output, `{"charge":"ch_1"}`)
+	if !loaded.CreatedAt.Equal(base) {
+		t.Errorf("CreatedAt: got %v, want %v", loaded.CreatedAt, base)
+	}
+	if _, err := store.LoadEffect("exec-1", "exec-1:refund"); !errors.Is(err, workflow.ErrEffectNotFound) {
+		t.Errorf("LoadEffect of an unknown effect: got %v, want ErrEffectNotFound", err)
+	}
+	if _, err := store.LoadEffect("exec-1", "exec-2:charge"); !errors.Is(err, workflow.ErrEffectNotFound) {
+		t.Errorf("LoadEffect of another execution's effect: got %v, want ErrEffectNotFound", err)
+	}
+
+	// Deleting an execution deletes its effects only
+	if err := store.DeleteState("exec-1"); err != nil {
+		t.Fatalf("DeleteState: %v", err)
+	}
+	if _, err := store.LoadEffect("exec-1", "exec-1:notify:email"); !errors.Is(err, workflow.ErrEffectNotFound) {
+		t.Errorf("LoadEffect after DeleteState: got %v, want ErrEffectNotFound", err)
+	}
+	if _, err := store.LoadEffect("exec-2", "exec-2:charge"); err != nil {
+		t.Errorf("LoadEffect of another execution after DeleteState: %v", err)
+	}
+
+	// So does cleaning it up
+	finished := execution("exec-2", "orders", workflow.StatusCompleted, base)
+	old := time.Now().Add(-48 * time.Hour)
+	finished.CompletedAt = &old
+	save(t, store, finished)
+	if _, err := store.CleanupOldStates(24 * time.Hour); err != nil {
+		t.Fatalf("CleanupOldStates: %v", err)
+	}
+	if _, err := store.LoadEffect("exec-2", "exec-2:charge"); !errors.Is(err, workflow.ErrEffectNotFound) {
+		t.Errorf("LoadEffect after CleanupOldStates: got %v, want ErrEffectNotFound", err)
+	}
+}
+
+func testDispatches(t *testing.T, store workflow.StateStore) {
+	if _, err := store.LoadDispatch("exec-1:charge"); !errors.Is(err, workflow.ErrDispatchNotFound) {
+		t.Errorf("LoadDispatch of an unknown dispatch: got %v, want ErrDispatchNotFound", err)
+	}
+
+	dispatches := []*workflow.StepDispatch{
+		{ID: "exec-1:charge", ExecutionID: "exec-1", WorkflowID: "orders", StepID: "charge", Status: workflow.DispatchQueued, LeaseUntil: base.Add(2 * time.Minute), CreatedAt: base},
+		{ID: "exec-2:charge", ExecutionID: "exec-2", WorkflowID: "orders", StepID: "charge", Status: workflow.DispatchRunning, Attempt: 1, Worker: "w1", LeaseUntil: base.Add(time.Minute), CreatedAt: base},
+		{ID: "exec-3:charge", ExecutionID: "exec-3", WorkflowID: "orders", StepID: "charge", Status: workflow.DispatchQueued, LeaseUntil: time.Now().Add(time.Hour), CreatedAt: base},
+	}
+	for _, dispatch := range dispatches {
+		if err := store.SaveDispatch(dispatch); err != nil {
+			t.Fatalf("SaveDispatch(%s): %v", dispatch.ID, err)
+		}
+	}
+
+	loaded, err := store.LoadDispatch("exec-2:charge")
+	if err != nil {
+		t.Fatalf("LoadDispatch: %v", err)
+	}
+	check(t, "Status", loaded.Status, workflow.DispatchRunning)
+	check(t, "Worker", loaded.Worker, "w1")
+	check(t, "Attempt", loaded.Attempt, 1)
+	if !loaded.LeaseUntil.Equal(base.Add(time.Minute)) {
+		t.Errorf("LeaseUntil: got %v, want %v", loaded.LeaseUntil, base.Add(time.Minute))
+	}
+
+	// Expired leases, earliest first
+	expired, err := store.ExpiredDispatches(time.Now(), 0)
+	if err != nil {
+		t.Fatalf("ExpiredDispatches: %v", err)
+	}
+	var ids []string
+	for _, dispatch := range expired {
+		ids = append(ids, dispatch.ID)
+	}
+	check(t, "expired dispatches", fmt.Sprint(ids), "[exec-2:charge exec-1:charge]")
+	if expired, err := store.ExpiredDispatches(time.Now(), 1); err != nil || len(expired) != 1 {
+		t.Errorf("ExpiredDispatches with a limit: got %d, %v, want 1", len(expired), err)
+	}
+
+	// Updates succeed from the stored version only
+	claimed := *loaded
+	claimed.Worker = "w2"
+	claimed.Attempt = 2
+	claimed.LeaseUntil = time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	if err := store.UpdateDispatch(&claimed); err != nil {
+		t.Fatalf("UpdateDispatch: %v", err)
+	}
+	check(t, "Version after UpdateDispatch", claimed.Version, loaded.Version+1)
+	stale := *loaded
+	stale.Worker = "w3"
+	if err := store.UpdateDispatch(&stale); !errors.Is(err, workflow.ErrDispatchConflict) {
+		t.Errorf("UpdateDispatch of a stale dispatch: got %v, want ErrDispatchConflict", err)
+	}
+	if reloaded, err := store.LoadDispatch("exec-2:charge"); err != nil {
+		t.Errorf("LoadDispatch after UpdateDispatch: %v", err)
+	} else {
+		check(t, "Worker after UpdateDispatch", reloaded.Worker, "w2")
+		check(t, "Version after UpdateDispatch", reloaded.Version, claimed.Version)
+	}
+	// The lease moved with the update
+	expired, err = store.ExpiredDispatches(time.Now(), 0)
+	if err != nil {
+		t.Fatalf("ExpiredDispatches: %v", err)
+	}
+	check(t, "expired dispatches after UpdateDispatch", len(expired), 1)
+
+	// Deleted dispatches are gone, and conflict with updates
+	if err := store.DeleteDispatch("exec-1:charge"); err != nil {
+		t.Fatalf("DeleteDispatch: %v", err)
+	}
+	if err := store.DeleteDispatch("exec-1:charge"); err != nil {
+		t.Errorf("DeleteDispatch of an unknown dispatch: %v", err)
+	}
+	if _, err := store.LoadDispatch("exec-1:charge"); !errors.Is(err, workflow.ErrDispatchNotFound) {
+		t.Errorf("LoadDispatch after DeleteDispatch: got %v, want ErrDispatchNotFound", err)
+	}
+	if err := store.UpdateDispatch(dispatches[0]); !errors.Is(err, workflow.ErrDispatchConflict) {
+		t.Errorf("UpdateDispatch of a deleted dispatch: got %v, want ErrDispatchConflict", err)
+	}
+	if expired, err := store.ExpiredDispatches(time.Now(), 0); err != nil || len(expired) != 0 {
+		t.Errorf("ExpiredDispatches after DeleteDispatch: got %d, %v, want 0", len(expired), err)
+	}
+}
+
+func testIdempotencyKeys(t *testing.T, store workflow.StateStore) {
+	claim := func(id, executionID string, expiresAt time.Time) string {
+		t.Helper()
+		claimed, err := store.ClaimIdempotencyKey(&workflow.IdempotencyKey{
+			ID:          id,
+			WorkflowID:  "orders",
+			ExecutionID: executionID,
+			ExpiresAt:   expiresAt,
+			CreatedAt:   time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("ClaimIdempotencyKey(%s): %v", id, err)
+		}
+		return claimed
+	}
+	later := time.Now().Add(time.Hour)
+
+	// The first claim keeps the key, until it expires
+	check(t, "first claim", claim("orders:evt-1", "exec-1", later), "exec-1")
+	check(t, "repeated claim", claim("orders:evt-1", "exec-2", later), "exec-1")
+	check(t, "claim of another key", claim("orders:evt-2", "exec-3", later), "exec-3")
+
+	// Keys already expired are not kept
+	check(t, "expired claim", claim("orders:evt-3", "exec-4", time.Now().Add(-time.Second)), "exec-4")
+	check(t, "claim after an expired one", claim("orders:evt-3", "exec-5", later), "exec-5")
+}
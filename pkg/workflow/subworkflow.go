@@ -0,0 +1,437 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/logger"
+)
+
+// subWorkflow is what a sub-workflow step starts. Step parameters:
+//
+//	workflow_id  ID of the registered workflow started
+//	await        wait for the child to finish, its variables becoming the
+//	             step's output (default true); false starts it and goes on
+//	inherit      start the child with a copy of the parent's variables
+//	input        child variables, literal or "$variable" of the parent
+//	output       parent variables set once the child completes, literal or
+//	             "$variable" of the child
+type subWorkflow struct {
+	workflowID string
+	await      bool
+	inherit    bool
+	input      map[string]interface{}
+	output     map[string]interface{}
+}
+
+func parseSubWorkflow(step *Step) (*subWorkflow, error) {
+	config := &subWorkflow{await: true}
+	config.workflowID, _ = step.Parameters["workflow_id"].(string)
+	if config.workflowID == "" {
+		return nil, fmt.Errorf("step %s starts a sub-workflow without a workflow_id", step.ID)
+	}
+	if await, ok := step.Parameters["await"].(bool); ok {
+		config.await = await
+	}
+	config.inherit, _ = step.Parameters["inherit"].(bool)
+
+	var err error
+	if config.input, err = mappingParameter(step.Parameters["input"]); err != nil {
+		return nil, fmt.Errorf("step %s: invalid input: %w", step.ID, err)
+	}
+	if config.output, err = mappingParameter(step.Parameters["output"]); err != nil {
+		return nil, fmt.Errorf("step %s: invalid output: %w", step.ID, err)
+	}
+	return config, nil
+}
+
+// mappingParameter reads variable mapping rules, as set by the builder or
+// decoded from a definition
+func mappingParameter(value interface{}) (map[string]interface{}, error) {
+	switch rules := value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return rules, nil
+	case map[string]string:
+		mapped := make(map[string]interface{}, len(rules))
+		for name, rule := range rules {
+			mapped[name] = rule
+		}
+		return mapped, nil
+	default:
+		return nil, fmt.Errorf("expected a map of variables, got %T", value)
+	}
+}
+
+// mapVariables evaluates mapping rules against the variables of source:
+// "$name" is the variable name, anything else a literal
+func mapVariables(source *ExecutionContext, rules map[string]interface{}) (map[string]interface{}, error) {
+	mapped := make(map[string]interface{}, len(rules))
+	for name, rule := range rules {
+		text, _ := rule.(string)
+		variable, isVariable := strings.CutPrefix(text, "$")
+		if !isVariable {
+			mapped[name] = rule
+			continue
+		}
+		value, exists := source.Get(variable)
+		if !exists {
+			return nil, fmt.Errorf("variable %s is not set", variable)
+		}
+		mapped[name] = value
+	}
+	return mapped, nil
+}
+
+// childInput returns the variables a child starts with
+func (s *subWorkflow) childInput(parent *ExecutionContext) (map[string]interface{}, error) {
+	input := make(map[string]interface{})
+	if s.inherit {
+		parent.mu.RLock()
+		for name, value := range parent.Variables {
+			input[name] = value
+		}
+		parent.mu.RUnlock()
+	}
+
+	mapped, err := mapVariables(parent, s.input)
+	if err != nil {
+		return nil, fmt.Errorf("sub-workflow %s input: %w", s.workflowID, err)
+	}
+	for name, value := range mapped {
+		input[name] = value
+	}
+	return input, nil
+}
+
+// result returns the output of a finished child, setting the parent's
+// output variables if it completed, or why it did not
+func (s *subWorkflow) result(parent *ExecutionContext, child *Execution) (interface{}, error) {
+	child.mu.RLock()
+	status, childErr := child.Status, child.Error
+	child.mu.RUnlock()
+
+	if status != StatusCompleted {
+		if childErr == nil {
+			childErr = errors.New(string(status))
+		}
+		return nil, fmt.Errorf("sub-workflow %s (%s) %s: %w", s.workflowID, child.ID, status, childErr)
+	}
+
+	mapped, err := mapVariables(child.Context, s.output)
+	if err != nil {
+		return nil, fmt.Errorf("sub-workflow %s output: %w", s.workflowID, err)
+	}
+	for name, value := range mapped {
+		parent.Set(name, value)
+	}
+
+	child.Context.mu.RLock()
+	defer child.Context.mu.RUnlock()
+	output := make(map[string]interface{}, len(child.Context.Variables))
+	for name, value := range child.Context.Variables {
+		output[name] = value
+	}
+	return output, nil
+}
+
+// newChild creates the child execution of a sub-workflow step
+func (s *subWorkflow) newChild(parent *ExecutionContext, step *Step) (*Execution, error) {
+	input, err := s.childInput(parent)
+	if err != nil {
+		return nil, err
+	}
+	child := newExecution(s.workflowID, input)
+	child.ParentExecutionID = parent.ExecutionID
+	child.ParentStepID = step.ID
+	child.Awaited = s.await
+	return child, nil
+}
+
+// runSubWorkflow runs the child of a sub-workflow step in memory: in the
+// step's goroutine when awaited, in its own otherwise
+func (e *WorkflowEngine) runSubWorkflow(ctx context.Context, step *Step, execCtx *ExecutionContext) (interface{}, error) {
+	config, err := parseSubWorkflow(step)
+	if err != nil {
+		return nil, err
+	}
+	workflow, err := e.GetWorkflow(config.workflowID)
+	if err != nil {
+		return nil, err
+	}
+	child, err := config.newChild(execCtx, step)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.executions[child.ID] = child
+	e.mu.Unlock()
+	e.getMetrics().started(child)
+
+	if !config.await {
+		// Outlives the step, and its timeout
+		go e.executeWorkflow(context.WithoutCancel(ctx), workflow, child)
+		return nil, nil
+	}
+
+	e.executeWorkflow(ctx, workflow, child)
+	child.mu.Lock()
+	child.Awaited = false
+	child.mu.Unlock()
+	return config.result(execCtx, child)
+}
+
+// ChildExecutions lists the executions started by an execution's
+// sub-workflow steps
+func (e *WorkflowEngine) ChildExecutions(executionID string) []*Execution {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	children := make([]*Execution, 0)
+	for _, execution := range e.executions {
+		if execution.ParentExecutionID == executionID {
+			children = append(children, execution)
+		}
+	}
+	return children
+}
+
+// startSubWorkflow starts the child of a sub-workflow step. An awaited
+// child pauses the execution until it finishes; a parent resumed before
+// then waits for the same child instead of starting another.
+func (e *StatefulWorkflowEngine) startSubWorkflow(execution *Execution, step *Step) (bool, error) {
+	config, err := parseSubWorkflow(step)
+	if err != nil {
+		return false, err
+	}
+	workflow, err := e.GetWorkflow(config.workflowID)
+	if err != nil {
+		return false, err
+	}
+
+	if config.await {
+		children, err := e.stateStore.ListChildren(execution.ID)
+		if err != nil {
+			return false, fmt.Errorf("failed to list child executions: %w", err)
+		}
+		for _, state := range children {
+			if state.ParentStepID != step.ID || !state.Awaited {
+				continue
+			}
+			child := state.execution()
+			if !child.done() {
+				if err := e.pause(execution); err != nil {
+					return false, err
+				}
+				return true, nil
+			}
+			// Finished without resuming the execution, or failed: the
+			// outcome is the step's, once
+			_, err := config.result(execution.Context, child)
+			if saveErr := e.delivered(child); saveErr != nil {
+				return false, fmt.Errorf("failed to save child execution: %w", saveErr)
+			}
+			return false, err
+		}
+	}
+
+	child, err := config.newChild(execution.Context, step)
+	if err != nil {
+		return false, err
+	}
+	// Paused first, so a child finishing at once finds it waiting
+	if config.await {
+		if err := e.pause(execution); err != nil {
+			return false, err
+		}
+	}
+	if err := e.startChild(workflow, child); err != nil {
+		if config.await {
+			execution.mu.Lock()
+			execution.Status = StatusRunning
+			execution.mu.Unlock()
+		}
+		return false, err
+	}
+
+	e.stateStore.LogEvent(execution.ID, step.ID, "sub_workflow_started", "Started sub-workflow "+workflow.ID, map[string]interface{}{
+		"workflow_id":        workflow.ID,
+		"child_execution_id": child.ID,
+		"await":              config.await,
+	})
+	return config.await, nil
+}
+
+// pause saves an execution as paused
+func (e *StatefulWorkflowEngine) pause(execution *Execution) error {
+	execution.mu.Lock()
+	execution.Status = StatusPaused
+	execution.mu.Unlock()
+	if err := e.stateStore.SaveState(execution); err != nil {
+		execution.mu.Lock()
+		execution.Status = StatusRunning
+		execution.mu.Unlock()
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
+
+// startChild saves and runs a child execution
+func (e *StatefulWorkflowEngine) startChild(workflow *Workflow, child *Execution) error {
+	if err := e.stateStore.SaveState(child); err != nil {
+		return fmt.Errorf("failed to save child execution: %w", err)
+	}
+	e.stateStore.LogEvent(child.ID, "", "started", "Workflow execution started", map[string]interface{}{
+		"parent_execution_id": child.ParentExecutionID,
+		"parent_step_id":      child.ParentStepID,
+	})
+
+	e.mu.Lock()
+	e.executions[child.ID] = child
+	e.mu.Unlock()
+	e.getMetrics().started(child)
+
+	// The parent's context may be gone by the time the child runs
+	ctx := context.Background()
+	go e.executeWorkflow(ctx, workflow, child)
+	go e.monitorExecution(ctx, child)
+	return nil
+}
+
+// executionFinished resumes the parent waiting for an execution that
+// completed, failed or was cancelled
+func (e *StatefulWorkflowEngine) executionFinished(execution *Execution) {
+	execution.mu.RLock()
+	parentID, awaited := execution.ParentExecutionID, execution.Awaited
+	execution.mu.RUnlock()
+	if parentID == "" || !awaited {
+		return
+	}
+
+	if err := e.deliverChild(execution); err != nil {
+		logger.Warn("Failed to resume the parent of a sub-workflow; resume it by hand", logger.Fields{
+			"execution_id":        execution.ID,
+			"parent_execution_id": parentID,
+			"error":               err.Error(),
+		})
+	}
+}
+
+// deliverChild completes the sub-workflow step of a paused parent with the
+// output of its child, and resumes it. A failed child fails the step once
+// the parent is resumed.
+func (e *StatefulWorkflowEngine) deliverChild(child *Execution) error {
+	// The parent reads the outcome from the store
+	if err := e.stateStore.SaveState(child); err != nil {
+		return err
+	}
+
+	parent, err := e.stateStore.LoadState(child.ParentExecutionID)
+	if errors.Is(err, ErrStateNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Cancelled or resumed by hand meanwhile
+	if parent.Status != StatusPaused || parent.CurrentStep != child.ParentStepID {
+		return nil
+	}
+
+	child.mu.RLock()
+	status := child.Status
+	child.mu.RUnlock()
+	if status == StatusCompleted {
+		workflow, err := e.GetWorkflow(parent.WorkflowID)
+		if err != nil {
+			return err
+		}
+		var step *Step
+		for i := range workflow.Steps {
+			if workflow.Steps[i].ID == child.ParentStepID {
+				step = &workflow.Steps[i]
+			}
+		}
+		if step == nil {
+			return fmt.Errorf("workflow %s has no step %s", workflow.ID, child.ParentStepID)
+		}
+		config, err := parseSubWorkflow(step)
+		if err != nil {
+			return err
+		}
+
+		// Failing to map the output leaves the child for the step to fail
+		if output, err := config.result(parent.Context, child); err == nil {
+			now := time.Now()
+			parent.StepResults[step.ID] = &StepResult{
+				StepID:      step.ID,
+				Status:      StatusCompleted,
+				Output:      output,
+				Attempts:    1,
+				StartedAt:   child.StartedAt,
+				CompletedAt: &now,
+				Duration:    now.Sub(child.StartedAt),
+			}
+			if err := e.stateStore.SaveState(parent); err != nil {
+				return err
+			}
+			if err := e.delivered(child); err != nil {
+				return err
+			}
+		}
+	}
+
+	e.stateStore.LogEvent(parent.ID, child.ParentStepID, "sub_workflow_"+string(status), "Sub-workflow "+string(status), map[string]interface{}{
+		"child_execution_id": child.ID,
+	})
+	return e.ResumeExecution(context.Background(), parent.ID)
+}
+
+// delivered saves that a child's outcome reached its parent, in the copy
+// its monitor may save again too
+func (e *StatefulWorkflowEngine) delivered(child *Execution) error {
+	for _, execution := range []*Execution{child, e.running(child.ID)} {
+		if execution != nil {
+			execution.mu.Lock()
+			execution.Awaited = false
+			execution.mu.Unlock()
+		}
+	}
+	return e.stateStore.SaveState(child)
+}
+
+// running returns the execution of this instance with an ID, if any
+func (e *StatefulWorkflowEngine) running(executionID string) *Execution {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.executions[executionID]
+}
+
+// cancelChildren cancels the children an execution waits for
+func (e *StatefulWorkflowEngine) cancelChildren(execution *Execution) error {
+	children, err := e.stateStore.ListChildren(execution.ID)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, state := range children {
+		if !state.Awaited || finished(state) {
+			continue
+		}
+		if err := e.CancelExecution(state.ExecutionID); err != nil {
+			errs = append(errs, fmt.Errorf("child execution %s: %w", state.ExecutionID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ChildExecutions lists the executions started by an execution's
+// sub-workflow steps, from the state store
+func (e *StatefulWorkflowEngine) ChildExecutions(executionID string) ([]*WorkflowState, error) {
+	return e.stateStore.ListChildren(executionID)
+}
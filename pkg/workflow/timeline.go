@@ -0,0 +1,233 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// timelineEvents is the number of recent events a stateful engine adds to
+// a timeline
+const timelineEvents = 200
+
+// Timeline is an execution's progress step by step: when each step
+// started and ended, how often it was attempted and, for the step an
+// unfinished execution is at, what it has been waiting for and since when
+type Timeline struct {
+	ExecutionID       string          `json:"execution_id"`
+	WorkflowID        string          `json:"workflow_id"`
+	ParentExecutionID string          `json:"parent_execution_id,omitempty"`
+	Status            WorkflowStatus  `json:"status"`
+	CurrentStep       string          `json:"current_step,omitempty"`
+	StartedAt         time.Time       `json:"started_at"`
+	CompletedAt       *time.Time      `json:"completed_at,omitempty"`
+	DurationMS        int64           `json:"duration_ms"` // So far, for unfinished executions
+	Error             string          `json:"error,omitempty"`
+	Steps             []TimelineStep  `json:"steps"`
+	Compensations     []TimelineStep  `json:"compensations,omitempty"`
+	Events            []TimelineEvent `json:"events,omitempty"` // Oldest first
+}
+
+// TimelineStep is a step of a timeline. Steps not reached are pending.
+type TimelineStep struct {
+	StepID      string         `json:"step_id"`
+	Name        string         `json:"name,omitempty"`
+	Type        StepType       `json:"type,omitempty"`
+	Status      WorkflowStatus `json:"status"`
+	StartedAt   *time.Time     `json:"started_at,omitempty"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+	OffsetMS    int64          `json:"offset_ms"` // From the start of the execution
+	DurationMS  int64          `json:"duration_ms"`
+	Attempts    int            `json:"attempts,omitempty"`
+	Retries     int            `json:"retries,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	Waiting     string         `json:"waiting,omitempty"` // The current step's latest event
+}
+
+// TimelineEvent is an event logged for an execution
+type TimelineEvent struct {
+	StepID  string                 `json:"step_id,omitempty"`
+	Type    string                 `json:"type"`
+	Message string                 `json:"message,omitempty"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+	At      time.Time              `json:"at"`
+}
+
+// finished reports whether the timeline's execution has ended
+func (t *Timeline) finished() bool {
+	for _, status := range terminalStatuses {
+		if t.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildTimeline returns the timeline of an execution of workflow, with the
+// execution's events as a state store lists them, newest first. workflow
+// may be nil when it is no longer registered; the timeline then lists the
+// steps run, in the order they started.
+func BuildTimeline(workflow *Workflow, execution *Execution, events []*EventLog, now time.Time) *Timeline {
+	execution.mu.RLock()
+	defer execution.mu.RUnlock()
+
+	timeline := &Timeline{
+		ExecutionID:       execution.ID,
+		WorkflowID:        execution.WorkflowID,
+		ParentExecutionID: execution.ParentExecutionID,
+		Status:            execution.Status,
+		CurrentStep:       execution.CurrentStep,
+		StartedAt:         execution.StartedAt,
+		CompletedAt:       execution.CompletedAt,
+		Steps:             make([]TimelineStep, 0, len(execution.StepResults)),
+	}
+	if execution.Error != nil {
+		timeline.Error = execution.Error.Error()
+	}
+	end := now
+	if execution.CompletedAt != nil {
+		end = *execution.CompletedAt
+	}
+	timeline.DurationMS = end.Sub(execution.StartedAt).Milliseconds()
+
+	// The latest event of each step, to tell what the current one waits for
+	latest := make(map[string]*EventLog)
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		if event.StepID != "" {
+			latest[event.StepID] = event
+		}
+		timeline.Events = append(timeline.Events, newTimelineEvent(event))
+	}
+
+	listed := make(map[string]bool, len(execution.StepResults))
+	if workflow != nil {
+		for _, step := range workflow.Steps {
+			listed[step.ID] = true
+			entry := TimelineStep{StepID: step.ID, Name: step.Name, Type: step.Type, Status: StatusPending}
+			if result := execution.StepResults[step.ID]; result != nil {
+				entry = timelineStep(entry, result, execution.StartedAt)
+			}
+			timeline.Steps = append(timeline.Steps, entry)
+		}
+	}
+
+	// Results of steps since removed from the workflow
+	var others []TimelineStep
+	for stepID, result := range execution.StepResults {
+		if !listed[stepID] && result != nil {
+			others = append(others, timelineStep(TimelineStep{StepID: stepID}, result, execution.StartedAt))
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].OffsetMS < others[j].OffsetMS })
+	timeline.Steps = append(timeline.Steps, others...)
+
+	if !timeline.finished() && execution.CurrentStep != "" {
+		for i := range timeline.Steps {
+			step := &timeline.Steps[i]
+			if step.StepID != execution.CurrentStep || step.Status == StatusCompleted || step.Status == StatusSkipped {
+				continue
+			}
+			step.Status = execution.Status
+			if event := latest[step.StepID]; event != nil {
+				step.Waiting = event.Message
+				if step.StartedAt == nil || event.Timestamp.After(*step.StartedAt) {
+					since := event.Timestamp
+					step.StartedAt = &since
+				}
+			}
+			if step.StartedAt != nil {
+				step.CompletedAt = nil
+				step.OffsetMS = step.StartedAt.Sub(execution.StartedAt).Milliseconds()
+				step.DurationMS = now.Sub(*step.StartedAt).Milliseconds()
+			}
+		}
+	}
+
+	for _, result := range execution.Compensations {
+		if result != nil {
+			timeline.Compensations = append(timeline.Compensations, timelineStep(TimelineStep{StepID: result.StepID}, result, execution.StartedAt))
+		}
+	}
+	return timeline
+}
+
+// timelineStep fills in a step's entry from its result
+func timelineStep(entry TimelineStep, result *StepResult, executionStart time.Time) TimelineStep {
+	started := result.StartedAt
+	entry.Status = result.Status
+	entry.StartedAt = &started
+	entry.CompletedAt = result.CompletedAt
+	entry.OffsetMS = started.Sub(executionStart).Milliseconds()
+	entry.DurationMS = result.Duration.Milliseconds()
+	if entry.DurationMS == 0 && result.CompletedAt != nil {
+		entry.DurationMS = result.CompletedAt.Sub(started).Milliseconds()
+	}
+	entry.Attempts = result.Attempts
+	if result.Attempts > 1 {
+		entry.Retries = result.Attempts - 1
+	}
+	if result.Error != nil {
+		entry.Error = result.Error.Error()
+	}
+	return entry
+}
+
+func newTimelineEvent(event *EventLog) TimelineEvent {
+	entry := TimelineEvent{
+		StepID:  event.StepID,
+		Type:    event.EventType,
+		Message: event.Message,
+		At:      event.Timestamp,
+	}
+	if event.Data != "" && event.Data != "null" {
+		json.Unmarshal([]byte(event.Data), &entry.Data)
+	}
+	return entry
+}
+
+// running reports whether an execution is running
+func (execution *Execution) running() bool {
+	execution.mu.RLock()
+	defer execution.mu.RUnlock()
+	return execution.Status == StatusRunning
+}
+
+// Graph returns the graph of a registered workflow
+func (e *WorkflowEngine) Graph(workflowID string) (*Graph, error) {
+	workflow, err := e.GetWorkflow(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	return BuildGraph(workflow), nil
+}
+
+// Timeline returns the timeline of an execution the engine holds
+func (e *WorkflowEngine) Timeline(executionID string) (*Timeline, error) {
+	execution, err := e.GetExecution(executionID)
+	if err != nil {
+		return nil, err
+	}
+	workflow, _ := e.GetWorkflow(execution.WorkflowID)
+	return BuildTimeline(workflow, execution, nil, time.Now()), nil
+}
+
+// Timeline returns the timeline of an execution with its logged events.
+// Running executions are read from memory, the rest from the state store,
+// where they may have been resumed or cancelled by another instance.
+func (e *StatefulWorkflowEngine) Timeline(executionID string) (*Timeline, error) {
+	execution, err := e.GetExecution(executionID)
+	if err != nil || !execution.running() {
+		if execution, err = e.stateStore.LoadState(executionID); err != nil {
+			return nil, fmt.Errorf("failed to load execution state: %w", err)
+		}
+	}
+
+	events, err := e.stateStore.GetEvents(executionID, timelineEvents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load execution events: %w", err)
+	}
+	workflow, _ := e.GetWorkflow(execution.WorkflowID)
+	return BuildTimeline(workflow, execution, events, time.Now()), nil
+}
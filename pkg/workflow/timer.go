@@ -0,0 +1,373 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"neonexcore/pkg/scheduler"
+)
+
+// TimerKind is what a timer does when it fires
+type TimerKind string
+
+const (
+	TimerWait TimerKind = "wait" // Resumes an execution paused in a wait step
+	TimerCron TimerKind = "cron" // Starts an execution of a workflow
+)
+
+// Timer is a persisted fire time, so waits and cron triggers survive
+// restarts
+type Timer struct {
+	ID          string    `gorm:"primaryKey"`
+	Kind        TimerKind `gorm:"index"`
+	WorkflowID  string    `gorm:"index"`
+	ExecutionID string    // Wait timers: the paused execution
+	StepID      string    // Wait timers: the step it waits in
+	Cron        string    // Cron timers: the schedule
+	Input       string    `gorm:"type:jsonb"` // Cron timers: JSON input of each execution
+	FireAt      time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
+
+// TableName keeps timers next to the other workflow tables
+func (Timer) TableName() string {
+	return "workflow_timers"
+}
+
+func waitTimerID(executionID string) string {
+	return "wait:" + executionID
+}
+
+func triggerTimerID(triggerID string) string {
+	return "cron:" + triggerID
+}
+
+// WakeTime returns when a wait step started at now ends, from its
+// parameters:
+//
+//	duration  time.Duration or a string such as "24h"
+//	until     time.Time or an RFC 3339 string
+//	cron      the next time of a cron expression, e.g. "0 9 * * MON"
+//
+// A step without any of them ends at once.
+func WakeTime(step *Step, now time.Time) (time.Time, error) {
+	if value, ok := step.Parameters["duration"]; ok {
+		duration, err := durationParameter(value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid wait duration: %w", err)
+		}
+		return now.Add(duration), nil
+	}
+
+	if value, ok := step.Parameters["until"]; ok {
+		switch v := value.(type) {
+		case time.Time:
+			return v, nil
+		case string:
+			until, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid wait time: %w", err)
+			}
+			return until, nil
+		default:
+			return time.Time{}, fmt.Errorf("invalid wait time: %v", value)
+		}
+	}
+
+	if value, ok := step.Parameters["cron"]; ok {
+		expr, _ := value.(string)
+		cron, err := scheduler.ParseCron(expr)
+		if err != nil {
+			return time.Time{}, err
+		}
+		next := cron.Next(now)
+		if next.IsZero() {
+			return time.Time{}, fmt.Errorf("cron %q never fires", expr)
+		}
+		return next, nil
+	}
+
+	return now, nil
+}
+
+// durationParameter reads a step parameter that is a time.Duration or a
+// string such as "24h", as definitions written to YAML or JSON have it
+func durationParameter(value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		return time.ParseDuration(v)
+	default:
+		return 0, fmt.Errorf("%v is not a duration", value)
+	}
+}
+
+// variableParameter reads a string step parameter that is either
+// literal or "$name", the value of an execution variable
+func variableParameter(execCtx *ExecutionContext, value interface{}) (string, error) {
+	text, _ := value.(string)
+	name, isVariable := strings.CutPrefix(text, "$")
+	if !isVariable {
+		return text, nil
+	}
+	variable, exists := execCtx.Get(name)
+	if !exists || variable == nil {
+		return "", fmt.Errorf("variable %s is not set", name)
+	}
+	return fmt.Sprint(variable), nil
+}
+
+// TimerService makes a stateful engine's wait steps durable and starts
+// executions on cron schedules. Fire times are kept in the engine's state
+// store: an execution reaching a wait step is saved as paused and resumed
+// after the step once its timer fires, by whichever process is running
+// then. Timers fire when FireDue runs, usually as a scheduler job added by
+// SetScheduler.
+type TimerService struct {
+	engine *StatefulWorkflowEngine
+	store  StateStore
+}
+
+// NewTimerService attaches a timer service to an engine. Wait steps of
+// executions started afterwards pause the execution instead of sleeping.
+func NewTimerService(engine *StatefulWorkflowEngine) *TimerService {
+	t := &TimerService{engine: engine, store: engine.stateStore}
+	engine.suspend[StepTypeWait] = t.pause
+	return t
+}
+
+// SetScheduler fires due timers every interval (default a second) on s,
+// starting with those that came due while the process was down. With a
+// leader check on s, timers fire on one instance only.
+func (t *TimerService) SetScheduler(s *scheduler.Scheduler, every time.Duration) error {
+	if every <= 0 {
+		every = time.Second
+	}
+	return s.Replace(scheduler.Job{
+		Name:       "workflow:timers",
+		Interval:   every,
+		RunAtStart: true,
+		Run: func(ctx context.Context) error {
+			_, err := t.FireDue(ctx)
+			return err
+		},
+	})
+}
+
+// AddTrigger starts an execution of a workflow with input at every time
+// of a cron expression. Adding a trigger again, e.g. at each startup,
+// keeps its next fire time unless the expression changed, so a time that
+// passed while the process was down still fires once.
+func (t *TimerService) AddTrigger(id, workflowID, expr string, input map[string]interface{}) error {
+	cron, err := scheduler.ParseCron(expr)
+	if err != nil {
+		return err
+	}
+	if input == nil {
+		input = make(map[string]interface{})
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("failed to encode trigger input: %w", err)
+	}
+
+	timer := &Timer{
+		ID:         triggerTimerID(id),
+		Kind:       TimerCron,
+		WorkflowID: workflowID,
+		Cron:       cron.String(),
+		Input:      string(data),
+		FireAt:     cron.Next(time.Now()),
+		CreatedAt:  time.Now(),
+	}
+	if timer.FireAt.IsZero() {
+		return fmt.Errorf("cron %q never fires", expr)
+	}
+
+	existing, err := t.store.ListTimers(workflowID)
+	if err != nil {
+		return err
+	}
+	for _, previous := range existing {
+		if previous.ID == timer.ID && previous.Cron == timer.Cron {
+			timer.FireAt, timer.CreatedAt = previous.FireAt, previous.CreatedAt
+		}
+	}
+	return t.store.SaveTimer(timer)
+}
+
+// RemoveTrigger removes a cron trigger
+func (t *TimerService) RemoveTrigger(id string) error {
+	return t.store.DeleteTimer(triggerTimerID(id))
+}
+
+// Triggers lists cron triggers by next fire time, optionally of one
+// workflow
+func (t *TimerService) Triggers(workflowID string) ([]*Timer, error) {
+	timers, err := t.store.ListTimers(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	triggers := make([]*Timer, 0, len(timers))
+	for _, timer := range timers {
+		if timer.Kind == TimerCron {
+			triggers = append(triggers, timer)
+		}
+	}
+	return triggers, nil
+}
+
+// FireDue fires the timers that are due, returning how many fired. A
+// timer that fails stays due and is retried on the next call, except a
+// trigger, whose time is skipped.
+func (t *TimerService) FireDue(ctx context.Context) (int, error) {
+	timers, err := t.store.DueTimers(time.Now(), 100)
+	if err != nil {
+		return 0, err
+	}
+
+	fired := 0
+	var errs []error
+	for _, timer := range timers {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := t.fire(timer); err != nil {
+			errs = append(errs, fmt.Errorf("timer %s: %w", timer.ID, err))
+			continue
+		}
+		fired++
+	}
+	return fired, errors.Join(errs...)
+}
+
+func (t *TimerService) fire(timer *Timer) error {
+	switch timer.Kind {
+	case TimerWait:
+		return t.resume(timer)
+	case TimerCron:
+		return t.trigger(timer)
+	default:
+		return t.store.DeleteTimer(timer.ID)
+	}
+}
+
+// pause saves an execution reaching a wait step as paused, with a timer
+// to resume it
+func (t *TimerService) pause(execution *Execution, step *Step) (bool, error) {
+	now := time.Now()
+	fireAt, err := WakeTime(step, now)
+	if err != nil || !fireAt.After(now) {
+		return false, err
+	}
+
+	timer := &Timer{
+		ID:          waitTimerID(execution.ID),
+		Kind:        TimerWait,
+		WorkflowID:  execution.WorkflowID,
+		ExecutionID: execution.ID,
+		StepID:      step.ID,
+		FireAt:      fireAt,
+		CreatedAt:   now,
+	}
+	if err := t.store.SaveTimer(timer); err != nil {
+		return false, fmt.Errorf("failed to save timer: %w", err)
+	}
+
+	execution.mu.Lock()
+	execution.Status = StatusPaused
+	execution.mu.Unlock()
+	if err := t.store.SaveState(execution); err != nil {
+		t.store.DeleteTimer(timer.ID)
+		execution.mu.Lock()
+		execution.Status = StatusRunning
+		execution.mu.Unlock()
+		return false, fmt.Errorf("failed to save state: %w", err)
+	}
+
+	t.store.LogEvent(execution.ID, step.ID, "waiting", "Waiting until "+fireAt.Format(time.RFC3339), map[string]interface{}{
+		"fire_at": fireAt,
+	})
+	return true, nil
+}
+
+// resume completes the wait step of a paused execution and resumes it
+func (t *TimerService) resume(timer *Timer) error {
+	execution, err := t.store.LoadState(timer.ExecutionID)
+	if errors.Is(err, ErrStateNotFound) {
+		return t.store.DeleteTimer(timer.ID)
+	}
+	if err != nil {
+		return err
+	}
+	// Cancelled or resumed by hand meanwhile
+	if execution.Status != StatusPaused || execution.CurrentStep != timer.StepID {
+		return t.store.DeleteTimer(timer.ID)
+	}
+
+	now := time.Now()
+	execution.StepResults[timer.StepID] = &StepResult{
+		StepID:      timer.StepID,
+		Status:      StatusCompleted,
+		Attempts:    1,
+		StartedAt:   timer.CreatedAt,
+		CompletedAt: &now,
+		Duration:    now.Sub(timer.CreatedAt),
+	}
+	if err := t.store.SaveState(execution); err != nil {
+		return err
+	}
+	if err := t.engine.ResumeExecution(context.Background(), execution.ID); err != nil {
+		return err
+	}
+	return t.store.DeleteTimer(timer.ID)
+}
+
+// trigger moves a cron timer to its next time and starts an execution
+func (t *TimerService) trigger(timer *Timer) error {
+	cron, err := scheduler.ParseCron(timer.Cron)
+	if err != nil {
+		t.store.DeleteTimer(timer.ID)
+		return err
+	}
+
+	// Times missed while the process was down fire once, not once each
+	scheduled := timer.FireAt
+	next := cron.Next(time.Now())
+	if next.IsZero() {
+		if err := t.store.DeleteTimer(timer.ID); err != nil {
+			return err
+		}
+	} else {
+		timer.FireAt = next
+		if err := t.store.SaveTimer(timer); err != nil {
+			return err
+		}
+	}
+
+	var input map[string]interface{}
+	if timer.Input != "" {
+		if err := json.Unmarshal([]byte(timer.Input), &input); err != nil {
+			return fmt.Errorf("failed to decode trigger input: %w", err)
+		}
+	}
+	if input == nil {
+		input = make(map[string]interface{})
+	}
+	// Instances firing the same time start one execution
+	execution, err := t.engine.StartExecution(context.Background(), timer.WorkflowID, input,
+		WithIdempotencyKey(timer.ID+"@"+scheduled.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return err
+	}
+	t.store.LogEvent(execution.ID, "", "triggered", "Started by trigger "+strings.TrimPrefix(timer.ID, "cron:"), map[string]interface{}{
+		"cron":      timer.Cron,
+		"scheduled": scheduled,
+	})
+	return nil
+}
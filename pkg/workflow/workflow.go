@@ -2,11 +2,20 @@ package workflow
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 )
 
+var (
+	// ErrWorkflowNotFound is returned for workflows not registered
+	ErrWorkflowNotFound = errors.New("workflow not found")
+	// ErrExecutionNotFound is returned for executions the engine does not
+	// hold
+	ErrExecutionNotFound = errors.New("execution not found")
+)
+
 // WorkflowStatus represents workflow execution status
 type WorkflowStatus string
 
@@ -17,6 +26,10 @@ const (
 	StatusFailed    WorkflowStatus = "failed"
 	StatusCancelled WorkflowStatus = "cancelled"
 	StatusPaused    WorkflowStatus = "paused"
+	// A step whose When condition was false
+	StatusSkipped WorkflowStatus = "skipped"
+	// Rolling back the steps completed before a failure
+	StatusCompensating WorkflowStatus = "compensating"
 )
 
 // Workflow represents a workflow definition
@@ -27,6 +40,10 @@ type Workflow struct {
 	Version     string
 	Steps       []Step
 	Config      map[string]interface{}
+	Rollback    bool // Compensate completed steps when a step fails
+	// Executions running at once, others wait to start; 0 is unlimited.
+	// Shared by instances through a RedisLimiter, see SetLimiter.
+	MaxConcurrent int
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
@@ -37,11 +54,15 @@ type Step struct {
 	Name         string
 	Type         StepType
 	Action       ActionFunc
+	Compensate   ActionFunc // Undoes the action when a later step fails
 	Condition    ConditionFunc
+	When         ConditionFunc // Runs the step only when true; it is skipped otherwise
 	OnSuccess    []string // Next step IDs on success
 	OnFailure    []string // Next step IDs on failure
 	RetryPolicy  *RetryPolicy
 	Timeout      time.Duration
+	Concurrency  int        // Runs of the step at once across executions; 0 is unlimited
+	RateLimit    *RateLimit // Attempts a second across executions
 	Parameters   map[string]interface{}
 	Metadata     map[string]string
 }
@@ -55,7 +76,14 @@ const (
 	StepTypeParallel  StepType = "parallel"
 	StepTypeLoop      StepType = "loop"
 	StepTypeWait      StepType = "wait"
-	StepTypeSubflow   StepType = "subflow"
+	StepTypeSubflow   StepType = "subflow" // Deprecated: use StepTypeSubWorkflow
+	// Starts another registered workflow as a child execution, see
+	// StepBuilder.SubWorkflow
+	StepTypeSubWorkflow StepType = "sub_workflow"
+	// Waits for a person to complete a task, see TaskService
+	StepTypeHumanTask StepType = "human_task"
+	// Waits for an external event, see StatefulWorkflowEngine.Signal
+	StepTypeWaitForSignal StepType = "wait_for_signal"
 )
 
 // ActionFunc function to execute for a step
@@ -73,18 +101,24 @@ type RetryPolicy struct {
 
 // Execution represents a workflow execution instance
 type Execution struct {
-	ID           string
-	WorkflowID   string
-	Status       WorkflowStatus
-	CurrentStep  string
-	Input        map[string]interface{}
-	Output       map[string]interface{}
-	Context      *ExecutionContext
-	StepResults  map[string]*StepResult
-	StartedAt    time.Time
-	CompletedAt  *time.Time
-	Error        error
-	mu           sync.RWMutex
+	ID            string
+	WorkflowID    string
+	Status        WorkflowStatus
+	CurrentStep   string
+	Input         map[string]interface{}
+	Output        map[string]interface{}
+	Context       *ExecutionContext
+	StepResults   map[string]*StepResult
+	Compensations []*StepResult // Run by a rollback, in order
+	StartedAt     time.Time
+	CompletedAt   *time.Time
+	Error         error
+	mu            sync.RWMutex
+
+	// Set on the child executions of sub-workflow steps
+	ParentExecutionID string
+	ParentStepID      string
+	Awaited           bool // The parent waits for the result, until it is delivered
 }
 
 // ExecutionContext context for workflow execution
@@ -114,6 +148,31 @@ type WorkflowEngine struct {
 	workflows  map[string]*Workflow
 	executions map[string]*Execution
 	mu         sync.RWMutex
+
+	// suspend pauses executions at steps of a type until something
+	// outside resumes them, e.g. a TimerService at wait steps. It
+	// persists what the step waits for and reports whether the execution
+	// stops.
+	suspend map[StepType]func(execution *Execution, step *Step) (bool, error)
+
+	// effects records the side effects of steps, see StepEffects; nil
+	// keeps them for one step run only
+	effects effectStore
+
+	// finished is called once an execution run by executeWorkflow has
+	// completed, failed or been cancelled
+	finished func(execution *Execution)
+
+	// limiter enforces concurrency and rate limits, see SetLimiter
+	limiter Limiter
+
+	// starts keeps the idempotency keys of executions, see
+	// WithIdempotencyKey
+	starts idempotencyStore
+
+	// metrics records executions and steps, see SetMetrics; nil records
+	// nothing
+	metrics *engineMetrics
 }
 
 // NewWorkflowEngine creates a new workflow engine
@@ -121,6 +180,9 @@ func NewWorkflowEngine() *WorkflowEngine {
 	return &WorkflowEngine{
 		workflows:  make(map[string]*Workflow),
 		executions: make(map[string]*Execution),
+		suspend:    make(map[StepType]func(*Execution, *Step) (bool, error)),
+		limiter:    NewMemoryLimiter(),
+		starts:     &memoryIdempotency{keys: make(map[string]*IdempotencyKey)},
 	}
 }
 
@@ -136,6 +198,7 @@ func (e *WorkflowEngine) RegisterWorkflow(workflow *Workflow) error {
 	e.workflows[workflow.ID] = workflow
 	e.mu.Unlock()
 
+	e.getMetrics().registered(workflow)
 	return nil
 }
 
@@ -146,21 +209,58 @@ func (e *WorkflowEngine) GetWorkflow(workflowID string) (*Workflow, error) {
 
 	workflow, exists := e.workflows[workflowID]
 	if !exists {
-		return nil, fmt.Errorf("workflow not found: %s", workflowID)
+		return nil, fmt.Errorf("%w: %s", ErrWorkflowNotFound, workflowID)
 	}
 
 	return workflow, nil
 }
 
-// StartExecution starts a workflow execution
-func (e *WorkflowEngine) StartExecution(ctx context.Context, workflowID string, input map[string]interface{}) (*Execution, error) {
-	workflow, err := e.GetWorkflow(workflowID)
+// StartExecution starts a workflow execution. With WithIdempotencyKey, a
+// key already used returns the execution it started instead.
+func (e *WorkflowEngine) StartExecution(ctx context.Context, workflowID string, input map[string]interface{}, opts ...StartOption) (*Execution, error) {
+	execution, duplicate, err := e.start(ctx, workflowID, input, opts)
 	if err != nil {
 		return nil, err
 	}
+	if duplicate != "" {
+		return e.GetExecution(duplicate)
+	}
+	return execution, nil
+}
+
+// start runs a new execution, unless its idempotency key was claimed by an
+// earlier start, whose execution ID it returns instead
+func (e *WorkflowEngine) start(ctx context.Context, workflowID string, input map[string]interface{}, opts []StartOption) (*Execution, string, error) {
+	workflow, err := e.GetWorkflow(workflowID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	execution := newExecution(workflowID, input)
+	claimed, err := e.claimStart(execution, newStartOptions(opts))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+	if claimed != execution.ID {
+		return nil, claimed, nil
+	}
+
+	e.mu.Lock()
+	e.executions[execution.ID] = execution
+	e.mu.Unlock()
+	e.getMetrics().started(execution)
+
+	// Execute workflow in background
+	go e.executeWorkflow(ctx, workflow, execution)
 
-	execution := &Execution{
-		ID:          fmt.Sprintf("exec-%d", time.Now().UnixNano()),
+	return execution, "", nil
+}
+
+// newExecution creates a running execution of a workflow
+func newExecution(workflowID string, input map[string]interface{}) *Execution {
+	executionID := fmt.Sprintf("exec-%d", time.Now().UnixNano())
+	return &Execution{
+		ID:          executionID,
 		WorkflowID:  workflowID,
 		Status:      StatusRunning,
 		Input:       input,
@@ -169,25 +269,26 @@ func (e *WorkflowEngine) StartExecution(ctx context.Context, workflowID string,
 		StartedAt:   time.Now(),
 		Context: &ExecutionContext{
 			WorkflowID:  workflowID,
-			ExecutionID: fmt.Sprintf("exec-%d", time.Now().UnixNano()),
+			ExecutionID: executionID,
 			Variables:   input,
 			StepResults: make(map[string]interface{}),
 			Metadata:    make(map[string]string),
 		},
 	}
-
-	e.mu.Lock()
-	e.executions[execution.ID] = execution
-	e.mu.Unlock()
-
-	// Execute workflow in background
-	go e.executeWorkflow(ctx, workflow, execution)
-
-	return execution, nil
 }
 
 // executeWorkflow executes a workflow
 func (e *WorkflowEngine) executeWorkflow(ctx context.Context, workflow *Workflow, execution *Execution) {
+	// Runs last, after a panic is recovered
+	defer func() {
+		if !execution.done() {
+			return
+		}
+		e.getMetrics().finished(execution)
+		if e.finished != nil {
+			e.finished(execution)
+		}
+	}()
 	defer func() {
 		if r := recover(); r != nil {
 			execution.mu.Lock()
@@ -199,8 +300,24 @@ func (e *WorkflowEngine) executeWorkflow(ctx context.Context, workflow *Workflow
 		}
 	}()
 
+	// Held until the execution finishes or pauses
+	release, err := e.acquireExecution(ctx, workflow)
+	if err != nil {
+		execution.mu.Lock()
+		execution.Status = StatusFailed
+		if ctx.Err() != nil {
+			execution.Status = StatusCancelled
+		}
+		execution.Error = err
+		now := time.Now()
+		execution.CompletedAt = &now
+		execution.mu.Unlock()
+		return
+	}
+	defer release()
+
 	// Execute steps in order
-	for i, step := range workflow.Steps {
+	for _, step := range workflow.Steps {
 		select {
 		case <-ctx.Done():
 			execution.mu.Lock()
@@ -214,10 +331,27 @@ func (e *WorkflowEngine) executeWorkflow(ctx context.Context, workflow *Workflow
 		}
 
 		execution.mu.Lock()
+		done := execution.StepResults[step.ID]
 		execution.CurrentStep = step.ID
 		execution.mu.Unlock()
 
-		result := e.executeStep(ctx, &step, execution.Context)
+		// Completed or skipped before the execution was resumed
+		if done != nil && (done.Status == StatusCompleted || done.Status == StatusSkipped) {
+			continue
+		}
+
+		var result *StepResult
+		if run, err := step.runs(execution.Context); err != nil || !run {
+			result = skipResult(&step, err)
+		} else if suspend := e.suspend[step.Type]; suspend != nil {
+			paused, err := suspend(execution, &step)
+			if paused {
+				return
+			}
+			result = waitResult(&step, err)
+		} else {
+			result = e.executeStep(ctx, &step, execution.Context)
+		}
 
 		execution.mu.Lock()
 		execution.StepResults[step.ID] = result
@@ -230,25 +364,62 @@ func (e *WorkflowEngine) executeWorkflow(ctx context.Context, workflow *Workflow
 				continue
 			}
 
+			err := result.Error
+			if workflow.Rollback {
+				if rollbackErr := e.compensate(ctx, workflow, execution); rollbackErr != nil {
+					err = fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+				}
+			}
+
 			execution.mu.Lock()
 			execution.Status = StatusFailed
-			execution.Error = result.Error
+			execution.Error = err
 			now := time.Now()
 			execution.CompletedAt = &now
 			execution.mu.Unlock()
 			return
 		}
+	}
 
-		// Check if this is the last step
-		if i == len(workflow.Steps)-1 {
-			execution.mu.Lock()
-			execution.Status = StatusCompleted
-			now := time.Now()
-			execution.CompletedAt = &now
-			execution.mu.Unlock()
-			return
-		}
+	execution.mu.Lock()
+	execution.Status = StatusCompleted
+	now := time.Now()
+	execution.CompletedAt = &now
+	execution.mu.Unlock()
+}
+
+// waitResult is the result of a step that did not suspend the execution,
+// such as a wait step already due, or that failed to
+func waitResult(step *Step, err error) *StepResult {
+	now := time.Now()
+	result := &StepResult{StepID: step.ID, Status: StatusCompleted, Attempts: 1, StartedAt: now, CompletedAt: &now}
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err
+	}
+	return result
+}
+
+// runs evaluates the step's When condition
+func (step *Step) runs(execCtx *ExecutionContext) (bool, error) {
+	if step.When == nil {
+		return true, nil
 	}
+	run, err := step.When(execCtx)
+	if err != nil {
+		return false, fmt.Errorf("step %s: when: %w", step.ID, err)
+	}
+	return run, nil
+}
+
+// skipResult is the result of a step whose When condition was false, or
+// failed to evaluate
+func skipResult(step *Step, err error) *StepResult {
+	result := waitResult(step, err)
+	if err == nil {
+		result.Status = StatusSkipped
+	}
+	return result
 }
 
 // executeStep executes a single step
@@ -258,6 +429,7 @@ func (e *WorkflowEngine) executeStep(ctx context.Context, step *Step, execCtx *E
 		Status:    StatusRunning,
 		StartedAt: time.Now(),
 	}
+	defer func() { e.getMetrics().step(execCtx.WorkflowID, step, result) }()
 
 	// Apply timeout if configured
 	if step.Timeout > 0 {
@@ -266,6 +438,9 @@ func (e *WorkflowEngine) executeStep(ctx context.Context, step *Step, execCtx *E
 		defer cancel()
 	}
 
+	// Shared by the attempts, so a retry skips effects already done
+	ctx = withEffects(ctx, e.effects, execCtx, step.ID)
+
 	// Execute with retry policy
 	maxAttempts := 1
 	if step.RetryPolicy != nil {
@@ -276,9 +451,15 @@ func (e *WorkflowEngine) executeStep(ctx context.Context, step *Step, execCtx *E
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		result.Attempts = attempt
 
+		// Waits for the step's concurrency slot and rate limit
+		release, err := e.acquireStep(ctx, step, execCtx.WorkflowID)
+		if err != nil {
+			lastErr = err
+			break
+		}
+
 		// Execute based on step type
 		var output interface{}
-		var err error
 
 		switch step.Type {
 		case StepTypeTask:
@@ -297,17 +478,28 @@ func (e *WorkflowEngine) executeStep(ctx context.Context, step *Step, execCtx *E
 			}
 
 		case StepTypeWait:
-			if duration, ok := step.Parameters["duration"].(time.Duration); ok {
-				time.Sleep(duration)
+			var wakeAt time.Time
+			if wakeAt, err = WakeTime(step, time.Now()); err == nil {
+				select {
+				case <-time.After(time.Until(wakeAt)):
+				case <-ctx.Done():
+					err = ctx.Err()
+				}
 			}
 
-		case StepTypeSubflow:
-			// Execute subflow (simplified)
-			output = map[string]interface{}{"subflow": "completed"}
+		case StepTypeHumanTask:
+			err = fmt.Errorf("human task %s needs a task service", step.ID)
+
+		case StepTypeWaitForSignal:
+			err = fmt.Errorf("step %s waits for a signal, which needs a stateful engine", step.ID)
+
+		case StepTypeSubWorkflow, StepTypeSubflow:
+			output, err = e.runSubWorkflow(ctx, step, execCtx)
 
 		default:
 			err = fmt.Errorf("unknown step type: %s", step.Type)
 		}
+		release()
 
 		if err == nil {
 			result.Status = StatusCompleted
@@ -328,13 +520,7 @@ func (e *WorkflowEngine) executeStep(ctx context.Context, step *Step, execCtx *E
 
 		// Retry with backoff
 		if attempt < maxAttempts && step.RetryPolicy != nil {
-			delay := step.RetryPolicy.Delay
-			if step.RetryPolicy.BackoffRate > 0 {
-				for i := 1; i < attempt; i++ {
-					delay = time.Duration(float64(delay) * step.RetryPolicy.BackoffRate)
-				}
-			}
-			time.Sleep(delay)
+			time.Sleep(step.RetryPolicy.backoff(attempt))
 		}
 	}
 
@@ -347,6 +533,19 @@ func (e *WorkflowEngine) executeStep(ctx context.Context, step *Step, execCtx *E
 	return result
 }
 
+// done reports whether an execution has completed, failed or been
+// cancelled
+func (execution *Execution) done() bool {
+	execution.mu.RLock()
+	defer execution.mu.RUnlock()
+	for _, status := range terminalStatuses {
+		if execution.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
 // GetExecution gets an execution by ID
 func (e *WorkflowEngine) GetExecution(executionID string) (*Execution, error) {
 	e.mu.RLock()
@@ -354,7 +553,7 @@ func (e *WorkflowEngine) GetExecution(executionID string) (*Execution, error) {
 
 	execution, exists := e.executions[executionID]
 	if !exists {
-		return nil, fmt.Errorf("execution not found: %s", executionID)
+		return nil, fmt.Errorf("%w: %s", ErrExecutionNotFound, executionID)
 	}
 
 	return execution, nil
@@ -415,10 +614,11 @@ func (e *WorkflowEngine) DeleteWorkflow(workflowID string) error {
 	defer e.mu.Unlock()
 
 	if _, exists := e.workflows[workflowID]; !exists {
-		return fmt.Errorf("workflow not found: %s", workflowID)
+		return fmt.Errorf("%w: %s", ErrWorkflowNotFound, workflowID)
 	}
 
 	delete(e.workflows, workflowID)
+	e.metrics.deleted(workflowID)
 	return nil
 }
 